@@ -0,0 +1,341 @@
+// Package alert evaluates the operator-defined rules on the server metrics and alerts the
+// operators when they start or stop firing. Alerts are independent of the players notifications.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/logger"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/pkg/errors"
+)
+
+// Metrics the rules can be defined on.
+const (
+	// MetricPoolSize is the number of sats bet in the lottery in progress, across all pools
+	MetricPoolSize = "pool_size"
+	// MetricPayoutFailures is the number of outgoing payments that failed
+	MetricPayoutFailures = "payout_failures"
+	// MetricRoutingFees is the number of sats paid in routing fees by the outgoing payments
+	MetricRoutingFees = "routing_fees"
+	// MetricErrors is the number of errors reported by the loggers
+	MetricErrors = "errors"
+	// MetricDrawLatency is the number of seconds the last draw took
+	MetricDrawLatency = "draw_latency"
+	// MetricPrizeLiabilities is the number of sats owed to the winners in unclaimed prizes
+	MetricPrizeLiabilities = "prize_liabilities"
+)
+
+// defaultWindow is the period the counter metrics take into account if not configured.
+const defaultWindow = time.Minute
+
+// Alert is the message sent to the operators when a rule starts or stops firing.
+type Alert struct {
+	Rule      string  `json:"rule"`
+	Metric    string  `json:"metric"`
+	Message   string  `json:"message"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	Timestamp int64   `json:"timestamp"`
+	Resolved  bool    `json:"resolved"`
+}
+
+// Node is the part of the lightning node the metrics are taken from.
+type Node interface {
+	lightning.PaymentSender
+	lightning.NodeInfo
+}
+
+// Engine evaluates the alerting rules periodically.
+type Engine struct {
+	db         *db.DB
+	lnd        Node
+	logger     *logger.Logger
+	now        func() time.Time
+	errorCount func(label string) uint64
+	channels   []channel
+	rules      []*rule
+	interval   time.Duration
+	enabled    bool
+	// Counters updated with the outgoing payments
+	payoutFailures atomic.Uint64
+	routingFees    atomic.Uint64
+}
+
+// rule is an alerting rule and its evaluation state.
+type rule struct {
+	config.AlertRule
+	samples []sample
+	firing  bool
+}
+
+// sample is the value of a counter at a point in time.
+type sample struct {
+	at    time.Time
+	value float64
+}
+
+// New returns a new alerting rules engine.
+func New(config config.Alerts, db *db.DB, lnd Node, notifier notification.Notifier) (*Engine, error) {
+	errorCount := logger.ErrorCount
+	logger, err := logger.New(config.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	var channels []channel
+	if config.Channels.TelegramChatID != 0 {
+		channels = append(channels, &telegramChannel{
+			notifier: notifier,
+			chatID:   config.Channels.TelegramChatID,
+		})
+	}
+	if config.Channels.Webhook != "" {
+		channels = append(channels, &webhookChannel{client: client, url: config.Channels.Webhook})
+	}
+	if config.Channels.PagerDuty != "" {
+		channels = append(channels, &pagerDutyChannel{
+			client:     client,
+			url:        config.Channels.PagerDuty,
+			routingKey: config.Channels.RoutingKey,
+		})
+	}
+
+	rules := make([]*rule, 0, len(config.Rules))
+	for _, r := range config.Rules {
+		if r.Window == 0 {
+			r.Window = defaultWindow
+		}
+		rules = append(rules, &rule{AlertRule: r})
+	}
+
+	return &Engine{
+		db:         db,
+		lnd:        lnd,
+		logger:     logger,
+		now:        time.Now,
+		errorCount: errorCount,
+		channels:   channels,
+		rules:      rules,
+		interval:   config.Interval,
+		enabled:    config.Enabled,
+	}, nil
+}
+
+// Start tracks the outgoing payments and executes the loop that evaluates the rules.
+func (e *Engine) Start(ctx context.Context) error {
+	if !e.enabled {
+		e.logger.Info("Alerts disabled")
+		return nil
+	}
+
+	stream, err := e.lnd.SubscribePayments(ctx)
+	if err != nil {
+		return errors.Wrap(err, "subscribing to payments stream")
+	}
+	go e.trackPayments(stream)
+
+	go func() {
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.evaluate(ctx)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// trackPayments counts the outgoing payments that failed and the fees paid by the ones that
+// succeeded.
+func (e *Engine) trackPayments(stream lightning.Stream[*lnrpc.Payment]) {
+	for {
+		payment, err := stream.Recv()
+		if err != nil {
+			e.logger.Error(errors.Wrap(err, "receiving events from payments stream"))
+			return
+		}
+
+		switch payment.Status {
+		case lnrpc.Payment_FAILED:
+			e.payoutFailures.Add(1)
+		case lnrpc.Payment_SUCCEEDED:
+			e.routingFees.Add(uint64(payment.FeeSat))
+		}
+	}
+}
+
+// evaluate alerts the operators about the rules that started or stopped firing.
+func (e *Engine) evaluate(ctx context.Context) {
+	now := e.now()
+
+	for _, r := range e.rules {
+		value, err := e.value(ctx, r, now)
+		if err != nil {
+			e.logger.Error(errors.Wrapf(err, "evaluating alert rule %q", r.Name))
+			continue
+		}
+
+		firing := value > r.Threshold
+		if r.Below {
+			firing = value < r.Threshold
+		}
+
+		if firing == r.firing {
+			continue
+		}
+		r.firing = firing
+
+		alert := Alert{
+			Rule:      r.Name,
+			Metric:    r.Metric,
+			Value:     value,
+			Threshold: r.Threshold,
+			Timestamp: now.Unix(),
+			Resolved:  !firing,
+		}
+		alert.Message = message(r, value)
+		e.send(ctx, alert)
+	}
+}
+
+// value returns the current value of the rule metric.
+func (e *Engine) value(ctx context.Context, r *rule, now time.Time) (float64, error) {
+	switch r.Metric {
+	case MetricPoolSize:
+		return e.poolSize()
+	case MetricPayoutFailures:
+		return r.delta(now, float64(e.payoutFailures.Load())), nil
+	case MetricRoutingFees:
+		return r.delta(now, float64(e.routingFees.Load())), nil
+	case MetricErrors:
+		return r.delta(now, float64(e.errorCount(r.Label))), nil
+	case MetricDrawLatency:
+		return e.drawLatency()
+	case MetricPrizeLiabilities:
+		return e.prizeLiabilities(ctx, r.Label)
+	default:
+		return 0, errors.Errorf("unknown metric %q", r.Metric)
+	}
+}
+
+// poolSize returns the number of sats bet in all the pools of the lottery in progress.
+func (e *Engine) poolSize() (float64, error) {
+	height, err := e.db.Lotteries.GetNextHeight()
+	if err != nil {
+		return 0, err
+	}
+
+	pools, err := e.db.Bets.ListPools(height)
+	if err != nil {
+		return 0, err
+	}
+
+	total := uint64(0)
+	for _, pool := range pools {
+		prizePool, err := e.db.Bets.GetPrizePool(height, pool)
+		if err != nil {
+			return 0, err
+		}
+		total += prizePool
+	}
+
+	return float64(total), nil
+}
+
+// drawLatency returns the number of seconds the last draw took, 0 if there are no draws yet.
+func (e *Engine) drawLatency() (float64, error) {
+	timings, err := e.db.DrawTimings.List(0, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(timings) == 0 {
+		return 0, nil
+	}
+
+	return time.Duration(timings[0].Total * int64(time.Microsecond)).Seconds(), nil
+}
+
+// prizeLiabilities returns the number of sats of the unclaimed prizes, only the ones in the aging
+// bucket if one is specified.
+func (e *Engine) prizeLiabilities(ctx context.Context, bucket string) (float64, error) {
+	info, err := e.lnd.GetInfo(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "getting node information")
+	}
+
+	prizes, err := e.db.Winners.ListUnclaimed()
+	if err != nil {
+		return 0, err
+	}
+
+	aging := lottery.PrizesAging(prizes, info.BlockHeight)
+	if bucket == "" {
+		return float64(aging.Liabilities), nil
+	}
+
+	agingBucket, ok := aging.Bucket(bucket)
+	if !ok {
+		return 0, errors.Errorf("unknown aging bucket %q", bucket)
+	}
+
+	return float64(agingBucket.Amount), nil
+}
+
+// send delivers the alert through every channel, failures are only logged.
+func (e *Engine) send(ctx context.Context, alert Alert) {
+	e.logger.Warning(alert.Message)
+
+	for _, channel := range e.channels {
+		if err := channel.send(ctx, alert); err != nil {
+			e.logger.Error(errors.Wrapf(err, "sending alert through %s", channel.name()))
+		}
+	}
+}
+
+// delta records the current value of a counter and returns how much it increased during the rule
+// window. The first sample is taken as the starting point.
+func (r *rule) delta(now time.Time, current float64) float64 {
+	r.samples = append(r.samples, sample{at: now, value: current})
+
+	// Keep the last sample taken before the window started as the baseline
+	cutoff := now.Add(-r.Window)
+	i := 0
+	for i < len(r.samples)-1 && !r.samples[i+1].at.After(cutoff) {
+		i++
+	}
+	r.samples = r.samples[i:]
+
+	return current - r.samples[0].value
+}
+
+func message(r *rule, value float64) string {
+	if !r.firing {
+		return fmt.Sprintf("Alert %q resolved: %s is %g", r.Name, r.Metric, value)
+	}
+
+	comparison := "above"
+	if r.Below {
+		comparison = "below"
+	}
+	return fmt.Sprintf("Alert %q firing: %s is %g, %s the threshold of %g",
+		r.Name, r.Metric, value, comparison, r.Threshold)
+}
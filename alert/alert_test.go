@@ -0,0 +1,300 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/logger"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const chatID = 1
+
+type paymentsStreamMock struct {
+	payments []*lnrpc.Payment
+}
+
+func (p *paymentsStreamMock) Recv() (*lnrpc.Payment, error) {
+	if len(p.payments) == 0 {
+		return nil, io.EOF
+	}
+	payment := p.payments[0]
+	p.payments = p.payments[1:]
+	return payment, nil
+}
+
+func setupEngine(t *testing.T, now *time.Time, rules ...config.AlertRule) (*Engine, *notification.NotifierMock) {
+	t.Helper()
+
+	notifierMock := notification.NewNotifierMock()
+	engine, err := New(config.Alerts{
+		Rules:    rules,
+		Channels: config.AlertChannels{TelegramChatID: chatID},
+		Logger:   config.Logger{Level: uint8(logger.DISABLED)},
+		Interval: time.Minute,
+		Enabled:  true,
+	}, nil, nil, notifierMock)
+	assert.NoError(t, err)
+	engine.now = func() time.Time { return *now }
+
+	return engine, notifierMock
+}
+
+func TestEvaluatePoolSize(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	engine, notifierMock := setupEngine(t, &now, config.AlertRule{
+		Name:      "low_pool",
+		Metric:    MetricPoolSize,
+		Threshold: 10_000,
+		Below:     true,
+	})
+	notifierMock.On("Notify", int64(chatID), mock.Anything)
+
+	height := uint32(840_000)
+	lotteriesMock := db.NewLotteriesStoreMock()
+	lotteriesMock.On("GetNextHeight").Return(height, nil)
+	betsMock := db.NewBetsStoreMock()
+	betsMock.On("ListPools", height).Return([]string{"", "whale"}, nil)
+	betsMock.On("GetPrizePool", height, "").Return(uint64(2_000), nil).Once()
+	betsMock.On("GetPrizePool", height, "whale").Return(uint64(5_000), nil).Once()
+	engine.db = &db.DB{Bets: betsMock, Lotteries: lotteriesMock}
+
+	engine.evaluate(context.Background())
+	notifierMock.AssertCalled(t, "Notify", int64(chatID),
+		`Alert "low_pool" firing: pool_size is 7000, below the threshold of 10000`)
+
+	// Still firing, no new alert
+	betsMock.On("GetPrizePool", height, "").Return(uint64(3_000), nil).Once()
+	betsMock.On("GetPrizePool", height, "whale").Return(uint64(5_000), nil).Once()
+	engine.evaluate(context.Background())
+	notifierMock.AssertNumberOfCalls(t, "Notify", 1)
+
+	betsMock.On("GetPrizePool", height, "").Return(uint64(6_000), nil).Once()
+	betsMock.On("GetPrizePool", height, "whale").Return(uint64(5_000), nil).Once()
+	engine.evaluate(context.Background())
+	notifierMock.AssertCalled(t, "Notify", int64(chatID),
+		`Alert "low_pool" resolved: pool_size is 11000`)
+}
+
+func TestEvaluatePoolSizeError(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	engine, notifierMock := setupEngine(t, &now, config.AlertRule{
+		Name:   "low_pool",
+		Metric: MetricPoolSize,
+		Below:  true,
+	})
+
+	lotteriesMock := db.NewLotteriesStoreMock()
+	lotteriesMock.On("GetNextHeight").Return(uint32(0), errors.New("test"))
+	engine.db = &db.DB{Lotteries: lotteriesMock}
+
+	engine.evaluate(context.Background())
+	notifierMock.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+}
+
+func TestEvaluateDrawLatency(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	engine, notifierMock := setupEngine(t, &now, config.AlertRule{
+		Name:      "slow_draw",
+		Metric:    MetricDrawLatency,
+		Threshold: 1,
+	})
+	notifierMock.On("Notify", int64(chatID), mock.Anything)
+
+	drawTimingsMock := db.NewDrawTimingsStoreMock()
+	drawTimingsMock.On("List", uint64(0), uint64(1)).Return([]db.DrawTiming(nil), nil).Once()
+	engine.db = &db.DB{DrawTimings: drawTimingsMock}
+
+	engine.evaluate(context.Background())
+	notifierMock.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+
+	drawTimingsMock.On("List", uint64(0), uint64(1)).
+		Return([]db.DrawTiming{{LotteryHeight: 144, Total: 2_500_000}}, nil).Once()
+	engine.evaluate(context.Background())
+	notifierMock.AssertCalled(t, "Notify", int64(chatID),
+		`Alert "slow_draw" firing: draw_latency is 2.5, above the threshold of 1`)
+}
+
+func TestEvaluatePrizeLiabilities(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	engine, notifierMock := setupEngine(t, &now,
+		config.AlertRule{Name: "liabilities", Metric: MetricPrizeLiabilities, Threshold: 100},
+		config.AlertRule{
+			Name:      "expired",
+			Metric:    MetricPrizeLiabilities,
+			Label:     lottery.AgingExpired,
+			Threshold: 100,
+		},
+	)
+	notifierMock.On("Notify", int64(chatID), mock.Anything)
+
+	lndMock := lightning.NewClientMock()
+	lndMock.On("GetInfo", mock.Anything).Return(&lnrpc.GetInfoResponse{BlockHeight: 1750}, nil)
+	engine.lnd = lndMock
+	winnersMock := db.NewWinnersStoreMock()
+	winnersMock.On("ListUnclaimed").Return([]db.UnclaimedPrize{
+		{PublicKey: "1", Amount: 100, LotteryHeight: 1000, ClaimDeadline: 1720},
+		{PublicKey: "2", Amount: 50, LotteryHeight: 1650, ClaimDeadline: 2370},
+	}, nil)
+	engine.db = &db.DB{Winners: winnersMock}
+
+	engine.evaluate(context.Background())
+	notifierMock.AssertCalled(t, "Notify", int64(chatID),
+		`Alert "liabilities" firing: prize_liabilities is 150, above the threshold of 100`)
+	notifierMock.AssertNumberOfCalls(t, "Notify", 1)
+}
+
+func TestEvaluatePayments(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	engine, notifierMock := setupEngine(t, &now,
+		config.AlertRule{Name: "failures", Metric: MetricPayoutFailures, Threshold: 1, Window: time.Hour},
+		config.AlertRule{Name: "fees", Metric: MetricRoutingFees, Threshold: 100},
+	)
+	notifierMock.On("Notify", int64(chatID), mock.Anything)
+
+	// First samples
+	engine.evaluate(context.Background())
+
+	engine.trackPayments(&paymentsStreamMock{payments: []*lnrpc.Payment{
+		{Status: lnrpc.Payment_FAILED},
+		{Status: lnrpc.Payment_IN_FLIGHT},
+		{Status: lnrpc.Payment_FAILED},
+		{Status: lnrpc.Payment_SUCCEEDED, FeeSat: 150},
+	}})
+	assert.Equal(t, uint64(2), engine.payoutFailures.Load())
+	assert.Equal(t, uint64(150), engine.routingFees.Load())
+
+	now = now.Add(time.Minute)
+	engine.evaluate(context.Background())
+	notifierMock.AssertCalled(t, "Notify", int64(chatID),
+		`Alert "failures" firing: payout_failures is 2, above the threshold of 1`)
+	notifierMock.AssertCalled(t, "Notify", int64(chatID),
+		`Alert "fees" firing: routing_fees is 150, above the threshold of 100`)
+
+	// The fees were paid more than a minute ago, the failures are still within the window
+	now = now.Add(2 * time.Minute)
+	engine.evaluate(context.Background())
+	notifierMock.AssertCalled(t, "Notify", int64(chatID), `Alert "fees" resolved: routing_fees is 0`)
+	notifierMock.AssertNumberOfCalls(t, "Notify", 3)
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	engine, notifierMock := setupEngine(t, &now, config.AlertRule{
+		Name:      "db_errors",
+		Metric:    MetricErrors,
+		Label:     "DB",
+		Threshold: 5,
+	})
+	notifierMock.On("Notify", int64(chatID), mock.Anything)
+
+	count := uint64(10)
+	engine.errorCount = func(label string) uint64 {
+		assert.Equal(t, "DB", label)
+		return count
+	}
+
+	engine.evaluate(context.Background())
+	notifierMock.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+
+	count = 20
+	now = now.Add(30 * time.Second)
+	engine.evaluate(context.Background())
+	notifierMock.AssertCalled(t, "Notify", int64(chatID),
+		`Alert "db_errors" firing: errors is 10, above the threshold of 5`)
+}
+
+func TestDelta(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	r := &rule{AlertRule: config.AlertRule{Window: time.Minute}}
+
+	assert.Equal(t, float64(0), r.delta(now, 5))
+	assert.Equal(t, float64(3), r.delta(now.Add(30*time.Second), 8))
+	assert.Equal(t, float64(5), r.delta(now.Add(60*time.Second), 10))
+	// The baseline moves to the last sample before the window
+	assert.Equal(t, float64(4), r.delta(now.Add(90*time.Second), 12))
+	assert.Len(t, r.samples, 3)
+}
+
+func TestChannels(t *testing.T) {
+	var (
+		received  Alert
+		pagerDuty []pagerDutyEvent
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		switch r.URL.Path {
+		case "/webhook":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		case "/pagerduty":
+			var event pagerDutyEvent
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+			pagerDuty = append(pagerDuty, event)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	alert := Alert{
+		Rule:      "fees",
+		Metric:    MetricRoutingFees,
+		Message:   "message",
+		Value:     150,
+		Threshold: 100,
+		Timestamp: 1_700_000_000,
+	}
+
+	webhook := &webhookChannel{client: server.Client(), url: server.URL + "/webhook"}
+	assert.NoError(t, webhook.send(ctx, alert))
+	assert.Equal(t, alert, received)
+
+	pagerDutyCh := &pagerDutyChannel{
+		client:     server.Client(),
+		url:        server.URL + "/pagerduty",
+		routingKey: "key",
+	}
+	assert.NoError(t, pagerDutyCh.send(ctx, alert))
+	alert.Resolved = true
+	assert.NoError(t, pagerDutyCh.send(ctx, alert))
+
+	expected := []pagerDutyEvent{
+		{
+			RoutingKey:  "key",
+			EventAction: "trigger",
+			DedupKey:    "btry-fees",
+			Payload: &pagerDutyPayload{
+				Summary:   "message",
+				Source:    "btry",
+				Severity:  "critical",
+				Timestamp: "2023-11-14T22:13:20Z",
+			},
+		},
+		{
+			RoutingKey:  "key",
+			EventAction: "resolve",
+			DedupKey:    "btry-fees",
+		},
+	}
+	assert.Equal(t, expected, pagerDuty)
+
+	invalid := &webhookChannel{client: server.Client(), url: server.URL + "/invalid"}
+	assert.Error(t, invalid.send(ctx, alert))
+}
@@ -0,0 +1,120 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/pkg/errors"
+)
+
+// channel delivers alerts to the operators.
+type channel interface {
+	name() string
+	send(ctx context.Context, alert Alert) error
+}
+
+type telegramChannel struct {
+	notifier notification.Notifier
+	chatID   int64
+}
+
+func (t *telegramChannel) name() string {
+	return "telegram"
+}
+
+func (t *telegramChannel) send(_ context.Context, alert Alert) error {
+	t.notifier.Notify(t.chatID, alert.Message)
+	return nil
+}
+
+// webhookChannel posts the alerts encoded as JSON.
+type webhookChannel struct {
+	client *http.Client
+	url    string
+}
+
+func (w *webhookChannel) name() string {
+	return "webhook"
+}
+
+func (w *webhookChannel) send(ctx context.Context, alert Alert) error {
+	return post(ctx, w.client, w.url, alert)
+}
+
+// pagerDutyChannel triggers and resolves incidents following the PagerDuty Events API v2, one per
+// rule.
+type pagerDutyChannel struct {
+	client     *http.Client
+	url        string
+	routingKey string
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary   string `json:"summary"`
+	Source    string `json:"source"`
+	Severity  string `json:"severity"`
+	Timestamp string `json:"timestamp"`
+}
+
+func (p *pagerDutyChannel) name() string {
+	return "pagerduty"
+}
+
+func (p *pagerDutyChannel) send(ctx context.Context, alert Alert) error {
+	event := pagerDutyEvent{
+		RoutingKey:  p.routingKey,
+		EventAction: "trigger",
+		DedupKey:    "btry-" + alert.Rule,
+	}
+	if alert.Resolved {
+		event.EventAction = "resolve"
+	} else {
+		event.Payload = &pagerDutyPayload{
+			Summary:   alert.Message,
+			Source:    "btry",
+			Severity:  "critical",
+			Timestamp: time.Unix(alert.Timestamp, 0).UTC().Format(time.RFC3339),
+		}
+	}
+
+	return post(ctx, p.client, p.url, event)
+}
+
+func post(ctx context.Context, client *http.Client, url string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "encoding request body")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "calling %s", url)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return errors.Errorf("%s responded with status %d", url, resp.StatusCode)
+	}
+
+	return nil
+}
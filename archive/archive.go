@@ -0,0 +1,299 @@
+// Package archive exports the history of the draws to Nostr long-form events and IPFS
+// periodically, signed with the audit log key, so anyone can verify the past draws even if the
+// server is gone or censored.
+package archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/logger"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/pkg/errors"
+)
+
+// Domain separates the signatures of the histories from the ones of other messages signed with
+// the audit log key.
+const Domain = "btry-archive-v1"
+
+const (
+	defaultInterval = 24 * time.Hour
+	// confirmations is the number of blocks mined on top of a lottery before it's archived, so
+	// the block hashes exported are not reorganized
+	confirmations = 6
+	// batchSize is the maximum number of draws exported in a document
+	batchSize = 144
+)
+
+// Draw contains what's needed to verify a lottery: the seed committed to before the bets, the
+// block hash that drew it and the winners.
+type Draw struct {
+	Commitment string      `json:"commitment"`
+	Seed       string      `json:"seed"`
+	BlockHash  string      `json:"block_hash"`
+	Collision  string      `json:"collision,omitempty"`
+	Winners    []db.Winner `json:"winners"`
+	Height     uint32      `json:"height"`
+}
+
+// History contains the draws of the lotteries between two heights.
+type History struct {
+	Draws       []Draw `json:"draws"`
+	Timestamp   int64  `json:"timestamp"`
+	FirstHeight uint32 `json:"first_height"`
+	LastHeight  uint32 `json:"last_height"`
+}
+
+// Document is a history signed with the audit log key. The signature is made over the message,
+// the history encoded in JSON.
+type Document struct {
+	Message   string `json:"message"`
+	Signature string `json:"signature"`
+	PublicKey string `json:"public_key"`
+	History
+}
+
+// Publisher publishes the documents to a destination and returns a reference to retrieve them.
+type Publisher interface {
+	Name() string
+	Publish(ctx context.Context, document Document) (string, error)
+}
+
+// Archiver exports the draw history.
+type Archiver interface {
+	Start(ctx context.Context)
+}
+
+type archiver struct {
+	db         *db.DB
+	lnd        lightning.NodeInfo
+	auditor    audit.Auditor
+	logger     *logger.Logger
+	now        func() time.Time
+	publishers []Publisher
+	interval   time.Duration
+	enabled    bool
+}
+
+// New returns a new draw history archiver.
+func New(
+	config config.Archive,
+	db *db.DB,
+	lnd lightning.NodeInfo,
+	auditor audit.Auditor,
+	torClient *http.Client,
+) (Archiver, error) {
+	logger, err := logger.New(config.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := config.Interval
+	if interval == 0 {
+		interval = defaultInterval
+	}
+
+	var publishers []Publisher
+	if len(config.Nostr.Relays) > 0 {
+		publishers = append(publishers, NewNostrPublisher(config.Nostr, logger, torClient))
+	}
+	if config.IPFS.URL != "" {
+		publishers = append(publishers, NewIPFSPublisher(config.IPFS, torClient))
+	}
+
+	return &archiver{
+		db:         db,
+		lnd:        lnd,
+		auditor:    auditor,
+		logger:     logger,
+		now:        time.Now,
+		publishers: publishers,
+		interval:   interval,
+		enabled:    config.Enabled,
+	}, nil
+}
+
+// Start exports the draws not archived yet to every destination and does it again every interval
+// until the context is cancelled.
+func (a *archiver) Start(ctx context.Context) {
+	if !a.enabled {
+		a.logger.Info("Archive disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+
+		for {
+			a.export(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (a *archiver) export(ctx context.Context) {
+	info, err := a.lnd.GetInfo(ctx)
+	if err != nil {
+		a.logger.Error(errors.Wrap(err, "getting node information"))
+		return
+	}
+
+	if info.BlockHeight <= confirmations {
+		return
+	}
+
+	for _, publisher := range a.publishers {
+		if err := a.exportTo(ctx, publisher, info.BlockHeight-confirmations); err != nil {
+			a.logger.Error(errors.Wrapf(err, "archiving draws to %s", publisher.Name()))
+		}
+	}
+}
+
+// exportTo publishes the draws after the last one archived in the destination up to the height
+// specified.
+func (a *archiver) exportTo(ctx context.Context, publisher Publisher, maxHeight uint32) error {
+	lastHeight, err := a.db.Archives.LastHeight(publisher.Name())
+	if err != nil {
+		return err
+	}
+
+	history, err := a.history(lastHeight+1, maxHeight)
+	if err != nil {
+		return err
+	}
+
+	if len(history.Draws) == 0 {
+		return nil
+	}
+
+	document, err := a.sign(history)
+	if err != nil {
+		return err
+	}
+
+	reference, err := publisher.Publish(ctx, document)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256([]byte(document.Message))
+	archive := db.Archive{
+		Destination: publisher.Name(),
+		Reference:   reference,
+		Hash:        hex.EncodeToString(hash[:]),
+		CreatedAt:   history.Timestamp,
+		FirstHeight: history.FirstHeight,
+		LastHeight:  history.LastHeight,
+	}
+	if _, err := a.db.Archives.Add(archive); err != nil {
+		return errors.Wrap(err, "storing archive")
+	}
+
+	a.logger.Infof("Archived the draws %d-%d to %s: %s", history.FirstHeight, history.LastHeight,
+		publisher.Name(), reference)
+	return nil
+}
+
+// history returns the draws between the heights specified, it stops at the first lottery whose
+// winners are still being revealed.
+func (a *archiver) history(minHeight, maxHeight uint32) (History, error) {
+	commitments, err := a.db.Lotteries.ListDrawn(minHeight, maxHeight, batchSize)
+	if err != nil {
+		return History{}, errors.Wrap(err, "listing drawn lotteries")
+	}
+
+	history := History{
+		Timestamp: a.now().Unix(),
+		Draws:     make([]Draw, 0, len(commitments)),
+	}
+	for _, commitment := range commitments {
+		pending, err := lottery.RevealPending(a.db.Reveals, commitment.Height)
+		if err != nil {
+			return History{}, errors.Wrap(err, "checking reveal")
+		}
+		if pending {
+			break
+		}
+
+		winners, err := a.db.Winners.List(commitment.Height)
+		if err != nil {
+			return History{}, errors.Wrap(err, "listing winners")
+		}
+
+		winners, err = policy.AnonymizeWinners(a.db.Privacy, winners)
+		if err != nil {
+			return History{}, err
+		}
+
+		history.Draws = append(history.Draws, Draw{
+			Commitment: commitment.Commitment,
+			Seed:       commitment.Seed,
+			BlockHash:  commitment.BlockHash,
+			Collision:  commitment.Collision,
+			Winners:    winners,
+			Height:     commitment.Height,
+		})
+	}
+
+	if len(history.Draws) > 0 {
+		history.FirstHeight = history.Draws[0].Height
+		history.LastHeight = history.Draws[len(history.Draws)-1].Height
+	}
+
+	return history, nil
+}
+
+func (a *archiver) sign(history History) (Document, error) {
+	message, err := json.Marshal(history)
+	if err != nil {
+		return Document{}, errors.Wrap(err, "encoding history")
+	}
+
+	signature, err := a.auditor.Sign(Domain, message)
+	if err != nil {
+		return Document{}, errors.Wrap(err, "signing history")
+	}
+
+	return Document{
+		History:   history,
+		Message:   string(message),
+		Signature: signature,
+		PublicKey: a.auditor.PublicKey(),
+	}, nil
+}
+
+// Verify checks that the document was signed with the audit log key and that its message matches
+// the history.
+func Verify(auditPublicKey string, document Document) error {
+	err := audit.VerifySignature(auditPublicKey, Domain, []byte(document.Message), document.Signature)
+	if err != nil {
+		return err
+	}
+
+	message, err := json.Marshal(document.History)
+	if err != nil {
+		return errors.Wrap(err, "encoding history")
+	}
+
+	if string(message) != document.Message {
+		return errors.New("the history does not match the message signed")
+	}
+
+	return nil
+}
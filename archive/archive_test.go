@@ -0,0 +1,209 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const privateKey = "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb"
+
+type publisherMock struct {
+	mock.Mock
+}
+
+func (p *publisherMock) Name() string {
+	return "test"
+}
+
+func (p *publisherMock) Publish(ctx context.Context, document Document) (string, error) {
+	args := p.Called(ctx, document)
+	return args.String(0), args.Error(1)
+}
+
+type mocks struct {
+	archives  *db.ArchivesStoreMock
+	lotteries *db.LotteriesStoreMock
+	privacy   *db.PrivacyStoreMock
+	reveals   *db.RevealsStoreMock
+	winners   *db.WinnersStoreMock
+	lnd       *lightning.NodeInfoMock
+	publisher *publisherMock
+}
+
+func newTestArchiver(t *testing.T) (*archiver, audit.Auditor, mocks) {
+	t.Helper()
+
+	auditMock := db.NewAuditStoreMock()
+	auditMock.On("Last").Return(db.AuditEntry{}, nil)
+	auditor, err := audit.New(config.Audit{Enabled: true, PrivateKey: privateKey}, &db.DB{Audit: auditMock})
+	assert.NoError(t, err)
+
+	m := mocks{
+		archives:  db.NewArchivesStoreMock(),
+		lotteries: db.NewLotteriesStoreMock(),
+		privacy:   db.NewPrivacyStoreMock(),
+		reveals:   db.NewRevealsStoreMock(),
+		winners:   db.NewWinnersStoreMock(),
+		lnd:       lightning.NewNodeInfoMock(),
+		publisher: &publisherMock{},
+	}
+	database := &db.DB{
+		Archives:  m.archives,
+		Lotteries: m.lotteries,
+		Privacy:   m.privacy,
+		Reveals:   m.reveals,
+		Winners:   m.winners,
+	}
+
+	a, err := New(config.Archive{
+		Enabled: true,
+		Logger:  config.Logger{Level: uint8(logger.DISABLED)},
+	}, database, m.lnd, auditor, nil)
+	assert.NoError(t, err)
+
+	archiver := a.(*archiver)
+	archiver.now = func() time.Time { return time.Unix(1_700_000_000, 0) }
+	archiver.publishers = []Publisher{m.publisher}
+
+	return archiver, auditor, m
+}
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	a, auditor, m := newTestArchiver(t)
+
+	winners := []db.Winner{{PublicKey: "public_key", Prize: 1_000, Ticket: 5}}
+	m.lnd.On("GetInfo", ctx).Return(&lnrpc.GetInfoResponse{BlockHeight: 500}, nil)
+	m.archives.On("LastHeight", "test").Return(uint32(144), nil)
+	// The lottery at 432 is still revealing its winners, the export stops right before it
+	m.lotteries.On("ListDrawn", uint32(145), uint32(494), uint64(batchSize)).Return([]db.Commitment{
+		{Height: 288, Commitment: "commitment", Seed: "seed", BlockHash: "block_hash"},
+		{Height: 432, Commitment: "commitment2", Seed: "seed2", BlockHash: "block_hash2"},
+	}, nil)
+	m.reveals.On("Get", uint32(288)).Return(db.Reveal{}, db.ErrRevealNotFound)
+	m.reveals.On("Get", uint32(432)).Return(db.Reveal{Hidden: 2}, nil)
+	m.winners.On("List", uint32(288)).Return(winners, nil)
+	m.privacy.On("List", []string{"public_key"}).Return(map[string]db.Privacy{}, nil)
+	m.publisher.On("Publish", ctx, mock.Anything).Return("reference", nil)
+	m.archives.On("Add", mock.Anything).Return(uint64(1), nil)
+
+	a.export(ctx)
+
+	document := m.publisher.Calls[0].Arguments.Get(1).(Document)
+	expectedHistory := History{
+		Draws: []Draw{
+			{Height: 288, Commitment: "commitment", Seed: "seed", BlockHash: "block_hash", Winners: winners},
+		},
+		Timestamp:   1_700_000_000,
+		FirstHeight: 288,
+		LastHeight:  288,
+	}
+	assert.Equal(t, expectedHistory, document.History)
+	assert.Equal(t, auditor.PublicKey(), document.PublicKey)
+	assert.NoError(t, Verify(auditor.PublicKey(), document))
+
+	archive := m.archives.Calls[1].Arguments.Get(0).(db.Archive)
+	assert.Equal(t, "test", archive.Destination)
+	assert.Equal(t, "reference", archive.Reference)
+	assert.Equal(t, uint32(288), archive.FirstHeight)
+	assert.Equal(t, uint32(288), archive.LastHeight)
+	assert.Len(t, archive.Hash, 64)
+}
+
+func TestExportNothingNew(t *testing.T) {
+	ctx := context.Background()
+	a, _, m := newTestArchiver(t)
+
+	m.lnd.On("GetInfo", ctx).Return(&lnrpc.GetInfoResponse{BlockHeight: 500}, nil)
+	m.archives.On("LastHeight", "test").Return(uint32(432), nil)
+	m.lotteries.On("ListDrawn", uint32(433), uint32(494), uint64(batchSize)).Return([]db.Commitment(nil), nil)
+
+	a.export(ctx)
+
+	m.publisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+	m.archives.AssertNotCalled(t, "Add", mock.Anything)
+}
+
+func TestExportPublishError(t *testing.T) {
+	ctx := context.Background()
+	a, _, m := newTestArchiver(t)
+
+	m.archives.On("LastHeight", "test").Return(uint32(0), nil)
+	m.lotteries.On("ListDrawn", uint32(1), uint32(494), uint64(batchSize)).Return([]db.Commitment{
+		{Height: 144, Commitment: "commitment", Seed: "seed", BlockHash: "block_hash"},
+	}, nil)
+	m.reveals.On("Get", uint32(144)).Return(db.Reveal{}, db.ErrRevealNotFound)
+	m.winners.On("List", uint32(144)).Return([]db.Winner(nil), nil)
+	m.publisher.On("Publish", ctx, mock.Anything).Return("", errors.New("unreachable"))
+
+	err := a.exportTo(ctx, m.publisher, 494)
+	assert.Error(t, err)
+
+	// The draws are exported again on the next run
+	m.archives.AssertNotCalled(t, "Add", mock.Anything)
+}
+
+func TestVerify(t *testing.T) {
+	a, auditor, _ := newTestArchiver(t)
+
+	document, err := a.sign(History{FirstHeight: 144, LastHeight: 144, Draws: []Draw{{Height: 144}}})
+	assert.NoError(t, err)
+	assert.NoError(t, Verify(auditor.PublicKey(), document))
+
+	document.Draws[0].BlockHash = "forged"
+	assert.Error(t, Verify(auditor.PublicKey(), document))
+}
+
+func TestIPFSPublish(t *testing.T) {
+	document := Document{History: History{FirstHeight: 144, LastHeight: 288}, Message: "message"}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v0/add", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("pin"))
+
+		file, header, err := r.FormFile("file")
+		assert.NoError(t, err)
+		defer file.Close()
+		assert.Equal(t, "btry-archive-144-288.json", header.Filename)
+
+		var received Document
+		assert.NoError(t, json.NewDecoder(file).Decode(&received))
+		assert.Equal(t, document, received)
+
+		_, _ = w.Write([]byte(`{"Name":"btry-archive-144-288.json","Hash":"cid","Size":"10"}`))
+	}))
+	defer server.Close()
+
+	publisher := NewIPFSPublisher(config.IPFS{URL: server.URL + "/"}, nil)
+	assert.Equal(t, "ipfs", publisher.Name())
+
+	cid, err := publisher.Publish(context.Background(), document)
+	assert.NoError(t, err)
+	assert.Equal(t, "cid", cid)
+}
+
+func TestIPFSPublishError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	publisher := NewIPFSPublisher(config.IPFS{URL: server.URL}, nil)
+	_, err := publisher.Publish(context.Background(), Document{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,125 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/logger"
+	"github.com/aftermath2/BTRY/nostr"
+
+	"github.com/pkg/errors"
+)
+
+const ipfsTimeout = time.Minute
+
+type nostrPublisher struct {
+	client *nostr.Client
+}
+
+// NewNostrPublisher returns a publisher that sends the documents to the relays as long-form
+// events, the reference is the event ID.
+func NewNostrPublisher(config config.Nostr, logger *logger.Logger, torClient *http.Client) Publisher {
+	return &nostrPublisher{
+		client: nostr.NewClient(config, logger, torClient),
+	}
+}
+
+func (n *nostrPublisher) Name() string {
+	return "nostr"
+}
+
+func (n *nostrPublisher) Publish(_ context.Context, document Document) (string, error) {
+	content, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return "", errors.Wrap(err, "encoding document")
+	}
+
+	identifier := fmt.Sprintf("btry-archive-%d-%d", document.FirstHeight, document.LastHeight)
+	title := fmt.Sprintf("BTRY draws %d-%d", document.FirstHeight, document.LastHeight)
+	article := fmt.Sprintf("History of the lotteries %d to %d, signed with the audit log key %s.\n\n```json\n%s\n```\n",
+		document.FirstHeight, document.LastHeight, document.PublicKey, content)
+
+	return n.client.PublishArticle(identifier, title, article)
+}
+
+type ipfsPublisher struct {
+	client *http.Client
+	url    string
+}
+
+// NewIPFSPublisher returns a publisher that adds the documents to an IPFS node through its RPC
+// API, the reference is the content identifier.
+func NewIPFSPublisher(config config.IPFS, torClient *http.Client) Publisher {
+	client := &http.Client{Timeout: ipfsTimeout}
+	if torClient != nil && strings.Contains(config.URL, ".onion") {
+		client = &http.Client{Transport: torClient.Transport, Timeout: ipfsTimeout}
+	}
+
+	return &ipfsPublisher{
+		client: client,
+		url:    strings.TrimSuffix(config.URL, "/"),
+	}
+}
+
+func (i *ipfsPublisher) Name() string {
+	return "ipfs"
+}
+
+func (i *ipfsPublisher) Publish(ctx context.Context, document Document) (string, error) {
+	content, err := json.Marshal(document)
+	if err != nil {
+		return "", errors.Wrap(err, "encoding document")
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	name := fmt.Sprintf("btry-archive-%d-%d.json", document.FirstHeight, document.LastHeight)
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		return "", errors.Wrap(err, "creating form file")
+	}
+	if _, err := part.Write(content); err != nil {
+		return "", errors.Wrap(err, "writing form file")
+	}
+	if err := writer.Close(); err != nil {
+		return "", errors.Wrap(err, "closing multipart writer")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.url+"/api/v0/add?pin=true", body)
+	if err != nil {
+		return "", errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "adding document to IPFS")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", errors.Errorf("IPFS node returned status %d: %s", resp.StatusCode, msg)
+	}
+
+	var added struct {
+		Hash string `json:"Hash"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&added); err != nil {
+		return "", errors.Wrap(err, "decoding response")
+	}
+
+	if added.Hash == "" {
+		return "", errors.New("IPFS node returned an empty content identifier")
+	}
+
+	return added.Hash, nil
+}
@@ -0,0 +1,222 @@
+// Package audit keeps an append-only, hash-chained and signed log of the lottery state changes.
+package audit
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// Event is a state-changing action recorded in the audit log.
+type Event string
+
+// Audited events
+const (
+	BetAccepted    Event = "bet_accepted"
+	DrawExecuted   Event = "draw_executed"
+	PrizeAssigned  Event = "prize_assigned"
+	PayoutSent     Event = "payout_sent"
+	PayoutProbed   Event = "payout_probed"
+	PayoutHeld     Event = "payout_held"
+	PayoutApproved Event = "payout_approved"
+	PayoutRejected Event = "payout_rejected"
+	PrizeExpired   Event = "prize_expired"
+	BetsRefunded   Event = "bets_refunded"
+	BetCancelled   Event = "bet_cancelled"
+	AirdropIssued  Event = "airdrop_issued"
+	// AccessDenied is recorded when a public key in the access lists is rejected
+	AccessDenied Event = "access_denied"
+	// AccessListUpdated is recorded when an operator adds or removes an access list entry
+	AccessListUpdated Event = "access_list_updated"
+	// SwapClaimCountersigned is recorded when a winner countersigns the receipt of a swap claim
+	SwapClaimCountersigned Event = "swap_claim_countersigned"
+	// FeeForwarded is recorded when a share of a lottery fee is paid to its destination
+	FeeForwarded Event = "fee_forwarded"
+	// RoundExported is recorded when an operator exports a lottery in progress to migrate it
+	RoundExported Event = "round_exported"
+	// RoundImported is recorded when an operator imports a lottery exported by another instance
+	RoundImported Event = "round_imported"
+	// ResultsCommitted is recorded when the winners of a lottery are going to be revealed one tier
+	// at a time, with the hash of the complete results
+	ResultsCommitted Event = "results_committed"
+	// DrawPostponed is recorded when the node can't pay the prizes of a lottery at its draw
+	DrawPostponed Event = "draw_postponed"
+	// DrawResumed is recorded when a postponed draw takes place
+	DrawResumed Event = "draw_resumed"
+)
+
+// genesisHash is the previous hash of the first entry in the log.
+var genesisHash = hex.EncodeToString(make([]byte, sha256.Size))
+
+// Auditor records events in the audit log.
+type Auditor interface {
+	PublicKey() string
+	Record(event Event, data any)
+	Sign(domain string, message []byte) (string, error)
+	SignReceipt(bet db.Bet, paymentHash string) (Receipt, error)
+}
+
+type auditor struct {
+	db         *db.DB
+	logger     *logger.Logger
+	privateKey ed25519.PrivateKey
+	last       db.AuditEntry
+	mu         sync.Mutex
+	enabled    bool
+}
+
+// New returns a new auditor.
+func New(config config.Audit, db *db.DB) (Auditor, error) {
+	logger, err := logger.New(config.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if !config.Enabled {
+		logger.Info("Audit log disabled")
+		return &auditor{enabled: false}, nil
+	}
+
+	seed, err := hex.DecodeString(config.PrivateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding private key")
+	}
+
+	last, err := db.Audit.Last()
+	if err != nil {
+		return nil, err
+	}
+
+	return &auditor{
+		db:         db,
+		logger:     logger,
+		privateKey: ed25519.NewKeyFromSeed(seed),
+		last:       last,
+		enabled:    true,
+	}, nil
+}
+
+// PublicKey returns the hex-encoded key used to verify the entries signatures.
+func (a *auditor) PublicKey() string {
+	if !a.enabled {
+		return ""
+	}
+	return hex.EncodeToString(a.privateKey.Public().(ed25519.PublicKey))
+}
+
+// Record appends an event to the log. Errors are logged and not returned so they don't interrupt
+// the operation that is being audited.
+func (a *auditor) Record(event Event, data any) {
+	if !a.enabled {
+		return
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		a.logger.Error(errors.Wrapf(err, "encoding %s audit data", event))
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	prevHash := a.last.Hash
+	if prevHash == "" {
+		prevHash = genesisHash
+	}
+
+	entry := db.AuditEntry{
+		ID:        a.last.ID + 1,
+		Timestamp: time.Now().Unix(),
+		Event:     string(event),
+		Data:      string(payload),
+		PrevHash:  prevHash,
+	}
+	hash := Hash(entry)
+	entry.Hash = hex.EncodeToString(hash)
+	entry.Signature = hex.EncodeToString(ed25519.Sign(a.privateKey, hash))
+
+	if err := a.db.Audit.Add(entry); err != nil {
+		a.logger.Error(errors.Wrapf(err, "recording %s event", event))
+		return
+	}
+
+	a.last = entry
+}
+
+// Hash returns the hash of the entry content, chained to the previous entry.
+func Hash(entry db.AuditEntry) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(entry.PrevHash) + len(entry.Event) + len(entry.Data) + 16)
+
+	var num [8]byte
+	binary.BigEndian.PutUint64(num[:], entry.ID)
+	buf.Write(num[:])
+	binary.BigEndian.PutUint64(num[:], uint64(entry.Timestamp))
+	buf.Write(num[:])
+	buf.WriteString(entry.Event)
+	buf.WriteByte(0)
+	buf.WriteString(entry.Data)
+	buf.WriteByte(0)
+	buf.WriteString(entry.PrevHash)
+
+	hash := sha256.Sum256(buf.Bytes())
+	return hash[:]
+}
+
+// Verify checks that the entries form an unbroken chain and that all of them were signed by the
+// owner of the public key.
+//
+// The entries must be sorted and contiguous, but they don't need to start from the first one.
+func Verify(publicKey string, entries []db.AuditEntry) error {
+	pubKey, err := hex.DecodeString(publicKey)
+	if err != nil {
+		return errors.Wrap(err, "decoding public key")
+	}
+	if len(pubKey) != ed25519.PublicKeySize {
+		return errors.New("invalid public key length")
+	}
+
+	for i, entry := range entries {
+		if i == 0 && entry.ID == 1 && entry.PrevHash != genesisHash {
+			return errors.Errorf("entry %d: invalid genesis hash", entry.ID)
+		}
+
+		if i > 0 {
+			prev := entries[i-1]
+			if entry.ID != prev.ID+1 {
+				return errors.Errorf("entry %d: expected id %d", entry.ID, prev.ID+1)
+			}
+			if entry.PrevHash != prev.Hash {
+				return errors.Errorf("entry %d: broken chain", entry.ID)
+			}
+		}
+
+		hash := Hash(entry)
+		if hex.EncodeToString(hash) != entry.Hash {
+			return errors.Errorf("entry %d: hash mismatch", entry.ID)
+		}
+
+		signature, err := hex.DecodeString(entry.Signature)
+		if err != nil {
+			return errors.Wrapf(err, "entry %d: decoding signature", entry.ID)
+		}
+
+		if !ed25519.Verify(pubKey, hash, signature) {
+			return errors.Errorf("entry %d: invalid signature", entry.ID)
+		}
+	}
+
+	return nil
+}
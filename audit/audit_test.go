@@ -0,0 +1,140 @@
+package audit_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+const privateKey = "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb"
+
+func TestRecord(t *testing.T) {
+	database := setupDB(t)
+	auditor, err := audit.New(config.Audit{Enabled: true, PrivateKey: privateKey}, database)
+	assert.NoError(t, err)
+
+	auditor.Record(audit.BetAccepted, map[string]any{"public_key": "pubkey", "tickets": 21})
+	auditor.Record(audit.DrawExecuted, map[string]any{"height": 840_000})
+	auditor.Record(audit.PrizeAssigned, map[string]any{"public_key": "pubkey", "prize": 10})
+
+	entries, err := database.Audit.List(0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	assert.Equal(t, string(audit.BetAccepted), entries[0].Event)
+	assert.Equal(t, `{"public_key":"pubkey","tickets":21}`, entries[0].Data)
+	assert.Equal(t, entries[0].Hash, entries[1].PrevHash)
+	assert.Equal(t, entries[1].Hash, entries[2].PrevHash)
+
+	err = audit.Verify(auditor.PublicKey(), entries)
+	assert.NoError(t, err)
+
+	t.Run("Chain continues after restart", func(t *testing.T) {
+		auditor, err := audit.New(config.Audit{Enabled: true, PrivateKey: privateKey}, database)
+		assert.NoError(t, err)
+
+		auditor.Record(audit.PayoutSent, map[string]any{"amount": 10})
+
+		entries, err := database.Audit.List(0, 0)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 4)
+
+		err = audit.Verify(auditor.PublicKey(), entries)
+		assert.NoError(t, err)
+	})
+}
+
+func TestRecordDisabled(t *testing.T) {
+	database := setupDB(t)
+	auditor, err := audit.New(config.Audit{Enabled: false}, database)
+	assert.NoError(t, err)
+
+	auditor.Record(audit.BetAccepted, nil)
+
+	entries, err := database.Audit.List(0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.Empty(t, auditor.PublicKey())
+}
+
+func TestVerify(t *testing.T) {
+	database := setupDB(t)
+	auditor, err := audit.New(config.Audit{Enabled: true, PrivateKey: privateKey}, database)
+	assert.NoError(t, err)
+
+	auditor.Record(audit.PrizeExpired, map[string]any{"amount": 1})
+	auditor.Record(audit.PrizeExpired, map[string]any{"amount": 2})
+
+	entries, err := database.Audit.List(0, 0)
+	assert.NoError(t, err)
+
+	cases := []struct {
+		tamper func(entries []db.AuditEntry) []db.AuditEntry
+		desc   string
+	}{
+		{
+			desc: "Modified data",
+			tamper: func(entries []db.AuditEntry) []db.AuditEntry {
+				entries[0].Data = `{"amount":1000}`
+				return entries
+			},
+		},
+		{
+			desc: "Broken chain",
+			tamper: func(entries []db.AuditEntry) []db.AuditEntry {
+				entries[1].PrevHash = entries[1].Hash
+				return entries
+			},
+		},
+		{
+			desc: "Reordered entries",
+			tamper: func(entries []db.AuditEntry) []db.AuditEntry {
+				return []db.AuditEntry{entries[1], entries[0]}
+			},
+		},
+		{
+			desc: "Invalid signature",
+			tamper: func(entries []db.AuditEntry) []db.AuditEntry {
+				entries[1].Signature = entries[0].Signature
+				return entries
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tampered := tc.tamper(append([]db.AuditEntry(nil), entries...))
+			assert.Error(t, audit.Verify(auditor.PublicKey(), tampered))
+		})
+	}
+
+	t.Run("Partial export", func(t *testing.T) {
+		assert.NoError(t, audit.Verify(auditor.PublicKey(), entries[1:]))
+	})
+}
+
+func setupDB(t *testing.T) *db.DB {
+	t.Helper()
+
+	file, err := os.CreateTemp("", "*")
+	assert.NoError(t, err)
+
+	db, err := db.Open(config.DB{
+		Path:   file.Name(),
+		Logger: config.Logger{},
+	})
+	assert.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, file.Close())
+		assert.NoError(t, db.Close())
+	})
+
+	return db
+}
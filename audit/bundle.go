@@ -0,0 +1,162 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// BundleDomain separates the receipt bundles signatures from the rest of the statements signed.
+const BundleDomain = "btry-receipt-bundle-v1"
+
+// Receipt bundle kinds.
+const (
+	BundleBet = "bet"
+	BundleWin = "win"
+)
+
+// Bundle is a bet or win receipt rendered for the players accounting and disputes, along with the
+// instructions to verify it. The server signs its JSON encoding, the signature is detached so the
+// document can be kept as is.
+type Bundle struct {
+	Kind            string `json:"kind"`
+	PublicKey       string `json:"public_key"`
+	ServerPublicKey string `json:"server_public_key"`
+	// Receipt is the one signed when the bet was accepted
+	Receipt *Receipt `json:"receipt,omitempty"`
+	// Prizes are the winning tickets of the player in the round
+	Prizes       []db.Winner `json:"prizes,omitempty"`
+	BlockHash    string      `json:"block_hash,omitempty"`
+	ServerSeed   string      `json:"server_seed,omitempty"`
+	Instructions []string    `json:"instructions"`
+	Tickets      uint64      `json:"tickets"`
+	// Amount is the number of sats paid for the bet or won in the round
+	Amount   uint64 `json:"amount"`
+	IssuedAt int64  `json:"issued_at"`
+	Round    uint32 `json:"round"`
+}
+
+// NewBetBundle returns the bundle of a bet receipt, amount is the number of sats paid for it.
+func NewBetBundle(receipt Receipt, amount uint64, serverPublicKey string) Bundle {
+	return Bundle{
+		Kind:            BundleBet,
+		PublicKey:       receipt.PublicKey,
+		ServerPublicKey: serverPublicKey,
+		Receipt:         &receipt,
+		Round:           receipt.Round,
+		Tickets:         receipt.LastTicket - receipt.FirstTicket + 1,
+		Amount:          amount,
+		IssuedAt:        time.Now().Unix(),
+		Instructions: []string{
+			"The receipt signature is an ed25519 signature made with the server public key over " +
+				"the SHA-256 hash of " + receiptDomain + ", the public key and the payment hash " +
+				"(each followed by a zero byte), and the big-endian encoded round (4 bytes), first " +
+				"ticket, last ticket and timestamp (8 bytes each).",
+			"The receipt can also be checked sending it to the /api/receipts/verify endpoint.",
+			bundleInstruction,
+		},
+	}
+}
+
+// NewWinBundle returns the bundle of the prizes won by a player in the lottery drawn with the
+// commitment.
+func NewWinBundle(publicKey string, commitment db.Commitment, prizes []db.Winner, serverPublicKey string) Bundle {
+	bundle := Bundle{
+		Kind:            BundleWin,
+		PublicKey:       publicKey,
+		ServerPublicKey: serverPublicKey,
+		Prizes:          prizes,
+		BlockHash:       commitment.BlockHash,
+		ServerSeed:      commitment.Seed,
+		Round:           commitment.Height,
+		Tickets:         uint64(len(prizes)),
+		IssuedAt:        time.Now().Unix(),
+		Instructions: []string{
+			"The winning tickets are drawn from the SHA-256 hash of the server seed followed by the " +
+				"block hash mined at the round height, the seed matches the commitment published " +
+				"before the round started.",
+			fmt.Sprintf("The draw can be reproduced with the bets archived in the "+
+				"/api/lottery/archive?height=%d endpoint.", commitment.Height),
+			bundleInstruction,
+		},
+	}
+	for _, prize := range prizes {
+		bundle.Amount += prize.Prize
+	}
+
+	return bundle
+}
+
+// bundleInstruction explains how to verify the signature of the bundles.
+const bundleInstruction = "This document is signed with an ed25519 signature made with the server " +
+	"public key over the SHA-256 hash of " + BundleDomain + ", a zero byte and the message."
+
+// SignBundle returns the JSON encoding of the bundle and its signature.
+func SignBundle(auditor Auditor, bundle Bundle) (string, string, error) {
+	message, err := json.Marshal(bundle)
+	if err != nil {
+		return "", "", errors.Wrap(err, "encoding bundle")
+	}
+
+	signature, err := auditor.Sign(BundleDomain, message)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(message), signature, nil
+}
+
+// VerifyBundle checks that the bundle message was signed by the owner of the public key.
+func VerifyBundle(publicKey, message, signature string) error {
+	return VerifySignature(publicKey, BundleDomain, []byte(message), signature)
+}
+
+// PDF returns the bundle rendered as a PDF document, including the signature of its message.
+func (b Bundle) PDF(message, signature string) []byte {
+	lines := []string{
+		"BTRY " + b.Kind + " receipt",
+		"",
+		"Round:       " + strconv.FormatUint(uint64(b.Round), 10),
+		"Public key:  " + b.PublicKey,
+	}
+
+	switch b.Kind {
+	case BundleBet:
+		lines = append(lines,
+			"Payment:     "+b.Receipt.PaymentHash,
+			fmt.Sprintf("Tickets:     %d (%d to %d)", b.Tickets, b.Receipt.FirstTicket, b.Receipt.LastTicket),
+			fmt.Sprintf("Amount:      %d sats", b.Amount),
+			"Placed at:   "+time.Unix(b.Receipt.Timestamp, 0).UTC().Format(time.RFC3339),
+			"Receipt:     "+b.Receipt.Signature,
+		)
+	case BundleWin:
+		lines = append(lines,
+			fmt.Sprintf("Prizes:      %d sats", b.Amount),
+			"Block hash:  "+b.BlockHash,
+			"Server seed: "+b.ServerSeed,
+		)
+		for _, prize := range b.Prizes {
+			lines = append(lines, fmt.Sprintf("  Ticket %d of pool %q: %d sats", prize.Ticket, prize.Pool,
+				prize.Prize))
+		}
+	}
+
+	lines = append(lines,
+		"Issued at:   "+time.Unix(b.IssuedAt, 0).UTC().Format(time.RFC3339),
+		"Server key:  "+b.ServerPublicKey,
+		"",
+		"Verification",
+		"",
+	)
+	for _, instruction := range b.Instructions {
+		lines = append(lines, "- "+instruction)
+	}
+	lines = append(lines, "", "Message:", message, "", "Signature:", signature)
+
+	return renderPDF(lines)
+}
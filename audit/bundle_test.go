@@ -0,0 +1,75 @@
+package audit_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignBundle(t *testing.T) {
+	auditor, err := audit.New(config.Audit{Enabled: true, PrivateKey: privateKey}, setupDB(t))
+	assert.NoError(t, err)
+
+	receipt, err := auditor.SignReceipt(db.Bet{
+		PublicKey:     "pubkey",
+		FirstTicket:   1_001,
+		Index:         1_500,
+		Tickets:       500,
+		LotteryHeight: 840_000,
+	}, "payment_hash")
+	assert.NoError(t, err)
+
+	bundle := audit.NewBetBundle(receipt, 500, auditor.PublicKey())
+	assert.Equal(t, audit.BundleBet, bundle.Kind)
+	assert.Equal(t, uint64(500), bundle.Tickets)
+	assert.Equal(t, uint32(840_000), bundle.Round)
+
+	message, signature, err := audit.SignBundle(auditor, bundle)
+	assert.NoError(t, err)
+
+	err = audit.VerifyBundle(auditor.PublicKey(), message, signature)
+	assert.NoError(t, err)
+
+	// The bundle signature can't be passed off as a statement of another domain
+	err = audit.VerifySignature(auditor.PublicKey(), "domain", []byte(message), signature)
+	assert.Error(t, err)
+
+	t.Run("Tampered", func(t *testing.T) {
+		err := audit.VerifyBundle(auditor.PublicKey(), message+" ", signature)
+		assert.Error(t, err)
+	})
+
+	t.Run("PDF", func(t *testing.T) {
+		pdf := bundle.PDF(message, signature)
+		assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4")))
+		assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+		assert.Contains(t, string(pdf), "(Round:       840000) '")
+		assert.Contains(t, string(pdf), "(Tickets:     500 \\(1001 to 1500\\)) '")
+	})
+}
+
+func TestNewWinBundle(t *testing.T) {
+	commitment := db.Commitment{Height: 840_000, Seed: "seed", BlockHash: "block_hash"}
+	prizes := []db.Winner{
+		{PublicKey: "pubkey", Ticket: 5, Prize: 1_000, Pool: "main"},
+		{PublicKey: "pubkey", Ticket: 9, Prize: 250, Pool: "main"},
+	}
+
+	bundle := audit.NewWinBundle("pubkey", commitment, prizes, "server")
+	assert.Equal(t, audit.BundleWin, bundle.Kind)
+	assert.Equal(t, uint64(1_250), bundle.Amount)
+	assert.Equal(t, uint64(2), bundle.Tickets)
+	assert.Equal(t, "seed", bundle.ServerSeed)
+	assert.Nil(t, bundle.Receipt)
+
+	// Long lines are wrapped so they fit in the page
+	pdf := string(bundle.PDF(strings.Repeat("a", 200), "signature"))
+	assert.Contains(t, pdf, "(  Ticket 5 of pool \"main\": 1000 sats) '")
+	assert.NotContains(t, pdf, strings.Repeat("a", 100))
+}
@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// AuditorMock is a mocked implementation of an auditor.
+type AuditorMock struct {
+	mock.Mock
+}
+
+// NewAuditorMock returns a mocked auditor.
+func NewAuditorMock() *AuditorMock {
+	return &AuditorMock{}
+}
+
+// PublicKey mock.
+func (a *AuditorMock) PublicKey() string {
+	args := a.Called()
+	return args.String(0)
+}
+
+// Record mock.
+func (a *AuditorMock) Record(event Event, data any) {
+	_ = a.Called(event, data)
+}
+
+// Sign mock.
+func (a *AuditorMock) Sign(domain string, message []byte) (string, error) {
+	args := a.Called(domain, message)
+	return args.String(0), args.Error(1)
+}
+
+// SignReceipt mock.
+func (a *AuditorMock) SignReceipt(bet db.Bet, paymentHash string) (Receipt, error) {
+	args := a.Called(bet, paymentHash)
+	return args.Get(0).(Receipt), args.Error(1)
+}
@@ -0,0 +1,111 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// PDF layout, in points. Pages are A4 and text is written in 10pt Courier, whose glyphs are 6pt
+// wide.
+const (
+	pdfWidth      = 595
+	pdfHeight     = 842
+	pdfMargin     = 50
+	pdfLeading    = 14
+	pdfLineLength = (pdfWidth - 2*pdfMargin) / 6
+	pdfPageLines  = (pdfHeight - 2*pdfMargin) / pdfLeading
+	// pdfFontObject is the number of the font object, the pages and their contents follow it
+	pdfFontObject = 3
+)
+
+// renderPDF returns a PDF document with the lines of text, wrapped to fit the page width. Only the
+// fonts every PDF reader has are used, so the document doesn't embed any.
+func renderPDF(lines []string) []byte {
+	var wrapped []string
+	for _, line := range lines {
+		wrapped = append(wrapped, wrapLine(line, pdfLineLength)...)
+	}
+
+	var pages [][]string
+	for len(wrapped) > pdfPageLines {
+		pages = append(pages, wrapped[:pdfPageLines])
+		wrapped = wrapped[pdfPageLines:]
+	}
+	pages = append(pages, wrapped)
+
+	var (
+		buf     bytes.Buffer
+		offsets []int
+	)
+	object := func(content string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), content)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+
+	// Every page is followed by its content stream
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", pdfFontObject+1+2*i)
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>")
+
+	for i, page := range pages {
+		var stream bytes.Buffer
+		fmt.Fprintf(&stream, "BT /F1 10 Tf %d TL %d %d Td\n", pdfLeading, pdfMargin, pdfHeight-pdfMargin)
+		for _, line := range page {
+			fmt.Fprintf(&stream, "(%s) '\n", escapePDF(line))
+		}
+		stream.WriteString("ET")
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+			"/Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+			pdfWidth, pdfHeight, pdfFontObject, pdfFontObject+2+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", stream.Len(), stream.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.Bytes()
+}
+
+// wrapLine splits the line in chunks of up to length characters, breaking at spaces when possible.
+func wrapLine(line string, length int) []string {
+	var lines []string
+	for len(line) > length {
+		cut := strings.LastIndex(line[:length+1], " ")
+		if cut <= 0 {
+			cut = length
+		}
+		lines = append(lines, line[:cut])
+		line = strings.TrimLeft(line[cut:], " ")
+	}
+	return append(lines, line)
+}
+
+// escapePDF escapes the characters with a special meaning in PDF strings and replaces the ones
+// the standard fonts can't represent.
+func escapePDF(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < ' ' || r > '~':
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// ErrSigningDisabled is returned when receipts are requested but the audit log, which holds the
+// signing key, is disabled.
+var ErrSigningDisabled = errors.New("signing key not configured")
+
+// receiptDomain separates the receipts signatures from the audit log entries ones.
+const receiptDomain = "btry-receipt-v1"
+
+// Receipt is the server's signed acknowledgement of a bet. It lets players prove they held a
+// range of tickets in a lottery without relying on the server database.
+type Receipt = db.Receipt
+
+// SignReceipt returns a receipt of the bet stored, signed with the audit log key.
+func (a *auditor) SignReceipt(bet db.Bet, paymentHash string) (Receipt, error) {
+	if !a.enabled {
+		return Receipt{}, ErrSigningDisabled
+	}
+
+	receipt := Receipt{
+		PublicKey:    bet.PublicKey,
+		PaymentHash:  paymentHash,
+		Round:        bet.LotteryHeight,
+		FirstTicket:  bet.FirstTicket,
+		LastTicket:   bet.Index,
+		Timestamp:    time.Now().Unix(),
+		BonusTickets: bet.Bonus,
+	}
+	receipt.Signature = hex.EncodeToString(ed25519.Sign(a.privateKey, ReceiptHash(receipt)))
+
+	return receipt, nil
+}
+
+// Sign signs a message of another kind of statement with the audit log key. The domain separates
+// its signatures from the ones of the log entries, the receipts and other statements.
+func (a *auditor) Sign(domain string, message []byte) (string, error) {
+	if !a.enabled {
+		return "", ErrSigningDisabled
+	}
+
+	return hex.EncodeToString(ed25519.Sign(a.privateKey, messageHash(domain, message))), nil
+}
+
+// VerifySignature checks that the message was signed with Sign by the owner of the public key.
+func VerifySignature(publicKey, domain string, message []byte, signature string) error {
+	pubKey, err := hex.DecodeString(publicKey)
+	if err != nil {
+		return errors.Wrap(err, "decoding public key")
+	}
+	if len(pubKey) != ed25519.PublicKeySize {
+		return errors.New("invalid public key length")
+	}
+
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return errors.Wrap(err, "decoding signature")
+	}
+
+	if !ed25519.Verify(pubKey, messageHash(domain, message), sig) {
+		return errors.New("invalid signature")
+	}
+
+	return nil
+}
+
+func messageHash(domain string, message []byte) []byte {
+	hash := sha256.New()
+	hash.Write([]byte(domain))
+	hash.Write([]byte{0})
+	hash.Write(message)
+	return hash.Sum(nil)
+}
+
+// ReceiptHash returns the hash of the receipt content, which is what gets signed.
+func ReceiptHash(receipt Receipt) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(receiptDomain)
+	buf.WriteByte(0)
+	buf.WriteString(receipt.PublicKey)
+	buf.WriteByte(0)
+	buf.WriteString(receipt.PaymentHash)
+	buf.WriteByte(0)
+
+	var num [8]byte
+	binary.BigEndian.PutUint32(num[:4], receipt.Round)
+	buf.Write(num[:4])
+	binary.BigEndian.PutUint64(num[:], receipt.FirstTicket)
+	buf.Write(num[:])
+	binary.BigEndian.PutUint64(num[:], receipt.LastTicket)
+	buf.Write(num[:])
+	binary.BigEndian.PutUint64(num[:], uint64(receipt.Timestamp))
+	buf.Write(num[:])
+	// Appended only when granted so the receipts issued before bonus tickets existed still verify
+	if receipt.BonusTickets > 0 {
+		binary.BigEndian.PutUint64(num[:], receipt.BonusTickets)
+		buf.Write(num[:])
+	}
+
+	hash := sha256.Sum256(buf.Bytes())
+	return hash[:]
+}
+
+// VerifyReceipt checks that the receipt was signed by the owner of the public key.
+func VerifyReceipt(publicKey string, receipt Receipt) error {
+	pubKey, err := hex.DecodeString(publicKey)
+	if err != nil {
+		return errors.Wrap(err, "decoding public key")
+	}
+	if len(pubKey) != ed25519.PublicKeySize {
+		return errors.New("invalid public key length")
+	}
+
+	signature, err := hex.DecodeString(receipt.Signature)
+	if err != nil {
+		return errors.Wrap(err, "decoding signature")
+	}
+
+	if receipt.FirstTicket == 0 || receipt.FirstTicket > receipt.LastTicket {
+		return errors.New("invalid ticket range")
+	}
+
+	if !ed25519.Verify(pubKey, ReceiptHash(receipt), signature) {
+		return errors.New("invalid signature")
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package audit_test
+
+import (
+	"testing"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignReceipt(t *testing.T) {
+	auditor, err := audit.New(config.Audit{Enabled: true, PrivateKey: privateKey}, setupDB(t))
+	assert.NoError(t, err)
+
+	bet := db.Bet{
+		PublicKey:     "pubkey",
+		FirstTicket:   1_001,
+		Index:         1_500,
+		Tickets:       500,
+		Bonus:         20,
+		LotteryHeight: 840_000,
+	}
+	receipt, err := auditor.SignReceipt(bet, "payment_hash")
+	assert.NoError(t, err)
+
+	assert.Equal(t, uint64(1_001), receipt.FirstTicket)
+	assert.Equal(t, uint64(1_500), receipt.LastTicket)
+	assert.Equal(t, uint32(840_000), receipt.Round)
+	assert.Equal(t, uint64(20), receipt.BonusTickets)
+	assert.Equal(t, "payment_hash", receipt.PaymentHash)
+
+	err = audit.VerifyReceipt(auditor.PublicKey(), receipt)
+	assert.NoError(t, err)
+
+	t.Run("Tampered", func(t *testing.T) {
+		tampered := receipt
+		tampered.LastTicket = 2_000
+		err := audit.VerifyReceipt(auditor.PublicKey(), tampered)
+		assert.Error(t, err)
+	})
+
+	t.Run("Tampered bonus", func(t *testing.T) {
+		tampered := receipt
+		tampered.BonusTickets = 100
+		err := audit.VerifyReceipt(auditor.PublicKey(), tampered)
+		assert.Error(t, err)
+	})
+
+	t.Run("Invalid range", func(t *testing.T) {
+		invalid := receipt
+		invalid.FirstTicket = 0
+		err := audit.VerifyReceipt(auditor.PublicKey(), invalid)
+		assert.Error(t, err)
+	})
+
+	t.Run("Invalid public key", func(t *testing.T) {
+		err := audit.VerifyReceipt("pubkey", receipt)
+		assert.Error(t, err)
+	})
+}
+
+func TestSignReceiptDisabled(t *testing.T) {
+	auditor, err := audit.New(config.Audit{Enabled: false}, setupDB(t))
+	assert.NoError(t, err)
+
+	_, err = auditor.SignReceipt(db.Bet{Index: 1, Tickets: 1}, "payment_hash")
+	assert.ErrorIs(t, err, audit.ErrSigningDisabled)
+}
+
+func TestSign(t *testing.T) {
+	auditor, err := audit.New(config.Audit{Enabled: true, PrivateKey: privateKey}, setupDB(t))
+	assert.NoError(t, err)
+
+	message := []byte(`{"liabilities":1000}`)
+	signature, err := auditor.Sign("domain", message)
+	assert.NoError(t, err)
+
+	err = audit.VerifySignature(auditor.PublicKey(), "domain", message, signature)
+	assert.NoError(t, err)
+
+	t.Run("Other domain", func(t *testing.T) {
+		err := audit.VerifySignature(auditor.PublicKey(), "other", message, signature)
+		assert.Error(t, err)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		err := audit.VerifySignature(auditor.PublicKey(), "domain", []byte(`{"liabilities":1}`), signature)
+		assert.Error(t, err)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		auditor, err := audit.New(config.Audit{Enabled: false}, setupDB(t))
+		assert.NoError(t, err)
+
+		_, err = auditor.Sign("domain", message)
+		assert.ErrorIs(t, err, audit.ErrSigningDisabled)
+	})
+}
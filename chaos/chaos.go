@@ -0,0 +1,11 @@
+// Package chaos injects failures in the draw and payout paths to verify they recover from them:
+// delays, dropped block events, failing database writes and lightning errors.
+//
+// The failures are only injected by the builds with the chaos tag (go build -tags chaos), in the
+// rest the hooks do nothing and the configuration is ignored.
+package chaos
+
+import "github.com/pkg/errors"
+
+// ErrInjected is the cause of the failures injected.
+var ErrInjected = errors.New("chaos: injected failure")
@@ -0,0 +1,35 @@
+//go:build !chaos
+
+package chaos
+
+import (
+	"github.com/aftermath2/BTRY/config"
+
+	"google.golang.org/grpc"
+)
+
+// Enabled is true in the builds that inject failures.
+const Enabled = false
+
+// Configure does nothing, failures are not injected in this build.
+func Configure(config.Chaos) {}
+
+// DropBlock always returns false, failures are not injected in this build.
+func DropBlock() bool {
+	return false
+}
+
+// FailWrite always returns nil, failures are not injected in this build.
+func FailWrite() error {
+	return nil
+}
+
+// DialOptions returns no options, failures are not injected in this build.
+func DialOptions() []grpc.DialOption {
+	return nil
+}
+
+// Driver returns the name of the driver as is, failures are not injected in this build.
+func Driver(name string) string {
+	return name
+}
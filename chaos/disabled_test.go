@@ -0,0 +1,22 @@
+//go:build !chaos
+
+package chaos_test
+
+import (
+	"testing"
+
+	"github.com/aftermath2/BTRY/chaos"
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisabled(t *testing.T) {
+	chaos.Configure(config.Chaos{Enabled: true, DropBlock: 1, FailWrite: 1, LightningError: 1})
+
+	assert.False(t, chaos.Enabled)
+	assert.False(t, chaos.DropBlock())
+	assert.NoError(t, chaos.FailWrite())
+	assert.Empty(t, chaos.DialOptions())
+	assert.Equal(t, "sqlite", chaos.Driver("sqlite"))
+}
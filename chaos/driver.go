@@ -0,0 +1,193 @@
+//go:build chaos
+
+package chaos
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var registerMu sync.Mutex
+
+// Driver registers a database driver that wraps the one with the name specified, failing and
+// delaying the writes, and returns its name. The name is returned as is if the driver wasn't
+// registered.
+func Driver(name string) string {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+
+	chaosName := "chaos-" + name
+	if slices.Contains(sql.Drivers(), chaosName) {
+		return chaosName
+	}
+
+	// Opening the database doesn't connect to it, it's only used to get the driver
+	db, err := sql.Open(name, "")
+	if err != nil {
+		return name
+	}
+	defer db.Close()
+
+	sql.Register(chaosName, &chaosDriver{Driver: db.Driver()})
+	return chaosName
+}
+
+// isWrite returns whether the statement modifies the database.
+func isWrite(query string) bool {
+	query = strings.ToUpper(strings.TrimSpace(query))
+	for _, prefix := range []string{"INSERT", "UPDATE", "DELETE", "REPLACE"} {
+		if strings.HasPrefix(query, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type chaosDriver struct {
+	driver.Driver
+}
+
+func (d *chaosDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: c}, nil
+}
+
+// conn wraps the driver connections so the statements prepared and executed on them can fail.
+type conn struct {
+	driver.Conn
+}
+
+// Unwrap returns the connection of the wrapped driver, for the callers that use its own methods.
+func (c *conn) Unwrap() driver.Conn {
+	return c.Conn
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		s   driver.Stmt
+		err error
+	)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = preparer.PrepareContext(ctx, query)
+	} else {
+		s, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &stmt{Stmt: s, write: isWrite(query)}, nil
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if isWrite(query) {
+		if err := FailWrite(); err != nil {
+			return nil, err
+		}
+	}
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	// Writes returning rows are queried
+	if isWrite(query) {
+		if err := FailWrite(); err != nil {
+			return nil, err
+		}
+	}
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c *conn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// stmt wraps the driver statements, the ones that modify the database can fail.
+type stmt struct {
+	driver.Stmt
+	write bool
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if s.write {
+		if err := FailWrite(); err != nil {
+			return nil, err
+		}
+	}
+
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	return s.Stmt.Exec(values(args))
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if s.write {
+		if err := FailWrite(); err != nil {
+			return nil, err
+		}
+	}
+
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+	return s.Stmt.Query(values(args))
+}
+
+func (s *stmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
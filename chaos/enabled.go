@@ -0,0 +1,128 @@
+//go:build chaos
+
+package chaos
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/fault"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Enabled is true in the builds that inject failures.
+const Enabled = true
+
+// injector decides which events fail, it's shared by all the hooks so the configuration can be
+// changed at runtime.
+var injector = &faults{rand: rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), 0))}
+
+type faults struct {
+	rand   *rand.Rand
+	config config.Chaos
+	mu     sync.Mutex
+}
+
+// Configure sets the probabilities of the failures injected from now on. It's called at startup
+// and every time the configuration is reloaded, a non-zero seed restarts the sequence of failures.
+func Configure(config config.Chaos) {
+	injector.mu.Lock()
+	defer injector.mu.Unlock()
+
+	injector.config = config
+	if config.Seed != 0 {
+		injector.rand = rand.New(rand.NewPCG(config.Seed, 0))
+	}
+}
+
+// roll returns whether the event selected by the probability function fails and, if it's delayed,
+// for how long.
+func roll(probability func(config.Chaos) float64) (bool, time.Duration) {
+	injector.mu.Lock()
+	defer injector.mu.Unlock()
+
+	config := injector.config
+	if !config.Enabled {
+		return false, 0
+	}
+
+	var delay time.Duration
+	if config.Delay > 0 && injector.rand.Float64() < config.Delay {
+		delay = time.Duration(injector.rand.Int64N(int64(config.MaxDelay)) + 1)
+	}
+
+	p := probability(config)
+	return p > 0 && injector.rand.Float64() < p, delay
+}
+
+// DropBlock returns whether the block event received must be discarded. The event may be delayed
+// as well.
+func DropBlock() bool {
+	drop, delay := roll(func(c config.Chaos) float64 { return c.DropBlock })
+	sleep(context.Background(), delay)
+	return drop
+}
+
+// FailWrite returns a transient error if the database write must fail. The write may be delayed as
+// well.
+func FailWrite() error {
+	fail, delay := roll(func(c config.Chaos) float64 { return c.FailWrite })
+	sleep(context.Background(), delay)
+	if fail {
+		return fault.NewTransient(errors.Wrap(ErrInjected, "database write"))
+	}
+	return nil
+}
+
+// lightningError returns an unavailable status if the lightning call must fail, it's classified as
+// transient like the node being unreachable. The call may be delayed as well.
+func lightningError(ctx context.Context, method string) error {
+	fail, delay := roll(func(c config.Chaos) float64 { return c.LightningError })
+	sleep(ctx, delay)
+	if fail {
+		return status.Errorf(codes.Unavailable, "%v: %s", ErrInjected, method)
+	}
+	return nil
+}
+
+// DialOptions returns the options that make the unary calls to the lightning node fail or wait,
+// they must be added after the ones classifying their errors. Subscriptions are left alone, dropped
+// block events are injected by DropBlock.
+func DialOptions() []grpc.DialOption {
+	unary := func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if err := lightningError(ctx, method); err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	return []grpc.DialOption{grpc.WithChainUnaryInterceptor(unary)}
+}
+
+func sleep(ctx context.Context, delay time.Duration) {
+	if delay == 0 {
+		return
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
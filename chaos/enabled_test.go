@@ -0,0 +1,122 @@
+//go:build chaos
+
+package chaos
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/fault"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	_ "modernc.org/sqlite"
+)
+
+func TestInjector(t *testing.T) {
+	t.Cleanup(func() { Configure(config.Chaos{}) })
+
+	t.Run("Disabled", func(t *testing.T) {
+		Configure(config.Chaos{DropBlock: 1, FailWrite: 1, LightningError: 1})
+
+		assert.False(t, DropBlock())
+		assert.NoError(t, FailWrite())
+		assert.NoError(t, lightningError(context.Background(), "/lnrpc.Lightning/GetInfo"))
+	})
+
+	t.Run("Always", func(t *testing.T) {
+		Configure(config.Chaos{Enabled: true, DropBlock: 1, FailWrite: 1, LightningError: 1})
+
+		assert.True(t, DropBlock())
+
+		err := FailWrite()
+		assert.ErrorIs(t, err, ErrInjected)
+		assert.True(t, fault.IsTransient(err))
+
+		err = lightningError(context.Background(), "/lnrpc.Lightning/GetInfo")
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+
+	t.Run("Seeded", func(t *testing.T) {
+		chaos := config.Chaos{Enabled: true, Seed: 21, DropBlock: 0.5}
+		sequence := func() []bool {
+			Configure(chaos)
+			drops := make([]bool, 20)
+			for i := range drops {
+				drops[i] = DropBlock()
+			}
+			return drops
+		}
+
+		first := sequence()
+		assert.Equal(t, first, sequence())
+		assert.Contains(t, first, true)
+		assert.Contains(t, first, false)
+	})
+
+	t.Run("Delay", func(t *testing.T) {
+		Configure(config.Chaos{Enabled: true, Delay: 1, MaxDelay: time.Millisecond})
+
+		start := time.Now()
+		assert.False(t, DropBlock())
+		assert.Greater(t, time.Since(start), time.Duration(0))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Configure(config.Chaos{Enabled: true, Delay: 1, MaxDelay: time.Hour})
+		assert.NoError(t, lightningError(ctx, "/lnrpc.Lightning/GetInfo"))
+	})
+}
+
+func TestDriver(t *testing.T) {
+	t.Cleanup(func() { Configure(config.Chaos{}) })
+
+	name := Driver("sqlite")
+	assert.Equal(t, "chaos-sqlite", name)
+	assert.Equal(t, name, Driver("sqlite"))
+	assert.Equal(t, "unknown", Driver("unknown"))
+
+	db, err := sql.Open(name, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE prizes (public_key TEXT, amount INTEGER)")
+	require.NoError(t, err)
+
+	Configure(config.Chaos{Enabled: true, FailWrite: 1})
+
+	// Schema changes and reads are not affected
+	_, err = db.Exec("CREATE INDEX prizes_public_key ON prizes(public_key)")
+	assert.NoError(t, err)
+
+	_, err = db.Exec("INSERT INTO prizes (public_key, amount) VALUES (?, ?)", "pubkey", uint64(10))
+	assert.ErrorIs(t, err, ErrInjected)
+
+	stmt, err := db.Prepare("UPDATE prizes SET amount=0 WHERE public_key=? RETURNING amount")
+	require.NoError(t, err)
+	defer stmt.Close()
+	_, err = stmt.Query("pubkey")
+	assert.ErrorIs(t, err, ErrInjected)
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	_, err = tx.Exec("DELETE FROM prizes")
+	assert.ErrorIs(t, err, ErrInjected)
+	require.NoError(t, tx.Rollback())
+
+	Configure(config.Chaos{Enabled: true})
+
+	_, err = db.Exec("INSERT INTO prizes (public_key, amount) VALUES (?, ?)", "pubkey", uint64(10))
+	assert.NoError(t, err)
+
+	var amount uint64
+	err = db.QueryRow("SELECT amount FROM prizes WHERE public_key=?", "pubkey").Scan(&amount)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), amount)
+}
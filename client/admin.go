@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/policy"
+)
+
+// Admin sends requests to the operators API. Its operations are not part of the OpenAPI document
+// and are written by hand.
+type Admin struct {
+	client *Client
+}
+
+// NewAdmin returns a client of the operators API served at the base URL. The session token is the
+// one returned by the login, it's sent in the Authorization header like the players public key.
+func NewAdmin(baseURL, sessionToken string, httpClient *http.Client) *Admin {
+	return &Admin{client: New(baseURL, sessionToken, httpClient)}
+}
+
+// ReplayParams contains the parameters of Replay. Either the distribution or the fee must be set.
+type ReplayParams struct {
+	Pool         string
+	Rounding     string
+	Distribution []float64
+	Fee          float64
+	Height       uint32
+}
+
+// Replay draws a past lottery pool again with another distribution or fee, without storing the
+// results.
+func (a *Admin) Replay(ctx context.Context, params ReplayParams) (handler.ReplayResponse, error) {
+	query := url.Values{}
+	query.Set("height", strconv.FormatUint(uint64(params.Height), 10))
+	if params.Pool != "" {
+		query.Set("pool", params.Pool)
+	}
+	if params.Rounding != "" {
+		query.Set("rounding", params.Rounding)
+	}
+	if len(params.Distribution) > 0 {
+		percentages := make([]string, 0, len(params.Distribution))
+		for _, p := range params.Distribution {
+			percentages = append(percentages, strconv.FormatFloat(p, 'f', -1, 64))
+		}
+		query.Set("distribution", strings.Join(percentages, ","))
+	} else {
+		query.Set("fee", strconv.FormatFloat(params.Fee, 'f', -1, 64))
+	}
+	var resp handler.ReplayResponse
+	err := a.client.do(ctx, http.MethodGet, "/admin/replay", query, true, nil, &resp)
+	return resp, err
+}
+
+// ListScheduledPayouts returns the automatic payouts deferred until the routing fees go down.
+func (a *Admin) ListScheduledPayouts(ctx context.Context) ([]db.ScheduledPayout, error) {
+	var resp []db.ScheduledPayout
+	err := a.client.do(ctx, http.MethodGet, "/admin/payouts/scheduled", nil, true, nil, &resp)
+	return resp, err
+}
+
+// ListApprovals returns the payouts awaiting the operators approval.
+func (a *Admin) ListApprovals(ctx context.Context) ([]db.Approval, error) {
+	var resp []db.Approval
+	err := a.client.do(ctx, http.MethodGet, "/admin/approvals", nil, true, nil, &resp)
+	return resp, err
+}
+
+// ListFeeDistributions returns the shares of the lotteries fee forwarded to the fee destinations,
+// the newest first.
+func (a *Admin) ListFeeDistributions(ctx context.Context, offset, limit uint64) ([]db.FeeDistribution, error) {
+	query := url.Values{}
+	query.Set("offset", strconv.FormatUint(offset, 10))
+	query.Set("limit", strconv.FormatUint(limit, 10))
+	var resp []db.FeeDistribution
+	err := a.client.do(ctx, http.MethodGet, "/admin/fees", query, true, nil, &resp)
+	return resp, err
+}
+
+// GetGrowth returns the projection of the prize pool of the lottery at the height specified, the
+// latest one opened if it's zero.
+func (a *Admin) GetGrowth(ctx context.Context, height uint32) (handler.GrowthResponse, error) {
+	query := url.Values{}
+	if height != 0 {
+		query.Set("height", strconv.FormatUint(uint64(height), 10))
+	}
+	var resp handler.GrowthResponse
+	err := a.client.do(ctx, http.MethodGet, "/admin/growth", query, true, nil, &resp)
+	return resp, err
+}
+
+// ScheduleMaintenance sets a maintenance window between the unix timestamps, it starts immediately
+// if from is zero.
+func (a *Admin) ScheduleMaintenance(ctx context.Context, from, until int64) (policy.MaintenanceStatus, error) {
+	query := url.Values{}
+	if from != 0 {
+		query.Set("from", strconv.FormatInt(from, 10))
+	}
+	query.Set("until", strconv.FormatInt(until, 10))
+	var resp policy.MaintenanceStatus
+	err := a.client.do(ctx, http.MethodPost, "/admin/maintenance", query, true, nil, &resp)
+	return resp, err
+}
+
+// EndMaintenance finishes the maintenance in progress or cancels the scheduled one.
+func (a *Admin) EndMaintenance(ctx context.Context) (policy.MaintenanceStatus, error) {
+	var resp policy.MaintenanceStatus
+	err := a.client.do(ctx, http.MethodDelete, "/admin/maintenance", nil, true, nil, &resp)
+	return resp, err
+}
+
+// GetMaintenance returns the maintenance window.
+func (a *Admin) GetMaintenance(ctx context.Context) (policy.MaintenanceStatus, error) {
+	return a.client.GetMaintenance(ctx)
+}
+
+// ListAPIKeys returns the API keys created, without the keys themselves.
+func (a *Admin) ListAPIKeys(ctx context.Context) ([]db.APIKey, error) {
+	var resp []db.APIKey
+	err := a.client.do(ctx, http.MethodGet, "/admin/keys", nil, true, nil, &resp)
+	return resp, err
+}
+
+// CreateAPIKey returns a new API key granted the scopes specified, a comma separated list. A rate
+// limit of 0 uses the default one.
+func (a *Admin) CreateAPIKey(ctx context.Context, name, scopes string, rateLimit uint64) (handler.APIKeyResponse, error) {
+	query := url.Values{}
+	query.Set("name", name)
+	query.Set("scopes", scopes)
+	if rateLimit != 0 {
+		query.Set("rate_limit", strconv.FormatUint(rateLimit, 10))
+	}
+	var resp handler.APIKeyResponse
+	err := a.client.do(ctx, http.MethodPost, "/admin/keys", query, true, nil, &resp)
+	return resp, err
+}
+
+// RotateAPIKey replaces an API key with a new one, keeping its scopes and rate limit.
+func (a *Admin) RotateAPIKey(ctx context.Context, id uint64) (handler.APIKeyResponse, error) {
+	query := url.Values{}
+	query.Set("id", strconv.FormatUint(id, 10))
+	var resp handler.APIKeyResponse
+	err := a.client.do(ctx, http.MethodPost, "/admin/keys/rotate", query, true, nil, &resp)
+	return resp, err
+}
+
+// RevokeAPIKey deletes an API key.
+func (a *Admin) RevokeAPIKey(ctx context.Context, id uint64) error {
+	query := url.Values{}
+	query.Set("id", strconv.FormatUint(id, 10))
+	var resp handler.RevokeAPIKeyResponse
+	return a.client.do(ctx, http.MethodDelete, "/admin/keys", query, true, nil, &resp)
+}
@@ -0,0 +1,114 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aftermath2/BTRY/client"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sessionToken = "session_token"
+
+func TestAdminReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/api/admin/replay", r.URL.Path)
+		assert.Equal(t, "distribution=70%2C20.5&height=144&pool=micro", r.URL.RawQuery)
+		assert.Equal(t, "Bearer "+sessionToken, r.Header.Get("Authorization"))
+
+		json.NewEncoder(w).Encode(handler.ReplayResponse{Height: 144, Pool: "micro", PrizePool: 1_000})
+	}))
+	defer srv.Close()
+
+	admin := client.NewAdmin(srv.URL, sessionToken, srv.Client())
+	resp, err := admin.Replay(context.Background(), client.ReplayParams{
+		Height:       144,
+		Pool:         "micro",
+		Distribution: []float64{70, 20.5},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, uint64(1_000), resp.PrizePool)
+}
+
+func TestAdminReplayFee(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "fee=0&height=144", r.URL.RawQuery)
+
+		json.NewEncoder(w).Encode(handler.ReplayResponse{})
+	}))
+	defer srv.Close()
+
+	admin := client.NewAdmin(srv.URL, sessionToken, srv.Client())
+	_, err := admin.Replay(context.Background(), client.ReplayParams{Height: 144})
+	assert.NoError(t, err)
+}
+
+func TestAdminGrowth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/admin/growth", r.URL.Path)
+		assert.Equal(t, "height=144", r.URL.RawQuery)
+
+		resp := handler.GrowthResponse{Conversion: 0.5}
+		resp.ProjectedPrizePool = 49_000
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	admin := client.NewAdmin(srv.URL, sessionToken, srv.Client())
+	resp, err := admin.GetGrowth(context.Background(), 144)
+	assert.NoError(t, err)
+
+	assert.Equal(t, uint64(49_000), resp.ProjectedPrizePool)
+	assert.Equal(t, 0.5, resp.Conversion)
+}
+
+func TestAdminAPIKeys(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/admin/keys", r.URL.Path)
+
+		switch r.Method {
+		case http.MethodPost:
+			assert.Equal(t, "name=shop&rate_limit=50&scopes=bets", r.URL.RawQuery)
+			json.NewEncoder(w).Encode(handler.APIKeyResponse{
+				Key:    "key",
+				APIKey: db.APIKey{ID: 1, Name: "shop", RateLimit: 50},
+			})
+		case http.MethodDelete:
+			assert.Equal(t, "id=1", r.URL.RawQuery)
+			json.NewEncoder(w).Encode(handler.RevokeAPIKeyResponse{Success: true})
+		}
+	}))
+	defer srv.Close()
+
+	admin := client.NewAdmin(srv.URL, sessionToken, srv.Client())
+	resp, err := admin.CreateAPIKey(context.Background(), "shop", "bets", 50)
+	assert.NoError(t, err)
+	assert.Equal(t, "key", resp.Key)
+	assert.Equal(t, uint64(1), resp.APIKey.ID)
+
+	err = admin.RevokeAPIKey(context.Background(), 1)
+	assert.NoError(t, err)
+}
+
+func TestAdminUnauthorized(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"code":"UNAUTHORIZED","message":"unauthorized"}}`))
+	}))
+	defer srv.Close()
+
+	admin := client.NewAdmin(srv.URL, "expired", srv.Client())
+	_, err := admin.ListScheduledPayouts(context.Background())
+
+	var apiErr *client.Error
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
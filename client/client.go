@@ -0,0 +1,124 @@
+// Package client is a Go client of the BTRY HTTP API.
+//
+// The operations are generated from the OpenAPI document in http/api/openapi, run go generate in
+// that package after changing the API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Error is returned when the API responds with a status code other than 200.
+type Error struct {
+	// Code is the machine-readable code of the error, LNURL endpoints don't set it
+	Code       string
+	Message    string
+	StatusCode int
+	Retryable  bool
+	Details    map[string]any
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("btry: %d %s", e.StatusCode, e.Message)
+}
+
+// Client sends requests to the BTRY API.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	publicKey  string
+}
+
+// New returns a client of the API served at the base URL, e.g. https://btry.example. The public
+// key authenticates the player in the operations that require it and may be empty. A nil HTTP
+// client means http.DefaultClient.
+func New(baseURL, publicKey string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		httpClient: httpClient,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		publicKey:  publicKey,
+	}
+}
+
+func (c *Client) do(
+	ctx context.Context,
+	method, path string,
+	query url.Values,
+	auth bool,
+	body, resp any,
+) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "encoding request body")
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	u := c.baseURL + "/api" + path
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if auth && c.publicKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.publicKey)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "sending request")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var errResponse struct {
+			Error struct {
+				Code      string         `json:"code"`
+				Message   string         `json:"message"`
+				Retryable bool           `json:"retryable"`
+				Details   map[string]any `json:"details"`
+			} `json:"error"`
+			Reason string `json:"reason"`
+		}
+		_ = json.NewDecoder(res.Body).Decode(&errResponse)
+
+		if errResponse.Error.Code == "" {
+			return &Error{StatusCode: res.StatusCode, Message: errResponse.Reason}
+		}
+		return &Error{
+			Code:       errResponse.Error.Code,
+			Message:    errResponse.Error.Message,
+			StatusCode: res.StatusCode,
+			Retryable:  errResponse.Error.Retryable,
+			Details:    errResponse.Error.Details,
+		}
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+		return errors.Wrap(err, "decoding response body")
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aftermath2/BTRY/client"
+	"github.com/aftermath2/BTRY/http/api/handler"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const publicKey = "03864ef025fde8fb587d989186ce6a4a186895ee44a926bfc370e2c366597a3f8f"
+
+func TestGetBets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/api/bets", r.URL.Path)
+		assert.Equal(t, "height=5&reverse=true", r.URL.RawQuery)
+		assert.Empty(t, r.Header.Get("Authorization"))
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"bets": []map[string]any{{"public_key": publicKey, "tickets": 100}},
+		})
+	}))
+	defer srv.Close()
+
+	c := client.New(srv.URL, publicKey, srv.Client())
+	resp, err := c.GetBets(context.Background(), client.GetBetsParams{Height: 5, Reverse: true})
+	assert.NoError(t, err)
+
+	assert.Len(t, resp.Bets, 1)
+	assert.Equal(t, publicKey, resp.Bets[0].PublicKey)
+	assert.Equal(t, uint64(100), resp.Bets[0].Tickets)
+}
+
+func TestGetPrizes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer "+publicKey, r.Header.Get("Authorization"))
+		assert.Empty(t, r.URL.RawQuery)
+
+		json.NewEncoder(w).Encode(handler.GetPrizesResponse{Prizes: 2100})
+	}))
+	defer srv.Close()
+
+	c := client.New(srv.URL+"/", publicKey, nil)
+	resp, err := c.GetPrizes(context.Background())
+	assert.NoError(t, err)
+
+	assert.Equal(t, uint64(2100), resp.Prizes)
+}
+
+func TestWithdraw(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, []string{"lnbc1", "lnbc2"}, r.URL.Query()["pr"])
+		assert.Equal(t, []string{"10", "20"}, r.URL.Query()["fee"])
+		assert.Equal(t, publicKey, r.URL.Query().Get("pubkey"))
+
+		json.NewEncoder(w).Encode(handler.WithdrawResponse{PaymentIDs: []uint64{1, 2}})
+	}))
+	defer srv.Close()
+
+	c := client.New(srv.URL, "", srv.Client())
+	resp, err := c.Withdraw(context.Background(), client.WithdrawParams{
+		PublicKey:       publicKey,
+		K1:              "k1",
+		PaymentRequests: []string{"lnbc1", "lnbc2"},
+		Fees:            []uint64{10, 20},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []uint64{1, 2}, resp.PaymentIDs)
+}
+
+func TestError(t *testing.T) {
+	cases := []struct {
+		desc     string
+		body     string
+		expected *client.Error
+	}{
+		{
+			desc: "API error",
+			body: `{"error":{"code":"INVALID_REQUEST","message":"invalid height","retryable":false}}`,
+			expected: &client.Error{
+				Code:       "INVALID_REQUEST",
+				StatusCode: http.StatusBadRequest,
+				Message:    "invalid height",
+			},
+		},
+		{
+			desc: "API error with details",
+			body: `{"error":{"code":"CAPACITY_EXCEEDED","message":"capacity exceeded","retryable":true,` +
+				`"details":{"capacity":1000}}}`,
+			expected: &client.Error{
+				Code:       "CAPACITY_EXCEEDED",
+				StatusCode: http.StatusBadRequest,
+				Message:    "capacity exceeded",
+				Retryable:  true,
+				Details:    map[string]any{"capacity": float64(1000)},
+			},
+		},
+		{
+			desc:     "LNURL error",
+			body:     `{"status":"ERROR","reason":"invalid signature"}`,
+			expected: &client.Error{StatusCode: http.StatusBadRequest, Message: "invalid signature"},
+		},
+		{
+			desc:     "Empty body",
+			expected: &client.Error{StatusCode: http.StatusBadRequest},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+
+			c := client.New(srv.URL, "", srv.Client())
+			_, err := c.GetWinners(context.Background(), client.GetWinnersParams{Height: 1})
+			assert.Equal(t, tc.expected, err)
+		})
+	}
+}
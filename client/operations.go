@@ -0,0 +1,880 @@
+// Code generated by go generate; DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/http/api/tokens"
+	"github.com/aftermath2/BTRY/policy"
+	lnurl "github.com/fiatjaf/go-lnurl"
+)
+
+// GetArchivesParams contains the parameters of GetArchives.
+type GetArchivesParams struct {
+	// Number of items to skip
+	Offset uint64
+	// Maximum number of items returned
+	Limit uint64
+	// List the newest items first
+	Reverse bool
+}
+
+// GetArchives lists the exports of the draw history to Nostr and IPFS.
+func (c *Client) GetArchives(ctx context.Context, params GetArchivesParams) (handler.ArchivesResponse, error) {
+	query := url.Values{}
+	if params.Offset != 0 {
+		query.Set("offset", strconv.FormatUint(params.Offset, 10))
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.FormatUint(params.Limit, 10))
+	}
+	if params.Reverse {
+		query.Set("reverse", strconv.FormatBool(params.Reverse))
+	}
+	var resp handler.ArchivesResponse
+	err := c.do(ctx, http.MethodGet, "/archives", query, false, nil, &resp)
+	return resp, err
+}
+
+// GetBetsParams contains the parameters of GetBets.
+type GetBetsParams struct {
+	// Lottery height
+	Height uint64
+	// Number of items to skip
+	Offset uint64
+	// Maximum number of items returned
+	Limit uint64
+	// List the newest items first
+	Reverse bool
+	// Lottery pool, empty for the unnamed one
+	Pool string
+}
+
+// GetBets lists the bets of a lottery.
+func (c *Client) GetBets(ctx context.Context, params GetBetsParams) (handler.BetsResponse, error) {
+	query := url.Values{}
+	query.Set("height", strconv.FormatUint(params.Height, 10))
+	if params.Offset != 0 {
+		query.Set("offset", strconv.FormatUint(params.Offset, 10))
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.FormatUint(params.Limit, 10))
+	}
+	if params.Reverse {
+		query.Set("reverse", strconv.FormatBool(params.Reverse))
+	}
+	if params.Pool != "" {
+		query.Set("pool", params.Pool)
+	}
+	var resp handler.BetsResponse
+	err := c.do(ctx, http.MethodGet, "/bets", query, false, nil, &resp)
+	return resp, err
+}
+
+// CancelBetParams contains the parameters of CancelBet.
+type CancelBetParams struct {
+	// Hash of the invoice that paid the bet
+	PaymentHash string
+	// Signature of the public key
+	Signature string
+}
+
+// CancelBet cancels a recent bet, refunding the sats paid minus the cancellation fee.
+func (c *Client) CancelBet(ctx context.Context, params CancelBetParams) (handler.CancelBetResponse, error) {
+	query := url.Values{}
+	query.Set("payment_hash", params.PaymentHash)
+	query.Set("signature", params.Signature)
+	var resp handler.CancelBetResponse
+	err := c.do(ctx, http.MethodDelete, "/bets", query, true, nil, &resp)
+	return resp, err
+}
+
+// GetClaimParams contains the parameters of GetClaim.
+type GetClaimParams struct {
+	// Claim code of the bet
+	Code string
+}
+
+// GetClaim returns the prizes of an anonymous bet.
+func (c *Client) GetClaim(ctx context.Context, params GetClaimParams) (handler.GetPrizesResponse, error) {
+	query := url.Values{}
+	query.Set("code", params.Code)
+	var resp handler.GetPrizesResponse
+	err := c.do(ctx, http.MethodGet, "/claim", query, false, nil, &resp)
+	return resp, err
+}
+
+// ClaimParams contains the parameters of Claim.
+type ClaimParams struct {
+	// Claim code of the bet
+	Code string
+	// Invoice to pay, repeat it to split the withdrawal. A single zero-amount invoice is paid with all the prizes left after the fee
+	PaymentRequests []string
+	// Maximum routing fee of each invoice, in sats
+	Fees []uint64
+}
+
+// Claim withdraws the prizes of an anonymous bet.
+func (c *Client) Claim(ctx context.Context, params ClaimParams) (handler.WithdrawResponse, error) {
+	query := url.Values{}
+	query.Set("code", params.Code)
+	for _, v := range params.PaymentRequests {
+		query.Add("pr", v)
+	}
+	for _, v := range params.Fees {
+		query.Add("fee", strconv.FormatUint(v, 10))
+	}
+	var resp handler.WithdrawResponse
+	err := c.do(ctx, http.MethodPost, "/claim", query, false, nil, &resp)
+	return resp, err
+}
+
+// StartSwapClaimParams contains the parameters of StartSwapClaim.
+type StartSwapClaimParams struct {
+	// Signature of the public key
+	Signature string
+}
+
+// StartSwapClaim returns the payment hash the hold invoice of a swap claim must be locked to.
+func (c *Client) StartSwapClaim(ctx context.Context, params StartSwapClaimParams) (handler.SwapClaimResponse, error) {
+	query := url.Values{}
+	query.Set("signature", params.Signature)
+	var resp handler.SwapClaimResponse
+	err := c.do(ctx, http.MethodPost, "/claims/swap", query, true, nil, &resp)
+	return resp, err
+}
+
+// CountersignSwapClaimParams contains the parameters of CountersignSwapClaim.
+type CountersignSwapClaimParams struct {
+	// Payment hash of the swap claim
+	PaymentHash string
+	// Player signature of the receipt
+	Countersignature string
+	// Signature of the public key
+	Signature string
+}
+
+// CountersignSwapClaim countersigns the receipt of a swap claim, revealing the preimage of the hold invoice.
+func (c *Client) CountersignSwapClaim(ctx context.Context, params CountersignSwapClaimParams) (handler.SwapPreimageResponse, error) {
+	query := url.Values{}
+	query.Set("payment_hash", params.PaymentHash)
+	query.Set("countersignature", params.Countersignature)
+	query.Set("signature", params.Signature)
+	var resp handler.SwapPreimageResponse
+	err := c.do(ctx, http.MethodPost, "/claims/swap/countersign", query, true, nil, &resp)
+	return resp, err
+}
+
+// IssueClaimTokenParams contains the parameters of IssueClaimToken.
+type IssueClaimTokenParams struct {
+	// Origin of the site the token is bound to
+	Origin string
+	// Signature of the public key
+	Signature string
+}
+
+// IssueClaimToken returns a short-lived token that lets a third-party site start the claim flow.
+func (c *Client) IssueClaimToken(ctx context.Context, params IssueClaimTokenParams) (tokens.Token, error) {
+	query := url.Values{}
+	query.Set("origin", params.Origin)
+	query.Set("signature", params.Signature)
+	var resp tokens.Token
+	err := c.do(ctx, http.MethodPost, "/claims/token", query, true, nil, &resp)
+	return resp, err
+}
+
+// GetWidgetClaimParams contains the parameters of GetWidgetClaim.
+type GetWidgetClaimParams struct {
+	// Claim token
+	Token string
+}
+
+// GetWidgetClaim returns the prizes a claim token gives access to.
+func (c *Client) GetWidgetClaim(ctx context.Context, params GetWidgetClaimParams) (handler.GetPrizesResponse, error) {
+	query := url.Values{}
+	query.Set("token", params.Token)
+	var resp handler.GetPrizesResponse
+	err := c.do(ctx, http.MethodGet, "/claims/widget", query, false, nil, &resp)
+	return resp, err
+}
+
+// WidgetClaimParams contains the parameters of WidgetClaim.
+type WidgetClaimParams struct {
+	// Claim token
+	Token string
+	// Invoice to pay, repeat it to split the withdrawal. A single zero-amount invoice is paid with all the prizes left after the fee
+	PaymentRequests []string
+	// Maximum routing fee of each invoice, in sats
+	Fees []uint64
+	// Winner signature of the token ID and the invoices
+	Confirmation string
+}
+
+// WidgetClaim withdraws the prizes a claim token gives access to.
+func (c *Client) WidgetClaim(ctx context.Context, params WidgetClaimParams) (handler.WithdrawResponse, error) {
+	query := url.Values{}
+	query.Set("token", params.Token)
+	for _, v := range params.PaymentRequests {
+		query.Add("pr", v)
+	}
+	for _, v := range params.Fees {
+		query.Add("fee", strconv.FormatUint(v, 10))
+	}
+	query.Set("confirmation", params.Confirmation)
+	var resp handler.WithdrawResponse
+	err := c.do(ctx, http.MethodPost, "/claims/widget", query, false, nil, &resp)
+	return resp, err
+}
+
+// PaySwapClaimParams contains the parameters of PaySwapClaim.
+type PaySwapClaimParams struct {
+	// Hold invoice to pay
+	PaymentRequest string
+	// Maximum routing fee, in sats
+	Fee uint64
+	// Signature of the public key
+	Signature string
+}
+
+// PaySwapClaim withdraws the prizes paying a hold invoice locked to the swap claim hash.
+func (c *Client) PaySwapClaim(ctx context.Context, params PaySwapClaimParams) (handler.SwapPaymentResponse, error) {
+	query := url.Values{}
+	query.Set("pr", params.PaymentRequest)
+	if params.Fee != 0 {
+		query.Set("fee", strconv.FormatUint(params.Fee, 10))
+	}
+	query.Set("signature", params.Signature)
+	var resp handler.SwapPaymentResponse
+	err := c.do(ctx, http.MethodPost, "/claims/swap/pay", query, true, nil, &resp)
+	return resp, err
+}
+
+// GetHeightsParams contains the parameters of GetHeights.
+type GetHeightsParams struct {
+	// Number of items to skip
+	Offset uint64
+	// Maximum number of items returned
+	Limit uint64
+	// List the newest items first
+	Reverse bool
+}
+
+// GetHeights lists the heights of the lotteries.
+func (c *Client) GetHeights(ctx context.Context, params GetHeightsParams) (handler.HeightsResponse, error) {
+	query := url.Values{}
+	if params.Offset != 0 {
+		query.Set("offset", strconv.FormatUint(params.Offset, 10))
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.FormatUint(params.Limit, 10))
+	}
+	if params.Reverse {
+		query.Set("reverse", strconv.FormatBool(params.Reverse))
+	}
+	var resp handler.HeightsResponse
+	err := c.do(ctx, http.MethodGet, "/heights", query, false, nil, &resp)
+	return resp, err
+}
+
+// GetIdentity returns the server identity key the responses of the critical endpoints are signed with.
+func (c *Client) GetIdentity(ctx context.Context) (handler.IdentityResponse, error) {
+	var resp handler.IdentityResponse
+	err := c.do(ctx, http.MethodGet, "/identity", nil, false, nil, &resp)
+	return resp, err
+}
+
+// GetInvoiceParams contains the parameters of GetInvoice.
+type GetInvoiceParams struct {
+	// Amount bet, in sats
+	Amount uint64
+	// Place an anonymous bet
+	Anonymous bool
+	// Pay the invoice with on-chain funds through a submarine swap
+	Swap bool
+	// Compressed public key to refund a failed swap with, hex encoded
+	RefundPublicKey string
+}
+
+// GetInvoice creates the invoice of a bet, anonymous bets don't take a public key.
+func (c *Client) GetInvoice(ctx context.Context, params GetInvoiceParams) (handler.InvoiceResponse, error) {
+	query := url.Values{}
+	query.Set("amount", strconv.FormatUint(params.Amount, 10))
+	if params.Anonymous {
+		query.Set("anonymous", strconv.FormatBool(params.Anonymous))
+	}
+	if params.Swap {
+		query.Set("swap", strconv.FormatBool(params.Swap))
+	}
+	if params.RefundPublicKey != "" {
+		query.Set("refund_public_key", params.RefundPublicKey)
+	}
+	var resp handler.InvoiceResponse
+	err := c.do(ctx, http.MethodGet, "/invoice", query, true, nil, &resp)
+	return resp, err
+}
+
+// GetInvoiceSwapParams contains the parameters of GetInvoiceSwap.
+type GetInvoiceSwapParams struct {
+	// Swap ID
+	ID string
+}
+
+// GetInvoiceSwap returns the status of the submarine swap paying a bet invoice.
+func (c *Client) GetInvoiceSwap(ctx context.Context, params GetInvoiceSwapParams) (handler.InvoiceSwapResponse, error) {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	var resp handler.InvoiceSwapResponse
+	err := c.do(ctx, http.MethodGet, "/invoice/swap", query, false, nil, &resp)
+	return resp, err
+}
+
+// GetLottery returns the lottery in progress.
+func (c *Client) GetLottery(ctx context.Context) (handler.LotteryResponse, error) {
+	var resp handler.LotteryResponse
+	err := c.do(ctx, http.MethodGet, "/lottery", nil, false, nil, &resp)
+	return resp, err
+}
+
+// GetBetArchiveParams contains the parameters of GetBetArchive.
+type GetBetArchiveParams struct {
+	// Lottery height
+	Height uint64
+}
+
+// GetBetArchive returns the bets of a drawn lottery and its commitment.
+func (c *Client) GetBetArchive(ctx context.Context, params GetBetArchiveParams) (handler.BetArchiveResponse, error) {
+	query := url.Values{}
+	query.Set("height", strconv.FormatUint(params.Height, 10))
+	var resp handler.BetArchiveResponse
+	err := c.do(ctx, http.MethodGet, "/lottery/archive", query, false, nil, &resp)
+	return resp, err
+}
+
+// GetCommitmentParams contains the parameters of GetCommitment.
+type GetCommitmentParams struct {
+	// Lottery height, the one in progress by default
+	Height uint64
+}
+
+// GetCommitment returns the commitment to the server seed of a lottery.
+func (c *Client) GetCommitment(ctx context.Context, params GetCommitmentParams) (db.Commitment, error) {
+	query := url.Values{}
+	if params.Height != 0 {
+		query.Set("height", strconv.FormatUint(params.Height, 10))
+	}
+	var resp db.Commitment
+	err := c.do(ctx, http.MethodGet, "/lottery/commitment", query, false, nil, &resp)
+	return resp, err
+}
+
+// GetFairnessParams contains the parameters of GetFairness.
+type GetFairnessParams struct {
+	// Number of items to skip
+	Offset uint64
+	// Maximum number of items returned
+	Limit uint64
+	// List the newest items first
+	Reverse bool
+}
+
+// GetFairness lists the fairness reports of the lotteries drawn.
+func (c *Client) GetFairness(ctx context.Context, params GetFairnessParams) (handler.FairnessResponse, error) {
+	query := url.Values{}
+	if params.Offset != 0 {
+		query.Set("offset", strconv.FormatUint(params.Offset, 10))
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.FormatUint(params.Limit, 10))
+	}
+	if params.Reverse {
+		query.Set("reverse", strconv.FormatBool(params.Reverse))
+	}
+	var resp handler.FairnessResponse
+	err := c.do(ctx, http.MethodGet, "/lottery/fairness", query, false, nil, &resp)
+	return resp, err
+}
+
+// GetLightningAddress returns the lightning address prizes are sent to.
+func (c *Client) GetLightningAddress(ctx context.Context) (handler.GetLightningAddressResponse, error) {
+	var resp handler.GetLightningAddressResponse
+	err := c.do(ctx, http.MethodGet, "/lightning/address", nil, true, nil, &resp)
+	return resp, err
+}
+
+// SetLightningAddressParams contains the parameters of SetLightningAddress.
+type SetLightningAddressParams struct {
+	// Lightning address
+	Address string
+}
+
+// SetLightningAddress links a lightning address prizes are sent to automatically.
+func (c *Client) SetLightningAddress(ctx context.Context, params SetLightningAddressParams) (handler.SetLightningAddressResponse, error) {
+	query := url.Values{}
+	query.Set("address", params.Address)
+	var resp handler.SetLightningAddressResponse
+	err := c.do(ctx, http.MethodPost, "/lightning/address", query, true, nil, &resp)
+	return resp, err
+}
+
+// LNURLWithdrawParams contains the parameters of LNURLWithdraw.
+type LNURLWithdrawParams struct {
+	// Player public key
+	PublicKey string
+	// Signature of the public key
+	Signature string
+}
+
+// LNURLWithdraw returns the LNURL-withdraw request of the prizes.
+func (c *Client) LNURLWithdraw(ctx context.Context, params LNURLWithdrawParams) (lnurl.LNURLWithdrawResponse, error) {
+	query := url.Values{}
+	query.Set("pubkey", params.PublicKey)
+	query.Set("signature", params.Signature)
+	var resp lnurl.LNURLWithdrawResponse
+	err := c.do(ctx, http.MethodGet, "/lightning/lnurlw", query, false, nil, &resp)
+	return resp, err
+}
+
+// LNURLPay returns the LNURL-pay request that sells a ticket of a fixed amount.
+func (c *Client) LNURLPay(ctx context.Context) (lnurl.LNURLPayParams, error) {
+	var resp lnurl.LNURLPayParams
+	err := c.do(ctx, http.MethodGet, "/lightning/lnurlp", nil, false, nil, &resp)
+	return resp, err
+}
+
+// LNURLPayCallbackParams contains the parameters of LNURLPayCallback.
+type LNURLPayCallbackParams struct {
+	// Ticket amount, in millisatoshis
+	Amount uint64
+	// Public key the bet is registered under
+	Comment string
+}
+
+// LNURLPayCallback creates the invoice of a ticket, bets without a public key in the comment are anonymous.
+func (c *Client) LNURLPayCallback(ctx context.Context, params LNURLPayCallbackParams) (lnurl.LNURLPayValues, error) {
+	query := url.Values{}
+	query.Set("amount", strconv.FormatUint(params.Amount, 10))
+	if params.Comment != "" {
+		query.Set("comment", params.Comment)
+	}
+	var resp lnurl.LNURLPayValues
+	err := c.do(ctx, http.MethodGet, "/lightning/lnurlp/callback", query, false, nil, &resp)
+	return resp, err
+}
+
+// GetLimits returns the responsible gambling limits.
+func (c *Client) GetLimits(ctx context.Context) (handler.LimitsResponse, error) {
+	var resp handler.LimitsResponse
+	err := c.do(ctx, http.MethodGet, "/limits", nil, true, nil, &resp)
+	return resp, err
+}
+
+// SetLimitParams contains the parameters of SetLimit.
+type SetLimitParams struct {
+	// Limit kind, deposit or loss
+	Kind string
+	// Limit amount, in sats
+	Amount uint64
+	// Signature of the public key
+	Signature string
+}
+
+// SetLimit sets a deposit or loss limit, loosening one takes effect after the cooldown.
+func (c *Client) SetLimit(ctx context.Context, params SetLimitParams) (db.Limit, error) {
+	query := url.Values{}
+	query.Set("kind", params.Kind)
+	query.Set("amount", strconv.FormatUint(params.Amount, 10))
+	query.Set("signature", params.Signature)
+	var resp db.Limit
+	err := c.do(ctx, http.MethodPost, "/limits", query, true, nil, &resp)
+	return resp, err
+}
+
+// ExcludeParams contains the parameters of Exclude.
+type ExcludeParams struct {
+	// Length of the exclusion
+	Days uint64
+	// Signature of the public key
+	Signature string
+}
+
+// Exclude prevents the player from betting for a number of days.
+func (c *Client) Exclude(ctx context.Context, params ExcludeParams) (handler.ExclusionResponse, error) {
+	query := url.Values{}
+	query.Set("days", strconv.FormatUint(params.Days, 10))
+	query.Set("signature", params.Signature)
+	var resp handler.ExclusionResponse
+	err := c.do(ctx, http.MethodPost, "/limits/exclusion", query, true, nil, &resp)
+	return resp, err
+}
+
+// GetMaintenance returns the maintenance window.
+func (c *Client) GetMaintenance(ctx context.Context) (policy.MaintenanceStatus, error) {
+	var resp policy.MaintenanceStatus
+	err := c.do(ctx, http.MethodGet, "/maintenance", nil, false, nil, &resp)
+	return resp, err
+}
+
+// GetNotifications returns the services prize notifications are sent through.
+func (c *Client) GetNotifications(ctx context.Context) (handler.NotificationsResponse, error) {
+	var resp handler.NotificationsResponse
+	err := c.do(ctx, http.MethodGet, "/notifications", nil, true, nil, &resp)
+	return resp, err
+}
+
+// SetDigestNotificationsParams contains the parameters of SetDigestNotifications.
+type SetDigestNotificationsParams struct {
+	// Signature of the public key
+	Signature string
+}
+
+// SetDigestNotifications sends the result of every lottery the player bets on.
+func (c *Client) SetDigestNotifications(ctx context.Context, params SetDigestNotificationsParams) (handler.DigestNotificationsResponse, error) {
+	query := url.Values{}
+	query.Set("signature", params.Signature)
+	var resp handler.DigestNotificationsResponse
+	err := c.do(ctx, http.MethodPost, "/notifications/digest", query, true, nil, &resp)
+	return resp, err
+}
+
+// DeleteDigestNotificationsParams contains the parameters of DeleteDigestNotifications.
+type DeleteDigestNotificationsParams struct {
+	// Signature of the public key
+	Signature string
+}
+
+// DeleteDigestNotifications stops sending the result of every lottery the player bets on.
+func (c *Client) DeleteDigestNotifications(ctx context.Context, params DeleteDigestNotificationsParams) (handler.DigestNotificationsResponse, error) {
+	query := url.Values{}
+	query.Set("signature", params.Signature)
+	var resp handler.DigestNotificationsResponse
+	err := c.do(ctx, http.MethodDelete, "/notifications/digest", query, true, nil, &resp)
+	return resp, err
+}
+
+// SetNostrNotificationsParams contains the parameters of SetNostrNotifications.
+type SetNostrNotificationsParams struct {
+	// Nostr public key
+	Npub string
+	// Signature of the public key
+	Signature string
+}
+
+// SetNostrNotifications sends prize notifications to a nostr public key.
+func (c *Client) SetNostrNotifications(ctx context.Context, params SetNostrNotificationsParams) (handler.NostrNotificationsResponse, error) {
+	query := url.Values{}
+	query.Set("npub", params.Npub)
+	query.Set("signature", params.Signature)
+	var resp handler.NostrNotificationsResponse
+	err := c.do(ctx, http.MethodPost, "/notifications/nostr", query, true, nil, &resp)
+	return resp, err
+}
+
+// DeleteNostrNotificationsParams contains the parameters of DeleteNostrNotifications.
+type DeleteNostrNotificationsParams struct {
+	// Signature of the public key
+	Signature string
+}
+
+// DeleteNostrNotifications stops sending prize notifications through nostr.
+func (c *Client) DeleteNostrNotifications(ctx context.Context, params DeleteNostrNotificationsParams) (handler.NostrNotificationsResponse, error) {
+	query := url.Values{}
+	query.Set("signature", params.Signature)
+	var resp handler.NostrNotificationsResponse
+	err := c.do(ctx, http.MethodDelete, "/notifications/nostr", query, true, nil, &resp)
+	return resp, err
+}
+
+// GetPayoutsParams contains the parameters of GetPayouts.
+type GetPayoutsParams struct {
+	// Number of items to skip
+	Offset uint64
+	// Maximum number of items returned
+	Limit uint64
+	// List the newest items first
+	Reverse bool
+}
+
+// GetPayouts lists the withdrawals of the player and the status of their payments.
+func (c *Client) GetPayouts(ctx context.Context, params GetPayoutsParams) (handler.PayoutsResponse, error) {
+	query := url.Values{}
+	if params.Offset != 0 {
+		query.Set("offset", strconv.FormatUint(params.Offset, 10))
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.FormatUint(params.Limit, 10))
+	}
+	if params.Reverse {
+		query.Set("reverse", strconv.FormatBool(params.Reverse))
+	}
+	var resp handler.PayoutsResponse
+	err := c.do(ctx, http.MethodGet, "/payouts", query, true, nil, &resp)
+	return resp, err
+}
+
+// GetPrivacy returns how the player appears in the public winners lists.
+func (c *Client) GetPrivacy(ctx context.Context) (db.Privacy, error) {
+	var resp db.Privacy
+	err := c.do(ctx, http.MethodGet, "/privacy", nil, true, nil, &resp)
+	return resp, err
+}
+
+// SetPrivacyParams contains the parameters of SetPrivacy.
+type SetPrivacyParams struct {
+	// full, truncated, alias or hidden
+	Display string
+	// Name displayed with the alias mode
+	Alias string
+	// Signature of the public key
+	Signature string
+}
+
+// SetPrivacy changes how the player appears in the public winners lists.
+func (c *Client) SetPrivacy(ctx context.Context, params SetPrivacyParams) (db.Privacy, error) {
+	query := url.Values{}
+	query.Set("display", params.Display)
+	if params.Alias != "" {
+		query.Set("alias", params.Alias)
+	}
+	query.Set("signature", params.Signature)
+	var resp db.Privacy
+	err := c.do(ctx, http.MethodPost, "/privacy", query, true, nil, &resp)
+	return resp, err
+}
+
+// GetPrizes returns the prizes available to withdraw.
+func (c *Client) GetPrizes(ctx context.Context) (handler.GetPrizesResponse, error) {
+	var resp handler.GetPrizesResponse
+	err := c.do(ctx, http.MethodGet, "/prizes", nil, true, nil, &resp)
+	return resp, err
+}
+
+// GetReceiptBundleParams contains the parameters of GetReceiptBundle.
+type GetReceiptBundleParams struct {
+	// Payment hash of the bet, the prizes of the round are returned if empty
+	PaymentHash string
+	// Lottery height of the prizes, required without a payment hash
+	Height uint64
+	// Include the receipt rendered as a PDF document
+	PDF bool
+	// Signature of the public key
+	Signature string
+}
+
+// GetReceiptBundle returns a bet or win receipt signed by the server, along with the instructions to verify it.
+func (c *Client) GetReceiptBundle(ctx context.Context, params GetReceiptBundleParams) (handler.ReceiptBundleResponse, error) {
+	query := url.Values{}
+	if params.PaymentHash != "" {
+		query.Set("payment_hash", params.PaymentHash)
+	}
+	if params.Height != 0 {
+		query.Set("height", strconv.FormatUint(params.Height, 10))
+	}
+	if params.PDF {
+		query.Set("pdf", strconv.FormatBool(params.PDF))
+	}
+	query.Set("signature", params.Signature)
+	var resp handler.ReceiptBundleResponse
+	err := c.do(ctx, http.MethodGet, "/receipts/bundle", query, true, nil, &resp)
+	return resp, err
+}
+
+// VerifyReceipt verifies that a bet receipt was signed by the server.
+func (c *Client) VerifyReceipt(ctx context.Context, body db.Receipt) (handler.VerifyReceiptResponse, error) {
+	var resp handler.VerifyReceiptResponse
+	err := c.do(ctx, http.MethodPost, "/receipts/verify", nil, false, body, &resp)
+	return resp, err
+}
+
+// GetReserves returns the proof of reserves, the prize liabilities and the channel balances covering them.
+func (c *Client) GetReserves(ctx context.Context) (handler.ReservesResponse, error) {
+	var resp handler.ReservesResponse
+	err := c.do(ctx, http.MethodGet, "/reserves", nil, false, nil, &resp)
+	return resp, err
+}
+
+// GetStats returns the all-time statistics.
+func (c *Client) GetStats(ctx context.Context) (handler.StatsResponse, error) {
+	var resp handler.StatsResponse
+	err := c.do(ctx, http.MethodGet, "/stats", nil, false, nil, &resp)
+	return resp, err
+}
+
+// GetRoundStatsParams contains the parameters of GetRoundStats.
+type GetRoundStatsParams struct {
+	// Number of items to skip
+	Offset uint64
+	// Maximum number of items returned
+	Limit uint64
+	// List the newest items first
+	Reverse bool
+}
+
+// GetRoundStats lists the statistics of each lottery.
+func (c *Client) GetRoundStats(ctx context.Context, params GetRoundStatsParams) (handler.RoundsResponse, error) {
+	query := url.Values{}
+	if params.Offset != 0 {
+		query.Set("offset", strconv.FormatUint(params.Offset, 10))
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.FormatUint(params.Limit, 10))
+	}
+	if params.Reverse {
+		query.Set("reverse", strconv.FormatBool(params.Reverse))
+	}
+	var resp handler.RoundsResponse
+	err := c.do(ctx, http.MethodGet, "/stats/rounds", query, false, nil, &resp)
+	return resp, err
+}
+
+// GetStreaksParams contains the parameters of GetStreaks.
+type GetStreaksParams struct {
+	// Maximum number of items returned
+	Limit uint64
+}
+
+// GetStreaks lists the longest winning streaks.
+func (c *Client) GetStreaks(ctx context.Context, params GetStreaksParams) (handler.StreaksResponse, error) {
+	query := url.Values{}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.FormatUint(params.Limit, 10))
+	}
+	var resp handler.StreaksResponse
+	err := c.do(ctx, http.MethodGet, "/stats/streaks", query, false, nil, &resp)
+	return resp, err
+}
+
+// GetBiggestWinsParams contains the parameters of GetBiggestWins.
+type GetBiggestWinsParams struct {
+	// Maximum number of items returned
+	Limit uint64
+}
+
+// GetBiggestWins lists the biggest prizes won.
+func (c *Client) GetBiggestWins(ctx context.Context, params GetBiggestWinsParams) (handler.WinsResponse, error) {
+	query := url.Values{}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.FormatUint(params.Limit, 10))
+	}
+	var resp handler.WinsResponse
+	err := c.do(ctx, http.MethodGet, "/stats/wins", query, false, nil, &resp)
+	return resp, err
+}
+
+// GetTicketParams contains the parameters of GetTicket.
+type GetTicketParams struct {
+	// Lottery height
+	Height uint64
+	// Ticket number
+	Ticket uint64
+	// Lottery pool, empty for the unnamed one
+	Pool string
+}
+
+// GetTicket returns the owner of a ticket.
+func (c *Client) GetTicket(ctx context.Context, params GetTicketParams) (handler.TicketResponse, error) {
+	query := url.Values{}
+	query.Set("height", strconv.FormatUint(params.Height, 10))
+	query.Set("ticket", strconv.FormatUint(params.Ticket, 10))
+	if params.Pool != "" {
+		query.Set("pool", params.Pool)
+	}
+	var resp handler.TicketResponse
+	err := c.do(ctx, http.MethodGet, "/tickets", query, false, nil, &resp)
+	return resp, err
+}
+
+// GetWinnersParams contains the parameters of GetWinners.
+type GetWinnersParams struct {
+	// Lottery height
+	Height uint64
+}
+
+// GetWinners lists the winners of a lottery.
+func (c *Client) GetWinners(ctx context.Context, params GetWinnersParams) (handler.WinnersResponse, error) {
+	query := url.Values{}
+	query.Set("height", strconv.FormatUint(params.Height, 10))
+	var resp handler.WinnersResponse
+	err := c.do(ctx, http.MethodGet, "/winners", query, false, nil, &resp)
+	return resp, err
+}
+
+// SearchWinnersParams contains the parameters of SearchWinners.
+type SearchWinnersParams struct {
+	// Winner public key, only if it's displayed in full
+	PublicKey string
+	// First lottery height
+	From uint64
+	// Last lottery height
+	To uint64
+	// Claim status: claimed, unclaimed or expired
+	Status string
+	// ID of the winner to start after
+	Offset uint64
+	// Maximum number of items returned
+	Limit uint64
+	// List the newest items first
+	Reverse bool
+}
+
+// SearchWinners searches the winners of every lottery.
+func (c *Client) SearchWinners(ctx context.Context, params SearchWinnersParams) (handler.WinnersSearchResponse, error) {
+	query := url.Values{}
+	if params.PublicKey != "" {
+		query.Set("pubkey", params.PublicKey)
+	}
+	if params.From != 0 {
+		query.Set("from", strconv.FormatUint(params.From, 10))
+	}
+	if params.To != 0 {
+		query.Set("to", strconv.FormatUint(params.To, 10))
+	}
+	if params.Status != "" {
+		query.Set("status", params.Status)
+	}
+	if params.Offset != 0 {
+		query.Set("offset", strconv.FormatUint(params.Offset, 10))
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.FormatUint(params.Limit, 10))
+	}
+	if params.Reverse {
+		query.Set("reverse", strconv.FormatBool(params.Reverse))
+	}
+	var resp handler.WinnersSearchResponse
+	err := c.do(ctx, http.MethodGet, "/winners/search", query, false, nil, &resp)
+	return resp, err
+}
+
+// WithdrawParams contains the parameters of Withdraw.
+type WithdrawParams struct {
+	// Player public key
+	PublicKey string
+	// Signature of the public key
+	K1 string
+	// Invoice to pay, repeat it to split the withdrawal. A single zero-amount invoice is paid with all the prizes left after the fee
+	PaymentRequests []string
+	// Maximum routing fee of each invoice, in sats
+	Fees []uint64
+}
+
+// Withdraw withdraws the prizes paying one or more invoices.
+func (c *Client) Withdraw(ctx context.Context, params WithdrawParams) (handler.WithdrawResponse, error) {
+	query := url.Values{}
+	query.Set("pubkey", params.PublicKey)
+	query.Set("k1", params.K1)
+	for _, v := range params.PaymentRequests {
+		query.Add("pr", v)
+	}
+	for _, v := range params.Fees {
+		query.Add("fee", strconv.FormatUint(v, 10))
+	}
+	var resp handler.WithdrawResponse
+	err := c.do(ctx, http.MethodPost, "/withdraw", query, false, nil, &resp)
+	return resp, err
+}
@@ -0,0 +1,378 @@
+// Command btryctl runs the common operator tasks against the BTRY admin API: dry-run draws,
+// pending payouts, accounting exports, prize pool projections, maintenance windows and API keys.
+//
+// It authenticates with the session token returned by the operator login, taken from the -token
+// flag or the BTRY_ADMIN_TOKEN environment variable.
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aftermath2/BTRY/client"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultURL = "http://127.0.0.1:7070"
+	// exportPageSize is the number of fee distributions requested at a time
+	exportPageSize = 500
+	timeout        = time.Minute
+)
+
+// command is a btryctl subcommand, it receives the arguments that follow its name.
+type command struct {
+	run   func(ctx context.Context, admin *client.Admin, args []string, out io.Writer) error
+	usage string
+}
+
+var commands = map[string]command{
+	"replay": {
+		run:   replay,
+		usage: "draw a past lottery pool again with another distribution or fee, nothing is stored",
+	},
+	"payouts": {
+		run:   payouts,
+		usage: "list the payouts awaiting approval and the ones deferred by high routing fees",
+	},
+	"export": {
+		run:   export,
+		usage: "export the fee distributions of every lottery in CSV or JSON",
+	},
+	"growth": {
+		run:   growth,
+		usage: "project the prize pool of a lottery at its draw, the latest one opened by default",
+	},
+	"maintenance": {
+		run:   maintenance,
+		usage: "show, start or end the maintenance window (status|start|end)",
+	},
+	"keys": {
+		run:   keys,
+		usage: "list, create, rotate or revoke the integrators API keys (list|create|rotate|revoke)",
+	},
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Getenv, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "btryctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, getenv func(string) string, out io.Writer) error {
+	flags := flag.NewFlagSet("btryctl", flag.ContinueOnError)
+	flags.SetOutput(out)
+	baseURL := flags.String("url", envOr(getenv, "BTRY_URL", defaultURL), "BTRY server URL")
+	token := flags.String("token", getenv("BTRY_ADMIN_TOKEN"), "operator session token")
+	flags.Usage = func() {
+		fmt.Fprintln(out, "Usage: btryctl [-url URL] [-token TOKEN] <command> [arguments]")
+		fmt.Fprintln(out, "\nCommands:")
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(out, "  %-12s %s\n", name, commands[name].usage)
+		}
+		fmt.Fprintln(out, "\nFlags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no command specified")
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		flags.Usage()
+		return errors.Errorf("unknown command %q", flags.Arg(0))
+	}
+
+	if *token == "" {
+		return errors.New("the operator session token is required, use -token or BTRY_ADMIN_TOKEN")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	admin := client.NewAdmin(*baseURL, *token, nil)
+	return cmd.run(ctx, admin, flags.Args()[1:], out)
+}
+
+func replay(ctx context.Context, admin *client.Admin, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.SetOutput(out)
+	height := flags.Uint("height", 0, "height of the lottery")
+	pool := flags.String("pool", "", "name of the pool, the unnamed one by default")
+	distribution := flags.String("distribution", "", "comma separated prize percentages, e.g. 70,20")
+	fee := flags.Float64("fee", -1, "fee percentage, scales the pool's configured distribution")
+	rounding := flags.String("rounding", "", "rounding policy, the configured one by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *height == 0 {
+		return errors.New("-height is required")
+	}
+
+	params := client.ReplayParams{
+		Height:   uint32(*height),
+		Pool:     *pool,
+		Rounding: *rounding,
+		Fee:      *fee,
+	}
+	switch {
+	case *distribution != "" && *fee >= 0:
+		return errors.New("either -distribution or -fee must be provided, not both")
+	case *distribution != "":
+		for _, percentage := range strings.Split(*distribution, ",") {
+			p, err := strconv.ParseFloat(strings.TrimSpace(percentage), 64)
+			if err != nil {
+				return errors.Wrap(err, "invalid distribution")
+			}
+			params.Distribution = append(params.Distribution, p)
+		}
+	case *fee < 0:
+		return errors.New("-distribution or -fee is required")
+	}
+
+	resp, err := admin.Replay(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(out, resp)
+}
+
+func payouts(ctx context.Context, admin *client.Admin, _ []string, out io.Writer) error {
+	approvals, err := admin.ListApprovals(ctx)
+	if err != nil {
+		return errors.Wrap(err, "listing approvals")
+	}
+
+	scheduled, err := admin.ListScheduledPayouts(ctx)
+	if err != nil {
+		return errors.Wrap(err, "listing scheduled payouts")
+	}
+
+	return printJSON(out, map[string]any{
+		"approvals": approvals,
+		"scheduled": scheduled,
+	})
+}
+
+func export(ctx context.Context, admin *client.Admin, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(out)
+	format := flags.String("format", "csv", "output format, csv or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *format != "csv" && *format != "json" {
+		return errors.Errorf("unknown format %q", *format)
+	}
+
+	distributions := make([]any, 0)
+	w := csv.NewWriter(out)
+	if *format == "csv" {
+		header := []string{"lottery_height", "destination", "address", "amount", "paid_at", "preimage"}
+		if err := w.Write(header); err != nil {
+			return errors.Wrap(err, "writing header")
+		}
+	}
+
+	for offset := uint64(0); ; offset += exportPageSize {
+		page, err := admin.ListFeeDistributions(ctx, offset, exportPageSize)
+		if err != nil {
+			return errors.Wrap(err, "listing fee distributions")
+		}
+
+		for _, d := range page {
+			if *format == "json" {
+				distributions = append(distributions, d)
+				continue
+			}
+
+			record := []string{
+				strconv.FormatUint(uint64(d.LotteryHeight), 10),
+				d.Destination,
+				d.Address,
+				strconv.FormatUint(d.Amount, 10),
+				strconv.FormatInt(d.PaidAt, 10),
+				d.Preimage,
+			}
+			if err := w.Write(record); err != nil {
+				return errors.Wrap(err, "writing record")
+			}
+		}
+
+		if len(page) < exportPageSize {
+			break
+		}
+	}
+
+	if *format == "json" {
+		return printJSON(out, distributions)
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func growth(ctx context.Context, admin *client.Admin, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("growth", flag.ContinueOnError)
+	flags.SetOutput(out)
+	height := flags.Uint("height", 0, "height of the lottery, the latest one opened by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	resp, err := admin.GetGrowth(ctx, uint32(*height))
+	if err != nil {
+		return err
+	}
+
+	return printJSON(out, resp)
+}
+
+func maintenance(ctx context.Context, admin *client.Admin, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("subcommand required: status, start or end")
+	}
+
+	switch args[0] {
+	case "status":
+		status, err := admin.GetMaintenance(ctx)
+		if err != nil {
+			return err
+		}
+		return printJSON(out, status)
+
+	case "start":
+		flags := flag.NewFlagSet("maintenance start", flag.ContinueOnError)
+		flags.SetOutput(out)
+		in := flags.Duration("in", 0, "time until the window starts, immediately by default")
+		duration := flags.Duration("for", 0, "duration of the window")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		if *duration <= 0 {
+			return errors.New("-for is required")
+		}
+
+		var from int64
+		start := time.Now()
+		if *in > 0 {
+			start = start.Add(*in)
+			from = start.Unix()
+		}
+
+		status, err := admin.ScheduleMaintenance(ctx, from, start.Add(*duration).Unix())
+		if err != nil {
+			return err
+		}
+		return printJSON(out, status)
+
+	case "end":
+		status, err := admin.EndMaintenance(ctx)
+		if err != nil {
+			return err
+		}
+		return printJSON(out, status)
+
+	default:
+		return errors.Errorf("unknown maintenance subcommand %q", args[0])
+	}
+}
+
+func keys(ctx context.Context, admin *client.Admin, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("subcommand required: list, create, rotate or revoke")
+	}
+
+	switch args[0] {
+	case "list":
+		apiKeys, err := admin.ListAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+		return printJSON(out, apiKeys)
+
+	case "create":
+		flags := flag.NewFlagSet("keys create", flag.ContinueOnError)
+		flags.SetOutput(out)
+		name := flags.String("name", "", "name of the integrator")
+		scopes := flags.String("scopes", "", "comma separated scopes granted")
+		rateLimit := flags.Uint64("rate-limit", 0, "requests allowed per interval, the default by default")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		if *name == "" {
+			return errors.New("-name is required")
+		}
+
+		resp, err := admin.CreateAPIKey(ctx, *name, *scopes, *rateLimit)
+		if err != nil {
+			return err
+		}
+		return printJSON(out, resp)
+
+	case "rotate", "revoke":
+		if len(args) != 2 {
+			return errors.Errorf("usage: btryctl keys %s <id>", args[0])
+		}
+		id, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "invalid key ID")
+		}
+
+		if args[0] == "revoke" {
+			if err := admin.RevokeAPIKey(ctx, id); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "API key %d revoked\n", id)
+			return nil
+		}
+
+		resp, err := admin.RotateAPIKey(ctx, id)
+		if err != nil {
+			return err
+		}
+		return printJSON(out, resp)
+
+	default:
+		return errors.Errorf("unknown keys subcommand %q", args[0])
+	}
+}
+
+func printJSON(out io.Writer, v any) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func envOr(getenv func(string) string, key, fallback string) string {
+	if v := getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/admin/fees", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		// The first page is full, the second one is the last
+		offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+		assert.NoError(t, err)
+		size := exportPageSize
+		if offset > 0 {
+			size = 1
+		}
+
+		distributions := make([]db.FeeDistribution, 0, size)
+		for i := 0; i < size; i++ {
+			distributions = append(distributions, db.FeeDistribution{
+				LotteryHeight: 144,
+				Destination:   "dev",
+				Address:       "dev@btry.example",
+				Amount:        10,
+			})
+		}
+		distributions[0].PaidAt = 1_700_000_000
+		distributions[0].Preimage = "preimage"
+		json.NewEncoder(w).Encode(distributions)
+	}))
+	defer srv.Close()
+
+	out := &bytes.Buffer{}
+	err := run([]string{"-url", srv.URL, "-token", "token", "export"}, noEnv, out)
+	assert.NoError(t, err)
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	assert.Len(t, lines, exportPageSize+2)
+	assert.Equal(t, "lottery_height,destination,address,amount,paid_at,preimage", string(lines[0]))
+	assert.Equal(t, "144,dev,dev@btry.example,10,1700000000,preimage", string(lines[1]))
+}
+
+func TestRunErrors(t *testing.T) {
+	cases := []struct {
+		desc string
+		args []string
+	}{
+		{desc: "No command", args: []string{"-token", "token"}},
+		{desc: "Unknown command", args: []string{"-token", "token", "draw"}},
+		{desc: "No token", args: []string{"payouts"}},
+		{desc: "Replay without distribution", args: []string{"-token", "token", "replay", "-height", "144"}},
+		{
+			desc: "Replay with distribution and fee",
+			args: []string{"-token", "token", "replay", "-height", "144", "-distribution", "70", "-fee", "5"},
+		},
+		{desc: "Maintenance without duration", args: []string{"-token", "token", "maintenance", "start"}},
+		{desc: "Revoke without ID", args: []string{"-token", "token", "keys", "revoke"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := run(tc.args, noEnv, &bytes.Buffer{})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func noEnv(string) string {
+	return ""
+}
@@ -2,13 +2,20 @@
 package config
 
 import (
+	"crypto/ed25519"
 	"crypto/tls"
+	"encoding/hex"
+	"math"
+	"net/netip"
 	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"time"
 
+	"github.com/aftermath2/BTRY/lottery/engine"
+
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/credentials"
 	"gopkg.in/macaroon.v2"
@@ -17,37 +24,371 @@ import (
 
 // Config represents the configuration for the BTRY application.
 type Config struct {
+	Alerts    Alerts    `yaml:"alerts"`
+	Archive   Archive   `yaml:"archive"`
+	Audit     Audit     `yaml:"audit"`
+	Chaos     Chaos     `yaml:"chaos"`
 	Notifier  Notifier  `yaml:"notifier"`
 	DB        DB        `yaml:"db"`
+	Jobs      Jobs      `yaml:"jobs"`
+	Leader    Leader    `yaml:"leader"`
 	Lottery   Lottery   `yaml:"lottery"`
 	Tor       Tor       `yaml:"tor"`
 	Lightning Lightning `yaml:"lightning"`
+	Liquidity Liquidity `yaml:"liquidity"`
+	Rates     Rates     `yaml:"rates"`
+	Reserves  Reserves  `yaml:"reserves"`
+	Watchdog  Watchdog  `yaml:"watchdog"`
+	Webhooks  Webhooks  `yaml:"webhooks"`
+	Reload    Reload    `yaml:"reload"`
 	API       API       `yaml:"api"`
 	Server    Server    `yaml:"server"`
 }
 
+// Admin API configuration. Operators authenticate with passkeys (WebAuthn), the API is disabled if
+// the relying party ID is empty.
+//
+// The setup token is only used to register the first operator, who is granted the owner role.
+type Admin struct {
+	RPID            string        `yaml:"rp_id"`
+	Origin          string        `yaml:"origin"`
+	SetupToken      string        `yaml:"setup_token"`
+	SessionDuration time.Duration `yaml:"session_duration"`
+}
+
 // API configuration.
 type API struct {
-	Logger      Logger      `yaml:"logger"`
-	SSE         SSE         `yaml:"sse"`
-	RateLimiter RateLimiter `yaml:"rate_limiter"`
+	Admin        Admin        `yaml:"admin"`
+	Logger       Logger       `yaml:"logger"`
+	Cache        Cache        `yaml:"cache"`
+	SSE          SSE          `yaml:"sse"`
+	GraphQL      GraphQL      `yaml:"graphql"`
+	Live         Live         `yaml:"live"`
+	Jurisdiction Jurisdiction `yaml:"jurisdiction"`
+	Maintenance  Maintenance  `yaml:"maintenance"`
+	RateLimiter  RateLimiter  `yaml:"rate_limiter"`
+	ClaimWidget  ClaimWidget  `yaml:"claim_widget"`
+	Swaps        Swaps        `yaml:"swaps"`
+	Identity     Identity     `yaml:"identity"`
+}
+
+// Identity configures the server identity key, the hex encoded seed of an ed25519 key that clients
+// and mirrors pin to detect responses tampered with by middleboxes or compromised CDNs. When
+// SignResponses is true, the responses of the critical endpoints carry a detached signature of
+// their body.
+type Identity struct {
+	PrivateKey    string `yaml:"private_key"`
+	SignResponses bool   `yaml:"sign_responses"`
+}
+
+// ClaimWidget configures the short-lived tokens winners hand to third-party sites so they can
+// embed the claim flow. Secret is the hex encoded key the tokens are signed with, at least 32
+// bytes, the widget is disabled when it's empty. TTL defaults to 10 minutes and can't exceed an
+// hour.
+type ClaimWidget struct {
+	Secret string        `yaml:"secret"`
+	TTL    time.Duration `yaml:"ttl"`
+}
+
+// Swaps configures the submarine swaps that let players holding on-chain funds only pay the bet
+// invoices. Provider is the swap service, only "boltz" is supported, and URL its API, the public
+// one by default. Swaps are disabled when the provider is empty.
+type Swaps struct {
+	Provider string `yaml:"provider"`
+	URL      string `yaml:"url"`
+}
+
+// Archive configures the periodic export of the draw history to Nostr long-form events and IPFS,
+// so the record of the draws survives the server. The exports are signed with the audit log key,
+// which must be enabled. The destinations without relays or URL are skipped. Interval defaults to
+// 24 hours.
+type Archive struct {
+	Logger   Logger        `yaml:"logger"`
+	Nostr    Nostr         `yaml:"nostr"`
+	IPFS     IPFS          `yaml:"ipfs"`
+	Interval time.Duration `yaml:"interval"`
+	Enabled  bool          `yaml:"enabled"`
+}
+
+// IPFS node configuration, URL is the address of its RPC API, like http://127.0.0.1:5001.
+type IPFS struct {
+	URL string `yaml:"url"`
+}
+
+// Alerts configuration. Every Interval, the rules are evaluated and the operators are alerted
+// through the channels configured when one starts or stops firing.
+type Alerts struct {
+	Rules    []AlertRule   `yaml:"rules"`
+	Channels AlertChannels `yaml:"channels"`
+	Logger   Logger        `yaml:"logger"`
+	Interval time.Duration `yaml:"interval"`
+	Enabled  bool          `yaml:"enabled"`
+}
+
+// AlertRule fires when the value of a metric is above Threshold, or below it if Below is set.
+//
+// Counter metrics take into account the events that happened in the last Window, which defaults to
+// a minute. Label filters the errors metric by the label of the loggers that reported them, and
+// the prize liabilities metric by aging bucket.
+type AlertRule struct {
+	Name      string        `yaml:"name"`
+	Metric    string        `yaml:"metric"`
+	Label     string        `yaml:"label"`
+	Threshold float64       `yaml:"threshold"`
+	Window    time.Duration `yaml:"window"`
+	Below     bool          `yaml:"below"`
+}
+
+// AlertChannels are the destinations of the operator alerts. Webhook receives a JSON object with
+// the alert and PagerDuty follows the PagerDuty Events API v2, using RoutingKey as the integration
+// key.
+type AlertChannels struct {
+	Webhook        string `yaml:"webhook"`
+	PagerDuty      string `yaml:"pagerduty"`
+	RoutingKey     string `yaml:"routing_key"`
+	TelegramChatID int64  `yaml:"telegram_chat_id"`
+}
+
+// Audit log configuration.
+type Audit struct {
+	PrivateKey string `yaml:"private_key"`
+	Logger     Logger `yaml:"logger"`
+	Enabled    bool   `yaml:"enabled"`
+}
+
+// Chaos configures the failures injected to verify the draws and payouts recover from them. Each
+// probability, between 0 and 1, applies to every event of its kind: block events are dropped, the
+// database writes and the lightning calls fail with transient errors and all of them are delayed
+// up to MaxDelay. A zero seed makes the failures different on every run.
+//
+// It's only honored by the builds with the chaos tag, production builds ignore it. It can be
+// reloaded.
+type Chaos struct {
+	Seed           uint64        `yaml:"seed"`
+	MaxDelay       time.Duration `yaml:"max_delay"`
+	Delay          float64       `yaml:"delay"`
+	DropBlock      float64       `yaml:"drop_block"`
+	FailWrite      float64       `yaml:"fail_write"`
+	LightningError float64       `yaml:"lightning_error"`
+	Enabled        bool          `yaml:"enabled"`
 }
 
 // DB database configuration.
+//
+// WAL enables the write-ahead log journal mode, which lets readers and the writer work
+// concurrently. MmapSize is the maximum number of bytes of the database file mapped into memory,
+// zero disables memory-mapped I/O.
 type DB struct {
-	Path            string        `yaml:"path"`
-	Logger          Logger        `yaml:"logger"`
-	MaxIdleConns    int           `yaml:"max_idle_conns"`
-	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	Path            string          `yaml:"path"`
+	Logger          Logger          `yaml:"logger"`
+	Snapshot        Snapshot        `yaml:"snapshot"`
+	Notifications   NotificationsDB `yaml:"notifications"`
+	Retention       Retention       `yaml:"retention"`
+	MaxIdleConns    int             `yaml:"max_idle_conns"`
+	ConnMaxIdleTime time.Duration   `yaml:"conn_max_idle_time"`
+	BusyTimeout     time.Duration   `yaml:"busy_timeout"`
+	MmapSize        int64           `yaml:"mmap_size"`
+	WAL             bool            `yaml:"wal"`
+}
+
+// Snapshot configures the read-only copy of the database used to serve the public read load. It
+// is refreshed every Interval, a zero value disables it.
+type Snapshot struct {
+	Path     string        `yaml:"path"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// Retention prunes the records older than Months once a day: the bet archives, winners and expired
+// prizes of the lotteries drawn before then and the webhook deliveries. A zero value keeps them
+// forever. DryRun only logs the number of records that would be pruned and, if ExportDir is set,
+// the records are written there as JSON lines before they are deleted.
+type Retention struct {
+	ExportDir string `yaml:"export_dir"`
+	Months    uint32 `yaml:"months"`
+	DryRun    bool   `yaml:"dry_run"`
+}
+
+// Blocks returns the approximate number of blocks mined during the retention period, counting 30
+// days per month.
+func (r Retention) Blocks() uint32 {
+	return r.Months * 30 * BlocksPerDay
+}
+
+// NotificationsDB configures where the chat IDs and nostr keys linked to the public keys are
+// stored. They are kept in the main database unless Path points to another SQLite database, and
+// encrypted if a provider is configured.
+type NotificationsDB struct {
+	Path       string     `yaml:"path"`
+	Encryption Encryption `yaml:"encryption"`
+}
+
+// Encryption at rest configuration. The "file" provider reads AES-256 keys from KeyFile, one hex
+// encoded key per line, and encrypts with the last one. The "vault" provider uses a HashiCorp Vault
+// transit secrets engine. An empty provider disables the encryption.
+type Encryption struct {
+	Provider string `yaml:"provider"`
+	KeyFile  string `yaml:"key_file"`
+	Vault    Vault  `yaml:"vault"`
+}
+
+// Vault transit secrets engine configuration.
+type Vault struct {
+	Address string `yaml:"address"`
+	Token   string `yaml:"token"`
+	// Mount is the path the transit engine is mounted at, "transit" by default
+	Mount string `yaml:"mount"`
+	Key   string `yaml:"key"`
 }
 
 // Lightning configuration.
 type Lightning struct {
+	// Network is the bitcoin network the node runs on, it defaults to mainnet
+	Network      string `yaml:"network"`
 	RPCAddress   string `yaml:"rpc_address"`
 	TLSCertPath  string `yaml:"tls_cert_path"`
 	MacaroonPath string `yaml:"macaroon_path"`
 	Logger       Logger `yaml:"logger"`
 	MaxFeePPM    int64  `yaml:"max_fee_ppm"`
+	// AMPMinAmount is the amount from which bets are paid with AMP invoices, which can be split in
+	// multiple payments through different routes. 0 disables them
+	AMPMinAmount uint64 `yaml:"amp_min_amount"`
+	// Tor routes the connection to the node through the Tor proxy, required for onion addresses
+	Tor bool `yaml:"tor"`
+}
+
+// Bitcoin networks the lightning node may run on.
+const (
+	NetworkMainnet = "mainnet"
+	NetworkTestnet = "testnet"
+	NetworkSignet  = "signet"
+	NetworkRegtest = "regtest"
+)
+
+// Liquidity manager configuration. It compares the node balance against the prize liabilities,
+// which are the prizes won that haven't been withdrawn nor expired yet.
+//
+// Outbound liquidity should cover the liabilities times OutboundRatio, and inbound liquidity
+// should be at least the liabilities times InboundRatio to keep receiving bets. Channels whose
+// local or remote balance is below ChannelRatio of their capacity are candidates for rebalancing.
+type Liquidity struct {
+	Actions       []string         `yaml:"actions"`
+	Loop          Loop             `yaml:"loop"`
+	Inbound       LiquidityInbound `yaml:"inbound"`
+	Logger        Logger           `yaml:"logger"`
+	Budget        LiquidityBudget  `yaml:"budget"`
+	Interval      time.Duration    `yaml:"interval"`
+	OutboundRatio float64          `yaml:"outbound_ratio"`
+	InboundRatio  float64          `yaml:"inbound_ratio"`
+	ChannelRatio  float64          `yaml:"channel_ratio"`
+	MaxFeePPM     uint64           `yaml:"max_fee_ppm"`
+	AlertChatID   int64            `yaml:"alert_chat_id"`
+	Enabled       bool             `yaml:"enabled"`
+	DryRun        bool             `yaml:"dry_run"`
+}
+
+// Jobs queue configuration. The side effects of the draws are executed asynchronously by Workers
+// goroutines, jobs that fail are retried up to MaxAttempts times, waiting Backoff times two to the
+// power of the attempts made in between. Zero values are replaced by the defaults.
+type Jobs struct {
+	Logger       Logger        `yaml:"logger"`
+	Workers      int           `yaml:"workers"`
+	MaxAttempts  uint32        `yaml:"max_attempts"`
+	Backoff      time.Duration `yaml:"backoff"`
+	Timeout      time.Duration `yaml:"timeout"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+// Leader configures the election of the instance that draws the lotteries and sends the payouts
+// when several of them share the database and the lightning node. The leader renews a lease stored
+// in the database every third of its duration, the followers serve the read requests and take over
+// once it expires. ID defaults to the host name.
+type Leader struct {
+	Logger  Logger        `yaml:"logger"`
+	ID      string        `yaml:"id"`
+	Lease   time.Duration `yaml:"lease"`
+	Enabled bool          `yaml:"enabled"`
+}
+
+// Webhooks configuration. Events are delivered to the URLs integrators subscribed with a POST
+// request that must be answered within Timeout, failed deliveries are retried by the jobs queue.
+// Tor routes the requests through the Tor proxy, which is required to reach onion addresses.
+//
+// URLs resolving to loopback, private or link-local addresses are rejected, both when subscribing
+// and when connecting, unless AllowPrivate is set (e.g. receivers in the same network).
+type Webhooks struct {
+	Logger       Logger        `yaml:"logger"`
+	Timeout      time.Duration `yaml:"timeout"`
+	Tor          bool          `yaml:"tor"`
+	AllowPrivate bool          `yaml:"allow_private"`
+}
+
+// Watchdog configuration. It cross-checks the node block feed against a secondary chain source,
+// either a bitcoind RPC server or an Esplora API (e.g. mempool.space).
+//
+// Operators are alerted when the heights diverge by more than MaxDivergence blocks or the feed
+// stalls, no block was received for StallTimeout while the secondary source advanced. If
+// PauseDraws is enabled, draws are postponed until the feed recovers and the target block hash
+// matches the one of the secondary source.
+type Watchdog struct {
+	Source        string        `yaml:"source"`
+	URL           string        `yaml:"url"`
+	RPCUser       string        `yaml:"rpc_user"`
+	RPCPassword   string        `yaml:"rpc_password"`
+	Logger        Logger        `yaml:"logger"`
+	Interval      time.Duration `yaml:"interval"`
+	StallTimeout  time.Duration `yaml:"stall_timeout"`
+	MaxDivergence uint32        `yaml:"max_divergence"`
+	AlertChatID   int64         `yaml:"alert_chat_id"`
+	PauseDraws    bool          `yaml:"pause_draws"`
+	Enabled       bool          `yaml:"enabled"`
+}
+
+// Rates configuration. The price of bitcoin in Currencies (ISO 4217 codes) is fetched every Interval,
+// five minutes by default, from the first of the Providers that responds, and used to show the
+// approximate fiat value of the amounts in the API responses.
+//
+// Prices older than MaxAge, three intervals by default, are not shown.
+type Rates struct {
+	Providers  []RateProvider `yaml:"providers"`
+	Currencies []string       `yaml:"currencies"`
+	Logger     Logger         `yaml:"logger"`
+	Interval   time.Duration  `yaml:"interval"`
+	MaxAge     time.Duration  `yaml:"max_age"`
+	Enabled    bool           `yaml:"enabled"`
+}
+
+// Reserves configures the proof of reserves, a statement of the prize liabilities and the channel
+// balances covering them, refreshed on every block. It's signed with the audit log key, which must be
+// enabled, and the node key.
+type Reserves struct {
+	Logger  Logger `yaml:"logger"`
+	Enabled bool   `yaml:"enabled"`
+}
+
+// RateProvider is an exchange rates API, either coingecko or mempool. URL overrides the provider's
+// public instance.
+type RateProvider struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+}
+
+// LiquidityBudget limits the fees paid and the amount moved by the liquidity actions in a period.
+// A zero MaxAmount means there's no limit on the amount moved.
+type LiquidityBudget struct {
+	Period    time.Duration `yaml:"period"`
+	MaxFees   uint64        `yaml:"max_fees"`
+	MaxAmount uint64        `yaml:"max_amount"`
+}
+
+// LiquidityInbound configures the analysis of the bets the node couldn't receive. The bet invoices
+// that expired in the last Window are compared against the channels inbound liquidity, and the
+// inbound liquidity the next rounds need is forecast with the biggest prize pool of the last Rounds.
+// A zero Window disables the analysis. Channels are ordered from the LSP at most once per Cooldown.
+type LiquidityInbound struct {
+	LSP      LSP           `yaml:"lsp"`
+	Window   time.Duration `yaml:"window"`
+	Cooldown time.Duration `yaml:"cooldown"`
+	Rounds   uint64        `yaml:"rounds"`
 }
 
 // Logger configuration.
@@ -57,10 +398,352 @@ type Logger struct {
 	Level   uint8  `yaml:"level"`
 }
 
+// BlocksPerDay is the expected number of blocks mined in a day.
+const BlocksPerDay = 144
+
+// BlockInterval is the expected time between blocks.
+const BlockInterval = 24 * time.Hour / BlocksPerDay
+
 // Lottery configuration.
+//
+// Rounding is the policy used to round the prizes to whole sats: "nearest" (the default),
+// "first_prize" or "fee". Collision is the policy applied when several prizes land on the same
+// public key: "stack" (the default), "reroll" or "cascade".
+//
+// Lotteries are drawn every Duration blocks, or every Frequency if it's set (a multiple of the
+// block interval, e.g. "1h"). The next lottery opens for betting Overlap blocks before the
+// target height of the current one, which stops accepting bets while it awaits its draw.
 type Lottery struct {
-	Logger   Logger `yaml:"logger"`
-	Duration uint32 `yaml:"duration"`
+	Logger        Logger        `yaml:"logger"`
+	Bonus         Bonus         `yaml:"bonus"`
+	LastTicket    LastTicket    `yaml:"last_ticket"`
+	PeerCap       PeerCap       `yaml:"peer_cap"`
+	HousePlay     HousePlay     `yaml:"house_play"`
+	ClaimWindow   ClaimWindow   `yaml:"claim_window"`
+	DeadManSwitch DeadManSwitch `yaml:"dead_man_switch"`
+	Postponement  Postponement  `yaml:"postponement"`
+	Limits        Limits        `yaml:"limits"`
+	Cancellation  Cancellation  `yaml:"cancellation"`
+	ClaimCodes    ClaimCodes    `yaml:"claim_codes"`
+	LNURLPay      LNURLPay      `yaml:"lnurl_pay"`
+	Keysend       Keysend       `yaml:"keysend"`
+	Digest        Digest        `yaml:"digest"`
+	Approvals     Approvals     `yaml:"approvals"`
+	Payouts       Payouts       `yaml:"payouts"`
+	BetArchive    BetArchive    `yaml:"bet_archive"`
+	WinnersHub    WinnersHub    `yaml:"winners_hub"`
+	DrawSLO       DrawSLO       `yaml:"draw_slo"`
+	Capacity      Capacity      `yaml:"capacity"`
+	StatsPrivacy  StatsPrivacy  `yaml:"stats_privacy"`
+	Reveal        Reveal        `yaml:"reveal"`
+	Milestones    Milestones    `yaml:"milestones"`
+	Pools         []Pool        `yaml:"pools"`
+	// AdaptiveTiers scale the number of prizes of each pool with its unique participants
+	AdaptiveTiers []AdaptiveTier `yaml:"adaptive_tiers"`
+	// FeeDestinations split the fee of every lottery, the share left stays in the node
+	FeeDestinations []FeeDestination `yaml:"fee_destinations"`
+	Rounding        string           `yaml:"rounding"`
+	Collision       string           `yaml:"collision"`
+	Frequency       time.Duration    `yaml:"frequency"`
+	Duration        uint32           `yaml:"duration"`
+	Overlap         uint32           `yaml:"overlap"`
+}
+
+// Overflow policies of the winners hub.
+const (
+	// OverflowDropOldest discards the oldest batch buffered to make room for the new one
+	OverflowDropOldest = "drop_oldest"
+	// OverflowDropNewest discards the new batch, keeping the ones buffered
+	OverflowDropNewest = "drop_newest"
+)
+
+// WinnersHub configures the delivery of the lottery winners to the services subscribed to them.
+//
+// Every subscriber buffers up to BufferSize batches of winners, when a slow one falls behind the
+// Overflow policy decides which batch is dropped. The last Replay batches are sent to subscribers
+// when they join.
+type WinnersHub struct {
+	Overflow   string `yaml:"overflow"`
+	BufferSize int    `yaml:"buffer_size"`
+	Replay     int    `yaml:"replay"`
+}
+
+// FeeDestination receives Percentage of the fee of every lottery, the sats left after paying the
+// winners, through its lightning address. Name identifies it in the distribution records.
+type FeeDestination struct {
+	Name       string  `yaml:"name"`
+	Address    string  `yaml:"address"`
+	Percentage float64 `yaml:"percentage"`
+}
+
+// StatsPrivacy keeps the public statistics from revealing the bets of individual players. The
+// prize pools and prizes of the lotteries with less than MinPlayers unique players are hidden, and
+// the amounts published are rounded down to a multiple of Bucket sats. It applies to the
+// statistics, the leaderboards and the live updates feed. Zero values disable each policy.
+type StatsPrivacy struct {
+	MinPlayers uint64 `yaml:"min_players"`
+	Bucket     uint64 `yaml:"bucket"`
+}
+
+// Limits configures the responsible gambling limits players can set themselves. Changes that
+// loosen a limit take effect after Cooldown, which defaults to a week.
+type Limits struct {
+	Cooldown time.Duration `yaml:"cooldown"`
+}
+
+// Cancellation lets players cancel their bets during Window after placing them, as long as the
+// lottery hasn't been drawn. The sats paid are refunded as a prize, minus Fee, a percentage. A
+// Window of 0 disables cancellations.
+type Cancellation struct {
+	Window time.Duration `yaml:"window"`
+	Fee    float64       `yaml:"fee"`
+}
+
+// ClaimCodes lets players bet anonymously, without a persistent public key. Each anonymous bet
+// comes with a one-time claim code that redeems the prizes it wins until Expiry elapses. An Expiry
+// of 0 disables anonymous bets.
+type ClaimCodes struct {
+	Expiry time.Duration `yaml:"expiry"`
+}
+
+// LNURLPay configures a static LNURL-pay code that sells a ticket of Amount sats on every payment,
+// meant to be printed at points of sale. Payers may attach the public key the bet is registered
+// under in the comment, the rest of the bets are anonymous. An amount of 0 disables it.
+type LNURLPay struct {
+	Description string `yaml:"description"`
+	Amount      uint64 `yaml:"amount"`
+}
+
+// Keysend accepts spontaneous keysend payments carrying a bet record as bets. The node must hold
+// keysend payments (keysend-hold-time in lnd) so the ones that can't be placed are returned.
+//
+// Confirmation is the amount in sats of the keysend payments confirming the bets to the payers
+// that ask for them, 0 disables the confirmations.
+type Keysend struct {
+	Confirmation uint64 `yaml:"confirmation"`
+	Enabled      bool   `yaml:"enabled"`
+}
+
+// Digest notifies the players that opted in of the result of every lottery they bet on. The
+// messages are sent in batches of BatchSize every Interval to stay under the rate limits of the
+// notification services, which default to 20 every second.
+type Digest struct {
+	Interval  time.Duration `yaml:"interval"`
+	BatchSize int           `yaml:"batch_size"`
+	Enabled   bool          `yaml:"enabled"`
+}
+
+// Milestones announces when the prize pool of the next lottery crosses each of the Amounts, in
+// satoshis, to drive participation. The announcements are sent to the telegram chat with ChatID, if
+// any, and published on nostr if Nostr is true.
+type Milestones struct {
+	Amounts []uint64 `yaml:"amounts"`
+	ChatID  int64    `yaml:"chat_id"`
+	Nostr   bool     `yaml:"nostr"`
+}
+
+// BetArchive keeps a compressed copy of the bets of each lottery drawn, before they are compacted,
+// so the draws can be verified later on. Retention is the number of lotteries whose bets are kept,
+// 0 keeps them forever.
+type BetArchive struct {
+	Retention uint32 `yaml:"retention"`
+}
+
+// DrawSLO is the service level objective of the draws latency: Target percent of the last Window
+// draws must complete within Objective. Zero values are replaced by the defaults, a second, 99%
+// and 30 draws.
+type DrawSLO struct {
+	Objective time.Duration `yaml:"objective"`
+	Target    float64       `yaml:"target"`
+	Window    uint64        `yaml:"window"`
+}
+
+// Capacity sets the maximum number of sats bet in a lottery, calculated according to Mode:
+//
+//   - "remote_balance" (the default): the remote balance of the node channels divided by Divisor,
+//     which defaults to 5.
+//   - "liability": like "remote_balance", minus the prizes owed to the winners that the local
+//     balance doesn't cover, as the bets received would be needed to pay them.
+//   - "fixed": Override sats regardless of the liquidity, the draws warn when it doesn't back it.
+//
+// A non-zero Override without a mode selects the fixed mode.
+type Capacity struct {
+	Mode     string `yaml:"mode"`
+	Divisor  int64  `yaml:"divisor"`
+	Override int64  `yaml:"override"`
+}
+
+// Reveal enables the suspense mode, where the winners of every lottery are announced one tier at a
+// time over the Blocks following the draw, from the last place to the first one. The hash of the
+// complete results is published at the draw so the reveals can be verified. A Blocks of 0 announces
+// all the winners at the draw.
+type Reveal struct {
+	Blocks uint32 `yaml:"blocks"`
+}
+
+// Approvals holds the withdrawals above Threshold sats until two different operators approve them
+// in the administration API. Pending approvals expire after Expiry, which defaults to a day, or
+// when their invoice does, and the prizes are returned. A Threshold of 0 disables approvals.
+type Approvals struct {
+	Threshold uint64        `yaml:"threshold"`
+	Expiry    time.Duration `yaml:"expiry"`
+}
+
+// Payouts defers the automatic payouts whose estimated routing fee is above FeeCeilingPPM parts per
+// million of the amount or above FeeCeilingSat sats, retrying them on every block until the fees go
+// down. Payouts whose prizes expire in UrgentBlocks or less, 144 by default, are sent regardless of
+// the fees. Both ceilings at 0 disable the deferral.
+//
+// Payouts of ProbeThreshold sats or more are preceded by a route probe to the winner node. If
+// ProbeAttempts probes in a row, 3 by default, don't find a route whose success probability is at
+// least ProbeMinSuccess, the payout isn't attempted and the winner is asked to claim the prizes with
+// an invoice. A ProbeThreshold of 0 disables the probes.
+type Payouts struct {
+	FeeCeilingPPM   int64   `yaml:"fee_ceiling_ppm"`
+	FeeCeilingSat   int64   `yaml:"fee_ceiling_sat"`
+	ProbeThreshold  uint64  `yaml:"probe_threshold"`
+	ProbeMinSuccess float64 `yaml:"probe_min_success"`
+	ProbeAttempts   int     `yaml:"probe_attempts"`
+	UrgentBlocks    uint32  `yaml:"urgent_blocks"`
+}
+
+// Pool is a segment of the lottery for bets within an amount range, so small players don't
+// compete against big ones. Every pool is drawn independently on the same block.
+//
+// A MaxAmount of 0 means there's no upper limit. Capacity is the percentage of the lottery
+// capacity the pool can take and Distribution the prize pool percentage assigned to each winner,
+// in order. If it's empty, the default prize table is used.
+type Pool struct {
+	Name         string    `yaml:"name"`
+	Distribution []float64 `yaml:"distribution"`
+	MinAmount    uint64    `yaml:"min_amount"`
+	MaxAmount    uint64    `yaml:"max_amount"`
+	Capacity     float64   `yaml:"capacity"`
+}
+
+// AdaptiveTier limits the prizes of the pools with Participants unique public keys or more to the
+// first Tiers of their distribution, so small lotteries don't hand many prizes to a few players.
+// The percentages kept are scaled so the fee doesn't change. Tiers must be sorted by participants,
+// pools below the first one use its tiers.
+type AdaptiveTier struct {
+	Participants uint64 `yaml:"participants"`
+	Tiers        int    `yaml:"tiers"`
+}
+
+// DeadManSwitch decides what happens to the bets of a lottery whose target height was mined
+// while the server was unable to draw it. If up to MaxMissedHeights target heights were missed,
+// the bets are moved to the next lottery, otherwise they are refunded to the bettors.
+type DeadManSwitch struct {
+	MaxMissedHeights uint32 `yaml:"max_missed_heights"`
+	Enabled          bool   `yaml:"enabled"`
+}
+
+// Postponement delays the draws the node can't pay, when the local balance of its channels doesn't
+// cover the prizes owed plus the prize pool of the lottery, for example after force closes. The
+// draw is attempted again every Blocks blocks, 6 by default, and bets are rejected meanwhile.
+type Postponement struct {
+	Enabled bool   `yaml:"enabled"`
+	Blocks  uint32 `yaml:"blocks"`
+}
+
+// Bonus contains the promotional bundles and the coin-age schedule that grant extra tickets to
+// bets. Bonus tickets are funded from BTRY's fee, so they never exceed the fee of the tickets paid
+// in their pool, and RoundCap limits the number of them issued in a single lottery.
+type Bonus struct {
+	Bundles  []Bundle  `yaml:"bundles"`
+	CoinAge  []CoinAge `yaml:"coin_age"`
+	RoundCap uint64    `yaml:"round_cap"`
+}
+
+// LastTicket configures a bonus draw among the last Tickets sold before the lottery closes, to
+// encourage filling the pool. The prize is Percentage of the prize pool and it's funded from BTRY's
+// fee. Tickets 0 disables it.
+type LastTicket struct {
+	Tickets    uint64  `yaml:"tickets"`
+	Percentage float64 `yaml:"percentage"`
+}
+
+// Bundle grants a percentage of extra tickets to the bets of at least MinAmount sats.
+type Bundle struct {
+	MinAmount  uint64  `yaml:"min_amount"`
+	Percentage float64 `yaml:"percentage"`
+}
+
+// CoinAge grants a percentage of extra tickets to the bets placed when at least MinBlocksLeft
+// blocks remain until the lottery draw, rewarding the players that bet early in the round.
+type CoinAge struct {
+	MinBlocksLeft uint32  `yaml:"min_blocks_left"`
+	Percentage    float64 `yaml:"percentage"`
+}
+
+// HousePlay designates the public keys of the operators. Their bets are rejected unless Allowed is
+// true, in which case they are accepted and flagged as house bets. The policy is published so
+// players know whether the house takes part in the draws.
+type HousePlay struct {
+	PublicKeys []string `yaml:"public_keys"`
+	Allowed    bool     `yaml:"allowed"`
+}
+
+// PeerCap limits the sats bet in a single lottery through each channel peer, so the prize
+// liabilities aren't concentrated behind one channel. Peers overrides MaxAmount for specific node
+// public keys.
+type PeerCap struct {
+	Peers     map[string]uint64 `yaml:"peers"`
+	MaxAmount uint64            `yaml:"max_amount"`
+	Enabled   bool              `yaml:"enabled"`
+}
+
+// ClaimWindow is the period winners have to withdraw their prizes. It can be expressed either in
+// blocks or in days, but not both.
+type ClaimWindow struct {
+	Blocks uint32 `yaml:"blocks"`
+	Days   uint32 `yaml:"days"`
+}
+
+// ClaimWindowBlocks returns the number of blocks winners have to withdraw their prizes.
+//
+// If it's not specified, prizes expire after five lotteries.
+func (l Lottery) ClaimWindowBlocks() uint32 {
+	if l.ClaimWindow.Blocks != 0 {
+		return l.ClaimWindow.Blocks
+	}
+	if l.ClaimWindow.Days != 0 {
+		return l.ClaimWindow.Days * BlocksPerDay
+	}
+	return l.DurationBlocks() * 5
+}
+
+// TierSchedule returns the adaptive tiers as the schedule used by the lottery engine.
+func (l Lottery) TierSchedule() engine.TierSchedule {
+	schedule := make(engine.TierSchedule, 0, len(l.AdaptiveTiers))
+	for _, tier := range l.AdaptiveTiers {
+		schedule = append(schedule, engine.TierStep{Participants: tier.Participants, Tiers: tier.Tiers})
+	}
+	return schedule
+}
+
+// DurationBlocks returns the number of blocks between lottery draws.
+func (l Lottery) DurationBlocks() uint32 {
+	if l.Frequency != 0 {
+		return uint32(l.Frequency / BlockInterval)
+	}
+	return l.Duration
+}
+
+// Loop daemon REST API configuration, used to perform Loop Out swaps.
+type Loop struct {
+	Address      string `yaml:"address"`
+	TLSCertPath  string `yaml:"tls_cert_path"`
+	MacaroonPath string `yaml:"macaroon_path"`
+}
+
+// LSP configuration, used to buy inbound channels with LSPS1 orders over its HTTP API.
+type LSP struct {
+	URL   string `yaml:"url"`
+	Token string `yaml:"token"`
+	// ChannelExpiryBlocks is the number of blocks the LSP must keep the channel open for
+	ChannelExpiryBlocks uint32 `yaml:"channel_expiry_blocks"`
+	MaxFeePPM           uint64 `yaml:"max_fee_ppm"`
 }
 
 // Nostr configuration.
@@ -71,10 +754,34 @@ type Nostr struct {
 
 // Notifier configuration.
 type Notifier struct {
-	Telegram Telegram `yaml:"telegram"`
-	Nostr    Nostr    `yaml:"nostr"`
-	Logger   Logger   `yaml:"logger"`
-	Enabled  bool     `yaml:"enabled"`
+	Telegram  Telegram  `yaml:"telegram"`
+	Dispatch  Dispatch  `yaml:"dispatch"`
+	Nostr     Nostr     `yaml:"nostr"`
+	Templates Templates `yaml:"templates"`
+	Logger    Logger    `yaml:"logger"`
+	Enabled   bool      `yaml:"enabled"`
+}
+
+// Dispatch configures the delivery of the telegram messages. Workers send them in parallel within
+// the Telegram rate limits: GlobalRate messages per second across all chats, 25 by default, and one
+// every ChatInterval to the same chat, 1s by default. Messages rejected by the flood control are
+// retried after the time Telegram advises, up to MaxAttempts times.
+type Dispatch struct {
+	ChatInterval time.Duration `yaml:"chat_interval"`
+	Workers      int           `yaml:"workers"`
+	GlobalRate   int           `yaml:"global_rate"`
+	MaxAttempts  int           `yaml:"max_attempts"`
+}
+
+// Cache configuration. The responses of the hot read endpoints are kept in memory for the TTL of
+// their group and discarded when a new block is found or a lottery is drawn. A zero TTL disables
+// caching in the group, its responses still carry an ETag.
+type Cache struct {
+	Info    time.Duration `yaml:"info"`
+	History time.Duration `yaml:"history"`
+	Stats   time.Duration `yaml:"stats"`
+	// MaxEntries is the number of responses kept, defaults to 1000
+	MaxEntries int `yaml:"max_entries"`
 }
 
 // RateLimiter configuration.
@@ -96,36 +803,125 @@ type Server struct {
 	} `yaml:"timeout"`
 }
 
+// Jurisdiction gating configuration, it restricts the clients that can place bets by their IP
+// address. In "block" mode the networks and countries listed are rejected, in "allow" mode only
+// them are accepted. An empty mode disables the gating.
+//
+// Countries are looked up in CountriesFile, a CSV file with a network (CIDR) and an ISO country
+// code per line, like the ones derived from the MaxMind GeoLite2 or DB-IP Lite databases.
+type Jurisdiction struct {
+	Mode          string   `yaml:"mode"`
+	CountriesFile string   `yaml:"countries_file"`
+	Countries     []string `yaml:"countries"`
+	CIDRs         []string `yaml:"cidrs"`
+}
+
+// Maintenance window, new bets and withdrawals are rejected between From and Until while the draws
+// keep running. A zero From starts the window immediately and a zero Until disables it.
+type Maintenance struct {
+	From  time.Time `yaml:"from"`
+	Until time.Time `yaml:"until"`
+}
+
+// GraphQL endpoint configuration. Subscriptions to the lottery information check for updates every
+// UpdateInterval, which defaults to 10 seconds.
+type GraphQL struct {
+	Logger         Logger        `yaml:"logger"`
+	UpdateInterval time.Duration `yaml:"update_interval"`
+	Enabled        bool          `yaml:"enabled"`
+}
+
+// Live websocket configuration. The changes of the prize pools are coalesced and sent to each client
+// at most once every Interval, which defaults to one second.
+type Live struct {
+	Logger   Logger        `yaml:"logger"`
+	Interval time.Duration `yaml:"interval"`
+	Enabled  bool          `yaml:"enabled"`
+}
+
 // SSE configuration.
 type SSE struct {
 	Logger   Logger        `yaml:"logger"`
 	Deadline time.Duration `yaml:"deadline"`
 }
 
+// Templates configuration. The notification messages are Go text templates, the ones of each
+// event can be overridden with a <event>.tmpl file in the directory or in the messages map, which
+// takes precedence.
+type Templates struct {
+	Messages map[string]string `yaml:"messages"`
+	Dir      string            `yaml:"dir"`
+	// ClaimURL is the page where winners claim their prizes, available in the templates
+	ClaimURL string `yaml:"claim_url"`
+	// VerifyURL is the page where the draws are verified, available in the templates
+	VerifyURL string `yaml:"verify_url"`
+}
+
 // Telegram configuration.
 type Telegram struct {
 	BotAPIToken string `yaml:"bot_api_token"`
 	BotName     string `yaml:"bot_name"`
 }
 
+// Reload configuration. The settings that don't require restarting the server are reloaded
+// when it receives a SIGHUP signal or an operator requests it through the admin API.
+type Reload struct {
+	Logger Logger `yaml:"logger"`
+}
+
 // Tor configuration.
 type Tor struct {
 	Address string        `yaml:"address"`
 	Timeout time.Duration `yaml:"timeout"`
+	// ControlAddress is the address of the Tor control port, required by the hidden service
+	ControlAddress string `yaml:"control_address"`
+	// ControlPassword is used to authenticate against the control port, if empty the cookie
+	// authentication is attempted
+	ControlPassword string        `yaml:"control_password"`
+	HiddenService   HiddenService `yaml:"hidden_service"`
+}
+
+// HiddenService configuration. The API is exposed as an onion service created through the Tor
+// control port.
+type HiddenService struct {
+	// Port is the port the onion service is reachable at, defaults to 80
+	Port int `yaml:"port"`
+	// TargetAddress is the IP address Tor forwards the connections to, leave empty if Tor runs on
+	// the same host
+	TargetAddress string `yaml:"target_address"`
+	// KeyPath is the file the private key of the service is stored in to keep the same onion
+	// address across restarts
+	KeyPath string `yaml:"key_path"`
+	Enabled bool   `yaml:"enabled"`
 }
 
 // New returns a configuration object loaded from a file.
 func New() (Config, error) {
+	path, err := Path()
+	if err != nil {
+		return Config{}, err
+	}
+
+	return Load(path)
+}
+
+// Path returns the location of the configuration file.
+func Path() (string, error) {
 	configPath := os.Getenv("BTRY_CONFIG")
 	if configPath == "" {
 		dir, err := os.Getwd()
 		if err != nil {
-			return Config{}, err
+			return "", err
 		}
 		configPath = filepath.Join(dir, "btry.yml")
 	}
 
-	f, err := os.OpenFile(configPath, os.O_RDONLY, 0o600)
+	return configPath, nil
+}
+
+// Load reads and validates the configuration file located at the path specified.
+func Load(path string) (Config, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY, 0o600)
 	if err != nil {
 		return Config{}, errors.Wrap(err, "opening file")
 	}
@@ -143,16 +939,100 @@ func New() (Config, error) {
 	return config, nil
 }
 
-// Validate returns an error if the configuration is not valid.
-func (c Config) Validate() error {
-	if err := validateLoggers(
+// Loggers returns the configuration of all the loggers.
+func (c Config) Loggers() []Logger {
+	return []Logger{
+		c.Alerts.Logger,
+		c.Archive.Logger,
 		c.API.Logger,
 		c.API.SSE.Logger,
+		c.API.GraphQL.Logger,
+		c.API.Live.Logger,
+		c.Audit.Logger,
 		c.DB.Logger,
+		c.Jobs.Logger,
+		c.Leader.Logger,
 		c.Lightning.Logger,
+		c.Liquidity.Logger,
+		c.Rates.Logger,
+		c.Watchdog.Logger,
+		c.Webhooks.Logger,
 		c.Lottery.Logger,
+		c.Notifier.Logger,
+		c.Reload.Logger,
 		c.Server.Logger,
-	); err != nil {
+	}
+}
+
+// Reloadable returns an error if the next configuration changes settings that require restarting
+// the server.
+//
+// Only the logger levels, the pools prize tables, the limits cooldown, the cancellation settings,
+// the peer cap amounts, the maintenance window and the notifier credentials can be reloaded.
+func (c Config) Reloadable(next Config) error {
+	current := reflect.ValueOf(c.structural())
+	nextValue := reflect.ValueOf(next.structural())
+
+	for i := 0; i < current.NumField(); i++ {
+		if !reflect.DeepEqual(current.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			section := strings.Split(current.Type().Field(i).Tag.Get("yaml"), ",")[0]
+			return errors.Errorf("%s settings changed, they require a restart", section)
+		}
+	}
+
+	return nil
+}
+
+// structural returns a copy of the configuration without the settings that can be reloaded.
+func (c Config) structural() Config {
+	for _, logger := range []*Logger{
+		&c.Alerts.Logger,
+		&c.Archive.Logger,
+		&c.API.Logger,
+		&c.API.SSE.Logger,
+		&c.API.GraphQL.Logger,
+		&c.API.Live.Logger,
+		&c.Audit.Logger,
+		&c.DB.Logger,
+		&c.Jobs.Logger,
+		&c.Leader.Logger,
+		&c.Lightning.Logger,
+		&c.Liquidity.Logger,
+		&c.Rates.Logger,
+		&c.Watchdog.Logger,
+		&c.Webhooks.Logger,
+		&c.Lottery.Logger,
+		&c.Notifier.Logger,
+		&c.Reload.Logger,
+		&c.Server.Logger,
+	} {
+		logger.Level = 0
+	}
+
+	// Copy the pools to avoid modifying the original ones
+	pools := make([]Pool, len(c.Lottery.Pools))
+	for i, pool := range c.Lottery.Pools {
+		pool.Distribution = nil
+		pools[i] = pool
+	}
+	c.Lottery.Pools = pools
+	c.API.Maintenance = Maintenance{}
+	c.Chaos = Chaos{}
+	c.Lottery.Limits = Limits{}
+	c.Lottery.Cancellation = Cancellation{}
+	c.Lottery.Approvals = Approvals{}
+	c.Lottery.Bonus = Bonus{}
+	c.Lottery.PeerCap.Peers = nil
+	c.Lottery.PeerCap.MaxAmount = 0
+	c.Notifier.Telegram = Telegram{}
+	c.Notifier.Nostr = Nostr{}
+
+	return c
+}
+
+// Validate returns an error if the configuration is not valid.
+func (c Config) Validate() error {
+	if err := validateLoggers(c.Loggers()...); err != nil {
 		return err
 	}
 
@@ -170,18 +1050,800 @@ func (c Config) Validate() error {
 		return errors.Wrap(err, "invalid macaroon encoding")
 	}
 
-	if c.Lottery.Duration == 0 {
+	switch c.Lightning.Network {
+	case "", NetworkMainnet, NetworkTestnet, NetworkSignet, NetworkRegtest:
+	default:
+		return errors.Errorf("invalid lightning network %q", c.Lightning.Network)
+	}
+
+	if c.Lottery.Duration != 0 && c.Lottery.Frequency != 0 {
+		return errors.New("invalid lottery duration, specify either duration or frequency")
+	}
+
+	if c.Lottery.Frequency < 0 || c.Lottery.Frequency%BlockInterval != 0 {
+		return errors.Errorf("invalid lottery frequency, must be a positive multiple of %s", BlockInterval)
+	}
+
+	if c.Lottery.DurationBlocks() == 0 {
 		return errors.New("invalid lottery duration, must be higher than zero")
 	}
 
-	return validateAddresses(c.Lightning.RPCAddress, c.Server.Address, c.Tor.Address)
-}
+	if c.Lottery.Overlap >= c.Lottery.DurationBlocks() {
+		return errors.New("invalid lottery overlap, must be lower than the lottery duration")
+	}
 
-func validateLoggers(loggers ...Logger) error {
-	for _, logger := range loggers {
-		// Not importing logger constants to avoid cycle
-		if logger.Level < 0 || logger.Level > 5 {
-			return errors.Errorf("invalid logger %q. Level should be between 0 and 5", logger.Label)
+	if c.Lottery.ClaimWindow.Blocks != 0 && c.Lottery.ClaimWindow.Days != 0 {
+		return errors.New("invalid claim window, specify either blocks or days")
+	}
+
+	if c.Lottery.ClaimWindowBlocks() < c.Lottery.DurationBlocks() {
+		return errors.New("invalid claim window, must be equal or higher than the lottery duration")
+	}
+
+	if c.DB.Retention.Months != 0 && c.DB.Retention.Blocks() <= c.Lottery.ClaimWindowBlocks() {
+		return errors.New("invalid database retention, it must be longer than the claim window")
+	}
+
+	if err := validateDB(c.DB); err != nil {
+		return err
+	}
+
+	if err := validateAdmin(c.API.Admin); err != nil {
+		return err
+	}
+
+	if err := validateJurisdiction(c.API.Jurisdiction); err != nil {
+		return err
+	}
+
+	if err := validateClaimWidget(c.API.ClaimWidget); err != nil {
+		return err
+	}
+
+	if err := validateIdentity(c.API.Identity); err != nil {
+		return err
+	}
+
+	if provider := c.API.Swaps.Provider; provider != "" && provider != "boltz" {
+		return errors.Errorf("invalid swap provider %q", provider)
+	}
+
+	if maintenance := c.API.Maintenance; !maintenance.Until.IsZero() && !maintenance.Until.After(maintenance.From) {
+		return errors.New("invalid maintenance window, must end after it starts")
+	}
+
+	if c.API.GraphQL.UpdateInterval < 0 {
+		return errors.New("invalid graphql update interval, must not be negative")
+	}
+
+	if c.API.Live.Interval < 0 {
+		return errors.New("invalid live interval, must not be negative")
+	}
+
+	if cache := c.API.Cache; cache.Info < 0 || cache.History < 0 || cache.Stats < 0 || cache.MaxEntries < 0 {
+		return errors.New("invalid cache settings, must not be negative")
+	}
+
+	if err := validateBonus(c.Lottery.Bonus); err != nil {
+		return err
+	}
+
+	if lastTicket := c.Lottery.LastTicket; lastTicket.Tickets > 0 &&
+		(lastTicket.Percentage <= 0 || lastTicket.Percentage > 100) {
+		return errors.Errorf("invalid last ticket bonus percentage %.2f. It should be between 0 and 100",
+			lastTicket.Percentage)
+	}
+
+	if err := validatePeerCap(c.Lottery.PeerCap); err != nil {
+		return err
+	}
+
+	if err := validateMilestones(c.Lottery.Milestones); err != nil {
+		return err
+	}
+
+	for _, publicKey := range c.Lottery.HousePlay.PublicKeys {
+		if _, err := hex.DecodeString(publicKey); err != nil || len(publicKey) != 64 {
+			return errors.Errorf("invalid house public key %q", publicKey)
+		}
+	}
+
+	if err := validatePools(c.Lottery.Pools); err != nil {
+		return err
+	}
+
+	if err := engine.Rounding(c.Lottery.Rounding).Validate(); err != nil {
+		return errors.Wrap(err, "invalid lottery rounding")
+	}
+
+	if err := engine.Collision(c.Lottery.Collision).Validate(); err != nil {
+		return errors.Wrap(err, "invalid lottery collision policy")
+	}
+
+	if err := c.Lottery.TierSchedule().Validate(); err != nil {
+		return errors.Wrap(err, "invalid adaptive tiers")
+	}
+
+	if c.Lottery.Limits.Cooldown < 0 {
+		return errors.New("invalid limits cooldown, must not be negative")
+	}
+
+	if err := validateCancellation(c.Lottery.Cancellation); err != nil {
+		return err
+	}
+
+	if err := validateDispatch(c.Notifier.Dispatch); err != nil {
+		return err
+	}
+
+	if c.Lottery.ClaimCodes.Expiry < 0 {
+		return errors.New("invalid claim codes expiry, must not be negative")
+	}
+
+	if digest := c.Lottery.Digest; digest.Interval < 0 || digest.BatchSize < 0 {
+		return errors.New("invalid digest settings, must not be negative")
+	}
+
+	if c.Lottery.Approvals.Expiry < 0 {
+		return errors.New("invalid approvals expiry, must not be negative")
+	}
+
+	if payouts := c.Lottery.Payouts; payouts.FeeCeilingPPM < 0 || payouts.FeeCeilingSat < 0 {
+		return errors.New("invalid payouts fee ceiling, must not be negative")
+	}
+
+	if payouts := c.Lottery.Payouts; payouts.ProbeAttempts < 0 {
+		return errors.New("invalid payouts probe attempts, must not be negative")
+	}
+
+	if payouts := c.Lottery.Payouts; payouts.ProbeMinSuccess < 0 || payouts.ProbeMinSuccess > 1 {
+		return errors.New("invalid payouts probe minimum success, must be between 0 and 1")
+	}
+
+	if err := validateFeeDestinations(c.Lottery.FeeDestinations); err != nil {
+		return err
+	}
+
+	if err := validateWinnersHub(c.Lottery.WinnersHub); err != nil {
+		return err
+	}
+
+	if slo := c.Lottery.DrawSLO; slo.Objective < 0 || slo.Target < 0 || slo.Target > 100 {
+		return errors.New("invalid draw SLO, the objective must not be negative and the target " +
+			"must be between 0 and 100")
+	}
+
+	if err := validateCapacity(c.Lottery.Capacity); err != nil {
+		return err
+	}
+
+	if c.Reserves.Enabled && !c.Audit.Enabled {
+		return errors.New("the proof of reserves requires the audit log to be enabled")
+	}
+
+	if err := validateArchive(c.Archive, c.Audit); err != nil {
+		return err
+	}
+
+	if err := validateLiquidity(c.Liquidity); err != nil {
+		return err
+	}
+
+	if jobs := c.Jobs; jobs.Workers < 0 || jobs.Backoff < 0 || jobs.Timeout < 0 || jobs.PollInterval < 0 {
+		return errors.New("invalid jobs settings, must not be negative")
+	}
+
+	if c.Leader.Lease < 0 {
+		return errors.New("invalid leader lease, must not be negative")
+	}
+
+	if err := validateWatchdog(c.Watchdog); err != nil {
+		return err
+	}
+
+	if err := validateRates(c.Rates); err != nil {
+		return err
+	}
+
+	if err := validateAlerts(c.Alerts); err != nil {
+		return err
+	}
+
+	if c.Audit.Enabled {
+		privateKey, err := hex.DecodeString(c.Audit.PrivateKey)
+		if err != nil {
+			return errors.Wrap(err, "invalid audit private key encoding")
+		}
+		if len(privateKey) != ed25519.SeedSize {
+			return errors.New("invalid audit private key length")
+		}
+	}
+
+	if err := validateChaos(c.Chaos); err != nil {
+		return err
+	}
+
+	if err := validateHiddenService(c.Tor); err != nil {
+		return err
+	}
+
+	return validateAddresses(c.Lightning.RPCAddress, c.Server.Address, c.Tor.Address)
+}
+
+func validateAdmin(admin Admin) error {
+	if admin.RPID == "" {
+		return nil
+	}
+
+	origin, err := url.Parse(admin.Origin)
+	if err != nil || origin.Host == "" {
+		return errors.Errorf("invalid admin origin %q", admin.Origin)
+	}
+
+	if origin.Hostname() != admin.RPID && !strings.HasSuffix(origin.Hostname(), "."+admin.RPID) {
+		return errors.New("invalid admin relying party ID, it must be the origin domain or a suffix of it")
+	}
+
+	if admin.SessionDuration < 0 {
+		return errors.New("invalid admin session duration")
+	}
+
+	return nil
+}
+
+func validateCapacity(capacity Capacity) error {
+	if capacity.Divisor < 0 || capacity.Override < 0 {
+		return errors.New("invalid capacity, the divisor and the override must not be negative")
+	}
+
+	switch capacity.Mode {
+	case "":
+		return nil
+	case "remote_balance", "liability":
+		if capacity.Override > 0 {
+			return errors.Errorf("the capacity override is not used in %q mode", capacity.Mode)
+		}
+	case "fixed":
+		if capacity.Override == 0 {
+			return errors.New("the fixed capacity mode requires an override")
+		}
+	default:
+		return errors.Errorf("invalid capacity mode %q", capacity.Mode)
+	}
+
+	return nil
+}
+
+func validateJurisdiction(jurisdiction Jurisdiction) error {
+	switch jurisdiction.Mode {
+	case "":
+		return nil
+	case "allow", "block":
+	default:
+		return errors.Errorf("invalid jurisdiction mode %q", jurisdiction.Mode)
+	}
+
+	for _, cidr := range jurisdiction.CIDRs {
+		if _, err := netip.ParsePrefix(cidr); err != nil {
+			return errors.Wrap(err, "invalid jurisdiction cidr")
+		}
+	}
+
+	if len(jurisdiction.Countries) > 0 && jurisdiction.CountriesFile == "" {
+		return errors.New("jurisdiction countries require a countries file")
+	}
+
+	return nil
+}
+
+func validateDB(db DB) error {
+	if db.BusyTimeout < 0 {
+		return errors.New("invalid database busy timeout")
+	}
+
+	if db.MmapSize < 0 {
+		return errors.New("invalid database mmap size")
+	}
+
+	if db.Snapshot.Interval < 0 {
+		return errors.New("invalid database snapshot interval")
+	}
+
+	if path := db.Notifications.Path; path != "" && filepath.Clean(path) == filepath.Clean(db.Path) {
+		return errors.New("invalid notifications database path, it must differ from the database path")
+	}
+
+	switch encryption := db.Notifications.Encryption; encryption.Provider {
+	case "":
+	case "file":
+		if encryption.KeyFile == "" {
+			return errors.New("encryption key file is required")
+		}
+	case "vault":
+		if encryption.Vault.Address == "" || encryption.Vault.Key == "" {
+			return errors.New("vault address and key are required")
+		}
+	default:
+		return errors.Errorf("invalid encryption provider %q", encryption.Provider)
+	}
+
+	if db.Retention.ExportDir != "" {
+		if info, err := os.Stat(db.Retention.ExportDir); err != nil || !info.IsDir() {
+			return errors.New("invalid database retention export directory")
+		}
+	}
+
+	if db.Snapshot.Interval > 0 {
+		if db.Snapshot.Path == "" {
+			return errors.New("database snapshot path is required")
+		}
+		if filepath.Clean(db.Snapshot.Path) == filepath.Clean(db.Path) {
+			return errors.New("invalid database snapshot path, it must differ from the database path")
+		}
+	}
+
+	return nil
+}
+
+func validateMilestones(milestones Milestones) error {
+	for i, amount := range milestones.Amounts {
+		if amount == 0 || (i > 0 && amount <= milestones.Amounts[i-1]) {
+			return errors.New("invalid milestones, amounts must be higher than zero and ascending")
+		}
+	}
+
+	return nil
+}
+
+func validatePeerCap(peerCap PeerCap) error {
+	if !peerCap.Enabled {
+		return nil
+	}
+
+	if peerCap.MaxAmount == 0 {
+		return errors.New("invalid peer cap maximum amount, must be higher than zero")
+	}
+
+	for publicKey := range peerCap.Peers {
+		if len(publicKey) != 66 {
+			return errors.Errorf("invalid peer cap public key %q", publicKey)
+		}
+		if _, err := hex.DecodeString(publicKey); err != nil {
+			return errors.Errorf("invalid peer cap public key %q", publicKey)
+		}
+	}
+
+	return nil
+}
+
+func validatePools(pools []Pool) error {
+	names := make(map[string]struct{}, len(pools))
+	capacity := float64(0)
+	for i, pool := range pools {
+		if pool.Name == "" {
+			return errors.New("invalid pool name, must not be empty")
+		}
+		if _, ok := names[pool.Name]; ok {
+			return errors.Errorf("duplicated pool %q", pool.Name)
+		}
+		names[pool.Name] = struct{}{}
+
+		if pool.MaxAmount != 0 && pool.MaxAmount < pool.MinAmount {
+			return errors.Errorf("invalid pool %q amount range, maximum is lower than minimum",
+				pool.Name)
+		}
+
+		for _, other := range pools[:i] {
+			if pool.overlaps(other) {
+				return errors.Errorf("pools %q and %q amount ranges overlap", other.Name, pool.Name)
+			}
+		}
+
+		if pool.Capacity <= 0 || pool.Capacity > 100 {
+			return errors.Errorf("invalid pool %q capacity %.2f. It should be between 0 and 100",
+				pool.Name, pool.Capacity)
+		}
+		capacity += pool.Capacity
+
+		if err := engine.Distribution(pool.Distribution).Validate(); err != nil {
+			return errors.Wrapf(err, "invalid pool %q distribution", pool.Name)
+		}
+	}
+
+	if capacity > 100 {
+		return errors.New("invalid pools capacity, percentages add up to more than 100")
+	}
+
+	return nil
+}
+
+// overlaps returns whether an amount fits in both pools.
+func (p Pool) overlaps(other Pool) bool {
+	pMax, otherMax := p.MaxAmount, other.MaxAmount
+	if pMax == 0 {
+		pMax = math.MaxUint64
+	}
+	if otherMax == 0 {
+		otherMax = math.MaxUint64
+	}
+	return p.MinAmount <= otherMax && other.MinAmount <= pMax
+}
+
+func validateCancellation(cancellation Cancellation) error {
+	if cancellation.Window < 0 {
+		return errors.New("invalid cancellation window, must not be negative")
+	}
+
+	if cancellation.Fee < 0 || cancellation.Fee >= 100 {
+		return errors.New("invalid cancellation fee, must be a percentage lower than 100")
+	}
+
+	return nil
+}
+
+func validateDispatch(dispatch Dispatch) error {
+	if dispatch.Workers < 0 || dispatch.GlobalRate < 0 || dispatch.MaxAttempts < 0 {
+		return errors.New("invalid notifier dispatch, workers, global rate and attempts must not be negative")
+	}
+
+	if dispatch.ChatInterval < 0 {
+		return errors.New("invalid notifier dispatch chat interval, must not be negative")
+	}
+
+	return nil
+}
+
+func validateFeeDestinations(destinations []FeeDestination) error {
+	names := make(map[string]struct{}, len(destinations))
+	total := float64(0)
+	for _, destination := range destinations {
+		if destination.Name == "" || destination.Address == "" {
+			return errors.New("invalid fee destination, the name and the address are required")
+		}
+		if _, ok := names[destination.Name]; ok {
+			return errors.Errorf("duplicated fee destination %q", destination.Name)
+		}
+		names[destination.Name] = struct{}{}
+
+		if destination.Percentage <= 0 || destination.Percentage > 100 {
+			return errors.Errorf("invalid fee destination %q percentage %.2f. It should be between 0 and 100",
+				destination.Name, destination.Percentage)
+		}
+		total += destination.Percentage
+	}
+
+	if total > 100 {
+		return errors.Errorf("invalid fee destinations, their percentages add up to %.2f", total)
+	}
+
+	return nil
+}
+
+func validateWinnersHub(hub WinnersHub) error {
+	switch hub.Overflow {
+	case "", OverflowDropOldest, OverflowDropNewest:
+	default:
+		return errors.Errorf("invalid winners hub overflow policy %q", hub.Overflow)
+	}
+
+	if hub.BufferSize < 0 || hub.Replay < 0 {
+		return errors.New("invalid winners hub buffer size or replay, must not be negative")
+	}
+
+	return nil
+}
+
+func validateClaimWidget(widget ClaimWidget) error {
+	if widget.Secret == "" {
+		return nil
+	}
+
+	secret, err := hex.DecodeString(widget.Secret)
+	if err != nil {
+		return errors.Wrap(err, "invalid claim widget secret")
+	}
+
+	if len(secret) < 32 {
+		return errors.New("invalid claim widget secret, must be at least 32 bytes long")
+	}
+
+	if widget.TTL < 0 || widget.TTL > time.Hour {
+		return errors.New("invalid claim widget ttl, must be between 0 and 1h")
+	}
+
+	return nil
+}
+
+func validateIdentity(identity Identity) error {
+	if identity.PrivateKey == "" {
+		if identity.SignResponses {
+			return errors.New("signing responses requires an identity private key")
+		}
+		return nil
+	}
+
+	seed, err := hex.DecodeString(identity.PrivateKey)
+	if err != nil {
+		return errors.Wrap(err, "invalid identity private key")
+	}
+
+	if len(seed) != 32 {
+		return errors.New("invalid identity private key, must be 32 bytes long")
+	}
+
+	return nil
+}
+
+func validateChaos(chaos Chaos) error {
+	for _, probability := range []float64{chaos.Delay, chaos.DropBlock, chaos.FailWrite, chaos.LightningError} {
+		if probability < 0 || probability > 1 {
+			return errors.New("invalid chaos probability, must be between 0 and 1")
+		}
+	}
+
+	if chaos.MaxDelay < 0 || (chaos.Delay > 0 && chaos.MaxDelay == 0) {
+		return errors.New("invalid chaos max delay, must be higher than zero if delays are injected")
+	}
+
+	return nil
+}
+
+func validateArchive(archive Archive, audit Audit) error {
+	if !archive.Enabled {
+		return nil
+	}
+
+	if !audit.Enabled {
+		return errors.New("the archive requires the audit log to be enabled")
+	}
+
+	if len(archive.Nostr.Relays) == 0 && archive.IPFS.URL == "" {
+		return errors.New("the archive requires nostr relays or an IPFS node")
+	}
+
+	if len(archive.Nostr.Relays) > 0 && archive.Nostr.PrivateKey == "" {
+		return errors.New("the archive nostr private key is required")
+	}
+
+	if archive.IPFS.URL != "" {
+		if _, err := url.ParseRequestURI(archive.IPFS.URL); err != nil {
+			return errors.Wrap(err, "invalid archive IPFS URL")
+		}
+	}
+
+	if archive.Interval < 0 {
+		return errors.New("invalid archive interval, must be positive")
+	}
+
+	return nil
+}
+
+func validateBonus(bonus Bonus) error {
+	if len(bonus.Bundles) == 0 && len(bonus.CoinAge) == 0 {
+		return nil
+	}
+
+	if bonus.RoundCap == 0 {
+		return errors.New("invalid bonus round cap, must be higher than zero")
+	}
+
+	for _, bundle := range bonus.Bundles {
+		if bundle.MinAmount == 0 {
+			return errors.New("invalid bundle minimum amount, must be higher than zero")
+		}
+		if bundle.Percentage <= 0 || bundle.Percentage > 100 {
+			return errors.Errorf("invalid bundle percentage %.2f. It should be between 0 and 100",
+				bundle.Percentage)
+		}
+	}
+
+	for _, coinAge := range bonus.CoinAge {
+		if coinAge.MinBlocksLeft == 0 {
+			return errors.New("invalid coin age minimum blocks left, must be higher than zero")
+		}
+		if coinAge.Percentage <= 0 || coinAge.Percentage > 100 {
+			return errors.Errorf("invalid coin age percentage %.2f. It should be between 0 and 100",
+				coinAge.Percentage)
+		}
+	}
+
+	return nil
+}
+
+func validateLiquidity(liquidity Liquidity) error {
+	if !liquidity.Enabled {
+		return nil
+	}
+
+	if liquidity.Interval <= 0 {
+		return errors.New("invalid liquidity interval, must be higher than zero")
+	}
+
+	if liquidity.OutboundRatio < 0 || liquidity.InboundRatio < 0 {
+		return errors.New("invalid liquidity ratios, must be positive")
+	}
+
+	if liquidity.ChannelRatio < 0 || liquidity.ChannelRatio >= 0.5 {
+		return errors.New("invalid liquidity channel ratio, must be between 0 and 0.5")
+	}
+
+	if liquidity.Inbound.Window < 0 || liquidity.Inbound.Cooldown < 0 {
+		return errors.New("invalid inbound liquidity window or cooldown, must be positive")
+	}
+
+	payable := false
+	for _, action := range liquidity.Actions {
+		// Not importing liquidity constants to avoid cycle
+		switch action {
+		case "alert":
+		case "rebalance":
+			payable = true
+		case "loop_out":
+			payable = true
+			if liquidity.Loop.Address == "" || liquidity.Loop.MacaroonPath == "" {
+				return errors.New("loop address and macaroon path are required to perform loop out swaps")
+			}
+		case "lsp_order":
+			payable = true
+			if liquidity.Inbound.Window == 0 || liquidity.Inbound.LSP.URL == "" {
+				return errors.New("inbound liquidity window and LSP URL are required to order channels")
+			}
+		default:
+			return errors.Errorf("invalid liquidity action %q", action)
+		}
+	}
+
+	if payable && (liquidity.Budget.Period <= 0 || liquidity.Budget.MaxFees == 0) {
+		return errors.New("invalid liquidity budget, period and maximum fees must be higher than zero")
+	}
+
+	return nil
+}
+
+func validateAlerts(alerts Alerts) error {
+	if !alerts.Enabled {
+		return nil
+	}
+
+	if alerts.Interval <= 0 {
+		return errors.New("invalid alerts interval, must be higher than zero")
+	}
+
+	channels := alerts.Channels
+	if channels.Webhook == "" && channels.PagerDuty == "" && channels.TelegramChatID == 0 {
+		return errors.New("at least one alerts channel is required")
+	}
+
+	for _, address := range []string{channels.Webhook, channels.PagerDuty} {
+		if address == "" {
+			continue
+		}
+		if _, err := url.ParseRequestURI(address); err != nil {
+			return errors.Wrap(err, "invalid alerts channel url")
+		}
+	}
+
+	if channels.PagerDuty != "" && channels.RoutingKey == "" {
+		return errors.New("pagerduty routing key is required")
+	}
+
+	names := make(map[string]struct{}, len(alerts.Rules))
+	for _, rule := range alerts.Rules {
+		if rule.Name == "" {
+			return errors.New("alert rule name is required")
+		}
+		if _, ok := names[rule.Name]; ok {
+			return errors.Errorf("duplicate alert rule %q", rule.Name)
+		}
+		names[rule.Name] = struct{}{}
+
+		// Not importing alert constants to avoid cycle
+		switch rule.Metric {
+		case "pool_size", "payout_failures", "routing_fees", "errors", "draw_latency":
+		default:
+			return errors.Errorf("invalid alert rule %q metric %q", rule.Name, rule.Metric)
+		}
+
+		if rule.Window < 0 {
+			return errors.Errorf("invalid alert rule %q window, must not be negative", rule.Name)
+		}
+	}
+
+	return nil
+}
+
+func validateWatchdog(watchdog Watchdog) error {
+	if !watchdog.Enabled {
+		return nil
+	}
+
+	// Not importing watchdog constants to avoid cycle
+	if watchdog.Source != "bitcoind" && watchdog.Source != "esplora" {
+		return errors.Errorf("invalid watchdog source %q", watchdog.Source)
+	}
+
+	if _, err := url.ParseRequestURI(watchdog.URL); err != nil {
+		return errors.Wrap(err, "invalid watchdog url")
+	}
+
+	if watchdog.Interval <= 0 || watchdog.StallTimeout <= 0 {
+		return errors.New("invalid watchdog interval or stall timeout, must be higher than zero")
+	}
+
+	return nil
+}
+
+func validateRates(rates Rates) error {
+	if !rates.Enabled {
+		return nil
+	}
+
+	if len(rates.Providers) == 0 {
+		return errors.New("at least one rates provider is required")
+	}
+
+	for _, provider := range rates.Providers {
+		// Not importing rates constants to avoid cycle
+		if provider.Name != "coingecko" && provider.Name != "mempool" {
+			return errors.Errorf("invalid rates provider %q", provider.Name)
+		}
+
+		if provider.URL != "" {
+			if _, err := url.ParseRequestURI(provider.URL); err != nil {
+				return errors.Wrapf(err, "invalid %s rates provider url", provider.Name)
+			}
+		}
+	}
+
+	if len(rates.Currencies) == 0 {
+		return errors.New("at least one rates currency is required")
+	}
+
+	for _, currency := range rates.Currencies {
+		if len(currency) != 3 {
+			return errors.Errorf("invalid rates currency %q, must be an ISO 4217 code", currency)
+		}
+	}
+
+	if rates.Interval < 0 || rates.MaxAge < 0 {
+		return errors.New("invalid rates interval or max age, must not be negative")
+	}
+
+	return nil
+}
+
+func validateLoggers(loggers ...Logger) error {
+	for _, logger := range loggers {
+		// Not importing logger constants to avoid cycle
+		if logger.Level < 0 || logger.Level > 5 {
+			return errors.Errorf("invalid logger %q. Level should be between 0 and 5", logger.Label)
+		}
+	}
+
+	return nil
+}
+
+func validateHiddenService(tor Tor) error {
+	hiddenService := tor.HiddenService
+	if !hiddenService.Enabled {
+		return nil
+	}
+
+	if tor.ControlAddress == "" {
+		return errors.New("the hidden service requires the tor control address")
+	}
+
+	if hiddenService.KeyPath == "" {
+		return errors.New("the hidden service requires a path to store its private key")
+	}
+
+	if hiddenService.Port < 0 || hiddenService.Port > 65535 {
+		return errors.New("invalid hidden service port")
+	}
+
+	if hiddenService.TargetAddress != "" {
+		if _, err := netip.ParseAddr(hiddenService.TargetAddress); err != nil {
+			return errors.Wrap(err, "invalid hidden service target address")
 		}
 	}
 
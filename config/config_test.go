@@ -3,6 +3,7 @@ package config_test
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/aftermath2/BTRY/config"
 	"github.com/aftermath2/BTRY/logger"
@@ -79,6 +80,1083 @@ func TestValidate(t *testing.T) {
 			},
 			fail: true,
 		},
+		{
+			desc: "Valid claim window",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.ClaimWindow.Days = 3
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Claim window in blocks and days",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.ClaimWindow.Blocks = 432
+				c.Lottery.ClaimWindow.Days = 3
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Claim window shorter than the lottery",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.ClaimWindow.Blocks = 143
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Hourly lottery",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Duration = 0
+				c.Lottery.Frequency = time.Hour
+				c.Lottery.Overlap = 2
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Lottery duration and frequency",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Frequency = time.Hour
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Lottery frequency not a multiple of the block interval",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Duration = 0
+				c.Lottery.Frequency = 45 * time.Minute
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Lottery overlap longer than the duration",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Overlap = 144
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid GraphQL",
+			getConfig: func(c config.Config) config.Config {
+				c.API.GraphQL = config.GraphQL{Enabled: true, UpdateInterval: 5 * time.Second}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Negative GraphQL update interval",
+			getConfig: func(c config.Config) config.Config {
+				c.API.GraphQL = config.GraphQL{Enabled: true, UpdateInterval: -time.Second}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Negative live interval",
+			getConfig: func(c config.Config) config.Config {
+				c.API.Live = config.Live{Enabled: true, Interval: -time.Second}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Negative jobs backoff",
+			getConfig: func(c config.Config) config.Config {
+				c.Jobs.Backoff = -time.Second
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Negative digest batch size",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Digest = config.Digest{Enabled: true, BatchSize: -1}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Negative leader lease",
+			getConfig: func(c config.Config) config.Config {
+				c.Leader = config.Leader{Enabled: true, Lease: -time.Second}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid jurisdiction",
+			getConfig: func(c config.Config) config.Config {
+				c.API.Jurisdiction = config.Jurisdiction{
+					Mode:          "block",
+					CountriesFile: "countries.csv",
+					Countries:     []string{"US"},
+					CIDRs:         []string{"10.0.0.0/8", "2001:db8::/32"},
+				}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Invalid jurisdiction mode",
+			getConfig: func(c config.Config) config.Config {
+				c.API.Jurisdiction.Mode = "deny"
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Invalid jurisdiction cidr",
+			getConfig: func(c config.Config) config.Config {
+				c.API.Jurisdiction = config.Jurisdiction{Mode: "allow", CIDRs: []string{"10.0.0.0"}}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Jurisdiction countries without file",
+			getConfig: func(c config.Config) config.Config {
+				c.API.Jurisdiction = config.Jurisdiction{Mode: "block", Countries: []string{"US"}}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid maintenance window",
+			getConfig: func(c config.Config) config.Config {
+				c.API.Maintenance = config.Maintenance{Until: time.Unix(1_800_000_000, 0)}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Maintenance ending before it starts",
+			getConfig: func(c config.Config) config.Config {
+				c.API.Maintenance = config.Maintenance{
+					From:  time.Unix(1_800_000_000, 0),
+					Until: time.Unix(1_700_000_000, 0),
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid database snapshot",
+			getConfig: func(c config.Config) config.Config {
+				c.DB = config.DB{
+					Path:     "btry.db",
+					WAL:      true,
+					Snapshot: config.Snapshot{Path: "btry_snapshot.db", Interval: time.Minute},
+				}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Database snapshot without path",
+			getConfig: func(c config.Config) config.Config {
+				c.DB.Snapshot.Interval = time.Minute
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Database snapshot overwriting the database",
+			getConfig: func(c config.Config) config.Config {
+				c.DB = config.DB{
+					Path:     "./btry.db",
+					Snapshot: config.Snapshot{Path: "btry.db", Interval: time.Minute},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Database retention",
+			getConfig: func(c config.Config) config.Config {
+				c.DB.Retention = config.Retention{Months: 12, DryRun: true, ExportDir: os.TempDir()}
+				return c
+			},
+		},
+		{
+			desc: "Database retention shorter than the claim window",
+			getConfig: func(c config.Config) config.Config {
+				c.DB.Retention.Months = 1
+				c.Lottery.ClaimWindow = config.ClaimWindow{Days: 60}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Database retention export directory not found",
+			getConfig: func(c config.Config) config.Config {
+				c.DB.Retention = config.Retention{Months: 12, ExportDir: "/path/not/found"}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid admin",
+			getConfig: func(c config.Config) config.Config {
+				c.API.Admin = config.Admin{RPID: "btry.example", Origin: "https://admin.btry.example"}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Admin origin outside the relying party",
+			getConfig: func(c config.Config) config.Config {
+				c.API.Admin = config.Admin{RPID: "btry.example", Origin: "https://evil.example"}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid bonus bundles",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Bonus = config.Bonus{
+					Bundles:  []config.Bundle{{MinAmount: 10_000, Percentage: 5}},
+					RoundCap: 50_000,
+				}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Bonus bundles without round cap",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Bonus.Bundles = []config.Bundle{{MinAmount: 10_000, Percentage: 5}}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Invalid bundle percentage",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Bonus = config.Bonus{
+					Bundles:  []config.Bundle{{MinAmount: 10_000, Percentage: 150}},
+					RoundCap: 50_000,
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid coin age bonus",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Bonus = config.Bonus{
+					CoinAge:  []config.CoinAge{{MinBlocksLeft: 100, Percentage: 2}},
+					RoundCap: 50_000,
+				}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Coin age bonus without round cap",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Bonus.CoinAge = []config.CoinAge{{MinBlocksLeft: 100, Percentage: 2}}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Invalid coin age blocks left",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Bonus = config.Bonus{
+					CoinAge:  []config.CoinAge{{Percentage: 2}},
+					RoundCap: 50_000,
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Reserves without audit log",
+			getConfig: func(c config.Config) config.Config {
+				c.Reserves.Enabled = true
+				c.Audit.Enabled = false
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid claim widget",
+			getConfig: func(c config.Config) config.Config {
+				c.API.ClaimWidget = config.ClaimWidget{
+					Secret: "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb",
+					TTL:    5 * time.Minute,
+				}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Short claim widget secret",
+			getConfig: func(c config.Config) config.Config {
+				c.API.ClaimWidget.Secret = "4ccd089b"
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Claim widget ttl too long",
+			getConfig: func(c config.Config) config.Config {
+				c.API.ClaimWidget = config.ClaimWidget{
+					Secret: "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb",
+					TTL:    2 * time.Hour,
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid archive",
+			getConfig: func(c config.Config) config.Config {
+				c.Audit.Enabled = true
+				c.Audit.PrivateKey = "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb"
+				c.Archive = config.Archive{Enabled: true, IPFS: config.IPFS{URL: "http://127.0.0.1:5001"}}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Archive without destinations",
+			getConfig: func(c config.Config) config.Config {
+				c.Audit.Enabled = true
+				c.Archive.Enabled = true
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Archive without nostr private key",
+			getConfig: func(c config.Config) config.Config {
+				c.Audit.Enabled = true
+				c.Archive = config.Archive{Enabled: true, Nostr: config.Nostr{Relays: []string{"wss://relay.damus.io"}}}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Archive without audit log",
+			getConfig: func(c config.Config) config.Config {
+				c.Audit.Enabled = false
+				c.Archive = config.Archive{Enabled: true, IPFS: config.IPFS{URL: "http://127.0.0.1:5001"}}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid last ticket bonus",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.LastTicket = config.LastTicket{Tickets: 10_000, Percentage: 0.5}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Last ticket bonus without percentage",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.LastTicket = config.LastTicket{Tickets: 10_000}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid peer cap",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.PeerCap = config.PeerCap{
+					Enabled:   true,
+					MaxAmount: 1_000_000,
+					Peers: map[string]uint64{
+						"03864ef025fde8fb587d989186ce6a4a186895ee44a926bfc370e2c366597a3f8f": 5_000_000,
+					},
+				}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Peer cap without maximum amount",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.PeerCap = config.PeerCap{Enabled: true}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Invalid peer cap public key",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.PeerCap = config.PeerCap{
+					Enabled:   true,
+					MaxAmount: 1_000_000,
+					Peers:     map[string]uint64{"peer": 5_000_000},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid pools",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Pools = []config.Pool{
+					{Name: "micro", MaxAmount: 9_999, Capacity: 20, Distribution: []float64{60, 30, 9}},
+					{Name: "standard", MinAmount: 10_000, MaxAmount: 999_999, Capacity: 50},
+					{Name: "whale", MinAmount: 1_000_000, Capacity: 30},
+				}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Overlapping pools",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Pools = []config.Pool{
+					{Name: "micro", MaxAmount: 10_000, Capacity: 50},
+					{Name: "whale", MinAmount: 10_000, Capacity: 50},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Pools capacity above 100",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Pools = []config.Pool{
+					{Name: "micro", MaxAmount: 9_999, Capacity: 60},
+					{Name: "whale", MinAmount: 10_000, Capacity: 60},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Invalid pool distribution",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Pools = []config.Pool{
+					{Name: "micro", Capacity: 100, Distribution: []float64{80, 30}},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid network",
+			getConfig: func(c config.Config) config.Config {
+				c.Lightning.Network = config.NetworkSignet
+				return c
+			},
+		},
+		{
+			desc: "Invalid network",
+			getConfig: func(c config.Config) config.Config {
+				c.Lightning.Network = "testnet3"
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid rounding",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Rounding = "first_prize"
+				return c
+			},
+		},
+		{
+			desc: "Invalid rounding",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Rounding = "ceil"
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid hidden service",
+			getConfig: func(c config.Config) config.Config {
+				c.Tor.ControlAddress = "127.0.0.1:9051"
+				c.Tor.HiddenService = config.HiddenService{
+					Enabled:       true,
+					Port:          80,
+					TargetAddress: "127.0.0.1",
+					KeyPath:       "onion.key",
+				}
+				return c
+			},
+		},
+		{
+			desc: "Hidden service without control address",
+			getConfig: func(c config.Config) config.Config {
+				c.Tor.HiddenService = config.HiddenService{Enabled: true, KeyPath: "onion.key"}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Hidden service without key path",
+			getConfig: func(c config.Config) config.Config {
+				c.Tor.ControlAddress = "127.0.0.1:9051"
+				c.Tor.HiddenService = config.HiddenService{Enabled: true}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Invalid hidden service target address",
+			getConfig: func(c config.Config) config.Config {
+				c.Tor.ControlAddress = "127.0.0.1:9051"
+				c.Tor.HiddenService = config.HiddenService{
+					Enabled:       true,
+					TargetAddress: "localhost",
+					KeyPath:       "onion.key",
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Negative cache TTL",
+			getConfig: func(c config.Config) config.Config {
+				c.API.Cache.Stats = -time.Second
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid collision policy",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Collision = "cascade"
+				return c
+			},
+		},
+		{
+			desc: "Invalid collision policy",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Collision = "skip"
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Signed responses without identity",
+			getConfig: func(c config.Config) config.Config {
+				c.API.Identity.SignResponses = true
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Invalid identity private key",
+			getConfig: func(c config.Config) config.Config {
+				c.API.Identity.PrivateKey = "4ccd089b"
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Invalid swap provider",
+			getConfig: func(c config.Config) config.Config {
+				c.API.Swaps.Provider = "loop"
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Invalid notifier dispatch",
+			getConfig: func(c config.Config) config.Config {
+				c.Notifier.Dispatch.Workers = -1
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Invalid house public key",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.HousePlay.PublicKeys = []string{"npub"}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Invalid milestones",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Milestones.Amounts = []uint64{1_000_000, 500_000}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid adaptive tiers",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.AdaptiveTiers = []config.AdaptiveTier{
+					{Participants: 0, Tiers: 3},
+					{Participants: 10, Tiers: 5},
+					{Participants: 100, Tiers: 8},
+				}
+				return c
+			},
+		},
+		{
+			desc: "Invalid adaptive tiers",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.AdaptiveTiers = []config.AdaptiveTier{
+					{Participants: 100, Tiers: 8},
+					{Participants: 10, Tiers: 5},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid liquidity",
+			getConfig: func(c config.Config) config.Config {
+				c.Liquidity = config.Liquidity{
+					Enabled:       true,
+					Interval:      time.Hour,
+					OutboundRatio: 1.2,
+					ChannelRatio:  0.2,
+					Actions:       []string{"alert", "rebalance"},
+					Budget:        config.LiquidityBudget{Period: 24 * time.Hour, MaxFees: 1000},
+				}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Invalid liquidity action",
+			getConfig: func(c config.Config) config.Config {
+				c.Liquidity = config.Liquidity{
+					Enabled:  true,
+					Interval: time.Hour,
+					Actions:  []string{"open_channel"},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Liquidity actions without budget",
+			getConfig: func(c config.Config) config.Config {
+				c.Liquidity = config.Liquidity{
+					Enabled:  true,
+					Interval: time.Hour,
+					Actions:  []string{"rebalance"},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Loop out without loop configuration",
+			getConfig: func(c config.Config) config.Config {
+				c.Liquidity = config.Liquidity{
+					Enabled:  true,
+					Interval: time.Hour,
+					Actions:  []string{"loop_out"},
+					Budget:   config.LiquidityBudget{Period: 24 * time.Hour, MaxFees: 1000},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "LSP order without LSP",
+			getConfig: func(c config.Config) config.Config {
+				c.Liquidity = config.Liquidity{
+					Enabled:  true,
+					Interval: time.Hour,
+					Actions:  []string{"lsp_order"},
+					Inbound:  config.LiquidityInbound{Window: 24 * time.Hour},
+					Budget:   config.LiquidityBudget{Period: 24 * time.Hour, MaxFees: 1000},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Invalid encryption provider",
+			getConfig: func(c config.Config) config.Config {
+				c.DB.Notifications.Encryption.Provider = "kms"
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Encryption without key file",
+			getConfig: func(c config.Config) config.Config {
+				c.DB.Notifications.Encryption.Provider = "file"
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Invalid winners hub overflow policy",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.WinnersHub.Overflow = "block"
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Negative winners hub replay",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.WinnersHub = config.WinnersHub{Overflow: config.OverflowDropNewest, Replay: -1}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid alerts",
+			getConfig: func(c config.Config) config.Config {
+				c.Alerts = config.Alerts{
+					Enabled:  true,
+					Interval: time.Minute,
+					Channels: config.AlertChannels{
+						PagerDuty:  "https://events.pagerduty.com/v2/enqueue",
+						RoutingKey: "key",
+					},
+					Rules: []config.AlertRule{{Name: "fees", Metric: "routing_fees", Threshold: 1000}},
+				}
+				return c
+			},
+		},
+		{
+			desc: "Alerts without channels",
+			getConfig: func(c config.Config) config.Config {
+				c.Alerts = config.Alerts{Enabled: true, Interval: time.Minute}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Pagerduty without routing key",
+			getConfig: func(c config.Config) config.Config {
+				c.Alerts = config.Alerts{
+					Enabled:  true,
+					Interval: time.Minute,
+					Channels: config.AlertChannels{PagerDuty: "https://events.pagerduty.com/v2/enqueue"},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Invalid alert rule metric",
+			getConfig: func(c config.Config) config.Config {
+				c.Alerts = config.Alerts{
+					Enabled:  true,
+					Interval: time.Minute,
+					Channels: config.AlertChannels{TelegramChatID: 1},
+					Rules:    []config.AlertRule{{Name: "cpu", Metric: "cpu_usage"}},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Duplicate alert rule",
+			getConfig: func(c config.Config) config.Config {
+				c.Alerts = config.Alerts{
+					Enabled:  true,
+					Interval: time.Minute,
+					Channels: config.AlertChannels{Webhook: "https://btry.com/alerts"},
+					Rules: []config.AlertRule{
+						{Name: "errors", Metric: "errors"},
+						{Name: "errors", Metric: "errors", Label: "DB"},
+					},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Negative cancellation window",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Cancellation.Window = -time.Minute
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Invalid cancellation fee",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Cancellation = config.Cancellation{Window: 10 * time.Minute, Fee: 100}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Fee destinations",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.FeeDestinations = []config.FeeDestination{
+					{Name: "charity", Address: "charity@example.com", Percentage: 40},
+					{Name: "dev", Address: "dev@example.com", Percentage: 60},
+				}
+				return c
+			},
+		},
+		{
+			desc: "Fee destinations above 100%",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.FeeDestinations = []config.FeeDestination{
+					{Name: "charity", Address: "charity@example.com", Percentage: 50},
+					{Name: "dev", Address: "dev@example.com", Percentage: 60},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Duplicated fee destination",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.FeeDestinations = []config.FeeDestination{
+					{Name: "dev", Address: "charity@example.com", Percentage: 10},
+					{Name: "dev", Address: "dev@example.com", Percentage: 10},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Fee destination without address",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.FeeDestinations = []config.FeeDestination{{Name: "dev", Percentage: 10}}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Negative claim codes expiry",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.ClaimCodes.Expiry = -time.Hour
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Negative approvals expiry",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Approvals = config.Approvals{Threshold: 1_000_000, Expiry: -time.Hour}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Payouts fee ceilings",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Payouts = config.Payouts{FeeCeilingPPM: 5_000, FeeCeilingSat: 100, UrgentBlocks: 72}
+				return c
+			},
+		},
+		{
+			desc: "Negative payouts fee ceiling",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Payouts = config.Payouts{FeeCeilingPPM: -1}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Payouts route probes",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Payouts = config.Payouts{ProbeThreshold: 500_000, ProbeMinSuccess: 0.5, ProbeAttempts: 2}
+				return c
+			},
+		},
+		{
+			desc: "Invalid payouts probe minimum success",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Payouts = config.Payouts{ProbeThreshold: 500_000, ProbeMinSuccess: 1.5}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid draw SLO",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.DrawSLO = config.DrawSLO{Objective: 5 * time.Second, Target: 99.9, Window: 100}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Invalid draw SLO target",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.DrawSLO = config.DrawSLO{Target: 150}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid capacity",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Capacity = config.Capacity{Divisor: 10, Override: 2_000_000}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Negative capacity divisor",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Capacity = config.Capacity{Divisor: -1}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Liability capacity",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Capacity = config.Capacity{Mode: "liability", Divisor: 10}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Invalid capacity mode",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Capacity = config.Capacity{Mode: "unknown"}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Fixed capacity without override",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Capacity = config.Capacity{Mode: "fixed"}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Capacity override not used",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Capacity = config.Capacity{Mode: "remote_balance", Override: 2_000_000}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid rates",
+			getConfig: func(c config.Config) config.Config {
+				c.Rates = config.Rates{
+					Enabled:    true,
+					Providers:  []config.RateProvider{{Name: "mempool"}, {Name: "coingecko", URL: "http://localhost:8080"}},
+					Currencies: []string{"USD", "EUR"},
+				}
+				return c
+			},
+		},
+		{
+			desc: "Invalid rates provider",
+			getConfig: func(c config.Config) config.Config {
+				c.Rates = config.Rates{
+					Enabled:    true,
+					Providers:  []config.RateProvider{{Name: "kraken"}},
+					Currencies: []string{"USD"},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Invalid rates currency",
+			getConfig: func(c config.Config) config.Config {
+				c.Rates = config.Rates{
+					Enabled:    true,
+					Providers:  []config.RateProvider{{Name: "mempool"}},
+					Currencies: []string{"dollar"},
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid watchdog",
+			getConfig: func(c config.Config) config.Config {
+				c.Watchdog = config.Watchdog{
+					Enabled:      true,
+					Source:       "esplora",
+					URL:          "https://mempool.space/api",
+					Interval:     time.Minute,
+					StallTimeout: 2 * time.Hour,
+				}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Invalid watchdog source",
+			getConfig: func(c config.Config) config.Config {
+				c.Watchdog = config.Watchdog{
+					Enabled:      true,
+					Source:       "electrum",
+					URL:          "https://mempool.space/api",
+					Interval:     time.Minute,
+					StallTimeout: 2 * time.Hour,
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Watchdog without stall timeout",
+			getConfig: func(c config.Config) config.Config {
+				c.Watchdog = config.Watchdog{
+					Enabled:  true,
+					Source:   "bitcoind",
+					URL:      "http://127.0.0.1:8332",
+					Interval: time.Minute,
+				}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Valid audit private key",
+			getConfig: func(c config.Config) config.Config {
+				c.Audit.Enabled = true
+				c.Audit.PrivateKey = "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb"
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Invalid audit private key",
+			getConfig: func(c config.Config) config.Config {
+				c.Audit.Enabled = true
+				c.Audit.PrivateKey = "4ccd089b"
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Chaos",
+			getConfig: func(c config.Config) config.Config {
+				c.Chaos = config.Chaos{Enabled: true, MaxDelay: time.Second, Delay: 0.1, FailWrite: 1}
+				return c
+			},
+			fail: false,
+		},
+		{
+			desc: "Invalid chaos probability",
+			getConfig: func(c config.Config) config.Config {
+				c.Chaos = config.Chaos{Enabled: true, DropBlock: 1.5}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Chaos delay without max delay",
+			getConfig: func(c config.Config) config.Config {
+				c.Chaos = config.Chaos{Enabled: true, Delay: 0.5}
+				return c
+			},
+			fail: true,
+		},
 		{
 			desc: "Invalid address",
 			getConfig: func(c config.Config) config.Config {
@@ -101,3 +1179,126 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestClaimWindowBlocks(t *testing.T) {
+	cases := []struct {
+		desc     string
+		config   config.Lottery
+		expected uint32
+	}{
+		{
+			desc:     "Default",
+			config:   config.Lottery{Duration: 144},
+			expected: 720,
+		},
+		{
+			desc:     "Blocks",
+			config:   config.Lottery{Duration: 144, ClaimWindow: config.ClaimWindow{Blocks: 300}},
+			expected: 300,
+		},
+		{
+			desc:     "Days",
+			config:   config.Lottery{Duration: 144, ClaimWindow: config.ClaimWindow{Days: 2}},
+			expected: 288,
+		},
+		{
+			desc:     "Frequency",
+			config:   config.Lottery{Frequency: time.Hour},
+			expected: 30,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.config.ClaimWindowBlocks())
+		})
+	}
+}
+
+func TestReloadable(t *testing.T) {
+	current := config.Config{
+		Lottery: config.Lottery{
+			Duration: 144,
+			Pools:    []config.Pool{{Name: "micro", Capacity: 100, Distribution: []float64{50}}},
+			PeerCap:  config.PeerCap{Enabled: true, MaxAmount: 10_000},
+		},
+		Notifier: config.Notifier{Enabled: true},
+		Server:   config.Server{Address: "127.0.0.1:4000"},
+	}
+
+	cases := []struct {
+		getConfig func(c config.Config) config.Config
+		desc      string
+		fail      bool
+	}{
+		{
+			desc: "Unchanged",
+			getConfig: func(c config.Config) config.Config {
+				return c
+			},
+		},
+		{
+			desc: "Reloadable settings",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Logger.Level = 1
+				c.Lottery.Pools = []config.Pool{{Name: "micro", Capacity: 100, Distribution: []float64{60, 30}}}
+				c.Lottery.Limits.Cooldown = time.Hour
+				c.Lottery.Cancellation = config.Cancellation{Window: 10 * time.Minute, Fee: 2}
+				c.Lottery.PeerCap.MaxAmount = 20_000
+				c.Lottery.Approvals = config.Approvals{Threshold: 1_000_000, Expiry: time.Hour}
+				c.Lottery.Bonus = config.Bonus{Bundles: []config.Bundle{{MinAmount: 100, Percentage: 10}}}
+				c.API.Maintenance.Until = time.Unix(1_800_000_000, 0)
+				c.Notifier.Telegram.BotAPIToken = "token"
+				c.Notifier.Nostr.Relays = []string{"wss://relay.example"}
+				c.Chaos = config.Chaos{Enabled: true, LightningError: 0.2}
+				return c
+			},
+		},
+		{
+			desc: "Lottery duration",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Duration = 100
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Pool range",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.Pools = []config.Pool{{Name: "micro", Capacity: 100, MaxAmount: 1000}}
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Peer cap disabled",
+			getConfig: func(c config.Config) config.Config {
+				c.Lottery.PeerCap.Enabled = false
+				return c
+			},
+			fail: true,
+		},
+		{
+			desc: "Logger label",
+			getConfig: func(c config.Config) config.Config {
+				c.Server.Logger.Label = "HTTP"
+				return c
+			},
+			fail: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := current.Reloadable(tc.getConfig(current))
+			if tc.fail {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	// The configuration compared is not modified
+	assert.Equal(t, []float64{50}, current.Lottery.Pools[0].Distribution)
+}
@@ -0,0 +1,145 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"strings"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/pkg/errors"
+)
+
+// keySize is the size of the AES-256 keys read from the key file.
+const keySize = 32
+
+// ErrNotEncrypted is returned when decrypting a value that wasn't encrypted by the cipher, like the
+// ones stored before enabling the encryption.
+var ErrNotEncrypted = errors.New("value not encrypted")
+
+// Cipher encrypts the values stored at rest.
+type Cipher interface {
+	Encrypt(plaintext []byte) (string, error)
+	// Decrypt returns ErrNotEncrypted if the ciphertext isn't in the format of the cipher
+	Decrypt(ciphertext string) ([]byte, error)
+	// Current returns whether the ciphertext was encrypted with the current key, values encrypted
+	// with previous keys should be encrypted again
+	Current(ciphertext string) bool
+}
+
+// NewCipher returns the cipher of the provider configured, or nil if the encryption is disabled.
+func NewCipher(config config.Encryption) (Cipher, error) {
+	switch config.Provider {
+	case "":
+		return nil, nil
+	case "file":
+		return newKeyFileCipher(config.KeyFile)
+	case "vault":
+		return newVaultCipher(config.Vault)
+	default:
+		return nil, errors.Errorf("invalid encryption provider %q", config.Provider)
+	}
+}
+
+// keyFileCipher encrypts with AES-256-GCM. Ciphertexts are prefixed with the ID of their key, so
+// values encrypted with previous keys can still be decrypted after adding a new one.
+type keyFileCipher struct {
+	keys      map[string]cipher.AEAD
+	currentID string
+}
+
+func newKeyFileCipher(path string) (*keyFileCipher, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading encryption key file")
+	}
+
+	c := &keyFileCipher{keys: make(map[string]cipher.AEAD)}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, err := hex.DecodeString(line)
+		if err != nil || len(key) != keySize {
+			return nil, errors.Errorf("invalid encryption key, must be %d hex encoded bytes", keySize)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating block cipher")
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating GCM cipher")
+		}
+
+		id := keyID(key)
+		c.keys[id] = aead
+		c.currentID = id
+	}
+
+	if c.currentID == "" {
+		return nil, errors.New("no encryption keys found")
+	}
+
+	return c, nil
+}
+
+// Encrypt returns the plaintext encrypted with the last key of the file.
+func (c *keyFileCipher) Encrypt(plaintext []byte) (string, error) {
+	aead := c.keys[c.currentID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", errors.Wrap(err, "generating nonce")
+	}
+
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return c.currentID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plaintext of a value encrypted with any of the keys of the file.
+func (c *keyFileCipher) Decrypt(ciphertext string) ([]byte, error) {
+	id, encoded, ok := strings.Cut(ciphertext, ":")
+	if !ok {
+		return nil, ErrNotEncrypted
+	}
+
+	aead, ok := c.keys[id]
+	if !ok {
+		return nil, errors.Errorf("encryption key %s not found", id)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding ciphertext")
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("invalid ciphertext length")
+	}
+
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypting value")
+	}
+
+	return plaintext, nil
+}
+
+// Current returns whether the ciphertext was encrypted with the last key of the file.
+func (c *keyFileCipher) Current(ciphertext string) bool {
+	return strings.HasPrefix(ciphertext, c.currentID+":")
+}
+
+// keyID returns the first 4 bytes of the hash of the key, hex encoded.
+func keyID(key []byte) string {
+	hash := sha256.Sum256(key)
+	return hex.EncodeToString(hash[:4])
+}
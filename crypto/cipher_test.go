@@ -0,0 +1,134 @@
+package crypto_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/crypto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	encryptionKey  = "8ad8c9f4e5c5b0f0e4d5b1c9a3c5f1a4b2e6d7c8f9a0b1c2d3e4f5a6b7c8d9e0"
+	encryptionKey2 = "1f2e3d4c5b6a79880f1e2d3c4b5a69780a1b2c3d4e5f60718293a4b5c6d7e8f9"
+)
+
+func TestKeyFileCipher(t *testing.T) {
+	cipher := newKeyFileCipher(t, "# Previous keys first\n"+encryptionKey+"\n")
+
+	ciphertext, err := cipher.Encrypt([]byte("505"))
+	assert.NoError(t, err)
+	assert.NotContains(t, ciphertext, "505")
+	assert.True(t, cipher.Current(ciphertext))
+
+	plaintext, err := cipher.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "505", string(plaintext))
+
+	_, err = cipher.Decrypt("505")
+	assert.ErrorIs(t, err, crypto.ErrNotEncrypted)
+
+	// Rotate the key, values encrypted with the previous one can still be decrypted
+	rotated := newKeyFileCipher(t, encryptionKey+"\n"+encryptionKey2)
+	assert.False(t, rotated.Current(ciphertext))
+
+	plaintext, err = rotated.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "505", string(plaintext))
+
+	// Removed keys can't decrypt
+	removed := newKeyFileCipher(t, encryptionKey2)
+	_, err = removed.Decrypt(ciphertext)
+	assert.Error(t, err)
+}
+
+func TestKeyFileCipherInvalid(t *testing.T) {
+	cases := []struct {
+		desc    string
+		content string
+	}{
+		{desc: "Empty", content: "# No keys"},
+		{desc: "Invalid length", content: "8ad8c9f4"},
+		{desc: "Invalid encoding", content: strings.Repeat("z", 64)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys")
+			assert.NoError(t, os.WriteFile(path, []byte(tc.content), 0o600))
+
+			_, err := crypto.NewCipher(config.Encryption{Provider: "file", KeyFile: path})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestVaultCipher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token", r.Header.Get("X-Vault-Token"))
+
+		var req map[string]string
+		if r.Method == http.MethodPost {
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		}
+
+		switch r.URL.Path {
+		case "/v1/transit/keys/btry":
+			w.Write([]byte(`{"data":{"latest_version":2}}`))
+		case "/v1/transit/encrypt/btry":
+			w.Write([]byte(`{"data":{"ciphertext":"vault:v2:` + req["plaintext"] + `"}}`))
+		case "/v1/transit/decrypt/btry":
+			_, plaintext, _ := strings.Cut(strings.TrimPrefix(req["ciphertext"], "vault:v"), ":")
+			w.Write([]byte(`{"data":{"plaintext":"` + plaintext + `"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":["no handler for route"]}`))
+		}
+	}))
+	defer server.Close()
+
+	cipher, err := crypto.NewCipher(config.Encryption{
+		Provider: "vault",
+		Vault:    config.Vault{Address: server.URL, Token: "token", Key: "btry"},
+	})
+	assert.NoError(t, err)
+
+	ciphertext, err := cipher.Encrypt([]byte("505"))
+	assert.NoError(t, err)
+	assert.True(t, cipher.Current(ciphertext))
+
+	plaintext, err := cipher.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "505", string(plaintext))
+
+	previous := "vault:v1:" + base64.StdEncoding.EncodeToString([]byte("505"))
+	assert.False(t, cipher.Current(previous))
+
+	_, err = cipher.Decrypt("505")
+	assert.ErrorIs(t, err, crypto.ErrNotEncrypted)
+
+	_, err = crypto.NewCipher(config.Encryption{
+		Provider: "vault",
+		Vault:    config.Vault{Address: server.URL, Token: "token", Mount: "kv", Key: "btry"},
+	})
+	assert.ErrorContains(t, err, "no handler for route")
+}
+
+func newKeyFileCipher(t *testing.T, content string) crypto.Cipher {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "keys")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	cipher, err := crypto.NewCipher(config.Encryption{Provider: "file", KeyFile: path})
+	assert.NoError(t, err)
+	return cipher
+}
@@ -0,0 +1,86 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/pkg/errors"
+)
+
+// Headers of the signed responses.
+const (
+	TimestampHeader = "X-BTRY-Timestamp"
+	SignatureHeader = "X-BTRY-Signature"
+)
+
+// responseDomain separates the responses signatures from the ones of other statements.
+const responseDomain = "btry-response-v1"
+
+// Identity is the key the server proves it's the origin of its responses with.
+type Identity struct {
+	privateKey ed25519.PrivateKey
+}
+
+// NewIdentity returns the server identity, or nil if there's no key configured.
+func NewIdentity(config config.Identity) (*Identity, error) {
+	if config.PrivateKey == "" {
+		return nil, nil
+	}
+
+	seed, err := hex.DecodeString(config.PrivateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding identity private key")
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.New("invalid identity private key length")
+	}
+
+	return &Identity{privateKey: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+// PublicKey returns the hex-encoded key used to verify the responses signatures.
+func (i *Identity) PublicKey() string {
+	return hex.EncodeToString(i.privateKey.Public().(ed25519.PublicKey))
+}
+
+// SignResponse returns the signature of the response body sent at the timestamp, which is
+// included so clients can reject stale responses replayed to them.
+func (i *Identity) SignResponse(timestamp string, body []byte) string {
+	return hex.EncodeToString(ed25519.Sign(i.privateKey, responseHash(timestamp, body)))
+}
+
+// VerifyResponse checks that the response body was signed at the timestamp by the owner of the
+// public key.
+func VerifyResponse(publicKey, timestamp string, body []byte, signature string) error {
+	pubKey, err := hex.DecodeString(publicKey)
+	if err != nil {
+		return errors.Wrap(err, "decoding public key")
+	}
+	if len(pubKey) != ed25519.PublicKeySize {
+		return errors.New("invalid public key length")
+	}
+
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return errors.Wrap(err, "decoding signature")
+	}
+
+	if !ed25519.Verify(pubKey, responseHash(timestamp, body), sig) {
+		return errors.New("invalid signature")
+	}
+
+	return nil
+}
+
+func responseHash(timestamp string, body []byte) []byte {
+	hash := sha256.New()
+	hash.Write([]byte(responseDomain))
+	hash.Write([]byte{0})
+	hash.Write([]byte(timestamp))
+	hash.Write([]byte{'.'})
+	hash.Write(body)
+	return hash.Sum(nil)
+}
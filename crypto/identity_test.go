@@ -0,0 +1,37 @@
+package crypto_test
+
+import (
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/crypto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentity(t *testing.T) {
+	identity, err := crypto.NewIdentity(config.Identity{
+		PrivateKey: "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb",
+	})
+	assert.NoError(t, err)
+
+	publicKey := identity.PublicKey()
+	assert.NoError(t, crypto.ValidatePublicKey(publicKey))
+
+	body := []byte(`{"next_height":144}`)
+	signature := identity.SignResponse("1231006505", body)
+	assert.NoError(t, crypto.VerifyResponse(publicKey, "1231006505", body, signature))
+
+	assert.Error(t, crypto.VerifyResponse(publicKey, "1231006506", body, signature))
+	assert.Error(t, crypto.VerifyResponse(publicKey, "1231006505", []byte(`{"next_height":145}`), signature))
+	assert.Error(t, crypto.VerifyResponse("4ccd089b", "1231006505", body, signature))
+}
+
+func TestNewIdentity(t *testing.T) {
+	identity, err := crypto.NewIdentity(config.Identity{})
+	assert.NoError(t, err)
+	assert.Nil(t, identity)
+
+	_, err = crypto.NewIdentity(config.Identity{PrivateKey: "4ccd089b"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,139 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/pkg/errors"
+)
+
+// vaultPrefix is the prefix of the ciphertexts returned by the Vault transit engine.
+const vaultPrefix = "vault:v"
+
+// vaultCipher encrypts with a HashiCorp Vault transit secrets engine, the key never leaves Vault.
+// Keys are rotated in Vault, values encrypted with previous versions are still decrypted.
+type vaultCipher struct {
+	client  *http.Client
+	address string
+	token   string
+	mount   string
+	key     string
+	// latestVersion is the version of the key when the cipher was created
+	latestVersion int
+}
+
+func newVaultCipher(config config.Vault) (*vaultCipher, error) {
+	mount := config.Mount
+	if mount == "" {
+		mount = "transit"
+	}
+
+	c := &vaultCipher{
+		client:  &http.Client{Timeout: 30 * time.Second},
+		address: strings.TrimSuffix(config.Address, "/"),
+		token:   config.Token,
+		mount:   strings.Trim(mount, "/"),
+		key:     config.Key,
+	}
+
+	var resp struct {
+		Data struct {
+			LatestVersion int `json:"latest_version"`
+		} `json:"data"`
+	}
+	if err := c.do(http.MethodGet, "keys", nil, &resp); err != nil {
+		return nil, errors.Wrap(err, "reading vault key")
+	}
+	c.latestVersion = resp.Data.LatestVersion
+
+	return c, nil
+}
+
+// Encrypt returns the plaintext encrypted with the latest version of the key.
+func (c *vaultCipher) Encrypt(plaintext []byte) (string, error) {
+	req := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	if err := c.do(http.MethodPost, "encrypt", req, &resp); err != nil {
+		return "", errors.Wrap(err, "encrypting value")
+	}
+
+	return resp.Data.Ciphertext, nil
+}
+
+// Decrypt returns the plaintext of a value encrypted with any version of the key.
+func (c *vaultCipher) Decrypt(ciphertext string) ([]byte, error) {
+	if !strings.HasPrefix(ciphertext, vaultPrefix) {
+		return nil, ErrNotEncrypted
+	}
+
+	req := map[string]string{"ciphertext": ciphertext}
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := c.do(http.MethodPost, "decrypt", req, &resp); err != nil {
+		return nil, errors.Wrap(err, "decrypting value")
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding plaintext")
+	}
+
+	return plaintext, nil
+}
+
+// Current returns whether the ciphertext was encrypted with the latest version of the key.
+func (c *vaultCipher) Current(ciphertext string) bool {
+	return strings.HasPrefix(ciphertext, vaultPrefix+strconv.Itoa(c.latestVersion)+":")
+}
+
+func (c *vaultCipher) do(method, operation string, body, dst any) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return errors.Wrap(err, "encoding request")
+		}
+	}
+
+	url := c.address + "/v1/" + c.mount + "/" + operation + "/" + c.key
+	req, err := http.NewRequest(method, url, &reqBody)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("X-Vault-Token", c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&vaultErr)
+		return errors.Errorf("vault returned status %d: %s", resp.StatusCode,
+			strings.Join(vaultErr.Errors, ", "))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(dst); err != nil {
+		return errors.Wrap(err, "decoding response")
+	}
+
+	return nil
+}
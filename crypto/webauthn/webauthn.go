@@ -0,0 +1,297 @@
+// Package webauthn verifies WebAuthn (passkeys) registration and authentication ceremonies.
+//
+// Only the "none" attestation format and the ES256 and EdDSA algorithms are supported, which is
+// enough to authenticate the operators with their passkeys without trusting the authenticators
+// vendors.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// COSE algorithms identifiers
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+)
+
+// Ceremony types found in the client data
+const (
+	typeCreate = "webauthn.create"
+	typeGet    = "webauthn.get"
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent      byte = 1 << 0
+	flagUserVerified     byte = 1 << 2
+	flagAttestedCredData byte = 1 << 6
+)
+
+// authDataMinLength is the length of the rp ID hash, the flags and the signature counter.
+const authDataMinLength = sha256.Size + 1 + 4
+
+// Encoding is used for all the binary values exchanged with the browser.
+var Encoding = base64.RawURLEncoding
+
+// RelyingParty is the service the operators authenticate against.
+type RelyingParty struct {
+	ID     string
+	Origin string
+}
+
+// Credential is a public key registered by an authenticator.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type authenticatorData struct {
+	credentialID []byte
+	publicKey    []byte
+	rpIDHash     []byte
+	signCount    uint32
+	flags        byte
+}
+
+// Challenge returns the challenge contained in the client data.
+func Challenge(clientDataJSON []byte) ([]byte, error) {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return nil, errors.Wrap(err, "decoding client data")
+	}
+
+	challenge, err := Encoding.DecodeString(data.Challenge)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding challenge")
+	}
+
+	return challenge, nil
+}
+
+// VerifyRegistration validates the response to a credential creation request and returns the new
+// credential.
+func (rp RelyingParty) VerifyRegistration(
+	challenge, clientDataJSON, attestationObject []byte,
+) (Credential, error) {
+	if err := rp.verifyClientData(typeCreate, challenge, clientDataJSON); err != nil {
+		return Credential{}, err
+	}
+
+	obj, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return Credential{}, errors.Wrap(err, "decoding attestation object")
+	}
+	attestation, ok := obj.(map[any]any)
+	if !ok {
+		return Credential{}, errors.New("invalid attestation object")
+	}
+
+	if format, _ := attestation["fmt"].(string); format != "none" {
+		return Credential{}, errors.Errorf("unsupported attestation format %q", format)
+	}
+
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return Credential{}, errors.New("invalid authenticator data")
+	}
+
+	authData, err := rp.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	if authData.flags&flagAttestedCredData == 0 {
+		return Credential{}, errors.New("missing attested credential data")
+	}
+
+	if _, err := parsePublicKey(authData.publicKey); err != nil {
+		return Credential{}, err
+	}
+
+	return Credential{
+		ID:        authData.credentialID,
+		PublicKey: authData.publicKey,
+		SignCount: authData.signCount,
+	}, nil
+}
+
+// VerifyAssertion validates the response to an authentication request signed by the credential
+// and returns the updated signature counter.
+func (rp RelyingParty) VerifyAssertion(
+	credential Credential,
+	challenge, clientDataJSON, rawAuthData, signature []byte,
+) (uint32, error) {
+	if err := rp.verifyClientData(typeGet, challenge, clientDataJSON); err != nil {
+		return 0, err
+	}
+
+	authData, err := rp.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+
+	// A counter that doesn't increase may indicate a cloned authenticator. Authenticators that
+	// don't implement it always return zero
+	if (authData.signCount != 0 || credential.SignCount != 0) &&
+		authData.signCount <= credential.SignCount {
+		return 0, errors.New("invalid signature counter")
+	}
+
+	publicKey, err := parsePublicKey(credential.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	message := append(append([]byte(nil), rawAuthData...), clientDataHash[:]...)
+
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return 0, errors.New("invalid signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, message, signature) {
+			return 0, errors.New("invalid signature")
+		}
+	}
+
+	return authData.signCount, nil
+}
+
+func (rp RelyingParty) verifyClientData(ceremonyType string, challenge, clientDataJSON []byte) error {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return errors.Wrap(err, "decoding client data")
+	}
+
+	if data.Type != ceremonyType {
+		return errors.Errorf("invalid ceremony type %q", data.Type)
+	}
+
+	dataChallenge, err := Encoding.DecodeString(data.Challenge)
+	if err != nil {
+		return errors.Wrap(err, "decoding challenge")
+	}
+	if subtle.ConstantTimeCompare(dataChallenge, challenge) != 1 {
+		return errors.New("invalid challenge")
+	}
+
+	if data.Origin != rp.Origin {
+		return errors.Errorf("invalid origin %q", data.Origin)
+	}
+
+	return nil
+}
+
+func (rp RelyingParty) parseAuthenticatorData(data []byte) (authenticatorData, error) {
+	if len(data) < authDataMinLength {
+		return authenticatorData{}, errors.New("authenticator data too short")
+	}
+
+	authData := authenticatorData{
+		rpIDHash:  data[:sha256.Size],
+		flags:     data[sha256.Size],
+		signCount: binary.BigEndian.Uint32(data[sha256.Size+1 : authDataMinLength]),
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return authenticatorData{}, errors.New("invalid relying party ID hash")
+	}
+
+	if authData.flags&flagUserPresent == 0 {
+		return authenticatorData{}, errors.New("user not present")
+	}
+	if authData.flags&flagUserVerified == 0 {
+		return authenticatorData{}, errors.New("user not verified")
+	}
+
+	if authData.flags&flagAttestedCredData == 0 {
+		return authData, nil
+	}
+
+	// AAGUID (16 bytes) followed by the credential ID length (2 bytes)
+	rest := data[authDataMinLength:]
+	if len(rest) < 18 {
+		return authenticatorData{}, errors.New("attested credential data too short")
+	}
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < idLength {
+		return authenticatorData{}, errors.New("invalid credential ID length")
+	}
+	authData.credentialID = rest[:idLength]
+	rest = rest[idLength:]
+
+	// The public key is followed by the extensions, if any
+	_, extensions, err := decodeCBOR(rest)
+	if err != nil {
+		return authenticatorData{}, errors.Wrap(err, "decoding credential public key")
+	}
+	authData.publicKey = rest[:len(rest)-len(extensions)]
+
+	return authData, nil
+}
+
+// parsePublicKey decodes a COSE encoded public key.
+func parsePublicKey(coseKey []byte) (any, error) {
+	obj, _, err := decodeCBOR(coseKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding public key")
+	}
+	key, ok := obj.(map[any]any)
+	if !ok {
+		return nil, errors.New("invalid public key")
+	}
+
+	alg, _ := key[int64(3)].(int64)
+	switch alg {
+	case AlgES256:
+		x, okX := key[int64(-2)].([]byte)
+		y, okY := key[int64(-3)].([]byte)
+		if crv, _ := key[int64(-1)].(int64); crv != 1 || !okX || !okY {
+			return nil, errors.New("invalid ES256 public key")
+		}
+
+		publicKey := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, errors.New("public key is not on the curve")
+		}
+		return publicKey, nil
+
+	case AlgEdDSA:
+		x, ok := key[int64(-2)].([]byte)
+		if crv, _ := key[int64(-1)].(int64); crv != 6 || !ok || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid EdDSA public key")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, errors.Errorf("unsupported public key algorithm %d", alg)
+	}
+}
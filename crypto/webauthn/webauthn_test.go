@@ -0,0 +1,293 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var rp = RelyingParty{ID: "btry.example", Origin: "https://btry.example"}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	cases := []struct {
+		desc string
+		alg  int64
+	}{
+		{desc: "ES256", alg: AlgES256},
+		{desc: "EdDSA", alg: AlgEdDSA},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			auth := newAuthenticator(t, tc.alg)
+			challenge := []byte("registration challenge")
+
+			clientDataJSON := auth.clientData(typeCreate, challenge, rp.Origin)
+			credential, err := rp.VerifyRegistration(challenge, clientDataJSON, auth.attestation(rp.ID))
+			assert.NoError(t, err)
+			assert.Equal(t, auth.id, credential.ID)
+
+			gotChallenge, err := Challenge(clientDataJSON)
+			assert.NoError(t, err)
+			assert.Equal(t, challenge, gotChallenge)
+
+			challenge = []byte("login challenge")
+			clientDataJSON = auth.clientData(typeGet, challenge, rp.Origin)
+			authData, signature := auth.sign(rp.ID, clientDataJSON)
+
+			signCount, err := rp.VerifyAssertion(credential, challenge, clientDataJSON, authData, signature)
+			assert.NoError(t, err)
+			assert.Equal(t, auth.counter, signCount)
+
+			t.Run("Replayed counter", func(t *testing.T) {
+				credential.SignCount = signCount
+				_, err := rp.VerifyAssertion(credential, challenge, clientDataJSON, authData, signature)
+				assert.Error(t, err)
+			})
+		})
+	}
+}
+
+func TestVerifyRegistrationErrors(t *testing.T) {
+	auth := newAuthenticator(t, AlgES256)
+	challenge := []byte("challenge")
+
+	cases := []struct {
+		desc              string
+		clientDataJSON    []byte
+		attestationObject []byte
+	}{
+		{
+			desc:              "Wrong challenge",
+			clientDataJSON:    auth.clientData(typeCreate, []byte("other"), rp.Origin),
+			attestationObject: auth.attestation(rp.ID),
+		},
+		{
+			desc:              "Wrong type",
+			clientDataJSON:    auth.clientData(typeGet, challenge, rp.Origin),
+			attestationObject: auth.attestation(rp.ID),
+		},
+		{
+			desc:              "Wrong origin",
+			clientDataJSON:    auth.clientData(typeCreate, challenge, "https://evil.example"),
+			attestationObject: auth.attestation(rp.ID),
+		},
+		{
+			desc:              "Wrong relying party",
+			clientDataJSON:    auth.clientData(typeCreate, challenge, rp.Origin),
+			attestationObject: auth.attestation("evil.example"),
+		},
+		{
+			desc:              "Invalid attestation object",
+			clientDataJSON:    auth.clientData(typeCreate, challenge, rp.Origin),
+			attestationObject: []byte{0xff},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := rp.VerifyRegistration(challenge, tc.clientDataJSON, tc.attestationObject)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestVerifyAssertionInvalidSignature(t *testing.T) {
+	auth := newAuthenticator(t, AlgES256)
+	other := newAuthenticator(t, AlgES256)
+	challenge := []byte("challenge")
+
+	credential, err := rp.VerifyRegistration(
+		challenge,
+		auth.clientData(typeCreate, challenge, rp.Origin),
+		auth.attestation(rp.ID),
+	)
+	assert.NoError(t, err)
+
+	clientDataJSON := other.clientData(typeGet, challenge, rp.Origin)
+	authData, signature := other.sign(rp.ID, clientDataJSON)
+
+	_, err = rp.VerifyAssertion(credential, challenge, clientDataJSON, authData, signature)
+	assert.Error(t, err)
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	data := encodeCBOR(map[any]any{
+		"a":       int64(-25),
+		int64(1):  []byte{1, 2},
+		"list":    []any{int64(500), "text"},
+		int64(-3): int64(1 << 40),
+	})
+	data = append(data, 0xaa)
+
+	obj, rest, err := decodeCBOR(data)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xaa}, rest)
+	assert.Equal(t, map[any]any{
+		"a":       int64(-25),
+		int64(1):  []byte{1, 2},
+		"list":    []any{int64(500), "text"},
+		int64(-3): int64(1 << 40),
+	}, obj)
+
+	t.Run("Truncated", func(t *testing.T) {
+		_, _, err := decodeCBOR(data[:5])
+		assert.Error(t, err)
+	})
+
+	t.Run("Huge length", func(t *testing.T) {
+		_, _, err := decodeCBOR([]byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+		assert.Error(t, err)
+	})
+}
+
+type authenticator struct {
+	t          *testing.T
+	ecdsaKey   *ecdsa.PrivateKey
+	ed25519Key ed25519.PrivateKey
+	id         []byte
+	counter    uint32
+}
+
+func newAuthenticator(t *testing.T, alg int64) *authenticator {
+	t.Helper()
+
+	auth := &authenticator{t: t, id: make([]byte, 16)}
+	_, err := rand.Read(auth.id)
+	assert.NoError(t, err)
+
+	switch alg {
+	case AlgES256:
+		auth.ecdsaKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, auth.ed25519Key, err = ed25519.GenerateKey(rand.Reader)
+	}
+	assert.NoError(t, err)
+
+	return auth
+}
+
+func (a *authenticator) clientData(ceremonyType string, challenge []byte, origin string) []byte {
+	data, err := json.Marshal(clientData{
+		Type:      ceremonyType,
+		Challenge: Encoding.EncodeToString(challenge),
+		Origin:    origin,
+	})
+	assert.NoError(a.t, err)
+	return data
+}
+
+func (a *authenticator) coseKey() []byte {
+	if a.ecdsaKey != nil {
+		return encodeCBOR(map[any]any{
+			int64(1):  int64(2),
+			int64(3):  AlgES256,
+			int64(-1): int64(1),
+			int64(-2): a.ecdsaKey.X.FillBytes(make([]byte, 32)),
+			int64(-3): a.ecdsaKey.Y.FillBytes(make([]byte, 32)),
+		})
+	}
+
+	return encodeCBOR(map[any]any{
+		int64(1):  int64(1),
+		int64(3):  AlgEdDSA,
+		int64(-1): int64(6),
+		int64(-2): []byte(a.ed25519Key.Public().(ed25519.PublicKey)),
+	})
+}
+
+func (a *authenticator) authData(rpID string, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte(nil), rpIDHash[:]...)
+
+	flags := flagUserPresent | flagUserVerified
+	if attested {
+		flags |= flagAttestedCredData
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.counter)
+
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.id)))
+		data = append(data, a.id...)
+		data = append(data, a.coseKey()...)
+	}
+
+	return data
+}
+
+func (a *authenticator) attestation(rpID string) []byte {
+	return encodeCBOR(map[any]any{
+		"fmt":      "none",
+		"attStmt":  map[any]any{},
+		"authData": a.authData(rpID, true),
+	})
+}
+
+func (a *authenticator) sign(rpID string, clientDataJSON []byte) ([]byte, []byte) {
+	a.counter++
+	authData := a.authData(rpID, false)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	message := append(append([]byte(nil), authData...), clientDataHash[:]...)
+
+	if a.ecdsaKey != nil {
+		digest := sha256.Sum256(message)
+		signature, err := ecdsa.SignASN1(rand.Reader, a.ecdsaKey, digest[:])
+		assert.NoError(a.t, err)
+		return authData, signature
+	}
+
+	return authData, ed25519.Sign(a.ed25519Key, message)
+}
+
+func encodeCBOR(v any) []byte {
+	header := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n <= 0xff:
+			return []byte{major<<5 | 24, byte(n)}
+		case n <= 0xffff:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		case n <= 0xffffffff:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+		default:
+			return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+		}
+	}
+
+	switch value := v.(type) {
+	case int64:
+		if value < 0 {
+			return header(cborNegInt, uint64(-1-value))
+		}
+		return header(cborUint, uint64(value))
+	case []byte:
+		return append(header(cborBytes, uint64(len(value))), value...)
+	case string:
+		return append(header(cborText, uint64(len(value))), value...)
+	case []any:
+		data := header(cborArray, uint64(len(value)))
+		for _, item := range value {
+			data = append(data, encodeCBOR(item)...)
+		}
+		return data
+	case map[any]any:
+		data := header(cborMap, uint64(len(value)))
+		for key, item := range value {
+			data = append(data, encodeCBOR(key)...)
+			data = append(data, encodeCBOR(item)...)
+		}
+		return data
+	default:
+		panic("unsupported type")
+	}
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// ErrAccessEntryNotFound is returned when the public key is not in the list.
+var ErrAccessEntryNotFound = errors.New("access list entry not found")
+
+// Access lists kinds.
+const (
+	ListAllow = "allow"
+	ListDeny  = "deny"
+)
+
+// Actions restricted by the access lists.
+const (
+	AccessBets        = "bets"
+	AccessWithdrawals = "withdrawals"
+)
+
+// AccessListsStore contains the methods used to manage the public keys allowed or denied to bet
+// and withdraw.
+type AccessListsStore interface {
+	Add(entry AccessEntry) error
+	Allowed(publicKey, action string) (bool, error)
+	Delete(publicKey, list, action string) error
+	List() ([]AccessEntry, error)
+}
+
+// AccessEntry is a public key in the allow or deny list of an action.
+type AccessEntry struct {
+	PublicKey  string `json:"public_key"`
+	List       string `json:"list"`
+	Action     string `json:"action"`
+	Reason     string `json:"reason,omitempty"`
+	OperatorID uint64 `json:"operator_id"`
+	CreatedAt  int64  `json:"created_at"`
+}
+
+type accessLists struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newAccessListsStore returns a new access lists storage service.
+func newAccessListsStore(db *sql.DB, logger *logger.Logger) AccessListsStore {
+	return &accessLists{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add puts a public key in a list, replacing the previous entry if it was already there.
+func (a *accessLists) Add(entry AccessEntry) error {
+	query := `INSERT OR REPLACE INTO access_lists
+	(public_key, list, action, reason, operator_id, created_at) VALUES (?,?,?,?,?,?)`
+	stmt, err := a.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(entry.PublicKey, entry.List, entry.Action, entry.Reason, entry.OperatorID,
+		entry.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "adding access list entry")
+	}
+
+	return nil
+}
+
+// Allowed returns whether the public key can perform the action. It can't if it's in the deny list
+// or if the allow list has entries and it's not one of them.
+func (a *accessLists) Allowed(publicKey, action string) (bool, error) {
+	query := `SELECT
+	EXISTS (SELECT 1 FROM access_lists WHERE public_key=? AND list='deny' AND action=?),
+	EXISTS (SELECT 1 FROM access_lists WHERE list='allow' AND action=?),
+	EXISTS (SELECT 1 FROM access_lists WHERE public_key=? AND list='allow' AND action=?)`
+	stmt, err := a.db.Prepare(query)
+	if err != nil {
+		return false, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var denied, restricted, allowed bool
+	err = stmt.QueryRow(publicKey, action, action, publicKey, action).Scan(&denied, &restricted, &allowed)
+	if err != nil {
+		return false, errors.Wrap(err, "checking access lists")
+	}
+
+	return !denied && (!restricted || allowed), nil
+}
+
+// Delete removes a public key from a list.
+func (a *accessLists) Delete(publicKey, list, action string) error {
+	stmt, err := a.db.Prepare("DELETE FROM access_lists WHERE public_key=? AND list=? AND action=?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(publicKey, list, action)
+	if err != nil {
+		return errors.Wrap(err, "deleting access list entry")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "getting affected rows")
+	}
+	if rows == 0 {
+		return ErrAccessEntryNotFound
+	}
+
+	return nil
+}
+
+// List returns the entries of every access list.
+func (a *accessLists) List() ([]AccessEntry, error) {
+	query := `SELECT public_key, list, action, reason, operator_id, created_at FROM access_lists
+	ORDER BY action, list, created_at`
+	stmt, err := a.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing access lists")
+	}
+	defer rows.Close()
+
+	var entries []AccessEntry
+	// Reuse object
+	var entry AccessEntry
+	for rows.Next() {
+		err := rows.Scan(&entry.PublicKey, &entry.List, &entry.Action, &entry.Reason, &entry.OperatorID,
+			&entry.CreatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,37 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// AccessListsStoreMock is a mocked implementation of the access lists store.
+type AccessListsStoreMock struct {
+	mock.Mock
+}
+
+// NewAccessListsStoreMock returns a mocked access lists store.
+func NewAccessListsStoreMock() *AccessListsStoreMock {
+	return &AccessListsStoreMock{}
+}
+
+// Add mock.
+func (a *AccessListsStoreMock) Add(entry AccessEntry) error {
+	args := a.Called(entry)
+	return args.Error(0)
+}
+
+// Allowed mock.
+func (a *AccessListsStoreMock) Allowed(publicKey, action string) (bool, error) {
+	args := a.Called(publicKey, action)
+	return args.Bool(0), args.Error(1)
+}
+
+// Delete mock.
+func (a *AccessListsStoreMock) Delete(publicKey, list, action string) error {
+	args := a.Called(publicKey, list, action)
+	return args.Error(0)
+}
+
+// List mock.
+func (a *AccessListsStoreMock) List() ([]AccessEntry, error) {
+	args := a.Called()
+	return args.Get(0).([]AccessEntry), args.Error(1)
+}
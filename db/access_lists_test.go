@@ -0,0 +1,87 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AccessListsSuite struct {
+	suite.Suite
+
+	db *database.DB
+}
+
+func TestAccessListsSuite(t *testing.T) {
+	suite.Run(t, &AccessListsSuite{})
+}
+
+func (s *AccessListsSuite) SetupTest() {
+	s.db = setupDB(s.T(), func(db *sql.DB) {})
+}
+
+func (s *AccessListsSuite) TestAllowed() {
+	allowed, err := s.db.AccessLists.Allowed("a", database.AccessBets)
+	s.NoError(err)
+	s.True(allowed)
+
+	deny := database.AccessEntry{
+		PublicKey:  "a",
+		List:       database.ListDeny,
+		Action:     database.AccessBets,
+		Reason:     "operator node",
+		OperatorID: 1,
+		CreatedAt:  1231006505,
+	}
+	s.NoError(s.db.AccessLists.Add(deny))
+
+	allowed, err = s.db.AccessLists.Allowed("a", database.AccessBets)
+	s.NoError(err)
+	s.False(allowed)
+
+	// Lists only restrict their action
+	allowed, err = s.db.AccessLists.Allowed("a", database.AccessWithdrawals)
+	s.NoError(err)
+	s.True(allowed)
+
+	allow := database.AccessEntry{
+		PublicKey:  "b",
+		List:       database.ListAllow,
+		Action:     database.AccessWithdrawals,
+		OperatorID: 1,
+		CreatedAt:  1231006505,
+	}
+	s.NoError(s.db.AccessLists.Add(allow))
+
+	allowed, err = s.db.AccessLists.Allowed("a", database.AccessWithdrawals)
+	s.NoError(err)
+	s.False(allowed)
+	allowed, err = s.db.AccessLists.Allowed("b", database.AccessWithdrawals)
+	s.NoError(err)
+	s.True(allowed)
+
+	entries, err := s.db.AccessLists.List()
+	s.NoError(err)
+	s.Equal([]database.AccessEntry{deny, allow}, entries)
+}
+
+func (s *AccessListsSuite) TestDelete() {
+	entry := database.AccessEntry{
+		PublicKey: "a",
+		List:      database.ListDeny,
+		Action:    database.AccessWithdrawals,
+		CreatedAt: 1231006505,
+	}
+	s.NoError(s.db.AccessLists.Add(entry))
+
+	s.ErrorIs(s.db.AccessLists.Delete("a", database.ListAllow, database.AccessWithdrawals),
+		database.ErrAccessEntryNotFound)
+	s.NoError(s.db.AccessLists.Delete("a", database.ListDeny, database.AccessWithdrawals))
+
+	allowed, err := s.db.AccessLists.Allowed("a", database.AccessWithdrawals)
+	s.NoError(err)
+	s.True(allowed)
+}
@@ -0,0 +1,264 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoAPIKey is thrown when an API key does not exist or it was revoked.
+var ErrNoAPIKey = errors.New("api key not found")
+
+// Scope is a permission granted to an API key.
+type Scope string
+
+// API key scopes
+const (
+	// ScopeStats grants access to the statistics endpoints.
+	ScopeStats Scope = "stats"
+	// ScopeBets allows creating bet invoices on behalf of the players, like kiosks do.
+	ScopeBets Scope = "bets"
+	// ScopeWebhooks allows managing webhook subscriptions.
+	ScopeWebhooks Scope = "webhooks"
+)
+
+var scopes = []Scope{ScopeStats, ScopeBets, ScopeWebhooks}
+
+// ParseScopes returns the scopes in a comma-separated list of names.
+func ParseScopes(names string) ([]Scope, error) {
+	var parsed []Scope
+	for _, name := range strings.Split(names, ",") {
+		scope := Scope(strings.TrimSpace(name))
+		found := false
+		for _, s := range scopes {
+			if s == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("invalid scope %q", name)
+		}
+		parsed = append(parsed, scope)
+	}
+
+	return parsed, nil
+}
+
+// APIKeysStore contains the methods used to store and retrieve the API keys of third-party
+// integrators.
+//
+// Only the hashes of the keys are stored.
+type APIKeysStore interface {
+	Add(name, keyHash string, scopes []Scope, rateLimit uint64) (APIKey, error)
+	Get(keyHash string) (APIKey, error)
+	List() ([]APIKey, error)
+	Revoke(id uint64) error
+	Rotate(id uint64, keyHash string) error
+}
+
+// APIKey identifies an integrator and the permissions it was granted.
+type APIKey struct {
+	Name   string  `json:"name"`
+	Scopes []Scope `json:"scopes"`
+	ID     uint64  `json:"id"`
+	// RateLimit is the number of requests allowed per rate limiter interval, 0 uses the default
+	RateLimit uint64 `json:"rate_limit"`
+	CreatedAt int64  `json:"created_at"`
+	RotatedAt int64  `json:"rotated_at,omitempty"`
+}
+
+// HasScope returns whether the key was granted the scope.
+func (k APIKey) HasScope(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type apiKeys struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newAPIKeysStore returns a new API keys storage service.
+func newAPIKeysStore(db *sql.DB, logger *logger.Logger) APIKeysStore {
+	return &apiKeys{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add saves an API key.
+func (a *apiKeys) Add(name, keyHash string, scopes []Scope, rateLimit uint64) (APIKey, error) {
+	query := "INSERT INTO api_keys (name, key_hash, scopes, rate_limit, created_at) VALUES (?,?,?,?,?)"
+	stmt, err := a.db.Prepare(query)
+	if err != nil {
+		return APIKey{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	createdAt := time.Now().Unix()
+	result, err := stmt.Exec(name, keyHash, joinScopes(scopes), rateLimit, createdAt)
+	if err != nil {
+		return APIKey{}, errors.Wrap(err, "adding api key")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return APIKey{}, errors.Wrap(err, "getting api key id")
+	}
+
+	return APIKey{
+		ID:        uint64(id),
+		Name:      name,
+		Scopes:    scopes,
+		RateLimit: rateLimit,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// Get returns the API key with the hash specified.
+func (a *apiKeys) Get(keyHash string) (APIKey, error) {
+	query := "SELECT id, name, scopes, rate_limit, created_at, rotated_at FROM api_keys WHERE key_hash=?"
+	stmt, err := a.db.Prepare(query)
+	if err != nil {
+		return APIKey{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	apiKey, err := scanAPIKey(stmt.QueryRow(keyHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return APIKey{}, ErrNoAPIKey
+		}
+		return APIKey{}, errors.Wrap(err, "getting api key")
+	}
+
+	return apiKey, nil
+}
+
+// List returns all the API keys.
+func (a *apiKeys) List() ([]APIKey, error) {
+	query := "SELECT id, name, scopes, rate_limit, created_at, rotated_at FROM api_keys ORDER BY id"
+	stmt, err := a.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing api keys")
+	}
+	defer rows.Close()
+
+	var apiKeys []APIKey
+	for rows.Next() {
+		apiKey, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	return apiKeys, nil
+}
+
+// Revoke removes an API key and its webhooks, the requests using it are rejected from then on.
+func (a *apiKeys) Revoke(id uint64) error {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM api_keys WHERE id=?", id)
+	if err != nil {
+		return errors.Wrap(err, "revoking api key")
+	}
+
+	if err := checkAPIKeyAffected(result); err != nil {
+		return err
+	}
+
+	query := `DELETE FROM webhook_deliveries WHERE webhook_id IN
+	(SELECT id FROM webhooks WHERE api_key_id=?)`
+	if _, err := tx.Exec(query, id); err != nil {
+		return errors.Wrap(err, "deleting webhook deliveries")
+	}
+
+	if _, err := tx.Exec("DELETE FROM webhooks WHERE api_key_id=?", id); err != nil {
+		return errors.Wrap(err, "deleting webhooks")
+	}
+
+	return tx.Commit()
+}
+
+// Rotate replaces the hash of an API key, keeping its scopes and rate limit. The previous key is
+// no longer valid.
+func (a *apiKeys) Rotate(id uint64, keyHash string) error {
+	stmt, err := a.db.Prepare("UPDATE api_keys SET key_hash=?, rotated_at=? WHERE id=?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(keyHash, time.Now().Unix(), id)
+	if err != nil {
+		return errors.Wrap(err, "rotating api key")
+	}
+
+	return checkAPIKeyAffected(result)
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row scanner) (APIKey, error) {
+	var (
+		apiKey APIKey
+		scopes string
+	)
+	err := row.Scan(&apiKey.ID, &apiKey.Name, &scopes, &apiKey.RateLimit, &apiKey.CreatedAt,
+		&apiKey.RotatedAt)
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	if scopes != "" {
+		for _, scope := range strings.Split(scopes, ",") {
+			apiKey.Scopes = append(apiKey.Scopes, Scope(scope))
+		}
+	}
+
+	return apiKey, nil
+}
+
+func checkAPIKeyAffected(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "getting affected rows")
+	}
+	if rows == 0 {
+		return ErrNoAPIKey
+	}
+
+	return nil
+}
+
+func joinScopes(scopes []Scope) string {
+	names := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		names = append(names, string(scope))
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,43 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// APIKeysStoreMock is a mocked implementation of the API keys store.
+type APIKeysStoreMock struct {
+	mock.Mock
+}
+
+// NewAPIKeysStoreMock returns a mocked API keys store.
+func NewAPIKeysStoreMock() *APIKeysStoreMock {
+	return &APIKeysStoreMock{}
+}
+
+// Add mock.
+func (a *APIKeysStoreMock) Add(name, keyHash string, scopes []Scope, rateLimit uint64) (APIKey, error) {
+	args := a.Called(name, keyHash, scopes, rateLimit)
+	return args.Get(0).(APIKey), args.Error(1)
+}
+
+// Get mock.
+func (a *APIKeysStoreMock) Get(keyHash string) (APIKey, error) {
+	args := a.Called(keyHash)
+	return args.Get(0).(APIKey), args.Error(1)
+}
+
+// List mock.
+func (a *APIKeysStoreMock) List() ([]APIKey, error) {
+	args := a.Called()
+	return args.Get(0).([]APIKey), args.Error(1)
+}
+
+// Revoke mock.
+func (a *APIKeysStoreMock) Revoke(id uint64) error {
+	args := a.Called(id)
+	return args.Error(0)
+}
+
+// Rotate mock.
+func (a *APIKeysStoreMock) Rotate(id uint64, keyHash string) error {
+	args := a.Called(id, keyHash)
+	return args.Error(0)
+}
@@ -0,0 +1,95 @@
+package db_test
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type APIKeysSuite struct {
+	suite.Suite
+
+	db *database.DB
+}
+
+func TestAPIKeysSuite(t *testing.T) {
+	suite.Run(t, &APIKeysSuite{})
+}
+
+func (s *APIKeysSuite) SetupTest() {
+	s.db = setupDB(s.T(), func(db *sql.DB) {})
+}
+
+func (s *APIKeysSuite) TestAdd() {
+	scopes := []database.Scope{database.ScopeStats, database.ScopeBets}
+	apiKey, err := s.db.APIKeys.Add("kiosk", "hash", scopes, 100)
+	s.NoError(err)
+
+	got, err := s.db.APIKeys.Get("hash")
+	s.NoError(err)
+	s.Equal(apiKey, got)
+	s.True(got.HasScope(database.ScopeBets))
+	s.False(got.HasScope(database.ScopeWebhooks))
+
+	_, err = s.db.APIKeys.Add("kiosk", "hash2", scopes, 0)
+	s.Error(err)
+}
+
+func (s *APIKeysSuite) TestList() {
+	first, err := s.db.APIKeys.Add("stats", "hash", []database.Scope{database.ScopeStats}, 0)
+	s.NoError(err)
+	second, err := s.db.APIKeys.Add("kiosk", "hash2", []database.Scope{database.ScopeBets}, 10)
+	s.NoError(err)
+
+	apiKeys, err := s.db.APIKeys.List()
+	s.NoError(err)
+	s.Equal([]database.APIKey{first, second}, apiKeys)
+}
+
+func (s *APIKeysSuite) TestRotate() {
+	apiKey, err := s.db.APIKeys.Add("kiosk", "hash", []database.Scope{database.ScopeBets}, 0)
+	s.NoError(err)
+
+	err = s.db.APIKeys.Rotate(apiKey.ID, "new_hash")
+	s.NoError(err)
+
+	_, err = s.db.APIKeys.Get("hash")
+	s.ErrorIs(err, database.ErrNoAPIKey)
+
+	got, err := s.db.APIKeys.Get("new_hash")
+	s.NoError(err)
+	s.Equal(apiKey.Scopes, got.Scopes)
+	s.NotZero(got.RotatedAt)
+
+	err = s.db.APIKeys.Rotate(apiKey.ID+1, "hash")
+	s.ErrorIs(err, database.ErrNoAPIKey)
+}
+
+func (s *APIKeysSuite) TestRevoke() {
+	apiKey, err := s.db.APIKeys.Add("kiosk", "hash", []database.Scope{database.ScopeBets}, 0)
+	s.NoError(err)
+
+	err = s.db.APIKeys.Revoke(apiKey.ID)
+	s.NoError(err)
+
+	_, err = s.db.APIKeys.Get("hash")
+	s.ErrorIs(err, database.ErrNoAPIKey)
+
+	err = s.db.APIKeys.Revoke(apiKey.ID)
+	s.ErrorIs(err, database.ErrNoAPIKey)
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := database.ParseScopes("stats, webhooks")
+	if err != nil || !reflect.DeepEqual(scopes, []database.Scope{database.ScopeStats, database.ScopeWebhooks}) {
+		t.Errorf("expected stats and webhooks scopes, got %v (%v)", scopes, err)
+	}
+
+	if _, err := database.ParseScopes("stats,admin"); err == nil {
+		t.Error("expected an error")
+	}
+}
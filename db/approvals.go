@@ -0,0 +1,259 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrApprovalNotFound is returned when there's no pending approval with the ID provided.
+	ErrApprovalNotFound = errors.New("approval not found")
+	// ErrAlreadyApproved is returned when an operator approves the same payout twice.
+	ErrAlreadyApproved = errors.New("payout already approved by the operator")
+)
+
+// ApprovalsStore contains the methods used to persist the payouts waiting for the operators
+// approval.
+//
+// The prizes of a payout are deducted when it's requested and stored along with it, so they can
+// be restored if it expires or it's rejected.
+type ApprovalsStore interface {
+	Add(approval Approval) (uint64, error)
+	Approve(id, operatorID uint64, now int64, required int) (Approval, bool, error)
+	Delete(id uint64) (Approval, error)
+	List() ([]Approval, error)
+}
+
+// Approval is a payout waiting for the operators approval.
+type Approval struct {
+	PublicKey      string      `json:"public_key"`
+	PaymentRequest string      `json:"payment_request"`
+	PaymentHash    string      `json:"payment_hash"`
+	Claims         []PrizesRow `json:"-"`
+	Approvers      []uint64    `json:"approvers"`
+	ID             uint64      `json:"id"`
+	Amount         uint64      `json:"amount"`
+	Fee            uint64      `json:"fee"`
+	CreatedAt      int64       `json:"created_at"`
+	ExpiresAt      int64       `json:"expires_at"`
+}
+
+type approvals struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newApprovalsStore returns a new approvals storage service.
+func newApprovalsStore(db *sql.DB, logger *logger.Logger) ApprovalsStore {
+	return &approvals{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add stores a payout pending approval and returns its identifier.
+func (a *approvals) Add(approval Approval) (uint64, error) {
+	claims, err := json.Marshal(approval.Claims)
+	if err != nil {
+		return 0, errors.Wrap(err, "encoding claims")
+	}
+
+	query := `INSERT INTO approvals
+	(public_key, payment_request, payment_hash, amount, fee, claims, created_at, expires_at)
+	VALUES (?,?,?,?,?,?,?,?)`
+	stmt, err := a.db.Prepare(query)
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(approval.PublicKey, approval.PaymentRequest, approval.PaymentHash,
+		approval.Amount, approval.Fee, claims, approval.CreatedAt, approval.ExpiresAt)
+	if err != nil {
+		return 0, errors.Wrap(err, "adding approval")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, errors.Wrap(err, "getting approval id")
+	}
+
+	return uint64(id), nil
+}
+
+// Approve records the operator's approval of a payout that hasn't expired at now. Once it has the
+// approvals required, the payout is removed and returned as released, so only one caller pays it.
+func (a *approvals) Approve(id, operatorID uint64, now int64, required int) (Approval, bool, error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return Approval{}, false, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	approval, err := getApproval(tx, id)
+	if err != nil {
+		return Approval{}, false, err
+	}
+
+	if now >= approval.ExpiresAt {
+		return Approval{}, false, ErrApprovalNotFound
+	}
+
+	for _, approver := range approval.Approvers {
+		if approver == operatorID {
+			return Approval{}, false, ErrAlreadyApproved
+		}
+	}
+
+	query := "INSERT INTO approval_signatures (approval_id, operator_id, created_at) VALUES (?,?,?)"
+	if _, err := tx.Exec(query, id, operatorID, now); err != nil {
+		return Approval{}, false, errors.Wrap(err, "adding approval signature")
+	}
+	approval.Approvers = append(approval.Approvers, operatorID)
+
+	released := len(approval.Approvers) >= required
+	if released {
+		if err := deleteApproval(tx, id); err != nil {
+			return Approval{}, false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Approval{}, false, errors.Wrap(err, "committing transaction")
+	}
+
+	return approval, released, nil
+}
+
+// Delete removes a pending payout and returns it.
+func (a *approvals) Delete(id uint64) (Approval, error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return Approval{}, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	approval, err := getApproval(tx, id)
+	if err != nil {
+		return Approval{}, err
+	}
+
+	if err := deleteApproval(tx, id); err != nil {
+		return Approval{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Approval{}, errors.Wrap(err, "committing transaction")
+	}
+
+	return approval, nil
+}
+
+// List returns the payouts pending approval, oldest first.
+func (a *approvals) List() ([]Approval, error) {
+	query := `SELECT a.id, a.public_key, a.payment_request, a.payment_hash, a.amount, a.fee,
+	a.created_at, a.expires_at, COALESCE(GROUP_CONCAT(s.operator_id), '')
+	FROM approvals a LEFT JOIN approval_signatures s ON s.approval_id = a.id
+	GROUP BY a.id ORDER BY a.id`
+	rows, err := a.db.Query(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying approvals")
+	}
+	defer rows.Close()
+
+	var approvals []Approval
+	for rows.Next() {
+		var (
+			approval  Approval
+			approvers string
+		)
+		err := rows.Scan(&approval.ID, &approval.PublicKey, &approval.PaymentRequest,
+			&approval.PaymentHash, &approval.Amount, &approval.Fee, &approval.CreatedAt,
+			&approval.ExpiresAt, &approvers)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning approval")
+		}
+
+		approval.Approvers, err = parseApprovers(approvers)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, approval)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating approvals")
+	}
+
+	return approvals, nil
+}
+
+func getApproval(tx *sql.Tx, id uint64) (Approval, error) {
+	query := `SELECT public_key, payment_request, payment_hash, amount, fee, claims, created_at,
+	expires_at FROM approvals WHERE id = ?`
+
+	approval := Approval{ID: id}
+	var claims []byte
+	err := tx.QueryRow(query, id).Scan(&approval.PublicKey, &approval.PaymentRequest,
+		&approval.PaymentHash, &approval.Amount, &approval.Fee, &claims, &approval.CreatedAt,
+		&approval.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Approval{}, ErrApprovalNotFound
+		}
+		return Approval{}, errors.Wrap(err, "getting approval")
+	}
+
+	if err := json.Unmarshal(claims, &approval.Claims); err != nil {
+		return Approval{}, errors.Wrap(err, "decoding claims")
+	}
+
+	rows, err := tx.Query("SELECT operator_id FROM approval_signatures WHERE approval_id = ? ORDER BY rowid", id)
+	if err != nil {
+		return Approval{}, errors.Wrap(err, "querying approval signatures")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var operatorID uint64
+		if err := rows.Scan(&operatorID); err != nil {
+			return Approval{}, errors.Wrap(err, "scanning approval signature")
+		}
+		approval.Approvers = append(approval.Approvers, operatorID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return Approval{}, errors.Wrap(err, "iterating approval signatures")
+	}
+
+	return approval, nil
+}
+
+func deleteApproval(tx *sql.Tx, id uint64) error {
+	if _, err := tx.Exec("DELETE FROM approval_signatures WHERE approval_id = ?", id); err != nil {
+		return errors.Wrap(err, "deleting approval signatures")
+	}
+
+	if _, err := tx.Exec("DELETE FROM approvals WHERE id = ?", id); err != nil {
+		return errors.Wrap(err, "deleting approval")
+	}
+
+	return nil
+}
+
+func parseApprovers(approvers string) ([]uint64, error) {
+	if approvers == "" {
+		return nil, nil
+	}
+
+	var ids []uint64
+	if err := json.Unmarshal([]byte("["+approvers+"]"), &ids); err != nil {
+		return nil, errors.Wrap(err, "decoding approvers")
+	}
+
+	return ids, nil
+}
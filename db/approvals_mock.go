@@ -0,0 +1,41 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// ApprovalsStoreMock is a mocked implementation of the approvals store.
+type ApprovalsStoreMock struct {
+	mock.Mock
+}
+
+// NewApprovalsStoreMock returns a mocked approvals store.
+func NewApprovalsStoreMock() *ApprovalsStoreMock {
+	return &ApprovalsStoreMock{}
+}
+
+// Add mock.
+func (a *ApprovalsStoreMock) Add(approval Approval) (uint64, error) {
+	args := a.Called(approval)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+// Approve mock.
+func (a *ApprovalsStoreMock) Approve(id, operatorID uint64, now int64, required int) (Approval, bool, error) {
+	args := a.Called(id, operatorID, now, required)
+	return args.Get(0).(Approval), args.Bool(1), args.Error(2)
+}
+
+// Delete mock.
+func (a *ApprovalsStoreMock) Delete(id uint64) (Approval, error) {
+	args := a.Called(id)
+	return args.Get(0).(Approval), args.Error(1)
+}
+
+// List mock.
+func (a *ApprovalsStoreMock) List() ([]Approval, error) {
+	args := a.Called()
+	var approvals []Approval
+	if v := args.Get(0); v != nil {
+		approvals = v.([]Approval)
+	}
+	return approvals, args.Error(1)
+}
@@ -0,0 +1,96 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ApprovalsSuite struct {
+	suite.Suite
+
+	db database.ApprovalsStore
+}
+
+func TestApprovalsSuite(t *testing.T) {
+	suite.Run(t, &ApprovalsSuite{})
+}
+
+func (a *ApprovalsSuite) SetupTest() {
+	db := setupDB(a.T(), func(db *sql.DB) {})
+	a.db = db.Approvals
+}
+
+func (a *ApprovalsSuite) addApproval(expiresAt int64) database.Approval {
+	approval := database.Approval{
+		PublicKey:      "public_key",
+		PaymentRequest: "lnbc1",
+		PaymentHash:    "hash",
+		Claims:         []database.PrizesRow{{RowID: 1, Amount: 600}, {RowID: 2, Amount: 400}},
+		Amount:         990,
+		Fee:            10,
+		CreatedAt:      100,
+		ExpiresAt:      expiresAt,
+	}
+
+	id, err := a.db.Add(approval)
+	a.NoError(err)
+	approval.ID = id
+
+	return approval
+}
+
+func (a *ApprovalsSuite) TestApprove() {
+	approval := a.addApproval(1000)
+
+	pending, released, err := a.db.Approve(approval.ID, 1, 200, 2)
+	a.NoError(err)
+	a.False(released)
+	a.Equal([]uint64{1}, pending.Approvers)
+
+	_, _, err = a.db.Approve(approval.ID, 1, 300, 2)
+	a.ErrorIs(err, database.ErrAlreadyApproved)
+
+	approvals, err := a.db.List()
+	a.NoError(err)
+	a.Len(approvals, 1)
+	a.Equal([]uint64{1}, approvals[0].Approvers)
+	a.Nil(approvals[0].Claims)
+
+	approved, released, err := a.db.Approve(approval.ID, 2, 300, 2)
+	a.NoError(err)
+	a.True(released)
+	approval.Approvers = []uint64{1, 2}
+	a.Equal(approval, approved)
+
+	approvals, err = a.db.List()
+	a.NoError(err)
+	a.Empty(approvals)
+
+	_, _, err = a.db.Approve(approval.ID, 3, 300, 2)
+	a.ErrorIs(err, database.ErrApprovalNotFound)
+}
+
+func (a *ApprovalsSuite) TestApproveExpired() {
+	approval := a.addApproval(1000)
+
+	_, _, err := a.db.Approve(approval.ID, 1, 1000, 2)
+	a.ErrorIs(err, database.ErrApprovalNotFound)
+}
+
+func (a *ApprovalsSuite) TestDelete() {
+	approval := a.addApproval(1000)
+	_, _, err := a.db.Approve(approval.ID, 1, 200, 2)
+	a.NoError(err)
+
+	deleted, err := a.db.Delete(approval.ID)
+	a.NoError(err)
+	approval.Approvers = []uint64{1}
+	a.Equal(approval, deleted)
+
+	_, err = a.db.Delete(approval.ID)
+	a.ErrorIs(err, database.ErrApprovalNotFound)
+}
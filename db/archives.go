@@ -0,0 +1,128 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// ArchivesStore contains the methods used to store and retrieve the exports of the draw history.
+type ArchivesStore interface {
+	Add(archive Archive) (uint64, error)
+	LastHeight(destination string) (uint32, error)
+	List(offset, limit uint64, reverse bool) ([]Archive, error)
+}
+
+// Archive is an export of the draws between two lottery heights to an external destination, where
+// it can be retrieved with the reference even if BTRY's server is gone.
+type Archive struct {
+	// Destination is where the draws were exported to, "nostr" or "ipfs"
+	Destination string `json:"destination"`
+	// Reference is the ID of the nostr event or the CID of the IPFS document
+	Reference string `json:"reference"`
+	// Hash is the SHA-256 hash of the signed message, hex encoded
+	Hash        string `json:"hash"`
+	ID          uint64 `json:"id"`
+	CreatedAt   int64  `json:"created_at"`
+	FirstHeight uint32 `json:"first_height"`
+	LastHeight  uint32 `json:"last_height"`
+}
+
+type archives struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newArchivesStore returns a new archives storage service.
+func newArchivesStore(db *sql.DB, logger *logger.Logger) ArchivesStore {
+	return &archives{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add saves an archive and returns its ID.
+func (a *archives) Add(archive Archive) (uint64, error) {
+	query := `INSERT INTO archives (destination, first_height, last_height, reference, hash, created_at)
+	VALUES (?,?,?,?,?,?)`
+	stmt, err := a.db.Prepare(query)
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(archive.Destination, archive.FirstHeight, archive.LastHeight,
+		archive.Reference, archive.Hash, archive.CreatedAt)
+	if err != nil {
+		return 0, errors.Wrap(err, "adding archive")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, errors.Wrap(err, "getting archive ID")
+	}
+
+	return uint64(id), nil
+}
+
+// LastHeight returns the height of the last lottery exported to the destination, zero if none was.
+func (a *archives) LastHeight(destination string) (uint32, error) {
+	query := "SELECT COALESCE(MAX(last_height), 0) FROM archives WHERE destination=?"
+	stmt, err := a.db.Prepare(query)
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var height uint32
+	if err := stmt.QueryRow(destination).Scan(&height); err != nil {
+		return 0, errors.Wrap(err, "getting last archived height")
+	}
+
+	return height, nil
+}
+
+// List returns the archives exported. The offset is the ID of the archive to start after.
+//
+// A limit value of 0 means there's no limit.
+func (a *archives) List(offset, limit uint64, reverse bool) ([]Archive, error) {
+	// Cap limit to avoid creating a slice with too big capacity
+	if limit > 500 {
+		limit = 500
+	}
+
+	query := "SELECT id, destination, first_height, last_height, reference, hash, created_at FROM archives"
+	query = AddPagination(query, offset, limit, "id", reverse)
+
+	stmt, err := a.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing archives")
+	}
+	defer rows.Close()
+
+	var archives []Archive
+	for rows.Next() {
+		var archive Archive
+		err := rows.Scan(&archive.ID, &archive.Destination, &archive.FirstHeight, &archive.LastHeight,
+			&archive.Reference, &archive.Hash, &archive.CreatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		archives = append(archives, archive)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating archives")
+	}
+
+	return archives, nil
+}
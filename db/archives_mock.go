@@ -0,0 +1,36 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// ArchivesStoreMock is a mocked implementation of the archives store.
+type ArchivesStoreMock struct {
+	mock.Mock
+}
+
+// NewArchivesStoreMock returns a mocked archives store.
+func NewArchivesStoreMock() *ArchivesStoreMock {
+	return &ArchivesStoreMock{}
+}
+
+// Add mock.
+func (a *ArchivesStoreMock) Add(archive Archive) (uint64, error) {
+	args := a.Called(archive)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+// LastHeight mock.
+func (a *ArchivesStoreMock) LastHeight(destination string) (uint32, error) {
+	args := a.Called(destination)
+	return args.Get(0).(uint32), args.Error(1)
+}
+
+// List mock.
+func (a *ArchivesStoreMock) List(offset, limit uint64, reverse bool) ([]Archive, error) {
+	args := a.Called(offset, limit, reverse)
+	var r0 []Archive
+	v0 := args.Get(0)
+	if v0 != nil {
+		r0 = v0.([]Archive)
+	}
+	return r0, args.Error(1)
+}
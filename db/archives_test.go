@@ -0,0 +1,77 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ArchivesSuite struct {
+	suite.Suite
+
+	db database.ArchivesStore
+}
+
+func TestArchivesSuite(t *testing.T) {
+	suite.Run(t, &ArchivesSuite{})
+}
+
+func (a *ArchivesSuite) SetupTest() {
+	a.db = setupDB(a.T(), func(db *sql.DB) {}).Archives
+}
+
+func (a *ArchivesSuite) TestAdd() {
+	archive := database.Archive{
+		Destination: "nostr",
+		Reference:   "event_id",
+		Hash:        "hash",
+		CreatedAt:   1_700_000_000,
+		FirstHeight: 144,
+		LastHeight:  432,
+	}
+	id, err := a.db.Add(archive)
+	a.NoError(err)
+	a.Equal(uint64(1), id)
+
+	archives, err := a.db.List(0, 0, false)
+	a.NoError(err)
+	archive.ID = id
+	a.Equal([]database.Archive{archive}, archives)
+}
+
+func (a *ArchivesSuite) TestLastHeight() {
+	height, err := a.db.LastHeight("nostr")
+	a.NoError(err)
+	a.Zero(height)
+
+	_, err = a.db.Add(database.Archive{Destination: "nostr", FirstHeight: 144, LastHeight: 288})
+	a.NoError(err)
+	_, err = a.db.Add(database.Archive{Destination: "nostr", FirstHeight: 432, LastHeight: 576})
+	a.NoError(err)
+	_, err = a.db.Add(database.Archive{Destination: "ipfs", FirstHeight: 144, LastHeight: 720})
+	a.NoError(err)
+
+	height, err = a.db.LastHeight("nostr")
+	a.NoError(err)
+	a.Equal(uint32(576), height)
+}
+
+func (a *ArchivesSuite) TestList() {
+	for _, height := range []uint32{144, 288, 432} {
+		_, err := a.db.Add(database.Archive{Destination: "ipfs", FirstHeight: height, LastHeight: height})
+		a.NoError(err)
+	}
+
+	archives, err := a.db.List(1, 1, false)
+	a.NoError(err)
+	a.Len(archives, 1)
+	a.Equal(uint32(288), archives[0].FirstHeight)
+
+	archives, err = a.db.List(0, 0, true)
+	a.NoError(err)
+	a.Len(archives, 3)
+	a.Equal(uint32(432), archives[0].FirstHeight)
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// AuditStore contains the methods used to store and retrieve audit log entries from the database.
+//
+// The audit table is append-only, entries can't be updated nor deleted.
+type AuditStore interface {
+	Add(entry AuditEntry) error
+	Last() (AuditEntry, error)
+	List(offset, limit uint64) ([]AuditEntry, error)
+}
+
+// AuditEntry represents a state-changing event recorded in the audit log.
+type AuditEntry struct {
+	Event     string `json:"event"`
+	Data      string `json:"data"`
+	PrevHash  string `json:"prev_hash"`
+	Hash      string `json:"hash"`
+	Signature string `json:"signature"`
+	ID        uint64 `json:"id"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type audit struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newAuditStore returns a new audit storage service.
+func newAuditStore(db *sql.DB, logger *logger.Logger) AuditStore {
+	return &audit{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add appends an entry to the audit log.
+func (a *audit) Add(entry AuditEntry) error {
+	query := "INSERT INTO audit (id, timestamp, event, data, prev_hash, hash, signature) " +
+		"VALUES (?,?,?,?,?,?,?)"
+	stmt, err := a.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(
+		entry.ID,
+		entry.Timestamp,
+		entry.Event,
+		entry.Data,
+		entry.PrevHash,
+		entry.Hash,
+		entry.Signature,
+	)
+	if err != nil {
+		return errors.Wrap(err, "adding audit entry")
+	}
+
+	return nil
+}
+
+// Last returns the most recent entry of the audit log, or an empty one if there are none.
+func (a *audit) Last() (AuditEntry, error) {
+	query := "SELECT id, timestamp, event, data, prev_hash, hash, signature FROM audit " +
+		"ORDER BY id DESC LIMIT 1"
+	stmt, err := a.db.Prepare(query)
+	if err != nil {
+		return AuditEntry{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var entry AuditEntry
+	err = stmt.QueryRow().Scan(
+		&entry.ID,
+		&entry.Timestamp,
+		&entry.Event,
+		&entry.Data,
+		&entry.PrevHash,
+		&entry.Hash,
+		&entry.Signature,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return AuditEntry{}, nil
+		}
+		return AuditEntry{}, errors.Wrap(err, "scanning audit entry")
+	}
+
+	return entry, nil
+}
+
+// List returns audit log entries in the order they were appended.
+//
+// A limit value of 0 means there's no limit.
+func (a *audit) List(offset, limit uint64) ([]AuditEntry, error) {
+	// Cap limit to avoid creating a slice with too big capacity
+	if limit > 500 {
+		limit = 500
+	}
+
+	query := "SELECT id, timestamp, event, data, prev_hash, hash, signature FROM audit"
+	query = AddPagination(query, offset, limit, "id", false)
+
+	stmt, err := a.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing audit entries")
+	}
+	defer rows.Close()
+
+	entries := make([]AuditEntry, 0, limit)
+	// Reuse object
+	var entry AuditEntry
+	for rows.Next() {
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Timestamp,
+			&entry.Event,
+			&entry.Data,
+			&entry.PrevHash,
+			&entry.Hash,
+			&entry.Signature,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
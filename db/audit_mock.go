@@ -0,0 +1,36 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// AuditStoreMock is a mocked implementation of the audit store.
+type AuditStoreMock struct {
+	mock.Mock
+}
+
+// NewAuditStoreMock returns a mocked audit store.
+func NewAuditStoreMock() *AuditStoreMock {
+	return &AuditStoreMock{}
+}
+
+// Add mock.
+func (a *AuditStoreMock) Add(entry AuditEntry) error {
+	args := a.Called(entry)
+	return args.Error(0)
+}
+
+// Last mock.
+func (a *AuditStoreMock) Last() (AuditEntry, error) {
+	args := a.Called()
+	return args.Get(0).(AuditEntry), args.Error(1)
+}
+
+// List mock.
+func (a *AuditStoreMock) List(offset, limit uint64) ([]AuditEntry, error) {
+	args := a.Called(offset, limit)
+	var r0 []AuditEntry
+	v0 := args.Get(0)
+	if v0 != nil {
+		r0 = v0.([]AuditEntry)
+	}
+	return r0, args.Error(1)
+}
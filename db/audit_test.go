@@ -0,0 +1,113 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+var testAuditEntry = database.AuditEntry{
+	ID:        1,
+	Timestamp: 1715000000,
+	Event:     "bet_accepted",
+	Data:      `{"public_key":"pubkey","tickets":21}`,
+	PrevHash:  "0000000000000000000000000000000000000000000000000000000000000000",
+	Hash:      "4a4a1c1e9c2c9f0b4c6b0b7a6a0fa4a1a4a1c1e9c2c9f0b4c6b0b7a6a0fa4a1a",
+	Signature: "signature",
+}
+
+type AuditSuite struct {
+	suite.Suite
+
+	db database.AuditStore
+}
+
+func TestAuditSuite(t *testing.T) {
+	suite.Run(t, &AuditSuite{})
+}
+
+func (a *AuditSuite) SetupTest() {
+	db := setupDB(a.T(), func(db *sql.DB) {
+		query := `INSERT INTO audit (id, timestamp, event, data, prev_hash, hash, signature)
+		VALUES (?, ?, ?, ?, ?, ?, ?);`
+		_, err := db.Exec(query,
+			testAuditEntry.ID,
+			testAuditEntry.Timestamp,
+			testAuditEntry.Event,
+			testAuditEntry.Data,
+			testAuditEntry.PrevHash,
+			testAuditEntry.Hash,
+			testAuditEntry.Signature,
+		)
+		a.NoError(err)
+	})
+	a.db = db.Audit
+}
+
+func (a *AuditSuite) TestAdd() {
+	entry := database.AuditEntry{
+		ID:        2,
+		Timestamp: 1715000100,
+		Event:     "draw_executed",
+		Data:      "{}",
+		PrevHash:  testAuditEntry.Hash,
+		Hash:      "hash",
+		Signature: "signature",
+	}
+	err := a.db.Add(entry)
+	a.NoError(err)
+
+	last, err := a.db.Last()
+	a.NoError(err)
+	a.Equal(entry, last)
+}
+
+func (a *AuditSuite) TestAddForkedChain() {
+	entry := database.AuditEntry{
+		ID:        2,
+		Event:     "draw_executed",
+		Data:      "{}",
+		PrevHash:  testAuditEntry.PrevHash,
+		Hash:      "hash",
+		Signature: "signature",
+	}
+	err := a.db.Add(entry)
+	a.Error(err)
+}
+
+func (a *AuditSuite) TestLast() {
+	last, err := a.db.Last()
+	a.NoError(err)
+
+	a.Equal(testAuditEntry, last)
+}
+
+func (a *AuditSuite) TestList() {
+	entries, err := a.db.List(0, 0)
+	a.NoError(err)
+
+	a.Len(entries, 1)
+	a.Equal(testAuditEntry, entries[0])
+
+	entries, err = a.db.List(testAuditEntry.ID, 0)
+	a.NoError(err)
+	a.Empty(entries)
+}
+
+func TestAuditAppendOnly(t *testing.T) {
+	setupDB(t, func(db *sql.DB) {
+		_, err := db.Exec(`INSERT INTO audit (id, timestamp, event, data, prev_hash, hash, signature)
+		VALUES (1, 1, 'event', '{}', 'prev', 'hash', 'sig');`)
+		assert.NoError(t, err)
+
+		_, err = db.Exec("UPDATE audit SET event='tampered' WHERE id=1")
+		assert.Error(t, err)
+
+		_, err = db.Exec("DELETE FROM audit WHERE id=1")
+		assert.Error(t, err)
+	})
+}
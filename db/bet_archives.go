@@ -0,0 +1,119 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBetArchiveNotFound is returned when there's no archive of the lottery bets.
+var ErrBetArchiveNotFound = errors.New("bet archive not found")
+
+// BetArchivesStore contains the methods used to keep a copy of the bets of the lotteries drawn.
+//
+// The bets rows are compacted after the draws, the archives hold the bets as they were placed
+// so anyone can verify the draws later on.
+type BetArchivesStore interface {
+	Add(lotteryHeight uint32, bets []Bet) error
+	Delete(beforeHeight uint32) (uint64, error)
+	Get(lotteryHeight uint32) ([]Bet, error)
+}
+
+type betArchives struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newBetArchivesStore returns a new bet archives storage service.
+func newBetArchivesStore(db *sql.DB, logger *logger.Logger) BetArchivesStore {
+	return &betArchives{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add stores the bets of a lottery compressed, replacing the previous archive if there was one.
+func (b *betArchives) Add(lotteryHeight uint32, bets []Bet) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(bets); err != nil {
+		return errors.Wrap(err, "encoding bets")
+	}
+	if err := gz.Close(); err != nil {
+		return errors.Wrap(err, "compressing bets")
+	}
+
+	query := "INSERT OR REPLACE INTO bet_archives (lottery_height, bets) VALUES (?,?)"
+	stmt, err := b.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(lotteryHeight, buf.Bytes()); err != nil {
+		return errors.Wrap(err, "archiving bets")
+	}
+
+	return nil
+}
+
+// Delete removes the archives of the lotteries before the height specified and returns how many
+// were.
+func (b *betArchives) Delete(beforeHeight uint32) (uint64, error) {
+	stmt, err := b.db.Prepare("DELETE FROM bet_archives WHERE lottery_height < ?")
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(beforeHeight)
+	if err != nil {
+		return 0, errors.Wrap(err, "deleting bet archives")
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "counting deleted bet archives")
+	}
+
+	return uint64(deleted), nil
+}
+
+// Get returns the bets archived of the lottery at the height specified.
+func (b *betArchives) Get(lotteryHeight uint32) ([]Bet, error) {
+	stmt, err := b.db.Prepare("SELECT bets FROM bet_archives WHERE lottery_height=?")
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var compressed []byte
+	if err := stmt.QueryRow(lotteryHeight).Scan(&compressed); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBetArchiveNotFound
+		}
+		return nil, errors.Wrap(err, "getting bet archive")
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, errors.Wrap(err, "decompressing bets")
+	}
+	defer gz.Close()
+
+	var bets []Bet
+	if err := json.NewDecoder(gz).Decode(&bets); err != nil {
+		return nil, errors.Wrap(err, "decoding bets")
+	}
+
+	for i := range bets {
+		bets[i].LotteryHeight = lotteryHeight
+	}
+
+	return bets, nil
+}
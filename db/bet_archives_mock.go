@@ -0,0 +1,31 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// BetArchivesStoreMock is a mocked implementation of the bet archives store.
+type BetArchivesStoreMock struct {
+	mock.Mock
+}
+
+// NewBetArchivesStoreMock returns a mocked bet archives store.
+func NewBetArchivesStoreMock() *BetArchivesStoreMock {
+	return &BetArchivesStoreMock{}
+}
+
+// Add mock.
+func (b *BetArchivesStoreMock) Add(lotteryHeight uint32, bets []Bet) error {
+	args := b.Called(lotteryHeight, bets)
+	return args.Error(0)
+}
+
+// Delete mock.
+func (b *BetArchivesStoreMock) Delete(beforeHeight uint32) (uint64, error) {
+	args := b.Called(beforeHeight)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+// Get mock.
+func (b *BetArchivesStoreMock) Get(lotteryHeight uint32) ([]Bet, error) {
+	args := b.Called(lotteryHeight)
+	return args.Get(0).([]Bet), args.Error(1)
+}
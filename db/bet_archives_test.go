@@ -0,0 +1,71 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var archivedBets = []database.Bet{
+	{PublicKey: "a", FirstTicket: 1, Index: 100, Tickets: 100, LotteryHeight: firstHeight},
+	{PublicKey: "b", FirstTicket: 101, Index: 150, Tickets: 50, LotteryHeight: firstHeight},
+	{PublicKey: "a", Pool: "micro", FirstTicket: 1, Index: 10, Tickets: 10, Bonus: 2, LotteryHeight: firstHeight},
+}
+
+type BetArchivesSuite struct {
+	suite.Suite
+
+	db database.BetArchivesStore
+}
+
+func TestBetArchivesSuite(t *testing.T) {
+	suite.Run(t, &BetArchivesSuite{})
+}
+
+func (b *BetArchivesSuite) SetupTest() {
+	db := setupDB(b.T(), func(db *sql.DB) {
+		_, err := db.Exec("INSERT INTO lotteries (height) VALUES (?), (?)", firstHeight, secondHeight)
+		b.NoError(err)
+	})
+	b.db = db.BetArchives
+}
+
+func (b *BetArchivesSuite) TestAdd() {
+	err := b.db.Add(firstHeight, archivedBets)
+	b.NoError(err)
+
+	bets, err := b.db.Get(firstHeight)
+	b.NoError(err)
+	b.Equal(archivedBets, bets)
+
+	// Archiving the lottery again replaces its bets
+	err = b.db.Add(firstHeight, archivedBets[:1])
+	b.NoError(err)
+
+	bets, err = b.db.Get(firstHeight)
+	b.NoError(err)
+	b.Equal(archivedBets[:1], bets)
+}
+
+func (b *BetArchivesSuite) TestGetNotFound() {
+	_, err := b.db.Get(secondHeight)
+	b.ErrorIs(err, database.ErrBetArchiveNotFound)
+}
+
+func (b *BetArchivesSuite) TestDelete() {
+	b.NoError(b.db.Add(firstHeight, archivedBets))
+	b.NoError(b.db.Add(secondHeight, archivedBets))
+
+	deleted, err := b.db.Delete(secondHeight)
+	b.NoError(err)
+	b.Equal(uint64(1), deleted)
+
+	_, err = b.db.Get(firstHeight)
+	b.ErrorIs(err, database.ErrBetArchiveNotFound)
+
+	_, err = b.db.Get(secondHeight)
+	b.NoError(err)
+}
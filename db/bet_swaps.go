@@ -0,0 +1,116 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBetSwapNotFound is returned when there's no bet swap with the ID provided.
+var ErrBetSwapNotFound = errors.New("bet swap not found")
+
+// BetSwapsStore contains the methods used to store and retrieve the submarine swaps paying bet
+// invoices.
+type BetSwapsStore interface {
+	Add(swap BetSwap) error
+	Get(id string) (BetSwap, error)
+	SetStatus(id, status string, updatedAt int64) error
+}
+
+// BetSwap is a submarine swap paying the invoice of a bet with on-chain funds. The bet is
+// registered like any other once the invoice is paid.
+type BetSwap struct {
+	ID          string `json:"id"`
+	PaymentHash string `json:"payment_hash"`
+	PublicKey   string `json:"public_key"`
+	Address     string `json:"address"`
+	Status      string `json:"status"`
+	// ExpectedAmount is the amount of sats to send on-chain, including the provider fees
+	ExpectedAmount     uint64 `json:"expected_amount"`
+	Amount             uint64 `json:"amount"`
+	TimeoutBlockHeight uint32 `json:"timeout_block_height"`
+	CreatedAt          int64  `json:"created_at"`
+	UpdatedAt          int64  `json:"updated_at"`
+}
+
+type betSwaps struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newBetSwapsStore returns a new bet swaps storage service.
+func newBetSwapsStore(db *sql.DB, logger *logger.Logger) BetSwapsStore {
+	return &betSwaps{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add saves a bet swap.
+func (b *betSwaps) Add(swap BetSwap) error {
+	query := `INSERT INTO bet_swaps (id, payment_hash, public_key, address, status, expected_amount,
+	amount, timeout_block_height, created_at, updated_at) VALUES (?,?,?,?,?,?,?,?,?,?)`
+	stmt, err := b.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(swap.ID, swap.PaymentHash, swap.PublicKey, swap.Address, swap.Status,
+		swap.ExpectedAmount, swap.Amount, swap.TimeoutBlockHeight, swap.CreatedAt, swap.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "adding bet swap")
+	}
+
+	return nil
+}
+
+// Get returns the bet swap with the ID specified.
+func (b *betSwaps) Get(id string) (BetSwap, error) {
+	query := `SELECT id, payment_hash, public_key, address, status, expected_amount, amount,
+	timeout_block_height, created_at, updated_at FROM bet_swaps WHERE id=?`
+	stmt, err := b.db.Prepare(query)
+	if err != nil {
+		return BetSwap{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var swap BetSwap
+	err = stmt.QueryRow(id).Scan(&swap.ID, &swap.PaymentHash, &swap.PublicKey, &swap.Address,
+		&swap.Status, &swap.ExpectedAmount, &swap.Amount, &swap.TimeoutBlockHeight, &swap.CreatedAt,
+		&swap.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return BetSwap{}, ErrBetSwapNotFound
+		}
+		return BetSwap{}, errors.Wrap(err, "getting bet swap")
+	}
+
+	return swap, nil
+}
+
+// SetStatus updates the status of the bet swap.
+func (b *betSwaps) SetStatus(id, status string, updatedAt int64) error {
+	stmt, err := b.db.Prepare("UPDATE bet_swaps SET status=?, updated_at=? WHERE id=?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(status, updatedAt, id)
+	if err != nil {
+		return errors.Wrap(err, "updating bet swap status")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "getting rows affected")
+	}
+	if affected == 0 {
+		return ErrBetSwapNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// BetSwapsStoreMock is a mocked implementation of the bet swaps store.
+type BetSwapsStoreMock struct {
+	mock.Mock
+}
+
+// NewBetSwapsStoreMock returns a mocked bet swaps store.
+func NewBetSwapsStoreMock() *BetSwapsStoreMock {
+	return &BetSwapsStoreMock{}
+}
+
+// Add mock.
+func (b *BetSwapsStoreMock) Add(swap BetSwap) error {
+	args := b.Called(swap)
+	return args.Error(0)
+}
+
+// Get mock.
+func (b *BetSwapsStoreMock) Get(id string) (BetSwap, error) {
+	args := b.Called(id)
+	return args.Get(0).(BetSwap), args.Error(1)
+}
+
+// SetStatus mock.
+func (b *BetSwapsStoreMock) SetStatus(id, status string, updatedAt int64) error {
+	args := b.Called(id, status, updatedAt)
+	return args.Error(0)
+}
@@ -0,0 +1,57 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type BetSwapsSuite struct {
+	suite.Suite
+
+	db *database.DB
+}
+
+func TestBetSwapsSuite(t *testing.T) {
+	suite.Run(t, &BetSwapsSuite{})
+}
+
+func (b *BetSwapsSuite) SetupTest() {
+	b.db = setupDB(b.T(), func(db *sql.DB) {})
+}
+
+func (b *BetSwapsSuite) TestFlow() {
+	swap := database.BetSwap{
+		ID:                 "jkP3hq",
+		PaymentHash:        "hash",
+		PublicKey:          "pubkey",
+		Address:            "bc1pswap",
+		Status:             "swap.created",
+		ExpectedAmount:     10_420,
+		Amount:             10_000,
+		TimeoutBlockHeight: 840_288,
+		CreatedAt:          1_700_000_000,
+		UpdatedAt:          1_700_000_000,
+	}
+	b.NoError(b.db.BetSwaps.Add(swap))
+
+	got, err := b.db.BetSwaps.Get(swap.ID)
+	b.NoError(err)
+	b.Equal(swap, got)
+
+	b.NoError(b.db.BetSwaps.SetStatus(swap.ID, "transaction.claimed", 1_700_000_600))
+
+	got, err = b.db.BetSwaps.Get(swap.ID)
+	b.NoError(err)
+	b.Equal("transaction.claimed", got.Status)
+	b.Equal(int64(1_700_000_600), got.UpdatedAt)
+
+	_, err = b.db.BetSwaps.Get("unknown")
+	b.ErrorIs(err, database.ErrBetSwapNotFound)
+
+	err = b.db.BetSwaps.SetStatus("unknown", "swap.expired", 1_700_000_600)
+	b.ErrorIs(err, database.ErrBetSwapNotFound)
+}
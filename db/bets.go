@@ -2,24 +2,59 @@ package db
 
 import (
 	"database/sql"
+	"math"
 
 	"github.com/aftermath2/BTRY/logger"
 
 	"github.com/pkg/errors"
 )
 
+var (
+	// ErrBetNotFound is returned when the public key has no bet with the payment hash specified.
+	ErrBetNotFound = errors.New("bet not found")
+	// ErrCancellationExpired is returned when the bet can't be cancelled anymore.
+	ErrCancellationExpired = errors.New("bet cancellation window expired")
+	// ErrTicketNotFound is returned when no bet holds the ticket specified.
+	ErrTicketNotFound = errors.New("ticket not found")
+	// ErrInsufficientFeeBalance is returned when the fees collected can't fund the free tickets.
+	ErrInsufficientFeeBalance = errors.New("insufficient fee balance")
+)
+
 // BetsStore contains the methods used to store and retrieve bets from the database.
 type BetsStore interface {
-	Add(bet Bet) error
-	GetPrizePool(lotteryHeight uint32) (uint64, error)
-	List(lotteryHeight uint32, offset, limit uint64, reverse bool) ([]Bet, error)
+	Add(bet Bet, bonusCap uint64, feeRate float64) (Bet, error)
+	Airdrop(publicKeys []string, tickets uint64, pool, promo string, createdAt int64) ([]Bet, error)
+	Cancel(publicKey, paymentHash string, placedAfter int64, fee float64, sign ReceiptSigner) (Bet, uint64, error)
+	Compact(lotteryHeight uint32) (uint64, error)
+	Exists(paymentHash string) (bool, error)
+	GetFeeBalance() (uint64, error)
+	GetPrizePool(lotteryHeight uint32, pool string) (uint64, error)
+	GetPublicKey(lotteryHeight uint32, pool string, ticket uint64) (string, error)
+	List(lotteryHeight uint32, pool string, offset, limit uint64, reverse bool) ([]Bet, error)
+	ListPlayers(lotteryHeight uint32) ([]string, error)
+	ListPools(lotteryHeight uint32) ([]string, error)
+	Move(fromHeight, toHeight uint32, sign ReceiptSigner) error
 }
 
 // Bet represents a user bet.
+//
+// Tickets include the bonus ones, which are only informative. Ticket indexes start from one in
+// each pool, a bet holds the range from FirstTicket to Index, inclusive.
+//
+// Bets airdropped by the operators have a Promo tag and all their tickets are bonus ones. Bets
+// placed by the operators themselves, when they are allowed to play, are flagged as House.
 type Bet struct {
-	PublicKey string `json:"public_key,omitempty" db:"public_key"`
-	Index     uint64 `json:"index,omitempty"`
-	Tickets   uint64 `json:"tickets,omitempty"`
+	PublicKey     string `json:"public_key,omitempty" db:"public_key"`
+	Pool          string `json:"pool,omitempty"`
+	FirstTicket   uint64 `json:"first_ticket,omitempty" db:"first_idx"`
+	Index         uint64 `json:"index,omitempty"`
+	Tickets       uint64 `json:"tickets,omitempty"`
+	Bonus         uint64 `json:"bonus,omitempty"`
+	Promo         string `json:"promo,omitempty"`
+	House         bool   `json:"house,omitempty"`
+	LotteryHeight uint32 `json:"-" db:"lottery_height"`
+	PaymentHash   string `json:"-" db:"payment_hash"`
+	CreatedAt     int64  `json:"-" db:"created_at"`
 }
 
 type bets struct {
@@ -35,48 +70,421 @@ func newBetsStore(db *sql.DB, logger *logger.Logger) BetsStore {
 	}
 }
 
-// Add saves a bet in the database.
-func (b *bets) Add(bet Bet) error {
+// Add saves a bet in the database, in the pool specified, and returns it as stored.
+//
+// The bet bonus tickets are reduced so the bonus tickets issued in the lottery don't exceed the
+// cap. They are funded from the fee of the pool, so the ones issued in it don't exceed feeRate
+// percent of the tickets paid in it either, and the prizes never add up to more than the sats
+// received.
+func (b *bets) Add(bet Bet, bonusCap uint64, feeRate float64) (Bet, error) {
 	tx, err := b.db.Begin()
 	if err != nil {
-		return errors.Wrap(err, "starting transaction")
+		return Bet{}, errors.Wrap(err, "starting transaction")
 	}
 	defer tx.Rollback()
 
 	height, err := getNextHeight(tx)
 	if err != nil {
-		return err
+		return Bet{}, err
 	}
 
-	highestIndex, err := getHighestIndex(tx, height)
+	highestIndex, err := getHighestIndex(tx, height, bet.Pool)
 	if err != nil {
-		return err
+		return Bet{}, err
+	}
+
+	if bet.Bonus > 0 {
+		issuedBonus, err := getIssuedBonus(tx, height)
+		if err != nil {
+			return Bet{}, err
+		}
+
+		bet.Bonus = min(bet.Bonus, bonusCap-min(issuedBonus, bonusCap))
+
+		paid, poolBonus, err := getPoolFunding(tx, height, bet.Pool)
+		if err != nil {
+			return Bet{}, err
+		}
+
+		fee := uint64(math.Floor(float64(paid+bet.Tickets) * feeRate / 100))
+		bet.Bonus = min(bet.Bonus, fee-min(poolBonus, fee))
 	}
 
-	query := "INSERT INTO bets (idx, tickets, public_key, lottery_height) VALUES (?,?,?,?)"
+	query := `INSERT INTO bets (first_idx, idx, tickets, bonus, public_key, lottery_height, pool,
+	payment_hash, house, created_at) VALUES (?,?,?,?,?,?,?,?,?,?)`
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		return errors.Wrap(err, "preparing statement")
+		return Bet{}, errors.Wrap(err, "preparing statement")
 	}
 	defer stmt.Close()
 
-	index := highestIndex + bet.Tickets
-	if _, err := stmt.Exec(index, bet.Tickets, bet.PublicKey, height); err != nil {
-		return errors.Wrap(err, "adding bet")
+	bet.Tickets += bet.Bonus
+	bet.FirstTicket = highestIndex + 1
+	bet.Index = highestIndex + bet.Tickets
+	bet.LotteryHeight = height
+	_, err = stmt.Exec(bet.FirstTicket, bet.Index, bet.Tickets, bet.Bonus, bet.PublicKey, height,
+		bet.Pool, bet.PaymentHash, bet.House, bet.CreatedAt)
+	if err != nil {
+		return Bet{}, errors.Wrap(err, "adding bet")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Bet{}, errors.Wrap(err, "committing transaction")
+	}
+
+	return bet, nil
+}
+
+// Airdrop gives free tickets in a pool of the next lottery to each of the public keys, tagged with
+// the promotion, and returns the bets stored.
+//
+// The tickets are bonus ones funded by the fee balance, the airdrop fails with
+// ErrInsufficientFeeBalance if it can't cover all of them. Public keys listed more than once
+// receive the tickets once.
+func (b *bets) Airdrop(publicKeys []string, tickets uint64, pool, promo string, createdAt int64) ([]Bet, error) {
+	publicKeys = dedupe(publicKeys)
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return nil, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	balance, err := getFeeBalance(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if balance < tickets*uint64(len(publicKeys)) {
+		return nil, ErrInsufficientFeeBalance
+	}
+
+	height, err := getNextHeight(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	highestIndex, err := getHighestIndex(tx, height, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO bets (first_idx, idx, tickets, bonus, public_key, lottery_height, pool,
+	promo, created_at) VALUES (?,?,?,?,?,?,?,?,?)`
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	bets := make([]Bet, 0, len(publicKeys))
+	for _, publicKey := range publicKeys {
+		bet := Bet{
+			PublicKey:     publicKey,
+			Pool:          pool,
+			FirstTicket:   highestIndex + 1,
+			Index:         highestIndex + tickets,
+			Tickets:       tickets,
+			Bonus:         tickets,
+			Promo:         promo,
+			LotteryHeight: height,
+			CreatedAt:     createdAt,
+		}
+		_, err := stmt.Exec(bet.FirstTicket, bet.Index, bet.Tickets, bet.Bonus, bet.PublicKey, height,
+			bet.Pool, bet.Promo, bet.CreatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "adding bet")
+		}
+
+		highestIndex = bet.Index
+		bets = append(bets, bet)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "committing transaction")
+	}
+
+	return bets, nil
+}
+
+// Cancel removes a bet of the lottery that hasn't been drawn yet, if it was placed after
+// placedAfter, and returns it along with the amount refunded.
+//
+// The tickets paid minus the fee, a percentage, are refunded as a prize of the lottery, the fee is
+// added to the fee balance, and the tickets of the bets placed later in the pool are moved down so
+// the indexes remain contiguous. The receipts of the bets moved are signed again with their new
+// range. The refund, the fee, the release of the bet exposure and the receipts are stored in the
+// same transaction, so a cancelled bet is never left unrefunded and the receipts issued always
+// match the tickets held.
+func (b *bets) Cancel(
+	publicKey, paymentHash string,
+	placedAfter int64,
+	fee float64,
+	sign ReceiptSigner,
+) (Bet, uint64, error) {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return Bet{}, 0, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	query := `SELECT first_idx, idx, tickets, bonus, lottery_height, pool, created_at FROM bets
+	WHERE public_key=? AND payment_hash=?`
+	bet := Bet{PublicKey: publicKey, PaymentHash: paymentHash}
+	err = tx.QueryRow(query, publicKey, paymentHash).Scan(&bet.FirstTicket, &bet.Index, &bet.Tickets,
+		&bet.Bonus, &bet.LotteryHeight, &bet.Pool, &bet.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Bet{}, 0, ErrBetNotFound
+		}
+		return Bet{}, 0, errors.Wrap(err, "getting bet")
+	}
+
+	height, err := getNextHeight(tx)
+	if err != nil {
+		return Bet{}, 0, err
+	}
+
+	if bet.LotteryHeight != height || bet.CreatedAt <= placedAfter {
+		return Bet{}, 0, ErrCancellationExpired
+	}
+
+	query = "DELETE FROM bets WHERE idx=? AND lottery_height=? AND pool=?"
+	if _, err := tx.Exec(query, bet.Index, bet.LotteryHeight, bet.Pool); err != nil {
+		return Bet{}, 0, errors.Wrap(err, "deleting bet")
+	}
+
+	// Every index is moved by the same number of tickets, so they never collide with each other
+	query = `UPDATE bets SET first_idx = first_idx - ?, idx = idx - ?
+	WHERE idx > ? AND lottery_height=? AND pool=?`
+	_, err = tx.Exec(query, bet.Tickets, bet.Tickets, bet.Index, bet.LotteryHeight, bet.Pool)
+	if err != nil {
+		return Bet{}, 0, errors.Wrap(err, "releasing tickets")
+	}
+
+	where := "b.lottery_height=? AND b.pool=? AND b.first_idx >= ?"
+	if err := reissueReceipts(tx, sign, where, bet.LotteryHeight, bet.Pool, bet.FirstTicket); err != nil {
+		return Bet{}, 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM exposure WHERE payment_hash=?", paymentHash); err != nil {
+		return Bet{}, 0, errors.Wrap(err, "deleting exposure")
+	}
+
+	// The receipt no longer proves the tickets were held
+	if _, err := tx.Exec("DELETE FROM receipts WHERE payment_hash=?", paymentHash); err != nil {
+		return Bet{}, 0, errors.Wrap(err, "deleting receipt")
+	}
+
+	// Bonus tickets were not paid for
+	paid := bet.Tickets - bet.Bonus
+	refund := paid - uint64(float64(paid)*fee/100)
+	if refund > 0 {
+		query = "INSERT INTO prizes (public_key, amount, lottery_height) VALUES (?,?,?)"
+		if _, err := tx.Exec(query, publicKey, refund, bet.LotteryHeight); err != nil {
+			return Bet{}, 0, errors.Wrap(err, "storing refund")
+		}
 	}
 
-	return tx.Commit()
+	// The fee retained is added to the fee balance, the bet is no longer part of any prize pool
+	if retained := paid - refund; retained > 0 {
+		query = `INSERT INTO cancellation_fees (payment_hash, public_key, lottery_height, amount)
+		VALUES (?,?,?,?)`
+		if _, err := tx.Exec(query, paymentHash, publicKey, bet.LotteryHeight, retained); err != nil {
+			return Bet{}, 0, errors.Wrap(err, "storing cancellation fee")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Bet{}, 0, errors.Wrap(err, "committing transaction")
+	}
+
+	return bet, refund, nil
 }
 
-// GetPrizePool returns the prize pool size.
-func (b *bets) GetPrizePool(lotteryHeight uint32) (uint64, error) {
+// reissueReceipts signs again the receipts of the bets matching the condition, whose tickets were
+// moved.
+func reissueReceipts(tx *sql.Tx, sign ReceiptSigner, where string, args ...any) error {
+	query := `SELECT b.payment_hash, b.public_key, b.lottery_height, b.pool, b.first_idx, b.idx,
+	b.tickets, b.bonus FROM bets b JOIN receipts r ON r.payment_hash = b.payment_hash
+	WHERE b.payment_hash != '' AND ` + where
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return errors.Wrap(err, "listing moved bets")
+	}
+
+	var moved []Bet
+	for rows.Next() {
+		var bet Bet
+		err := rows.Scan(&bet.PaymentHash, &bet.PublicKey, &bet.LotteryHeight, &bet.Pool,
+			&bet.FirstTicket, &bet.Index, &bet.Tickets, &bet.Bonus)
+		if err != nil {
+			rows.Close()
+			return errors.Wrap(err, "scanning bet")
+		}
+		moved = append(moved, bet)
+	}
+	if err := rows.Close(); err != nil {
+		return errors.Wrap(err, "closing rows")
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "iterating bets")
+	}
+
+	query = `INSERT OR REPLACE INTO receipts (payment_hash, public_key, lottery_height, first_idx, idx,
+	signature, created_at, bonus) VALUES (?,?,?,?,?,?,?,?)`
+	for _, bet := range moved {
+		receipt, err := sign(bet, bet.PaymentHash)
+		if err != nil {
+			return errors.Wrapf(err, "signing receipt of bet %s", bet.PaymentHash)
+		}
+
+		_, err = tx.Exec(query, receipt.PaymentHash, receipt.PublicKey, receipt.Round,
+			receipt.FirstTicket, receipt.LastTicket, receipt.Signature, receipt.Timestamp,
+			receipt.BonusTickets)
+		if err != nil {
+			return errors.Wrap(err, "storing receipt")
+		}
+	}
+
+	return nil
+}
+
+// Compact merges the consecutive bets placed by the same public key in each pool of a lottery into a
+// single one holding their whole range of tickets, and returns the number of bets removed. Bets of
+// different promotions are not merged.
+//
+// The payment hashes of the bets merged are discarded, hence the lottery must have been drawn.
+func (b *bets) Compact(lotteryHeight uint32) (uint64, error) {
 	tx, err := b.db.Begin()
 	if err != nil {
 		return 0, errors.Wrap(err, "starting transaction")
 	}
 	defer tx.Rollback()
 
-	highestIndex, err := getHighestIndex(tx, lotteryHeight)
+	query := `SELECT pool, first_idx, idx, tickets, bonus, public_key, promo, created_at FROM bets
+	WHERE lottery_height=? ORDER BY pool, idx`
+	rows, err := tx.Query(query, lotteryHeight)
+	if err != nil {
+		return 0, errors.Wrap(err, "listing bets")
+	}
+
+	// runs contains the bets merged, the last one of each run is kept with the range of all of them
+	var (
+		runs [][]Bet
+		bet  Bet
+	)
+	for rows.Next() {
+		err := rows.Scan(&bet.Pool, &bet.FirstTicket, &bet.Index, &bet.Tickets, &bet.Bonus,
+			&bet.PublicKey, &bet.Promo, &bet.CreatedAt)
+		if err != nil {
+			rows.Close()
+			return 0, errors.Wrap(err, "scanning bet")
+		}
+
+		if len(runs) > 0 {
+			run := runs[len(runs)-1]
+			last := run[len(run)-1]
+			// Airdropped tickets keep their promotion apart from the paid ones
+			if last.Pool == bet.Pool && last.PublicKey == bet.PublicKey && last.Promo == bet.Promo {
+				runs[len(runs)-1] = append(run, bet)
+				continue
+			}
+		}
+		runs = append(runs, []Bet{bet})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, errors.Wrap(err, "iterating bets")
+	}
+
+	deleteStmt, err := tx.Prepare("DELETE FROM bets WHERE idx=? AND lottery_height=? AND pool=?")
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer deleteStmt.Close()
+
+	updateStmt, err := tx.Prepare(`UPDATE bets SET first_idx=?, tickets=?, bonus=?, payment_hash='',
+	created_at=? WHERE idx=? AND lottery_height=? AND pool=?`)
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer updateStmt.Close()
+
+	removed := uint64(0)
+	for _, run := range runs {
+		if len(run) == 1 {
+			continue
+		}
+
+		merged := run[len(run)-1]
+		merged.FirstTicket = run[0].FirstTicket
+		merged.Tickets, merged.Bonus = 0, 0
+		for _, bet := range run {
+			merged.Tickets += bet.Tickets
+			merged.Bonus += bet.Bonus
+		}
+
+		for _, bet := range run[:len(run)-1] {
+			if _, err := deleteStmt.Exec(bet.Index, lotteryHeight, bet.Pool); err != nil {
+				return 0, errors.Wrap(err, "deleting bet")
+			}
+			removed++
+		}
+
+		_, err := updateStmt.Exec(merged.FirstTicket, merged.Tickets, merged.Bonus, merged.CreatedAt,
+			merged.Index, lotteryHeight, merged.Pool)
+		if err != nil {
+			return 0, errors.Wrap(err, "merging bets")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "committing transaction")
+	}
+
+	return removed, nil
+}
+
+// Exists returns whether a bet was placed with the payment hash specified. Bets compacted after the
+// draw lose their payment hashes.
+func (b *bets) Exists(paymentHash string) (bool, error) {
+	query := "SELECT EXISTS(SELECT 1 FROM bets WHERE payment_hash=?)"
+	stmt, err := b.db.Prepare(query)
+	if err != nil {
+		return false, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var exists bool
+	if err := stmt.QueryRow(paymentHash).Scan(&exists); err != nil {
+		return false, errors.Wrap(err, "checking bet existence")
+	}
+
+	return exists, nil
+}
+
+// GetFeeBalance returns the fees collected in the lotteries drawn minus the bonus tickets of those
+// lotteries and the airdrops, which are funded by them, and the fees distributed.
+func (b *bets) GetFeeBalance() (uint64, error) {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return 0, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	return getFeeBalance(tx)
+}
+
+// GetPrizePool returns the prize pool size of a lottery pool.
+func (b *bets) GetPrizePool(lotteryHeight uint32, pool string) (uint64, error) {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return 0, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	highestIndex, err := getHighestIndex(tx, lotteryHeight, pool)
 	if err != nil {
 		return 0, err
 	}
@@ -84,16 +492,46 @@ func (b *bets) GetPrizePool(lotteryHeight uint32) (uint64, error) {
 	return highestIndex, nil
 }
 
-// List returns a list of bets.
+// GetPublicKey returns the public key of the bet holding the ticket in a lottery pool.
+func (b *bets) GetPublicKey(lotteryHeight uint32, pool string, ticket uint64) (string, error) {
+	// The range starting right before the ticket is found with the bets_ranges index
+	query := `SELECT public_key, idx FROM bets WHERE lottery_height=? AND pool=? AND first_idx <= ?
+	ORDER BY first_idx DESC LIMIT 1`
+	stmt, err := b.db.Prepare(query)
+	if err != nil {
+		return "", errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var (
+		publicKey string
+		index     uint64
+	)
+	if err := stmt.QueryRow(lotteryHeight, pool, ticket).Scan(&publicKey, &index); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrTicketNotFound
+		}
+		return "", errors.Wrap(err, "getting public key")
+	}
+
+	if ticket == 0 || ticket > index {
+		return "", ErrTicketNotFound
+	}
+
+	return publicKey, nil
+}
+
+// List returns a list of the bets placed in a lottery pool.
 //
 // A limit value of 0 means there's no limit.
-func (b *bets) List(lotteryHeight uint32, offset, limit uint64, reverse bool) ([]Bet, error) {
+func (b *bets) List(lotteryHeight uint32, pool string, offset, limit uint64, reverse bool) ([]Bet, error) {
 	// Cap limit to avoid creating a slice with too big capacity
 	if limit > 500 {
 		limit = 500
 	}
 
-	query := "SELECT idx, tickets, public_key FROM bets WHERE lottery_height=?"
+	query := `SELECT first_idx, idx, tickets, bonus, public_key, promo, house FROM bets
+	WHERE lottery_height=? AND pool=?`
 	query = AddPagination(query, offset, limit, "idx", reverse)
 
 	stmt, err := b.db.Prepare(query)
@@ -102,7 +540,7 @@ func (b *bets) List(lotteryHeight uint32, offset, limit uint64, reverse bool) ([
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.Query(lotteryHeight)
+	rows, err := stmt.Query(lotteryHeight, pool)
 	if err != nil {
 		return nil, errors.Wrap(err, "listing bets")
 	}
@@ -110,9 +548,11 @@ func (b *bets) List(lotteryHeight uint32, offset, limit uint64, reverse bool) ([
 
 	bets := make([]Bet, 0, limit)
 	// Reuse object
-	var bet Bet
+	bet := Bet{LotteryHeight: lotteryHeight, Pool: pool}
 	for rows.Next() {
-		if err := rows.Scan(&bet.Index, &bet.Tickets, &bet.PublicKey); err != nil {
+		err := rows.Scan(&bet.FirstTicket, &bet.Index, &bet.Tickets, &bet.Bonus, &bet.PublicKey,
+			&bet.Promo, &bet.House)
+		if err != nil {
 			return nil, err
 		}
 
@@ -122,15 +562,152 @@ func (b *bets) List(lotteryHeight uint32, offset, limit uint64, reverse bool) ([
 	return bets, nil
 }
 
-func getHighestIndex(tx *sql.Tx, lotteryHeight uint32) (uint64, error) {
-	stmt, err := tx.Prepare("SELECT COALESCE(MAX(idx), 0) FROM bets WHERE lottery_height=?")
+// ListPlayers returns the public keys that paid for tickets in a lottery, sorted.
+func (b *bets) ListPlayers(lotteryHeight uint32) ([]string, error) {
+	query := "SELECT DISTINCT public_key FROM bets WHERE lottery_height=? AND promo='' ORDER BY public_key"
+	stmt, err := b.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(lotteryHeight)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing players")
+	}
+	defer rows.Close()
+
+	var (
+		players []string
+		// Reuse object
+		publicKey string
+	)
+	for rows.Next() {
+		if err := rows.Scan(&publicKey); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		players = append(players, publicKey)
+	}
+
+	return players, nil
+}
+
+// ListPools returns the pools that have bets in a lottery, sorted by name.
+func (b *bets) ListPools(lotteryHeight uint32) ([]string, error) {
+	stmt, err := b.db.Prepare("SELECT DISTINCT pool FROM bets WHERE lottery_height=? ORDER BY pool")
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(lotteryHeight)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing pools")
+	}
+	defer rows.Close()
+
+	var (
+		pools []string
+		// Reuse object
+		pool string
+	)
+	for rows.Next() {
+		if err := rows.Scan(&pool); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		pools = append(pools, pool)
+	}
+
+	return pools, nil
+}
+
+// Move transfers the bets of a lottery to another one, along with their exposure to the channel
+// peers they were paid through.
+//
+// The destination lottery may already have bets, the tickets moved are numbered after the last
+// ticket of each pool and their receipts are signed again with the new range.
+func (b *bets) Move(fromHeight, toHeight uint32, sign ReceiptSigner) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	pools, err := listPools(tx, fromHeight)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE bets SET lottery_height=?, first_idx=first_idx+?, idx=idx+?
+	WHERE lottery_height=? AND pool=?`
+	for _, pool := range pools {
+		offset, err := getHighestIndex(tx, toHeight, pool)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(query, toHeight, offset, offset, fromHeight, pool); err != nil {
+			return errors.Wrap(err, "moving bets")
+		}
+
+		if offset == 0 {
+			continue
+		}
+
+		where := "b.lottery_height=? AND b.pool=? AND b.idx > ?"
+		if err := reissueReceipts(tx, sign, where, toHeight, pool, offset); err != nil {
+			return err
+		}
+	}
+
+	// Receipts of the bets that kept their tickets only change the lottery
+	query = `UPDATE receipts SET lottery_height=? WHERE lottery_height=?`
+	if _, err := tx.Exec(query, toHeight, fromHeight); err != nil {
+		return errors.Wrap(err, "moving receipts")
+	}
+
+	query = "UPDATE exposure SET lottery_height=? WHERE lottery_height=?"
+	if _, err := tx.Exec(query, toHeight, fromHeight); err != nil {
+		return errors.Wrap(err, "moving exposure")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
+	return nil
+}
+
+func listPools(tx *sql.Tx, lotteryHeight uint32) ([]string, error) {
+	rows, err := tx.Query("SELECT DISTINCT pool FROM bets WHERE lottery_height=?", lotteryHeight)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing pools")
+	}
+	defer rows.Close()
+
+	var pools []string
+	for rows.Next() {
+		var pool string
+		if err := rows.Scan(&pool); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+		pools = append(pools, pool)
+	}
+
+	return pools, rows.Err()
+}
+
+func getHighestIndex(tx *sql.Tx, lotteryHeight uint32, pool string) (uint64, error) {
+	stmt, err := tx.Prepare("SELECT COALESCE(MAX(idx), 0) FROM bets WHERE lottery_height=? AND pool=?")
 	if err != nil {
 		return 0, errors.Wrap(err, "preparing statement")
 	}
 	defer stmt.Close()
 
 	var index uint64
-	if err := stmt.QueryRow(lotteryHeight).Scan(&index); err != nil {
+	if err := stmt.QueryRow(lotteryHeight, pool).Scan(&index); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, nil
 		}
@@ -139,3 +716,81 @@ func getHighestIndex(tx *sql.Tx, lotteryHeight uint32) (uint64, error) {
 
 	return index, nil
 }
+
+// getFeeBalance returns the fees left after paying the prizes of the lotteries drawn, funding the
+// bonus tickets issued and distributing the shares of the fee, plus the fees retained from the
+// bets cancelled.
+func getFeeBalance(tx *sql.Tx) (uint64, error) {
+	// The prize pools recorded include the bonus tickets of the lotteries drawn. The bonus tickets of
+	// the paid bets are funded by the fee of their lottery once it's drawn, while airdrops are
+	// taken from the balance as soon as they are given
+	query := `SELECT (SELECT total_pool FROM stats)
+	- (SELECT COALESCE(SUM(prize), 0) FROM stats_wins)
+	- (SELECT COALESCE(SUM(bonus), 0) FROM bets
+		WHERE promo != '' OR lottery_height IN (SELECT height FROM stats_rounds))
+	- (SELECT COALESCE(SUM(amount), 0) FROM fee_distributions)
+	+ (SELECT COALESCE(SUM(amount), 0) FROM cancellation_fees)`
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var balance int64
+	if err := stmt.QueryRow().Scan(&balance); err != nil {
+		return 0, errors.Wrap(err, "scanning fee balance")
+	}
+
+	return uint64(max(balance, 0)), nil
+}
+
+// getPoolFunding returns the tickets paid in a pool of a lottery and the bonus tickets given to its
+// bets, which are funded by their fee. Airdrops are funded by the fee balance instead.
+func getPoolFunding(tx *sql.Tx, lotteryHeight uint32, pool string) (uint64, uint64, error) {
+	query := `SELECT COALESCE(SUM(tickets - bonus), 0), COALESCE(SUM(bonus), 0) FROM bets
+	WHERE lottery_height=? AND pool=? AND promo=''`
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var paid, bonus uint64
+	if err := stmt.QueryRow(lotteryHeight, pool).Scan(&paid, &bonus); err != nil {
+		return 0, 0, errors.Wrap(err, "scanning pool funding")
+	}
+
+	return paid, bonus, nil
+}
+
+// getIssuedBonus returns the bonus tickets given to the bets of a lottery, airdrops are excluded
+// from the bonus cap.
+func getIssuedBonus(tx *sql.Tx, lotteryHeight uint32) (uint64, error) {
+	stmt, err := tx.Prepare("SELECT COALESCE(SUM(bonus), 0) FROM bets WHERE lottery_height=? AND promo=''")
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var bonus uint64
+	if err := stmt.QueryRow(lotteryHeight).Scan(&bonus); err != nil {
+		return 0, errors.Wrap(err, "scanning issued bonus")
+	}
+
+	return bonus, nil
+}
+
+// dedupe returns the public keys without repetitions, in the order they were first listed.
+func dedupe(publicKeys []string) []string {
+	seen := make(map[string]struct{}, len(publicKeys))
+	unique := make([]string, 0, len(publicKeys))
+	for _, publicKey := range publicKeys {
+		if _, ok := seen[publicKey]; ok {
+			continue
+		}
+		seen[publicKey] = struct{}{}
+		unique = append(unique, publicKey)
+	}
+
+	return unique
+}
@@ -13,19 +13,78 @@ func NewBetsStoreMock() *BetsStoreMock {
 }
 
 // Add mock.
-func (b *BetsStoreMock) Add(bet Bet) error {
-	args := b.Called(bet)
-	return args.Error(0)
+func (b *BetsStoreMock) Add(bet Bet, bonusCap uint64, feeRate float64) (Bet, error) {
+	args := b.Called(bet, bonusCap, feeRate)
+	return args.Get(0).(Bet), args.Error(1)
 }
 
-// GetPrizePool mock.
-func (b *BetsStoreMock) GetPrizePool(lotteryHeight uint32) (uint64, error) {
+// Airdrop mock.
+func (b *BetsStoreMock) Airdrop(publicKeys []string, tickets uint64, pool, promo string, createdAt int64) ([]Bet, error) {
+	args := b.Called(publicKeys, tickets, pool, promo, createdAt)
+	return args.Get(0).([]Bet), args.Error(1)
+}
+
+// Cancel mock.
+func (b *BetsStoreMock) Cancel(
+	publicKey, paymentHash string,
+	placedAfter int64,
+	fee float64,
+	sign ReceiptSigner,
+) (Bet, uint64, error) {
+	args := b.Called(publicKey, paymentHash, placedAfter, fee)
+	return args.Get(0).(Bet), args.Get(1).(uint64), args.Error(2)
+}
+
+// Compact mock.
+func (b *BetsStoreMock) Compact(lotteryHeight uint32) (uint64, error) {
 	args := b.Called(lotteryHeight)
 	return args.Get(0).(uint64), args.Error(1)
 }
 
+// Exists mock.
+func (b *BetsStoreMock) Exists(paymentHash string) (bool, error) {
+	args := b.Called(paymentHash)
+	return args.Bool(0), args.Error(1)
+}
+
+// GetFeeBalance mock.
+func (b *BetsStoreMock) GetFeeBalance() (uint64, error) {
+	args := b.Called()
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+// GetPrizePool mock.
+func (b *BetsStoreMock) GetPrizePool(lotteryHeight uint32, pool string) (uint64, error) {
+	args := b.Called(lotteryHeight, pool)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+// GetPublicKey mock.
+func (b *BetsStoreMock) GetPublicKey(lotteryHeight uint32, pool string, ticket uint64) (string, error) {
+	args := b.Called(lotteryHeight, pool, ticket)
+	return args.String(0), args.Error(1)
+}
+
 // List mock.
-func (b *BetsStoreMock) List(lotteryHeight uint32, offset, limit uint64, reverse bool) ([]Bet, error) {
-	args := b.Called(lotteryHeight, offset, limit, reverse)
+func (b *BetsStoreMock) List(lotteryHeight uint32, pool string, offset, limit uint64, reverse bool) ([]Bet, error) {
+	args := b.Called(lotteryHeight, pool, offset, limit, reverse)
 	return args.Get(0).([]Bet), args.Error(1)
 }
+
+// ListPlayers mock.
+func (b *BetsStoreMock) ListPlayers(lotteryHeight uint32) ([]string, error) {
+	args := b.Called(lotteryHeight)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// ListPools mock.
+func (b *BetsStoreMock) ListPools(lotteryHeight uint32) ([]string, error) {
+	args := b.Called(lotteryHeight)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// Move mock.
+func (b *BetsStoreMock) Move(fromHeight, toHeight uint32, sign ReceiptSigner) error {
+	args := b.Called(fromHeight, toHeight, sign)
+	return args.Error(0)
+}
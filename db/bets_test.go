@@ -12,21 +12,30 @@ import (
 
 var (
 	firstBet = database.Bet{
-		Index:     15,
-		PublicKey: "7d959d6d552c7d38b3ecafb72805fa03a6dee6b7f0c5f63f57a371736cb004b1",
-		Tickets:   15,
+		FirstTicket:   1,
+		Index:         15,
+		PublicKey:     "7d959d6d552c7d38b3ecafb72805fa03a6dee6b7f0c5f63f57a371736cb004b1",
+		Tickets:       15,
+		LotteryHeight: lotteryHeight,
 	}
 	secondBet = database.Bet{
-		Index:     33,
-		PublicKey: "876baf90c3d2d26c04ba1d208c29605b2c6fd13fbb3f6b46cf7f10ece3dac69d",
-		Tickets:   18,
+		FirstTicket:   16,
+		Index:         33,
+		PublicKey:     "876baf90c3d2d26c04ba1d208c29605b2c6fd13fbb3f6b46cf7f10ece3dac69d",
+		Tickets:       18,
+		LotteryHeight: lotteryHeight,
 	}
 )
 
 type BetsSuite struct {
 	suite.Suite
 
-	db database.BetsStore
+	db        database.BetsStore
+	lotteries database.LotteriesStore
+	prizes    database.PrizesStore
+	stats     database.StatsStore
+	exposure  database.ExposureStore
+	fees      database.FeeDistributionsStore
 }
 
 func TestBetsSuite(t *testing.T) {
@@ -39,12 +48,19 @@ func (b *BetsSuite) SetupTest() {
 		_, err := db.Exec(lotteriesQuery, lotteryHeight)
 		b.NoError(err)
 		query := `DELETE FROM bets;
-		INSERT INTO bets (idx, public_key, tickets, lottery_height) VALUES (?,?,?,?), (?,?,?,?);`
-		_, err = db.Exec(query, firstBet.Index, firstBet.PublicKey, firstBet.Tickets, lotteryHeight,
-			secondBet.Index, secondBet.PublicKey, secondBet.Tickets, lotteryHeight)
+		INSERT INTO bets (first_idx, idx, public_key, tickets, lottery_height) VALUES
+		(?,?,?,?,?), (?,?,?,?,?);`
+		_, err = db.Exec(query,
+			firstBet.FirstTicket, firstBet.Index, firstBet.PublicKey, firstBet.Tickets, lotteryHeight,
+			secondBet.FirstTicket, secondBet.Index, secondBet.PublicKey, secondBet.Tickets, lotteryHeight)
 		b.NoError(err)
 	})
 	b.db = db.Bets
+	b.lotteries = db.Lotteries
+	b.prizes = db.Prizes
+	b.stats = db.Stats
+	b.exposure = db.Exposure
+	b.fees = db.Fees
 }
 
 func (b *BetsSuite) TestAdd() {
@@ -52,21 +68,418 @@ func (b *BetsSuite) TestAdd() {
 		PublicKey: "99c1c20dfa84ca4a475359d8f6e711cb42923c7d2792b9e278766cb4801b8917",
 		Tickets:   10,
 	}
-	err := b.db.Add(bet)
+	stored, err := b.db.Add(bet, 0, 0)
 	b.NoError(err)
 
-	bets, err := b.db.List(lotteryHeight, 0, 0, false)
+	bets, err := b.db.List(lotteryHeight, "", 0, 0, false)
 	b.NoError(err)
 
 	b.Len(bets, 3)
 	b.Equal(bet.PublicKey, bets[2].PublicKey)
 	expectedIndex := firstBet.Tickets + secondBet.Tickets + bet.Tickets
 	b.Equal(expectedIndex, bets[2].Index)
+	b.Equal(secondBet.Index+1, bets[2].FirstTicket)
 	b.Equal(bet.Tickets, bets[2].Tickets)
+	b.Equal(bets[2], stored)
+}
+
+func (b *BetsSuite) TestAddPool() {
+	bet := database.Bet{
+		PublicKey: "99c1c20dfa84ca4a475359d8f6e711cb42923c7d2792b9e278766cb4801b8917",
+		Tickets:   1_000_000,
+		Pool:      "whale",
+	}
+	stored, err := b.db.Add(bet, 0, 0)
+	b.NoError(err)
+
+	// Tickets indexes start from one in each pool
+	b.Equal(bet.Tickets, stored.Index)
+	b.Equal("whale", stored.Pool)
+
+	bets, err := b.db.List(lotteryHeight, "whale", 0, 0, false)
+	b.NoError(err)
+	b.Equal([]database.Bet{stored}, bets)
+
+	prizePool, err := b.db.GetPrizePool(lotteryHeight, "")
+	b.NoError(err)
+	b.Equal(secondBet.Index, prizePool)
+
+	prizePool, err = b.db.GetPrizePool(lotteryHeight, "whale")
+	b.NoError(err)
+	b.Equal(bet.Tickets, prizePool)
+
+	pools, err := b.db.ListPools(lotteryHeight)
+	b.NoError(err)
+	b.Equal([]string{"", "whale"}, pools)
+}
+
+func (b *BetsSuite) TestAddHouse() {
+	bet := database.Bet{
+		PublicKey: "99c1c20dfa84ca4a475359d8f6e711cb42923c7d2792b9e278766cb4801b8917",
+		Tickets:   10,
+		House:     true,
+	}
+	stored, err := b.db.Add(bet, 0, 0)
+	b.NoError(err)
+	b.True(stored.House)
+
+	bets, err := b.db.List(lotteryHeight, "", 0, 0, false)
+	b.NoError(err)
+	b.False(bets[0].House)
+	b.False(bets[1].House)
+	b.Equal(stored, bets[2])
+}
+
+func (b *BetsSuite) TestAddBonus() {
+	publicKey := "99c1c20dfa84ca4a475359d8f6e711cb42923c7d2792b9e278766cb4801b8917"
+	bonusCap := uint64(15)
+	feeRate := float64(50)
+
+	bet, err := b.db.Add(database.Bet{PublicKey: publicKey, Tickets: 100, Bonus: 10}, bonusCap, feeRate)
+	b.NoError(err)
+	b.Equal(uint64(110), bet.Tickets)
+	b.Equal(uint64(10), bet.Bonus)
+	b.Equal(secondBet.Index+bet.Tickets, bet.Index)
+
+	// Only 5 bonus tickets left in this lottery
+	bet, err = b.db.Add(database.Bet{PublicKey: publicKey, Tickets: 100, Bonus: 10}, bonusCap, feeRate)
+	b.NoError(err)
+	b.Equal(uint64(105), bet.Tickets)
+	b.Equal(uint64(5), bet.Bonus)
+
+	bet, err = b.db.Add(database.Bet{PublicKey: publicKey, Tickets: 100, Bonus: 10}, bonusCap, feeRate)
+	b.NoError(err)
+	b.Equal(uint64(100), bet.Tickets)
+	b.Zero(bet.Bonus)
+
+	prizePool, err := b.db.GetPrizePool(lotteryHeight, "")
+	b.NoError(err)
+	b.Equal(secondBet.Index+315, prizePool)
+}
+
+func (b *BetsSuite) TestAddBonusFee() {
+	publicKey := "99c1c20dfa84ca4a475359d8f6e711cb42923c7d2792b9e278766cb4801b8917"
+	bonusCap := uint64(1_000)
+	feeRate := float64(10)
+
+	// The fee of the 133 tickets paid funds 13 bonus tickets
+	bet, err := b.db.Add(database.Bet{PublicKey: publicKey, Tickets: 100, Bonus: 20}, bonusCap, feeRate)
+	b.NoError(err)
+	b.Equal(uint64(13), bet.Bonus)
+
+	bet, err = b.db.Add(database.Bet{PublicKey: publicKey, Tickets: 67, Bonus: 20}, bonusCap, feeRate)
+	b.NoError(err)
+	b.Equal(uint64(7), bet.Bonus)
+
+	// Each pool funds its own bonus tickets
+	bet, err = b.db.Add(database.Bet{PublicKey: publicKey, Tickets: 100, Bonus: 20, Pool: "vip"},
+		bonusCap, feeRate)
+	b.NoError(err)
+	b.Equal(uint64(10), bet.Bonus)
+
+	prizePool, err := b.db.GetPrizePool(lotteryHeight, "")
+	b.NoError(err)
+	b.Equal(secondBet.Index+100+67+20, prizePool)
+}
+
+func (b *BetsSuite) TestAirdrop() {
+	publicKeys := []string{firstBet.PublicKey, "99c1c20dfa84ca4a475359d8f6e711cb42923c7d2792b9e278766cb4801b8917"}
+
+	_, err := b.db.Airdrop(publicKeys, 5, "", "launch", 1)
+	b.ErrorIs(err, database.ErrInsufficientFeeBalance)
+
+	// 100 sats of fees collected in the previous lottery
+	winners := []database.Winner{{PublicKey: secondBet.PublicKey, Prize: 900}}
+	err = b.stats.AddRound(database.RoundStats{Height: 1, PrizePool: 1_000}, winners, 0)
+	b.NoError(err)
+
+	balance, err := b.db.GetFeeBalance()
+	b.NoError(err)
+	b.Equal(uint64(100), balance)
+
+	bets, err := b.db.Airdrop(publicKeys, 5, "", "launch", 1)
+	b.NoError(err)
+
+	expected := []database.Bet{
+		{
+			PublicKey:     publicKeys[0],
+			FirstTicket:   secondBet.Index + 1,
+			Index:         secondBet.Index + 5,
+			Tickets:       5,
+			Bonus:         5,
+			Promo:         "launch",
+			LotteryHeight: lotteryHeight,
+			CreatedAt:     1,
+		},
+		{
+			PublicKey:     publicKeys[1],
+			FirstTicket:   secondBet.Index + 6,
+			Index:         secondBet.Index + 10,
+			Tickets:       5,
+			Bonus:         5,
+			Promo:         "launch",
+			LotteryHeight: lotteryHeight,
+			CreatedAt:     1,
+		},
+	}
+	b.Equal(expected, bets)
+
+	balance, err = b.db.GetFeeBalance()
+	b.NoError(err)
+	b.Equal(uint64(90), balance)
+
+	// Airdrops don't use the bonus cap of the paid bets
+	bet, err := b.db.Add(database.Bet{PublicKey: publicKeys[1], Tickets: 10, Bonus: 5}, 5, 50)
+	b.NoError(err)
+	b.Equal(uint64(5), bet.Bonus)
+
+	_, err = b.db.Airdrop(publicKeys, 50, "", "launch", 2)
+	b.ErrorIs(err, database.ErrInsufficientFeeBalance)
+
+	players, err := b.db.ListPlayers(lotteryHeight)
+	b.NoError(err)
+	b.Equal([]string{firstBet.PublicKey, secondBet.PublicKey, publicKeys[1]}, players)
+}
+
+func (b *BetsSuite) TestFeeBalance() {
+	bet := database.Bet{PublicKey: firstBet.PublicKey, Tickets: 20, Bonus: 10}
+	_, err := b.db.Add(bet, 10, 100)
+	b.NoError(err)
+
+	// 100 sats of fees collected in the lottery drawn, 10 of them fund its bonus tickets
+	winners := []database.Winner{{PublicKey: secondBet.PublicKey, Prize: 900}}
+	err = b.stats.AddRound(database.RoundStats{Height: lotteryHeight, PrizePool: 1_000}, winners, 0)
+	b.NoError(err)
+
+	balance, err := b.db.GetFeeBalance()
+	b.NoError(err)
+	b.Equal(uint64(90), balance)
+
+	// The bonus tickets of the next lottery are funded by its own fee once it's drawn
+	err = b.lotteries.AddHeight(lotteryHeight+144, "", "")
+	b.NoError(err)
+	_, err = b.db.Add(bet, 10, 100)
+	b.NoError(err)
+
+	balance, err = b.db.GetFeeBalance()
+	b.NoError(err)
+	b.Equal(uint64(90), balance)
+
+	// Public keys listed twice receive the airdrop once
+	bets, err := b.db.Airdrop([]string{bet.PublicKey, bet.PublicKey}, 5, "", "launch", 1)
+	b.NoError(err)
+	b.Len(bets, 1)
+
+	balance, err = b.db.GetFeeBalance()
+	b.NoError(err)
+	b.Equal(uint64(85), balance)
+
+	// Shares of the fee distributed leave the balance
+	err = b.fees.Add([]database.FeeDistribution{
+		{LotteryHeight: lotteryHeight, Destination: "dev", Address: "dev@example.com", Amount: 30},
+	})
+	b.NoError(err)
+
+	balance, err = b.db.GetFeeBalance()
+	b.NoError(err)
+	b.Equal(uint64(55), balance)
+}
+
+func (b *BetsSuite) TestCancel() {
+	publicKey := "99c1c20dfa84ca4a475359d8f6e711cb42923c7d2792b9e278766cb4801b8917"
+	bet, err := b.db.Add(database.Bet{
+		PublicKey:   publicKey,
+		Tickets:     100,
+		Bonus:       10,
+		PaymentHash: "first_hash",
+		CreatedAt:   100,
+	}, 10, 0)
+	b.NoError(err)
+
+	last, err := b.db.Add(database.Bet{
+		PublicKey:   publicKey,
+		Tickets:     20,
+		PaymentHash: "second_hash",
+		CreatedAt:   200,
+	}, 10, 0)
+	b.NoError(err)
+
+	cancelled, refund, err := b.db.Cancel(publicKey, bet.PaymentHash, 50, 1, signReceipt)
+	b.NoError(err)
+	b.Equal(bet.Index, cancelled.Index)
+	b.Equal(bet.Tickets, cancelled.Tickets)
+	// Bonus tickets are not refunded and the fee is rounded down
+	b.Equal(uint64(99), refund)
+
+	prizes, err := b.prizes.Get(publicKey)
+	b.NoError(err)
+	b.Equal(refund, prizes)
+
+	// The tickets of the following bets are released back
+	bets, err := b.db.List(lotteryHeight, "", 0, 0, false)
+	b.NoError(err)
+	b.Len(bets, 3)
+	b.Equal(secondBet.Index+1, bets[2].FirstTicket)
+	b.Equal(secondBet.Index+last.Tickets, bets[2].Index)
+	b.Equal(last.Tickets, bets[2].Tickets)
+
+	prizePool, err := b.db.GetPrizePool(lotteryHeight, "")
+	b.NoError(err)
+	b.Equal(secondBet.Index+last.Tickets, prizePool)
+
+	// The fee retained is added to the balance
+	balance, err := b.db.GetFeeBalance()
+	b.NoError(err)
+	b.Equal(uint64(1), balance)
+
+	_, _, err = b.db.Cancel(publicKey, bet.PaymentHash, 50, 1, signReceipt)
+	b.ErrorIs(err, database.ErrBetNotFound)
+}
+
+func (b *BetsSuite) TestCancelErrors() {
+	publicKey := "99c1c20dfa84ca4a475359d8f6e711cb42923c7d2792b9e278766cb4801b8917"
+	bet, err := b.db.Add(database.Bet{
+		PublicKey:   publicKey,
+		Tickets:     100,
+		PaymentHash: "hash",
+		CreatedAt:   100,
+	}, 0, 0)
+	b.NoError(err)
+
+	_, _, err = b.db.Cancel(firstBet.PublicKey, bet.PaymentHash, 50, 0, signReceipt)
+	b.ErrorIs(err, database.ErrBetNotFound)
+
+	_, _, err = b.db.Cancel(publicKey, bet.PaymentHash, 100, 0, signReceipt)
+	b.ErrorIs(err, database.ErrCancellationExpired)
+
+	// Bets of lotteries already drawn can't be cancelled
+	err = b.lotteries.AddHeight(lotteryHeight+144, "", "")
+	b.NoError(err)
+
+	_, _, err = b.db.Cancel(publicKey, bet.PaymentHash, 50, 0, signReceipt)
+	b.ErrorIs(err, database.ErrCancellationExpired)
+}
+
+func (b *BetsSuite) TestCompact() {
+	publicKey := "99c1c20dfa84ca4a475359d8f6e711cb42923c7d2792b9e278766cb4801b8917"
+	add := func(bet database.Bet) database.Bet {
+		stored, err := b.db.Add(bet, 100, 50)
+		b.NoError(err)
+		return stored
+	}
+
+	add(database.Bet{PublicKey: secondBet.PublicKey, Tickets: 10, PaymentHash: "a", CreatedAt: 1})
+	add(database.Bet{PublicKey: publicKey, Tickets: 20, Bonus: 5, PaymentHash: "b", CreatedAt: 2})
+	last := add(database.Bet{PublicKey: publicKey, Tickets: 30, PaymentHash: "c", CreatedAt: 3})
+	whale := add(database.Bet{PublicKey: publicKey, Tickets: 1000, Pool: "whale"})
+
+	err := b.stats.AddRound(database.RoundStats{Height: 1, PrizePool: 100}, nil, 0)
+	b.NoError(err)
+	promo, err := b.db.Airdrop([]string{publicKey}, 10, "", "launch", 4)
+	b.NoError(err)
+
+	removed, err := b.db.Compact(lotteryHeight)
+	b.NoError(err)
+	b.Equal(uint64(2), removed)
+
+	bets, err := b.db.List(lotteryHeight, "", 0, 0, false)
+	b.NoError(err)
+
+	expected := []database.Bet{
+		firstBet,
+		{
+			PublicKey:     secondBet.PublicKey,
+			FirstTicket:   secondBet.FirstTicket,
+			Index:         secondBet.Index + 10,
+			Tickets:       secondBet.Tickets + 10,
+			LotteryHeight: lotteryHeight,
+		},
+		{
+			PublicKey:     publicKey,
+			FirstTicket:   secondBet.Index + 11,
+			Index:         last.Index,
+			Tickets:       55,
+			Bonus:         5,
+			LotteryHeight: lotteryHeight,
+		},
+		// Airdropped tickets are not merged with the paid ones
+		{
+			PublicKey:     publicKey,
+			FirstTicket:   promo[0].FirstTicket,
+			Index:         promo[0].Index,
+			Tickets:       10,
+			Bonus:         10,
+			Promo:         "launch",
+			LotteryHeight: lotteryHeight,
+		},
+	}
+	b.Equal(expected, bets)
+
+	prizePool, err := b.db.GetPrizePool(lotteryHeight, "")
+	b.NoError(err)
+	b.Equal(promo[0].Index, prizePool)
+
+	// Bets in other pools are not merged
+	bets, err = b.db.List(lotteryHeight, "whale", 0, 0, false)
+	b.NoError(err)
+	b.Len(bets, 1)
+	b.Equal(whale.Index, bets[0].Index)
+
+	// Compacting again has no effect
+	removed, err = b.db.Compact(lotteryHeight)
+	b.NoError(err)
+	b.Zero(removed)
+}
+
+func (b *BetsSuite) TestExists() {
+	_, err := b.db.Add(database.Bet{PublicKey: "public_key", Tickets: 10, PaymentHash: "hash"}, 0, 0)
+	b.NoError(err)
+
+	exists, err := b.db.Exists("hash")
+	b.NoError(err)
+	b.True(exists)
+
+	exists, err = b.db.Exists("missing")
+	b.NoError(err)
+	b.False(exists)
+}
+
+func (b *BetsSuite) TestGetPublicKey() {
+	bet, err := b.db.Add(database.Bet{PublicKey: "pool", Tickets: 10, Pool: "whale"}, 0, 0)
+	b.NoError(err)
+
+	cases := []struct {
+		desc      string
+		pool      string
+		publicKey string
+		ticket    uint64
+	}{
+		{desc: "First ticket", ticket: 1, publicKey: firstBet.PublicKey},
+		{desc: "Last ticket of a range", ticket: firstBet.Index, publicKey: firstBet.PublicKey},
+		{desc: "First ticket of a range", ticket: secondBet.FirstTicket, publicKey: secondBet.PublicKey},
+		{desc: "Last ticket", ticket: secondBet.Index, publicKey: secondBet.PublicKey},
+		{desc: "Pool", pool: "whale", ticket: bet.Index, publicKey: bet.PublicKey},
+	}
+
+	for _, tc := range cases {
+		b.Run(tc.desc, func() {
+			publicKey, err := b.db.GetPublicKey(lotteryHeight, tc.pool, tc.ticket)
+			b.NoError(err)
+			b.Equal(tc.publicKey, publicKey)
+		})
+	}
+
+	for _, ticket := range []uint64{0, secondBet.Index + 1} {
+		_, err := b.db.GetPublicKey(lotteryHeight, "", ticket)
+		b.ErrorIs(err, database.ErrTicketNotFound)
+	}
+
+	_, err = b.db.GetPublicKey(lotteryHeight+144, "", 1)
+	b.ErrorIs(err, database.ErrTicketNotFound)
 }
 
 func (b *BetsSuite) TestGetPrizePool() {
-	prizePool, err := b.db.GetPrizePool(lotteryHeight)
+	prizePool, err := b.db.GetPrizePool(lotteryHeight, "")
 	b.NoError(err)
 
 	expectedPrizePool := firstBet.Tickets + secondBet.Tickets
@@ -116,7 +529,7 @@ func (b *BetsSuite) TestList() {
 
 	for _, tc := range cases {
 		b.Run(tc.desc, func() {
-			bets, err := b.db.List(lotteryHeight, tc.offset, tc.limit, tc.reverse)
+			bets, err := b.db.List(lotteryHeight, "", tc.offset, tc.limit, tc.reverse)
 			b.NoError(err)
 
 			b.Equal(tc.expected, bets)
@@ -131,3 +544,62 @@ func (b *BetsSuite) TestList() {
 		})
 	}
 }
+
+func (b *BetsSuite) TestMove() {
+	toHeight := lotteryHeight + 144
+	b.NoError(b.exposure.Add(lotteryHeight, "hash", map[string]uint64{"peer": 10}))
+
+	err := b.db.Move(lotteryHeight, toHeight, signReceipt)
+	b.NoError(err)
+
+	exposure, err := b.exposure.List(lotteryHeight)
+	b.NoError(err)
+	b.Empty(exposure)
+
+	exposure, err = b.exposure.List(toHeight)
+	b.NoError(err)
+	b.Equal(map[string]uint64{"peer": 10}, exposure)
+
+	bets, err := b.db.List(lotteryHeight, "", 0, 0, false)
+	b.NoError(err)
+	b.Empty(bets)
+
+	bets, err = b.db.List(toHeight, "", 0, 0, false)
+	b.NoError(err)
+
+	first, second := firstBet, secondBet
+	first.LotteryHeight = toHeight
+	second.LotteryHeight = toHeight
+	b.Equal([]database.Bet{first, second}, bets)
+}
+
+func (b *BetsSuite) TestMoveIntoLotteryWithBets() {
+	toHeight := lotteryHeight + 144
+	b.NoError(b.lotteries.AddHeight(toHeight, "", ""))
+
+	existing, err := b.db.Add(database.Bet{
+		PublicKey: "99c1c20dfa84ca4a475359d8f6e711cb42923c7d2792b9e278766cb4801b8917",
+		Tickets:   10,
+	}, 0, 0)
+	b.NoError(err)
+	b.Equal(toHeight, existing.LotteryHeight)
+
+	err = b.db.Move(lotteryHeight, toHeight, signReceipt)
+	b.NoError(err)
+
+	bets, err := b.db.List(toHeight, "", 0, 0, false)
+	b.NoError(err)
+
+	// The tickets moved are numbered after the ones of the destination lottery
+	first, second := firstBet, secondBet
+	first.LotteryHeight, second.LotteryHeight = toHeight, toHeight
+	first.FirstTicket += existing.Index
+	first.Index += existing.Index
+	second.FirstTicket += existing.Index
+	second.Index += existing.Index
+	b.Equal([]database.Bet{existing, first, second}, bets)
+
+	prizePool, err := b.db.GetPrizePool(toHeight, "")
+	b.NoError(err)
+	b.Equal(existing.Tickets+firstBet.Tickets+secondBet.Tickets, prizePool)
+}
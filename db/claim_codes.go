@@ -0,0 +1,96 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// ErrClaimCodeNotFound is returned when there's no claim code with the hash provided.
+var ErrClaimCodeNotFound = errors.New("claim code not found")
+
+// ClaimCodesStore contains the methods used to store the codes issued to anonymous bettors.
+//
+// Only the SHA-256 hash of the codes is stored, along with the public key the bets and prizes are
+// registered under, which has no private key.
+type ClaimCodesStore interface {
+	Add(codeHash, publicKey string, expiresAt int64) error
+	DeleteExpired(now int64) (uint64, error)
+	Get(codeHash string) (ClaimCode, error)
+}
+
+// ClaimCode is a claim code issued to an anonymous bettor.
+type ClaimCode struct {
+	PublicKey string
+	ExpiresAt int64
+}
+
+type claimCodes struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newClaimCodesStore returns a new claim codes storage service.
+func newClaimCodesStore(db *sql.DB, logger *logger.Logger) ClaimCodesStore {
+	return &claimCodes{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add stores the hash of a claim code.
+func (c *claimCodes) Add(codeHash, publicKey string, expiresAt int64) error {
+	stmt, err := c.db.Prepare("INSERT INTO claim_codes (code_hash, public_key, expires_at) VALUES (?,?,?)")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(codeHash, publicKey, expiresAt); err != nil {
+		return errors.Wrap(err, "adding claim code")
+	}
+
+	return nil
+}
+
+// DeleteExpired removes the claim codes that expired before now and returns how many were.
+func (c *claimCodes) DeleteExpired(now int64) (uint64, error) {
+	stmt, err := c.db.Prepare("DELETE FROM claim_codes WHERE expires_at < ?")
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(now)
+	if err != nil {
+		return 0, errors.Wrap(err, "deleting expired claim codes")
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "counting deleted claim codes")
+	}
+
+	return uint64(deleted), nil
+}
+
+// Get returns the claim code with the hash specified.
+func (c *claimCodes) Get(codeHash string) (ClaimCode, error) {
+	stmt, err := c.db.Prepare("SELECT public_key, expires_at FROM claim_codes WHERE code_hash=?")
+	if err != nil {
+		return ClaimCode{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var claimCode ClaimCode
+	if err := stmt.QueryRow(codeHash).Scan(&claimCode.PublicKey, &claimCode.ExpiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ClaimCode{}, ErrClaimCodeNotFound
+		}
+		return ClaimCode{}, errors.Wrap(err, "getting claim code")
+	}
+
+	return claimCode, nil
+}
@@ -0,0 +1,31 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// ClaimCodesStoreMock is a mocked implementation of the claim codes store.
+type ClaimCodesStoreMock struct {
+	mock.Mock
+}
+
+// NewClaimCodesStoreMock returns a mocked claim codes store.
+func NewClaimCodesStoreMock() *ClaimCodesStoreMock {
+	return &ClaimCodesStoreMock{}
+}
+
+// Add mock.
+func (c *ClaimCodesStoreMock) Add(codeHash, publicKey string, expiresAt int64) error {
+	args := c.Called(codeHash, publicKey, expiresAt)
+	return args.Error(0)
+}
+
+// DeleteExpired mock.
+func (c *ClaimCodesStoreMock) DeleteExpired(now int64) (uint64, error) {
+	args := c.Called(now)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+// Get mock.
+func (c *ClaimCodesStoreMock) Get(codeHash string) (ClaimCode, error) {
+	args := c.Called(codeHash)
+	return args.Get(0).(ClaimCode), args.Error(1)
+}
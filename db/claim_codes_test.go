@@ -0,0 +1,63 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	codeHash           = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	claimCodePublicKey = "f2c1b0fa1cbd8e4a53fa4b4f7b0a6dfaf7e7f3ba2a1a1c8e3a6f2f0e1b7c9d4a"
+)
+
+type ClaimCodesSuite struct {
+	suite.Suite
+
+	db database.ClaimCodesStore
+}
+
+func TestClaimCodesSuite(t *testing.T) {
+	suite.Run(t, &ClaimCodesSuite{})
+}
+
+func (c *ClaimCodesSuite) SetupTest() {
+	db := setupDB(c.T(), func(db *sql.DB) {})
+	c.db = db.ClaimCodes
+}
+
+func (c *ClaimCodesSuite) TestAdd() {
+	err := c.db.Add(codeHash, claimCodePublicKey, 1_000)
+	c.NoError(err)
+
+	claimCode, err := c.db.Get(codeHash)
+	c.NoError(err)
+	c.Equal(database.ClaimCode{PublicKey: claimCodePublicKey, ExpiresAt: 1_000}, claimCode)
+
+	// Codes are unique
+	err = c.db.Add(codeHash, claimCodePublicKey, 2_000)
+	c.Error(err)
+}
+
+func (c *ClaimCodesSuite) TestGetNotFound() {
+	_, err := c.db.Get(codeHash)
+	c.ErrorIs(err, database.ErrClaimCodeNotFound)
+}
+
+func (c *ClaimCodesSuite) TestDeleteExpired() {
+	c.NoError(c.db.Add(codeHash, claimCodePublicKey, 1_000))
+	c.NoError(c.db.Add("other", claimCodePublicKey, 3_000))
+
+	deleted, err := c.db.DeleteExpired(2_000)
+	c.NoError(err)
+	c.Equal(uint64(1), deleted)
+
+	_, err = c.db.Get(codeHash)
+	c.ErrorIs(err, database.ErrClaimCodeNotFound)
+
+	_, err = c.db.Get("other")
+	c.NoError(err)
+}
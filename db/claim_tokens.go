@@ -0,0 +1,68 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/aftermath2/BTRY/fault"
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// ErrClaimTokenUsed is returned when a claim token was already used to withdraw prizes.
+var ErrClaimTokenUsed = errors.New("claim token already used")
+
+// ClaimTokensStore contains the methods used to record the claim widget tokens used.
+type ClaimTokensStore interface {
+	IsUsed(id string) (bool, error)
+	Use(id, publicKey string, expiresAt int64) error
+}
+
+type claimTokens struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newClaimTokensStore returns a new claim tokens storage service.
+func newClaimTokensStore(db *sql.DB, logger *logger.Logger) ClaimTokensStore {
+	return &claimTokens{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// IsUsed returns whether the token with the ID specified was used.
+func (c *claimTokens) IsUsed(id string) (bool, error) {
+	var used bool
+	query := "SELECT EXISTS (SELECT 1 FROM claim_tokens WHERE id=?)"
+	if err := c.db.QueryRow(query, id).Scan(&used); err != nil {
+		return false, errors.Wrap(err, "checking claim token")
+	}
+
+	return used, nil
+}
+
+// Use records that the token with the ID specified was used, or returns ErrClaimTokenUsed if it
+// already was. Tokens are kept until they expire, the expired ones are removed.
+func (c *claimTokens) Use(id, publicKey string, expiresAt int64) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM claim_tokens WHERE expires_at <= ?", time.Now().Unix()); err != nil {
+		return errors.Wrap(err, "deleting expired claim tokens")
+	}
+
+	query := "INSERT INTO claim_tokens (id, public_key, expires_at) VALUES (?,?,?)"
+	if _, err := tx.Exec(query, id, publicKey, expiresAt); err != nil {
+		if fault.KindOf(Classify(err)) == fault.Conflict {
+			return ErrClaimTokenUsed
+		}
+		return errors.Wrap(err, "using claim token")
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,25 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// ClaimTokensStoreMock is a mocked implementation of the claim tokens store.
+type ClaimTokensStoreMock struct {
+	mock.Mock
+}
+
+// NewClaimTokensStoreMock returns a mocked claim tokens store.
+func NewClaimTokensStoreMock() *ClaimTokensStoreMock {
+	return &ClaimTokensStoreMock{}
+}
+
+// IsUsed mock.
+func (c *ClaimTokensStoreMock) IsUsed(id string) (bool, error) {
+	args := c.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+// Use mock.
+func (c *ClaimTokensStoreMock) Use(id, publicKey string, expiresAt int64) error {
+	args := c.Called(id, publicKey, expiresAt)
+	return args.Error(0)
+}
@@ -0,0 +1,51 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ClaimTokensSuite struct {
+	suite.Suite
+
+	db *database.DB
+}
+
+func TestClaimTokensSuite(t *testing.T) {
+	suite.Run(t, &ClaimTokensSuite{})
+}
+
+func (c *ClaimTokensSuite) SetupTest() {
+	c.db = setupDB(c.T(), func(db *sql.DB) {})
+}
+
+func (c *ClaimTokensSuite) TestUse() {
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	used, err := c.db.ClaimTokens.IsUsed("id")
+	c.NoError(err)
+	c.False(used)
+
+	c.NoError(c.db.ClaimTokens.Use("id", "pubkey", expiresAt))
+
+	used, err = c.db.ClaimTokens.IsUsed("id")
+	c.NoError(err)
+	c.True(used)
+
+	err = c.db.ClaimTokens.Use("id", "pubkey", expiresAt)
+	c.ErrorIs(err, database.ErrClaimTokenUsed)
+
+	c.NoError(c.db.ClaimTokens.Use("id2", "pubkey", expiresAt))
+}
+
+func (c *ClaimTokensSuite) TestUseExpired() {
+	// Expired tokens are removed, they are rejected before reaching the store anyway
+	expired := time.Now().Add(-time.Minute).Unix()
+	c.NoError(c.db.ClaimTokens.Use("id", "pubkey", expired))
+	c.NoError(c.db.ClaimTokens.Use("other", "pubkey", expired))
+	c.NoError(c.db.ClaimTokens.Use("id", "pubkey", expired))
+}
@@ -3,10 +3,15 @@ package db
 
 import (
 	"database/sql"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/aftermath2/BTRY/chaos"
 	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/crypto"
 	"github.com/aftermath2/BTRY/logger"
 
 	"github.com/pkg/errors"
@@ -14,12 +19,50 @@ import (
 
 // DB represents the application database.
 type DB struct {
-	db            *sql.DB
+	db      *sql.DB
+	logger  *logger.Logger
+	replica atomic.Pointer[DB]
+	retired atomic.Pointer[DB]
+	// notifyDB is the database of the notifications store if it's not the main one
+	notifyDB      *sql.DB
+	snapshot      config.Snapshot
+	retention     config.Retention
+	AccessLists   AccessListsStore
+	APIKeys       APIKeysStore
+	Approvals     ApprovalsStore
+	Archives      ArchivesStore
+	Audit         AuditStore
 	Bets          BetsStore
+	BetArchives   BetArchivesStore
+	BetSwaps      BetSwapsStore
+	ClaimCodes    ClaimCodesStore
+	ClaimTokens   ClaimTokensStore
+	DrawTimings   DrawTimingsStore
+	Exposure      ExposureStore
+	Fairness      FairnessStore
+	Fees          FeeDistributionsStore
+	Growth        GrowthStore
+	Invoices      InvoicesStore
+	Jobs          JobsStore
+	Leases        LeasesStore
 	Lightning     LightningStore
+	Limits        LimitsStore
 	Lotteries     LotteriesStore
+	Migrations    RoundMigrationsStore
 	Notifications NotificationsStore
+	Operators     OperatorsStore
+	Payouts       PayoutsStore
+	Postponements PostponementsStore
 	Prizes        PrizesStore
+	Privacy       PrivacyStore
+	Receipts      ReceiptsStore
+	Reveals       RevealsStore
+	Scheduled     ScheduledPayoutsStore
+	Search        SearchStore
+	Sessions      SessionsStore
+	Stats         StatsStore
+	SwapClaims    SwapClaimsStore
+	Webhooks      WebhooksStore
 	Winners       WinnersStore
 }
 
@@ -30,7 +73,7 @@ func Open(config config.DB) (*DB, error) {
 		return nil, err
 	}
 
-	db, err := sql.Open("sqlite", config.Path+"?_pragma=busy_timeout=5000")
+	db, err := sql.Open(chaos.Driver("sqlite"), dataSourceName(config))
 	if err != nil {
 		return nil, errors.Wrap(err, "opening database")
 	}
@@ -45,20 +88,208 @@ func Open(config config.DB) (*DB, error) {
 		return nil, errors.Wrap(err, "executing migrations")
 	}
 
+	if err := upgrade(db); err != nil {
+		return nil, errors.Wrap(err, "upgrading schema")
+	}
+
+	database := newDB(db, logger)
+	database.snapshot = config.Snapshot
+	database.retention = config.Retention
+	if err := database.openNotifications(config); err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	return database, nil
+}
+
+// openNotifications sets up the notifications store in the database configured, encrypting its
+// values with the cipher of the provider configured. Values stored in plaintext or with a previous
+// key are encrypted again.
+func (db *DB) openNotifications(config config.DB) error {
+	notificationsDB := db.db
+	if config.Notifications.Path != "" {
+		notificationsConfig := config
+		notificationsConfig.Path = config.Notifications.Path
+		sqlDB, err := sql.Open("sqlite", dataSourceName(notificationsConfig))
+		if err != nil {
+			return errors.Wrap(err, "opening notifications database")
+		}
+		db.notifyDB = sqlDB
+		notificationsDB = sqlDB
+	}
+
+	if _, err := notificationsDB.Exec(notificationsMigrations); err != nil {
+		return errors.Wrap(err, "executing notifications migrations")
+	}
+
+	if err := upgradeNotifications(notificationsDB); err != nil {
+		return errors.Wrap(err, "upgrading notifications")
+	}
+
+	if notificationsDB != db.db {
+		if err := moveNotifications(db.db, notificationsDB); err != nil {
+			return errors.Wrap(err, "moving notifications")
+		}
+	}
+
+	cipher, err := crypto.NewCipher(config.Notifications.Encryption)
+	if err != nil {
+		return errors.Wrap(err, "creating notifications cipher")
+	}
+
+	notifications := newNotificationsStore(notificationsDB, db.logger, cipher)
+	updated, err := notifications.reencrypt()
+	if err != nil {
+		return errors.Wrap(err, "encrypting notifications")
+	}
+	if updated > 0 {
+		db.logger.Infof("Encrypted %d notification values with the current key", updated)
+	}
+
+	db.Notifications = notifications
+	return nil
+}
+
+// Close releases all related resources.
+func (db *DB) Close() error {
+	if db.notifyDB != nil {
+		if err := db.notifyDB.Close(); err != nil {
+			db.logger.Error(errors.Wrap(err, "closing notifications database"))
+		}
+	}
+
+	for _, replica := range []*DB{db.replica.Swap(nil), db.retired.Swap(nil)} {
+		if replica == nil {
+			continue
+		}
+		if err := replica.Close(); err != nil {
+			db.logger.Error(errors.Wrap(err, "closing read replica"))
+		}
+	}
+	return db.db.Close()
+}
+
+func newDB(db *sql.DB, logger *logger.Logger) *DB {
 	return &DB{
 		db:            db,
+		logger:        logger,
+		AccessLists:   newAccessListsStore(db, logger),
+		APIKeys:       newAPIKeysStore(db, logger),
+		Approvals:     newApprovalsStore(db, logger),
+		Archives:      newArchivesStore(db, logger),
+		Audit:         newAuditStore(db, logger),
 		Bets:          newBetsStore(db, logger),
+		BetArchives:   newBetArchivesStore(db, logger),
+		BetSwaps:      newBetSwapsStore(db, logger),
+		ClaimCodes:    newClaimCodesStore(db, logger),
+		ClaimTokens:   newClaimTokensStore(db, logger),
+		DrawTimings:   newDrawTimingsStore(db, logger),
+		Exposure:      newExposureStore(db, logger),
+		Fairness:      newFairnessStore(db, logger),
+		Fees:          newFeeDistributionsStore(db, logger),
+		Growth:        newGrowthStore(db, logger),
+		Invoices:      newInvoicesStore(db, logger),
+		Jobs:          newJobsStore(db, logger),
+		Leases:        newLeasesStore(db, logger),
 		Lightning:     newLightningStore(db, logger),
+		Limits:        newLimitsStore(db, logger),
 		Lotteries:     newLotteriesStore(db, logger),
-		Notifications: newNotificationsStore(db, logger),
+		Migrations:    newRoundMigrationsStore(db, logger),
+		Notifications: newNotificationsStore(db, logger, nil),
+		Operators:     newOperatorsStore(db, logger),
+		Payouts:       newPayoutsStore(db, logger),
+		Postponements: newPostponementsStore(db, logger),
 		Prizes:        newPrizesStore(db, logger),
+		Privacy:       newPrivacyStore(db, logger),
+		Receipts:      newReceiptsStore(db, logger),
+		Reveals:       newRevealsStore(db, logger),
+		Scheduled:     newScheduledPayoutsStore(db, logger),
+		Search:        newSearchStore(db, logger),
+		Sessions:      newSessionsStore(db, logger),
+		Stats:         newStatsStore(db, logger),
+		SwapClaims:    newSwapClaimsStore(db, logger),
+		Webhooks:      newWebhooksStore(db, logger),
 		Winners:       newWinnersStore(db, logger),
-	}, nil
+	}
 }
 
-// Close releases all related resources.
-func (db *DB) Close() error {
-	return db.db.Close()
+// moveNotifications moves the notifications stored in the main database, before another one was
+// configured, to the notifications database.
+func moveNotifications(from, to *sql.DB) error {
+	tables := []struct{ name, columns string }{
+		{name: "notifications", columns: "public_key, chat_id, service"},
+		{name: "nostr_notifications", columns: "public_key, nostr_public_key"},
+		{name: "digest_notifications", columns: "public_key"},
+	}
+
+	for _, table := range tables {
+		var exists bool
+		query := "SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type='table' AND name=?)"
+		if err := from.QueryRow(query, table.name).Scan(&exists); err != nil {
+			return errors.Wrap(err, "checking table")
+		}
+		if !exists {
+			continue
+		}
+
+		rows, err := from.Query("SELECT " + table.columns + " FROM " + table.name)
+		if err != nil {
+			return errors.Wrapf(err, "listing %s", table.name)
+		}
+
+		var values [][]any
+		for rows.Next() {
+			row := make([]any, strings.Count(table.columns, ",")+1)
+			pointers := make([]any, len(row))
+			for i := range row {
+				pointers[i] = &row[i]
+			}
+			if err := rows.Scan(pointers...); err != nil {
+				rows.Close()
+				return errors.Wrap(err, "scanning rows")
+			}
+			values = append(values, row)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return errors.Wrapf(err, "iterating %s", table.name)
+		}
+
+		for _, row := range values {
+			query := "INSERT OR IGNORE INTO " + table.name + " (" + table.columns + ") VALUES " +
+				BulkInsertValues(1, len(row))
+			if _, err := to.Exec(query, row...); err != nil {
+				return errors.Wrapf(err, "copying %s", table.name)
+			}
+		}
+
+		if _, err := from.Exec("DROP TABLE " + table.name); err != nil {
+			return errors.Wrapf(err, "dropping %s", table.name)
+		}
+	}
+
+	return nil
+}
+
+// dataSourceName returns the database connection string with the pragmas executed on every new
+// connection.
+func dataSourceName(config config.DB) string {
+	busyTimeout := config.BusyTimeout
+	if busyTimeout == 0 {
+		busyTimeout = 5 * time.Second
+	}
+
+	pragmas := url.Values{}
+	pragmas.Add("_pragma", "busy_timeout="+strconv.FormatInt(busyTimeout.Milliseconds(), 10))
+	if config.WAL {
+		pragmas.Add("_pragma", "journal_mode=WAL")
+	}
+	if config.MmapSize > 0 {
+		pragmas.Add("_pragma", "mmap_size="+strconv.FormatInt(config.MmapSize, 10))
+	}
+
+	return config.Path + "?" + pragmas.Encode()
 }
 
 // AddPagination returns the pagination part of an SQL query.
@@ -122,6 +353,184 @@ func BulkInsertValues(rows, values int) string {
 	return strings.Join(list, ",")
 }
 
+// upgrade executes the schema upgrades that were not applied yet. The database user_version
+// holds the number of upgrades executed.
+func upgrade(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	var version int
+	if err := tx.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return errors.Wrap(err, "getting schema version")
+	}
+
+	if version >= len(upgrades) {
+		return nil
+	}
+
+	for i, upgrade := range upgrades[version:] {
+		if _, err := tx.Exec(upgrade); err != nil {
+			return errors.Wrapf(err, "executing upgrade %d", version+i+1)
+		}
+	}
+
+	if _, err := tx.Exec("PRAGMA user_version=" + strconv.Itoa(len(upgrades))); err != nil {
+		return errors.Wrap(err, "setting schema version")
+	}
+
+	return tx.Commit()
+}
+
+// upgrades contains changes to the tables created in the migrations. New entries must always be
+// appended at the end.
+var upgrades = []string{
+	"ALTER TABLE winners ADD COLUMN claim_deadline INTEGER NOT NULL DEFAULT 0",
+	"ALTER TABLE bets ADD COLUMN bonus INTEGER NOT NULL DEFAULT 0",
+	// Backfill the statistics of the lotteries that took place before they were tracked. Winners
+	// restored after a failed payment have no ticket and are skipped
+	`INSERT OR IGNORE INTO stats_rounds (height, prize_pool, players, winners)
+		SELECT b.lottery_height, MAX(b.idx), COUNT(DISTINCT b.public_key),
+			(SELECT COUNT(DISTINCT w.public_key) FROM winners w
+			WHERE w.lottery_height = b.lottery_height AND w.ticket != 0)
+		FROM bets b
+		WHERE b.lottery_height IN (SELECT lottery_height FROM winners WHERE ticket != 0)
+		GROUP BY b.lottery_height;
+	INSERT INTO stats_wins (lottery_height, prize)
+		SELECT lottery_height, SUM(prize) FROM winners WHERE ticket != 0
+		GROUP BY lottery_height, public_key;
+	UPDATE stats SET
+		rounds = (SELECT COUNT(*) FROM stats_rounds),
+		total_pool = (SELECT COALESCE(SUM(prize_pool), 0) FROM stats_rounds);`,
+	// Ticket indexes are unique per pool, the primary key must include it
+	`CREATE TABLE bets_pools (
+		idx INTEGER NOT NULL CHECK (idx > 0),
+		tickets INTEGER CHECK (tickets > 0),
+		public_key VARCHAR(64) NOT NULL,
+		lottery_height INTEGER NOT NULL,
+		bonus INTEGER NOT NULL DEFAULT 0,
+		pool TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (lottery_height) REFERENCES lotteries(height),
+		PRIMARY KEY (idx, lottery_height, pool)
+	);
+	INSERT INTO bets_pools (idx, tickets, public_key, lottery_height, bonus)
+		SELECT idx, tickets, public_key, lottery_height, bonus FROM bets;
+	DROP TABLE bets;
+	ALTER TABLE bets_pools RENAME TO bets;`,
+	"ALTER TABLE winners ADD COLUMN pool TEXT NOT NULL DEFAULT ''",
+	// Speed up calculating the players weekly activity, after the bets table was rebuilt
+	"CREATE INDEX IF NOT EXISTS bets_public_key ON bets(public_key)",
+	// Bets can be cancelled for a while after they were placed, identified by their payment hash
+	"ALTER TABLE bets ADD COLUMN payment_hash TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE bets ADD COLUMN created_at INTEGER NOT NULL DEFAULT 0",
+	// Lotteries commit to a server seed before they start, it's combined with the block hash
+	"ALTER TABLE lotteries ADD COLUMN seed TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE lotteries ADD COLUMN commitment TEXT NOT NULL DEFAULT ''",
+	// Bets store the range of tickets they hold, the ticket owners are found with the index
+	"ALTER TABLE bets ADD COLUMN first_idx INTEGER NOT NULL DEFAULT 0",
+	"UPDATE bets SET first_idx = idx - tickets + 1",
+	"CREATE INDEX IF NOT EXISTS bets_ranges ON bets(lottery_height, pool, first_idx, idx, public_key)",
+	// The hash of the block that drew a lottery is kept so its draw can be replayed
+	"ALTER TABLE lotteries ADD COLUMN block_hash TEXT NOT NULL DEFAULT ''",
+	// Winners are reminded to claim their prizes before they expire, the block height of the last
+	// reminder avoids sending it twice
+	"ALTER TABLE winners ADD COLUMN last_reminded_at INTEGER NOT NULL DEFAULT 0",
+	// The collision policy of each draw is stored so it can be verified after the setting changes
+	"ALTER TABLE lotteries ADD COLUMN collision TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE invoices ADD COLUMN settle_index INTEGER NOT NULL DEFAULT 0",
+	// Free tickets airdropped by the operators are tagged with the promotion they belong to
+	"ALTER TABLE bets ADD COLUMN promo TEXT NOT NULL DEFAULT ''",
+	// Winners are revealed by tier when the suspense mode is enabled, from the last place to the first
+	"ALTER TABLE winners ADD COLUMN tier INTEGER NOT NULL DEFAULT 0",
+	// Draws are locked so a target height delivered twice by the block feed is drawn once
+	"ALTER TABLE lotteries ADD COLUMN draw_locked BOOLEAN NOT NULL DEFAULT 0",
+	// Receipts acknowledge the bonus tickets granted to the bet
+	"ALTER TABLE receipts ADD COLUMN bonus INTEGER NOT NULL DEFAULT 0",
+	// Bets placed by the house public keys are flagged when the operators are allowed to play
+	"ALTER TABLE bets ADD COLUMN house BOOLEAN NOT NULL DEFAULT 0",
+	// Winners are searched by public key and their claim status is derived from their prizes
+	"CREATE INDEX IF NOT EXISTS winners_public_key ON winners(public_key, lottery_height)",
+	"CREATE INDEX IF NOT EXISTS prizes_winners ON prizes(public_key, lottery_height)",
+	// Winners drawn before the claim window was configurable have no deadline, their prizes expired
+	// five lotteries after they were won. The duration is taken from the lottery that followed
+	`UPDATE winners SET claim_deadline = lottery_height + 5 * COALESCE(
+		(SELECT MIN(l.height) FROM lotteries l WHERE l.height > winners.lottery_height) - lottery_height,
+		144)
+	WHERE claim_deadline = 0`,
+	// Hold invoices keep their preimage while pending, so they are settled after a restart
+	"ALTER TABLE invoices ADD COLUMN preimage TEXT NOT NULL DEFAULT ''",
+	// Invoices whose bet failed to be placed after many restarts stop holding back the settle index
+	"ALTER TABLE invoices ADD COLUMN retries INTEGER NOT NULL DEFAULT 0",
+	// The results hash of the lotteries revealed by tier is salted so the winners can't be guessed
+	"ALTER TABLE reveals ADD COLUMN salt TEXT NOT NULL DEFAULT ''",
+}
+
+// notificationsMigrations creates the tables of the notifications store, which may be kept in
+// another database.
+const notificationsMigrations = `
+CREATE TABLE IF NOT EXISTS notifications (
+	public_key VARCHAR(64) PRIMARY KEY,
+	chat_id INTEGER NOT NULL,
+	service TEXT NOT NULL CHECK (service IN ('telegram'))
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS nostr_notifications (
+	public_key VARCHAR(64) PRIMARY KEY,
+	nostr_public_key VARCHAR(64) NOT NULL
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS digest_notifications (
+	public_key VARCHAR(64) PRIMARY KEY
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	public_key VARCHAR(64) NOT NULL DEFAULT '',
+	status TEXT NOT NULL CHECK (status IN ('pending', 'sent', 'failed')),
+	attempts INTEGER NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS notification_deliveries_status ON notification_deliveries(status);
+CREATE INDEX IF NOT EXISTS notification_deliveries_public_key ON notification_deliveries(public_key);
+`
+
+// notificationsUpgrades contains the columns added to the tables of the notifications store. The
+// store may share the database with the rest of tables, so they are applied when the column is
+// missing instead of tracking the schema version. New entries must always be appended at the end.
+var notificationsUpgrades = []struct{ table, column, definition string }{
+	// Deliveries to the players are tracked by channel, win notifications keep their message to be
+	// retried until the claim window closes
+	{table: "notification_deliveries", column: "channel", definition: "TEXT NOT NULL DEFAULT 'telegram'"},
+	{table: "notification_deliveries", column: "message", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "notification_deliveries", column: "retry_until", definition: "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// upgradeNotifications adds the columns of the notifications store that are missing.
+func upgradeNotifications(db *sql.DB) error {
+	for _, upgrade := range notificationsUpgrades {
+		var exists bool
+		query := "SELECT EXISTS (SELECT 1 FROM pragma_table_info(?) WHERE name=?)"
+		if err := db.QueryRow(query, upgrade.table, upgrade.column).Scan(&exists); err != nil {
+			return errors.Wrap(err, "checking column")
+		}
+		if exists {
+			continue
+		}
+
+		query = "ALTER TABLE " + upgrade.table + " ADD COLUMN " + upgrade.column + " " + upgrade.definition
+		if _, err := db.Exec(query); err != nil {
+			return errors.Wrapf(err, "adding %s.%s", upgrade.table, upgrade.column)
+		}
+	}
+
+	return nil
+}
+
 const migrations = `
 CREATE TABLE IF NOT EXISTS bets (
 	idx INTEGER NOT NULL CHECK (idx > 0),
@@ -151,11 +560,19 @@ CREATE TABLE IF NOT EXISTS prizes (
 	FOREIGN KEY (lottery_height) REFERENCES lotteries(height)
 );
 
-CREATE TABLE IF NOT EXISTS notifications (
-	public_key VARCHAR(64) PRIMARY KEY,
-	chat_id INTEGER NOT NULL,
-	service TEXT NOT NULL CHECK (service IN ('telegram'))
-) WITHOUT ROWID;
+CREATE TABLE IF NOT EXISTS prize_claims (
+	payment_hash VARCHAR(64) PRIMARY KEY,
+	public_key VARCHAR(64) NOT NULL,
+	created_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS prize_claim_winners (
+	payment_hash VARCHAR(64) NOT NULL,
+	lottery_height INTEGER NOT NULL,
+	amount INTEGER NOT NULL,
+	PRIMARY KEY (payment_hash, lottery_height),
+	FOREIGN KEY (payment_hash) REFERENCES prize_claims(payment_hash)
+);
 
 CREATE TABLE IF NOT EXISTS lotteries (
 	height INTEGER PRIMARY KEY CHECK (height > 0)
@@ -165,4 +582,388 @@ CREATE TABLE IF NOT EXISTS lightning (
 	public_key VARCHAR(64) NOT NULL,
 	address VARCHAR(255) NOT NULL,
 	PRIMARY KEY (public_key, address)
-);`
+);
+
+CREATE TABLE IF NOT EXISTS audit (
+	id INTEGER PRIMARY KEY CHECK (id > 0),
+	timestamp INTEGER NOT NULL,
+	event TEXT NOT NULL,
+	data TEXT NOT NULL,
+	prev_hash VARCHAR(64) NOT NULL UNIQUE,
+	hash VARCHAR(64) NOT NULL UNIQUE,
+	signature VARCHAR(128) NOT NULL
+);
+
+CREATE TRIGGER IF NOT EXISTS audit_no_update BEFORE UPDATE ON audit
+BEGIN
+	SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_no_delete BEFORE DELETE ON audit
+BEGIN
+	SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+CREATE TABLE IF NOT EXISTS operators (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	role INTEGER NOT NULL CHECK (role IN (1, 2, 3)),
+	created_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS credentials (
+	id BLOB PRIMARY KEY,
+	operator_id INTEGER NOT NULL,
+	public_key BLOB NOT NULL,
+	sign_count INTEGER NOT NULL DEFAULT 0,
+	FOREIGN KEY (operator_id) REFERENCES operators(id)
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	token_hash VARCHAR(64) PRIMARY KEY,
+	operator_id INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL,
+	FOREIGN KEY (operator_id) REFERENCES operators(id)
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS claim_tokens (
+	id VARCHAR(32) PRIMARY KEY,
+	public_key VARCHAR(64) NOT NULL,
+	expires_at INTEGER NOT NULL
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS invites (
+	code_hash VARCHAR(64) PRIMARY KEY,
+	role INTEGER NOT NULL CHECK (role IN (1, 2, 3)),
+	expires_at INTEGER NOT NULL
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS stats (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	rounds INTEGER NOT NULL DEFAULT 0,
+	total_pool INTEGER NOT NULL DEFAULT 0,
+	total_paid_out INTEGER NOT NULL DEFAULT 0,
+	payouts INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT OR IGNORE INTO stats (id) VALUES (1);
+
+CREATE TABLE IF NOT EXISTS stats_rounds (
+	height INTEGER PRIMARY KEY,
+	prize_pool INTEGER NOT NULL,
+	players INTEGER NOT NULL,
+	winners INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS stats_wins (
+	lottery_height INTEGER NOT NULL,
+	prize INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS stats_wins_prize ON stats_wins(prize);
+
+CREATE TABLE IF NOT EXISTS stats_streaks (
+	public_key VARCHAR(64) PRIMARY KEY,
+	current INTEGER NOT NULL,
+	last_height INTEGER NOT NULL,
+	longest INTEGER NOT NULL,
+	longest_height INTEGER NOT NULL
+) WITHOUT ROWID;
+
+CREATE INDEX IF NOT EXISTS stats_streaks_longest ON stats_streaks(longest);
+
+CREATE TABLE IF NOT EXISTS exposure (
+	payment_hash VARCHAR(64) NOT NULL,
+	peer_public_key VARCHAR(66) NOT NULL,
+	amount INTEGER NOT NULL CHECK (amount > 0),
+	lottery_height INTEGER NOT NULL,
+	FOREIGN KEY (lottery_height) REFERENCES lotteries(height),
+	PRIMARY KEY (payment_hash, peer_public_key)
+);
+
+CREATE INDEX IF NOT EXISTS exposure_lottery_height ON exposure(lottery_height);
+
+CREATE TABLE IF NOT EXISTS limits (
+	public_key VARCHAR(64) NOT NULL,
+	kind TEXT NOT NULL CHECK (kind IN ('deposit', 'loss')),
+	amount INTEGER NOT NULL CHECK (amount >= 0),
+	effective_at INTEGER NOT NULL,
+	PRIMARY KEY (public_key, kind, effective_at)
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS exclusions (
+	public_key VARCHAR(64) PRIMARY KEY,
+	until INTEGER NOT NULL
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS claim_codes (
+	code_hash VARCHAR(64) PRIMARY KEY,
+	public_key VARCHAR(64) NOT NULL,
+	expires_at INTEGER NOT NULL
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS jobs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL,
+	payload BLOB NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	run_at INTEGER NOT NULL,
+	last_error TEXT NOT NULL DEFAULT '',
+	failed BOOLEAN NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS jobs_run_at ON jobs(failed, run_at);
+
+CREATE TABLE IF NOT EXISTS privacy (
+	public_key VARCHAR(64) PRIMARY KEY,
+	display TEXT NOT NULL CHECK (display IN ('full', 'truncated', 'alias', 'hidden')),
+	alias TEXT NOT NULL DEFAULT ''
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS approvals (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	public_key VARCHAR(64) NOT NULL,
+	payment_request TEXT NOT NULL,
+	payment_hash VARCHAR(64) NOT NULL,
+	amount INTEGER NOT NULL,
+	fee INTEGER NOT NULL,
+	claims TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS approval_signatures (
+	approval_id INTEGER NOT NULL,
+	operator_id INTEGER NOT NULL,
+	created_at INTEGER NOT NULL,
+	PRIMARY KEY (approval_id, operator_id)
+);
+
+CREATE TABLE IF NOT EXISTS bet_archives (
+	lottery_height INTEGER PRIMARY KEY,
+	bets BLOB NOT NULL,
+	FOREIGN KEY (lottery_height) REFERENCES lotteries(height)
+);
+
+CREATE TABLE IF NOT EXISTS invoices (
+	payment_hash VARCHAR(64) PRIMARY KEY,
+	public_key VARCHAR(64) NOT NULL,
+	amount INTEGER NOT NULL,
+	status TEXT NOT NULL CHECK (status IN ('pending', 'paid', 'expired')),
+	created_at INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL DEFAULT 0
+) WITHOUT ROWID;
+
+CREATE INDEX IF NOT EXISTS invoices_created_at ON invoices(created_at);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	key_hash VARCHAR(64) NOT NULL UNIQUE,
+	scopes TEXT NOT NULL,
+	rate_limit INTEGER NOT NULL DEFAULT 0,
+	created_at INTEGER NOT NULL,
+	rotated_at INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS webhooks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	api_key_id INTEGER NOT NULL,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	events TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	FOREIGN KEY (api_key_id) REFERENCES api_keys(id)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	webhook_id INTEGER NOT NULL,
+	delivery_id TEXT NOT NULL,
+	event TEXT NOT NULL,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id);
+
+CREATE TABLE IF NOT EXISTS draw_timings (
+	lottery_height INTEGER PRIMARY KEY,
+	bets INTEGER NOT NULL,
+	bets_listing INTEGER NOT NULL,
+	winners INTEGER NOT NULL,
+	db_writes INTEGER NOT NULL,
+	notifications INTEGER NOT NULL,
+	total INTEGER NOT NULL,
+	created_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS fairness_reports (
+	lottery_height INTEGER PRIMARY KEY,
+	players INTEGER NOT NULL,
+	tickets INTEGER NOT NULL,
+	gini REAL NOT NULL,
+	winning_positions TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS swap_claims (
+	payment_hash TEXT PRIMARY KEY,
+	public_key TEXT NOT NULL,
+	preimage TEXT NOT NULL,
+	message TEXT NOT NULL DEFAULT '',
+	signature TEXT NOT NULL DEFAULT '',
+	countersignature TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	countersigned_at INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS swap_claims_public_key ON swap_claims(public_key);
+
+CREATE TABLE IF NOT EXISTS bet_swaps (
+	id TEXT PRIMARY KEY,
+	payment_hash VARCHAR(64) NOT NULL,
+	public_key VARCHAR(64) NOT NULL,
+	address TEXT NOT NULL,
+	status TEXT NOT NULL,
+	expected_amount INTEGER NOT NULL,
+	amount INTEGER NOT NULL,
+	timeout_block_height INTEGER NOT NULL,
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS growth_samples (
+	lottery_height INTEGER NOT NULL,
+	block_height INTEGER NOT NULL,
+	prize_pool INTEGER NOT NULL,
+	bets INTEGER NOT NULL,
+	created_at INTEGER NOT NULL,
+	PRIMARY KEY (lottery_height, block_height)
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS growth_milestones (
+	lottery_height INTEGER NOT NULL,
+	amount INTEGER NOT NULL,
+	reached_at INTEGER NOT NULL,
+	PRIMARY KEY (lottery_height, amount)
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS receipts (
+	payment_hash VARCHAR(64) PRIMARY KEY,
+	public_key VARCHAR(64) NOT NULL,
+	lottery_height INTEGER NOT NULL,
+	first_idx INTEGER NOT NULL,
+	idx INTEGER NOT NULL,
+	signature TEXT NOT NULL,
+	created_at INTEGER NOT NULL
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS scheduled_payouts (
+	id INTEGER PRIMARY KEY,
+	public_key VARCHAR(64) NOT NULL,
+	amount INTEGER NOT NULL,
+	deadline INTEGER NOT NULL,
+	estimated_fee INTEGER NOT NULL,
+	checks INTEGER NOT NULL DEFAULT 1,
+	created_at INTEGER NOT NULL,
+	checked_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS access_lists (
+	public_key VARCHAR(64) NOT NULL,
+	list TEXT NOT NULL,
+	action TEXT NOT NULL,
+	reason TEXT NOT NULL DEFAULT '',
+	operator_id INTEGER NOT NULL,
+	created_at INTEGER NOT NULL,
+	PRIMARY KEY (public_key, list, action)
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS leases (
+	name TEXT PRIMARY KEY,
+	holder TEXT NOT NULL,
+	expires_at INTEGER NOT NULL
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS fee_distributions (
+	id INTEGER PRIMARY KEY,
+	lottery_height INTEGER NOT NULL,
+	destination TEXT NOT NULL,
+	address TEXT NOT NULL,
+	amount INTEGER NOT NULL CHECK (amount > 0),
+	preimage TEXT NOT NULL DEFAULT '',
+	paid_at INTEGER NOT NULL DEFAULT 0,
+	UNIQUE (lottery_height, destination)
+);
+
+CREATE TABLE IF NOT EXISTS cancellation_fees (
+	payment_hash VARCHAR(64) PRIMARY KEY,
+	public_key VARCHAR(64) NOT NULL,
+	lottery_height INTEGER NOT NULL,
+	amount INTEGER NOT NULL CHECK (amount > 0)
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS migration_tokens (
+	token_hash TEXT PRIMARY KEY,
+	expires_at INTEGER NOT NULL,
+	used_at INTEGER NOT NULL DEFAULT 0
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS reveals (
+	lottery_height INTEGER PRIMARY KEY,
+	results_hash TEXT NOT NULL,
+	prize_pool INTEGER NOT NULL,
+	tiers INTEGER NOT NULL,
+	hidden INTEGER NOT NULL,
+	blocks INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS archives (
+	id INTEGER PRIMARY KEY,
+	destination TEXT NOT NULL,
+	first_height INTEGER NOT NULL,
+	last_height INTEGER NOT NULL,
+	reference TEXT NOT NULL,
+	hash VARCHAR(64) NOT NULL,
+	created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS archives_destination_idx ON archives (destination, last_height);
+
+CREATE TABLE IF NOT EXISTS lottery_tiers (
+	lottery_height INTEGER NOT NULL,
+	pool TEXT NOT NULL,
+	participants INTEGER NOT NULL,
+	tiers INTEGER NOT NULL,
+	FOREIGN KEY (lottery_height) REFERENCES lotteries(height),
+	PRIMARY KEY (lottery_height, pool)
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS postponements (
+	lottery_height INTEGER PRIMARY KEY,
+	block_hash VARCHAR(64) NOT NULL,
+	liabilities INTEGER NOT NULL,
+	local_balance INTEGER NOT NULL,
+	postponed_at INTEGER NOT NULL,
+	resume_height INTEGER NOT NULL,
+	attempts INTEGER NOT NULL,
+	resumed_at INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS payouts (
+	id INTEGER PRIMARY KEY,
+	public_key VARCHAR(64) NOT NULL,
+	payment_hash VARCHAR(64) NOT NULL,
+	amount INTEGER NOT NULL,
+	status TEXT NOT NULL,
+	failure_reason TEXT NOT NULL DEFAULT '',
+	fee INTEGER NOT NULL DEFAULT 0,
+	preimage TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS payouts_public_key ON payouts(public_key);
+CREATE INDEX IF NOT EXISTS payouts_payment_hash ON payouts(payment_hash);`
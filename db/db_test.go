@@ -2,6 +2,7 @@ package db_test
 
 import (
 	"database/sql"
+	"fmt"
 	"os"
 	"testing"
 
@@ -24,6 +25,77 @@ func TestOpen(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestOpenUpgrade(t *testing.T) {
+	file, err := os.CreateTemp("", "*")
+	assert.NoError(t, err)
+	defer file.Close()
+
+	dbConfig := config.DB{Path: file.Name()}
+	database, err := db.Open(dbConfig)
+	assert.NoError(t, err)
+	assert.NoError(t, database.Close())
+
+	// Upgrades must not be executed twice
+	database, err = db.Open(dbConfig)
+	assert.NoError(t, err)
+	assert.NoError(t, database.Close())
+
+	sqlDB, err := sql.Open("sqlite", file.Name())
+	assert.NoError(t, err)
+	defer sqlDB.Close()
+
+	var version int
+	err = sqlDB.QueryRow("PRAGMA user_version").Scan(&version)
+	assert.NoError(t, err)
+	assert.NotZero(t, version)
+}
+
+func TestClaimDeadlineBackfill(t *testing.T) {
+	file, err := os.CreateTemp("", "*")
+	assert.NoError(t, err)
+	defer file.Close()
+
+	dbConfig := config.DB{Path: file.Name()}
+	database, err := db.Open(dbConfig)
+	assert.NoError(t, err)
+	assert.NoError(t, database.Close())
+
+	sqlDB, err := sql.Open("sqlite", file.Name())
+	assert.NoError(t, err)
+	defer sqlDB.Close()
+
+	var version int
+	err = sqlDB.QueryRow("PRAGMA user_version").Scan(&version)
+	assert.NoError(t, err)
+
+	// Simulate the winners drawn before the claim deadlines were stored, the last one has no
+	// lottery after it
+	_, err = sqlDB.Exec(`INSERT INTO lotteries (height) VALUES (100), (200), (300), (310);
+	INSERT INTO winners (public_key, prize, ticket, lottery_height) VALUES
+		('a', 10, 1, 100), ('b', 20, 2, 300), ('c', 30, 3, 310);
+	INSERT INTO winners (public_key, prize, ticket, lottery_height, claim_deadline) VALUES
+		('d', 40, 4, 200, 250);
+	ALTER TABLE invoices DROP COLUMN preimage;
+	ALTER TABLE invoices DROP COLUMN retries;
+	ALTER TABLE reveals DROP COLUMN salt;`)
+	assert.NoError(t, err)
+	// Roll back to the version right before the backfill
+	_, err = sqlDB.Exec(fmt.Sprintf("PRAGMA user_version = %d", version-4))
+	assert.NoError(t, err)
+
+	database, err = db.Open(dbConfig)
+	assert.NoError(t, err)
+	defer database.Close()
+
+	expected := map[uint32]uint32{100: 600, 200: 250, 300: 350, 310: 1030}
+	for height, deadline := range expected {
+		winners, err := database.Winners.List(height)
+		assert.NoError(t, err)
+		assert.Len(t, winners, 1)
+		assert.Equal(t, deadline, winners[0].ClaimDeadline, height)
+	}
+}
+
 func TestClose(t *testing.T) {
 	file, err := os.CreateTemp("", "*")
 	assert.NoError(t, err)
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// DrawTimingsStore contains the methods used to store and retrieve how long the draws took.
+type DrawTimingsStore interface {
+	Add(timing DrawTiming) error
+	List(offset, limit uint64) ([]DrawTiming, error)
+}
+
+// DrawTiming contains the duration of each stage of a draw, in microseconds.
+type DrawTiming struct {
+	BetsListing   int64  `json:"bets_listing_us"`
+	Winners       int64  `json:"winners_us"`
+	DBWrites      int64  `json:"db_writes_us"`
+	Notifications int64  `json:"notifications_us"`
+	Total         int64  `json:"total_us"`
+	Bets          uint64 `json:"bets"`
+	CreatedAt     int64  `json:"created_at"`
+	LotteryHeight uint32 `json:"lottery_height"`
+}
+
+type drawTimings struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newDrawTimingsStore returns a new draw timings storage service.
+func newDrawTimingsStore(db *sql.DB, logger *logger.Logger) DrawTimingsStore {
+	return &drawTimings{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add saves the timing of a draw, replacing the previous one of the same lottery.
+func (d *drawTimings) Add(timing DrawTiming) error {
+	query := `INSERT OR REPLACE INTO draw_timings
+	(lottery_height, bets, bets_listing, winners, db_writes, notifications, total, created_at)
+	VALUES (?,?,?,?,?,?,?,?)`
+	stmt, err := d.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(timing.LotteryHeight, timing.Bets, timing.BetsListing, timing.Winners,
+		timing.DBWrites, timing.Notifications, timing.Total, timing.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "adding draw timing")
+	}
+
+	return nil
+}
+
+// List returns the timings of the draws, the newest first. The offset is the height of the lottery
+// to start after.
+func (d *drawTimings) List(offset, limit uint64) ([]DrawTiming, error) {
+	query := `SELECT lottery_height, bets, bets_listing, winners, db_writes, notifications, total,
+	created_at FROM draw_timings`
+	query = AddPagination(query, offset, limit, "lottery_height", true)
+
+	stmt, err := d.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing draw timings")
+	}
+	defer rows.Close()
+
+	var timings []DrawTiming
+	// Reuse object
+	var timing DrawTiming
+	for rows.Next() {
+		err := rows.Scan(&timing.LotteryHeight, &timing.Bets, &timing.BetsListing, &timing.Winners,
+			&timing.DBWrites, &timing.Notifications, &timing.Total, &timing.CreatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		timings = append(timings, timing)
+	}
+
+	return timings, nil
+}
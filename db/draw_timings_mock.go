@@ -0,0 +1,25 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// DrawTimingsStoreMock is a mocked implementation of the draw timings store.
+type DrawTimingsStoreMock struct {
+	mock.Mock
+}
+
+// NewDrawTimingsStoreMock returns a mocked draw timings store.
+func NewDrawTimingsStoreMock() *DrawTimingsStoreMock {
+	return &DrawTimingsStoreMock{}
+}
+
+// Add mock.
+func (d *DrawTimingsStoreMock) Add(timing DrawTiming) error {
+	args := d.Called(timing)
+	return args.Error(0)
+}
+
+// List mock.
+func (d *DrawTimingsStoreMock) List(offset, limit uint64) ([]DrawTiming, error) {
+	args := d.Called(offset, limit)
+	return args.Get(0).([]DrawTiming), args.Error(1)
+}
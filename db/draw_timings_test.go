@@ -0,0 +1,63 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DrawTimingsSuite struct {
+	suite.Suite
+
+	db *database.DB
+}
+
+func TestDrawTimingsSuite(t *testing.T) {
+	suite.Run(t, &DrawTimingsSuite{})
+}
+
+func (s *DrawTimingsSuite) SetupTest() {
+	s.db = setupDB(s.T(), func(db *sql.DB) {})
+}
+
+func (s *DrawTimingsSuite) TestAdd() {
+	timing := database.DrawTiming{
+		LotteryHeight: 144,
+		Bets:          2,
+		BetsListing:   100,
+		Winners:       50,
+		DBWrites:      300,
+		Notifications: 20,
+		Total:         470,
+		CreatedAt:     1231006505,
+	}
+	s.NoError(s.db.DrawTimings.Add(timing))
+
+	// Draws replayed after a crash replace the previous timing
+	timing.Total = 500
+	s.NoError(s.db.DrawTimings.Add(timing))
+
+	timings, err := s.db.DrawTimings.List(0, 0)
+	s.NoError(err)
+	s.Equal([]database.DrawTiming{timing}, timings)
+}
+
+func (s *DrawTimingsSuite) TestList() {
+	for _, height := range []uint32{144, 288, 432} {
+		s.NoError(s.db.DrawTimings.Add(database.DrawTiming{LotteryHeight: height, Total: int64(height)}))
+	}
+
+	timings, err := s.db.DrawTimings.List(0, 2)
+	s.NoError(err)
+	s.Len(timings, 2)
+	s.Equal(uint32(432), timings[0].LotteryHeight)
+	s.Equal(uint32(288), timings[1].LotteryHeight)
+
+	timings, err = s.db.DrawTimings.List(288, 0)
+	s.NoError(err)
+	s.Len(timings, 1)
+	s.Equal(uint32(144), timings[0].LotteryHeight)
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+
+	"github.com/aftermath2/BTRY/fault"
+
+	"github.com/pkg/errors"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Classify returns the error classified by its cause: locked databases and timeouts are transient,
+// as another connection holds the lock, and constraint violations are conflicts with the rows
+// already stored. Errors that are already classified or have other causes are returned as is.
+func Classify(err error) error {
+	if err == nil || fault.KindOf(err) != fault.Unknown {
+		return err
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fault.NewTransient(err)
+	}
+
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+
+	switch sqliteErr.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return fault.NewTransient(err)
+	case sqlite3.SQLITE_CONSTRAINT:
+		return fault.NewConflict(err)
+	}
+
+	return err
+}
@@ -0,0 +1,59 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/fault"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	assert.NoError(t, db.Classify(nil))
+	assert.Equal(t, fault.Unknown, fault.KindOf(db.Classify(errors.New("test"))))
+	assert.True(t, fault.IsTransient(db.Classify(context.DeadlineExceeded)))
+
+	t.Run("Conflict", func(t *testing.T) {
+		database := setupDB(t, func(*sql.DB) {})
+		reveal := db.Reveal{LotteryHeight: 144, Tiers: 8, Hidden: 8}
+		assert.NoError(t, database.Reveals.Add(reveal))
+
+		err := db.Classify(database.Reveals.Add(reveal))
+		assert.Equal(t, fault.Conflict, fault.KindOf(err))
+	})
+
+	t.Run("Busy", func(t *testing.T) {
+		file, err := os.CreateTemp("", "*")
+		assert.NoError(t, err)
+		defer file.Close()
+
+		writer, err := sql.Open("sqlite", file.Name())
+		assert.NoError(t, err)
+		defer writer.Close()
+		_, err = writer.Exec("CREATE TABLE test (id INTEGER)")
+		assert.NoError(t, err)
+
+		tx, err := writer.Begin()
+		assert.NoError(t, err)
+		defer tx.Rollback()
+		_, err = tx.Exec("INSERT INTO test (id) VALUES (1)")
+		assert.NoError(t, err)
+
+		other, err := sql.Open("sqlite", file.Name())
+		assert.NoError(t, err)
+		defer other.Close()
+
+		_, err = other.Exec("INSERT INTO test (id) VALUES (2)")
+		assert.True(t, fault.IsTransient(db.Classify(err)))
+	})
+
+	t.Run("Already classified", func(t *testing.T) {
+		err := fault.NewPermanent(context.DeadlineExceeded)
+		assert.Equal(t, fault.Permanent, fault.KindOf(db.Classify(err)))
+	})
+}
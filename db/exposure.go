@@ -0,0 +1,101 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// ExposureStore contains the methods used to track the channel peers through which the bets were
+// paid.
+type ExposureStore interface {
+	Add(height uint32, paymentHash string, amounts map[string]uint64) error
+	Delete(paymentHash string) error
+	List(height uint32) (map[string]uint64, error)
+}
+
+type exposure struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newExposureStore returns a new exposure storage service.
+func newExposureStore(db *sql.DB, logger *logger.Logger) ExposureStore {
+	return &exposure{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add stores the amount of a bet received through each peer.
+func (e *exposure) Add(height uint32, paymentHash string, amounts map[string]uint64) error {
+	tx, err := e.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO exposure (payment_hash, peer_public_key, amount, lottery_height) VALUES (?,?,?,?)"
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	for peer, amount := range amounts {
+		if _, err := stmt.Exec(paymentHash, peer, amount, height); err != nil {
+			return errors.Wrap(err, "adding exposure")
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes the exposure of a bet.
+func (e *exposure) Delete(paymentHash string) error {
+	stmt, err := e.db.Prepare("DELETE FROM exposure WHERE payment_hash = ?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(paymentHash); err != nil {
+		return errors.Wrap(err, "deleting exposure")
+	}
+
+	return nil
+}
+
+// List returns the sum of the bets received through each peer in a lottery.
+func (e *exposure) List(height uint32) (map[string]uint64, error) {
+	query := "SELECT peer_public_key, SUM(amount) FROM exposure WHERE lottery_height = ? GROUP BY peer_public_key"
+	stmt, err := e.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(height)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing exposure")
+	}
+	defer rows.Close()
+
+	exposure := make(map[string]uint64)
+	// Reuse objects
+	var (
+		peer   string
+		amount uint64
+	)
+	for rows.Next() {
+		if err := rows.Scan(&peer, &amount); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		exposure[peer] = amount
+	}
+
+	return exposure, nil
+}
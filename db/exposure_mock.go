@@ -0,0 +1,36 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// ExposureStoreMock is a mocked implementation of the exposure store.
+type ExposureStoreMock struct {
+	mock.Mock
+}
+
+// NewExposureStoreMock returns a mocked exposure store.
+func NewExposureStoreMock() *ExposureStoreMock {
+	return &ExposureStoreMock{}
+}
+
+// Add mock.
+func (e *ExposureStoreMock) Add(height uint32, paymentHash string, amounts map[string]uint64) error {
+	args := e.Called(height, paymentHash, amounts)
+	return args.Error(0)
+}
+
+// Delete mock.
+func (e *ExposureStoreMock) Delete(paymentHash string) error {
+	args := e.Called(paymentHash)
+	return args.Error(0)
+}
+
+// List mock.
+func (e *ExposureStoreMock) List(height uint32) (map[string]uint64, error) {
+	args := e.Called(height)
+	var r0 map[string]uint64
+	v0 := args.Get(0)
+	if v0 != nil {
+		r0 = v0.(map[string]uint64)
+	}
+	return r0, args.Error(1)
+}
@@ -0,0 +1,63 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ExposureSuite struct {
+	suite.Suite
+
+	db database.ExposureStore
+}
+
+func TestExposureSuite(t *testing.T) {
+	suite.Run(t, &ExposureSuite{})
+}
+
+func (e *ExposureSuite) SetupTest() {
+	db := setupDB(e.T(), func(db *sql.DB) {
+		_, err := db.Exec("INSERT INTO lotteries (height) VALUES (?), (?)", lotteryHeight, lotteryHeight+1)
+		e.NoError(err)
+	})
+	e.db = db.Exposure
+}
+
+func (e *ExposureSuite) TestList() {
+	err := e.db.Add(lotteryHeight, "hash1", map[string]uint64{"peer1": 1_000, "peer2": 500})
+	e.NoError(err)
+	err = e.db.Add(lotteryHeight, "hash2", map[string]uint64{"peer1": 2_000})
+	e.NoError(err)
+	err = e.db.Add(lotteryHeight+1, "hash3", map[string]uint64{"peer1": 4_000})
+	e.NoError(err)
+
+	exposure, err := e.db.List(lotteryHeight)
+	e.NoError(err)
+
+	e.Equal(map[string]uint64{"peer1": 3_000, "peer2": 500}, exposure)
+}
+
+func (e *ExposureSuite) TestAddDuplicate() {
+	err := e.db.Add(lotteryHeight, "hash", map[string]uint64{"peer": 1_000})
+	e.NoError(err)
+
+	err = e.db.Add(lotteryHeight, "hash", map[string]uint64{"peer": 1_000})
+	e.Error(err)
+}
+
+func (e *ExposureSuite) TestDelete() {
+	err := e.db.Add(lotteryHeight, "hash", map[string]uint64{"peer1": 1_000, "peer2": 500})
+	e.NoError(err)
+
+	err = e.db.Delete("hash")
+	e.NoError(err)
+
+	exposure, err := e.db.List(lotteryHeight)
+	e.NoError(err)
+
+	e.Empty(exposure)
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// FairnessStore contains the methods used to store and retrieve the fairness reports of the draws.
+type FairnessStore interface {
+	Add(report FairnessReport) error
+	List(offset, limit uint64, reverse bool) ([]FairnessReport, error)
+}
+
+// FairnessReport describes how concentrated the tickets of a lottery were and where the winning
+// tickets fell, so the community can monitor the draws over time.
+type FairnessReport struct {
+	WinningPositions []WinningPosition `json:"winning_positions"`
+	// Gini is the Gini coefficient of the tickets held by each player, from 0 (every player held
+	// the same number of tickets) to 1 (a single player held all of them)
+	Gini          float64 `json:"gini"`
+	Players       uint64  `json:"players"`
+	Tickets       uint64  `json:"tickets"`
+	LotteryHeight uint32  `json:"lottery_height"`
+}
+
+// WinningPosition is a winning ticket relative to the prize pool of its lottery pool.
+type WinningPosition struct {
+	Pool      string `json:"pool,omitempty"`
+	Ticket    uint64 `json:"ticket"`
+	PrizePool uint64 `json:"prize_pool"`
+	// Position is the ticket divided by the prize pool, from 0 to 1
+	Position float64 `json:"position"`
+}
+
+type fairness struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newFairnessStore returns a new fairness reports storage service.
+func newFairnessStore(db *sql.DB, logger *logger.Logger) FairnessStore {
+	return &fairness{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add saves the fairness report of a draw, replacing the previous one of the same lottery.
+func (f *fairness) Add(report FairnessReport) error {
+	positions, err := json.Marshal(report.WinningPositions)
+	if err != nil {
+		return errors.Wrap(err, "encoding winning positions")
+	}
+
+	query := `INSERT OR REPLACE INTO fairness_reports
+	(lottery_height, players, tickets, gini, winning_positions) VALUES (?,?,?,?,?)`
+	stmt, err := f.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(report.LotteryHeight, report.Players, report.Tickets, report.Gini, positions)
+	if err != nil {
+		return errors.Wrap(err, "adding fairness report")
+	}
+
+	return nil
+}
+
+// List returns the fairness reports of the draws. The offset is the height of the lottery to start
+// after.
+//
+// A limit value of 0 means there's no limit.
+func (f *fairness) List(offset, limit uint64, reverse bool) ([]FairnessReport, error) {
+	// Cap limit to avoid creating a slice with too big capacity
+	if limit > 500 {
+		limit = 500
+	}
+
+	query := "SELECT lottery_height, players, tickets, gini, winning_positions FROM fairness_reports"
+	query = AddPagination(query, offset, limit, "lottery_height", reverse)
+
+	stmt, err := f.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing fairness reports")
+	}
+	defer rows.Close()
+
+	var reports []FairnessReport
+	for rows.Next() {
+		var (
+			report    FairnessReport
+			positions []byte
+		)
+		err := rows.Scan(&report.LotteryHeight, &report.Players, &report.Tickets, &report.Gini,
+			&positions)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		if err := json.Unmarshal(positions, &report.WinningPositions); err != nil {
+			return nil, errors.Wrap(err, "decoding winning positions")
+		}
+
+		reports = append(reports, report)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating fairness reports")
+	}
+
+	return reports, nil
+}
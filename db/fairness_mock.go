@@ -0,0 +1,30 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// FairnessStoreMock is a mocked implementation of the fairness reports store.
+type FairnessStoreMock struct {
+	mock.Mock
+}
+
+// NewFairnessStoreMock returns a mocked fairness reports store.
+func NewFairnessStoreMock() *FairnessStoreMock {
+	return &FairnessStoreMock{}
+}
+
+// Add mock.
+func (f *FairnessStoreMock) Add(report FairnessReport) error {
+	args := f.Called(report)
+	return args.Error(0)
+}
+
+// List mock.
+func (f *FairnessStoreMock) List(offset, limit uint64, reverse bool) ([]FairnessReport, error) {
+	args := f.Called(offset, limit, reverse)
+	var r0 []FairnessReport
+	v0 := args.Get(0)
+	if v0 != nil {
+		r0 = v0.([]FairnessReport)
+	}
+	return r0, args.Error(1)
+}
@@ -0,0 +1,64 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FairnessSuite struct {
+	suite.Suite
+
+	db *database.DB
+}
+
+func TestFairnessSuite(t *testing.T) {
+	suite.Run(t, &FairnessSuite{})
+}
+
+func (s *FairnessSuite) SetupTest() {
+	s.db = setupDB(s.T(), func(db *sql.DB) {})
+}
+
+func (s *FairnessSuite) TestAdd() {
+	report := database.FairnessReport{
+		LotteryHeight: 144,
+		Players:       3,
+		Tickets:       1_000,
+		Gini:          0.25,
+		WinningPositions: []database.WinningPosition{
+			{Ticket: 250, PrizePool: 1_000, Position: 0.25},
+			{Pool: "whale", Ticket: 90, PrizePool: 100, Position: 0.9},
+		},
+	}
+	s.NoError(s.db.Fairness.Add(report))
+
+	// Draws replayed after a crash replace the previous report
+	report.Gini = 0.5
+	s.NoError(s.db.Fairness.Add(report))
+
+	reports, err := s.db.Fairness.List(0, 0, false)
+	s.NoError(err)
+	s.Equal([]database.FairnessReport{report}, reports)
+}
+
+func (s *FairnessSuite) TestList() {
+	for _, height := range []uint32{144, 288, 432} {
+		report := database.FairnessReport{LotteryHeight: height, WinningPositions: []database.WinningPosition{}}
+		s.NoError(s.db.Fairness.Add(report))
+	}
+
+	reports, err := s.db.Fairness.List(0, 2, true)
+	s.NoError(err)
+	s.Len(reports, 2)
+	s.Equal(uint32(432), reports[0].LotteryHeight)
+	s.Equal(uint32(288), reports[1].LotteryHeight)
+
+	reports, err = s.db.Fairness.List(144, 0, false)
+	s.NoError(err)
+	s.Len(reports, 2)
+	s.Equal(uint32(288), reports[0].LotteryHeight)
+}
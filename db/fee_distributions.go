@@ -0,0 +1,155 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// FeeDistributionsStore contains the methods used to store and retrieve the shares of the house
+// fee forwarded to each destination after the draws.
+type FeeDistributionsStore interface {
+	Add(distributions []FeeDistribution) error
+	List(offset, limit uint64) ([]FeeDistribution, error)
+	ListUnpaid(lotteryHeight uint32) ([]FeeDistribution, error)
+	SetPaid(id uint64, preimage string, paidAt int64) error
+}
+
+// FeeDistribution is the share of the fee of a lottery owed to a destination.
+type FeeDistribution struct {
+	Destination string `json:"destination"`
+	Address     string `json:"address"`
+	// Preimage is the proof of the payment, empty until it's forwarded
+	Preimage      string `json:"preimage,omitempty"`
+	ID            uint64 `json:"id"`
+	Amount        uint64 `json:"amount"`
+	PaidAt        int64  `json:"paid_at,omitempty"`
+	LotteryHeight uint32 `json:"lottery_height"`
+}
+
+type feeDistributions struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newFeeDistributionsStore returns a new fee distributions storage service.
+func newFeeDistributionsStore(db *sql.DB, logger *logger.Logger) FeeDistributionsStore {
+	return &feeDistributions{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add stores the fee distributions. A lottery has a single distribution per destination, the ones
+// already stored are left as they are.
+func (f *feeDistributions) Add(distributions []FeeDistribution) error {
+	if len(distributions) == 0 {
+		return nil
+	}
+
+	query := "INSERT OR IGNORE INTO fee_distributions (lottery_height, destination, address, amount) VALUES "
+	query += BulkInsertValues(len(distributions), 4)
+
+	stmt, err := f.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	args := make([]any, 0, len(distributions)*4)
+	for _, d := range distributions {
+		args = append(args, d.LotteryHeight, d.Destination, d.Address, d.Amount)
+	}
+
+	if _, err := stmt.Exec(args...); err != nil {
+		return errors.Wrap(err, "storing fee distributions")
+	}
+
+	return nil
+}
+
+// List returns the fee distributions, the newest first. Offset is the identifier the list starts
+// after.
+//
+// A limit value of 0 means there's no limit.
+func (f *feeDistributions) List(offset, limit uint64) ([]FeeDistribution, error) {
+	// Cap limit to avoid creating a slice with too big capacity
+	if limit > 500 {
+		limit = 500
+	}
+
+	query := `SELECT id, lottery_height, destination, address, amount, preimage, paid_at
+	FROM fee_distributions`
+	query = AddPagination(query, offset, limit, "id", true)
+
+	stmt, err := f.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing fee distributions")
+	}
+	defer rows.Close()
+
+	return scanFeeDistributions(rows, limit)
+}
+
+// ListUnpaid returns the fee distributions of the lottery that weren't forwarded yet.
+func (f *feeDistributions) ListUnpaid(lotteryHeight uint32) ([]FeeDistribution, error) {
+	query := `SELECT id, lottery_height, destination, address, amount, preimage, paid_at
+	FROM fee_distributions WHERE lottery_height=? AND paid_at=0 ORDER BY id`
+	stmt, err := f.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(lotteryHeight)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing unpaid fee distributions")
+	}
+	defer rows.Close()
+
+	return scanFeeDistributions(rows, 0)
+}
+
+// SetPaid records the payment of a fee distribution.
+func (f *feeDistributions) SetPaid(id uint64, preimage string, paidAt int64) error {
+	stmt, err := f.db.Prepare("UPDATE fee_distributions SET preimage=?, paid_at=? WHERE id=?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(preimage, paidAt, id); err != nil {
+		return errors.Wrap(err, "updating fee distribution")
+	}
+
+	return nil
+}
+
+func scanFeeDistributions(rows *sql.Rows, capacity uint64) ([]FeeDistribution, error) {
+	distributions := make([]FeeDistribution, 0, capacity)
+	// Reuse object
+	var d FeeDistribution
+	for rows.Next() {
+		err := rows.Scan(&d.ID, &d.LotteryHeight, &d.Destination, &d.Address, &d.Amount,
+			&d.Preimage, &d.PaidAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		distributions = append(distributions, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating fee distributions")
+	}
+
+	return distributions, nil
+}
@@ -0,0 +1,37 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// FeeDistributionsStoreMock is a mocked implementation of the fee distributions store.
+type FeeDistributionsStoreMock struct {
+	mock.Mock
+}
+
+// NewFeeDistributionsStoreMock returns a mocked fee distributions store.
+func NewFeeDistributionsStoreMock() *FeeDistributionsStoreMock {
+	return &FeeDistributionsStoreMock{}
+}
+
+// Add mock.
+func (f *FeeDistributionsStoreMock) Add(distributions []FeeDistribution) error {
+	args := f.Called(distributions)
+	return args.Error(0)
+}
+
+// List mock.
+func (f *FeeDistributionsStoreMock) List(offset, limit uint64) ([]FeeDistribution, error) {
+	args := f.Called(offset, limit)
+	return args.Get(0).([]FeeDistribution), args.Error(1)
+}
+
+// ListUnpaid mock.
+func (f *FeeDistributionsStoreMock) ListUnpaid(lotteryHeight uint32) ([]FeeDistribution, error) {
+	args := f.Called(lotteryHeight)
+	return args.Get(0).([]FeeDistribution), args.Error(1)
+}
+
+// SetPaid mock.
+func (f *FeeDistributionsStoreMock) SetPaid(id uint64, preimage string, paidAt int64) error {
+	args := f.Called(id, preimage, paidAt)
+	return args.Error(0)
+}
@@ -0,0 +1,65 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FeeDistributionsSuite struct {
+	suite.Suite
+
+	db database.FeeDistributionsStore
+}
+
+func TestFeeDistributionsSuite(t *testing.T) {
+	suite.Run(t, &FeeDistributionsSuite{})
+}
+
+func (s *FeeDistributionsSuite) SetupTest() {
+	db := setupDB(s.T(), func(db *sql.DB) {})
+	s.db = db.Fees
+}
+
+func (s *FeeDistributionsSuite) TestFeeDistributions() {
+	distributions := []database.FeeDistribution{
+		{LotteryHeight: 144, Destination: "charity", Address: "charity@example.com", Amount: 300},
+		{LotteryHeight: 144, Destination: "dev", Address: "dev@example.com", Amount: 200},
+	}
+	s.NoError(s.db.Add(distributions))
+
+	// Distributions already stored are ignored
+	duplicate := []database.FeeDistribution{
+		{LotteryHeight: 144, Destination: "charity", Address: "other@example.com", Amount: 1},
+		{LotteryHeight: 288, Destination: "charity", Address: "charity@example.com", Amount: 100},
+	}
+	s.NoError(s.db.Add(duplicate))
+
+	unpaid, err := s.db.ListUnpaid(144)
+	s.NoError(err)
+	s.Len(unpaid, 2)
+	s.Equal("charity@example.com", unpaid[0].Address)
+	s.Equal(uint64(300), unpaid[0].Amount)
+
+	s.NoError(s.db.SetPaid(unpaid[0].ID, "preimage", 1231006505))
+
+	unpaid, err = s.db.ListUnpaid(144)
+	s.NoError(err)
+	s.Len(unpaid, 1)
+	s.Equal("dev", unpaid[0].Destination)
+
+	list, err := s.db.List(0, 2)
+	s.NoError(err)
+	s.Len(list, 2)
+	s.Equal(uint32(288), list[0].LotteryHeight)
+	s.Equal("dev", list[1].Destination)
+
+	list, err = s.db.List(list[1].ID, 0)
+	s.NoError(err)
+	s.Len(list, 1)
+	s.Equal("preimage", list[0].Preimage)
+	s.Equal(int64(1231006505), list[0].PaidAt)
+}
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// GrowthStore contains the methods used to track how the prize pool of the lotteries grows.
+type GrowthStore interface {
+	AddMilestone(lotteryHeight uint32, amount uint64, reachedAt int64) (bool, error)
+	ListMilestones(lotteryHeight uint32) ([]Milestone, error)
+	ListSamples(lotteryHeight uint32) ([]GrowthSample, error)
+	Sample(lotteryHeight, blockHeight uint32, createdAt int64) (GrowthSample, error)
+}
+
+// GrowthSample is the size of the prize pool of a lottery, the sum of all its pools, and the number
+// of bets paid when a block was mined.
+type GrowthSample struct {
+	PrizePool     uint64 `json:"prize_pool"`
+	Bets          uint64 `json:"bets"`
+	CreatedAt     int64  `json:"created_at"`
+	LotteryHeight uint32 `json:"lottery_height"`
+	BlockHeight   uint32 `json:"block_height"`
+}
+
+// Milestone is a prize pool amount a lottery reached, it's announced once.
+type Milestone struct {
+	Amount        uint64 `json:"amount"`
+	ReachedAt     int64  `json:"reached_at"`
+	LotteryHeight uint32 `json:"lottery_height"`
+}
+
+type growth struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newGrowthStore returns a new growth storage service.
+func newGrowthStore(db *sql.DB, logger *logger.Logger) GrowthStore {
+	return &growth{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// AddMilestone records that the lottery prize pool reached the amount. It returns false if it was
+// already recorded.
+func (g *growth) AddMilestone(lotteryHeight uint32, amount uint64, reachedAt int64) (bool, error) {
+	query := "INSERT OR IGNORE INTO growth_milestones (lottery_height, amount, reached_at) VALUES (?,?,?)"
+	stmt, err := g.db.Prepare(query)
+	if err != nil {
+		return false, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(lotteryHeight, amount, reachedAt)
+	if err != nil {
+		return false, errors.Wrap(err, "adding milestone")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "getting rows affected")
+	}
+
+	return rows == 1, nil
+}
+
+// ListMilestones returns the milestones reached by the lottery, in ascending order.
+func (g *growth) ListMilestones(lotteryHeight uint32) ([]Milestone, error) {
+	query := "SELECT amount, reached_at FROM growth_milestones WHERE lottery_height=? ORDER BY amount"
+	stmt, err := g.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(lotteryHeight)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing milestones")
+	}
+	defer rows.Close()
+
+	var milestones []Milestone
+	// Reuse object
+	milestone := Milestone{LotteryHeight: lotteryHeight}
+	for rows.Next() {
+		if err := rows.Scan(&milestone.Amount, &milestone.ReachedAt); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		milestones = append(milestones, milestone)
+	}
+
+	return milestones, nil
+}
+
+// ListSamples returns the samples of the lottery, sorted by block height.
+func (g *growth) ListSamples(lotteryHeight uint32) ([]GrowthSample, error) {
+	query := `SELECT block_height, prize_pool, bets, created_at FROM growth_samples
+	WHERE lottery_height=? ORDER BY block_height`
+	stmt, err := g.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(lotteryHeight)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing growth samples")
+	}
+	defer rows.Close()
+
+	var samples []GrowthSample
+	// Reuse object
+	sample := GrowthSample{LotteryHeight: lotteryHeight}
+	for rows.Next() {
+		err := rows.Scan(&sample.BlockHeight, &sample.PrizePool, &sample.Bets, &sample.CreatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		samples = append(samples, sample)
+	}
+
+	return samples, nil
+}
+
+// Sample saves the current prize pool and number of bets paid of the lottery at the block height,
+// replacing the previous sample of the same block. Airdropped bets count towards the prize pool
+// only.
+func (g *growth) Sample(lotteryHeight, blockHeight uint32, createdAt int64) (GrowthSample, error) {
+	tx, err := g.db.Begin()
+	if err != nil {
+		return GrowthSample{}, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	sample := GrowthSample{
+		LotteryHeight: lotteryHeight,
+		BlockHeight:   blockHeight,
+		CreatedAt:     createdAt,
+	}
+
+	// Ticket indexes start from one in each pool, the highest one is the pool size
+	query := `SELECT COALESCE(SUM(size), 0) FROM
+	(SELECT MAX(idx) AS size FROM bets WHERE lottery_height=? GROUP BY pool)`
+	if err := tx.QueryRow(query, lotteryHeight).Scan(&sample.PrizePool); err != nil {
+		return GrowthSample{}, errors.Wrap(err, "getting prize pool")
+	}
+
+	query = "SELECT COUNT(*) FROM bets WHERE lottery_height=? AND promo=''"
+	if err := tx.QueryRow(query, lotteryHeight).Scan(&sample.Bets); err != nil {
+		return GrowthSample{}, errors.Wrap(err, "counting bets")
+	}
+
+	query = `INSERT OR REPLACE INTO growth_samples
+	(lottery_height, block_height, prize_pool, bets, created_at) VALUES (?,?,?,?,?)`
+	_, err = tx.Exec(query, sample.LotteryHeight, sample.BlockHeight, sample.PrizePool, sample.Bets,
+		sample.CreatedAt)
+	if err != nil {
+		return GrowthSample{}, errors.Wrap(err, "adding growth sample")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return GrowthSample{}, errors.Wrap(err, "committing transaction")
+	}
+
+	return sample, nil
+}
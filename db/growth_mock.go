@@ -0,0 +1,45 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// GrowthStoreMock is a mocked implementation of the growth store.
+type GrowthStoreMock struct {
+	mock.Mock
+}
+
+// NewGrowthStoreMock returns a mocked growth store.
+func NewGrowthStoreMock() *GrowthStoreMock {
+	return &GrowthStoreMock{}
+}
+
+// AddMilestone mock.
+func (g *GrowthStoreMock) AddMilestone(lotteryHeight uint32, amount uint64, reachedAt int64) (bool, error) {
+	args := g.Called(lotteryHeight, amount, reachedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+// ListMilestones mock.
+func (g *GrowthStoreMock) ListMilestones(lotteryHeight uint32) ([]Milestone, error) {
+	args := g.Called(lotteryHeight)
+	var milestones []Milestone
+	if v := args.Get(0); v != nil {
+		milestones = v.([]Milestone)
+	}
+	return milestones, args.Error(1)
+}
+
+// ListSamples mock.
+func (g *GrowthStoreMock) ListSamples(lotteryHeight uint32) ([]GrowthSample, error) {
+	args := g.Called(lotteryHeight)
+	var samples []GrowthSample
+	if v := args.Get(0); v != nil {
+		samples = v.([]GrowthSample)
+	}
+	return samples, args.Error(1)
+}
+
+// Sample mock.
+func (g *GrowthStoreMock) Sample(lotteryHeight, blockHeight uint32, createdAt int64) (GrowthSample, error) {
+	args := g.Called(lotteryHeight, blockHeight, createdAt)
+	return args.Get(0).(GrowthSample), args.Error(1)
+}
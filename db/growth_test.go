@@ -0,0 +1,85 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type GrowthSuite struct {
+	suite.Suite
+
+	db *database.DB
+}
+
+func TestGrowthSuite(t *testing.T) {
+	suite.Run(t, &GrowthSuite{})
+}
+
+func (s *GrowthSuite) SetupTest() {
+	s.db = setupDB(s.T(), func(db *sql.DB) {
+		_, err := db.Exec(`INSERT INTO lotteries (height) VALUES (144);
+		INSERT INTO bets (first_idx, idx, tickets, public_key, lottery_height, pool, promo) VALUES
+			(1, 10, 10, 'a', 144, '', ''), (11, 30, 20, 'b', 144, '', ''),
+			(1, 5, 5, 'a', 144, 'whale', ''), (31, 35, 5, 'c', 144, '', 'launch')`)
+		s.NoError(err)
+	})
+}
+
+func (s *GrowthSuite) TestSample() {
+	sample, err := s.db.Growth.Sample(144, 100, 1231006505)
+	s.NoError(err)
+
+	expected := database.GrowthSample{
+		PrizePool:     40,
+		Bets:          3,
+		CreatedAt:     1231006505,
+		LotteryHeight: 144,
+		BlockHeight:   100,
+	}
+	s.Equal(expected, sample)
+
+	// The same block replaces the previous sample
+	_, err = s.db.Growth.Sample(144, 100, 1231006600)
+	s.NoError(err)
+	_, err = s.db.Growth.Sample(144, 101, 1231007000)
+	s.NoError(err)
+
+	samples, err := s.db.Growth.ListSamples(144)
+	s.NoError(err)
+	s.Len(samples, 2)
+	s.Equal(int64(1231006600), samples[0].CreatedAt)
+	s.Equal(uint32(101), samples[1].BlockHeight)
+}
+
+func (s *GrowthSuite) TestSampleEmpty() {
+	sample, err := s.db.Growth.Sample(288, 200, 1231006505)
+	s.NoError(err)
+
+	s.Zero(sample.PrizePool)
+	s.Zero(sample.Bets)
+}
+
+func (s *GrowthSuite) TestMilestones() {
+	added, err := s.db.Growth.AddMilestone(144, 100_000, 1231006505)
+	s.NoError(err)
+	s.True(added)
+
+	added, err = s.db.Growth.AddMilestone(144, 100_000, 1231006600)
+	s.NoError(err)
+	s.False(added)
+
+	_, err = s.db.Growth.AddMilestone(144, 50_000, 1231006400)
+	s.NoError(err)
+
+	milestones, err := s.db.Growth.ListMilestones(144)
+	s.NoError(err)
+	expected := []database.Milestone{
+		{Amount: 50_000, ReachedAt: 1231006400, LotteryHeight: 144},
+		{Amount: 100_000, ReachedAt: 1231006505, LotteryHeight: 144},
+	}
+	s.Equal(expected, milestones)
+}
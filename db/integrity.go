@@ -0,0 +1,346 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Kinds of integrity violations.
+const (
+	// ViolationTicketRange is a bet whose tickets don't follow the previous bet of the pool, or
+	// whose range doesn't match its number of tickets
+	ViolationTicketRange = "ticket_range"
+	// ViolationOrphanWinner is a winner of a lottery that doesn't exist
+	ViolationOrphanWinner = "orphan_winner"
+	// ViolationPrizesExceedPool is a lottery whose prizes add up to more than its prize pool
+	ViolationPrizesExceedPool = "prizes_exceed_pool"
+	// ViolationOrphanNotification is a notification linked to a public key without bets or prizes
+	ViolationOrphanNotification = "orphan_notification"
+)
+
+// Violation is a broken database invariant.
+type Violation struct {
+	Kind      string `json:"kind"`
+	Details   string `json:"details"`
+	Pool      string `json:"pool,omitempty"`
+	PublicKey string `json:"public_key,omitempty"`
+	// Repair describes the fix applied by Repair, empty if it must be fixed manually
+	Repair string `json:"repair,omitempty"`
+	Height uint32 `json:"height,omitempty"`
+}
+
+// String returns a description of the violation.
+func (v Violation) String() string {
+	return v.Kind + ": " + v.Details
+}
+
+// Verify cross-checks the invariants of the database and returns the violations found.
+//
+// Unless full is true only the lotteries since the last one drawn are checked and the notifications
+// are skipped, which is cheap enough to run on every start.
+func (db *DB) Verify(full bool) ([]Violation, error) {
+	minHeight := uint32(0)
+	if !full {
+		query := "SELECT COALESCE(MAX(height), 0) FROM stats_rounds"
+		if err := db.db.QueryRow(query).Scan(&minHeight); err != nil {
+			return nil, errors.Wrap(err, "getting last round")
+		}
+	}
+
+	checks := []func(minHeight uint32) ([]Violation, error){
+		db.verifyTicketRanges,
+		db.verifyWinners,
+		db.verifyPrizes,
+	}
+	if full {
+		checks = append(checks, func(uint32) ([]Violation, error) {
+			return db.verifyNotifications()
+		})
+	}
+
+	var violations []Violation
+	for _, check := range checks {
+		found, err := check(minHeight)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, found...)
+	}
+
+	return violations, nil
+}
+
+// Repair fixes a violation returned by Verify, if it has a repair available.
+func (db *DB) Repair(v Violation) error {
+	if v.Repair == "" {
+		return errors.Errorf("%s violations must be fixed manually", v.Kind)
+	}
+
+	switch v.Kind {
+	case ViolationTicketRange:
+		return db.renumberTickets(v.Height, v.Pool)
+	case ViolationOrphanNotification:
+		notificationsDB := db.db
+		if db.notifyDB != nil {
+			notificationsDB = db.notifyDB
+		}
+		for _, table := range []string{"notifications", "nostr_notifications"} {
+			query := "DELETE FROM " + table + " WHERE public_key=?"
+			if _, err := notificationsDB.Exec(query, v.PublicKey); err != nil {
+				return errors.Wrapf(err, "deleting %s", table)
+			}
+		}
+		return nil
+	default:
+		return errors.Errorf("unknown violation %q", v.Kind)
+	}
+}
+
+// verifyTicketRanges reports the pools of each lottery whose ticket indexes are not contiguous.
+func (db *DB) verifyTicketRanges(minHeight uint32) ([]Violation, error) {
+	query := `SELECT b.lottery_height, b.pool, b.first_idx, b.idx, b.tickets, b.previous,
+		COALESCE(l.block_hash, '')
+	FROM (
+		SELECT lottery_height, pool, first_idx, idx, tickets,
+			LAG(idx, 1, 0) OVER (PARTITION BY lottery_height, pool ORDER BY idx) AS previous
+		FROM bets WHERE lottery_height >= ?
+	) b LEFT JOIN lotteries l ON l.height = b.lottery_height
+	WHERE b.first_idx != b.previous + 1 OR b.idx - b.first_idx + 1 != b.tickets
+	ORDER BY b.lottery_height, b.pool, b.idx`
+	rows, err := db.db.Query(query, minHeight)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking ticket ranges")
+	}
+	defer rows.Close()
+
+	var violations []Violation
+	for rows.Next() {
+		var (
+			v                                     Violation
+			firstTicket, index, tickets, previous uint64
+			blockHash                             string
+		)
+		err := rows.Scan(&v.Height, &v.Pool, &firstTicket, &index, &tickets, &previous, &blockHash)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		// Only the first broken range of each pool is reported, renumbering fixes all of them
+		if n := len(violations); n > 0 && violations[n-1].Height == v.Height &&
+			violations[n-1].Pool == v.Pool {
+			continue
+		}
+
+		v.Kind = ViolationTicketRange
+		v.Details = fmt.Sprintf("lottery %d pool %q: bet of %d tickets holds %d-%d after ticket %d",
+			v.Height, v.Pool, tickets, firstTicket, index, previous)
+		// Renumbering a drawn lottery would change the holders of the winning tickets
+		if blockHash == "" {
+			v.Repair = "renumber the tickets of the pool in the order they were placed"
+		}
+		violations = append(violations, v)
+	}
+
+	return violations, rows.Err()
+}
+
+// verifyWinners reports the lotteries that have winners but don't exist.
+func (db *DB) verifyWinners(minHeight uint32) ([]Violation, error) {
+	query := `SELECT lottery_height, COUNT(*) FROM winners
+	WHERE lottery_height >= ? AND lottery_height NOT IN (SELECT height FROM lotteries)
+	GROUP BY lottery_height ORDER BY lottery_height`
+	rows, err := db.db.Query(query, minHeight)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking winners")
+	}
+	defer rows.Close()
+
+	var violations []Violation
+	for rows.Next() {
+		var (
+			v       Violation
+			winners uint64
+		)
+		if err := rows.Scan(&v.Height, &winners); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		v.Kind = ViolationOrphanWinner
+		v.Details = fmt.Sprintf("%d winners of lottery %d, which doesn't exist", winners, v.Height)
+		violations = append(violations, v)
+	}
+
+	return violations, rows.Err()
+}
+
+// verifyPrizes reports the lotteries whose prizes exceed their prize pool. Winners restored after a
+// failed payment have no ticket and are not counted, nor are the ones of lotteries that don't exist.
+func (db *DB) verifyPrizes(minHeight uint32) ([]Violation, error) {
+	query := `SELECT w.lottery_height, w.prizes, COALESCE(p.prize_pool, 0) FROM (
+		SELECT lottery_height, SUM(prize) AS prizes FROM winners
+		WHERE ticket != 0 AND lottery_height >= ? AND lottery_height IN (SELECT height FROM lotteries)
+		GROUP BY lottery_height
+	) w LEFT JOIN (
+		SELECT lottery_height, SUM(size) AS prize_pool FROM (
+			SELECT lottery_height, MAX(idx) AS size FROM bets GROUP BY lottery_height, pool
+		) GROUP BY lottery_height
+	) p ON p.lottery_height = w.lottery_height
+	WHERE w.prizes > COALESCE(p.prize_pool, 0)
+	ORDER BY w.lottery_height`
+	rows, err := db.db.Query(query, minHeight)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking prizes")
+	}
+	defer rows.Close()
+
+	var violations []Violation
+	for rows.Next() {
+		var (
+			v                 Violation
+			prizes, prizePool uint64
+		)
+		if err := rows.Scan(&v.Height, &prizes, &prizePool); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		v.Kind = ViolationPrizesExceedPool
+		v.Details = fmt.Sprintf("lottery %d prizes add up to %d sats, its prize pool is %d sats",
+			v.Height, prizes, prizePool)
+		violations = append(violations, v)
+	}
+
+	return violations, rows.Err()
+}
+
+// verifyNotifications reports the notifications linked to public keys that have no bets, winners
+// or prizes. They may be stored in another database, so the public keys are looked up one by one.
+func (db *DB) verifyNotifications() ([]Violation, error) {
+	notificationsDB := db.db
+	if db.notifyDB != nil {
+		notificationsDB = db.notifyDB
+	}
+
+	query := `SELECT public_key FROM notifications UNION SELECT public_key FROM nostr_notifications
+	ORDER BY public_key`
+	rows, err := notificationsDB.Query(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing notifications")
+	}
+
+	var publicKeys []string
+	for rows.Next() {
+		var publicKey string
+		if err := rows.Scan(&publicKey); err != nil {
+			rows.Close()
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating notifications")
+	}
+
+	stmt, err := db.db.Prepare(`SELECT EXISTS (SELECT 1 FROM bets WHERE public_key=?)
+	OR EXISTS (SELECT 1 FROM winners WHERE public_key=?)
+	OR EXISTS (SELECT 1 FROM prizes WHERE public_key=?)`)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var violations []Violation
+	for _, publicKey := range publicKeys {
+		var exists bool
+		if err := stmt.QueryRow(publicKey, publicKey, publicKey).Scan(&exists); err != nil {
+			return nil, errors.Wrap(err, "checking player")
+		}
+		if exists {
+			continue
+		}
+
+		violations = append(violations, Violation{
+			Kind:      ViolationOrphanNotification,
+			Details:   fmt.Sprintf("notifications of %s, which has no bets or prizes", publicKey),
+			PublicKey: publicKey,
+			Repair:    "delete the notifications of the public key",
+		})
+	}
+
+	return violations, nil
+}
+
+// renumberTickets assigns contiguous ranges to the bets of a lottery pool, keeping their order.
+func (db *DB) renumberTickets(lotteryHeight uint32, pool string) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	var blockHash string
+	err = tx.QueryRow("SELECT block_hash FROM lotteries WHERE height=?", lotteryHeight).Scan(&blockHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return errors.Wrap(err, "getting lottery")
+	}
+	if blockHash != "" {
+		return errors.Errorf("lottery %d was already drawn", lotteryHeight)
+	}
+
+	var offset uint64
+	query := `SELECT COALESCE(MAX(idx), 0) + COALESCE(SUM(tickets), 0) FROM bets
+	WHERE lottery_height=? AND pool=?`
+	if err := tx.QueryRow(query, lotteryHeight, pool).Scan(&offset); err != nil {
+		return errors.Wrap(err, "getting highest index")
+	}
+
+	// Moving every range past the highest index first avoids collisions while renumbering
+	query = "UPDATE bets SET idx = idx + ? WHERE lottery_height=? AND pool=?"
+	if _, err := tx.Exec(query, offset, lotteryHeight, pool); err != nil {
+		return errors.Wrap(err, "moving tickets")
+	}
+
+	query = "SELECT idx, tickets FROM bets WHERE lottery_height=? AND pool=? ORDER BY idx"
+	rows, err := tx.Query(query, lotteryHeight, pool)
+	if err != nil {
+		return errors.Wrap(err, "listing bets")
+	}
+
+	var bets [][2]uint64
+	for rows.Next() {
+		var index, tickets uint64
+		if err := rows.Scan(&index, &tickets); err != nil {
+			rows.Close()
+			return errors.Wrap(err, "scanning rows")
+		}
+		bets = append(bets, [2]uint64{index, tickets})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "iterating bets")
+	}
+
+	stmt, err := tx.Prepare("UPDATE bets SET first_idx=?, idx=? WHERE idx=? AND lottery_height=? AND pool=?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	highestIndex := uint64(0)
+	for _, bet := range bets {
+		index, tickets := bet[0], bet[1]
+		_, err := stmt.Exec(highestIndex+1, highestIndex+tickets, index, lotteryHeight, pool)
+		if err != nil {
+			return errors.Wrap(err, "renumbering bet")
+		}
+		highestIndex += tickets
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
+	return nil
+}
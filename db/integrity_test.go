@@ -0,0 +1,84 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	database := setupDB(t, func(sqlDB *sql.DB) {
+		_, err := sqlDB.Exec(`INSERT INTO lotteries (height, block_hash) VALUES (100, 'hash'), (200, '');
+		INSERT INTO stats_rounds (height, prize_pool, players, winners) VALUES (100, 20, 2, 1);
+		INSERT INTO bets (first_idx, idx, tickets, public_key, lottery_height, pool) VALUES
+			(1, 10, 10, 'a', 100, ''), (12, 20, 9, 'b', 100, ''),
+			(1, 5, 5, 'a', 200, ''), (4, 8, 5, 'b', 200, ''), (9, 10, 2, 'c', 200, ''),
+			(1, 5, 5, 'a', 200, 'whale');
+		INSERT INTO winners (public_key, prize, ticket, lottery_height) VALUES
+			('a', 25, 3, 100), ('a', 100, 0, 100), ('b', 5, 1, 50);
+		INSERT INTO notifications (public_key, chat_id, service) VALUES ('a', 1, 'telegram'), ('d', 2, 'telegram');
+		INSERT INTO nostr_notifications (public_key, nostr_public_key) VALUES ('e', 'npub')`)
+		assert.NoError(t, err)
+	})
+
+	violations, err := database.Verify(true)
+	assert.NoError(t, err)
+
+	kinds := make([]string, 0, len(violations))
+	for _, v := range violations {
+		kinds = append(kinds, v.Kind)
+	}
+	expected := []string{
+		db.ViolationTicketRange,
+		db.ViolationTicketRange,
+		db.ViolationOrphanWinner,
+		db.ViolationPrizesExceedPool,
+		db.ViolationOrphanNotification,
+		db.ViolationOrphanNotification,
+	}
+	assert.Equal(t, expected, kinds)
+
+	// The drawn lottery can't be renumbered
+	assert.Equal(t, uint32(100), violations[0].Height)
+	assert.Empty(t, violations[0].Repair)
+	assert.Error(t, database.Repair(violations[0]))
+	assert.Equal(t, uint32(200), violations[1].Height)
+	assert.Equal(t, "", violations[1].Pool)
+	assert.Equal(t, uint32(50), violations[2].Height)
+	assert.Equal(t, uint32(100), violations[3].Height)
+	assert.Equal(t, "d", violations[4].PublicKey)
+	assert.Equal(t, "e", violations[5].PublicKey)
+
+	// Quick checks skip the lotteries before the last one drawn and the notifications
+	quick, err := database.Verify(false)
+	assert.NoError(t, err)
+	assert.Equal(t, violations[:2], quick[:2])
+	assert.Equal(t, violations[3], quick[2])
+	assert.Len(t, quick, 3)
+
+	for _, v := range violations {
+		if v.Repair != "" {
+			assert.NoError(t, database.Repair(v))
+		}
+	}
+
+	bets, err := database.Bets.List(200, "", 0, 0, false)
+	assert.NoError(t, err)
+	assert.Len(t, bets, 3)
+	for i, expected := range [][2]uint64{{1, 5}, {6, 10}, {11, 12}} {
+		assert.Equal(t, expected, [2]uint64{bets[i].FirstTicket, bets[i].Index})
+	}
+
+	_, err = database.Notifications.GetChatID("d")
+	assert.ErrorIs(t, err, db.ErrNoChatID)
+	chatID, err := database.Notifications.GetChatID("a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), chatID)
+
+	violations, err = database.Verify(true)
+	assert.NoError(t, err)
+	assert.Len(t, violations, 3)
+}
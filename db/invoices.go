@@ -0,0 +1,325 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// Invoice statuses.
+const (
+	InvoicePending = "pending"
+	InvoicePaid    = "paid"
+	InvoiceExpired = "expired"
+)
+
+// maxInvoiceRetries is the number of times the bet of a paid invoice is retried after a restart
+// before the invoice stops holding back the settle index.
+const maxInvoiceRetries = 3
+
+// ErrInvoiceNotFound is returned when there's no invoice tracked with the payment hash specified.
+var ErrInvoiceNotFound = errors.New("invoice not found")
+
+// InvoicesStore contains the methods used to track the bet invoices generated until they are paid
+// or expire.
+type InvoicesStore interface {
+	Add(invoice Invoice) error
+	Expire(paymentHash string, now int64) (bool, error)
+	Get(paymentHash string) (Invoice, error)
+	LastSettleIndex() (uint64, error)
+	ListExpired(since int64) ([]Invoice, error)
+	ListHeld(now int64) ([]Invoice, error)
+	Retry(paymentHash string, settleIndex uint64) error
+	Settle(paymentHash string, settleIndex uint64, now int64) error
+	Stats(since int64) (InvoiceStats, error)
+}
+
+// Invoice is a bet invoice generated by the API.
+//
+// Hold invoices keep their preimage until they are settled or cancelled, so they can be watched
+// again after a restart.
+type Invoice struct {
+	PaymentHash string `json:"payment_hash"`
+	PublicKey   string `json:"public_key"`
+	Status      string `json:"status"`
+	Preimage    string `json:"-"`
+	Amount      uint64 `json:"amount"`
+	CreatedAt   int64  `json:"created_at"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+// InvoiceStats contains the number of invoices generated in a period and how many of them were
+// paid, expired or are still pending, with their amounts in sats.
+type InvoiceStats struct {
+	Created       uint64 `json:"created"`
+	Paid          uint64 `json:"paid"`
+	Expired       uint64 `json:"expired"`
+	Pending       uint64 `json:"pending"`
+	CreatedAmount uint64 `json:"created_amount"`
+	PaidAmount    uint64 `json:"paid_amount"`
+	ExpiredAmount uint64 `json:"expired_amount"`
+	PendingAmount uint64 `json:"pending_amount"`
+}
+
+type invoices struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newInvoicesStore returns a new invoices storage service.
+func newInvoicesStore(db *sql.DB, logger *logger.Logger) InvoicesStore {
+	return &invoices{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add starts tracking a pending invoice.
+func (i *invoices) Add(invoice Invoice) error {
+	query := `INSERT INTO invoices (payment_hash, public_key, amount, status, created_at, expires_at,
+	preimage) VALUES (?,?,?,?,?,?,?)`
+	stmt, err := i.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(invoice.PaymentHash, invoice.PublicKey, invoice.Amount, InvoicePending,
+		invoice.CreatedAt, invoice.ExpiresAt, invoice.Preimage)
+	if err != nil {
+		return errors.Wrap(err, "adding invoice")
+	}
+
+	return nil
+}
+
+// Expire marks the invoice as expired if it's still pending and returns whether it was.
+func (i *invoices) Expire(paymentHash string, now int64) (bool, error) {
+	return i.setStatus(paymentHash, InvoiceExpired, now)
+}
+
+// Get returns the invoice with the payment hash specified.
+func (i *invoices) Get(paymentHash string) (Invoice, error) {
+	query := `SELECT public_key, amount, status, created_at, expires_at FROM invoices
+	WHERE payment_hash=?`
+	stmt, err := i.db.Prepare(query)
+	if err != nil {
+		return Invoice{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	invoice := Invoice{PaymentHash: paymentHash}
+	err = stmt.QueryRow(paymentHash).Scan(&invoice.PublicKey, &invoice.Amount, &invoice.Status,
+		&invoice.CreatedAt, &invoice.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Invoice{}, ErrInvoiceNotFound
+		}
+		return Invoice{}, errors.Wrap(err, "getting invoice")
+	}
+
+	return invoice, nil
+}
+
+// LastSettleIndex returns the highest lightning node settle index of the invoices registered, or
+// zero if none was. If the bet of a paid invoice couldn't be placed, the index right before the
+// oldest of them is returned instead so they are received again, unless they were retried
+// maxInvoiceRetries times already.
+func (i *invoices) LastSettleIndex() (uint64, error) {
+	query := `SELECT COALESCE(
+		(SELECT MIN(settle_index) - 1 FROM invoices
+		WHERE status=? AND settle_index > 0 AND retries <= ?),
+		MAX(settle_index),
+		0
+	) FROM invoices`
+	stmt, err := i.db.Prepare(query)
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var settleIndex uint64
+	if err := stmt.QueryRow(InvoicePending, maxInvoiceRetries).Scan(&settleIndex); err != nil {
+		return 0, errors.Wrap(err, "getting last settle index")
+	}
+
+	return settleIndex, nil
+}
+
+// ListExpired returns the invoices that expired since the timestamp specified.
+func (i *invoices) ListExpired(since int64) ([]Invoice, error) {
+	query := `SELECT payment_hash, public_key, amount, status, created_at, expires_at FROM invoices
+	WHERE status=? AND updated_at >= ? ORDER BY updated_at`
+	stmt, err := i.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(InvoiceExpired, since)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing expired invoices")
+	}
+	defer rows.Close()
+
+	var invoices []Invoice
+	for rows.Next() {
+		var invoice Invoice
+		err := rows.Scan(&invoice.PaymentHash, &invoice.PublicKey, &invoice.Amount, &invoice.Status,
+			&invoice.CreatedAt, &invoice.ExpiresAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning invoices")
+		}
+
+		invoices = append(invoices, invoice)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating invoices")
+	}
+
+	return invoices, nil
+}
+
+// ListHeld returns the pending hold invoices that didn't expire yet, the oldest first.
+func (i *invoices) ListHeld(now int64) ([]Invoice, error) {
+	query := `SELECT payment_hash, public_key, amount, status, created_at, expires_at, preimage
+	FROM invoices WHERE status=? AND preimage != '' AND expires_at > ? ORDER BY created_at`
+	stmt, err := i.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(InvoicePending, now)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing held invoices")
+	}
+	defer rows.Close()
+
+	var invoices []Invoice
+	for rows.Next() {
+		var invoice Invoice
+		err := rows.Scan(&invoice.PaymentHash, &invoice.PublicKey, &invoice.Amount, &invoice.Status,
+			&invoice.CreatedAt, &invoice.ExpiresAt, &invoice.Preimage)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning invoices")
+		}
+
+		invoices = append(invoices, invoice)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating invoices")
+	}
+
+	return invoices, nil
+}
+
+// Settle marks the invoice as paid if it's still pending and records the lightning node settle
+// index. Invoices that weren't tracked are ignored.
+func (i *invoices) Settle(paymentHash string, settleIndex uint64, now int64) error {
+	if _, err := i.setStatus(paymentHash, InvoicePaid, now); err != nil {
+		return err
+	}
+
+	query := "UPDATE invoices SET settle_index=? WHERE payment_hash=?"
+	stmt, err := i.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(settleIndex, paymentHash); err != nil {
+		return errors.Wrap(err, "setting invoice settle index")
+	}
+
+	return nil
+}
+
+// Retry records the settle index of a paid invoice whose bet couldn't be placed, leaving it
+// pending, so the invoices settled from then on are received again after a restart. The number of
+// retries is counted so an invoice that keeps failing doesn't hold back the others forever.
+func (i *invoices) Retry(paymentHash string, settleIndex uint64) error {
+	query := "UPDATE invoices SET settle_index=?, retries=retries+1 WHERE payment_hash=? AND status=?"
+	stmt, err := i.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(settleIndex, paymentHash, InvoicePending); err != nil {
+		return errors.Wrap(err, "setting invoice settle index")
+	}
+
+	return nil
+}
+
+// Stats returns the conversion of the invoices created since the timestamp specified.
+func (i *invoices) Stats(since int64) (InvoiceStats, error) {
+	query := `SELECT status, COUNT(*), COALESCE(SUM(amount), 0) FROM invoices
+	WHERE created_at >= ? GROUP BY status`
+	stmt, err := i.db.Prepare(query)
+	if err != nil {
+		return InvoiceStats{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(since)
+	if err != nil {
+		return InvoiceStats{}, errors.Wrap(err, "querying invoices stats")
+	}
+	defer rows.Close()
+
+	var stats InvoiceStats
+	for rows.Next() {
+		var (
+			status        string
+			count, amount uint64
+		)
+		if err := rows.Scan(&status, &count, &amount); err != nil {
+			return InvoiceStats{}, errors.Wrap(err, "scanning invoices stats")
+		}
+
+		switch status {
+		case InvoicePaid:
+			stats.Paid, stats.PaidAmount = count, amount
+		case InvoiceExpired:
+			stats.Expired, stats.ExpiredAmount = count, amount
+		case InvoicePending:
+			stats.Pending, stats.PendingAmount = count, amount
+		}
+		stats.Created += count
+		stats.CreatedAmount += amount
+	}
+
+	if err := rows.Err(); err != nil {
+		return InvoiceStats{}, errors.Wrap(err, "iterating invoices stats")
+	}
+
+	return stats, nil
+}
+
+// setStatus completes a pending invoice, the preimage of hold invoices isn't needed anymore.
+func (i *invoices) setStatus(paymentHash, status string, now int64) (bool, error) {
+	query := "UPDATE invoices SET status=?, updated_at=?, preimage='' WHERE payment_hash=? AND status=?"
+	stmt, err := i.db.Prepare(query)
+	if err != nil {
+		return false, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(status, now, paymentHash, InvoicePending)
+	if err != nil {
+		return false, errors.Wrapf(err, "setting invoice status to %s", status)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "counting updated invoices")
+	}
+
+	return updated > 0, nil
+}
@@ -0,0 +1,67 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// InvoicesStoreMock is a mocked implementation of the invoices store.
+type InvoicesStoreMock struct {
+	mock.Mock
+}
+
+// NewInvoicesStoreMock returns a mocked invoices store.
+func NewInvoicesStoreMock() *InvoicesStoreMock {
+	return &InvoicesStoreMock{}
+}
+
+// Add mock.
+func (i *InvoicesStoreMock) Add(invoice Invoice) error {
+	args := i.Called(invoice)
+	return args.Error(0)
+}
+
+// Expire mock.
+func (i *InvoicesStoreMock) Expire(paymentHash string, now int64) (bool, error) {
+	args := i.Called(paymentHash, now)
+	return args.Bool(0), args.Error(1)
+}
+
+// Get mock.
+func (i *InvoicesStoreMock) Get(paymentHash string) (Invoice, error) {
+	args := i.Called(paymentHash)
+	return args.Get(0).(Invoice), args.Error(1)
+}
+
+// LastSettleIndex mock.
+func (i *InvoicesStoreMock) LastSettleIndex() (uint64, error) {
+	args := i.Called()
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+// ListExpired mock.
+func (i *InvoicesStoreMock) ListExpired(since int64) ([]Invoice, error) {
+	args := i.Called(since)
+	return args.Get(0).([]Invoice), args.Error(1)
+}
+
+// ListHeld mock.
+func (i *InvoicesStoreMock) ListHeld(now int64) ([]Invoice, error) {
+	args := i.Called(now)
+	return args.Get(0).([]Invoice), args.Error(1)
+}
+
+// Retry mock.
+func (i *InvoicesStoreMock) Retry(paymentHash string, settleIndex uint64) error {
+	args := i.Called(paymentHash, settleIndex)
+	return args.Error(0)
+}
+
+// Settle mock.
+func (i *InvoicesStoreMock) Settle(paymentHash string, settleIndex uint64, now int64) error {
+	args := i.Called(paymentHash, settleIndex, now)
+	return args.Error(0)
+}
+
+// Stats mock.
+func (i *InvoicesStoreMock) Stats(since int64) (InvoiceStats, error) {
+	args := i.Called(since)
+	return args.Get(0).(InvoiceStats), args.Error(1)
+}
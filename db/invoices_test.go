@@ -0,0 +1,241 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type InvoicesSuite struct {
+	suite.Suite
+
+	db database.InvoicesStore
+}
+
+func TestInvoicesSuite(t *testing.T) {
+	suite.Run(t, &InvoicesSuite{})
+}
+
+func (i *InvoicesSuite) SetupTest() {
+	db := setupDB(i.T(), func(db *sql.DB) {})
+	i.db = db.Invoices
+}
+
+func (i *InvoicesSuite) TestAdd() {
+	invoice := database.Invoice{
+		PaymentHash: "hash",
+		PublicKey:   "public_key",
+		Amount:      1_000,
+		CreatedAt:   100,
+		ExpiresAt:   200,
+	}
+	err := i.db.Add(invoice)
+	i.NoError(err)
+
+	got, err := i.db.Get(invoice.PaymentHash)
+	i.NoError(err)
+
+	invoice.Status = database.InvoicePending
+	i.Equal(invoice, got)
+
+	// Payment hashes are unique
+	err = i.db.Add(invoice)
+	i.Error(err)
+}
+
+func (i *InvoicesSuite) TestGetNotFound() {
+	_, err := i.db.Get("hash")
+	i.ErrorIs(err, database.ErrInvoiceNotFound)
+}
+
+func (i *InvoicesSuite) TestSettle() {
+	i.NoError(i.db.Add(database.Invoice{PaymentHash: "hash", Amount: 1_000}))
+
+	err := i.db.Settle("hash", 1, 150)
+	i.NoError(err)
+
+	invoice, err := i.db.Get("hash")
+	i.NoError(err)
+	i.Equal(database.InvoicePaid, invoice.Status)
+
+	// Paid invoices can't expire
+	expired, err := i.db.Expire("hash", 200)
+	i.NoError(err)
+	i.False(expired)
+
+	// Untracked invoices are ignored
+	err = i.db.Settle("untracked", 2, 150)
+	i.NoError(err)
+}
+
+func (i *InvoicesSuite) TestLastSettleIndex() {
+	settleIndex, err := i.db.LastSettleIndex()
+	i.NoError(err)
+	i.Zero(settleIndex)
+
+	i.NoError(i.db.Add(database.Invoice{PaymentHash: "hash", Amount: 1_000}))
+	i.NoError(i.db.Add(database.Invoice{PaymentHash: "hash2", Amount: 2_000}))
+	i.NoError(i.db.Settle("hash2", 7, 150))
+	i.NoError(i.db.Settle("hash", 5, 160))
+	// Settle indexes of untracked invoices are not recorded
+	i.NoError(i.db.Settle("untracked", 9, 170))
+
+	settleIndex, err = i.db.LastSettleIndex()
+	i.NoError(err)
+	i.Equal(uint64(7), settleIndex)
+
+	// Paid invoices whose bet wasn't placed are received again
+	i.NoError(i.db.Add(database.Invoice{PaymentHash: "hash3", Amount: 3_000}))
+	i.NoError(i.db.Retry("hash3", 6))
+	// Invoices settled already are not retried
+	i.NoError(i.db.Retry("hash2", 2))
+
+	settleIndex, err = i.db.LastSettleIndex()
+	i.NoError(err)
+	i.Equal(uint64(5), settleIndex)
+
+	i.NoError(i.db.Settle("hash3", 6, 180))
+	settleIndex, err = i.db.LastSettleIndex()
+	i.NoError(err)
+	i.Equal(uint64(7), settleIndex)
+}
+
+func (i *InvoicesSuite) TestLastSettleIndexRetries() {
+	i.NoError(i.db.Add(database.Invoice{PaymentHash: "hash", Amount: 1_000}))
+	i.NoError(i.db.Add(database.Invoice{PaymentHash: "hash2", Amount: 2_000}))
+	i.NoError(i.db.Settle("hash2", 7, 150))
+
+	for range 3 {
+		i.NoError(i.db.Retry("hash", 5))
+
+		settleIndex, err := i.db.LastSettleIndex()
+		i.NoError(err)
+		i.Equal(uint64(4), settleIndex)
+	}
+
+	// The invoice kept failing, it no longer holds back the rest
+	i.NoError(i.db.Retry("hash", 5))
+
+	settleIndex, err := i.db.LastSettleIndex()
+	i.NoError(err)
+	i.Equal(uint64(7), settleIndex)
+}
+
+func (i *InvoicesSuite) TestExpire() {
+	i.NoError(i.db.Add(database.Invoice{PaymentHash: "hash", Amount: 1_000}))
+
+	expired, err := i.db.Expire("hash", 200)
+	i.NoError(err)
+	i.True(expired)
+
+	expired, err = i.db.Expire("hash", 300)
+	i.NoError(err)
+	i.False(expired)
+
+	// Expired invoices stay expired
+	i.NoError(i.db.Settle("hash", 1, 400))
+
+	invoice, err := i.db.Get("hash")
+	i.NoError(err)
+	i.Equal(database.InvoiceExpired, invoice.Status)
+}
+
+func (i *InvoicesSuite) TestListExpired() {
+	invoices := []database.Invoice{
+		{PaymentHash: "old", Amount: 1_000},
+		{PaymentHash: "expired", Amount: 2_000},
+		{PaymentHash: "paid", Amount: 3_000},
+		{PaymentHash: "pending", Amount: 4_000},
+	}
+	for _, invoice := range invoices {
+		i.NoError(i.db.Add(invoice))
+	}
+	_, err := i.db.Expire("old", 100)
+	i.NoError(err)
+	_, err = i.db.Expire("expired", 200)
+	i.NoError(err)
+	i.NoError(i.db.Settle("paid", 1, 200))
+
+	expired, err := i.db.ListExpired(150)
+	i.NoError(err)
+
+	expected := []database.Invoice{
+		{PaymentHash: "expired", Amount: 2_000, Status: database.InvoiceExpired},
+	}
+	i.Equal(expected, expired)
+}
+
+func (i *InvoicesSuite) TestListHeld() {
+	invoices := []database.Invoice{
+		{PaymentHash: "held", Preimage: "preimage", CreatedAt: 110, ExpiresAt: 300},
+		{PaymentHash: "regular", CreatedAt: 100, ExpiresAt: 300},
+		{PaymentHash: "held_old", Preimage: "preimage2", CreatedAt: 100, ExpiresAt: 300},
+		{PaymentHash: "timed_out", Preimage: "preimage3", CreatedAt: 90, ExpiresAt: 150},
+		{PaymentHash: "settled", Preimage: "preimage4", CreatedAt: 120, ExpiresAt: 300},
+	}
+	for _, invoice := range invoices {
+		i.NoError(i.db.Add(invoice))
+	}
+	i.NoError(i.db.Settle("settled", 1, 200))
+
+	held, err := i.db.ListHeld(200)
+	i.NoError(err)
+
+	expected := []database.Invoice{
+		{
+			PaymentHash: "held_old",
+			Status:      database.InvoicePending,
+			Preimage:    "preimage2",
+			CreatedAt:   100,
+			ExpiresAt:   300,
+		},
+		{
+			PaymentHash: "held",
+			Status:      database.InvoicePending,
+			Preimage:    "preimage",
+			CreatedAt:   110,
+			ExpiresAt:   300,
+		},
+	}
+	i.Equal(expected, held)
+
+	// Settled invoices drop their preimage
+	invoice, err := i.db.Get("settled")
+	i.NoError(err)
+	i.Empty(invoice.Preimage)
+}
+
+func (i *InvoicesSuite) TestStats() {
+	invoices := []database.Invoice{
+		{PaymentHash: "old", Amount: 5_000, CreatedAt: 50},
+		{PaymentHash: "paid", Amount: 1_000, CreatedAt: 100},
+		{PaymentHash: "paid2", Amount: 2_000, CreatedAt: 110},
+		{PaymentHash: "expired", Amount: 3_000, CreatedAt: 120},
+		{PaymentHash: "pending", Amount: 4_000, CreatedAt: 130},
+	}
+	for _, invoice := range invoices {
+		i.NoError(i.db.Add(invoice))
+	}
+	i.NoError(i.db.Settle("paid", 1, 150))
+	i.NoError(i.db.Settle("paid2", 2, 150))
+	_, err := i.db.Expire("expired", 150)
+	i.NoError(err)
+
+	stats, err := i.db.Stats(100)
+	i.NoError(err)
+
+	expected := database.InvoiceStats{
+		Created:       4,
+		Paid:          2,
+		Expired:       1,
+		Pending:       1,
+		CreatedAmount: 10_000,
+		PaidAmount:    3_000,
+		ExpiredAmount: 3_000,
+		PendingAmount: 4_000,
+	}
+	i.Equal(expected, stats)
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// JobsStore contains the methods used to persist the jobs queue.
+//
+// Jobs are claimed by pushing their run time forward, if the process crashes while running one it
+// is claimed again once that time is reached. Jobs that exhausted their attempts are kept as
+// failed for inspection.
+type JobsStore interface {
+	Add(kind string, payload []byte, runAt int64) (uint64, error)
+	Claim(now, leaseUntil int64, limit uint64) ([]Job, error)
+	Delete(id uint64) error
+	Fail(id uint64, lastError string) error
+	Retry(id uint64, runAt int64, lastError string) error
+}
+
+// Job is a task executed asynchronously.
+type Job struct {
+	Kind      string
+	LastError string
+	Payload   []byte
+	ID        uint64
+	RunAt     int64
+	Attempts  uint32
+}
+
+type jobs struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newJobsStore returns a new jobs storage service.
+func newJobsStore(db *sql.DB, logger *logger.Logger) JobsStore {
+	return &jobs{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add enqueues a job to be executed at runAt and returns its identifier.
+func (j *jobs) Add(kind string, payload []byte, runAt int64) (uint64, error) {
+	stmt, err := j.db.Prepare("INSERT INTO jobs (kind, payload, run_at) VALUES (?,?,?)")
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(kind, payload, runAt)
+	if err != nil {
+		return 0, errors.Wrap(err, "adding job")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, errors.Wrap(err, "getting job id")
+	}
+
+	return uint64(id), nil
+}
+
+// Claim returns up to limit jobs due at now, oldest first, increasing their attempts and
+// postponing them until leaseUntil so they are not claimed twice.
+func (j *jobs) Claim(now, leaseUntil int64, limit uint64) ([]Job, error) {
+	tx, err := j.db.Begin()
+	if err != nil {
+		return nil, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	query := `SELECT id, kind, payload, attempts, last_error FROM jobs
+	WHERE failed = 0 AND run_at <= ? ORDER BY run_at, id LIMIT ?`
+	rows, err := tx.Query(query, now, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying jobs")
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		var job Job
+		if err := rows.Scan(&job.ID, &job.Kind, &job.Payload, &job.Attempts, &job.LastError); err != nil {
+			return nil, errors.Wrap(err, "scanning job")
+		}
+		job.Attempts++
+		job.RunAt = leaseUntil
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating jobs")
+	}
+
+	stmt, err := tx.Prepare("UPDATE jobs SET attempts = attempts + 1, run_at = ? WHERE id = ?")
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	for _, job := range jobs {
+		if _, err := stmt.Exec(leaseUntil, job.ID); err != nil {
+			return nil, errors.Wrap(err, "claiming job")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "committing transaction")
+	}
+
+	return jobs, nil
+}
+
+// Delete removes a job that was completed.
+func (j *jobs) Delete(id uint64) error {
+	stmt, err := j.db.Prepare("DELETE FROM jobs WHERE id = ?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(id); err != nil {
+		return errors.Wrap(err, "deleting job")
+	}
+
+	return nil
+}
+
+// Fail marks a job as failed, it won't be claimed again.
+func (j *jobs) Fail(id uint64, lastError string) error {
+	stmt, err := j.db.Prepare("UPDATE jobs SET failed = 1, last_error = ? WHERE id = ?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(lastError, id); err != nil {
+		return errors.Wrap(err, "failing job")
+	}
+
+	return nil
+}
+
+// Retry schedules a job that returned an error to be executed again at runAt.
+func (j *jobs) Retry(id uint64, runAt int64, lastError string) error {
+	stmt, err := j.db.Prepare("UPDATE jobs SET run_at = ?, last_error = ? WHERE id = ?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(runAt, lastError, id); err != nil {
+		return errors.Wrap(err, "rescheduling job")
+	}
+
+	return nil
+}
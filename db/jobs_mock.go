@@ -0,0 +1,47 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// JobsStoreMock is a mocked implementation of the jobs store.
+type JobsStoreMock struct {
+	mock.Mock
+}
+
+// NewJobsStoreMock returns a mocked jobs store.
+func NewJobsStoreMock() *JobsStoreMock {
+	return &JobsStoreMock{}
+}
+
+// Add mock.
+func (j *JobsStoreMock) Add(kind string, payload []byte, runAt int64) (uint64, error) {
+	args := j.Called(kind, payload, runAt)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+// Claim mock.
+func (j *JobsStoreMock) Claim(now, leaseUntil int64, limit uint64) ([]Job, error) {
+	args := j.Called(now, leaseUntil, limit)
+	var jobs []Job
+	if v := args.Get(0); v != nil {
+		jobs = v.([]Job)
+	}
+	return jobs, args.Error(1)
+}
+
+// Delete mock.
+func (j *JobsStoreMock) Delete(id uint64) error {
+	args := j.Called(id)
+	return args.Error(0)
+}
+
+// Fail mock.
+func (j *JobsStoreMock) Fail(id uint64, lastError string) error {
+	args := j.Called(id, lastError)
+	return args.Error(0)
+}
+
+// Retry mock.
+func (j *JobsStoreMock) Retry(id uint64, runAt int64, lastError string) error {
+	args := j.Called(id, runAt, lastError)
+	return args.Error(0)
+}
@@ -0,0 +1,97 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type JobsSuite struct {
+	suite.Suite
+
+	db database.JobsStore
+}
+
+func TestJobsSuite(t *testing.T) {
+	suite.Run(t, &JobsSuite{})
+}
+
+func (j *JobsSuite) SetupTest() {
+	db := setupDB(j.T(), func(db *sql.DB) {})
+	j.db = db.Jobs
+}
+
+func (j *JobsSuite) TestClaim() {
+	first, err := j.db.Add("notify", []byte(`{"a":1}`), 100)
+	j.NoError(err)
+	second, err := j.db.Add("stats", []byte(`{}`), 200)
+	j.NoError(err)
+	_, err = j.db.Add("stats", []byte(`{}`), 300)
+	j.NoError(err)
+
+	jobs, err := j.db.Claim(200, 500, 10)
+	j.NoError(err)
+
+	expected := []database.Job{
+		{ID: first, Kind: "notify", Payload: []byte(`{"a":1}`), Attempts: 1, RunAt: 500},
+		{ID: second, Kind: "stats", Payload: []byte(`{}`), Attempts: 1, RunAt: 500},
+	}
+	j.Equal(expected, jobs)
+
+	// Claimed jobs are leased
+	jobs, err = j.db.Claim(400, 500, 10)
+	j.NoError(err)
+	j.Len(jobs, 1)
+	j.Equal(uint32(1), jobs[0].Attempts)
+
+	// Lease expired, the process crashed while running them
+	jobs, err = j.db.Claim(500, 600, 1)
+	j.NoError(err)
+	j.Len(jobs, 1)
+	j.Equal(first, jobs[0].ID)
+	j.Equal(uint32(2), jobs[0].Attempts)
+}
+
+func (j *JobsSuite) TestDelete() {
+	id, err := j.db.Add("notify", []byte(`{}`), 100)
+	j.NoError(err)
+
+	err = j.db.Delete(id)
+	j.NoError(err)
+
+	jobs, err := j.db.Claim(100, 200, 10)
+	j.NoError(err)
+	j.Empty(jobs)
+}
+
+func (j *JobsSuite) TestRetry() {
+	id, err := j.db.Add("notify", []byte(`{}`), 100)
+	j.NoError(err)
+
+	_, err = j.db.Claim(100, 200, 10)
+	j.NoError(err)
+
+	err = j.db.Retry(id, 150, "timeout")
+	j.NoError(err)
+
+	jobs, err := j.db.Claim(150, 200, 10)
+	j.NoError(err)
+	j.Len(jobs, 1)
+	j.Equal("timeout", jobs[0].LastError)
+	j.Equal(uint32(2), jobs[0].Attempts)
+}
+
+func (j *JobsSuite) TestFail() {
+	id, err := j.db.Add("notify", []byte(`{}`), 100)
+	j.NoError(err)
+
+	err = j.db.Fail(id, "invalid payload")
+	j.NoError(err)
+
+	jobs, err := j.db.Claim(1_000, 2_000, 10)
+	j.NoError(err)
+	j.Empty(jobs)
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// LeasesStore contains the methods used to hold named leases shared by the instances using the
+// database.
+type LeasesStore interface {
+	Acquire(name, holder string, now, expiresAt int64) (bool, error)
+	Release(name, holder string) error
+}
+
+type leases struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newLeasesStore returns a new leases storage service.
+func newLeasesStore(db *sql.DB, logger *logger.Logger) LeasesStore {
+	return &leases{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Acquire takes or renews the lease until expiresAt and returns whether the holder got it. The
+// lease is only taken from another holder once it expired.
+func (l *leases) Acquire(name, holder string, now, expiresAt int64) (bool, error) {
+	query := `INSERT INTO leases (name, holder, expires_at) VALUES (?,?,?)
+	ON CONFLICT (name) DO UPDATE SET holder=excluded.holder, expires_at=excluded.expires_at
+	WHERE leases.holder=excluded.holder OR leases.expires_at<=?`
+	stmt, err := l.db.Prepare(query)
+	if err != nil {
+		return false, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(name, holder, expiresAt, now)
+	if err != nil {
+		return false, errors.Wrapf(err, "acquiring lease %q", name)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "getting rows affected")
+	}
+
+	return rows > 0, nil
+}
+
+// Release gives up the lease if it's held by the holder specified.
+func (l *leases) Release(name, holder string) error {
+	stmt, err := l.db.Prepare("DELETE FROM leases WHERE name=? AND holder=?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(name, holder); err != nil {
+		return errors.Wrapf(err, "releasing lease %q", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// LeasesStoreMock is a mocked implementation of the leases store.
+type LeasesStoreMock struct {
+	mock.Mock
+}
+
+// NewLeasesStoreMock returns a mocked leases store.
+func NewLeasesStoreMock() *LeasesStoreMock {
+	return &LeasesStoreMock{}
+}
+
+// Acquire mock.
+func (l *LeasesStoreMock) Acquire(name, holder string, now, expiresAt int64) (bool, error) {
+	args := l.Called(name, holder, now, expiresAt)
+	return args.Bool(0), args.Error(1)
+}
+
+// Release mock.
+func (l *LeasesStoreMock) Release(name, holder string) error {
+	args := l.Called(name, holder)
+	return args.Error(0)
+}
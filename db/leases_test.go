@@ -0,0 +1,44 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeases(t *testing.T) {
+	db := setupDB(t, func(db *sql.DB) {})
+
+	acquired, err := db.Leases.Acquire("leader", "a", 100, 130)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// The holder renews it while the rest wait for it to expire
+	acquired, err = db.Leases.Acquire("leader", "b", 110, 140)
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+
+	acquired, err = db.Leases.Acquire("leader", "a", 120, 150)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	acquired, err = db.Leases.Acquire("leader", "b", 140, 170)
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+
+	acquired, err = db.Leases.Acquire("leader", "b", 150, 180)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// Only the holder can release it
+	assert.NoError(t, db.Leases.Release("leader", "a"))
+	acquired, err = db.Leases.Acquire("leader", "a", 160, 190)
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+
+	assert.NoError(t, db.Leases.Release("leader", "b"))
+	acquired, err = db.Leases.Acquire("leader", "a", 160, 190)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+}
@@ -0,0 +1,174 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// LimitsStore contains the methods used to store the responsible gambling limits players set
+// themselves.
+//
+// Limits are stored with the time they take effect so changes can be delayed.
+type LimitsStore interface {
+	Exclude(publicKey string, until int64) error
+	GetActivity(publicKey string, fromHeight uint32) (Activity, error)
+	GetExclusion(publicKey string) (int64, error)
+	List(publicKey string, now int64) ([]Limit, error)
+	Set(publicKey string, limit Limit, now int64) error
+}
+
+// LimitKind is the type of a limit.
+type LimitKind string
+
+// Limit kinds.
+const (
+	// DepositLimit caps the sats bet in a week
+	DepositLimit LimitKind = "deposit"
+	// LossLimit caps the sats bet minus the prizes won in a week
+	LossLimit LimitKind = "loss"
+)
+
+// Limit is the maximum amount of sats a player allows itself to bet or lose in a week. An amount
+// of 0 means there's no limit.
+type Limit struct {
+	Kind        LimitKind `json:"kind"`
+	Amount      uint64    `json:"amount"`
+	EffectiveAt int64     `json:"effective_at"`
+}
+
+// Activity contains the sats bet and won by a player.
+type Activity struct {
+	Deposits uint64
+	Winnings uint64
+}
+
+type limits struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newLimitsStore returns a new limits storage service.
+func newLimitsStore(db *sql.DB, logger *logger.Logger) LimitsStore {
+	return &limits{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Exclude prevents a public key from betting until the time specified. Exclusions can only be
+// extended.
+func (l *limits) Exclude(publicKey string, until int64) error {
+	stmt, err := l.db.Prepare(`INSERT INTO exclusions (public_key, until) VALUES (?,?)
+	ON CONFLICT (public_key) DO UPDATE SET until = MAX(until, excluded.until)`)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(publicKey, until); err != nil {
+		return errors.Wrap(err, "adding exclusion")
+	}
+
+	return nil
+}
+
+// GetActivity returns the sats bet and won by a public key in the lotteries after fromHeight.
+//
+// Bonus tickets were not paid for and restored prizes were not won, neither is taken into account.
+func (l *limits) GetActivity(publicKey string, fromHeight uint32) (Activity, error) {
+	stmt, err := l.db.Prepare(`SELECT
+	(SELECT COALESCE(SUM(tickets - bonus), 0) FROM bets WHERE public_key=? AND lottery_height > ?),
+	(SELECT COALESCE(SUM(prize), 0) FROM winners WHERE public_key=? AND lottery_height > ? AND ticket != 0)`)
+	if err != nil {
+		return Activity{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var activity Activity
+	err = stmt.QueryRow(publicKey, fromHeight, publicKey, fromHeight).
+		Scan(&activity.Deposits, &activity.Winnings)
+	if err != nil {
+		return Activity{}, errors.Wrap(err, "getting activity")
+	}
+
+	return activity, nil
+}
+
+// GetExclusion returns the time until which the public key can't bet, or zero if it never
+// excluded itself.
+func (l *limits) GetExclusion(publicKey string) (int64, error) {
+	stmt, err := l.db.Prepare("SELECT until FROM exclusions WHERE public_key=?")
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var until int64
+	if err := stmt.QueryRow(publicKey).Scan(&until); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, errors.Wrap(err, "getting exclusion")
+	}
+
+	return until, nil
+}
+
+// List returns the limits of a public key in effect at now, followed by the ones that will take
+// effect later.
+func (l *limits) List(publicKey string, now int64) ([]Limit, error) {
+	stmt, err := l.db.Prepare(`SELECT kind, amount, effective_at FROM limits l WHERE public_key=? AND
+	(effective_at > ? OR effective_at = (SELECT MAX(effective_at) FROM limits
+		WHERE public_key = l.public_key AND kind = l.kind AND effective_at <= ?))
+	ORDER BY effective_at, kind`)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(publicKey, now, now)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing limits")
+	}
+	defer rows.Close()
+
+	var (
+		limits []Limit
+		// Reuse object
+		limit Limit
+	)
+	for rows.Next() {
+		if err := rows.Scan(&limit.Kind, &limit.Amount, &limit.EffectiveAt); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		limits = append(limits, limit)
+	}
+
+	return limits, nil
+}
+
+// Set stores a limit. Changes of the same kind that were not in effect at now are discarded.
+func (l *limits) Set(publicKey string, limit Limit, now int64) error {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	query := "DELETE FROM limits WHERE public_key=? AND kind=? AND effective_at > ?"
+	if _, err := tx.Exec(query, publicKey, limit.Kind, now); err != nil {
+		return errors.Wrap(err, "deleting pending limits")
+	}
+
+	query = `INSERT INTO limits (public_key, kind, amount, effective_at) VALUES (?,?,?,?)
+	ON CONFLICT (public_key, kind, effective_at) DO UPDATE SET amount = excluded.amount`
+	if _, err := tx.Exec(query, publicKey, limit.Kind, limit.Amount, limit.EffectiveAt); err != nil {
+		return errors.Wrap(err, "adding limit")
+	}
+
+	return tx.Commit()
+}
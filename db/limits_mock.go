@@ -0,0 +1,48 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// LimitsStoreMock is a mocked implementation of the limits store.
+type LimitsStoreMock struct {
+	mock.Mock
+}
+
+// NewLimitsStoreMock returns a mocked limits store.
+func NewLimitsStoreMock() *LimitsStoreMock {
+	return &LimitsStoreMock{}
+}
+
+// Exclude mock.
+func (l *LimitsStoreMock) Exclude(publicKey string, until int64) error {
+	args := l.Called(publicKey, until)
+	return args.Error(0)
+}
+
+// GetActivity mock.
+func (l *LimitsStoreMock) GetActivity(publicKey string, fromHeight uint32) (Activity, error) {
+	args := l.Called(publicKey, fromHeight)
+	return args.Get(0).(Activity), args.Error(1)
+}
+
+// GetExclusion mock.
+func (l *LimitsStoreMock) GetExclusion(publicKey string) (int64, error) {
+	args := l.Called(publicKey)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// List mock.
+func (l *LimitsStoreMock) List(publicKey string, now int64) ([]Limit, error) {
+	args := l.Called(publicKey, now)
+	var r0 []Limit
+	v0 := args.Get(0)
+	if v0 != nil {
+		r0 = v0.([]Limit)
+	}
+	return r0, args.Error(1)
+}
+
+// Set mock.
+func (l *LimitsStoreMock) Set(publicKey string, limit Limit, now int64) error {
+	args := l.Called(publicKey, limit, now)
+	return args.Error(0)
+}
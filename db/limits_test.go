@@ -0,0 +1,102 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const limitsPublicKey = "7d959d6d552c7d38b3ecafb72805fa03a6dee6b7f0c5f63f57a371736cb004b1"
+
+type LimitsSuite struct {
+	suite.Suite
+
+	db database.LimitsStore
+}
+
+func TestLimitsSuite(t *testing.T) {
+	suite.Run(t, &LimitsSuite{})
+}
+
+func (l *LimitsSuite) SetupTest() {
+	db := setupDB(l.T(), func(db *sql.DB) {
+		query := `INSERT INTO lotteries (height) VALUES (144), (288), (432);
+		INSERT INTO bets (idx, tickets, bonus, public_key, lottery_height) VALUES
+			(1100, 1100, 100, ?, 144), (500, 500, 0, ?, 288), (2500, 2000, 0, ?, 432), (3000, 500, 0, 'other', 432);
+		INSERT INTO winners (public_key, prize, ticket, lottery_height) VALUES
+			(?, 400, 20, 288), (?, 300, 0, 432), ('other', 1000, 2700, 432);`
+		_, err := db.Exec(query, limitsPublicKey, limitsPublicKey, limitsPublicKey,
+			limitsPublicKey, limitsPublicKey)
+		l.NoError(err)
+	})
+	l.db = db.Limits
+}
+
+func (l *LimitsSuite) TestExclude() {
+	until, err := l.db.GetExclusion(limitsPublicKey)
+	l.NoError(err)
+	l.Zero(until)
+
+	err = l.db.Exclude(limitsPublicKey, 2_000)
+	l.NoError(err)
+
+	// Exclusions can't be shortened
+	err = l.db.Exclude(limitsPublicKey, 1_000)
+	l.NoError(err)
+
+	until, err = l.db.GetExclusion(limitsPublicKey)
+	l.NoError(err)
+	l.Equal(int64(2_000), until)
+
+	err = l.db.Exclude(limitsPublicKey, 3_000)
+	l.NoError(err)
+
+	until, err = l.db.GetExclusion(limitsPublicKey)
+	l.NoError(err)
+	l.Equal(int64(3_000), until)
+}
+
+func (l *LimitsSuite) TestGetActivity() {
+	activity, err := l.db.GetActivity(limitsPublicKey, 0)
+	l.NoError(err)
+	l.Equal(database.Activity{Deposits: 3_500, Winnings: 400}, activity)
+
+	activity, err = l.db.GetActivity(limitsPublicKey, 288)
+	l.NoError(err)
+	l.Equal(database.Activity{Deposits: 2_000}, activity)
+
+	activity, err = l.db.GetActivity("unknown", 0)
+	l.NoError(err)
+	l.Zero(activity)
+}
+
+func (l *LimitsSuite) TestSet() {
+	now := int64(1_000)
+	deposit := database.Limit{Kind: database.DepositLimit, Amount: 10_000, EffectiveAt: now}
+	err := l.db.Set(limitsPublicKey, deposit, now)
+	l.NoError(err)
+
+	loss := database.Limit{Kind: database.LossLimit, Amount: 5_000, EffectiveAt: now}
+	err = l.db.Set(limitsPublicKey, loss, now)
+	l.NoError(err)
+
+	looserDeposit := database.Limit{Kind: database.DepositLimit, Amount: 20_000, EffectiveAt: now + 500}
+	err = l.db.Set(limitsPublicKey, looserDeposit, now)
+	l.NoError(err)
+
+	limits, err := l.db.List(limitsPublicKey, now)
+	l.NoError(err)
+	l.Equal([]database.Limit{deposit, loss, looserDeposit}, limits)
+
+	// A new change discards the pending one
+	tighterDeposit := database.Limit{Kind: database.DepositLimit, Amount: 8_000, EffectiveAt: now + 100}
+	err = l.db.Set(limitsPublicKey, tighterDeposit, now+100)
+	l.NoError(err)
+
+	limits, err = l.db.List(limitsPublicKey, now+600)
+	l.NoError(err)
+	l.Equal([]database.Limit{loss, tighterDeposit}, limits)
+}
@@ -8,12 +8,44 @@ import (
 	"github.com/pkg/errors"
 )
 
+// ErrLotteryNotFound is returned when there is no lottery at the height specified.
+var ErrLotteryNotFound = errors.New("lottery not found")
+
+// Commitment is the hash of the server seed published before a lottery starts, the seed is
+// combined with the block hash to draw the winners.
+type Commitment struct {
+	Commitment string `json:"commitment"`
+	// Seed is only revealed once the lottery is drawn
+	Seed string `json:"seed,omitempty"`
+	// BlockHash is the hash of the block that drew the lottery
+	BlockHash string `json:"block_hash,omitempty"`
+	// Collision is the policy the lottery was drawn with when several prizes land on the same
+	// public key, empty means they were stacked
+	Collision string `json:"collision,omitempty"`
+	Height    uint32 `json:"height"`
+}
+
+// Tiers is the number of prize tiers a lottery pool was drawn with when they adapt to the unique
+// participants of the pool.
+type Tiers struct {
+	Pool         string `json:"pool"`
+	Participants uint64 `json:"participants"`
+	Tiers        uint32 `json:"tiers"`
+}
+
 // LotteriesStore contains the methods used to store and retrieve lotteries from the database.
 type LotteriesStore interface {
-	AddHeight(height uint32) error
+	AddHeight(height uint32, seed, commitment string) error
 	DeleteHeight(height uint32) error
+	GetCommitment(height uint32) (Commitment, error)
 	GetNextHeight() (uint32, error)
+	ListDrawn(minHeight, maxHeight uint32, limit uint64) ([]Commitment, error)
 	ListHeights(offset, limit uint64, reverse bool) ([]uint32, error)
+	ListTiers(height uint32) ([]Tiers, error)
+	ListUndrawn(minHeight uint32) ([]uint32, error)
+	IsDrawn(height uint32) (bool, error)
+	SetDraw(height uint32, blockHash, collision string) error
+	SetTiers(height uint32, tiers []Tiers) error
 }
 
 type lotteries struct {
@@ -29,15 +61,15 @@ func newLotteriesStore(db *sql.DB, logger *logger.Logger) LotteriesStore {
 	}
 }
 
-func (l *lotteries) AddHeight(height uint32) error {
-	query := "INSERT OR IGNORE INTO lotteries (height) VALUES (?)"
+func (l *lotteries) AddHeight(height uint32, seed, commitment string) error {
+	query := "INSERT OR IGNORE INTO lotteries (height, seed, commitment) VALUES (?, ?, ?)"
 	stmt, err := l.db.Prepare(query)
 	if err != nil {
 		return errors.Wrap(err, "preparing statement")
 	}
 	defer stmt.Close()
 
-	if _, err := stmt.Exec(height); err != nil {
+	if _, err := stmt.Exec(height, seed, commitment); err != nil {
 		return errors.Wrap(err, "adding height")
 	}
 
@@ -59,6 +91,26 @@ func (l *lotteries) DeleteHeight(height uint32) error {
 	return nil
 }
 
+func (l *lotteries) GetCommitment(height uint32) (Commitment, error) {
+	query := "SELECT commitment, seed, block_hash, collision FROM lotteries WHERE height=?"
+	stmt, err := l.db.Prepare(query)
+	if err != nil {
+		return Commitment{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	commitment := Commitment{Height: height}
+	if err := stmt.QueryRow(height).Scan(&commitment.Commitment, &commitment.Seed,
+		&commitment.BlockHash, &commitment.Collision); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Commitment{}, ErrLotteryNotFound
+		}
+		return Commitment{}, errors.Wrap(err, "getting commitment")
+	}
+
+	return commitment, nil
+}
+
 func (l *lotteries) GetNextHeight() (uint32, error) {
 	tx, err := l.db.Begin()
 	if err != nil {
@@ -74,6 +126,43 @@ func (l *lotteries) GetNextHeight() (uint32, error) {
 	return height, nil
 }
 
+// ListDrawn returns the commitments of the lotteries drawn between the heights specified, both
+// included, in ascending order. Lotteries without bets are never drawn.
+func (l *lotteries) ListDrawn(minHeight, maxHeight uint32, limit uint64) ([]Commitment, error) {
+	// Cap limit to avoid creating a slice with too big capacity
+	if limit == 0 || limit > 500 {
+		limit = 500
+	}
+
+	query := `SELECT height, commitment, seed, block_hash, collision FROM lotteries
+	WHERE height BETWEEN ? AND ? AND block_hash!='' ORDER BY height LIMIT ?`
+	stmt, err := l.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(minHeight, maxHeight, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing drawn lotteries")
+	}
+	defer rows.Close()
+
+	var commitments []Commitment
+	for rows.Next() {
+		var commitment Commitment
+		err := rows.Scan(&commitment.Height, &commitment.Commitment, &commitment.Seed,
+			&commitment.BlockHash, &commitment.Collision)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		commitments = append(commitments, commitment)
+	}
+
+	return commitments, rows.Err()
+}
+
 func (l *lotteries) ListHeights(offset, limit uint64, reverse bool) ([]uint32, error) {
 	// Cap limit to avoid creating a slice with too big capacity
 	if limit > 500 {
@@ -108,6 +197,127 @@ func (l *lotteries) ListHeights(offset, limit uint64, reverse bool) ([]uint32, e
 	return heights, nil
 }
 
+// ListUndrawn returns the heights of the lotteries from minHeight on that weren't drawn yet, in
+// ascending order. Lotteries without bets never store the block that drew them, so heights that
+// were already mined may be listed.
+func (l *lotteries) ListUndrawn(minHeight uint32) ([]uint32, error) {
+	query := "SELECT height FROM lotteries WHERE height >= ? AND block_hash='' ORDER BY height"
+	stmt, err := l.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(minHeight)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing undrawn heights")
+	}
+	defer rows.Close()
+
+	var heights []uint32
+	for rows.Next() {
+		var height uint32
+		if err := rows.Scan(&height); err != nil {
+			return nil, err
+		}
+
+		heights = append(heights, height)
+	}
+
+	return heights, rows.Err()
+}
+
+// drawnQuery returns whether the results of a lottery were stored. Lotteries with winners are
+// considered drawn, even if they were drawn before the lock was recorded.
+const drawnQuery = `SELECT draw_locked OR EXISTS (SELECT 1 FROM winners WHERE lottery_height=height)
+FROM lotteries WHERE height=?`
+
+// IsDrawn returns whether the results of the lottery at the height specified were stored, so a
+// target height delivered twice by the block feed is drawn only once. Unknown lotteries are not
+// drawn.
+func (l *lotteries) IsDrawn(height uint32) (bool, error) {
+	var drawn bool
+	if err := l.db.QueryRow(drawnQuery, height).Scan(&drawn); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "checking draw")
+	}
+
+	return drawn, nil
+}
+
+// SetDraw stores the hash of the block that drew the lottery at the height specified and the
+// collision policy used.
+func (l *lotteries) SetDraw(height uint32, blockHash, collision string) error {
+	query := "UPDATE lotteries SET block_hash=?, collision=? WHERE height=?"
+	stmt, err := l.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(blockHash, collision, height); err != nil {
+		return errors.Wrap(err, "setting draw")
+	}
+
+	return nil
+}
+
+// SetTiers stores the participants and the prize tiers each pool of the lottery was drawn with.
+func (l *lotteries) SetTiers(height uint32, tiers []Tiers) error {
+	if len(tiers) == 0 {
+		return nil
+	}
+
+	query := "INSERT OR REPLACE INTO lottery_tiers (lottery_height, pool, participants, tiers) VALUES "
+	query += BulkInsertValues(len(tiers), 4)
+	stmt, err := l.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	args := make([]any, 0, len(tiers)*4)
+	for _, t := range tiers {
+		args = append(args, height, t.Pool, t.Participants, t.Tiers)
+	}
+
+	if _, err := stmt.Exec(args...); err != nil {
+		return errors.Wrap(err, "storing tiers")
+	}
+
+	return nil
+}
+
+// ListTiers returns the prize tiers the pools of the lottery were drawn with, sorted by pool. It's
+// empty if the tiers didn't adapt to the participants.
+func (l *lotteries) ListTiers(height uint32) ([]Tiers, error) {
+	query := "SELECT pool, participants, tiers FROM lottery_tiers WHERE lottery_height=? ORDER BY pool"
+	stmt, err := l.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(height)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing tiers")
+	}
+	defer rows.Close()
+
+	var tiers []Tiers
+	for rows.Next() {
+		var t Tiers
+		if err := rows.Scan(&t.Pool, &t.Participants, &t.Tiers); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+		tiers = append(tiers, t)
+	}
+
+	return tiers, rows.Err()
+}
+
 func getNextHeight(tx *sql.Tx) (uint32, error) {
 	query := "SELECT COALESCE(MAX(height), 0) FROM lotteries"
 	stmt, err := tx.Prepare(query)
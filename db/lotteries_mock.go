@@ -13,8 +13,8 @@ func NewLotteriesStoreMock() *LotteriesStoreMock {
 }
 
 // AddHeight mock.
-func (l *LotteriesStoreMock) AddHeight(height uint32) error {
-	args := l.Called(height)
+func (l *LotteriesStoreMock) AddHeight(height uint32, seed, commitment string) error {
+	args := l.Called(height, seed, commitment)
 	return args.Error(0)
 }
 
@@ -24,6 +24,12 @@ func (l *LotteriesStoreMock) DeleteHeight(height uint32) error {
 	return args.Error(0)
 }
 
+// GetCommitment mock.
+func (l *LotteriesStoreMock) GetCommitment(height uint32) (Commitment, error) {
+	args := l.Called(height)
+	return args.Get(0).(Commitment), args.Error(1)
+}
+
 // GetNextHeight mock.
 func (l *LotteriesStoreMock) GetNextHeight() (uint32, error) {
 	args := l.Called()
@@ -35,3 +41,49 @@ func (l *LotteriesStoreMock) ListHeights(offset, limit uint64, reverse bool) ([]
 	args := l.Called(offset, limit, reverse)
 	return args.Get(0).([]uint32), args.Error(1)
 }
+
+// IsDrawn mock.
+func (l *LotteriesStoreMock) IsDrawn(height uint32) (bool, error) {
+	args := l.Called(height)
+	return args.Bool(0), args.Error(1)
+}
+
+// ListDrawn mock.
+func (l *LotteriesStoreMock) ListDrawn(minHeight, maxHeight uint32, limit uint64) ([]Commitment, error) {
+	args := l.Called(minHeight, maxHeight, limit)
+	var r0 []Commitment
+	v0 := args.Get(0)
+	if v0 != nil {
+		r0 = v0.([]Commitment)
+	}
+	return r0, args.Error(1)
+}
+
+// ListTiers mock.
+func (l *LotteriesStoreMock) ListTiers(height uint32) ([]Tiers, error) {
+	args := l.Called(height)
+	var r0 []Tiers
+	v0 := args.Get(0)
+	if v0 != nil {
+		r0 = v0.([]Tiers)
+	}
+	return r0, args.Error(1)
+}
+
+// ListUndrawn mock.
+func (l *LotteriesStoreMock) ListUndrawn(minHeight uint32) ([]uint32, error) {
+	args := l.Called(minHeight)
+	return args.Get(0).([]uint32), args.Error(1)
+}
+
+// SetDraw mock.
+func (l *LotteriesStoreMock) SetDraw(height uint32, blockHash, collision string) error {
+	args := l.Called(height, blockHash, collision)
+	return args.Error(0)
+}
+
+// SetTiers mock.
+func (l *LotteriesStoreMock) SetTiers(height uint32, tiers []Tiers) error {
+	args := l.Called(height, tiers)
+	return args.Error(0)
+}
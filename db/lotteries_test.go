@@ -37,7 +37,7 @@ func (l *LotteriesSuite) SetupTest() {
 
 func (l *LotteriesSuite) TestAddHeight() {
 	thirdHeight := secondHeight + 144
-	err := l.db.AddHeight(thirdHeight)
+	err := l.db.AddHeight(thirdHeight, "seed", "commitment")
 	l.NoError(err)
 
 	heights, err := l.db.ListHeights(0, 0, false)
@@ -49,6 +49,57 @@ func (l *LotteriesSuite) TestAddHeight() {
 	l.Equal(thirdHeight, heights[2])
 }
 
+func (l *LotteriesSuite) TestGetCommitment() {
+	thirdHeight := secondHeight + 144
+	err := l.db.AddHeight(thirdHeight, "seed", "commitment")
+	l.NoError(err)
+
+	commitment, err := l.db.GetCommitment(thirdHeight)
+	l.NoError(err)
+	l.Equal(database.Commitment{Height: thirdHeight, Commitment: "commitment", Seed: "seed"}, commitment)
+
+	// Lotteries started before the commitments were introduced
+	commitment, err = l.db.GetCommitment(firstHeight)
+	l.NoError(err)
+	l.Equal(database.Commitment{Height: firstHeight}, commitment)
+
+	_, err = l.db.GetCommitment(thirdHeight + 144)
+	l.ErrorIs(err, database.ErrLotteryNotFound)
+}
+
+func (l *LotteriesSuite) TestIsDrawn() {
+	drawn, err := l.db.IsDrawn(secondHeight)
+	l.NoError(err)
+	l.False(drawn)
+
+	// Unknown lottery
+	drawn, err = l.db.IsDrawn(secondHeight + 144)
+	l.NoError(err)
+	l.False(drawn)
+}
+
+func (l *LotteriesSuite) TestIsDrawnStored() {
+	db := setupDB(l.T(), func(db *sql.DB) {
+		query := `INSERT INTO lotteries (height) VALUES (?);
+		INSERT INTO winners (public_key, prize, ticket, lottery_height) VALUES ('a', 10, 1, ?)`
+		_, err := db.Exec(query, firstHeight, firstHeight)
+		l.NoError(err)
+
+		query = "INSERT INTO lotteries (height, draw_locked) VALUES (?, 1)"
+		_, err = db.Exec(query, secondHeight)
+		l.NoError(err)
+	})
+
+	// Lotteries drawn before the locks were recorded
+	drawn, err := db.Lotteries.IsDrawn(firstHeight)
+	l.NoError(err)
+	l.True(drawn)
+
+	drawn, err = db.Lotteries.IsDrawn(secondHeight)
+	l.NoError(err)
+	l.True(drawn)
+}
+
 func (l *LotteriesSuite) TestGetNextHeight() {
 	nextHeight, err := l.db.GetNextHeight()
 	l.NoError(err)
@@ -114,3 +165,78 @@ func (l *LotteriesSuite) TestListHeights() {
 		})
 	}
 }
+
+func (l *LotteriesSuite) TestListUndrawn() {
+	thirdHeight := secondHeight + 144
+	err := l.db.AddHeight(thirdHeight, "seed", "commitment")
+	l.NoError(err)
+
+	err = l.db.SetDraw(secondHeight, "hash", "")
+	l.NoError(err)
+
+	heights, err := l.db.ListUndrawn(secondHeight)
+	l.NoError(err)
+	l.Equal([]uint32{thirdHeight}, heights)
+
+	heights, err = l.db.ListUndrawn(thirdHeight + 1)
+	l.NoError(err)
+	l.Empty(heights)
+}
+
+func (l *LotteriesSuite) TestListDrawn() {
+	thirdHeight := secondHeight + 144
+	l.NoError(l.db.AddHeight(thirdHeight, "seed", "commitment"))
+	l.NoError(l.db.SetDraw(firstHeight, "first", ""))
+	l.NoError(l.db.SetDraw(thirdHeight, "third", "reroll"))
+
+	commitments, err := l.db.ListDrawn(firstHeight, thirdHeight, 0)
+	l.NoError(err)
+	l.Len(commitments, 2)
+	l.Equal(firstHeight, commitments[0].Height)
+	expected := database.Commitment{
+		Height:     thirdHeight,
+		Commitment: "commitment",
+		Seed:       "seed",
+		BlockHash:  "third",
+		Collision:  "reroll",
+	}
+	l.Equal(expected, commitments[1])
+
+	commitments, err = l.db.ListDrawn(firstHeight+1, thirdHeight-1, 0)
+	l.NoError(err)
+	l.Empty(commitments)
+
+	commitments, err = l.db.ListDrawn(firstHeight, thirdHeight, 1)
+	l.NoError(err)
+	l.Len(commitments, 1)
+}
+
+func (l *LotteriesSuite) TestSetDraw() {
+	blockHash := "000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6"
+	err := l.db.SetDraw(secondHeight, blockHash, "reroll")
+	l.NoError(err)
+
+	commitment, err := l.db.GetCommitment(secondHeight)
+	l.NoError(err)
+	expected := database.Commitment{Height: secondHeight, BlockHash: blockHash, Collision: "reroll"}
+	l.Equal(expected, commitment)
+}
+
+func (l *LotteriesSuite) TestTiers() {
+	tiers := []database.Tiers{
+		{Pool: "whale", Participants: 150, Tiers: 8},
+		{Pool: "", Participants: 4, Tiers: 3},
+	}
+	err := l.db.SetTiers(secondHeight, tiers)
+	l.NoError(err)
+
+	got, err := l.db.ListTiers(secondHeight)
+	l.NoError(err)
+	l.Equal([]database.Tiers{tiers[1], tiers[0]}, got)
+
+	got, err = l.db.ListTiers(firstHeight)
+	l.NoError(err)
+	l.Empty(got)
+
+	l.NoError(l.db.SetTiers(firstHeight, nil))
+}
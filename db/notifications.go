@@ -2,30 +2,80 @@ package db
 
 import (
 	"database/sql"
+	"strconv"
 
+	"github.com/aftermath2/BTRY/crypto"
 	"github.com/aftermath2/BTRY/logger"
 
 	"github.com/pkg/errors"
 )
 
-var ErrNoChatID = errors.New("no chat ID linked to this public key")
+var (
+	ErrNoChatID = errors.New("no chat ID linked to this public key")
+	// ErrNoNostrKey is returned when the public key has no nostr key linked.
+	ErrNoNostrKey = errors.New("no nostr key linked to this public key")
+)
+
+// Notification delivery statuses.
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+)
+
+// Notification channels.
+const (
+	ChannelTelegram = "telegram"
+	ChannelNostr    = "nostr"
+)
 
 // NotificationsStore contains the methods used to store and retrieve notifications from the database.
 type NotificationsStore interface {
 	Add(publicKey string, chatID int64) error
+	AddDelivery(delivery NotificationDelivery) (uint64, error)
+	DeleteNostrKey(publicKey string) error
 	GetChatID(publicKey string) (int64, error)
+	GetDigest(publicKey string) (bool, error)
+	GetNostrKey(publicKey string) (string, error)
+	ListDeliveries(status string, offset, limit uint64) ([]NotificationDelivery, error)
+	ListDigests() ([]string, error)
+	ListPlayerDeliveries(publicKey string, offset, limit uint64) ([]NotificationDelivery, error)
+	ListRetries(now int64) ([]NotificationDelivery, error)
+	SetDigest(publicKey string, enabled bool) error
+	SetNostrKey(publicKey, nostrPublicKey string) error
+	UpdateDelivery(delivery NotificationDelivery) error
 }
 
+// NotificationDelivery is the delivery status of a message through a channel. The public key is
+// empty for the messages sent to the operators.
+type NotificationDelivery struct {
+	PublicKey string `json:"public_key,omitempty"`
+	Channel   string `json:"channel"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Message   string `json:"message,omitempty"`
+	ID        uint64 `json:"id"`
+	Attempts  uint32 `json:"attempts"`
+	// RetryUntil is the time until which the delivery is retried if it fails, zero if it's not
+	RetryUntil int64 `json:"retry_until,omitempty"`
+	CreatedAt  int64 `json:"created_at"`
+	UpdatedAt  int64 `json:"updated_at"`
+}
+
+// notifications stores the chat IDs and nostr keys encrypted with the cipher, if any. Only the
+// values are encrypted, the public keys are needed to look them up.
 type notifications struct {
 	db     *sql.DB
 	logger *logger.Logger
+	cipher crypto.Cipher
 }
 
 // newNotificationsStore returns a new notifications storage service.
-func newNotificationsStore(db *sql.DB, logger *logger.Logger) NotificationsStore {
+func newNotificationsStore(db *sql.DB, logger *logger.Logger, cipher crypto.Cipher) *notifications {
 	return &notifications{
 		db:     db,
 		logger: logger,
+		cipher: cipher,
 	}
 }
 
@@ -38,13 +88,42 @@ func (n *notifications) Add(publicKey string, chatID int64) error {
 	}
 	defer stmt.Close()
 
-	if _, err := stmt.Exec(publicKey, chatID, "telegram"); err != nil {
+	value, err := n.encrypt(strconv.FormatInt(chatID, 10))
+	if err != nil {
+		return err
+	}
+
+	if _, err := stmt.Exec(publicKey, value, "telegram"); err != nil {
 		return errors.Wrap(err, "adding notification")
 	}
 
 	return nil
 }
 
+// AddDelivery stores a pending delivery of a message and returns its ID.
+func (n *notifications) AddDelivery(delivery NotificationDelivery) (uint64, error) {
+	query := `INSERT INTO notification_deliveries
+	(public_key, channel, status, message, retry_until, created_at, updated_at) VALUES (?,?,?,?,?,?,?)`
+	stmt, err := n.db.Prepare(query)
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(delivery.PublicKey, delivery.Channel, DeliveryPending, delivery.Message,
+		delivery.RetryUntil, delivery.CreatedAt, delivery.CreatedAt)
+	if err != nil {
+		return 0, errors.Wrap(err, "adding notification delivery")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, errors.Wrap(err, "getting notification delivery ID")
+	}
+
+	return uint64(id), nil
+}
+
 // GetChatID looks for the chat ID corresponding to the public key.
 func (n *notifications) GetChatID(publicKey string) (int64, error) {
 	stmt, err := n.db.Prepare("SELECT chat_id FROM notifications WHERE public_key=?")
@@ -53,13 +132,333 @@ func (n *notifications) GetChatID(publicKey string) (int64, error) {
 	}
 	defer stmt.Close()
 
-	var chatID int64
-	if err := stmt.QueryRow(publicKey).Scan(&chatID); err != nil {
+	var value string
+	if err := stmt.QueryRow(publicKey).Scan(&value); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrNoChatID
 		}
 		return 0, errors.Wrap(err, "scanning notification chat ID")
 	}
 
+	value, err = n.decrypt(value)
+	if err != nil {
+		return 0, err
+	}
+
+	chatID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "parsing notification chat ID")
+	}
+
 	return chatID, nil
 }
+
+// GetDigest returns whether the public key opted in to the draw digests.
+func (n *notifications) GetDigest(publicKey string) (bool, error) {
+	stmt, err := n.db.Prepare("SELECT EXISTS (SELECT 1 FROM digest_notifications WHERE public_key=?)")
+	if err != nil {
+		return false, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var enabled bool
+	if err := stmt.QueryRow(publicKey).Scan(&enabled); err != nil {
+		return false, errors.Wrap(err, "scanning digest")
+	}
+
+	return enabled, nil
+}
+
+// ListDeliveries returns the messages deliveries, the newest first. An empty status lists all of
+// them.
+func (n *notifications) ListDeliveries(status string, offset, limit uint64) ([]NotificationDelivery, error) {
+	query := `SELECT id, public_key, channel, status, message, attempts, error, retry_until,
+	created_at, updated_at FROM notification_deliveries`
+	args := []any{}
+	if status != "" {
+		query += " WHERE status=?"
+		args = append(args, status)
+	}
+	query = AddPagination(query, offset, limit, "id", true)
+
+	return n.listDeliveries(query, args...)
+}
+
+// ListPlayerDeliveries returns the deliveries of the messages sent to the public key through every
+// channel, the newest first.
+func (n *notifications) ListPlayerDeliveries(publicKey string, offset, limit uint64) ([]NotificationDelivery, error) {
+	query := `SELECT id, public_key, channel, status, message, attempts, error, retry_until,
+	created_at, updated_at FROM notification_deliveries WHERE public_key=?`
+	query = AddPagination(query, offset, limit, "id", true)
+
+	return n.listDeliveries(query, publicKey)
+}
+
+// ListRetries returns the deliveries that failed and can still be retried at the time specified,
+// the oldest first.
+func (n *notifications) ListRetries(now int64) ([]NotificationDelivery, error) {
+	query := `SELECT id, public_key, channel, status, message, attempts, error, retry_until,
+	created_at, updated_at FROM notification_deliveries WHERE status=? AND retry_until > ? ORDER BY id`
+
+	return n.listDeliveries(query, DeliveryFailed, now)
+}
+
+// listDeliveries returns the deliveries selected by the query.
+func (n *notifications) listDeliveries(query string, args ...any) ([]NotificationDelivery, error) {
+	stmt, err := n.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing notification deliveries")
+	}
+	defer rows.Close()
+
+	var deliveries []NotificationDelivery
+	// Reuse object
+	var delivery NotificationDelivery
+	for rows.Next() {
+		err := rows.Scan(&delivery.ID, &delivery.PublicKey, &delivery.Channel, &delivery.Status,
+			&delivery.Message, &delivery.Attempts, &delivery.Error, &delivery.RetryUntil,
+			&delivery.CreatedAt, &delivery.UpdatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ListDigests returns the public keys that opted in to the draw digests.
+func (n *notifications) ListDigests() ([]string, error) {
+	stmt, err := n.db.Prepare("SELECT public_key FROM digest_notifications")
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing digests")
+	}
+	defer rows.Close()
+
+	var publicKeys []string
+	for rows.Next() {
+		var publicKey string
+		if err := rows.Scan(&publicKey); err != nil {
+			return nil, errors.Wrap(err, "scanning public key")
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating digests")
+	}
+
+	return publicKeys, nil
+}
+
+// SetDigest opts the public key in or out of the draw digests.
+func (n *notifications) SetDigest(publicKey string, enabled bool) error {
+	query := "DELETE FROM digest_notifications WHERE public_key=?"
+	if enabled {
+		query = "INSERT OR IGNORE INTO digest_notifications (public_key) VALUES (?)"
+	}
+	stmt, err := n.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(publicKey); err != nil {
+		return errors.Wrap(err, "setting digest")
+	}
+
+	return nil
+}
+
+// DeleteNostrKey unlinks the nostr key from the public key.
+func (n *notifications) DeleteNostrKey(publicKey string) error {
+	stmt, err := n.db.Prepare("DELETE FROM nostr_notifications WHERE public_key=?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(publicKey); err != nil {
+		return errors.Wrap(err, "deleting nostr key")
+	}
+
+	return nil
+}
+
+// GetNostrKey looks for the nostr public key (hex encoded) the notifications of the public key
+// are sent to.
+func (n *notifications) GetNostrKey(publicKey string) (string, error) {
+	stmt, err := n.db.Prepare("SELECT nostr_public_key FROM nostr_notifications WHERE public_key=?")
+	if err != nil {
+		return "", errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var nostrPublicKey string
+	if err := stmt.QueryRow(publicKey).Scan(&nostrPublicKey); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoNostrKey
+		}
+		return "", errors.Wrap(err, "scanning nostr key")
+	}
+
+	return n.decrypt(nostrPublicKey)
+}
+
+// SetNostrKey links a nostr public key (hex encoded) to the public key, replacing the previous
+// one if any.
+func (n *notifications) SetNostrKey(publicKey, nostrPublicKey string) error {
+	query := `INSERT INTO nostr_notifications (public_key, nostr_public_key) VALUES (?,?)
+	ON CONFLICT (public_key) DO UPDATE SET nostr_public_key = excluded.nostr_public_key`
+	stmt, err := n.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	value, err := n.encrypt(nostrPublicKey)
+	if err != nil {
+		return err
+	}
+
+	if _, err := stmt.Exec(publicKey, value); err != nil {
+		return errors.Wrap(err, "setting nostr key")
+	}
+
+	return nil
+}
+
+// UpdateDelivery sets the status, attempts and error of a delivery.
+func (n *notifications) UpdateDelivery(delivery NotificationDelivery) error {
+	query := "UPDATE notification_deliveries SET status=?, attempts=?, error=?, updated_at=? WHERE id=?"
+	stmt, err := n.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(delivery.Status, delivery.Attempts, delivery.Error, delivery.UpdatedAt, delivery.ID)
+	if err != nil {
+		return errors.Wrap(err, "updating notification delivery")
+	}
+
+	return nil
+}
+
+// reencrypt encrypts the values stored in plaintext or with a previous key with the current one,
+// so keys can be rotated. It returns the number of values updated.
+func (n *notifications) reencrypt() (int, error) {
+	if n.cipher == nil {
+		return 0, nil
+	}
+
+	var updated int
+	for _, column := range []struct{ table, name string }{
+		{table: "notifications", name: "chat_id"},
+		{table: "nostr_notifications", name: "nostr_public_key"},
+	} {
+		values, err := n.staleValues(column.table, column.name)
+		if err != nil {
+			return updated, err
+		}
+
+		query := "UPDATE " + column.table + " SET " + column.name + "=? WHERE public_key=?"
+		stmt, err := n.db.Prepare(query)
+		if err != nil {
+			return updated, errors.Wrap(err, "preparing statement")
+		}
+
+		for publicKey, value := range values {
+			plaintext, err := n.decrypt(value)
+			if err != nil {
+				stmt.Close()
+				return updated, err
+			}
+
+			value, err := n.encrypt(plaintext)
+			if err != nil {
+				stmt.Close()
+				return updated, err
+			}
+
+			if _, err := stmt.Exec(value, publicKey); err != nil {
+				stmt.Close()
+				return updated, errors.Wrapf(err, "updating %s", column.table)
+			}
+			updated++
+		}
+		stmt.Close()
+	}
+
+	return updated, nil
+}
+
+// staleValues returns the values of the column that aren't encrypted with the current key, by
+// public key.
+func (n *notifications) staleValues(table, column string) (map[string]string, error) {
+	rows, err := n.db.Query("SELECT public_key, " + column + " FROM " + table)
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing %s", table)
+	}
+	defer rows.Close()
+
+	values := make(map[string]string)
+	for rows.Next() {
+		var publicKey, value string
+		if err := rows.Scan(&publicKey, &value); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		if !n.cipher.Current(value) {
+			values[publicKey] = value
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, "iterating %s", table)
+	}
+
+	return values, nil
+}
+
+func (n *notifications) encrypt(value string) (string, error) {
+	if n.cipher == nil {
+		return value, nil
+	}
+
+	ciphertext, err := n.cipher.Encrypt([]byte(value))
+	if err != nil {
+		return "", errors.Wrap(err, "encrypting notification")
+	}
+	return ciphertext, nil
+}
+
+// decrypt returns the plaintext of the value. Values stored before enabling the encryption are
+// returned as they are until they are encrypted.
+func (n *notifications) decrypt(value string) (string, error) {
+	if n.cipher == nil {
+		return value, nil
+	}
+
+	plaintext, err := n.cipher.Decrypt(value)
+	if err != nil {
+		if errors.Is(err, crypto.ErrNotEncrypted) {
+			return value, nil
+		}
+		return "", errors.Wrap(err, "decrypting notification")
+	}
+	return string(plaintext), nil
+}
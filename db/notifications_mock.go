@@ -18,8 +18,90 @@ func (n *NotificationsStoreMock) Add(publicKey string, chatID int64) error {
 	return args.Error(0)
 }
 
+// AddDelivery mock.
+func (n *NotificationsStoreMock) AddDelivery(delivery NotificationDelivery) (uint64, error) {
+	args := n.Called(delivery)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+// DeleteNostrKey mock.
+func (n *NotificationsStoreMock) DeleteNostrKey(publicKey string) error {
+	args := n.Called(publicKey)
+	return args.Error(0)
+}
+
 // GetChatID mock.
 func (n *NotificationsStoreMock) GetChatID(publicKey string) (int64, error) {
 	args := n.Called(publicKey)
 	return args.Get(0).(int64), args.Error(1)
 }
+
+// GetDigest mock.
+func (n *NotificationsStoreMock) GetDigest(publicKey string) (bool, error) {
+	args := n.Called(publicKey)
+	return args.Bool(0), args.Error(1)
+}
+
+// GetNostrKey mock.
+func (n *NotificationsStoreMock) GetNostrKey(publicKey string) (string, error) {
+	args := n.Called(publicKey)
+	return args.String(0), args.Error(1)
+}
+
+// ListDeliveries mock.
+func (n *NotificationsStoreMock) ListDeliveries(status string, offset, limit uint64) ([]NotificationDelivery, error) {
+	args := n.Called(status, offset, limit)
+	var deliveries []NotificationDelivery
+	if v := args.Get(0); v != nil {
+		deliveries = v.([]NotificationDelivery)
+	}
+	return deliveries, args.Error(1)
+}
+
+// ListDigests mock.
+func (n *NotificationsStoreMock) ListDigests() ([]string, error) {
+	args := n.Called()
+	var publicKeys []string
+	if v := args.Get(0); v != nil {
+		publicKeys = v.([]string)
+	}
+	return publicKeys, args.Error(1)
+}
+
+// SetDigest mock.
+func (n *NotificationsStoreMock) SetDigest(publicKey string, enabled bool) error {
+	args := n.Called(publicKey, enabled)
+	return args.Error(0)
+}
+
+// SetNostrKey mock.
+func (n *NotificationsStoreMock) SetNostrKey(publicKey, nostrPublicKey string) error {
+	args := n.Called(publicKey, nostrPublicKey)
+	return args.Error(0)
+}
+
+// ListPlayerDeliveries mock.
+func (n *NotificationsStoreMock) ListPlayerDeliveries(publicKey string, offset, limit uint64) ([]NotificationDelivery, error) {
+	args := n.Called(publicKey, offset, limit)
+	var deliveries []NotificationDelivery
+	if v := args.Get(0); v != nil {
+		deliveries = v.([]NotificationDelivery)
+	}
+	return deliveries, args.Error(1)
+}
+
+// ListRetries mock.
+func (n *NotificationsStoreMock) ListRetries(now int64) ([]NotificationDelivery, error) {
+	args := n.Called(now)
+	var deliveries []NotificationDelivery
+	if v := args.Get(0); v != nil {
+		deliveries = v.([]NotificationDelivery)
+	}
+	return deliveries, args.Error(1)
+}
+
+// UpdateDelivery mock.
+func (n *NotificationsStoreMock) UpdateDelivery(delivery NotificationDelivery) error {
+	args := n.Called(delivery)
+	return args.Error(0)
+}
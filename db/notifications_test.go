@@ -2,16 +2,22 @@ package db_test
 
 import (
 	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/aftermath2/BTRY/config"
 	database "github.com/aftermath2/BTRY/db"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
 
 const (
 	notificationPublicKey       = "7d959d6d552c7d38b3ecafb72805fa03a6dee6b7f0c5f63f57a371736cb004b1"
 	notificationChatID    int64 = 505
+	notificationNostrKey        = "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
 )
 
 type NotificationsSuite struct {
@@ -30,6 +36,11 @@ func (n *NotificationsSuite) SetupTest() {
 		INSERT INTO notifications (public_key, chat_id, service) VALUES (?, ?, ?);`
 		_, err := db.Exec(query, notificationPublicKey, notificationChatID, "telegram")
 		n.NoError(err)
+
+		query = `DELETE FROM nostr_notifications;
+		INSERT INTO nostr_notifications (public_key, nostr_public_key) VALUES (?, ?);`
+		_, err = db.Exec(query, notificationPublicKey, notificationNostrKey)
+		n.NoError(err)
 	})
 	n.db = db.Notifications
 }
@@ -50,3 +61,333 @@ func (n *NotificationsSuite) TestGetChatID() {
 
 	n.Equal(notificationChatID, gotChatID)
 }
+
+func (n *NotificationsSuite) TestDigests() {
+	enabled, err := n.db.GetDigest(notificationPublicKey)
+	n.NoError(err)
+	n.False(enabled)
+
+	err = n.db.SetDigest(notificationPublicKey, true)
+	n.NoError(err)
+	// Opting in twice is a no-op
+	err = n.db.SetDigest(notificationPublicKey, true)
+	n.NoError(err)
+
+	enabled, err = n.db.GetDigest(notificationPublicKey)
+	n.NoError(err)
+	n.True(enabled)
+
+	publicKeys, err := n.db.ListDigests()
+	n.NoError(err)
+	n.Equal([]string{notificationPublicKey}, publicKeys)
+
+	err = n.db.SetDigest(notificationPublicKey, false)
+	n.NoError(err)
+
+	publicKeys, err = n.db.ListDigests()
+	n.NoError(err)
+	n.Empty(publicKeys)
+}
+
+func (n *NotificationsSuite) TestGetNostrKey() {
+	nostrKey, err := n.db.GetNostrKey(notificationPublicKey)
+	n.NoError(err)
+	n.Equal(notificationNostrKey, nostrKey)
+
+	_, err = n.db.GetNostrKey("876baf90c3d2d26c04ba1d208c29605b2c6fd13fbb3f6b46cf7f10ece3dac69d")
+	n.ErrorIs(err, database.ErrNoNostrKey)
+}
+
+func (n *NotificationsSuite) TestSetNostrKey() {
+	nostrKey := "82341f882b6eabcd2ba7f1ef90aad961cf074af15b9ef44a09f9d2a8fbfbe6a2"
+	err := n.db.SetNostrKey(notificationPublicKey, nostrKey)
+	n.NoError(err)
+
+	gotNostrKey, err := n.db.GetNostrKey(notificationPublicKey)
+	n.NoError(err)
+	n.Equal(nostrKey, gotNostrKey)
+}
+
+func (n *NotificationsSuite) TestDeleteNostrKey() {
+	err := n.db.DeleteNostrKey(notificationPublicKey)
+	n.NoError(err)
+
+	_, err = n.db.GetNostrKey(notificationPublicKey)
+	n.ErrorIs(err, database.ErrNoNostrKey)
+
+	// The telegram chat is kept
+	_, err = n.db.GetChatID(notificationPublicKey)
+	n.NoError(err)
+}
+
+func (n *NotificationsSuite) TestDeliveries() {
+	id, err := n.db.AddDelivery(database.NotificationDelivery{
+		PublicKey: notificationPublicKey,
+		Channel:   database.ChannelTelegram,
+		Message:   "You won",
+		CreatedAt: 1_700_000_000,
+	})
+	n.NoError(err)
+	_, err = n.db.AddDelivery(database.NotificationDelivery{
+		Channel:   database.ChannelTelegram,
+		Message:   "Alert",
+		CreatedAt: 1_700_000_001,
+	})
+	n.NoError(err)
+
+	pending, err := n.db.ListDeliveries(database.DeliveryPending, 0, 0)
+	n.NoError(err)
+	n.Len(pending, 2)
+
+	err = n.db.UpdateDelivery(database.NotificationDelivery{
+		ID:        id,
+		Status:    database.DeliveryFailed,
+		Attempts:  3,
+		Error:     "Too Many Requests: retry after 5",
+		UpdatedAt: 1_700_000_010,
+	})
+	n.NoError(err)
+
+	failed, err := n.db.ListDeliveries(database.DeliveryFailed, 0, 0)
+	n.NoError(err)
+	n.Equal([]database.NotificationDelivery{{
+		ID:        id,
+		PublicKey: notificationPublicKey,
+		Channel:   database.ChannelTelegram,
+		Status:    database.DeliveryFailed,
+		Message:   "You won",
+		Attempts:  3,
+		Error:     "Too Many Requests: retry after 5",
+		CreatedAt: 1_700_000_000,
+		UpdatedAt: 1_700_000_010,
+	}}, failed)
+
+	// Newest first
+	all, err := n.db.ListDeliveries("", 0, 1)
+	n.NoError(err)
+	n.Len(all, 1)
+	n.Equal(database.DeliveryPending, all[0].Status)
+}
+
+func (n *NotificationsSuite) TestPlayerDeliveries() {
+	for _, delivery := range []database.NotificationDelivery{
+		{PublicKey: notificationPublicKey, Channel: database.ChannelTelegram, Message: "You won", CreatedAt: 1},
+		{PublicKey: notificationPublicKey, Channel: database.ChannelNostr, Message: "You won", CreatedAt: 1},
+		{PublicKey: "other", Channel: database.ChannelTelegram, Message: "You won", CreatedAt: 1},
+	} {
+		_, err := n.db.AddDelivery(delivery)
+		n.NoError(err)
+	}
+
+	deliveries, err := n.db.ListPlayerDeliveries(notificationPublicKey, 0, 10)
+	n.NoError(err)
+	n.Len(deliveries, 2)
+	// Newest first
+	n.Equal(database.ChannelNostr, deliveries[0].Channel)
+	n.Equal(database.ChannelTelegram, deliveries[1].Channel)
+	n.Equal("You won", deliveries[0].Message)
+}
+
+func (n *NotificationsSuite) TestListRetries() {
+	now := int64(1_700_000_000)
+	deliveries := []database.NotificationDelivery{
+		// Retried
+		{PublicKey: notificationPublicKey, Channel: database.ChannelNostr, RetryUntil: now + 1},
+		// Claim window closed
+		{PublicKey: notificationPublicKey, Channel: database.ChannelNostr, RetryUntil: now},
+		// Not retried
+		{PublicKey: notificationPublicKey, Channel: database.ChannelTelegram},
+		// Sent
+		{PublicKey: notificationPublicKey, Channel: database.ChannelTelegram, RetryUntil: now + 1},
+	}
+	ids := make([]uint64, 0, len(deliveries))
+	for i, delivery := range deliveries {
+		id, err := n.db.AddDelivery(delivery)
+		n.NoError(err)
+		ids = append(ids, id)
+
+		status := database.DeliveryFailed
+		if i == len(deliveries)-1 {
+			status = database.DeliverySent
+		}
+		err = n.db.UpdateDelivery(database.NotificationDelivery{ID: id, Status: status, Attempts: 1})
+		n.NoError(err)
+	}
+
+	retries, err := n.db.ListRetries(now)
+	n.NoError(err)
+	n.Len(retries, 1)
+	n.Equal(ids[0], retries[0].ID)
+	n.Equal(uint32(1), retries[0].Attempts)
+}
+
+func TestNotificationsEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "keys")
+	key := "8ad8c9f4e5c5b0f0e4d5b1c9a3c5f1a4b2e6d7c8f9a0b1c2d3e4f5a6b7c8d9e0"
+	assert.NoError(t, os.WriteFile(keyFile, []byte(key), 0o600))
+
+	dbConfig := config.DB{
+		Path: filepath.Join(dir, "btry.db"),
+		Notifications: config.NotificationsDB{
+			Path:       filepath.Join(dir, "notifications.db"),
+			Encryption: config.Encryption{Provider: "file", KeyFile: keyFile},
+		},
+	}
+	db, err := database.Open(dbConfig)
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.Notifications.Add(notificationPublicKey, notificationChatID))
+	assert.NoError(t, db.Notifications.SetNostrKey(notificationPublicKey, notificationNostrKey))
+	assert.NoError(t, db.Close())
+
+	// The values are stored encrypted and only in the notifications database
+	chatID, nostrKey := readNotification(t, dbConfig.Notifications.Path)
+	assert.NotEqual(t, "505", chatID)
+	assert.NotEqual(t, notificationNostrKey, nostrKey)
+	assert.Zero(t, countNotificationTables(t, dbConfig.Path))
+
+	// Rotate the key, the values are encrypted with the new one on startup
+	key2 := "1f2e3d4c5b6a79880f1e2d3c4b5a69780a1b2c3d4e5f60718293a4b5c6d7e8f9"
+	assert.NoError(t, os.WriteFile(keyFile, []byte(key+"\n"+key2), 0o600))
+
+	db, err = database.Open(dbConfig)
+	assert.NoError(t, err)
+
+	gotChatID, err := db.Notifications.GetChatID(notificationPublicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, notificationChatID, gotChatID)
+	gotNostrKey, err := db.Notifications.GetNostrKey(notificationPublicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, notificationNostrKey, gotNostrKey)
+	assert.NoError(t, db.Close())
+
+	rotatedChatID, _ := readNotification(t, dbConfig.Notifications.Path)
+	assert.NotEqual(t, chatID, rotatedChatID)
+
+	// The previous key is no longer needed
+	assert.NoError(t, os.WriteFile(keyFile, []byte(key2), 0o600))
+	db, err = database.Open(dbConfig)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	gotChatID, err = db.Notifications.GetChatID(notificationPublicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, notificationChatID, gotChatID)
+}
+
+func TestNotificationsEncryptPlaintext(t *testing.T) {
+	dir := t.TempDir()
+	dbConfig := config.DB{Path: filepath.Join(dir, "btry.db")}
+	db, err := database.Open(dbConfig)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Notifications.Add(notificationPublicKey, notificationChatID))
+	assert.NoError(t, db.Close())
+
+	chatID, _ := readNotification(t, dbConfig.Path)
+	assert.Equal(t, "505", chatID)
+
+	keyFile := filepath.Join(dir, "keys")
+	key := "8ad8c9f4e5c5b0f0e4d5b1c9a3c5f1a4b2e6d7c8f9a0b1c2d3e4f5a6b7c8d9e0"
+	assert.NoError(t, os.WriteFile(keyFile, []byte(key), 0o600))
+	dbConfig.Notifications.Encryption = config.Encryption{Provider: "file", KeyFile: keyFile}
+
+	db, err = database.Open(dbConfig)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	chatID, _ = readNotification(t, dbConfig.Path)
+	assert.NotEqual(t, "505", chatID)
+
+	gotChatID, err := db.Notifications.GetChatID(notificationPublicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, notificationChatID, gotChatID)
+}
+
+func TestNotificationsMove(t *testing.T) {
+	dir := t.TempDir()
+	dbConfig := config.DB{Path: filepath.Join(dir, "btry.db")}
+	db, err := database.Open(dbConfig)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Notifications.Add(notificationPublicKey, notificationChatID))
+	assert.NoError(t, db.Notifications.SetNostrKey(notificationPublicKey, notificationNostrKey))
+	assert.NoError(t, db.Close())
+
+	dbConfig.Notifications.Path = filepath.Join(dir, "notifications.db")
+	db, err = database.Open(dbConfig)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	chatID, nostrKey := readNotification(t, dbConfig.Notifications.Path)
+	assert.Equal(t, "505", chatID)
+	assert.Equal(t, notificationNostrKey, nostrKey)
+
+	gotChatID, err := db.Notifications.GetChatID(notificationPublicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, notificationChatID, gotChatID)
+	assert.Zero(t, countNotificationTables(t, dbConfig.Path))
+}
+
+func TestNotificationsUpgrade(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "btry.db")
+	sqlDB, err := sql.Open("sqlite", path)
+	assert.NoError(t, err)
+	// Deliveries table created before they were tracked by channel
+	_, err = sqlDB.Exec(`CREATE TABLE notification_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		public_key VARCHAR(64) NOT NULL DEFAULT '',
+		status TEXT NOT NULL CHECK (status IN ('pending', 'sent', 'failed')),
+		attempts INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL
+	);
+	INSERT INTO notification_deliveries (public_key, status, created_at, updated_at)
+	VALUES ('a', 'sent', 1, 1);`)
+	assert.NoError(t, err)
+	assert.NoError(t, sqlDB.Close())
+
+	db, err := database.Open(config.DB{Path: path})
+	assert.NoError(t, err)
+	defer db.Close()
+
+	deliveries, err := db.Notifications.ListPlayerDeliveries("a", 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, database.ChannelTelegram, deliveries[0].Channel)
+}
+
+// countNotificationTables returns the number of notification tables in the database.
+func countNotificationTables(t *testing.T, path string) int {
+	t.Helper()
+
+	sqlDB, err := sql.Open("sqlite", path)
+	assert.NoError(t, err)
+	defer sqlDB.Close()
+
+	var tables int
+	err = sqlDB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name LIKE '%notifications'").Scan(&tables)
+	assert.NoError(t, err)
+	return tables
+}
+
+// readNotification returns the values stored for the notification public key, bypassing the store.
+func readNotification(t *testing.T, path string) (string, string) {
+	t.Helper()
+
+	sqlDB, err := sql.Open("sqlite", path)
+	assert.NoError(t, err)
+	defer sqlDB.Close()
+
+	var chatID, nostrKey string
+	err = sqlDB.QueryRow("SELECT chat_id FROM notifications WHERE public_key=?", notificationPublicKey).
+		Scan(&chatID)
+	assert.NoError(t, err)
+	err = sqlDB.QueryRow("SELECT nostr_public_key FROM nostr_notifications WHERE public_key=?",
+		notificationPublicKey).Scan(&nostrKey)
+	if !errors.Is(err, sql.ErrNoRows) {
+		assert.NoError(t, err)
+	}
+
+	return chatID, nostrKey
+}
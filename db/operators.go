@@ -0,0 +1,314 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// Operators errors
+var (
+	ErrNoCredential   = errors.New("credential not found")
+	ErrNoOperator     = errors.New("operator not found")
+	ErrInvalidInvite  = errors.New("invalid or expired invite")
+	ErrOperatorsExist = errors.New("an invite is required to register new operators")
+)
+
+// Role determines the administration endpoints an operator has access to. Each role includes the
+// permissions of the lower ones.
+type Role uint8
+
+// Operator roles
+const (
+	RoleReadOnly Role = iota + 1
+	RoleOperator
+	RoleOwner
+)
+
+var roleNames = map[Role]string{
+	RoleReadOnly: "read_only",
+	RoleOperator: "operator",
+	RoleOwner:    "owner",
+}
+
+// ParseRole returns the role with the name specified.
+func ParseRole(name string) (Role, error) {
+	for role, roleName := range roleNames {
+		if roleName == name {
+			return role, nil
+		}
+	}
+	return 0, errors.Errorf("invalid role %q", name)
+}
+
+// String returns the role name.
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (r *Role) UnmarshalText(text []byte) error {
+	role, err := ParseRole(string(text))
+	if err != nil {
+		return err
+	}
+	*r = role
+	return nil
+}
+
+// OperatorsStore contains the methods used to store and retrieve the operators, their WebAuthn
+// credentials and the invites to register new ones.
+type OperatorsStore interface {
+	Add(name string, credential Credential, inviteHash string) (Operator, error)
+	AddInvite(codeHash string, role Role, expiresAt int64) error
+	Count() (uint64, error)
+	Delete(id uint64) error
+	GetCredential(id []byte) (Credential, error)
+	GetInviteRole(codeHash string) (Role, error)
+	List() ([]Operator, error)
+	UpdateSignCount(credentialID []byte, signCount uint32) error
+}
+
+// Operator is a user with access to the administration endpoints.
+type Operator struct {
+	Name      string `json:"name"`
+	ID        uint64 `json:"id"`
+	CreatedAt int64  `json:"created_at"`
+	Role      Role   `json:"role"`
+}
+
+// Credential is an operator passkey.
+type Credential struct {
+	ID         []byte
+	PublicKey  []byte
+	OperatorID uint64
+	SignCount  uint32
+}
+
+type operators struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newOperatorsStore returns a new operators storage service.
+func newOperatorsStore(db *sql.DB, logger *logger.Logger) OperatorsStore {
+	return &operators{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add registers an operator and its credential.
+//
+// If the invite hash is not empty, the invite is consumed and its role assigned to the operator.
+// Otherwise, the operator is registered as the owner only if there are no other operators.
+func (o *operators) Add(name string, credential Credential, inviteHash string) (Operator, error) {
+	tx, err := o.db.Begin()
+	if err != nil {
+		return Operator{}, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	createdAt := time.Now().Unix()
+	role := RoleOwner
+	if inviteHash != "" {
+		query := "DELETE FROM invites WHERE code_hash=? AND expires_at > ? RETURNING role"
+		if err := tx.QueryRow(query, inviteHash, createdAt).Scan(&role); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return Operator{}, ErrInvalidInvite
+			}
+			return Operator{}, errors.Wrap(err, "consuming invite")
+		}
+	} else {
+		var count uint64
+		if err := tx.QueryRow("SELECT COUNT(*) FROM operators").Scan(&count); err != nil {
+			return Operator{}, errors.Wrap(err, "counting operators")
+		}
+		if count > 0 {
+			return Operator{}, ErrOperatorsExist
+		}
+	}
+
+	query := "INSERT INTO operators (name, role, created_at) VALUES (?,?,?) RETURNING id"
+	var id uint64
+	if err := tx.QueryRow(query, name, role, createdAt).Scan(&id); err != nil {
+		return Operator{}, errors.Wrap(err, "adding operator")
+	}
+
+	query = "INSERT INTO credentials (id, operator_id, public_key, sign_count) VALUES (?,?,?,?)"
+	_, err = tx.Exec(query, credential.ID, id, credential.PublicKey, credential.SignCount)
+	if err != nil {
+		return Operator{}, errors.Wrap(err, "adding credential")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Operator{}, errors.Wrap(err, "committing transaction")
+	}
+
+	return Operator{
+		ID:        id,
+		Name:      name,
+		Role:      role,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// AddInvite stores an invite to register a new operator with the role specified.
+func (o *operators) AddInvite(codeHash string, role Role, expiresAt int64) error {
+	stmt, err := o.db.Prepare("INSERT INTO invites (code_hash, role, expires_at) VALUES (?,?,?)")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(codeHash, role, expiresAt); err != nil {
+		return errors.Wrap(err, "adding invite")
+	}
+
+	return nil
+}
+
+// Count returns the number of operators registered.
+func (o *operators) Count() (uint64, error) {
+	stmt, err := o.db.Prepare("SELECT COUNT(*) FROM operators")
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var count uint64
+	if err := stmt.QueryRow().Scan(&count); err != nil {
+		return 0, errors.Wrap(err, "counting operators")
+	}
+
+	return count, nil
+}
+
+// Delete removes an operator together with its credentials and sessions.
+func (o *operators) Delete(id uint64) error {
+	tx, err := o.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM sessions WHERE operator_id=?", id); err != nil {
+		return errors.Wrap(err, "deleting sessions")
+	}
+
+	if _, err := tx.Exec("DELETE FROM credentials WHERE operator_id=?", id); err != nil {
+		return errors.Wrap(err, "deleting credentials")
+	}
+
+	result, err := tx.Exec("DELETE FROM operators WHERE id=?", id)
+	if err != nil {
+		return errors.Wrap(err, "deleting operator")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "getting affected rows")
+	}
+	if rows == 0 {
+		return ErrNoOperator
+	}
+
+	return tx.Commit()
+}
+
+// GetCredential returns the credential with the ID specified.
+func (o *operators) GetCredential(id []byte) (Credential, error) {
+	query := "SELECT id, operator_id, public_key, sign_count FROM credentials WHERE id=?"
+	stmt, err := o.db.Prepare(query)
+	if err != nil {
+		return Credential{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var credential Credential
+	err = stmt.QueryRow(id).Scan(
+		&credential.ID,
+		&credential.OperatorID,
+		&credential.PublicKey,
+		&credential.SignCount,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Credential{}, ErrNoCredential
+		}
+		return Credential{}, errors.Wrap(err, "getting credential")
+	}
+
+	return credential, nil
+}
+
+// GetInviteRole returns the role of an invite that hasn't expired yet.
+func (o *operators) GetInviteRole(codeHash string) (Role, error) {
+	stmt, err := o.db.Prepare("SELECT role FROM invites WHERE code_hash=? AND expires_at > ?")
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var role Role
+	if err := stmt.QueryRow(codeHash, time.Now().Unix()).Scan(&role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidInvite
+		}
+		return 0, errors.Wrap(err, "getting invite")
+	}
+
+	return role, nil
+}
+
+// List returns all the operators.
+func (o *operators) List() ([]Operator, error) {
+	stmt, err := o.db.Prepare("SELECT id, name, role, created_at FROM operators ORDER BY id")
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing operators")
+	}
+	defer rows.Close()
+
+	var operators []Operator
+	// Reuse object
+	var operator Operator
+	for rows.Next() {
+		err := rows.Scan(&operator.ID, &operator.Name, &operator.Role, &operator.CreatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		operators = append(operators, operator)
+	}
+
+	return operators, nil
+}
+
+// UpdateSignCount sets the last signature counter reported by the credential authenticator.
+func (o *operators) UpdateSignCount(credentialID []byte, signCount uint32) error {
+	stmt, err := o.db.Prepare("UPDATE credentials SET sign_count=? WHERE id=?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(signCount, credentialID); err != nil {
+		return errors.Wrap(err, "updating signature counter")
+	}
+
+	return nil
+}
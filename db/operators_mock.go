@@ -0,0 +1,61 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// OperatorsStoreMock is a mocked implementation of the operators store.
+type OperatorsStoreMock struct {
+	mock.Mock
+}
+
+// NewOperatorsStoreMock returns a mocked operators store.
+func NewOperatorsStoreMock() *OperatorsStoreMock {
+	return &OperatorsStoreMock{}
+}
+
+// Add mock.
+func (o *OperatorsStoreMock) Add(name string, credential Credential, inviteHash string) (Operator, error) {
+	args := o.Called(name, credential, inviteHash)
+	return args.Get(0).(Operator), args.Error(1)
+}
+
+// AddInvite mock.
+func (o *OperatorsStoreMock) AddInvite(codeHash string, role Role, expiresAt int64) error {
+	args := o.Called(codeHash, role, expiresAt)
+	return args.Error(0)
+}
+
+// Count mock.
+func (o *OperatorsStoreMock) Count() (uint64, error) {
+	args := o.Called()
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+// Delete mock.
+func (o *OperatorsStoreMock) Delete(id uint64) error {
+	args := o.Called(id)
+	return args.Error(0)
+}
+
+// GetCredential mock.
+func (o *OperatorsStoreMock) GetCredential(id []byte) (Credential, error) {
+	args := o.Called(id)
+	return args.Get(0).(Credential), args.Error(1)
+}
+
+// GetInviteRole mock.
+func (o *OperatorsStoreMock) GetInviteRole(codeHash string) (Role, error) {
+	args := o.Called(codeHash)
+	return args.Get(0).(Role), args.Error(1)
+}
+
+// List mock.
+func (o *OperatorsStoreMock) List() ([]Operator, error) {
+	args := o.Called()
+	return args.Get(0).([]Operator), args.Error(1)
+}
+
+// UpdateSignCount mock.
+func (o *OperatorsStoreMock) UpdateSignCount(credentialID []byte, signCount uint32) error {
+	args := o.Called(credentialID, signCount)
+	return args.Error(0)
+}
@@ -0,0 +1,139 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var testCredential = database.Credential{
+	ID:        []byte{1, 2, 3},
+	PublicKey: []byte{4, 5, 6},
+	SignCount: 1,
+}
+
+type OperatorsSuite struct {
+	suite.Suite
+
+	db database.OperatorsStore
+}
+
+func TestOperatorsSuite(t *testing.T) {
+	suite.Run(t, &OperatorsSuite{})
+}
+
+func (o *OperatorsSuite) SetupTest() {
+	db := setupDB(o.T(), func(db *sql.DB) {})
+	o.db = db.Operators
+}
+
+func (o *OperatorsSuite) TestAdd() {
+	owner, err := o.db.Add("owner", testCredential, "")
+	o.NoError(err)
+	o.Equal(database.RoleOwner, owner.Role)
+	o.NotZero(owner.ID)
+
+	credential, err := o.db.GetCredential(testCredential.ID)
+	o.NoError(err)
+	testCredential.OperatorID = owner.ID
+	o.Equal(testCredential, credential)
+
+	count, err := o.db.Count()
+	o.NoError(err)
+	o.Equal(uint64(1), count)
+
+	o.Run("Without invite", func() {
+		_, err := o.db.Add("second", database.Credential{ID: []byte{7}, PublicKey: []byte{7}}, "")
+		o.ErrorIs(err, database.ErrOperatorsExist)
+	})
+
+	o.Run("With invite", func() {
+		expiresAt := time.Now().Add(time.Hour).Unix()
+		err := o.db.AddInvite("invite", database.RoleReadOnly, expiresAt)
+		o.NoError(err)
+
+		role, err := o.db.GetInviteRole("invite")
+		o.NoError(err)
+		o.Equal(database.RoleReadOnly, role)
+
+		operator, err := o.db.Add("reader", database.Credential{ID: []byte{8}, PublicKey: []byte{8}}, "invite")
+		o.NoError(err)
+		o.Equal(database.RoleReadOnly, operator.Role)
+
+		// Invites can be used only once
+		_, err = o.db.Add("another", database.Credential{ID: []byte{9}, PublicKey: []byte{9}}, "invite")
+		o.ErrorIs(err, database.ErrInvalidInvite)
+	})
+
+	o.Run("Expired invite", func() {
+		expiresAt := time.Now().Add(-time.Hour).Unix()
+		err := o.db.AddInvite("expired", database.RoleOwner, expiresAt)
+		o.NoError(err)
+
+		_, err = o.db.GetInviteRole("expired")
+		o.ErrorIs(err, database.ErrInvalidInvite)
+
+		_, err = o.db.Add("late", database.Credential{ID: []byte{10}, PublicKey: []byte{10}}, "expired")
+		o.ErrorIs(err, database.ErrInvalidInvite)
+	})
+}
+
+func (o *OperatorsSuite) TestDelete() {
+	owner, err := o.db.Add("owner", testCredential, "")
+	o.NoError(err)
+
+	err = o.db.Delete(owner.ID)
+	o.NoError(err)
+
+	operators, err := o.db.List()
+	o.NoError(err)
+	o.Empty(operators)
+
+	_, err = o.db.GetCredential(testCredential.ID)
+	o.ErrorIs(err, database.ErrNoCredential)
+
+	err = o.db.Delete(owner.ID)
+	o.ErrorIs(err, database.ErrNoOperator)
+}
+
+func (o *OperatorsSuite) TestList() {
+	owner, err := o.db.Add("owner", testCredential, "")
+	o.NoError(err)
+
+	operators, err := o.db.List()
+	o.NoError(err)
+	o.Equal([]database.Operator{owner}, operators)
+}
+
+func (o *OperatorsSuite) TestUpdateSignCount() {
+	_, err := o.db.Add("owner", testCredential, "")
+	o.NoError(err)
+
+	err = o.db.UpdateSignCount(testCredential.ID, 10)
+	o.NoError(err)
+
+	credential, err := o.db.GetCredential(testCredential.ID)
+	o.NoError(err)
+	o.Equal(uint32(10), credential.SignCount)
+}
+
+func TestParseRole(t *testing.T) {
+	for _, role := range []database.Role{
+		database.RoleReadOnly,
+		database.RoleOperator,
+		database.RoleOwner,
+	} {
+		parsed, err := database.ParseRole(role.String())
+		if err != nil || parsed != role {
+			t.Errorf("expected role %s, got %s (%v)", role, parsed, err)
+		}
+	}
+
+	if _, err := database.ParseRole("admin"); err == nil {
+		t.Error("expected an error")
+	}
+}
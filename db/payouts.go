@@ -0,0 +1,156 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// Payout statuses.
+const (
+	// PayoutPending is the status of the payouts the node hasn't started sending yet
+	PayoutPending   = "pending"
+	PayoutInFlight  = "in_flight"
+	PayoutSucceeded = "succeeded"
+	PayoutFailed    = "failed"
+)
+
+// PayoutsStore contains the methods used to store and retrieve the status of the withdrawals paid
+// to invoices.
+type PayoutsStore interface {
+	Add(payout Payout) (uint64, error)
+	List(publicKey string, offset, limit uint64, reverse bool) ([]Payout, error)
+	Update(payout Payout) (bool, error)
+}
+
+// Payout represents a withdrawal paid to an invoice.
+type Payout struct {
+	PublicKey   string `json:"public_key"`
+	PaymentHash string `json:"payment_hash"`
+	Status      string `json:"status"`
+	// FailureReason is set when the payment fails
+	FailureReason string `json:"failure_reason,omitempty"`
+	// Preimage proves the invoice was paid, it's set when the payment succeeds
+	Preimage string `json:"preimage,omitempty"`
+	ID       uint64 `json:"id"`
+	// Amount is the prizes claimed, the routing fee included
+	Amount uint64 `json:"amount"`
+	// Fee is the routing fee paid, it's set when the payment succeeds
+	Fee       uint64 `json:"fee"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+type payouts struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newPayoutsStore returns a new payouts storage service.
+func newPayoutsStore(db *sql.DB, logger *logger.Logger) PayoutsStore {
+	return &payouts{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add stores a payout and returns its identifier.
+func (p *payouts) Add(payout Payout) (uint64, error) {
+	query := `INSERT INTO payouts
+	(public_key, payment_hash, amount, status, created_at, updated_at) VALUES (?,?,?,?,?,?)`
+	stmt, err := p.db.Prepare(query)
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(payout.PublicKey, payout.PaymentHash, payout.Amount, payout.Status,
+		payout.CreatedAt, payout.CreatedAt)
+	if err != nil {
+		return 0, errors.Wrap(err, "adding payout")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, errors.Wrap(err, "getting payout identifier")
+	}
+
+	return uint64(id), nil
+}
+
+// List returns the payouts of the public key.
+func (p *payouts) List(publicKey string, offset, limit uint64, reverse bool) ([]Payout, error) {
+	// Cap limit to avoid creating a slice with too big capacity
+	if limit > 500 {
+		limit = 500
+	}
+
+	query := `SELECT id, public_key, payment_hash, amount, status, failure_reason, fee, preimage,
+	created_at, updated_at FROM payouts WHERE public_key=?`
+	query = AddPagination(query, offset, limit, "id", reverse)
+
+	stmt, err := p.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing payouts")
+	}
+	defer rows.Close()
+
+	payouts := make([]Payout, 0, limit)
+	// Reuse object
+	var payout Payout
+	for rows.Next() {
+		err := rows.Scan(&payout.ID, &payout.PublicKey, &payout.PaymentHash, &payout.Amount,
+			&payout.Status, &payout.FailureReason, &payout.Fee, &payout.Preimage, &payout.CreatedAt,
+			&payout.UpdatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		payouts = append(payouts, payout)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating payouts")
+	}
+
+	return payouts, nil
+}
+
+// Update sets the status of the payout in progress with the payment hash and returns whether it
+// changed. Completed payouts are left as they are and payouts in flight don't go back to that
+// status, so repeated updates of the node are ignored.
+func (p *payouts) Update(payout Payout) (bool, error) {
+	from := PayoutInFlight
+	if payout.Status == PayoutInFlight {
+		from = PayoutPending
+	}
+
+	query := `UPDATE payouts SET status=?, failure_reason=?, fee=?, preimage=?, updated_at=?
+	WHERE payment_hash=? AND status IN (?, ?)`
+	stmt, err := p.db.Prepare(query)
+	if err != nil {
+		return false, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(payout.Status, payout.FailureReason, payout.Fee, payout.Preimage,
+		payout.UpdatedAt, payout.PaymentHash, PayoutPending, from)
+	if err != nil {
+		return false, errors.Wrap(err, "updating payout")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "getting rows affected")
+	}
+
+	return n > 0, nil
+}
@@ -0,0 +1,31 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// PayoutsStoreMock is a mocked implementation of the payouts store.
+type PayoutsStoreMock struct {
+	mock.Mock
+}
+
+// NewPayoutsStoreMock returns a mocked payouts store.
+func NewPayoutsStoreMock() *PayoutsStoreMock {
+	return &PayoutsStoreMock{}
+}
+
+// Add mock.
+func (p *PayoutsStoreMock) Add(payout Payout) (uint64, error) {
+	args := p.Called(payout)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+// List mock.
+func (p *PayoutsStoreMock) List(publicKey string, offset, limit uint64, reverse bool) ([]Payout, error) {
+	args := p.Called(publicKey, offset, limit, reverse)
+	return args.Get(0).([]Payout), args.Error(1)
+}
+
+// Update mock.
+func (p *PayoutsStoreMock) Update(payout Payout) (bool, error) {
+	args := p.Called(payout)
+	return args.Bool(0), args.Error(1)
+}
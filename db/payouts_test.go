@@ -0,0 +1,155 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PayoutsSuite struct {
+	suite.Suite
+
+	db *database.DB
+}
+
+func TestPayoutsSuite(t *testing.T) {
+	suite.Run(t, &PayoutsSuite{})
+}
+
+func (p *PayoutsSuite) SetupTest() {
+	p.db = setupDB(p.T(), func(db *sql.DB) {})
+}
+
+func (p *PayoutsSuite) TestPayouts() {
+	first, err := p.db.Payouts.Add(database.Payout{
+		PublicKey:   "a",
+		PaymentHash: "hash1",
+		Amount:      1_010,
+		Status:      database.PayoutPending,
+		CreatedAt:   1231006505,
+	})
+	p.NoError(err)
+	second, err := p.db.Payouts.Add(database.Payout{
+		PublicKey:   "a",
+		PaymentHash: "hash2",
+		Amount:      505,
+		Status:      database.PayoutPending,
+		CreatedAt:   1231006505,
+	})
+	p.NoError(err)
+	_, err = p.db.Payouts.Add(database.Payout{
+		PublicKey:   "b",
+		PaymentHash: "hash3",
+		Amount:      100,
+		Status:      database.PayoutPending,
+		CreatedAt:   1231006505,
+	})
+	p.NoError(err)
+
+	updated, err := p.db.Payouts.Update(database.Payout{
+		PaymentHash: "hash1",
+		Status:      database.PayoutInFlight,
+		UpdatedAt:   1231006510,
+	})
+	p.NoError(err)
+	p.True(updated)
+
+	// Every attempt of the node is reported in flight
+	updated, err = p.db.Payouts.Update(database.Payout{
+		PaymentHash: "hash1",
+		Status:      database.PayoutInFlight,
+		UpdatedAt:   1231006515,
+	})
+	p.NoError(err)
+	p.False(updated)
+
+	updated, err = p.db.Payouts.Update(database.Payout{
+		PaymentHash: "hash1",
+		Status:      database.PayoutSucceeded,
+		Fee:         3,
+		Preimage:    "preimage",
+		UpdatedAt:   1231006520,
+	})
+	p.NoError(err)
+	p.True(updated)
+
+	updated, err = p.db.Payouts.Update(database.Payout{
+		PaymentHash:   "hash2",
+		Status:        database.PayoutFailed,
+		FailureReason: "FAILURE_REASON_NO_ROUTE",
+		UpdatedAt:     1231006525,
+	})
+	p.NoError(err)
+	p.True(updated)
+
+	// Completed payouts don't change
+	updated, err = p.db.Payouts.Update(database.Payout{
+		PaymentHash: "hash2",
+		Status:      database.PayoutInFlight,
+		UpdatedAt:   1231006530,
+	})
+	p.NoError(err)
+	p.False(updated)
+
+	payouts, err := p.db.Payouts.List("a", 0, 10, true)
+	p.NoError(err)
+	expected := []database.Payout{
+		{
+			ID:            second,
+			PublicKey:     "a",
+			PaymentHash:   "hash2",
+			Amount:        505,
+			Status:        database.PayoutFailed,
+			FailureReason: "FAILURE_REASON_NO_ROUTE",
+			CreatedAt:     1231006505,
+			UpdatedAt:     1231006525,
+		},
+		{
+			ID:          first,
+			PublicKey:   "a",
+			PaymentHash: "hash1",
+			Amount:      1_010,
+			Status:      database.PayoutSucceeded,
+			Fee:         3,
+			Preimage:    "preimage",
+			CreatedAt:   1231006505,
+			UpdatedAt:   1231006520,
+		},
+	}
+	p.Equal(expected, payouts)
+
+	payouts, err = p.db.Payouts.List("a", first, 10, false)
+	p.NoError(err)
+	p.Equal(expected[:1], payouts)
+}
+
+func (p *PayoutsSuite) TestUpdateRetriedInvoice() {
+	// The invoice of a failed payout can be used again, only the payout in progress is updated
+	for _, status := range []string{database.PayoutFailed, database.PayoutPending} {
+		_, err := p.db.Payouts.Add(database.Payout{
+			PublicKey:   "a",
+			PaymentHash: "hash",
+			Amount:      1_000,
+			Status:      status,
+			CreatedAt:   1231006505,
+		})
+		p.NoError(err)
+	}
+
+	updated, err := p.db.Payouts.Update(database.Payout{
+		PaymentHash: "hash",
+		Status:      database.PayoutSucceeded,
+		UpdatedAt:   1231006510,
+	})
+	p.NoError(err)
+	p.True(updated)
+
+	payouts, err := p.db.Payouts.List("a", 0, 10, false)
+	p.NoError(err)
+	p.Len(payouts, 2)
+	p.Equal(database.PayoutFailed, payouts[0].Status)
+	p.Equal(database.PayoutSucceeded, payouts[1].Status)
+}
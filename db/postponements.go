@@ -0,0 +1,119 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPostponementNotFound is returned when no lottery draw is postponed.
+var ErrPostponementNotFound = errors.New("postponement not found")
+
+// PostponementsStore contains the methods used to keep track of the draws postponed because the
+// node couldn't pay their prizes.
+type PostponementsStore interface {
+	GetActive() (Postponement, error)
+	Postpone(postponement Postponement) error
+	Resume(lotteryHeight, height uint32) error
+}
+
+// Postponement is a lottery draw delayed until the node channels cover the prizes owed, including
+// the ones of the lottery itself.
+type Postponement struct {
+	// BlockHash is the hash of the target block, the lottery is drawn with it once resumed
+	BlockHash     string `json:"block_hash"`
+	Liabilities   uint64 `json:"-"`
+	LocalBalance  uint64 `json:"-"`
+	LotteryHeight uint32 `json:"lottery_height"`
+	// PostponedAt is the height at which the draw was postponed for the first time
+	PostponedAt uint32 `json:"postponed_at"`
+	// ResumeHeight is the height at which the draw is attempted again
+	ResumeHeight uint32 `json:"resume_height"`
+	// Attempts is the number of times the draw was postponed
+	Attempts uint32 `json:"attempts"`
+	// ResumedAt is the height at which the lottery was drawn, zero while it's postponed
+	ResumedAt uint32 `json:"resumed_at,omitempty"`
+}
+
+type postponements struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newPostponementsStore returns a new postponements storage service.
+func newPostponementsStore(db *sql.DB, logger *logger.Logger) PostponementsStore {
+	return &postponements{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetActive returns the postponement of the lottery that is waiting for liquidity to be drawn.
+//
+// Lotteries drawn or removed while postponed, for example if the postponements were disabled
+// afterwards, are not active.
+func (p *postponements) GetActive() (Postponement, error) {
+	query := `SELECT p.lottery_height, p.block_hash, p.liabilities, p.local_balance, p.postponed_at,
+	p.resume_height, p.attempts, p.resumed_at FROM postponements p
+	INNER JOIN lotteries l ON l.height = p.lottery_height
+	WHERE p.resumed_at = 0 AND l.block_hash = '' ORDER BY p.lottery_height LIMIT 1`
+	stmt, err := p.db.Prepare(query)
+	if err != nil {
+		return Postponement{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var postponement Postponement
+	err = stmt.QueryRow().Scan(&postponement.LotteryHeight, &postponement.BlockHash,
+		&postponement.Liabilities, &postponement.LocalBalance, &postponement.PostponedAt,
+		&postponement.ResumeHeight, &postponement.Attempts, &postponement.ResumedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Postponement{}, ErrPostponementNotFound
+		}
+		return Postponement{}, errors.Wrap(err, "getting postponement")
+	}
+
+	return postponement, nil
+}
+
+// Postpone stores the postponement of a lottery draw. If it was already postponed, the resume
+// height and the balances are updated and the attempts increased.
+func (p *postponements) Postpone(postponement Postponement) error {
+	query := `INSERT INTO postponements
+	(lottery_height, block_hash, liabilities, local_balance, postponed_at, resume_height, attempts)
+	VALUES (?,?,?,?,?,?,1)
+	ON CONFLICT (lottery_height) DO UPDATE SET liabilities=excluded.liabilities,
+	local_balance=excluded.local_balance, resume_height=excluded.resume_height,
+	attempts=attempts+1`
+	stmt, err := p.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(postponement.LotteryHeight, postponement.BlockHash, postponement.Liabilities,
+		postponement.LocalBalance, postponement.PostponedAt, postponement.ResumeHeight)
+	if err != nil {
+		return errors.Wrap(err, "postponing draw")
+	}
+
+	return nil
+}
+
+// Resume sets the height at which the postponed lottery draw was resumed.
+func (p *postponements) Resume(lotteryHeight, height uint32) error {
+	stmt, err := p.db.Prepare("UPDATE postponements SET resumed_at=? WHERE lottery_height=?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(height, lotteryHeight); err != nil {
+		return errors.Wrap(err, "resuming draw")
+	}
+
+	return nil
+}
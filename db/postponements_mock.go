@@ -0,0 +1,31 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// PostponementsStoreMock is a mocked implementation of the postponements store.
+type PostponementsStoreMock struct {
+	mock.Mock
+}
+
+// NewPostponementsStoreMock returns a mocked postponements store.
+func NewPostponementsStoreMock() *PostponementsStoreMock {
+	return &PostponementsStoreMock{}
+}
+
+// GetActive mock.
+func (m *PostponementsStoreMock) GetActive() (Postponement, error) {
+	args := m.Called()
+	return args.Get(0).(Postponement), args.Error(1)
+}
+
+// Postpone mock.
+func (m *PostponementsStoreMock) Postpone(postponement Postponement) error {
+	args := m.Called(postponement)
+	return args.Error(0)
+}
+
+// Resume mock.
+func (m *PostponementsStoreMock) Resume(lotteryHeight, height uint32) error {
+	args := m.Called(lotteryHeight, height)
+	return args.Error(0)
+}
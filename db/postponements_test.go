@@ -0,0 +1,83 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PostponementsSuite struct {
+	suite.Suite
+
+	db *database.DB
+}
+
+func TestPostponementsSuite(t *testing.T) {
+	suite.Run(t, &PostponementsSuite{})
+}
+
+func (s *PostponementsSuite) SetupTest() {
+	s.db = setupDB(s.T(), func(db *sql.DB) {})
+	s.NoError(s.db.Lotteries.AddHeight(144, "seed", "commitment"))
+	s.NoError(s.db.Lotteries.AddHeight(288, "seed", "commitment"))
+}
+
+func (s *PostponementsSuite) TestPostpone() {
+	postponement := database.Postponement{
+		LotteryHeight: 144,
+		BlockHash:     "hash",
+		Liabilities:   150_000,
+		LocalBalance:  100_000,
+		PostponedAt:   144,
+		ResumeHeight:  150,
+	}
+	s.NoError(s.db.Postponements.Postpone(postponement))
+
+	got, err := s.db.Postponements.GetActive()
+	s.NoError(err)
+	postponement.Attempts = 1
+	s.Equal(postponement, got)
+
+	// Postponing it again keeps the first height and block hash
+	s.NoError(s.db.Postponements.Postpone(database.Postponement{
+		LotteryHeight: 144,
+		Liabilities:   150_000,
+		LocalBalance:  120_000,
+		PostponedAt:   150,
+		ResumeHeight:  156,
+	}))
+
+	got, err = s.db.Postponements.GetActive()
+	s.NoError(err)
+	s.Equal(uint32(144), got.PostponedAt)
+	s.Equal("hash", got.BlockHash)
+	s.Equal(uint64(120_000), got.LocalBalance)
+	s.Equal(uint32(156), got.ResumeHeight)
+	s.Equal(uint32(2), got.Attempts)
+}
+
+func (s *PostponementsSuite) TestResume() {
+	s.NoError(s.db.Postponements.Postpone(database.Postponement{LotteryHeight: 144, ResumeHeight: 150}))
+	s.NoError(s.db.Postponements.Resume(144, 150))
+
+	_, err := s.db.Postponements.GetActive()
+	s.ErrorIs(err, database.ErrPostponementNotFound)
+}
+
+func (s *PostponementsSuite) TestGetActiveDrawn() {
+	s.NoError(s.db.Postponements.Postpone(database.Postponement{LotteryHeight: 144, ResumeHeight: 150}))
+	s.NoError(s.db.Postponements.Postpone(database.Postponement{LotteryHeight: 288, ResumeHeight: 294}))
+
+	// Lotteries drawn or removed while postponed are not active anymore
+	s.NoError(s.db.Lotteries.SetDraw(144, "hash", ""))
+	got, err := s.db.Postponements.GetActive()
+	s.NoError(err)
+	s.Equal(uint32(288), got.LotteryHeight)
+
+	s.NoError(s.db.Lotteries.DeleteHeight(288))
+	_, err = s.db.Postponements.GetActive()
+	s.ErrorIs(err, database.ErrPostponementNotFound)
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// Winners display modes, how a player appears in the public winners lists.
+const (
+	DisplayFull      = "full"
+	DisplayTruncated = "truncated"
+	DisplayAlias     = "alias"
+	DisplayHidden    = "hidden"
+)
+
+// PrivacyStore contains the methods used to store the players privacy preferences.
+type PrivacyStore interface {
+	Get(publicKey string) (Privacy, error)
+	List(publicKeys []string) (map[string]Privacy, error)
+	Set(publicKey string, privacy Privacy) error
+}
+
+// Privacy is the preference of a player on how to appear in the public winners lists. Players
+// without one are displayed with their full public key.
+type Privacy struct {
+	Display string `json:"display"`
+	Alias   string `json:"alias,omitempty"`
+}
+
+type privacy struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newPrivacyStore returns a new privacy preferences storage service.
+func newPrivacyStore(db *sql.DB, logger *logger.Logger) PrivacyStore {
+	return &privacy{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Get returns the privacy preference of a public key.
+func (p *privacy) Get(publicKey string) (Privacy, error) {
+	stmt, err := p.db.Prepare("SELECT display, alias FROM privacy WHERE public_key=?")
+	if err != nil {
+		return Privacy{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var privacy Privacy
+	if err := stmt.QueryRow(publicKey).Scan(&privacy.Display, &privacy.Alias); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Privacy{Display: DisplayFull}, nil
+		}
+		return Privacy{}, errors.Wrap(err, "getting privacy preference")
+	}
+
+	return privacy, nil
+}
+
+// List returns the privacy preferences of the public keys that have one.
+func (p *privacy) List(publicKeys []string) (map[string]Privacy, error) {
+	preferences := make(map[string]Privacy)
+	if len(publicKeys) == 0 {
+		return preferences, nil
+	}
+
+	args := make([]any, len(publicKeys))
+	for i, publicKey := range publicKeys {
+		args[i] = publicKey
+	}
+	placeholders := strings.Repeat("?,", len(publicKeys)-1) + "?"
+
+	query := "SELECT public_key, display, alias FROM privacy WHERE public_key IN (" + placeholders + ")"
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying privacy preferences")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			publicKey string
+			privacy   Privacy
+		)
+		if err := rows.Scan(&publicKey, &privacy.Display, &privacy.Alias); err != nil {
+			return nil, errors.Wrap(err, "scanning privacy preference")
+		}
+		preferences[publicKey] = privacy
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating privacy preferences")
+	}
+
+	return preferences, nil
+}
+
+// Set stores the privacy preference of a public key, replacing the previous one.
+func (p *privacy) Set(publicKey string, privacy Privacy) error {
+	query := `INSERT INTO privacy (public_key, display, alias) VALUES (?,?,?)
+	ON CONFLICT (public_key) DO UPDATE SET display=excluded.display, alias=excluded.alias`
+	stmt, err := p.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(publicKey, privacy.Display, privacy.Alias); err != nil {
+		return errors.Wrap(err, "setting privacy preference")
+	}
+
+	return nil
+}
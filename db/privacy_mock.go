@@ -0,0 +1,35 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// PrivacyStoreMock is a mocked implementation of the privacy store.
+type PrivacyStoreMock struct {
+	mock.Mock
+}
+
+// NewPrivacyStoreMock returns a mocked privacy store.
+func NewPrivacyStoreMock() *PrivacyStoreMock {
+	return &PrivacyStoreMock{}
+}
+
+// Get mock.
+func (p *PrivacyStoreMock) Get(publicKey string) (Privacy, error) {
+	args := p.Called(publicKey)
+	return args.Get(0).(Privacy), args.Error(1)
+}
+
+// List mock.
+func (p *PrivacyStoreMock) List(publicKeys []string) (map[string]Privacy, error) {
+	args := p.Called(publicKeys)
+	var preferences map[string]Privacy
+	if v := args.Get(0); v != nil {
+		preferences = v.(map[string]Privacy)
+	}
+	return preferences, args.Error(1)
+}
+
+// Set mock.
+func (p *PrivacyStoreMock) Set(publicKey string, privacy Privacy) error {
+	args := p.Called(publicKey, privacy)
+	return args.Error(0)
+}
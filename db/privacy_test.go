@@ -0,0 +1,65 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PrivacySuite struct {
+	suite.Suite
+
+	db database.PrivacyStore
+}
+
+func TestPrivacySuite(t *testing.T) {
+	suite.Run(t, &PrivacySuite{})
+}
+
+func (p *PrivacySuite) SetupTest() {
+	db := setupDB(p.T(), func(db *sql.DB) {})
+	p.db = db.Privacy
+}
+
+func (p *PrivacySuite) TestGetDefault() {
+	privacy, err := p.db.Get("public_key")
+	p.NoError(err)
+	p.Equal(database.Privacy{Display: database.DisplayFull}, privacy)
+}
+
+func (p *PrivacySuite) TestSet() {
+	err := p.db.Set("public_key", database.Privacy{Display: database.DisplayAlias, Alias: "satoshi"})
+	p.NoError(err)
+
+	err = p.db.Set("public_key", database.Privacy{Display: database.DisplayHidden})
+	p.NoError(err)
+
+	privacy, err := p.db.Get("public_key")
+	p.NoError(err)
+	p.Equal(database.Privacy{Display: database.DisplayHidden}, privacy)
+
+	err = p.db.Set("public_key", database.Privacy{Display: "invisible"})
+	p.Error(err)
+}
+
+func (p *PrivacySuite) TestList() {
+	p.NoError(p.db.Set("a", database.Privacy{Display: database.DisplayTruncated}))
+	p.NoError(p.db.Set("b", database.Privacy{Display: database.DisplayAlias, Alias: "bob"}))
+	p.NoError(p.db.Set("c", database.Privacy{Display: database.DisplayHidden}))
+
+	preferences, err := p.db.List([]string{"a", "b", "d"})
+	p.NoError(err)
+
+	expected := map[string]database.Privacy{
+		"a": {Display: database.DisplayTruncated},
+		"b": {Display: database.DisplayAlias, Alias: "bob"},
+	}
+	p.Equal(expected, preferences)
+
+	preferences, err = p.db.List(nil)
+	p.NoError(err)
+	p.Empty(preferences)
+}
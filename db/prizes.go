@@ -2,7 +2,9 @@ package db
 
 import (
 	"database/sql"
+	"time"
 
+	"github.com/aftermath2/BTRY/fault"
 	"github.com/aftermath2/BTRY/logger"
 
 	"github.com/pkg/errors"
@@ -11,18 +13,34 @@ import (
 // ErrInsufficientPrizes is returned when the user requests more than he has.
 var ErrInsufficientPrizes = errors.New("withdrawal amount is higher than assigned prizes")
 
+// ErrInvoiceClaimed is returned when the invoice was already used to claim prizes.
+var ErrInvoiceClaimed = errors.New("invoice already used to claim prizes")
+
 // PrizesStore contains the methods used to store and retrieve prizes from the database.
 type PrizesStore interface {
+	Claim(publicKey, paymentHash string, amount uint64) ([]PrizesRow, error)
 	Expire(lotteryHeight uint32) (uint64, error)
 	Get(publicKey string) (uint64, error)
+	GetTotal() (uint64, error)
+	List(publicKey string) ([]Prize, error)
+	Restore(claims []PrizesRow) error
 	Set(lotteryHeight uint32, winners []Winner) error
-	Withdraw(publicKey string, amount uint64) error
+	Withdraw(publicKey string, amount uint64) ([]PrizesRow, error)
+}
+
+// Prize is the claimable balance of a prize won, which expires along with the lottery it was won
+// in.
+type Prize struct {
+	LotteryHeight uint32 `json:"lottery_height"`
+	Amount        uint64 `json:"amount"`
 }
 
 // PrizesRow represent a prizes table row.
 type PrizesRow struct {
-	RowID  int    `db:"rowid"`
-	Amount uint64 `db:"amount"`
+	// PaymentHash is the invoice the amount was claimed with, if it was claimed by one
+	PaymentHash string
+	RowID       int    `db:"rowid"`
+	Amount      uint64 `db:"amount"`
 }
 
 type prizes struct {
@@ -38,9 +56,10 @@ func newPrizesStore(db *sql.DB, logger *logger.Logger) PrizesStore {
 	}
 }
 
-// Expire sets prizes won before lotteryHeight as expired.
+// Expire sets prizes won before lotteryHeight as expired and returns the amount of the ones that
+// weren't expired yet.
 func (p *prizes) Expire(lotteryHeight uint32) (uint64, error) {
-	query := "UPDATE prizes SET expired=1 WHERE lottery_height <= ? RETURNING amount"
+	query := "UPDATE prizes SET expired=1 WHERE lottery_height <= ? AND expired=0 RETURNING amount"
 	stmt, err := p.db.Prepare(query)
 	if err != nil {
 		return 0, errors.Wrap(err, "preparing statement")
@@ -86,13 +105,121 @@ func (p *prizes) Get(publicKey string) (uint64, error) {
 	return prizes, nil
 }
 
+// GetTotal returns the sum of the prizes that haven't been withdrawn nor expired yet.
+func (p *prizes) GetTotal() (uint64, error) {
+	stmt, err := p.db.Prepare("SELECT COALESCE(SUM(amount), 0) FROM prizes WHERE expired=0")
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var total uint64
+	if err := stmt.QueryRow().Scan(&total); err != nil {
+		return 0, errors.Wrap(err, "getting prizes total")
+	}
+
+	return total, nil
+}
+
+// List returns the prizes of the public key that can still be claimed, the ones expiring first go
+// first.
+func (p *prizes) List(publicKey string) ([]Prize, error) {
+	query := `SELECT lottery_height, amount FROM prizes WHERE public_key=? AND expired=0 AND amount != 0
+	ORDER BY lottery_height, rowid`
+	stmt, err := p.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing prizes")
+	}
+	defer rows.Close()
+
+	var (
+		prizes []Prize
+		// Reuse object
+		prize Prize
+	)
+	for rows.Next() {
+		if err := rows.Scan(&prize.LotteryHeight, &prize.Amount); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		prizes = append(prizes, prize)
+	}
+
+	return prizes, nil
+}
+
+// Restore gives back the amounts claimed from each prize. Prizes that expired in the meantime
+// remain expired.
+//
+// The invoices the prizes were claimed with are released, so they can be used again.
+func (p *prizes) Restore(claims []PrizesRow) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("UPDATE prizes SET amount = amount + ? WHERE rowid=?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	released := make(map[string]struct{})
+	for _, claim := range claims {
+		if _, err := stmt.Exec(claim.Amount, claim.RowID); err != nil {
+			return errors.Wrap(err, "restoring prizes")
+		}
+
+		if claim.PaymentHash == "" {
+			continue
+		}
+		if _, ok := released[claim.PaymentHash]; ok {
+			continue
+		}
+		released[claim.PaymentHash] = struct{}{}
+
+		if _, err := tx.Exec("DELETE FROM prize_claim_winners WHERE payment_hash=?",
+			claim.PaymentHash); err != nil {
+			return errors.Wrap(err, "releasing invoice")
+		}
+		if _, err := tx.Exec("DELETE FROM prize_claims WHERE payment_hash=?",
+			claim.PaymentHash); err != nil {
+			return errors.Wrap(err, "releasing invoice")
+		}
+	}
+
+	return tx.Commit()
+}
+
 // Set stores the prizes for each winner.
 func (p *prizes) Set(lotteryHeight uint32, winners []Winner) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	if err := setPrizes(tx, lotteryHeight, winners); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setPrizes inserts the prizes of the winners inside the transaction.
+func setPrizes(tx *sql.Tx, lotteryHeight uint32, winners []Winner) error {
 	query := "INSERT INTO prizes (public_key, amount, lottery_height) VALUES "
 	values := BulkInsertValues(len(winners), 3)
 	query += values
 
-	stmt, err := p.db.Prepare(query)
+	stmt, err := tx.Prepare(query)
 	if err != nil {
 		return errors.Wrap(err, "preparing statement")
 	}
@@ -112,57 +239,144 @@ func (p *prizes) Set(lotteryHeight uint32, winners []Winner) error {
 	return nil
 }
 
-// Withdraw substracts the withdrawal amount from the winner prizes.
-func (p *prizes) Withdraw(publicKey string, amount uint64) error {
-	if amount == 0 {
-		return ErrInsufficientPrizes
+// Claim substracts the amount of the invoice with the payment hash specified from the winner
+// prizes, like Withdraw, and records the claim along with the amount taken from the prizes of each
+// lottery.
+//
+// Claims are keyed by the payment hash and recorded in the same transaction as the deduction, so an
+// invoice submitted by concurrent requests claims the prizes once and ErrInvoiceClaimed is returned
+// to the rest.
+func (p *prizes) Claim(publicKey, paymentHash string, amount uint64) ([]PrizesRow, error) {
+	if paymentHash == "" {
+		return nil, errors.New("payment hash is required")
 	}
 
 	tx, err := p.db.Begin()
 	if err != nil {
-		return errors.Wrap(err, "starting transaction")
+		return nil, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	// Writing first takes the database lock, the concurrent claims wait for this one to finish
+	// and violate the uniqueness of the payment hash
+	_, err = tx.Exec("INSERT INTO prize_claims (payment_hash, public_key, created_at) VALUES (?,?,?)",
+		paymentHash, publicKey, time.Now().Unix())
+	if err != nil {
+		if fault.KindOf(Classify(err)) == fault.Conflict {
+			return nil, ErrInvoiceClaimed
+		}
+		return nil, errors.Wrap(err, "recording payment hash")
+	}
+
+	claims, err := withdraw(tx, publicKey, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO prize_claim_winners (payment_hash, lottery_height, amount)
+	SELECT ?, lottery_height, ? FROM prizes WHERE rowid=?
+	ON CONFLICT (payment_hash, lottery_height) DO UPDATE SET amount = amount + excluded.amount`
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	for i, claim := range claims {
+		if _, err := stmt.Exec(paymentHash, claim.Amount, claim.RowID); err != nil {
+			return nil, errors.Wrap(err, "recording payment hash")
+		}
+		claims[i].PaymentHash = paymentHash
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "committing transaction")
+	}
+
+	return claims, nil
+}
+
+// Withdraw substracts the withdrawal amount from the winner prizes and returns the amount claimed
+// from each of them, so they can be restored if the payment fails.
+//
+// Prizes can be claimed partially, the ones expiring first are claimed first and the remainder
+// keeps the expiration of the prize it belongs to.
+func (p *prizes) Withdraw(publicKey string, amount uint64) ([]PrizesRow, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, errors.Wrap(err, "starting transaction")
 	}
 	defer tx.Rollback()
 
-	query := "SELECT rowid, amount FROM prizes WHERE public_key=? AND expired=0 AND amount != 0 ORDER BY rowid DESC"
+	claims, err := withdraw(tx, publicKey, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "committing transaction")
+	}
+
+	return claims, nil
+}
+
+// withdraw substracts the amount from the winner prizes inside the transaction.
+func withdraw(tx *sql.Tx, publicKey string, amount uint64) ([]PrizesRow, error) {
+	if amount == 0 {
+		return nil, ErrInsufficientPrizes
+	}
+
+	query := `SELECT rowid, amount FROM prizes WHERE public_key=? AND expired=0 AND amount != 0
+	ORDER BY lottery_height, rowid`
 	selectStmt, err := tx.Prepare(query)
 	if err != nil {
-		return errors.Wrap(err, "preparing statement")
+		return nil, errors.Wrap(err, "preparing statement")
 	}
 	defer selectStmt.Close()
 
 	rows, err := selectStmt.Query(publicKey)
 	if err != nil {
-		return errors.Wrap(err, "updating prizes")
+		return nil, errors.Wrap(err, "updating prizes")
 	}
 
-	var prizes []*PrizesRow
+	var (
+		prizes  []*PrizesRow
+		amounts []uint64
+	)
 	for rows.Next() {
 		var prize PrizesRow
 		if err := rows.Scan(&prize.RowID, &prize.Amount); err != nil {
-			return errors.Wrap(err, "scanning rows")
+			return nil, errors.Wrap(err, "scanning rows")
 		}
 
 		prizes = append(prizes, &prize)
+		amounts = append(amounts, prize.Amount)
 	}
 
 	if err := UpdatePrizes(amount, prizes); err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, prize := range prizes {
-		updateStmt, err := tx.Prepare("UPDATE prizes SET amount=? WHERE rowid=?")
-		if err != nil {
-			return errors.Wrap(err, "preparing statement")
+	updateStmt, err := tx.Prepare("UPDATE prizes SET amount=? WHERE rowid=?")
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer updateStmt.Close()
+
+	var claims []PrizesRow
+	for i, prize := range prizes {
+		if prize.Amount == amounts[i] {
+			continue
 		}
-		defer updateStmt.Close()
 
 		if _, err := updateStmt.Exec(prize.Amount, prize.RowID); err != nil {
-			return errors.Wrap(err, "updating prizes")
+			return nil, errors.Wrap(err, "updating prizes")
 		}
+
+		claims = append(claims, PrizesRow{RowID: prize.RowID, Amount: amounts[i] - prize.Amount})
 	}
 
-	return tx.Commit()
+	return claims, nil
 }
 
 // UpdatePrizes substracts the amount from the prizes.
@@ -12,6 +12,17 @@ func NewPrizesStoreMock() *PrizesStoreMock {
 	return &PrizesStoreMock{}
 }
 
+// Claim mock.
+func (w *PrizesStoreMock) Claim(publicKey, paymentHash string, amount uint64) ([]PrizesRow, error) {
+	args := w.Called(publicKey, paymentHash, amount)
+	var r0 []PrizesRow
+	v0 := args.Get(0)
+	if v0 != nil {
+		r0 = v0.([]PrizesRow)
+	}
+	return r0, args.Error(1)
+}
+
 // Expire mock.
 func (w *PrizesStoreMock) Expire(height uint32) (uint64, error) {
 	args := w.Called(height)
@@ -24,6 +35,24 @@ func (w *PrizesStoreMock) Get(publicKey string) (uint64, error) {
 	return args.Get(0).(uint64), args.Error(1)
 }
 
+// GetTotal mock.
+func (w *PrizesStoreMock) GetTotal() (uint64, error) {
+	args := w.Called()
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+// List mock.
+func (w *PrizesStoreMock) List(publicKey string) ([]Prize, error) {
+	args := w.Called(publicKey)
+	return args.Get(0).([]Prize), args.Error(1)
+}
+
+// Restore mock.
+func (w *PrizesStoreMock) Restore(claims []PrizesRow) error {
+	args := w.Called(claims)
+	return args.Error(0)
+}
+
 // Set mock.
 func (w *PrizesStoreMock) Set(lotteryHeight uint32, winners []Winner) error {
 	args := w.Called(lotteryHeight, winners)
@@ -31,7 +60,7 @@ func (w *PrizesStoreMock) Set(lotteryHeight uint32, winners []Winner) error {
 }
 
 // Withdraw mock.
-func (w *PrizesStoreMock) Withdraw(publicKey string, amount uint64) error {
+func (w *PrizesStoreMock) Withdraw(publicKey string, amount uint64) ([]PrizesRow, error) {
 	args := w.Called(publicKey, amount)
-	return args.Error(0)
+	return args.Get(0).([]PrizesRow), args.Error(1)
 }
@@ -2,6 +2,7 @@ package db_test
 
 import (
 	"database/sql"
+	"sync"
 	"testing"
 
 	database "github.com/aftermath2/BTRY/db"
@@ -12,7 +13,8 @@ import (
 type PrizesSuite struct {
 	suite.Suite
 
-	db database.PrizesStore
+	db      database.PrizesStore
+	winners database.WinnersStore
 }
 
 func TestPrizesSuite(t *testing.T) {
@@ -30,6 +32,7 @@ func (p *PrizesSuite) SetupTest() {
 		p.NoError(err)
 	})
 	p.db = db.Prizes
+	p.winners = db.Winners
 }
 
 func (p *PrizesSuite) TestPrizesAccumulation() {
@@ -58,6 +61,11 @@ func (p *PrizesSuite) TestExpire() {
 	p.NoError(err)
 
 	p.Zero(prizes)
+
+	// Prizes already expired are not counted again
+	expiredAmount, err = p.db.Expire(height + 1)
+	p.NoError(err)
+	p.Zero(expiredAmount)
 }
 
 func (p *PrizesSuite) TestGet() {
@@ -77,6 +85,22 @@ func (p *PrizesSuite) TestGetMany() {
 	p.Equal(testWinner2.Prize*2, prizes)
 }
 
+func (p *PrizesSuite) TestGetTotal() {
+	err := p.db.Set(lotteryHeight, []database.Winner{testWinner2})
+	p.NoError(err)
+
+	total, err := p.db.GetTotal()
+	p.NoError(err)
+	p.Equal(testWinner.Prize+testWinner2.Prize, total)
+
+	_, err = p.db.Expire(lotteryHeight)
+	p.NoError(err)
+
+	total, err = p.db.GetTotal()
+	p.NoError(err)
+	p.Zero(total)
+}
+
 func (p *PrizesSuite) TestSet() {
 	err := p.db.Set(1, []database.Winner{testWinner2, testWinner2})
 	p.NoError(err)
@@ -89,8 +113,10 @@ func (p *PrizesSuite) TestSet() {
 
 func (p *PrizesSuite) TestWithdraw() {
 	amount := uint64(20)
-	err := p.db.Withdraw(testWinner.PublicKey, amount)
+	claims, err := p.db.Withdraw(testWinner.PublicKey, amount)
 	p.NoError(err)
+	p.Len(claims, 1)
+	p.Equal(amount, claims[0].Amount)
 
 	prizes, err := p.db.Get(testWinner.PublicKey)
 	p.NoError(err)
@@ -108,8 +134,9 @@ func (p *PrizesSuite) TestWithdrawMultiplePrizes() {
 	p.NoError(err)
 
 	amount := uint64(200)
-	err = p.db.Withdraw(winner.PublicKey, amount)
+	claims, err := p.db.Withdraw(winner.PublicKey, amount)
 	p.NoError(err)
+	p.Len(claims, 2)
 
 	prizes, err := p.db.Get(winner.PublicKey)
 	p.NoError(err)
@@ -119,11 +146,120 @@ func (p *PrizesSuite) TestWithdrawMultiplePrizes() {
 
 func (p *PrizesSuite) TestWithdrawInsufficientPrizes() {
 	amount := testWinner.Prize + 125
-	err := p.db.Withdraw(testWinner.PublicKey, amount)
+	_, err := p.db.Withdraw(testWinner.PublicKey, amount)
 	p.Error(err)
 	p.ErrorIs(err, database.ErrInsufficientPrizes)
 }
 
+func (p *PrizesSuite) TestClaim() {
+	p.NoError(p.winners.Add(lotteryHeight, []database.Winner{testWinner}))
+
+	claims, err := p.db.Claim(testWinner.PublicKey, "hash", 20)
+	p.NoError(err)
+	p.Equal([]database.PrizesRow{{PaymentHash: "hash", RowID: 1, Amount: 20}}, claims)
+
+	// The payment hash was recorded on the claims
+	_, err = p.db.Claim(testWinner.PublicKey, "hash", 10)
+	p.ErrorIs(err, database.ErrInvoiceClaimed)
+
+	// Claiming the same prize again doesn't release the invoices used before
+	claims2, err := p.db.Claim(testWinner.PublicKey, "hash3", 5)
+	p.NoError(err)
+	_, err = p.db.Claim(testWinner.PublicKey, "hash", 10)
+	p.ErrorIs(err, database.ErrInvoiceClaimed)
+	p.NoError(p.db.Restore(claims2))
+
+	prizes, err := p.db.Get(testWinner.PublicKey)
+	p.NoError(err)
+	p.Equal(testWinner.Prize-20, prizes)
+
+	// The payment failed, the invoice can be used again
+	p.NoError(p.db.Restore(claims))
+	_, err = p.db.Claim(testWinner.PublicKey, "hash", 10)
+	p.NoError(err)
+
+	_, err = p.db.Claim(testWinner.PublicKey, "hash2", testWinner.Prize)
+	p.ErrorIs(err, database.ErrInsufficientPrizes)
+
+	_, err = p.db.Claim(testWinner.PublicKey, "", 10)
+	p.Error(err)
+}
+
+func (p *PrizesSuite) TestClaimConcurrent() {
+	p.NoError(p.winners.Add(lotteryHeight, []database.Winner{testWinner}))
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		claimed int
+	)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := p.db.Claim(testWinner.PublicKey, "hash", 5)
+			if err != nil {
+				p.ErrorIs(err, database.ErrInvoiceClaimed)
+				return
+			}
+			mu.Lock()
+			claimed++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	p.Equal(1, claimed)
+	prizes, err := p.db.Get(testWinner.PublicKey)
+	p.NoError(err)
+	p.Equal(testWinner.Prize-5, prizes)
+}
+
+func (p *PrizesSuite) TestPartialClaims() {
+	winner := database.Winner{
+		PublicKey: "17dc39e569bbeab0b1a1e2da5198d217c855fe5041a0b04f94030fdaf15c0bcd",
+		Prize:     100,
+	}
+	err := p.db.Set(lotteryHeight+1, []database.Winner{winner})
+	p.NoError(err)
+	err = p.db.Set(lotteryHeight, []database.Winner{winner})
+	p.NoError(err)
+
+	// The prize expiring first is claimed first
+	first, err := p.db.Withdraw(winner.PublicKey, 60)
+	p.NoError(err)
+	second, err := p.db.Withdraw(winner.PublicKey, 60)
+	p.NoError(err)
+	p.Len(second, 2)
+
+	prizes, err := p.db.List(winner.PublicKey)
+	p.NoError(err)
+	p.Equal([]database.Prize{{LotteryHeight: lotteryHeight + 1, Amount: 80}}, prizes)
+
+	// The second payment failed, its claims go back to the prizes they were taken from
+	err = p.db.Restore(second)
+	p.NoError(err)
+
+	prizes, err = p.db.List(winner.PublicKey)
+	p.NoError(err)
+	expected := []database.Prize{
+		{LotteryHeight: lotteryHeight, Amount: 40},
+		{LotteryHeight: lotteryHeight + 1, Amount: 100},
+	}
+	p.Equal(expected, prizes)
+
+	// Remainders expire with their prize
+	_, err = p.db.Expire(lotteryHeight)
+	p.NoError(err)
+
+	err = p.db.Restore(first)
+	p.NoError(err)
+
+	total, err := p.db.Get(winner.PublicKey)
+	p.NoError(err)
+	p.Equal(uint64(100), total)
+}
+
 func (p *PrizesSuite) TestUpdatePrizes() {
 	cases := []struct {
 		err            error
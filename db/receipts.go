@@ -0,0 +1,92 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// ErrReceiptNotFound is returned when there's no receipt of the bet specified.
+var ErrReceiptNotFound = errors.New("receipt not found")
+
+// ReceiptsStore contains the methods used to store and retrieve the bet receipts issued.
+type ReceiptsStore interface {
+	Add(receipt Receipt) error
+	Get(publicKey, paymentHash string) (Receipt, error)
+}
+
+// Receipt is the server's signed acknowledgement of a bet. It lets players prove they held a
+// range of tickets in a lottery without relying on the server database.
+//
+// Receipts are kept after the bets are compacted, so players can download them later on.
+type Receipt struct {
+	PublicKey   string `json:"public_key"`
+	PaymentHash string `json:"payment_hash"`
+	Signature   string `json:"signature"`
+	FirstTicket uint64 `json:"first_ticket"`
+	LastTicket  uint64 `json:"last_ticket"`
+	Timestamp   int64  `json:"timestamp"`
+	// Extra tickets included in the range, granted by the bonus bundles or the coin-age schedule
+	BonusTickets uint64 `json:"bonus_tickets,omitempty"`
+	Round        uint32 `json:"round"`
+}
+
+// ReceiptSigner returns the signed receipt of a bet.
+type ReceiptSigner func(bet Bet, paymentHash string) (Receipt, error)
+
+type receipts struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newReceiptsStore returns a new receipts storage service.
+func newReceiptsStore(db *sql.DB, logger *logger.Logger) ReceiptsStore {
+	return &receipts{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add saves a receipt, replacing the previous one of the same bet.
+func (r *receipts) Add(receipt Receipt) error {
+	query := `INSERT OR REPLACE INTO receipts (payment_hash, public_key, lottery_height, first_idx, idx,
+	signature, created_at, bonus) VALUES (?,?,?,?,?,?,?,?)`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(receipt.PaymentHash, receipt.PublicKey, receipt.Round, receipt.FirstTicket,
+		receipt.LastTicket, receipt.Signature, receipt.Timestamp, receipt.BonusTickets)
+	if err != nil {
+		return errors.Wrap(err, "adding receipt")
+	}
+
+	return nil
+}
+
+// Get returns the receipt of the bet paid with the payment hash, if it was placed by the public key.
+func (r *receipts) Get(publicKey, paymentHash string) (Receipt, error) {
+	query := `SELECT lottery_height, first_idx, idx, signature, created_at, bonus FROM receipts
+	WHERE payment_hash=? AND public_key=?`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return Receipt{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	receipt := Receipt{PublicKey: publicKey, PaymentHash: paymentHash}
+	err = stmt.QueryRow(paymentHash, publicKey).Scan(&receipt.Round, &receipt.FirstTicket,
+		&receipt.LastTicket, &receipt.Signature, &receipt.Timestamp, &receipt.BonusTickets)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Receipt{}, ErrReceiptNotFound
+		}
+		return Receipt{}, errors.Wrap(err, "getting receipt")
+	}
+
+	return receipt, nil
+}
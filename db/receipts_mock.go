@@ -0,0 +1,25 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// ReceiptsStoreMock is a mocked implementation of the receipts store.
+type ReceiptsStoreMock struct {
+	mock.Mock
+}
+
+// NewReceiptsStoreMock returns a mocked receipts store.
+func NewReceiptsStoreMock() *ReceiptsStoreMock {
+	return &ReceiptsStoreMock{}
+}
+
+// Add mock.
+func (r *ReceiptsStoreMock) Add(receipt Receipt) error {
+	args := r.Called(receipt)
+	return args.Error(0)
+}
+
+// Get mock.
+func (r *ReceiptsStoreMock) Get(publicKey, paymentHash string) (Receipt, error) {
+	args := r.Called(publicKey, paymentHash)
+	return args.Get(0).(Receipt), args.Error(1)
+}
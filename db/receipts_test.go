@@ -0,0 +1,195 @@
+package db_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReceiptsSuite struct {
+	suite.Suite
+
+	db        database.ReceiptsStore
+	bets      database.BetsStore
+	lotteries database.LotteriesStore
+}
+
+func TestReceiptsSuite(t *testing.T) {
+	suite.Run(t, &ReceiptsSuite{})
+}
+
+func (r *ReceiptsSuite) SetupTest() {
+	db := setupDB(r.T(), func(db *sql.DB) {
+		_, err := db.Exec(`INSERT INTO lotteries (height) VALUES (?)`, lotteryHeight)
+		r.NoError(err)
+	})
+	r.db = db.Receipts
+	r.bets = db.Bets
+	r.lotteries = db.Lotteries
+}
+
+func (r *ReceiptsSuite) TestAdd() {
+	receipt := database.Receipt{
+		PublicKey:    firstBet.PublicKey,
+		PaymentHash:  "hash",
+		Signature:    "signature",
+		FirstTicket:  1,
+		LastTicket:   15,
+		Timestamp:    100,
+		BonusTickets: 5,
+		Round:        lotteryHeight,
+	}
+	err := r.db.Add(receipt)
+	r.NoError(err)
+
+	got, err := r.db.Get(receipt.PublicKey, receipt.PaymentHash)
+	r.NoError(err)
+	r.Equal(receipt, got)
+
+	// Receipts are only returned to the player who placed the bet
+	_, err = r.db.Get(secondBet.PublicKey, receipt.PaymentHash)
+	r.ErrorIs(err, database.ErrReceiptNotFound)
+
+	// Signing the bet again replaces the receipt
+	receipt.Signature = "signature2"
+	err = r.db.Add(receipt)
+	r.NoError(err)
+
+	got, err = r.db.Get(receipt.PublicKey, receipt.PaymentHash)
+	r.NoError(err)
+	r.Equal("signature2", got.Signature)
+}
+
+func (r *ReceiptsSuite) TestGetNotFound() {
+	_, err := r.db.Get(firstBet.PublicKey, "hash")
+	r.ErrorIs(err, database.ErrReceiptNotFound)
+}
+
+// signReceipt returns a receipt of the bet with a fake signature.
+func signReceipt(bet database.Bet, paymentHash string) (database.Receipt, error) {
+	return database.Receipt{
+		PublicKey:    bet.PublicKey,
+		PaymentHash:  paymentHash,
+		Signature:    "reissued",
+		FirstTicket:  bet.FirstTicket,
+		LastTicket:   bet.Index,
+		BonusTickets: bet.Bonus,
+		Round:        bet.LotteryHeight,
+	}, nil
+}
+
+func (r *ReceiptsSuite) TestDeletedOnCancel() {
+	bet, err := r.bets.Add(database.Bet{
+		PublicKey:   firstBet.PublicKey,
+		Tickets:     10,
+		PaymentHash: "hash",
+		CreatedAt:   100,
+	}, 10, 0)
+	r.NoError(err)
+
+	err = r.db.Add(database.Receipt{
+		PublicKey:   bet.PublicKey,
+		PaymentHash: bet.PaymentHash,
+		FirstTicket: bet.FirstTicket,
+		LastTicket:  bet.Index,
+		Round:       bet.LotteryHeight,
+	})
+	r.NoError(err)
+
+	_, _, err = r.bets.Cancel(bet.PublicKey, bet.PaymentHash, 50, 0, signReceipt)
+	r.NoError(err)
+
+	_, err = r.db.Get(bet.PublicKey, bet.PaymentHash)
+	r.ErrorIs(err, database.ErrReceiptNotFound)
+}
+
+func (r *ReceiptsSuite) TestReissuedOnCancel() {
+	add := func(paymentHash string) database.Bet {
+		bet, err := r.bets.Add(database.Bet{
+			PublicKey:   firstBet.PublicKey,
+			Tickets:     10,
+			PaymentHash: paymentHash,
+			CreatedAt:   100,
+		}, 0, 0)
+		r.NoError(err)
+
+		err = r.db.Add(database.Receipt{
+			PublicKey:   bet.PublicKey,
+			PaymentHash: bet.PaymentHash,
+			Signature:   "signature",
+			FirstTicket: bet.FirstTicket,
+			LastTicket:  bet.Index,
+			Round:       bet.LotteryHeight,
+		})
+		r.NoError(err)
+		return bet
+	}
+	first := add("first")
+	add("second")
+	add("third")
+
+	_, _, err := r.bets.Cancel(first.PublicKey, "second", 50, 0, signReceipt)
+	r.NoError(err)
+
+	// The receipts of the bets placed before are left as they were
+	receipt, err := r.db.Get(first.PublicKey, "first")
+	r.NoError(err)
+	r.Equal("signature", receipt.Signature)
+
+	receipt, err = r.db.Get(first.PublicKey, "third")
+	r.NoError(err)
+	r.Equal("reissued", receipt.Signature)
+	r.Equal(uint64(11), receipt.FirstTicket)
+	r.Equal(uint64(20), receipt.LastTicket)
+
+	// The cancellation is rolled back if the receipts can't be signed
+	fail := func(database.Bet, string) (database.Receipt, error) {
+		return database.Receipt{}, errors.New("signing disabled")
+	}
+	_, _, err = r.bets.Cancel(first.PublicKey, "first", 50, 0, fail)
+	r.Error(err)
+
+	receipt, err = r.db.Get(first.PublicKey, "first")
+	r.NoError(err)
+	r.Equal("signature", receipt.Signature)
+}
+
+func (r *ReceiptsSuite) TestReissuedOnMove() {
+	bet, err := r.bets.Add(database.Bet{
+		PublicKey:   firstBet.PublicKey,
+		Tickets:     10,
+		PaymentHash: "hash",
+		CreatedAt:   100,
+	}, 0, 0)
+	r.NoError(err)
+
+	err = r.db.Add(database.Receipt{
+		PublicKey:   bet.PublicKey,
+		PaymentHash: bet.PaymentHash,
+		Signature:   "signature",
+		FirstTicket: bet.FirstTicket,
+		LastTicket:  bet.Index,
+		Round:       bet.LotteryHeight,
+	})
+	r.NoError(err)
+
+	toHeight := lotteryHeight + 144
+	err = r.lotteries.AddHeight(toHeight, "", "")
+	r.NoError(err)
+	existing, err := r.bets.Add(database.Bet{PublicKey: secondBet.PublicKey, Tickets: 5}, 0, 0)
+	r.NoError(err)
+
+	err = r.bets.Move(lotteryHeight, toHeight, signReceipt)
+	r.NoError(err)
+
+	got, err := r.db.Get(bet.PublicKey, bet.PaymentHash)
+	r.NoError(err)
+	r.Equal("reissued", got.Signature)
+	r.Equal(toHeight, got.Round)
+	r.Equal(existing.Index+1, got.FirstTicket)
+	r.Equal(existing.Index+bet.Tickets, got.LastTicket)
+}
@@ -0,0 +1,205 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// pruneInterval is the time between the retention pruning runs.
+const pruneInterval = 24 * time.Hour
+
+// PruneResult contains the number of records pruned by table, or that would be in a dry run.
+type PruneResult map[string]uint64
+
+// Total returns the number of records pruned.
+func (p PruneResult) Total() uint64 {
+	var total uint64
+	for _, count := range p {
+		total += count
+	}
+	return total
+}
+
+// pruneTarget is a set of records removed by the retention policy.
+type pruneTarget struct {
+	arg   any
+	table string
+	where string
+}
+
+// StartPruning removes the records outside the retention period once a day until the context is
+// cancelled.
+func (db *DB) StartPruning(ctx context.Context) {
+	if db.retention.Months == 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := db.prune(time.Now()); err != nil {
+				db.logger.Error(errors.Wrap(err, "pruning records"))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// prune applies the retention policy, exporting the records to a new file in the export directory
+// if there is one.
+func (db *DB) prune(now time.Time) error {
+	before := now.AddDate(0, -int(db.retention.Months), 0)
+
+	var (
+		export     io.Writer
+		exportPath string
+	)
+	if db.retention.ExportDir != "" && !db.retention.DryRun {
+		exportPath = filepath.Join(db.retention.ExportDir, fmt.Sprintf("btry-prune-%d.jsonl", now.Unix()))
+		f, err := os.Create(exportPath)
+		if err != nil {
+			return errors.Wrap(err, "creating export file")
+		}
+		defer f.Close()
+		export = f
+	}
+
+	result, err := db.Prune(db.retention.Blocks(), before, db.retention.DryRun, export)
+	if exportPath != "" && (err != nil || result.Total() == 0) {
+		// Don't leave empty or partial exports behind
+		os.Remove(exportPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	if db.retention.DryRun {
+		db.logger.Infof("Retention dry run, records that would be pruned: %v", result)
+		return nil
+	}
+
+	if result.Total() > 0 {
+		db.logger.Infof("Records pruned: %v", result)
+	}
+	return nil
+}
+
+// Prune deletes the bet archives, winners and expired prizes of the lotteries drawn more than
+// blocks before the latest one and the webhook deliveries created before the time specified.
+//
+// Nothing is deleted in a dry run, only counted. If export is not nil, the records are written to
+// it as JSON lines before they are deleted, the deletion is aborted if the export fails.
+func (db *DB) Prune(blocks uint32, before time.Time, dryRun bool, export io.Writer) (PruneResult, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	var latest uint32
+	if err := tx.QueryRow("SELECT COALESCE(MAX(height), 0) FROM lotteries").Scan(&latest); err != nil {
+		return nil, errors.Wrap(err, "getting latest lottery height")
+	}
+
+	targets := []pruneTarget{
+		{table: "webhook_deliveries", where: "created_at < ?", arg: before.Unix()},
+	}
+	if latest > blocks {
+		height := latest - blocks
+		targets = append(targets,
+			pruneTarget{table: "bet_archives", where: "lottery_height < ?", arg: height},
+			pruneTarget{table: "winners", where: "lottery_height < ?", arg: height},
+			pruneTarget{table: "prizes", where: "expired=1 AND lottery_height < ?", arg: height},
+		)
+	}
+
+	result := make(PruneResult, len(targets))
+	for _, target := range targets {
+		var count uint64
+		query := "SELECT COUNT(*) FROM " + target.table + " WHERE " + target.where
+		if err := tx.QueryRow(query, target.arg).Scan(&count); err != nil {
+			return nil, errors.Wrapf(err, "counting %s", target.table)
+		}
+		result[target.table] = count
+
+		if dryRun || count == 0 {
+			continue
+		}
+
+		if export != nil {
+			if err := exportRecords(tx, target, export); err != nil {
+				return nil, errors.Wrapf(err, "exporting %s", target.table)
+			}
+		}
+
+		query = "DELETE FROM " + target.table + " WHERE " + target.where
+		if _, err := tx.Exec(query, target.arg); err != nil {
+			return nil, errors.Wrapf(err, "pruning %s", target.table)
+		}
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "committing transaction")
+	}
+
+	return result, nil
+}
+
+// exportRecords writes the records of the target to w, one JSON object per line with the table
+// name and the record columns.
+func exportRecords(tx *sql.Tx, target pruneTarget, w io.Writer) error {
+	rows, err := tx.Query("SELECT * FROM "+target.table+" WHERE "+target.where, target.arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return errors.Wrap(err, "scanning rows")
+		}
+
+		record := make(map[string]any, len(columns))
+		for i, column := range columns {
+			record[column] = values[i]
+		}
+
+		line := map[string]any{"table": target.table, "record": record}
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,65 @@
+package db_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrune(t *testing.T) {
+	database := setupDB(t, func(sqlDB *sql.DB) {
+		_, err := sqlDB.Exec(`INSERT INTO lotteries (height) VALUES (100), (4500), (5000);
+		INSERT INTO bet_archives (lottery_height, bets) VALUES (100, x'00'), (4500, x'00');
+		INSERT INTO winners (public_key, prize, ticket, lottery_height) VALUES ('a', 10, 1, 100), ('b', 20, 2, 4500);
+		INSERT INTO prizes (amount, public_key, lottery_height, expired) VALUES
+			(10, 'a', 100, 1), (5, 'c', 100, 0), (20, 'b', 4500, 1);
+		INSERT INTO webhook_deliveries (webhook_id, delivery_id, event, created_at) VALUES
+			(1, 'old', 'draw', 1000), (1, 'new', 'draw', 2000000000)`)
+		assert.NoError(t, err)
+	})
+
+	before := time.Unix(1_500_000_000, 0)
+	expected := db.PruneResult{"bet_archives": 1, "winners": 1, "prizes": 1, "webhook_deliveries": 1}
+
+	result, err := database.Prune(1000, before, true, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+	assert.Equal(t, uint64(4), result.Total())
+
+	// Dry runs keep the records
+	result, err = database.Prune(1000, before, true, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+
+	var export bytes.Buffer
+	result, err = database.Prune(1000, before, false, &export)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+
+	lines := strings.Split(strings.TrimSpace(export.String()), "\n")
+	assert.Len(t, lines, 4)
+	var line struct {
+		Table  string         `json:"table"`
+		Record map[string]any `json:"record"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+	assert.Equal(t, "webhook_deliveries", line.Table)
+	assert.Equal(t, "old", line.Record["delivery_id"])
+
+	result, err = database.Prune(1000, before, true, nil)
+	assert.NoError(t, err)
+	assert.Zero(t, result.Total())
+
+	// The archive kept is found, its fake bets can't be decompressed
+	_, err = database.BetArchives.Get(4500)
+	assert.NotErrorIs(t, err, db.ErrBetArchiveNotFound)
+	_, err = database.BetArchives.Get(100)
+	assert.ErrorIs(t, err, db.ErrBetArchiveNotFound)
+}
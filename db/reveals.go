@@ -0,0 +1,174 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// ErrRevealNotFound is returned when the lottery winners were not revealed progressively.
+var ErrRevealNotFound = errors.New("reveal not found")
+
+// RevealsStore contains the methods used to keep track of the lotteries whose winners are revealed
+// one tier at a time.
+type RevealsStore interface {
+	Add(reveal Reveal) error
+	Get(lotteryHeight uint32) (Reveal, error)
+	ListPending() ([]Reveal, error)
+	SetHidden(lotteryHeight, hidden uint32) error
+}
+
+// Reveal is the progress of the reveal of a lottery winners, the results are committed to at the
+// draw with their hash.
+type Reveal struct {
+	ResultsHash string `json:"results_hash"`
+	// Salt is hashed along with the results, it's kept secret until every tier is revealed
+	Salt          string `json:"salt,omitempty"`
+	PrizePool     uint64 `json:"-"`
+	LotteryHeight uint32 `json:"lottery_height"`
+	// Tiers is the number of prizes of the largest pool
+	Tiers uint32 `json:"tiers"`
+	// Hidden is the number of top tiers that weren't revealed yet
+	Hidden uint32 `json:"hidden"`
+	// Blocks is the number of blocks after the draw the reveal lasts
+	Blocks uint32 `json:"blocks"`
+}
+
+// Disclosed returns the reveal without the salt of the results hash while any tier is hidden.
+func (r Reveal) Disclosed() Reveal {
+	if r.Hidden > 0 {
+		r.Salt = ""
+	}
+	return r
+}
+
+// Revealed returns the winners whose tiers were already revealed.
+func (r Reveal) Revealed(winners []Winner) []Winner {
+	if r.Hidden == 0 {
+		return winners
+	}
+
+	revealed := make([]Winner, 0, len(winners))
+	for _, winner := range winners {
+		if winner.Tier >= r.Hidden {
+			revealed = append(revealed, winner)
+		}
+	}
+
+	return revealed
+}
+
+type reveals struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newRevealsStore returns a new reveals storage service.
+func newRevealsStore(db *sql.DB, logger *logger.Logger) RevealsStore {
+	return &reveals{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add stores the reveal of a lottery drawn.
+func (r *reveals) Add(reveal Reveal) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	if err := addReveal(tx, reveal); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// addReveal inserts the reveal inside the transaction.
+func addReveal(tx *sql.Tx, reveal Reveal) error {
+	query := `INSERT INTO reveals (lottery_height, results_hash, salt, prize_pool, tiers, hidden, blocks)
+	VALUES (?,?,?,?,?,?,?)`
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(reveal.LotteryHeight, reveal.ResultsHash, reveal.Salt, reveal.PrizePool,
+		reveal.Tiers, reveal.Hidden, reveal.Blocks)
+	if err != nil {
+		return errors.Wrap(err, "adding reveal")
+	}
+
+	return nil
+}
+
+// Get returns the reveal of the lottery at the height specified.
+func (r *reveals) Get(lotteryHeight uint32) (Reveal, error) {
+	query := `SELECT lottery_height, results_hash, salt, prize_pool, tiers, hidden, blocks FROM reveals
+	WHERE lottery_height=?`
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return Reveal{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var reveal Reveal
+	err = stmt.QueryRow(lotteryHeight).Scan(&reveal.LotteryHeight, &reveal.ResultsHash, &reveal.Salt,
+		&reveal.PrizePool, &reveal.Tiers, &reveal.Hidden, &reveal.Blocks)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Reveal{}, ErrRevealNotFound
+		}
+		return Reveal{}, errors.Wrap(err, "getting reveal")
+	}
+
+	return reveal, nil
+}
+
+// ListPending returns the reveals that have tiers hidden, oldest first.
+func (r *reveals) ListPending() ([]Reveal, error) {
+	query := `SELECT lottery_height, results_hash, salt, prize_pool, tiers, hidden, blocks FROM reveals
+	WHERE hidden > 0 ORDER BY lottery_height`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing reveals")
+	}
+	defer rows.Close()
+
+	var reveals []Reveal
+	for rows.Next() {
+		var reveal Reveal
+		err := rows.Scan(&reveal.LotteryHeight, &reveal.ResultsHash, &reveal.Salt, &reveal.PrizePool,
+			&reveal.Tiers, &reveal.Hidden, &reveal.Blocks)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+		reveals = append(reveals, reveal)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating rows")
+	}
+
+	return reveals, nil
+}
+
+// SetHidden updates the number of tiers of the lottery that are still hidden.
+func (r *reveals) SetHidden(lotteryHeight, hidden uint32) error {
+	stmt, err := r.db.Prepare("UPDATE reveals SET hidden=? WHERE lottery_height=?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(hidden, lotteryHeight); err != nil {
+		return errors.Wrap(err, "updating reveal")
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// RevealsStoreMock is a mocked implementation of the reveals store.
+type RevealsStoreMock struct {
+	mock.Mock
+}
+
+// NewRevealsStoreMock returns a mocked reveals store.
+func NewRevealsStoreMock() *RevealsStoreMock {
+	return &RevealsStoreMock{}
+}
+
+// Add mock.
+func (m *RevealsStoreMock) Add(reveal Reveal) error {
+	args := m.Called(reveal)
+	return args.Error(0)
+}
+
+// Get mock.
+func (m *RevealsStoreMock) Get(lotteryHeight uint32) (Reveal, error) {
+	args := m.Called(lotteryHeight)
+	return args.Get(0).(Reveal), args.Error(1)
+}
+
+// ListPending mock.
+func (m *RevealsStoreMock) ListPending() ([]Reveal, error) {
+	args := m.Called()
+	var r0 []Reveal
+	if v := args.Get(0); v != nil {
+		r0 = v.([]Reveal)
+	}
+	return r0, args.Error(1)
+}
+
+// SetHidden mock.
+func (m *RevealsStoreMock) SetHidden(lotteryHeight, hidden uint32) error {
+	args := m.Called(lotteryHeight, hidden)
+	return args.Error(0)
+}
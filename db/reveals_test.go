@@ -0,0 +1,85 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RevealsSuite struct {
+	suite.Suite
+
+	db *database.DB
+}
+
+func TestRevealsSuite(t *testing.T) {
+	suite.Run(t, &RevealsSuite{})
+}
+
+func (s *RevealsSuite) SetupTest() {
+	s.db = setupDB(s.T(), func(db *sql.DB) {})
+}
+
+func (s *RevealsSuite) TestAdd() {
+	reveal := database.Reveal{
+		LotteryHeight: 144,
+		ResultsHash:   "hash",
+		Salt:          "salt",
+		PrizePool:     10_000,
+		Tiers:         8,
+		Hidden:        8,
+		Blocks:        6,
+	}
+	s.NoError(s.db.Reveals.Add(reveal))
+
+	got, err := s.db.Reveals.Get(144)
+	s.NoError(err)
+	s.Equal(reveal, got)
+
+	s.Error(s.db.Reveals.Add(reveal))
+}
+
+func (s *RevealsSuite) TestGetNotFound() {
+	_, err := s.db.Reveals.Get(144)
+	s.ErrorIs(err, database.ErrRevealNotFound)
+}
+
+func (s *RevealsSuite) TestListPending() {
+	s.NoError(s.db.Reveals.Add(database.Reveal{LotteryHeight: 288, Tiers: 8, Hidden: 8, Blocks: 6}))
+	s.NoError(s.db.Reveals.Add(database.Reveal{LotteryHeight: 144, Tiers: 8, Hidden: 3, Blocks: 6}))
+	s.NoError(s.db.Reveals.Add(database.Reveal{LotteryHeight: 432, Tiers: 8, Hidden: 8, Blocks: 6}))
+
+	s.NoError(s.db.Reveals.SetHidden(432, 0))
+
+	reveals, err := s.db.Reveals.ListPending()
+	s.NoError(err)
+	s.Len(reveals, 2)
+	s.Equal(uint32(144), reveals[0].LotteryHeight)
+	s.Equal(uint32(3), reveals[0].Hidden)
+	s.Equal(uint32(288), reveals[1].LotteryHeight)
+}
+
+func (s *RevealsSuite) TestRevealed() {
+	winners := []database.Winner{
+		{PublicKey: "first", Tier: 0},
+		{PublicKey: "second", Tier: 1},
+		{PublicKey: "third", Tier: 2},
+	}
+
+	reveal := database.Reveal{Tiers: 3, Hidden: 2}
+	s.Equal(winners[2:], reveal.Revealed(winners))
+
+	reveal.Hidden = 0
+	s.Equal(winners, reveal.Revealed(winners))
+}
+
+func (s *RevealsSuite) TestDisclosed() {
+	reveal := database.Reveal{ResultsHash: "hash", Salt: "salt", Tiers: 3, Hidden: 1}
+	s.Empty(reveal.Disclosed().Salt)
+
+	reveal.Hidden = 0
+	s.Equal(reveal, reveal.Disclosed())
+}
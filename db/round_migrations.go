@@ -0,0 +1,242 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidMigrationToken is returned when the migration token doesn't exist, it expired or it
+	// was already used.
+	ErrInvalidMigrationToken = errors.New("invalid migration token")
+	// ErrLotteryDrawn is returned when exporting or storing the results of a lottery that was
+	// already drawn.
+	ErrLotteryDrawn = errors.New("lottery already drawn")
+	// ErrRoundNotEmpty is returned when importing a round into a lottery that already has bets.
+	ErrRoundNotEmpty = errors.New("the lottery already has bets")
+)
+
+// RoundMigrationsStore contains the methods used to move a lottery that hasn't been drawn yet
+// between instances, so the hardware can be replaced mid-round without refunding the players.
+type RoundMigrationsStore interface {
+	AddToken(tokenHash string, expiresAt int64) error
+	Export(height uint32) (RoundExport, error)
+	Import(round RoundExport, tokenHash string, now int64) error
+}
+
+// RoundExport is the state of a lottery that hasn't been drawn yet: its server seed, the bets
+// placed and the channel peers they were received through.
+type RoundExport struct {
+	Commitment string             `json:"commitment"`
+	Seed       string             `json:"seed"`
+	Bets       []MigratedBet      `json:"bets"`
+	Exposure   []MigratedExposure `json:"exposure"`
+	Height     uint32             `json:"height"`
+}
+
+// MigratedBet is a bet with the fields that are not public.
+type MigratedBet struct {
+	PublicKey   string `json:"public_key"`
+	Pool        string `json:"pool"`
+	PaymentHash string `json:"payment_hash"`
+	Promo       string `json:"promo"`
+	FirstTicket uint64 `json:"first_ticket"`
+	Index       uint64 `json:"index"`
+	Tickets     uint64 `json:"tickets"`
+	Bonus       uint64 `json:"bonus"`
+	House       bool   `json:"house"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+// MigratedExposure is the amount of a bet received through a channel peer.
+type MigratedExposure struct {
+	PaymentHash string `json:"payment_hash"`
+	Peer        string `json:"peer"`
+	Amount      uint64 `json:"amount"`
+}
+
+type roundMigrations struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newRoundMigrationsStore returns a new round migrations storage service.
+func newRoundMigrationsStore(db *sql.DB, logger *logger.Logger) RoundMigrationsStore {
+	return &roundMigrations{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// AddToken stores the hash of a one-time token that authorizes importing a round until expiresAt.
+func (m *roundMigrations) AddToken(tokenHash string, expiresAt int64) error {
+	stmt, err := m.db.Prepare("INSERT INTO migration_tokens (token_hash, expires_at) VALUES (?, ?)")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(tokenHash, expiresAt); err != nil {
+		return errors.Wrap(err, "adding migration token")
+	}
+
+	return nil
+}
+
+// Export returns the state of the lottery at the height specified.
+func (m *roundMigrations) Export(height uint32) (RoundExport, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return RoundExport{}, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	round := RoundExport{Height: height}
+	var blockHash string
+	query := "SELECT seed, commitment, block_hash FROM lotteries WHERE height=?"
+	err = tx.QueryRow(query, height).Scan(&round.Seed, &round.Commitment, &blockHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return RoundExport{}, ErrLotteryNotFound
+		}
+		return RoundExport{}, errors.Wrap(err, "getting lottery")
+	}
+
+	if blockHash != "" {
+		return RoundExport{}, ErrLotteryDrawn
+	}
+
+	round.Bets, err = exportBets(tx, height)
+	if err != nil {
+		return RoundExport{}, err
+	}
+
+	round.Exposure, err = exportExposure(tx, height)
+	if err != nil {
+		return RoundExport{}, err
+	}
+
+	return round, nil
+}
+
+// Import stores the round exported by another instance, consuming the migration token. The server
+// seed replaces the one of the lottery at the same height, if it exists, so the commitment already
+// published remains valid.
+func (m *roundMigrations) Import(round RoundExport, tokenHash string, now int64) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE migration_tokens SET used_at=? WHERE token_hash=? AND used_at=0 AND expires_at>?"
+	result, err := tx.Exec(query, now, tokenHash, now)
+	if err != nil {
+		return errors.Wrap(err, "using migration token")
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return ErrInvalidMigrationToken
+	}
+
+	var bets uint64
+	query = "SELECT COUNT(*) FROM bets WHERE lottery_height=?"
+	if err := tx.QueryRow(query, round.Height).Scan(&bets); err != nil {
+		return errors.Wrap(err, "counting bets")
+	}
+	if bets > 0 {
+		return ErrRoundNotEmpty
+	}
+
+	query = `INSERT INTO lotteries (height, seed, commitment) VALUES (?, ?, ?)
+	ON CONFLICT (height) DO UPDATE SET seed=excluded.seed, commitment=excluded.commitment`
+	if _, err := tx.Exec(query, round.Height, round.Seed, round.Commitment); err != nil {
+		return errors.Wrap(err, "storing lottery")
+	}
+
+	betStmt, err := tx.Prepare(`INSERT INTO bets (first_idx, idx, tickets, bonus, public_key,
+	lottery_height, pool, payment_hash, promo, house, created_at) VALUES (?,?,?,?,?,?,?,?,?,?,?)`)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer betStmt.Close()
+
+	for _, bet := range round.Bets {
+		_, err := betStmt.Exec(bet.FirstTicket, bet.Index, bet.Tickets, bet.Bonus, bet.PublicKey,
+			round.Height, bet.Pool, bet.PaymentHash, bet.Promo, bet.House, bet.CreatedAt)
+		if err != nil {
+			return errors.Wrap(err, "adding bet")
+		}
+	}
+
+	exposureStmt, err := tx.Prepare(`INSERT INTO exposure (payment_hash, peer_public_key, amount,
+	lottery_height) VALUES (?,?,?,?)`)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer exposureStmt.Close()
+
+	for _, e := range round.Exposure {
+		if _, err := exposureStmt.Exec(e.PaymentHash, e.Peer, e.Amount, round.Height); err != nil {
+			return errors.Wrap(err, "adding exposure")
+		}
+	}
+
+	return tx.Commit()
+}
+
+func exportBets(tx *sql.Tx, height uint32) ([]MigratedBet, error) {
+	rows, err := tx.Query(`SELECT public_key, pool, payment_hash, promo, first_idx, idx, tickets,
+	bonus, house, created_at FROM bets WHERE lottery_height=? ORDER BY pool, first_idx`, height)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing bets")
+	}
+	defer rows.Close()
+
+	bets := make([]MigratedBet, 0)
+	// Reuse object
+	var bet MigratedBet
+	for rows.Next() {
+		err := rows.Scan(&bet.PublicKey, &bet.Pool, &bet.PaymentHash, &bet.Promo, &bet.FirstTicket,
+			&bet.Index, &bet.Tickets, &bet.Bonus, &bet.House, &bet.CreatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		bets = append(bets, bet)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating bets")
+	}
+
+	return bets, nil
+}
+
+func exportExposure(tx *sql.Tx, height uint32) ([]MigratedExposure, error) {
+	rows, err := tx.Query(`SELECT payment_hash, peer_public_key, amount FROM exposure
+	WHERE lottery_height=? ORDER BY payment_hash, peer_public_key`, height)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing exposure")
+	}
+	defer rows.Close()
+
+	exposure := make([]MigratedExposure, 0)
+	// Reuse object
+	var e MigratedExposure
+	for rows.Next() {
+		if err := rows.Scan(&e.PaymentHash, &e.Peer, &e.Amount); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		exposure = append(exposure, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating exposure")
+	}
+
+	return exposure, nil
+}
@@ -0,0 +1,31 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// RoundMigrationsStoreMock is a mocked implementation of the round migrations store.
+type RoundMigrationsStoreMock struct {
+	mock.Mock
+}
+
+// NewRoundMigrationsStoreMock returns a mocked round migrations store.
+func NewRoundMigrationsStoreMock() *RoundMigrationsStoreMock {
+	return &RoundMigrationsStoreMock{}
+}
+
+// AddToken mock.
+func (m *RoundMigrationsStoreMock) AddToken(tokenHash string, expiresAt int64) error {
+	args := m.Called(tokenHash, expiresAt)
+	return args.Error(0)
+}
+
+// Export mock.
+func (m *RoundMigrationsStoreMock) Export(height uint32) (RoundExport, error) {
+	args := m.Called(height)
+	return args.Get(0).(RoundExport), args.Error(1)
+}
+
+// Import mock.
+func (m *RoundMigrationsStoreMock) Import(round RoundExport, tokenHash string, now int64) error {
+	args := m.Called(round, tokenHash, now)
+	return args.Error(0)
+}
@@ -0,0 +1,88 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RoundMigrationsSuite struct {
+	suite.Suite
+
+	source      *database.DB
+	destination *database.DB
+}
+
+func TestRoundMigrationsSuite(t *testing.T) {
+	suite.Run(t, &RoundMigrationsSuite{})
+}
+
+func (s *RoundMigrationsSuite) SetupTest() {
+	s.source = setupDB(s.T(), func(db *sql.DB) {
+		_, err := db.Exec("INSERT INTO lotteries (height, seed, commitment) VALUES (?, 'seed', 'commitment')",
+			lotteryHeight)
+		s.NoError(err)
+	})
+	s.destination = setupDB(s.T(), func(db *sql.DB) {
+		_, err := db.Exec("INSERT INTO lotteries (height, seed, commitment) VALUES (?, 'other', 'other')",
+			lotteryHeight)
+		s.NoError(err)
+	})
+}
+
+func (s *RoundMigrationsSuite) TestMigrate() {
+	_, err := s.source.Bets.Add(database.Bet{PublicKey: "1", Tickets: 100, PaymentHash: "hash1", CreatedAt: 10}, 0, 0)
+	s.NoError(err)
+	_, err = s.source.Bets.Add(database.Bet{PublicKey: "2", Tickets: 50, PaymentHash: "hash2", CreatedAt: 20}, 0, 0)
+	s.NoError(err)
+	err = s.source.Exposure.Add(lotteryHeight, "hash1", map[string]uint64{"peer": 100})
+	s.NoError(err)
+
+	round, err := s.source.Migrations.Export(lotteryHeight)
+	s.NoError(err)
+	s.Equal("seed", round.Seed)
+	s.Len(round.Bets, 2)
+	s.Equal(uint64(101), round.Bets[1].FirstTicket)
+	s.Equal([]database.MigratedExposure{{PaymentHash: "hash1", Peer: "peer", Amount: 100}}, round.Exposure)
+
+	s.NoError(s.destination.Migrations.AddToken("token", 100))
+
+	err = s.destination.Migrations.Import(round, "unknown", 50)
+	s.ErrorIs(err, database.ErrInvalidMigrationToken)
+
+	// Expired
+	err = s.destination.Migrations.Import(round, "token", 100)
+	s.ErrorIs(err, database.ErrInvalidMigrationToken)
+
+	err = s.destination.Migrations.Import(round, "token", 50)
+	s.NoError(err)
+
+	commitment, err := s.destination.Lotteries.GetCommitment(lotteryHeight)
+	s.NoError(err)
+	s.Equal("seed", commitment.Seed)
+	s.Equal("commitment", commitment.Commitment)
+
+	imported, err := s.destination.Migrations.Export(lotteryHeight)
+	s.NoError(err)
+	s.Equal(round, imported)
+
+	// The token is used once
+	err = s.destination.Migrations.Import(round, "token", 60)
+	s.ErrorIs(err, database.ErrInvalidMigrationToken)
+
+	s.NoError(s.destination.Migrations.AddToken("token2", 100))
+	err = s.destination.Migrations.Import(round, "token2", 60)
+	s.ErrorIs(err, database.ErrRoundNotEmpty)
+}
+
+func (s *RoundMigrationsSuite) TestExportErrors() {
+	_, err := s.source.Migrations.Export(lotteryHeight + 1)
+	s.ErrorIs(err, database.ErrLotteryNotFound)
+
+	s.NoError(s.source.Lotteries.SetDraw(lotteryHeight, "block_hash", ""))
+	_, err = s.source.Migrations.Export(lotteryHeight)
+	s.ErrorIs(err, database.ErrLotteryDrawn)
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// ScheduledPayoutsStore contains the methods used to store and retrieve the automatic payouts
+// deferred until routing fees go down.
+type ScheduledPayoutsStore interface {
+	Add(payout ScheduledPayout) (uint64, error)
+	Delete(id uint64) error
+	List() ([]ScheduledPayout, error)
+	Update(id uint64, estimatedFee, checkedAt int64) error
+}
+
+// ScheduledPayout represents an automatic payout waiting for cheaper routing fees.
+type ScheduledPayout struct {
+	PublicKey string `json:"public_key"`
+	ID        uint64 `json:"id"`
+	Amount    uint64 `json:"amount"`
+	// EstimatedFee is the last routing fee estimated, in satoshis
+	EstimatedFee int64 `json:"estimated_fee"`
+	// Checks is the number of times the fee was estimated
+	Checks    uint64 `json:"checks"`
+	CreatedAt int64  `json:"created_at"`
+	CheckedAt int64  `json:"checked_at"`
+	// Deadline is the block height at which the prizes expire
+	Deadline uint32 `json:"deadline"`
+}
+
+type scheduledPayouts struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newScheduledPayoutsStore returns a new scheduled payouts storage service.
+func newScheduledPayoutsStore(db *sql.DB, logger *logger.Logger) ScheduledPayoutsStore {
+	return &scheduledPayouts{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add schedules a payout and returns its identifier.
+func (s *scheduledPayouts) Add(payout ScheduledPayout) (uint64, error) {
+	query := `INSERT INTO scheduled_payouts
+	(public_key, amount, deadline, estimated_fee, created_at, checked_at) VALUES (?,?,?,?,?,?)`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(payout.PublicKey, payout.Amount, payout.Deadline, payout.EstimatedFee,
+		payout.CreatedAt, payout.CreatedAt)
+	if err != nil {
+		return 0, errors.Wrap(err, "adding scheduled payout")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, errors.Wrap(err, "getting scheduled payout identifier")
+	}
+
+	return uint64(id), nil
+}
+
+// Delete removes a scheduled payout.
+func (s *scheduledPayouts) Delete(id uint64) error {
+	stmt, err := s.db.Prepare("DELETE FROM scheduled_payouts WHERE id=?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(id); err != nil {
+		return errors.Wrap(err, "deleting scheduled payout")
+	}
+
+	return nil
+}
+
+// List returns the scheduled payouts, the closest to expire first.
+func (s *scheduledPayouts) List() ([]ScheduledPayout, error) {
+	query := `SELECT id, public_key, amount, deadline, estimated_fee, checks, created_at, checked_at
+	FROM scheduled_payouts ORDER BY deadline, id`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing scheduled payouts")
+	}
+	defer rows.Close()
+
+	var payouts []ScheduledPayout
+	// Reuse object
+	var payout ScheduledPayout
+	for rows.Next() {
+		err := rows.Scan(&payout.ID, &payout.PublicKey, &payout.Amount, &payout.Deadline,
+			&payout.EstimatedFee, &payout.Checks, &payout.CreatedAt, &payout.CheckedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		payouts = append(payouts, payout)
+	}
+
+	return payouts, nil
+}
+
+// Update records a new fee estimation of a scheduled payout.
+func (s *scheduledPayouts) Update(id uint64, estimatedFee, checkedAt int64) error {
+	query := `UPDATE scheduled_payouts SET estimated_fee=?, checked_at=?, checks=checks+1 WHERE id=?`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(estimatedFee, checkedAt, id); err != nil {
+		return errors.Wrap(err, "updating scheduled payout")
+	}
+
+	return nil
+}
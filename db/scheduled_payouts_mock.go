@@ -0,0 +1,37 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// ScheduledPayoutsStoreMock is a mocked implementation of the scheduled payouts store.
+type ScheduledPayoutsStoreMock struct {
+	mock.Mock
+}
+
+// NewScheduledPayoutsStoreMock returns a mocked scheduled payouts store.
+func NewScheduledPayoutsStoreMock() *ScheduledPayoutsStoreMock {
+	return &ScheduledPayoutsStoreMock{}
+}
+
+// Add mock.
+func (s *ScheduledPayoutsStoreMock) Add(payout ScheduledPayout) (uint64, error) {
+	args := s.Called(payout)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+// Delete mock.
+func (s *ScheduledPayoutsStoreMock) Delete(id uint64) error {
+	args := s.Called(id)
+	return args.Error(0)
+}
+
+// List mock.
+func (s *ScheduledPayoutsStoreMock) List() ([]ScheduledPayout, error) {
+	args := s.Called()
+	return args.Get(0).([]ScheduledPayout), args.Error(1)
+}
+
+// Update mock.
+func (s *ScheduledPayoutsStoreMock) Update(id uint64, estimatedFee, checkedAt int64) error {
+	args := s.Called(id, estimatedFee, checkedAt)
+	return args.Error(0)
+}
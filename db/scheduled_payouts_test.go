@@ -0,0 +1,78 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ScheduledPayoutsSuite struct {
+	suite.Suite
+
+	db *database.DB
+}
+
+func TestScheduledPayoutsSuite(t *testing.T) {
+	suite.Run(t, &ScheduledPayoutsSuite{})
+}
+
+func (s *ScheduledPayoutsSuite) SetupTest() {
+	s.db = setupDB(s.T(), func(db *sql.DB) {})
+}
+
+func (s *ScheduledPayoutsSuite) TestScheduledPayouts() {
+	first, err := s.db.Scheduled.Add(database.ScheduledPayout{
+		PublicKey:    "a",
+		Amount:       1_000,
+		Deadline:     500,
+		EstimatedFee: 40,
+		CreatedAt:    1231006505,
+	})
+	s.NoError(err)
+	second, err := s.db.Scheduled.Add(database.ScheduledPayout{
+		PublicKey:    "b",
+		Amount:       2_000,
+		Deadline:     300,
+		EstimatedFee: 60,
+		CreatedAt:    1231006505,
+	})
+	s.NoError(err)
+
+	s.NoError(s.db.Scheduled.Update(first, 20, 1231007105))
+
+	payouts, err := s.db.Scheduled.List()
+	s.NoError(err)
+	expected := []database.ScheduledPayout{
+		{
+			ID:           second,
+			PublicKey:    "b",
+			Amount:       2_000,
+			Deadline:     300,
+			EstimatedFee: 60,
+			Checks:       1,
+			CreatedAt:    1231006505,
+			CheckedAt:    1231006505,
+		},
+		{
+			ID:           first,
+			PublicKey:    "a",
+			Amount:       1_000,
+			Deadline:     500,
+			EstimatedFee: 20,
+			Checks:       2,
+			CreatedAt:    1231006505,
+			CheckedAt:    1231007105,
+		},
+	}
+	s.Equal(expected, payouts)
+
+	s.NoError(s.db.Scheduled.Delete(second))
+
+	payouts, err = s.db.Scheduled.List()
+	s.NoError(err)
+	s.Len(payouts, 1)
+	s.Equal(first, payouts[0].ID)
+}
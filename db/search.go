@@ -0,0 +1,340 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// maxSearchKeys is the maximum number of public keys a prefix can match, the records of more keys
+// would be too many to be useful.
+const maxSearchKeys = 10
+
+// SearchStore contains the methods used to find the records related to a player, so the operators
+// don't have to query each table when investigating a support request.
+type SearchStore interface {
+	Search(prefix, paymentHash string, limit uint64) (SearchResult, error)
+}
+
+// SearchResult contains the records related to the public keys matched by a search.
+type SearchResult struct {
+	PublicKeys []string          `json:"public_keys"`
+	Bets       []SearchBet       `json:"bets"`
+	Invoices   []Invoice         `json:"invoices"`
+	Winners    []SearchWinner    `json:"winners"`
+	Approvals  []Approval        `json:"approvals"`
+	Scheduled  []ScheduledPayout `json:"scheduled_payouts"`
+	// Audit contains the audit log entries mentioning the public keys or the payment hash
+	Audit []AuditEntry `json:"audit"`
+}
+
+// SearchBet is a bet with the fields that are not public.
+type SearchBet struct {
+	PublicKey     string `json:"public_key"`
+	Pool          string `json:"pool"`
+	PaymentHash   string `json:"payment_hash"`
+	Promo         string `json:"promo"`
+	Index         uint64 `json:"index"`
+	Tickets       uint64 `json:"tickets"`
+	Bonus         uint64 `json:"bonus"`
+	CreatedAt     int64  `json:"created_at"`
+	LotteryHeight uint32 `json:"lottery_height"`
+}
+
+// SearchWinner is a prize won in a lottery.
+type SearchWinner struct {
+	PublicKey     string `json:"public_key"`
+	Pool          string `json:"pool"`
+	Prize         uint64 `json:"prize"`
+	Ticket        uint64 `json:"ticket"`
+	LotteryHeight uint32 `json:"lottery_height"`
+	ClaimDeadline uint32 `json:"claim_deadline"`
+}
+
+type search struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newSearchStore returns a new search storage service.
+func newSearchStore(db *sql.DB, logger *logger.Logger) SearchStore {
+	return &search{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Search returns the records of the public keys starting with prefix and of the owners of the
+// payment hash, both are optional. At most limit records of each kind are returned, newest first.
+func (s *search) Search(prefix, paymentHash string, limit uint64) (SearchResult, error) {
+	// Cap limit to avoid creating slices with too big capacity
+	if limit == 0 || limit > 500 {
+		limit = 500
+	}
+
+	publicKeys, err := s.publicKeys(prefix, paymentHash)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	result := SearchResult{PublicKeys: publicKeys}
+	if len(publicKeys) == 0 {
+		if paymentHash == "" {
+			return result, nil
+		}
+		// The payment may only be mentioned in the audit log, like a payout sent on-chain
+		result.Audit, err = s.audit(nil, paymentHash, limit)
+		return result, err
+	}
+
+	if result.Bets, err = s.bets(publicKeys, limit); err != nil {
+		return SearchResult{}, err
+	}
+	if result.Invoices, err = s.invoices(publicKeys, limit); err != nil {
+		return SearchResult{}, err
+	}
+	if result.Winners, err = s.winners(publicKeys, limit); err != nil {
+		return SearchResult{}, err
+	}
+	if result.Approvals, err = s.approvals(publicKeys, limit); err != nil {
+		return SearchResult{}, err
+	}
+	if result.Scheduled, err = s.scheduled(publicKeys, limit); err != nil {
+		return SearchResult{}, err
+	}
+	if result.Audit, err = s.audit(publicKeys, paymentHash, limit); err != nil {
+		return SearchResult{}, err
+	}
+
+	return result, nil
+}
+
+// publicKeys returns the public keys starting with prefix that have any record and the ones that
+// own the payment hash.
+func (s *search) publicKeys(prefix, paymentHash string) ([]string, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	if prefix != "" {
+		conditions = append(conditions, "public_key LIKE ? || '%'")
+		args = append(args, prefix)
+	}
+	if paymentHash != "" {
+		conditions = append(conditions, "payment_hash = ?")
+		args = append(args, paymentHash)
+	}
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+
+	query := `SELECT DISTINCT public_key FROM (
+		SELECT public_key, payment_hash FROM bets
+		UNION ALL SELECT public_key, payment_hash FROM invoices
+		UNION ALL SELECT public_key, payment_hash FROM approvals
+		UNION ALL SELECT public_key, '' FROM winners
+		UNION ALL SELECT public_key, '' FROM scheduled_payouts
+	) WHERE ` + strings.Join(conditions, " OR ") + " ORDER BY public_key LIMIT ?"
+	args = append(args, maxSearchKeys)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching public keys")
+	}
+	defer rows.Close()
+
+	var publicKeys []string
+	for rows.Next() {
+		var publicKey string
+		if err := rows.Scan(&publicKey); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating rows")
+	}
+
+	return publicKeys, nil
+}
+
+func (s *search) bets(publicKeys []string, limit uint64) ([]SearchBet, error) {
+	query := `SELECT public_key, pool, payment_hash, promo, idx, tickets, bonus, created_at,
+	lottery_height FROM bets WHERE public_key IN (` + placeholders(len(publicKeys)) + `)
+	ORDER BY lottery_height DESC, idx DESC LIMIT ?`
+
+	rows, err := s.db.Query(query, searchArgs(publicKeys, limit)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching bets")
+	}
+	defer rows.Close()
+
+	var bets []SearchBet
+	for rows.Next() {
+		var bet SearchBet
+		err := rows.Scan(&bet.PublicKey, &bet.Pool, &bet.PaymentHash, &bet.Promo, &bet.Index,
+			&bet.Tickets, &bet.Bonus, &bet.CreatedAt, &bet.LotteryHeight)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+		bets = append(bets, bet)
+	}
+
+	return bets, errors.Wrap(rows.Err(), "iterating rows")
+}
+
+func (s *search) invoices(publicKeys []string, limit uint64) ([]Invoice, error) {
+	query := `SELECT payment_hash, public_key, status, amount, created_at, expires_at FROM invoices
+	WHERE public_key IN (` + placeholders(len(publicKeys)) + `) ORDER BY created_at DESC LIMIT ?`
+
+	rows, err := s.db.Query(query, searchArgs(publicKeys, limit)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching invoices")
+	}
+	defer rows.Close()
+
+	var invoices []Invoice
+	for rows.Next() {
+		var invoice Invoice
+		err := rows.Scan(&invoice.PaymentHash, &invoice.PublicKey, &invoice.Status, &invoice.Amount,
+			&invoice.CreatedAt, &invoice.ExpiresAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+		invoices = append(invoices, invoice)
+	}
+
+	return invoices, errors.Wrap(rows.Err(), "iterating rows")
+}
+
+func (s *search) winners(publicKeys []string, limit uint64) ([]SearchWinner, error) {
+	query := `SELECT public_key, pool, prize, ticket, lottery_height, claim_deadline FROM winners
+	WHERE public_key IN (` + placeholders(len(publicKeys)) + `) ORDER BY lottery_height DESC LIMIT ?`
+
+	rows, err := s.db.Query(query, searchArgs(publicKeys, limit)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching winners")
+	}
+	defer rows.Close()
+
+	var winners []SearchWinner
+	for rows.Next() {
+		var winner SearchWinner
+		err := rows.Scan(&winner.PublicKey, &winner.Pool, &winner.Prize, &winner.Ticket,
+			&winner.LotteryHeight, &winner.ClaimDeadline)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+		winners = append(winners, winner)
+	}
+
+	return winners, errors.Wrap(rows.Err(), "iterating rows")
+}
+
+func (s *search) approvals(publicKeys []string, limit uint64) ([]Approval, error) {
+	query := `SELECT id, public_key, payment_request, payment_hash, amount, fee, created_at,
+	expires_at FROM approvals WHERE public_key IN (` + placeholders(len(publicKeys)) + `)
+	ORDER BY id DESC LIMIT ?`
+
+	rows, err := s.db.Query(query, searchArgs(publicKeys, limit)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching approvals")
+	}
+	defer rows.Close()
+
+	var approvals []Approval
+	for rows.Next() {
+		var approval Approval
+		err := rows.Scan(&approval.ID, &approval.PublicKey, &approval.PaymentRequest,
+			&approval.PaymentHash, &approval.Amount, &approval.Fee, &approval.CreatedAt,
+			&approval.ExpiresAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+		approvals = append(approvals, approval)
+	}
+
+	return approvals, errors.Wrap(rows.Err(), "iterating rows")
+}
+
+func (s *search) scheduled(publicKeys []string, limit uint64) ([]ScheduledPayout, error) {
+	query := `SELECT id, public_key, amount, deadline, estimated_fee, checks, created_at, checked_at
+	FROM scheduled_payouts WHERE public_key IN (` + placeholders(len(publicKeys)) + `)
+	ORDER BY id DESC LIMIT ?`
+
+	rows, err := s.db.Query(query, searchArgs(publicKeys, limit)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching scheduled payouts")
+	}
+	defer rows.Close()
+
+	var payouts []ScheduledPayout
+	for rows.Next() {
+		var payout ScheduledPayout
+		err := rows.Scan(&payout.ID, &payout.PublicKey, &payout.Amount, &payout.Deadline,
+			&payout.EstimatedFee, &payout.Checks, &payout.CreatedAt, &payout.CheckedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+		payouts = append(payouts, payout)
+	}
+
+	return payouts, errors.Wrap(rows.Err(), "iterating rows")
+}
+
+// audit returns the entries whose data contains any of the public keys or the payment hash.
+func (s *search) audit(publicKeys []string, paymentHash string, limit uint64) ([]AuditEntry, error) {
+	terms := publicKeys
+	if paymentHash != "" {
+		terms = append(terms[:len(terms):len(terms)], paymentHash)
+	}
+
+	conditions := make([]string, 0, len(terms))
+	args := make([]any, 0, len(terms)+1)
+	for _, term := range terms {
+		// The terms are hex encoded, they can't contain the LIKE wildcards
+		conditions = append(conditions, "data LIKE '%' || ? || '%'")
+		args = append(args, term)
+	}
+	args = append(args, limit)
+
+	query := "SELECT id, timestamp, event, data, prev_hash, hash, signature FROM audit WHERE " +
+		strings.Join(conditions, " OR ") + " ORDER BY id DESC LIMIT ?"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching audit entries")
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		err := rows.Scan(&entry.ID, &entry.Timestamp, &entry.Event, &entry.Data, &entry.PrevHash,
+			&entry.Hash, &entry.Signature)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, errors.Wrap(rows.Err(), "iterating rows")
+}
+
+// placeholders returns n comma separated query placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// searchArgs returns the query arguments of the public keys followed by the limit.
+func searchArgs(publicKeys []string, limit uint64) []any {
+	args := make([]any, 0, len(publicKeys)+1)
+	for _, publicKey := range publicKeys {
+		args = append(args, publicKey)
+	}
+	return append(args, limit)
+}
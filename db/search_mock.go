@@ -0,0 +1,19 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// SearchStoreMock is a mocked implementation of the search store.
+type SearchStoreMock struct {
+	mock.Mock
+}
+
+// NewSearchStoreMock returns a mocked search store.
+func NewSearchStoreMock() *SearchStoreMock {
+	return &SearchStoreMock{}
+}
+
+// Search mock.
+func (m *SearchStoreMock) Search(prefix, paymentHash string, limit uint64) (SearchResult, error) {
+	args := m.Called(prefix, paymentHash, limit)
+	return args.Get(0).(SearchResult), args.Error(1)
+}
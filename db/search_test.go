@@ -0,0 +1,88 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SearchSuite struct {
+	suite.Suite
+
+	db *database.DB
+}
+
+func TestSearchSuite(t *testing.T) {
+	suite.Run(t, &SearchSuite{})
+}
+
+func (s *SearchSuite) SetupTest() {
+	s.db = setupDB(s.T(), func(db *sql.DB) {
+		_, err := db.Exec("INSERT INTO lotteries (height) VALUES (?)", lotteryHeight)
+		s.NoError(err)
+	})
+
+	_, err := s.db.Bets.Add(database.Bet{PublicKey: testWinner.PublicKey, Tickets: 10, PaymentHash: "aa01"}, 0, 0)
+	s.NoError(err)
+	_, err = s.db.Bets.Add(database.Bet{PublicKey: testWinner2.PublicKey, Tickets: 5, PaymentHash: "bb01"}, 0, 0)
+	s.NoError(err)
+	s.NoError(s.db.Invoices.Add(database.Invoice{PaymentHash: "aa01", PublicKey: testWinner.PublicKey}))
+	s.NoError(s.db.Winners.Add(lotteryHeight, []database.Winner{testWinner}))
+	_, err = s.db.Scheduled.Add(database.ScheduledPayout{PublicKey: testWinner.PublicKey, Amount: 75})
+	s.NoError(err)
+	s.NoError(s.db.Audit.Add(database.AuditEntry{
+		ID:       1,
+		Event:    "payout_sent",
+		Data:     `{"payment_hash":"cc01","public_key":"` + testWinner.PublicKey + `"}`,
+		PrevHash: "prev",
+		Hash:     "hash",
+	}))
+}
+
+func (s *SearchSuite) TestSearchPrefix() {
+	result, err := s.db.Search.Search("7d959d", "", 10)
+	s.NoError(err)
+
+	s.Equal([]string{testWinner.PublicKey}, result.PublicKeys)
+	s.Len(result.Bets, 1)
+	s.Equal("aa01", result.Bets[0].PaymentHash)
+	s.Equal(lotteryHeight, result.Bets[0].LotteryHeight)
+	s.Len(result.Invoices, 1)
+	s.Equal([]database.SearchWinner{{
+		PublicKey:     testWinner.PublicKey,
+		Prize:         testWinner.Prize,
+		Ticket:        testWinner.Ticket,
+		LotteryHeight: lotteryHeight,
+	}}, result.Winners)
+	s.Len(result.Scheduled, 1)
+	s.Empty(result.Approvals)
+	s.Len(result.Audit, 1)
+}
+
+func (s *SearchSuite) TestSearchPaymentHash() {
+	result, err := s.db.Search.Search("", "bb01", 10)
+	s.NoError(err)
+
+	s.Equal([]string{testWinner2.PublicKey}, result.PublicKeys)
+	s.Len(result.Bets, 1)
+	s.Empty(result.Winners)
+	s.Empty(result.Audit)
+}
+
+func (s *SearchSuite) TestSearchAuditOnly() {
+	result, err := s.db.Search.Search("", "cc01", 10)
+	s.NoError(err)
+
+	s.Empty(result.PublicKeys)
+	s.Empty(result.Bets)
+	s.Len(result.Audit, 1)
+}
+
+func (s *SearchSuite) TestSearchNotFound() {
+	result, err := s.db.Search.Search("ffff", "", 10)
+	s.NoError(err)
+	s.Equal(database.SearchResult{}, result)
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoSession is thrown when a session does not exist or it has expired.
+var ErrNoSession = errors.New("session not found")
+
+// SessionsStore contains the methods used to store and retrieve the operators sessions.
+//
+// Only the hashes of the session tokens are stored.
+type SessionsStore interface {
+	Add(tokenHash string, operatorID uint64, expiresAt int64) error
+	Delete(tokenHash string) error
+	Get(tokenHash string) (Session, error)
+}
+
+// Session is an authenticated operator session.
+type Session struct {
+	Operator  Operator
+	ExpiresAt int64
+}
+
+type sessions struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newSessionsStore returns a new sessions storage service.
+func newSessionsStore(db *sql.DB, logger *logger.Logger) SessionsStore {
+	return &sessions{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add saves a session, expired sessions are removed.
+func (s *sessions) Add(tokenHash string, operatorID uint64, expiresAt int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM sessions WHERE expires_at <= ?", time.Now().Unix()); err != nil {
+		return errors.Wrap(err, "deleting expired sessions")
+	}
+
+	query := "INSERT INTO sessions (token_hash, operator_id, expires_at) VALUES (?,?,?)"
+	if _, err := tx.Exec(query, tokenHash, operatorID, expiresAt); err != nil {
+		return errors.Wrap(err, "adding session")
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a session.
+func (s *sessions) Delete(tokenHash string) error {
+	stmt, err := s.db.Prepare("DELETE FROM sessions WHERE token_hash=?")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(tokenHash); err != nil {
+		return errors.Wrap(err, "deleting session")
+	}
+
+	return nil
+}
+
+// Get returns a session that hasn't expired yet together with its operator.
+func (s *sessions) Get(tokenHash string) (Session, error) {
+	query := `SELECT s.expires_at, o.id, o.name, o.role, o.created_at FROM sessions s
+	INNER JOIN operators o ON s.operator_id = o.id
+	WHERE s.token_hash=? AND s.expires_at > ?`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return Session{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var session Session
+	err = stmt.QueryRow(tokenHash, time.Now().Unix()).Scan(
+		&session.ExpiresAt,
+		&session.Operator.ID,
+		&session.Operator.Name,
+		&session.Operator.Role,
+		&session.Operator.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Session{}, ErrNoSession
+		}
+		return Session{}, errors.Wrap(err, "getting session")
+	}
+
+	return session, nil
+}
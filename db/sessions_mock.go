@@ -0,0 +1,31 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// SessionsStoreMock is a mocked implementation of the sessions store.
+type SessionsStoreMock struct {
+	mock.Mock
+}
+
+// NewSessionsStoreMock returns a mocked sessions store.
+func NewSessionsStoreMock() *SessionsStoreMock {
+	return &SessionsStoreMock{}
+}
+
+// Add mock.
+func (s *SessionsStoreMock) Add(tokenHash string, operatorID uint64, expiresAt int64) error {
+	args := s.Called(tokenHash, operatorID, expiresAt)
+	return args.Error(0)
+}
+
+// Delete mock.
+func (s *SessionsStoreMock) Delete(tokenHash string) error {
+	args := s.Called(tokenHash)
+	return args.Error(0)
+}
+
+// Get mock.
+func (s *SessionsStoreMock) Get(tokenHash string) (Session, error) {
+	args := s.Called(tokenHash)
+	return args.Get(0).(Session), args.Error(1)
+}
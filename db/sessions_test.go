@@ -0,0 +1,75 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SessionsSuite struct {
+	suite.Suite
+
+	db       *database.DB
+	operator database.Operator
+}
+
+func TestSessionsSuite(t *testing.T) {
+	suite.Run(t, &SessionsSuite{})
+}
+
+func (s *SessionsSuite) SetupTest() {
+	s.db = setupDB(s.T(), func(db *sql.DB) {})
+
+	operator, err := s.db.Operators.Add("owner", testCredential, "")
+	s.NoError(err)
+	s.operator = operator
+}
+
+func (s *SessionsSuite) TestAdd() {
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	err := s.db.Sessions.Add("token", s.operator.ID, expiresAt)
+	s.NoError(err)
+
+	session, err := s.db.Sessions.Get("token")
+	s.NoError(err)
+
+	expected := database.Session{
+		Operator:  s.operator,
+		ExpiresAt: expiresAt,
+	}
+	s.Equal(expected, session)
+}
+
+func (s *SessionsSuite) TestDelete() {
+	err := s.db.Sessions.Add("token", s.operator.ID, time.Now().Add(time.Hour).Unix())
+	s.NoError(err)
+
+	err = s.db.Sessions.Delete("token")
+	s.NoError(err)
+
+	_, err = s.db.Sessions.Get("token")
+	s.ErrorIs(err, database.ErrNoSession)
+}
+
+func (s *SessionsSuite) TestGetExpired() {
+	err := s.db.Sessions.Add("token", s.operator.ID, time.Now().Add(-time.Second).Unix())
+	s.NoError(err)
+
+	_, err = s.db.Sessions.Get("token")
+	s.ErrorIs(err, database.ErrNoSession)
+}
+
+func (s *SessionsSuite) TestGetDeletedOperator() {
+	err := s.db.Sessions.Add("token", s.operator.ID, time.Now().Add(time.Hour).Unix())
+	s.NoError(err)
+
+	err = s.db.Operators.Delete(s.operator.ID)
+	s.NoError(err)
+
+	_, err = s.db.Sessions.Get("token")
+	s.ErrorIs(err, database.ErrNoSession)
+}
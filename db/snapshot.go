@@ -0,0 +1,161 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"modernc.org/sqlite"
+)
+
+// snapshotStepPages is the number of pages copied on each backup step. Locks on the source
+// database are released between steps, so writers are never blocked for long.
+const snapshotStepPages = 256
+
+type backuper interface {
+	NewBackup(dstURI string) (*sqlite.Backup, error)
+}
+
+// Snapshot writes an online copy of the database to path using the SQLite backup API.
+//
+// The copy is written to a temporary file first and then renamed, so a partial snapshot is never
+// left at path.
+func (db *DB) Snapshot(ctx context.Context, path string) error {
+	tmpPath := path + ".tmp"
+	if err := os.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "removing temporary snapshot")
+	}
+
+	conn, err := db.db.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting connection")
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(driverConn any) error {
+		// The connections of the drivers injecting failures wrap the SQLite ones
+		if wrapper, ok := driverConn.(interface{ Unwrap() driver.Conn }); ok {
+			driverConn = wrapper.Unwrap()
+		}
+		b, ok := driverConn.(backuper)
+		if !ok {
+			return errors.New("database driver does not support backups")
+		}
+
+		backup, err := b.NewBackup(tmpPath)
+		if err != nil {
+			return errors.Wrap(err, "starting backup")
+		}
+
+		for {
+			if err := ctx.Err(); err != nil {
+				backup.Finish()
+				return err
+			}
+
+			more, err := backup.Step(snapshotStepPages)
+			if err != nil {
+				backup.Finish()
+				return errors.Wrap(err, "copying pages")
+			}
+			if !more {
+				break
+			}
+		}
+
+		dstConn, err := backup.Commit()
+		if err != nil {
+			return errors.Wrap(err, "finishing backup")
+		}
+		defer dstConn.Close()
+
+		// The snapshot is only read, the write-ahead log the source may be using is not needed
+		execer, ok := dstConn.(driver.ExecerContext)
+		if !ok {
+			return nil
+		}
+		_, err = execer.ExecContext(ctx, "PRAGMA journal_mode=DELETE", nil)
+		return errors.Wrap(err, "setting snapshot journal mode")
+	})
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// ReadReplica returns the read-only copy of the database refreshed by StartSnapshots. If snapshots
+// are disabled or none was taken yet, it returns the database itself.
+//
+// Data read from the replica can be up to one snapshot interval old, it must only be used to
+// serve public requests.
+func (db *DB) ReadReplica() *DB {
+	if replica := db.replica.Load(); replica != nil {
+		return replica
+	}
+	return db
+}
+
+// StartSnapshots refreshes the read replica periodically until the context is cancelled.
+func (db *DB) StartSnapshots(ctx context.Context) {
+	if db.snapshot.Interval == 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(db.snapshot.Interval)
+		defer ticker.Stop()
+
+		for {
+			if err := db.refreshReplica(ctx); err != nil {
+				db.logger.Error(errors.Wrap(err, "refreshing read replica"))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// refreshReplica takes a new snapshot and replaces the read replica with it.
+//
+// The previous replica is kept open until the next refresh so requests that already obtained it
+// can finish their queries.
+func (db *DB) refreshReplica(ctx context.Context) error {
+	start := time.Now()
+	if err := db.Snapshot(ctx, db.snapshot.Path); err != nil {
+		return err
+	}
+
+	sqlDB, err := sql.Open("sqlite", "file:"+db.snapshot.Path+"?mode=ro&_pragma=query_only(1)")
+	if err != nil {
+		return errors.Wrap(err, "opening snapshot")
+	}
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		sqlDB.Close()
+		return errors.Wrap(err, "opening snapshot")
+	}
+
+	replica := newDB(sqlDB, db.logger)
+	// The notifications may be encrypted or in another database, they are always read from the
+	// primary
+	replica.Notifications = db.Notifications
+
+	previous := db.replica.Swap(replica)
+	if retired := db.retired.Swap(previous); retired != nil {
+		if err := retired.Close(); err != nil {
+			db.logger.Error(errors.Wrap(err, "closing previous read replica"))
+		}
+	}
+
+	db.logger.Debugf("Read replica refreshed in %s", time.Since(start))
+	return nil
+}
@@ -0,0 +1,93 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenPragmas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "btry.db")
+	database, err := db.Open(config.DB{Path: path, WAL: true, BusyTimeout: 10 * time.Second})
+	assert.NoError(t, err)
+	defer database.Close()
+
+	sqlDB, err := sql.Open("sqlite", path)
+	assert.NoError(t, err)
+	defer sqlDB.Close()
+
+	// The journal mode is persisted in the database file
+	var journalMode string
+	err = sqlDB.QueryRow("PRAGMA journal_mode").Scan(&journalMode)
+	assert.NoError(t, err)
+	assert.Equal(t, "wal", journalMode)
+}
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	database, err := db.Open(config.DB{Path: filepath.Join(dir, "btry.db"), WAL: true})
+	assert.NoError(t, err)
+	defer database.Close()
+
+	err = database.Lotteries.AddHeight(144, "", "")
+	assert.NoError(t, err)
+
+	snapshotPath := filepath.Join(dir, "snapshot.db")
+	err = database.Snapshot(context.Background(), snapshotPath)
+	assert.NoError(t, err)
+
+	_, err = os.Stat(snapshotPath + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+
+	snapshot, err := db.Open(config.DB{Path: snapshotPath})
+	assert.NoError(t, err)
+	defer snapshot.Close()
+
+	heights, err := snapshot.Lotteries.ListHeights(0, 0, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{144}, heights)
+}
+
+func TestReadReplica(t *testing.T) {
+	dir := t.TempDir()
+	database, err := db.Open(config.DB{
+		Path:     filepath.Join(dir, "btry.db"),
+		WAL:      true,
+		Snapshot: config.Snapshot{Path: filepath.Join(dir, "snapshot.db"), Interval: 50 * time.Millisecond},
+	})
+	assert.NoError(t, err)
+	defer database.Close()
+
+	assert.Same(t, database, database.ReadReplica(), "No snapshot was taken yet")
+
+	err = database.Lotteries.AddHeight(144, "", "")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	database.StartSnapshots(ctx)
+
+	assert.Eventually(t, func() bool {
+		return database.ReadReplica() != database
+	}, time.Second, 10*time.Millisecond)
+
+	replica := database.ReadReplica()
+	err = replica.Lotteries.AddHeight(288, "", "")
+	assert.Error(t, err, "Replica must be read-only")
+
+	err = database.Lotteries.AddHeight(288, "", "")
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		heights, err := database.ReadReplica().Lotteries.ListHeights(0, 0, false)
+		return err == nil && len(heights) == 2
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,303 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// ErrRoundNotFound is returned when there are no statistics of the lottery at the height specified.
+var ErrRoundNotFound = errors.New("round not found")
+
+// StatsStore contains the methods used to keep the aggregate statistics of the lotteries. They
+// are updated every time a lottery ends or a prize is paid out, so reading them is cheap.
+//
+// Statistics are anonymized, public keys are never returned.
+type StatsStore interface {
+	AddPayout(amount uint64) error
+	AddRound(round RoundStats, winners []Winner, previousHeight uint32) error
+	Get() (Stats, error)
+	GetRound(height uint32) (RoundStats, error)
+	ListBiggestWins(limit uint64) ([]Win, error)
+	ListRounds(offset, limit uint64, reverse bool) ([]RoundStats, error)
+	ListStreaks(limit uint64) ([]Streak, error)
+}
+
+// Stats contains the all-time statistics.
+type Stats struct {
+	Rounds       uint64 `json:"rounds"`
+	TotalPool    uint64 `json:"total_pool"`
+	AveragePool  uint64 `json:"average_pool"`
+	TotalPaidOut uint64 `json:"total_paid_out"`
+	Payouts      uint64 `json:"payouts"`
+}
+
+// RoundStats contains the statistics of a single lottery.
+type RoundStats struct {
+	Height    uint32 `json:"height"`
+	PrizePool uint64 `json:"prize_pool"`
+	Players   uint64 `json:"players"`
+	Winners   uint64 `json:"winners"`
+	// Hidden is set when the statistics are withheld to protect the privacy of the players, it's
+	// never stored
+	Hidden bool `json:"hidden,omitempty"`
+}
+
+// Win is the sum of the prizes won by a player in a lottery.
+type Win struct {
+	Height uint32 `json:"height"`
+	Prize  uint64 `json:"prize"`
+	// Players is the number of players in the lottery
+	Players uint64 `json:"-"`
+}
+
+// Streak is the number of consecutive lotteries won by the same player.
+type Streak struct {
+	Length    uint32 `json:"length"`
+	EndHeight uint32 `json:"end_height"`
+}
+
+type stats struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newStatsStore returns a new statistics storage service.
+func newStatsStore(db *sql.DB, logger *logger.Logger) StatsStore {
+	return &stats{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// AddPayout adds a prize paid out to the totals.
+func (s *stats) AddPayout(amount uint64) error {
+	stmt, err := s.db.Prepare("UPDATE stats SET total_paid_out = total_paid_out + ?, payouts = payouts + 1")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(amount); err != nil {
+		return errors.Wrap(err, "adding payout")
+	}
+
+	return nil
+}
+
+// AddRound stores the statistics of a lottery that ended and updates the totals and the players
+// win streaks.
+//
+// previousHeight is the height of the lottery before the one being added, winners of both keep
+// their streak going.
+func (s *stats) AddRound(round RoundStats, winners []Winner, previousHeight uint32) error {
+	prizes := make(map[string]uint64, len(winners))
+	for _, winner := range winners {
+		prizes[winner.PublicKey] += winner.Prize
+	}
+	round.Winners = uint64(len(prizes))
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO stats_rounds (height, prize_pool, players, winners) VALUES (?,?,?,?)"
+	if _, err := tx.Exec(query, round.Height, round.PrizePool, round.Players, round.Winners); err != nil {
+		return errors.Wrap(err, "adding round")
+	}
+
+	query = "UPDATE stats SET rounds = rounds + 1, total_pool = total_pool + ?"
+	if _, err := tx.Exec(query, round.PrizePool); err != nil {
+		return errors.Wrap(err, "updating totals")
+	}
+
+	winStmt, err := tx.Prepare("INSERT INTO stats_wins (lottery_height, prize) VALUES (?,?)")
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer winStmt.Close()
+
+	streakStmt, err := tx.Prepare(`INSERT INTO stats_streaks
+	(public_key, current, last_height, longest, longest_height) VALUES (?, 1, ?, 1, ?)
+	ON CONFLICT (public_key) DO UPDATE SET
+		current = CASE WHEN last_height = ? THEN current + 1 ELSE 1 END,
+		longest = MAX(longest, CASE WHEN last_height = ? THEN current + 1 ELSE 1 END),
+		longest_height = CASE
+			WHEN last_height = ? AND current + 1 > longest THEN excluded.last_height
+			ELSE longest_height
+		END,
+		last_height = excluded.last_height`)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer streakStmt.Close()
+
+	for publicKey, prize := range prizes {
+		if _, err := winStmt.Exec(round.Height, prize); err != nil {
+			return errors.Wrap(err, "adding win")
+		}
+
+		_, err := streakStmt.Exec(publicKey, round.Height, round.Height,
+			previousHeight, previousHeight, previousHeight)
+		if err != nil {
+			return errors.Wrap(err, "updating streak")
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Get returns the all-time statistics.
+func (s *stats) Get() (Stats, error) {
+	stmt, err := s.db.Prepare("SELECT rounds, total_pool, total_paid_out, payouts FROM stats")
+	if err != nil {
+		return Stats{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var stats Stats
+	err = stmt.QueryRow().Scan(&stats.Rounds, &stats.TotalPool, &stats.TotalPaidOut, &stats.Payouts)
+	if err != nil {
+		return Stats{}, errors.Wrap(err, "getting stats")
+	}
+
+	if stats.Rounds > 0 {
+		stats.AveragePool = stats.TotalPool / stats.Rounds
+	}
+
+	return stats, nil
+}
+
+// GetRound returns the statistics of the lottery at the height specified.
+func (s *stats) GetRound(height uint32) (RoundStats, error) {
+	query := "SELECT height, prize_pool, players, winners FROM stats_rounds WHERE height=?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return RoundStats{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var round RoundStats
+	err = stmt.QueryRow(height).Scan(&round.Height, &round.PrizePool, &round.Players, &round.Winners)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return RoundStats{}, ErrRoundNotFound
+		}
+		return RoundStats{}, errors.Wrap(err, "getting round")
+	}
+
+	return round, nil
+}
+
+// ListBiggestWins returns the highest prizes won by a single player in a lottery.
+func (s *stats) ListBiggestWins(limit uint64) ([]Win, error) {
+	// Cap limit to avoid creating a slice with too big capacity
+	if limit == 0 || limit > 100 {
+		limit = 100
+	}
+
+	query := `SELECT w.lottery_height, w.prize, COALESCE(r.players, 0) FROM stats_wins w
+	LEFT JOIN stats_rounds r ON r.height = w.lottery_height
+	ORDER BY w.prize DESC, w.lottery_height ASC LIMIT ?`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing wins")
+	}
+	defer rows.Close()
+
+	wins := make([]Win, 0, limit)
+	// Reuse object
+	var win Win
+	for rows.Next() {
+		if err := rows.Scan(&win.Height, &win.Prize, &win.Players); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		wins = append(wins, win)
+	}
+
+	return wins, nil
+}
+
+// ListRounds returns the statistics of each lottery.
+//
+// A limit value of 0 means there's no limit.
+func (s *stats) ListRounds(offset, limit uint64, reverse bool) ([]RoundStats, error) {
+	// Cap limit to avoid creating a slice with too big capacity
+	if limit > 500 {
+		limit = 500
+	}
+
+	query := "SELECT height, prize_pool, players, winners FROM stats_rounds"
+	query = AddPagination(query, offset, limit, "height", reverse)
+
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing rounds")
+	}
+	defer rows.Close()
+
+	rounds := make([]RoundStats, 0, limit)
+	// Reuse object
+	var round RoundStats
+	for rows.Next() {
+		if err := rows.Scan(&round.Height, &round.PrizePool, &round.Players, &round.Winners); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		rounds = append(rounds, round)
+	}
+
+	return rounds, nil
+}
+
+// ListStreaks returns the longest win streaks.
+func (s *stats) ListStreaks(limit uint64) ([]Streak, error) {
+	// Cap limit to avoid creating a slice with too big capacity
+	if limit == 0 || limit > 100 {
+		limit = 100
+	}
+
+	query := `SELECT longest, longest_height FROM stats_streaks WHERE longest > 1
+	ORDER BY longest DESC, longest_height ASC LIMIT ?`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing streaks")
+	}
+	defer rows.Close()
+
+	streaks := make([]Streak, 0, limit)
+	// Reuse object
+	var streak Streak
+	for rows.Next() {
+		if err := rows.Scan(&streak.Length, &streak.EndHeight); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		streaks = append(streaks, streak)
+	}
+
+	return streaks, nil
+}
@@ -0,0 +1,55 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// StatsStoreMock is a mocked implementation of the statistics store.
+type StatsStoreMock struct {
+	mock.Mock
+}
+
+// NewStatsStoreMock returns a mocked statistics store.
+func NewStatsStoreMock() *StatsStoreMock {
+	return &StatsStoreMock{}
+}
+
+// AddPayout mock.
+func (s *StatsStoreMock) AddPayout(amount uint64) error {
+	args := s.Called(amount)
+	return args.Error(0)
+}
+
+// AddRound mock.
+func (s *StatsStoreMock) AddRound(round RoundStats, winners []Winner, previousHeight uint32) error {
+	args := s.Called(round, winners, previousHeight)
+	return args.Error(0)
+}
+
+// Get mock.
+func (s *StatsStoreMock) Get() (Stats, error) {
+	args := s.Called()
+	return args.Get(0).(Stats), args.Error(1)
+}
+
+// GetRound mock.
+func (s *StatsStoreMock) GetRound(height uint32) (RoundStats, error) {
+	args := s.Called(height)
+	return args.Get(0).(RoundStats), args.Error(1)
+}
+
+// ListBiggestWins mock.
+func (s *StatsStoreMock) ListBiggestWins(limit uint64) ([]Win, error) {
+	args := s.Called(limit)
+	return args.Get(0).([]Win), args.Error(1)
+}
+
+// ListRounds mock.
+func (s *StatsStoreMock) ListRounds(offset, limit uint64, reverse bool) ([]RoundStats, error) {
+	args := s.Called(offset, limit, reverse)
+	return args.Get(0).([]RoundStats), args.Error(1)
+}
+
+// ListStreaks mock.
+func (s *StatsStoreMock) ListStreaks(limit uint64) ([]Streak, error) {
+	args := s.Called(limit)
+	return args.Get(0).([]Streak), args.Error(1)
+}
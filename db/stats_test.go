@@ -0,0 +1,180 @@
+package db_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type StatsSuite struct {
+	suite.Suite
+
+	db database.StatsStore
+}
+
+func TestStatsSuite(t *testing.T) {
+	suite.Run(t, &StatsSuite{})
+}
+
+func (s *StatsSuite) SetupTest() {
+	db := setupDB(s.T(), func(db *sql.DB) {})
+	s.db = db.Stats
+}
+
+func (s *StatsSuite) TestAddRound() {
+	winners := []database.Winner{
+		{PublicKey: "1", Prize: 500, Ticket: 3},
+		{PublicKey: "2", Prize: 250, Ticket: 7},
+		{PublicKey: "1", Prize: 125, Ticket: 9},
+	}
+	round := database.RoundStats{Height: 144, PrizePool: 1_000, Players: 3}
+	err := s.db.AddRound(round, winners, 0)
+	s.NoError(err)
+
+	err = s.db.AddRound(database.RoundStats{Height: 288, PrizePool: 3_000, Players: 5}, nil, 144)
+	s.NoError(err)
+
+	stats, err := s.db.Get()
+	s.NoError(err)
+
+	expected := database.Stats{
+		Rounds:      2,
+		TotalPool:   4_000,
+		AveragePool: 2_000,
+	}
+	s.Equal(expected, stats)
+
+	rounds, err := s.db.ListRounds(0, 0, false)
+	s.NoError(err)
+
+	round.Winners = 2
+	expectedRounds := []database.RoundStats{round, {Height: 288, PrizePool: 3_000, Players: 5}}
+	s.Equal(expectedRounds, rounds)
+
+	wins, err := s.db.ListBiggestWins(1)
+	s.NoError(err)
+	s.Equal([]database.Win{{Height: 144, Prize: 625, Players: 3}}, wins)
+}
+
+func (s *StatsSuite) TestGetRound() {
+	round := database.RoundStats{Height: 144, PrizePool: 1_000, Players: 3}
+	err := s.db.AddRound(round, []database.Winner{{PublicKey: "1", Prize: 500}}, 0)
+	s.NoError(err)
+
+	got, err := s.db.GetRound(144)
+	s.NoError(err)
+
+	round.Winners = 1
+	s.Equal(round, got)
+
+	_, err = s.db.GetRound(288)
+	s.ErrorIs(err, database.ErrRoundNotFound)
+}
+
+func (s *StatsSuite) TestAddPayout() {
+	err := s.db.AddPayout(2_000)
+	s.NoError(err)
+	err = s.db.AddPayout(500)
+	s.NoError(err)
+
+	stats, err := s.db.Get()
+	s.NoError(err)
+
+	s.Equal(uint64(2_500), stats.TotalPaidOut)
+	s.Equal(uint64(2), stats.Payouts)
+}
+
+func (s *StatsSuite) TestStreaks() {
+	rounds := []struct {
+		winners []string
+		height  uint32
+	}{
+		{height: 10, winners: []string{"1", "2"}},
+		{height: 20, winners: []string{"1", "2"}},
+		{height: 30, winners: []string{"1"}},
+		// Round without a draw between 30 and 50 breaks the streak
+		{height: 50, winners: []string{"1", "2"}},
+		{height: 60, winners: []string{"2"}},
+	}
+
+	for _, round := range rounds {
+		winners := make([]database.Winner, 0, len(round.winners))
+		for _, publicKey := range round.winners {
+			winners = append(winners, database.Winner{PublicKey: publicKey, Prize: 10, Ticket: 1})
+		}
+
+		err := s.db.AddRound(database.RoundStats{Height: round.height}, winners, round.height-10)
+		s.NoError(err)
+	}
+
+	streaks, err := s.db.ListStreaks(0)
+	s.NoError(err)
+
+	expected := []database.Streak{
+		{Length: 3, EndHeight: 30},
+		{Length: 2, EndHeight: 20},
+	}
+	s.Equal(expected, streaks)
+}
+
+func TestStatsBackfill(t *testing.T) {
+	file, err := os.CreateTemp("", "*")
+	assert.NoError(t, err)
+	defer file.Close()
+
+	dbConfig := config.DB{Path: file.Name()}
+	db, err := database.Open(dbConfig)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Close())
+
+	sqlDB, err := sql.Open("sqlite", file.Name())
+	assert.NoError(t, err)
+	defer sqlDB.Close()
+
+	// Simulate a database that was created before the statistics were tracked
+	_, err = sqlDB.Exec(`DELETE FROM stats_rounds; DELETE FROM stats_wins;
+	UPDATE stats SET rounds = 0, total_pool = 0;
+	INSERT INTO lotteries (height) VALUES (144), (288);
+	INSERT INTO bets (idx, tickets, public_key, lottery_height) VALUES
+		(100, 100, 'a', 144), (300, 200, 'b', 144), (400, 100, 'a', 144), (50, 50, 'c', 288);
+	INSERT INTO winners (public_key, prize, ticket, lottery_height) VALUES
+		('a', 200, 50, 144), ('b', 100, 250, 144), ('a', 50, 350, 144), ('c', 20, 0, 288);
+	ALTER TABLE winners DROP COLUMN pool;
+	ALTER TABLE lotteries DROP COLUMN seed;
+	ALTER TABLE lotteries DROP COLUMN commitment;
+	ALTER TABLE lotteries DROP COLUMN block_hash;
+	ALTER TABLE winners DROP COLUMN last_reminded_at;
+	ALTER TABLE lotteries DROP COLUMN collision;
+	ALTER TABLE invoices DROP COLUMN settle_index;
+	ALTER TABLE winners DROP COLUMN tier;
+	ALTER TABLE lotteries DROP COLUMN draw_locked;
+	ALTER TABLE receipts DROP COLUMN bonus;
+	ALTER TABLE bets DROP COLUMN house;
+	ALTER TABLE invoices DROP COLUMN preimage;
+	ALTER TABLE invoices DROP COLUMN retries;
+	ALTER TABLE reveals DROP COLUMN salt;
+	PRAGMA user_version = 2;`)
+	assert.NoError(t, err)
+
+	db, err = database.Open(dbConfig)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	stats, err := db.Stats.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, database.Stats{Rounds: 1, TotalPool: 400, AveragePool: 400}, stats)
+
+	rounds, err := db.Stats.ListRounds(0, 0, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []database.RoundStats{{Height: 144, PrizePool: 400, Players: 2, Winners: 2}}, rounds)
+
+	wins, err := db.Stats.ListBiggestWins(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []database.Win{{Height: 144, Prize: 250, Players: 2}, {Height: 144, Prize: 100, Players: 2}}, wins)
+}
@@ -0,0 +1,233 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrSwapClaimNotFound is returned when there's no swap claim with the payment hash provided.
+	ErrSwapClaimNotFound = errors.New("swap claim not found")
+	// ErrSwapClaimUsed is returned when a hold invoice was already paid with the swap claim hash.
+	ErrSwapClaimUsed = errors.New("swap claim already used")
+)
+
+// SwapClaimsStore contains the methods used to store and retrieve the prize claims paid to hold
+// invoices.
+type SwapClaimsStore interface {
+	Add(claim SwapClaim) error
+	Countersign(paymentHash, countersignature string, countersignedAt int64) error
+	Get(paymentHash string) (SwapClaim, error)
+	List(publicKey string) ([]SwapClaim, error)
+	SetReceipt(paymentHash, message, signature string) error
+}
+
+// SwapClaim is a prize claim paid to a hold invoice locked to a hash chosen by the server. The
+// preimage is revealed to the winner only after they countersign the receipt, so the receipt, both
+// signatures and the preimage prove the payment to either side.
+type SwapClaim struct {
+	PublicKey   string `json:"public_key"`
+	PaymentHash string `json:"payment_hash"`
+	// Preimage is omitted from the listings until the winner countersigns the receipt
+	Preimage string `json:"preimage,omitempty"`
+	// Prizes are the amounts the invoice claimed from the prizes won in each lottery
+	Prizes []Prize `json:"prizes,omitempty"`
+	// Message is the receipt signed by the server and the winner, set when the invoice is paid
+	Message          string `json:"message,omitempty"`
+	Signature        string `json:"signature,omitempty"`
+	Countersignature string `json:"countersignature,omitempty"`
+	CreatedAt        int64  `json:"created_at"`
+	CountersignedAt  int64  `json:"countersigned_at,omitempty"`
+}
+
+type swapClaims struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newSwapClaimsStore returns a new swap claims storage service.
+func newSwapClaimsStore(db *sql.DB, logger *logger.Logger) SwapClaimsStore {
+	return &swapClaims{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add saves a swap claim whose invoice wasn't paid yet.
+func (s *swapClaims) Add(claim SwapClaim) error {
+	query := "INSERT INTO swap_claims (payment_hash, public_key, preimage, created_at) VALUES (?,?,?,?)"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(claim.PaymentHash, claim.PublicKey, claim.Preimage, claim.CreatedAt); err != nil {
+		return errors.Wrap(err, "adding swap claim")
+	}
+
+	return nil
+}
+
+// Countersign stores the winner's signature of the receipt. Claims without a receipt are not
+// updated.
+func (s *swapClaims) Countersign(paymentHash, countersignature string, countersignedAt int64) error {
+	query := `UPDATE swap_claims SET countersignature=?, countersigned_at=?
+	WHERE payment_hash=? AND message != ''`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(countersignature, countersignedAt, paymentHash)
+	if err != nil {
+		return errors.Wrap(err, "countersigning swap claim")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "getting rows affected")
+	}
+	if affected == 0 {
+		return ErrSwapClaimNotFound
+	}
+
+	return nil
+}
+
+// Get returns the swap claim of the payment hash.
+func (s *swapClaims) Get(paymentHash string) (SwapClaim, error) {
+	query := `SELECT payment_hash, public_key, preimage, message, signature, countersignature,
+	created_at, countersigned_at FROM swap_claims WHERE payment_hash=?`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return SwapClaim{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	var claim SwapClaim
+	err = stmt.QueryRow(paymentHash).Scan(&claim.PaymentHash, &claim.PublicKey, &claim.Preimage,
+		&claim.Message, &claim.Signature, &claim.Countersignature, &claim.CreatedAt,
+		&claim.CountersignedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SwapClaim{}, ErrSwapClaimNotFound
+		}
+		return SwapClaim{}, errors.Wrap(err, "getting swap claim")
+	}
+
+	return claim, nil
+}
+
+// List returns the swap claims of a public key, the newest first, along with the prizes they
+// claimed. The preimages of the claims that weren't countersigned are not included, as they would
+// let the winner settle the invoice without acknowledging the receipt.
+func (s *swapClaims) List(publicKey string) ([]SwapClaim, error) {
+	query := `SELECT payment_hash, public_key, CASE WHEN countersigned_at != 0 THEN preimage ELSE '' END,
+	message, signature, countersignature, created_at, countersigned_at FROM swap_claims
+	WHERE public_key=? ORDER BY created_at DESC, rowid DESC`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing swap claims")
+	}
+	defer rows.Close()
+
+	var claims []SwapClaim
+	for rows.Next() {
+		var claim SwapClaim
+		err := rows.Scan(&claim.PaymentHash, &claim.PublicKey, &claim.Preimage, &claim.Message,
+			&claim.Signature, &claim.Countersignature, &claim.CreatedAt, &claim.CountersignedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		claims = append(claims, claim)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating swap claims")
+	}
+
+	if err := s.listPrizes(publicKey, claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// listPrizes sets the prizes claimed by each of the swap claims of the public key.
+func (s *swapClaims) listPrizes(publicKey string, claims []SwapClaim) error {
+	query := `SELECT w.payment_hash, w.lottery_height, w.amount FROM prize_claim_winners w
+	JOIN swap_claims s ON s.payment_hash = w.payment_hash
+	WHERE s.public_key=? ORDER BY w.lottery_height`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(publicKey)
+	if err != nil {
+		return errors.Wrap(err, "listing swap claim prizes")
+	}
+	defer rows.Close()
+
+	prizes := make(map[string][]Prize)
+	for rows.Next() {
+		var (
+			paymentHash string
+			prize       Prize
+		)
+		if err := rows.Scan(&paymentHash, &prize.LotteryHeight, &prize.Amount); err != nil {
+			return errors.Wrap(err, "scanning rows")
+		}
+
+		prizes[paymentHash] = append(prizes[paymentHash], prize)
+	}
+
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "iterating swap claim prizes")
+	}
+
+	for i, claim := range claims {
+		claims[i].Prizes = prizes[claim.PaymentHash]
+	}
+
+	return nil
+}
+
+// SetReceipt stores the receipt of the invoice paid and the server signature. A swap claim can
+// only be used to pay one invoice.
+func (s *swapClaims) SetReceipt(paymentHash, message, signature string) error {
+	query := "UPDATE swap_claims SET message=?, signature=? WHERE payment_hash=? AND message=''"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(message, signature, paymentHash)
+	if err != nil {
+		return errors.Wrap(err, "setting swap claim receipt")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "getting rows affected")
+	}
+	if affected == 0 {
+		return ErrSwapClaimUsed
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// SwapClaimsStoreMock is a mocked implementation of the swap claims store.
+type SwapClaimsStoreMock struct {
+	mock.Mock
+}
+
+// NewSwapClaimsStoreMock returns a mocked swap claims store.
+func NewSwapClaimsStoreMock() *SwapClaimsStoreMock {
+	return &SwapClaimsStoreMock{}
+}
+
+// Add mock.
+func (s *SwapClaimsStoreMock) Add(claim SwapClaim) error {
+	args := s.Called(claim)
+	return args.Error(0)
+}
+
+// Countersign mock.
+func (s *SwapClaimsStoreMock) Countersign(paymentHash, countersignature string, countersignedAt int64) error {
+	args := s.Called(paymentHash, countersignature, countersignedAt)
+	return args.Error(0)
+}
+
+// Get mock.
+func (s *SwapClaimsStoreMock) Get(paymentHash string) (SwapClaim, error) {
+	args := s.Called(paymentHash)
+	return args.Get(0).(SwapClaim), args.Error(1)
+}
+
+// List mock.
+func (s *SwapClaimsStoreMock) List(publicKey string) ([]SwapClaim, error) {
+	args := s.Called(publicKey)
+	var r0 []SwapClaim
+	v0 := args.Get(0)
+	if v0 != nil {
+		r0 = v0.([]SwapClaim)
+	}
+	return r0, args.Error(1)
+}
+
+// SetReceipt mock.
+func (s *SwapClaimsStoreMock) SetReceipt(paymentHash, message, signature string) error {
+	args := s.Called(paymentHash, message, signature)
+	return args.Error(0)
+}
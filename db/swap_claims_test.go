@@ -0,0 +1,96 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SwapClaimsSuite struct {
+	suite.Suite
+
+	db *database.DB
+}
+
+func TestSwapClaimsSuite(t *testing.T) {
+	suite.Run(t, &SwapClaimsSuite{})
+}
+
+func (s *SwapClaimsSuite) SetupTest() {
+	s.db = setupDB(s.T(), func(db *sql.DB) {})
+}
+
+func (s *SwapClaimsSuite) TestFlow() {
+	claim := database.SwapClaim{
+		PublicKey:   "pubkey",
+		PaymentHash: "hash",
+		Preimage:    "preimage",
+		CreatedAt:   1231006505,
+	}
+	s.NoError(s.db.SwapClaims.Add(claim))
+
+	// The receipt must be set before countersigning
+	err := s.db.SwapClaims.Countersign(claim.PaymentHash, "countersignature", 1231006600)
+	s.ErrorIs(err, database.ErrSwapClaimNotFound)
+
+	s.NoError(s.db.SwapClaims.SetReceipt(claim.PaymentHash, "message", "signature"))
+	err = s.db.SwapClaims.SetReceipt(claim.PaymentHash, "message2", "signature2")
+	s.ErrorIs(err, database.ErrSwapClaimUsed)
+
+	s.NoError(s.db.SwapClaims.Countersign(claim.PaymentHash, "countersignature", 1231006600))
+
+	claim.Message = "message"
+	claim.Signature = "signature"
+	claim.Countersignature = "countersignature"
+	claim.CountersignedAt = 1231006600
+	got, err := s.db.SwapClaims.Get(claim.PaymentHash)
+	s.NoError(err)
+	s.Equal(claim, got)
+}
+
+func (s *SwapClaimsSuite) TestGetNotFound() {
+	_, err := s.db.SwapClaims.Get("hash")
+	s.ErrorIs(err, database.ErrSwapClaimNotFound)
+}
+
+func (s *SwapClaimsSuite) TestList() {
+	s.NoError(s.db.SwapClaims.Add(database.SwapClaim{PublicKey: "pubkey", PaymentHash: "hash", CreatedAt: 1}))
+	s.NoError(s.db.SwapClaims.Add(database.SwapClaim{PublicKey: "pubkey", PaymentHash: "hash2", CreatedAt: 2}))
+	s.NoError(s.db.SwapClaims.Add(database.SwapClaim{PublicKey: "pubkey2", PaymentHash: "hash3", CreatedAt: 3}))
+
+	claims, err := s.db.SwapClaims.List("pubkey")
+	s.NoError(err)
+	s.Len(claims, 2)
+	s.Equal("hash2", claims[0].PaymentHash)
+	s.Equal("hash", claims[1].PaymentHash)
+}
+
+func (s *SwapClaimsSuite) TestListPreimage() {
+	winner := database.Winner{PublicKey: "pubkey", Prize: 100}
+	s.NoError(s.db.Prizes.Set(1, []database.Winner{winner}))
+	s.NoError(s.db.Prizes.Set(2, []database.Winner{winner}))
+
+	claim := database.SwapClaim{PublicKey: "pubkey", PaymentHash: "hash", Preimage: "preimage", CreatedAt: 1}
+	s.NoError(s.db.SwapClaims.Add(claim))
+	_, err := s.db.Prizes.Claim(claim.PublicKey, claim.PaymentHash, 150)
+	s.NoError(err)
+	s.NoError(s.db.SwapClaims.SetReceipt(claim.PaymentHash, "message", "signature"))
+
+	// The preimage is hidden until the receipt is countersigned
+	claims, err := s.db.SwapClaims.List(claim.PublicKey)
+	s.NoError(err)
+	s.Len(claims, 1)
+	s.Empty(claims[0].Preimage)
+	expected := []database.Prize{{LotteryHeight: 1, Amount: 100}, {LotteryHeight: 2, Amount: 50}}
+	s.Equal(expected, claims[0].Prizes)
+
+	s.NoError(s.db.SwapClaims.Countersign(claim.PaymentHash, "countersignature", 2))
+
+	claims, err = s.db.SwapClaims.List(claim.PublicKey)
+	s.NoError(err)
+	s.Equal(claim.Preimage, claims[0].Preimage)
+	s.Equal(expected, claims[0].Prizes)
+}
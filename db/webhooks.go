@@ -0,0 +1,256 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoWebhook is thrown when a webhook does not exist or belongs to another API key.
+var ErrNoWebhook = errors.New("webhook not found")
+
+// WebhooksStore contains the methods used to store and retrieve the webhooks integrators
+// subscribed and the log of their deliveries.
+type WebhooksStore interface {
+	Add(apiKeyID uint64, url, secret string, events []string) (Webhook, error)
+	AddDelivery(delivery WebhookDelivery) error
+	Delete(id, apiKeyID uint64) error
+	Get(id uint64) (Webhook, error)
+	List(apiKeyID uint64) ([]Webhook, error)
+	ListByEvent(event string) ([]Webhook, error)
+	ListDeliveries(webhookID, offset, limit uint64) ([]WebhookDelivery, error)
+}
+
+// Webhook is a URL subscribed to a set of events.
+type Webhook struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret is the key the payloads are signed with, it's only returned when the webhook is
+	// created
+	Secret    string `json:"-"`
+	ID        uint64 `json:"id"`
+	APIKeyID  uint64 `json:"api_key_id"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// WebhookDelivery is an attempt to deliver an event to a webhook. Retries share the delivery ID.
+type WebhookDelivery struct {
+	DeliveryID string `json:"delivery_id"`
+	Event      string `json:"event"`
+	Error      string `json:"error,omitempty"`
+	ID         uint64 `json:"id"`
+	WebhookID  uint64 `json:"webhook_id"`
+	StatusCode int    `json:"status_code,omitempty"`
+	CreatedAt  int64  `json:"created_at"`
+}
+
+type webhooks struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+// newWebhooksStore returns a new webhooks storage service.
+func newWebhooksStore(db *sql.DB, logger *logger.Logger) WebhooksStore {
+	return &webhooks{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add saves a webhook subscribed to the events specified.
+func (w *webhooks) Add(apiKeyID uint64, url, secret string, events []string) (Webhook, error) {
+	query := "INSERT INTO webhooks (api_key_id, url, secret, events, created_at) VALUES (?,?,?,?,?)"
+	stmt, err := w.db.Prepare(query)
+	if err != nil {
+		return Webhook{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	createdAt := time.Now().Unix()
+	result, err := stmt.Exec(apiKeyID, url, secret, strings.Join(events, ","), createdAt)
+	if err != nil {
+		return Webhook{}, errors.Wrap(err, "adding webhook")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return Webhook{}, errors.Wrap(err, "getting webhook id")
+	}
+
+	return Webhook{
+		ID:        uint64(id),
+		APIKeyID:  apiKeyID,
+		URL:       url,
+		Secret:    secret,
+		Events:    events,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// AddDelivery logs a delivery attempt.
+func (w *webhooks) AddDelivery(delivery WebhookDelivery) error {
+	query := `INSERT INTO webhook_deliveries (webhook_id, delivery_id, event, status_code, error, created_at)
+	VALUES (?,?,?,?,?,?)`
+	stmt, err := w.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(delivery.WebhookID, delivery.DeliveryID, delivery.Event, delivery.StatusCode,
+		delivery.Error, delivery.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "adding webhook delivery")
+	}
+
+	return nil
+}
+
+// Delete removes a webhook of the API key and its deliveries log.
+func (w *webhooks) Delete(id, apiKeyID uint64) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM webhooks WHERE id=? AND api_key_id=?", id, apiKeyID)
+	if err != nil {
+		return errors.Wrap(err, "deleting webhook")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "getting affected rows")
+	}
+	if rows == 0 {
+		return ErrNoWebhook
+	}
+
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id=?", id); err != nil {
+		return errors.Wrap(err, "deleting webhook deliveries")
+	}
+
+	return tx.Commit()
+}
+
+// Get returns the webhook with the ID specified, including its secret.
+func (w *webhooks) Get(id uint64) (Webhook, error) {
+	query := "SELECT id, api_key_id, url, secret, events, created_at FROM webhooks WHERE id=?"
+	stmt, err := w.db.Prepare(query)
+	if err != nil {
+		return Webhook{}, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	webhook, err := scanWebhook(stmt.QueryRow(id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Webhook{}, ErrNoWebhook
+		}
+		return Webhook{}, errors.Wrap(err, "getting webhook")
+	}
+
+	return webhook, nil
+}
+
+// List returns the webhooks of an API key, 0 lists the webhooks of every key.
+func (w *webhooks) List(apiKeyID uint64) ([]Webhook, error) {
+	query := "SELECT id, api_key_id, url, secret, events, created_at FROM webhooks"
+	args := []any{}
+	if apiKeyID != 0 {
+		query += " WHERE api_key_id=?"
+		args = append(args, apiKeyID)
+	}
+
+	return w.list(query+" ORDER BY id", args...)
+}
+
+// ListByEvent returns the webhooks subscribed to the event.
+func (w *webhooks) ListByEvent(event string) ([]Webhook, error) {
+	query := `SELECT id, api_key_id, url, secret, events, created_at FROM webhooks
+	WHERE ',' || events || ',' LIKE '%,' || ? || ',%' ORDER BY id`
+	return w.list(query, event)
+}
+
+// ListDeliveries returns the log of the deliveries to a webhook, the newest first.
+func (w *webhooks) ListDeliveries(webhookID, offset, limit uint64) ([]WebhookDelivery, error) {
+	query := `SELECT id, webhook_id, delivery_id, event, status_code, error, created_at
+	FROM webhook_deliveries WHERE webhook_id=?`
+	query = AddPagination(query, offset, limit, "id", true)
+
+	stmt, err := w.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(webhookID)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing webhook deliveries")
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	// Reuse object
+	var delivery WebhookDelivery
+	for rows.Next() {
+		err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.DeliveryID, &delivery.Event,
+			&delivery.StatusCode, &delivery.Error, &delivery.CreatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+func (w *webhooks) list(query string, args ...any) ([]Webhook, error) {
+	stmt, err := w.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing webhooks")
+	}
+	defer rows.Close()
+
+	var webhooks []Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+func scanWebhook(row scanner) (Webhook, error) {
+	var (
+		webhook Webhook
+		events  string
+	)
+	err := row.Scan(&webhook.ID, &webhook.APIKeyID, &webhook.URL, &webhook.Secret, &events,
+		&webhook.CreatedAt)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	if events != "" {
+		webhook.Events = strings.Split(events, ",")
+	}
+
+	return webhook, nil
+}
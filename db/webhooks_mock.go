@@ -0,0 +1,55 @@
+package db
+
+import "github.com/stretchr/testify/mock"
+
+// WebhooksStoreMock is a mocked implementation of the webhooks store.
+type WebhooksStoreMock struct {
+	mock.Mock
+}
+
+// NewWebhooksStoreMock returns a mocked webhooks store.
+func NewWebhooksStoreMock() *WebhooksStoreMock {
+	return &WebhooksStoreMock{}
+}
+
+// Add mock.
+func (w *WebhooksStoreMock) Add(apiKeyID uint64, url, secret string, events []string) (Webhook, error) {
+	args := w.Called(apiKeyID, url, secret, events)
+	return args.Get(0).(Webhook), args.Error(1)
+}
+
+// AddDelivery mock.
+func (w *WebhooksStoreMock) AddDelivery(delivery WebhookDelivery) error {
+	args := w.Called(delivery)
+	return args.Error(0)
+}
+
+// Delete mock.
+func (w *WebhooksStoreMock) Delete(id, apiKeyID uint64) error {
+	args := w.Called(id, apiKeyID)
+	return args.Error(0)
+}
+
+// Get mock.
+func (w *WebhooksStoreMock) Get(id uint64) (Webhook, error) {
+	args := w.Called(id)
+	return args.Get(0).(Webhook), args.Error(1)
+}
+
+// List mock.
+func (w *WebhooksStoreMock) List(apiKeyID uint64) ([]Webhook, error) {
+	args := w.Called(apiKeyID)
+	return args.Get(0).([]Webhook), args.Error(1)
+}
+
+// ListByEvent mock.
+func (w *WebhooksStoreMock) ListByEvent(event string) ([]Webhook, error) {
+	args := w.Called(event)
+	return args.Get(0).([]Webhook), args.Error(1)
+}
+
+// ListDeliveries mock.
+func (w *WebhooksStoreMock) ListDeliveries(webhookID, offset, limit uint64) ([]WebhookDelivery, error) {
+	args := w.Called(webhookID, offset, limit)
+	return args.Get(0).([]WebhookDelivery), args.Error(1)
+}
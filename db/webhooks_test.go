@@ -0,0 +1,135 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	database "github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WebhooksSuite struct {
+	suite.Suite
+
+	db     *database.DB
+	apiKey database.APIKey
+}
+
+func TestWebhooksSuite(t *testing.T) {
+	suite.Run(t, &WebhooksSuite{})
+}
+
+func (s *WebhooksSuite) SetupTest() {
+	s.db = setupDB(s.T(), func(db *sql.DB) {})
+
+	apiKey, err := s.db.APIKeys.Add("integrator", "hash", []database.Scope{database.ScopeWebhooks}, 0)
+	s.NoError(err)
+	s.apiKey = apiKey
+}
+
+func (s *WebhooksSuite) TestAdd() {
+	webhook, err := s.db.Webhooks.Add(s.apiKey.ID, "https://example.com", "secret",
+		[]string{"draw_completed", "payout_sent"})
+	s.NoError(err)
+
+	got, err := s.db.Webhooks.Get(webhook.ID)
+	s.NoError(err)
+	s.Equal(webhook, got)
+}
+
+func (s *WebhooksSuite) TestList() {
+	first, err := s.db.Webhooks.Add(s.apiKey.ID, "https://example.com/1", "secret", []string{"draw_completed"})
+	s.NoError(err)
+	second, err := s.db.Webhooks.Add(s.apiKey.ID+1, "https://example.com/2", "secret", []string{"payout_sent"})
+	s.NoError(err)
+
+	webhooks, err := s.db.Webhooks.List(s.apiKey.ID)
+	s.NoError(err)
+	s.Equal([]database.Webhook{first}, webhooks)
+
+	webhooks, err = s.db.Webhooks.List(0)
+	s.NoError(err)
+	s.Equal([]database.Webhook{first, second}, webhooks)
+}
+
+func (s *WebhooksSuite) TestListByEvent() {
+	webhook, err := s.db.Webhooks.Add(s.apiKey.ID, "https://example.com", "secret",
+		[]string{"draw_completed", "payout_sent"})
+	s.NoError(err)
+	_, err = s.db.Webhooks.Add(s.apiKey.ID, "https://example.com", "secret", []string{"winner_announced"})
+	s.NoError(err)
+
+	webhooks, err := s.db.Webhooks.ListByEvent("payout_sent")
+	s.NoError(err)
+	s.Equal([]database.Webhook{webhook}, webhooks)
+
+	webhooks, err = s.db.Webhooks.ListByEvent("payout")
+	s.NoError(err)
+	s.Empty(webhooks)
+}
+
+func (s *WebhooksSuite) TestDeliveries() {
+	webhook, err := s.db.Webhooks.Add(s.apiKey.ID, "https://example.com", "secret", []string{"draw_completed"})
+	s.NoError(err)
+
+	now := time.Now().Unix()
+	failed := database.WebhookDelivery{
+		WebhookID:  webhook.ID,
+		DeliveryID: "delivery",
+		Event:      "draw_completed",
+		StatusCode: 500,
+		Error:      "responded with status 500",
+		CreatedAt:  now,
+	}
+	s.NoError(s.db.Webhooks.AddDelivery(failed))
+	succeeded := failed
+	succeeded.StatusCode = 200
+	succeeded.Error = ""
+	succeeded.CreatedAt = now + 30
+	s.NoError(s.db.Webhooks.AddDelivery(succeeded))
+
+	deliveries, err := s.db.Webhooks.ListDeliveries(webhook.ID, 0, 10)
+	s.NoError(err)
+	s.Len(deliveries, 2)
+	// Newest first
+	s.Equal(200, deliveries[0].StatusCode)
+	s.Equal("responded with status 500", deliveries[1].Error)
+
+	deliveries, err = s.db.Webhooks.ListDeliveries(webhook.ID, deliveries[0].ID, 10)
+	s.NoError(err)
+	s.Len(deliveries, 1)
+}
+
+func (s *WebhooksSuite) TestDelete() {
+	webhook, err := s.db.Webhooks.Add(s.apiKey.ID, "https://example.com", "secret", []string{"draw_completed"})
+	s.NoError(err)
+	err = s.db.Webhooks.AddDelivery(database.WebhookDelivery{WebhookID: webhook.ID, DeliveryID: "delivery"})
+	s.NoError(err)
+
+	// Other API keys can't delete it
+	err = s.db.Webhooks.Delete(webhook.ID, s.apiKey.ID+1)
+	s.ErrorIs(err, database.ErrNoWebhook)
+
+	err = s.db.Webhooks.Delete(webhook.ID, s.apiKey.ID)
+	s.NoError(err)
+
+	_, err = s.db.Webhooks.Get(webhook.ID)
+	s.ErrorIs(err, database.ErrNoWebhook)
+
+	deliveries, err := s.db.Webhooks.ListDeliveries(webhook.ID, 0, 0)
+	s.NoError(err)
+	s.Empty(deliveries)
+}
+
+func (s *WebhooksSuite) TestRevokeAPIKey() {
+	webhook, err := s.db.Webhooks.Add(s.apiKey.ID, "https://example.com", "secret", []string{"draw_completed"})
+	s.NoError(err)
+
+	err = s.db.APIKeys.Revoke(s.apiKey.ID)
+	s.NoError(err)
+
+	_, err = s.db.Webhooks.Get(webhook.ID)
+	s.ErrorIs(err, database.ErrNoWebhook)
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"database/sql"
+	"strings"
 
 	"github.com/aftermath2/BTRY/logger"
 
@@ -12,13 +13,60 @@ import (
 type WinnersStore interface {
 	Add(lotteryHeight uint32, winners []Winner) error
 	List(lotteryHeight uint32) ([]Winner, error)
+	ListHeightsAfter(lotteryHeight uint32, limit uint64) ([]uint32, error)
+	ListUnclaimed() ([]UnclaimedPrize, error)
+	Search(filter WinnersFilter, offset, limit uint64, reverse bool) ([]Winner, error)
+	SetReminded(publicKey string, lotteryHeight, blockHeight uint32) error
+	Store(lotteryHeight uint32, winners []Winner, reveal *Reveal) error
 }
 
+// Claim statuses of the prizes won.
+const (
+	ClaimClaimed   = "claimed"
+	ClaimUnclaimed = "unclaimed"
+	ClaimExpired   = "expired"
+)
+
 // Winner represents a user that had a winning ticket.
 type Winner struct {
 	PublicKey string `json:"public_key,omitempty" db:"public_key"`
 	Prize     uint64 `json:"prize,omitempty"`
 	Ticket    uint64 `json:"ticket,omitempty"`
+	// ID and LotteryHeight are only set by Search, the ID is the cursor of its pages
+	ID            uint64 `json:"id,omitempty"`
+	LotteryHeight uint32 `json:"lottery_height,omitempty" db:"lottery_height"`
+	// Status is the claim status of the prizes won in the lottery, only set by Search
+	Status string `json:"status,omitempty"`
+	// Pool is the lottery pool the ticket belongs to
+	Pool string `json:"pool,omitempty"`
+	// ClaimDeadline is the block height at which the prize expires
+	ClaimDeadline uint32 `json:"claim_deadline,omitempty" db:"claim_deadline"`
+	// Alias is the name displayed instead of the public key, if the winner chose one
+	Alias string `json:"alias,omitempty"`
+	// Tier is the position of the prize in the pool distribution, starting from zero
+	Tier uint32 `json:"-"`
+}
+
+// UnclaimedPrize is the amount a winner has yet to claim from the prizes won in a lottery.
+type UnclaimedPrize struct {
+	PublicKey     string
+	Amount        uint64
+	LotteryHeight uint32
+	ClaimDeadline uint32
+	// LastRemindedAt is the block height at which the winner was last reminded to claim it
+	LastRemindedAt uint32
+}
+
+// WinnersFilter narrows down the winners searched, its zero value matches all of them.
+type WinnersFilter struct {
+	PublicKey string
+	// Status is one of the claim statuses
+	Status     string
+	FromHeight uint32
+	// ToHeight is inclusive, zero means there's no upper bound
+	ToHeight uint32
+	// Revealed excludes the winners of the tiers that are still hidden
+	Revealed bool
 }
 
 type winners struct {
@@ -36,22 +84,88 @@ func newWinnersStore(db *sql.DB, logger *logger.Logger) WinnersStore {
 
 // Add adds winners to the database.
 func (w *winners) Add(lotteryHeight uint32, winners []Winner) error {
-	query := "INSERT INTO winners (public_key, prize, ticket, lottery_height) VALUES "
-	values := BulkInsertValues(len(winners), 4)
+	tx, err := w.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	if err := addWinners(tx, lotteryHeight, winners); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Store saves the winners of a draw along with their prizes, the draw lock and, when the winners
+// are revealed gradually, the reveal in a single transaction, so the results are either stored
+// completely or not at all and the draw can be retried. ErrLotteryDrawn is returned if the results
+// were already stored.
+func (w *winners) Store(lotteryHeight uint32, winners []Winner, reveal *Reveal) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	var drawn bool
+	if err := tx.QueryRow(drawnQuery, lotteryHeight).Scan(&drawn); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrLotteryNotFound
+		}
+		return errors.Wrap(err, "checking draw")
+	}
+	if drawn {
+		return ErrLotteryDrawn
+	}
+
+	if _, err := tx.Exec("UPDATE lotteries SET draw_locked=1 WHERE height=?", lotteryHeight); err != nil {
+		return errors.Wrap(err, "locking draw")
+	}
+
+	if err := addWinners(tx, lotteryHeight, winners); err != nil {
+		return err
+	}
+
+	if err := setPrizes(tx, lotteryHeight, winners); err != nil {
+		return err
+	}
+
+	if reveal != nil {
+		if err := addReveal(tx, *reveal); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
+	return nil
+}
+
+// addWinners inserts the winners of the lottery inside the transaction.
+func addWinners(tx *sql.Tx, lotteryHeight uint32, winners []Winner) error {
+	query := `INSERT INTO winners (public_key, prize, ticket, lottery_height, claim_deadline, pool, tier)
+	VALUES `
+	values := BulkInsertValues(len(winners), 7)
 	query += values
 
-	stmt, err := w.db.Prepare(query)
+	stmt, err := tx.Prepare(query)
 	if err != nil {
 		return errors.Wrap(err, "preparing statement")
 	}
 	defer stmt.Close()
 
-	args := make([]any, 0, len(winners)*4)
+	args := make([]any, 0, len(winners)*7)
 	for _, winner := range winners {
 		args = append(args, winner.PublicKey)
 		args = append(args, winner.Prize)
 		args = append(args, winner.Ticket)
 		args = append(args, lotteryHeight)
+		args = append(args, winner.ClaimDeadline)
+		args = append(args, winner.Pool)
+		args = append(args, winner.Tier)
 	}
 
 	if _, err := stmt.Exec(args...); err != nil {
@@ -63,7 +177,8 @@ func (w *winners) Add(lotteryHeight uint32, winners []Winner) error {
 
 // List returns the winners from the lottery at the lottery height specified.
 func (w *winners) List(lotteryHeight uint32) ([]Winner, error) {
-	query := "SELECT public_key, prize, ticket FROM winners WHERE lottery_height=?"
+	query := `SELECT public_key, prize, ticket, claim_deadline, pool, tier FROM winners
+	WHERE lottery_height=?`
 	stmt, err := w.db.Prepare(query)
 	if err != nil {
 		return nil, errors.Wrap(err, "preparing statement")
@@ -82,7 +197,9 @@ func (w *winners) List(lotteryHeight uint32) ([]Winner, error) {
 		winner Winner
 	)
 	for rows.Next() {
-		if err := rows.Scan(&winner.PublicKey, &winner.Prize, &winner.Ticket); err != nil {
+		err := rows.Scan(&winner.PublicKey, &winner.Prize, &winner.Ticket, &winner.ClaimDeadline,
+			&winner.Pool, &winner.Tier)
+		if err != nil {
 			return nil, errors.Wrap(err, "scanning rows")
 		}
 
@@ -91,3 +208,167 @@ func (w *winners) List(lotteryHeight uint32) ([]Winner, error) {
 
 	return winners, nil
 }
+
+// ListHeightsAfter returns the heights of the lotteries drawn after the one specified that had
+// winners, in ascending order. Winners restored after a failed payment have no ticket and are
+// skipped.
+func (w *winners) ListHeightsAfter(lotteryHeight uint32, limit uint64) ([]uint32, error) {
+	query := `SELECT DISTINCT lottery_height FROM winners WHERE lottery_height > ? AND ticket != 0
+	ORDER BY lottery_height LIMIT ?`
+	stmt, err := w.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(lotteryHeight, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "selecting lottery heights")
+	}
+	defer rows.Close()
+
+	var heights []uint32
+	for rows.Next() {
+		var height uint32
+		if err := rows.Scan(&height); err != nil {
+			return nil, errors.Wrap(err, "scanning lottery height")
+		}
+		heights = append(heights, height)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating lottery heights")
+	}
+
+	return heights, nil
+}
+
+// ListUnclaimed returns the prizes that were neither claimed nor expired, grouped by winner and
+// lottery. Prizes without a claim deadline, like refunds, are not included.
+func (w *winners) ListUnclaimed() ([]UnclaimedPrize, error) {
+	query := `SELECT w.public_key, w.lottery_height, MAX(w.claim_deadline), MAX(w.last_reminded_at),
+	(SELECT COALESCE(SUM(p.amount), 0) FROM prizes p
+		WHERE p.public_key=w.public_key AND p.lottery_height=w.lottery_height AND p.expired=0) AS amount
+	FROM winners w WHERE w.claim_deadline != 0
+	GROUP BY w.public_key, w.lottery_height HAVING amount > 0
+	ORDER BY w.lottery_height`
+	stmt, err := w.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, errors.Wrap(err, "selecting unclaimed prizes")
+	}
+	defer rows.Close()
+
+	var prizes []UnclaimedPrize
+	for rows.Next() {
+		var prize UnclaimedPrize
+		err := rows.Scan(&prize.PublicKey, &prize.LotteryHeight, &prize.ClaimDeadline,
+			&prize.LastRemindedAt, &prize.Amount)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		prizes = append(prizes, prize)
+	}
+
+	return prizes, nil
+}
+
+// Search returns the winners matching the filter. The offset is the ID of the winner to start
+// after. Winners restored after a failed payment have no ticket and are skipped.
+//
+// The claim status is derived from the prizes of the winner in the lottery: they are unclaimed if
+// there's an amount left that didn't expire, expired if there's one that did and claimed otherwise.
+//
+// A limit value of 0 means there's no limit.
+func (w *winners) Search(filter WinnersFilter, offset, limit uint64, reverse bool) ([]Winner, error) {
+	// Cap limit to avoid creating a slice with too big capacity
+	if limit > 500 {
+		limit = 500
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`SELECT w.rowid, w.public_key, w.prize, w.ticket, w.lottery_height, w.claim_deadline,
+	w.pool, w.tier, CASE
+		WHEN EXISTS (SELECT 1 FROM prizes p WHERE p.public_key=w.public_key
+			AND p.lottery_height=w.lottery_height AND p.amount > 0 AND p.expired=0) THEN 'unclaimed'
+		WHEN EXISTS (SELECT 1 FROM prizes p WHERE p.public_key=w.public_key
+			AND p.lottery_height=w.lottery_height AND p.amount > 0 AND p.expired=1) THEN 'expired'
+		ELSE 'claimed' END AS status
+	FROM winners w WHERE w.ticket != 0`)
+
+	var args []any
+	if filter.PublicKey != "" {
+		sb.WriteString(" AND w.public_key=?")
+		args = append(args, filter.PublicKey)
+	}
+	if filter.FromHeight > 0 {
+		sb.WriteString(" AND w.lottery_height >= ?")
+		args = append(args, filter.FromHeight)
+	}
+	if filter.ToHeight > 0 {
+		sb.WriteString(" AND w.lottery_height <= ?")
+		args = append(args, filter.ToHeight)
+	}
+	if filter.Status != "" {
+		sb.WriteString(" AND status=?")
+		args = append(args, filter.Status)
+	}
+	if filter.Revealed {
+		sb.WriteString(` AND w.tier >= COALESCE(
+		(SELECT r.hidden FROM reveals r WHERE r.lottery_height=w.lottery_height), 0)`)
+	}
+
+	query := AddPagination(sb.String(), offset, limit, "w.rowid", reverse)
+	stmt, err := w.db.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching winners")
+	}
+	defer rows.Close()
+
+	var winners []Winner
+	for rows.Next() {
+		var winner Winner
+		err := rows.Scan(&winner.ID, &winner.PublicKey, &winner.Prize, &winner.Ticket,
+			&winner.LotteryHeight, &winner.ClaimDeadline, &winner.Pool, &winner.Tier, &winner.Status)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+
+		winners = append(winners, winner)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating winners")
+	}
+
+	return winners, nil
+}
+
+// SetReminded records the block height at which the winner was reminded to claim the prizes won in
+// the lottery.
+func (w *winners) SetReminded(publicKey string, lotteryHeight, blockHeight uint32) error {
+	query := "UPDATE winners SET last_reminded_at=? WHERE public_key=? AND lottery_height=?"
+	stmt, err := w.db.Prepare(query)
+	if err != nil {
+		return errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(blockHeight, publicKey, lotteryHeight); err != nil {
+		return errors.Wrap(err, "updating winners")
+	}
+
+	return nil
+}
@@ -28,3 +28,48 @@ func (w *WinnersStoreMock) List(height uint32) ([]Winner, error) {
 	}
 	return r0, args.Error(1)
 }
+
+// ListHeightsAfter mock.
+func (w *WinnersStoreMock) ListHeightsAfter(lotteryHeight uint32, limit uint64) ([]uint32, error) {
+	args := w.Called(lotteryHeight, limit)
+	var r0 []uint32
+	v0 := args.Get(0)
+	if v0 != nil {
+		r0 = v0.([]uint32)
+	}
+	return r0, args.Error(1)
+}
+
+// ListUnclaimed mock.
+func (w *WinnersStoreMock) ListUnclaimed() ([]UnclaimedPrize, error) {
+	args := w.Called()
+	var r0 []UnclaimedPrize
+	v0 := args.Get(0)
+	if v0 != nil {
+		r0 = v0.([]UnclaimedPrize)
+	}
+	return r0, args.Error(1)
+}
+
+// Search mock.
+func (w *WinnersStoreMock) Search(filter WinnersFilter, offset, limit uint64, reverse bool) ([]Winner, error) {
+	args := w.Called(filter, offset, limit, reverse)
+	var r0 []Winner
+	v0 := args.Get(0)
+	if v0 != nil {
+		r0 = v0.([]Winner)
+	}
+	return r0, args.Error(1)
+}
+
+// SetReminded mock.
+func (w *WinnersStoreMock) SetReminded(publicKey string, lotteryHeight, blockHeight uint32) error {
+	args := w.Called(publicKey, lotteryHeight, blockHeight)
+	return args.Error(0)
+}
+
+// Store mock.
+func (w *WinnersStoreMock) Store(lotteryHeight uint32, winners []Winner, reveal *Reveal) error {
+	args := w.Called(lotteryHeight, winners, reveal)
+	return args.Error(0)
+}
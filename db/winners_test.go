@@ -28,7 +28,10 @@ const lotteryHeight uint32 = 1
 type WinnersSuite struct {
 	suite.Suite
 
-	db database.WinnersStore
+	db        database.WinnersStore
+	lotteries database.LotteriesStore
+	prizes    database.PrizesStore
+	reveals   database.RevealsStore
 }
 
 func TestWinnersSuite(t *testing.T) {
@@ -46,16 +49,65 @@ func (w *WinnersSuite) SetupTest() {
 		w.NoError(err)
 	})
 	w.db = db.Winners
+	w.lotteries = db.Lotteries
+	w.prizes = db.Prizes
+	w.reveals = db.Reveals
 }
 
 func (w *WinnersSuite) TestAddWinners() {
 	winner := database.Winner{
-		PublicKey: "pubKey",
-		Prize:     2016,
-		Ticket:    21,
+		PublicKey:     "pubKey",
+		Prize:         2016,
+		Ticket:        21,
+		ClaimDeadline: lotteryHeight + 720,
+		Pool:          "whale",
 	}
 	err := w.db.Add(lotteryHeight, []database.Winner{winner})
 	w.NoError(err)
+
+	winners, err := w.db.List(lotteryHeight)
+	w.NoError(err)
+	w.Contains(winners, winner)
+}
+
+func (w *WinnersSuite) TestStore() {
+	height := lotteryHeight + 144
+	w.NoError(w.lotteries.AddHeight(height, "", ""))
+	reveal := database.Reveal{LotteryHeight: height, Tiers: 1, Hidden: 1, Blocks: 6}
+	w.NoError(w.reveals.Add(reveal))
+
+	// The reveal was stored already, none of the results are
+	err := w.db.Store(height, []database.Winner{testWinner2}, &reveal)
+	w.Error(err)
+
+	winners, err := w.db.List(height)
+	w.NoError(err)
+	w.Empty(winners)
+
+	prizes, err := w.prizes.Get(testWinner2.PublicKey)
+	w.NoError(err)
+	w.Zero(prizes)
+
+	w.NoError(w.db.Store(height, []database.Winner{testWinner2}, nil))
+
+	winners, err = w.db.List(height)
+	w.NoError(err)
+	w.Len(winners, 1)
+
+	prizes, err = w.prizes.Get(testWinner2.PublicKey)
+	w.NoError(err)
+	w.Equal(testWinner2.Prize, prizes)
+
+	// The draw was locked along with the results
+	drawn, err := w.lotteries.IsDrawn(height)
+	w.NoError(err)
+	w.True(drawn)
+
+	err = w.db.Store(height, []database.Winner{testWinner}, nil)
+	w.ErrorIs(err, database.ErrLotteryDrawn)
+
+	err = w.db.Store(height+144, []database.Winner{testWinner2}, nil)
+	w.ErrorIs(err, database.ErrLotteryNotFound)
 }
 
 func (w *WinnersSuite) TestList() {
@@ -65,3 +117,175 @@ func (w *WinnersSuite) TestList() {
 	w.Len(winners, 1)
 	w.Equal(testWinner, winners[0])
 }
+
+func (w *WinnersSuite) TestListHeightsAfter() {
+	for _, height := range []uint32{144, 288, 432} {
+		w.NoError(w.lotteries.AddHeight(height, "", ""))
+	}
+	w.NoError(w.db.Add(288, []database.Winner{testWinner, testWinner2}))
+	w.NoError(w.db.Add(144, []database.Winner{testWinner2}))
+	// Restored prizes are not draws
+	w.NoError(w.db.Add(432, []database.Winner{{PublicKey: "restored", Prize: 10}}))
+
+	heights, err := w.db.ListHeightsAfter(0, 10)
+	w.NoError(err)
+	w.Equal([]uint32{lotteryHeight, 144, 288}, heights)
+
+	heights, err = w.db.ListHeightsAfter(lotteryHeight, 1)
+	w.NoError(err)
+	w.Equal([]uint32{144}, heights)
+
+	heights, err = w.db.ListHeightsAfter(288, 10)
+	w.NoError(err)
+	w.Empty(heights)
+}
+
+func (w *WinnersSuite) TestListUnclaimed() {
+	winner := database.Winner{
+		PublicKey:     "pubKey",
+		Prize:         2016,
+		Ticket:        21,
+		ClaimDeadline: lotteryHeight + 720,
+	}
+	err := w.db.Add(lotteryHeight, []database.Winner{winner, winner})
+	w.NoError(err)
+
+	err = w.prizes.Set(lotteryHeight, []database.Winner{winner, winner, testWinner})
+	w.NoError(err)
+
+	prizes, err := w.db.ListUnclaimed()
+	w.NoError(err)
+
+	// The test winner has no claim deadline
+	expected := []database.UnclaimedPrize{
+		{
+			PublicKey:     winner.PublicKey,
+			Amount:        winner.Prize * 2,
+			LotteryHeight: lotteryHeight,
+			ClaimDeadline: winner.ClaimDeadline,
+		},
+	}
+	w.Equal(expected, prizes)
+
+	_, err = w.prizes.Withdraw(winner.PublicKey, winner.Prize*2)
+	w.NoError(err)
+
+	prizes, err = w.db.ListUnclaimed()
+	w.NoError(err)
+	w.Empty(prizes)
+}
+
+func (w *WinnersSuite) TestSearch() {
+	for _, height := range []uint32{144, 288, 432} {
+		w.NoError(w.lotteries.AddHeight(height, "", ""))
+	}
+	second := testWinner2
+	second.Tier = 1
+	w.NoError(w.db.Add(144, []database.Winner{testWinner}))
+	w.NoError(w.db.Add(288, []database.Winner{testWinner, second}))
+	w.NoError(w.db.Add(432, []database.Winner{{PublicKey: "restored", Prize: 10}}))
+	w.NoError(w.prizes.Set(144, []database.Winner{testWinner}))
+	w.NoError(w.prizes.Set(288, []database.Winner{testWinner, second}))
+	_, err := w.prizes.Expire(144)
+	w.NoError(err)
+	w.NoError(w.reveals.Add(database.Reveal{LotteryHeight: 288, Tiers: 2, Hidden: 1, Blocks: 6}))
+
+	// The winner from the setup has no prizes left
+	all := []database.Winner{
+		{ID: 1, PublicKey: testWinner.PublicKey, Prize: testWinner.Prize, Ticket: testWinner.Ticket,
+			LotteryHeight: lotteryHeight, Status: database.ClaimClaimed},
+		{ID: 2, PublicKey: testWinner.PublicKey, Prize: testWinner.Prize, Ticket: testWinner.Ticket,
+			LotteryHeight: 144, Status: database.ClaimExpired},
+		{ID: 3, PublicKey: testWinner.PublicKey, Prize: testWinner.Prize, Ticket: testWinner.Ticket,
+			LotteryHeight: 288, Status: database.ClaimUnclaimed},
+		{ID: 4, PublicKey: second.PublicKey, Prize: second.Prize, Ticket: second.Ticket,
+			LotteryHeight: 288, Status: database.ClaimUnclaimed, Tier: 1},
+	}
+
+	cases := []struct {
+		desc     string
+		filter   database.WinnersFilter
+		offset   uint64
+		limit    uint64
+		reverse  bool
+		expected []database.Winner
+	}{
+		{
+			desc:     "All",
+			expected: all,
+		},
+		{
+			desc:     "Public key",
+			filter:   database.WinnersFilter{PublicKey: testWinner2.PublicKey},
+			expected: all[3:],
+		},
+		{
+			desc:     "Height range",
+			filter:   database.WinnersFilter{FromHeight: 100, ToHeight: 200},
+			expected: all[1:2],
+		},
+		{
+			desc:     "From height",
+			filter:   database.WinnersFilter{FromHeight: 144},
+			expected: all[1:],
+		},
+		{
+			desc:     "Unclaimed",
+			filter:   database.WinnersFilter{Status: database.ClaimUnclaimed},
+			expected: all[2:],
+		},
+		{
+			desc:     "Expired",
+			filter:   database.WinnersFilter{PublicKey: testWinner.PublicKey, Status: database.ClaimExpired},
+			expected: all[1:2],
+		},
+		{
+			desc:     "Revealed",
+			filter:   database.WinnersFilter{FromHeight: 288, Revealed: true},
+			expected: all[3:],
+		},
+		{
+			desc:     "Page",
+			offset:   1,
+			limit:    2,
+			expected: all[1:3],
+		},
+		{
+			desc:     "Reverse page",
+			offset:   4,
+			limit:    1,
+			reverse:  true,
+			expected: all[2:3],
+		},
+	}
+
+	for _, tc := range cases {
+		w.Run(tc.desc, func() {
+			winners, err := w.db.Search(tc.filter, tc.offset, tc.limit, tc.reverse)
+			w.NoError(err)
+			w.Equal(tc.expected, winners)
+		})
+	}
+}
+
+func (w *WinnersSuite) TestSetReminded() {
+	winner := database.Winner{
+		PublicKey:     "pubKey",
+		Prize:         2016,
+		Ticket:        21,
+		ClaimDeadline: lotteryHeight + 720,
+	}
+	err := w.db.Add(lotteryHeight, []database.Winner{winner})
+	w.NoError(err)
+
+	err = w.prizes.Set(lotteryHeight, []database.Winner{winner})
+	w.NoError(err)
+
+	err = w.db.SetReminded(winner.PublicKey, lotteryHeight, lotteryHeight+360)
+	w.NoError(err)
+
+	prizes, err := w.db.ListUnclaimed()
+	w.NoError(err)
+	w.Len(prizes, 1)
+	w.Equal(lotteryHeight+360, prizes[0].LastRemindedAt)
+}
@@ -0,0 +1,121 @@
+// Package fault classifies the errors of the lottery, database and lightning modules by how the
+// callers should react to them: transient failures are retried, permanent ones and conflicts with
+// the current state are not.
+package fault
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Kind of failure.
+type Kind uint8
+
+// Kinds of failure. Unknown is the kind of the errors that weren't classified.
+const (
+	Unknown Kind = iota
+	// Transient failures are expected to go away, like a timeout or an unreachable node
+	Transient
+	// Permanent failures won't succeed by repeating the same operation
+	Permanent
+	// Conflict failures are caused by the current state, like a row that was already stored
+	Conflict
+)
+
+// String returns the name of the kind.
+func (k Kind) String() string {
+	switch k {
+	case Transient:
+		return "transient"
+	case Permanent:
+		return "permanent"
+	case Conflict:
+		return "conflict"
+	default:
+		return "unknown"
+	}
+}
+
+// Error is an error classified with its kind.
+type Error struct {
+	Err  error
+	Kind Kind
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error classified.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap classifies the error with the kind specified, nil errors are returned as is.
+func Wrap(err error, kind Kind) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Err: err, Kind: kind}
+}
+
+// NewTransient classifies the error as transient.
+func NewTransient(err error) error {
+	return Wrap(err, Transient)
+}
+
+// NewPermanent classifies the error as permanent.
+func NewPermanent(err error) error {
+	return Wrap(err, Permanent)
+}
+
+// NewConflict classifies the error as a conflict.
+func NewConflict(err error) error {
+	return Wrap(err, Conflict)
+}
+
+// KindOf returns the kind of the outermost classification of the error.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return Unknown
+}
+
+// IsTransient returns whether the error is classified as transient.
+func IsTransient(err error) bool {
+	return KindOf(err) == Transient
+}
+
+// Policy configures how many times an operation is attempted and how long to wait between the
+// attempts, the delay is doubled after each of them.
+type Policy struct {
+	Attempts int
+	Delay    time.Duration
+}
+
+// DefaultPolicy is the policy used for the operations retried in place.
+var DefaultPolicy = Policy{Attempts: 3, Delay: time.Second}
+
+// Retry executes the function until it succeeds, it fails with an error that isn't transient or
+// the attempts are exhausted, returning the last error.
+func Retry(ctx context.Context, policy Policy, fn func() error) error {
+	delay := policy.Delay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !IsTransient(err) || attempt >= policy.Attempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,96 @@
+package fault_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/fault"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKindOf(t *testing.T) {
+	err := errors.New("test")
+
+	assert.Equal(t, fault.Unknown, fault.KindOf(err))
+	assert.Equal(t, fault.Unknown, fault.KindOf(nil))
+	assert.Equal(t, fault.Transient, fault.KindOf(fault.NewTransient(err)))
+	assert.Equal(t, fault.Permanent, fault.KindOf(fault.NewPermanent(err)))
+	assert.Equal(t, fault.Conflict, fault.KindOf(fault.NewConflict(err)))
+	assert.NoError(t, fault.NewTransient(nil))
+
+	t.Run("Wrapped", func(t *testing.T) {
+		wrapped := errors.Wrap(fault.NewTransient(err), "context")
+		assert.True(t, fault.IsTransient(wrapped))
+		assert.ErrorIs(t, wrapped, err)
+		assert.Equal(t, "context: test", wrapped.Error())
+	})
+
+	t.Run("Reclassified", func(t *testing.T) {
+		reclassified := fault.NewPermanent(errors.Wrap(fault.NewTransient(err), "context"))
+		assert.Equal(t, fault.Permanent, fault.KindOf(reclassified))
+	})
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	policy := fault.Policy{Attempts: 3}
+
+	t.Run("Transient", func(t *testing.T) {
+		calls := 0
+		err := fault.Retry(ctx, policy, func() error {
+			calls++
+			if calls < 3 {
+				return fault.NewTransient(errors.New("timeout"))
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("Attempts exhausted", func(t *testing.T) {
+		calls := 0
+		err := fault.Retry(ctx, policy, func() error {
+			calls++
+			return fault.NewTransient(errors.New("timeout"))
+		})
+		assert.True(t, fault.IsTransient(err))
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("Permanent", func(t *testing.T) {
+		calls := 0
+		err := fault.Retry(ctx, policy, func() error {
+			calls++
+			return fault.NewPermanent(errors.New("invalid"))
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Unclassified", func(t *testing.T) {
+		calls := 0
+		err := fault.Retry(ctx, policy, func() error {
+			calls++
+			return errors.New("unknown")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		calls := 0
+		err := fault.Retry(ctx, fault.Policy{Attempts: 3, Delay: time.Hour}, func() error {
+			calls++
+			return fault.NewTransient(errors.New("timeout"))
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+}
@@ -0,0 +1,143 @@
+// Package errors defines the structured errors the API responds with, so clients can branch on a
+// machine-readable code instead of parsing the message.
+package errors
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/policy"
+)
+
+// Code identifies the kind of error, it's stable across releases and languages.
+type Code string
+
+// Error codes.
+const (
+	CodeInvalidRequest      Code = "INVALID_REQUEST"
+	CodeUnauthorized        Code = "UNAUTHORIZED"
+	CodeForbidden           Code = "FORBIDDEN"
+	CodeNotFound            Code = "NOT_FOUND"
+	CodeConflict            Code = "CONFLICT"
+	CodeRateLimited         Code = "RATE_LIMITED"
+	CodeInternal            Code = "INTERNAL"
+	CodeUnavailable         Code = "UNAVAILABLE"
+	CodeMaintenance         Code = "MAINTENANCE"
+	CodeNotLeader           Code = "NOT_LEADER"
+	CodeCapacityExceeded    Code = "CAPACITY_EXCEEDED"
+	CodeRoundClosed         Code = "ROUND_CLOSED"
+	CodeDrawPostponed       Code = "DRAW_POSTPONED"
+	CodeLimitReached        Code = "LIMIT_REACHED"
+	CodeSelfExcluded        Code = "SELF_EXCLUDED"
+	CodeAccessDenied        Code = "ACCESS_DENIED"
+	CodeJurisdictionBlocked Code = "JURISDICTION_BLOCKED"
+	CodeInsufficientPrizes  Code = "INSUFFICIENT_PRIZES"
+	CodeInvoiceClaimed      Code = "INVOICE_ALREADY_CLAIMED"
+)
+
+// sentinels maps the errors returned by the services to the code they are responded with.
+var sentinels = []struct {
+	err  error
+	code Code
+}{
+	{err: policy.ErrPeerCapExceeded, code: CodeCapacityExceeded},
+	{err: policy.ErrLimitExceeded, code: CodeLimitReached},
+	{err: policy.ErrSelfExcluded, code: CodeSelfExcluded},
+	{err: policy.ErrAccessDenied, code: CodeAccessDenied},
+	{err: policy.ErrJurisdictionBlocked, code: CodeJurisdictionBlocked},
+	{err: db.ErrCancellationExpired, code: CodeRoundClosed},
+	{err: db.ErrInsufficientPrizes, code: CodeInsufficientPrizes},
+	{err: db.ErrInvoiceClaimed, code: CodeInvoiceClaimed},
+}
+
+// statusCodes maps the HTTP status codes to the code used when the error is not a known one.
+var statusCodes = map[int]Code{
+	http.StatusBadRequest:                 CodeInvalidRequest,
+	http.StatusUnauthorized:               CodeUnauthorized,
+	http.StatusForbidden:                  CodeForbidden,
+	http.StatusNotFound:                   CodeNotFound,
+	http.StatusConflict:                   CodeConflict,
+	http.StatusTooManyRequests:            CodeRateLimited,
+	http.StatusUnavailableForLegalReasons: CodeJurisdictionBlocked,
+	http.StatusServiceUnavailable:         CodeUnavailable,
+}
+
+// Error is the structured error returned by the API.
+type Error struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+	// Retryable tells whether the same request may succeed later without changes
+	Retryable bool           `json:"retryable"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// Response is the body of the responses of failed requests.
+type Response struct {
+	Error *Error `json:"error"`
+}
+
+// New returns an error with the code and message provided.
+func New(code Code, message string) *Error {
+	return &Error{
+		Code:      code,
+		Message:   message,
+		Retryable: code.Retryable(),
+	}
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// WithDetail sets a detail of the error and returns it.
+func (e *Error) WithDetail(key string, value any) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]any)
+	}
+	e.Details[key] = value
+	return e
+}
+
+// Retryable returns whether the condition the code describes is expected to be temporary.
+func (c Code) Retryable() bool {
+	switch c {
+	case CodeRateLimited, CodeInternal, CodeUnavailable, CodeMaintenance, CodeNotLeader,
+		CodeCapacityExceeded, CodeRoundClosed, CodeDrawPostponed:
+		return true
+	default:
+		return false
+	}
+}
+
+// From converts err into a structured error. Errors that are already structured are returned as
+// they are, the known ones take their code and the rest are classified by the status code.
+func From(statusCode int, err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	for _, s := range sentinels {
+		if errors.Is(err, s.err) {
+			return New(s.code, err.Error())
+		}
+	}
+
+	code, ok := statusCodes[statusCode]
+	if !ok {
+		code = CodeInternal
+	}
+	return New(code, err.Error())
+}
+
+// Write responds with the error envelope.
+func Write(w http.ResponseWriter, statusCode int, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(Response{Error: From(statusCode, err)}); err != nil {
+		http.Error(w, "failed encoding response body", http.StatusInternalServerError)
+	}
+}
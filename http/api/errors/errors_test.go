@@ -0,0 +1,106 @@
+package errors_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrom(t *testing.T) {
+	capacityErr := apierrors.New(apierrors.CodeCapacityExceeded, "capacity exceeded").
+		WithDetail("capacity", 1000)
+
+	cases := []struct {
+		desc       string
+		err        error
+		statusCode int
+		code       apierrors.Code
+		retryable  bool
+	}{
+		{
+			desc:       "Structured",
+			err:        errors.Wrap(capacityErr, "creating invoice"),
+			statusCode: http.StatusBadRequest,
+			code:       apierrors.CodeCapacityExceeded,
+			retryable:  true,
+		},
+		{
+			desc:       "Known",
+			err:        errors.Wrap(policy.ErrLimitExceeded, "checking limits"),
+			statusCode: http.StatusForbidden,
+			code:       apierrors.CodeLimitReached,
+		},
+		{
+			desc:       "Round closed",
+			err:        db.ErrCancellationExpired,
+			statusCode: http.StatusConflict,
+			code:       apierrors.CodeRoundClosed,
+			retryable:  true,
+		},
+		{
+			desc:       "Insufficient prizes",
+			err:        errors.Wrap(db.ErrInsufficientPrizes, "withdrawing prizes"),
+			statusCode: http.StatusBadRequest,
+			code:       apierrors.CodeInsufficientPrizes,
+		},
+		{
+			desc:       "Invoice claimed",
+			err:        db.ErrInvoiceClaimed,
+			statusCode: http.StatusConflict,
+			code:       apierrors.CodeInvoiceClaimed,
+		},
+		{
+			desc:       "Status code",
+			err:        errors.New("not found"),
+			statusCode: http.StatusNotFound,
+			code:       apierrors.CodeNotFound,
+		},
+		{
+			desc:       "Unknown status code",
+			err:        errors.New("test"),
+			statusCode: http.StatusInternalServerError,
+			code:       apierrors.CodeInternal,
+			retryable:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := apierrors.From(tc.statusCode, tc.err)
+			assert.Equal(t, tc.code, err.Code)
+			assert.Equal(t, tc.retryable, err.Retryable)
+		})
+	}
+
+	assert.Equal(t, capacityErr, apierrors.From(http.StatusBadRequest, capacityErr))
+}
+
+func TestWrite(t *testing.T) {
+	rec := httptest.NewRecorder()
+	err := apierrors.New(apierrors.CodeCapacityExceeded, "capacity exceeded").
+		WithDetail("capacity", 1000)
+	apierrors.Write(rec, http.StatusBadRequest, err)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "application/json; charset=UTF-8", rec.Header().Get("Content-Type"))
+
+	var resp apierrors.Response
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+
+	expected := &apierrors.Error{
+		Code:      apierrors.CodeCapacityExceeded,
+		Message:   "capacity exceeded",
+		Retryable: true,
+		Details:   map[string]any{"capacity": float64(1000)},
+	}
+	assert.Equal(t, expected, resp.Error)
+}
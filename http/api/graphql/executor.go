@@ -0,0 +1,297 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxDepth is the maximum number of nested selection sets, it limits the cost of a single request.
+const maxDepth = 6
+
+// Resolver returns the value of a field given the value of the object it belongs to.
+type Resolver func(ctx context.Context, parent any, args Args) (any, error)
+
+// Subscriber returns a channel that receives the values of a subscription field until the
+// context is cancelled.
+type Subscriber func(ctx context.Context, args Args) (<-chan any, error)
+
+// Object is a type composed of fields.
+type Object struct {
+	Fields map[string]*Field
+	Name   string
+}
+
+// Field of an object.
+//
+// Fields without a resolver take the value of the parent struct field whose JSON name matches.
+// Type is the object returned, or the type of the elements if the value is a slice, and is nil for
+// scalars.
+type Field struct {
+	Resolve   Resolver
+	Subscribe Subscriber
+	Type      *Object
+}
+
+// Schema contains the root types of the operations.
+type Schema struct {
+	Query        *Object
+	Subscription *Object
+}
+
+// Args contains the arguments of a field with the variables replaced.
+type Args map[string]any
+
+// Uint returns the value of an unsigned integer argument, zero if it was not passed.
+func (a Args) Uint(name string) (uint64, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		if v >= 0 {
+			return uint64(v), nil
+		}
+	case float64:
+		if v >= 0 && v == float64(uint64(v)) {
+			return uint64(v), nil
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil && n >= 0 {
+			return uint64(n), nil
+		}
+	}
+	return 0, errors.Errorf("argument %q must be a positive integer", name)
+}
+
+// String returns the value of a string argument, empty if it was not passed.
+func (a Args) String(name string) (string, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", errors.Errorf("argument %q must be a string", name)
+}
+
+// Bool returns the value of a boolean argument, false if it was not passed.
+func (a Args) Bool(name string) (bool, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	}
+	return false, errors.Errorf("argument %q must be a boolean", name)
+}
+
+// Error is an error found executing a request, Path points to the field that failed.
+type Error struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// Response is the result of executing a request.
+type Response struct {
+	Data   any     `json:"data,omitempty"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// fields is an object in the response, they keep the order of the selections.
+type fields struct {
+	keys   []string
+	values []any
+}
+
+// MarshalJSON encodes the fields as a JSON object.
+func (f *fields) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range f.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(f.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// executor resolves the selections of an operation.
+type executor struct {
+	variables map[string]any
+	errors    []Error
+}
+
+// prepare selects the operation to execute and merges the variables passed with the defaults.
+func prepare(document, operationName string, variables map[string]any) (operation, *executor, error) {
+	operations, err := parse(document)
+	if err != nil {
+		return operation{}, nil, err
+	}
+
+	var op *operation
+	for i := range operations {
+		if operationName == "" || operations[i].name == operationName {
+			if op != nil {
+				return operation{}, nil, errors.New("operation name is required when the document contains many")
+			}
+			op = &operations[i]
+		}
+	}
+	if op == nil {
+		return operation{}, nil, errors.Errorf("operation %q not found", operationName)
+	}
+
+	values := make(map[string]any, len(op.variables))
+	for name, defaultValue := range op.variables {
+		values[name] = defaultValue
+		if value, ok := variables[name]; ok {
+			values[name] = value
+		}
+	}
+
+	return *op, &executor{variables: values}, nil
+}
+
+// execute resolves the selections on the value of an object.
+func (e *executor) execute(ctx context.Context, object *Object, value any, selections []selection, path []any) any {
+	if len(path) > maxDepth {
+		e.fail(path, errors.New("maximum query depth exceeded"))
+		return nil
+	}
+
+	result := &fields{
+		keys:   make([]string, 0, len(selections)),
+		values: make([]any, 0, len(selections)),
+	}
+	for _, s := range selections {
+		key := s.key()
+		fieldPath := append(path[:len(path):len(path)], key)
+		result.keys = append(result.keys, key)
+		result.values = append(result.values, e.resolveField(ctx, object, value, s, fieldPath))
+	}
+
+	return result
+}
+
+func (e *executor) resolveField(ctx context.Context, object *Object, parent any, s selection, path []any) any {
+	if s.name == "__typename" {
+		return object.Name
+	}
+
+	field, ok := object.Fields[s.name]
+	if !ok {
+		e.fail(path, errors.Errorf("cannot query field %q on type %q", s.name, object.Name))
+		return nil
+	}
+
+	args, err := e.arguments(s.arguments)
+	if err != nil {
+		e.fail(path, err)
+		return nil
+	}
+
+	var value any
+	if field.Resolve != nil {
+		value, err = field.Resolve(ctx, parent, args)
+		if err != nil {
+			e.fail(path, err)
+			return nil
+		}
+	} else {
+		value = property(parent, s.name)
+	}
+
+	return e.complete(ctx, field, value, s, path)
+}
+
+// complete resolves the selections on the value of a field if it's an object or a list of them.
+func (e *executor) complete(ctx context.Context, field *Field, value any, s selection, path []any) any {
+	if field.Type == nil {
+		if s.selections != nil {
+			e.fail(path, errors.Errorf("field %q of type %q must not have a selection", s.name, "scalar"))
+			return nil
+		}
+		return value
+	}
+
+	if s.selections == nil {
+		e.fail(path, errors.Errorf("field %q of type %q must have a selection", s.name, field.Type.Name))
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || ((v.Kind() == reflect.Pointer || v.Kind() == reflect.Slice) && v.IsNil()) {
+		return nil
+	}
+
+	if v.Kind() == reflect.Slice {
+		list := make([]any, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			itemPath := append(path[:len(path):len(path)], i)
+			list = append(list, e.execute(ctx, field.Type, v.Index(i).Interface(), s.selections, itemPath))
+		}
+		return list
+	}
+
+	return e.execute(ctx, field.Type, value, s.selections, path)
+}
+
+// arguments replaces the variables in the arguments with their values.
+func (e *executor) arguments(arguments map[string]any) (Args, error) {
+	args := make(Args, len(arguments))
+	for name, value := range arguments {
+		if v, ok := value.(variable); ok {
+			variableValue, ok := e.variables[string(v)]
+			if !ok {
+				return nil, errors.Errorf("variable %q is not defined", v)
+			}
+			value = variableValue
+		}
+		args[name] = value
+	}
+	return args, nil
+}
+
+func (e *executor) fail(path []any, err error) {
+	e.errors = append(e.errors, Error{Message: err.Error(), Path: path})
+}
+
+// property returns the value of the struct field with the JSON name specified.
+func property(parent any, name string) any {
+	v := reflect.ValueOf(parent)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if tag == name {
+			return v.Field(i).Interface()
+		}
+	}
+
+	return nil
+}
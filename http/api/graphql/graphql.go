@@ -0,0 +1,185 @@
+// Package graphql serves the lottery data through a GraphQL endpoint, so clients can fetch exactly
+// what they need in a single request and subscribe to the winners and the pools updates.
+//
+// It implements the subset of GraphQL the web client uses: queries and subscriptions with nested
+// selections, aliases, arguments and variables. Mutations, fragments and directives are not
+// supported.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxBodySize is the maximum size of a request body.
+const maxBodySize = 1 << 20
+
+// request is the body of a GraphQL request.
+type request struct {
+	Variables     map[string]any `json:"variables"`
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+}
+
+// Handler executes GraphQL requests. Queries are answered with a JSON response and subscriptions
+// with a stream of server-sent events, one per update.
+type Handler struct {
+	schema *Schema
+	// deadline is the maximum duration of a subscription, zero means no limit
+	deadline time.Duration
+}
+
+// NewHandler returns a handler that executes requests against the schema.
+func NewHandler(schema *Schema, deadline time.Duration) *Handler {
+	return &Handler{
+		schema:   schema,
+		deadline: deadline,
+	}
+}
+
+// ServeHTTP handles requests sent as JSON in a POST body or in the URL query parameters of a GET
+// request, which EventSource clients use for subscriptions.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeRequest(r)
+	if err != nil {
+		sendResponse(w, http.StatusBadRequest, Response{Errors: []Error{{Message: err.Error()}}})
+		return
+	}
+
+	op, executor, err := prepare(req.Query, req.OperationName, req.Variables)
+	if err != nil {
+		sendResponse(w, http.StatusBadRequest, Response{Errors: []Error{{Message: err.Error()}}})
+		return
+	}
+
+	if op.kind == subscription {
+		h.subscribe(w, r, op, executor)
+		return
+	}
+
+	data := executor.execute(r.Context(), h.schema.Query, nil, op.selections, nil)
+	sendResponse(w, http.StatusOK, Response{Data: data, Errors: executor.errors})
+}
+
+// subscribe streams the result of executing the subscription selection on every update.
+func (h *Handler) subscribe(w http.ResponseWriter, r *http.Request, op operation, e *executor) {
+	if len(op.selections) != 1 {
+		err := errors.New("subscriptions must select a single field")
+		sendResponse(w, http.StatusBadRequest, Response{Errors: []Error{{Message: err.Error()}}})
+		return
+	}
+
+	s := op.selections[0]
+	field, ok := h.schema.Subscription.Fields[s.name]
+	if !ok || field.Subscribe == nil {
+		err := errors.Errorf("cannot subscribe to field %q", s.name)
+		sendResponse(w, http.StatusBadRequest, Response{Errors: []Error{{Message: err.Error()}}})
+		return
+	}
+
+	args, err := e.arguments(s.arguments)
+	if err != nil {
+		sendResponse(w, http.StatusBadRequest, Response{Errors: []Error{{Message: err.Error()}}})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	if h.deadline > 0 {
+		ctx, cancel = context.WithTimeout(r.Context(), h.deadline)
+	}
+	defer cancel()
+
+	updates, err := field.Subscribe(ctx, args)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, Response{Errors: []Error{{Message: err.Error()}}})
+		return
+	}
+
+	// Overwrite server.WriteTimeout to avoid a short timeout, a zero deadline removes it
+	rc := http.NewResponseController(w)
+	var writeDeadline time.Time
+	if h.deadline > 0 {
+		writeDeadline = time.Now().UTC().Add(h.deadline)
+	}
+	rc.SetWriteDeadline(writeDeadline)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case value, ok := <-updates:
+			if !ok {
+				fmt.Fprint(w, "event: complete\ndata:\n\n")
+				rc.Flush()
+				return
+			}
+
+			executor := &executor{variables: e.variables}
+			key := s.key()
+			data := &fields{
+				keys:   []string{key},
+				values: []any{executor.complete(ctx, field, value, s, []any{key})},
+			}
+			payload, err := json.Marshal(Response{Data: data, Errors: executor.errors})
+			if err != nil {
+				return
+			}
+
+			if _, err := fmt.Fprintf(w, "event: next\ndata: %s\n\n", payload); err != nil {
+				return
+			}
+			rc.Flush()
+		}
+	}
+}
+
+func decodeRequest(r *http.Request) (request, error) {
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			decoder := json.NewDecoder(strings.NewReader(variables))
+			decoder.UseNumber()
+			if err := decoder.Decode(&req.Variables); err != nil {
+				return request{}, errors.Wrap(err, "invalid variables")
+			}
+		}
+	case http.MethodPost:
+		decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize))
+		decoder.UseNumber()
+		if err := decoder.Decode(&req); err != nil {
+			return request{}, errors.Wrap(err, "invalid request body")
+		}
+	default:
+		return request{}, errors.Errorf("method %s not allowed", r.Method)
+	}
+
+	if req.Query == "" {
+		return request{}, errors.New("query is required")
+	}
+
+	return req, nil
+}
+
+func sendResponse(w http.ResponseWriter, statusCode int, response Response) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "failed encoding response body", http.StatusInternalServerError)
+	}
+}
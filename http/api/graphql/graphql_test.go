@@ -0,0 +1,212 @@
+package graphql
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/logger"
+	"github.com/aftermath2/BTRY/lottery"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestHandler(t *testing.T, database *db.DB, winnersHub *lottery.WinnersHub) *Handler {
+	t.Helper()
+
+	lndMock := lightning.NewNodeInfoMock()
+	lndMock.On("RemoteBalance", mock.Anything).Return(int64(100_000), nil)
+
+	logger, err := logger.New(config.Logger{Level: uint8(logger.DISABLED)})
+	assert.NoError(t, err)
+
+	pools := lottery.NewPools([]config.Pool{{Name: "", Capacity: 100}})
+	schema := NewSchema(database, lndMock, logger, pools, lottery.RemoteBalanceCapacity{}, lottery.StatsPrivacy{}, winnersHub,
+		time.Second)
+	return NewHandler(schema, time.Minute)
+}
+
+func TestQuery(t *testing.T) {
+	height := uint32(840_000)
+	lotteriesMock := db.NewLotteriesStoreMock()
+	lotteriesMock.On("GetNextHeight").Return(height, nil)
+	lotteriesMock.On("GetCommitment", height).
+		Return(db.Commitment{Height: height, Commitment: "abcd", Seed: "secret"}, nil)
+	betsMock := db.NewBetsStoreMock()
+	betsMock.On("GetPrizePool", height, "").Return(uint64(5_000), nil)
+	statsMock := db.NewStatsStoreMock()
+	statsMock.On("ListRounds", uint64(0), uint64(1), true).
+		Return([]db.RoundStats{{Height: 839_856, PrizePool: 7_000, Players: 3, Winners: 1}}, nil)
+	winnersMock := db.NewWinnersStoreMock()
+	winnersMock.On("List", uint32(839_856)).
+		Return([]db.Winner{{PublicKey: "pubkey", Prize: 3_500, Ticket: 42}}, nil)
+	privacyMock := db.NewPrivacyStoreMock()
+	privacyMock.On("List", []string{"pubkey"}).Return(map[string]db.Privacy{}, nil)
+	revealsMock := db.NewRevealsStoreMock()
+	revealsMock.On("Get", uint32(839_856)).Return(db.Reveal{}, db.ErrRevealNotFound)
+
+	postponementsMock := db.NewPostponementsStoreMock()
+	postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+
+	database := &db.DB{
+		Bets:          betsMock,
+		Lotteries:     lotteriesMock,
+		Postponements: postponementsMock,
+		Privacy:       privacyMock,
+		Reveals:       revealsMock,
+		Stats:         statsMock,
+		Winners:       winnersMock,
+	}
+	handler := newTestHandler(t, database, lottery.NewWinnersHub(config.WinnersHub{}))
+
+	body := `{
+		"query": "query Home($limit: Int) { lottery { next_height prize_pool pools { capacity } commitment { commitment seed } } last: rounds(limit: $limit, reverse: true) { __typename height winning_tickets { public_key ticket } } }",
+		"variables": {"limit": 1}
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	expected := `{"data":{
+		"lottery":{"next_height":840000,"prize_pool":5000,"pools":[{"capacity":20000}],
+			"commitment":{"commitment":"abcd","seed":""}},
+		"last":[{"__typename":"Round","height":839856,"winning_tickets":[{"public_key":"pubkey","ticket":42}]}]
+	}}`
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, expected, rec.Body.String())
+}
+
+func TestQueryErrors(t *testing.T) {
+	winnersMock := db.NewWinnersStoreMock()
+	winnersMock.On("List", uint32(1)).Return([]db.Winner{{Prize: 10}}, nil)
+	privacyMock := db.NewPrivacyStoreMock()
+	privacyMock.On("List", []string{""}).Return(map[string]db.Privacy{}, nil)
+	revealsMock := db.NewRevealsStoreMock()
+	revealsMock.On("Get", uint32(1)).Return(db.Reveal{}, db.ErrRevealNotFound)
+	database := &db.DB{Privacy: privacyMock, Reveals: revealsMock, Winners: winnersMock}
+	handler := newTestHandler(t, database, lottery.NewWinnersHub(config.WinnersHub{}))
+
+	cases := []struct {
+		desc       string
+		query      string
+		expected   string
+		statusCode int
+	}{
+		{
+			desc:       "Syntax error",
+			query:      "{ winners(height: 1) { prize }",
+			expected:   `{"errors":[{"message":"expected name, found end of document"}]}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			desc:  "Unknown field",
+			query: "{ winners(height: 1) { prize } players { public_key } }",
+			expected: `{"data":{"winners":[{"prize":10}],"players":null},
+				"errors":[{"message":"cannot query field \"players\" on type \"Query\"","path":["players"]}]}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			desc:  "Missing argument",
+			query: "{ winners { prize } }",
+			expected: `{"data":{"winners":null},
+				"errors":[{"message":"argument \"height\" is required","path":["winners"]}]}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			desc:  "Missing selection",
+			query: "{ winners(height: 1) }",
+			expected: `{"data":{"winners":null},
+				"errors":[{"message":"field \"winners\" of type \"Winner\" must have a selection","path":["winners"]}]}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			desc:  "Undefined variable",
+			query: "{ winners(height: $height) { prize } }",
+			expected: `{"data":{"winners":null},
+				"errors":[{"message":"variable \"height\" is not defined","path":["winners"]}]}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			desc:       "Alias",
+			query:      "{ a: winners(height: 1) { prize } }",
+			expected:   `{"data":{"a":[{"prize":10}]}}`,
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(tc.query), nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.statusCode, rec.Code)
+			assert.JSONEq(t, tc.expected, rec.Body.String())
+		})
+	}
+}
+
+func TestSubscribeWinners(t *testing.T) {
+	winnersHub := lottery.NewWinnersHub(config.WinnersHub{})
+	privacyMock := db.NewPrivacyStoreMock()
+	privacyMock.On("List", []string{"pubkey"}).
+		Return(map[string]db.Privacy{"pubkey": {Display: db.DisplayAlias, Alias: "lucky"}}, nil)
+	handler := newTestHandler(t, &db.DB{Privacy: privacyMock}, winnersHub)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	query := url.QueryEscape("subscription { winners { public_key alias prize } }")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?query="+query, nil)
+	assert.NoError(t, err)
+
+	res, err := server.Client().Do(req)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	winnersHub.Publish([]db.Winner{{PublicKey: "pubkey", Prize: 500, Ticket: 7}})
+
+	reader := bufio.NewReader(res.Body)
+	event, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "event: next\n", event)
+
+	data, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, `data: {"data":{"winners":[{"public_key":"","alias":"lucky","prize":500}]}}`+"\n", data)
+}
+
+func TestSubscribeErrors(t *testing.T) {
+	handler := newTestHandler(t, &db.DB{}, lottery.NewWinnersHub(config.WinnersHub{}))
+
+	cases := []struct {
+		desc  string
+		query string
+	}{
+		{desc: "Multiple fields", query: "subscription { winners { prize } lottery { next_height } }"},
+		{desc: "Unknown field", query: "subscription { bets { index } }"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(tc.query), nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
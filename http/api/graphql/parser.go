@@ -0,0 +1,463 @@
+package graphql
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Operation types
+const (
+	query        = "query"
+	subscription = "subscription"
+)
+
+// operation is an executable definition of a document.
+type operation struct {
+	kind       string
+	name       string
+	variables  map[string]any
+	selections []selection
+}
+
+// selection is a field requested, along with the fields of the value it returns if it's an
+// object.
+type selection struct {
+	alias      string
+	name       string
+	arguments  map[string]any
+	selections []selection
+}
+
+// key returns the name the field has in the response.
+func (s selection) key() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+// variable is a reference to a variable of the operation used as an argument.
+type variable string
+
+type tokenKind uint8
+
+const (
+	eof tokenKind = iota
+	punctuator
+	name
+	intValue
+	floatValue
+	stringValue
+)
+
+type token struct {
+	value string
+	kind  tokenKind
+}
+
+// parser builds the operations of a GraphQL document. Only queries and subscriptions with
+// arguments, aliases and variables are supported, fragments and directives are not.
+type parser struct {
+	src   string
+	token token
+	pos   int
+}
+
+// parse returns the operations of the document.
+func parse(document string) ([]operation, error) {
+	p := &parser{src: document}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	var operations []operation
+	for p.token.kind != eof {
+		op, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, op)
+	}
+
+	if len(operations) == 0 {
+		return nil, errors.New("document contains no operations")
+	}
+
+	return operations, nil
+}
+
+func (p *parser) parseOperation() (operation, error) {
+	op := operation{kind: query}
+
+	// Shorthand queries have no operation type
+	if p.token.kind == name {
+		switch p.token.value {
+		case query, subscription:
+			op.kind = p.token.value
+		case "mutation":
+			return operation{}, errors.New("mutations are not supported")
+		case "fragment":
+			return operation{}, errors.New("fragments are not supported")
+		default:
+			return operation{}, errors.Errorf("unexpected %q", p.token.value)
+		}
+		if err := p.next(); err != nil {
+			return operation{}, err
+		}
+
+		if p.token.kind == name {
+			op.name = p.token.value
+			if err := p.next(); err != nil {
+				return operation{}, err
+			}
+		}
+
+		if p.is("(") {
+			variables, err := p.parseVariableDefinitions()
+			if err != nil {
+				return operation{}, err
+			}
+			op.variables = variables
+		}
+	}
+
+	selections, err := p.parseSelectionSet()
+	if err != nil {
+		return operation{}, err
+	}
+	op.selections = selections
+
+	return op, nil
+}
+
+// parseVariableDefinitions returns the variables declared and their default values.
+func (p *parser) parseVariableDefinitions() (map[string]any, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	variables := make(map[string]any)
+	for !p.is(")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if err := p.skipType(); err != nil {
+			return nil, err
+		}
+
+		var defaultValue any
+		if p.is("=") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			defaultValue, err = p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+		}
+		variables[name] = defaultValue
+	}
+
+	return variables, p.next()
+}
+
+// skipType consumes a type reference, types are checked when the arguments are read.
+func (p *parser) skipType() error {
+	if p.is("[") {
+		if err := p.next(); err != nil {
+			return err
+		}
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.parseName(); err != nil {
+		return err
+	}
+
+	if p.is("!") {
+		return p.next()
+	}
+	return nil
+}
+
+func (p *parser) parseSelectionSet() ([]selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var selections []selection
+	for !p.is("}") {
+		if p.is("...") {
+			return nil, errors.New("fragments are not supported")
+		}
+
+		s, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, s)
+	}
+
+	if len(selections) == 0 {
+		return nil, errors.New("selection set is empty")
+	}
+
+	return selections, p.next()
+}
+
+func (p *parser) parseField() (selection, error) {
+	fieldName, err := p.parseName()
+	if err != nil {
+		return selection{}, err
+	}
+
+	s := selection{name: fieldName}
+	if p.is(":") {
+		if err := p.next(); err != nil {
+			return selection{}, err
+		}
+		s.alias = fieldName
+		s.name, err = p.parseName()
+		if err != nil {
+			return selection{}, err
+		}
+	}
+
+	if p.is("(") {
+		s.arguments, err = p.parseArguments()
+		if err != nil {
+			return selection{}, err
+		}
+	}
+
+	if p.is("@") {
+		return selection{}, errors.New("directives are not supported")
+	}
+
+	if p.is("{") {
+		s.selections, err = p.parseSelectionSet()
+		if err != nil {
+			return selection{}, err
+		}
+	}
+
+	return s, nil
+}
+
+func (p *parser) parseArguments() (map[string]any, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	arguments := make(map[string]any)
+	for !p.is(")") {
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		arguments[name] = value
+	}
+
+	return arguments, p.next()
+}
+
+func (p *parser) parseValue() (any, error) {
+	tok := p.token
+	switch tok.kind {
+	case punctuator:
+		switch tok.value {
+		case "$":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			name, err := p.parseName()
+			if err != nil {
+				return nil, err
+			}
+			return variable(name), nil
+		case "[":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			list := []any{}
+			for !p.is("]") {
+				value, err := p.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, value)
+			}
+			return list, p.next()
+		}
+	case intValue:
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid integer %s", tok.value)
+		}
+		return n, p.next()
+	case floatValue:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid float %s", tok.value)
+		}
+		return f, p.next()
+	case stringValue:
+		return tok.value, p.next()
+	case name:
+		var value any
+		switch tok.value {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			// Enum values are handled as strings
+			value = tok.value
+		}
+		return value, p.next()
+	}
+
+	return nil, errors.Errorf("unexpected %q", tok.value)
+}
+
+func (p *parser) parseName() (string, error) {
+	if p.token.kind != name {
+		if p.token.kind == eof {
+			return "", errors.New("expected name, found end of document")
+		}
+		return "", errors.Errorf("expected name, found %q", p.token.value)
+	}
+	value := p.token.value
+	return value, p.next()
+}
+
+// is returns whether the current token is the punctuator specified.
+func (p *parser) is(value string) bool {
+	return p.token.kind == punctuator && p.token.value == value
+}
+
+// expect consumes the punctuator specified or fails if the current token is a different one.
+func (p *parser) expect(value string) error {
+	if !p.is(value) {
+		if p.token.kind == eof {
+			return errors.Errorf("expected %q, found end of document", value)
+		}
+		return errors.Errorf("expected %q, found %q", value, p.token.value)
+	}
+	return p.next()
+}
+
+// next reads the following token. Whitespace, commas and comments are ignored.
+func (p *parser) next() error {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != ',' {
+			break
+		}
+		p.pos++
+	}
+
+	if p.pos >= len(p.src) {
+		p.token = token{kind: eof}
+		return nil
+	}
+
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.token = token{kind: punctuator, value: "..."}
+	case strings.IndexByte("{}()[]:$!=@", c) >= 0:
+		p.pos++
+		p.token = token{kind: punctuator, value: string(c)}
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.token = token{kind: name, value: p.src[start:p.pos]}
+	case c == '-' || isDigit(c):
+		kind := intValue
+		p.pos++
+		for p.pos < len(p.src) {
+			c := p.src[p.pos]
+			if c == '.' || c == 'e' || c == 'E' || ((c == '+' || c == '-') && kind == floatValue) {
+				kind = floatValue
+			} else if !isDigit(c) {
+				break
+			}
+			p.pos++
+		}
+		p.token = token{kind: kind, value: p.src[start:p.pos]}
+	case c == '"':
+		value, err := p.readString()
+		if err != nil {
+			return err
+		}
+		p.token = token{kind: stringValue, value: value}
+	default:
+		return errors.Errorf("unexpected character %q", c)
+	}
+
+	return nil
+}
+
+// readString reads a quoted string, block strings are not supported.
+func (p *parser) readString() (string, error) {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos += 2
+			continue
+		case '\n':
+			return "", errors.New("unterminated string")
+		case '"':
+			p.pos++
+			value, err := strconv.Unquote(p.src[start:p.pos])
+			if err != nil {
+				return "", errors.Wrap(err, "invalid string")
+			}
+			return value, nil
+		}
+		p.pos++
+	}
+
+	return "", errors.New("unterminated string")
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,86 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	document := `
+	# Lottery page
+	query Page($height: Int!, $limit: Int = 10) {
+		lottery { next_height pools { name } }
+		latest: winners(height: $height) { public_key, prize }
+		rounds(limit: $limit, reverse: true, order: DESC, ids: [1, 2.5, "three", null]) { height }
+	}
+	subscription { winners { ticket } }`
+
+	operations, err := parse(document)
+	assert.NoError(t, err)
+
+	expected := []operation{
+		{
+			kind:      query,
+			name:      "Page",
+			variables: map[string]any{"height": nil, "limit": int64(10)},
+			selections: []selection{
+				{
+					name: "lottery",
+					selections: []selection{
+						{name: "next_height"},
+						{name: "pools", selections: []selection{{name: "name"}}},
+					},
+				},
+				{
+					alias:      "latest",
+					name:       "winners",
+					arguments:  map[string]any{"height": variable("height")},
+					selections: []selection{{name: "public_key"}, {name: "prize"}},
+				},
+				{
+					name: "rounds",
+					arguments: map[string]any{
+						"limit":   variable("limit"),
+						"reverse": true,
+						"order":   "DESC",
+						"ids":     []any{int64(1), 2.5, "three", nil},
+					},
+					selections: []selection{{name: "height"}},
+				},
+			},
+		},
+		{
+			kind: subscription,
+			selections: []selection{
+				{name: "winners", selections: []selection{{name: "ticket"}}},
+			},
+		},
+	}
+	assert.Equal(t, expected, operations)
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		desc     string
+		document string
+	}{
+		{desc: "Empty", document: "  # comment"},
+		{desc: "Empty selection", document: "{}"},
+		{desc: "Unclosed selection", document: "{ lottery { next_height }"},
+		{desc: "Mutation", document: "mutation { bet }"},
+		{desc: "Fragment definition", document: "fragment F on Lottery { next_height }"},
+		{desc: "Fragment spread", document: "{ lottery { ...F } }"},
+		{desc: "Directive", document: "{ lottery @skip(if: true) { next_height } }"},
+		{desc: "Unterminated string", document: `{ bets(pool: "whale) { index } }`},
+		{desc: "Invalid character", document: "{ lottery % }"},
+		{desc: "Missing argument value", document: "{ bets(height: ) { index } }"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := parse(tc.document)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,458 @@
+package graphql
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/logger"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/pkg/errors"
+)
+
+// defaultUpdateInterval is how often the lottery subscription checks for updates if not
+// configured.
+const defaultUpdateInterval = 10 * time.Second
+
+// Object types
+var (
+	winnerType = &Object{
+		Name: "Winner",
+		Fields: map[string]*Field{
+			"public_key":     {},
+			"alias":          {},
+			"prize":          {},
+			"ticket":         {},
+			"pool":           {},
+			"claim_deadline": {},
+		},
+	}
+	betType = &Object{
+		Name: "Bet",
+		Fields: map[string]*Field{
+			"public_key":   {},
+			"pool":         {},
+			"first_ticket": {},
+			"index":        {},
+			"tickets":      {},
+			"bonus":        {},
+		},
+	}
+	poolType = &Object{
+		Name: "Pool",
+		Fields: map[string]*Field{
+			"name":       {},
+			"prize_pool": {},
+			"capacity":   {},
+			"min_amount": {},
+			"max_amount": {},
+		},
+	}
+	commitmentType = &Object{
+		Name: "Commitment",
+		Fields: map[string]*Field{
+			"height":     {},
+			"commitment": {},
+			"seed":       {},
+		},
+	}
+	statsType = &Object{
+		Name: "Stats",
+		Fields: map[string]*Field{
+			"rounds":         {},
+			"total_pool":     {},
+			"average_pool":   {},
+			"total_paid_out": {},
+			"payouts":        {},
+		},
+	}
+	winType = &Object{
+		Name: "Win",
+		Fields: map[string]*Field{
+			"height": {},
+			"prize":  {},
+		},
+	}
+	streakType = &Object{
+		Name: "Streak",
+		Fields: map[string]*Field{
+			"length":     {},
+			"end_height": {},
+		},
+	}
+)
+
+// resolvers fetch the values of the fields that are not read from their parent object.
+type resolvers struct {
+	db             *db.DB
+	lnd            lightning.NodeInfo
+	logger         *logger.Logger
+	pools          lottery.Pools
+	capacity       lottery.CapacityOracle
+	statsPrivacy   lottery.StatsPrivacy
+	winnersHub     *lottery.WinnersHub
+	updateInterval time.Duration
+}
+
+// NewSchema returns the schema of the lottery data.
+//
+// Queries expose the lottery in progress, the past lotteries and the statistics. Subscriptions
+// deliver the winners of every lottery and the lottery information when it changes, checking for
+// updates every updateInterval.
+func NewSchema(
+	db *db.DB,
+	lnd lightning.NodeInfo,
+	logger *logger.Logger,
+	pools lottery.Pools,
+	capacity lottery.CapacityOracle,
+	statsPrivacy lottery.StatsPrivacy,
+	winnersHub *lottery.WinnersHub,
+	updateInterval time.Duration,
+) *Schema {
+	if updateInterval == 0 {
+		updateInterval = defaultUpdateInterval
+	}
+
+	r := &resolvers{
+		db:             db,
+		lnd:            lnd,
+		logger:         logger,
+		pools:          pools,
+		capacity:       capacity,
+		statsPrivacy:   statsPrivacy,
+		winnersHub:     winnersHub,
+		updateInterval: updateInterval,
+	}
+
+	lotteryType := &Object{
+		Name: "Lottery",
+		Fields: map[string]*Field{
+			"pools":       {Type: poolType},
+			"prize_pool":  {},
+			"capacity":    {},
+			"next_height": {},
+			"commitment":  {Resolve: r.lotteryCommitment, Type: commitmentType},
+		},
+	}
+	roundType := &Object{
+		Name: "Round",
+		Fields: map[string]*Field{
+			"height":          {},
+			"prize_pool":      {},
+			"players":         {},
+			"winners":         {},
+			"hidden":          {},
+			"winning_tickets": {Resolve: r.roundWinners, Type: winnerType},
+		},
+	}
+
+	return &Schema{
+		Query: &Object{
+			Name: "Query",
+			Fields: map[string]*Field{
+				"lottery":      {Resolve: r.lottery, Type: lotteryType},
+				"commitment":   {Resolve: r.commitment, Type: commitmentType},
+				"heights":      {Resolve: r.heights},
+				"bets":         {Resolve: r.bets, Type: betType},
+				"winners":      {Resolve: r.winners, Type: winnerType},
+				"stats":        {Resolve: r.stats, Type: statsType},
+				"rounds":       {Resolve: r.rounds, Type: roundType},
+				"biggest_wins": {Resolve: r.biggestWins, Type: winType},
+				"streaks":      {Resolve: r.streaks, Type: streakType},
+			},
+		},
+		Subscription: &Object{
+			Name: "Subscription",
+			Fields: map[string]*Field{
+				"lottery": {Subscribe: r.subscribeLottery, Type: lotteryType},
+				"winners": {Subscribe: r.subscribeWinners, Type: winnerType},
+			},
+		},
+	}
+}
+
+func (r *resolvers) lottery(ctx context.Context, _ any, _ Args) (any, error) {
+	return lottery.GetInfo(ctx, r.lnd, r.db, r.pools, r.capacity)
+}
+
+func (r *resolvers) lotteryCommitment(_ context.Context, parent any, _ Args) (any, error) {
+	info, ok := parent.(lottery.Info)
+	if !ok {
+		return nil, errors.New("invalid lottery")
+	}
+
+	commitment, err := r.db.Lotteries.GetCommitment(info.NextHeight)
+	if err != nil {
+		if errors.Is(err, db.ErrLotteryNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	commitment.Seed = ""
+	return commitment, nil
+}
+
+// commitment returns the commitment to the server seed of the lottery at the height specified,
+// or the one in progress if none is. The seed is included once the lottery was drawn.
+func (r *resolvers) commitment(_ context.Context, _ any, args Args) (any, error) {
+	height, err := args.Uint("height")
+	if err != nil {
+		return nil, err
+	}
+
+	nextHeight, err := r.db.Lotteries.GetNextHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	if height == 0 {
+		height = uint64(nextHeight)
+	}
+
+	commitment, err := r.db.Lotteries.GetCommitment(uint32(height))
+	if err != nil {
+		return nil, err
+	}
+
+	if commitment.Height >= nextHeight {
+		commitment.Seed = ""
+	}
+
+	// The seed would give the winners away before they are revealed
+	if commitment.Seed != "" {
+		pending, err := lottery.RevealPending(r.db.Reveals, commitment.Height)
+		if err != nil {
+			return nil, err
+		}
+		if pending {
+			commitment.Seed = ""
+		}
+	}
+
+	return commitment, nil
+}
+
+func (r *resolvers) heights(_ context.Context, _ any, args Args) (any, error) {
+	offset, limit, reverse, err := pagination(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.db.ReadReplica().Lotteries.ListHeights(offset, limit, reverse)
+}
+
+func (r *resolvers) bets(_ context.Context, _ any, args Args) (any, error) {
+	height, err := requiredHeight(args)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := args.String("pool")
+	if err != nil {
+		return nil, err
+	}
+
+	offset, limit, reverse, err := pagination(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.db.ReadReplica().Bets.List(height, pool, offset, limit, reverse)
+}
+
+func (r *resolvers) winners(_ context.Context, _ any, args Args) (any, error) {
+	height, err := requiredHeight(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.listWinners(height)
+}
+
+func (r *resolvers) stats(_ context.Context, _ any, _ Args) (any, error) {
+	stats, err := r.db.ReadReplica().Stats.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.statsPrivacy.Stats(stats), nil
+}
+
+func (r *resolvers) rounds(_ context.Context, _ any, args Args) (any, error) {
+	offset, limit, reverse, err := pagination(args)
+	if err != nil {
+		return nil, err
+	}
+
+	rounds, err := r.db.ReadReplica().Stats.ListRounds(offset, limit, reverse)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.statsPrivacy.Rounds(rounds), nil
+}
+
+func (r *resolvers) roundWinners(_ context.Context, parent any, _ Args) (any, error) {
+	round, ok := parent.(db.RoundStats)
+	if !ok {
+		return nil, errors.New("invalid round")
+	}
+
+	return r.listWinners(round.Height)
+}
+
+// listWinners returns the winners of the lottery displayed according to their privacy preferences.
+func (r *resolvers) listWinners(height uint32) ([]db.Winner, error) {
+	replica := r.db.ReadReplica()
+	winners, err := replica.Winners.List(height)
+	if err != nil {
+		return nil, err
+	}
+
+	winners, err = lottery.Revealed(replica.Reveals, height, winners)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy.AnonymizeWinners(replica.Privacy, winners)
+}
+
+func (r *resolvers) biggestWins(_ context.Context, _ any, args Args) (any, error) {
+	limit, err := args.Uint("limit")
+	if err != nil {
+		return nil, err
+	}
+
+	wins, err := r.db.ReadReplica().Stats.ListBiggestWins(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.statsPrivacy.Wins(wins), nil
+}
+
+func (r *resolvers) streaks(_ context.Context, _ any, args Args) (any, error) {
+	limit, err := args.Uint("limit")
+	if err != nil {
+		return nil, err
+	}
+
+	return r.db.ReadReplica().Stats.ListStreaks(limit)
+}
+
+// subscribeLottery sends the lottery information and then every time it changes.
+func (r *resolvers) subscribeLottery(ctx context.Context, _ Args) (<-chan any, error) {
+	info, err := lottery.GetInfo(ctx, r.lnd, r.db, r.pools, r.capacity)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan any, 1)
+	ch <- info
+
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(r.updateInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				latest, err := lottery.GetInfo(ctx, r.lnd, r.db, r.pools, r.capacity)
+				if err != nil {
+					r.logger.Error(errors.Wrap(err, "getting lottery information"))
+					continue
+				}
+
+				if reflect.DeepEqual(latest, info) {
+					continue
+				}
+				info = latest
+
+				select {
+				case ch <- info:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// subscribeWinners sends the winners of every lottery drawn.
+func (r *resolvers) subscribeWinners(ctx context.Context, _ Args) (<-chan any, error) {
+	subscription := r.winnersHub.Subscribe()
+
+	ch := make(chan any)
+	go func() {
+		defer close(ch)
+		defer subscription.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case winners, ok := <-subscription.C():
+				if !ok {
+					return
+				}
+
+				winners, err := policy.AnonymizeWinners(r.db.ReadReplica().Privacy, winners)
+				if err != nil {
+					r.logger.Error(errors.Wrap(err, "anonymizing winners"))
+					continue
+				}
+
+				select {
+				case ch <- winners:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func requiredHeight(args Args) (uint32, error) {
+	if _, ok := args["height"]; !ok {
+		return 0, errors.New("argument \"height\" is required")
+	}
+
+	height, err := args.Uint("height")
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(height), nil
+}
+
+func pagination(args Args) (offset, limit uint64, reverse bool, err error) {
+	offset, err = args.Uint("offset")
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	limit, err = args.Uint("limit")
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	reverse, err = args.Bool("reverse")
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	return offset, limit, reverse, nil
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/crypto"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+
+	"github.com/pkg/errors"
+)
+
+// maxAccessReasonLength is the maximum length of the reason of an access list entry.
+const maxAccessReasonLength = 140
+
+// AccessEntryRequest is the request body of the /admin/access endpoint.
+type AccessEntryRequest struct {
+	PublicKey string `json:"public_key"`
+	// List is either allow or deny
+	List string `json:"list"`
+	// Action is either bets or withdrawals
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ListAccessLists responds with the entries of the allow and deny lists.
+func (h *Handler) ListAccessLists(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.db.AccessLists.List()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, entries)
+}
+
+// AddAccessEntry puts a public key in the allow or deny list of bets or withdrawals.
+func (h *Handler) AddAccessEntry(w http.ResponseWriter, r *http.Request) {
+	var req AccessEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, errors.Wrap(err, "decoding request body"))
+		return
+	}
+
+	if err := validateAccessEntry(req.PublicKey, req.List, req.Action); err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(req.Reason) > maxAccessReasonLength {
+		sendError(w, http.StatusBadRequest, errors.Errorf("reason must have up to %d characters",
+			maxAccessReasonLength))
+		return
+	}
+
+	operator, ok := middleware.OperatorFromContext(r.Context())
+	if !ok {
+		sendError(w, http.StatusUnauthorized, errors.New("operator not authenticated"))
+		return
+	}
+
+	entry := db.AccessEntry{
+		PublicKey:  req.PublicKey,
+		List:       req.List,
+		Action:     req.Action,
+		Reason:     req.Reason,
+		OperatorID: operator.ID,
+		CreatedAt:  time.Now().Unix(),
+	}
+	if err := h.db.AccessLists.Add(entry); err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.auditor.Record(audit.AccessListUpdated, map[string]any{
+		"operator_id": operator.ID,
+		"public_key":  entry.PublicKey,
+		"list":        entry.List,
+		"action":      entry.Action,
+		"added":       true,
+	})
+
+	sendResponse(w, http.StatusOK, entry)
+}
+
+// RemoveAccessEntry takes a public key out of an allow or deny list and responds with the entry
+// removed.
+func (h *Handler) RemoveAccessEntry(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	publicKey, list, action := query.Get("pubkey"), query.Get("list"), query.Get("action")
+	if err := validateAccessEntry(publicKey, list, action); err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.db.AccessLists.Delete(publicKey, list, action); err != nil {
+		if errors.Is(err, db.ErrAccessEntryNotFound) {
+			sendError(w, http.StatusNotFound, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	data := map[string]any{
+		"public_key": publicKey,
+		"list":       list,
+		"action":     action,
+		"added":      false,
+	}
+	if operator, ok := middleware.OperatorFromContext(r.Context()); ok {
+		data["operator_id"] = operator.ID
+	}
+	h.auditor.Record(audit.AccessListUpdated, data)
+
+	sendResponse(w, http.StatusOK, db.AccessEntry{PublicKey: publicKey, List: list, Action: action})
+}
+
+func validateAccessEntry(publicKey, list, action string) error {
+	if err := crypto.ValidatePublicKey(publicKey); err != nil {
+		return err
+	}
+
+	if list != db.ListAllow && list != db.ListDeny {
+		return errors.New("invalid list, must be allow or deny")
+	}
+
+	if action != db.AccessBets && action != db.AccessWithdrawals {
+		return errors.New("invalid action, must be bets or withdrawals")
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func (h *HandlerSuite) TestListAccessLists() {
+	entries := []db.AccessEntry{
+		{PublicKey: validPublicKey, List: db.ListDeny, Action: db.AccessBets, Reason: "operator node"},
+	}
+	h.accessListsMock.On("List").Return(entries, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/access", nil)
+	h.handler.ListAccessLists(h.rec, h.req)
+
+	var response []db.AccessEntry
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(entries, response)
+}
+
+func (h *HandlerSuite) TestAddAccessEntry() {
+	h.accessListsMock.On("Add", mock.MatchedBy(func(entry db.AccessEntry) bool {
+		return entry.PublicKey == validPublicKey && entry.List == db.ListDeny &&
+			entry.Action == db.AccessWithdrawals && entry.OperatorID == 2
+	})).Return(nil)
+	h.auditorMock.On("Record", audit.AccessListUpdated, mock.Anything)
+
+	body := `{"public_key":"` + validPublicKey + `","list":"deny","action":"withdrawals","reason":"abuse"}`
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/access", strings.NewReader(body))
+	h.req = h.req.WithContext(withOperator(h.req.Context(), db.Operator{ID: 2, Role: db.RoleOperator}))
+	h.handler.AddAccessEntry(h.rec, h.req)
+
+	var response db.AccessEntry
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal("abuse", response.Reason)
+	h.auditorMock.AssertExpectations(h.T())
+}
+
+func (h *HandlerSuite) TestAddAccessEntryInvalid() {
+	cases := []struct {
+		desc string
+		body string
+	}{
+		{
+			desc: "Invalid body",
+			body: "deny",
+		},
+		{
+			desc: "Invalid public key",
+			body: `{"public_key":"abc","list":"deny","action":"bets"}`,
+		},
+		{
+			desc: "Unknown list",
+			body: `{"public_key":"` + validPublicKey + `","list":"block","action":"bets"}`,
+		},
+		{
+			desc: "Unknown action",
+			body: `{"public_key":"` + validPublicKey + `","list":"deny","action":"claims"}`,
+		},
+		{
+			desc: "Reason too long",
+			body: `{"public_key":"` + validPublicKey + `","list":"deny","action":"bets","reason":"` +
+				strings.Repeat("a", 141) + `"}`,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			req := httptest.NewRequest(http.MethodPost, "/admin/access", strings.NewReader(tc.body))
+			req = req.WithContext(withOperator(req.Context(), db.Operator{ID: 2, Role: db.RoleOperator}))
+			rec := httptest.NewRecorder()
+			h.handler.AddAccessEntry(rec, req)
+
+			h.Equal(http.StatusBadRequest, rec.Code)
+		})
+	}
+}
+
+func (h *HandlerSuite) TestRemoveAccessEntry() {
+	h.accessListsMock.On("Delete", validPublicKey, db.ListAllow, db.AccessBets).Return(nil).Once()
+	h.accessListsMock.On("Delete", validPublicKey, db.ListAllow, db.AccessBets).
+		Return(db.ErrAccessEntryNotFound).Once()
+	h.auditorMock.On("Record", audit.AccessListUpdated, mock.Anything).Once()
+
+	query := url.Values{"pubkey": {validPublicKey}, "list": {db.ListAllow}, "action": {db.AccessBets}}
+	h.req = httptest.NewRequest(http.MethodDelete, "/admin/access?"+query.Encode(), nil)
+	h.handler.RemoveAccessEntry(h.rec, h.req)
+	h.Equal(http.StatusOK, h.rec.Code)
+
+	rec := httptest.NewRecorder()
+	h.handler.RemoveAccessEntry(rec, h.req)
+	h.Equal(http.StatusNotFound, rec.Code)
+}
+
+func (h *HandlerSuite) TestGetInvoiceAccessDenied() {
+	h.req = httptest.NewRequest(http.MethodGet, "/invoice?amount=2000", nil)
+	h.SetAuthorizationKey(validPublicKey)
+	h.mockNoLimits()
+	h.accessListsMock.ExpectedCalls = nil
+	h.accessListsMock.On("Allowed", validPublicKey, db.AccessBets).Return(false, nil)
+	h.auditorMock.On("Record", audit.AccessDenied, mock.Anything)
+
+	h.handler.GetInvoice(h.rec, h.req)
+
+	h.Equal(http.StatusForbidden, h.rec.Code)
+	h.lndMock.AssertNotCalled(h.T(), "AddInvoice", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestWithdrawAccessDenied() {
+	query := url.Values{"k1": {validSignature}, "pubkey": {validPublicKey}, "pr": {"lnbc1"}}
+	h.req = httptest.NewRequest(http.MethodPost, "/withdraw?"+query.Encode(), nil)
+	h.accessListsMock.ExpectedCalls = nil
+	h.accessListsMock.On("Allowed", validPublicKey, db.AccessWithdrawals).Return(false, nil)
+	h.auditorMock.On("Record", audit.AccessDenied, mock.Anything)
+
+	h.handler.Withdraw(h.rec, h.req)
+
+	h.Equal(http.StatusForbidden, h.rec.Code)
+	h.prizesMock.AssertNotCalled(h.T(), "Withdraw", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,546 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aftermath2/BTRY/crypto/webauthn"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+	"github.com/aftermath2/BTRY/reload"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ceremonyTimeout is the time operators have to complete a registration or a login.
+	ceremonyTimeout = 5 * time.Minute
+	// maxCeremonies limits the number of pending ceremonies kept in memory.
+	maxCeremonies = 1000
+	// inviteDuration is the time an invite can be used for.
+	inviteDuration = 24 * time.Hour
+	// defaultSessionDuration is used when the configuration does not specify one.
+	defaultSessionDuration = 12 * time.Hour
+	maxOperatorNameLength  = 64
+)
+
+// ceremony is a pending WebAuthn registration or login.
+type ceremony struct {
+	name       string
+	inviteHash string
+	expiresAt  time.Time
+	register   bool
+}
+
+// The WebAuthn options are encoded following the browsers' JSON schema so clients can use
+// PublicKeyCredential.parseCreationOptionsFromJSON() and parseRequestOptionsFromJSON().
+
+type relyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type userEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// BeginRegistrationRequest is the request schema of the POST /admin/register/begin endpoint.
+type BeginRegistrationRequest struct {
+	Name   string `json:"name"`
+	Invite string `json:"invite,omitempty"`
+}
+
+// RegistrationOptions is the response schema of the POST /admin/register/begin endpoint.
+type RegistrationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	Attestation            string                 `json:"attestation"`
+	RP                     relyingPartyEntity     `json:"rp"`
+	User                   userEntity             `json:"user"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+}
+
+// RegistrationCredential is the request schema of the POST /admin/register/finish endpoint.
+type RegistrationCredential struct {
+	ID       string `json:"id"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject"`
+	} `json:"response"`
+}
+
+// LoginOptions is the response schema of the POST /admin/login/begin endpoint.
+type LoginOptions struct {
+	Challenge        string `json:"challenge"`
+	RPID             string `json:"rpId"`
+	UserVerification string `json:"userVerification"`
+	Timeout          int64  `json:"timeout"`
+}
+
+// LoginCredential is the request schema of the POST /admin/login/finish endpoint.
+type LoginCredential struct {
+	ID       string `json:"id"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+	} `json:"response"`
+}
+
+// LoginResponse is the response schema of the POST /admin/login/finish endpoint.
+type LoginResponse struct {
+	Token     string      `json:"token"`
+	Operator  db.Operator `json:"operator"`
+	ExpiresAt int64       `json:"expires_at"`
+}
+
+// LogoutResponse is the response schema of the POST /admin/logout endpoint.
+type LogoutResponse struct {
+	Success bool `json:"success,omitempty"`
+}
+
+// InviteResponse is the response schema of the POST /admin/invites endpoint.
+type InviteResponse struct {
+	Code      string  `json:"code"`
+	Role      db.Role `json:"role"`
+	ExpiresAt int64   `json:"expires_at"`
+}
+
+// DeleteOperatorResponse is the response schema of the DELETE /admin/operators endpoint.
+type DeleteOperatorResponse struct {
+	Success bool `json:"success,omitempty"`
+}
+
+// ReloadConfigResponse is the response schema of the POST /admin/config/reload endpoint.
+type ReloadConfigResponse struct {
+	Success bool `json:"success,omitempty"`
+}
+
+// BeginRegistration starts the registration of an operator passkey.
+//
+// New operators need an invite, except for the first one, who must provide the setup token.
+func (h *Handler) BeginRegistration(w http.ResponseWriter, r *http.Request) {
+	var req BeginRegistrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, errors.Wrap(err, "decoding request body"))
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxOperatorNameLength {
+		sendError(w, http.StatusBadRequest, errors.New("invalid operator name"))
+		return
+	}
+
+	var inviteHash string
+	if req.Invite != "" {
+		inviteHash = hashSecret(req.Invite)
+		if _, err := h.db.Operators.GetInviteRole(inviteHash); err != nil {
+			if errors.Is(err, db.ErrInvalidInvite) {
+				sendError(w, http.StatusUnauthorized, err)
+				return
+			}
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+	} else {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if len(h.setupToken) == 0 || subtle.ConstantTimeCompare([]byte(token), h.setupToken) != 1 {
+			sendError(w, http.StatusUnauthorized, errors.New("invalid setup token"))
+			return
+		}
+
+		count, err := h.db.Operators.Count()
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if count > 0 {
+			sendError(w, http.StatusForbidden, db.ErrOperatorsExist)
+			return
+		}
+	}
+
+	challenge, err := h.newCeremony(ceremony{
+		name:       req.Name,
+		inviteHash: inviteHash,
+		register:   true,
+	})
+	if err != nil {
+		sendError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	userID, err := randomBytes(16)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := RegistrationOptions{
+		Challenge:   challenge,
+		Attestation: "none",
+		RP: relyingPartyEntity{
+			ID:   h.rp.ID,
+			Name: "BTRY",
+		},
+		User: userEntity{
+			ID:          webauthn.Encoding.EncodeToString(userID),
+			Name:        req.Name,
+			DisplayName: req.Name,
+		},
+		AuthenticatorSelection: authenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: "required",
+		},
+		PubKeyCredParams: []credentialParameter{
+			{Type: "public-key", Alg: webauthn.AlgES256},
+			{Type: "public-key", Alg: webauthn.AlgEdDSA},
+		},
+		Timeout: ceremonyTimeout.Milliseconds(),
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// FinishRegistration verifies the passkey created by the operator and registers it.
+func (h *Handler) FinishRegistration(w http.ResponseWriter, r *http.Request) {
+	var req RegistrationCredential
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, errors.Wrap(err, "decoding request body"))
+		return
+	}
+
+	clientDataJSON, err := webauthn.Encoding.DecodeString(req.Response.ClientDataJSON)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, errors.Wrap(err, "decoding client data"))
+		return
+	}
+
+	attestationObject, err := webauthn.Encoding.DecodeString(req.Response.AttestationObject)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, errors.Wrap(err, "decoding attestation object"))
+		return
+	}
+
+	challenge, c, err := h.popCeremony(clientDataJSON, true)
+	if err != nil {
+		sendError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	credential, err := h.rp.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		sendError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	operator, err := h.db.Operators.Add(c.name, db.Credential{
+		ID:        credential.ID,
+		PublicKey: credential.PublicKey,
+		SignCount: credential.SignCount,
+	}, c.inviteHash)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidInvite) || errors.Is(err, db.ErrOperatorsExist) {
+			sendError(w, http.StatusForbidden, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, operator)
+}
+
+// BeginLogin starts the authentication of an operator.
+func (h *Handler) BeginLogin(w http.ResponseWriter, r *http.Request) {
+	challenge, err := h.newCeremony(ceremony{register: false})
+	if err != nil {
+		sendError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	resp := LoginOptions{
+		Challenge:        challenge,
+		RPID:             h.rp.ID,
+		UserVerification: "required",
+		Timeout:          ceremonyTimeout.Milliseconds(),
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// FinishLogin verifies the assertion signed by the operator passkey and creates a session.
+func (h *Handler) FinishLogin(w http.ResponseWriter, r *http.Request) {
+	var req LoginCredential
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, errors.Wrap(err, "decoding request body"))
+		return
+	}
+
+	credentialID, err := webauthn.Encoding.DecodeString(req.ID)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, errors.Wrap(err, "decoding credential ID"))
+		return
+	}
+
+	clientDataJSON, err := webauthn.Encoding.DecodeString(req.Response.ClientDataJSON)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, errors.Wrap(err, "decoding client data"))
+		return
+	}
+
+	authData, err := webauthn.Encoding.DecodeString(req.Response.AuthenticatorData)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, errors.Wrap(err, "decoding authenticator data"))
+		return
+	}
+
+	signature, err := webauthn.Encoding.DecodeString(req.Response.Signature)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, errors.Wrap(err, "decoding signature"))
+		return
+	}
+
+	challenge, _, err := h.popCeremony(clientDataJSON, false)
+	if err != nil {
+		sendError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	credential, err := h.db.Operators.GetCredential(credentialID)
+	if err != nil {
+		if errors.Is(err, db.ErrNoCredential) {
+			sendError(w, http.StatusUnauthorized, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	signCount, err := h.rp.VerifyAssertion(webauthn.Credential{
+		ID:        credential.ID,
+		PublicKey: credential.PublicKey,
+		SignCount: credential.SignCount,
+	}, challenge, clientDataJSON, authData, signature)
+	if err != nil {
+		sendError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if err := h.db.Operators.UpdateSignCount(credential.ID, signCount); err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	tokenBytes, err := randomBytes(32)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	token := hex.EncodeToString(tokenBytes)
+	expiresAt := time.Now().Add(h.sessionDuration)
+
+	err = h.db.Sessions.Add(middleware.HashSessionToken(token), credential.OperatorID, expiresAt.Unix())
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	session, err := h.db.Sessions.Get(middleware.HashSessionToken(token))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     middleware.SessionCookie,
+		Value:    token,
+		Path:     "/api/admin",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	resp := LoginResponse{
+		Token:     token,
+		Operator:  session.Operator,
+		ExpiresAt: session.ExpiresAt,
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// Logout terminates the operator session.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	token := middleware.SessionToken(r)
+	if err := h.db.Sessions.Delete(middleware.HashSessionToken(token)); err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     middleware.SessionCookie,
+		Path:     "/api/admin",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	resp := LogoutResponse{Success: true}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// CreateInvite responds with a single-use code to register a new operator with the role specified.
+func (h *Handler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	role, err := db.ParseRole(r.URL.Query().Get("role"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	codeBytes, err := randomBytes(16)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	code := hex.EncodeToString(codeBytes)
+	expiresAt := time.Now().Add(inviteDuration).Unix()
+
+	if err := h.db.Operators.AddInvite(hashSecret(code), role, expiresAt); err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := InviteResponse{
+		Code:      code,
+		Role:      role,
+		ExpiresAt: expiresAt,
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// ListOperators responds with the registered operators.
+func (h *Handler) ListOperators(w http.ResponseWriter, r *http.Request) {
+	operators, err := h.db.Operators.List()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, operators)
+}
+
+// DeleteOperator removes an operator and terminates its sessions.
+func (h *Handler) DeleteOperator(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIntParam(r.URL.Query(), "id", true)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if operator, ok := middleware.OperatorFromContext(r.Context()); ok && operator.ID == id {
+		sendError(w, http.StatusBadRequest, errors.New("operators cannot delete themselves"))
+		return
+	}
+
+	if err := h.db.Operators.Delete(id); err != nil {
+		if errors.Is(err, db.ErrNoOperator) {
+			sendError(w, http.StatusNotFound, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := DeleteOperatorResponse{Success: true}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// ReloadConfig reloads the configuration file, applying the settings that don't require restarting
+// the server.
+func (h *Handler) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	if err := h.reloader.Reload(); err != nil {
+		if errors.Is(err, reload.ErrInvalidConfig) {
+			sendError(w, http.StatusBadRequest, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := ReloadConfigResponse{Success: true}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// newCeremony stores a pending ceremony and returns its encoded challenge.
+func (h *Handler) newCeremony(c ceremony) (string, error) {
+	now := time.Now()
+	for item := range h.ceremonies.IterBuffered() {
+		if now.After(item.Val.expiresAt) {
+			h.ceremonies.Remove(item.Key)
+		}
+	}
+
+	if h.ceremonies.Count() >= maxCeremonies {
+		return "", errors.New("too many pending ceremonies, try again later")
+	}
+
+	challengeBytes, err := randomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	challenge := webauthn.Encoding.EncodeToString(challengeBytes)
+
+	c.expiresAt = now.Add(ceremonyTimeout)
+	h.ceremonies.Set(challenge, c)
+
+	return challenge, nil
+}
+
+// popCeremony removes and returns the pending ceremony whose challenge was signed in the client
+// data.
+func (h *Handler) popCeremony(clientDataJSON []byte, register bool) ([]byte, ceremony, error) {
+	challenge, err := webauthn.Challenge(clientDataJSON)
+	if err != nil {
+		return nil, ceremony{}, err
+	}
+
+	c, ok := h.ceremonies.Pop(webauthn.Encoding.EncodeToString(challenge))
+	if !ok || c.register != register || time.Now().After(c.expiresAt) {
+		return nil, ceremony{}, errors.New("invalid or expired challenge")
+	}
+
+	return challenge, c, nil
+}
+
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.Wrap(err, "generating random bytes")
+	}
+	return b, nil
+}
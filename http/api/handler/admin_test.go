@@ -0,0 +1,341 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/crypto/webauthn"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+	"github.com/aftermath2/BTRY/reload"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+const setupToken = "setup_token"
+
+var adminConfig = config.Admin{
+	RPID:       "btry.example",
+	Origin:     "https://btry.example",
+	SetupToken: setupToken,
+}
+
+func (h *HandlerSuite) TestBeginRegistration() {
+	h.operatorsMock.On("Count").Return(uint64(0), nil)
+
+	body := strings.NewReader(`{"name":"satoshi"}`)
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/register/begin", body)
+	h.req.Header.Set("Authorization", "Bearer "+setupToken)
+	h.handler.BeginRegistration(h.rec, h.req)
+
+	var response handler.RegistrationOptions
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.NotEmpty(response.Challenge)
+	h.Equal("btry.example", response.RP.ID)
+	h.Equal("satoshi", response.User.Name)
+	h.Equal("none", response.Attestation)
+	h.Equal("required", response.AuthenticatorSelection.UserVerification)
+}
+
+func (h *HandlerSuite) TestBeginRegistrationInvite() {
+	invite := "invite"
+	h.operatorsMock.On("GetInviteRole", mock.Anything).Return(db.RoleOperator, nil)
+
+	body := strings.NewReader(`{"name":"hal","invite":"` + invite + `"}`)
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/register/begin", body)
+	h.handler.BeginRegistration(h.rec, h.req)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.operatorsMock.AssertNotCalled(h.T(), "GetInviteRole", invite)
+}
+
+func (h *HandlerSuite) TestBeginRegistrationErrors() {
+	cases := []struct {
+		setMocks     func()
+		desc         string
+		body         string
+		token        string
+		expectedCode int
+	}{
+		{
+			desc:         "Invalid body",
+			body:         "{",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			desc:         "Empty name",
+			body:         `{"name":" "}`,
+			token:        setupToken,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			desc:         "Invalid setup token",
+			body:         `{"name":"satoshi"}`,
+			token:        "invalid",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			desc:  "Operators exist",
+			body:  `{"name":"satoshi"}`,
+			token: setupToken,
+			setMocks: func() {
+				h.operatorsMock.On("Count").Return(uint64(1), nil)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			desc: "Invalid invite",
+			body: `{"name":"satoshi","invite":"invalid"}`,
+			setMocks: func() {
+				h.operatorsMock.On("GetInviteRole", mock.Anything).Return(db.Role(0), db.ErrInvalidInvite)
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.SetupTest()
+			if tc.setMocks != nil {
+				tc.setMocks()
+			}
+
+			h.req = httptest.NewRequest(http.MethodPost, "/admin/register/begin", strings.NewReader(tc.body))
+			if tc.token != "" {
+				h.req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			h.handler.BeginRegistration(h.rec, h.req)
+
+			h.Equal(tc.expectedCode, h.rec.Code)
+		})
+	}
+}
+
+func (h *HandlerSuite) TestFinishRegistrationUnknownChallenge() {
+	clientData := `{"type":"webauthn.create","challenge":"AAAA","origin":"https://btry.example"}`
+	body := `{"id":"AAAA","response":{"clientDataJSON":"` +
+		webauthn.Encoding.EncodeToString([]byte(clientData)) + `","attestationObject":"AAAA"}}`
+
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/register/finish", strings.NewReader(body))
+	h.handler.FinishRegistration(h.rec, h.req)
+
+	h.Equal(http.StatusUnauthorized, h.rec.Code)
+	h.operatorsMock.AssertNotCalled(h.T(), "Add", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestBeginLogin() {
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/login/begin", nil)
+	h.handler.BeginLogin(h.rec, h.req)
+
+	var response handler.LoginOptions
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.NotEmpty(response.Challenge)
+	h.Equal("btry.example", response.RPID)
+	h.Equal("required", response.UserVerification)
+}
+
+func (h *HandlerSuite) TestFinishLoginUnknownCredential() {
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/login/begin", nil)
+	h.handler.BeginLogin(h.rec, h.req)
+
+	var options handler.LoginOptions
+	err := json.NewDecoder(h.rec.Body).Decode(&options)
+	h.NoError(err)
+
+	credentialID := []byte("credential")
+	h.operatorsMock.On("GetCredential", credentialID).Return(db.Credential{}, db.ErrNoCredential)
+
+	clientData := `{"type":"webauthn.get","challenge":"` + options.Challenge +
+		`","origin":"https://btry.example"}`
+	body := `{"id":"` + webauthn.Encoding.EncodeToString(credentialID) + `","response":{` +
+		`"clientDataJSON":"` + webauthn.Encoding.EncodeToString([]byte(clientData)) + `",` +
+		`"authenticatorData":"AAAA","signature":"AAAA"}}`
+
+	h.rec = httptest.NewRecorder()
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/login/finish", strings.NewReader(body))
+	h.handler.FinishLogin(h.rec, h.req)
+
+	h.Equal(http.StatusUnauthorized, h.rec.Code)
+	h.sessionsMock.AssertNotCalled(h.T(), "Add", mock.Anything, mock.Anything, mock.Anything)
+
+	h.Run("Challenges can't be reused", func() {
+		h.rec = httptest.NewRecorder()
+		h.req = httptest.NewRequest(http.MethodPost, "/admin/login/finish", strings.NewReader(body))
+		h.handler.FinishLogin(h.rec, h.req)
+
+		h.Equal(http.StatusUnauthorized, h.rec.Code)
+		h.operatorsMock.AssertNumberOfCalls(h.T(), "GetCredential", 1)
+	})
+}
+
+func (h *HandlerSuite) TestLogout() {
+	token := "token"
+	h.sessionsMock.On("Delete", middleware.HashSessionToken(token)).Return(nil)
+
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/logout", nil)
+	h.req.Header.Set("Authorization", "Bearer "+token)
+	h.handler.Logout(h.rec, h.req)
+
+	var response handler.LogoutResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.True(response.Success)
+	h.sessionsMock.AssertExpectations(h.T())
+}
+
+func (h *HandlerSuite) TestCreateInvite() {
+	h.operatorsMock.On("AddInvite", mock.Anything, db.RoleReadOnly, mock.Anything).Return(nil)
+
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/invites?role=read_only", nil)
+	h.handler.CreateInvite(h.rec, h.req)
+
+	var response handler.InviteResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Len(response.Code, 32)
+	h.Equal(db.RoleReadOnly, response.Role)
+	// Only the hash of the code is stored
+	h.operatorsMock.AssertNotCalled(h.T(), "AddInvite", response.Code, mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestCreateInviteInvalidRole() {
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/invites?role=admin", nil)
+	h.handler.CreateInvite(h.rec, h.req)
+
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestListOperators() {
+	operators := []db.Operator{{ID: 1, Name: "satoshi", Role: db.RoleOwner, CreatedAt: 1231006505}}
+	h.operatorsMock.On("List").Return(operators, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/operators", nil)
+	h.handler.ListOperators(h.rec, h.req)
+
+	var response []map[string]any
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Len(response, 1)
+	h.Equal("owner", response[0]["role"])
+}
+
+func (h *HandlerSuite) TestDeleteOperator() {
+	cases := []struct {
+		err          error
+		desc         string
+		url          string
+		expectedCode int
+	}{
+		{
+			desc:         "Success",
+			url:          "/admin/operators?id=2",
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc:         "Not found",
+			url:          "/admin/operators?id=2",
+			err:          db.ErrNoOperator,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			desc:         "Internal error",
+			url:          "/admin/operators?id=2",
+			err:          errors.New("test"),
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			desc:         "Self",
+			url:          "/admin/operators?id=1",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			desc:         "Missing ID",
+			url:          "/admin/operators",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.SetupTest()
+			h.operatorsMock.On("Delete", uint64(2)).Return(tc.err)
+
+			h.req = httptest.NewRequest(http.MethodDelete, tc.url, nil)
+			h.req = h.req.WithContext(withOperator(h.req.Context(), db.Operator{ID: 1, Role: db.RoleOwner}))
+			h.handler.DeleteOperator(h.rec, h.req)
+
+			h.Equal(tc.expectedCode, h.rec.Code)
+		})
+	}
+}
+
+func (h *HandlerSuite) TestReloadConfig() {
+	cases := []struct {
+		err          error
+		desc         string
+		expectedCode int
+	}{
+		{
+			desc:         "Success",
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc:         "Invalid configuration",
+			err:          errors.Wrap(reload.ErrInvalidConfig, "server settings changed"),
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			desc:         "Internal error",
+			err:          errors.New("test"),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.SetupTest()
+			h.reloaderMock.On("Reload").Return(tc.err)
+
+			h.req = httptest.NewRequest(http.MethodPost, "/admin/config/reload", nil)
+			h.handler.ReloadConfig(h.rec, h.req)
+
+			h.Equal(tc.expectedCode, h.rec.Code)
+		})
+	}
+}
+
+// withOperator runs the request through the admin middleware so the operator is set in the context.
+func withOperator(ctx context.Context, operator db.Operator) context.Context {
+	sessionsMock := db.NewSessionsStoreMock()
+	sessionsMock.On("Get", mock.Anything).Return(db.Session{Operator: operator}, nil)
+	admin := middleware.NewAdmin(adminConfig, &db.DB{Sessions: sessionsMock})
+
+	var result context.Context
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result = r.Context()
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer token")
+	admin.Authorize(db.RoleReadOnly)(next).ServeHTTP(httptest.NewRecorder(), req)
+
+	return result
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/crypto"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxAirdropRecipients is the maximum number of public keys of a single airdrop
+	maxAirdropRecipients = 10_000
+	// maxPromoLength is the maximum length of the promotion tag
+	maxPromoLength = 32
+)
+
+// AirdropRequest is the request body of the /admin/airdrops endpoint.
+type AirdropRequest struct {
+	// PublicKeys receive the tickets, ignored if PreviousRound is true
+	PublicKeys []string `json:"public_keys,omitempty"`
+	Promo      string   `json:"promo"`
+	// Pool defaults to the first one configured
+	Pool    string `json:"pool,omitempty"`
+	Tickets uint64 `json:"tickets"`
+	// PreviousRound sends the tickets to every player that paid for tickets in the last lottery drawn
+	PreviousRound bool `json:"previous_round,omitempty"`
+}
+
+// AirdropResponse is the response schema of the /admin/airdrops endpoint.
+type AirdropResponse struct {
+	Bets       []db.Bet `json:"bets"`
+	FeeBalance uint64   `json:"fee_balance"`
+}
+
+// Airdrop gives free tickets of the next lottery to a list of public keys, or to the players of the
+// previous round. The tickets are funded by the fee balance and tagged with a promotion so they are
+// told apart from the paid ones.
+func (h *Handler) Airdrop(w http.ResponseWriter, r *http.Request) {
+	var req AirdropRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, errors.Wrap(err, "decoding request body"))
+		return
+	}
+
+	if err := h.validateAirdrop(&req); err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	operator, ok := middleware.OperatorFromContext(r.Context())
+	if !ok {
+		sendError(w, http.StatusUnauthorized, errors.New("operator not authenticated"))
+		return
+	}
+
+	publicKeys := req.PublicKeys
+	if req.PreviousRound {
+		rounds, err := h.db.Stats.ListRounds(0, 1, true)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if len(rounds) == 0 {
+			sendError(w, http.StatusNotFound, errors.New("no lottery has been drawn yet"))
+			return
+		}
+
+		publicKeys, err = h.db.Bets.ListPlayers(rounds[0].Height)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	if len(publicKeys) == 0 {
+		sendError(w, http.StatusBadRequest, errors.New("no recipients"))
+		return
+	}
+	if len(publicKeys) > maxAirdropRecipients {
+		sendError(w, http.StatusBadRequest, errors.Errorf("too many recipients, the maximum is %d",
+			maxAirdropRecipients))
+		return
+	}
+
+	bets, err := h.db.Bets.Airdrop(publicKeys, req.Tickets, req.Pool, req.Promo, time.Now().Unix())
+	if err != nil {
+		if errors.Is(err, db.ErrInsufficientFeeBalance) {
+			sendError(w, http.StatusConflict, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.auditor.Record(audit.AirdropIssued, map[string]any{
+		"operator_id": operator.ID,
+		"promo":       req.Promo,
+		"pool":        req.Pool,
+		"tickets":     req.Tickets,
+		"recipients":  len(bets),
+	})
+
+	balance, err := h.db.Bets.GetFeeBalance()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, AirdropResponse{Bets: bets, FeeBalance: balance})
+}
+
+// validateAirdrop checks the airdrop parameters and sets the default pool.
+func (h *Handler) validateAirdrop(req *AirdropRequest) error {
+	if req.Tickets == 0 {
+		return errors.New("tickets must be higher than zero")
+	}
+
+	if req.Promo == "" || len(req.Promo) > maxPromoLength {
+		return errors.Errorf("promo must have between 1 and %d characters", maxPromoLength)
+	}
+
+	if req.Pool == "" {
+		req.Pool = h.pools[0].Name
+	}
+	exists := slices.ContainsFunc(h.pools, func(pool config.Pool) bool {
+		return pool.Name == req.Pool
+	})
+	if !exists {
+		return errors.Errorf("pool %q does not exist", req.Pool)
+	}
+
+	if req.PreviousRound {
+		return nil
+	}
+
+	for _, publicKey := range req.PublicKeys {
+		if err := crypto.ValidatePublicKey(publicKey); err != nil {
+			return errors.Wrapf(err, "invalid public key %q", publicKey)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func (h *HandlerSuite) TestAirdrop() {
+	bets := []db.Bet{
+		{PublicKey: validPublicKey, Tickets: 10, Bonus: 10, Promo: "launch", FirstTicket: 1, Index: 10},
+	}
+	h.betsMock.On("Airdrop", []string{validPublicKey}, uint64(10), "", "launch", mock.Anything).
+		Return(bets, nil)
+	h.betsMock.On("GetFeeBalance").Return(uint64(90), nil)
+	h.auditorMock.On("Record", audit.AirdropIssued, mock.Anything)
+
+	body := `{"public_keys":["` + validPublicKey + `"],"promo":"launch","tickets":10}`
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/airdrops", strings.NewReader(body))
+	h.req = h.req.WithContext(withOperator(h.req.Context(), db.Operator{ID: 2, Role: db.RoleOperator}))
+	h.handler.Airdrop(h.rec, h.req)
+
+	var response handler.AirdropResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(handler.AirdropResponse{Bets: bets, FeeBalance: 90}, response)
+}
+
+func (h *HandlerSuite) TestAirdropPreviousRound() {
+	players := []string{validPublicKey, "7d959d6d552c7d38b3ecafb72805fa03a6dee6b7f0c5f63f57a371736cb004b1"}
+	h.statsMock.On("ListRounds", uint64(0), uint64(1), true).Return([]db.RoundStats{{Height: 144}}, nil)
+	h.betsMock.On("ListPlayers", uint32(144)).Return(players, nil)
+	h.betsMock.On("Airdrop", players, uint64(1), "", "comeback", mock.Anything).
+		Return([]db.Bet{}, db.ErrInsufficientFeeBalance)
+
+	body := `{"previous_round":true,"promo":"comeback","tickets":1}`
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/airdrops", strings.NewReader(body))
+	h.req = h.req.WithContext(withOperator(h.req.Context(), db.Operator{ID: 2, Role: db.RoleOperator}))
+	h.handler.Airdrop(h.rec, h.req)
+
+	h.Equal(http.StatusConflict, h.rec.Code)
+	h.auditorMock.AssertNotCalled(h.T(), "Record", audit.AirdropIssued, mock.Anything)
+}
+
+func (h *HandlerSuite) TestAirdropInvalid() {
+	cases := []struct {
+		desc string
+		body string
+	}{
+		{
+			desc: "Invalid body",
+			body: "tickets",
+		},
+		{
+			desc: "No tickets",
+			body: `{"public_keys":["` + validPublicKey + `"],"promo":"launch"}`,
+		},
+		{
+			desc: "Missing promo",
+			body: `{"public_keys":["` + validPublicKey + `"],"tickets":1}`,
+		},
+		{
+			desc: "Promo too long",
+			body: `{"public_keys":["` + validPublicKey + `"],"promo":"` + strings.Repeat("a", 33) + `","tickets":1}`,
+		},
+		{
+			desc: "Unknown pool",
+			body: `{"public_keys":["` + validPublicKey + `"],"promo":"launch","pool":"whale","tickets":1}`,
+		},
+		{
+			desc: "Invalid public key",
+			body: `{"public_keys":["abc"],"promo":"launch","tickets":1}`,
+		},
+		{
+			desc: "No recipients",
+			body: `{"promo":"launch","tickets":1}`,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			req := httptest.NewRequest(http.MethodPost, "/admin/airdrops", strings.NewReader(tc.body))
+			req = req.WithContext(withOperator(req.Context(), db.Operator{ID: 2, Role: db.RoleOperator}))
+			rec := httptest.NewRecorder()
+			h.handler.Airdrop(rec, req)
+
+			h.Equal(http.StatusBadRequest, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"encoding/hex"
+	"net/http"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+
+	"github.com/pkg/errors"
+)
+
+const maxAPIKeyNameLength = 64
+
+// APIKeyResponse is the response schema of the POST /admin/keys and POST /admin/keys/rotate
+// endpoints. The key is only returned once.
+type APIKeyResponse struct {
+	Key    string    `json:"key"`
+	APIKey db.APIKey `json:"api_key"`
+}
+
+// RevokeAPIKeyResponse is the response schema of the DELETE /admin/keys endpoint.
+type RevokeAPIKeyResponse struct {
+	Success bool `json:"success,omitempty"`
+}
+
+// CreateAPIKey responds with a new API key for an integrator, granted the scopes specified.
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	name := query.Get("name")
+	if name == "" || len(name) > maxAPIKeyNameLength {
+		sendError(w, http.StatusBadRequest, errors.New("invalid name"))
+		return
+	}
+
+	scopes, err := db.ParseScopes(query.Get("scopes"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rateLimit, err := parseIntParam(query, "rate_limit", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	key, err := newAPIKey()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	apiKey, err := h.db.APIKeys.Add(name, middleware.HashAPIKey(key), scopes, rateLimit)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := APIKeyResponse{
+		Key:    key,
+		APIKey: apiKey,
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// ListAPIKeys responds with the API keys created, without the keys themselves.
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	apiKeys, err := h.db.APIKeys.List()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, apiKeys)
+}
+
+// RotateAPIKey replaces an API key with a new one, keeping its scopes and rate limit.
+func (h *Handler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIntParam(r.URL.Query(), "id", true)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	key, err := newAPIKey()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	keyHash := middleware.HashAPIKey(key)
+
+	if err := h.db.APIKeys.Rotate(id, keyHash); err != nil {
+		if errors.Is(err, db.ErrNoAPIKey) {
+			sendError(w, http.StatusNotFound, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	apiKey, err := h.db.APIKeys.Get(keyHash)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := APIKeyResponse{
+		Key:    key,
+		APIKey: apiKey,
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// RevokeAPIKey deletes an API key, the requests using it are rejected from then on.
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIntParam(r.URL.Query(), "id", true)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.db.APIKeys.Revoke(id); err != nil {
+		if errors.Is(err, db.ErrNoAPIKey) {
+			sendError(w, http.StatusNotFound, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := RevokeAPIKeyResponse{Success: true}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+func newAPIKey() (string, error) {
+	keyBytes, err := randomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(keyBytes), nil
+}
@@ -0,0 +1,140 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+func (h *HandlerSuite) TestCreateAPIKey() {
+	scopes := []db.Scope{db.ScopeStats, db.ScopeBets}
+	apiKey := db.APIKey{ID: 1, Name: "kiosk", Scopes: scopes, RateLimit: 100}
+	h.apiKeysMock.On("Add", "kiosk", mock.Anything, scopes, uint64(100)).Return(apiKey, nil)
+
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/keys?name=kiosk&scopes=stats,bets&rate_limit=100", nil)
+	h.handler.CreateAPIKey(h.rec, h.req)
+
+	var response handler.APIKeyResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Len(response.Key, 64)
+	h.Equal(apiKey, response.APIKey)
+	// Only the hash of the key is stored
+	h.apiKeysMock.AssertCalled(h.T(), "Add", "kiosk", middleware.HashAPIKey(response.Key), scopes, uint64(100))
+}
+
+func (h *HandlerSuite) TestCreateAPIKeyInvalid() {
+	cases := []struct {
+		desc string
+		url  string
+	}{
+		{
+			desc: "Missing name",
+			url:  "/admin/keys?scopes=stats",
+		},
+		{
+			desc: "Invalid scope",
+			url:  "/admin/keys?name=kiosk&scopes=admin",
+		},
+		{
+			desc: "Invalid rate limit",
+			url:  "/admin/keys?name=kiosk&scopes=stats&rate_limit=-1",
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.SetupTest()
+
+			h.req = httptest.NewRequest(http.MethodPost, tc.url, nil)
+			h.handler.CreateAPIKey(h.rec, h.req)
+
+			h.Equal(http.StatusBadRequest, h.rec.Code)
+		})
+	}
+}
+
+func (h *HandlerSuite) TestListAPIKeys() {
+	apiKeys := []db.APIKey{{ID: 1, Name: "kiosk", Scopes: []db.Scope{db.ScopeBets}, CreatedAt: 1231006505}}
+	h.apiKeysMock.On("List").Return(apiKeys, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/keys", nil)
+	h.handler.ListAPIKeys(h.rec, h.req)
+
+	var response []db.APIKey
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(apiKeys, response)
+}
+
+func (h *HandlerSuite) TestRotateAPIKey() {
+	apiKey := db.APIKey{ID: 1, Name: "kiosk", Scopes: []db.Scope{db.ScopeBets}, RotatedAt: 1231006505}
+	h.apiKeysMock.On("Rotate", uint64(1), mock.Anything).Return(nil)
+	h.apiKeysMock.On("Get", mock.Anything).Return(apiKey, nil)
+
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/keys/rotate?id=1", nil)
+	h.handler.RotateAPIKey(h.rec, h.req)
+
+	var response handler.APIKeyResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(apiKey, response.APIKey)
+	h.apiKeysMock.AssertCalled(h.T(), "Rotate", uint64(1), middleware.HashAPIKey(response.Key))
+}
+
+func (h *HandlerSuite) TestRevokeAPIKey() {
+	cases := []struct {
+		err          error
+		desc         string
+		url          string
+		expectedCode int
+	}{
+		{
+			desc:         "Success",
+			url:          "/admin/keys?id=1",
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc:         "Not found",
+			url:          "/admin/keys?id=1",
+			err:          db.ErrNoAPIKey,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			desc:         "Internal error",
+			url:          "/admin/keys?id=1",
+			err:          errors.New("test"),
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			desc:         "Missing ID",
+			url:          "/admin/keys",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.SetupTest()
+			h.apiKeysMock.On("Revoke", uint64(1)).Return(tc.err)
+
+			h.req = httptest.NewRequest(http.MethodDelete, tc.url, nil)
+			h.handler.RevokeAPIKey(h.rec, h.req)
+
+			h.Equal(tc.expectedCode, h.rec.Code)
+		})
+	}
+}
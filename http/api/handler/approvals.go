@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+
+	"github.com/pkg/errors"
+)
+
+// ApprovalResponse is the response schema of the /admin/approvals endpoint.
+type ApprovalResponse struct {
+	Approval db.Approval `json:"approval"`
+	// Released is true when the approval was the last one required and the payout was sent
+	Released  bool   `json:"released"`
+	PaymentID uint64 `json:"payment_id,omitempty"`
+}
+
+// ListApprovals responds with the payouts waiting for the operators approval.
+func (h *Handler) ListApprovals(w http.ResponseWriter, r *http.Request) {
+	approvals, err := h.db.Approvals.List()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, approvals)
+}
+
+// ApprovePayout records the operator's approval of a payout, paying it once two different
+// operators have approved it.
+func (h *Handler) ApprovePayout(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIntParam(r.URL.Query(), "id", true)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	operator, ok := middleware.OperatorFromContext(r.Context())
+	if !ok {
+		sendError(w, http.StatusUnauthorized, errors.New("operator not authenticated"))
+		return
+	}
+
+	approval, released, err := h.approvals.Approve(id, operator.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrApprovalNotFound):
+			sendError(w, http.StatusNotFound, err)
+		case errors.Is(err, db.ErrAlreadyApproved):
+			sendError(w, http.StatusConflict, err)
+		default:
+			sendError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	h.auditor.Record(audit.PayoutApproved, map[string]any{
+		"approval_id": approval.ID,
+		"operator_id": operator.ID,
+		"released":    released,
+	})
+
+	resp := ApprovalResponse{
+		Approval: approval,
+		Released: released,
+	}
+	if !released {
+		sendResponse(w, http.StatusOK, resp)
+		return
+	}
+
+	ctx := r.Context()
+	invoice, err := h.lnd.DecodeInvoice(ctx, approval.PaymentRequest)
+	if err != nil {
+		if err := h.db.Prizes.Restore(approval.Claims); err != nil {
+			sendError(w, http.StatusInternalServerError, errors.Wrap(err, "restoring prizes"))
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	// The amount of zero-amount invoices was set when the withdrawal was requested
+	if invoice.NumSatoshis == 0 {
+		invoice.NumSatoshis = int64(approval.Amount)
+	}
+
+	resp.PaymentID = h.eventStreamer.TrackWithdrawal(approval.PaymentHash, approval.PublicKey,
+		approval.Claims)
+	updates, err := h.lnd.PayInvoice(context.WithoutCancel(ctx), invoice, int64(approval.Fee), true)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.eventStreamer.WatchPayment(approval.PaymentHash, updates)
+
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// RejectPayout discards a payout pending approval, returning its prizes to the winner.
+func (h *Handler) RejectPayout(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIntParam(r.URL.Query(), "id", true)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	approval, err := h.approvals.Reject(id)
+	if err != nil {
+		if errors.Is(err, db.ErrApprovalNotFound) {
+			sendError(w, http.StatusNotFound, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	data := map[string]any{"approval_id": approval.ID}
+	if operator, ok := middleware.OperatorFromContext(r.Context()); ok {
+		data["operator_id"] = operator.ID
+	}
+	h.auditor.Record(audit.PayoutRejected, data)
+
+	sendResponse(w, http.StatusOK, ApprovalResponse{Approval: approval})
+}
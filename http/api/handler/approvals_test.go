@@ -0,0 +1,201 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/stretchr/testify/mock"
+)
+
+// approvalThreshold is the amount above which withdrawals must be approved in the handler tests.
+const approvalThreshold = 100_000
+
+func (h *HandlerSuite) TestWithdrawApprovalRequired() {
+	paymentRequest := "lnbcrt"
+
+	url := url.Values{}
+	url.Add("k1", validSignature)
+	url.Add("pubkey", validPublicKey)
+	url.Add("pr", paymentRequest)
+	url.Add("fee", "100")
+
+	h.req = httptest.NewRequest(http.MethodPost, "/withdraw?"+url.Encode(), nil)
+	ctx := h.req.Context()
+
+	invoice := &lnrpc.PayReq{
+		PaymentHash: "hash",
+		NumSatoshis: 150_000,
+		Timestamp:   time.Now().Unix(),
+		Expiry:      3600,
+	}
+	h.lndMock.On("DecodeInvoice", ctx, paymentRequest).Return(invoice, nil)
+
+	claims := []db.PrizesRow{{RowID: 1, Amount: 150_100}}
+	h.prizesMock.On("Get", validPublicKey).Return(uint64(150_100), nil)
+	h.prizesMock.On("Claim", validPublicKey, "hash", uint64(150_100)).Return(claims, nil)
+
+	approvalID := uint64(7)
+	h.approvalsMock.On("Add", mock.MatchedBy(func(approval db.Approval) bool {
+		return approval.PublicKey == validPublicKey && approval.PaymentRequest == paymentRequest &&
+			approval.Amount == 150_000 && approval.Fee == 100 &&
+			approval.ExpiresAt == invoice.Timestamp+invoice.Expiry
+	})).Return(approvalID, nil)
+	h.queueMock.On("Schedule", "expire_approval", approvalID, mock.Anything).Return(nil)
+	h.auditorMock.On("Record", audit.PayoutHeld, mock.Anything)
+
+	h.handler.Withdraw(h.rec, h.req)
+
+	var response handler.WithdrawResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal("OK", response.Status)
+	h.Equal([]uint64{approvalID}, response.ApprovalIDs)
+	h.lndMock.AssertNotCalled(h.T(), "PayInvoice", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestListApprovals() {
+	approvals := []db.Approval{{ID: 1, PublicKey: validPublicKey, Amount: 150_000, Approvers: []uint64{2}}}
+	h.approvalsMock.On("List").Return(approvals, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/approvals", nil)
+	h.handler.ListApprovals(h.rec, h.req)
+
+	var response []db.Approval
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(approvals, response)
+}
+
+func (h *HandlerSuite) TestApprovePayout() {
+	approval := db.Approval{ID: 1, PublicKey: validPublicKey, Approvers: []uint64{2}}
+	h.approvalsMock.On("Approve", uint64(1), uint64(2), mock.Anything, 2).Return(approval, false, nil)
+	h.auditorMock.On("Record", audit.PayoutApproved, mock.Anything)
+
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/approvals?id=1", nil)
+	h.req = h.req.WithContext(withOperator(h.req.Context(), db.Operator{ID: 2, Role: db.RoleOperator}))
+	h.handler.ApprovePayout(h.rec, h.req)
+
+	var response handler.ApprovalResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.False(response.Released)
+	h.lndMock.AssertNotCalled(h.T(), "PayInvoice", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestApprovePayoutReleased() {
+	claims := []db.PrizesRow{{RowID: 1, Amount: 150_100}}
+	approval := db.Approval{
+		ID:             1,
+		PublicKey:      validPublicKey,
+		PaymentRequest: "lnbcrt",
+		PaymentHash:    "hash",
+		Claims:         claims,
+		Amount:         150_000,
+		Fee:            100,
+		Approvers:      []uint64{2, 3},
+	}
+	h.approvalsMock.On("Approve", uint64(1), uint64(3), mock.Anything, 2).Return(approval, true, nil)
+	h.auditorMock.On("Record", audit.PayoutApproved, mock.Anything)
+
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/approvals?id=1", nil)
+	h.req = h.req.WithContext(withOperator(h.req.Context(), db.Operator{ID: 3, Role: db.RoleOperator}))
+	ctx := h.req.Context()
+
+	// Zero-amount invoices are paid with the amount approved
+	invoice := &lnrpc.PayReq{PaymentHash: "hash"}
+	h.lndMock.On("DecodeInvoice", ctx, "lnbcrt").Return(invoice, nil)
+	h.eventStreamerMock.On("TrackWithdrawal", "hash", validPublicKey, claims).Return(uint64(9))
+	h.lndMock.On("PayInvoice", mock.Anything, invoice, int64(100), true).Return(nil, nil)
+	h.eventStreamerMock.On("WatchPayment", invoice.PaymentHash, nil)
+
+	h.handler.ApprovePayout(h.rec, h.req)
+
+	var response handler.ApprovalResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.True(response.Released)
+	h.Equal(uint64(9), response.PaymentID)
+	h.Equal(int64(150_000), invoice.NumSatoshis)
+	h.lndMock.AssertExpectations(h.T())
+}
+
+func (h *HandlerSuite) TestApprovePayoutErrors() {
+	cases := []struct {
+		err          error
+		desc         string
+		url          string
+		expectedCode int
+	}{
+		{
+			desc:         "Missing ID",
+			url:          "/admin/approvals",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			desc:         "Not found",
+			url:          "/admin/approvals?id=1",
+			err:          db.ErrApprovalNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			desc:         "Already approved",
+			url:          "/admin/approvals?id=1",
+			err:          db.ErrAlreadyApproved,
+			expectedCode: http.StatusConflict,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.rec = httptest.NewRecorder()
+			h.approvalsMock.ExpectedCalls = nil
+			h.approvalsMock.On("Approve", uint64(1), uint64(2), mock.Anything, 2).
+				Return(db.Approval{}, false, tc.err)
+
+			h.req = httptest.NewRequest(http.MethodPost, tc.url, nil)
+			h.req = h.req.WithContext(withOperator(h.req.Context(), db.Operator{ID: 2, Role: db.RoleOperator}))
+			h.handler.ApprovePayout(h.rec, h.req)
+
+			h.Equal(tc.expectedCode, h.rec.Code)
+		})
+	}
+}
+
+func (h *HandlerSuite) TestRejectPayout() {
+	claims := []db.PrizesRow{{RowID: 1, Amount: 150_100}}
+	h.approvalsMock.On("Delete", uint64(1)).Return(db.Approval{ID: 1, Claims: claims}, nil)
+	h.prizesMock.On("Restore", claims).Return(nil)
+	h.auditorMock.On("Record", audit.PayoutRejected, mock.Anything)
+
+	h.req = httptest.NewRequest(http.MethodDelete, "/admin/approvals?id=1", nil)
+	h.req = h.req.WithContext(withOperator(h.req.Context(), db.Operator{ID: 2, Role: db.RoleOperator}))
+	h.handler.RejectPayout(h.rec, h.req)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.prizesMock.AssertExpectations(h.T())
+}
+
+func (h *HandlerSuite) TestRejectPayoutNotFound() {
+	h.approvalsMock.On("Delete", uint64(1)).Return(db.Approval{}, db.ErrApprovalNotFound)
+
+	h.req = httptest.NewRequest(http.MethodDelete, "/admin/approvals?id=1", nil)
+	h.handler.RejectPayout(h.rec, h.req)
+
+	h.Equal(http.StatusNotFound, h.rec.Code)
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// ArchivesResponse is the response schema of the /archives endpoint.
+type ArchivesResponse struct {
+	Archives []db.Archive `json:"archives,omitempty"`
+}
+
+// GetArchives responds with the exports of the draw history to Nostr and IPFS, which can be
+// retrieved with their references even if the server is gone.
+func (h *Handler) GetArchives(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	offset, err := parseIntParam(query, "offset", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, err := parseIntParam(query, "limit", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	reverse := false
+	reverseStr := query.Get("reverse")
+	if reverseStr != "" {
+		v, err := strconv.ParseBool(reverseStr)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, errors.Wrap(err, "invalid reverse parameter"))
+			return
+		}
+		reverse = v
+	}
+
+	archives, err := h.db.ReadReplica().Archives.List(offset, limit, reverse)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, ArchivesResponse{Archives: archives})
+}
@@ -0,0 +1,46 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func (h *HandlerSuite) TestGetArchives() {
+	archives := []db.Archive{
+		{
+			ID:          3,
+			Destination: "ipfs",
+			Reference:   "cid",
+			Hash:        "hash",
+			CreatedAt:   1_700_000_000,
+			FirstHeight: 144,
+			LastHeight:  288,
+		},
+	}
+	h.archivesMock.On("List", uint64(2), uint64(10), true).Return(archives, nil)
+	h.req = httptest.NewRequest(http.MethodGet, "/archives?offset=2&limit=10&reverse=true", nil)
+
+	h.handler.GetArchives(h.rec, h.req)
+
+	var response handler.ArchivesResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(archives, response.Archives)
+}
+
+func (h *HandlerSuite) TestGetArchivesInvalidLimit() {
+	h.req = httptest.NewRequest(http.MethodGet, "/archives?limit=many", nil)
+
+	h.handler.GetArchives(h.rec, h.req)
+
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+	h.archivesMock.AssertNotCalled(h.T(), "List", mock.Anything, mock.Anything, mock.Anything)
+}
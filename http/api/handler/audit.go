@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/aftermath2/BTRY/db"
+)
+
+// AuditLogResponse is the response schema of the /admin/audit endpoint.
+type AuditLogResponse struct {
+	PublicKey string          `json:"public_key"`
+	Entries   []db.AuditEntry `json:"entries"`
+}
+
+// GetAuditLog responds with the audit log entries and the public key that signed them.
+func (h *Handler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	offset, err := parseIntParam(query, "offset", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, err := parseIntParam(query, "limit", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	entries, err := h.db.Audit.List(offset, limit)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := AuditLogResponse{
+		PublicKey: h.auditor.PublicKey(),
+		Entries:   entries,
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
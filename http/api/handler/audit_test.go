@@ -0,0 +1,63 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+	"github.com/aftermath2/BTRY/http/api/handler"
+
+	"github.com/pkg/errors"
+)
+
+func (h *HandlerSuite) TestGetAuditLog() {
+	publicKey := "e68b99fc5f60c971926fdc3a3af38ccf67e6f4306ab1c388735533e7c5dcc749"
+	entries := []db.AuditEntry{
+		{
+			ID:        5,
+			Timestamp: 1715000000,
+			Event:     "bet_accepted",
+			Data:      "{}",
+			PrevHash:  "prev_hash",
+			Hash:      "hash",
+			Signature: "signature",
+		},
+	}
+	h.auditMock.On("List", uint64(4), uint64(1)).Return(entries, nil)
+	h.auditorMock.On("PublicKey").Return(publicKey)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/audit?offset=4&limit=1", nil)
+	h.handler.GetAuditLog(h.rec, h.req)
+
+	var response handler.AuditLogResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(publicKey, response.PublicKey)
+	h.Equal(entries, response.Entries)
+}
+
+func (h *HandlerSuite) TestGetAuditLogInvalidParameters() {
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/audit?offset=first", nil)
+	h.handler.GetAuditLog(h.rec, h.req)
+
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestGetAuditLogInternalError() {
+	expectedErr := errors.New("test error")
+	h.auditMock.On("List", uint64(0), uint64(0)).Return(nil, expectedErr)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/audit", nil)
+	h.handler.GetAuditLog(h.rec, h.req)
+
+	var response apierrors.Response
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusInternalServerError, h.rec.Code)
+	h.Equal(expectedErr.Error(), response.Error.Message)
+}
@@ -1,20 +1,40 @@
 package handler
 
 import (
+	"encoding/hex"
 	"net/http"
 	"strconv"
 
+	"github.com/aftermath2/BTRY/audit"
 	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/policy"
+	"github.com/aftermath2/BTRY/rates"
 
 	"github.com/pkg/errors"
 )
 
 // BetsResponse is the response schema of the /bets endpoint.
 type BetsResponse struct {
-	Bets []db.Bet `json:"bets,omitempty"`
+	Bets []BetResponse `json:"bets,omitempty"`
 }
 
-// GetBets responds with the list of bets.
+// BetResponse is a bet along with the approximate fiat value of the amount paid.
+type BetResponse struct {
+	Fiat rates.Values `json:"fiat,omitempty"`
+	db.Bet
+}
+
+// TicketResponse is the response schema of the /tickets endpoint.
+type TicketResponse struct {
+	PublicKey string `json:"public_key"`
+}
+
+// CancelBetResponse is the response schema of the DELETE /bets endpoint.
+type CancelBetResponse struct {
+	Refund uint64 `json:"refund"`
+}
+
+// GetBets responds with the list of bets of a lottery pool, the unnamed one if not specified.
 func (h *Handler) GetBets(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 
@@ -47,14 +67,97 @@ func (h *Handler) GetBets(w http.ResponseWriter, r *http.Request) {
 		reverse = v
 	}
 
-	bets, err := h.db.Bets.List(uint32(height), offset, limit, reverse)
+	pool := query.Get("pool")
+	bets, err := h.db.ReadReplica().Bets.List(uint32(height), pool, offset, limit, reverse)
 	if err != nil {
 		sendError(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	respBody := BetsResponse{
-		Bets: bets,
+		Bets: make([]BetResponse, 0, len(bets)),
+	}
+	for _, bet := range bets {
+		respBody.Bets = append(respBody.Bets, BetResponse{
+			Bet: bet,
+			// Bonus tickets were not paid for
+			Fiat: h.rates.Convert(bet.Tickets - bet.Bonus),
+		})
 	}
 	sendResponse(w, http.StatusOK, respBody)
 }
+
+// GetTicket responds with the public key holding a ticket of a lottery pool, the unnamed one if
+// not specified.
+func (h *Handler) GetTicket(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	height, err := parseIntParam(query, "height", true)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ticket, err := parseIntParam(query, "ticket", true)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	pool := query.Get("pool")
+	publicKey, err := h.db.ReadReplica().Bets.GetPublicKey(uint32(height), pool, ticket)
+	if err != nil {
+		if errors.Is(err, db.ErrTicketNotFound) {
+			sendError(w, http.StatusNotFound, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, TicketResponse{PublicKey: publicKey})
+}
+
+// CancelBet cancels a bet of the lottery in progress that was placed recently, the sats paid minus
+// the cancellation fee are refunded as a prize. The bet is identified by the hash of the invoice
+// that paid it.
+func (h *Handler) CancelBet(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := getSignedPublicKey(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	paymentHash := r.URL.Query().Get("payment_hash")
+	if hash, err := hex.DecodeString(paymentHash); err != nil || len(hash) != 32 {
+		sendError(w, http.StatusBadRequest, errors.New("invalid payment hash"))
+		return
+	}
+
+	bet, refund, err := h.cancellation.Cancel(publicKey, paymentHash)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrBetNotFound):
+			sendError(w, http.StatusNotFound, err)
+		case errors.Is(err, db.ErrCancellationExpired), errors.Is(err, policy.ErrCancellationDisabled):
+			sendError(w, http.StatusForbidden, err)
+		default:
+			sendError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	// The following bets tickets moved down, the range released lets verifiers reconcile receipts
+	h.auditor.Record(audit.BetCancelled, map[string]any{
+		"public_key":     publicKey,
+		"payment_hash":   paymentHash,
+		"lottery_height": bet.LotteryHeight,
+		"pool":           bet.Pool,
+		"first_ticket":   bet.FirstTicket,
+		"last_ticket":    bet.Index,
+		"refund":         refund,
+	})
+
+	resp := CancelBetResponse{Refund: refund}
+	sendResponse(w, http.StatusOK, resp)
+}
@@ -7,13 +7,29 @@ import (
 	"net/url"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/crypto"
 	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
 	"github.com/aftermath2/BTRY/http/api/handler"
 	"github.com/aftermath2/BTRY/http/api/sse"
+	"github.com/aftermath2/BTRY/http/api/tokens"
+	"github.com/aftermath2/BTRY/jobs"
 	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/lottery/engine"
+	"github.com/aftermath2/BTRY/policy"
+	"github.com/aftermath2/BTRY/rates"
+	"github.com/aftermath2/BTRY/reload"
+	"github.com/aftermath2/BTRY/reserves"
+	"github.com/aftermath2/BTRY/swaps"
+	"github.com/aftermath2/BTRY/webhooks"
 
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -22,14 +38,53 @@ type HandlerSuite struct {
 
 	rec               *httptest.ResponseRecorder
 	req               *http.Request
+	accessListsMock   *db.AccessListsStoreMock
+	apiKeysMock       *db.APIKeysStoreMock
+	approvalsMock     *db.ApprovalsStoreMock
+	archivesMock      *db.ArchivesStoreMock
+	auditMock         *db.AuditStoreMock
 	betsMock          *db.BetsStoreMock
+	betArchivesMock   *db.BetArchivesStoreMock
+	betSwapsMock      *db.BetSwapsStoreMock
+	invoicesMock      *db.InvoicesStoreMock
+	claimCodesMock    *db.ClaimCodesStoreMock
+	claimTokensMock   *db.ClaimTokensStoreMock
+	drawTimingsMock   *db.DrawTimingsStoreMock
+	fairnessMock      *db.FairnessStoreMock
+	feesMock          *db.FeeDistributionsStoreMock
+	growthMock        *db.GrowthStoreMock
 	lightningMock     *db.LightningStoreMock
+	limitsMock        *db.LimitsStoreMock
+	migrationsMock    *db.RoundMigrationsStoreMock
+	notificationsMock *db.NotificationsStoreMock
 	lotteriesMock     *db.LotteriesStoreMock
+	operatorsMock     *db.OperatorsStoreMock
+	payoutsMock       *db.PayoutsStoreMock
+	postponementsMock *db.PostponementsStoreMock
 	prizesMock        *db.PrizesStoreMock
+	privacyMock       *db.PrivacyStoreMock
+	receiptsMock      *db.ReceiptsStoreMock
+	revealsMock       *db.RevealsStoreMock
+	scheduledMock     *db.ScheduledPayoutsStoreMock
+	searchMock        *db.SearchStoreMock
+	sessionsMock      *db.SessionsStoreMock
+	statsMock         *db.StatsStoreMock
+	swapClaimsMock    *db.SwapClaimsStoreMock
+	webhooksMock      *db.WebhooksStoreMock
 	winnersMock       *db.WinnersStoreMock
 	lndMock           *lightning.ClientMock
 	handler           *handler.Handler
+	identity          *crypto.Identity
+	jurisdiction      *policy.Jurisdiction
+	maintenance       *policy.Maintenance
+	queueMock         *jobs.QueueMock
+	ratesMock         *rates.RatesMock
+	reservesMock      *reserves.ProverMock
+	swapsMock         *swaps.ProviderMock
 	eventStreamerMock *sse.StreamerMock
+	auditorMock       *audit.AuditorMock
+	publisherMock     *webhooks.PublisherMock
+	reloaderMock      *reload.ReloaderMock
 }
 
 func TestHandlerSuite(t *testing.T) {
@@ -39,21 +94,108 @@ func TestHandlerSuite(t *testing.T) {
 func (h *HandlerSuite) SetupTest() {
 	h.rec = httptest.NewRecorder()
 	h.req = httptest.NewRequest(http.MethodGet, "/", nil)
+	h.accessListsMock = db.NewAccessListsStoreMock()
+	h.accessListsMock.On("Allowed", mock.Anything, mock.Anything).Return(true, nil).Maybe()
+	h.apiKeysMock = db.NewAPIKeysStoreMock()
+	h.approvalsMock = db.NewApprovalsStoreMock()
+	h.archivesMock = db.NewArchivesStoreMock()
+	h.auditMock = db.NewAuditStoreMock()
 	h.betsMock = db.NewBetsStoreMock()
+	h.betArchivesMock = db.NewBetArchivesStoreMock()
+	h.betSwapsMock = db.NewBetSwapsStoreMock()
+	h.invoicesMock = db.NewInvoicesStoreMock()
+	h.invoicesMock.On("Add", mock.Anything).Return(nil).Maybe()
+	h.claimCodesMock = db.NewClaimCodesStoreMock()
+	h.claimTokensMock = db.NewClaimTokensStoreMock()
+	h.drawTimingsMock = db.NewDrawTimingsStoreMock()
+	h.fairnessMock = db.NewFairnessStoreMock()
+	h.feesMock = db.NewFeeDistributionsStoreMock()
+	h.growthMock = db.NewGrowthStoreMock()
 	h.lightningMock = db.NewLightningStoreMock()
+	h.limitsMock = db.NewLimitsStoreMock()
+	h.migrationsMock = db.NewRoundMigrationsStoreMock()
+	h.notificationsMock = db.NewNotificationsStoreMock()
 	h.lotteriesMock = db.NewLotteriesStoreMock()
+	h.operatorsMock = db.NewOperatorsStoreMock()
+	h.payoutsMock = db.NewPayoutsStoreMock()
+	h.postponementsMock = db.NewPostponementsStoreMock()
 	h.prizesMock = db.NewPrizesStoreMock()
+	h.privacyMock = db.NewPrivacyStoreMock()
+	h.receiptsMock = db.NewReceiptsStoreMock()
+	h.revealsMock = db.NewRevealsStoreMock()
+	h.scheduledMock = db.NewScheduledPayoutsStoreMock()
+	h.searchMock = db.NewSearchStoreMock()
+	h.sessionsMock = db.NewSessionsStoreMock()
+	h.statsMock = db.NewStatsStoreMock()
+	h.swapClaimsMock = db.NewSwapClaimsStoreMock()
+	h.webhooksMock = db.NewWebhooksStoreMock()
 	h.winnersMock = db.NewWinnersStoreMock()
 	h.lndMock = lightning.NewClientMock()
 	h.eventStreamerMock = sse.NewStreamerMock()
+	h.auditorMock = audit.NewAuditorMock()
+	h.publisherMock = webhooks.NewPublisherMock()
+	h.reloaderMock = reload.NewReloaderMock()
+	h.queueMock = jobs.NewQueueMock()
+	h.queueMock.On("Register", mock.Anything, mock.Anything)
+	h.queueMock.On("Schedule", "expire_invoice", mock.Anything, mock.Anything).Return(nil).Maybe()
+	h.ratesMock = rates.NewRatesMock()
+	h.reservesMock = reserves.NewProverMock()
+	h.swapsMock = swaps.NewProviderMock()
 	db := &db.DB{
-		Bets:      h.betsMock,
-		Lightning: h.lightningMock,
-		Lotteries: h.lotteriesMock,
-		Prizes:    h.prizesMock,
-		Winners:   h.winnersMock,
+		AccessLists:   h.accessListsMock,
+		APIKeys:       h.apiKeysMock,
+		Approvals:     h.approvalsMock,
+		Archives:      h.archivesMock,
+		Audit:         h.auditMock,
+		Bets:          h.betsMock,
+		BetArchives:   h.betArchivesMock,
+		BetSwaps:      h.betSwapsMock,
+		ClaimCodes:    h.claimCodesMock,
+		ClaimTokens:   h.claimTokensMock,
+		DrawTimings:   h.drawTimingsMock,
+		Fairness:      h.fairnessMock,
+		Fees:          h.feesMock,
+		Growth:        h.growthMock,
+		Invoices:      h.invoicesMock,
+		Lightning:     h.lightningMock,
+		Limits:        h.limitsMock,
+		Lotteries:     h.lotteriesMock,
+		Migrations:    h.migrationsMock,
+		Notifications: h.notificationsMock,
+		Operators:     h.operatorsMock,
+		Payouts:       h.payoutsMock,
+		Postponements: h.postponementsMock,
+		Prizes:        h.prizesMock,
+		Privacy:       h.privacyMock,
+		Receipts:      h.receiptsMock,
+		Reveals:       h.revealsMock,
+		Scheduled:     h.scheduledMock,
+		Search:        h.searchMock,
+		Sessions:      h.sessionsMock,
+		Stats:         h.statsMock,
+		SwapClaims:    h.swapClaimsMock,
+		Webhooks:      h.webhooksMock,
+		Winners:       h.winnersMock,
 	}
-	h.handler = handler.New(h.lndMock, db, h.eventStreamerMock)
+	peerCap := policy.NewPeerCap(config.PeerCap{}, db, h.lndMock)
+	limits := policy.NewLimits(config.Limits{}, db)
+	cancellation := policy.NewCancellation(config.Cancellation{Window: 10 * time.Minute, Fee: 1}, db, h.auditorMock)
+	claimCodes := policy.NewClaimCodes(config.ClaimCodes{Expiry: time.Hour}, db)
+	claimTokens, _ := tokens.New(config.ClaimWidget{Secret: claimTokenSecret})
+	h.maintenance = policy.NewMaintenance(config.Maintenance{})
+	h.jurisdiction, _ = policy.NewJurisdiction(config.Jurisdiction{Mode: "block", CIDRs: []string{"203.0.113.0/24"}})
+	approvals := policy.NewApprovals(config.Approvals{Threshold: approvalThreshold}, db, h.queueMock)
+	housePlay := policy.NewHousePlay(config.HousePlay{PublicKeys: []string{housePublicKey}})
+	h.identity, _ = crypto.NewIdentity(config.Identity{PrivateKey: identityPrivateKey})
+	h.handler = handler.New(h.lndMock, db, h.eventStreamerMock, h.auditorMock, h.publisherMock, h.identity, peerCap, limits,
+		housePlay, cancellation, claimCodes, claimTokens, h.jurisdiction, h.maintenance, approvals, h.invoices(db, peerCap),
+		h.ratesMock, h.reservesMock, h.swapsMock, lottery.NewPools(nil), lottery.RemoteBalanceCapacity{}, lottery.StatsPrivacy{}, engine.RoundingNearest, config.LastTicket{}, lnurlPayConfig, config.DrawSLO{}, h.reloaderMock, adminConfig)
+}
+
+// invoices returns an invoices policy tracking the invoices in the suite's mock.
+func (h *HandlerSuite) invoices(database *db.DB, peerCap *policy.PeerCap) *policy.Invoices {
+	database.Invoices = h.invoicesMock
+	return policy.NewInvoices(database, h.lndMock, h.queueMock, peerCap)
 }
 
 func (h *HandlerSuite) SetAuthorizationKey(publicKey string) {
@@ -78,7 +220,9 @@ func (h *HandlerSuite) TestGetBets() {
 			Tickets:   8,
 		},
 	}
-	h.betsMock.On("List", height, uint64(0), uint64(0), false).Return(bets, nil)
+	h.betsMock.On("List", height, "", uint64(0), uint64(0), false).Return(bets, nil)
+	h.ratesMock.On("Convert", uint64(17)).Return(rates.Values{"USD": 0.01})
+	h.ratesMock.On("Convert", uint64(8)).Return(nil)
 
 	url := url.Values{}
 	url.Add("height", strconv.FormatUint(uint64(height), 10))
@@ -90,7 +234,50 @@ func (h *HandlerSuite) TestGetBets() {
 	h.NoError(err)
 
 	h.Equal(http.StatusOK, h.rec.Code)
-	h.Equal(bets, response.Bets)
+	expected := []handler.BetResponse{
+		{Bet: bets[0], Fiat: rates.Values{"USD": 0.01}},
+		{Bet: bets[1]},
+	}
+	h.Equal(expected, response.Bets)
+}
+
+func (h *HandlerSuite) TestGetTicket() {
+	height := uint32(256)
+	h.betsMock.On("GetPublicKey", height, "whale", uint64(1500)).Return("pubkey", nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/tickets?height=256&ticket=1500&pool=whale", nil)
+	h.handler.GetTicket(h.rec, h.req)
+
+	var response handler.TicketResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal("pubkey", response.PublicKey)
+}
+
+func (h *HandlerSuite) TestGetTicketErrors() {
+	h.betsMock.On("GetPublicKey", uint32(256), "", uint64(5000)).Return("", db.ErrTicketNotFound)
+
+	cases := []struct {
+		desc       string
+		url        string
+		statusCode int
+	}{
+		{desc: "Missing height", url: "/tickets?ticket=1", statusCode: http.StatusBadRequest},
+		{desc: "Invalid ticket", url: "/tickets?height=256&ticket=a", statusCode: http.StatusBadRequest},
+		{desc: "Not found", url: "/tickets?height=256&ticket=5000", statusCode: http.StatusNotFound},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			h.handler.GetTicket(rec, req)
+
+			h.Equal(tc.statusCode, rec.Code)
+		})
+	}
 }
 
 func (h *HandlerSuite) TestGetBetsParameters() {
@@ -106,7 +293,7 @@ func (h *HandlerSuite) TestGetBetsParameters() {
 	url.Add("reverse", strconv.FormatBool(reverse))
 	h.req = httptest.NewRequest(http.MethodPost, "/bets?"+url.Encode(), nil)
 
-	h.betsMock.On("List", height, offset, limit, reverse).Return([]db.Bet{}, nil)
+	h.betsMock.On("List", height, "", offset, limit, reverse).Return([]db.Bet{}, nil)
 
 	h.handler.GetBets(h.rec, h.req)
 
@@ -171,17 +358,110 @@ func (h *HandlerSuite) TestGetBetsInvalidHeightError() {
 func (h *HandlerSuite) TestGetBetsInternalError() {
 	height := uint32(1)
 	expectedErr := errors.New("test error")
-	h.betsMock.On("List", height, uint64(0), uint64(0), false).Return([]db.Bet{}, expectedErr)
+	h.betsMock.On("List", height, "", uint64(0), uint64(0), false).Return([]db.Bet{}, expectedErr)
 
 	url := url.Values{}
 	url.Add("height", strconv.FormatUint(uint64(height), 10))
 	h.req = httptest.NewRequest(http.MethodPost, "/bets?"+url.Encode(), nil)
 	h.handler.GetBets(h.rec, h.req)
 
-	var response handler.ErrorResponse
+	var response apierrors.Response
 	err := json.NewDecoder(h.rec.Body).Decode(&response)
 	h.NoError(err)
 
 	h.Equal(http.StatusInternalServerError, h.rec.Code)
-	h.Equal(expectedErr.Error(), response.Error)
+	h.Equal(expectedErr.Error(), response.Error.Message)
+}
+
+func (h *HandlerSuite) TestCancelBet() {
+	paymentHash := "d2c4d1b5c4e1f8ae2b1b0c8b5a4e7f1c0b3a8d9e6f5c4b3a2918d7e6f5a4b3c2"
+	bet := db.Bet{
+		PublicKey:     validPublicKey,
+		FirstTicket:   51,
+		Index:         150,
+		Tickets:       100,
+		LotteryHeight: 144,
+		PaymentHash:   paymentHash,
+	}
+	h.betsMock.On("Cancel", validPublicKey, paymentHash, mock.Anything, 1.0).
+		Return(bet, uint64(99), nil)
+	h.auditorMock.On("Record", audit.BetCancelled, map[string]any{
+		"public_key":     validPublicKey,
+		"payment_hash":   paymentHash,
+		"lottery_height": bet.LotteryHeight,
+		"pool":           "",
+		"first_ticket":   uint64(51),
+		"last_ticket":    uint64(150),
+		"refund":         uint64(99),
+	})
+
+	url := url.Values{}
+	url.Add("payment_hash", paymentHash)
+	url.Add("signature", validSignature)
+	h.req = httptest.NewRequest(http.MethodDelete, "/bets?"+url.Encode(), nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.handler.CancelBet(h.rec, h.req)
+
+	var response handler.CancelBetResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(uint64(99), response.Refund)
+	h.auditorMock.AssertExpectations(h.T())
+}
+
+func (h *HandlerSuite) TestCancelBetErrors() {
+	paymentHash := "d2c4d1b5c4e1f8ae2b1b0c8b5a4e7f1c0b3a8d9e6f5c4b3a2918d7e6f5a4b3c2"
+
+	cases := []struct {
+		err          error
+		desc         string
+		paymentHash  string
+		expectedCode int
+	}{
+		{
+			desc:         "Invalid payment hash",
+			paymentHash:  "hash",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			desc:         "Not found",
+			paymentHash:  paymentHash,
+			err:          db.ErrBetNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			desc:         "Window expired",
+			paymentHash:  paymentHash,
+			err:          db.ErrCancellationExpired,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			desc:         "Internal error",
+			paymentHash:  paymentHash,
+			err:          errors.New("test"),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.betsMock.ExpectedCalls = nil
+			h.betsMock.On("Cancel", validPublicKey, tc.paymentHash, mock.Anything, 1.0).
+				Return(db.Bet{}, uint64(0), tc.err)
+
+			url := url.Values{}
+			url.Add("payment_hash", tc.paymentHash)
+			url.Add("signature", validSignature)
+			h.rec = httptest.NewRecorder()
+			h.req = httptest.NewRequest(http.MethodDelete, "/bets?"+url.Encode(), nil)
+			h.SetAuthorizationKey(validPublicKey)
+
+			h.handler.CancelBet(h.rec, h.req)
+
+			h.Equal(tc.expectedCode, h.rec.Code)
+		})
+	}
 }
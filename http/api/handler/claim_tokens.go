@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+	"github.com/aftermath2/BTRY/http/api/tokens"
+
+	"github.com/pkg/errors"
+)
+
+// IssueClaimToken responds with a short-lived token the winner hands to a third-party site to
+// embed the claim flow. The token is bound to the origin parameter, which is required.
+func (h *Handler) IssueClaimToken(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := getSignedPublicKey(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	token, err := h.claimTokens.Issue(publicKey, r.URL.Query().Get("origin"))
+	if err != nil {
+		if errors.Is(err, tokens.ErrDisabled) {
+			sendError(w, http.StatusNotFound, err)
+			return
+		}
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, token)
+}
+
+// GetWidgetClaim responds with the prizes the claim token gives access to.
+func (h *Handler) GetWidgetClaim(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		sendError(w, http.StatusBadRequest, errors.New("token parameter missing"))
+		return
+	}
+
+	claims, err := h.claimTokens.Validate(token, r.Header.Get("Origin"))
+	if err != nil {
+		sendError(w, claimTokenStatus(err), err)
+		return
+	}
+
+	h.sendPrizes(w, claims.Subject)
+}
+
+// WidgetClaim withdraws the prizes the claim token gives access to. It accepts the same invoice
+// parameters as the /withdraw endpoint.
+//
+// The winner confirms the withdrawal signing the token ID and the invoices, so the site holding the
+// token can't send the prizes elsewhere, and each token withdraws once. The token is only used up
+// when the withdrawal succeeds, a failed one can be attempted again.
+func (h *Handler) WidgetClaim(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	token := query.Get("token")
+	if token == "" {
+		sendLNURLError(w, http.StatusBadRequest, errors.New("token parameter missing"))
+		return
+	}
+
+	confirmation := query.Get("confirmation")
+	if confirmation == "" {
+		sendLNURLError(w, http.StatusBadRequest, errors.New("confirmation parameter missing"))
+		return
+	}
+
+	claims, err := h.claimTokens.Validate(token, r.Header.Get("Origin"))
+	if err != nil {
+		sendLNURLError(w, claimTokenStatus(err), err)
+		return
+	}
+
+	if err := tokens.VerifyConfirmation(claims, query["pr"], confirmation); err != nil {
+		sendLNURLError(w, claimTokenStatus(err), err)
+		return
+	}
+
+	used, err := h.db.ClaimTokens.IsUsed(claims.ID)
+	if err != nil {
+		sendLNURLError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if used {
+		sendLNURLError(w, http.StatusConflict, db.ErrClaimTokenUsed)
+		return
+	}
+
+	resp, status, err := h.withdrawPrizes(r, claims.Subject)
+	if err != nil {
+		sendLNURLError(w, status, err)
+		return
+	}
+
+	if err := h.db.ClaimTokens.Use(claims.ID, claims.Subject, claims.ExpiresAt); err != nil {
+		// The prizes were withdrawn already, the client is told how to follow the withdrawal
+		err := apierrors.New(apierrors.CodeInternal, err.Error()).WithDetail("withdrawal", resp)
+		sendLNURLError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, resp)
+}
+
+func claimTokenStatus(err error) int {
+	switch {
+	case errors.Is(err, tokens.ErrDisabled):
+		return http.StatusNotFound
+	case errors.Is(err, tokens.ErrInvalidToken), errors.Is(err, tokens.ErrInvalidConfirmation):
+		return http.StatusForbidden
+	case errors.Is(err, db.ErrClaimTokenUsed):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
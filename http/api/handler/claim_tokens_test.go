@@ -0,0 +1,287 @@
+package handler_test
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/http/api/tokens"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	claimTokenSecret = "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb"
+	widgetOrigin     = "https://dashboard.example"
+)
+
+func (h *HandlerSuite) issueClaimToken(origin string) string {
+	return h.issueWinnerClaimToken(validPublicKey, origin).Token
+}
+
+func (h *HandlerSuite) issueWinnerClaimToken(publicKey, origin string) tokens.Token {
+	service, err := tokens.New(config.ClaimWidget{Secret: claimTokenSecret})
+	h.NoError(err)
+
+	token, err := service.Issue(publicKey, origin)
+	h.NoError(err)
+
+	return token
+}
+
+// confirmWidgetClaim returns the winner signature confirming the withdrawal of the token prizes to
+// the invoices.
+func confirmWidgetClaim(privateKey ed25519.PrivateKey, id string, paymentRequests ...string) string {
+	message := tokens.ConfirmationMessage(id, paymentRequests)
+	hash := sha256.Sum256(append([]byte(tokens.ConfirmationDomain+"\x00"), message...))
+	return hex.EncodeToString(ed25519.Sign(privateKey, hash[:]))
+}
+
+func (h *HandlerSuite) TestIssueClaimToken() {
+	query := url.Values{}
+	query.Add("signature", validSignature)
+	query.Add("origin", widgetOrigin)
+	h.req = httptest.NewRequest(http.MethodPost, "/claims/token?"+query.Encode(), nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.handler.IssueClaimToken(h.rec, h.req)
+
+	var response tokens.Token
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Greater(response.ExpiresAt, time.Now().Unix())
+
+	service, err := tokens.New(config.ClaimWidget{Secret: claimTokenSecret})
+	h.NoError(err)
+	claims, err := service.Validate(response.Token, widgetOrigin)
+	h.NoError(err)
+	h.Equal(validPublicKey, claims.Subject)
+}
+
+func (h *HandlerSuite) TestIssueClaimTokenErrors() {
+	cases := []struct {
+		desc       string
+		target     string
+		statusCode int
+	}{
+		{desc: "Missing signature", target: "/claims/token", statusCode: http.StatusBadRequest},
+		{
+			desc:       "Missing origin",
+			target:     "/claims/token?signature=" + validSignature,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			desc:       "Invalid origin",
+			target:     "/claims/token?origin=dashboard&signature=" + validSignature,
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, tc.target, nil)
+			req.Header.Set("Authorization", "Bearer "+validPublicKey)
+
+			h.handler.IssueClaimToken(rec, req)
+
+			h.Equal(tc.statusCode, rec.Code)
+		})
+	}
+}
+
+func (h *HandlerSuite) TestGetWidgetClaim() {
+	h.req = httptest.NewRequest(http.MethodGet, "/claims/widget?token="+h.issueClaimToken(widgetOrigin), nil)
+	h.req.Header.Set("Origin", widgetOrigin)
+
+	claimable := []db.Prize{{LotteryHeight: 144, Amount: 500}}
+	h.prizesMock.On("Get", validPublicKey).Return(uint64(500), nil)
+	h.prizesMock.On("List", validPublicKey).Return(claimable, nil)
+	h.ratesMock.On("Convert", uint64(500)).Return(nil)
+
+	h.handler.GetWidgetClaim(h.rec, h.req)
+
+	var response handler.GetPrizesResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(uint64(500), response.Prizes)
+	h.Equal(claimable, response.Claimable)
+}
+
+func (h *HandlerSuite) TestGetWidgetClaimErrors() {
+	cases := []struct {
+		desc       string
+		token      string
+		origin     string
+		statusCode int
+	}{
+		{desc: "Missing token", statusCode: http.StatusBadRequest},
+		{desc: "Invalid token", token: "token", statusCode: http.StatusForbidden},
+		{
+			desc:       "Other origin",
+			token:      h.issueClaimToken(widgetOrigin),
+			origin:     "https://evil.example",
+			statusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/claims/widget?token="+tc.token, nil)
+			req.Header.Set("Origin", tc.origin)
+
+			h.handler.GetWidgetClaim(rec, req)
+
+			h.Equal(tc.statusCode, rec.Code)
+		})
+	}
+	h.prizesMock.AssertNotCalled(h.T(), "Get", mock.Anything)
+}
+
+func (h *HandlerSuite) TestWidgetClaim() {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	h.NoError(err)
+	winner := hex.EncodeToString(publicKey)
+	token := h.issueWinnerClaimToken(winner, widgetOrigin)
+
+	paymentRequest := "lnbcrt"
+	query := url.Values{}
+	query.Add("token", token.Token)
+	query.Add("pr", paymentRequest)
+	query.Add("fee", "10")
+	query.Add("confirmation", confirmWidgetClaim(privateKey, token.ID, paymentRequest))
+
+	h.req = httptest.NewRequest(http.MethodPost, "/claims/widget?"+query.Encode(), nil)
+	h.req.Header.Set("Origin", widgetOrigin)
+	ctx := h.req.Context()
+
+	invoice := &lnrpc.PayReq{
+		PaymentHash: "hash",
+		NumSatoshis: 1000,
+		Timestamp:   time.Now().Unix(),
+		Expiry:      3600,
+	}
+	h.claimTokensMock.On("IsUsed", token.ID).Return(false, nil)
+	h.claimTokensMock.On("Use", token.ID, winner, token.ExpiresAt).Return(nil)
+	h.lndMock.On("DecodeInvoice", ctx, paymentRequest).Return(invoice, nil)
+	claims := []db.PrizesRow{{RowID: 1, Amount: 1010}}
+	h.prizesMock.On("Get", winner).Return(uint64(1010), nil)
+	h.prizesMock.On("Claim", winner, "hash", uint64(1010)).Return(claims, nil)
+	h.eventStreamerMock.On("TrackWithdrawal", invoice.PaymentHash, winner, claims).
+		Return(uint64(7))
+	h.lndMock.On("PayInvoice", mock.Anything, invoice, int64(10), true).Return(nil, nil)
+	h.eventStreamerMock.On("WatchPayment", invoice.PaymentHash, nil)
+
+	h.handler.WidgetClaim(h.rec, h.req)
+
+	var response handler.WithdrawResponse
+	err = json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(uint64(7), response.PaymentID)
+	h.claimTokensMock.AssertExpectations(h.T())
+}
+
+func (h *HandlerSuite) TestWidgetClaimWithdrawalFailed() {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	h.NoError(err)
+	winner := hex.EncodeToString(publicKey)
+	token := h.issueWinnerClaimToken(winner, widgetOrigin)
+
+	paymentRequest := "lnbcrt"
+	query := url.Values{}
+	query.Add("token", token.Token)
+	query.Add("pr", paymentRequest)
+	query.Add("confirmation", confirmWidgetClaim(privateKey, token.ID, paymentRequest))
+
+	h.req = httptest.NewRequest(http.MethodPost, "/claims/widget?"+query.Encode(), nil)
+	h.req.Header.Set("Origin", widgetOrigin)
+
+	h.claimTokensMock.On("IsUsed", token.ID).Return(false, nil)
+	h.lndMock.On("DecodeInvoice", h.req.Context(), paymentRequest).
+		Return(nil, errors.New("invalid payment request"))
+
+	h.handler.WidgetClaim(h.rec, h.req)
+
+	// The token can be used to withdraw again
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+	h.claimTokensMock.AssertNotCalled(h.T(), "Use", token.ID, mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestWidgetClaimErrors() {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	h.NoError(err)
+	winner := hex.EncodeToString(publicKey)
+	token := h.issueWinnerClaimToken(winner, widgetOrigin)
+	used := h.issueWinnerClaimToken(winner, widgetOrigin)
+	h.claimTokensMock.On("IsUsed", used.ID).Return(true, nil)
+
+	cases := []struct {
+		desc         string
+		token        string
+		confirmation string
+		statusCode   int
+	}{
+		{
+			desc:       "Missing confirmation",
+			token:      token.Token,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			// The site replaced the invoice the winner signed
+			desc:         "Other invoice",
+			token:        token.Token,
+			confirmation: confirmWidgetClaim(privateKey, token.ID, "lnbcrt_site"),
+			statusCode:   http.StatusForbidden,
+		},
+		{
+			desc:         "Other token",
+			token:        token.Token,
+			confirmation: confirmWidgetClaim(privateKey, used.ID, "lnbcrt"),
+			statusCode:   http.StatusForbidden,
+		},
+		{
+			desc:         "Token used",
+			token:        used.Token,
+			confirmation: confirmWidgetClaim(privateKey, used.ID, "lnbcrt"),
+			statusCode:   http.StatusConflict,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			query := url.Values{}
+			query.Add("token", tc.token)
+			query.Add("pr", "lnbcrt")
+			if tc.confirmation != "" {
+				query.Add("confirmation", tc.confirmation)
+			}
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/claims/widget?"+query.Encode(), nil)
+			req.Header.Set("Origin", widgetOrigin)
+
+			h.handler.WidgetClaim(rec, req)
+
+			h.Equal(tc.statusCode, rec.Code)
+		})
+	}
+	h.claimTokensMock.AssertNotCalled(h.T(), "Use", token.ID, mock.Anything, mock.Anything)
+	h.prizesMock.AssertNotCalled(h.T(), "Claim", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/pkg/errors"
+)
+
+// GetClaim responds with the prizes the claim code of an anonymous bet gives access to.
+func (h *Handler) GetClaim(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		sendError(w, http.StatusBadRequest, errors.New("code parameter missing"))
+		return
+	}
+
+	publicKey, err := h.claimCodes.Redeem(code)
+	if err != nil {
+		sendError(w, claimCodeStatus(err), err)
+		return
+	}
+
+	h.sendPrizes(w, publicKey)
+}
+
+// Claim withdraws the prizes won by an anonymous bet, the claim code is presented instead of a
+// signature. It accepts the same invoice parameters as the /withdraw endpoint.
+func (h *Handler) Claim(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		sendLNURLError(w, http.StatusBadRequest, errors.New("code parameter missing"))
+		return
+	}
+
+	publicKey, err := h.claimCodes.Redeem(code)
+	if err != nil {
+		sendLNURLError(w, claimCodeStatus(err), err)
+		return
+	}
+
+	h.withdraw(w, r, publicKey)
+}
+
+func claimCodeStatus(err error) int {
+	if errors.Is(err, policy.ErrAnonymousBetsDisabled) || errors.Is(err, policy.ErrInvalidClaimCode) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,121 @@
+package handler_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/stretchr/testify/mock"
+)
+
+const claimCode = "5d41402abc4b2a76b9719d911017c5925d41402abc4b2a76b9719d911017c592"
+
+func claimCodeHash() string {
+	hash := sha256.Sum256([]byte(claimCode))
+	return hex.EncodeToString(hash[:])
+}
+
+func (h *HandlerSuite) TestGetClaim() {
+	h.req = httptest.NewRequest(http.MethodGet, "/claim?code="+claimCode, nil)
+
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	h.claimCodesMock.On("Get", claimCodeHash()).
+		Return(db.ClaimCode{PublicKey: validPublicKey, ExpiresAt: expiresAt}, nil)
+	claimable := []db.Prize{{LotteryHeight: 144, Amount: 500}}
+	h.prizesMock.On("Get", validPublicKey).Return(uint64(500), nil)
+	h.prizesMock.On("List", validPublicKey).Return(claimable, nil)
+	h.ratesMock.On("Convert", uint64(500)).Return(nil)
+
+	h.handler.GetClaim(h.rec, h.req)
+
+	var response handler.GetPrizesResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(uint64(500), response.Prizes)
+	h.Equal(claimable, response.Claimable)
+}
+
+func (h *HandlerSuite) TestClaim() {
+	paymentRequest := "lnbcrt"
+	url := url.Values{}
+	url.Add("code", claimCode)
+	url.Add("pr", paymentRequest)
+	url.Add("fee", "10")
+
+	h.req = httptest.NewRequest(http.MethodPost, "/claim?"+url.Encode(), nil)
+	ctx := h.req.Context()
+
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	h.claimCodesMock.On("Get", claimCodeHash()).
+		Return(db.ClaimCode{PublicKey: validPublicKey, ExpiresAt: expiresAt}, nil)
+
+	invoice := &lnrpc.PayReq{
+		PaymentHash: "hash",
+		NumSatoshis: 1000,
+		Timestamp:   time.Now().Unix(),
+		Expiry:      3600,
+	}
+	h.lndMock.On("DecodeInvoice", ctx, paymentRequest).Return(invoice, nil)
+	claims := []db.PrizesRow{{RowID: 1, Amount: 1010}}
+	h.prizesMock.On("Get", validPublicKey).Return(uint64(1010), nil)
+	h.prizesMock.On("Claim", validPublicKey, "hash", uint64(1010)).Return(claims, nil)
+	h.eventStreamerMock.On("TrackWithdrawal", invoice.PaymentHash, validPublicKey, claims).
+		Return(uint64(7))
+	h.lndMock.On("PayInvoice", mock.Anything, invoice, int64(10), true).Return(nil, nil)
+	h.eventStreamerMock.On("WatchPayment", invoice.PaymentHash, nil)
+
+	h.handler.Claim(h.rec, h.req)
+
+	var response handler.WithdrawResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(uint64(7), response.PaymentID)
+}
+
+func (h *HandlerSuite) TestClaimErrors() {
+	h.claimCodesMock.On("Get", claimCodeHash()).
+		Return(db.ClaimCode{PublicKey: validPublicKey, ExpiresAt: time.Now().Add(-time.Hour).Unix()}, nil)
+
+	cases := []struct {
+		desc       string
+		code       string
+		statusCode int
+	}{
+		{desc: "Missing code", statusCode: http.StatusBadRequest},
+		{desc: "Expired code", code: claimCode, statusCode: http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.rec = httptest.NewRecorder()
+			h.req = httptest.NewRequest(http.MethodPost, "/claim?pr=lnbc&fee=0&code="+tc.code, nil)
+
+			h.handler.Claim(h.rec, h.req)
+
+			h.Equal(tc.statusCode, h.rec.Code)
+			h.prizesMock.AssertNotCalled(h.T(), "Claim", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func (h *HandlerSuite) TestGetClaimUnknownCode() {
+	h.req = httptest.NewRequest(http.MethodGet, "/claim?code=unknown", nil)
+	hash := sha256.Sum256([]byte("unknown"))
+	h.claimCodesMock.On("Get", hex.EncodeToString(hash[:])).Return(db.ClaimCode{}, db.ErrClaimCodeNotFound)
+
+	h.handler.GetClaim(h.rec, h.req)
+
+	h.Equal(http.StatusForbidden, h.rec.Code)
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/aftermath2/BTRY/lottery"
+)
+
+// GetDrawTimings responds with how long each stage of the draws took, the newest first.
+func (h *Handler) GetDrawTimings(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	offset, err := parseIntParam(query, "offset", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, err := parseIntParam(query, "limit", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	timings, err := h.db.DrawTimings.List(offset, limit)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, timings)
+}
+
+// GetDrawSLO responds with the latency report of the last draws against the service level
+// objective.
+func (h *Handler) GetDrawSLO(w http.ResponseWriter, r *http.Request) {
+	slo := lottery.DrawSLO(h.drawSLO)
+
+	timings, err := h.db.DrawTimings.List(0, slo.Window)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, lottery.NewSLOReport(slo, timings))
+}
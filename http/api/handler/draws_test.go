@@ -0,0 +1,58 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lottery"
+
+	"github.com/pkg/errors"
+)
+
+func (h *HandlerSuite) TestGetDrawTimings() {
+	timings := []db.DrawTiming{
+		{LotteryHeight: 288, Bets: 10, Total: 2_000, DBWrites: 1_500},
+		{LotteryHeight: 144, Bets: 5, Total: 1_000, DBWrites: 800},
+	}
+	h.drawTimingsMock.On("List", uint64(0), uint64(2)).Return(timings, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/draws/timings?limit=2", nil)
+	h.handler.GetDrawTimings(h.rec, h.req)
+
+	var response []db.DrawTiming
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(timings, response)
+}
+
+func (h *HandlerSuite) TestGetDrawSLO() {
+	timings := []db.DrawTiming{{LotteryHeight: 288, Total: 2_000_000}, {LotteryHeight: 144, Total: 1_000}}
+	// The default window is the last 30 draws
+	h.drawTimingsMock.On("List", uint64(0), uint64(30)).Return(timings, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/draws/slo", nil)
+	h.handler.GetDrawSLO(h.rec, h.req)
+
+	var response lottery.SLOReport
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(2, response.Draws)
+	h.Equal(1, response.Breaches)
+	h.Equal(float64(50), response.Compliance)
+	h.False(response.Met)
+}
+
+func (h *HandlerSuite) TestGetDrawSLOError() {
+	h.drawTimingsMock.On("List", uint64(0), uint64(30)).Return([]db.DrawTiming(nil), errors.New("test"))
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/draws/slo", nil)
+	h.handler.GetDrawSLO(h.rec, h.req)
+
+	h.Equal(http.StatusInternalServerError, h.rec.Code)
+}
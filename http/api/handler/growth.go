@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lottery"
+)
+
+// GrowthResponse is the response schema of the /admin/growth endpoint.
+type GrowthResponse struct {
+	lottery.Growth
+	// Milestones are the prize pool amounts the lottery already crossed
+	Milestones []db.Milestone `json:"milestones"`
+	// Invoices are the bet invoices created since the first block sampled
+	Invoices db.InvoiceStats `json:"invoices"`
+	// Conversion is the ratio of the invoices created that were paid
+	Conversion float64 `json:"conversion"`
+}
+
+// GetGrowth responds with the projection of the prize pool of the lottery at the height in the
+// query, the latest one opened by default, from its growth in the blocks sampled so far.
+func (h *Handler) GetGrowth(w http.ResponseWriter, r *http.Request) {
+	height, err := parseIntParam(r.URL.Query(), "height", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	replica := h.db.ReadReplica()
+	if height == 0 {
+		nextHeight, err := replica.Lotteries.GetNextHeight()
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+		height = uint64(nextHeight)
+	}
+
+	samples, err := replica.Growth.ListSamples(uint32(height))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	milestones, err := replica.Growth.ListMilestones(uint32(height))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := GrowthResponse{
+		Growth:     lottery.ProjectGrowth(uint32(height), samples),
+		Milestones: milestones,
+	}
+	if len(samples) > 0 {
+		resp.Invoices, err = h.invoices.Stats(time.Unix(samples[0].CreatedAt, 0))
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if resp.Invoices.Created > 0 {
+			resp.Conversion = float64(resp.Invoices.Paid) / float64(resp.Invoices.Created)
+		}
+	}
+
+	sendResponse(w, http.StatusOK, resp)
+}
@@ -0,0 +1,73 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+	"github.com/aftermath2/BTRY/http/api/handler"
+)
+
+func (h *HandlerSuite) TestGetGrowth() {
+	height := uint32(144)
+	samples := []db.GrowthSample{
+		{LotteryHeight: height, BlockHeight: 100, PrizePool: 5_000, Bets: 2, CreatedAt: 1_700_000_000},
+		{LotteryHeight: height, BlockHeight: 110, PrizePool: 15_000, Bets: 6, CreatedAt: 1_700_006_000},
+	}
+	milestones := []db.Milestone{{LotteryHeight: height, Amount: 10_000, ReachedAt: 1_700_006_000}}
+	stats := db.InvoiceStats{Created: 8, Paid: 4}
+	h.lotteriesMock.On("GetNextHeight").Return(height, nil)
+	h.growthMock.On("ListSamples", height).Return(samples, nil)
+	h.growthMock.On("ListMilestones", height).Return(milestones, nil)
+	h.invoicesMock.On("Stats", int64(1_700_000_000)).Return(stats, nil)
+
+	h.handler.GetGrowth(h.rec, h.req)
+
+	var response handler.GrowthResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(height, response.Height)
+	h.Equal(uint32(34), response.BlocksLeft)
+	h.Equal(float64(1_000), response.SatsPerBlock)
+	h.Equal(uint64(49_000), response.ProjectedPrizePool)
+	h.Equal(milestones, response.Milestones)
+	h.Equal(stats, response.Invoices)
+	h.Equal(0.5, response.Conversion)
+}
+
+func (h *HandlerSuite) TestGetGrowthNoSamples() {
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/growth?height=288", nil)
+	h.growthMock.On("ListSamples", uint32(288)).Return(nil, nil)
+	h.growthMock.On("ListMilestones", uint32(288)).Return(nil, nil)
+
+	h.handler.GetGrowth(h.rec, h.req)
+
+	var response handler.GrowthResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(uint32(288), response.Height)
+	h.Zero(response.ProjectedPrizePool)
+	h.invoicesMock.AssertNotCalled(h.T(), "Stats")
+}
+
+func (h *HandlerSuite) TestGetGrowthError() {
+	expectedErr := errors.New("test err")
+	h.lotteriesMock.On("GetNextHeight").Return(uint32(144), nil)
+	h.growthMock.On("ListSamples", uint32(144)).Return(nil, expectedErr)
+
+	h.handler.GetGrowth(h.rec, h.req)
+
+	var response apierrors.Response
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusInternalServerError, h.rec.Code)
+	h.Equal(expectedErr.Error(), response.Error.Message)
+}
@@ -6,34 +6,149 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
 	"github.com/aftermath2/BTRY/crypto"
+	"github.com/aftermath2/BTRY/crypto/webauthn"
 	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
 	"github.com/aftermath2/BTRY/http/api/sse"
+	"github.com/aftermath2/BTRY/http/api/tokens"
 	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/lottery/engine"
+	"github.com/aftermath2/BTRY/policy"
+	"github.com/aftermath2/BTRY/rates"
+	"github.com/aftermath2/BTRY/reload"
+	"github.com/aftermath2/BTRY/reserves"
+	"github.com/aftermath2/BTRY/swaps"
+	"github.com/aftermath2/BTRY/webhooks"
 
 	"github.com/fiatjaf/go-lnurl"
+	cmap "github.com/orcaman/concurrent-map/v2"
 	"github.com/pkg/errors"
 )
 
-// ErrorResponse is the object returned when an error is thrown.
-type ErrorResponse struct {
-	Error string `json:"error,omitempty"`
+// requestError is an error caused by the request parameters, responded with a 400 status.
+type requestError struct {
+	error
+}
+
+// Unwrap returns the error caused by the request.
+func (e requestError) Unwrap() error {
+	return e.error
 }
 
 // Handler handles endpoints requests.
 type Handler struct {
-	lnd           lightning.Client
-	db            *db.DB
-	eventStreamer sse.Streamer
+	lnd             lightning.Client
+	db              *db.DB
+	eventStreamer   sse.Streamer
+	auditor         audit.Auditor
+	publisher       webhooks.Publisher
+	identity        *crypto.Identity
+	peerCap         *policy.PeerCap
+	limits          *policy.Limits
+	housePlay       *policy.HousePlay
+	cancellation    *policy.Cancellation
+	claimCodes      *policy.ClaimCodes
+	claimTokens     *tokens.Service
+	jurisdiction    *policy.Jurisdiction
+	maintenance     *policy.Maintenance
+	approvals       *policy.Approvals
+	invoices        *policy.Invoices
+	swapClaims      *policy.SwapClaims
+	rates           rates.Rates
+	reserves        reserves.Prover
+	swaps           swaps.Provider
+	pools           lottery.Pools
+	capacity        lottery.CapacityOracle
+	statsPrivacy    lottery.StatsPrivacy
+	rounding        engine.Rounding
+	lastTicket      config.LastTicket
+	lnurlPay        config.LNURLPay
+	drawSLO         config.DrawSLO
+	reloader        reload.Reloader
+	ceremonies      cmap.ConcurrentMap[string, ceremony]
+	rp              webauthn.RelyingParty
+	setupToken      []byte
+	sessionDuration time.Duration
 }
 
 // New returns the endpoints handler.
-func New(lnd lightning.Client, db *db.DB, eventStreamer sse.Streamer) *Handler {
+func New(
+	lnd lightning.Client,
+	db *db.DB,
+	eventStreamer sse.Streamer,
+	auditor audit.Auditor,
+	publisher webhooks.Publisher,
+	identity *crypto.Identity,
+	peerCap *policy.PeerCap,
+	limits *policy.Limits,
+	housePlay *policy.HousePlay,
+	cancellation *policy.Cancellation,
+	claimCodes *policy.ClaimCodes,
+	claimTokens *tokens.Service,
+	jurisdiction *policy.Jurisdiction,
+	maintenance *policy.Maintenance,
+	approvals *policy.Approvals,
+	invoices *policy.Invoices,
+	rates rates.Rates,
+	reserves reserves.Prover,
+	swaps swaps.Provider,
+	pools lottery.Pools,
+	capacity lottery.CapacityOracle,
+	statsPrivacy lottery.StatsPrivacy,
+	rounding engine.Rounding,
+	lastTicket config.LastTicket,
+	lnurlPay config.LNURLPay,
+	drawSLO config.DrawSLO,
+	reloader reload.Reloader,
+	admin config.Admin,
+) *Handler {
+	sessionDuration := admin.SessionDuration
+	if sessionDuration == 0 {
+		sessionDuration = defaultSessionDuration
+	}
+
 	return &Handler{
 		lnd:           lnd,
 		db:            db,
 		eventStreamer: eventStreamer,
+		auditor:       auditor,
+		publisher:     publisher,
+		identity:      identity,
+		peerCap:       peerCap,
+		limits:        limits,
+		housePlay:     housePlay,
+		cancellation:  cancellation,
+		claimCodes:    claimCodes,
+		claimTokens:   claimTokens,
+		jurisdiction:  jurisdiction,
+		maintenance:   maintenance,
+		approvals:     approvals,
+		invoices:      invoices,
+		swapClaims:    policy.NewSwapClaims(db, auditor),
+		rates:         rates,
+		reserves:      reserves,
+		swaps:         swaps,
+		pools:         pools,
+		capacity:      capacity,
+		statsPrivacy:  statsPrivacy,
+		rounding:      rounding,
+		lastTicket:    lastTicket,
+		lnurlPay:      lnurlPay,
+		drawSLO:       drawSLO,
+		reloader:      reloader,
+		ceremonies:    cmap.New[ceremony](),
+		rp: webauthn.RelyingParty{
+			ID:     admin.RPID,
+			Origin: admin.Origin,
+		},
+		setupToken:      []byte(admin.SetupToken),
+		sessionDuration: sessionDuration,
 	}
 }
 
@@ -52,6 +167,15 @@ func getAuthPublicKey(r *http.Request) (string, error) {
 	return publicKey, nil
 }
 
+// baseURL returns the scheme and host the request was sent to.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
 func parseIntParam(query url.Values, key string, required bool) (uint64, error) {
 	str := query.Get(key)
 	if str == "" {
@@ -77,13 +201,20 @@ func sendResponse(w http.ResponseWriter, statusCode int, v any) {
 	}
 }
 
+// lnurlErrorResponse is the LNURL error response, extended with the structured error so clients
+// other than wallets can branch on its code.
+type lnurlErrorResponse struct {
+	lnurl.LNURLErrorResponse
+	Error *apierrors.Error `json:"error"`
+}
+
 func sendLNURLError(w http.ResponseWriter, statusCode int, err error) {
-	sendResponse(w, statusCode, lnurl.ErrorResponse(err.Error()))
+	sendResponse(w, statusCode, lnurlErrorResponse{
+		LNURLErrorResponse: lnurl.ErrorResponse(err.Error()),
+		Error:              apierrors.From(statusCode, err),
+	})
 }
 
 func sendError(w http.ResponseWriter, statusCode int, err error) {
-	errResponse := ErrorResponse{
-		Error: err.Error(),
-	}
-	sendResponse(w, statusCode, errResponse)
+	apierrors.Write(w, statusCode, err)
 }
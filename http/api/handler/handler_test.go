@@ -7,6 +7,8 @@ import (
 	"net/url"
 	"testing"
 
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+
 	"github.com/fiatjaf/go-lnurl"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -161,10 +163,12 @@ func TestSendError(t *testing.T) {
 	testErr := errors.New("fiat")
 	sendError(rec, code, testErr)
 
-	var response ErrorResponse
+	var response apierrors.Response
 	err := json.NewDecoder(rec.Body).Decode(&response)
 	assert.NoError(t, err)
 
 	assert.Equal(t, code, rec.Code)
-	assert.Equal(t, testErr.Error(), response.Error)
+	assert.Equal(t, testErr.Error(), response.Error.Message)
+	assert.Equal(t, apierrors.CodeInternal, response.Error.Code)
+	assert.True(t, response.Error.Retryable)
 }
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// IdentityResponse is the response schema of the /identity endpoint.
+type IdentityResponse struct {
+	// PublicKey is the server identity key the responses of the critical endpoints are signed with
+	PublicKey string `json:"public_key"`
+}
+
+// GetIdentity responds with the server identity public key. Clients should pin the key published
+// by the operators through another channel rather than trusting the one in this response.
+func (h *Handler) GetIdentity(w http.ResponseWriter, r *http.Request) {
+	if h.identity == nil {
+		sendError(w, http.StatusNotFound, errors.New("server identity not configured"))
+		return
+	}
+
+	sendResponse(w, http.StatusOK, IdentityResponse{PublicKey: h.identity.PublicKey()})
+}
@@ -0,0 +1,39 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/lottery/engine"
+)
+
+const identityPrivateKey = "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"
+
+func (h *HandlerSuite) TestGetIdentity() {
+	h.req = httptest.NewRequest(http.MethodGet, "/identity", nil)
+
+	h.handler.GetIdentity(h.rec, h.req)
+
+	var response handler.IdentityResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal("d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a", response.PublicKey)
+}
+
+func (h *HandlerSuite) TestGetIdentityNotConfigured() {
+	h.req = httptest.NewRequest(http.MethodGet, "/identity", nil)
+
+	handler.New(h.lndMock, &db.DB{}, h.eventStreamerMock, h.auditorMock, h.publisherMock, nil, nil, nil, nil, nil, nil, nil, nil,
+		nil, nil, nil, nil, nil, nil, lottery.NewPools(nil), lottery.RemoteBalanceCapacity{}, lottery.StatsPrivacy{},
+		engine.RoundingNearest, config.LastTicket{}, config.LNURLPay{}, config.DrawSLO{}, h.reloaderMock,
+		adminConfig).GetIdentity(h.rec, h.req)
+
+	h.Equal(http.StatusNotFound, h.rec.Code)
+}
@@ -1,11 +1,20 @@
 package handler
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
 	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/policy"
+	"github.com/aftermath2/BTRY/swaps"
 
 	"github.com/pkg/errors"
 )
@@ -14,17 +23,85 @@ import (
 type InvoiceResponse struct {
 	Invoice   string `json:"invoice,omitempty"`
 	PaymentID uint64 `json:"payment_id,omitempty"`
+	// ClaimCode redeems the prizes won by an anonymous bet, it's only returned once
+	ClaimCode string `json:"claim_code,omitempty"`
+	// Swap is the submarine swap paying the invoice with on-chain funds, if requested
+	Swap *swaps.Swap `json:"swap,omitempty"`
+}
+
+// InvoiceSwapResponse is the response schema of the /invoice/swap endpoint.
+type InvoiceSwapResponse struct {
+	ID          string `json:"id"`
+	PaymentHash string `json:"payment_hash"`
+	Address     string `json:"address"`
+	// Status is the one reported by the swap provider, the bet is registered once the invoice
+	// is paid, on "invoice.paid"
+	Status             string `json:"status"`
+	ExpectedAmount     uint64 `json:"expected_amount"`
+	Amount             uint64 `json:"amount"`
+	TimeoutBlockHeight uint32 `json:"timeout_block_height"`
+	UpdatedAt          int64  `json:"updated_at"`
+}
+
+// InvoiceStatsResponse is the response schema of the /admin/invoices endpoint.
+type InvoiceStatsResponse struct {
+	db.InvoiceStats
+	// Conversion is the ratio of the invoices created that were paid
+	Conversion float64 `json:"conversion"`
 }
 
 // GetInvoice reponds with an invoice and its preimage hash.
+//
+// Anonymous bets don't require an authorization public key, they are registered under a new one
+// and the response includes the claim code that redeems their prizes.
+//
+// Players holding on-chain funds only can request a submarine swap paying the invoice, passing
+// the public key they would refund the funds with if the swap fails.
 func (h *Handler) GetInvoice(w http.ResponseWriter, r *http.Request) {
-	publicKey, err := getAuthPublicKey(r)
-	if err != nil {
-		sendError(w, http.StatusBadRequest, err)
-		return
+	query := r.URL.Query()
+
+	anonymous := false
+	if anonymousStr := query.Get("anonymous"); anonymousStr != "" {
+		v, err := strconv.ParseBool(anonymousStr)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, errors.Wrap(err, "invalid anonymous parameter"))
+			return
+		}
+		anonymous = v
 	}
 
-	query := r.URL.Query()
+	swap := false
+	if swapStr := query.Get("swap"); swapStr != "" {
+		v, err := strconv.ParseBool(swapStr)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, errors.Wrap(err, "invalid swap parameter"))
+			return
+		}
+		swap = v
+	}
+
+	refundPublicKey := query.Get("refund_public_key")
+	if swap {
+		if err := validateRefundPublicKey(refundPublicKey); err != nil {
+			sendError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	var publicKey string
+	if anonymous {
+		if !h.claimCodes.Enabled() {
+			sendError(w, http.StatusForbidden, policy.ErrAnonymousBetsDisabled)
+			return
+		}
+	} else {
+		var err error
+		publicKey, err = getAuthPublicKey(r)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
 
 	amount := query.Get("amount")
 	amountSat, err := strconv.ParseUint(amount, 10, 64)
@@ -33,37 +110,272 @@ func (h *Handler) GetInvoice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
+	// Limits and the house public keys are set on persistent public keys, anonymous bets are not
+	// tied to any
+	if !anonymous {
+		if err := h.limits.Check(publicKey, amountSat); err != nil {
+			if errors.Is(err, policy.ErrSelfExcluded) || errors.Is(err, policy.ErrLimitExceeded) {
+				sendError(w, http.StatusForbidden, err)
+				return
+			}
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
 
-	lotteryInfo, err := lottery.GetInfo(ctx, h.lnd, h.db)
+		if err := h.housePlay.Check(publicKey); err != nil {
+			sendError(w, http.StatusForbidden, err)
+			return
+		}
+	}
+
+	if err := policy.CheckAccess(h.db.AccessLists, h.auditor, publicKey, db.AccessBets); err != nil {
+		sendError(w, accessStatus(err), err)
+		return
+	}
+
+	resp, rHash, err := h.betInvoice(r.Context(), publicKey, anonymous, amountSat, nil)
 	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			sendError(w, http.StatusBadRequest, err)
+			return
+		}
 		sendError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	// An invoice may be requested before the capacity has been fulfilled but pay afterwards,
-	// the user would participate in the lottery but the funds may not be considered in the pool
-	// (assuming the liquidity remains the same and no withdrawal is done in the same day)
-	if amountSat > uint64(lotteryInfo.Capacity) {
-		err := errors.Errorf(
-			"requested amount exceeds current capacity. Amount should be equal or lower than %d",
-			lotteryInfo.Capacity)
+	if swap {
+		s, err := h.swaps.Create(r.Context(), resp.Invoice, refundPublicKey)
+		if err != nil {
+			if errors.Is(err, swaps.ErrDisabled) {
+				sendError(w, http.StatusForbidden, err)
+				return
+			}
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		err = h.db.BetSwaps.Add(db.BetSwap{
+			ID:                 s.ID,
+			PaymentHash:        rHash,
+			PublicKey:          publicKey,
+			Address:            s.Address,
+			Status:             swaps.StatusCreated,
+			ExpectedAmount:     s.ExpectedAmount,
+			Amount:             amountSat,
+			TimeoutBlockHeight: s.TimeoutBlockHeight,
+			CreatedAt:          time.Now().Unix(),
+		})
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+		resp.Swap = &s
+	}
+
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// GetInvoiceSwap responds with the submarine swap paying a bet invoice. Its status is refreshed
+// from the provider until it's final.
+func (h *Handler) GetInvoiceSwap(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		sendError(w, http.StatusBadRequest, errors.New("id is required"))
+		return
+	}
+
+	swap, err := h.db.BetSwaps.Get(id)
+	if err != nil {
+		if errors.Is(err, db.ErrBetSwapNotFound) {
+			sendError(w, http.StatusNotFound, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !swaps.Final(swap.Status) {
+		status, err := h.swaps.Status(r.Context(), swap.ID)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if status != swap.Status {
+			swap.Status = status
+			swap.UpdatedAt = time.Now().Unix()
+			if err := h.db.BetSwaps.SetStatus(swap.ID, swap.Status, swap.UpdatedAt); err != nil {
+				sendError(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+	}
+
+	sendResponse(w, http.StatusOK, InvoiceSwapResponse{
+		ID:                 swap.ID,
+		PaymentHash:        swap.PaymentHash,
+		Address:            swap.Address,
+		Status:             swap.Status,
+		ExpectedAmount:     swap.ExpectedAmount,
+		Amount:             swap.Amount,
+		TimeoutBlockHeight: swap.TimeoutBlockHeight,
+		UpdatedAt:          swap.UpdatedAt,
+	})
+}
+
+// GetInvoiceStats responds with the number of bet invoices created since the timestamp in the
+// query, all of them by default, and how many were paid or abandoned.
+func (h *Handler) GetInvoiceStats(w http.ResponseWriter, r *http.Request) {
+	since, err := parseIntParam(r.URL.Query(), "since", false)
+	if err != nil {
 		sendError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	inv, err := h.lnd.AddInvoice(ctx, amountSat)
+	stats, err := h.invoices.Stats(time.Unix(int64(since), 0))
 	if err != nil {
 		sendError(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	resp := InvoiceStatsResponse{InvoiceStats: stats}
+	if stats.Created > 0 {
+		resp.Conversion = float64(stats.Paid) / float64(stats.Created)
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// betInvoice returns an invoice to place a bet of the amount specified in the lottery in progress
+// and its payment hash. Anonymous bets are registered under a new public key and the response
+// includes its claim code.
+//
+// The invoice commits to the description hash instead of the memo if one is passed.
+func (h *Handler) betInvoice(
+	ctx context.Context,
+	publicKey string,
+	anonymous bool,
+	amountSat uint64,
+	descriptionHash []byte,
+) (InvoiceResponse, string, error) {
+	pool, ok := h.pools.Route(amountSat)
+	if !ok {
+		err := errors.Errorf("there is no lottery pool accepting bets of %d sats", amountSat)
+		return InvoiceResponse{}, "", requestError{err}
+	}
+
+	lotteryInfo, err := lottery.GetInfo(ctx, h.lnd, h.db, h.pools, h.capacity)
+	if err != nil {
+		return InvoiceResponse{}, "", err
+	}
+
+	if postponement := lotteryInfo.Postponement; postponement != nil {
+		message := fmt.Sprintf("the lottery %d draw is postponed, bets are accepted again once it "+
+			"takes place", postponement.LotteryHeight)
+		err := apierrors.New(apierrors.CodeDrawPostponed, message).
+			WithDetail("resume_height", postponement.ResumeHeight)
+		return InvoiceResponse{}, "", requestError{err}
+	}
+
+	poolInfo, ok := lotteryInfo.Pool(pool.Name)
+	if !ok {
+		return InvoiceResponse{}, "", errors.Errorf("pool %q not found", pool.Name)
+	}
+
+	// An invoice may be requested before the capacity has been fulfilled but pay afterwards,
+	// the user would participate in the lottery but the funds may not be considered in the pool
+	// (assuming the liquidity remains the same and no withdrawal is done in the same day)
+	if amountSat > uint64(poolInfo.Capacity) {
+		message := fmt.Sprintf(
+			"requested amount exceeds current capacity. Amount should be equal or lower than %d",
+			poolInfo.Capacity)
+		err := apierrors.New(apierrors.CodeCapacityExceeded, message).
+			WithDetail("capacity", poolInfo.Capacity)
+		return InvoiceResponse{}, "", requestError{err}
+	}
+
+	var claimCode string
+	if anonymous {
+		publicKey, claimCode, err = h.claimCodes.Issue()
+		if err != nil {
+			return InvoiceResponse{}, "", err
+		}
+	}
+
+	memo := lottery.InvoiceMemo(lotteryInfo.NextHeight, amountSat)
+
+	// Hold invoices let the server inspect the channels the payment arrived through before
+	// accepting the bet
+	if h.peerCap.Enabled() {
+		resp, rHash, err := h.addHoldInvoice(ctx, publicKey, amountSat, memo, descriptionHash)
+		if err != nil {
+			return InvoiceResponse{}, "", err
+		}
+		resp.ClaimCode = claimCode
+		return resp, rHash, nil
+	}
+
+	inv, err := h.lnd.AddInvoice(ctx, amountSat, memo, descriptionHash)
+	if err != nil {
+		return InvoiceResponse{}, "", err
+	}
+
 	rHash := hex.EncodeToString(inv.RHash)
+	if err := h.invoices.Track(rHash, publicKey, amountSat); err != nil {
+		return InvoiceResponse{}, "", err
+	}
 	paymentID := h.eventStreamer.TrackPayment(rHash, publicKey, amountSat)
 
 	resp := InvoiceResponse{
 		PaymentID: paymentID,
 		Invoice:   inv.PaymentRequest,
+		ClaimCode: claimCode,
 	}
-	sendResponse(w, http.StatusOK, resp)
+	return resp, rHash, nil
+}
+
+func (h *Handler) addHoldInvoice(
+	ctx context.Context,
+	publicKey string,
+	amountSat uint64,
+	memo string,
+	descriptionHash []byte,
+) (InvoiceResponse, string, error) {
+	preimage := make([]byte, 32)
+	if _, err := rand.Read(preimage); err != nil {
+		return InvoiceResponse{}, "", errors.Wrap(err, "generating preimage")
+	}
+	hash := sha256.Sum256(preimage)
+
+	paymentRequest, err := h.lnd.AddHoldInvoice(ctx, hash[:], amountSat, memo, descriptionHash)
+	if err != nil {
+		return InvoiceResponse{}, "", err
+	}
+
+	// The preimage is stored before watching the invoice so it can be settled after a restart
+	rHash := hex.EncodeToString(hash[:])
+	if err := h.invoices.TrackHold(rHash, publicKey, amountSat, preimage); err != nil {
+		return InvoiceResponse{}, "", err
+	}
+	paymentID := h.eventStreamer.TrackHoldInvoice(rHash, preimage, publicKey, amountSat)
+
+	resp := InvoiceResponse{
+		PaymentID: paymentID,
+		Invoice:   paymentRequest,
+	}
+	return resp, rHash, nil
+}
+
+// validateRefundPublicKey checks that the key is a hex encoded compressed secp256k1 public key.
+func validateRefundPublicKey(publicKey string) error {
+	if publicKey == "" {
+		return errors.New("refund_public_key is required to pay with a swap")
+	}
+
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != 33 || (key[0] != 0x02 && key[0] != 0x03) {
+		return errors.New("invalid refund_public_key, must be a hex encoded compressed public key")
+	}
+
+	return nil
 }
@@ -1,29 +1,46 @@
 package handler_test
 
 import (
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
+	"strings"
 
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
 	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/lottery/engine"
+	"github.com/aftermath2/BTRY/policy"
+	"github.com/aftermath2/BTRY/swaps"
 
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
 )
 
+// housePublicKey is the public key designated as the operators' one in the suite.
+const housePublicKey = "3fd2c1e5f4bd6a4cf3a61bd5e7a5ea8a36e3a1e4a3c92d6f4a2f2e15bd1a8c47"
+
 func (h *HandlerSuite) TestGetInvoice() {
 	amount := uint64(2000)
 	h.req = httptest.NewRequest(http.MethodGet, "/invoice?amount="+strconv.FormatUint(amount, 10), nil)
 	publicKey := "e68b99fc5f60c971926fdc3a3af38ccf67e6f4306ab1c388735533e7c5dcc749"
 	h.SetAuthorizationKey(publicKey)
 
+	h.mockNoLimits()
+
 	ctx := h.req.Context()
 	blockHeight := uint32(1)
 	h.lndMock.On("RemoteBalance", ctx).Return(int64(1_000_000), nil)
 	h.lotteriesMock.On("GetNextHeight").Return(blockHeight, nil)
-	h.betsMock.On("GetPrizePool", blockHeight).Return(uint64(0), nil)
+	h.postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+	h.betsMock.On("GetPrizePool", blockHeight, "").Return(uint64(0), nil)
 
 	addInvoiceResp := &lnrpc.AddInvoiceResponse{
 		RHash:          []byte("rhash"),
@@ -31,7 +48,7 @@ func (h *HandlerSuite) TestGetInvoice() {
 		AddIndex:       0,
 		PaymentAddr:    []byte("addr"),
 	}
-	h.lndMock.On("AddInvoice", ctx, amount).Return(addInvoiceResp, nil)
+	h.lndMock.On("AddInvoice", ctx, amount, "BTRY;round=1;tickets=2000", []byte(nil)).Return(addInvoiceResp, nil)
 
 	paymentID := uint64(123456)
 	h.eventStreamerMock.On("TrackPayment", hex.EncodeToString(addInvoiceResp.RHash), publicKey, amount).Return(paymentID)
@@ -45,6 +62,278 @@ func (h *HandlerSuite) TestGetInvoice() {
 	h.Equal(http.StatusOK, h.rec.Code)
 	h.Equal(paymentID, response.PaymentID)
 	h.Equal(addInvoiceResp.PaymentRequest, response.Invoice)
+
+	// The invoice is tracked until it's paid or expires
+	h.invoicesMock.AssertCalled(h.T(), "Add", mock.MatchedBy(func(invoice db.Invoice) bool {
+		return invoice.PaymentHash == hex.EncodeToString(addInvoiceResp.RHash) &&
+			invoice.PublicKey == publicKey && invoice.Amount == amount
+	}))
+	h.queueMock.AssertCalled(h.T(), "Schedule", "expire_invoice",
+		hex.EncodeToString(addInvoiceResp.RHash), mock.Anything)
+}
+
+func (h *HandlerSuite) TestGetInvoiceHousePlay() {
+	h.req = httptest.NewRequest(http.MethodGet, "/invoice?amount=2000", nil)
+	h.SetAuthorizationKey(housePublicKey)
+	h.mockNoLimits()
+
+	h.handler.GetInvoice(h.rec, h.req)
+
+	var response apierrors.Response
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusForbidden, h.rec.Code)
+	h.Equal(policy.ErrHousePlay.Error(), response.Error.Message)
+	h.lndMock.AssertNotCalled(h.T(), "AddInvoice", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestGetInvoiceWithSwap() {
+	amount := uint64(2000)
+	refundPublicKey := "02" + strings.Repeat("ab", 32)
+	h.req = httptest.NewRequest(http.MethodGet,
+		"/invoice?amount=2000&swap=true&refund_public_key="+refundPublicKey, nil)
+	publicKey := "e68b99fc5f60c971926fdc3a3af38ccf67e6f4306ab1c388735533e7c5dcc749"
+	h.SetAuthorizationKey(publicKey)
+
+	h.mockNoLimits()
+
+	ctx := h.req.Context()
+	blockHeight := uint32(1)
+	h.lndMock.On("RemoteBalance", ctx).Return(int64(1_000_000), nil)
+	h.lotteriesMock.On("GetNextHeight").Return(blockHeight, nil)
+	h.postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+	h.betsMock.On("GetPrizePool", blockHeight, "").Return(uint64(0), nil)
+
+	addInvoiceResp := &lnrpc.AddInvoiceResponse{RHash: []byte("rhash"), PaymentRequest: "pr"}
+	h.lndMock.On("AddInvoice", ctx, amount, "BTRY;round=1;tickets=2000", []byte(nil)).Return(addInvoiceResp, nil)
+	h.eventStreamerMock.On("TrackPayment", hex.EncodeToString(addInvoiceResp.RHash), publicKey, amount).
+		Return(uint64(1))
+
+	swap := swaps.Swap{
+		ID:                 "jkP3hq",
+		Address:            "bc1pswap",
+		BIP21:              "bitcoin:bc1pswap?amount=0.0000242",
+		ExpectedAmount:     2420,
+		TimeoutBlockHeight: 840_288,
+	}
+	h.swapsMock.On("Create", ctx, "pr", refundPublicKey).Return(swap, nil)
+	h.betSwapsMock.On("Add", mock.MatchedBy(func(s db.BetSwap) bool {
+		return s.ID == swap.ID && s.PaymentHash == hex.EncodeToString(addInvoiceResp.RHash) &&
+			s.PublicKey == publicKey && s.Amount == amount && s.Status == swaps.StatusCreated
+	})).Return(nil)
+
+	h.handler.GetInvoice(h.rec, h.req)
+
+	var response handler.InvoiceResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal("pr", response.Invoice)
+	h.Equal(&swap, response.Swap)
+	h.betSwapsMock.AssertExpectations(h.T())
+}
+
+func (h *HandlerSuite) TestGetInvoiceWithSwapInvalidRefundKey() {
+	for _, refundPublicKey := range []string{"", "04" + strings.Repeat("ab", 32), "not hex"} {
+		h.rec = httptest.NewRecorder()
+		h.req = httptest.NewRequest(http.MethodGet,
+			"/invoice?amount=2000&swap=true&refund_public_key="+url.QueryEscape(refundPublicKey), nil)
+		h.SetAuthorizationKey(validPublicKey)
+
+		h.handler.GetInvoice(h.rec, h.req)
+
+		h.Equal(http.StatusBadRequest, h.rec.Code, refundPublicKey)
+	}
+	h.swapsMock.AssertNotCalled(h.T(), "Create", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestGetInvoiceSwap() {
+	swap := db.BetSwap{
+		ID:                 "jkP3hq",
+		PaymentHash:        "hash",
+		PublicKey:          validPublicKey,
+		Address:            "bc1pswap",
+		Status:             swaps.StatusMempool,
+		ExpectedAmount:     2420,
+		Amount:             2000,
+		TimeoutBlockHeight: 840_288,
+	}
+	h.betSwapsMock.On("Get", swap.ID).Return(swap, nil)
+	h.req = httptest.NewRequest(http.MethodGet, "/invoice/swap?id="+swap.ID, nil)
+	h.swapsMock.On("Status", h.req.Context(), swap.ID).Return(swaps.StatusInvoicePaid, nil)
+	h.betSwapsMock.On("SetStatus", swap.ID, swaps.StatusInvoicePaid, mock.Anything).Return(nil)
+
+	h.handler.GetInvoiceSwap(h.rec, h.req)
+
+	var response handler.InvoiceSwapResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(swaps.StatusInvoicePaid, response.Status)
+	h.Equal(swap.ExpectedAmount, response.ExpectedAmount)
+	h.betSwapsMock.AssertExpectations(h.T())
+}
+
+func (h *HandlerSuite) TestGetInvoiceSwapFinal() {
+	swap := db.BetSwap{ID: "jkP3hq", Status: swaps.StatusClaimed}
+	h.betSwapsMock.On("Get", swap.ID).Return(swap, nil)
+	h.req = httptest.NewRequest(http.MethodGet, "/invoice/swap?id="+swap.ID, nil)
+
+	h.handler.GetInvoiceSwap(h.rec, h.req)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	// Final statuses are not refreshed
+	h.swapsMock.AssertNotCalled(h.T(), "Status", mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestGetInvoiceSwapNotFound() {
+	h.betSwapsMock.On("Get", "unknown").Return(db.BetSwap{}, db.ErrBetSwapNotFound)
+	h.req = httptest.NewRequest(http.MethodGet, "/invoice/swap?id=unknown", nil)
+
+	h.handler.GetInvoiceSwap(h.rec, h.req)
+
+	h.Equal(http.StatusNotFound, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestGetInvoiceStats() {
+	stats := db.InvoiceStats{
+		Created:       4,
+		Paid:          3,
+		Expired:       1,
+		CreatedAmount: 10_000,
+		PaidAmount:    7_000,
+		ExpiredAmount: 3_000,
+	}
+	h.invoicesMock.On("Stats", int64(1_700_000_000)).Return(stats, nil)
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/invoices?since=1700000000", nil)
+
+	h.handler.GetInvoiceStats(h.rec, h.req)
+
+	var response handler.InvoiceStatsResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(handler.InvoiceStatsResponse{InvoiceStats: stats, Conversion: 0.75}, response)
+}
+
+func (h *HandlerSuite) TestGetHoldInvoice() {
+	amount := uint64(2000)
+	h.req = httptest.NewRequest(http.MethodGet, "/invoice?amount="+strconv.FormatUint(amount, 10), nil)
+	publicKey := "e68b99fc5f60c971926fdc3a3af38ccf67e6f4306ab1c388735533e7c5dcc749"
+	h.SetAuthorizationKey(publicKey)
+
+	h.mockNoLimits()
+
+	ctx := h.req.Context()
+	blockHeight := uint32(1)
+	h.lndMock.On("RemoteBalance", ctx).Return(int64(1_000_000), nil)
+	h.lotteriesMock.On("GetNextHeight").Return(blockHeight, nil)
+	h.postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+	h.betsMock.On("GetPrizePool", blockHeight, "").Return(uint64(0), nil)
+
+	var hash []byte
+	h.lndMock.On("AddHoldInvoice", ctx, mock.Anything, amount, "BTRY;round=1;tickets=2000", []byte(nil)).
+		Run(func(args mock.Arguments) {
+			hash = args.Get(1).([]byte)
+		}).
+		Return("pr", nil)
+
+	paymentID := uint64(123456)
+	h.eventStreamerMock.On("TrackHoldInvoice", mock.Anything, mock.Anything, publicKey, amount).
+		Return(paymentID)
+
+	database := &db.DB{
+		AccessLists:   h.accessListsMock,
+		Bets:          h.betsMock,
+		Limits:        h.limitsMock,
+		Lotteries:     h.lotteriesMock,
+		Postponements: h.postponementsMock,
+	}
+	peerCap := policy.NewPeerCap(config.PeerCap{Enabled: true, MaxAmount: 1_000_000}, database, h.lndMock)
+	limits := policy.NewLimits(config.Limits{}, database)
+	invoices := h.invoices(database, peerCap)
+	handler.New(h.lndMock, database, h.eventStreamerMock, h.auditorMock, h.publisherMock, nil, peerCap, limits, policy.NewHousePlay(config.HousePlay{}), nil, nil, nil, nil,
+		nil, nil, invoices, nil, nil, nil, lottery.NewPools(nil), lottery.RemoteBalanceCapacity{}, lottery.StatsPrivacy{}, engine.RoundingNearest, config.LastTicket{}, config.LNURLPay{}, config.DrawSLO{}, h.reloaderMock, adminConfig).GetInvoice(h.rec, h.req)
+
+	var response handler.InvoiceResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(paymentID, response.PaymentID)
+	h.Equal("pr", response.Invoice)
+
+	// The streamer must be able to settle the invoice with the preimage of the hash used
+	args := h.eventStreamerMock.Calls[0].Arguments
+	h.Equal(hex.EncodeToString(hash), args.String(0))
+	preimageHash := sha256.Sum256(args.Get(1).([]byte))
+	h.Equal(hash, preimageHash[:])
+
+	// The preimage is stored to settle the invoice after a restart
+	h.invoicesMock.AssertCalled(h.T(), "Add", mock.MatchedBy(func(invoice db.Invoice) bool {
+		return invoice.PaymentHash == args.String(0) &&
+			invoice.Preimage == hex.EncodeToString(args.Get(1).([]byte))
+	}))
+}
+
+func (h *HandlerSuite) TestGetInvoicePools() {
+	pools := lottery.NewPools([]config.Pool{
+		{Name: "micro", MaxAmount: 9_999, Capacity: 10},
+		{Name: "whale", MinAmount: 100_000, Capacity: 90},
+	})
+	database := &db.DB{
+		AccessLists:   h.accessListsMock,
+		Bets:          h.betsMock,
+		Limits:        h.limitsMock,
+		Lotteries:     h.lotteriesMock,
+		Postponements: h.postponementsMock,
+	}
+	peerCap := policy.NewPeerCap(config.PeerCap{}, database, h.lndMock)
+	limits := policy.NewLimits(config.Limits{}, database)
+	handler := handler.New(h.lndMock, database, h.eventStreamerMock, h.auditorMock, h.publisherMock, nil, peerCap, limits, policy.NewHousePlay(config.HousePlay{}), nil, nil, nil,
+		nil, nil, nil, h.invoices(database, peerCap), nil, nil, nil, pools, lottery.RemoteBalanceCapacity{}, lottery.StatsPrivacy{}, engine.RoundingNearest, config.LastTicket{}, config.LNURLPay{}, config.DrawSLO{}, h.reloaderMock, adminConfig)
+
+	h.mockNoLimits()
+
+	blockHeight := uint32(1)
+	h.lndMock.On("RemoteBalance", mock.Anything).Return(int64(400_000), nil)
+	h.lotteriesMock.On("GetNextHeight").Return(blockHeight, nil)
+	h.postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+	h.betsMock.On("GetPrizePool", blockHeight, "micro").Return(uint64(0), nil)
+	h.betsMock.On("GetPrizePool", blockHeight, "whale").Return(uint64(0), nil)
+
+	cases := []struct {
+		desc   string
+		amount string
+		status int
+	}{
+		{
+			desc:   "No pool",
+			amount: "50000",
+			status: http.StatusBadRequest,
+		},
+		{
+			desc:   "Exceeds pool capacity",
+			amount: "9000",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.rec = httptest.NewRecorder()
+			h.req = httptest.NewRequest(http.MethodGet, "/invoice?amount="+tc.amount, nil)
+			h.SetDefaultAuthorizationKey()
+
+			handler.GetInvoice(h.rec, h.req)
+
+			h.Equal(tc.status, h.rec.Code)
+		})
+	}
 }
 
 func (h *HandlerSuite) TestGetInvoiceInvalidPublicKey() {
@@ -68,14 +357,43 @@ func (h *HandlerSuite) TestGetInvoiceExceedsCapacity() {
 	h.req = httptest.NewRequest(http.MethodGet, "/invoice?amount=5000000", nil)
 	h.SetDefaultAuthorizationKey()
 
+	h.mockNoLimits()
+
 	blockHeight := uint32(1)
 	h.lndMock.On("RemoteBalance", h.req.Context()).Return(int64(5000000), nil)
 	h.lotteriesMock.On("GetNextHeight").Return(blockHeight, nil)
-	h.betsMock.On("GetPrizePool", blockHeight).Return(uint64(0), nil)
+	h.postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+	h.betsMock.On("GetPrizePool", blockHeight, "").Return(uint64(0), nil)
+
+	h.handler.GetInvoice(h.rec, h.req)
+
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestGetInvoicePostponed() {
+	h.req = httptest.NewRequest(http.MethodGet, "/invoice?amount=5000", nil)
+	h.SetDefaultAuthorizationKey()
+
+	h.mockNoLimits()
+
+	blockHeight := uint32(144)
+	postponement := db.Postponement{LotteryHeight: blockHeight, PostponedAt: 144, ResumeHeight: 150}
+	h.lndMock.On("RemoteBalance", h.req.Context()).Return(int64(5000000), nil)
+	h.lotteriesMock.On("GetNextHeight").Return(blockHeight, nil)
+	h.postponementsMock.On("GetActive").Return(postponement, nil)
+	h.betsMock.On("GetPrizePool", blockHeight, "").Return(uint64(0), nil)
 
 	h.handler.GetInvoice(h.rec, h.req)
 
+	var response apierrors.Response
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
 	h.Equal(http.StatusBadRequest, h.rec.Code)
+	h.Equal(apierrors.CodeDrawPostponed, response.Error.Code)
+	h.True(response.Error.Retryable)
+	h.Equal(float64(150), response.Error.Details["resume_height"])
+	h.lndMock.AssertNotCalled(h.T(), "AddInvoice", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
 func (h *HandlerSuite) TestGetInvoiceGetInfoError() {
@@ -83,18 +401,20 @@ func (h *HandlerSuite) TestGetInvoiceGetInfoError() {
 	h.req = httptest.NewRequest(http.MethodGet, "/invoice?amount="+strconv.FormatUint(amount, 10), nil)
 	h.SetDefaultAuthorizationKey()
 
+	h.mockNoLimits()
+
 	ctx := h.req.Context()
 	expectedErr := errors.New("test err")
 	h.lndMock.On("RemoteBalance", ctx).Return(int64(0), expectedErr)
 
 	h.handler.GetInvoice(h.rec, h.req)
 
-	var response handler.ErrorResponse
+	var response apierrors.Response
 	err := json.NewDecoder(h.rec.Body).Decode(&response)
 	h.NoError(err)
 
 	h.Equal(http.StatusInternalServerError, h.rec.Code)
-	h.Equal(expectedErr.Error(), response.Error)
+	h.Equal(expectedErr.Error(), response.Error.Message)
 }
 
 func (h *HandlerSuite) TestGetInvoiceAddInvoiceError() {
@@ -102,21 +422,82 @@ func (h *HandlerSuite) TestGetInvoiceAddInvoiceError() {
 	h.req = httptest.NewRequest(http.MethodGet, "/invoice?amount="+strconv.FormatUint(amount, 10), nil)
 	h.SetDefaultAuthorizationKey()
 
+	h.mockNoLimits()
+
 	ctx := h.req.Context()
 	blockHeight := uint32(1)
 	h.lndMock.On("RemoteBalance", ctx).Return(int64(1_000_000), nil)
 	h.lotteriesMock.On("GetNextHeight").Return(blockHeight, nil)
-	h.betsMock.On("GetPrizePool", blockHeight).Return(uint64(0), nil)
+	h.postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+	h.betsMock.On("GetPrizePool", blockHeight, "").Return(uint64(0), nil)
 
 	expectedErr := errors.New("test err")
-	h.lndMock.On("AddInvoice", ctx, amount).Return(nil, expectedErr)
+	h.lndMock.On("AddInvoice", ctx, amount, mock.Anything, []byte(nil)).Return(nil, expectedErr)
 
 	h.handler.GetInvoice(h.rec, h.req)
 
-	var response handler.ErrorResponse
+	var response apierrors.Response
 	err := json.NewDecoder(h.rec.Body).Decode(&response)
 	h.NoError(err)
 
 	h.Equal(http.StatusInternalServerError, h.rec.Code)
-	h.Equal(expectedErr.Error(), response.Error)
+	h.Equal(expectedErr.Error(), response.Error.Message)
+}
+
+func (h *HandlerSuite) TestGetInvoiceAnonymous() {
+	amount := uint64(2000)
+	h.req = httptest.NewRequest(http.MethodGet, "/invoice?anonymous=true&amount="+strconv.FormatUint(amount, 10), nil)
+
+	ctx := h.req.Context()
+	blockHeight := uint32(1)
+	h.lndMock.On("RemoteBalance", ctx).Return(int64(1_000_000), nil)
+	h.lotteriesMock.On("GetNextHeight").Return(blockHeight, nil)
+	h.postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+	h.betsMock.On("GetPrizePool", blockHeight, "").Return(uint64(0), nil)
+
+	var codeHash, publicKey string
+	h.claimCodesMock.On("Add", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			codeHash = args.String(0)
+			publicKey = args.String(1)
+		}).
+		Return(nil)
+
+	addInvoiceResp := &lnrpc.AddInvoiceResponse{RHash: []byte("rhash"), PaymentRequest: "pr"}
+	h.lndMock.On("AddInvoice", ctx, amount, "BTRY;round=1;tickets=2000", []byte(nil)).Return(addInvoiceResp, nil)
+	h.eventStreamerMock.On("TrackPayment", hex.EncodeToString(addInvoiceResp.RHash), mock.Anything, amount).
+		Return(uint64(1))
+
+	h.handler.GetInvoice(h.rec, h.req)
+
+	var response handler.InvoiceResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal("pr", response.Invoice)
+
+	// Only the hash of the code is stored and the bet is tracked under the public key issued
+	hash := sha256.Sum256([]byte(response.ClaimCode))
+	h.Equal(hex.EncodeToString(hash[:]), codeHash)
+	h.eventStreamerMock.AssertCalled(h.T(), "TrackPayment", mock.Anything, publicKey, amount)
+	h.limitsMock.AssertNotCalled(h.T(), "GetExclusion", mock.Anything)
+}
+
+func (h *HandlerSuite) TestGetInvoiceAnonymousDisabled() {
+	db := &db.DB{
+		AccessLists: h.accessListsMock,
+		Bets:        h.betsMock,
+		ClaimCodes:  h.claimCodesMock,
+		Lotteries:   h.lotteriesMock,
+	}
+	claimCodes := policy.NewClaimCodes(config.ClaimCodes{}, db)
+	handler := handler.New(h.lndMock, db, h.eventStreamerMock, h.auditorMock, h.publisherMock, nil, nil, nil, nil, nil, claimCodes, nil,
+		nil, nil, nil, h.invoices(db, nil), nil, nil, nil, lottery.NewPools(nil), lottery.RemoteBalanceCapacity{}, lottery.StatsPrivacy{}, engine.RoundingNearest, config.LastTicket{}, config.LNURLPay{}, config.DrawSLO{}, h.reloaderMock, adminConfig)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/invoice?anonymous=true&amount=2000", nil)
+	handler.GetInvoice(h.rec, h.req)
+
+	h.Equal(http.StatusForbidden, h.rec.Code)
+	h.claimCodesMock.AssertNotCalled(h.T(), "Add", mock.Anything, mock.Anything, mock.Anything)
 }
@@ -0,0 +1,9 @@
+package handler
+
+import "net/http"
+
+// GetJurisdictionStats responds with the number of bet attempts blocked by the jurisdiction
+// gating since the server started.
+func (h *Handler) GetJurisdictionStats(w http.ResponseWriter, r *http.Request) {
+	sendResponse(w, http.StatusOK, h.jurisdiction.Stats())
+}
@@ -0,0 +1,24 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aftermath2/BTRY/policy"
+)
+
+func (h *HandlerSuite) TestGetJurisdictionStats() {
+	h.ErrorIs(h.jurisdiction.Check("203.0.113.7"), policy.ErrJurisdictionBlocked)
+	h.NoError(h.jurisdiction.Check("198.51.100.1"))
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/jurisdiction", nil)
+	h.handler.GetJurisdictionStats(h.rec, h.req)
+
+	var response policy.JurisdictionStats
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(policy.JurisdictionStats{ByCountry: map[string]uint64{"network": 1}, Blocked: 1}, response)
+}
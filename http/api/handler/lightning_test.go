@@ -7,6 +7,7 @@ import (
 	"net/url"
 
 	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
 	"github.com/aftermath2/BTRY/http/api/handler"
 
 	"github.com/pkg/errors"
@@ -51,12 +52,12 @@ func (h *HandlerSuite) TestGetLightningAddressError() {
 	h.SetAuthorizationKey(validPublicKey)
 	h.handler.GetLightningAddress(h.rec, h.req)
 
-	var response handler.ErrorResponse
+	var response apierrors.Response
 	err := json.NewDecoder(h.rec.Body).Decode(&response)
 	h.NoError(err)
 
 	h.Equal(http.StatusInternalServerError, h.rec.Code)
-	h.Equal(expectedErr.Error(), response.Error)
+	h.Equal(expectedErr.Error(), response.Error.Message)
 }
 
 func (h *HandlerSuite) TestGetLightningNoAuthError() {
@@ -113,10 +114,10 @@ func (h *HandlerSuite) TestSetLightningAddressInternalError() {
 
 	h.handler.SetLightningAddress(h.rec, h.req)
 
-	var response handler.ErrorResponse
+	var response apierrors.Response
 	err := json.NewDecoder(h.rec.Body).Decode(&response)
 	h.NoError(err)
 
 	h.Equal(http.StatusInternalServerError, h.rec.Code)
-	h.Equal(expectedErr.Error(), response.Error)
+	h.Equal(expectedErr.Error(), response.Error.Message)
 }
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aftermath2/BTRY/crypto"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// maxExclusionDays is the longest self-exclusion period that can be requested at once.
+const maxExclusionDays = 5 * 365
+
+// LimitsResponse is the response schema of the /limits endpoints.
+type LimitsResponse struct {
+	Limits        []db.Limit `json:"limits"`
+	ExcludedUntil int64      `json:"excluded_until,omitempty"`
+}
+
+// ExclusionResponse is the response schema of the /exclusion endpoint.
+type ExclusionResponse struct {
+	ExcludedUntil int64 `json:"excluded_until"`
+}
+
+// GetLimits responds with the public key's limits, both in effect and pending, and the time until
+// which it is self-excluded.
+func (h *Handler) GetLimits(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := getAuthPublicKey(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limits, err := h.limits.List(publicKey)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	excludedUntil, err := h.db.Limits.GetExclusion(publicKey)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := LimitsResponse{
+		Limits:        limits,
+		ExcludedUntil: excludedUntil,
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// SetLimit changes the weekly deposit or loss limit of a public key.
+func (h *Handler) SetLimit(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := getSignedPublicKey(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	query := r.URL.Query()
+
+	kind := db.LimitKind(query.Get("kind"))
+	if kind != db.DepositLimit && kind != db.LossLimit {
+		sendError(w, http.StatusBadRequest, errors.Errorf("invalid limit kind %q", kind))
+		return
+	}
+
+	amount, err := parseIntParam(query, "amount", true)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, err := h.limits.Set(publicKey, kind, amount)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, limit)
+}
+
+// Exclude prevents a public key from betting for a number of days.
+func (h *Handler) Exclude(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := getSignedPublicKey(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	days, err := parseIntParam(r.URL.Query(), "days", true)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if days == 0 || days > maxExclusionDays {
+		err := errors.Errorf("invalid number of days, must be between 1 and %d", maxExclusionDays)
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	until, err := h.limits.Exclude(publicKey, time.Duration(days)*24*time.Hour)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := ExclusionResponse{ExcludedUntil: until.Unix()}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// getSignedPublicKey returns the authorization public key, only if the request contains its
+// signature. Public keys are not secret, changes on behalf of a player require proving its
+// ownership.
+func getSignedPublicKey(r *http.Request) (string, error) {
+	publicKey, err := getAuthPublicKey(r)
+	if err != nil {
+		return "", err
+	}
+
+	signature := r.URL.Query().Get("signature")
+	if signature == "" {
+		return "", errors.New("signature parameter missing")
+	}
+
+	if err := crypto.VerifySignature(publicKey, signature); err != nil {
+		return "", err
+	}
+
+	return publicKey, nil
+}
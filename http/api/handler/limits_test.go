@@ -0,0 +1,134 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func (h *HandlerSuite) mockNoLimits() {
+	h.limitsMock.On("GetExclusion", mock.Anything).Return(int64(0), nil)
+	h.limitsMock.On("List", mock.Anything, mock.Anything).Return(nil, nil)
+}
+
+func (h *HandlerSuite) TestGetLimits() {
+	h.req = httptest.NewRequest(http.MethodGet, "/limits", nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	limits := []db.Limit{{Kind: db.DepositLimit, Amount: 10_000, EffectiveAt: 1}}
+	excludedUntil := int64(2)
+	h.limitsMock.On("List", validPublicKey, mock.Anything).Return(limits, nil)
+	h.limitsMock.On("GetExclusion", validPublicKey).Return(excludedUntil, nil)
+
+	h.handler.GetLimits(h.rec, h.req)
+
+	var response handler.LimitsResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(limits, response.Limits)
+	h.Equal(excludedUntil, response.ExcludedUntil)
+}
+
+func (h *HandlerSuite) TestSetLimit() {
+	h.req = httptest.NewRequest(http.MethodPost,
+		"/limits?kind=loss&amount=5000&signature="+validSignature, nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.limitsMock.On("List", validPublicKey, mock.Anything).Return(nil, nil)
+	h.limitsMock.On("Set", validPublicKey, mock.Anything, mock.Anything).Return(nil)
+
+	h.handler.SetLimit(h.rec, h.req)
+
+	var response db.Limit
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(db.LossLimit, response.Kind)
+	h.Equal(uint64(5000), response.Amount)
+}
+
+func (h *HandlerSuite) TestSetLimitInvalid() {
+	cases := []struct {
+		desc  string
+		query string
+	}{
+		{
+			desc:  "Missing signature",
+			query: "?kind=loss&amount=5000",
+		},
+		{
+			desc:  "Invalid signature",
+			query: "?kind=loss&amount=5000&signature=abcd",
+		},
+		{
+			desc:  "Invalid kind",
+			query: "?kind=win&amount=5000&signature=" + validSignature,
+		},
+		{
+			desc:  "Invalid amount",
+			query: "?kind=loss&amount=five&signature=" + validSignature,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.rec = httptest.NewRecorder()
+			h.req = httptest.NewRequest(http.MethodPost, "/limits"+tc.query, nil)
+			h.SetAuthorizationKey(validPublicKey)
+
+			h.handler.SetLimit(h.rec, h.req)
+
+			h.Equal(http.StatusBadRequest, h.rec.Code)
+		})
+	}
+}
+
+func (h *HandlerSuite) TestExclude() {
+	h.req = httptest.NewRequest(http.MethodPost,
+		"/limits/exclusion?days=30&signature="+validSignature, nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	until := time.Now().Add(30 * 24 * time.Hour).Unix()
+	h.limitsMock.On("Exclude", validPublicKey, mock.Anything).Return(nil)
+	h.limitsMock.On("GetExclusion", validPublicKey).Return(until, nil)
+
+	h.handler.Exclude(h.rec, h.req)
+
+	var response handler.ExclusionResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(until, response.ExcludedUntil)
+}
+
+func (h *HandlerSuite) TestExcludeInvalidDays() {
+	h.req = httptest.NewRequest(http.MethodPost,
+		"/limits/exclusion?days=0&signature="+validSignature, nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.handler.Exclude(h.rec, h.req)
+
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestGetInvoiceSelfExcluded() {
+	h.req = httptest.NewRequest(http.MethodGet, "/invoice?amount=2000", nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	until := time.Now().Add(time.Hour).Unix()
+	h.limitsMock.On("GetExclusion", validPublicKey).Return(until, nil)
+
+	h.handler.GetInvoice(h.rec, h.req)
+
+	h.Equal(http.StatusForbidden, h.rec.Code)
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/aftermath2/BTRY/crypto"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/fiatjaf/go-lnurl"
+	"github.com/pkg/errors"
+)
+
+// errLNURLPayDisabled is returned when the operator didn't configure the LNURL-pay ticket amount.
+var errLNURLPayDisabled = errors.New("LNURL-pay bets are disabled")
+
+// LNURLPay endpoint handler.
+//
+// Responds with the parameters of the static LNURL-pay code, which sells a ticket of a fixed amount
+// on every payment. The payer may write the public key the bet is registered under in the comment.
+func (h *Handler) LNURLPay(w http.ResponseWriter, r *http.Request) {
+	if h.lnurlPay.Amount == 0 {
+		sendLNURLError(w, http.StatusNotFound, errLNURLPayDisabled)
+		return
+	}
+
+	amountMsat := int64(h.lnurlPay.Amount) * 1000
+	resp := &lnurl.LNURLPayParams{
+		Tag:             "payRequest",
+		Callback:        baseURL(r) + "/api/lightning/lnurlp/callback",
+		MinSendable:     amountMsat,
+		MaxSendable:     amountMsat,
+		EncodedMetadata: h.lnurlPayMetadata(),
+		CommentAllowed:  int64(hex.EncodedLen(ed25519.PublicKeySize)),
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// LNURLPayCallback endpoint handler.
+//
+// Responds with the invoice of a bet of the LNURL-pay ticket amount. Bets without a public key in
+// the comment are anonymous, the success action shows the claim code that redeems their prizes.
+func (h *Handler) LNURLPayCallback(w http.ResponseWriter, r *http.Request) {
+	if h.lnurlPay.Amount == 0 {
+		sendLNURLError(w, http.StatusNotFound, errLNURLPayDisabled)
+		return
+	}
+
+	query := r.URL.Query()
+	amountMsat, err := parseIntParam(query, "amount", true)
+	if err != nil {
+		sendLNURLError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if amountMsat != h.lnurlPay.Amount*1000 {
+		err := errors.Errorf("invalid amount, tickets cost %d millisatoshis", h.lnurlPay.Amount*1000)
+		sendLNURLError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	publicKey := query.Get("comment")
+	anonymous := publicKey == ""
+	if anonymous {
+		if !h.claimCodes.Enabled() {
+			sendLNURLError(w, http.StatusForbidden, policy.ErrAnonymousBetsDisabled)
+			return
+		}
+	} else {
+		if err := crypto.ValidatePublicKey(publicKey); err != nil {
+			sendLNURLError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.limits.Check(publicKey, h.lnurlPay.Amount); err != nil {
+			if errors.Is(err, policy.ErrSelfExcluded) || errors.Is(err, policy.ErrLimitExceeded) {
+				sendLNURLError(w, http.StatusForbidden, err)
+				return
+			}
+			sendLNURLError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if err := h.housePlay.Check(publicKey); err != nil {
+			sendLNURLError(w, http.StatusForbidden, err)
+			return
+		}
+	}
+
+	if err := policy.CheckAccess(h.db.AccessLists, h.auditor, publicKey, db.AccessBets); err != nil {
+		sendLNURLError(w, accessStatus(err), err)
+		return
+	}
+
+	descriptionHash := sha256.Sum256([]byte(h.lnurlPayMetadata()))
+	invoice, _, err := h.betInvoice(r.Context(), publicKey, anonymous, h.lnurlPay.Amount, descriptionHash[:])
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			sendLNURLError(w, http.StatusBadRequest, err)
+			return
+		}
+		sendLNURLError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := &lnurl.LNURLPayValues{
+		PR:     invoice.Invoice,
+		Routes: []any{},
+	}
+	if invoice.ClaimCode != "" {
+		resp.SuccessAction = lnurl.Action("Claim code: "+invoice.ClaimCode, "")
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// lnurlPayMetadata returns the encoded metadata of the LNURL-pay code, the invoices commit to its
+// hash.
+func (h *Handler) lnurlPayMetadata() string {
+	description := h.lnurlPay.Description
+	if description == "" {
+		description = fmt.Sprintf("BTRY ticket of %d sats", h.lnurlPay.Amount)
+	}
+
+	return lnurl.Metadata{Description: description}.Encode()
+}
@@ -0,0 +1,200 @@
+package handler_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/lottery/engine"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/fiatjaf/go-lnurl"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/stretchr/testify/mock"
+)
+
+var lnurlPayConfig = config.LNURLPay{Amount: 2000, Description: "BTRY ticket"}
+
+// lnurlPayMetadata is the metadata of the LNURL-pay code with the suite's configuration.
+var lnurlPayMetadata = `[["text/plain","BTRY ticket"]]`
+
+func (h *HandlerSuite) TestLNURLPay() {
+	h.req = httptest.NewRequest(http.MethodGet, "/lightning/lnurlp", nil)
+
+	h.handler.LNURLPay(h.rec, h.req)
+
+	var response lnurl.LNURLPayParams
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal("payRequest", response.Tag)
+	h.Equal("http://"+h.req.Host+"/api/lightning/lnurlp/callback", response.Callback)
+	h.Equal(int64(2_000_000), response.MinSendable)
+	h.Equal(int64(2_000_000), response.MaxSendable)
+	h.Equal(lnurlPayMetadata, response.EncodedMetadata)
+	h.Equal(int64(64), response.CommentAllowed)
+}
+
+func (h *HandlerSuite) TestLNURLPayDisabled() {
+	handler := handler.New(h.lndMock, &db.DB{}, h.eventStreamerMock, h.auditorMock, h.publisherMock, nil, nil, nil, nil, nil, nil, nil,
+		nil, nil, nil, nil, nil, nil, nil, lottery.NewPools(nil), lottery.RemoteBalanceCapacity{}, lottery.StatsPrivacy{}, engine.RoundingNearest, config.LastTicket{},
+		config.LNURLPay{}, config.DrawSLO{}, h.reloaderMock, adminConfig)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/lightning/lnurlp", nil)
+	handler.LNURLPay(h.rec, h.req)
+
+	var response lnurl.LNURLErrorResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusNotFound, h.rec.Code)
+	h.Equal("ERROR", response.Status)
+}
+
+func (h *HandlerSuite) TestLNURLPayCallback() {
+	h.req = httptest.NewRequest(http.MethodGet,
+		"/lightning/lnurlp/callback?amount=2000000&comment="+validPublicKey, nil)
+	h.mockNoLimits()
+	descriptionHash := h.mockBetInvoice(lnurlPayConfig.Amount)
+
+	h.handler.LNURLPayCallback(h.rec, h.req)
+
+	var response lnurl.LNURLPayValues
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal("pr", response.PR)
+	h.Nil(response.SuccessAction)
+	h.lndMock.AssertCalled(h.T(), "AddInvoice", mock.Anything, lnurlPayConfig.Amount, mock.Anything,
+		descriptionHash)
+	h.eventStreamerMock.AssertCalled(h.T(), "TrackPayment", mock.Anything, validPublicKey,
+		lnurlPayConfig.Amount)
+}
+
+func (h *HandlerSuite) TestLNURLPayCallbackAnonymous() {
+	h.req = httptest.NewRequest(http.MethodGet, "/lightning/lnurlp/callback?amount=2000000", nil)
+	h.mockBetInvoice(lnurlPayConfig.Amount)
+
+	var codeHash string
+	h.claimCodesMock.On("Add", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			codeHash = args.String(0)
+		}).
+		Return(nil)
+
+	h.handler.LNURLPayCallback(h.rec, h.req)
+
+	var response lnurl.LNURLPayValues
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal("pr", response.PR)
+	h.Equal("message", response.SuccessAction.Tag)
+
+	// The success action shows the claim code whose hash was stored
+	claimCode := response.SuccessAction.Message[len("Claim code: "):]
+	hash := sha256.Sum256([]byte(claimCode))
+	h.Equal(hex.EncodeToString(hash[:]), codeHash)
+	h.limitsMock.AssertNotCalled(h.T(), "GetExclusion", mock.Anything)
+}
+
+func (h *HandlerSuite) TestLNURLPayCallbackErrors() {
+	cases := []struct {
+		desc       string
+		query      string
+		statusCode int
+	}{
+		{
+			desc:       "Missing amount",
+			query:      "",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			desc:       "Different amount",
+			query:      "amount=1000000",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			desc:       "Invalid public key",
+			query:      "amount=2000000&comment=hello",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.SetupTest()
+			h.req = httptest.NewRequest(http.MethodGet, "/lightning/lnurlp/callback?"+tc.query, nil)
+
+			h.handler.LNURLPayCallback(h.rec, h.req)
+
+			var response lnurl.LNURLErrorResponse
+			err := json.NewDecoder(h.rec.Body).Decode(&response)
+			h.NoError(err)
+
+			h.Equal(tc.statusCode, h.rec.Code)
+			h.Equal("ERROR", response.Status)
+			h.lndMock.AssertNotCalled(h.T(), "AddInvoice", mock.Anything, mock.Anything, mock.Anything,
+				mock.Anything)
+		})
+	}
+}
+
+func (h *HandlerSuite) TestLNURLPayCallbackHousePlay() {
+	h.req = httptest.NewRequest(http.MethodGet,
+		"/lightning/lnurlp/callback?amount=2000000&comment="+housePublicKey, nil)
+	h.mockNoLimits()
+
+	h.handler.LNURLPayCallback(h.rec, h.req)
+
+	var response lnurl.LNURLErrorResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusForbidden, h.rec.Code)
+	h.Equal(policy.ErrHousePlay.Error(), response.Reason)
+	h.lndMock.AssertNotCalled(h.T(), "AddInvoice", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestLNURLPayCallbackAnonymousDisabled() {
+	db := &db.DB{ClaimCodes: h.claimCodesMock}
+	claimCodes := policy.NewClaimCodes(config.ClaimCodes{}, db)
+	handler := handler.New(h.lndMock, db, h.eventStreamerMock, h.auditorMock, h.publisherMock, nil, nil, nil, nil, nil, claimCodes, nil,
+		nil, nil, nil, nil, nil, nil, nil, lottery.NewPools(nil), lottery.RemoteBalanceCapacity{}, lottery.StatsPrivacy{}, engine.RoundingNearest, config.LastTicket{},
+		lnurlPayConfig, config.DrawSLO{}, h.reloaderMock, adminConfig)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/lightning/lnurlp/callback?amount=2000000", nil)
+	handler.LNURLPayCallback(h.rec, h.req)
+
+	h.Equal(http.StatusForbidden, h.rec.Code)
+	h.claimCodesMock.AssertNotCalled(h.T(), "Add", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// mockBetInvoice mocks the creation of a bet invoice of the amount specified and returns the
+// description hash it's expected to commit to.
+func (h *HandlerSuite) mockBetInvoice(amount uint64) []byte {
+	ctx := h.req.Context()
+	blockHeight := uint32(1)
+	h.lndMock.On("RemoteBalance", ctx).Return(int64(1_000_000), nil)
+	h.lotteriesMock.On("GetNextHeight").Return(blockHeight, nil)
+	h.postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+	h.betsMock.On("GetPrizePool", blockHeight, "").Return(uint64(0), nil)
+
+	descriptionHash := sha256.Sum256([]byte(lnurlPayMetadata))
+	addInvoiceResp := &lnrpc.AddInvoiceResponse{RHash: []byte("rhash"), PaymentRequest: "pr"}
+	h.lndMock.On("AddInvoice", ctx, amount, "BTRY;round=1;tickets=2000", descriptionHash[:]).
+		Return(addInvoiceResp, nil)
+	h.eventStreamerMock.On("TrackPayment", hex.EncodeToString(addInvoiceResp.RHash), mock.Anything, amount).
+		Return(uint64(1))
+
+	return descriptionHash[:]
+}
@@ -49,16 +49,7 @@ func (h *Handler) LNURLWithdraw(w http.ResponseWriter, r *http.Request) {
 		maxWithdrawableMsat = int64(totalPrizes-fee) * 1000
 	}
 
-	scheme := "http"
-	if r.TLS != nil {
-		scheme = "https"
-	}
-	callback := fmt.Sprintf("%s://%s/api/withdraw?fee=%d&pubkey=%s",
-		scheme,
-		r.Host,
-		fee,
-		publicKey,
-	)
+	callback := fmt.Sprintf("%s/api/withdraw?fee=%d&pubkey=%s", baseURL(r), fee, publicKey)
 	resp := &lnurl.LNURLWithdrawResponse{
 		Tag:                "withdrawalRequest",
 		Callback:           callback,
@@ -4,25 +4,211 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
 	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/rates"
 
 	"github.com/pkg/errors"
 )
 
+// LotteryResponse is the response schema of the /lottery endpoint.
+type LotteryResponse struct {
+	// Fiat is the approximate value of the prize pool
+	Fiat rates.Values `json:"fiat,omitempty"`
+	// Network is the bitcoin network the lottery runs on, invoices for other networks are rejected
+	Network       string `json:"network"`
+	InvoicePrefix string `json:"invoice_prefix"`
+	// LastTicket is set when the last tickets sold enter a bonus draw
+	LastTicket *LastTicketBonus `json:"last_ticket,omitempty"`
+	// HousePlay tells whether the operators can bet: "undeclared" when they didn't designate their
+	// public keys, "excluded" when they can't and "flagged" when their bets are flagged
+	HousePlay string `json:"house_play"`
+	lottery.Info
+}
+
+// LastTicketBonus describes the bonus draw among the last tickets sold before the lottery closes.
+type LastTicketBonus struct {
+	// Tickets is the number of tickets taking part in the draw
+	Tickets uint64 `json:"tickets"`
+	// Percentage of the prize pool won
+	Percentage float64 `json:"percentage"`
+}
+
 // HeightsResponse is the response schema of the /heights endpoint.
 type HeightsResponse struct {
 	Heights []uint32 `json:"heights"`
 }
 
+// BetArchiveResponse is the response schema of the /lottery/archive endpoint.
+type BetArchiveResponse struct {
+	Commitment db.Commitment `json:"commitment"`
+	Bets       []db.Bet      `json:"bets"`
+	// Tiers are the prize tiers of each pool, only when they adapted to the participants
+	Tiers []db.Tiers `json:"tiers,omitempty"`
+}
+
+// FairnessResponse is the response schema of the /lottery/fairness endpoint.
+type FairnessResponse struct {
+	Reports []db.FairnessReport `json:"reports,omitempty"`
+}
+
 // GetLottery endpoint handler.
 func (h *Handler) GetLottery(w http.ResponseWriter, r *http.Request) {
-	lotteryInfo, err := lottery.GetInfo(r.Context(), h.lnd, h.db)
+	lotteryInfo, err := lottery.GetInfo(r.Context(), h.lnd, h.db, h.pools, h.capacity)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	network := h.lnd.Network()
+	resp := LotteryResponse{
+		Info:          lotteryInfo,
+		Fiat:          h.rates.Convert(uint64(lotteryInfo.PrizePool)),
+		Network:       network,
+		InvoicePrefix: lightning.InvoicePrefix(network),
+		HousePlay:     h.housePlay.Mode(),
+	}
+	if h.lastTicket.Tickets > 0 {
+		resp.LastTicket = &LastTicketBonus{
+			Tickets:    h.lastTicket.Tickets,
+			Percentage: h.lastTicket.Percentage,
+		}
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// GetCommitment endpoint handler.
+//
+// Returns the commitment to the server seed of the lottery at the height specified, or the one in
+// progress if none is. The seed is included once the lottery was drawn.
+func (h *Handler) GetCommitment(w http.ResponseWriter, r *http.Request) {
+	height, err := parseIntParam(r.URL.Query(), "height", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	nextHeight, err := h.db.Lotteries.GetNextHeight()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if height == 0 {
+		height = uint64(nextHeight)
+	}
+
+	commitment, err := h.db.Lotteries.GetCommitment(uint32(height))
+	if err != nil {
+		if errors.Is(err, db.ErrLotteryNotFound) {
+			sendError(w, http.StatusNotFound, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if commitment.Height >= nextHeight {
+		commitment.Seed = ""
+	}
+
+	// The seed would give the winners away before they are revealed
+	if commitment.Seed != "" {
+		pending, err := lottery.RevealPending(h.db.Reveals, commitment.Height)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if pending {
+			commitment.Seed = ""
+		}
+	}
+
+	sendResponse(w, http.StatusOK, commitment)
+}
+
+// GetBetArchive endpoint handler.
+//
+// Returns the bets of a lottery drawn as they were placed along with its server seed and block
+// hash, everything needed to reproduce the draw.
+func (h *Handler) GetBetArchive(w http.ResponseWriter, r *http.Request) {
+	height, err := parseIntParam(r.URL.Query(), "height", true)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	database := h.db.ReadReplica()
+	pending, err := lottery.RevealPending(database.Reveals, uint32(height))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if pending {
+		sendError(w, http.StatusConflict, errors.New("the lottery winners are still being revealed"))
+		return
+	}
+
+	bets, err := database.BetArchives.Get(uint32(height))
+	if err != nil {
+		if errors.Is(err, db.ErrBetArchiveNotFound) {
+			sendError(w, http.StatusNotFound, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	commitment, err := database.Lotteries.GetCommitment(uint32(height))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	tiers, err := database.Lotteries.ListTiers(uint32(height))
 	if err != nil {
 		sendError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	sendResponse(w, http.StatusOK, lotteryInfo)
+	sendResponse(w, http.StatusOK, BetArchiveResponse{Commitment: commitment, Bets: bets, Tiers: tiers})
+}
+
+// GetFairness responds with the fairness reports of the lotteries drawn.
+func (h *Handler) GetFairness(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	offset, err := parseIntParam(query, "offset", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, err := parseIntParam(query, "limit", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	reverse := false
+	reverseStr := query.Get("reverse")
+	if reverseStr != "" {
+		v, err := strconv.ParseBool(reverseStr)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, errors.Wrap(err, "invalid reverse parameter"))
+			return
+		}
+		reverse = v
+	}
+
+	reports, err := h.db.ReadReplica().Fairness.List(offset, limit, reverse)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, FairnessResponse{Reports: reports})
 }
 
 // GetHeights endpoint handler.
@@ -52,7 +238,7 @@ func (h *Handler) GetHeights(w http.ResponseWriter, r *http.Request) {
 		reverse = v
 	}
 
-	heights, err := h.db.Lotteries.ListHeights(offset, limit, reverse)
+	heights, err := h.db.ReadReplica().Lotteries.ListHeights(offset, limit, reverse)
 	if err != nil {
 		sendError(w, http.StatusInternalServerError, err)
 		return
@@ -64,3 +250,58 @@ func (h *Handler) GetHeights(w http.ResponseWriter, r *http.Request) {
 
 	sendResponse(w, http.StatusOK, respBody)
 }
+
+// GetDrawCard endpoint handler.
+//
+// Returns an SVG image summarizing the result of the lottery at the height specified, or the last
+// one drawn if none is, to share it on social networks.
+func (h *Handler) GetDrawCard(w http.ResponseWriter, r *http.Request) {
+	height, err := parseIntParam(r.URL.Query(), "height", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	replica := h.db.ReadReplica()
+	var round db.RoundStats
+	if height == 0 {
+		rounds, err := replica.Stats.ListRounds(0, 1, true)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if len(rounds) == 0 {
+			sendError(w, http.StatusNotFound, db.ErrRoundNotFound)
+			return
+		}
+		round = rounds[0]
+	} else {
+		round, err = replica.Stats.GetRound(uint32(height))
+		if err != nil {
+			if errors.Is(err, db.ErrRoundNotFound) {
+				sendError(w, http.StatusNotFound, err)
+				return
+			}
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	round = h.statsPrivacy.Round(round)
+	if round.Hidden {
+		sendError(w, http.StatusNotFound, errors.New("the round statistics are private"))
+		return
+	}
+
+	winners, err := replica.Winners.List(round.Height)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	card := lottery.NewDrawCard(round, winners)
+	card.TopPrize = h.statsPrivacy.Amount(card.TopPrize)
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(card.SVG())
+}
@@ -7,10 +7,17 @@ import (
 	"net/url"
 	"strconv"
 
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
 	"github.com/aftermath2/BTRY/http/api/handler"
 	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/lottery/engine"
+	"github.com/aftermath2/BTRY/policy"
+	"github.com/aftermath2/BTRY/rates"
 
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
 )
 
 func (h *HandlerSuite) TestGetLottery() {
@@ -19,21 +26,53 @@ func (h *HandlerSuite) TestGetLottery() {
 	prizePool := uint64(50000)
 	nextHeight := uint32(145)
 	h.lotteriesMock.On("GetNextHeight").Return(nextHeight, nil)
-	h.betsMock.On("GetPrizePool", nextHeight).Return(prizePool, nil)
+	h.postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+	h.betsMock.On("GetPrizePool", nextHeight, "").Return(prizePool, nil)
+	fiat := rates.Values{"USD": 30}
+	h.ratesMock.On("Convert", prizePool).Return(fiat)
+	h.lndMock.On("Network").Return(config.NetworkSignet)
 
 	h.handler.GetLottery(h.rec, h.req)
 
-	var response lottery.Info
+	var response handler.LotteryResponse
 	err := json.NewDecoder(h.rec.Body).Decode(&response)
 	h.NoError(err)
 
 	h.Equal(http.StatusOK, h.rec.Code)
 
-	expectedCapacity := remoteBalance / lottery.CapacityDivisor
+	expectedCapacity := remoteBalance / lottery.DefaultCapacityDivisor
 	h.Equal(expectedCapacity, response.Capacity)
 
 	h.Equal(prizePool, uint64(response.PrizePool))
 	h.Equal(nextHeight, response.NextHeight)
+	h.Equal(fiat, response.Fiat)
+	h.Equal(config.NetworkSignet, response.Network)
+	h.Equal("lntbs", response.InvoicePrefix)
+	h.Equal(policy.HousePlayExcluded, response.HousePlay)
+}
+
+func (h *HandlerSuite) TestGetLotteryLastTicket() {
+	h.lndMock.On("RemoteBalance", h.req.Context()).Return(int64(500000), nil)
+	h.lotteriesMock.On("GetNextHeight").Return(uint32(145), nil)
+	h.postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+	h.betsMock.On("GetPrizePool", uint32(145), "").Return(uint64(50000), nil)
+	h.ratesMock.On("Convert", uint64(50000)).Return(rates.Values(nil))
+	h.lndMock.On("Network").Return(config.NetworkMainnet)
+
+	db := &db.DB{Bets: h.betsMock, Lotteries: h.lotteriesMock, Postponements: h.postponementsMock}
+	lastTicket := config.LastTicket{Tickets: 10_000, Percentage: 0.5}
+	housePlay := policy.NewHousePlay(config.HousePlay{PublicKeys: []string{housePublicKey}, Allowed: true})
+	handler.New(h.lndMock, db, h.eventStreamerMock, h.auditorMock, h.publisherMock, nil, nil, nil, housePlay, nil, nil, nil, nil, nil, nil,
+		nil, h.ratesMock, nil, nil, lottery.NewPools(nil), lottery.RemoteBalanceCapacity{}, lottery.StatsPrivacy{}, engine.RoundingNearest, lastTicket, config.LNURLPay{}, config.DrawSLO{}, h.reloaderMock,
+		adminConfig).GetLottery(h.rec, h.req)
+
+	var response handler.LotteryResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(&handler.LastTicketBonus{Tickets: 10_000, Percentage: 0.5}, response.LastTicket)
+	h.Equal(policy.HousePlayFlagged, response.HousePlay)
 }
 
 func (h *HandlerSuite) TestGetLotteryError() {
@@ -42,12 +81,189 @@ func (h *HandlerSuite) TestGetLotteryError() {
 
 	h.handler.GetLottery(h.rec, h.req)
 
-	var response handler.ErrorResponse
+	var response apierrors.Response
 	err := json.NewDecoder(h.rec.Body).Decode(&response)
 	h.NoError(err)
 
 	h.Equal(http.StatusInternalServerError, h.rec.Code)
-	h.Equal(expectedErr.Error(), response.Error)
+	h.Equal(expectedErr.Error(), response.Error.Message)
+}
+
+func (h *HandlerSuite) TestGetCommitment() {
+	nextHeight := uint32(289)
+	h.lotteriesMock.On("GetNextHeight").Return(nextHeight, nil)
+	h.lotteriesMock.On("GetCommitment", nextHeight).
+		Return(db.Commitment{Height: nextHeight, Commitment: "commitment", Seed: "seed"}, nil)
+
+	h.handler.GetCommitment(h.rec, h.req)
+
+	var response db.Commitment
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	// The seed of the lottery in progress must not be revealed
+	h.Equal(db.Commitment{Height: nextHeight, Commitment: "commitment"}, response)
+}
+
+func (h *HandlerSuite) TestGetCommitmentRevealed() {
+	height := uint32(145)
+	h.lotteriesMock.On("GetNextHeight").Return(height+144, nil)
+	expected := db.Commitment{Height: height, Commitment: "commitment", Seed: "seed"}
+	h.lotteriesMock.On("GetCommitment", height).Return(expected, nil)
+	h.revealsMock.On("Get", height).Return(db.Reveal{}, db.ErrRevealNotFound)
+	h.req = httptest.NewRequest(http.MethodGet, "/lottery/commitment?height=145", nil)
+
+	h.handler.GetCommitment(h.rec, h.req)
+
+	var response db.Commitment
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(expected, response)
+}
+
+func (h *HandlerSuite) TestGetCommitmentRevealing() {
+	height := uint32(145)
+	h.lotteriesMock.On("GetNextHeight").Return(height+144, nil)
+	commitment := db.Commitment{Height: height, Commitment: "commitment", Seed: "seed"}
+	h.lotteriesMock.On("GetCommitment", height).Return(commitment, nil)
+	h.revealsMock.On("Get", height).Return(db.Reveal{LotteryHeight: height, Tiers: 8, Hidden: 3}, nil)
+	h.req = httptest.NewRequest(http.MethodGet, "/lottery/commitment?height=145", nil)
+
+	h.handler.GetCommitment(h.rec, h.req)
+
+	var response db.Commitment
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Empty(response.Seed)
+}
+
+func (h *HandlerSuite) TestGetCommitmentNotFound() {
+	h.lotteriesMock.On("GetNextHeight").Return(uint32(289), nil)
+	h.lotteriesMock.On("GetCommitment", uint32(1)).Return(db.Commitment{}, db.ErrLotteryNotFound)
+	h.req = httptest.NewRequest(http.MethodGet, "/lottery/commitment?height=1", nil)
+
+	h.handler.GetCommitment(h.rec, h.req)
+
+	h.Equal(http.StatusNotFound, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestGetBetArchive() {
+	height := uint32(145)
+	bets := []db.Bet{
+		{PublicKey: "a", FirstTicket: 1, Index: 100, Tickets: 100},
+		{PublicKey: "b", FirstTicket: 101, Index: 150, Tickets: 50},
+	}
+	commitment := db.Commitment{Height: height, Commitment: "commitment", Seed: "seed", BlockHash: "hash"}
+	h.betArchivesMock.On("Get", height).Return(bets, nil)
+	h.revealsMock.On("Get", height).Return(db.Reveal{}, db.ErrRevealNotFound)
+	h.lotteriesMock.On("GetCommitment", height).Return(commitment, nil)
+	tiers := []db.Tiers{{Pool: "", Participants: 2, Tiers: 3}}
+	h.lotteriesMock.On("ListTiers", height).Return(tiers, nil)
+	h.req = httptest.NewRequest(http.MethodGet, "/lottery/archive?height=145", nil)
+
+	h.handler.GetBetArchive(h.rec, h.req)
+
+	var response handler.BetArchiveResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(handler.BetArchiveResponse{Commitment: commitment, Bets: bets, Tiers: tiers}, response)
+}
+
+func (h *HandlerSuite) TestGetBetArchiveNotFound() {
+	h.betArchivesMock.On("Get", uint32(145)).Return([]db.Bet(nil), db.ErrBetArchiveNotFound)
+	h.revealsMock.On("Get", uint32(145)).Return(db.Reveal{}, db.ErrRevealNotFound)
+	h.req = httptest.NewRequest(http.MethodGet, "/lottery/archive?height=145", nil)
+
+	h.handler.GetBetArchive(h.rec, h.req)
+
+	h.Equal(http.StatusNotFound, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestGetBetArchiveRevealing() {
+	h.revealsMock.On("Get", uint32(145)).Return(db.Reveal{LotteryHeight: 145, Tiers: 8, Hidden: 1}, nil)
+	h.req = httptest.NewRequest(http.MethodGet, "/lottery/archive?height=145", nil)
+
+	h.handler.GetBetArchive(h.rec, h.req)
+
+	h.Equal(http.StatusConflict, h.rec.Code)
+	h.betArchivesMock.AssertNotCalled(h.T(), "Get", mock.Anything)
+}
+
+func (h *HandlerSuite) TestGetDrawCard() {
+	round := db.RoundStats{Height: 144, PrizePool: 10_000, Players: 3, Winners: 1}
+	h.statsMock.On("GetRound", uint32(144)).Return(round, nil)
+	h.winnersMock.On("List", uint32(144)).Return([]db.Winner{{PublicKey: "1", Prize: 5_000}}, nil)
+	h.req = httptest.NewRequest(http.MethodGet, "/lottery/card?height=144", nil)
+
+	h.handler.GetDrawCard(h.rec, h.req)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal("image/svg+xml", h.rec.Header().Get("Content-Type"))
+	h.Contains(h.rec.Body.String(), "Lottery #144 drawn")
+	h.Contains(h.rec.Body.String(), "10,000 sats")
+}
+
+func (h *HandlerSuite) TestGetDrawCardLast() {
+	round := db.RoundStats{Height: 288, PrizePool: 2_000, Players: 2, Winners: 1}
+	h.statsMock.On("ListRounds", uint64(0), uint64(1), true).Return([]db.RoundStats{round}, nil)
+	h.winnersMock.On("List", uint32(288)).Return([]db.Winner{{PublicKey: "1", Prize: 1_500}}, nil)
+	h.req = httptest.NewRequest(http.MethodGet, "/lottery/card", nil)
+
+	h.handler.GetDrawCard(h.rec, h.req)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Contains(h.rec.Body.String(), "Lottery #288 drawn")
+	h.Contains(h.rec.Body.String(), "1,500 sats")
+}
+
+func (h *HandlerSuite) TestGetDrawCardNotFound() {
+	h.statsMock.On("GetRound", uint32(145)).Return(db.RoundStats{}, db.ErrRoundNotFound)
+	h.req = httptest.NewRequest(http.MethodGet, "/lottery/card?height=145", nil)
+
+	h.handler.GetDrawCard(h.rec, h.req)
+
+	h.Equal(http.StatusNotFound, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestGetFairness() {
+	reports := []db.FairnessReport{
+		{
+			LotteryHeight: 288,
+			Players:       2,
+			Tickets:       150,
+			Gini:          0.1666,
+			WinningPositions: []db.WinningPosition{
+				{Ticket: 120, PrizePool: 150, Position: 0.8},
+			},
+		},
+	}
+	h.fairnessMock.On("List", uint64(144), uint64(10), true).Return(reports, nil)
+	h.req = httptest.NewRequest(http.MethodGet, "/lottery/fairness?offset=144&limit=10&reverse=true", nil)
+
+	h.handler.GetFairness(h.rec, h.req)
+
+	var response handler.FairnessResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(reports, response.Reports)
+}
+
+func (h *HandlerSuite) TestGetFairnessInvalidReverse() {
+	h.req = httptest.NewRequest(http.MethodGet, "/lottery/fairness?reverse=maybe", nil)
+
+	h.handler.GetFairness(h.rec, h.req)
+
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+	h.fairnessMock.AssertNotCalled(h.T(), "List", mock.Anything, mock.Anything, mock.Anything)
 }
 
 func (h *HandlerSuite) TestListHeights() {
@@ -127,10 +343,10 @@ func (h *HandlerSuite) TestGetHeightsInternalError() {
 
 	h.handler.GetHeights(h.rec, h.req)
 
-	var response handler.ErrorResponse
+	var response apierrors.Response
 	err := json.NewDecoder(h.rec.Body).Decode(&response)
 	h.NoError(err)
 
 	h.Equal(http.StatusInternalServerError, h.rec.Code)
-	h.Equal(expectedErr.Error(), response.Error)
+	h.Equal(expectedErr.Error(), response.Error.Message)
 }
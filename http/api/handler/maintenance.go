@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/pkg/errors"
+)
+
+// GetMaintenance responds with the maintenance window scheduled, if any.
+func (h *Handler) GetMaintenance(w http.ResponseWriter, r *http.Request) {
+	sendResponse(w, http.StatusOK, h.maintenance.Status())
+}
+
+// ScheduleMaintenance sets a maintenance window between the from and until unix timestamps, it
+// starts immediately if from is not specified.
+//
+// The window is replaced by the configured one on the next configuration reload.
+func (h *Handler) ScheduleMaintenance(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	from, err := parseIntParam(query, "from", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	until, err := parseIntParam(query, "until", true)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var fromTime time.Time
+	if from != 0 {
+		fromTime = time.Unix(int64(from), 0)
+	}
+
+	if err := h.maintenance.Schedule(fromTime, time.Unix(int64(until), 0)); err != nil {
+		if errors.Is(err, policy.ErrInvalidMaintenanceWindow) {
+			sendError(w, http.StatusBadRequest, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, h.maintenance.Status())
+}
+
+// EndMaintenance finishes the maintenance in progress or cancels the scheduled one.
+func (h *Handler) EndMaintenance(w http.ResponseWriter, r *http.Request) {
+	h.maintenance.End()
+	sendResponse(w, http.StatusOK, h.maintenance.Status())
+}
@@ -0,0 +1,85 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/aftermath2/BTRY/policy"
+)
+
+func (h *HandlerSuite) TestScheduleMaintenance() {
+	from := time.Now().Add(time.Hour).Unix()
+	until := from + 3600
+
+	url := fmt.Sprintf("/admin/maintenance?from=%d&until=%d", from, until)
+	h.req = httptest.NewRequest(http.MethodPost, url, nil)
+	h.handler.ScheduleMaintenance(h.rec, h.req)
+
+	var response policy.MaintenanceStatus
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	expected := policy.MaintenanceStatus{From: from, Until: until, Active: false}
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(expected, response)
+	h.Equal(expected, h.maintenance.Status())
+}
+
+func (h *HandlerSuite) TestScheduleMaintenanceInvalid() {
+	cases := []struct {
+		desc string
+		url  string
+	}{
+		{
+			desc: "Missing until",
+			url:  "/admin/maintenance",
+		},
+		{
+			desc: "Invalid until",
+			url:  "/admin/maintenance?until=tomorrow",
+		},
+		{
+			desc: "Ends before it starts",
+			url:  "/admin/maintenance?from=2000&until=1000",
+		},
+		{
+			desc: "Already ended",
+			url:  "/admin/maintenance?until=1000",
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			rec := httptest.NewRecorder()
+			h.handler.ScheduleMaintenance(rec, httptest.NewRequest(http.MethodPost, tc.url, nil))
+
+			h.Equal(http.StatusBadRequest, rec.Code)
+		})
+	}
+}
+
+func (h *HandlerSuite) TestEndMaintenance() {
+	err := h.maintenance.Schedule(time.Time{}, time.Now().Add(time.Hour))
+	h.NoError(err)
+
+	rec := httptest.NewRecorder()
+	h.handler.GetMaintenance(rec, httptest.NewRequest(http.MethodGet, "/maintenance", nil))
+
+	var response policy.MaintenanceStatus
+	err = json.NewDecoder(rec.Body).Decode(&response)
+	h.NoError(err)
+	h.True(response.Active)
+
+	h.req = httptest.NewRequest(http.MethodDelete, "/admin/maintenance", nil)
+	h.handler.EndMaintenance(h.rec, h.req)
+
+	var endResponse policy.MaintenanceStatus
+	err = json.NewDecoder(h.rec.Body).Decode(&endResponse)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(policy.MaintenanceStatus{}, endResponse)
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+	"github.com/aftermath2/BTRY/lottery"
+
+	"github.com/pkg/errors"
+)
+
+// migrationTokenExpiry is how long a migration token can be used to import a round.
+const migrationTokenExpiry = time.Hour
+
+// MigrationTokenResponse is the response schema of the POST /admin/migration/token endpoint. The
+// token is only returned once.
+type MigrationTokenResponse struct {
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// ExportRoundRequest is the request body of the POST /admin/migration/export endpoint.
+type ExportRoundRequest struct {
+	// Token is the one issued by the destination instance
+	Token string `json:"token"`
+	// Height defaults to the next lottery
+	Height uint32 `json:"height,omitempty"`
+}
+
+// ImportRoundRequest is the request body of the POST /admin/migration/import endpoint.
+type ImportRoundRequest struct {
+	Token  string                  `json:"token"`
+	Bundle lottery.MigrationBundle `json:"bundle"`
+}
+
+// ImportRoundResponse is the response schema of the POST /admin/migration/import endpoint.
+type ImportRoundResponse struct {
+	Height uint32 `json:"height"`
+	Bets   int    `json:"bets"`
+}
+
+// CreateMigrationToken responds with a one-time token that authorizes importing a round exported
+// by another instance into this one.
+func (h *Handler) CreateMigrationToken(w http.ResponseWriter, r *http.Request) {
+	tokenBytes, err := randomBytes(32)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	expiresAt := time.Now().Add(migrationTokenExpiry).Unix()
+	if err := h.db.Migrations.AddToken(hashSecret(token), expiresAt); err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, MigrationTokenResponse{Token: token, ExpiresAt: expiresAt})
+}
+
+// ExportRound responds with the bets and the server seed of a lottery that hasn't been drawn yet,
+// checksummed with the token of the destination instance.
+//
+// Bets must not change while the round is moved, so it's only exported during maintenance.
+func (h *Handler) ExportRound(w http.ResponseWriter, r *http.Request) {
+	var req ExportRoundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, errors.Wrap(err, "decoding request body"))
+		return
+	}
+
+	if req.Token == "" {
+		sendError(w, http.StatusBadRequest, errors.New("token is required"))
+		return
+	}
+
+	if !h.maintenance.Status().Active {
+		sendError(w, http.StatusConflict, errors.New("rounds can only be exported during maintenance"))
+		return
+	}
+
+	operator, ok := middleware.OperatorFromContext(r.Context())
+	if !ok {
+		sendError(w, http.StatusUnauthorized, errors.New("operator not authenticated"))
+		return
+	}
+
+	height := req.Height
+	if height == 0 {
+		nextHeight, err := h.db.Lotteries.GetNextHeight()
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+		height = nextHeight
+	}
+
+	round, err := h.db.Migrations.Export(height)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrLotteryNotFound):
+			sendError(w, http.StatusNotFound, err)
+		case errors.Is(err, db.ErrLotteryDrawn):
+			sendError(w, http.StatusConflict, err)
+		default:
+			sendError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	bundle, err := lottery.NewMigrationBundle(round, req.Token)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.auditor.Record(audit.RoundExported, map[string]any{
+		"operator_id": operator.ID,
+		"height":      round.Height,
+		"bets":        len(round.Bets),
+	})
+
+	sendResponse(w, http.StatusOK, bundle)
+}
+
+// ImportRound stores a round exported by another instance. The bundle checksum is verified with
+// the token, which can only be used once.
+func (h *Handler) ImportRound(w http.ResponseWriter, r *http.Request) {
+	var req ImportRoundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, errors.Wrap(err, "decoding request body"))
+		return
+	}
+
+	if !h.maintenance.Status().Active {
+		sendError(w, http.StatusConflict, errors.New("rounds can only be imported during maintenance"))
+		return
+	}
+
+	if err := req.Bundle.Verify(req.Token); err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	operator, ok := middleware.OperatorFromContext(r.Context())
+	if !ok {
+		sendError(w, http.StatusUnauthorized, errors.New("operator not authenticated"))
+		return
+	}
+
+	round := req.Bundle.Round
+	if err := h.db.Migrations.Import(round, hashSecret(req.Token), time.Now().Unix()); err != nil {
+		switch {
+		case errors.Is(err, db.ErrInvalidMigrationToken):
+			sendError(w, http.StatusForbidden, err)
+		case errors.Is(err, db.ErrRoundNotEmpty):
+			sendError(w, http.StatusConflict, err)
+		default:
+			sendError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	h.auditor.Record(audit.RoundImported, map[string]any{
+		"operator_id": operator.ID,
+		"height":      round.Height,
+		"bets":        len(round.Bets),
+		"checksum":    req.Bundle.Checksum,
+	})
+
+	sendResponse(w, http.StatusOK, ImportRoundResponse{Height: round.Height, Bets: len(round.Bets)})
+}
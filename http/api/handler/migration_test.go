@@ -0,0 +1,147 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/lottery/engine"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func (h *HandlerSuite) TestCreateMigrationToken() {
+	h.migrationsMock.On("AddToken", mock.Anything, mock.Anything).Return(nil)
+
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/migration/token", nil)
+	h.handler.CreateMigrationToken(h.rec, h.req)
+
+	var response handler.MigrationTokenResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Len(response.Token, 64)
+	h.Greater(response.ExpiresAt, time.Now().Unix())
+	h.migrationsMock.AssertNotCalled(h.T(), "AddToken", response.Token, mock.Anything)
+}
+
+func (h *HandlerSuite) TestExportRound() {
+	round := migrationRound()
+	h.NoError(h.maintenance.Schedule(time.Time{}, time.Now().Add(time.Hour)))
+	h.lotteriesMock.On("GetNextHeight").Return(round.Height, nil)
+	h.migrationsMock.On("Export", round.Height).Return(round, nil)
+	h.auditorMock.On("Record", audit.RoundExported, mock.Anything)
+
+	body := `{"token":"token"}`
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/migration/export", strings.NewReader(body))
+	h.req = h.req.WithContext(withOperator(h.req.Context(), db.Operator{ID: 1, Role: db.RoleOwner}))
+	h.handler.ExportRound(h.rec, h.req)
+
+	var response lottery.MigrationBundle
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(round, response.Round)
+	h.NoError(response.Verify("token"))
+}
+
+func (h *HandlerSuite) TestExportRoundOutsideMaintenance() {
+	body := `{"token":"token"}`
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/migration/export", strings.NewReader(body))
+	h.req = h.req.WithContext(withOperator(h.req.Context(), db.Operator{ID: 1, Role: db.RoleOwner}))
+	h.handler.ExportRound(h.rec, h.req)
+
+	h.Equal(http.StatusConflict, h.rec.Code)
+	h.migrationsMock.AssertNotCalled(h.T(), "Export", mock.Anything)
+}
+
+func (h *HandlerSuite) TestImportRound() {
+	round := migrationRound()
+	bundle, err := lottery.NewMigrationBundle(round, "token")
+	h.NoError(err)
+
+	h.NoError(h.maintenance.Schedule(time.Time{}, time.Now().Add(time.Hour)))
+	h.migrationsMock.On("Import", round, mock.Anything, mock.Anything).Return(nil)
+	h.auditorMock.On("Record", audit.RoundImported, mock.Anything)
+
+	body, err := json.Marshal(handler.ImportRoundRequest{Token: "token", Bundle: bundle})
+	h.NoError(err)
+	h.req = httptest.NewRequest(http.MethodPost, "/admin/migration/import", bytes.NewReader(body))
+	h.req = h.req.WithContext(withOperator(h.req.Context(), db.Operator{ID: 1, Role: db.RoleOwner}))
+	h.handler.ImportRound(h.rec, h.req)
+
+	var response handler.ImportRoundResponse
+	err = json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(handler.ImportRoundResponse{Height: round.Height, Bets: 1}, response)
+}
+
+func (h *HandlerSuite) TestImportRoundInvalid() {
+	round := migrationRound()
+	bundle, err := lottery.NewMigrationBundle(round, "token")
+	h.NoError(err)
+	h.NoError(h.maintenance.Schedule(time.Time{}, time.Now().Add(time.Hour)))
+
+	cases := []struct {
+		desc         string
+		token        string
+		importErr    error
+		expectedCode int
+	}{
+		{
+			desc:         "Invalid checksum",
+			token:        "other",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			desc:         "Invalid token",
+			token:        "token",
+			importErr:    db.ErrInvalidMigrationToken,
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.rec = httptest.NewRecorder()
+			if tc.importErr != nil {
+				h.migrationsMock.On("Import", round, mock.Anything, mock.Anything).Return(tc.importErr).Once()
+			}
+
+			body, err := json.Marshal(handler.ImportRoundRequest{Token: tc.token, Bundle: bundle})
+			h.NoError(err)
+			h.req = httptest.NewRequest(http.MethodPost, "/admin/migration/import", bytes.NewReader(body))
+			h.req = h.req.WithContext(withOperator(h.req.Context(), db.Operator{ID: 1, Role: db.RoleOwner}))
+			h.handler.ImportRound(h.rec, h.req)
+
+			h.Equal(tc.expectedCode, h.rec.Code)
+		})
+	}
+
+	h.auditorMock.AssertNotCalled(h.T(), "Record", audit.RoundImported, mock.Anything)
+}
+
+func migrationRound() db.RoundExport {
+	seed := make([]byte, 32)
+	return db.RoundExport{
+		Height:     144,
+		Seed:       hex.EncodeToString(seed),
+		Commitment: hex.EncodeToString(engine.Commitment(seed)),
+		Bets: []db.MigratedBet{
+			{PublicKey: validPublicKey, PaymentHash: "hash", FirstTicket: 1, Index: 100, Tickets: 100},
+		},
+		Exposure: []db.MigratedExposure{},
+	}
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/pkg/errors"
+)
+
+// deliveriesHistory is the number of latest deliveries listed in the notifications settings.
+const deliveriesHistory = 20
+
+// NotificationsResponse is the response schema of the GET /notifications endpoint.
+type NotificationsResponse struct {
+	Nostr string `json:"nostr,omitempty"`
+	// Deliveries are the latest notifications sent to the player, one per service
+	Deliveries []db.NotificationDelivery `json:"deliveries,omitempty"`
+	Telegram   bool                      `json:"telegram,omitempty"`
+	// Digest is true if the player receives the result of every lottery they bet on
+	Digest bool `json:"digest,omitempty"`
+}
+
+// NostrNotificationsResponse is the response schema of the /notifications/nostr endpoints.
+type NostrNotificationsResponse struct {
+	Success bool `json:"success,omitempty"`
+}
+
+// DigestNotificationsResponse is the response schema of the /notifications/digest endpoints.
+type DigestNotificationsResponse struct {
+	Success bool `json:"success,omitempty"`
+}
+
+// GetNotifications responds with the services the public key enabled notifications on and the
+// latest deliveries through them. The nostr key is encoded as an npub.
+func (h *Handler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := getAuthPublicKey(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var resp NotificationsResponse
+
+	_, err = h.db.Notifications.GetChatID(publicKey)
+	if err != nil && !errors.Is(err, db.ErrNoChatID) {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	resp.Telegram = err == nil
+
+	nostrKey, err := h.db.Notifications.GetNostrKey(publicKey)
+	if err != nil && !errors.Is(err, db.ErrNoNostrKey) {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp.Digest, err = h.db.Notifications.GetDigest(publicKey)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp.Deliveries, err = h.db.Notifications.ListPlayerDeliveries(publicKey, 0, deliveriesHistory)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if nostrKey != "" {
+		npub, err := nip19.EncodePublicKey(nostrKey)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+		resp.Nostr = npub
+	}
+
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// SetNostrNotifications links a nostr npub to the public key, prize notifications are sent to it
+// as encrypted direct messages.
+func (h *Handler) SetNostrNotifications(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := getSignedPublicKey(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	prefix, value, err := nip19.Decode(r.URL.Query().Get("npub"))
+	if err != nil || prefix != "npub" || !nostr.IsValidPublicKey(value.(string)) {
+		sendError(w, http.StatusBadRequest, errors.New("invalid npub"))
+		return
+	}
+
+	if err := h.db.Notifications.SetNostrKey(publicKey, value.(string)); err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := NostrNotificationsResponse{Success: true}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// DeleteNostrNotifications unlinks the nostr npub from the public key.
+func (h *Handler) DeleteNostrNotifications(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := getSignedPublicKey(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.db.Notifications.DeleteNostrKey(publicKey); err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := NostrNotificationsResponse{Success: true}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// SetDigestNotifications opts the public key in to the draw digests, which notify the result of
+// every lottery it bets on through the services enabled.
+func (h *Handler) SetDigestNotifications(w http.ResponseWriter, r *http.Request) {
+	h.setDigest(w, r, true)
+}
+
+// DeleteDigestNotifications opts the public key out of the draw digests.
+func (h *Handler) DeleteDigestNotifications(w http.ResponseWriter, r *http.Request) {
+	h.setDigest(w, r, false)
+}
+
+func (h *Handler) setDigest(w http.ResponseWriter, r *http.Request, enabled bool) {
+	publicKey, err := getSignedPublicKey(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.db.Notifications.SetDigest(publicKey, enabled); err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := DigestNotificationsResponse{Success: true}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// GetNotificationDeliveries responds with the delivery status of the telegram messages, the newest
+// first. They can be filtered by status: pending, sent or failed.
+func (h *Handler) GetNotificationDeliveries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	status := query.Get("status")
+	switch status {
+	case "", db.DeliveryPending, db.DeliverySent, db.DeliveryFailed:
+	default:
+		sendError(w, http.StatusBadRequest, errors.Errorf("invalid status %q", status))
+		return
+	}
+
+	offset, err := parseIntParam(query, "offset", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, err := parseIntParam(query, "limit", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	deliveries, err := h.db.Notifications.ListDeliveries(status, offset, limit)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, deliveries)
+}
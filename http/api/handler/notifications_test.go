@@ -0,0 +1,211 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+const nostrKey = "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+
+func (h *HandlerSuite) TestGetNotifications() {
+	h.req = httptest.NewRequest(http.MethodGet, "/notifications", nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.notificationsMock.On("GetChatID", validPublicKey).Return(int64(0), db.ErrNoChatID)
+	h.notificationsMock.On("GetNostrKey", validPublicKey).Return(nostrKey, nil)
+	h.notificationsMock.On("GetDigest", validPublicKey).Return(true, nil)
+	deliveries := []db.NotificationDelivery{
+		{
+			ID:        2,
+			PublicKey: validPublicKey,
+			Channel:   db.ChannelNostr,
+			Status:    db.DeliverySent,
+			Message:   "Congratulations!",
+			Attempts:  1,
+			CreatedAt: 1231006505,
+			UpdatedAt: 1231006505,
+		},
+		{
+			ID:         1,
+			PublicKey:  validPublicKey,
+			Channel:    db.ChannelTelegram,
+			Status:     db.DeliveryFailed,
+			Error:      "chat not found",
+			Message:    "Congratulations!",
+			Attempts:   2,
+			RetryUntil: 1231438505,
+			CreatedAt:  1231006505,
+			UpdatedAt:  1231007105,
+		},
+	}
+	h.notificationsMock.On("ListPlayerDeliveries", validPublicKey, uint64(0), uint64(20)).
+		Return(deliveries, nil)
+
+	h.handler.GetNotifications(h.rec, h.req)
+
+	var response handler.NotificationsResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	npub, err := nip19.EncodePublicKey(nostrKey)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.False(response.Telegram)
+	h.True(response.Digest)
+	h.Equal(npub, response.Nostr)
+	h.Equal(deliveries, response.Deliveries)
+}
+
+func (h *HandlerSuite) TestGetNotificationsError() {
+	h.req = httptest.NewRequest(http.MethodGet, "/notifications", nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.notificationsMock.On("GetChatID", validPublicKey).Return(int64(1), nil)
+	h.notificationsMock.On("GetNostrKey", validPublicKey).Return("", errors.New("test"))
+
+	h.handler.GetNotifications(h.rec, h.req)
+
+	h.Equal(http.StatusInternalServerError, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestSetNostrNotifications() {
+	npub, err := nip19.EncodePublicKey(nostrKey)
+	h.NoError(err)
+
+	h.req = httptest.NewRequest(http.MethodPost,
+		"/notifications/nostr?npub="+npub+"&signature="+validSignature, nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.notificationsMock.On("SetNostrKey", validPublicKey, nostrKey).Return(nil)
+
+	h.handler.SetNostrNotifications(h.rec, h.req)
+
+	var response handler.NostrNotificationsResponse
+	err = json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.True(response.Success)
+	h.notificationsMock.AssertExpectations(h.T())
+}
+
+func (h *HandlerSuite) TestSetNostrNotificationsInvalid() {
+	nsec, err := nip19.EncodePrivateKey(nostrKey)
+	h.NoError(err)
+
+	cases := []struct {
+		desc  string
+		query string
+	}{
+		{
+			desc:  "Missing signature",
+			query: "?npub=npub1",
+		},
+		{
+			desc:  "Invalid npub",
+			query: "?npub=npub1abc&signature=" + validSignature,
+		},
+		{
+			desc:  "Private key",
+			query: "?npub=" + nsec + "&signature=" + validSignature,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.rec = httptest.NewRecorder()
+			h.req = httptest.NewRequest(http.MethodPost, "/notifications/nostr"+tc.query, nil)
+			h.SetAuthorizationKey(validPublicKey)
+
+			h.handler.SetNostrNotifications(h.rec, h.req)
+
+			h.Equal(http.StatusBadRequest, h.rec.Code)
+		})
+	}
+}
+
+func (h *HandlerSuite) TestDeleteNostrNotifications() {
+	h.req = httptest.NewRequest(http.MethodDelete, "/notifications/nostr?signature="+validSignature, nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.notificationsMock.On("DeleteNostrKey", validPublicKey).Return(nil)
+
+	h.handler.DeleteNostrNotifications(h.rec, h.req)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.notificationsMock.AssertExpectations(h.T())
+}
+
+func (h *HandlerSuite) TestSetDigestNotifications() {
+	h.req = httptest.NewRequest(http.MethodPost, "/notifications/digest?signature="+validSignature, nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.notificationsMock.On("SetDigest", validPublicKey, true).Return(nil)
+
+	h.handler.SetDigestNotifications(h.rec, h.req)
+
+	var response handler.DigestNotificationsResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.True(response.Success)
+	h.notificationsMock.AssertExpectations(h.T())
+}
+
+func (h *HandlerSuite) TestDeleteDigestNotifications() {
+	h.req = httptest.NewRequest(http.MethodDelete, "/notifications/digest?signature="+validSignature, nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.notificationsMock.On("SetDigest", validPublicKey, false).Return(nil)
+
+	h.handler.DeleteDigestNotifications(h.rec, h.req)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.notificationsMock.AssertExpectations(h.T())
+}
+
+func (h *HandlerSuite) TestSetDigestNotificationsUnsigned() {
+	h.req = httptest.NewRequest(http.MethodPost, "/notifications/digest", nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.handler.SetDigestNotifications(h.rec, h.req)
+
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+	h.notificationsMock.AssertNotCalled(h.T(), "SetDigest", validPublicKey, true)
+}
+
+func (h *HandlerSuite) TestGetNotificationDeliveries() {
+	deliveries := []db.NotificationDelivery{
+		{ID: 2, PublicKey: validPublicKey, Status: db.DeliveryFailed, Attempts: 3,
+			Error: "Too Many Requests: retry after 5", CreatedAt: 1_700_000_000, UpdatedAt: 1_700_000_010},
+	}
+	h.notificationsMock.On("ListDeliveries", db.DeliveryFailed, uint64(0), uint64(10)).Return(deliveries, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/notifications/deliveries?status=failed&limit=10", nil)
+	h.handler.GetNotificationDeliveries(h.rec, h.req)
+
+	var response []db.NotificationDelivery
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(deliveries, response)
+}
+
+func (h *HandlerSuite) TestGetNotificationDeliveriesInvalidStatus() {
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/notifications/deliveries?status=lost", nil)
+	h.handler.GetNotificationDeliveries(h.rec, h.req)
+
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+	h.notificationsMock.AssertNotCalled(h.T(), "ListDeliveries", mock.Anything, mock.Anything, mock.Anything)
+}
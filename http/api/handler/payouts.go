@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// PayoutsResponse is the response schema of the /payouts endpoint.
+type PayoutsResponse struct {
+	Payouts []db.Payout `json:"payouts"`
+}
+
+// GetPayouts responds with the withdrawals of the user and the status of their payments.
+func (h *Handler) GetPayouts(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := getAuthPublicKey(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	query := r.URL.Query()
+
+	offset, err := parseIntParam(query, "offset", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, err := parseIntParam(query, "limit", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	reverse := false
+	reverseStr := query.Get("reverse")
+	if reverseStr != "" {
+		v, err := strconv.ParseBool(reverseStr)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, errors.Wrap(err, "invalid reverse parameter"))
+			return
+		}
+		reverse = v
+	}
+
+	payouts, err := h.db.Payouts.List(publicKey, offset, limit, reverse)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, PayoutsResponse{Payouts: payouts})
+}
+
+// ListScheduledPayouts responds with the automatic payouts deferred until the routing fees go
+// down, the closest to expire first.
+func (h *Handler) ListScheduledPayouts(w http.ResponseWriter, r *http.Request) {
+	payouts, err := h.db.Scheduled.List()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, payouts)
+}
+
+// ListFeeDistributions responds with the shares of the lotteries fee forwarded to the fee
+// destinations, the newest first.
+func (h *Handler) ListFeeDistributions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	offset, err := parseIntParam(query, "offset", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, err := parseIntParam(query, "limit", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	distributions, err := h.db.Fees.List(offset, limit)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, distributions)
+}
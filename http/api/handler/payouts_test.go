@@ -0,0 +1,112 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+func (h *HandlerSuite) TestGetPayouts() {
+	h.SetDefaultAuthorizationKey()
+	publicKey := "e68b99fc5f60c971926fdc3a3af38ccf67e6f4306ab1c388735533e7c5dcc749"
+	payouts := []db.Payout{
+		{
+			ID:            2,
+			PublicKey:     publicKey,
+			PaymentHash:   "hash2",
+			Amount:        505,
+			Status:        db.PayoutFailed,
+			FailureReason: "FAILURE_REASON_NO_ROUTE",
+		},
+		{
+			ID:          1,
+			PublicKey:   publicKey,
+			PaymentHash: "hash1",
+			Amount:      1_010,
+			Status:      db.PayoutSucceeded,
+			Fee:         3,
+			Preimage:    "preimage",
+		},
+	}
+	h.payoutsMock.On("List", publicKey, uint64(0), uint64(10), true).Return(payouts, nil)
+
+	h.req.URL.RawQuery = "limit=10&reverse=true"
+	h.handler.GetPayouts(h.rec, h.req)
+
+	var response handler.PayoutsResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(payouts, response.Payouts)
+}
+
+func (h *HandlerSuite) TestGetPayoutsInvalidReverse() {
+	h.SetDefaultAuthorizationKey()
+
+	h.req.URL.RawQuery = "reverse=maybe"
+	h.handler.GetPayouts(h.rec, h.req)
+
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+	h.payoutsMock.AssertNotCalled(h.T(), "List", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestListScheduledPayouts() {
+	payouts := []db.ScheduledPayout{
+		{ID: 1, PublicKey: validPublicKey, Amount: 20_000, Deadline: 900_720, EstimatedFee: 150, Checks: 3},
+	}
+	h.scheduledMock.On("List").Return(payouts, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/payouts/scheduled", nil)
+	h.handler.ListScheduledPayouts(h.rec, h.req)
+
+	var response []db.ScheduledPayout
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(payouts, response)
+}
+
+func (h *HandlerSuite) TestListScheduledPayoutsError() {
+	h.scheduledMock.On("List").Return([]db.ScheduledPayout(nil), errors.New("test"))
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/payouts/scheduled", nil)
+	h.handler.ListScheduledPayouts(h.rec, h.req)
+
+	h.Equal(http.StatusInternalServerError, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestListFeeDistributions() {
+	distributions := []db.FeeDistribution{
+		{ID: 2, LotteryHeight: 288, Destination: "charity", Address: "charity@example.com", Amount: 300},
+		{ID: 1, LotteryHeight: 144, Destination: "charity", Address: "charity@example.com", Amount: 200,
+			Preimage: "preimage", PaidAt: 1_700_000_000},
+	}
+	h.feesMock.On("List", uint64(3), uint64(2)).Return(distributions, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/fees?offset=3&limit=2", nil)
+	h.handler.ListFeeDistributions(h.rec, h.req)
+
+	var response []db.FeeDistribution
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(distributions, response)
+}
+
+func (h *HandlerSuite) TestListFeeDistributionsError() {
+	h.feesMock.On("List", uint64(0), uint64(0)).Return([]db.FeeDistribution(nil), errors.New("test"))
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/fees", nil)
+	h.handler.ListFeeDistributions(h.rec, h.req)
+
+	h.Equal(http.StatusInternalServerError, h.rec.Code)
+}
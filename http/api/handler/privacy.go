@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/policy"
+)
+
+// GetPrivacy responds with how the public key appears in the public winners lists.
+func (h *Handler) GetPrivacy(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := getAuthPublicKey(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	privacy, err := h.db.Privacy.Get(publicKey)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, privacy)
+}
+
+// SetPrivacy changes how the public key appears in the public winners lists: with the full public
+// key, truncated, with an alias or hidden.
+func (h *Handler) SetPrivacy(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := getSignedPublicKey(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	query := r.URL.Query()
+	privacy := db.Privacy{
+		Display: query.Get("display"),
+		Alias:   query.Get("alias"),
+	}
+	if err := policy.ValidatePrivacy(privacy); err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.db.Privacy.Set(publicKey, privacy); err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, privacy)
+}
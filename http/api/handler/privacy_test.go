@@ -0,0 +1,81 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aftermath2/BTRY/db"
+)
+
+func (h *HandlerSuite) TestGetPrivacy() {
+	h.req = httptest.NewRequest(http.MethodGet, "/privacy", nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	privacy := db.Privacy{Display: db.DisplayTruncated}
+	h.privacyMock.On("Get", validPublicKey).Return(privacy, nil)
+
+	h.handler.GetPrivacy(h.rec, h.req)
+
+	var response db.Privacy
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(privacy, response)
+}
+
+func (h *HandlerSuite) TestSetPrivacy() {
+	h.req = httptest.NewRequest(http.MethodPost,
+		"/privacy?display=alias&alias=lucky&signature="+validSignature, nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	privacy := db.Privacy{Display: db.DisplayAlias, Alias: "lucky"}
+	h.privacyMock.On("Set", validPublicKey, privacy).Return(nil)
+
+	h.handler.SetPrivacy(h.rec, h.req)
+
+	var response db.Privacy
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(privacy, response)
+}
+
+func (h *HandlerSuite) TestSetPrivacyInvalid() {
+	cases := []struct {
+		desc  string
+		query string
+	}{
+		{
+			desc:  "Missing signature",
+			query: "?display=hidden",
+		},
+		{
+			desc:  "Invalid display",
+			query: "?display=blurred&signature=" + validSignature,
+		},
+		{
+			desc:  "Alias without alias display",
+			query: "?display=full&alias=lucky&signature=" + validSignature,
+		},
+		{
+			desc:  "Missing alias",
+			query: "?display=alias&signature=" + validSignature,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.rec = httptest.NewRecorder()
+			h.req = httptest.NewRequest(http.MethodPost, "/privacy"+tc.query, nil)
+			h.SetAuthorizationKey(validPublicKey)
+
+			h.handler.SetPrivacy(h.rec, h.req)
+
+			h.Equal(http.StatusBadRequest, h.rec.Code)
+		})
+	}
+	h.privacyMock.AssertNotCalled(h.T(), "Set")
+}
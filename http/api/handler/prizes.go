@@ -1,10 +1,33 @@
 package handler
 
-import "net/http"
+import (
+	"net/http"
 
-// GetPrizesResponse contains a number representing a user's total prizes.
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/rates"
+
+	"github.com/pkg/errors"
+)
+
+// GetPrizesResponse contains a number representing a user's total prizes and the balance left to
+// claim of each of them.
 type GetPrizesResponse struct {
-	Prizes uint64 `json:"prizes"`
+	Fiat      rates.Values `json:"fiat,omitempty"`
+	Claimable []db.Prize   `json:"claimable"`
+	Prizes    uint64       `json:"prizes"`
+}
+
+// PrizesAtRiskResponse is the response schema of the /admin/prizes/at-risk endpoint.
+type PrizesAtRiskResponse struct {
+	lottery.AtRisk
+	Height uint32 `json:"height"`
+}
+
+// PrizesAgingResponse is the response schema of the /admin/prizes/aging endpoint.
+type PrizesAgingResponse struct {
+	lottery.Aging
+	Height uint32 `json:"height"`
 }
 
 // GetPrizes returns a public key's prizes.
@@ -15,14 +38,71 @@ func (h *Handler) GetPrizes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.sendPrizes(w, publicKey)
+}
+
+// sendPrizes responds with the prizes of the public key.
+func (h *Handler) sendPrizes(w http.ResponseWriter, publicKey string) {
 	prizes, err := h.db.Prizes.Get(publicKey)
 	if err != nil {
 		sendError(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	claimable, err := h.db.Prizes.List(publicKey)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	resp := GetPrizesResponse{
-		Prizes: prizes,
+		Fiat:      h.rates.Convert(prizes),
+		Claimable: claimable,
+		Prizes:    prizes,
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// GetPrizesAtRisk responds with a summary of the unclaimed prizes whose winners were already
+// reminded to claim them before they expire.
+func (h *Handler) GetPrizesAtRisk(w http.ResponseWriter, r *http.Request) {
+	info, err := h.lnd.GetInfo(r.Context())
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, errors.Wrap(err, "getting node information"))
+		return
+	}
+
+	prizes, err := h.db.ReadReplica().Winners.ListUnclaimed()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := PrizesAtRiskResponse{
+		AtRisk: lottery.PrizesAtRisk(prizes, info.BlockHeight),
+		Height: info.BlockHeight,
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// GetPrizesAging responds with the unclaimed prizes grouped by the days elapsed since they were
+// won, so operators can anticipate the liquidity the claims will need.
+func (h *Handler) GetPrizesAging(w http.ResponseWriter, r *http.Request) {
+	info, err := h.lnd.GetInfo(r.Context())
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, errors.Wrap(err, "getting node information"))
+		return
+	}
+
+	prizes, err := h.db.ReadReplica().Winners.ListUnclaimed()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := PrizesAgingResponse{
+		Aging:  lottery.PrizesAging(prizes, info.BlockHeight),
+		Height: info.BlockHeight,
 	}
 	sendResponse(w, http.StatusOK, resp)
 }
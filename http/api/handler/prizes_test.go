@@ -4,8 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
 	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/rates"
 
+	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/pkg/errors"
 )
 
@@ -14,7 +19,11 @@ func (h *HandlerSuite) TestGetPrizes() {
 	h.SetAuthorizationKey(publicKey)
 
 	prizes := uint64(100)
+	claimable := []db.Prize{{LotteryHeight: 144, Amount: 40}, {LotteryHeight: 288, Amount: 60}}
 	h.prizesMock.On("Get", publicKey).Return(prizes, nil)
+	h.prizesMock.On("List", publicKey).Return(claimable, nil)
+	fiat := rates.Values{"EUR": 0.06}
+	h.ratesMock.On("Convert", prizes).Return(fiat)
 
 	h.handler.GetPrizes(h.rec, h.req)
 
@@ -24,6 +33,8 @@ func (h *HandlerSuite) TestGetPrizes() {
 
 	h.Equal(http.StatusOK, h.rec.Code)
 	h.Equal(prizes, response.Prizes)
+	h.Equal(claimable, response.Claimable)
+	h.Equal(fiat, response.Fiat)
 }
 
 func (h *HandlerSuite) TestGetPrizesNoPrizes() {
@@ -32,6 +43,8 @@ func (h *HandlerSuite) TestGetPrizesNoPrizes() {
 
 	prizes := uint64(0)
 	h.prizesMock.On("Get", publicKey).Return(prizes, nil)
+	h.prizesMock.On("List", publicKey).Return([]db.Prize(nil), nil)
+	h.ratesMock.On("Convert", prizes).Return(nil)
 
 	h.handler.GetPrizes(h.rec, h.req)
 
@@ -60,10 +73,82 @@ func (h *HandlerSuite) TestGetPrizesInternalError() {
 
 	h.handler.GetPrizes(h.rec, h.req)
 
-	var response handler.ErrorResponse
+	var response apierrors.Response
 	err := json.NewDecoder(h.rec.Body).Decode(&response)
 	h.NoError(err)
 
 	h.Equal(http.StatusInternalServerError, h.rec.Code)
-	h.Equal(expectedErr.Error(), response.Error)
+	h.Equal(expectedErr.Error(), response.Error.Message)
+}
+
+func (h *HandlerSuite) TestGetPrizesAtRisk() {
+	prizes := []db.UnclaimedPrize{
+		{PublicKey: "1", Amount: 100, LotteryHeight: 1000, ClaimDeadline: 1720},
+		{PublicKey: "2", Amount: 50, LotteryHeight: 1432, ClaimDeadline: 2152},
+	}
+	h.winnersMock.On("ListUnclaimed").Return(prizes, nil)
+	h.lndMock.On("GetInfo", h.req.Context()).Return(&lnrpc.GetInfoResponse{BlockHeight: 1700}, nil)
+
+	h.handler.GetPrizesAtRisk(h.rec, h.req)
+
+	var response handler.PrizesAtRiskResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(uint32(1700), response.Height)
+	h.Equal(lottery.AtRisk{Count: 1, Final: 1, Amount: 100}, response.AtRisk)
+}
+
+func (h *HandlerSuite) TestGetPrizesAtRiskError() {
+	expectedErr := errors.New("test err")
+	h.lndMock.On("GetInfo", h.req.Context()).Return(&lnrpc.GetInfoResponse{BlockHeight: 1700}, nil)
+	h.winnersMock.On("ListUnclaimed").Return(nil, expectedErr)
+
+	h.handler.GetPrizesAtRisk(h.rec, h.req)
+
+	var response apierrors.Response
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusInternalServerError, h.rec.Code)
+	h.Equal(expectedErr.Error(), response.Error.Message)
+}
+
+func (h *HandlerSuite) TestGetPrizesAging() {
+	prizes := []db.UnclaimedPrize{
+		{PublicKey: "1", Amount: 100, LotteryHeight: 1000, ClaimDeadline: 1720},
+		{PublicKey: "2", Amount: 50, LotteryHeight: 1650, ClaimDeadline: 2370},
+	}
+	h.winnersMock.On("ListUnclaimed").Return(prizes, nil)
+	h.lndMock.On("GetInfo", h.req.Context()).Return(&lnrpc.GetInfoResponse{BlockHeight: 1750}, nil)
+
+	h.handler.GetPrizesAging(h.rec, h.req)
+
+	var response handler.PrizesAgingResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(uint32(1750), response.Height)
+	h.Equal(uint64(150), response.Liabilities)
+
+	day1, _ := response.Bucket(lottery.AgingDay1)
+	h.Equal(lottery.AgingBucket{Bucket: lottery.AgingDay1, Count: 1, Amount: 50}, day1)
+	expired, _ := response.Bucket(lottery.AgingExpired)
+	h.Equal(lottery.AgingBucket{Bucket: lottery.AgingExpired, Count: 1, Amount: 100}, expired)
+}
+
+func (h *HandlerSuite) TestGetPrizesAgingError() {
+	expectedErr := errors.New("test err")
+	h.lndMock.On("GetInfo", h.req.Context()).Return(nil, expectedErr)
+
+	h.handler.GetPrizesAging(h.rec, h.req)
+
+	var response apierrors.Response
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusInternalServerError, h.rec.Code)
+	h.Contains(response.Error.Message, expectedErr.Error())
 }
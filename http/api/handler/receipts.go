@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// errNoPrizes is returned when a win receipt is requested for a round the player didn't win.
+var errNoPrizes = errors.New("no prizes won in the round")
+
+// VerifyReceiptResponse is the response schema of the /receipts/verify endpoint.
+type VerifyReceiptResponse struct {
+	PublicKey string `json:"public_key"`
+	Error     string `json:"error,omitempty"`
+	Valid     bool   `json:"valid"`
+}
+
+// ReceiptBundleResponse is the response schema of the /receipts/bundle endpoint.
+type ReceiptBundleResponse struct {
+	// Message is the bundle encoded in JSON, which is what the signature covers
+	Message   string `json:"message"`
+	Signature string `json:"signature"`
+	// PDF is the bundle rendered as a document, only included if requested
+	PDF    []byte       `json:"pdf,omitempty"`
+	Bundle audit.Bundle `json:"bundle"`
+}
+
+// GetReceiptBundle responds with a bet or win receipt bundle signed by the server, to be downloaded
+// by the player. The receipt of the bet paid with the payment hash is returned if it's specified,
+// and the one of the prizes won in the round at height otherwise.
+func (h *Handler) GetReceiptBundle(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := getSignedPublicKey(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	query := r.URL.Query()
+	paymentHash := query.Get("payment_hash")
+	height, err := parseIntParam(query, "height", paymentHash == "")
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	withPDF := false
+	if pdfStr := query.Get("pdf"); pdfStr != "" {
+		v, err := strconv.ParseBool(pdfStr)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, errors.Wrap(err, "invalid pdf parameter"))
+			return
+		}
+		withPDF = v
+	}
+
+	serverPublicKey := h.auditor.PublicKey()
+	if serverPublicKey == "" {
+		sendError(w, http.StatusNotFound, audit.ErrSigningDisabled)
+		return
+	}
+
+	var bundle audit.Bundle
+	if paymentHash != "" {
+		bundle, err = h.betBundle(publicKey, paymentHash, serverPublicKey)
+	} else {
+		bundle, err = h.winBundle(publicKey, uint32(height), serverPublicKey)
+	}
+	if err != nil {
+		if errors.Is(err, db.ErrReceiptNotFound) || errors.Is(err, db.ErrLotteryNotFound) ||
+			errors.Is(err, errNoPrizes) {
+			sendError(w, http.StatusNotFound, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	message, signature, err := audit.SignBundle(h.auditor, bundle)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := ReceiptBundleResponse{
+		Bundle:    bundle,
+		Message:   message,
+		Signature: signature,
+	}
+	if withPDF {
+		resp.PDF = bundle.PDF(message, signature)
+	}
+
+	filename := fmt.Sprintf("btry-%s-receipt-%d.json", bundle.Kind, bundle.Round)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// betBundle returns the bundle of the receipt of a bet placed by the public key.
+func (h *Handler) betBundle(publicKey, paymentHash, serverPublicKey string) (audit.Bundle, error) {
+	receipt, err := h.db.Receipts.Get(publicKey, paymentHash)
+	if err != nil {
+		return audit.Bundle{}, err
+	}
+
+	// The amount is left out if the invoice is no longer tracked
+	var amount uint64
+	invoice, err := h.db.Invoices.Get(paymentHash)
+	switch {
+	case err == nil:
+		amount = invoice.Amount
+	case !errors.Is(err, db.ErrInvoiceNotFound):
+		return audit.Bundle{}, err
+	}
+
+	return audit.NewBetBundle(receipt, amount, serverPublicKey), nil
+}
+
+// winBundle returns the bundle of the prizes won by the public key in the lottery at height.
+func (h *Handler) winBundle(publicKey string, height uint32, serverPublicKey string) (audit.Bundle, error) {
+	commitment, err := h.db.Lotteries.GetCommitment(height)
+	if err != nil {
+		return audit.Bundle{}, err
+	}
+
+	winners, err := h.db.ReadReplica().Winners.List(height)
+	if err != nil {
+		return audit.Bundle{}, err
+	}
+
+	var prizes []db.Winner
+	for _, winner := range winners {
+		if winner.PublicKey == publicKey {
+			prizes = append(prizes, winner)
+		}
+	}
+	if len(prizes) == 0 {
+		return audit.Bundle{}, errNoPrizes
+	}
+
+	return audit.NewWinBundle(publicKey, commitment, prizes, serverPublicKey), nil
+}
+
+// VerifyReceipt checks that a bet receipt was signed by the server and responds with the public key
+// used, so players can verify receipts on their own.
+func (h *Handler) VerifyReceipt(w http.ResponseWriter, r *http.Request) {
+	publicKey := h.auditor.PublicKey()
+	if publicKey == "" {
+		sendError(w, http.StatusNotFound, audit.ErrSigningDisabled)
+		return
+	}
+
+	var receipt audit.Receipt
+	if err := json.NewDecoder(r.Body).Decode(&receipt); err != nil {
+		sendError(w, http.StatusBadRequest, errors.Wrap(err, "decoding request body"))
+		return
+	}
+
+	resp := VerifyReceiptResponse{
+		PublicKey: publicKey,
+		Valid:     true,
+	}
+	if err := audit.VerifyReceipt(publicKey, receipt); err != nil {
+		resp.Valid = false
+		resp.Error = err.Error()
+	}
+
+	sendResponse(w, http.StatusOK, resp)
+}
@@ -0,0 +1,218 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+func (h *HandlerSuite) TestVerifyReceipt() {
+	publicKey := "e68b99fc5f60c971926fdc3a3af38ccf67e6f4306ab1c388735533e7c5dcc749"
+	h.auditorMock.On("PublicKey").Return(publicKey)
+
+	body := `{"public_key":"pubkey","first_ticket":1,"last_ticket":10,"round":840000,"signature":"00"}`
+	h.req = httptest.NewRequest(http.MethodPost, "/receipts/verify", strings.NewReader(body))
+	h.handler.VerifyReceipt(h.rec, h.req)
+
+	var response handler.VerifyReceiptResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(publicKey, response.PublicKey)
+	h.False(response.Valid)
+	h.Equal("invalid signature", response.Error)
+}
+
+func (h *HandlerSuite) TestVerifyReceiptErrors() {
+	cases := []struct {
+		desc         string
+		publicKey    string
+		body         string
+		expectedCode int
+	}{
+		{
+			desc:         "Signing disabled",
+			publicKey:    "",
+			body:         "{}",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			desc:         "Invalid body",
+			publicKey:    "e68b99fc5f60c971926fdc3a3af38ccf67e6f4306ab1c388735533e7c5dcc749",
+			body:         "{",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.SetupTest()
+			h.auditorMock.On("PublicKey").Return(tc.publicKey)
+
+			h.req = httptest.NewRequest(http.MethodPost, "/receipts/verify", strings.NewReader(tc.body))
+			h.handler.VerifyReceipt(h.rec, h.req)
+
+			h.Equal(tc.expectedCode, h.rec.Code)
+		})
+	}
+}
+
+func (h *HandlerSuite) TestGetReceiptBundle() {
+	serverPublicKey := "e68b99fc5f60c971926fdc3a3af38ccf67e6f4306ab1c388735533e7c5dcc749"
+	receipt := db.Receipt{
+		PublicKey:   validPublicKey,
+		PaymentHash: "payment_hash",
+		Signature:   "receipt_signature",
+		FirstTicket: 11,
+		LastTicket:  20,
+		Timestamp:   100,
+		Round:       840_000,
+	}
+	h.auditorMock.On("PublicKey").Return(serverPublicKey)
+	h.auditorMock.On("Sign", audit.BundleDomain, mock.Anything).Return("signature", nil)
+	h.receiptsMock.On("Get", validPublicKey, receipt.PaymentHash).Return(receipt, nil)
+	h.invoicesMock.On("Get", receipt.PaymentHash).Return(db.Invoice{Amount: 10}, nil)
+
+	url := url.Values{}
+	url.Add("payment_hash", receipt.PaymentHash)
+	url.Add("pdf", "true")
+	url.Add("signature", validSignature)
+	h.req = httptest.NewRequest(http.MethodGet, "/receipts/bundle?"+url.Encode(), nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.handler.GetReceiptBundle(h.rec, h.req)
+
+	var response handler.ReceiptBundleResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(`attachment; filename="btry-bet-receipt-840000.json"`, h.rec.Header().Get("Content-Disposition"))
+	h.Equal("signature", response.Signature)
+	h.Equal(audit.BundleBet, response.Bundle.Kind)
+	h.Equal(&receipt, response.Bundle.Receipt)
+	h.Equal(uint64(10), response.Bundle.Tickets)
+	h.Equal(uint64(10), response.Bundle.Amount)
+	h.Equal(serverPublicKey, response.Bundle.ServerPublicKey)
+	h.True(bytes.HasPrefix(response.PDF, []byte("%PDF")))
+
+	// The message signed is the bundle itself
+	var bundle audit.Bundle
+	h.NoError(json.Unmarshal([]byte(response.Message), &bundle))
+	h.Equal(response.Bundle, bundle)
+}
+
+func (h *HandlerSuite) TestGetReceiptBundleWin() {
+	height := uint32(840_000)
+	commitment := db.Commitment{Height: height, Seed: "seed", BlockHash: "block_hash"}
+	winners := []db.Winner{
+		{PublicKey: "pubkey", Ticket: 3, Prize: 5_000},
+		{PublicKey: validPublicKey, Ticket: 7, Prize: 1_000},
+	}
+	h.auditorMock.On("PublicKey").Return("server")
+	h.auditorMock.On("Sign", audit.BundleDomain, mock.Anything).Return("signature", nil)
+	h.lotteriesMock.On("GetCommitment", height).Return(commitment, nil)
+	h.winnersMock.On("List", height).Return(winners, nil)
+
+	url := url.Values{}
+	url.Add("height", "840000")
+	url.Add("signature", validSignature)
+	h.req = httptest.NewRequest(http.MethodGet, "/receipts/bundle?"+url.Encode(), nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.handler.GetReceiptBundle(h.rec, h.req)
+
+	var response handler.ReceiptBundleResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(audit.BundleWin, response.Bundle.Kind)
+	h.Equal(winners[1:], response.Bundle.Prizes)
+	h.Equal(uint64(1_000), response.Bundle.Amount)
+	h.Equal("seed", response.Bundle.ServerSeed)
+	h.Empty(response.PDF)
+}
+
+func (h *HandlerSuite) TestGetReceiptBundleErrors() {
+	cases := []struct {
+		receiptErr   error
+		desc         string
+		publicKey    string
+		params       map[string]string
+		winners      []db.Winner
+		expectedCode int
+	}{
+		{
+			desc:         "Signing disabled",
+			params:       map[string]string{"payment_hash": "hash"},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			desc:         "Missing height",
+			publicKey:    "server",
+			params:       map[string]string{},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			desc:         "Invalid pdf",
+			publicKey:    "server",
+			params:       map[string]string{"payment_hash": "hash", "pdf": "yes"},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			desc:         "Receipt not found",
+			publicKey:    "server",
+			params:       map[string]string{"payment_hash": "hash"},
+			receiptErr:   db.ErrReceiptNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			desc:         "Internal error",
+			publicKey:    "server",
+			params:       map[string]string{"payment_hash": "hash"},
+			receiptErr:   errors.New("test"),
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			desc:         "No prizes",
+			publicKey:    "server",
+			params:       map[string]string{"height": "840000"},
+			winners:      []db.Winner{{PublicKey: "pubkey", Prize: 1_000}},
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.SetupTest()
+			h.auditorMock.On("PublicKey").Return(tc.publicKey)
+			h.receiptsMock.On("Get", validPublicKey, "hash").Return(db.Receipt{}, tc.receiptErr)
+			h.lotteriesMock.On("GetCommitment", uint32(840_000)).Return(db.Commitment{}, nil)
+			h.winnersMock.On("List", uint32(840_000)).Return(tc.winners, nil)
+
+			url := url.Values{}
+			for k, v := range tc.params {
+				url.Add(k, v)
+			}
+			url.Add("signature", validSignature)
+			h.req = httptest.NewRequest(http.MethodGet, "/receipts/bundle?"+url.Encode(), nil)
+			h.SetAuthorizationKey(validPublicKey)
+
+			h.handler.GetReceiptBundle(h.rec, h.req)
+
+			h.Equal(tc.expectedCode, h.rec.Code)
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/lottery/engine"
+
+	"github.com/pkg/errors"
+)
+
+// ReplayResponse is the response schema of the /admin/replay endpoint.
+type ReplayResponse struct {
+	Pool         string       `json:"pool,omitempty"`
+	Actual       ReplayResult `json:"actual"`
+	Hypothetical ReplayResult `json:"hypothetical"`
+	Height       uint32       `json:"height"`
+	PrizePool    uint64       `json:"prize_pool"`
+}
+
+// ReplayResult contains the winners of a draw and how the prize pool was split.
+type ReplayResult struct {
+	// Distribution is only set in the hypothetical draw, the actual one could have been drawn
+	// with a prize table that is no longer configured
+	Distribution engine.Distribution `json:"distribution,omitempty"`
+	Rounding     engine.Rounding     `json:"rounding,omitempty"`
+	Winners      []db.Winner         `json:"winners"`
+	Payout       uint64              `json:"payout"`
+	Fee          uint64              `json:"fee"`
+}
+
+// ReplayDraw draws a past lottery pool again with another prize distribution or fee, to compare
+// the payouts with the actual ones. The fee scales the pool's configured distribution. Prizes are
+// rounded with the configured policy unless another one is specified.
+func (h *Handler) ReplayDraw(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	height, err := parseIntParam(query, "height", true)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+	pool := query.Get("pool")
+
+	distribution, err := parseReplayDistribution(query, h.pools.Distribution(pool))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rounding := h.rounding
+	if r := query.Get("rounding"); r != "" {
+		rounding = engine.Rounding(r)
+	}
+	if err := rounding.Validate(); err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	database := h.db.ReadReplica()
+	commitment, err := database.Lotteries.GetCommitment(uint32(height))
+	if err != nil {
+		if errors.Is(err, db.ErrLotteryNotFound) {
+			sendError(w, http.StatusNotFound, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	bets, err := database.Bets.List(uint32(height), pool, 0, 0, false)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if len(bets) == 0 {
+		sendError(w, http.StatusNotFound, errors.New("the lottery pool has no bets"))
+		return
+	}
+
+	hypothetical, err := lottery.Replay(commitment, pool, bets, distribution, rounding,
+		lottery.DrawHooks(h.lastTicket)...)
+	if err != nil {
+		if errors.Is(err, lottery.ErrBlockHashNotStored) {
+			sendError(w, http.StatusConflict, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	winners, err := database.Winners.List(uint32(height))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	actual := make([]db.Winner, 0, len(winners))
+	for _, winner := range winners {
+		// Winners restored after a failed payment have no ticket
+		if winner.Pool == pool && winner.Ticket != 0 {
+			actual = append(actual, winner)
+		}
+	}
+
+	prizePool := bets[len(bets)-1].Index
+	sendResponse(w, http.StatusOK, ReplayResponse{
+		Height:       uint32(height),
+		Pool:         pool,
+		PrizePool:    prizePool,
+		Actual:       newReplayResult(prizePool, nil, "", actual),
+		Hypothetical: newReplayResult(prizePool, distribution, rounding, hypothetical),
+	})
+}
+
+func newReplayResult(
+	prizePool uint64,
+	distribution engine.Distribution,
+	rounding engine.Rounding,
+	winners []db.Winner,
+) ReplayResult {
+	var payout uint64
+	for _, winner := range winners {
+		payout += winner.Prize
+	}
+
+	var fee uint64
+	if payout < prizePool {
+		fee = prizePool - payout
+	}
+
+	return ReplayResult{
+		Distribution: distribution,
+		Rounding:     rounding,
+		Winners:      winners,
+		Payout:       payout,
+		Fee:          fee,
+	}
+}
+
+// parseReplayDistribution returns the distribution in the query, either a comma separated list of
+// percentages or a fee applied to the configured one.
+func parseReplayDistribution(query url.Values, configured engine.Distribution) (engine.Distribution, error) {
+	distributionStr := query.Get("distribution")
+	feeStr := query.Get("fee")
+
+	switch {
+	case distributionStr != "" && feeStr != "":
+		return nil, errors.New("either distribution or fee must be provided, not both")
+	case distributionStr != "":
+		percentages := strings.Split(distributionStr, ",")
+		distribution := make(engine.Distribution, 0, len(percentages))
+		for _, percentage := range percentages {
+			p, err := strconv.ParseFloat(strings.TrimSpace(percentage), 64)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid distribution")
+			}
+			distribution = append(distribution, p)
+		}
+
+		if err := distribution.Validate(); err != nil {
+			return nil, errors.Wrap(err, "invalid distribution")
+		}
+		return distribution, nil
+	case feeStr != "":
+		fee, err := strconv.ParseFloat(feeStr, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid fee")
+		}
+		return lottery.ScaleDistribution(configured, fee)
+	default:
+		return nil, errors.New("query parameter \"distribution\" or \"fee\" is required")
+	}
+}
@@ -0,0 +1,148 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/lottery/engine"
+)
+
+func (h *HandlerSuite) TestReplayDraw() {
+	height := uint32(144)
+	commitment := db.Commitment{
+		Height:    height,
+		Seed:      "6b1d6b1f9ac7a0a5a6b8d2e0c3f4e5d6c7b8a9f0e1d2c3b4a5968778695a4b3c",
+		BlockHash: "000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6",
+	}
+	bets := []db.Bet{
+		{PublicKey: "a", Index: 600_000, Tickets: 600_000},
+		{PublicKey: "b", Index: 1_000_000, Tickets: 400_000},
+	}
+	winners := []db.Winner{
+		{PublicKey: "a", Ticket: 1, Prize: 500_000},
+		{PublicKey: "b", Ticket: 900_000, Prize: 250_000},
+		{PublicKey: "a", Prize: 250_000},
+	}
+	h.lotteriesMock.On("GetCommitment", height).Return(commitment, nil)
+	h.betsMock.On("List", height, "", uint64(0), uint64(0), false).Return(bets, nil)
+	h.winnersMock.On("List", height).Return(winners, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/replay?height=144&distribution=70,20", nil)
+	h.handler.ReplayDraw(h.rec, h.req)
+
+	var response handler.ReplayResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(uint64(1_000_000), response.PrizePool)
+
+	h.Equal(winners[:2], response.Actual.Winners)
+	h.Equal(uint64(750_000), response.Actual.Payout)
+	h.Equal(uint64(250_000), response.Actual.Fee)
+
+	h.Equal(engine.Distribution{70, 20}, response.Hypothetical.Distribution)
+	h.Len(response.Hypothetical.Winners, 2)
+	h.Equal(uint64(700_000), response.Hypothetical.Winners[0].Prize)
+	h.Equal(uint64(200_000), response.Hypothetical.Winners[1].Prize)
+	h.Equal(uint64(900_000), response.Hypothetical.Payout)
+	h.Equal(uint64(100_000), response.Hypothetical.Fee)
+}
+
+func (h *HandlerSuite) TestReplayDrawFee() {
+	height := uint32(144)
+	commitment := db.Commitment{
+		Height:    height,
+		BlockHash: "000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6",
+	}
+	bets := []db.Bet{{PublicKey: "a", Index: 1_000_000, Tickets: 1_000_000}}
+	h.lotteriesMock.On("GetCommitment", height).Return(commitment, nil)
+	h.betsMock.On("List", height, "", uint64(0), uint64(0), false).Return(bets, nil)
+	h.winnersMock.On("List", height).Return([]db.Winner{}, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/replay?height=144&fee=5", nil)
+	h.handler.ReplayDraw(h.rec, h.req)
+
+	var response handler.ReplayResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Len(response.Hypothetical.Winners, len(engine.DefaultDistribution))
+	h.InDelta(5, response.Hypothetical.Distribution.Fee(), 0.000001)
+	h.Equal(uint64(50_000), response.Hypothetical.Fee)
+}
+
+func (h *HandlerSuite) TestReplayDrawRounding() {
+	height := uint32(144)
+	commitment := db.Commitment{
+		Height:    height,
+		BlockHash: "000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6",
+	}
+	bets := []db.Bet{{PublicKey: "a", Index: 1_000_003, Tickets: 1_000_003}}
+	h.lotteriesMock.On("GetCommitment", height).Return(commitment, nil)
+	h.betsMock.On("List", height, "", uint64(0), uint64(0), false).Return(bets, nil)
+	h.winnersMock.On("List", height).Return([]db.Winner{}, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/replay?height=144&distribution=50,25&rounding=first_prize", nil)
+	h.handler.ReplayDraw(h.rec, h.req)
+
+	var response handler.ReplayResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(engine.RoundingFirstPrize, response.Hypothetical.Rounding)
+	h.Equal(uint64(500_002), response.Hypothetical.Winners[0].Prize)
+	h.Equal(uint64(250_000), response.Hypothetical.Winners[1].Prize)
+	h.Equal(uint64(250_001), response.Hypothetical.Fee)
+}
+
+func (h *HandlerSuite) TestReplayDrawBlockHashNotStored() {
+	height := uint32(144)
+	bets := []db.Bet{{PublicKey: "a", Index: 100, Tickets: 100}}
+	h.lotteriesMock.On("GetCommitment", height).Return(db.Commitment{Height: height}, nil)
+	h.betsMock.On("List", height, "", uint64(0), uint64(0), false).Return(bets, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/replay?height=144&fee=5", nil)
+	h.handler.ReplayDraw(h.rec, h.req)
+
+	h.Equal(http.StatusConflict, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestReplayDrawNotFound() {
+	h.lotteriesMock.On("GetCommitment", uint32(144)).Return(db.Commitment{}, db.ErrLotteryNotFound)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/replay?height=144&fee=5", nil)
+	h.handler.ReplayDraw(h.rec, h.req)
+
+	h.Equal(http.StatusNotFound, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestReplayDrawInvalidParams() {
+	cases := []struct {
+		desc  string
+		query string
+	}{
+		{desc: "Missing height", query: "fee=5"},
+		{desc: "Missing distribution and fee", query: "height=144"},
+		{desc: "Distribution and fee", query: "height=144&fee=5&distribution=50"},
+		{desc: "Invalid distribution", query: "height=144&distribution=50,a"},
+		{desc: "Distribution over 100", query: "height=144&distribution=80,30"},
+		{desc: "Invalid fee", query: "height=144&fee=100"},
+		{desc: "Invalid rounding", query: "height=144&fee=5&rounding=ceil"},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/admin/replay?"+tc.query, nil)
+			h.handler.ReplayDraw(rec, req)
+
+			h.Equal(http.StatusBadRequest, rec.Code)
+		})
+	}
+}
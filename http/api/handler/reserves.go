@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/aftermath2/BTRY/reserves"
+
+	"github.com/pkg/errors"
+)
+
+// ReservesResponse is the response schema of the /reserves endpoint.
+type ReservesResponse struct {
+	// PublicKey is the audit log key the statement is signed with
+	PublicKey string `json:"public_key"`
+	reserves.Proof
+}
+
+// GetReserves responds with the last proof of reserves, a signed statement of the prizes owed to
+// the players and the channel balances covering them.
+func (h *Handler) GetReserves(w http.ResponseWriter, r *http.Request) {
+	proof, err := h.reserves.Proof()
+	if err != nil {
+		switch {
+		case errors.Is(err, reserves.ErrDisabled):
+			sendError(w, http.StatusNotFound, err)
+		case errors.Is(err, reserves.ErrNotReady):
+			sendError(w, http.StatusServiceUnavailable, err)
+		default:
+			sendError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	sendResponse(w, http.StatusOK, ReservesResponse{
+		PublicKey: h.auditor.PublicKey(),
+		Proof:     proof,
+	})
+}
@@ -0,0 +1,81 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/reserves"
+
+	"github.com/pkg/errors"
+)
+
+func (h *HandlerSuite) TestGetReserves() {
+	publicKey := "e68b99fc5f60c971926fdc3a3af38ccf67e6f4306ab1c388735533e7c5dcc749"
+	h.auditorMock.On("PublicKey").Return(publicKey)
+	proof := reserves.Proof{
+		Statement: reserves.Statement{
+			Channels:        []reserves.Channel{{ChannelPoint: "txid:0", Capacity: 100_000, LocalBalance: 80_000}},
+			UnclaimedPrizes: 30_000,
+			PrizePool:       20_000,
+			Liabilities:     50_000,
+			LocalBalance:    80_000,
+			Height:          840_000,
+		},
+		Message:       "{}",
+		Signature:     "signature",
+		NodePublicKey: "node_public_key",
+		NodeSignature: "node_signature",
+	}
+	h.reservesMock.On("Proof").Return(proof, nil)
+
+	h.handler.GetReserves(h.rec, h.req)
+
+	var response handler.ReservesResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(handler.ReservesResponse{PublicKey: publicKey, Proof: proof}, response)
+}
+
+func (h *HandlerSuite) TestGetReservesErrors() {
+	cases := []struct {
+		err          error
+		desc         string
+		expectedCode int
+	}{
+		{
+			desc:         "Disabled",
+			err:          reserves.ErrDisabled,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			desc:         "Not ready",
+			err:          reserves.ErrNotReady,
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			desc:         "Other",
+			err:          errors.New("test error"),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.SetupTest()
+			h.reservesMock.On("Proof").Return(reserves.Proof{}, tc.err)
+
+			h.handler.GetReserves(h.rec, h.req)
+
+			var response apierrors.Response
+			err := json.NewDecoder(h.rec.Body).Decode(&response)
+			h.NoError(err)
+
+			h.Equal(tc.expectedCode, h.rec.Code)
+			h.Equal(tc.err.Error(), response.Error.Message)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// minSearchPrefix is the minimum number of characters of a public key prefix, shorter ones match
+// too many players.
+const minSearchPrefix = 8
+
+// SearchResponse is the response schema of the /admin/search endpoint.
+type SearchResponse struct {
+	db.SearchResult
+	Notifications []SearchNotifications `json:"notifications"`
+}
+
+// SearchNotifications contains the notification channels a public key is subscribed to, the chat
+// IDs and nostr keys are not disclosed.
+type SearchNotifications struct {
+	PublicKey string `json:"public_key"`
+	Telegram  bool   `json:"telegram"`
+	Nostr     bool   `json:"nostr"`
+	Digest    bool   `json:"digest"`
+}
+
+// Search responds with the records related to a public key prefix, a payment hash or an invoice.
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	q := strings.ToLower(strings.TrimSpace(query.Get("q")))
+	if q == "" {
+		sendError(w, http.StatusBadRequest, errors.New(`query parameter "q" is required`))
+		return
+	}
+
+	limit, err := parseIntParam(query, "limit", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var prefix, paymentHash string
+	switch {
+	case strings.HasPrefix(q, "ln"):
+		invoice, err := h.lnd.DecodeInvoice(r.Context(), q)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, errors.Wrap(err, "invalid invoice"))
+			return
+		}
+		paymentHash = invoice.PaymentHash
+
+	case len(q) < minSearchPrefix || len(q) > 64:
+		sendError(w, http.StatusBadRequest,
+			errors.Errorf("query must have between %d and 64 characters", minSearchPrefix))
+		return
+
+	default:
+		if !isHex(q) {
+			sendError(w, http.StatusBadRequest, errors.New("query must be hex encoded"))
+			return
+		}
+		prefix = q
+		// Public keys and payment hashes have the same length
+		if len(q) == 64 {
+			paymentHash = q
+		}
+	}
+
+	result, err := h.db.Search.Search(prefix, paymentHash, limit)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	notifications := make([]SearchNotifications, 0, len(result.PublicKeys))
+	for _, publicKey := range result.PublicKeys {
+		subscriptions, err := h.notificationChannels(publicKey)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+		notifications = append(notifications, subscriptions)
+	}
+
+	resp := SearchResponse{
+		SearchResult:  result,
+		Notifications: notifications,
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// notificationChannels returns the notification channels the public key is subscribed to.
+func (h *Handler) notificationChannels(publicKey string) (SearchNotifications, error) {
+	subscriptions := SearchNotifications{PublicKey: publicKey}
+
+	_, err := h.db.Notifications.GetChatID(publicKey)
+	if err != nil && !errors.Is(err, db.ErrNoChatID) {
+		return SearchNotifications{}, err
+	}
+	subscriptions.Telegram = err == nil
+
+	_, err = h.db.Notifications.GetNostrKey(publicKey)
+	if err != nil && !errors.Is(err, db.ErrNoNostrKey) {
+		return SearchNotifications{}, err
+	}
+	subscriptions.Nostr = err == nil
+
+	subscriptions.Digest, err = h.db.Notifications.GetDigest(publicKey)
+	if err != nil {
+		return SearchNotifications{}, err
+	}
+
+	return subscriptions, nil
+}
+
+// isHex returns whether s only contains lowercase hex characters, the prefix may have an odd length
+// so it can't be decoded.
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+)
+
+func (h *HandlerSuite) TestSearchPrefix() {
+	result := db.SearchResult{
+		PublicKeys: []string{validPublicKey},
+		Bets:       []db.SearchBet{{PublicKey: validPublicKey, PaymentHash: "hash", Tickets: 10}},
+	}
+	h.searchMock.On("Search", validPublicKey[:10], "", uint64(20)).Return(result, nil)
+	h.notificationsMock.On("GetChatID", validPublicKey).Return(int64(0), db.ErrNoChatID)
+	h.notificationsMock.On("GetNostrKey", validPublicKey).Return("nostr", nil)
+	h.notificationsMock.On("GetDigest", validPublicKey).Return(false, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/search?limit=20&q="+validPublicKey[:10], nil)
+	h.handler.Search(h.rec, h.req)
+
+	var response handler.SearchResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(result, response.SearchResult)
+	h.Equal([]handler.SearchNotifications{{PublicKey: validPublicKey, Nostr: true}}, response.Notifications)
+}
+
+func (h *HandlerSuite) TestSearchHash() {
+	h.searchMock.On("Search", validPublicKey, validPublicKey, uint64(0)).Return(db.SearchResult{}, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/search?q="+validPublicKey, nil)
+	h.handler.Search(h.rec, h.req)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestSearchInvoice() {
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/search?q=LNBCRT1", nil)
+	invoice := &lnrpc.PayReq{PaymentHash: "hash"}
+	h.lndMock.On("DecodeInvoice", h.req.Context(), "lnbcrt1").Return(invoice, nil)
+	h.searchMock.On("Search", "", "hash", uint64(0)).Return(db.SearchResult{}, nil)
+
+	h.handler.Search(h.rec, h.req)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestSearchInvalid() {
+	cases := []struct {
+		desc  string
+		query string
+	}{
+		{desc: "Empty", query: ""},
+		{desc: "Short prefix", query: "abcdef"},
+		{desc: "Not hex", query: "abcdefgh"},
+		{desc: "Too long", query: validPublicKey + "00"},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/admin/search?q="+tc.query, nil)
+			h.handler.Search(rec, req)
+
+			h.Equal(http.StatusBadRequest, rec.Code)
+		})
+	}
+
+	h.searchMock.AssertNotCalled(h.T(), "Search")
+}
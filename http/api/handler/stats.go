@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// StatsResponse is the response schema of the /stats endpoint.
+type StatsResponse struct {
+	Stats db.Stats `json:"stats"`
+}
+
+// WinsResponse is the response schema of the /stats/wins endpoint.
+type WinsResponse struct {
+	Wins []db.Win `json:"wins,omitempty"`
+}
+
+// RoundsResponse is the response schema of the /stats/rounds endpoint.
+type RoundsResponse struct {
+	Rounds []db.RoundStats `json:"rounds,omitempty"`
+}
+
+// StreaksResponse is the response schema of the /stats/streaks endpoint.
+type StreaksResponse struct {
+	Streaks []db.Streak `json:"streaks,omitempty"`
+}
+
+// GetStats responds with the all-time statistics.
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.db.ReadReplica().Stats.Get()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, StatsResponse{Stats: h.statsPrivacy.Stats(stats)})
+}
+
+// GetBiggestWins responds with the leaderboard of the highest prizes won.
+func (h *Handler) GetBiggestWins(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseIntParam(r.URL.Query(), "limit", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	wins, err := h.db.ReadReplica().Stats.ListBiggestWins(limit)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, WinsResponse{Wins: h.statsPrivacy.Wins(wins)})
+}
+
+// GetRoundStats responds with the statistics of each lottery.
+func (h *Handler) GetRoundStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	offset, err := parseIntParam(query, "offset", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, err := parseIntParam(query, "limit", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	reverse := false
+	reverseStr := query.Get("reverse")
+	if reverseStr != "" {
+		v, err := strconv.ParseBool(reverseStr)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, errors.Wrap(err, "invalid reverse parameter"))
+			return
+		}
+		reverse = v
+	}
+
+	rounds, err := h.db.ReadReplica().Stats.ListRounds(offset, limit, reverse)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, RoundsResponse{Rounds: h.statsPrivacy.Rounds(rounds)})
+}
+
+// GetStreaks responds with the longest win streaks.
+func (h *Handler) GetStreaks(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseIntParam(r.URL.Query(), "limit", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	streaks, err := h.db.ReadReplica().Stats.ListStreaks(limit)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, StreaksResponse{Streaks: streaks})
+}
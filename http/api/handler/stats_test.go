@@ -0,0 +1,146 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/lottery/engine"
+
+	"github.com/pkg/errors"
+)
+
+func (h *HandlerSuite) TestGetStats() {
+	stats := db.Stats{
+		Rounds:       2,
+		TotalPool:    10_000,
+		AveragePool:  5_000,
+		TotalPaidOut: 7_500,
+		Payouts:      3,
+	}
+	h.statsMock.On("Get").Return(stats, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/stats", nil)
+	h.handler.GetStats(h.rec, h.req)
+
+	var response handler.StatsResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(stats, response.Stats)
+}
+
+func (h *HandlerSuite) TestGetStatsInternalError() {
+	expectedErr := errors.New("test err")
+	h.statsMock.On("Get").Return(db.Stats{}, expectedErr)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/stats", nil)
+	h.handler.GetStats(h.rec, h.req)
+
+	var response apierrors.Response
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusInternalServerError, h.rec.Code)
+	h.Equal(expectedErr.Error(), response.Error.Message)
+}
+
+func (h *HandlerSuite) TestGetBiggestWins() {
+	wins := []db.Win{{Height: 288, Prize: 5_000}, {Height: 144, Prize: 2_500}}
+	h.statsMock.On("ListBiggestWins", uint64(2)).Return(wins, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/stats/wins?limit=2", nil)
+	h.handler.GetBiggestWins(h.rec, h.req)
+
+	var response handler.WinsResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(wins, response.Wins)
+}
+
+func (h *HandlerSuite) TestGetBiggestWinsInvalidLimit() {
+	h.req = httptest.NewRequest(http.MethodGet, "/stats/wins?limit=two", nil)
+	h.handler.GetBiggestWins(h.rec, h.req)
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestGetRoundStats() {
+	rounds := []db.RoundStats{{Height: 288, PrizePool: 3_000, Players: 5, Winners: 4}}
+	h.statsMock.On("ListRounds", uint64(1), uint64(1), true).Return(rounds, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/stats/rounds?offset=1&limit=1&reverse=true", nil)
+	h.handler.GetRoundStats(h.rec, h.req)
+
+	var response handler.RoundsResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(rounds, response.Rounds)
+}
+
+func (h *HandlerSuite) TestGetRoundStatsPrivacy() {
+	rounds := []db.RoundStats{
+		{Height: 288, PrizePool: 3_450, Players: 5, Winners: 4},
+		{Height: 144, PrizePool: 900, Players: 2, Winners: 1},
+	}
+	h.statsMock.On("ListRounds", uint64(0), uint64(0), true).Return(rounds, nil)
+
+	database := &db.DB{Stats: h.statsMock}
+	privacy := lottery.StatsPrivacy{MinPlayers: 3, Bucket: 1_000}
+	h.req = httptest.NewRequest(http.MethodGet, "/stats/rounds?reverse=true", nil)
+	handler.New(h.lndMock, database, h.eventStreamerMock, h.auditorMock, h.publisherMock, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		nil, nil, nil, nil, nil, lottery.NewPools(nil), lottery.RemoteBalanceCapacity{}, privacy, engine.RoundingNearest,
+		config.LastTicket{}, config.LNURLPay{}, config.DrawSLO{}, h.reloaderMock, adminConfig).
+		GetRoundStats(h.rec, h.req)
+
+	var response handler.RoundsResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	expected := []db.RoundStats{
+		{Height: 288, PrizePool: 3_000, Players: 5, Winners: 4},
+		{Height: 144, Hidden: true},
+	}
+	h.Equal(expected, response.Rounds)
+}
+
+func (h *HandlerSuite) TestGetRoundStatsInvalidReverse() {
+	h.req = httptest.NewRequest(http.MethodGet, "/stats/rounds?reverse=maybe", nil)
+	h.handler.GetRoundStats(h.rec, h.req)
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestGetStreaks() {
+	streaks := []db.Streak{{Length: 3, EndHeight: 432}}
+	h.statsMock.On("ListStreaks", uint64(0)).Return(streaks, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/stats/streaks", nil)
+	h.handler.GetStreaks(h.rec, h.req)
+
+	var response handler.StreaksResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(streaks, response.Streaks)
+}
+
+func (h *HandlerSuite) TestGetStreaksInternalError() {
+	expectedErr := errors.New("test err")
+	h.statsMock.On("ListStreaks", uint64(0)).Return([]db.Streak(nil), expectedErr)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/stats/streaks", nil)
+	h.handler.GetStreaks(h.rec, h.req)
+
+	h.Equal(http.StatusInternalServerError, h.rec.Code)
+}
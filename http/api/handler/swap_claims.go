@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/pkg/errors"
+)
+
+// SwapClaimResponse is the response schema of the /claims/swap endpoint.
+type SwapClaimResponse struct {
+	// PaymentHash is the hash the hold invoice must be locked to
+	PaymentHash string `json:"payment_hash"`
+}
+
+// SwapPaymentResponse is the response schema of the /claims/swap/pay endpoint.
+type SwapPaymentResponse struct {
+	// Message is the receipt the winner countersigns to receive the preimage
+	Message   string `json:"message"`
+	Signature string `json:"signature"`
+	WithdrawResponse
+}
+
+// SwapPreimageResponse is the response schema of the /claims/swap/countersign endpoint.
+type SwapPreimageResponse struct {
+	Preimage string `json:"preimage"`
+}
+
+// StartSwapClaim responds with the payment hash of a new swap claim. The winner creates a hold
+// invoice locked to it, which can't be settled until the server reveals the preimage.
+func (h *Handler) StartSwapClaim(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := getSignedPublicKey(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	prizes, err := h.db.Prizes.Get(publicKey)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if prizes == 0 {
+		sendError(w, http.StatusBadRequest, db.ErrInsufficientPrizes)
+		return
+	}
+
+	paymentHash, err := h.swapClaims.Start(publicKey)
+	if err != nil {
+		if errors.Is(err, audit.ErrSigningDisabled) {
+			sendError(w, http.StatusNotFound, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, SwapClaimResponse{PaymentHash: paymentHash})
+}
+
+// PaySwapClaim pays the hold invoice of a swap claim with the winner's prizes and responds with the
+// receipt signed by the server. Large payouts wait for the operators approval like withdrawals.
+func (h *Handler) PaySwapClaim(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := getSignedPublicKey(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := policy.CheckAccess(h.db.AccessLists, h.auditor, publicKey, db.AccessWithdrawals); err != nil {
+		sendError(w, accessStatus(err), err)
+		return
+	}
+
+	query := r.URL.Query()
+	paymentRequest := query.Get("pr")
+	if paymentRequest == "" {
+		sendError(w, http.StatusBadRequest, errors.New("pr parameter missing"))
+		return
+	}
+
+	fees, err := parseFees(query["fee"], 1)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := r.Context()
+	invoice, err := h.decodeInvoice(ctx, paymentRequest, false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// The receipt is stored before paying so the swap claim can't pay another invoice
+	amount := uint64(invoice.NumSatoshis)
+	message, signature, err := h.swapClaims.Sign(publicKey, invoice.PaymentHash, amount)
+	if err != nil {
+		sendError(w, swapClaimStatus(err), err)
+		return
+	}
+
+	resp, status, err := h.payInvoices(ctx, publicKey, []string{paymentRequest},
+		[]*lnrpc.PayReq{invoice}, fees)
+	if err != nil {
+		sendError(w, status, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, SwapPaymentResponse{
+		Message:          message,
+		Signature:        signature,
+		WithdrawResponse: resp,
+	})
+}
+
+// CountersignSwapClaim verifies the winner's signature of a swap claim receipt and responds with
+// the preimage that settles the hold invoice.
+func (h *Handler) CountersignSwapClaim(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := getSignedPublicKey(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	query := r.URL.Query()
+	paymentHash := query.Get("payment_hash")
+	if paymentHash == "" {
+		sendError(w, http.StatusBadRequest, errors.New("payment_hash parameter missing"))
+		return
+	}
+
+	countersignature := query.Get("countersignature")
+	if countersignature == "" {
+		sendError(w, http.StatusBadRequest, errors.New("countersignature parameter missing"))
+		return
+	}
+
+	preimage, err := h.swapClaims.Countersign(publicKey, paymentHash, countersignature)
+	if err != nil {
+		sendError(w, swapClaimStatus(err), err)
+		return
+	}
+
+	h.auditor.Record(audit.SwapClaimCountersigned, map[string]any{
+		"public_key":       publicKey,
+		"payment_hash":     paymentHash,
+		"countersignature": countersignature,
+	})
+
+	sendResponse(w, http.StatusOK, SwapPreimageResponse{Preimage: preimage})
+}
+
+// GetSwapClaims responds with the swap claims of a public key, including their receipts, both
+// signatures, the prizes claimed and, once the receipts are countersigned, the preimages, to
+// resolve disputes.
+func (h *Handler) GetSwapClaims(w http.ResponseWriter, r *http.Request) {
+	publicKey := r.URL.Query().Get("pubkey")
+	if publicKey == "" {
+		sendError(w, http.StatusBadRequest, errors.New("pubkey parameter missing"))
+		return
+	}
+
+	claims, err := h.db.SwapClaims.List(publicKey)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, claims)
+}
+
+func swapClaimStatus(err error) int {
+	switch {
+	case errors.Is(err, db.ErrSwapClaimNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrSwapClaimUsed):
+		return http.StatusConflict
+	case errors.Is(err, policy.ErrSwapClaimNotPaid), errors.Is(err, policy.ErrInvalidCountersignature):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
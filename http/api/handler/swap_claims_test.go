@@ -0,0 +1,156 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/stretchr/testify/mock"
+)
+
+func (h *HandlerSuite) TestStartSwapClaim() {
+	h.req = httptest.NewRequest(http.MethodPost, "/claims/swap?signature="+validSignature, nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.prizesMock.On("Get", validPublicKey).Return(uint64(100_000), nil)
+	h.auditorMock.On("PublicKey").Return("server_pubkey")
+	h.swapClaimsMock.On("Add", mock.MatchedBy(func(claim db.SwapClaim) bool {
+		return claim.PublicKey == validPublicKey && claim.Preimage != ""
+	})).Return(nil)
+
+	h.handler.StartSwapClaim(h.rec, h.req)
+
+	var response handler.SwapClaimResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Len(response.PaymentHash, 64)
+}
+
+func (h *HandlerSuite) TestStartSwapClaimNoPrizes() {
+	h.req = httptest.NewRequest(http.MethodPost, "/claims/swap?signature="+validSignature, nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.prizesMock.On("Get", validPublicKey).Return(uint64(0), nil)
+
+	h.handler.StartSwapClaim(h.rec, h.req)
+
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+	h.swapClaimsMock.AssertNotCalled(h.T(), "Add", mock.Anything)
+}
+
+func (h *HandlerSuite) TestPaySwapClaim() {
+	query := url.Values{}
+	query.Add("signature", validSignature)
+	query.Add("pr", "lnbcrt")
+	query.Add("fee", "10")
+	h.req = httptest.NewRequest(http.MethodPost, "/claims/swap/pay?"+query.Encode(), nil)
+	h.SetAuthorizationKey(validPublicKey)
+	ctx := h.req.Context()
+
+	invoice := &lnrpc.PayReq{
+		PaymentHash: "hash",
+		NumSatoshis: 1_000,
+		Timestamp:   time.Now().Unix(),
+		Expiry:      3600,
+	}
+	h.lndMock.On("DecodeInvoice", ctx, "lnbcrt").Return(invoice, nil)
+	h.swapClaimsMock.On("Get", "hash").Return(db.SwapClaim{PublicKey: validPublicKey, PaymentHash: "hash"}, nil)
+	h.auditorMock.On("Sign", policy.SwapClaimDomain, mock.Anything).Return("server_signature", nil)
+	h.swapClaimsMock.On("SetReceipt", "hash", mock.Anything, "server_signature").Return(nil)
+
+	claims := []db.PrizesRow{{RowID: 1, Amount: 1_010}}
+	h.prizesMock.On("Get", validPublicKey).Return(uint64(1_010), nil)
+	h.prizesMock.On("Claim", validPublicKey, "hash", uint64(1_010)).Return(claims, nil)
+	h.eventStreamerMock.On("TrackWithdrawal", "hash", validPublicKey, claims).Return(uint64(7))
+	h.lndMock.On("PayInvoice", mock.Anything, invoice, int64(10), true).Return(nil, nil)
+	h.eventStreamerMock.On("WatchPayment", invoice.PaymentHash, nil)
+
+	h.handler.PaySwapClaim(h.rec, h.req)
+
+	var response handler.SwapPaymentResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal("server_signature", response.Signature)
+	h.Equal(uint64(7), response.PaymentID)
+
+	var receipt policy.SwapReceipt
+	h.NoError(json.Unmarshal([]byte(response.Message), &receipt))
+	h.Equal(validPublicKey, receipt.PublicKey)
+	h.Equal("hash", receipt.PaymentHash)
+	h.Equal(uint64(1_000), receipt.Amount)
+}
+
+func (h *HandlerSuite) TestPaySwapClaimUsed() {
+	query := url.Values{}
+	query.Add("signature", validSignature)
+	query.Add("pr", "lnbcrt")
+	query.Add("fee", "10")
+	h.req = httptest.NewRequest(http.MethodPost, "/claims/swap/pay?"+query.Encode(), nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	invoice := &lnrpc.PayReq{PaymentHash: "hash", NumSatoshis: 1_000, Timestamp: time.Now().Unix(), Expiry: 3600}
+	h.lndMock.On("DecodeInvoice", h.req.Context(), "lnbcrt").Return(invoice, nil)
+	claim := db.SwapClaim{PublicKey: validPublicKey, PaymentHash: "hash", Message: "receipt"}
+	h.swapClaimsMock.On("Get", "hash").Return(claim, nil)
+
+	h.handler.PaySwapClaim(h.rec, h.req)
+
+	h.Equal(http.StatusConflict, h.rec.Code)
+	h.prizesMock.AssertNotCalled(h.T(), "Claim", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestCountersignSwapClaimNotPaid() {
+	h.req = httptest.NewRequest(http.MethodPost,
+		"/claims/swap/countersign?payment_hash=hash&countersignature=sig&signature="+validSignature, nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.swapClaimsMock.On("Get", "hash").Return(db.SwapClaim{PublicKey: validPublicKey, PaymentHash: "hash"}, nil)
+
+	h.handler.CountersignSwapClaim(h.rec, h.req)
+
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+	h.auditorMock.AssertNotCalled(h.T(), "Record", audit.SwapClaimCountersigned, mock.Anything)
+}
+
+func (h *HandlerSuite) TestCountersignSwapClaimOtherPublicKey() {
+	h.req = httptest.NewRequest(http.MethodPost,
+		"/claims/swap/countersign?payment_hash=hash&countersignature=sig&signature="+validSignature, nil)
+	h.SetAuthorizationKey(validPublicKey)
+
+	h.swapClaimsMock.On("Get", "hash").Return(db.SwapClaim{PublicKey: "pubkey", Message: "receipt"}, nil)
+
+	h.handler.CountersignSwapClaim(h.rec, h.req)
+
+	h.Equal(http.StatusNotFound, h.rec.Code)
+}
+
+func (h *HandlerSuite) TestGetSwapClaims() {
+	claims := []db.SwapClaim{{
+		PublicKey:   "pubkey",
+		PaymentHash: "hash",
+		Prizes:      []db.Prize{{LotteryHeight: 1, Amount: 100}},
+	}}
+	h.swapClaimsMock.On("List", "pubkey").Return(claims, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/claims/swap?pubkey=pubkey", nil)
+	h.handler.GetSwapClaims(h.rec, h.req)
+
+	var response []db.SwapClaim
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(claims, response)
+}
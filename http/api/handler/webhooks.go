@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+	"github.com/aftermath2/BTRY/webhooks"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	maxWebhookURLLength = 512
+	// maxWebhooks is the number of webhooks each API key can subscribe
+	maxWebhooks = 10
+)
+
+// WebhookResponse is the response schema of the POST /webhooks endpoint. The secret used to sign
+// the payloads is only returned once.
+type WebhookResponse struct {
+	Secret  string     `json:"secret"`
+	Webhook db.Webhook `json:"webhook"`
+}
+
+// DeleteWebhookResponse is the response schema of the DELETE /webhooks endpoint.
+type DeleteWebhookResponse struct {
+	Success bool `json:"success,omitempty"`
+}
+
+// GetWebhooks responds with the webhooks subscribed by the API key.
+func (h *Handler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	apiKey, _ := middleware.APIKeyFromContext(r.Context())
+
+	webhooks, err := h.db.Webhooks.List(apiKey.ID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, webhooks)
+}
+
+// CreateWebhook subscribes a URL to the events specified.
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	apiKey, _ := middleware.APIKeyFromContext(r.Context())
+	query := r.URL.Query()
+
+	webhookURL := query.Get("url")
+	if err := h.validateWebhookURL(r.Context(), webhookURL); err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	events, err := webhooks.ParseEvents(query.Get("events"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	subscribed, err := h.db.Webhooks.List(apiKey.ID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(subscribed) >= maxWebhooks {
+		sendError(w, http.StatusBadRequest, errors.Errorf("webhooks limit of %d reached", maxWebhooks))
+		return
+	}
+
+	secretBytes, err := randomBytes(32)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	secret := hex.EncodeToString(secretBytes)
+
+	webhook, err := h.db.Webhooks.Add(apiKey.ID, webhookURL, secret, events)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := WebhookResponse{
+		Secret:  secret,
+		Webhook: webhook,
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// DeleteWebhook unsubscribes a webhook of the API key.
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	apiKey, _ := middleware.APIKeyFromContext(r.Context())
+
+	id, err := parseIntParam(r.URL.Query(), "id", true)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.db.Webhooks.Delete(id, apiKey.ID); err != nil {
+		if errors.Is(err, db.ErrNoWebhook) {
+			sendError(w, http.StatusNotFound, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := DeleteWebhookResponse{Success: true}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// ListWebhooks responds with the webhooks subscribed by every API key.
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.db.Webhooks.List(0)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, webhooks)
+}
+
+// GetWebhookDeliveries responds with the log of the deliveries to a webhook, the newest first.
+func (h *Handler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	id, err := parseIntParam(query, "id", true)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	offset, err := parseIntParam(query, "offset", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, err := parseIntParam(query, "limit", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	deliveries, err := h.db.Webhooks.ListDeliveries(id, offset, limit)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, deliveries)
+}
+
+// validateWebhookURL checks the length of the URL and that it's a public http or https address,
+// so integrators can't make the server send requests to its own network.
+func (h *Handler) validateWebhookURL(ctx context.Context, rawURL string) error {
+	if rawURL == "" || len(rawURL) > maxWebhookURLLength {
+		return errors.New("invalid url")
+	}
+
+	return h.publisher.ValidateURL(ctx, rawURL)
+}
@@ -0,0 +1,197 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+	"github.com/aftermath2/BTRY/webhooks"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+var integrator = db.APIKey{ID: 7, Name: "kiosk", Scopes: []db.Scope{db.ScopeWebhooks}}
+
+func (h *HandlerSuite) TestGetWebhooks() {
+	webhooks := []db.Webhook{{ID: 1, APIKeyID: integrator.ID, URL: "https://example.com", Events: []string{"draw_completed"}}}
+	h.webhooksMock.On("List", integrator.ID).Return(webhooks, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/webhooks", nil)
+	h.req = h.req.WithContext(withAPIKey(h.req.Context(), integrator))
+	h.handler.GetWebhooks(h.rec, h.req)
+
+	var response []db.Webhook
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(webhooks, response)
+}
+
+func (h *HandlerSuite) TestCreateWebhook() {
+	events := []string{"draw_completed", "payout_sent"}
+	webhook := db.Webhook{ID: 1, APIKeyID: integrator.ID, URL: "https://example.com/hook", Events: events}
+	h.webhooksMock.On("List", integrator.ID).Return([]db.Webhook{}, nil)
+	h.webhooksMock.On("Add", integrator.ID, webhook.URL, mock.Anything, events).Return(webhook, nil)
+	h.publisherMock.On("ValidateURL", mock.Anything, webhook.URL).Return(nil)
+
+	h.req = httptest.NewRequest(http.MethodPost, "/webhooks?url=https://example.com/hook&events=draw_completed,payout_sent", nil)
+	h.req = h.req.WithContext(withAPIKey(h.req.Context(), integrator))
+	h.handler.CreateWebhook(h.rec, h.req)
+
+	var response handler.WebhookResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Len(response.Secret, 64)
+	h.Equal(webhook, response.Webhook)
+	h.webhooksMock.AssertCalled(h.T(), "Add", integrator.ID, webhook.URL, response.Secret, events)
+}
+
+func (h *HandlerSuite) TestCreateWebhookInvalid() {
+	cases := []struct {
+		validateErr error
+		desc        string
+		url         string
+	}{
+		{
+			desc: "Missing url",
+			url:  "/webhooks?events=draw_completed",
+		},
+		{
+			desc:        "Invalid scheme",
+			url:         "/webhooks?url=ftp://example.com&events=draw_completed",
+			validateErr: errors.New("invalid url, it must be an http or https address"),
+		},
+		{
+			desc:        "Non-public address",
+			url:         "/webhooks?url=http://127.0.0.1:8080&events=draw_completed",
+			validateErr: webhooks.ErrNonPublicAddress,
+		},
+		{
+			desc: "Invalid event",
+			url:  "/webhooks?url=https://example.com&events=bet_accepted",
+		},
+		{
+			desc: "Limit reached",
+			url:  "/webhooks?url=https://example.com&events=draw_completed",
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.SetupTest()
+			h.webhooksMock.On("List", integrator.ID).Return(make([]db.Webhook, 10), nil)
+			h.publisherMock.On("ValidateURL", mock.Anything, mock.Anything).Return(tc.validateErr).Maybe()
+
+			h.req = httptest.NewRequest(http.MethodPost, tc.url, nil)
+			h.req = h.req.WithContext(withAPIKey(h.req.Context(), integrator))
+			h.handler.CreateWebhook(h.rec, h.req)
+
+			h.Equal(http.StatusBadRequest, h.rec.Code)
+			h.webhooksMock.AssertNotCalled(h.T(), "Add", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func (h *HandlerSuite) TestDeleteWebhook() {
+	cases := []struct {
+		err          error
+		desc         string
+		expectedCode int
+	}{
+		{
+			desc:         "Deleted",
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc:         "Not found",
+			err:          db.ErrNoWebhook,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			desc:         "Error",
+			err:          errors.New("test"),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			h.SetupTest()
+			h.webhooksMock.On("Delete", uint64(1), integrator.ID).Return(tc.err)
+
+			h.req = httptest.NewRequest(http.MethodDelete, "/webhooks?id=1", nil)
+			h.req = h.req.WithContext(withAPIKey(h.req.Context(), integrator))
+			h.handler.DeleteWebhook(h.rec, h.req)
+
+			h.Equal(tc.expectedCode, h.rec.Code)
+		})
+	}
+}
+
+func (h *HandlerSuite) TestListWebhooks() {
+	webhooks := []db.Webhook{{ID: 1, APIKeyID: 2}, {ID: 2, APIKeyID: 3}}
+	h.webhooksMock.On("List", uint64(0)).Return(webhooks, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/webhooks", nil)
+	h.handler.ListWebhooks(h.rec, h.req)
+
+	var response []db.Webhook
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(webhooks, response)
+}
+
+func (h *HandlerSuite) TestGetWebhookDeliveries() {
+	deliveries := []db.WebhookDelivery{
+		{ID: 2, WebhookID: 1, DeliveryID: "b", Event: "payout_sent", StatusCode: http.StatusOK},
+		{ID: 1, WebhookID: 1, DeliveryID: "a", Event: "draw_completed", Error: "timeout"},
+	}
+	h.webhooksMock.On("ListDeliveries", uint64(1), uint64(0), uint64(10)).Return(deliveries, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/webhooks/deliveries?id=1&limit=10", nil)
+	h.handler.GetWebhookDeliveries(h.rec, h.req)
+
+	var response []db.WebhookDelivery
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(deliveries, response)
+
+	h.SetupTest()
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/webhooks/deliveries", nil)
+	h.handler.GetWebhookDeliveries(h.rec, h.req)
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+}
+
+// withAPIKey runs the request through the API keys middleware so the key is set in the context.
+func withAPIKey(ctx context.Context, apiKey db.APIKey) context.Context {
+	apiKeysMock := db.NewAPIKeysStoreMock()
+	apiKeysMock.On("Get", mock.Anything).Return(apiKey, nil)
+	apiKeys, _ := middleware.NewAPIKeys(config.RateLimiter{Tokens: 10, Interval: time.Minute},
+		&db.DB{APIKeys: apiKeysMock})
+
+	var result context.Context
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result = r.Context()
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	req.Header.Set(middleware.APIKeyHeader, "key")
+	noLimit := func(next http.Handler) http.Handler { return next }
+	apiKeys.Limit(noLimit)(next).ServeHTTP(httptest.NewRecorder(), req)
+
+	return result
+}
@@ -2,17 +2,41 @@ package handler
 
 import (
 	"net/http"
+	"net/url"
+	"strconv"
 
+	"github.com/aftermath2/BTRY/crypto"
 	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/pkg/errors"
 )
 
 // WinnersResponse is the response schema of the /winners endpoint.
 type WinnersResponse struct {
+	// Reveal is set when the winners are revealed one tier at a time, only the tiers revealed are
+	// listed until it completes
+	Reveal  *db.Reveal  `json:"reveal,omitempty"`
+	Winners []db.Winner `json:"winners,omitempty"`
+}
+
+// WinnersSearchResponse is the response schema of the /winners/search endpoint.
+type WinnersSearchResponse struct {
 	Winners []db.Winner `json:"winners,omitempty"`
 }
 
-// GetWinners responds with the list of winners.
+// GetWinners responds with the list of winners, displayed according to their privacy
+// preferences.
 func (h *Handler) GetWinners(w http.ResponseWriter, r *http.Request) {
+	h.sendWinners(w, r, true)
+}
+
+// GetAdminWinners responds with the list of winners and their full public keys.
+func (h *Handler) GetAdminWinners(w http.ResponseWriter, r *http.Request) {
+	h.sendWinners(w, r, false)
+}
+
+func (h *Handler) sendWinners(w http.ResponseWriter, r *http.Request, anonymize bool) {
 	query := r.URL.Query()
 
 	height, err := parseIntParam(query, "height", true)
@@ -21,14 +45,153 @@ func (h *Handler) GetWinners(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	winners, err := h.db.Winners.List(uint32(height))
+	replica := h.db.ReadReplica()
+	winners, err := replica.Winners.List(uint32(height))
 	if err != nil {
 		sendError(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	var reveal *db.Reveal
+	progress, err := replica.Reveals.Get(uint32(height))
+	switch {
+	case err == nil:
+		if anonymize {
+			progress = progress.Disclosed()
+		}
+		reveal = &progress
+	case !errors.Is(err, db.ErrRevealNotFound):
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if anonymize {
+		if reveal != nil {
+			winners = reveal.Revealed(winners)
+		}
+		winners, err = policy.AnonymizeWinners(replica.Privacy, winners)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
 	resp := WinnersResponse{
+		Reveal:  reveal,
 		Winners: winners,
 	}
 	sendResponse(w, http.StatusOK, resp)
 }
+
+// SearchWinners responds with the winners of every lottery filtered by public key, height range
+// and claim status. The tiers that are still hidden are left out and a public key is only looked up
+// if its owner displays it in full.
+func (h *Handler) SearchWinners(w http.ResponseWriter, r *http.Request) {
+	h.searchWinners(w, r, true)
+}
+
+// SearchAdminWinners responds with the winners of every lottery filtered by public key, height
+// range and claim status, including the tiers that are still hidden.
+func (h *Handler) SearchAdminWinners(w http.ResponseWriter, r *http.Request) {
+	h.searchWinners(w, r, false)
+}
+
+func (h *Handler) searchWinners(w http.ResponseWriter, r *http.Request, anonymize bool) {
+	query := r.URL.Query()
+
+	filter, err := parseWinnersFilter(query)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+	filter.Revealed = anonymize
+
+	offset, err := parseIntParam(query, "offset", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, err := parseIntParam(query, "limit", false)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	reverse := false
+	reverseStr := query.Get("reverse")
+	if reverseStr != "" {
+		v, err := strconv.ParseBool(reverseStr)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, errors.Wrap(err, "invalid reverse parameter"))
+			return
+		}
+		reverse = v
+	}
+
+	replica := h.db.ReadReplica()
+	if anonymize && filter.PublicKey != "" {
+		privacy, err := replica.Privacy.Get(filter.PublicKey)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+		// Looking up the players that chose not to be displayed would tell whether they won
+		if privacy.Display != db.DisplayFull {
+			sendResponse(w, http.StatusOK, WinnersSearchResponse{})
+			return
+		}
+	}
+
+	winners, err := replica.Winners.Search(filter, offset, limit, reverse)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if anonymize {
+		winners, err = policy.AnonymizeWinners(replica.Privacy, winners)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	sendResponse(w, http.StatusOK, WinnersSearchResponse{Winners: winners})
+}
+
+func parseWinnersFilter(query url.Values) (db.WinnersFilter, error) {
+	from, err := parseIntParam(query, "from", false)
+	if err != nil {
+		return db.WinnersFilter{}, err
+	}
+
+	to, err := parseIntParam(query, "to", false)
+	if err != nil {
+		return db.WinnersFilter{}, err
+	}
+	if to != 0 && to < from {
+		return db.WinnersFilter{}, errors.New("to must be greater than or equal to from")
+	}
+
+	status := query.Get("status")
+	switch status {
+	case "", db.ClaimClaimed, db.ClaimUnclaimed, db.ClaimExpired:
+	default:
+		return db.WinnersFilter{}, errors.Errorf("invalid status %q", status)
+	}
+
+	publicKey := query.Get("pubkey")
+	if publicKey != "" {
+		if err := crypto.ValidatePublicKey(publicKey); err != nil {
+			return db.WinnersFilter{}, err
+		}
+	}
+
+	return db.WinnersFilter{
+		PublicKey:  publicKey,
+		Status:     status,
+		FromHeight: uint32(from),
+		ToHeight:   uint32(to),
+	}, nil
+}
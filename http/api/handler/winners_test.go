@@ -6,9 +6,11 @@ import (
 	"net/http/httptest"
 
 	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
 	"github.com/aftermath2/BTRY/http/api/handler"
 
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
 )
 
 func (h *HandlerSuite) TestGetWinners() {
@@ -25,6 +27,10 @@ func (h *HandlerSuite) TestGetWinners() {
 		},
 	}
 	h.winnersMock.On("List", uint32(0)).Return(winners, nil)
+	h.revealsMock.On("Get", uint32(0)).Return(db.Reveal{}, db.ErrRevealNotFound)
+	h.privacyMock.On("List", []string{"pubkey", "pubkey2"}).Return(map[string]db.Privacy{
+		"pubkey2": {Display: db.DisplayAlias, Alias: "lucky"},
+	}, nil)
 
 	h.req = httptest.NewRequest(http.MethodGet, "/winners?height=0", nil)
 	h.handler.GetWinners(h.rec, h.req)
@@ -33,8 +39,50 @@ func (h *HandlerSuite) TestGetWinners() {
 	err := json.NewDecoder(h.rec.Body).Decode(&response)
 	h.NoError(err)
 
+	expected := []db.Winner{winners[0], {Alias: "lucky", Prize: 100, Ticket: 5}}
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(expected, response.Winners)
+}
+
+func (h *HandlerSuite) TestGetWinnersRevealing() {
+	winners := []db.Winner{
+		{PublicKey: "first", Prize: 100, Ticket: 5, Tier: 0},
+		{PublicKey: "second", Prize: 10, Ticket: 8, Tier: 1},
+	}
+	reveal := db.Reveal{LotteryHeight: 10, ResultsHash: "hash", Salt: "salt", Tiers: 2, Hidden: 1, Blocks: 6}
+	h.winnersMock.On("List", uint32(10)).Return(winners, nil)
+	h.revealsMock.On("Get", uint32(10)).Return(reveal, nil)
+	h.privacyMock.On("List", []string{"second"}).Return(map[string]db.Privacy{}, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/winners?height=10", nil)
+	h.handler.GetWinners(h.rec, h.req)
+
+	var response handler.WinnersResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal([]db.Winner{{PublicKey: "second", Prize: 10, Ticket: 8}}, response.Winners)
+	// The salt is kept secret until the first place is revealed
+	reveal.Salt = ""
+	h.Equal(&reveal, response.Reveal)
+}
+
+func (h *HandlerSuite) TestGetAdminWinners() {
+	winners := []db.Winner{{PublicKey: "pubkey", Prize: 1, Ticket: 1}}
+	h.winnersMock.On("List", uint32(10)).Return(winners, nil)
+	h.revealsMock.On("Get", uint32(10)).Return(db.Reveal{}, db.ErrRevealNotFound)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/winners?height=10", nil)
+	h.handler.GetAdminWinners(h.rec, h.req)
+
+	var response handler.WinnersResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
 	h.Equal(http.StatusOK, h.rec.Code)
 	h.Equal(winners, response.Winners)
+	h.privacyMock.AssertNotCalled(h.T(), "List", mock.Anything)
 }
 
 func (h *HandlerSuite) TestGetWinnersInvalidHeight() {
@@ -50,10 +98,95 @@ func (h *HandlerSuite) TestGetWinnersInternalError() {
 	h.req = httptest.NewRequest(http.MethodGet, "/winners?height=0", nil)
 	h.handler.GetWinners(h.rec, h.req)
 
-	var response handler.ErrorResponse
+	var response apierrors.Response
 	err := json.NewDecoder(h.rec.Body).Decode(&response)
 	h.NoError(err)
 
 	h.Equal(http.StatusInternalServerError, h.rec.Code)
-	h.Equal(expectedErr.Error(), response.Error)
+	h.Equal(expectedErr.Error(), response.Error.Message)
+}
+
+func (h *HandlerSuite) TestSearchWinners() {
+	publicKey := "7d959d6d552c7d38b3ecafb72805fa03a6dee6b7f0c5f63f57a371736cb004b1"
+	winners := []db.Winner{
+		{ID: 3, PublicKey: publicKey, Prize: 75, Ticket: 21, LotteryHeight: 144, Status: db.ClaimUnclaimed},
+	}
+	filter := db.WinnersFilter{
+		PublicKey:  publicKey,
+		Status:     db.ClaimUnclaimed,
+		FromHeight: 100,
+		ToHeight:   200,
+		Revealed:   true,
+	}
+	h.privacyMock.On("Get", publicKey).Return(db.Privacy{Display: db.DisplayFull}, nil)
+	h.winnersMock.On("Search", filter, uint64(2), uint64(10), true).Return(winners, nil)
+	h.privacyMock.On("List", []string{publicKey}).Return(map[string]db.Privacy{}, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet,
+		"/winners/search?pubkey="+publicKey+"&status=unclaimed&from=100&to=200&offset=2&limit=10&reverse=true", nil)
+	h.handler.SearchWinners(h.rec, h.req)
+
+	var response handler.WinnersSearchResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(winners, response.Winners)
+}
+
+func (h *HandlerSuite) TestSearchWinnersHiddenPublicKey() {
+	publicKey := "7d959d6d552c7d38b3ecafb72805fa03a6dee6b7f0c5f63f57a371736cb004b1"
+	h.privacyMock.On("Get", publicKey).Return(db.Privacy{Display: db.DisplayAlias, Alias: "lucky"}, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/winners/search?pubkey="+publicKey, nil)
+	h.handler.SearchWinners(h.rec, h.req)
+
+	var response handler.WinnersSearchResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Empty(response.Winners)
+	h.winnersMock.AssertNotCalled(h.T(), "Search", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestSearchAdminWinners() {
+	publicKey := "7d959d6d552c7d38b3ecafb72805fa03a6dee6b7f0c5f63f57a371736cb004b1"
+	winners := []db.Winner{
+		{ID: 1, PublicKey: publicKey, Prize: 75, Ticket: 21, LotteryHeight: 144, Status: db.ClaimExpired},
+	}
+	filter := db.WinnersFilter{PublicKey: publicKey, Status: db.ClaimExpired}
+	h.winnersMock.On("Search", filter, uint64(0), uint64(0), false).Return(winners, nil)
+
+	h.req = httptest.NewRequest(http.MethodGet, "/admin/winners/search?pubkey="+publicKey+"&status=expired", nil)
+	h.handler.SearchAdminWinners(h.rec, h.req)
+
+	var response handler.WinnersSearchResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(winners, response.Winners)
+	h.privacyMock.AssertNotCalled(h.T(), "Get", mock.Anything)
+}
+
+func (h *HandlerSuite) TestSearchWinnersInvalidParams() {
+	cases := []struct {
+		desc  string
+		query string
+	}{
+		{desc: "Invalid status", query: "status=paid"},
+		{desc: "Invalid public key", query: "pubkey=7d959d6d"},
+		{desc: "Invalid range", query: "from=200&to=100"},
+		{desc: "Invalid offset", query: "offset=one"},
+		{desc: "Invalid reverse", query: "reverse=maybe"},
+	}
+
+	for _, tc := range cases {
+		h.Run(tc.desc, func() {
+			rec := httptest.NewRecorder()
+			h.handler.SearchWinners(rec, httptest.NewRequest(http.MethodGet, "/winners/search?"+tc.query, nil))
+			h.Equal(http.StatusBadRequest, rec.Code)
+		})
+	}
 }
@@ -1,11 +1,18 @@
 package handler
 
 import (
+	"context"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/aftermath2/BTRY/audit"
 	"github.com/aftermath2/BTRY/crypto"
+	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+	"github.com/aftermath2/BTRY/policy"
 
+	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/pkg/errors"
 )
 
@@ -13,9 +20,20 @@ import (
 type WithdrawResponse struct {
 	Status    string `json:"status,omitempty"`
 	PaymentID uint64 `json:"payment_id,omitempty"`
+	// PaymentIDs contains the ID of every payment when the withdrawal is split into many invoices
+	PaymentIDs []uint64 `json:"payment_ids,omitempty"`
+	// ApprovalIDs is set instead of the payment IDs when the withdrawal must be approved by the
+	// operators, the invoices are paid once they do
+	ApprovalIDs []uint64 `json:"approval_ids,omitempty"`
 }
 
+// maxWithdrawalInvoices is the maximum number of invoices a withdrawal can be split into.
+const maxWithdrawalInvoices = 5
+
 // Withdraw handles a withdrawal request by attempting to pay an invoice.
+//
+// Prizes can be claimed partially and split across multiple destinations by repeating the pr and
+// fee parameters, one pair per invoice.
 func (h *Handler) Withdraw(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 
@@ -36,56 +54,286 @@ func (h *Handler) Withdraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	paymentRequest := query.Get("pr")
-	if paymentRequest == "" {
-		sendLNURLError(w, http.StatusBadRequest, errors.New("pr parameter missing"))
+	h.withdraw(w, r, publicKey)
+}
+
+// withdraw pays the invoices in the request with the prizes of the public key.
+func (h *Handler) withdraw(w http.ResponseWriter, r *http.Request, publicKey string) {
+	resp, status, err := h.withdrawPrizes(r, publicKey)
+	if err != nil {
+		sendLNURLError(w, status, err)
 		return
 	}
 
-	fee, err := parseIntParam(query, "fee", true)
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// withdrawPrizes pays the invoices in the request with the prizes of the public key. On failure,
+// it returns the status code to respond with.
+func (h *Handler) withdrawPrizes(r *http.Request, publicKey string) (WithdrawResponse, int, error) {
+	if err := policy.CheckAccess(h.db.AccessLists, h.auditor, publicKey, db.AccessWithdrawals); err != nil {
+		return WithdrawResponse{}, accessStatus(err), err
+	}
+
+	query := r.URL.Query()
+
+	paymentRequests := query["pr"]
+	if len(paymentRequests) == 0 || paymentRequests[0] == "" {
+		return WithdrawResponse{}, http.StatusBadRequest, errors.New("pr parameter missing")
+	}
+
+	if len(paymentRequests) > maxWithdrawalInvoices {
+		err := errors.Errorf("a withdrawal can be split into %d invoices at most", maxWithdrawalInvoices)
+		return WithdrawResponse{}, http.StatusBadRequest, err
+	}
+
+	fees, err := parseFees(query["fee"], len(paymentRequests))
 	if err != nil {
-		sendLNURLError(w, http.StatusBadRequest, err)
-		return
+		return WithdrawResponse{}, http.StatusBadRequest, err
 	}
 
 	ctx := r.Context()
 
+	// The amount of a zero-amount invoice is set by the server, which is only unambiguous when the
+	// prizes are withdrawn to a single destination
+	zeroAmount := len(paymentRequests) == 1
+
+	invoices := make([]*lnrpc.PayReq, 0, len(paymentRequests))
+	for _, paymentRequest := range paymentRequests {
+		invoice, err := h.decodeInvoice(ctx, paymentRequest, zeroAmount)
+		if err != nil {
+			return WithdrawResponse{}, http.StatusBadRequest, err
+		}
+
+		invoices = append(invoices, invoice)
+	}
+
+	return h.payInvoices(ctx, publicKey, paymentRequests, invoices, fees)
+}
+
+// decodeInvoice decodes an invoice to be paid with prizes. Zero-amount invoices are rejected unless
+// zeroAmount is true.
+func (h *Handler) decodeInvoice(
+	ctx context.Context,
+	paymentRequest string,
+	zeroAmount bool,
+) (*lnrpc.PayReq, error) {
 	invoice, err := h.lnd.DecodeInvoice(ctx, paymentRequest)
 	if err != nil {
-		sendLNURLError(w, http.StatusBadRequest, err)
-		return
+		return nil, err
 	}
 
-	if invoice.NumSatoshis == 0 {
-		sendLNURLError(w, http.StatusBadRequest, errors.New("invalid invoice amount"))
-		return
+	if invoice.NumSatoshis < 0 || (invoice.NumSatoshis == 0 && !zeroAmount) {
+		return nil, errors.New("invalid invoice amount")
 	}
 
 	if time.Now().Unix() >= (invoice.Timestamp + invoice.Expiry) {
-		sendLNURLError(w, http.StatusBadRequest, errors.New("invoice expired"))
-		return
+		return nil, errors.New("invoice expired")
 	}
 
-	withdrawAmount := uint64(invoice.NumSatoshis) + fee
+	return invoice, nil
+}
 
-	// Here the invoice amount is deducted from the public key prize and persisted, if the payment
-	// fails, the user will get its funds restored.
-	// It's done this way to not let users request more funds than they have.
-	if err := h.db.Prizes.Withdraw(publicKey, withdrawAmount); err != nil {
-		sendLNURLError(w, http.StatusBadRequest, err)
-		return
+// payInvoices pays the invoices with the prizes of the public key, or holds them until the
+// operators approve them. On failure, it returns the status code to respond with.
+func (h *Handler) payInvoices(
+	ctx context.Context,
+	publicKey string,
+	paymentRequests []string,
+	invoices []*lnrpc.PayReq,
+	fees []uint64,
+) (WithdrawResponse, int, error) {
+	if err := h.setClaimAmounts(publicKey, invoices, fees); err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			return WithdrawResponse{}, http.StatusBadRequest, err
+		}
+		return WithdrawResponse{}, http.StatusInternalServerError, err
 	}
 
-	paymentID := h.eventStreamer.TrackPayment(invoice.PaymentHash, publicKey, withdrawAmount)
+	// Here the invoices amount is deducted from the public key prizes and persisted along with the
+	// invoice payment hash, if a payment fails, the user will get its funds restored to the prizes
+	// they were claimed from.
+	// It's done this way to not let users request more funds than they have nor pay the same invoice
+	// twice when concurrent requests pass the check above.
+	claims := make([][]db.PrizesRow, len(invoices))
+	for i, invoice := range invoices {
+		var err error
+		claims[i], err = h.db.Prizes.Claim(publicKey, invoice.PaymentHash, uint64(invoice.NumSatoshis)+fees[i])
+		if err != nil {
+			if err := h.restoreClaims(claims[:i]); err != nil {
+				return WithdrawResponse{}, http.StatusInternalServerError, err
+			}
+			return WithdrawResponse{}, claimStatus(err), err
+		}
+	}
 
-	if _, err := h.lnd.PayInvoice(ctx, invoice, int64(fee), false); err != nil {
-		sendLNURLError(w, http.StatusInternalServerError, err)
-		return
+	var total uint64
+	for i, invoice := range invoices {
+		total += uint64(invoice.NumSatoshis) + fees[i]
+	}
+
+	if h.approvals.Required(total) {
+		return h.holdWithdrawal(publicKey, paymentRequests, invoices, fees, claims)
+	}
+
+	paymentIDs := make([]uint64, 0, len(invoices))
+	for i, invoice := range invoices {
+		paymentID := h.eventStreamer.TrackWithdrawal(invoice.PaymentHash, publicKey, claims[i])
+
+		// The payment outlives the request, its updates are followed by the event streamer
+		updates, payErr := h.lnd.PayInvoice(context.WithoutCancel(ctx), invoice, int64(fees[i]), true)
+		if payErr != nil {
+			// The payments that weren't attempted won't fail, restore them now
+			h.eventStreamer.Untrack(invoice.PaymentHash)
+			if err := h.restoreClaims(claims[i:]); err != nil {
+				return WithdrawResponse{}, http.StatusInternalServerError, err
+			}
+			if len(paymentIDs) == 0 {
+				return WithdrawResponse{}, http.StatusInternalServerError, payErr
+			}
+
+			// The invoices paid already are followed like in a successful withdrawal
+			err := apierrors.New(apierrors.CodeInternal, payErr.Error()).
+				WithDetail("payment_ids", paymentIDs)
+			return WithdrawResponse{}, http.StatusInternalServerError, err
+		}
+		h.eventStreamer.WatchPayment(invoice.PaymentHash, updates)
+
+		paymentIDs = append(paymentIDs, paymentID)
 	}
 
 	resp := WithdrawResponse{
-		PaymentID: paymentID,
+		PaymentID: paymentIDs[0],
 		Status:    "OK",
 	}
-	sendResponse(w, http.StatusOK, resp)
+	if len(paymentIDs) > 1 {
+		resp.PaymentIDs = paymentIDs
+	}
+	return resp, http.StatusOK, nil
+}
+
+// holdWithdrawal stores the invoices of a withdrawal to be paid once the operators approve them.
+// The prizes remain deducted until then.
+func (h *Handler) holdWithdrawal(
+	publicKey string,
+	paymentRequests []string,
+	invoices []*lnrpc.PayReq,
+	fees []uint64,
+	claims [][]db.PrizesRow,
+) (WithdrawResponse, int, error) {
+	approvalIDs := make([]uint64, 0, len(invoices))
+	for i, invoice := range invoices {
+		approval, err := h.approvals.Request(policy.PayoutRequest{
+			PublicKey:      publicKey,
+			PaymentRequest: paymentRequests[i],
+			PaymentHash:    invoice.PaymentHash,
+			Claims:         claims[i],
+			Amount:         uint64(invoice.NumSatoshis),
+			Fee:            fees[i],
+			InvoiceExpiry:  time.Unix(invoice.Timestamp+invoice.Expiry, 0),
+		})
+		if err != nil {
+			// The approvals already requested are returned when they expire
+			if err := h.restoreClaims(claims[i:]); err != nil {
+				return WithdrawResponse{}, http.StatusInternalServerError, err
+			}
+			return WithdrawResponse{}, http.StatusInternalServerError, err
+		}
+
+		h.auditor.Record(audit.PayoutHeld, map[string]any{
+			"approval_id": approval.ID,
+			"public_key":  publicKey,
+			"amount":      approval.Amount,
+			"expires_at":  approval.ExpiresAt,
+		})
+		approvalIDs = append(approvalIDs, approval.ID)
+	}
+
+	resp := WithdrawResponse{
+		Status:      "OK",
+		ApprovalIDs: approvalIDs,
+	}
+	return resp, http.StatusOK, nil
+}
+
+// setClaimAmounts sets the amount of the zero-amount invoices to the prizes claimable after the
+// fee and verifies that the invoices don't claim more than that.
+func (h *Handler) setClaimAmounts(publicKey string, invoices []*lnrpc.PayReq, fees []uint64) error {
+	claimable, err := h.db.Prizes.Get(publicKey)
+	if err != nil {
+		return errors.Wrap(err, "getting prizes")
+	}
+
+	var requested uint64
+	for i, invoice := range invoices {
+		if invoice.NumSatoshis == 0 {
+			if claimable <= fees[i] {
+				return overClaimError(claimable, fees[i])
+			}
+			invoice.NumSatoshis = int64(claimable - fees[i])
+		}
+		requested += uint64(invoice.NumSatoshis) + fees[i]
+	}
+
+	if requested > claimable {
+		return overClaimError(claimable, requested)
+	}
+
+	return nil
+}
+
+// overClaimError returns the error responded when the invoices claim more than the prizes available.
+func overClaimError(claimable, requested uint64) error {
+	err := apierrors.New(apierrors.CodeInsufficientPrizes, db.ErrInsufficientPrizes.Error()).
+		WithDetail("claimable", claimable).
+		WithDetail("requested", requested)
+	return requestError{err}
+}
+
+// parseFees returns the routing fee of each invoice of a withdrawal.
+func parseFees(values []string, invoices int) ([]uint64, error) {
+	if len(values) != invoices {
+		return nil, errors.New("query parameter \"fee\" is required for each invoice")
+	}
+
+	fees := make([]uint64, 0, len(values))
+	for _, value := range values {
+		fee, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid fee")
+		}
+		fees = append(fees, fee)
+	}
+
+	return fees, nil
+}
+
+// restoreClaims gives back the prizes claimed for invoices that won't be paid.
+func (h *Handler) restoreClaims(claims [][]db.PrizesRow) error {
+	for _, c := range claims {
+		if err := h.db.Prizes.Restore(c); err != nil {
+			return errors.Wrap(err, "restoring prizes")
+		}
+	}
+
+	return nil
+}
+
+func claimStatus(err error) int {
+	switch {
+	case errors.Is(err, db.ErrInvoiceClaimed):
+		return http.StatusConflict
+	case errors.Is(err, db.ErrInsufficientPrizes):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func accessStatus(err error) int {
+	if errors.Is(err, policy.ErrAccessDenied) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
 }
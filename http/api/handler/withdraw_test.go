@@ -9,11 +9,13 @@ import (
 	"time"
 
 	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
 	"github.com/aftermath2/BTRY/http/api/handler"
 
 	"github.com/fiatjaf/go-lnurl"
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
 )
 
 const (
@@ -22,6 +24,12 @@ const (
 		"90858d44389a49bccd20c7c2f414f88230d418a2be43157336005"
 )
 
+// lnurlErrorResponse is the LNURL error response along with the structured error.
+type lnurlErrorResponse struct {
+	lnurl.LNURLErrorResponse
+	Error *apierrors.Error `json:"error"`
+}
+
 func (h *HandlerSuite) TestWithdraw() {
 	paymentRequest := "lnbcrt"
 	fee := int64(10)
@@ -44,12 +52,15 @@ func (h *HandlerSuite) TestWithdraw() {
 	h.lndMock.On("DecodeInvoice", ctx, paymentRequest).Return(invoice, nil)
 
 	withdrawAmount := uint64(invoice.NumSatoshis + fee)
-	h.prizesMock.On("Withdraw", validPublicKey, withdrawAmount).Return(nil)
+	claims := []db.PrizesRow{{RowID: 1, Amount: withdrawAmount, PaymentHash: invoice.PaymentHash}}
+	h.prizesMock.On("Get", validPublicKey).Return(withdrawAmount, nil)
+	h.prizesMock.On("Claim", validPublicKey, invoice.PaymentHash, withdrawAmount).Return(claims, nil)
 
-	h.lndMock.On("PayInvoice", ctx, invoice, fee, false).Return(nil, nil)
+	h.lndMock.On("PayInvoice", mock.Anything, invoice, fee, true).Return(nil, nil)
+	h.eventStreamerMock.On("WatchPayment", invoice.PaymentHash, nil)
 
 	paymentID := uint64(789)
-	h.eventStreamerMock.On("TrackPayment", invoice.PaymentHash, validPublicKey, withdrawAmount).
+	h.eventStreamerMock.On("TrackWithdrawal", invoice.PaymentHash, validPublicKey, claims).
 		Return(paymentID)
 
 	h.handler.Withdraw(h.rec, h.req)
@@ -63,6 +74,147 @@ func (h *HandlerSuite) TestWithdraw() {
 	h.Equal(paymentID, response.PaymentID)
 }
 
+func (h *HandlerSuite) TestWithdrawSplit() {
+	url := url.Values{}
+	url.Add("k1", validSignature)
+	url.Add("pubkey", validPublicKey)
+	url.Add("pr", "lnbc1")
+	url.Add("fee", "10")
+	url.Add("pr", "lnbc2")
+	url.Add("fee", "5")
+
+	h.req = httptest.NewRequest(http.MethodPost, "/withdraw?"+url.Encode(), nil)
+	ctx := h.req.Context()
+
+	first := &lnrpc.PayReq{PaymentHash: "hash1", NumSatoshis: 1000, Timestamp: time.Now().Unix(), Expiry: 3600}
+	second := &lnrpc.PayReq{PaymentHash: "hash2", NumSatoshis: 500, Timestamp: time.Now().Unix(), Expiry: 3600}
+	h.lndMock.On("DecodeInvoice", ctx, "lnbc1").Return(first, nil)
+	h.lndMock.On("DecodeInvoice", ctx, "lnbc2").Return(second, nil)
+
+	firstClaims := []db.PrizesRow{{RowID: 1, Amount: 1010}}
+	secondClaims := []db.PrizesRow{{RowID: 1, Amount: 300}, {RowID: 2, Amount: 205}}
+	h.prizesMock.On("Get", validPublicKey).Return(uint64(2000), nil)
+	h.prizesMock.On("Claim", validPublicKey, "hash1", uint64(1010)).Return(firstClaims, nil)
+	h.prizesMock.On("Claim", validPublicKey, "hash2", uint64(505)).Return(secondClaims, nil)
+
+	h.eventStreamerMock.On("TrackWithdrawal", "hash1", validPublicKey, firstClaims).Return(uint64(1))
+	h.eventStreamerMock.On("TrackWithdrawal", "hash2", validPublicKey, secondClaims).Return(uint64(2))
+	h.lndMock.On("PayInvoice", mock.Anything, first, int64(10), true).Return(nil, nil)
+	h.eventStreamerMock.On("WatchPayment", first.PaymentHash, nil)
+	h.lndMock.On("PayInvoice", mock.Anything, second, int64(5), true).Return(nil, nil)
+	h.eventStreamerMock.On("WatchPayment", second.PaymentHash, nil)
+
+	h.handler.Withdraw(h.rec, h.req)
+
+	var response handler.WithdrawResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal([]uint64{1, 2}, response.PaymentIDs)
+}
+
+func (h *HandlerSuite) TestWithdrawSplitPaymentFailed() {
+	url := url.Values{}
+	url.Add("k1", validSignature)
+	url.Add("pubkey", validPublicKey)
+	url.Add("pr", "lnbc1")
+	url.Add("fee", "10")
+	url.Add("pr", "lnbc2")
+	url.Add("fee", "5")
+	url.Add("pr", "lnbc3")
+	url.Add("fee", "0")
+
+	h.req = httptest.NewRequest(http.MethodPost, "/withdraw?"+url.Encode(), nil)
+	ctx := h.req.Context()
+
+	first := &lnrpc.PayReq{PaymentHash: "hash1", NumSatoshis: 1000, Timestamp: time.Now().Unix(), Expiry: 3600}
+	second := &lnrpc.PayReq{PaymentHash: "hash2", NumSatoshis: 500, Timestamp: time.Now().Unix(), Expiry: 3600}
+	third := &lnrpc.PayReq{PaymentHash: "hash3", NumSatoshis: 100, Timestamp: time.Now().Unix(), Expiry: 3600}
+	h.lndMock.On("DecodeInvoice", ctx, "lnbc1").Return(first, nil)
+	h.lndMock.On("DecodeInvoice", ctx, "lnbc2").Return(second, nil)
+	h.lndMock.On("DecodeInvoice", ctx, "lnbc3").Return(third, nil)
+
+	firstClaims := []db.PrizesRow{{RowID: 1, Amount: 1010}}
+	secondClaims := []db.PrizesRow{{RowID: 1, Amount: 505}}
+	thirdClaims := []db.PrizesRow{{RowID: 2, Amount: 100}}
+	h.prizesMock.On("Get", validPublicKey).Return(uint64(2000), nil)
+	h.prizesMock.On("Claim", validPublicKey, "hash1", uint64(1010)).Return(firstClaims, nil)
+	h.prizesMock.On("Claim", validPublicKey, "hash2", uint64(505)).Return(secondClaims, nil)
+	h.prizesMock.On("Claim", validPublicKey, "hash3", uint64(100)).Return(thirdClaims, nil)
+
+	h.eventStreamerMock.On("TrackWithdrawal", "hash1", validPublicKey, firstClaims).Return(uint64(1))
+	h.eventStreamerMock.On("TrackWithdrawal", "hash2", validPublicKey, secondClaims).Return(uint64(2))
+	h.lndMock.On("PayInvoice", mock.Anything, first, int64(10), true).Return(nil, nil)
+	h.eventStreamerMock.On("WatchPayment", first.PaymentHash, nil)
+	h.lndMock.On("PayInvoice", mock.Anything, second, int64(5), true).
+		Return(nil, errors.New("no route"))
+	// The claims of the invoice that failed and the ones after it are given back
+	h.eventStreamerMock.On("Untrack", "hash2")
+	h.prizesMock.On("Restore", secondClaims).Return(nil).Once()
+	h.prizesMock.On("Restore", thirdClaims).Return(nil).Once()
+
+	h.handler.Withdraw(h.rec, h.req)
+
+	var response lnurlErrorResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusInternalServerError, h.rec.Code)
+	h.Equal([]any{float64(1)}, response.Error.Details["payment_ids"])
+	h.prizesMock.AssertExpectations(h.T())
+	h.eventStreamerMock.AssertExpectations(h.T())
+	h.lndMock.AssertNotCalled(h.T(), "PayInvoice", mock.Anything, third, mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestWithdrawSplitInsufficientPrizes() {
+	url := url.Values{}
+	url.Add("k1", validSignature)
+	url.Add("pubkey", validPublicKey)
+	url.Add("pr", "lnbc1")
+	url.Add("fee", "0")
+	url.Add("pr", "lnbc2")
+	url.Add("fee", "0")
+
+	h.req = httptest.NewRequest(http.MethodPost, "/withdraw?"+url.Encode(), nil)
+	ctx := h.req.Context()
+
+	first := &lnrpc.PayReq{PaymentHash: "hash1", NumSatoshis: 1000, Timestamp: time.Now().Unix(), Expiry: 3600}
+	second := &lnrpc.PayReq{PaymentHash: "hash2", NumSatoshis: 500, Timestamp: time.Now().Unix(), Expiry: 3600}
+	h.lndMock.On("DecodeInvoice", ctx, "lnbc1").Return(first, nil)
+	h.lndMock.On("DecodeInvoice", ctx, "lnbc2").Return(second, nil)
+
+	// The first claim is given back if the second can't be covered, a concurrent withdrawal may
+	// have claimed the prizes after they were checked
+	firstClaims := []db.PrizesRow{{RowID: 1, Amount: 1000}}
+	h.prizesMock.On("Get", validPublicKey).Return(uint64(1500), nil)
+	h.prizesMock.On("Claim", validPublicKey, "hash1", uint64(1000)).Return(firstClaims, nil)
+	h.prizesMock.On("Claim", validPublicKey, "hash2", uint64(500)).
+		Return([]db.PrizesRow(nil), db.ErrInsufficientPrizes)
+	h.prizesMock.On("Restore", firstClaims).Return(nil)
+
+	h.handler.Withdraw(h.rec, h.req)
+
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+	h.prizesMock.AssertExpectations(h.T())
+	h.lndMock.AssertNotCalled(h.T(), "PayInvoice", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestWithdrawMissingFee() {
+	url := url.Values{}
+	url.Add("k1", validSignature)
+	url.Add("pubkey", validPublicKey)
+	url.Add("pr", "lnbc1")
+	url.Add("pr", "lnbc2")
+	url.Add("fee", "10")
+
+	h.req = httptest.NewRequest(http.MethodPost, "/withdraw?"+url.Encode(), nil)
+
+	h.handler.Withdraw(h.rec, h.req)
+
+	h.Equal(http.StatusBadRequest, h.rec.Code)
+}
+
 func (h *HandlerSuite) TestWithdrawInvalidParameters() {
 	cases := []struct {
 		desc      string
@@ -138,18 +290,91 @@ func (h *HandlerSuite) TestWithdrawInsufficientPrizes() {
 		Expiry:      150000,
 	}
 	h.lndMock.On("DecodeInvoice", ctx, paymentRequest).Return(invoice, nil)
-
-	withdrawAmount := uint64(invoice.NumSatoshis + fee)
-	h.prizesMock.On("Withdraw", validPublicKey, withdrawAmount).Return(db.ErrInsufficientPrizes)
+	h.prizesMock.On("Get", validPublicKey).Return(uint64(500), nil)
 
 	h.handler.Withdraw(h.rec, h.req)
 
-	var response lnurl.LNURLErrorResponse
+	var response lnurlErrorResponse
 	err := json.NewDecoder(h.rec.Body).Decode(&response)
 	h.NoError(err)
 
 	h.Equal(http.StatusBadRequest, h.rec.Code)
-	h.Equal(db.ErrInsufficientPrizes.Error(), response.Reason)
+	h.Equal(lnurl.LNURLErrorResponse{Status: "ERROR", Reason: db.ErrInsufficientPrizes.Error()},
+		response.LNURLErrorResponse)
+	h.Equal(apierrors.CodeInsufficientPrizes, response.Error.Code)
+	h.Equal(map[string]any{"claimable": float64(500), "requested": float64(1010)}, response.Error.Details)
+	h.prizesMock.AssertNotCalled(h.T(), "Claim", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestWithdrawInvoiceClaimed() {
+	paymentRequest := "lnbcrt"
+
+	url := url.Values{}
+	url.Add("k1", validSignature)
+	url.Add("pubkey", validPublicKey)
+	url.Add("pr", paymentRequest)
+	url.Add("fee", "0")
+
+	h.req = httptest.NewRequest(http.MethodPost, "/withdraw?"+url.Encode(), nil)
+	ctx := h.req.Context()
+
+	invoice := &lnrpc.PayReq{
+		PaymentHash: "hash",
+		NumSatoshis: 1000,
+		Timestamp:   time.Now().Unix(),
+		Expiry:      150000,
+	}
+	h.lndMock.On("DecodeInvoice", ctx, paymentRequest).Return(invoice, nil)
+	h.prizesMock.On("Get", validPublicKey).Return(uint64(2000), nil)
+	h.prizesMock.On("Claim", validPublicKey, invoice.PaymentHash, uint64(1000)).
+		Return([]db.PrizesRow(nil), db.ErrInvoiceClaimed)
+
+	h.handler.Withdraw(h.rec, h.req)
+
+	var response lnurlErrorResponse
+	err := json.NewDecoder(h.rec.Body).Decode(&response)
+	h.NoError(err)
+
+	h.Equal(http.StatusConflict, h.rec.Code)
+	h.Equal(db.ErrInvoiceClaimed.Error(), response.Reason)
+	h.Equal(apierrors.CodeInvoiceClaimed, response.Error.Code)
+	h.lndMock.AssertNotCalled(h.T(), "PayInvoice", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (h *HandlerSuite) TestWithdrawZeroAmountInvoice() {
+	paymentRequest := "lnbcrt"
+	fee := int64(10)
+
+	url := url.Values{}
+	url.Add("k1", validSignature)
+	url.Add("pubkey", validPublicKey)
+	url.Add("pr", paymentRequest)
+	url.Add("fee", strconv.FormatInt(fee, 10))
+
+	h.req = httptest.NewRequest(http.MethodPost, "/withdraw?"+url.Encode(), nil)
+	ctx := h.req.Context()
+
+	invoice := &lnrpc.PayReq{
+		PaymentHash: "hash",
+		Timestamp:   time.Now().Unix(),
+		Expiry:      150000,
+	}
+	h.lndMock.On("DecodeInvoice", ctx, paymentRequest).Return(invoice, nil)
+
+	// The invoice is paid with every prize left after the fee
+	claimable := uint64(1500)
+	claims := []db.PrizesRow{{RowID: 1, Amount: claimable, PaymentHash: invoice.PaymentHash}}
+	h.prizesMock.On("Get", validPublicKey).Return(claimable, nil)
+	h.prizesMock.On("Claim", validPublicKey, invoice.PaymentHash, claimable).Return(claims, nil)
+	h.eventStreamerMock.On("TrackWithdrawal", invoice.PaymentHash, validPublicKey, claims).
+		Return(uint64(1))
+	h.lndMock.On("PayInvoice", mock.Anything, invoice, fee, true).Return(nil, nil)
+	h.eventStreamerMock.On("WatchPayment", invoice.PaymentHash, nil)
+
+	h.handler.Withdraw(h.rec, h.req)
+
+	h.Equal(http.StatusOK, h.rec.Code)
+	h.Equal(int64(1490), invoice.NumSatoshis)
 }
 
 func (h *HandlerSuite) TestWithdrawInvoiceDecodeError() {
@@ -177,20 +402,22 @@ func (h *HandlerSuite) TestWithdrawInvoiceDecodeError() {
 }
 
 func (h *HandlerSuite) TestWithdrawInvoiceInvalidAmount() {
+	// Zero-amount invoices can't be used to split a withdrawal
 	url := url.Values{}
 	url.Add("k1", validSignature)
 	url.Add("pubkey", validPublicKey)
+	url.Add("pr", "lnbc1")
+	url.Add("fee", "1")
+	url.Add("pr", "lnbc2")
 	url.Add("fee", "1")
-	paymentRequest := "lntc"
-	url.Set("pr", paymentRequest)
 
 	h.req = httptest.NewRequest(http.MethodPost, "/withdraw?"+url.Encode(), nil)
 	ctx := h.req.Context()
 
-	invoice := &lnrpc.PayReq{
-		NumSatoshis: 0,
-	}
-	h.lndMock.On("DecodeInvoice", ctx, paymentRequest).Return(invoice, nil)
+	first := &lnrpc.PayReq{PaymentHash: "hash1", NumSatoshis: 1000, Timestamp: time.Now().Unix(), Expiry: 3600}
+	second := &lnrpc.PayReq{PaymentHash: "hash2", Timestamp: time.Now().Unix(), Expiry: 3600}
+	h.lndMock.On("DecodeInvoice", ctx, "lnbc1").Return(first, nil)
+	h.lndMock.On("DecodeInvoice", ctx, "lnbc2").Return(second, nil)
 
 	h.handler.Withdraw(h.rec, h.req)
 
@@ -242,14 +469,19 @@ func (h *HandlerSuite) TestWithdrawPayInvoiceError() {
 	h.lndMock.On("DecodeInvoice", ctx, paymentRequest).Return(invoice, nil)
 
 	withdrawAmount := uint64(invoice.NumSatoshis + fee)
-	h.prizesMock.On("Withdraw", validPublicKey, withdrawAmount).Return(nil)
+	claims := []db.PrizesRow{{RowID: 1, Amount: withdrawAmount, PaymentHash: invoice.PaymentHash}}
+	h.prizesMock.On("Get", validPublicKey).Return(withdrawAmount, nil)
+	h.prizesMock.On("Claim", validPublicKey, invoice.PaymentHash, withdrawAmount).Return(claims, nil)
 
 	paymentID := uint64(654)
-	h.eventStreamerMock.On("TrackPayment", invoice.PaymentHash, validPublicKey, withdrawAmount).
+	h.eventStreamerMock.On("TrackWithdrawal", invoice.PaymentHash, validPublicKey, claims).
 		Return(paymentID)
 
 	expectedErr := errors.New("test err")
-	h.lndMock.On("PayInvoice", ctx, invoice, fee, false).Return(nil, expectedErr)
+	h.lndMock.On("PayInvoice", mock.Anything, invoice, fee, true).Return(nil, expectedErr)
+	// The payment was never attempted, its prizes are given back right away
+	h.eventStreamerMock.On("Untrack", invoice.PaymentHash)
+	h.prizesMock.On("Restore", claims).Return(nil)
 
 	h.handler.Withdraw(h.rec, h.req)
 
@@ -259,4 +491,5 @@ func (h *HandlerSuite) TestWithdrawPayInvoiceError() {
 
 	h.Equal(http.StatusInternalServerError, h.rec.Code)
 	h.Equal(expectedErr.Error(), response.Reason)
+	h.prizesMock.AssertExpectations(h.T())
 }
@@ -0,0 +1,737 @@
+// Package live pushes the countdown to the next draw and the changes of the prize pools through a
+// websocket, so clients don't have to fetch the lottery information again after every block or bet.
+//
+// The connection is multiplexed in topics. Besides the lottery updates, clients can follow the
+// winners of every draw and, after proving the ownership of a public key, its bets and payouts.
+package live
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/logger"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/pkg/errors"
+	"nhooyr.io/websocket"
+)
+
+const (
+	// defaultInterval is the minimum time between the messages sent to a client if not configured
+	defaultInterval = time.Second
+	// writeTimeout is the maximum time to write a message before dropping the client
+	writeTimeout = 10 * time.Second
+	// maxQueued is the number of messages that can't be coalesced a client can have pending, it's
+	// disconnected if it doesn't keep up
+	maxQueued = 64
+	// maxRequestSize is the maximum size of the messages sent by the clients
+	maxRequestSize = 1024
+)
+
+// AuthDomain separates the signatures that authenticate the websocket clients from the rest of the
+// statements signed by the players.
+const AuthDomain = "btry-live-auth-v1"
+
+// Topics the clients can subscribe to.
+const (
+	// TopicLottery streams the countdown and the prize pools, clients are subscribed to it when
+	// they connect
+	TopicLottery = "lottery"
+	// TopicWinners streams the winners of every draw
+	TopicWinners = "winners"
+	// TopicBets streams the bets placed by the authenticated public key
+	TopicBets = "bets"
+	// TopicPayouts streams the status of the withdrawals of the authenticated public key
+	TopicPayouts = "payouts"
+)
+
+// privateTopics can only be subscribed to after authenticating.
+var privateTopics = map[string]bool{
+	TopicLottery: false,
+	TopicWinners: false,
+	TopicBets:    true,
+	TopicPayouts: true,
+}
+
+// Request types sent by the clients.
+const (
+	// RequestAuth proves the ownership of a public key with the signature of the hello challenge,
+	// it may subscribe to topics at the same time
+	RequestAuth = "auth"
+	// RequestSubscribe adds topics to the subscription
+	RequestSubscribe = "subscribe"
+	// RequestUnsubscribe removes topics from the subscription
+	RequestUnsubscribe = "unsubscribe"
+)
+
+// Payout statuses.
+const (
+	PayoutInFlight  = "in_flight"
+	PayoutSucceeded = "succeeded"
+	PayoutFailed    = "failed"
+)
+
+// Message types.
+const (
+	// TypeSnapshot messages contain the absolute values. They are sent when the client connects and
+	// when a new round starts
+	TypeSnapshot = "snapshot"
+	// TypeDelta messages contain the sats added to the pools since the previous message and, after a
+	// block, the blocks remaining
+	TypeDelta = "delta"
+	// TypeHello is the first message, it contains the challenge to authenticate
+	TypeHello = "hello"
+	// TypeSubscribed acknowledges a request with the topics subscribed
+	TypeSubscribed = "subscribed"
+	// TypeError rejects a request, the connection is kept open
+	TypeError = "error"
+	// TypeWinners messages contain the winners of a draw
+	TypeWinners = "winners"
+	// TypeBet messages contain a bet placed by the client
+	TypeBet = "bet"
+	// TypePayout messages contain the status of a withdrawal of the client
+	TypePayout = "payout"
+)
+
+// Message is sent to the clients through the websocket.
+type Message struct {
+	// Pools are the prize pools by name, absolute in snapshots and increments in deltas
+	Pools     map[string]int64 `json:"pools,omitempty"`
+	Bet       *db.Bet          `json:"bet,omitempty"`
+	Payout    *Payout          `json:"payout,omitempty"`
+	Type      string           `json:"type"`
+	Topic     string           `json:"topic,omitempty"`
+	Challenge string           `json:"challenge,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Topics    []string         `json:"topics,omitempty"`
+	Winners   []db.Winner      `json:"winners,omitempty"`
+	PrizePool int64            `json:"prize_pool,omitempty"`
+	// BlocksRemaining is the number of blocks until the next lottery is drawn
+	BlocksRemaining *uint32 `json:"blocks_remaining,omitempty"`
+	Height          uint32  `json:"height,omitempty"`
+	NextHeight      uint32  `json:"next_height,omitempty"`
+	// Hidden is set while the lottery has too few players to publish its prize pools
+	Hidden bool `json:"hidden,omitempty"`
+}
+
+// Request is sent by the clients to authenticate and manage their subscriptions.
+type Request struct {
+	Type      string   `json:"type"`
+	PublicKey string   `json:"public_key,omitempty"`
+	Signature string   `json:"signature,omitempty"`
+	Topics    []string `json:"topics,omitempty"`
+}
+
+// Payout is the status of a withdrawal.
+type Payout struct {
+	Error     string `json:"error,omitempty"`
+	Status    string `json:"status"`
+	PaymentID uint64 `json:"payment_id"`
+	Amount    uint64 `json:"amount"`
+}
+
+// merge coalesces the next message into the one pending to be sent.
+func (m *Message) merge(next Message) {
+	if next.Type == TypeSnapshot {
+		*m = next
+		m.Pools = maps.Clone(next.Pools)
+		return
+	}
+
+	// Deltas are added to the pending message, whether it's a snapshot or another delta
+	if m.Pools == nil {
+		m.Pools = make(map[string]int64, len(next.Pools))
+	}
+	for name, amount := range next.Pools {
+		m.Pools[name] += amount
+	}
+	m.PrizePool += next.PrizePool
+	m.Hidden = next.Hidden
+	if next.BlocksRemaining != nil {
+		m.BlocksRemaining = next.BlocksRemaining
+		m.Height = next.Height
+	}
+}
+
+// client is a websocket connection, the lottery updates published while it's writing are coalesced
+// in pending and the rest of the messages are queued.
+type client struct {
+	pending *Message
+	queue   []Message
+	topics  map[string]struct{}
+	signal  chan struct{}
+	// challenge is signed by the client to authenticate
+	challenge string
+	// publicKey is set once the client proves its ownership
+	publicKey string
+	// overflow is set when the queue is full, the client is disconnected
+	overflow bool
+}
+
+// subscribed returns whether the client receives the messages of the topic.
+func (c *client) subscribed(topic string) bool {
+	_, ok := c.topics[topic]
+	return ok
+}
+
+// subscriptions returns the topics the client is subscribed to.
+func (c *client) subscriptions() []string {
+	topics := make([]string, 0, len(c.topics))
+	for topic := range c.topics {
+		topics = append(topics, topic)
+	}
+	slices.Sort(topics)
+	return topics
+}
+
+// Hub keeps the state of the lottery in progress while there are clients connected and sends them
+// its changes.
+//
+// Bets are added as they are placed, the pools are read again from the database after every block
+// and draw so cancellations and refunds are reflected too.
+//
+// The clients receive the state with the statistics privacy policy applied, so the changes sent
+// don't reveal the size of each bet. The bets and payouts of a public key are only sent to the
+// clients authenticated with it.
+type Hub struct {
+	clients map[*client]struct{}
+	// players of the lottery in progress, only tracked if the policy has a minimum
+	players  map[string]struct{}
+	state    Message
+	db       *db.DB
+	lnd      lightning.NodeInfo
+	draws    *lottery.Subscription
+	logger   *logger.Logger
+	pools    lottery.Pools
+	privacy  lottery.StatsPrivacy
+	interval time.Duration
+	deadline time.Duration
+	mu       sync.Mutex
+}
+
+// NewHub returns a new live updates hub, deadline is the maximum duration of a connection.
+func NewHub(
+	config config.Live,
+	deadline time.Duration,
+	db *db.DB,
+	lnd lightning.NodeInfo,
+	pools lottery.Pools,
+	privacy lottery.StatsPrivacy,
+	winnersHub *lottery.WinnersHub,
+) (*Hub, error) {
+	logger, err := logger.New(config.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := config.Interval
+	if interval == 0 {
+		interval = defaultInterval
+	}
+
+	h := &Hub{
+		clients:  make(map[*client]struct{}),
+		db:       db,
+		lnd:      lnd,
+		draws:    winnersHub.Subscribe(),
+		logger:   logger,
+		pools:    pools,
+		privacy:  privacy,
+		interval: interval,
+		deadline: deadline,
+	}
+	go h.subscribeDraws()
+
+	return h, nil
+}
+
+// Close stops following the draws.
+func (h *Hub) Close() {
+	h.draws.Close()
+}
+
+// AddBet sends the tickets of a bet placed to the clients, and the bet to its owner.
+func (h *Hub) AddBet(bet db.Bet) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.clients) == 0 {
+		return
+	}
+
+	h.publish(TopicBets, bet.PublicKey, Message{Type: TypeBet, Topic: TopicBets, Bet: &bet})
+
+	if bet.LotteryHeight != h.state.NextHeight {
+		return
+	}
+
+	prev := h.view()
+	amount := int64(bet.Tickets)
+	h.state.Pools[bet.Pool] += amount
+	h.state.PrizePool += amount
+	if h.players != nil && bet.Promo == "" {
+		h.players[bet.PublicKey] = struct{}{}
+	}
+
+	// Bets that don't change the bucketed amounts are not published
+	msg := delta(prev, h.view())
+	if len(msg.Pools) == 0 && msg.PrizePool == 0 && msg.Hidden == prev.Hidden {
+		return
+	}
+	h.broadcast(msg)
+}
+
+// Payout sends the status of a withdrawal to the clients authenticated with the public key.
+func (h *Hub) Payout(publicKey string, payout Payout) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.publish(TopicPayouts, publicKey, Message{Type: TypePayout, Topic: TopicPayouts, Payout: &payout})
+}
+
+// Block sends the blocks remaining until the next draw to the clients.
+func (h *Hub) Block(height uint32) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.clients) == 0 {
+		return
+	}
+
+	h.state.Height = height
+	if err := h.refresh(); err != nil {
+		h.logger.Error(errors.Wrap(err, "refreshing lottery state"))
+	}
+}
+
+// subscribeDraws sends a snapshot to the clients after every draw, as the next round starts empty,
+// and the winners to the ones following them.
+func (h *Hub) subscribeDraws() {
+	for winners := range h.draws.C() {
+		h.mu.Lock()
+		if len(h.clients) > 0 {
+			if err := h.refresh(); err != nil {
+				h.logger.Error(errors.Wrap(err, "refreshing lottery state"))
+			}
+			h.publishWinners(winners)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// publishWinners sends the winners, displayed according to their privacy preferences, to the
+// clients subscribed to them. It must be called holding the lock.
+func (h *Hub) publishWinners(winners []db.Winner) {
+	subscribed := false
+	for c := range h.clients {
+		if c.subscribed(TopicWinners) {
+			subscribed = true
+			break
+		}
+	}
+	if !subscribed {
+		return
+	}
+
+	winners, err := policy.AnonymizeWinners(h.db.ReadReplica().Privacy, winners)
+	if err != nil {
+		h.logger.Error(errors.Wrap(err, "anonymizing winners"))
+		return
+	}
+
+	h.publish(TopicWinners, "", Message{Type: TypeWinners, Topic: TopicWinners, Winners: winners})
+}
+
+// refresh reads the lottery state from the database and broadcasts the changes. It must be called
+// holding the lock.
+func (h *Hub) refresh() error {
+	next, players, err := h.load()
+	if err != nil {
+		return err
+	}
+
+	prev := h.view()
+	newRound := next.NextHeight != h.state.NextHeight
+	h.state = next
+	h.players = players
+
+	if newRound {
+		h.broadcast(h.view())
+		return nil
+	}
+
+	msg := delta(prev, h.view())
+	msg.BlocksRemaining = next.BlocksRemaining
+	msg.Height = next.Height
+	h.broadcast(msg)
+
+	return nil
+}
+
+// load returns the state of the lottery in progress, keeping the last block height known, and its
+// players if they are tracked.
+func (h *Hub) load() (Message, map[string]struct{}, error) {
+	nextHeight, err := h.db.Lotteries.GetNextHeight()
+	if err != nil {
+		return Message{}, nil, err
+	}
+
+	state := Message{
+		Type:       TypeSnapshot,
+		Topic:      TopicLottery,
+		Pools:      make(map[string]int64, len(h.pools)),
+		NextHeight: nextHeight,
+		Height:     h.state.Height,
+	}
+	for _, pool := range h.pools {
+		prizePool, err := h.db.Bets.GetPrizePool(nextHeight, pool.Name)
+		if err != nil {
+			return Message{}, nil, err
+		}
+		state.Pools[pool.Name] = int64(prizePool)
+		state.PrizePool += int64(prizePool)
+	}
+
+	// The lottery at the next height is drawn once its block is found
+	remaining := uint32(0)
+	if nextHeight > state.Height {
+		remaining = nextHeight - state.Height
+	}
+	state.BlocksRemaining = &remaining
+
+	if h.privacy.MinPlayers == 0 {
+		return state, nil, nil
+	}
+
+	publicKeys, err := h.db.Bets.ListPlayers(nextHeight)
+	if err != nil {
+		return Message{}, nil, err
+	}
+	players := make(map[string]struct{}, len(publicKeys))
+	for _, publicKey := range publicKeys {
+		players[publicKey] = struct{}{}
+	}
+
+	return state, players, nil
+}
+
+// view returns a copy of the lottery state with the privacy policy applied, the prize pools are
+// bucketed or zeroed if there are too few players. It must be called holding the lock.
+func (h *Hub) view() Message {
+	view := h.state
+	view.Pools = make(map[string]int64, len(h.state.Pools))
+	if h.privacy.Hidden(uint64(len(h.players))) {
+		for name := range h.state.Pools {
+			view.Pools[name] = 0
+		}
+		view.PrizePool = 0
+		view.Hidden = true
+		return view
+	}
+
+	for name, amount := range h.state.Pools {
+		view.Pools[name] = int64(h.privacy.Amount(uint64(amount)))
+	}
+	view.PrizePool = int64(h.privacy.Amount(uint64(h.state.PrizePool)))
+	return view
+}
+
+// delta returns the changes between two views of the lottery state.
+func delta(prev, next Message) Message {
+	msg := Message{
+		Type:      TypeDelta,
+		Topic:     TopicLottery,
+		Pools:     make(map[string]int64),
+		PrizePool: next.PrizePool - prev.PrizePool,
+		Hidden:    next.Hidden,
+	}
+	for name, amount := range next.Pools {
+		if diff := amount - prev.Pools[name]; diff != 0 {
+			msg.Pools[name] = diff
+		}
+	}
+	return msg
+}
+
+// broadcast coalesces the lottery update into the pending one of every client subscribed and wakes
+// them up. It must be called holding the lock.
+func (h *Hub) broadcast(msg Message) {
+	for c := range h.clients {
+		if c.subscribed(TopicLottery) {
+			h.push(c, msg)
+		}
+	}
+}
+
+// push coalesces the lottery update into the pending one of the client. It must be called holding
+// the lock.
+func (h *Hub) push(c *client, msg Message) {
+	if c.pending == nil {
+		c.pending = &Message{Type: msg.Type, Topic: msg.Topic}
+	}
+	c.pending.merge(msg)
+	wake(c)
+}
+
+// publish queues the message to the clients subscribed to the topic, only to the ones
+// authenticated with the public key if it's not empty. It must be called holding the lock.
+func (h *Hub) publish(topic, publicKey string, msg Message) {
+	for c := range h.clients {
+		if !c.subscribed(topic) || (publicKey != "" && c.publicKey != publicKey) {
+			continue
+		}
+		enqueue(c, msg)
+	}
+}
+
+// enqueue adds the message to the client queue, flagging it if it's full. It must be called holding
+// the lock.
+func enqueue(c *client, msg Message) {
+	if len(c.queue) >= maxQueued {
+		c.overflow = true
+	} else {
+		c.queue = append(c.queue, msg)
+	}
+	wake(c)
+}
+
+func wake(c *client) {
+	select {
+	case c.signal <- struct{}{}:
+	default:
+	}
+}
+
+// handle applies a request of the client and queues the response.
+func (h *Hub) handle(c *client, req Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.apply(c, req); err != nil {
+		enqueue(c, Message{Type: TypeError, Error: err.Error()})
+		return
+	}
+
+	enqueue(c, Message{Type: TypeSubscribed, Topics: c.subscriptions()})
+}
+
+// apply authenticates the client or changes its subscriptions. It must be called holding the lock.
+func (h *Hub) apply(c *client, req Request) error {
+	switch req.Type {
+	case RequestAuth:
+		if c.publicKey != "" {
+			return errors.New("already authenticated")
+		}
+		err := audit.VerifySignature(req.PublicKey, AuthDomain, []byte(c.challenge), req.Signature)
+		if err != nil {
+			return errors.Wrap(err, "invalid authentication")
+		}
+		c.publicKey = req.PublicKey
+
+	case RequestSubscribe:
+		if len(req.Topics) == 0 {
+			return errors.New("no topics to subscribe to")
+		}
+
+	case RequestUnsubscribe:
+		for _, topic := range req.Topics {
+			delete(c.topics, topic)
+		}
+		return nil
+
+	default:
+		return errors.Errorf("unknown request type %q", req.Type)
+	}
+
+	for _, topic := range req.Topics {
+		private, ok := privateTopics[topic]
+		if !ok {
+			return errors.Errorf("unknown topic %q", topic)
+		}
+		if private && c.publicKey == "" {
+			return errors.Errorf("the %s topic requires authentication", topic)
+		}
+	}
+
+	for _, topic := range req.Topics {
+		if c.subscribed(topic) {
+			continue
+		}
+		c.topics[topic] = struct{}{}
+
+		// Lottery subscribers start from a snapshot
+		if topic == TopicLottery {
+			snapshot := h.view()
+			snapshot.Type = TypeSnapshot
+			h.push(c, snapshot)
+		}
+	}
+
+	return nil
+}
+
+// subscribe registers a client subscribed to the lottery updates, the first one loads the lottery
+// state.
+func (h *Hub) subscribe(ctx context.Context) (*client, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, errors.Wrap(err, "generating challenge")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.clients) == 0 {
+		info, err := h.lnd.GetInfo(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "getting block height")
+		}
+		h.state.Height = info.BlockHeight
+
+		h.state, h.players, err = h.load()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c := &client{
+		topics:    map[string]struct{}{TopicLottery: {}},
+		signal:    make(chan struct{}, 1),
+		challenge: hex.EncodeToString(challenge),
+	}
+	h.clients[c] = struct{}{}
+	enqueue(c, Message{Type: TypeHello, Challenge: c.challenge, Topics: c.subscriptions()})
+	h.push(c, h.view())
+
+	return c, nil
+}
+
+// unsubscribe removes the client.
+func (h *Hub) unsubscribe(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.clients, c)
+}
+
+// take returns the messages pending to be sent to the client, the queued ones first, and whether
+// it fell behind.
+func (h *Hub) take(c *client) ([]Message, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	msgs := c.queue
+	c.queue = nil
+	if c.pending != nil {
+		msgs = append(msgs, *c.pending)
+		c.pending = nil
+	}
+	return msgs, c.overflow
+}
+
+// ServeHTTP upgrades the connection to a websocket and sends a hello message with the
+// authentication challenge and a snapshot of the lottery in progress, followed by the messages of
+// the topics subscribed. The messages are sent in batches, at most one every interval.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The private topics require signing a challenge sent through the connection, so any origin is
+	// accepted like in the rest of the API
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+	if err != nil {
+		h.logger.Error(errors.Wrap(err, "accepting websocket connection"))
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(maxRequestSize)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	if h.deadline > 0 {
+		ctx, cancel = context.WithTimeout(ctx, h.deadline)
+		defer cancel()
+	}
+
+	c, err := h.subscribe(ctx)
+	if err != nil {
+		h.logger.Error(err)
+		conn.Close(websocket.StatusInternalError, "getting lottery information")
+		return
+	}
+	defer h.unsubscribe(c)
+
+	go h.read(ctx, cancel, conn, c)
+
+	for {
+		select {
+		case <-ctx.Done():
+			conn.Close(websocket.StatusNormalClosure, "")
+			return
+		case <-c.signal:
+		}
+
+		msgs, overflow := h.take(c)
+		if overflow {
+			conn.Close(websocket.StatusTryAgainLater, "too many messages pending")
+			return
+		}
+
+		for _, msg := range msgs {
+			if err := write(ctx, conn, &msg); err != nil {
+				return
+			}
+		}
+
+		// Changes published in the meantime are coalesced into the next message
+		select {
+		case <-ctx.Done():
+			conn.Close(websocket.StatusNormalClosure, "")
+			return
+		case <-time.After(h.interval):
+		}
+	}
+}
+
+// read handles the requests of the client until the connection is closed, then cancels the context.
+func (h *Hub) read(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, c *client) {
+	defer cancel()
+
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+
+		var req Request
+		if err := json.Unmarshal(data, &req); err != nil {
+			h.mu.Lock()
+			enqueue(c, Message{Type: TypeError, Error: "invalid request"})
+			h.mu.Unlock()
+			continue
+		}
+
+		h.handle(c, req)
+	}
+}
+
+func write(ctx context.Context, conn *websocket.Conn, msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "encoding message")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	return conn.Write(ctx, websocket.MessageText, data)
+}
@@ -0,0 +1,329 @@
+package live
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/lottery"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"nhooyr.io/websocket"
+)
+
+func TestHub(t *testing.T) {
+	lndMock := lightning.NewClientMock()
+	lndMock.On("GetInfo", mock.Anything).Return(&lnrpc.GetInfoResponse{BlockHeight: 100}, nil)
+	lotteriesMock := db.NewLotteriesStoreMock()
+	lotteriesMock.On("GetNextHeight").Return(uint32(144), nil).Twice()
+	lotteriesMock.On("GetNextHeight").Return(uint32(288), nil)
+	betsMock := db.NewBetsStoreMock()
+	betsMock.On("GetPrizePool", uint32(144), "small").Return(uint64(1_000), nil)
+	betsMock.On("GetPrizePool", uint32(144), "big").Return(uint64(5_000), nil)
+	betsMock.On("GetPrizePool", uint32(288), "small").Return(uint64(0), nil)
+	betsMock.On("GetPrizePool", uint32(288), "big").Return(uint64(0), nil)
+
+	winnersHub := lottery.NewWinnersHub(config.WinnersHub{})
+	pools := lottery.NewPools([]config.Pool{{Name: "small"}, {Name: "big"}})
+	database := &db.DB{Bets: betsMock, Lotteries: lotteriesMock}
+	hub, err := NewHub(config.Live{Interval: 100 * time.Millisecond}, 0, database, lndMock, pools,
+		lottery.StatsPrivacy{}, winnersHub)
+	assert.NoError(t, err)
+	defer hub.Close()
+
+	srv := httptest.NewServer(hub)
+	defer srv.Close()
+
+	conn, _ := dial(t, srv)
+	defer conn.CloseNow()
+
+	remaining := uint32(44)
+	expected := Message{
+		Type:            TypeSnapshot,
+		Topic:           TopicLottery,
+		Pools:           map[string]int64{"small": 1_000, "big": 5_000},
+		PrizePool:       6_000,
+		BlocksRemaining: &remaining,
+		Height:          100,
+		NextHeight:      144,
+	}
+	assert.Equal(t, expected, readMessage(t, conn))
+
+	// Bets placed within the interval are coalesced, the ones of other rounds are ignored
+	hub.AddBet(db.Bet{Pool: "small", Tickets: 10, LotteryHeight: 144})
+	hub.AddBet(db.Bet{Pool: "small", Tickets: 20, LotteryHeight: 144})
+	hub.AddBet(db.Bet{Pool: "big", Tickets: 500, LotteryHeight: 144})
+	hub.AddBet(db.Bet{Pool: "big", Tickets: 100, LotteryHeight: 150})
+	expected = Message{
+		Type:      TypeDelta,
+		Topic:     TopicLottery,
+		Pools:     map[string]int64{"small": 30, "big": 500},
+		PrizePool: 530,
+	}
+	assert.Equal(t, expected, readMessage(t, conn))
+
+	// The pools are read again after every block, catching the cancellations
+	hub.Block(101)
+	remaining = 43
+	expected = Message{
+		Type:            TypeDelta,
+		Topic:           TopicLottery,
+		Pools:           map[string]int64{"small": -30, "big": -500},
+		PrizePool:       -530,
+		BlocksRemaining: &remaining,
+		Height:          101,
+	}
+	assert.Equal(t, expected, readMessage(t, conn))
+
+	// A new round starts after the draw
+	winnersHub.Publish([]db.Winner{{PublicKey: "pubkey", Prize: 1_000}})
+	remaining = 187
+	expected = Message{
+		Type:            TypeSnapshot,
+		Topic:           TopicLottery,
+		Pools:           map[string]int64{"small": 0, "big": 0},
+		BlocksRemaining: &remaining,
+		Height:          101,
+		NextHeight:      288,
+	}
+	assert.Equal(t, expected, readMessage(t, conn))
+}
+
+func TestHubPrivacy(t *testing.T) {
+	lndMock := lightning.NewClientMock()
+	lndMock.On("GetInfo", mock.Anything).Return(&lnrpc.GetInfoResponse{BlockHeight: 100}, nil)
+	lotteriesMock := db.NewLotteriesStoreMock()
+	lotteriesMock.On("GetNextHeight").Return(uint32(144), nil)
+	betsMock := db.NewBetsStoreMock()
+	betsMock.On("GetPrizePool", uint32(144), "").Return(uint64(1_050), nil)
+	betsMock.On("ListPlayers", uint32(144)).Return([]string{"a"}, nil)
+
+	winnersHub := lottery.NewWinnersHub(config.WinnersHub{})
+	database := &db.DB{Bets: betsMock, Lotteries: lotteriesMock}
+	privacy := lottery.StatsPrivacy{MinPlayers: 2, Bucket: 100}
+	hub, err := NewHub(config.Live{Interval: 100 * time.Millisecond}, 0, database, lndMock,
+		lottery.NewPools(nil), privacy, winnersHub)
+	assert.NoError(t, err)
+	defer hub.Close()
+
+	srv := httptest.NewServer(hub)
+	defer srv.Close()
+
+	conn, _ := dial(t, srv)
+	defer conn.CloseNow()
+
+	// A single player, the pools are hidden
+	remaining := uint32(44)
+	expected := Message{
+		Type:            TypeSnapshot,
+		Topic:           TopicLottery,
+		Pools:           map[string]int64{"": 0},
+		BlocksRemaining: &remaining,
+		Height:          100,
+		NextHeight:      144,
+		Hidden:          true,
+	}
+	assert.Equal(t, expected, readMessage(t, conn))
+
+	// The second player reveals the pools, bucketed
+	hub.AddBet(db.Bet{PublicKey: "b", Tickets: 20, LotteryHeight: 144})
+	hub.AddBet(db.Bet{PublicKey: "c", Tickets: 30, LotteryHeight: 144})
+	expected = Message{
+		Type:      TypeDelta,
+		Topic:     TopicLottery,
+		Pools:     map[string]int64{"": 1_100},
+		PrizePool: 1_100,
+	}
+	assert.Equal(t, expected, readMessage(t, conn))
+
+	// Bets within the bucket are not published, the cancellations hide the pools again
+	hub.AddBet(db.Bet{PublicKey: "a", Tickets: 10, LotteryHeight: 144})
+	hub.Block(101)
+	remaining = 43
+	expected = Message{
+		Type:            TypeDelta,
+		Topic:           TopicLottery,
+		Pools:           map[string]int64{"": -1_100},
+		PrizePool:       -1_100,
+		BlocksRemaining: &remaining,
+		Height:          101,
+		Hidden:          true,
+	}
+	assert.Equal(t, expected, readMessage(t, conn))
+}
+
+func TestHubTopics(t *testing.T) {
+	lndMock := lightning.NewClientMock()
+	lndMock.On("GetInfo", mock.Anything).Return(&lnrpc.GetInfoResponse{BlockHeight: 100}, nil)
+	lotteriesMock := db.NewLotteriesStoreMock()
+	lotteriesMock.On("GetNextHeight").Return(uint32(144), nil)
+	betsMock := db.NewBetsStoreMock()
+	betsMock.On("GetPrizePool", uint32(144), "").Return(uint64(0), nil)
+	privacyMock := db.NewPrivacyStoreMock()
+	privacyMock.On("List", mock.Anything).Return(map[string]db.Privacy{}, nil)
+
+	winnersHub := lottery.NewWinnersHub(config.WinnersHub{})
+	database := &db.DB{Bets: betsMock, Lotteries: lotteriesMock, Privacy: privacyMock}
+	hub, err := NewHub(config.Live{Interval: 10 * time.Millisecond}, 0, database, lndMock,
+		lottery.NewPools(nil), lottery.StatsPrivacy{}, winnersHub)
+	assert.NoError(t, err)
+	defer hub.Close()
+
+	srv := httptest.NewServer(hub)
+	defer srv.Close()
+
+	conn, hello := dial(t, srv)
+	defer conn.CloseNow()
+	assert.Equal(t, TypeSnapshot, readMessage(t, conn).Type)
+
+	// Private topics require authentication
+	sendRequest(t, conn, Request{Type: RequestSubscribe, Topics: []string{TopicBets}})
+	assert.Equal(t, Message{Type: TypeError, Error: "the bets topic requires authentication"},
+		readMessage(t, conn))
+
+	sendRequest(t, conn, Request{Type: RequestUnsubscribe, Topics: []string{TopicLottery}})
+	assert.Equal(t, Message{Type: TypeSubscribed}, readMessage(t, conn))
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	hash := sha256.New()
+	hash.Write([]byte(AuthDomain))
+	hash.Write([]byte{0})
+	hash.Write([]byte(hello.Challenge))
+	auth := Request{
+		Type:      RequestAuth,
+		PublicKey: hex.EncodeToString(publicKey),
+		Signature: hex.EncodeToString(ed25519.Sign(privateKey, hash.Sum(nil))),
+		Topics:    []string{TopicBets, TopicPayouts, TopicWinners},
+	}
+	sendRequest(t, conn, auth)
+	expected := Message{Type: TypeSubscribed, Topics: []string{TopicBets, TopicPayouts, TopicWinners}}
+	assert.Equal(t, expected, readMessage(t, conn))
+
+	// Only the bets and payouts of the public key authenticated are delivered
+	hub.AddBet(db.Bet{PublicKey: "other", Tickets: 10, LotteryHeight: 144})
+	hub.Payout("other", Payout{PaymentID: 1, Amount: 10, Status: PayoutSucceeded})
+	bet := db.Bet{PublicKey: auth.PublicKey, Tickets: 20, Index: 30}
+	hub.AddBet(bet)
+	assert.Equal(t, Message{Type: TypeBet, Topic: TopicBets, Bet: &bet}, readMessage(t, conn))
+
+	payout := Payout{PaymentID: 2, Amount: 100, Status: PayoutFailed, Error: "FAILURE_REASON_NO_ROUTE"}
+	hub.Payout(auth.PublicKey, payout)
+	assert.Equal(t, Message{Type: TypePayout, Topic: TopicPayouts, Payout: &payout}, readMessage(t, conn))
+
+	winners := []db.Winner{{PublicKey: auth.PublicKey, Prize: 1_000}}
+	winnersHub.Publish(winners)
+	assert.Equal(t, Message{Type: TypeWinners, Topic: TopicWinners, Winners: winners}, readMessage(t, conn))
+
+	// The authentication can't be changed
+	sendRequest(t, conn, auth)
+	assert.Equal(t, Message{Type: TypeError, Error: "already authenticated"}, readMessage(t, conn))
+}
+
+func TestHubInvalidAuth(t *testing.T) {
+	lndMock := lightning.NewClientMock()
+	lndMock.On("GetInfo", mock.Anything).Return(&lnrpc.GetInfoResponse{BlockHeight: 100}, nil)
+	lotteriesMock := db.NewLotteriesStoreMock()
+	lotteriesMock.On("GetNextHeight").Return(uint32(144), nil)
+	betsMock := db.NewBetsStoreMock()
+	betsMock.On("GetPrizePool", uint32(144), "").Return(uint64(0), nil)
+
+	database := &db.DB{Bets: betsMock, Lotteries: lotteriesMock}
+	hub, err := NewHub(config.Live{Interval: 10 * time.Millisecond}, 0, database, lndMock,
+		lottery.NewPools(nil), lottery.StatsPrivacy{}, lottery.NewWinnersHub(config.WinnersHub{}))
+	assert.NoError(t, err)
+	defer hub.Close()
+
+	srv := httptest.NewServer(hub)
+	defer srv.Close()
+
+	conn, _ := dial(t, srv)
+	defer conn.CloseNow()
+	readMessage(t, conn)
+
+	// A signature of the public key instead of the challenge
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	sendRequest(t, conn, Request{
+		Type:      RequestAuth,
+		PublicKey: hex.EncodeToString(publicKey),
+		Signature: hex.EncodeToString(ed25519.Sign(privateKey, publicKey)),
+		Topics:    []string{TopicBets},
+	})
+	msg := readMessage(t, conn)
+	assert.Equal(t, TypeError, msg.Type)
+	assert.Contains(t, msg.Error, "invalid authentication")
+
+	sendRequest(t, conn, Request{Type: RequestSubscribe, Topics: []string{"unknown"}})
+	assert.Equal(t, Message{Type: TypeError, Error: `unknown topic "unknown"`}, readMessage(t, conn))
+}
+
+func TestMerge(t *testing.T) {
+	remaining := uint32(5)
+	pending := &Message{Type: TypeSnapshot}
+	pending.merge(Message{Type: TypeSnapshot, Pools: map[string]int64{"": 100}, PrizePool: 100, NextHeight: 10})
+	pending.merge(Message{Type: TypeDelta, Pools: map[string]int64{"": 50}, PrizePool: 50})
+	pending.merge(Message{Type: TypeDelta, BlocksRemaining: &remaining, Height: 5})
+
+	// Deltas applied to a pending snapshot keep it absolute
+	expected := &Message{
+		Type:            TypeSnapshot,
+		Pools:           map[string]int64{"": 150},
+		PrizePool:       150,
+		BlocksRemaining: &remaining,
+		Height:          5,
+		NextHeight:      10,
+	}
+	assert.Equal(t, expected, pending)
+
+	// Snapshots replace everything pending
+	pending.merge(Message{Type: TypeSnapshot, Pools: map[string]int64{"": 0}, NextHeight: 20})
+	assert.Equal(t, &Message{Type: TypeSnapshot, Pools: map[string]int64{"": 0}, NextHeight: 20}, pending)
+}
+
+// dial connects to the hub and returns the hello message.
+func dial(t *testing.T, srv *httptest.Server) (*websocket.Conn, Message) {
+	t.Helper()
+
+	conn, _, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	assert.NoError(t, err)
+
+	hello := readMessage(t, conn)
+	assert.Equal(t, TypeHello, hello.Type)
+	assert.Equal(t, []string{TopicLottery}, hello.Topics)
+	assert.Len(t, hello.Challenge, 64)
+	return conn, hello
+}
+
+func sendRequest(t *testing.T, conn *websocket.Conn, req Request) {
+	t.Helper()
+
+	data, err := json.Marshal(req)
+	assert.NoError(t, err)
+	assert.NoError(t, conn.Write(context.Background(), websocket.MessageText, data))
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) Message {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, data, err := conn.Read(ctx)
+	assert.NoError(t, err)
+
+	var msg Message
+	assert.NoError(t, json.Unmarshal(data, &msg))
+	return msg
+}
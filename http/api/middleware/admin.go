@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+
+	"github.com/pkg/errors"
+)
+
+// SessionCookie is the name of the cookie holding the operators session token.
+const SessionCookie = "btry_admin_session"
+
+type contextKey string
+
+const operatorKey contextKey = "operator"
+
+// Admin protects the administration endpoints.
+type Admin struct {
+	db      *db.DB
+	enabled bool
+}
+
+// NewAdmin returns a new admin authentication middleware.
+func NewAdmin(config config.Admin, db *db.DB) *Admin {
+	return &Admin{
+		db:      db,
+		enabled: config.RPID != "",
+	}
+}
+
+// Enabled rejects all the requests if the administration endpoints are disabled.
+func (a *Admin) Enabled(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.enabled {
+			apierrors.Write(w, http.StatusForbidden, errors.New("admin API disabled"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Authorize rejects the requests that don't carry a valid session of an operator with the role
+// specified or a higher one.
+func (a *Admin) Authorize(role db.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := SessionToken(r)
+			if token == "" {
+				apierrors.Write(w, http.StatusUnauthorized, errors.New("unauthorized"))
+				return
+			}
+
+			session, err := a.db.Sessions.Get(HashSessionToken(token))
+			if err != nil {
+				if errors.Is(err, db.ErrNoSession) {
+					apierrors.Write(w, http.StatusUnauthorized, errors.New("unauthorized"))
+					return
+				}
+				apierrors.Write(w, http.StatusInternalServerError, errors.New("internal server error"))
+				return
+			}
+
+			if session.Operator.Role < role {
+				apierrors.Write(w, http.StatusForbidden, errors.New("forbidden"))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), operatorKey, session.Operator)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// OperatorFromContext returns the operator authenticated in the request.
+func OperatorFromContext(ctx context.Context) (db.Operator, bool) {
+	operator, ok := ctx.Value(operatorKey).(db.Operator)
+	return operator, ok
+}
+
+// SessionToken returns the session token sent in the authorization header or in the session cookie.
+func SessionToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return ""
+	}
+
+	return cookie.Value
+}
+
+// HashSessionToken returns the hash of the session token, which is what gets stored.
+func HashSessionToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,134 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminEnabled(t *testing.T) {
+	cases := []struct {
+		desc         string
+		config       config.Admin
+		expectedCode int
+	}{
+		{
+			desc:         "Enabled",
+			config:       config.Admin{RPID: "btry.example"},
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc:         "Disabled",
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			admin := middleware.NewAdmin(tc.config, &db.DB{})
+			handler := admin.Enabled(&noopHandler{})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestAdminAuthorize(t *testing.T) {
+	token := "token"
+	operator := db.Operator{ID: 1, Name: "operator", Role: db.RoleOperator}
+
+	cases := []struct {
+		setRequest   func(r *http.Request)
+		desc         string
+		role         db.Role
+		expectedCode int
+	}{
+		{
+			desc: "Valid",
+			setRequest: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+token)
+			},
+			role:         db.RoleOperator,
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc: "Valid cookie",
+			setRequest: func(r *http.Request) {
+				r.AddCookie(&http.Cookie{Name: middleware.SessionCookie, Value: token})
+			},
+			role:         db.RoleReadOnly,
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc: "Insufficient role",
+			setRequest: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+token)
+			},
+			role:         db.RoleOwner,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			desc: "Invalid session",
+			setRequest: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer invalid")
+			},
+			role:         db.RoleReadOnly,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			desc:         "Missing session",
+			setRequest:   func(r *http.Request) {},
+			role:         db.RoleReadOnly,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			desc: "Database error",
+			setRequest: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer error")
+			},
+			role:         db.RoleReadOnly,
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	sessionsMock := db.NewSessionsStoreMock()
+	sessionsMock.On("Get", middleware.HashSessionToken(token)).
+		Return(db.Session{Operator: operator}, nil)
+	sessionsMock.On("Get", middleware.HashSessionToken("invalid")).
+		Return(db.Session{}, db.ErrNoSession)
+	sessionsMock.On("Get", middleware.HashSessionToken("error")).
+		Return(db.Session{}, errors.New("test"))
+
+	admin := middleware.NewAdmin(config.Admin{RPID: "btry.example"}, &db.DB{Sessions: sessionsMock})
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var gotOperator db.Operator
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotOperator, _ = middleware.OperatorFromContext(r.Context())
+			})
+			handler := admin.Authorize(tc.role)(next)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			tc.setRequest(req)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			if tc.expectedCode == http.StatusOK {
+				assert.Equal(t, operator, gotOperator)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+
+	"github.com/pkg/errors"
+	"github.com/sethvargo/go-limiter"
+	"github.com/sethvargo/go-limiter/httplimit"
+	"github.com/sethvargo/go-limiter/memorystore"
+)
+
+// APIKeyHeader is the header integrators send their API key in.
+const APIKeyHeader = "X-API-Key"
+
+const apiKeyKey contextKey = "api_key"
+
+// APIKeys authenticates the requests of third-party integrators and rate limits them per key
+// instead of per IP address.
+type APIKeys struct {
+	db      *db.DB
+	store   limiter.Store
+	limiter *httplimit.Middleware
+	// limits holds the rate limit each key bucket was configured with
+	limits map[uint64]uint64
+	config config.RateLimiter
+	mu     sync.Mutex
+}
+
+// NewAPIKeys returns a new API keys middleware. Keys without a rate limit get the same one as the
+// IP addresses.
+func NewAPIKeys(config config.RateLimiter, db *db.DB) (*APIKeys, error) {
+	store, err := memorystore.New(&memorystore.Config{
+		Tokens:   config.Tokens,
+		Interval: config.Interval,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating api keys rate limiter memory store")
+	}
+
+	limiter, err := httplimit.NewMiddleware(store, apiKeyFunc)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating api keys rate limiter middleware")
+	}
+
+	return &APIKeys{
+		db:      db,
+		store:   store,
+		limiter: limiter,
+		limits:  make(map[uint64]uint64),
+		config:  config,
+	}, nil
+}
+
+// Limit authenticates the requests carrying an API key and applies the key's rate limit, the rest
+// are limited by the fallback middleware. Invalid or revoked keys are rejected.
+func (a *APIKeys) Limit(fallback func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		limited := a.limiter.Handle(next)
+		fallbackLimited := fallback(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The key was already authenticated and limited by a parent router
+			if _, ok := APIKeyFromContext(r.Context()); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := r.Header.Get(APIKeyHeader)
+			if key == "" {
+				fallbackLimited.ServeHTTP(w, r)
+				return
+			}
+
+			apiKey, err := a.db.APIKeys.Get(HashAPIKey(key))
+			if err != nil {
+				if errors.Is(err, db.ErrNoAPIKey) {
+					apierrors.Write(w, http.StatusUnauthorized, errors.New("invalid api key"))
+					return
+				}
+				apierrors.Write(w, http.StatusInternalServerError, errors.New("internal server error"))
+				return
+			}
+
+			if err := a.configureLimit(r.Context(), apiKey); err != nil {
+				apierrors.Write(w, http.StatusInternalServerError, errors.New("internal server error"))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), apiKeyKey, apiKey)
+			limited.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireScope rejects the requests carrying an API key that wasn't granted the scope specified.
+// Requests without an API key are not affected.
+func (a *APIKeys) RequireScope(scope db.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiKey, ok := APIKeyFromContext(r.Context()); ok && !apiKey.HasScope(scope) {
+				apierrors.Write(w, http.StatusForbidden, errors.New("forbidden"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Authorize rejects the requests without an API key granted the scope specified.
+func (a *APIKeys) Authorize(scope db.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKey, ok := APIKeyFromContext(r.Context())
+			if !ok {
+				apierrors.Write(w, http.StatusUnauthorized, errors.New("api key required"))
+				return
+			}
+
+			if !apiKey.HasScope(scope) {
+				apierrors.Write(w, http.StatusForbidden, errors.New("forbidden"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// configureLimit sets the number of tokens of the key bucket when it's first used or its rate
+// limit changes.
+func (a *APIKeys) configureLimit(ctx context.Context, apiKey db.APIKey) error {
+	tokens := apiKey.RateLimit
+	if tokens == 0 {
+		tokens = a.config.Tokens
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if configured, ok := a.limits[apiKey.ID]; ok && configured == tokens {
+		return nil
+	}
+
+	key := strconv.FormatUint(apiKey.ID, 10)
+	if err := a.store.Set(ctx, key, tokens, a.config.Interval); err != nil {
+		return errors.Wrap(err, "setting api key rate limit")
+	}
+	a.limits[apiKey.ID] = tokens
+
+	return nil
+}
+
+// APIKeyFromContext returns the API key authenticated in the request.
+func APIKeyFromContext(ctx context.Context) (db.APIKey, bool) {
+	apiKey, ok := ctx.Value(apiKeyKey).(db.APIKey)
+	return apiKey, ok
+}
+
+// HashAPIKey returns the hash of the API key, which is what gets stored.
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func apiKeyFunc(r *http.Request) (string, error) {
+	apiKey, ok := APIKeyFromContext(r.Context())
+	if !ok {
+		return "", errors.New("api key not found in context")
+	}
+	return strconv.FormatUint(apiKey.ID, 10), nil
+}
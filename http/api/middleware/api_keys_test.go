@@ -0,0 +1,126 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeysLimit(t *testing.T) {
+	apiKey := db.APIKey{ID: 1, Name: "kiosk", Scopes: []db.Scope{db.ScopeBets}, RateLimit: 2}
+
+	apiKeysMock := db.NewAPIKeysStoreMock()
+	apiKeysMock.On("Get", middleware.HashAPIKey("key")).Return(apiKey, nil)
+	apiKeysMock.On("Get", middleware.HashAPIKey("invalid")).Return(db.APIKey{}, db.ErrNoAPIKey)
+	apiKeysMock.On("Get", middleware.HashAPIKey("error")).Return(db.APIKey{}, errors.New("test"))
+
+	rateLimiterConfig := config.RateLimiter{Tokens: 1, Interval: time.Minute}
+	apiKeys, err := middleware.NewAPIKeys(rateLimiterConfig, &db.DB{APIKeys: apiKeysMock})
+	require.NoError(t, err)
+	rateLimiter, err := middleware.NewRateLimiter(rateLimiterConfig)
+	require.NoError(t, err)
+
+	var gotAPIKey db.APIKey
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAPIKey, _ = middleware.APIKeyFromContext(r.Context())
+	})
+	limit := apiKeys.Limit(rateLimiter.Handle)
+	// Keys are limited once even if the middleware is applied by nested routers
+	handler := limit(limit(next))
+
+	serve := func(key string) int {
+		handler := handler
+		if key == "" {
+			handler = limit(next)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if key != "" {
+			req.Header.Set(middleware.APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// The key has its own limit, independent of the IP address one
+	assert.Equal(t, http.StatusOK, serve(""))
+	assert.Equal(t, http.StatusTooManyRequests, serve(""))
+	assert.Equal(t, http.StatusOK, serve("key"))
+	assert.Equal(t, apiKey, gotAPIKey)
+	assert.Equal(t, http.StatusOK, serve("key"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("key"))
+
+	assert.Equal(t, http.StatusUnauthorized, serve("invalid"))
+	assert.Equal(t, http.StatusInternalServerError, serve("error"))
+}
+
+func TestAPIKeysScopes(t *testing.T) {
+	cases := []struct {
+		desc         string
+		key          string
+		expectedCode int
+		// expectedAuthorizeCode is the status code returned by the Authorize middleware, which
+		// requires a key
+		expectedAuthorizeCode int
+	}{
+		{
+			desc:                  "Granted",
+			key:                   "bets",
+			expectedCode:          http.StatusOK,
+			expectedAuthorizeCode: http.StatusOK,
+		},
+		{
+			desc:                  "Not granted",
+			key:                   "stats",
+			expectedCode:          http.StatusForbidden,
+			expectedAuthorizeCode: http.StatusForbidden,
+		},
+		{
+			desc:                  "No key",
+			expectedCode:          http.StatusOK,
+			expectedAuthorizeCode: http.StatusUnauthorized,
+		},
+	}
+
+	apiKeysMock := db.NewAPIKeysStoreMock()
+	apiKeysMock.On("Get", middleware.HashAPIKey("bets")).
+		Return(db.APIKey{ID: 1, Scopes: []db.Scope{db.ScopeBets}}, nil)
+	apiKeysMock.On("Get", middleware.HashAPIKey("stats")).
+		Return(db.APIKey{ID: 2, Scopes: []db.Scope{db.ScopeStats}}, nil)
+
+	rateLimiterConfig := config.RateLimiter{Tokens: 10, Interval: time.Minute}
+	apiKeys, err := middleware.NewAPIKeys(rateLimiterConfig, &db.DB{APIKeys: apiKeysMock})
+	require.NoError(t, err)
+	rateLimiter, err := middleware.NewRateLimiter(rateLimiterConfig)
+	require.NoError(t, err)
+
+	limit := apiKeys.Limit(rateLimiter.Handle)
+	handler := limit(apiKeys.RequireScope(db.ScopeBets)(&noopHandler{}))
+	authorizeHandler := limit(apiKeys.Authorize(db.ScopeBets)(&noopHandler{}))
+
+	serve := func(handler http.Handler, key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if key != "" {
+			req.Header.Set(middleware.APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expectedCode, serve(handler, tc.key))
+			assert.Equal(t, tc.expectedAuthorizeCode, serve(authorizeHandler, tc.key))
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+)
+
+// defaultCacheEntries is the number of responses kept when the configuration doesn't specify it.
+const defaultCacheEntries = 1000
+
+// Cache keeps the responses of read endpoints in memory and answers conditional requests with a
+// 304 Not Modified when their ETag matches.
+//
+// Entries belong to the generation they were stored in, invalidating the cache starts a new one so
+// the responses computed before a block or a draw are not served afterwards.
+type Cache struct {
+	entries    map[string]cacheEntry
+	now        func() time.Time
+	config     config.Cache
+	generation uint64
+	mu         sync.RWMutex
+}
+
+type cacheEntry struct {
+	expiresAt   time.Time
+	contentType string
+	etag        string
+	body        []byte
+	generation  uint64
+}
+
+// NewCache returns a new cache middleware.
+func NewCache(config config.Cache) *Cache {
+	if config.MaxEntries == 0 {
+		config.MaxEntries = defaultCacheEntries
+	}
+
+	return &Cache{
+		entries: make(map[string]cacheEntry),
+		now:     time.Now,
+		config:  config,
+	}
+}
+
+// Info caches the responses of the lottery information endpoints.
+func (c *Cache) Info(next http.Handler) http.Handler {
+	return c.handle(c.config.Info, next)
+}
+
+// History caches the responses of the past lotteries endpoints.
+func (c *Cache) History(next http.Handler) http.Handler {
+	return c.handle(c.config.History, next)
+}
+
+// Stats caches the responses of the statistics endpoints.
+func (c *Cache) Stats(next http.Handler) http.Handler {
+	return c.handle(c.config.Stats, next)
+}
+
+// Invalidate discards every response stored, it's called when a new block is found and after the
+// lotteries are drawn.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	clear(c.entries)
+}
+
+// handle serves the request from the cache if there's a fresh entry for it, otherwise the next
+// handler's response is stored for the TTL specified. Successful responses carry an ETag even if
+// they are not cached.
+func (c *Cache) handle(ttl time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.URL.RequestURI()
+		if entry, ok := c.get(key); ok {
+			w.Header().Set("X-Cache", "HIT")
+			writeCacheEntry(w, r, entry)
+			return
+		}
+
+		c.mu.RLock()
+		generation := c.generation
+		c.mu.RUnlock()
+
+		rec := &responseRecorder{ResponseWriter: w, header: make(http.Header), statusCode: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if rec.statusCode != http.StatusOK {
+			copyHeader(w.Header(), rec.header)
+			w.WriteHeader(rec.statusCode)
+			w.Write(rec.body.Bytes())
+			return
+		}
+
+		hash := sha256.Sum256(rec.body.Bytes())
+		entry := cacheEntry{
+			contentType: rec.header.Get("Content-Type"),
+			etag:        `"` + hex.EncodeToString(hash[:16]) + `"`,
+			body:        rec.body.Bytes(),
+			expiresAt:   c.now().Add(ttl),
+			generation:  generation,
+		}
+		if ttl > 0 {
+			c.set(key, entry)
+		}
+
+		copyHeader(w.Header(), rec.header)
+		w.Header().Set("X-Cache", "MISS")
+		writeCacheEntry(w, r, entry)
+	})
+}
+
+func (c *Cache) get(key string) (cacheEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[key]
+	if !ok || entry.generation != c.generation || !c.now().Before(entry.expiresAt) {
+		return cacheEntry{}, false
+	}
+
+	return entry, true
+}
+
+// set stores the entry unless it was computed before the cache was invalidated. When the cache is
+// full the expired entries are removed, if none is the response is not stored.
+func (c *Cache) set(key string, entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry.generation != c.generation {
+		return
+	}
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.config.MaxEntries {
+		now := c.now()
+		for k, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.config.MaxEntries {
+			return
+		}
+	}
+
+	c.entries[key] = entry
+}
+
+// writeCacheEntry writes the entry's body, or a 304 Not Modified if the client already has it.
+func writeCacheEntry(w http.ResponseWriter, r *http.Request, entry cacheEntry) {
+	w.Header().Set("ETag", entry.etag)
+	if etagMatches(r.Header.Get("If-None-Match"), entry.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if entry.contentType != "" {
+		w.Header().Set("Content-Type", entry.contentType)
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(entry.body)
+}
+
+// etagMatches returns whether the If-None-Match header contains the ETag, weak validators are
+// compared as strong ones as the responses are never transformed.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func copyHeader(dst, src http.Header) {
+	for key, values := range src {
+		dst[key] = values
+	}
+}
+
+// responseRecorder buffers the response of the next handler so it can be stored.
+type responseRecorder struct {
+	http.ResponseWriter
+	header     http.Header
+	body       bytes.Buffer
+	statusCode int
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
@@ -0,0 +1,94 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type counterHandler struct {
+	calls int
+}
+
+func (h *counterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls++
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"height":1}`))
+}
+
+func TestCache(t *testing.T) {
+	next := &counterHandler{}
+	cache := middleware.NewCache(config.Cache{Info: time.Hour})
+	handler := cache.Info(next)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/lottery", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	etag := rec.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/lottery", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "HIT", rec.Header().Get("X-Cache"))
+	assert.Equal(t, `{"height":1}`, rec.Body.String())
+	assert.Equal(t, 1, next.calls)
+
+	cache.Invalidate()
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/lottery", nil))
+	assert.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+	assert.Equal(t, 2, next.calls)
+}
+
+func TestCacheNotModified(t *testing.T) {
+	cases := []struct {
+		desc         string
+		ifNoneMatch  func(etag string) string
+		expectedCode int
+	}{
+		{
+			desc:         "Match",
+			ifNoneMatch:  func(etag string) string { return etag },
+			expectedCode: http.StatusNotModified,
+		},
+		{
+			desc:         "Weak match",
+			ifNoneMatch:  func(etag string) string { return `"other", W/` + etag },
+			expectedCode: http.StatusNotModified,
+		},
+		{
+			desc:         "No match",
+			ifNoneMatch:  func(etag string) string { return `"other"` },
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			// Responses carry an ETag even when caching is disabled
+			handler := middleware.NewCache(config.Cache{}).Stats(&counterHandler{})
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+			etag := rec.Header().Get("ETag")
+
+			req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+			req.Header.Set("If-None-Match", tc.ifNoneMatch(etag))
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, etag, rec.Header().Get("ETag"))
+		})
+	}
+}
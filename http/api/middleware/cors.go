@@ -11,6 +11,8 @@ func Cors(next http.Handler) http.Handler {
 		header["Access-Control-Allow-Headers"] = []string{"Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, accept, origin, Cache-Control, X-Requested-With"}
 		header["Access-Control-Allow-Methods"] = []string{"GET, POST, HEAD"}
 		header["Access-Control-Max-Age"] = []string{"600"}
+		// Let browsers verify the signed responses
+		header["Access-Control-Expose-Headers"] = []string{"X-BTRY-Timestamp, X-BTRY-Signature"}
 		header["Vary"] = []string{"Origin, Access-Control-Request-Method, Access-Control-Request-Headers"}
 
 		// Non CORS headers added for extra security
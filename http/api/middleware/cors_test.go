@@ -17,15 +17,16 @@ func TestCors(t *testing.T) {
 	corsHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
 
 	headers := map[string]string{
-		"Access-Control-Allow-Origin":  "*",
-		"Access-Control-Allow-Headers": "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, accept, origin, Cache-Control, X-Requested-With",
-		"Access-Control-Allow-Methods": "GET, POST, HEAD",
-		"Access-Control-Max-Age":       "600",
-		"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
-		"X-Xss-Protection":             "1; mode=block",
-		"Strict-Transport-Security":    "max-age=63072000; includeSubDomains; preload",
-		"X-Frame-Options":              "DENY",
-		"X-Content-Type-Options":       "nosniff",
+		"Access-Control-Allow-Origin":   "*",
+		"Access-Control-Allow-Headers":  "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, accept, origin, Cache-Control, X-Requested-With",
+		"Access-Control-Allow-Methods":  "GET, POST, HEAD",
+		"Access-Control-Max-Age":        "600",
+		"Access-Control-Expose-Headers": "X-BTRY-Timestamp, X-BTRY-Signature",
+		"Vary":                          "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+		"X-Xss-Protection":              "1; mode=block",
+		"Strict-Transport-Security":     "max-age=63072000; includeSubDomains; preload",
+		"X-Frame-Options":               "DENY",
+		"X-Content-Type-Options":        "nosniff",
 		// "Content-Security-Policy":           "default-src 'self'",
 		"X-Permitted-Cross-Domain-Policies": "none",
 		"Referrer-Policy":                   "no-referrer",
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+	"github.com/aftermath2/BTRY/policy"
+)
+
+// Jurisdiction rejects the requests of the clients whose address is not allowed to place bets.
+type Jurisdiction struct {
+	jurisdiction *policy.Jurisdiction
+}
+
+// NewJurisdiction returns a new jurisdiction gating middleware.
+func NewJurisdiction(jurisdiction *policy.Jurisdiction) *Jurisdiction {
+	return &Jurisdiction{
+		jurisdiction: jurisdiction,
+	}
+}
+
+// Handle responds with a 451 Unavailable For Legal Reasons status if the client is blocked.
+func (j *Jurisdiction) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Forwarded headers may quote the address and enclose IPv6 ones in brackets
+		ip := strings.Trim(getClientIP(r), `"[]`)
+
+		if err := j.jurisdiction.Check(ip); err != nil {
+			apierrors.Write(w, http.StatusUnavailableForLegalReasons, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
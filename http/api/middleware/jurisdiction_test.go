@@ -0,0 +1,72 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJurisdiction(t *testing.T) {
+	jurisdiction, err := policy.NewJurisdiction(config.Jurisdiction{
+		Mode:  "block",
+		CIDRs: []string{"203.0.113.0/24", "2001:db8::/32"},
+	})
+	require.NoError(t, err)
+	handler := middleware.NewJurisdiction(jurisdiction).Handle(&noopHandler{})
+
+	cases := []struct {
+		desc       string
+		remoteAddr string
+		headers    map[string]string
+		expected   int
+	}{
+		{
+			desc:       "Blocked remote address",
+			remoteAddr: "203.0.113.7:4321",
+			expected:   http.StatusUnavailableForLegalReasons,
+		},
+		{
+			desc:       "Blocked forwarded address",
+			remoteAddr: "127.0.0.1:4321",
+			headers:    map[string]string{"Forwarded": `for="[2001:db8::1]"`},
+			expected:   http.StatusUnavailableForLegalReasons,
+		},
+		{
+			desc:       "Allowed",
+			remoteAddr: "198.51.100.1:4321",
+			headers:    map[string]string{"X-Forwarded-For": "192.0.2.1, 203.0.113.7"},
+			expected:   http.StatusOK,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/invoice", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expected, rec.Code)
+			if tc.expected != http.StatusOK {
+				var resp apierrors.Response
+				err := json.NewDecoder(rec.Body).Decode(&resp)
+				require.NoError(t, err)
+				assert.Equal(t, apierrors.CodeJurisdictionBlocked, resp.Error.Code)
+				assert.Equal(t, policy.ErrJurisdictionBlocked.Error(), resp.Error.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+	"github.com/aftermath2/BTRY/leader"
+)
+
+// Leader rejects the requests received by the followers of a high-availability deployment.
+type Leader struct {
+	elector leader.Elector
+}
+
+// NewLeader returns a new leader middleware.
+func NewLeader(elector leader.Elector) *Leader {
+	return &Leader{
+		elector: elector,
+	}
+}
+
+// Handle responds with a 503 Service Unavailable instead of calling the next handler if the
+// instance is not the leader, the invoices and payments are only tracked by the leader.
+func (l *Leader) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.elector.IsLeader() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		err := apierrors.New(apierrors.CodeNotLeader, "this instance is a follower, retry on the leader")
+		apierrors.Write(w, http.StatusServiceUnavailable, err)
+	})
+}
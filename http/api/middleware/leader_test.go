@@ -0,0 +1,56 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+	"github.com/aftermath2/BTRY/leader"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeader(t *testing.T) {
+	cases := []struct {
+		desc         string
+		isLeader     bool
+		expectedCode int
+	}{
+		{
+			desc:         "Leader",
+			isLeader:     true,
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc:         "Follower",
+			isLeader:     false,
+			expectedCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			electorMock := leader.NewElectorMock()
+			electorMock.On("IsLeader").Return(tc.isLeader)
+			handler := middleware.NewLeader(electorMock).Handle(&noopHandler{})
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			if tc.isLeader {
+				return
+			}
+
+			var resp apierrors.Response
+			err := json.NewDecoder(rec.Body).Decode(&resp)
+			require.NoError(t, err)
+			assert.Equal(t, apierrors.CodeNotLeader, resp.Error.Code)
+			assert.True(t, resp.Error.Retryable)
+		})
+	}
+}
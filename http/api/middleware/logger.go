@@ -26,7 +26,9 @@ func NewLogger(config config.Logger) (*Logger, error) {
 // Log intercepts and logs each API request.
 func (l *Logger) Log(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/api/events" {
+		// Long-lived connections are logged when they start, the websocket also needs the original
+		// writer to hijack the connection
+		if r.URL.Path == "/api/events" || r.URL.Path == "/api/live" {
 			l.logger.Info(r.Method, " ", r.URL.Path)
 			next.ServeHTTP(w, r)
 			return
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+	"github.com/aftermath2/BTRY/policy"
+)
+
+// Maintenance rejects the requests while a maintenance window is in progress.
+type Maintenance struct {
+	maintenance *policy.Maintenance
+}
+
+// NewMaintenance returns a new maintenance middleware.
+func NewMaintenance(maintenance *policy.Maintenance) *Maintenance {
+	return &Maintenance{
+		maintenance: maintenance,
+	}
+}
+
+// Handle responds with a 503 Service Unavailable and the expected end of the maintenance instead
+// of calling the next handler.
+func (m *Maintenance) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := m.maintenance.Status()
+		if !status.Active {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		retryAfter := max(status.Until-time.Now().Unix(), 1)
+		w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+		// Until is the unix timestamp at which the maintenance is expected to end
+		message := "under maintenance until " + time.Unix(status.Until, 0).UTC().Format(time.RFC3339)
+		err := apierrors.New(apierrors.CodeMaintenance, message).WithDetail("until", status.Until)
+		apierrors.Write(w, http.StatusServiceUnavailable, err)
+	})
+}
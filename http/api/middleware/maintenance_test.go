@@ -0,0 +1,49 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenance(t *testing.T) {
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	maintenance := policy.NewMaintenance(config.Maintenance{Until: until})
+	handler := middleware.NewMaintenance(maintenance).Handle(&noopHandler{})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	var resp apierrors.Response
+	err := json.NewDecoder(rec.Body).Decode(&resp)
+	require.NoError(t, err)
+
+	assert.Equal(t, apierrors.CodeMaintenance, resp.Error.Code)
+	assert.True(t, resp.Error.Retryable)
+	assert.Equal(t, float64(until.Unix()), resp.Error.Details["until"])
+	assert.Contains(t, resp.Error.Message, "under maintenance until")
+}
+
+func TestMaintenanceInactive(t *testing.T) {
+	from := time.Now().Add(time.Hour)
+	maintenance := policy.NewMaintenance(config.Maintenance{From: from, Until: from.Add(time.Hour)})
+	handler := middleware.NewMaintenance(maintenance).Handle(&noopHandler{})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
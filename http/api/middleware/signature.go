@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/crypto"
+)
+
+// Signature signs the responses of the critical endpoints with the server identity key, so clients
+// and mirrors can detect if they were tampered with on their way.
+type Signature struct {
+	identity *crypto.Identity
+	enabled  bool
+}
+
+// NewSignature returns a new signature middleware.
+func NewSignature(config config.Identity, identity *crypto.Identity) *Signature {
+	return &Signature{
+		identity: identity,
+		enabled:  config.SignResponses && identity != nil,
+	}
+}
+
+// Handle buffers the next handler's response and sends it with the time it was signed at and the
+// detached signature of its body. 304 Not Modified responses have no body and are not signed.
+func (s *Signature) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, header: make(http.Header), statusCode: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		copyHeader(w.Header(), rec.header)
+		if rec.statusCode != http.StatusNotModified {
+			timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			w.Header().Set(crypto.TimestampHeader, timestamp)
+			w.Header().Set(crypto.SignatureHeader, s.identity.SignResponse(timestamp, rec.body.Bytes()))
+		}
+		w.WriteHeader(rec.statusCode)
+		w.Write(rec.body.Bytes())
+	})
+}
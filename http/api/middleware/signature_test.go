@@ -0,0 +1,62 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/crypto"
+	"github.com/aftermath2/BTRY/http/api/middleware"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignature(t *testing.T) {
+	identityConfig := config.Identity{
+		PrivateKey:    "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb",
+		SignResponses: true,
+	}
+	identity, err := crypto.NewIdentity(identityConfig)
+	require.NoError(t, err)
+
+	// The cache answers with a 304 when the client already has the response
+	cache := middleware.NewCache(config.Cache{})
+	handler := middleware.NewSignature(identityConfig, identity).Handle(cache.Info(&counterHandler{}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/lottery", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"height":1}`, rec.Body.String())
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	timestamp := rec.Header().Get(crypto.TimestampHeader)
+	signature := rec.Header().Get(crypto.SignatureHeader)
+	assert.NoError(t, crypto.VerifyResponse(identity.PublicKey(), timestamp, rec.Body.Bytes(), signature))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/lottery", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Header().Get(crypto.SignatureHeader))
+}
+
+func TestSignatureDisabled(t *testing.T) {
+	identityConfig := config.Identity{
+		PrivateKey: "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb",
+	}
+	identity, err := crypto.NewIdentity(identityConfig)
+	require.NoError(t, err)
+
+	handler := middleware.NewSignature(identityConfig, identity).Handle(&counterHandler{})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/lottery", nil))
+
+	assert.Equal(t, `{"height":1}`, rec.Body.String())
+	assert.Empty(t, rec.Header().Get(crypto.TimestampHeader))
+	assert.Empty(t, rec.Header().Get(crypto.SignatureHeader))
+}
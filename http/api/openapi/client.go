@@ -0,0 +1,192 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// GoClient returns the source code of the operations of the Go client package.
+func GoClient() ([]byte, error) {
+	imports := map[string]string{
+		"context":  "",
+		"net/http": "",
+		"net/url":  "",
+	}
+
+	var b bytes.Buffer
+	for _, op := range Operations {
+		params := op.parameters()
+		if len(params) != 0 {
+			writeParams(&b, op.ID, params)
+		}
+
+		responseType := goType(reflect.TypeOf(op.Response), imports)
+		signature := "ctx context.Context"
+		if len(params) != 0 {
+			signature += ", params " + op.ID + "Params"
+		}
+		body := "nil"
+		if op.Body != nil {
+			signature += ", body " + goType(reflect.TypeOf(op.Body), imports)
+			body = "body"
+		}
+
+		fmt.Fprintf(&b, "// %s %s.\n", op.ID, lowerFirst(op.Summary))
+		fmt.Fprintf(&b, "func (c *Client) %s(%s) (%s, error) {\n", op.ID, signature, responseType)
+		query := "nil"
+		if len(params) != 0 {
+			query = "query"
+			b.WriteString("query := url.Values{}\n")
+			for _, param := range params {
+				writeParamEncoding(&b, param, imports)
+			}
+		}
+		fmt.Fprintf(&b, "var resp %s\n", responseType)
+		fmt.Fprintf(&b, "err := c.do(ctx, http.Method%s, %q, %s, %t, %s, &resp)\n",
+			methodName(op.Method), op.Path, query, op.Auth != AuthNone, body)
+		b.WriteString("return resp, err\n}\n\n")
+	}
+
+	paths := make([]string, 0, len(imports))
+	for p := range imports {
+		paths = append(paths, p)
+	}
+	// The standard library packages go first, separated from the rest
+	sort.Slice(paths, func(i, j int) bool {
+		if standardPackage(paths[i]) != standardPackage(paths[j]) {
+			return standardPackage(paths[i])
+		}
+		return paths[i] < paths[j]
+	})
+
+	var src bytes.Buffer
+	src.WriteString("// Code generated by go generate; DO NOT EDIT.\n\npackage client\n\nimport (\n")
+	for i, p := range paths {
+		if i > 0 && standardPackage(paths[i-1]) && !standardPackage(p) {
+			src.WriteString("\n")
+		}
+		fmt.Fprintf(&src, "%s %q\n", imports[p], p)
+	}
+	src.WriteString(")\n\n")
+	src.Write(b.Bytes())
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "formatting client")
+	}
+
+	return formatted, nil
+}
+
+func writeParams(b *bytes.Buffer, id string, params []Param) {
+	fmt.Fprintf(b, "// %sParams contains the parameters of %s.\n", id, id)
+	fmt.Fprintf(b, "type %sParams struct {\n", id)
+	for _, param := range params {
+		if param.Description != "" {
+			fmt.Fprintf(b, "// %s\n", param.Description)
+		}
+		typ := param.Kind.String()
+		if param.Repeated {
+			typ = "[]" + typ
+		}
+		fmt.Fprintf(b, "%s %s\n", param.field(), typ)
+	}
+	b.WriteString("}\n\n")
+}
+
+func writeParamEncoding(b *bytes.Buffer, param Param, imports map[string]string) {
+	value := "params." + param.field()
+	if param.Repeated {
+		fmt.Fprintf(b, "for _, v := range %s {\n", value)
+		fmt.Fprintf(b, "query.Add(%q, %s)\n", param.Name, encodeParam(param.Kind, "v", imports))
+		b.WriteString("}\n")
+		return
+	}
+
+	set := fmt.Sprintf("query.Set(%q, %s)\n", param.Name, encodeParam(param.Kind, value, imports))
+	if param.Required {
+		b.WriteString(set)
+		return
+	}
+
+	switch param.Kind {
+	case reflect.Bool:
+		fmt.Fprintf(b, "if %s {\n", value)
+	case reflect.Uint64:
+		fmt.Fprintf(b, "if %s != 0 {\n", value)
+	default:
+		fmt.Fprintf(b, "if %s != \"\" {\n", value)
+	}
+	b.WriteString(set)
+	b.WriteString("}\n")
+}
+
+func encodeParam(kind reflect.Kind, value string, imports map[string]string) string {
+	switch kind {
+	case reflect.Bool:
+		imports["strconv"] = ""
+		return "strconv.FormatBool(" + value + ")"
+	case reflect.Uint64:
+		imports["strconv"] = ""
+		return "strconv.FormatUint(" + value + ", 10)"
+	default:
+		return value
+	}
+}
+
+// goType returns the expression of the type, importing its package.
+func goType(t reflect.Type, imports map[string]string) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return "*" + goType(t.Elem(), imports)
+	case reflect.Slice:
+		return "[]" + goType(t.Elem(), imports)
+	case reflect.Map:
+		return "map[" + goType(t.Key(), imports) + "]" + goType(t.Elem(), imports)
+	}
+
+	if t.PkgPath() == "" {
+		return t.Name()
+	}
+
+	base := path.Base(t.PkgPath())
+	alias := strings.ToLower(exportedName(base))
+	if alias == base {
+		imports[t.PkgPath()] = ""
+	} else {
+		imports[t.PkgPath()] = alias
+	}
+
+	return alias + "." + t.Name()
+}
+
+func standardPackage(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// field returns the name of the parameter in the Go client.
+func (p Param) field() string {
+	if p.Field != "" {
+		return p.Field
+	}
+	return exportedName(p.Name)
+}
+
+func methodName(method string) string {
+	return method[:1] + strings.ToLower(method[1:])
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,40 @@
+// Command gen writes the OpenAPI document of the API, the TypeScript types and the Go client
+// operations generated from it.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/aftermath2/BTRY/http/api/openapi"
+)
+
+func main() {
+	spec := flag.String("spec", "", "OpenAPI document path")
+	typescript := flag.String("typescript", "", "TypeScript types path")
+	client := flag.String("client", "", "Go client operations path")
+	flag.Parse()
+
+	if *spec != "" {
+		write(*spec, openapi.JSON())
+	}
+
+	if *typescript != "" {
+		write(*typescript, openapi.TypeScript())
+	}
+
+	if *client != "" {
+		source, err := openapi.GoClient()
+		if err != nil {
+			log.Fatal(err)
+		}
+		write(*client, source)
+	}
+}
+
+func write(path string, data []byte) {
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
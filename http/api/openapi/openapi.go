@@ -0,0 +1,240 @@
+// Package openapi describes the public HTTP API as an OpenAPI 3 document.
+//
+// The schemas are generated from the types the handlers respond with, and the Go client and the
+// TypeScript types are generated from the document, so neither of them drifts from the server.
+package openapi
+
+//go:generate go run ./gen -spec openapi.json -typescript ../../../ui/src/types/openapi.ts -client ../../../client/operations.go
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	apierrors "github.com/aftermath2/BTRY/http/api/errors"
+)
+
+// Version is the version of the API described.
+const Version = "1.0.0"
+
+const securityScheme = "publicKey"
+
+// Document is an OpenAPI document.
+type Document struct {
+	Paths      map[string]map[string]*OperationObject `json:"paths"`
+	Components Components                             `json:"components"`
+	Info       Info                                   `json:"info"`
+	OpenAPI    string                                 `json:"openapi"`
+	Servers    []Server                               `json:"servers"`
+}
+
+// Info contains the API metadata.
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Server is the base URL of the API.
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Components contains the objects referenced in the document.
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme is an authentication method.
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme"`
+	Description string `json:"description,omitempty"`
+}
+
+// OperationObject describes an endpoint.
+type OperationObject struct {
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Parameters  []ParameterObject     `json:"parameters,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// ParameterObject describes a parameter of an endpoint.
+type ParameterObject struct {
+	Schema      *Schema `json:"schema"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Explode     bool    `json:"explode,omitempty"`
+}
+
+// RequestBody describes the body of a request.
+type RequestBody struct {
+	Content  map[string]MediaType `json:"content"`
+	Required bool                 `json:"required"`
+}
+
+// Response describes the response of an endpoint.
+type Response struct {
+	Content     map[string]MediaType `json:"content,omitempty"`
+	Description string               `json:"description"`
+}
+
+// MediaType contains the schema of a body.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+var (
+	document     Document
+	documentJSON []byte
+	documentOnce sync.Once
+)
+
+// Spec returns the document describing the operations of the API.
+func Spec() Document {
+	documentOnce.Do(func() {
+		document = build(Operations)
+		documentJSON, _ = json.MarshalIndent(document, "", "  ")
+		documentJSON = append(documentJSON, '\n')
+	})
+	return document
+}
+
+// JSON returns the document encoded in JSON.
+func JSON() []byte {
+	Spec()
+	return documentJSON
+}
+
+// ServeHTTP responds with the document encoded in JSON.
+func ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Write(JSON())
+}
+
+// build returns the document describing the operations.
+func build(operations []Operation) Document {
+	schemas := newSchemas()
+	errorSchema := schemas.of(ErrorResponse{})
+
+	doc := Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: "BTRY", Version: Version},
+		Servers: []Server{{URL: "/api"}},
+		Paths:   make(map[string]map[string]*OperationObject),
+		Components: Components{
+			Schemas: schemas.components,
+			SecuritySchemes: map[string]SecurityScheme{
+				securityScheme: {
+					Type:        "http",
+					Scheme:      "bearer",
+					Description: "Player public key, hex encoded",
+				},
+			},
+		},
+	}
+
+	for _, op := range operations {
+		object := &OperationObject{
+			OperationID: op.ID,
+			Summary:     op.Summary,
+			Responses: map[string]Response{
+				"200": {
+					Description: "Success",
+					Content:     jsonContent(schemas.of(op.Response)),
+				},
+				"default": {
+					Description: "Error",
+					Content:     jsonContent(errorSchema),
+				},
+			},
+		}
+
+		if op.Auth != AuthNone {
+			object.Security = []map[string][]string{{securityScheme: {}}}
+		}
+
+		for _, param := range op.parameters() {
+			object.Parameters = append(object.Parameters, param.object())
+		}
+
+		if op.Body != nil {
+			object.RequestBody = &RequestBody{
+				Required: true,
+				Content:  jsonContent(schemas.of(op.Body)),
+			}
+		}
+
+		path, ok := doc.Paths[op.Path]
+		if !ok {
+			path = make(map[string]*OperationObject)
+			doc.Paths[op.Path] = path
+		}
+		path[strings.ToLower(op.Method)] = object
+	}
+
+	for _, schema := range schemas.components {
+		sort.Strings(schema.Required)
+	}
+
+	return doc
+}
+
+// ErrorResponse is the body of the responses of failed requests. LNURL endpoints set the status and
+// the reason instead of the error.
+type ErrorResponse struct {
+	Error  *apierrors.Error `json:"error,omitempty"`
+	Status string           `json:"status,omitempty"`
+	Reason string           `json:"reason,omitempty"`
+}
+
+// parameters returns the parameters of the operation, including the signature of the ones that
+// require it.
+func (op Operation) parameters() []Param {
+	if op.Auth != AuthSignature {
+		return op.Params
+	}
+
+	signature := Param{
+		Name:        "signature",
+		Kind:        reflect.String,
+		Required:    true,
+		Description: "Signature of the public key",
+	}
+	return append(append([]Param{}, op.Params...), signature)
+}
+
+func (p Param) object() ParameterObject {
+	schema := &Schema{Type: "string"}
+	switch p.Kind {
+	case reflect.Bool:
+		schema = &Schema{Type: "boolean"}
+	case reflect.Uint64:
+		schema = &Schema{Type: "integer", Format: "int64"}
+	}
+
+	if p.Repeated {
+		schema = &Schema{Type: "array", Items: schema}
+	}
+
+	return ParameterObject{
+		Name:        p.Name,
+		In:          "query",
+		Description: p.Description,
+		Required:    p.Required,
+		Explode:     p.Repeated,
+		Schema:      schema,
+	}
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
@@ -0,0 +1,81 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aftermath2/BTRY/http/api/openapi"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerated(t *testing.T) {
+	client, err := openapi.GoClient()
+	assert.NoError(t, err)
+
+	cases := []struct {
+		path      string
+		generated []byte
+	}{
+		{path: "openapi.json", generated: openapi.JSON()},
+		{path: "../../../ui/src/types/openapi.ts", generated: openapi.TypeScript()},
+		{path: "../../../client/operations.go", generated: client},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			committed, err := os.ReadFile(tc.path)
+			assert.NoError(t, err)
+
+			assert.Equal(t, string(committed), string(tc.generated), "Outdated, run go generate")
+		})
+	}
+}
+
+func TestSpec(t *testing.T) {
+	doc := openapi.Spec()
+
+	var decoded map[string]any
+	assert.NoError(t, json.Unmarshal(openapi.JSON(), &decoded))
+	assert.Equal(t, "3.0.3", decoded["openapi"])
+
+	for _, op := range openapi.Operations {
+		object, ok := doc.Paths[op.Path][strings.ToLower(op.Method)]
+		if !assert.True(t, ok, op.ID) {
+			continue
+		}
+		assert.Equal(t, op.ID, object.OperationID)
+		assert.Equal(t, op.Auth != openapi.AuthNone, len(object.Security) == 1, op.ID)
+	}
+
+	bets := doc.Paths["/bets"]["get"]
+	assert.Equal(t, "#/components/schemas/BetsResponse", bets.Responses["200"].Content["application/json"].Schema.Ref)
+	assert.Equal(t, "height", bets.Parameters[0].Name)
+	assert.True(t, bets.Parameters[0].Required)
+
+	cancel := doc.Paths["/bets"]["delete"]
+	signature := cancel.Parameters[len(cancel.Parameters)-1]
+	assert.Equal(t, "signature", signature.Name)
+	assert.True(t, signature.Required)
+
+	withdraw := doc.Paths["/withdraw"]["post"]
+	for _, param := range withdraw.Parameters {
+		if param.Name == "pr" {
+			assert.Equal(t, "array", param.Schema.Type)
+			assert.True(t, param.Explode)
+		}
+	}
+
+	lottery, ok := doc.Components.Schemas["LotteryResponse"]
+	assert.True(t, ok)
+	assert.Equal(t, "integer", lottery.Properties["next_height"].Type)
+	assert.Contains(t, lottery.Required, "next_height")
+	assert.Contains(t, lottery.Required, "network")
+	assert.NotContains(t, lottery.Required, "fiat")
+
+	commitment := doc.Paths["/lottery/commitment"]["get"]
+	assert.Equal(t, "#/components/schemas/Commitment", commitment.Responses["200"].Content["application/json"].Schema.Ref)
+	assert.Equal(t, "#/components/schemas/ErrorResponse", commitment.Responses["default"].Content["application/json"].Schema.Ref)
+}
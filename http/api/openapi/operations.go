@@ -0,0 +1,546 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/http/api/tokens"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/fiatjaf/go-lnurl"
+)
+
+// Auth is how an operation identifies the player.
+type Auth int
+
+// Authentication methods.
+const (
+	// AuthNone operations are public
+	AuthNone Auth = iota
+	// AuthPublicKey operations take the player public key in the Authorization header
+	AuthPublicKey
+	// AuthSignature operations also take the signature of the public key in the signature
+	// parameter, to prove its ownership
+	AuthSignature
+)
+
+// Param is a query parameter of an operation. Kind is one of reflect.Bool, reflect.Uint64 or
+// reflect.String.
+type Param struct {
+	Name string
+	// Field is the name of the parameter in the Go client, the name in camel case by default
+	Field       string
+	Description string
+	Kind        reflect.Kind
+	Required    bool
+	// Repeated parameters can be specified multiple times
+	Repeated bool
+}
+
+// Operation is an endpoint of the API. Response and Body are zero values of the response and
+// request body types.
+type Operation struct {
+	Response any
+	Body     any
+	ID       string
+	Method   string
+	// Path is relative to /api
+	Path    string
+	Summary string
+	Params  []Param
+	Auth    Auth
+}
+
+var (
+	offsetParam  = Param{Name: "offset", Kind: reflect.Uint64, Description: "Number of items to skip"}
+	limitParam   = Param{Name: "limit", Kind: reflect.Uint64, Description: "Maximum number of items returned"}
+	reverseParam = Param{Name: "reverse", Kind: reflect.Bool, Description: "List the newest items first"}
+	poolParam    = Param{Name: "pool", Kind: reflect.String, Description: "Lottery pool, empty for the unnamed one"}
+	prParam      = Param{
+		Name:        "pr",
+		Field:       "PaymentRequests",
+		Kind:        reflect.String,
+		Required:    true,
+		Repeated:    true,
+		Description: "Invoice to pay, repeat it to split the withdrawal. A single zero-amount invoice is paid with all the prizes left after the fee",
+	}
+	feeParam = Param{
+		Name:        "fee",
+		Field:       "Fees",
+		Kind:        reflect.Uint64,
+		Repeated:    true,
+		Description: "Maximum routing fee of each invoice, in sats",
+	}
+)
+
+// Operations contains the public endpoints of the API. The administration API, the server-sent
+// events and GraphQL are not included.
+var Operations = []Operation{
+	{
+		ID:       "GetArchives",
+		Method:   http.MethodGet,
+		Path:     "/archives",
+		Summary:  "Lists the exports of the draw history to Nostr and IPFS",
+		Params:   []Param{offsetParam, limitParam, reverseParam},
+		Response: handler.ArchivesResponse{},
+	},
+	{
+		ID:      "GetBets",
+		Method:  http.MethodGet,
+		Path:    "/bets",
+		Summary: "Lists the bets of a lottery",
+		Params: []Param{
+			{Name: "height", Kind: reflect.Uint64, Required: true, Description: "Lottery height"},
+			offsetParam, limitParam, reverseParam, poolParam,
+		},
+		Response: handler.BetsResponse{},
+	},
+	{
+		ID:      "CancelBet",
+		Method:  http.MethodDelete,
+		Path:    "/bets",
+		Summary: "Cancels a recent bet, refunding the sats paid minus the cancellation fee",
+		Auth:    AuthSignature,
+		Params: []Param{
+			{Name: "payment_hash", Kind: reflect.String, Required: true, Description: "Hash of the invoice that paid the bet"},
+		},
+		Response: handler.CancelBetResponse{},
+	},
+	{
+		ID:      "GetClaim",
+		Method:  http.MethodGet,
+		Path:    "/claim",
+		Summary: "Returns the prizes of an anonymous bet",
+		Params: []Param{
+			{Name: "code", Kind: reflect.String, Required: true, Description: "Claim code of the bet"},
+		},
+		Response: handler.GetPrizesResponse{},
+	},
+	{
+		ID:      "Claim",
+		Method:  http.MethodPost,
+		Path:    "/claim",
+		Summary: "Withdraws the prizes of an anonymous bet",
+		Params: []Param{
+			{Name: "code", Kind: reflect.String, Required: true, Description: "Claim code of the bet"},
+			prParam, feeParam,
+		},
+		Response: handler.WithdrawResponse{},
+	},
+	{
+		ID:       "StartSwapClaim",
+		Method:   http.MethodPost,
+		Path:     "/claims/swap",
+		Summary:  "Returns the payment hash the hold invoice of a swap claim must be locked to",
+		Auth:     AuthSignature,
+		Response: handler.SwapClaimResponse{},
+	},
+	{
+		ID:      "CountersignSwapClaim",
+		Method:  http.MethodPost,
+		Path:    "/claims/swap/countersign",
+		Summary: "Countersigns the receipt of a swap claim, revealing the preimage of the hold invoice",
+		Auth:    AuthSignature,
+		Params: []Param{
+			{Name: "payment_hash", Kind: reflect.String, Required: true, Description: "Payment hash of the swap claim"},
+			{Name: "countersignature", Kind: reflect.String, Required: true, Description: "Player signature of the receipt"},
+		},
+		Response: handler.SwapPreimageResponse{},
+	},
+	{
+		ID:      "IssueClaimToken",
+		Method:  http.MethodPost,
+		Path:    "/claims/token",
+		Summary: "Returns a short-lived token that lets a third-party site start the claim flow",
+		Auth:    AuthSignature,
+		Params: []Param{
+			{Name: "origin", Kind: reflect.String, Required: true, Description: "Origin of the site the token is bound to"},
+		},
+		Response: tokens.Token{},
+	},
+	{
+		ID:      "GetWidgetClaim",
+		Method:  http.MethodGet,
+		Path:    "/claims/widget",
+		Summary: "Returns the prizes a claim token gives access to",
+		Params: []Param{
+			{Name: "token", Kind: reflect.String, Required: true, Description: "Claim token"},
+		},
+		Response: handler.GetPrizesResponse{},
+	},
+	{
+		ID:      "WidgetClaim",
+		Method:  http.MethodPost,
+		Path:    "/claims/widget",
+		Summary: "Withdraws the prizes a claim token gives access to",
+		Params: []Param{
+			{Name: "token", Kind: reflect.String, Required: true, Description: "Claim token"},
+			prParam, feeParam,
+			{Name: "confirmation", Kind: reflect.String, Required: true, Description: "Winner signature of the token ID and the invoices"},
+		},
+		Response: handler.WithdrawResponse{},
+	},
+	{
+		ID:      "PaySwapClaim",
+		Method:  http.MethodPost,
+		Path:    "/claims/swap/pay",
+		Summary: "Withdraws the prizes paying a hold invoice locked to the swap claim hash",
+		Auth:    AuthSignature,
+		Params: []Param{
+			{Name: "pr", Field: "PaymentRequest", Kind: reflect.String, Required: true, Description: "Hold invoice to pay"},
+			{Name: "fee", Kind: reflect.Uint64, Description: "Maximum routing fee, in sats"},
+		},
+		Response: handler.SwapPaymentResponse{},
+	},
+	{
+		ID:       "GetHeights",
+		Method:   http.MethodGet,
+		Path:     "/heights",
+		Summary:  "Lists the heights of the lotteries",
+		Params:   []Param{offsetParam, limitParam, reverseParam},
+		Response: handler.HeightsResponse{},
+	},
+	{
+		ID:       "GetIdentity",
+		Method:   http.MethodGet,
+		Path:     "/identity",
+		Summary:  "Returns the server identity key the responses of the critical endpoints are signed with",
+		Response: handler.IdentityResponse{},
+	},
+	{
+		ID:      "GetInvoice",
+		Method:  http.MethodGet,
+		Path:    "/invoice",
+		Summary: "Creates the invoice of a bet, anonymous bets don't take a public key",
+		Auth:    AuthPublicKey,
+		Params: []Param{
+			{Name: "amount", Kind: reflect.Uint64, Required: true, Description: "Amount bet, in sats"},
+			{Name: "anonymous", Kind: reflect.Bool, Description: "Place an anonymous bet"},
+			{Name: "swap", Kind: reflect.Bool, Description: "Pay the invoice with on-chain funds through a submarine swap"},
+			{Name: "refund_public_key", Kind: reflect.String, Description: "Compressed public key to refund a failed swap with, hex encoded"},
+		},
+		Response: handler.InvoiceResponse{},
+	},
+	{
+		ID:      "GetInvoiceSwap",
+		Method:  http.MethodGet,
+		Path:    "/invoice/swap",
+		Summary: "Returns the status of the submarine swap paying a bet invoice",
+		Params: []Param{
+			{Name: "id", Field: "ID", Kind: reflect.String, Required: true, Description: "Swap ID"},
+		},
+		Response: handler.InvoiceSwapResponse{},
+	},
+	{
+		ID:       "GetLottery",
+		Method:   http.MethodGet,
+		Path:     "/lottery",
+		Summary:  "Returns the lottery in progress",
+		Response: handler.LotteryResponse{},
+	},
+	{
+		ID:      "GetBetArchive",
+		Method:  http.MethodGet,
+		Path:    "/lottery/archive",
+		Summary: "Returns the bets of a drawn lottery and its commitment",
+		Params: []Param{
+			{Name: "height", Kind: reflect.Uint64, Required: true, Description: "Lottery height"},
+		},
+		Response: handler.BetArchiveResponse{},
+	},
+	{
+		ID:      "GetCommitment",
+		Method:  http.MethodGet,
+		Path:    "/lottery/commitment",
+		Summary: "Returns the commitment to the server seed of a lottery",
+		Params: []Param{
+			{Name: "height", Kind: reflect.Uint64, Description: "Lottery height, the one in progress by default"},
+		},
+		Response: db.Commitment{},
+	},
+	{
+		ID:       "GetFairness",
+		Method:   http.MethodGet,
+		Path:     "/lottery/fairness",
+		Summary:  "Lists the fairness reports of the lotteries drawn",
+		Params:   []Param{offsetParam, limitParam, reverseParam},
+		Response: handler.FairnessResponse{},
+	},
+	{
+		ID:       "GetLightningAddress",
+		Method:   http.MethodGet,
+		Path:     "/lightning/address",
+		Summary:  "Returns the lightning address prizes are sent to",
+		Auth:     AuthPublicKey,
+		Response: handler.GetLightningAddressResponse{},
+	},
+	{
+		ID:      "SetLightningAddress",
+		Method:  http.MethodPost,
+		Path:    "/lightning/address",
+		Summary: "Links a lightning address prizes are sent to automatically",
+		Auth:    AuthPublicKey,
+		Params: []Param{
+			{Name: "address", Kind: reflect.String, Required: true, Description: "Lightning address"},
+		},
+		Response: handler.SetLightningAddressResponse{},
+	},
+	{
+		ID:      "LNURLWithdraw",
+		Method:  http.MethodGet,
+		Path:    "/lightning/lnurlw",
+		Summary: "Returns the LNURL-withdraw request of the prizes",
+		Params: []Param{
+			{Name: "pubkey", Field: "PublicKey", Kind: reflect.String, Required: true, Description: "Player public key"},
+			{Name: "signature", Kind: reflect.String, Required: true, Description: "Signature of the public key"},
+		},
+		Response: lnurl.LNURLWithdrawResponse{},
+	},
+	{
+		ID:       "LNURLPay",
+		Method:   http.MethodGet,
+		Path:     "/lightning/lnurlp",
+		Summary:  "Returns the LNURL-pay request that sells a ticket of a fixed amount",
+		Response: lnurl.LNURLPayParams{},
+	},
+	{
+		ID:      "LNURLPayCallback",
+		Method:  http.MethodGet,
+		Path:    "/lightning/lnurlp/callback",
+		Summary: "Creates the invoice of a ticket, bets without a public key in the comment are anonymous",
+		Params: []Param{
+			{Name: "amount", Kind: reflect.Uint64, Required: true, Description: "Ticket amount, in millisatoshis"},
+			{Name: "comment", Kind: reflect.String, Description: "Public key the bet is registered under"},
+		},
+		Response: lnurl.LNURLPayValues{},
+	},
+	{
+		ID:       "GetLimits",
+		Method:   http.MethodGet,
+		Path:     "/limits",
+		Summary:  "Returns the responsible gambling limits",
+		Auth:     AuthPublicKey,
+		Response: handler.LimitsResponse{},
+	},
+	{
+		ID:      "SetLimit",
+		Method:  http.MethodPost,
+		Path:    "/limits",
+		Summary: "Sets a deposit or loss limit, loosening one takes effect after the cooldown",
+		Auth:    AuthSignature,
+		Params: []Param{
+			{Name: "kind", Kind: reflect.String, Required: true, Description: "Limit kind, deposit or loss"},
+			{Name: "amount", Kind: reflect.Uint64, Required: true, Description: "Limit amount, in sats"},
+		},
+		Response: db.Limit{},
+	},
+	{
+		ID:      "Exclude",
+		Method:  http.MethodPost,
+		Path:    "/limits/exclusion",
+		Summary: "Prevents the player from betting for a number of days",
+		Auth:    AuthSignature,
+		Params: []Param{
+			{Name: "days", Kind: reflect.Uint64, Required: true, Description: "Length of the exclusion"},
+		},
+		Response: handler.ExclusionResponse{},
+	},
+	{
+		ID:       "GetMaintenance",
+		Method:   http.MethodGet,
+		Path:     "/maintenance",
+		Summary:  "Returns the maintenance window",
+		Response: policy.MaintenanceStatus{},
+	},
+	{
+		ID:       "GetNotifications",
+		Method:   http.MethodGet,
+		Path:     "/notifications",
+		Summary:  "Returns the services prize notifications are sent through",
+		Auth:     AuthPublicKey,
+		Response: handler.NotificationsResponse{},
+	},
+	{
+		ID:       "SetDigestNotifications",
+		Method:   http.MethodPost,
+		Path:     "/notifications/digest",
+		Summary:  "Sends the result of every lottery the player bets on",
+		Auth:     AuthSignature,
+		Response: handler.DigestNotificationsResponse{},
+	},
+	{
+		ID:       "DeleteDigestNotifications",
+		Method:   http.MethodDelete,
+		Path:     "/notifications/digest",
+		Summary:  "Stops sending the result of every lottery the player bets on",
+		Auth:     AuthSignature,
+		Response: handler.DigestNotificationsResponse{},
+	},
+	{
+		ID:      "SetNostrNotifications",
+		Method:  http.MethodPost,
+		Path:    "/notifications/nostr",
+		Summary: "Sends prize notifications to a nostr public key",
+		Auth:    AuthSignature,
+		Params: []Param{
+			{Name: "npub", Kind: reflect.String, Required: true, Description: "Nostr public key"},
+		},
+		Response: handler.NostrNotificationsResponse{},
+	},
+	{
+		ID:       "DeleteNostrNotifications",
+		Method:   http.MethodDelete,
+		Path:     "/notifications/nostr",
+		Summary:  "Stops sending prize notifications through nostr",
+		Auth:     AuthSignature,
+		Response: handler.NostrNotificationsResponse{},
+	},
+	{
+		ID:       "GetPayouts",
+		Method:   http.MethodGet,
+		Path:     "/payouts",
+		Summary:  "Lists the withdrawals of the player and the status of their payments",
+		Auth:     AuthPublicKey,
+		Params:   []Param{offsetParam, limitParam, reverseParam},
+		Response: handler.PayoutsResponse{},
+	},
+	{
+		ID:       "GetPrivacy",
+		Method:   http.MethodGet,
+		Path:     "/privacy",
+		Summary:  "Returns how the player appears in the public winners lists",
+		Auth:     AuthPublicKey,
+		Response: db.Privacy{},
+	},
+	{
+		ID:      "SetPrivacy",
+		Method:  http.MethodPost,
+		Path:    "/privacy",
+		Summary: "Changes how the player appears in the public winners lists",
+		Auth:    AuthSignature,
+		Params: []Param{
+			{Name: "display", Kind: reflect.String, Required: true, Description: "full, truncated, alias or hidden"},
+			{Name: "alias", Kind: reflect.String, Description: "Name displayed with the alias mode"},
+		},
+		Response: db.Privacy{},
+	},
+	{
+		ID:       "GetPrizes",
+		Method:   http.MethodGet,
+		Path:     "/prizes",
+		Summary:  "Returns the prizes available to withdraw",
+		Auth:     AuthPublicKey,
+		Response: handler.GetPrizesResponse{},
+	},
+	{
+		ID:      "GetReceiptBundle",
+		Method:  http.MethodGet,
+		Path:    "/receipts/bundle",
+		Summary: "Returns a bet or win receipt signed by the server, along with the instructions to verify it",
+		Auth:    AuthSignature,
+		Params: []Param{
+			{Name: "payment_hash", Kind: reflect.String, Description: "Payment hash of the bet, the prizes of the round are returned if empty"},
+			{Name: "height", Kind: reflect.Uint64, Description: "Lottery height of the prizes, required without a payment hash"},
+			{Name: "pdf", Field: "PDF", Kind: reflect.Bool, Description: "Include the receipt rendered as a PDF document"},
+		},
+		Response: handler.ReceiptBundleResponse{},
+	},
+	{
+		ID:       "VerifyReceipt",
+		Method:   http.MethodPost,
+		Path:     "/receipts/verify",
+		Summary:  "Verifies that a bet receipt was signed by the server",
+		Body:     audit.Receipt{},
+		Response: handler.VerifyReceiptResponse{},
+	},
+	{
+		ID:       "GetReserves",
+		Method:   http.MethodGet,
+		Path:     "/reserves",
+		Summary:  "Returns the proof of reserves, the prize liabilities and the channel balances covering them",
+		Response: handler.ReservesResponse{},
+	},
+	{
+		ID:       "GetStats",
+		Method:   http.MethodGet,
+		Path:     "/stats",
+		Summary:  "Returns the all-time statistics",
+		Response: handler.StatsResponse{},
+	},
+	{
+		ID:       "GetRoundStats",
+		Method:   http.MethodGet,
+		Path:     "/stats/rounds",
+		Summary:  "Lists the statistics of each lottery",
+		Params:   []Param{offsetParam, limitParam, reverseParam},
+		Response: handler.RoundsResponse{},
+	},
+	{
+		ID:       "GetStreaks",
+		Method:   http.MethodGet,
+		Path:     "/stats/streaks",
+		Summary:  "Lists the longest winning streaks",
+		Params:   []Param{limitParam},
+		Response: handler.StreaksResponse{},
+	},
+	{
+		ID:       "GetBiggestWins",
+		Method:   http.MethodGet,
+		Path:     "/stats/wins",
+		Summary:  "Lists the biggest prizes won",
+		Params:   []Param{limitParam},
+		Response: handler.WinsResponse{},
+	},
+	{
+		ID:      "GetTicket",
+		Method:  http.MethodGet,
+		Path:    "/tickets",
+		Summary: "Returns the owner of a ticket",
+		Params: []Param{
+			{Name: "height", Kind: reflect.Uint64, Required: true, Description: "Lottery height"},
+			{Name: "ticket", Kind: reflect.Uint64, Required: true, Description: "Ticket number"},
+			poolParam,
+		},
+		Response: handler.TicketResponse{},
+	},
+	{
+		ID:      "GetWinners",
+		Method:  http.MethodGet,
+		Path:    "/winners",
+		Summary: "Lists the winners of a lottery",
+		Params: []Param{
+			{Name: "height", Kind: reflect.Uint64, Required: true, Description: "Lottery height"},
+		},
+		Response: handler.WinnersResponse{},
+	},
+	{
+		ID:      "SearchWinners",
+		Method:  http.MethodGet,
+		Path:    "/winners/search",
+		Summary: "Searches the winners of every lottery",
+		Params: []Param{
+			{Name: "pubkey", Field: "PublicKey", Kind: reflect.String, Description: "Winner public key, only if it's displayed in full"},
+			{Name: "from", Kind: reflect.Uint64, Description: "First lottery height"},
+			{Name: "to", Kind: reflect.Uint64, Description: "Last lottery height"},
+			{Name: "status", Kind: reflect.String, Description: "Claim status: claimed, unclaimed or expired"},
+			{Name: "offset", Kind: reflect.Uint64, Description: "ID of the winner to start after"},
+			limitParam, reverseParam,
+		},
+		Response: handler.WinnersSearchResponse{},
+	},
+	{
+		ID:      "Withdraw",
+		Method:  http.MethodPost,
+		Path:    "/withdraw",
+		Summary: "Withdraws the prizes paying one or more invoices",
+		Params: []Param{
+			{Name: "pubkey", Field: "PublicKey", Kind: reflect.String, Required: true, Description: "Player public key"},
+			{Name: "k1", Kind: reflect.String, Required: true, Description: "Signature of the public key"},
+			prParam, feeParam,
+		},
+		Response: handler.WithdrawResponse{},
+	},
+}
@@ -0,0 +1,82 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// TypeScript returns the TypeScript types of the document schemas, the parameters and the
+// responses of the operations.
+func TypeScript() []byte {
+	doc := Spec()
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by go generate; DO NOT EDIT.\n")
+
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		schema := doc.Components.Schemas[name]
+		fmt.Fprintf(&b, "\nexport type %s = {\n", name)
+		for _, property := range schema.order {
+			optional := "?"
+			if slices.Contains(schema.Required, property) {
+				optional = ""
+			}
+			fmt.Fprintf(&b, "\treadonly %s%s: %s\n", property, optional, tsType(schema.Properties[property]))
+		}
+		b.WriteString("}\n")
+	}
+
+	for _, op := range Operations {
+		params := op.parameters()
+		if len(params) != 0 {
+			fmt.Fprintf(&b, "\nexport type %sParams = {\n", op.ID)
+			for _, param := range params {
+				optional := "?"
+				if param.Required {
+					optional = ""
+				}
+				fmt.Fprintf(&b, "\treadonly %s%s: %s\n", param.Name, optional, tsType(param.object().Schema))
+			}
+			b.WriteString("}\n")
+		}
+
+		name := op.ID + "Response"
+		response := tsType(doc.Paths[op.Path][strings.ToLower(op.Method)].Responses["200"].Content["application/json"].Schema)
+		if name != response {
+			fmt.Fprintf(&b, "\nexport type %s = %s\n", name, response)
+		}
+	}
+
+	return b.Bytes()
+}
+
+func tsType(schema *Schema) string {
+	if schema.Ref != "" {
+		return strings.TrimPrefix(schema.Ref, schemasRef)
+	}
+
+	switch schema.Type {
+	case "boolean", "string":
+		return schema.Type
+	case "integer", "number":
+		return "number"
+	case "array":
+		return tsType(schema.Items) + "[]"
+	case "object":
+		if schema.AdditionalProperties != nil {
+			return "{ [key: string]: " + tsType(schema.AdditionalProperties) + " }"
+		}
+		return "object"
+	default:
+		return "unknown"
+	}
+}
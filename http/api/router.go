@@ -5,13 +5,29 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 
+	"github.com/aftermath2/BTRY/audit"
 	"github.com/aftermath2/BTRY/config"
-	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/crypto"
+	database "github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/http/api/graphql"
 	"github.com/aftermath2/BTRY/http/api/handler"
+	"github.com/aftermath2/BTRY/http/api/live"
 	"github.com/aftermath2/BTRY/http/api/middleware"
+	"github.com/aftermath2/BTRY/http/api/openapi"
 	"github.com/aftermath2/BTRY/http/api/sse"
+	"github.com/aftermath2/BTRY/http/api/tokens"
+	"github.com/aftermath2/BTRY/leader"
 	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/logger"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/lottery/engine"
+	"github.com/aftermath2/BTRY/policy"
+	"github.com/aftermath2/BTRY/rates"
+	"github.com/aftermath2/BTRY/reload"
+	"github.com/aftermath2/BTRY/reserves"
+	"github.com/aftermath2/BTRY/swaps"
 	"github.com/aftermath2/BTRY/ui"
+	"github.com/aftermath2/BTRY/webhooks"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
@@ -26,14 +42,41 @@ type Router interface {
 type router struct {
 	mux           *chi.Mux
 	eventStreamer sse.Streamer
+	draws         *lottery.Subscription
+	liveHub       *live.Hub
 }
 
 // NewRouter returns an HTTP request multiplexer.
 func NewRouter(
 	config config.API,
-	db *db.DB,
+	betSettings lottery.BetSettings,
+	pools lottery.Pools,
+	capacity lottery.CapacityOracle,
+	statsPrivacy lottery.StatsPrivacy,
+	rounding engine.Rounding,
+	lastTicket config.LastTicket,
+	lnurlPay config.LNURLPay,
+	keysend config.Keysend,
+	drawSLO config.DrawSLO,
+	db *database.DB,
 	lnd lightning.Client,
-	winnersCh <-chan []db.Winner,
+	auditor audit.Auditor,
+	webhooks webhooks.Publisher,
+	peerCap *policy.PeerCap,
+	limits *policy.Limits,
+	housePlay *policy.HousePlay,
+	cancellation *policy.Cancellation,
+	claimCodes *policy.ClaimCodes,
+	jurisdiction *policy.Jurisdiction,
+	maintenance *policy.Maintenance,
+	approvals *policy.Approvals,
+	invoices *policy.Invoices,
+	elector leader.Elector,
+	payoutNotifier lottery.PayoutNotifier,
+	rates rates.Rates,
+	reserves reserves.Prover,
+	reloader reload.Reloader,
+	winnersHub *lottery.WinnersHub,
 	blocksCh chan<- *chainrpc.BlockEpoch,
 ) (Router, error) {
 	rateLimiter, err := middleware.NewRateLimiter(config.RateLimiter)
@@ -46,13 +89,65 @@ func NewRouter(
 		return nil, err
 	}
 
-	eventStreamer, err := sse.NewStreamer(config.SSE, db, lnd, winnersCh, blocksCh)
+	apiKeysMw, err := middleware.NewAPIKeys(config.RateLimiter, db)
 	if err != nil {
 		return nil, err
 	}
+	limit := apiKeysMw.Limit(rateLimiter.Handle)
+
+	claimTokens, err := tokens.New(config.ClaimWidget)
+	if err != nil {
+		return nil, err
+	}
+
+	swapProvider, err := swaps.New(config.Swaps)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := crypto.NewIdentity(config.Identity)
+	if err != nil {
+		return nil, err
+	}
+
+	adminMw := middleware.NewAdmin(config.Admin, db)
+	maintenanceMw := middleware.NewMaintenance(maintenance)
+	leaderMw := middleware.NewLeader(elector)
+	jurisdictionMw := middleware.NewJurisdiction(jurisdiction)
+	cacheMw := middleware.NewCache(config.Cache)
+	// Signatures go on top of the cache so the cached responses are signed as well
+	signatureMw := middleware.NewSignature(config.Identity, identity)
+
+	liveHub, err := live.NewHub(config.Live, config.SSE.Deadline, db, lnd, pools, statsPrivacy, winnersHub)
+	if err != nil {
+		return nil, err
+	}
+
+	// Blocks go through the cache before reaching the lottery so the responses are discarded as
+	// soon as the height changes
+	streamerBlocksCh := make(chan *chainrpc.BlockEpoch)
+	go invalidateOnBlock(cacheMw, liveHub, streamerBlocksCh, blocksCh)
+	draws := winnersHub.Subscribe()
+	go invalidateOnDraw(cacheMw, draws)
+
+	eventStreamer, err := sse.NewStreamer(config.SSE, betSettings, lastTicket, keysend, pools, capacity, db, lnd,
+		auditor, webhooks, peerCap, limits, housePlay, elector, payoutNotifier, winnersHub, liveHub,
+		streamerBlocksCh)
+	if err != nil {
+		draws.Close()
+		liveHub.Close()
+		return nil, err
+	}
+
+	winnersStream, err := sse.NewWinnersStream(config.SSE, db, winnersHub)
+	if err != nil {
+		draws.Close()
+		liveHub.Close()
+		return nil, err
+	}
 
 	mux := chi.NewRouter()
-	mux.Use(rateLimiter.Handle, middleware.Cors)
+	mux.Use(limit, middleware.Cors)
 
 	uiFs, err := ui.FS()
 	if err != nil {
@@ -67,26 +162,174 @@ func NewRouter(
 	mux.Get("/withdraw", redirectRoot)
 	mux.Get("/faq", redirectRoot)
 
-	handler := handler.New(lnd, db, eventStreamer)
+	var graphqlHandler http.Handler
+	if config.GraphQL.Enabled {
+		graphqlLogger, err := logger.New(config.GraphQL.Logger)
+		if err != nil {
+			return nil, err
+		}
+		schema := graphql.NewSchema(db, lnd, graphqlLogger, pools, capacity, statsPrivacy, winnersHub,
+			config.GraphQL.UpdateInterval)
+		graphqlHandler = graphql.NewHandler(schema, config.SSE.Deadline)
+	}
+
+	handler := handler.New(lnd, db, eventStreamer, auditor, webhooks, identity, peerCap, limits, housePlay,
+		cancellation, claimCodes, claimTokens, jurisdiction, maintenance, approvals, invoices, rates, reserves,
+		swapProvider, pools, capacity, statsPrivacy, rounding, lastTicket, lnurlPay, drawSLO, reloader,
+		config.Admin)
 	mux.Route("/api", func(r chi.Router) {
-		r.Use(rateLimiter.Handle, middleware.Cors, loggerMw.Log)
+		r.Use(limit, middleware.Cors, loggerMw.Log)
 
+		r.With(cacheMw.History).Get("/archives", handler.GetArchives)
 		r.Get("/bets", handler.GetBets)
-		r.Get("/heights", handler.GetHeights)
+		r.Get("/claim", handler.GetClaim)
+		r.Post("/claims/swap/countersign", handler.CountersignSwapClaim)
+		r.Post("/claims/token", handler.IssueClaimToken)
+		r.Get("/claims/widget", handler.GetWidgetClaim)
+		r.With(cacheMw.Info).Get("/heights", handler.GetHeights)
+		r.With(signatureMw.Handle).Get("/identity", handler.GetIdentity)
+		r.Get("/invoice/swap", handler.GetInvoiceSwap)
 		r.Handle("/events", eventStreamer)
-		r.Get("/lottery", handler.GetLottery)
-		r.Get("/invoice", handler.GetInvoice)
+		if graphqlHandler != nil {
+			r.Handle("/graphql", graphqlHandler)
+		}
+		r.With(signatureMw.Handle, cacheMw.Info).Get("/lottery", handler.GetLottery)
+		r.With(signatureMw.Handle, cacheMw.History).Get("/lottery/archive", handler.GetBetArchive)
+		r.With(cacheMw.History).Get("/lottery/card", handler.GetDrawCard)
+		r.With(signatureMw.Handle).Get("/lottery/commitment", handler.GetCommitment)
+		r.With(signatureMw.Handle, cacheMw.History).Get("/lottery/fairness", handler.GetFairness)
 		r.Get("/lightning/address", handler.GetLightningAddress)
+		r.Get("/lightning/lnurlp", handler.LNURLPay)
 		r.Post("/lightning/address", handler.SetLightningAddress)
-		r.Get("/lightning/lnurlw", handler.LNURLWithdraw)
+		r.Get("/limits", handler.GetLimits)
+		if config.Live.Enabled {
+			r.Handle("/live", liveHub)
+		}
+		r.Get("/maintenance", handler.GetMaintenance)
+		r.Post("/limits", handler.SetLimit)
+		r.Post("/limits/exclusion", handler.Exclude)
+		r.Get("/notifications", handler.GetNotifications)
+		r.Post("/notifications/digest", handler.SetDigestNotifications)
+		r.Delete("/notifications/digest", handler.DeleteDigestNotifications)
+		r.Post("/notifications/nostr", handler.SetNostrNotifications)
+		r.Delete("/notifications/nostr", handler.DeleteNostrNotifications)
+		r.Get("/openapi.json", openapi.ServeHTTP)
+		r.Get("/payouts", handler.GetPayouts)
+		r.Get("/privacy", handler.GetPrivacy)
+		r.Post("/privacy", handler.SetPrivacy)
 		r.Get("/prizes", handler.GetPrizes)
-		r.Get("/winners", handler.GetWinners)
-		r.Post("/withdraw", handler.Withdraw)
+		r.Get("/receipts/bundle", handler.GetReceiptBundle)
+		r.With(signatureMw.Handle).Post("/receipts/verify", handler.VerifyReceipt)
+		r.Get("/reserves", handler.GetReserves)
+		r.Group(func(r chi.Router) {
+			r.Use(apiKeysMw.RequireScope(database.ScopeStats), cacheMw.Stats)
+
+			r.Get("/stats", handler.GetStats)
+			r.Get("/stats/rounds", handler.GetRoundStats)
+			r.Get("/stats/streaks", handler.GetStreaks)
+			r.Get("/stats/wins", handler.GetBiggestWins)
+		})
+		r.Get("/tickets", handler.GetTicket)
+		r.Group(func(r chi.Router) {
+			r.Use(apiKeysMw.Authorize(database.ScopeWebhooks))
+
+			r.Get("/webhooks", handler.GetWebhooks)
+			r.Post("/webhooks", handler.CreateWebhook)
+			r.Delete("/webhooks", handler.DeleteWebhook)
+		})
+		r.With(signatureMw.Handle, cacheMw.History).Get("/winners", handler.GetWinners)
+		r.Get("/winners/search", handler.SearchWinners)
+		r.Handle("/winners/stream", winnersStream)
+
+		// New bets and withdrawals are rejected during maintenance, the draws keep running. Only
+		// the leader takes them, the followers serve the rest
+		r.Group(func(r chi.Router) {
+			r.Use(maintenanceMw.Handle, leaderMw.Handle)
+
+			r.Delete("/bets", handler.CancelBet)
+			r.Post("/claim", handler.Claim)
+			r.Post("/claims/swap", handler.StartSwapClaim)
+			r.Post("/claims/swap/pay", handler.PaySwapClaim)
+			r.Post("/claims/widget", handler.WidgetClaim)
+			r.Group(func(r chi.Router) {
+				r.Use(jurisdictionMw.Handle, apiKeysMw.RequireScope(database.ScopeBets))
+
+				r.Get("/invoice", handler.GetInvoice)
+				r.Get("/lightning/lnurlp/callback", handler.LNURLPayCallback)
+			})
+			r.Get("/lightning/lnurlw", handler.LNURLWithdraw)
+			r.Post("/withdraw", handler.Withdraw)
+		})
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(adminMw.Enabled)
+
+			r.Post("/register/begin", handler.BeginRegistration)
+			r.Post("/register/finish", handler.FinishRegistration)
+			r.Post("/login/begin", handler.BeginLogin)
+			r.Post("/login/finish", handler.FinishLogin)
+
+			r.Group(func(r chi.Router) {
+				r.Use(adminMw.Authorize(database.RoleReadOnly))
+
+				r.Post("/logout", handler.Logout)
+				r.Get("/access", handler.ListAccessLists)
+				r.Get("/approvals", handler.ListApprovals)
+				r.Get("/audit", handler.GetAuditLog)
+				r.Get("/claims/swap", handler.GetSwapClaims)
+				r.Get("/draws/slo", handler.GetDrawSLO)
+				r.Get("/draws/timings", handler.GetDrawTimings)
+				r.Get("/fees", handler.ListFeeDistributions)
+				r.Get("/growth", handler.GetGrowth)
+				r.Get("/invoices", handler.GetInvoiceStats)
+				r.Get("/jurisdiction", handler.GetJurisdictionStats)
+				r.Get("/keys", handler.ListAPIKeys)
+				r.Get("/notifications/deliveries", handler.GetNotificationDeliveries)
+				r.Get("/payouts/scheduled", handler.ListScheduledPayouts)
+				r.Get("/prizes/aging", handler.GetPrizesAging)
+				r.Get("/prizes/at-risk", handler.GetPrizesAtRisk)
+				r.Get("/replay", handler.ReplayDraw)
+				r.Get("/search", handler.Search)
+				r.Get("/webhooks", handler.ListWebhooks)
+				r.Get("/webhooks/deliveries", handler.GetWebhookDeliveries)
+				r.Get("/winners", handler.GetAdminWinners)
+				r.Get("/winners/search", handler.SearchAdminWinners)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(adminMw.Authorize(database.RoleOperator))
+
+				r.Post("/access", handler.AddAccessEntry)
+				r.Delete("/access", handler.RemoveAccessEntry)
+				r.Post("/airdrops", handler.Airdrop)
+				r.Post("/approvals", handler.ApprovePayout)
+				r.Delete("/approvals", handler.RejectPayout)
+				r.Post("/maintenance", handler.ScheduleMaintenance)
+				r.Delete("/maintenance", handler.EndMaintenance)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(adminMw.Authorize(database.RoleOwner))
+
+				r.Post("/config/reload", handler.ReloadConfig)
+				r.Post("/invites", handler.CreateInvite)
+				r.Post("/keys", handler.CreateAPIKey)
+				r.Delete("/keys", handler.RevokeAPIKey)
+				r.Post("/keys/rotate", handler.RotateAPIKey)
+				r.Post("/migration/token", handler.CreateMigrationToken)
+				r.Post("/migration/export", handler.ExportRound)
+				r.Post("/migration/import", handler.ImportRound)
+				r.Get("/operators", handler.ListOperators)
+				r.Delete("/operators", handler.DeleteOperator)
+			})
+		})
 	})
 
 	return &router{
 		mux:           mux,
 		eventStreamer: eventStreamer,
+		draws:         draws,
+		liveHub:       liveHub,
 	}, nil
 }
 
@@ -95,9 +338,33 @@ func (rr *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (rr *router) Close() error {
+	rr.draws.Close()
+	rr.liveHub.Close()
 	return rr.eventStreamer.Close()
 }
 
+// invalidateOnBlock discards the cached responses every time a new block is found, forwards it and
+// sends the blocks remaining to the live updates clients.
+func invalidateOnBlock(
+	cache *middleware.Cache,
+	liveHub *live.Hub,
+	in <-chan *chainrpc.BlockEpoch,
+	out chan<- *chainrpc.BlockEpoch,
+) {
+	for block := range in {
+		cache.Invalidate()
+		out <- block
+		liveHub.Block(block.Height)
+	}
+}
+
+// invalidateOnDraw discards the cached responses after the lotteries are drawn.
+func invalidateOnDraw(cache *middleware.Cache, draws *lottery.Subscription) {
+	for range draws.C() {
+		cache.Invalidate()
+	}
+}
+
 func redirectRoot(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusPermanentRedirect)
 }
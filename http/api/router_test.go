@@ -9,12 +9,20 @@ import (
 	"github.com/aftermath2/BTRY/config"
 	"github.com/aftermath2/BTRY/db"
 	"github.com/aftermath2/BTRY/http/api"
+	"github.com/aftermath2/BTRY/leader"
 	"github.com/aftermath2/BTRY/lightning"
 	"github.com/aftermath2/BTRY/logger"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/lottery/engine"
+	"github.com/aftermath2/BTRY/policy"
+	"github.com/aftermath2/BTRY/rates"
+	"github.com/aftermath2/BTRY/reload"
+	"github.com/aftermath2/BTRY/reserves"
 
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestRouter(t *testing.T) {
@@ -33,7 +41,7 @@ func TestRouter(t *testing.T) {
 			},
 		},
 	}
-	winnersCh := make(chan []db.Winner)
+	winnersHub := lottery.NewWinnersHub(config.WinnersHub{})
 	blocksCh := make(chan *chainrpc.BlockEpoch)
 	lndMock := lightning.NewClientMock()
 
@@ -41,12 +49,18 @@ func TestRouter(t *testing.T) {
 		Return(lightning.BlockedStreamMock[*chainrpc.BlockEpoch]{}, nil)
 	lndMock.On("SubscribeChannelEvents", context.Background()).
 		Return(lightning.BlockedStreamMock[*lnrpc.ChannelEventUpdate]{}, nil)
-	lndMock.On("SubscribeInvoices", context.Background()).
+	lndMock.On("SubscribeInvoices", context.Background(), uint64(0)).
 		Return(lightning.BlockedStreamMock[*lnrpc.Invoice]{}, nil)
 	lndMock.On("SubscribePayments", context.Background()).
 		Return(lightning.BlockedStreamMock[*lnrpc.Payment]{}, nil)
+	invoicesMock := db.NewInvoicesStoreMock()
+	invoicesMock.On("LastSettleIndex").Return(uint64(0), nil)
+	leaderMock := leader.NewElectorMock()
+	leaderMock.On("OnElected", mock.Anything)
 
-	handler, err := api.NewRouter(apiConfig, &db.DB{}, lndMock, winnersCh, blocksCh)
+	handler, err := api.NewRouter(apiConfig, lottery.NewBetSettingsMock(), lottery.NewPools(nil), lottery.RemoteBalanceCapacity{}, lottery.StatsPrivacy{}, engine.RoundingNearest, config.LastTicket{}, config.LNURLPay{}, config.Keysend{}, config.DrawSLO{}, &db.DB{Invoices: invoicesMock}, lndMock, nil, nil,
+		&policy.PeerCap{}, &policy.Limits{}, policy.NewHousePlay(config.HousePlay{}), &policy.Cancellation{}, &policy.ClaimCodes{}, &policy.Jurisdiction{}, policy.NewMaintenance(config.Maintenance{}), &policy.Approvals{}, &policy.Invoices{}, leaderMock, nil, rates.NewRatesMock(), reserves.NewProverMock(), reload.NewReloaderMock(), winnersHub,
+		blocksCh)
 	assert.NoError(t, err)
 
 	srv := httptest.NewServer(handler)
@@ -56,4 +70,10 @@ func TestRouter(t *testing.T) {
 	assert.NoError(t, err)
 
 	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, err = srv.Client().Get(srv.URL + "/api/openapi.json")
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/json; charset=UTF-8", res.Header.Get("Content-Type"))
 }
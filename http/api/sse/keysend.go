@@ -0,0 +1,161 @@
+package sse
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/pkg/errors"
+)
+
+// confirmationMaxFee is the maximum routing fee paid for the keysend confirmations, in sats.
+const confirmationMaxFee = 10
+
+// settleKeysend waits for the HTLCs of a keysend payment to be held and settles it if it carries a
+// bet that can be placed, otherwise it's cancelled and the funds are returned to the payer.
+//
+// Keysend payments without a bet record are left to the node.
+func (s *streamer) settleKeysend(ctx context.Context, hash []byte) {
+	rHash := hex.EncodeToString(hash)
+	stream, err := s.lnd.SubscribeSingleInvoice(ctx, hash)
+	if err != nil {
+		s.logger.Error(errors.Wrapf(err, "subscribing to keysend invoice %s", rHash))
+		return
+	}
+
+	for {
+		invoice, err := stream.Recv()
+		if err != nil {
+			s.logger.Error(errors.Wrapf(err, "receiving events from keysend invoice %s stream", rHash))
+			return
+		}
+
+		switch invoice.State {
+		case lnrpc.Invoice_ACCEPTED:
+			s.acceptKeysend(ctx, rHash, hash, invoice.Htlcs)
+			return
+		case lnrpc.Invoice_CANCELED:
+			return
+		case lnrpc.Invoice_SETTLED:
+			s.logger.Warningf("Keysend payment %s was settled by the node before placing its bet, "+
+				"keysend-hold-time must be set in lnd", rHash)
+			return
+		}
+	}
+}
+
+// acceptKeysend places the bet of a keysend payment whose HTLCs are held.
+func (s *streamer) acceptKeysend(
+	ctx context.Context,
+	rHash string,
+	hash []byte,
+	htlcs []*lnrpc.InvoiceHTLC,
+) {
+	bet, err := lottery.ParseKeysendBet(htlcs)
+	if err != nil {
+		if !errors.Is(err, lottery.ErrNoBetRecord) {
+			s.declineKeysend(ctx, rHash, hash, err)
+		}
+		return
+	}
+
+	if err := s.checkKeysendBet(ctx, bet); err != nil {
+		s.declineKeysend(ctx, rHash, hash, err)
+		return
+	}
+
+	// The invoice is stored so the bet is replayed if the process stops before placing it
+	now := time.Now()
+	invoice := db.Invoice{
+		PaymentHash: rHash,
+		PublicKey:   bet.PublicKey,
+		Amount:      bet.Amount,
+		CreatedAt:   now.Unix(),
+		ExpiresAt:   now.Add(lightning.DefaultInvoiceExpiry).Unix(),
+	}
+	if err := s.db.Invoices.Add(invoice); err != nil {
+		s.declineKeysend(ctx, rHash, hash, err)
+		return
+	}
+
+	e := entry{publicKey: bet.PublicKey, amount: bet.Amount}
+	if s.keysend.Confirmation > 0 {
+		e.replyTo = bet.ReplyTo
+	}
+	s.track(rHash, e)
+	if s.peerCap.Enabled() {
+		s.acceptHoldInvoice(ctx, rHash, hash, bet.Preimage, htlcs)
+		return
+	}
+
+	if err := s.lnd.SettleInvoice(ctx, bet.Preimage); err != nil {
+		s.logger.Error(errors.Wrapf(err, "settling keysend invoice %s", rHash))
+	}
+}
+
+// confirmKeysend sends a keysend payment to the payer's node carrying the tickets of the bet in
+// custom records, a receipt node runners get without leaving the protocol.
+//
+// Bets replayed after a restart are not confirmed, the node to reply to is only kept in memory.
+func (s *streamer) confirmKeysend(ctx context.Context, replyTo, rHash string, bet db.Bet) {
+	records := lottery.KeysendConfirmation(bet)
+	amount := int64(s.keysend.Confirmation)
+	if err := s.lnd.SendKeysend(ctx, replyTo, amount, confirmationMaxFee, records); err != nil {
+		s.logger.Error(errors.Wrapf(err, "confirming keysend bet %s to %s", rHash, replyTo))
+	}
+}
+
+// checkKeysendBet applies the checks bet invoices go through before being created.
+func (s *streamer) checkKeysendBet(ctx context.Context, bet lottery.KeysendBet) error {
+	pool, ok := s.pools.Route(bet.Amount)
+	if !ok {
+		return errors.Errorf("there is no lottery pool accepting bets of %d sats", bet.Amount)
+	}
+
+	lotteryInfo, err := lottery.GetInfo(ctx, s.lnd, s.db, s.pools, s.capacity)
+	if err != nil {
+		return err
+	}
+
+	if postponement := lotteryInfo.Postponement; postponement != nil {
+		return errors.Errorf("the lottery %d draw is postponed, bets are not accepted",
+			postponement.LotteryHeight)
+	}
+
+	if bet.Round != 0 && bet.Round != lotteryInfo.NextHeight {
+		return errors.Errorf("round %d is not accepting bets, the next one is %d", bet.Round,
+			lotteryInfo.NextHeight)
+	}
+
+	poolInfo, ok := lotteryInfo.Pool(pool.Name)
+	if !ok {
+		return errors.Errorf("pool %q not found", pool.Name)
+	}
+	if bet.Amount > uint64(poolInfo.Capacity) {
+		return errors.Errorf("amount exceeds the current capacity of %d", poolInfo.Capacity)
+	}
+
+	if err := s.limits.Check(bet.PublicKey, bet.Amount); err != nil {
+		return err
+	}
+
+	if err := s.housePlay.Check(bet.PublicKey); err != nil {
+		return err
+	}
+
+	return policy.CheckAccess(s.db.AccessLists, s.auditor, bet.PublicKey, db.AccessBets)
+}
+
+func (s *streamer) declineKeysend(ctx context.Context, rHash string, hash []byte, reason error) {
+	s.logger.Warningf("Declining keysend payment %s: %v", rHash, reason)
+
+	if err := s.lnd.CancelInvoice(ctx, hash); err != nil {
+		s.logger.Error(errors.Wrapf(err, "cancelling keysend invoice %s", rHash))
+	}
+}
@@ -0,0 +1,209 @@
+package sse
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	keysendPublicKey = "e68b99fc5f60c971926fdc3a3af38ccf67e6f4306ab1c388735533e7c5dcc749"
+	keysendNode      = "03864ef025fde8fb587d989186ce6a4a186895ee44a926bfc370e2c366597a3f8f"
+)
+
+// setupKeysend prepares the streamer to check the keysend bets and returns the HTLCs of a payment
+// of 2000 sats betting in the round specified.
+func (s *SSESuite) setupKeysend(height uint32, round []byte) []*lnrpc.InvoiceHTLC {
+	limitsMock := db.NewLimitsStoreMock()
+	limitsMock.On("GetExclusion", keysendPublicKey).Return(int64(0), nil).Maybe()
+	limitsMock.On("List", keysendPublicKey, mock.Anything).Return([]db.Limit{}, nil).Maybe()
+	accessListsMock := db.NewAccessListsStoreMock()
+	accessListsMock.On("Allowed", keysendPublicKey, db.AccessBets).Return(true, nil).Maybe()
+
+	s.sse.db.Limits = limitsMock
+	s.sse.db.AccessLists = accessListsMock
+	s.sse.limits = policy.NewLimits(config.Limits{}, s.sse.db)
+	s.sse.peerCap = &policy.PeerCap{}
+	s.sse.capacity = lottery.FixedCapacity{Amount: 10_000}
+	s.sse.keysend = config.Keysend{Enabled: true}
+
+	s.lndMock.On("RemoteBalance", mock.Anything).Return(int64(0), nil)
+	s.lotteriesMock.On("GetNextHeight").Return(height, nil)
+	s.postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+	s.betsMock.On("GetPrizePool", height, "").Return(uint64(0), nil)
+
+	publicKey, err := hex.DecodeString(keysendPublicKey)
+	s.NoError(err)
+	return []*lnrpc.InvoiceHTLC{{
+		AmtMsat: 2_000_000,
+		State:   lnrpc.InvoiceHTLCState_ACCEPTED,
+		CustomRecords: map[uint64][]byte{
+			5_482_373_484:            []byte("preimage"),
+			lottery.KeysendBetRecord: append(publicKey, round...),
+		},
+	}}
+}
+
+func (s *SSESuite) TestSettleKeysend() {
+	ctx := context.Background()
+	hash := []byte("hash")
+	rHash := hex.EncodeToString(hash)
+	htlcs := s.setupKeysend(144, []byte{0, 0, 0, 144})
+
+	stream := &customEventsStreamMock[*lnrpc.Invoice]{
+		events: []*lnrpc.Invoice{
+			{RHash: hash, State: lnrpc.Invoice_OPEN, IsKeysend: true},
+			{RHash: hash, State: lnrpc.Invoice_ACCEPTED, IsKeysend: true, Htlcs: htlcs},
+		},
+	}
+	s.lndMock.On("SubscribeSingleInvoice", ctx, hash).Return(stream, nil)
+	s.lndMock.On("SettleInvoice", ctx, []byte("preimage")).Return(nil)
+	s.invoicesMock.On("Add", mock.MatchedBy(func(invoice db.Invoice) bool {
+		return invoice.PaymentHash == rHash && invoice.PublicKey == keysendPublicKey &&
+			invoice.Amount == 2_000
+	})).Return(nil)
+
+	s.sse.settleKeysend(ctx, hash)
+
+	s.lndMock.AssertCalled(s.T(), "SettleInvoice", ctx, []byte("preimage"))
+	s.invoicesMock.AssertExpectations(s.T())
+
+	// The bet is registered once the invoice is settled
+	entry, ok := s.sse.trackedPayments.Get(rHash)
+	s.True(ok)
+	s.Equal(keysendPublicKey, entry.publicKey)
+	s.Equal(uint64(2_000), entry.amount)
+}
+
+func (s *SSESuite) TestSettleKeysendConfirmation() {
+	ctx := context.Background()
+	hash := []byte("hash")
+	rHash := hex.EncodeToString(hash)
+	htlcs := s.setupKeysend(144, nil)
+	s.sse.keysend.Confirmation = 1
+	replyTo, err := hex.DecodeString(keysendNode)
+	s.NoError(err)
+	htlcs[0].CustomRecords[lottery.KeysendReplyRecord] = replyTo
+
+	stream := &customEventsStreamMock[*lnrpc.Invoice]{
+		events: []*lnrpc.Invoice{
+			{RHash: hash, State: lnrpc.Invoice_ACCEPTED, IsKeysend: true, Htlcs: htlcs},
+		},
+	}
+	s.lndMock.On("SubscribeSingleInvoice", ctx, hash).Return(stream, nil)
+	s.lndMock.On("SettleInvoice", ctx, []byte("preimage")).Return(nil)
+	s.invoicesMock.On("Add", mock.Anything).Return(nil)
+
+	s.sse.settleKeysend(ctx, hash)
+
+	entry, ok := s.sse.trackedPayments.Get(rHash)
+	s.True(ok)
+	s.Equal(keysendNode, entry.replyTo)
+}
+
+func (s *SSESuite) TestConfirmKeysend() {
+	ctx := context.Background()
+	s.sse.keysend = config.Keysend{Enabled: true, Confirmation: 1}
+	bet := db.Bet{LotteryHeight: 144, FirstTicket: 101, Index: 2_100, Bonus: 20}
+	s.lndMock.On("SendKeysend", ctx, keysendNode, int64(1), int64(confirmationMaxFee),
+		lottery.KeysendConfirmation(bet)).Return(nil)
+
+	s.sse.confirmKeysend(ctx, keysendNode, "hash", bet)
+
+	s.lndMock.AssertExpectations(s.T())
+}
+
+func (s *SSESuite) TestSettleKeysendDeclined() {
+	ctx := context.Background()
+	hash := []byte("hash")
+
+	cases := []struct {
+		desc  string
+		round []byte
+		setup func()
+	}{
+		{
+			desc:  "Round drawn",
+			round: []byte{0, 0, 0, 143},
+		},
+		{
+			desc: "Access denied",
+			setup: func() {
+				accessListsMock := db.NewAccessListsStoreMock()
+				accessListsMock.On("Allowed", keysendPublicKey, db.AccessBets).Return(false, nil)
+				s.sse.db.AccessLists = accessListsMock
+				s.auditorMock.On("Record", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			desc: "House public key",
+			setup: func() {
+				s.sse.housePlay = policy.NewHousePlay(config.HousePlay{
+					PublicKeys: []string{keysendPublicKey},
+				})
+			},
+		},
+		{
+			desc: "Draw postponed",
+			setup: func() {
+				postponementsMock := db.NewPostponementsStoreMock()
+				postponementsMock.On("GetActive").
+					Return(db.Postponement{LotteryHeight: 144, ResumeHeight: 150}, nil)
+				s.sse.db.Postponements = postponementsMock
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		s.Run(tc.desc, func() {
+			s.SetupTest()
+			htlcs := s.setupKeysend(144, tc.round)
+			if tc.setup != nil {
+				tc.setup()
+			}
+
+			stream := &customEventsStreamMock[*lnrpc.Invoice]{
+				events: []*lnrpc.Invoice{
+					{RHash: hash, State: lnrpc.Invoice_ACCEPTED, IsKeysend: true, Htlcs: htlcs},
+				},
+			}
+			s.lndMock.On("SubscribeSingleInvoice", ctx, hash).Return(stream, nil)
+			s.lndMock.On("CancelInvoice", ctx, hash).Return(nil)
+
+			s.sse.settleKeysend(ctx, hash)
+
+			s.lndMock.AssertCalled(s.T(), "CancelInvoice", ctx, hash)
+			s.lndMock.AssertNotCalled(s.T(), "SettleInvoice", mock.Anything, mock.Anything)
+			s.invoicesMock.AssertNotCalled(s.T(), "Add", mock.Anything)
+
+			_, ok := s.sse.trackedPayments.Get(hex.EncodeToString(hash))
+			s.False(ok)
+		})
+	}
+}
+
+func (s *SSESuite) TestSettleKeysendNoBetRecord() {
+	ctx := context.Background()
+	hash := []byte("hash")
+	htlcs := []*lnrpc.InvoiceHTLC{{AmtMsat: 1_000, State: lnrpc.InvoiceHTLCState_ACCEPTED}}
+
+	stream := &customEventsStreamMock[*lnrpc.Invoice]{
+		events: []*lnrpc.Invoice{
+			{RHash: hash, State: lnrpc.Invoice_ACCEPTED, IsKeysend: true, Htlcs: htlcs},
+		},
+	}
+	s.lndMock.On("SubscribeSingleInvoice", ctx, hash).Return(stream, nil)
+
+	s.sse.settleKeysend(ctx, hash)
+
+	// Other keysend payments are left to the node
+	s.lndMock.AssertNotCalled(s.T(), "CancelInvoice", mock.Anything, mock.Anything)
+	s.lndMock.AssertNotCalled(s.T(), "SettleInvoice", mock.Anything, mock.Anything)
+}
@@ -3,6 +3,10 @@ package sse
 import (
 	"net/http"
 
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/r3labs/sse"
 	"github.com/stretchr/testify/mock"
 )
@@ -23,12 +27,34 @@ func (s *StreamerMock) Close() error {
 	return args.Error(0)
 }
 
+// TrackHoldInvoice mock.
+func (s *StreamerMock) TrackHoldInvoice(rHash string, preimage []byte, publicKey string, amount uint64) uint64 {
+	args := s.Called(rHash, preimage, publicKey, amount)
+	return args.Get(0).(uint64)
+}
+
 // TrackPayment mock.
 func (s *StreamerMock) TrackPayment(rHash, publicKey string, amount uint64) uint64 {
 	args := s.Called(rHash, publicKey, amount)
 	return args.Get(0).(uint64)
 }
 
+// TrackWithdrawal mock.
+func (s *StreamerMock) TrackWithdrawal(paymentHash, publicKey string, claims []db.PrizesRow) uint64 {
+	args := s.Called(paymentHash, publicKey, claims)
+	return args.Get(0).(uint64)
+}
+
+// Untrack mock.
+func (s *StreamerMock) Untrack(paymentHash string) {
+	s.Called(paymentHash)
+}
+
+// WatchPayment mock.
+func (s *StreamerMock) WatchPayment(paymentHash string, updates lightning.Stream[*lnrpc.Payment]) {
+	s.Called(paymentHash, updates)
+}
+
 // ServerHTTP mock.
 func (s *StreamerMock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.Called(w, r)
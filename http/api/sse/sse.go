@@ -11,11 +11,17 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/aftermath2/BTRY/audit"
 	"github.com/aftermath2/BTRY/config"
 	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/fault"
+	"github.com/aftermath2/BTRY/http/api/live"
+	"github.com/aftermath2/BTRY/leader"
 	"github.com/aftermath2/BTRY/lightning"
 	"github.com/aftermath2/BTRY/logger"
 	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/policy"
+	"github.com/aftermath2/BTRY/webhooks"
 
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
@@ -47,11 +53,13 @@ type infoPayload struct {
 }
 
 type invoicesPayload struct {
-	Error     string `json:"error,omitempty"`
-	PublicKey string `json:"public_key,omitempty"`
-	PaymentID uint64 `json:"payment_id,omitempty"`
-	Amount    uint64 `json:"amount,omitempty"`
-	Status    status `json:"status,omitempty"`
+	Receipt      *audit.Receipt `json:"receipt,omitempty"`
+	Error        string         `json:"error,omitempty"`
+	PublicKey    string         `json:"public_key,omitempty"`
+	PaymentID    uint64         `json:"payment_id,omitempty"`
+	Amount       uint64         `json:"amount,omitempty"`
+	BonusTickets uint64         `json:"bonus_tickets,omitempty"`
+	Status       status         `json:"status,omitempty"`
 }
 
 type paymentsPayload struct {
@@ -64,6 +72,10 @@ type paymentsPayload struct {
 // It contains information to track invoices and payments.
 type entry struct {
 	publicKey string
+	// replyTo is the node the confirmation of a keysend bet is sent to
+	replyTo string
+	// claims are the amounts deducted from each prize to pay a withdrawal
+	claims    []db.PrizesRow
 	id        uint64
 	amount    uint64
 	timestamp int64
@@ -85,26 +97,57 @@ type Server interface {
 type Streamer interface {
 	http.Handler
 	io.Closer
+	TrackHoldInvoice(rHash string, preimage []byte, publicKey string, amount uint64) uint64
 	TrackPayment(rHash, publicKey string, amount uint64) uint64
+	TrackWithdrawal(paymentHash, publicKey string, claims []db.PrizesRow) uint64
+	Untrack(paymentHash string)
+	WatchPayment(paymentHash string, updates lightning.Stream[*lnrpc.Payment])
 }
 
 type streamer struct {
 	trackedPayments cmap.ConcurrentMap[string, entry]
 	lnd             lightning.Client
 	db              *db.DB
+	auditor         audit.Auditor
+	webhooks        webhooks.Publisher
+	peerCap         *policy.PeerCap
+	limits          *policy.Limits
+	housePlay       *policy.HousePlay
+	leader          leader.Elector
+	payoutNotifier  lottery.PayoutNotifier
 	server          Server
 	logger          *logger.Logger
-	winnersCh       <-chan []db.Winner
+	winners         *lottery.Subscription
+	live            *live.Hub
 	blocksCh        chan<- *chainrpc.BlockEpoch
 	config          config.SSE
+	settings        lottery.BetSettings
+	lastTicket      config.LastTicket
+	keysend         config.Keysend
+	pools           lottery.Pools
+	capacity        lottery.CapacityOracle
+	retry           fault.Policy
 }
 
 // NewStreamer returns a new event streamer.
 func NewStreamer(
 	config config.SSE,
+	settings lottery.BetSettings,
+	lastTicket config.LastTicket,
+	keysend config.Keysend,
+	pools lottery.Pools,
+	capacity lottery.CapacityOracle,
 	db *db.DB,
 	lnd lightning.Client,
-	winnersCh <-chan []db.Winner,
+	auditor audit.Auditor,
+	webhooks webhooks.Publisher,
+	peerCap *policy.PeerCap,
+	limits *policy.Limits,
+	housePlay *policy.HousePlay,
+	leader leader.Elector,
+	payoutNotifier lottery.PayoutNotifier,
+	winnersHub *lottery.WinnersHub,
+	liveHub *live.Hub,
 	blocksCh chan<- *chainrpc.BlockEpoch,
 ) (Streamer, error) {
 	logger, err := logger.New(config.Logger)
@@ -118,13 +161,27 @@ func NewStreamer(
 
 	streamer := &streamer{
 		config:          config,
+		settings:        settings,
+		lastTicket:      lastTicket,
+		keysend:         keysend,
+		pools:           pools,
+		capacity:        capacity,
 		server:          server,
 		lnd:             lnd,
 		db:              db,
+		auditor:         auditor,
+		webhooks:        webhooks,
+		peerCap:         peerCap,
+		limits:          limits,
+		housePlay:       housePlay,
+		leader:          leader,
+		payoutNotifier:  payoutNotifier,
 		trackedPayments: cmap.New[entry](),
 		logger:          logger,
-		winnersCh:       winnersCh,
+		winners:         winnersHub.Subscribe(),
+		live:            liveHub,
 		blocksCh:        blocksCh,
+		retry:           fault.DefaultPolicy,
 	}
 
 	// Start listening for events to stream
@@ -134,12 +191,14 @@ func NewStreamer(
 	go streamer.subscribeInvoices(ctx)
 	go streamer.subscribePayments(ctx)
 	go streamer.subscribeWinners(ctx)
+	leader.OnElected(streamer.resumeHoldInvoices)
 
 	return streamer, nil
 }
 
 // Close closes all of the streams and connections.
 func (s *streamer) Close() error {
+	s.winners.Close()
 	s.server.Close()
 	return nil
 }
@@ -159,16 +218,123 @@ func (s *streamer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 //
 // Takes payment hashes from both invoices (in) and payments (out).
 func (s *streamer) TrackPayment(rHash, publicKey string, amount uint64) uint64 {
+	return s.track(rHash, entry{publicKey: publicKey, amount: amount})
+}
+
+func (s *streamer) track(rHash string, e entry) uint64 {
+	e.id = rand.Uint64()
+	e.timestamp = time.Now().Unix()
+	s.trackedPayments.Set(rHash, e)
+	return e.id
+}
+
+// TrackWithdrawal tracks a withdrawal payment like TrackPayment does, if it fails the claims are
+// restored to the prizes they were deducted from. The payout is stored so the winner can follow its
+// status.
+func (s *streamer) TrackWithdrawal(paymentHash, publicKey string, claims []db.PrizesRow) uint64 {
+	amount := uint64(0)
+	for _, claim := range claims {
+		amount += claim.Amount
+	}
+
 	entry := entry{
 		id:        rand.Uint64(),
 		publicKey: publicKey,
+		claims:    claims,
 		amount:    amount,
 		timestamp: time.Now().Unix(),
 	}
-	s.trackedPayments.Set(rHash, entry)
+	s.trackedPayments.Set(paymentHash, entry)
+
+	payout := db.Payout{
+		PublicKey:   publicKey,
+		PaymentHash: paymentHash,
+		Amount:      amount,
+		Status:      db.PayoutPending,
+		CreatedAt:   entry.timestamp,
+	}
+	if _, err := s.db.Payouts.Add(payout); err != nil {
+		s.logger.Error(errors.Wrapf(err, "storing payout %s", paymentHash))
+	}
+
 	return entry.id
 }
 
+// WatchPayment follows the updates of a payment sent with PayInvoice until it completes, so the
+// withdrawal tracked with its hash reflects the progress reported by the node right away. The
+// payments subscription still completes the withdrawals whose stream breaks.
+func (s *streamer) WatchPayment(paymentHash string, updates lightning.Stream[*lnrpc.Payment]) {
+	go s.watchPayment(paymentHash, updates)
+}
+
+func (s *streamer) watchPayment(paymentHash string, updates lightning.Stream[*lnrpc.Payment]) {
+	received := false
+	for {
+		payment, err := updates.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return
+			}
+
+			// The node reports the payments it refuses to send as a stream error
+			if !received && lightning.PaymentRejected(err) {
+				s.paymentFailed(paymentHash, err.Error())
+				return
+			}
+
+			s.logger.Error(errors.Wrapf(err, "receiving payment %s updates", paymentHash))
+			return
+		}
+		received = true
+
+		s.updatePayment(payment)
+		if payment.Status == lnrpc.Payment_SUCCEEDED || payment.Status == lnrpc.Payment_FAILED {
+			return
+		}
+	}
+}
+
+// TrackHoldInvoice tracks a hold invoice like TrackPayment does and settles it once its HTLCs are
+// accepted, unless they arrived through channel peers that exceeded their bet cap.
+func (s *streamer) TrackHoldInvoice(rHash string, preimage []byte, publicKey string, amount uint64) uint64 {
+	id := s.TrackPayment(rHash, publicKey, amount)
+	go s.settleHoldInvoice(context.Background(), rHash, preimage)
+	return id
+}
+
+// resumeHoldInvoices watches again the hold invoices that were pending when the process stopped,
+// the leader takes over the ones left by other instances as well. Those that are not resumed are
+// cancelled by the invoices policy once they expire.
+//
+// Their bets are replayed by the invoices subscription once settled, as they are no longer
+// tracked.
+func (s *streamer) resumeHoldInvoices(ctx context.Context) {
+	invoices, err := s.db.Invoices.ListHeld(time.Now().Unix())
+	if err != nil {
+		s.logger.Error(errors.Wrap(err, "listing hold invoices"))
+		return
+	}
+
+	for _, invoice := range invoices {
+		if s.trackedPayments.Has(invoice.PaymentHash) {
+			continue
+		}
+
+		preimage, err := hex.DecodeString(invoice.Preimage)
+		if err != nil {
+			s.logger.Error(errors.Wrapf(err, "decoding invoice %s preimage", invoice.PaymentHash))
+			continue
+		}
+
+		go s.settleHoldInvoice(ctx, invoice.PaymentHash, preimage)
+	}
+}
+
+// Untrack stops tracking a payment that won't be attempted, so its claims are not restored twice.
+func (s *streamer) Untrack(paymentHash string) {
+	s.trackedPayments.Remove(paymentHash)
+}
+
 // removeExpiredPayments deletes all payments that were tracked more than one day ago.
 func (s *streamer) removeExpiredPayments() {
 	minTimestamp := time.Now().Add(-lightning.DefaultInvoiceExpiry).Unix()
@@ -227,7 +393,7 @@ func (s *streamer) subscribeChannelEvents(ctx context.Context) {
 			// Wait one second for the LND backend to update the channel list
 			time.Sleep(time.Second)
 
-			lotteryInfo, err := lottery.GetInfo(ctx, s.lnd, s.db)
+			lotteryInfo, err := lottery.GetInfo(ctx, s.lnd, s.db, s.pools, s.capacity)
 			if err != nil {
 				s.logger.Error(errors.Wrap(err, "getting lottery information"))
 				return
@@ -238,14 +404,27 @@ func (s *streamer) subscribeChannelEvents(ctx context.Context) {
 				Capacity:  &lotteryInfo.Capacity,
 			}
 			s.publish(infoEvent, payload)
+			s.webhooks.Publish(webhooks.CapacityChanged, map[string]any{
+				"capacity":   lotteryInfo.Capacity,
+				"prize_pool": lotteryInfo.PrizePool,
+			})
 		}
 	}
 }
 
 // subscribeInvoices listens to a stream of invoices and performs a specific action when finds one
 // that succeeded and was being tracked by the API.
+//
+// The invoices settled after the last one registered are received first, so the bets lost if the
+// process stopped between the settlement and the bet insertion are replayed on startup.
 func (s *streamer) subscribeInvoices(ctx context.Context) {
-	stream, err := s.lnd.SubscribeInvoices(ctx)
+	settleIndex, err := s.db.Invoices.LastSettleIndex()
+	if err != nil {
+		s.logger.Fatal(errors.Wrap(err, "getting last settle index"))
+		return
+	}
+
+	stream, err := s.lnd.SubscribeInvoices(ctx, settleIndex)
 	if err != nil {
 		s.logger.Fatal(errors.Wrap(err, "subscribing to invoices stream"))
 		return
@@ -258,26 +437,269 @@ func (s *streamer) subscribeInvoices(ctx context.Context) {
 			return
 		}
 
-		// Stream only settled invoices
-		if invoice.State == lnrpc.Invoice_SETTLED {
-			rHash := hex.EncodeToString(invoice.RHash)
+		rHash := hex.EncodeToString(invoice.RHash)
+		switch {
+		case invoice.State == lnrpc.Invoice_CANCELED:
+			// Expired invoices are cancelled, they will never be paid
+			s.trackedPayments.Remove(rHash)
+
+		case invoice.IsKeysend && invoice.State == lnrpc.Invoice_OPEN:
+			// Keysend invoices are added by the node when the payment arrives
+			if s.keysend.Enabled && s.leader.IsLeader() {
+				go s.settleKeysend(ctx, invoice.RHash)
+			}
+
+		case invoiceSettled(invoice):
 			entry, ok := s.trackedPayments.Get(rHash)
-			if !ok {
+			if !ok && !s.leader.IsLeader() {
+				// Followers leave the replays to the leader
 				continue
 			}
 
-			s.addBet(rHash, entry)
-			payload := &invoicesPayload{
-				PaymentID: entry.id,
-				PublicKey: entry.publicKey,
-				Amount:    entry.amount,
-				Status:    success,
+			// Stream only the settled invoices being tracked, the rest are replayed from the
+			// database if they were lost
+			place := func() error {
+				if ok {
+					return s.placeBet(ctx, rHash, entry)
+				}
+				return s.replayBet(rHash)
 			}
-			s.publish(invoicesEvent, payload)
+			if err := place(); err != nil {
+				s.retryBet(ctx, rHash, invoice.SettleIndex, err, place, func() {
+					if ok {
+						s.betFailed(rHash, entry)
+					}
+				})
+				continue
+			}
+
+			s.settleInvoice(rHash, invoice.SettleIndex)
+		}
+	}
+}
+
+// placeBet adds the bet of a tracked invoice and streams its result to the player.
+func (s *streamer) placeBet(ctx context.Context, rHash string, e entry) error {
+	bet, err := s.addBet(rHash, e)
+	if err != nil {
+		return err
+	}
+
+	payload := &invoicesPayload{
+		PaymentID:    e.id,
+		PublicKey:    e.publicKey,
+		Amount:       e.amount,
+		BonusTickets: bet.Bonus,
+		Receipt:      s.signReceipt(bet, rHash),
+		Status:       success,
+	}
+	s.publish(invoicesEvent, payload)
+
+	if e.replyTo != "" {
+		go s.confirmKeysend(ctx, e.replyTo, rHash, bet)
+	}
+	return nil
+}
+
+// betFailed stops tracking the invoice of a bet that couldn't be placed and lets the player know.
+func (s *streamer) betFailed(rHash string, e entry) {
+	s.trackedPayments.Remove(rHash)
+	s.publish(invoicesEvent, &invoicesPayload{
+		PaymentID: e.id,
+		PublicKey: e.publicKey,
+		Amount:    e.amount,
+		Error:     "payment received, the bet will be placed when the server restarts",
+		Status:    failed,
+	})
+}
+
+// settleInvoice registers the invoice of a bet placed. It's done after placing the bet so the
+// invoice is received again if the process stops in between.
+func (s *streamer) settleInvoice(rHash string, settleIndex uint64) {
+	if err := s.db.Invoices.Settle(rHash, settleIndex, time.Now().Unix()); err != nil {
+		s.logger.Error(errors.Wrapf(err, "marking invoice %s as paid", rHash))
+	}
+}
+
+// replayBet adds the bet of a settled invoice that is not tracked in memory, unless it's not a bet
+// invoice or the bet was already placed.
+func (s *streamer) replayBet(rHash string) error {
+	invoice, err := s.db.Invoices.Get(rHash)
+	if err != nil {
+		if errors.Is(err, db.ErrInvoiceNotFound) {
+			return nil
+		}
+		return errors.Wrapf(err, "getting invoice %s", rHash)
+	}
+
+	exists, err := s.db.Bets.Exists(rHash)
+	if err != nil {
+		return errors.Wrapf(err, "checking bet %s", rHash)
+	}
+	if exists {
+		return nil
+	}
+
+	s.logger.Warningf("Replaying bet %s from %s, its invoice was settled but the bet was not placed",
+		rHash, invoice.PublicKey)
+	_, err = s.addBet(rHash, entry{publicKey: invoice.PublicKey, amount: invoice.Amount})
+	return err
+}
+
+// retryBet leaves the invoice of a bet that couldn't be placed pending, so it's received again the
+// next time the invoices are subscribed, and places it in the background if the failure was
+// transient. fail is executed if the bet couldn't be placed within the retry policy attempts.
+func (s *streamer) retryBet(
+	ctx context.Context,
+	rHash string,
+	settleIndex uint64,
+	reason error,
+	place func() error,
+	fail func(),
+) {
+	s.logger.Error(reason)
+	if err := s.db.Invoices.Retry(rHash, settleIndex); err != nil {
+		s.logger.Error(errors.Wrapf(err, "retrying invoice %s", rHash))
+	}
+
+	if !fault.IsTransient(db.Classify(reason)) {
+		fail()
+		return
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.retry.Delay):
+		}
+
+		err := fault.Retry(ctx, s.retry, func() error {
+			return db.Classify(place())
+		})
+		if err != nil {
+			s.logger.Error(errors.Wrapf(err, "retrying bet %s", rHash))
+			fail()
+			return
+		}
+
+		s.settleInvoice(rHash, settleIndex)
+	}()
+}
+
+// invoiceSettled returns whether the invoice was paid in full. AMP invoices stay open after being
+// paid, their partial payments are grouped in sets that are settled once the full amount arrives.
+func invoiceSettled(invoice *lnrpc.Invoice) bool {
+	if invoice.State == lnrpc.Invoice_SETTLED {
+		return true
+	}
+
+	if !invoice.IsAmp {
+		return false
+	}
+
+	for _, set := range invoice.AmpInvoiceState {
+		if set.State == lnrpc.InvoiceHTLCState_SETTLED && set.AmtPaidMsat >= invoice.ValueMsat {
+			return true
+		}
+	}
+
+	return false
+}
+
+// settleHoldInvoice waits for the hold invoice HTLCs to be accepted and decides whether to settle
+// or cancel it. Settled invoices are registered as bets by subscribeInvoices.
+func (s *streamer) settleHoldInvoice(ctx context.Context, rHash string, preimage []byte) {
+	hash, err := hex.DecodeString(rHash)
+	if err != nil {
+		s.logger.Error(errors.Wrap(err, "decoding payment hash"))
+		return
+	}
+
+	stream, err := s.lnd.SubscribeSingleInvoice(ctx, hash)
+	if err != nil {
+		s.logger.Error(errors.Wrapf(err, "subscribing to invoice %s", rHash))
+		return
+	}
+
+	for {
+		invoice, err := stream.Recv()
+		if err != nil {
+			s.logger.Error(errors.Wrapf(err, "receiving events from invoice %s stream", rHash))
+			return
+		}
+
+		switch invoice.State {
+		case lnrpc.Invoice_ACCEPTED:
+			s.acceptHoldInvoice(ctx, rHash, hash, preimage, invoice.Htlcs)
+			return
+		case lnrpc.Invoice_CANCELED:
+			s.trackedPayments.Remove(rHash)
+			return
+		case lnrpc.Invoice_SETTLED:
+			return
 		}
 	}
 }
 
+// acceptHoldInvoice settles the invoice if the bet doesn't exceed the channel peers caps and
+// cancels it otherwise, returning the funds to the payer.
+func (s *streamer) acceptHoldInvoice(
+	ctx context.Context,
+	rHash string,
+	hash, preimage []byte,
+	htlcs []*lnrpc.InvoiceHTLC,
+) {
+	// Drop the reservation left if the process stopped before settling a resumed invoice
+	err := s.peerCap.Release(rHash)
+	var height uint32
+	if err == nil {
+		height, err = s.db.Lotteries.GetNextHeight()
+	}
+	if err == nil {
+		err = s.peerCap.Reserve(ctx, height, rHash, htlcs)
+	}
+	if err != nil {
+		s.declineHoldInvoice(ctx, rHash, hash, err)
+		return
+	}
+
+	if err := s.lnd.SettleInvoice(ctx, preimage); err != nil {
+		s.logger.Error(errors.Wrapf(err, "settling invoice %s", rHash))
+		if err := s.peerCap.Release(rHash); err != nil {
+			s.logger.Error(errors.Wrapf(err, "releasing invoice %s exposure", rHash))
+		}
+	}
+}
+
+func (s *streamer) declineHoldInvoice(ctx context.Context, rHash string, hash []byte, reason error) {
+	s.logger.Warningf("Declining invoice %s: %v", rHash, reason)
+
+	if err := s.lnd.CancelInvoice(ctx, hash); err != nil {
+		s.logger.Error(errors.Wrapf(err, "cancelling invoice %s", rHash))
+	}
+
+	entry, ok := s.trackedPayments.Get(rHash)
+	if !ok {
+		return
+	}
+	s.trackedPayments.Remove(rHash)
+
+	message := "bet declined, try again later"
+	if errors.Is(reason, policy.ErrPeerCapExceeded) {
+		message = "bet declined, the channel used to pay reached its limit for this lottery"
+	}
+
+	payload := &invoicesPayload{
+		PaymentID: entry.id,
+		PublicKey: entry.publicKey,
+		Amount:    entry.amount,
+		Error:     message,
+		Status:    failed,
+	}
+	s.publish(invoicesEvent, payload)
+}
+
 // subscribePayments listens to a stream of payments and performs a specific action when finds one
 // that succeeded and was being tracked by the API.
 func (s *streamer) subscribePayments(ctx context.Context) {
@@ -294,46 +716,159 @@ func (s *streamer) subscribePayments(ctx context.Context) {
 			return
 		}
 
-		entry, ok := s.trackedPayments.Get(payment.PaymentHash)
-		if !ok {
-			continue
+		s.updatePayment(payment)
+	}
+}
+
+// updatePayment applies an update of a payment to the withdrawal tracked with its hash. Updates
+// may be received twice, from the payment stream and from the payments subscription, only the first
+// one that completes the withdrawal is applied.
+func (s *streamer) updatePayment(payment *lnrpc.Payment) {
+	switch payment.Status {
+	case lnrpc.Payment_IN_FLIGHT:
+		if entry, ok := s.trackedPayments.Get(payment.PaymentHash); ok {
+			s.paymentInFlight(payment.PaymentHash, entry)
 		}
 
-		switch payment.Status {
-		case lnrpc.Payment_FAILED:
-			// Give the funds back to the user
-			s.restoreFunds(payment.PaymentHash, entry)
-			s.logger.Errorf("Failed to pay invoice %s: %s",
-				payment.PaymentHash, payment.FailureReason)
-
-			payload := &paymentsPayload{
-				PaymentID: entry.id,
-				Status:    failed,
-				Error:     payment.FailureReason.String(),
-			}
-			s.publish(paymentsEvent, payload)
+	case lnrpc.Payment_FAILED:
+		s.paymentFailed(payment.PaymentHash, payment.FailureReason.String())
 
-		case lnrpc.Payment_SUCCEEDED:
-			payload := &paymentsPayload{
-				PaymentID: entry.id,
-				Status:    success,
-			}
-			s.publish(paymentsEvent, payload)
-		}
+	case lnrpc.Payment_SUCCEEDED:
+		s.paymentSucceeded(payment)
+	}
+}
+
+// paymentInFlight notifies the winner that the node started sending the withdrawal, the following
+// attempts are not notified.
+func (s *streamer) paymentInFlight(paymentHash string, entry entry) {
+	updated, err := s.db.Payouts.Update(db.Payout{
+		PaymentHash: paymentHash,
+		Status:      db.PayoutInFlight,
+		UpdatedAt:   time.Now().Unix(),
+	})
+	if err != nil {
+		s.logger.Error(errors.Wrapf(err, "updating payout %s", paymentHash))
+		return
+	}
+	if !updated {
+		return
+	}
+
+	s.live.Payout(entry.publicKey, live.Payout{
+		PaymentID: entry.id,
+		Amount:    entry.amount,
+		Status:    live.PayoutInFlight,
+	})
+}
+
+func (s *streamer) paymentFailed(paymentHash, reason string) {
+	entry, ok := s.trackedPayments.Pop(paymentHash)
+	if !ok {
+		return
+	}
+
+	// Give the funds back to the user
+	s.restoreFunds(paymentHash, entry)
+	s.logger.Errorf("Failed to pay invoice %s: %s", paymentHash, reason)
+
+	payload := &paymentsPayload{
+		PaymentID: entry.id,
+		Status:    failed,
+		Error:     reason,
+	}
+	s.publish(paymentsEvent, payload)
+	s.live.Payout(entry.publicKey, live.Payout{
+		PaymentID: entry.id,
+		Amount:    entry.amount,
+		Status:    live.PayoutFailed,
+		Error:     reason,
+	})
+	s.completePayout(db.Payout{
+		PublicKey:     entry.publicKey,
+		PaymentHash:   paymentHash,
+		Amount:        entry.amount,
+		Status:        db.PayoutFailed,
+		FailureReason: reason,
+		UpdatedAt:     time.Now().Unix(),
+	})
+}
+
+func (s *streamer) paymentSucceeded(payment *lnrpc.Payment) {
+	// Stop tracking payment
+	entry, ok := s.trackedPayments.Pop(payment.PaymentHash)
+	if !ok {
+		return
+	}
+
+	s.auditor.Record(audit.PayoutSent, map[string]any{
+		"public_key":   entry.publicKey,
+		"amount":       entry.amount,
+		"payment_hash": payment.PaymentHash,
+	})
+	s.webhooks.Publish(webhooks.PayoutSent, map[string]any{
+		"amount":       entry.amount,
+		"payment_hash": payment.PaymentHash,
+	})
+	if err := s.db.Stats.AddPayout(entry.amount); err != nil {
+		s.logger.Error(errors.Wrap(err, "updating payout stats"))
+	}
+
+	payload := &paymentsPayload{
+		PaymentID: entry.id,
+		Status:    success,
 	}
+	s.publish(paymentsEvent, payload)
+	s.live.Payout(entry.publicKey, live.Payout{
+		PaymentID: entry.id,
+		Amount:    entry.amount,
+		Status:    live.PayoutSucceeded,
+	})
+	s.completePayout(db.Payout{
+		PublicKey:   entry.publicKey,
+		PaymentHash: payment.PaymentHash,
+		Amount:      entry.amount,
+		Status:      db.PayoutSucceeded,
+		Fee:         uint64(payment.FeeSat),
+		Preimage:    payment.PaymentPreimage,
+		UpdatedAt:   time.Now().Unix(),
+	})
+}
+
+// completePayout stores the result of a withdrawal and notifies the winner.
+func (s *streamer) completePayout(payout db.Payout) {
+	updated, err := s.db.Payouts.Update(payout)
+	if err != nil {
+		s.logger.Error(errors.Wrapf(err, "updating payout %s", payout.PaymentHash))
+		return
+	}
+	if !updated {
+		return
+	}
+
+	s.payoutNotifier.NotifyPayout(payout)
 }
 
 // subscribeWinners streams winners when they are known and restarts the prize pool.
 func (s *streamer) subscribeWinners(ctx context.Context) {
 	for {
 		select {
-		case winners := <-s.winnersCh:
-			lotteryInfo, err := lottery.GetInfo(ctx, s.lnd, s.db)
+		case winners, ok := <-s.winners.C():
+			if !ok {
+				return
+			}
+
+			lotteryInfo, err := lottery.GetInfo(ctx, s.lnd, s.db, s.pools, s.capacity)
 			if err != nil {
 				s.logger.Error(errors.Wrap(err, "getting lottery information"))
 				return
 			}
 
+			winners, err = policy.AnonymizeWinners(s.db.ReadReplica().Privacy, winners)
+			if err != nil {
+				s.logger.Error(errors.Wrap(err, "anonymizing winners"))
+				return
+			}
+
 			payload := &infoPayload{
 				PrizePool:  &lotteryInfo.PrizePool,
 				Capacity:   &lotteryInfo.Capacity,
@@ -363,17 +898,93 @@ func (s *streamer) publish(event []byte, payload any) {
 	})
 }
 
-func (s *streamer) addBet(rHash string, e entry) {
-	// Stop tracking payment
-	s.trackedPayments.Remove(rHash)
+// addBet places the bet paid with the invoice and stops tracking it. The invoice is tracked until
+// the bet is stored, so it can be placed again if it fails.
+func (s *streamer) addBet(rHash string, e entry) (db.Bet, error) {
+	// The settings may have been reloaded since the invoice was requested
+	bonus, pools := s.settings.Bonus(), s.settings.Pools()
+
+	// The amount was checked when the invoice was requested, if the pools changed since then the
+	// bet goes to the unnamed one
+	pool, ok := pools.Route(e.amount)
+	if !ok {
+		s.logger.Warningf("No pool accepts bets of %d sats, adding bet %s to the default pool", e.amount, rHash)
+	}
 
 	bet := db.Bet{
-		PublicKey: e.publicKey,
-		Tickets:   e.amount,
+		PublicKey:   e.publicKey,
+		Tickets:     e.amount,
+		Bonus:       lottery.BonusTickets(bonus.Bundles, e.amount) + s.coinAgeTickets(bonus.CoinAge, e.amount),
+		Pool:        pool.Name,
+		PaymentHash: rHash,
+		House:       s.housePlay.IsHouse(e.publicKey),
+		CreatedAt:   time.Now().Unix(),
+	}
+	feeRate := lottery.BonusFeeRate(pools.Distribution(pool.Name), s.lastTicket)
+	bet, err := s.db.Bets.Add(bet, bonus.RoundCap, feeRate)
+	if err != nil {
+		return db.Bet{}, errors.Wrapf(err, "adding bet: %s from %s", rHash, e.publicKey)
 	}
-	if err := s.db.Bets.Add(bet); err != nil {
-		s.logger.Error(errors.Wrapf(err, "adding bet: %s from %s", rHash, e.publicKey))
+	s.trackedPayments.Remove(rHash)
+
+	s.auditor.Record(audit.BetAccepted, map[string]any{
+		"public_key":    e.publicKey,
+		"tickets":       e.amount,
+		"bonus_tickets": bet.Bonus,
+		"pool":          bet.Pool,
+		"payment_hash":  rHash,
+	})
+	s.live.AddBet(bet)
+
+	return bet, nil
+}
+
+// coinAgeTickets returns the extra tickets granted to a bet for being placed early in the round.
+// Errors are only logged as the bet is accepted anyway, without the bonus.
+func (s *streamer) coinAgeTickets(coinAge []config.CoinAge, amount uint64) uint64 {
+	if len(coinAge) == 0 {
+		return 0
+	}
+
+	nextHeight, err := s.db.Lotteries.GetNextHeight()
+	if err != nil {
+		s.logger.Error(errors.Wrap(err, "getting next height"))
+		return 0
+	}
+
+	info, err := s.lnd.GetInfo(context.Background())
+	if err != nil {
+		s.logger.Error(errors.Wrap(err, "getting block height"))
+		return 0
 	}
+
+	if info.BlockHeight >= nextHeight {
+		return 0
+	}
+
+	return lottery.CoinAgeTickets(coinAge, amount, nextHeight-info.BlockHeight)
+}
+
+// signReceipt returns the receipt of a bet stored, or nil if it couldn't be signed.
+func (s *streamer) signReceipt(bet db.Bet, rHash string) *audit.Receipt {
+	if bet.Index == 0 {
+		return nil
+	}
+
+	receipt, err := s.auditor.SignReceipt(bet, rHash)
+	if err != nil {
+		if !errors.Is(err, audit.ErrSigningDisabled) {
+			s.logger.Error(errors.Wrapf(err, "signing receipt: %s from %s", rHash, bet.PublicKey))
+		}
+		return nil
+	}
+
+	// The receipt is stored so the player can download it after the bets are compacted
+	if err := s.db.Receipts.Add(receipt); err != nil {
+		s.logger.Error(errors.Wrapf(err, "storing receipt: %s from %s", rHash, bet.PublicKey))
+	}
+
+	return &receipt
 }
 
 // restoreFunds gives the user back the prizes that were discounted from him. It should be executed
@@ -382,23 +993,14 @@ func (s *streamer) restoreFunds(rHash string, e entry) {
 	// Stop tracking payment
 	s.trackedPayments.Remove(rHash)
 
-	// We should restore the prizes only if the public key is stored as a winner
-	if prizes, err := s.db.Prizes.Get(e.publicKey); err != nil || prizes == 0 {
+	// We should restore the prizes only if they were claimed by a withdrawal
+	if len(e.claims) == 0 {
 		s.logger.Error("tried restoring prizes to a user that is not a winner")
 		return
 	}
 
-	height, err := s.db.Lotteries.GetNextHeight()
-	if err != nil {
-		s.logger.Error("getting next height")
-		return
-	}
-
-	winner := db.Winner{
-		PublicKey: e.publicKey,
-		Prize:     e.amount,
-	}
-	if err := s.db.Winners.Add(height, []db.Winner{winner}); err != nil {
+	// Claims are restored to the prizes they were taken from, keeping their expiration
+	if err := s.db.Prizes.Restore(e.claims); err != nil {
 		s.logger.Error(
 			errors.Wrapf(err, "restoring funds. Public key %s, payment %s", e.publicKey, rHash),
 		)
@@ -12,20 +12,33 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aftermath2/BTRY/audit"
 	"github.com/aftermath2/BTRY/config"
 	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/fault"
+	"github.com/aftermath2/BTRY/http/api/live"
+	"github.com/aftermath2/BTRY/leader"
 	"github.com/aftermath2/BTRY/lightning"
 	"github.com/aftermath2/BTRY/logger"
 	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/lottery/engine"
+	"github.com/aftermath2/BTRY/policy"
+	"github.com/aftermath2/BTRY/webhooks"
 
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
 	cmap "github.com/orcaman/concurrent-map/v2"
 	"github.com/r3labs/sse"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
 )
 
+// defaultFeeRate funds the bonus tickets of the bets placed in the unnamed pool.
+var defaultFeeRate = engine.DefaultDistribution.Fee()
+
 func TestNewStreamer(t *testing.T) {
 	lndMock := lightning.NewClientMock()
 
@@ -33,16 +46,33 @@ func TestNewStreamer(t *testing.T) {
 		Return(lightning.BlockedStreamMock[*chainrpc.BlockEpoch]{}, nil)
 	lndMock.On("SubscribeChannelEvents", context.Background()).
 		Return(lightning.BlockedStreamMock[*lnrpc.ChannelEventUpdate]{}, nil)
-	lndMock.On("SubscribeInvoices", context.Background()).
+	lndMock.On("SubscribeInvoices", context.Background(), uint64(0)).
 		Return(lightning.BlockedStreamMock[*lnrpc.Invoice]{}, nil)
 	lndMock.On("SubscribePayments", context.Background()).
 		Return(lightning.BlockedStreamMock[*lnrpc.Payment]{}, nil)
+	invoicesMock := db.NewInvoicesStoreMock()
+	invoicesMock.On("LastSettleIndex").Return(uint64(0), nil)
+	leaderMock := leader.NewElectorMock()
+	leaderMock.On("OnElected", mock.Anything)
 
 	streamer, err := NewStreamer(
 		config.SSE{Logger: config.Logger{Level: uint8(logger.DISABLED)}},
-		&db.DB{},
+		lottery.NewBetSettingsMock(),
+		config.LastTicket{},
+		config.Keysend{},
+		lottery.NewPools(nil),
+		lottery.RemoteBalanceCapacity{},
+		&db.DB{Invoices: invoicesMock},
 		lndMock,
-		make(<-chan []db.Winner),
+		nil,
+		nil,
+		&policy.PeerCap{},
+		&policy.Limits{},
+		policy.NewHousePlay(config.HousePlay{}),
+		leaderMock,
+		nil,
+		lottery.NewWinnersHub(config.WinnersHub{}),
+		nil,
 		make(chan<- *chainrpc.BlockEpoch),
 	)
 	assert.NoError(t, err)
@@ -54,14 +84,23 @@ func TestNewStreamer(t *testing.T) {
 type SSESuite struct {
 	suite.Suite
 
-	betsMock      *db.BetsStoreMock
-	lotteriesMock *db.LotteriesStoreMock
-	prizesMock    *db.PrizesStoreMock
-	winnersMock   *db.WinnersStoreMock
-	lndMock       *lightning.ClientMock
-	server        *ServerMock
-	winnersCh     chan []db.Winner
-	sse           streamer
+	betsMock          *db.BetsStoreMock
+	invoicesMock      *db.InvoicesStoreMock
+	lotteriesMock     *db.LotteriesStoreMock
+	payoutsMock       *db.PayoutsStoreMock
+	postponementsMock *db.PostponementsStoreMock
+	prizesMock        *db.PrizesStoreMock
+	privacyMock       *db.PrivacyStoreMock
+	receiptsMock      *db.ReceiptsStoreMock
+	statsMock         *db.StatsStoreMock
+	winnersMock       *db.WinnersStoreMock
+	lndMock           *lightning.ClientMock
+	auditorMock       *audit.AuditorMock
+	webhooksMock      *webhooks.PublisherMock
+	notifierMock      *lottery.PayoutNotifierMock
+	server            *ServerMock
+	winnersHub        *lottery.WinnersHub
+	sse               streamer
 }
 
 func TestSSESuite(t *testing.T) {
@@ -73,27 +112,66 @@ func (s *SSESuite) SetupTest() {
 	s.NoError(err)
 
 	s.betsMock = db.NewBetsStoreMock()
+	s.invoicesMock = db.NewInvoicesStoreMock()
+	s.invoicesMock.On("LastSettleIndex").Return(uint64(0), nil).Maybe()
 	s.lotteriesMock = db.NewLotteriesStoreMock()
+	s.payoutsMock = db.NewPayoutsStoreMock()
+	s.postponementsMock = db.NewPostponementsStoreMock()
 	s.prizesMock = db.NewPrizesStoreMock()
+	s.privacyMock = db.NewPrivacyStoreMock()
+	s.receiptsMock = db.NewReceiptsStoreMock()
+	s.statsMock = db.NewStatsStoreMock()
 	s.winnersMock = db.NewWinnersStoreMock()
 	s.lndMock = lightning.NewClientMock()
+	s.auditorMock = audit.NewAuditorMock()
+	s.webhooksMock = webhooks.NewPublisherMock()
+	s.notifierMock = lottery.NewPayoutNotifierMock()
 	s.server = NewServerMock()
-	s.winnersCh = make(chan []db.Winner)
+	s.winnersHub = lottery.NewWinnersHub(config.WinnersHub{})
+	database := &db.DB{
+		Bets:          s.betsMock,
+		Invoices:      s.invoicesMock,
+		Lotteries:     s.lotteriesMock,
+		Payouts:       s.payoutsMock,
+		Postponements: s.postponementsMock,
+		Prizes:        s.prizesMock,
+		Privacy:       s.privacyMock,
+		Receipts:      s.receiptsMock,
+		Stats:         s.statsMock,
+		Winners:       s.winnersMock,
+	}
+	liveHub, err := live.NewHub(config.Live{}, 0, database, s.lndMock, lottery.NewPools(nil), lottery.StatsPrivacy{},
+		s.winnersHub)
+	s.NoError(err)
+	leaderMock := leader.NewElectorMock()
+	leaderMock.On("IsLeader").Return(true).Maybe()
 	s.sse = streamer{
 		server:          s.server,
-		winnersCh:       s.winnersCh,
+		winners:         s.winnersHub.Subscribe(),
+		live:            liveHub,
 		logger:          logger,
 		lnd:             s.lndMock,
+		auditor:         s.auditorMock,
+		webhooks:        s.webhooksMock,
 		trackedPayments: cmap.New[entry](),
-		db: &db.DB{
-			Bets:      s.betsMock,
-			Lotteries: s.lotteriesMock,
-			Prizes:    s.prizesMock,
-			Winners:   s.winnersMock,
-		},
+		settings:        s.betSettings(config.Bonus{}, lottery.NewPools(nil)),
+		pools:           lottery.NewPools(nil),
+		capacity:        lottery.RemoteBalanceCapacity{},
+		db:              database,
+		housePlay:       policy.NewHousePlay(config.HousePlay{}),
+		leader:          leaderMock,
+		payoutNotifier:  s.notifierMock,
 	}
 }
 
+// betSettings returns the reloadable settings read by the bets.
+func (s *SSESuite) betSettings(bonus config.Bonus, pools lottery.Pools) *lottery.BetSettingsMock {
+	settings := lottery.NewBetSettingsMock()
+	settings.On("Bonus").Return(bonus).Maybe()
+	settings.On("Pools").Return(pools).Maybe()
+	return settings
+}
+
 func (s *SSESuite) TestClose() {
 	s.server.On("Close").Return(nil)
 
@@ -163,6 +241,7 @@ func (s *SSESuite) TestServeHTTP() {
 }
 
 type customEventsStreamMock[T any] struct {
+	err     error
 	events  []T
 	counter int
 }
@@ -171,6 +250,9 @@ type customEventsStreamMock[T any] struct {
 func (s *customEventsStreamMock[T]) Recv() (T, error) {
 	if s.counter == len(s.events) {
 		var v T
+		if s.err != nil {
+			return v, s.err
+		}
 		return v, errors.New("exit")
 	}
 
@@ -198,10 +280,11 @@ func (s *SSESuite) TestSubscribeChannelEvents() {
 
 	s.lndMock.On("RemoteBalance", ctx).Return(remoteBalance, nil)
 	s.lotteriesMock.On("GetNextHeight").Return(blockHeight, nil)
-	s.betsMock.On("GetPrizePool", blockHeight).Return(prizePool, nil)
+	s.postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+	s.betsMock.On("GetPrizePool", blockHeight, "").Return(prizePool, nil)
 
 	pp := int64(prizePool)
-	capacity := remoteBalance / lottery.CapacityDivisor
+	capacity := remoteBalance / lottery.DefaultCapacityDivisor
 	payload := &infoPayload{
 		PrizePool: &pp,
 		Capacity:  &capacity,
@@ -212,8 +295,14 @@ func (s *SSESuite) TestSubscribeChannelEvents() {
 
 	event := &sse.Event{Event: infoEvent, Data: data}
 	s.server.On("Publish", streamID, event)
+	s.webhooksMock.On("Publish", webhooks.CapacityChanged, map[string]any{
+		"capacity":   capacity,
+		"prize_pool": pp,
+	})
 
 	s.sse.subscribeChannelEvents(ctx)
+
+	s.webhooksMock.AssertExpectations(s.T())
 }
 
 func (s *SSESuite) TestSubscribeChannelEventsPrivateChannel() {
@@ -257,19 +346,39 @@ func (s *SSESuite) TestSubscribeInvoices() {
 			RHash: rHash, State: lnrpc.Invoice_SETTLED,
 		}},
 	}
-	s.lndMock.On("SubscribeInvoices", ctx).Return(stream, nil)
+	s.lndMock.On("SubscribeInvoices", ctx, uint64(0)).Return(stream, nil)
 
 	bet := db.Bet{
-		PublicKey: publicKey,
-		Tickets:   amount,
+		PublicKey:   publicKey,
+		Tickets:     amount,
+		PaymentHash: hex.EncodeToString(rHash),
+	}
+	stored := db.Bet{
+		PublicKey:     publicKey,
+		Tickets:       amount,
+		Index:         amount,
+		LotteryHeight: 840_000,
 	}
-	s.betsMock.On("Add", bet).Return(nil)
+	receipt := audit.Receipt{
+		PublicKey:   publicKey,
+		PaymentHash: hex.EncodeToString(rHash),
+		FirstTicket: 1,
+		LastTicket:  amount,
+		Round:       840_000,
+		Signature:   "signature",
+	}
+	s.invoicesMock.On("Settle", hex.EncodeToString(rHash), uint64(0), mock.Anything).Return(nil)
+	s.betsMock.On("Add", matchBet(bet), uint64(0), defaultFeeRate).Return(stored, nil)
+	s.auditorMock.On("Record", audit.BetAccepted, mock.Anything)
+	s.auditorMock.On("SignReceipt", stored, hex.EncodeToString(rHash)).Return(receipt, nil)
+	s.receiptsMock.On("Add", receipt).Return(nil)
 
 	id := s.sse.TrackPayment(hex.EncodeToString(rHash), publicKey, amount)
 	payload := &invoicesPayload{
 		PaymentID: id,
 		PublicKey: publicKey,
 		Amount:    amount,
+		Receipt:   &receipt,
 		Status:    success,
 	}
 
@@ -282,6 +391,97 @@ func (s *SSESuite) TestSubscribeInvoices() {
 	s.sse.subscribeInvoices(ctx)
 }
 
+func (s *SSESuite) TestSubscribeInvoicesBetError() {
+	ctx := context.Background()
+	rHash := []byte("rHash")
+	paymentHash := hex.EncodeToString(rHash)
+	publicKey := "publicKey"
+	amount := uint64(200)
+
+	stream := &customEventsStreamMock[*lnrpc.Invoice]{
+		events: []*lnrpc.Invoice{{
+			RHash: rHash, State: lnrpc.Invoice_SETTLED, SettleIndex: 3,
+		}},
+	}
+	s.lndMock.On("SubscribeInvoices", ctx, uint64(0)).Return(stream, nil)
+
+	bet := db.Bet{PublicKey: publicKey, Tickets: amount, PaymentHash: paymentHash}
+	s.betsMock.On("Add", matchBet(bet), uint64(0), defaultFeeRate).Return(db.Bet{}, errors.New("locked"))
+	// The invoice is left pending so the bet is placed when it's received again
+	s.invoicesMock.On("Retry", paymentHash, uint64(3)).Return(nil)
+
+	id := s.sse.TrackPayment(paymentHash, publicKey, amount)
+	payload := &invoicesPayload{
+		PaymentID: id,
+		PublicKey: publicKey,
+		Amount:    amount,
+		Error:     "payment received, the bet will be placed when the server restarts",
+		Status:    failed,
+	}
+	data, err := json.Marshal(payload)
+	s.NoError(err)
+	s.server.On("Publish", streamID, &sse.Event{Event: invoicesEvent, Data: data})
+
+	s.sse.subscribeInvoices(ctx)
+
+	s.invoicesMock.AssertExpectations(s.T())
+	s.invoicesMock.AssertNotCalled(s.T(), "Settle", mock.Anything, mock.Anything, mock.Anything)
+	s.server.AssertExpectations(s.T())
+	_, ok := s.sse.trackedPayments.Get(paymentHash)
+	s.False(ok)
+}
+
+func (s *SSESuite) TestSubscribeInvoicesBetRetried() {
+	ctx := context.Background()
+	rHash := []byte("rHash")
+	paymentHash := hex.EncodeToString(rHash)
+	publicKey := "publicKey"
+	amount := uint64(200)
+	s.sse.retry = fault.Policy{Attempts: 3}
+
+	stream := &customEventsStreamMock[*lnrpc.Invoice]{
+		events: []*lnrpc.Invoice{{
+			RHash: rHash, State: lnrpc.Invoice_SETTLED, SettleIndex: 3,
+		}},
+	}
+	s.lndMock.On("SubscribeInvoices", ctx, uint64(0)).Return(stream, nil)
+
+	bet := db.Bet{PublicKey: publicKey, Tickets: amount, PaymentHash: paymentHash}
+	locked := fault.NewTransient(errors.New("database is locked"))
+	s.betsMock.On("Add", matchBet(bet), uint64(0), defaultFeeRate).Return(db.Bet{}, locked).Twice()
+	s.betsMock.On("Add", matchBet(bet), uint64(0), defaultFeeRate).Return(bet, nil).Once()
+	s.auditorMock.On("Record", audit.BetAccepted, mock.Anything)
+	s.invoicesMock.On("Retry", paymentHash, uint64(3)).Return(nil).Once()
+	settled := make(chan struct{})
+	s.invoicesMock.On("Settle", paymentHash, uint64(3), mock.Anything).Return(nil).
+		Run(func(mock.Arguments) { close(settled) })
+
+	id := s.sse.TrackPayment(paymentHash, publicKey, amount)
+	payload := &invoicesPayload{
+		PaymentID: id,
+		PublicKey: publicKey,
+		Amount:    amount,
+		Status:    success,
+	}
+	data, err := json.Marshal(payload)
+	s.NoError(err)
+	s.server.On("Publish", streamID, &sse.Event{Event: invoicesEvent, Data: data}).Once()
+
+	s.sse.subscribeInvoices(ctx)
+
+	// The bet is placed in the background, the invoice stays tracked meanwhile
+	select {
+	case <-settled:
+	case <-time.After(time.Second):
+		s.Fail("bet was not placed")
+	}
+	s.betsMock.AssertExpectations(s.T())
+	s.invoicesMock.AssertExpectations(s.T())
+	s.server.AssertExpectations(s.T())
+	_, ok := s.sse.trackedPayments.Get(paymentHash)
+	s.False(ok)
+}
+
 func (s *SSESuite) TestSubscribeInvoicesUntracked() {
 	ctx := context.Background()
 	rHash := []byte("rHash")
@@ -291,12 +491,305 @@ func (s *SSESuite) TestSubscribeInvoicesUntracked() {
 			RHash: rHash, State: lnrpc.Invoice_SETTLED,
 		}},
 	}
-	s.lndMock.On("SubscribeInvoices", ctx).Return(stream, nil)
+	s.lndMock.On("SubscribeInvoices", ctx, uint64(0)).Return(stream, nil)
+	s.invoicesMock.On("Get", hex.EncodeToString(rHash)).Return(db.Invoice{}, db.ErrInvoiceNotFound)
+	s.invoicesMock.On("Settle", hex.EncodeToString(rHash), uint64(0), mock.Anything).Return(nil)
 
 	_, ok := s.sse.trackedPayments.Get(hex.EncodeToString(rHash))
 	s.False(ok)
 
 	s.sse.subscribeInvoices(ctx)
+
+	// Invoices are marked as paid even if the API stopped tracking them
+	s.invoicesMock.AssertExpectations(s.T())
+	s.betsMock.AssertNotCalled(s.T(), "Add", mock.Anything, mock.Anything)
+}
+
+func (s *SSESuite) TestSubscribeInvoicesReplay() {
+	ctx := context.Background()
+	rHash := []byte("rHash")
+	paymentHash := hex.EncodeToString(rHash)
+	publicKey := "publicKey"
+	amount := uint64(200)
+
+	// The invoice was settled while the API was down, after the last one registered
+	stream := &customEventsStreamMock[*lnrpc.Invoice]{
+		events: []*lnrpc.Invoice{{
+			RHash: rHash, State: lnrpc.Invoice_SETTLED, SettleIndex: 8,
+		}},
+	}
+	s.invoicesMock = db.NewInvoicesStoreMock()
+	s.sse.db.Invoices = s.invoicesMock
+	s.invoicesMock.On("LastSettleIndex").Return(uint64(7), nil)
+	s.lndMock.On("SubscribeInvoices", ctx, uint64(7)).Return(stream, nil)
+
+	invoice := db.Invoice{PaymentHash: paymentHash, PublicKey: publicKey, Amount: amount}
+	bet := db.Bet{PublicKey: publicKey, Tickets: amount, PaymentHash: paymentHash}
+	s.invoicesMock.On("Get", paymentHash).Return(invoice, nil)
+	s.betsMock.On("Exists", paymentHash).Return(false, nil)
+	s.betsMock.On("Add", matchBet(bet), uint64(0), defaultFeeRate).Return(bet, nil).Once()
+	s.auditorMock.On("Record", audit.BetAccepted, mock.Anything)
+	s.invoicesMock.On("Settle", paymentHash, uint64(8), mock.Anything).Return(nil)
+
+	s.sse.subscribeInvoices(ctx)
+
+	s.betsMock.AssertExpectations(s.T())
+	s.invoicesMock.AssertExpectations(s.T())
+	s.server.AssertNotCalled(s.T(), "Publish", mock.Anything, mock.Anything)
+}
+
+func (s *SSESuite) TestSubscribeInvoicesReplayError() {
+	ctx := context.Background()
+	rHash := []byte("rHash")
+	paymentHash := hex.EncodeToString(rHash)
+
+	stream := &customEventsStreamMock[*lnrpc.Invoice]{
+		events: []*lnrpc.Invoice{{
+			RHash: rHash, State: lnrpc.Invoice_SETTLED, SettleIndex: 8,
+		}},
+	}
+	s.lndMock.On("SubscribeInvoices", ctx, uint64(0)).Return(stream, nil)
+	s.invoicesMock.On("Get", paymentHash).Return(db.Invoice{PaymentHash: paymentHash, Amount: 200}, nil)
+	s.betsMock.On("Exists", paymentHash).Return(false, errors.New("locked"))
+	s.invoicesMock.On("Retry", paymentHash, uint64(8)).Return(nil)
+
+	s.sse.subscribeInvoices(ctx)
+
+	s.invoicesMock.AssertExpectations(s.T())
+	s.invoicesMock.AssertNotCalled(s.T(), "Settle", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *SSESuite) TestSubscribeInvoicesReplayPlaced() {
+	ctx := context.Background()
+	rHash := []byte("rHash")
+	paymentHash := hex.EncodeToString(rHash)
+
+	stream := &customEventsStreamMock[*lnrpc.Invoice]{
+		events: []*lnrpc.Invoice{{
+			RHash: rHash, State: lnrpc.Invoice_SETTLED, SettleIndex: 8,
+		}},
+	}
+	s.lndMock.On("SubscribeInvoices", ctx, uint64(0)).Return(stream, nil)
+	s.invoicesMock.On("Get", paymentHash).Return(db.Invoice{PaymentHash: paymentHash, Amount: 200}, nil)
+	// The process stopped after placing the bet but before registering the invoice
+	s.betsMock.On("Exists", paymentHash).Return(true, nil)
+	s.invoicesMock.On("Settle", paymentHash, uint64(8), mock.Anything).Return(nil)
+
+	s.sse.subscribeInvoices(ctx)
+
+	s.invoicesMock.AssertExpectations(s.T())
+	s.betsMock.AssertNotCalled(s.T(), "Add", mock.Anything, mock.Anything)
+}
+
+func (s *SSESuite) TestSubscribeInvoicesCanceled() {
+	ctx := context.Background()
+	rHash := []byte("rHash")
+
+	stream := &customEventsStreamMock[*lnrpc.Invoice]{
+		events: []*lnrpc.Invoice{{
+			RHash: rHash, State: lnrpc.Invoice_CANCELED,
+		}},
+	}
+	s.lndMock.On("SubscribeInvoices", ctx, uint64(0)).Return(stream, nil)
+
+	s.sse.TrackPayment(hex.EncodeToString(rHash), "publicKey", 200)
+
+	s.sse.subscribeInvoices(ctx)
+
+	_, ok := s.sse.trackedPayments.Get(hex.EncodeToString(rHash))
+	s.False(ok)
+	s.betsMock.AssertNotCalled(s.T(), "Add", mock.Anything, mock.Anything)
+}
+
+func (s *SSESuite) TestSubscribeInvoicesAMP() {
+	ctx := context.Background()
+	rHash := []byte("rHash")
+	publicKey := "publicKey"
+	amount := uint64(200)
+
+	// AMP invoices stay open, the bet is registered once a set paying the full amount is settled
+	stream := &customEventsStreamMock[*lnrpc.Invoice]{
+		events: []*lnrpc.Invoice{{
+			RHash: rHash, State: lnrpc.Invoice_OPEN, IsAmp: true, ValueMsat: 200_000,
+			AmpInvoiceState: map[string]*lnrpc.AMPInvoiceState{
+				"setID": {State: lnrpc.InvoiceHTLCState_SETTLED, AmtPaidMsat: 200_000},
+			},
+		}},
+	}
+	s.lndMock.On("SubscribeInvoices", ctx, uint64(0)).Return(stream, nil)
+
+	stored := db.Bet{PublicKey: publicKey, Tickets: amount, Index: amount, LotteryHeight: 840_000}
+	receipt := audit.Receipt{PublicKey: publicKey, PaymentHash: hex.EncodeToString(rHash), Signature: "signature"}
+	s.invoicesMock.On("Settle", hex.EncodeToString(rHash), uint64(0), mock.Anything).Return(nil)
+	s.betsMock.On("Add", mock.Anything, uint64(0), defaultFeeRate).Return(stored, nil).Once()
+	s.auditorMock.On("Record", audit.BetAccepted, mock.Anything)
+	s.auditorMock.On("SignReceipt", stored, hex.EncodeToString(rHash)).Return(receipt, nil)
+	s.receiptsMock.On("Add", receipt).Return(nil)
+	s.server.On("Publish", streamID, mock.Anything)
+
+	s.sse.TrackPayment(hex.EncodeToString(rHash), publicKey, amount)
+	s.sse.subscribeInvoices(ctx)
+
+	s.betsMock.AssertExpectations(s.T())
+	_, ok := s.sse.trackedPayments.Get(hex.EncodeToString(rHash))
+	s.False(ok)
+}
+
+func (s *SSESuite) TestSubscribeInvoicesAMPPartial() {
+	ctx := context.Background()
+	rHash := []byte("rHash")
+
+	stream := &customEventsStreamMock[*lnrpc.Invoice]{
+		events: []*lnrpc.Invoice{
+			{
+				RHash: rHash, State: lnrpc.Invoice_OPEN, IsAmp: true, ValueMsat: 200_000,
+				AmpInvoiceState: map[string]*lnrpc.AMPInvoiceState{
+					"setID": {State: lnrpc.InvoiceHTLCState_ACCEPTED, AmtPaidMsat: 100_000},
+				},
+			},
+			{
+				RHash: rHash, State: lnrpc.Invoice_OPEN, IsAmp: true, ValueMsat: 200_000,
+				AmpInvoiceState: map[string]*lnrpc.AMPInvoiceState{
+					"setID": {State: lnrpc.InvoiceHTLCState_SETTLED, AmtPaidMsat: 100_000},
+				},
+			},
+		},
+	}
+	s.lndMock.On("SubscribeInvoices", ctx, uint64(0)).Return(stream, nil)
+
+	s.sse.TrackPayment(hex.EncodeToString(rHash), "publicKey", 200)
+	s.sse.subscribeInvoices(ctx)
+
+	_, ok := s.sse.trackedPayments.Get(hex.EncodeToString(rHash))
+	s.True(ok)
+	s.invoicesMock.AssertNotCalled(s.T(), "Settle", mock.Anything, mock.Anything, mock.Anything)
+	s.betsMock.AssertNotCalled(s.T(), "Add", mock.Anything, mock.Anything)
+}
+
+func (s *SSESuite) setupPeerCap(maxAmount uint64) *db.ExposureStoreMock {
+	exposureMock := db.NewExposureStoreMock()
+	peerCapConfig := config.PeerCap{Enabled: true, MaxAmount: maxAmount}
+	s.sse.peerCap = policy.NewPeerCap(peerCapConfig, &db.DB{Exposure: exposureMock}, s.lndMock)
+
+	channels := []*lnrpc.Channel{{ChanId: 1, RemotePubkey: "peer"}}
+	s.lndMock.On("ListChannels", mock.Anything).Return(channels, nil)
+	exposureMock.On("Delete", mock.Anything).Return(nil).Maybe()
+	return exposureMock
+}
+
+func (s *SSESuite) TestSettleHoldInvoice() {
+	ctx := context.Background()
+	hash := []byte("hash")
+	rHash := hex.EncodeToString(hash)
+	preimage := []byte("preimage")
+	height := uint32(144)
+	htlcs := []*lnrpc.InvoiceHTLC{{ChanId: 1, AmtMsat: 2_000_000, State: lnrpc.InvoiceHTLCState_ACCEPTED}}
+
+	exposureMock := s.setupPeerCap(10_000)
+	exposureMock.On("List", height).Return(map[string]uint64{"peer": 8_000}, nil)
+	exposureMock.On("Add", height, rHash, map[string]uint64{"peer": 2_000}).Return(nil)
+
+	stream := &customEventsStreamMock[*lnrpc.Invoice]{
+		events: []*lnrpc.Invoice{
+			{RHash: hash, State: lnrpc.Invoice_OPEN},
+			{RHash: hash, State: lnrpc.Invoice_ACCEPTED, Htlcs: htlcs},
+		},
+	}
+	s.lndMock.On("SubscribeSingleInvoice", ctx, hash).Return(stream, nil)
+	s.lndMock.On("SettleInvoice", ctx, preimage).Return(nil)
+	s.lotteriesMock.On("GetNextHeight").Return(height, nil)
+
+	s.sse.TrackPayment(rHash, "publicKey", 2_000)
+	s.sse.settleHoldInvoice(ctx, rHash, preimage)
+
+	s.lndMock.AssertCalled(s.T(), "SettleInvoice", ctx, preimage)
+	exposureMock.AssertExpectations(s.T())
+
+	// The bet is registered once the invoice is settled
+	_, ok := s.sse.trackedPayments.Get(rHash)
+	s.True(ok)
+}
+
+func (s *SSESuite) TestResumeHoldInvoices() {
+	ctx := context.Background()
+	hash := []byte("hash")
+	rHash := hex.EncodeToString(hash)
+	preimage := []byte("preimage")
+	height := uint32(144)
+	htlcs := []*lnrpc.InvoiceHTLC{{ChanId: 1, AmtMsat: 2_000_000, State: lnrpc.InvoiceHTLCState_ACCEPTED}}
+
+	exposureMock := s.setupPeerCap(10_000)
+	exposureMock.On("List", height).Return(map[string]uint64{}, nil)
+	exposureMock.On("Add", height, rHash, map[string]uint64{"peer": 2_000}).Return(nil)
+
+	held := []db.Invoice{
+		{PaymentHash: rHash, Preimage: hex.EncodeToString(preimage)},
+		// Invoices watched already are skipped
+		{PaymentHash: "tracked", Preimage: hex.EncodeToString([]byte("preimage2"))},
+	}
+	s.invoicesMock.On("ListHeld", mock.Anything).Return(held, nil)
+	s.sse.TrackPayment("tracked", "publicKey", 1_000)
+
+	stream := &customEventsStreamMock[*lnrpc.Invoice]{
+		events: []*lnrpc.Invoice{{RHash: hash, State: lnrpc.Invoice_ACCEPTED, Htlcs: htlcs}},
+	}
+	settled := make(chan struct{})
+	s.lndMock.On("SubscribeSingleInvoice", ctx, hash).Return(stream, nil)
+	s.lndMock.On("SettleInvoice", ctx, preimage).Return(nil).Run(func(mock.Arguments) {
+		close(settled)
+	})
+	s.lotteriesMock.On("GetNextHeight").Return(height, nil)
+
+	s.sse.resumeHoldInvoices(ctx)
+
+	select {
+	case <-settled:
+	case <-time.After(time.Second):
+		s.Fail("hold invoice not settled")
+	}
+	// A reservation left by a previous run is dropped before reserving again
+	exposureMock.AssertCalled(s.T(), "Delete", rHash)
+	s.lndMock.AssertNumberOfCalls(s.T(), "SubscribeSingleInvoice", 1)
+}
+
+func (s *SSESuite) TestSettleHoldInvoicePeerCapExceeded() {
+	ctx := context.Background()
+	hash := []byte("hash")
+	rHash := hex.EncodeToString(hash)
+	height := uint32(144)
+	htlcs := []*lnrpc.InvoiceHTLC{{ChanId: 1, AmtMsat: 2_001_000, State: lnrpc.InvoiceHTLCState_ACCEPTED}}
+
+	exposureMock := s.setupPeerCap(10_000)
+	exposureMock.On("List", height).Return(map[string]uint64{"peer": 8_000}, nil)
+
+	stream := &customEventsStreamMock[*lnrpc.Invoice]{
+		events: []*lnrpc.Invoice{{RHash: hash, State: lnrpc.Invoice_ACCEPTED, Htlcs: htlcs}},
+	}
+	s.lndMock.On("SubscribeSingleInvoice", ctx, hash).Return(stream, nil)
+	s.lndMock.On("CancelInvoice", ctx, hash).Return(nil)
+	s.lotteriesMock.On("GetNextHeight").Return(height, nil)
+
+	id := s.sse.TrackPayment(rHash, "publicKey", 2_001)
+	payload := &invoicesPayload{
+		PaymentID: id,
+		PublicKey: "publicKey",
+		Amount:    2_001,
+		Error:     "bet declined, the channel used to pay reached its limit for this lottery",
+		Status:    failed,
+	}
+	data, err := json.Marshal(payload)
+	s.NoError(err)
+	s.server.On("Publish", streamID, &sse.Event{Event: invoicesEvent, Data: data})
+
+	s.sse.settleHoldInvoice(ctx, rHash, []byte("preimage"))
+
+	s.lndMock.AssertCalled(s.T(), "CancelInvoice", ctx, hash)
+	s.lndMock.AssertNotCalled(s.T(), "SettleInvoice", mock.Anything, mock.Anything)
+	s.server.AssertExpectations(s.T())
+	exposureMock.AssertNotCalled(s.T(), "Add", mock.Anything, mock.Anything, mock.Anything)
+
+	_, ok := s.sse.trackedPayments.Get(rHash)
+	s.False(ok)
 }
 
 func (s *SSESuite) TestSubscribePayments() {
@@ -307,7 +800,10 @@ func (s *SSESuite) TestSubscribePayments() {
 
 	stream := &customEventsStreamMock[*lnrpc.Payment]{
 		events: []*lnrpc.Payment{{
-			PaymentHash: rHash, Status: lnrpc.Payment_SUCCEEDED,
+			PaymentHash:     rHash,
+			Status:          lnrpc.Payment_SUCCEEDED,
+			FeeSat:          3,
+			PaymentPreimage: "preimage",
 		}},
 	}
 
@@ -319,8 +815,33 @@ func (s *SSESuite) TestSubscribePayments() {
 	event := &sse.Event{Event: paymentsEvent, Data: data}
 	s.server.On("Publish", streamID, event)
 	s.lndMock.On("SubscribePayments", ctx).Return(stream, nil)
+	s.auditorMock.On("Record", audit.PayoutSent, map[string]any{
+		"public_key":   publicKey,
+		"amount":       amount,
+		"payment_hash": rHash,
+	})
+	s.webhooksMock.On("Publish", webhooks.PayoutSent, map[string]any{
+		"amount":       amount,
+		"payment_hash": rHash,
+	})
+	s.statsMock.On("AddPayout", amount).Return(nil)
+	payout := db.Payout{
+		PublicKey:   publicKey,
+		PaymentHash: rHash,
+		Amount:      amount,
+		Status:      db.PayoutSucceeded,
+		Fee:         3,
+		Preimage:    "preimage",
+	}
+	s.payoutsMock.On("Update", matchPayout(payout)).Return(true, nil)
+	s.notifierMock.On("NotifyPayout", matchPayout(payout))
 
 	s.sse.subscribePayments(ctx)
+
+	s.auditorMock.AssertExpectations(s.T())
+	s.webhooksMock.AssertExpectations(s.T())
+	s.statsMock.AssertExpectations(s.T())
+	s.notifierMock.AssertExpectations(s.T())
 }
 
 func (s *SSESuite) TestSubscribePaymentsFailed() {
@@ -354,8 +875,123 @@ func (s *SSESuite) TestSubscribePaymentsFailed() {
 	event := &sse.Event{Event: paymentsEvent, Data: data}
 	s.server.On("Publish", streamID, event)
 	s.lndMock.On("SubscribePayments", ctx).Return(stream, nil)
+	payout := db.Payout{
+		PublicKey:     publicKey,
+		PaymentHash:   rHash,
+		Amount:        amount,
+		Status:        db.PayoutFailed,
+		FailureReason: payment.FailureReason.String(),
+	}
+	s.payoutsMock.On("Update", matchPayout(payout)).Return(true, nil)
+	s.notifierMock.On("NotifyPayout", matchPayout(payout))
 
 	s.sse.subscribePayments(ctx)
+
+	s.notifierMock.AssertExpectations(s.T())
+}
+
+func (s *SSESuite) TestWatchPayment() {
+	rHash := "rHash"
+	publicKey := "publicKey"
+	claims := []db.PrizesRow{{RowID: 1, Amount: 2016}}
+
+	stream := &customEventsStreamMock[*lnrpc.Payment]{
+		events: []*lnrpc.Payment{
+			{PaymentHash: rHash, Status: lnrpc.Payment_IN_FLIGHT},
+			{PaymentHash: rHash, Status: lnrpc.Payment_IN_FLIGHT},
+			{PaymentHash: rHash, Status: lnrpc.Payment_SUCCEEDED, PaymentPreimage: "preimage"},
+		},
+	}
+
+	s.payoutsMock.On("Add", matchPayout(db.Payout{
+		PublicKey:   publicKey,
+		PaymentHash: rHash,
+		Amount:      2016,
+		Status:      db.PayoutPending,
+	})).Return(uint64(1), nil)
+	id := s.sse.TrackWithdrawal(rHash, publicKey, claims)
+
+	// Only the first attempt moves the payout in flight
+	inFlight := db.Payout{PaymentHash: rHash, Status: db.PayoutInFlight}
+	s.payoutsMock.On("Update", matchPayout(inFlight)).Return(true, nil).Once()
+	s.payoutsMock.On("Update", matchPayout(inFlight)).Return(false, nil).Once()
+	payout := db.Payout{
+		PublicKey:   publicKey,
+		PaymentHash: rHash,
+		Amount:      2016,
+		Status:      db.PayoutSucceeded,
+		Preimage:    "preimage",
+	}
+	s.payoutsMock.On("Update", matchPayout(payout)).Return(true, nil)
+	s.notifierMock.On("NotifyPayout", matchPayout(payout))
+	s.auditorMock.On("Record", audit.PayoutSent, mock.Anything)
+	s.webhooksMock.On("Publish", webhooks.PayoutSent, mock.Anything)
+	s.statsMock.On("AddPayout", uint64(2016)).Return(nil)
+
+	data, err := json.Marshal(&paymentsPayload{PaymentID: id, Status: success})
+	s.NoError(err)
+	s.server.On("Publish", streamID, &sse.Event{Event: paymentsEvent, Data: data})
+
+	s.sse.watchPayment(rHash, stream)
+
+	// The stream is not read after the payment completes
+	s.Equal(3, stream.counter)
+	s.payoutsMock.AssertExpectations(s.T())
+	s.notifierMock.AssertExpectations(s.T())
+	s.server.AssertExpectations(s.T())
+	s.Zero(s.sse.trackedPayments.Count())
+}
+
+func (s *SSESuite) TestWatchPaymentRejected() {
+	rHash := "rHash"
+	publicKey := "publicKey"
+	claims := []db.PrizesRow{{RowID: 1, Amount: 2016}}
+	rejectErr := grpcstatus.Error(codes.InvalidArgument, "invoice expired")
+
+	s.payoutsMock.On("Add", mock.Anything).Return(uint64(1), nil)
+	id := s.sse.TrackWithdrawal(rHash, publicKey, claims)
+
+	s.prizesMock.On("Restore", claims).Return(nil)
+	payout := db.Payout{
+		PublicKey:     publicKey,
+		PaymentHash:   rHash,
+		Amount:        2016,
+		Status:        db.PayoutFailed,
+		FailureReason: rejectErr.Error(),
+	}
+	s.payoutsMock.On("Update", matchPayout(payout)).Return(true, nil)
+	s.notifierMock.On("NotifyPayout", matchPayout(payout))
+
+	data, err := json.Marshal(&paymentsPayload{PaymentID: id, Status: failed, Error: rejectErr.Error()})
+	s.NoError(err)
+	s.server.On("Publish", streamID, &sse.Event{Event: paymentsEvent, Data: data})
+
+	stream := &customEventsStreamMock[*lnrpc.Payment]{err: rejectErr}
+
+	s.sse.watchPayment(rHash, stream)
+
+	s.prizesMock.AssertExpectations(s.T())
+	s.notifierMock.AssertExpectations(s.T())
+	s.Zero(s.sse.trackedPayments.Count())
+}
+
+func (s *SSESuite) TestWatchPaymentBroken() {
+	rHash := "rHash"
+	claims := []db.PrizesRow{{RowID: 1, Amount: 2016}}
+
+	s.payoutsMock.On("Add", mock.Anything).Return(uint64(1), nil)
+	s.sse.TrackWithdrawal(rHash, "publicKey", claims)
+
+	stream := &customEventsStreamMock[*lnrpc.Payment]{
+		err: grpcstatus.Error(codes.Unavailable, "connection reset"),
+	}
+
+	s.sse.watchPayment(rHash, stream)
+
+	// The payments subscription completes the withdrawal
+	s.prizesMock.AssertNotCalled(s.T(), "Restore", mock.Anything)
+	_, ok := s.sse.trackedPayments.Get(rHash)
+	s.True(ok)
 }
 
 func (s *SSESuite) TestSubscribePaymentsUntracked() {
@@ -378,17 +1014,21 @@ func (s *SSESuite) TestSubscribeWinners() {
 	prizePool := uint64(25000)
 	nextHeight := uint32(1)
 	winners := []db.Winner{{PublicKey: "winner", Prize: 10, Ticket: 1}}
+	anonymized := []db.Winner{{Prize: 10, Ticket: 1}}
 
 	s.lndMock.On("RemoteBalance", ctx).Return(remoteBalance, nil)
 	s.lotteriesMock.On("GetNextHeight").Return(nextHeight, nil)
-	s.betsMock.On("GetPrizePool", nextHeight).Return(prizePool, nil)
+	s.postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+	s.betsMock.On("GetPrizePool", nextHeight, "").Return(prizePool, nil)
+	s.privacyMock.On("List", []string{"winner"}).
+		Return(map[string]db.Privacy{"winner": {Display: db.DisplayHidden}}, nil)
 
 	pp := int64(prizePool)
-	capacity := remoteBalance / lottery.CapacityDivisor
+	capacity := remoteBalance / lottery.DefaultCapacityDivisor
 	payload := &infoPayload{
 		PrizePool:  &pp,
 		Capacity:   &capacity,
-		Winners:    &winners,
+		Winners:    &anonymized,
 		NextHeight: &nextHeight,
 	}
 
@@ -399,7 +1039,7 @@ func (s *SSESuite) TestSubscribeWinners() {
 	s.server.On("Publish", streamID, event)
 
 	go func() {
-		s.winnersCh <- winners
+		s.winnersHub.Publish(winners)
 
 		// Force subscribeWinners infinite loop to exit
 		cancel()
@@ -429,36 +1069,207 @@ func (s *SSESuite) TestAddBet() {
 		amount:    100,
 	}
 	s.sse.trackedPayments.Set(rHash, entry)
+	bonus := config.Bonus{
+		Bundles:  []config.Bundle{{MinAmount: 100, Percentage: 10}},
+		RoundCap: 1000,
+	}
+	s.sse.settings = s.betSettings(bonus, lottery.NewPools(nil))
 
 	bet := db.Bet{
+		PublicKey:   entry.publicKey,
+		Tickets:     entry.amount,
+		Bonus:       10,
+		PaymentHash: rHash,
+	}
+	stored := db.Bet{
 		PublicKey: entry.publicKey,
-		Tickets:   entry.amount,
+		Index:     110,
+		Tickets:   110,
+		Bonus:     10,
 	}
-	s.betsMock.On("Add", bet).Return(nil)
-
-	s.sse.addBet(rHash, entry)
+	s.betsMock.On("Add", matchBet(bet), bonus.RoundCap, defaultFeeRate).Return(stored, nil)
+	s.auditorMock.On("Record", audit.BetAccepted, map[string]any{
+		"public_key":    entry.publicKey,
+		"tickets":       entry.amount,
+		"bonus_tickets": stored.Bonus,
+		"payment_hash":  rHash,
+		"pool":          "",
+	})
+
+	actual, err := s.sse.addBet(rHash, entry)
+	s.NoError(err)
+	s.Equal(stored, actual)
 
 	count := s.sse.trackedPayments.Count()
 	s.Zero(count)
+	s.auditorMock.AssertExpectations(s.T())
 }
 
-func (s *SSESuite) TestRestoreFunds() {
+func (s *SSESuite) TestAddBetCoinAge() {
+	rHash := "rHash"
+	entry := entry{
+		publicKey: "publicKey",
+		amount:    1_000,
+	}
+	bonus := config.Bonus{
+		CoinAge:  []config.CoinAge{{MinBlocksLeft: 100, Percentage: 5}},
+		RoundCap: 1000,
+	}
+	s.sse.settings = s.betSettings(bonus, lottery.NewPools(nil))
+	s.lotteriesMock.On("GetNextHeight").Return(uint32(840_144), nil)
+	s.lndMock.On("GetInfo", context.Background()).Return(&lnrpc.GetInfoResponse{BlockHeight: 840_000}, nil)
+
+	bet := db.Bet{
+		PublicKey:   entry.publicKey,
+		Tickets:     entry.amount,
+		Bonus:       50,
+		PaymentHash: rHash,
+	}
+	stored := db.Bet{PublicKey: entry.publicKey, Index: 1_050, Tickets: 1_050, Bonus: 50}
+	s.betsMock.On("Add", matchBet(bet), bonus.RoundCap, defaultFeeRate).Return(stored, nil)
+	s.auditorMock.On("Record", audit.BetAccepted, mock.Anything)
+
+	actual, err := s.sse.addBet(rHash, entry)
+	s.NoError(err)
+	s.Equal(stored, actual)
+}
+
+func (s *SSESuite) TestAddBetCoinAgeFunding() {
+	rHash := "rHash"
+	entry := entry{
+		publicKey: "publicKey",
+		amount:    1_000,
+	}
+	bonus := config.Bonus{
+		CoinAge:  []config.CoinAge{{MinBlocksLeft: 100, Percentage: 5}},
+		RoundCap: 1000,
+	}
+	pools := lottery.NewPools([]config.Pool{{Name: "small", Distribution: []float64{80, 10}}})
+	s.sse.settings = s.betSettings(bonus, pools)
+	s.sse.lastTicket = config.LastTicket{Tickets: 100, Percentage: 4}
+	s.lotteriesMock.On("GetNextHeight").Return(uint32(840_144), nil)
+	s.lndMock.On("GetInfo", context.Background()).Return(&lnrpc.GetInfoResponse{BlockHeight: 840_000}, nil)
+
+	// The coin-age tickets are funded by the fee of the pool left after the last ticket bonus, the
+	// store reduces them to what it covers
+	bet := db.Bet{
+		PublicKey:   entry.publicKey,
+		Tickets:     entry.amount,
+		Bonus:       50,
+		Pool:        "small",
+		PaymentHash: rHash,
+	}
+	stored := db.Bet{PublicKey: entry.publicKey, Pool: "small", Index: 1_006, Tickets: 1_006, Bonus: 6}
+	s.betsMock.On("Add", matchBet(bet), bonus.RoundCap, float64(6)).Return(stored, nil)
+	s.auditorMock.On("Record", audit.BetAccepted, mock.Anything)
+
+	actual, err := s.sse.addBet(rHash, entry)
+	s.NoError(err)
+	s.Equal(stored, actual)
+	s.betsMock.AssertExpectations(s.T())
+}
+
+func (s *SSESuite) TestAddBetHouse() {
+	rHash := "rHash"
+	entry := entry{
+		publicKey: keysendPublicKey,
+		amount:    100,
+	}
+	s.sse.housePlay = policy.NewHousePlay(config.HousePlay{
+		PublicKeys: []string{keysendPublicKey},
+		Allowed:    true,
+	})
+
+	bet := db.Bet{
+		PublicKey:   entry.publicKey,
+		Tickets:     entry.amount,
+		PaymentHash: rHash,
+		House:       true,
+	}
+	stored := db.Bet{PublicKey: entry.publicKey, Index: 100, Tickets: 100, House: true}
+	s.betsMock.On("Add", matchBet(bet), uint64(0), defaultFeeRate).Return(stored, nil)
+	s.auditorMock.On("Record", audit.BetAccepted, mock.Anything)
+
+	actual, err := s.sse.addBet(rHash, entry)
+	s.NoError(err)
+	s.Equal(stored, actual)
+}
+
+func (s *SSESuite) TestSignReceipt() {
+	rHash := "rHash"
+	bet := db.Bet{PublicKey: "publicKey", Index: 100, Tickets: 100}
+
+	s.Run("Failed bet", func() {
+		s.Nil(s.sse.signReceipt(db.Bet{}, rHash))
+		s.auditorMock.AssertNotCalled(s.T(), "SignReceipt", mock.Anything, mock.Anything)
+	})
+
+	s.Run("Signing disabled", func() {
+		s.auditorMock.On("SignReceipt", bet, rHash).Return(audit.Receipt{}, audit.ErrSigningDisabled).Once()
+		s.Nil(s.sse.signReceipt(bet, rHash))
+	})
+
+	s.Run("Signed", func() {
+		receipt := audit.Receipt{PublicKey: bet.PublicKey, Signature: "signature"}
+		s.auditorMock.On("SignReceipt", bet, rHash).Return(receipt, nil).Once()
+		s.receiptsMock.On("Add", receipt).Return(nil).Once()
+		s.Equal(&receipt, s.sse.signReceipt(bet, rHash))
+		s.receiptsMock.AssertExpectations(s.T())
+	})
+}
+
+func (s *SSESuite) TestAddBetError() {
 	rHash := "hj432kl2ñ"
 	entry := entry{
 		publicKey: "publicKey",
 		amount:    100,
 	}
-	s.sse.trackedPayments.Set(rHash, entry)
 
-	s.prizesMock.On("Get", entry.publicKey).Return(uint64(0), nil)
-	nextHeight := uint32(1)
-	s.lotteriesMock.On("GetNextHeight").Return(nextHeight, nil)
+	bet := db.Bet{
+		PublicKey:   entry.publicKey,
+		Tickets:     entry.amount,
+		PaymentHash: rHash,
+	}
+	s.betsMock.On("Add", matchBet(bet), uint64(0), defaultFeeRate).Return(db.Bet{}, errors.New("test"))
 
-	winner := db.Winner{PublicKey: entry.publicKey, Prize: entry.amount}
-	s.winnersMock.On("Add", nextHeight, []db.Winner{winner}).Return(nil)
+	_, err := s.sse.addBet(rHash, entry)
+	s.Error(err)
+
+	s.auditorMock.AssertNotCalled(s.T(), "Record", audit.BetAccepted, mock.Anything)
+}
+
+func (s *SSESuite) TestRestoreFunds() {
+	rHash := "hj432kl2ñ"
+	claims := []db.PrizesRow{{RowID: 1, Amount: 60}, {RowID: 2, Amount: 40}}
+	s.payoutsMock.On("Add", mock.Anything).Return(uint64(1), nil)
+	s.sse.TrackWithdrawal(rHash, "publicKey", claims)
+
+	entry, ok := s.sse.trackedPayments.Get(rHash)
+	s.True(ok)
+	s.Equal(uint64(100), entry.amount)
+
+	s.prizesMock.On("Restore", claims).Return(nil)
 
 	s.sse.restoreFunds(rHash, entry)
 
 	count := s.sse.trackedPayments.Count()
 	s.Zero(count)
+	s.prizesMock.AssertExpectations(s.T())
+}
+
+// matchBet matches the bet ignoring the time it was placed at.
+func matchBet(bet db.Bet) any {
+	return mock.MatchedBy(func(b db.Bet) bool {
+		b.CreatedAt = 0
+		return b == bet
+	})
+}
+
+// matchPayout matches the payout ignoring the time it was created and updated at.
+func matchPayout(payout db.Payout) any {
+	return mock.MatchedBy(func(p db.Payout) bool {
+		p.CreatedAt = 0
+		p.UpdatedAt = 0
+		return p == payout
+	})
 }
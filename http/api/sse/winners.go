@@ -0,0 +1,177 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/logger"
+	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	winnersEvent = "winners"
+	// lastEventIDHeader is sent by the clients reconnecting to the stream
+	lastEventIDHeader = "Last-Event-ID"
+	// winnersPageSize is the number of draws read from the database at a time
+	winnersPageSize = 50
+)
+
+// winnersPayload is the data of a winners event, whose ID is the lottery height.
+type winnersPayload struct {
+	Winners       []db.Winner `json:"winners"`
+	LotteryHeight uint32      `json:"lottery_height"`
+}
+
+type winnersStream struct {
+	db         *db.DB
+	winnersHub *lottery.WinnersHub
+	logger     *logger.Logger
+	deadline   time.Duration
+}
+
+// NewWinnersStream returns a handler streaming the winners of every lottery drawn, for the clients
+// that can't hold a websocket.
+//
+// Each event ID is the lottery height, clients reconnecting with the Last-Event-ID header (or the
+// last_event_id query parameter) receive the draws they missed from the database first.
+func NewWinnersStream(config config.SSE, db *db.DB, winnersHub *lottery.WinnersHub) (http.Handler, error) {
+	logger, err := logger.New(config.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &winnersStream{
+		db:         db,
+		winnersHub: winnersHub,
+		logger:     logger,
+		deadline:   config.Deadline,
+	}, nil
+}
+
+func (ws *winnersStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lastEventID := r.Header.Get(lastEventIDHeader)
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	var lastHeight uint32
+	if lastEventID != "" {
+		height, err := strconv.ParseUint(lastEventID, 10, 32)
+		if err != nil {
+			http.Error(w, "invalid last event ID", http.StatusBadRequest)
+			return
+		}
+		lastHeight = uint32(height)
+	} else {
+		// Without an ID, only the lotteries drawn from now on are streamed
+		height, err := ws.db.Lotteries.GetNextHeight()
+		if err != nil {
+			http.Error(w, "getting next lottery height", http.StatusInternalServerError)
+			ws.logger.Error(errors.Wrap(err, "getting next lottery height"))
+			return
+		}
+		lastHeight = height - 1
+	}
+
+	ctx := r.Context()
+	if ws.deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ws.deadline)
+		defer cancel()
+	}
+
+	// Subscribe before reading the database so no draw is missed in between. The batches published
+	// are only used as a signal, the winners are always read from the database
+	subscription := ws.winnersHub.Subscribe()
+	defer subscription.Close()
+
+	// Overwrite server.WriteTimeout to avoid a short timeout, a zero deadline removes it
+	rc := http.NewResponseController(w)
+	var writeDeadline time.Time
+	if ws.deadline > 0 {
+		writeDeadline = time.Now().UTC().Add(ws.deadline)
+	}
+	rc.SetWriteDeadline(writeDeadline)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	for {
+		height, err := ws.sendDraws(w, lastHeight)
+		if err != nil {
+			ws.logger.Error(err)
+			return
+		}
+		lastHeight = height
+		rc.Flush()
+
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-subscription.C():
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// sendDraws writes the winners of the lotteries drawn after the height specified, up to the first
+// one whose winners are still being revealed, and returns the height of the last one sent.
+func (ws *winnersStream) sendDraws(w http.ResponseWriter, lastHeight uint32) (uint32, error) {
+	// The primary is read as the replicas may not have the draw that was just published yet
+	for {
+		heights, err := ws.db.Winners.ListHeightsAfter(lastHeight, winnersPageSize)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, height := range heights {
+			// The draws being revealed are sent once all their tiers are
+			pending, err := lottery.RevealPending(ws.db.Reveals, height)
+			if err != nil {
+				return 0, err
+			}
+			if pending {
+				return lastHeight, nil
+			}
+
+			winners, err := ws.db.Winners.List(height)
+			if err != nil {
+				return 0, err
+			}
+
+			winners, err = policy.AnonymizeWinners(ws.db.Privacy, winners)
+			if err != nil {
+				return 0, errors.Wrap(err, "anonymizing winners")
+			}
+
+			data, err := json.Marshal(winnersPayload{LotteryHeight: height, Winners: winners})
+			if err != nil {
+				return 0, errors.Wrap(err, "encoding winners")
+			}
+
+			_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", height, winnersEvent, data)
+			if err != nil {
+				return 0, errors.Wrap(err, "writing winners event")
+			}
+			lastHeight = height
+		}
+
+		if len(heights) < winnersPageSize {
+			return lastHeight, nil
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/logger"
+	"github.com/aftermath2/BTRY/lottery"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestWinnersStream(t *testing.T, database *db.DB, winnersHub *lottery.WinnersHub) http.Handler {
+	t.Helper()
+	handler, err := NewWinnersStream(
+		config.SSE{Logger: config.Logger{Level: uint8(logger.DISABLED)}},
+		database,
+		winnersHub,
+	)
+	assert.NoError(t, err)
+	return handler
+}
+
+func readEvent(t *testing.T, reader *bufio.Reader) string {
+	t.Helper()
+	var event string
+	for {
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		if line == "\n" {
+			return event
+		}
+		event += line
+	}
+}
+
+func TestWinnersStreamResume(t *testing.T) {
+	winnersHub := lottery.NewWinnersHub(config.WinnersHub{})
+	winnersMock := db.NewWinnersStoreMock()
+	privacyMock := db.NewPrivacyStoreMock()
+	revealsMock := db.NewRevealsStoreMock()
+	database := &db.DB{Privacy: privacyMock, Reveals: revealsMock, Winners: winnersMock}
+	handler := newTestWinnersStream(t, database, winnersHub)
+
+	// The draw at 288 was missed while disconnected, 432 is published afterwards
+	winnersMock.On("ListHeightsAfter", uint32(144), uint64(winnersPageSize)).Return([]uint32{288}, nil)
+	winnersMock.On("List", uint32(288)).Return([]db.Winner{{PublicKey: "pubkey", Prize: 500, Ticket: 7}}, nil)
+	winnersMock.On("ListHeightsAfter", uint32(288), uint64(winnersPageSize)).Return([]uint32{432}, nil)
+	winnersMock.On("List", uint32(432)).Return([]db.Winner{{PublicKey: "pubkey2", Prize: 200, Ticket: 3}}, nil)
+	winnersMock.On("ListHeightsAfter", uint32(432), uint64(winnersPageSize)).Return([]uint32(nil), nil)
+	revealsMock.On("Get", mock.Anything).Return(db.Reveal{}, db.ErrRevealNotFound)
+	privacyMock.On("List", []string{"pubkey"}).
+		Return(map[string]db.Privacy{"pubkey": {Display: db.DisplayAlias, Alias: "lucky"}}, nil)
+	privacyMock.On("List", []string{"pubkey2"}).Return(map[string]db.Privacy{}, nil)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	assert.NoError(t, err)
+	req.Header.Set(lastEventIDHeader, "144")
+
+	res, err := server.Client().Do(req)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(res.Body)
+	expected := "id: 288\nevent: winners\n" +
+		`data: {"winners":[{"prize":500,"ticket":7,"alias":"lucky"}],"lottery_height":288}` + "\n"
+	assert.Equal(t, expected, readEvent(t, reader))
+
+	// Published batches only signal that new winners were stored
+	winnersHub.Publish(nil)
+
+	expected = "id: 432\nevent: winners\n" +
+		`data: {"winners":[{"public_key":"pubkey2","prize":200,"ticket":3}],"lottery_height":432}` + "\n"
+	assert.Equal(t, expected, readEvent(t, reader))
+}
+
+func TestWinnersStreamNew(t *testing.T) {
+	winnersMock := db.NewWinnersStoreMock()
+	lotteriesMock := db.NewLotteriesStoreMock()
+	handler := newTestWinnersStream(t, &db.DB{Winners: winnersMock, Lotteries: lotteriesMock},
+		lottery.NewWinnersHub(config.WinnersHub{}))
+
+	// Clients without an event ID receive only the lotteries drawn after connecting
+	lotteriesMock.On("GetNextHeight").Return(uint32(576), nil)
+	winnersMock.On("ListHeightsAfter", uint32(575), uint64(winnersPageSize)).Return([]uint32(nil), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/winners/stream", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	winnersMock.AssertExpectations(t)
+	winnersMock.AssertNotCalled(t, "List", mock.Anything)
+}
+
+func TestWinnersStreamQueryID(t *testing.T) {
+	winnersMock := db.NewWinnersStoreMock()
+	handler := newTestWinnersStream(t, &db.DB{Winners: winnersMock}, lottery.NewWinnersHub(config.WinnersHub{}))
+
+	winnersMock.On("ListHeightsAfter", uint32(288), uint64(winnersPageSize)).Return([]uint32(nil), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/winners/stream?last_event_id=288", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	winnersMock.AssertExpectations(t)
+}
+
+func TestWinnersStreamInvalidID(t *testing.T) {
+	handler := newTestWinnersStream(t, &db.DB{}, lottery.NewWinnersHub(config.WinnersHub{}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/winners/stream", nil)
+	req.Header.Set(lastEventIDHeader, "height")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	body, err := io.ReadAll(rec.Body)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "invalid last event ID")
+}
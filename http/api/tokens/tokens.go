@@ -0,0 +1,212 @@
+// Package tokens issues and validates the claim widget tokens, JSON Web Tokens signed with
+// HMAC-SHA256 that let third-party sites start the claim flow of a winner without holding API
+// credentials nor the winner's keys.
+package tokens
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultTTL = 10 * time.Minute
+	issuer     = "btry"
+	// scope limits the tokens to the claim flow
+	scope = "claim"
+	// ConfirmationDomain separates the withdrawal confirmations from other messages signed by the
+	// winners
+	ConfirmationDomain = "btry-widget-claim-v1"
+)
+
+var (
+	// ErrDisabled is returned when the claim widget secret is not configured.
+	ErrDisabled = errors.New("claim widget disabled")
+	// ErrInvalidToken is returned when a token is malformed, was not signed by the server, expired
+	// or is used from a site other than the one it was issued for.
+	ErrInvalidToken = errors.New("invalid or expired claim token")
+	// ErrInvalidConfirmation is returned when the winner didn't sign the withdrawal of the token.
+	ErrInvalidConfirmation = errors.New("invalid withdrawal confirmation")
+
+	// header is the encoded header of every token, the only algorithm accepted is HS256
+	header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+)
+
+// Claims contains the claims of a token. The subject is the public key of the winner, the audience
+// the origin of the site it was issued for and the ID tells the tokens apart, so each one is used
+// once.
+type Claims struct {
+	ID        string `json:"jti"`
+	Subject   string `json:"sub"`
+	Audience  string `json:"aud"`
+	Issuer    string `json:"iss"`
+	Scope     string `json:"scope"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Token is a signed claim token.
+type Token struct {
+	// ID is signed by the winner, along with the invoices, to confirm the withdrawal
+	ID        string `json:"id"`
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// Service issues and validates claim tokens.
+type Service struct {
+	now    func() time.Time
+	secret []byte
+	ttl    time.Duration
+}
+
+// New returns a new claim tokens service.
+func New(config config.ClaimWidget) (*Service, error) {
+	secret, err := hex.DecodeString(config.Secret)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding claim widget secret")
+	}
+
+	ttl := config.TTL
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+
+	return &Service{
+		now:    time.Now,
+		secret: secret,
+		ttl:    ttl,
+	}, nil
+}
+
+// Enabled returns whether claim tokens are issued.
+func (s *Service) Enabled() bool {
+	return len(s.secret) > 0
+}
+
+// Issue returns a token that gives access to the prizes of the public key until it expires. The
+// token is only accepted in requests coming from the origin specified.
+func (s *Service) Issue(publicKey, origin string) (Token, error) {
+	if !s.Enabled() {
+		return Token{}, ErrDisabled
+	}
+
+	if origin == "" {
+		return Token{}, errors.New("origin is required")
+	}
+	if err := validateOrigin(origin); err != nil {
+		return Token{}, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Token{}, errors.Wrap(err, "generating token ID")
+	}
+
+	now := s.now()
+	claims := Claims{
+		ID:        hex.EncodeToString(id),
+		Subject:   publicKey,
+		Audience:  origin,
+		Issuer:    issuer,
+		Scope:     scope,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.ttl).Unix(),
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return Token{}, errors.Wrap(err, "encoding claims")
+	}
+
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	token := unsigned + "." + base64.RawURLEncoding.EncodeToString(s.sign(unsigned))
+
+	return Token{ID: claims.ID, Token: token, ExpiresAt: claims.ExpiresAt}, nil
+}
+
+// Validate returns the claims of the token if it's valid for a request from the origin specified.
+func (s *Service) Validate(token, origin string) (Claims, error) {
+	if !s.Enabled() {
+		return Claims{}, ErrDisabled
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != header {
+		return Claims{}, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, s.sign(parts[0]+"."+parts[1])) {
+		return Claims{}, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	if claims.Issuer != issuer || claims.Scope != scope || s.now().Unix() >= claims.ExpiresAt {
+		return Claims{}, ErrInvalidToken
+	}
+
+	// Tokens issued before the audience and the ID were required are rejected
+	if claims.ID == "" || claims.Audience == "" || claims.Audience != origin {
+		return Claims{}, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// ConfirmationMessage returns the message the winner signs to confirm the withdrawal of the token
+// prizes to the invoices: the token ID followed by the invoices, separated by line feeds.
+func ConfirmationMessage(id string, paymentRequests []string) []byte {
+	return []byte(strings.Join(append([]string{id}, paymentRequests...), "\n"))
+}
+
+// VerifyConfirmation checks that the winner the token was issued to signed the withdrawal to the
+// invoices, so the site holding the token can't send the prizes anywhere else.
+func VerifyConfirmation(claims Claims, paymentRequests []string, signature string) error {
+	message := ConfirmationMessage(claims.ID, paymentRequests)
+	if err := audit.VerifySignature(claims.Subject, ConfirmationDomain, message, signature); err != nil {
+		return ErrInvalidConfirmation
+	}
+
+	return nil
+}
+
+func (s *Service) sign(unsigned string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(unsigned))
+	return mac.Sum(nil)
+}
+
+// validateOrigin checks that the origin is a scheme and a host, as browsers send it.
+func validateOrigin(origin string) error {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return errors.Wrap(err, "invalid origin")
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+		return errors.Errorf("invalid origin %q, must be a scheme and a host", origin)
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	secret    = "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb"
+	publicKey = "02a1633cafcc01ebfb6d78e39f687a1f0995c62fc95f51ead10a02ee0be551b5dc"
+	origin    = "https://dashboard.example"
+)
+
+func newTestService(t *testing.T, now time.Time) *Service {
+	t.Helper()
+
+	service, err := New(config.ClaimWidget{Secret: secret, TTL: 5 * time.Minute})
+	assert.NoError(t, err)
+	service.now = func() time.Time { return now }
+
+	return service
+}
+
+func TestIssueValidate(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	service := newTestService(t, now)
+
+	token, err := service.Issue(publicKey, origin)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(5*time.Minute).Unix(), token.ExpiresAt)
+
+	claims, err := service.Validate(token.Token, origin)
+	assert.NoError(t, err)
+	assert.Len(t, token.ID, 32)
+	expected := Claims{
+		ID:        token.ID,
+		Subject:   publicKey,
+		Audience:  origin,
+		Issuer:    issuer,
+		Scope:     scope,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: token.ExpiresAt,
+	}
+	assert.Equal(t, expected, claims)
+
+	_, err = service.Validate(token.Token, "https://evil.example")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	service.now = func() time.Time { return now.Add(5 * time.Minute) }
+	_, err = service.Validate(token.Token, origin)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestIssueUniqueIDs(t *testing.T) {
+	service := newTestService(t, time.Unix(1_700_000_000, 0))
+
+	first, err := service.Issue(publicKey, origin)
+	assert.NoError(t, err)
+	second, err := service.Issue(publicKey, origin)
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, first.ID, second.ID)
+}
+
+func TestValidateMissingClaims(t *testing.T) {
+	service := newTestService(t, time.Unix(1_700_000_000, 0))
+
+	// Tokens without an audience or an ID were issued before they were required
+	cases := map[string]string{
+		"Missing audience": `{"jti":"id","sub":"pubkey","iss":"btry","scope":"claim","exp":1800000000}`,
+		"Missing ID":       `{"sub":"pubkey","aud":"https://dashboard.example","iss":"btry","scope":"claim","exp":1800000000}`,
+	}
+	for desc, payload := range cases {
+		t.Run(desc, func(t *testing.T) {
+			unsigned := header + "." + base64.RawURLEncoding.EncodeToString([]byte(payload))
+			token := unsigned + "." + base64.RawURLEncoding.EncodeToString(service.sign(unsigned))
+
+			_, err := service.Validate(token, "")
+			assert.ErrorIs(t, err, ErrInvalidToken)
+			_, err = service.Validate(token, origin)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestVerifyConfirmation(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	claims := Claims{ID: "id", Subject: hex.EncodeToString(public)}
+	paymentRequests := []string{"lnbc1", "lnbc2"}
+
+	message := ConfirmationMessage(claims.ID, paymentRequests)
+	assert.Equal(t, "id\nlnbc1\nlnbc2", string(message))
+	hash := sha256.Sum256(append([]byte(ConfirmationDomain+"\x00"), message...))
+	signature := hex.EncodeToString(ed25519.Sign(private, hash[:]))
+
+	assert.NoError(t, VerifyConfirmation(claims, paymentRequests, signature))
+
+	// The site can't change the invoices the winner signed
+	err = VerifyConfirmation(claims, []string{"lnbc3"}, signature)
+	assert.ErrorIs(t, err, ErrInvalidConfirmation)
+
+	err = VerifyConfirmation(Claims{ID: "other", Subject: claims.Subject}, paymentRequests, signature)
+	assert.ErrorIs(t, err, ErrInvalidConfirmation)
+}
+
+func TestValidateTampered(t *testing.T) {
+	service := newTestService(t, time.Unix(1_700_000_000, 0))
+
+	token, err := service.Issue(publicKey, origin)
+	assert.NoError(t, err)
+	parts := strings.Split(token.Token, ".")
+
+	other, err := New(config.ClaimWidget{Secret: strings.Repeat("ab", 32)})
+	assert.NoError(t, err)
+	other.now = service.now
+	forged, err := other.Issue(publicKey, origin)
+	assert.NoError(t, err)
+
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"jti":"id","sub":"other","aud":"https://dashboard.example","iss":"btry","scope":"claim","exp":1800000000}`))
+
+	cases := map[string]string{
+		"Malformed":       "token",
+		"Other secret":    forged.Token,
+		"None algorithm":  none + "." + parts[1] + ".",
+		"Swapped payload": parts[0] + "." + payload + "." + parts[2],
+	}
+	for desc, token := range cases {
+		t.Run(desc, func(t *testing.T) {
+			_, err := service.Validate(token, origin)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	service, err := New(config.ClaimWidget{})
+	assert.NoError(t, err)
+
+	_, err = service.Issue(publicKey, origin)
+	assert.ErrorIs(t, err, ErrDisabled)
+
+	_, err = service.Validate("token", "")
+	assert.ErrorIs(t, err, ErrDisabled)
+}
+
+func TestIssueInvalidOrigin(t *testing.T) {
+	service := newTestService(t, time.Unix(1_700_000_000, 0))
+
+	for _, origin := range []string{"", "dashboard.example", "ftp://dashboard.example", "https://dashboard.example/path"} {
+		_, err := service.Issue(publicKey, origin)
+		assert.Error(t, err, origin)
+	}
+}
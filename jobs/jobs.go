@@ -0,0 +1,231 @@
+// Package jobs implements a persistent queue that executes tasks asynchronously, retrying them
+// when they fail.
+//
+// Jobs are stored in the database before being executed and removed once they complete, so the
+// ones pending when the server stops are executed after it starts again.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/fault"
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultWorkers      = 4
+	defaultMaxAttempts  = 10
+	defaultBackoff      = 30 * time.Second
+	defaultTimeout      = time.Minute
+	defaultPollInterval = 10 * time.Second
+	// maxBackoffShift caps the exponential backoff at 2^maxBackoffShift times the base duration
+	maxBackoffShift = 10
+)
+
+// Handler executes a job with the payload it was enqueued with. Returning an error makes the
+// queue retry it later, unless it's classified as permanent or a conflict.
+type Handler func(ctx context.Context, payload []byte) error
+
+// Queue executes jobs asynchronously.
+type Queue interface {
+	Enqueue(kind string, payload any) error
+	Schedule(kind string, payload any, runAt time.Time) error
+	Register(kind string, handler Handler)
+	Start(ctx context.Context)
+}
+
+type queue struct {
+	db           *db.DB
+	logger       *logger.Logger
+	now          func() time.Time
+	handlers     map[string]Handler
+	wake         chan struct{}
+	workers      int
+	maxAttempts  uint32
+	backoff      time.Duration
+	timeout      time.Duration
+	pollInterval time.Duration
+	mu           sync.RWMutex
+}
+
+// New returns a new jobs queue.
+func New(config config.Jobs, db *db.DB) (Queue, error) {
+	logger, err := logger.New(config.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &queue{
+		db:           db,
+		logger:       logger,
+		now:          time.Now,
+		handlers:     make(map[string]Handler),
+		wake:         make(chan struct{}, 1),
+		workers:      config.Workers,
+		maxAttempts:  config.MaxAttempts,
+		backoff:      config.Backoff,
+		timeout:      config.Timeout,
+		pollInterval: config.PollInterval,
+	}
+	if q.workers == 0 {
+		q.workers = defaultWorkers
+	}
+	if q.maxAttempts == 0 {
+		q.maxAttempts = defaultMaxAttempts
+	}
+	if q.backoff == 0 {
+		q.backoff = defaultBackoff
+	}
+	if q.timeout == 0 {
+		q.timeout = defaultTimeout
+	}
+	if q.pollInterval == 0 {
+		q.pollInterval = defaultPollInterval
+	}
+
+	return q, nil
+}
+
+// Enqueue stores a job to be executed as soon as a worker is available. The payload is encoded
+// as JSON.
+func (q *queue) Enqueue(kind string, payload any) error {
+	return q.Schedule(kind, payload, q.now())
+}
+
+// Schedule stores a job to be executed once runAt is reached.
+func (q *queue) Schedule(kind string, payload any, runAt time.Time) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrapf(err, "encoding %s job payload", kind)
+	}
+
+	if _, err := q.db.Jobs.Add(kind, data, runAt.Unix()); err != nil {
+		return errors.Wrapf(err, "enqueuing %s job", kind)
+	}
+
+	if runAt.After(q.now()) {
+		return nil
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Register sets the handler executing the jobs of the kind specified. Handlers must be registered
+// before starting the queue.
+func (q *queue) Register(kind string, handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.handlers[kind] = handler
+}
+
+// Start executes the loop that claims the jobs due and runs them.
+func (q *queue) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(q.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			// Keep going while there are more jobs due than workers
+			if q.process(ctx) == q.workers {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.wake:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// process claims up to a job per worker and runs them concurrently, it returns the number of jobs
+// claimed.
+func (q *queue) process(ctx context.Context) int {
+	now := q.now()
+	// Leasing the jobs for longer than they can run for avoids executing them twice
+	leaseUntil := now.Add(q.timeout + time.Second).Unix()
+
+	jobs, err := q.db.Jobs.Claim(now.Unix(), leaseUntil, uint64(q.workers))
+	if err != nil {
+		q.logger.Error(errors.Wrap(err, "claiming jobs"))
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job db.Job) {
+			defer wg.Done()
+			q.run(ctx, job)
+		}(job)
+	}
+	wg.Wait()
+
+	return len(jobs)
+}
+
+// run executes a job and removes it if it completed, reschedules it if it failed or marks it as
+// failed if it has no attempts left or the error is permanent or a conflict.
+func (q *queue) run(ctx context.Context, job db.Job) {
+	q.mu.RLock()
+	handler, ok := q.handlers[job.Kind]
+	q.mu.RUnlock()
+
+	if !ok {
+		q.logger.Errorf("Job %d failed: unknown kind %q", job.ID, job.Kind)
+		if err := q.db.Jobs.Fail(job.ID, "unknown kind"); err != nil {
+			q.logger.Error(err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+
+	err := handler(ctx, job.Payload)
+	if err == nil {
+		if err := q.db.Jobs.Delete(job.ID); err != nil {
+			q.logger.Error(err)
+		}
+		return
+	}
+
+	// Attempting again a job that failed permanently or conflicts with the current state won't help
+	if kind := fault.KindOf(err); kind == fault.Permanent || kind == fault.Conflict {
+		q.logger.Errorf("Job %d (%s) failed with a %s error: %v", job.ID, job.Kind, kind, err)
+		if err := q.db.Jobs.Fail(job.ID, err.Error()); err != nil {
+			q.logger.Error(err)
+		}
+		return
+	}
+
+	if job.Attempts >= q.maxAttempts {
+		q.logger.Errorf("Job %d (%s) failed after %d attempts: %v", job.ID, job.Kind, job.Attempts, err)
+		if err := q.db.Jobs.Fail(job.ID, err.Error()); err != nil {
+			q.logger.Error(err)
+		}
+		return
+	}
+
+	backoff := q.backoff << min(job.Attempts-1, maxBackoffShift)
+	runAt := q.now().Add(backoff)
+	q.logger.Warningf("Job %d (%s) failed, retrying at %s: %v", job.ID, job.Kind, runAt.Format(time.RFC3339), err)
+	if err := q.db.Jobs.Retry(job.ID, runAt.Unix(), err.Error()); err != nil {
+		q.logger.Error(err)
+	}
+}
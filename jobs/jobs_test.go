@@ -0,0 +1,178 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/fault"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var now = time.Unix(1_700_000_000, 0)
+
+func newTestQueue(t *testing.T, jobsMock *db.JobsStoreMock) *queue {
+	t.Helper()
+
+	q, err := New(config.Jobs{Workers: 2, MaxAttempts: 3, Backoff: time.Minute}, &db.DB{Jobs: jobsMock})
+	assert.NoError(t, err)
+
+	testQueue := q.(*queue)
+	testQueue.now = func() time.Time { return now }
+	return testQueue
+}
+
+func TestEnqueue(t *testing.T) {
+	jobsMock := db.NewJobsStoreMock()
+	jobsMock.On("Add", "notify", []byte(`{"public_key":"key"}`), now.Unix()).Return(uint64(1), nil)
+
+	q := newTestQueue(t, jobsMock)
+
+	err := q.Enqueue("notify", map[string]string{"public_key": "key"})
+	assert.NoError(t, err)
+
+	jobsMock.AssertExpectations(t)
+	assert.Len(t, q.wake, 1)
+
+	// The workers are woken up once
+	jobsMock.On("Add", "notify", mock.Anything, now.Unix()).Return(uint64(2), nil)
+	err = q.Enqueue("notify", map[string]string{})
+	assert.NoError(t, err)
+	assert.Len(t, q.wake, 1)
+}
+
+func TestSchedule(t *testing.T) {
+	runAt := now.Add(time.Hour)
+	jobsMock := db.NewJobsStoreMock()
+	jobsMock.On("Add", "expire", []byte(`1`), runAt.Unix()).Return(uint64(1), nil)
+
+	q := newTestQueue(t, jobsMock)
+
+	err := q.Schedule("expire", 1, runAt)
+	assert.NoError(t, err)
+
+	jobsMock.AssertExpectations(t)
+	// Workers are not woken up for jobs that are not due yet
+	assert.Empty(t, q.wake)
+}
+
+func TestEnqueueError(t *testing.T) {
+	jobsMock := db.NewJobsStoreMock()
+	jobsMock.On("Add", "notify", mock.Anything, now.Unix()).Return(uint64(0), errors.New("disk full"))
+
+	q := newTestQueue(t, jobsMock)
+
+	err := q.Enqueue("notify", nil)
+	assert.Error(t, err)
+	assert.Empty(t, q.wake)
+}
+
+func TestProcess(t *testing.T) {
+	leaseUntil := now.Add(defaultTimeout + time.Second).Unix()
+	claimed := []db.Job{
+		{ID: 1, Kind: "succeed", Payload: []byte(`1`), Attempts: 1},
+		{ID: 2, Kind: "fail", Payload: []byte(`2`), Attempts: 2},
+		{ID: 3, Kind: "fail", Payload: []byte(`3`), Attempts: 3},
+		{ID: 4, Kind: "unknown", Attempts: 1},
+	}
+
+	jobsMock := db.NewJobsStoreMock()
+	jobsMock.On("Claim", now.Unix(), leaseUntil, uint64(2)).Return(claimed, nil)
+	jobsMock.On("Delete", uint64(1)).Return(nil).Once()
+	// Second attempt, retried after twice the backoff
+	jobsMock.On("Retry", uint64(2), now.Add(2*time.Minute).Unix(), "unavailable").Return(nil).Once()
+	// No attempts left
+	jobsMock.On("Fail", uint64(3), "unavailable").Return(nil).Once()
+	jobsMock.On("Fail", uint64(4), "unknown kind").Return(nil).Once()
+
+	q := newTestQueue(t, jobsMock)
+
+	var payloads []string
+	q.Register("succeed", func(_ context.Context, payload []byte) error {
+		payloads = append(payloads, string(payload))
+		return nil
+	})
+	q.Register("fail", func(context.Context, []byte) error {
+		return errors.New("unavailable")
+	})
+
+	n := q.process(context.Background())
+	assert.Equal(t, len(claimed), n)
+	assert.Equal(t, []string{"1"}, payloads)
+
+	jobsMock.AssertExpectations(t)
+}
+
+func TestProcessClassifiedErrors(t *testing.T) {
+	leaseUntil := now.Add(defaultTimeout + time.Second).Unix()
+	claimed := []db.Job{
+		{ID: 1, Kind: "transient", Attempts: 1},
+		{ID: 2, Kind: "permanent", Attempts: 1},
+		{ID: 3, Kind: "conflict", Attempts: 1},
+	}
+
+	jobsMock := db.NewJobsStoreMock()
+	jobsMock.On("Claim", now.Unix(), leaseUntil, uint64(2)).Return(claimed, nil)
+	jobsMock.On("Retry", uint64(1), now.Add(time.Minute).Unix(), "timeout").Return(nil).Once()
+	// Failed on the first attempt
+	jobsMock.On("Fail", uint64(2), "invalid").Return(nil).Once()
+	jobsMock.On("Fail", uint64(3), "already paid").Return(nil).Once()
+
+	q := newTestQueue(t, jobsMock)
+	q.Register("transient", func(context.Context, []byte) error {
+		return fault.NewTransient(errors.New("timeout"))
+	})
+	q.Register("permanent", func(context.Context, []byte) error {
+		return fault.NewPermanent(errors.New("invalid"))
+	})
+	q.Register("conflict", func(context.Context, []byte) error {
+		return fault.NewConflict(errors.New("already paid"))
+	})
+
+	q.process(context.Background())
+	jobsMock.AssertExpectations(t)
+}
+
+func TestProcessClaimError(t *testing.T) {
+	jobsMock := db.NewJobsStoreMock()
+	jobsMock.On("Claim", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("locked"))
+
+	q := newTestQueue(t, jobsMock)
+
+	n := q.process(context.Background())
+	assert.Zero(t, n)
+}
+
+func TestStart(t *testing.T) {
+	done := make(chan struct{})
+
+	jobsMock := db.NewJobsStoreMock()
+	jobsMock.On("Add", "notify", mock.Anything, now.Unix()).Return(uint64(1), nil)
+	jobsMock.On("Claim", mock.Anything, mock.Anything, mock.Anything).
+		Return([]db.Job{{ID: 1, Kind: "notify", Payload: []byte(`{}`), Attempts: 1}}, nil).Once()
+	jobsMock.On("Claim", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	jobsMock.On("Delete", uint64(1)).Return(nil)
+
+	q := newTestQueue(t, jobsMock)
+	q.Register("notify", func(context.Context, []byte) error {
+		close(done)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	assert.NoError(t, q.Enqueue("notify", struct{}{}))
+	q.Start(ctx)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("job was not executed")
+	}
+}
@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// QueueMock is a mocked implementation of a jobs queue.
+type QueueMock struct {
+	mock.Mock
+}
+
+// NewQueueMock returns a mocked jobs queue.
+func NewQueueMock() *QueueMock {
+	return &QueueMock{}
+}
+
+// Enqueue mock.
+func (q *QueueMock) Enqueue(kind string, payload any) error {
+	args := q.Called(kind, payload)
+	return args.Error(0)
+}
+
+// Schedule mock.
+func (q *QueueMock) Schedule(kind string, payload any, runAt time.Time) error {
+	args := q.Called(kind, payload, runAt)
+	return args.Error(0)
+}
+
+// Register mock.
+func (q *QueueMock) Register(kind string, handler Handler) {
+	_ = q.Called(kind, handler)
+}
+
+// Start mock.
+func (q *QueueMock) Start(ctx context.Context) {
+	_ = q.Called(ctx)
+}
@@ -0,0 +1,179 @@
+// Package leader elects the instance that draws the lotteries and sends the payouts when several
+// of them share the database and the lightning node, so the draws are not executed twice.
+package leader
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// leaseName is the name of the lease held by the leader
+	leaseName    = "leader"
+	defaultLease = 30 * time.Second
+)
+
+// Elector campaigns for the leadership of the deployment.
+type Elector interface {
+	IsLeader() bool
+	OnElected(fn func(ctx context.Context))
+	Start(ctx context.Context) error
+}
+
+type elector struct {
+	db     *db.DB
+	logger *logger.Logger
+	now    func() time.Time
+	// cancel stops the services started by the callbacks when the leadership is lost
+	cancel    context.CancelFunc
+	callbacks []func(ctx context.Context)
+	leaderCtx context.Context
+	id        string
+	lease     time.Duration
+	enabled   bool
+	mu        sync.RWMutex
+}
+
+// New returns a new leader elector. Instances that don't have it enabled are always the leader.
+func New(config config.Leader, db *db.DB) (Elector, error) {
+	logger, err := logger.New(config.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	id := config.ID
+	if id == "" {
+		id, err = os.Hostname()
+		if err != nil {
+			return nil, errors.Wrap(err, "getting host name")
+		}
+	}
+
+	lease := config.Lease
+	if lease == 0 {
+		lease = defaultLease
+	}
+
+	return &elector{
+		db:      db,
+		logger:  logger,
+		now:     time.Now,
+		id:      id,
+		lease:   lease,
+		enabled: config.Enabled,
+	}, nil
+}
+
+// IsLeader returns whether this instance is the leader.
+func (e *elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.leaderCtx != nil
+}
+
+// OnElected registers a function that is called every time the instance is elected, right away if
+// it's the leader already. The context is cancelled when the leadership is lost.
+func (e *elector) OnElected(fn func(ctx context.Context)) {
+	e.mu.Lock()
+	e.callbacks = append(e.callbacks, fn)
+	leaderCtx := e.leaderCtx
+	e.mu.Unlock()
+
+	if leaderCtx != nil {
+		fn(leaderCtx)
+	}
+}
+
+// Start campaigns for the leadership, the lease is renewed every third of its duration and
+// released when the context is cancelled.
+func (e *elector) Start(ctx context.Context) error {
+	if !e.enabled {
+		e.elect(ctx)
+		return nil
+	}
+
+	e.logger.Infof("Campaigning for the leadership as %q", e.id)
+	e.campaign(ctx)
+
+	go func() {
+		ticker := time.NewTicker(e.lease / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				e.resign()
+				return
+			case <-ticker.C:
+				e.campaign(ctx)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// campaign acquires or renews the lease. The leader steps down as soon as it fails to renew it, a
+// follower can only take it over once it expires.
+func (e *elector) campaign(ctx context.Context) {
+	now := e.now()
+	acquired, err := e.db.Leases.Acquire(leaseName, e.id, now.Unix(), now.Add(e.lease).Unix())
+	if err != nil {
+		e.logger.Error(err)
+	}
+
+	switch {
+	case acquired && !e.IsLeader():
+		e.elect(ctx)
+	case !acquired && e.IsLeader():
+		e.demote()
+	}
+}
+
+// elect makes the instance the leader and calls the functions registered.
+func (e *elector) elect(ctx context.Context) {
+	e.mu.Lock()
+	e.leaderCtx, e.cancel = context.WithCancel(ctx)
+	leaderCtx := e.leaderCtx
+	callbacks := e.callbacks
+	e.mu.Unlock()
+
+	if e.enabled {
+		e.logger.Infof("Elected leader as %q", e.id)
+	}
+
+	for _, fn := range callbacks {
+		fn(leaderCtx)
+	}
+}
+
+// demote makes the instance a follower.
+func (e *elector) demote() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.logger.Warningf("Lost the leadership, %q is now a follower", e.id)
+	e.cancel()
+	e.leaderCtx, e.cancel = nil, nil
+}
+
+// resign releases the lease so a follower takes over without waiting for it to expire.
+func (e *elector) resign() {
+	if !e.IsLeader() {
+		return
+	}
+
+	e.demote()
+	if err := e.db.Leases.Release(leaseName, e.id); err != nil {
+		e.logger.Error(err)
+	}
+}
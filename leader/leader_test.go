@@ -0,0 +1,104 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var now = time.Unix(1_700_000_000, 0)
+
+func newTestElector(t *testing.T, leasesMock *db.LeasesStoreMock) *elector {
+	t.Helper()
+
+	e, err := New(config.Leader{
+		Logger:  config.Logger{Level: uint8(logger.DISABLED)},
+		ID:      "a",
+		Lease:   time.Minute,
+		Enabled: true,
+	}, &db.DB{Leases: leasesMock})
+	assert.NoError(t, err)
+
+	testElector := e.(*elector)
+	testElector.now = func() time.Time { return now }
+	return testElector
+}
+
+func TestDisabled(t *testing.T) {
+	e, err := New(config.Leader{Logger: config.Logger{Level: uint8(logger.DISABLED)}}, nil)
+	assert.NoError(t, err)
+
+	elected := 0
+	e.OnElected(func(ctx context.Context) { elected++ })
+
+	err = e.Start(context.Background())
+	assert.NoError(t, err)
+
+	assert.True(t, e.IsLeader())
+	assert.Equal(t, 1, elected)
+
+	// Functions registered after the election are called right away
+	e.OnElected(func(ctx context.Context) { elected++ })
+	assert.Equal(t, 2, elected)
+}
+
+func TestCampaign(t *testing.T) {
+	leasesMock := db.NewLeasesStoreMock()
+	expiresAt := now.Add(time.Minute).Unix()
+	leasesMock.On("Acquire", leaseName, "a", now.Unix(), expiresAt).Return(false, nil).Once()
+
+	e := newTestElector(t, leasesMock)
+
+	var leaderCtx context.Context
+	e.OnElected(func(ctx context.Context) { leaderCtx = ctx })
+
+	// The lease is held by another instance
+	e.campaign(context.Background())
+	assert.False(t, e.IsLeader())
+	assert.Nil(t, leaderCtx)
+
+	leasesMock.On("Acquire", leaseName, "a", now.Unix(), expiresAt).Return(true, nil).Twice()
+	e.campaign(context.Background())
+	assert.True(t, e.IsLeader())
+	assert.NotNil(t, leaderCtx)
+
+	// Renewing the lease doesn't elect the instance again
+	electedCtx := leaderCtx
+	e.campaign(context.Background())
+	assert.Equal(t, electedCtx, leaderCtx)
+
+	leasesMock.On("Acquire", leaseName, "a", now.Unix(), expiresAt).Return(false, errors.New("locked"))
+	e.campaign(context.Background())
+	assert.False(t, e.IsLeader())
+	assert.ErrorIs(t, leaderCtx.Err(), context.Canceled)
+}
+
+func TestResign(t *testing.T) {
+	leasesMock := db.NewLeasesStoreMock()
+	leasesMock.On("Acquire", leaseName, "a", now.Unix(), now.Add(time.Minute).Unix()).Return(true, nil)
+	released := make(chan struct{})
+	leasesMock.On("Release", leaseName, "a").Return(nil).Run(func(mock.Arguments) { close(released) })
+
+	e := newTestElector(t, leasesMock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := e.Start(ctx)
+	assert.NoError(t, err)
+	assert.True(t, e.IsLeader())
+
+	cancel()
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("the lease was not released")
+	}
+	assert.False(t, e.IsLeader())
+}
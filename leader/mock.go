@@ -0,0 +1,34 @@
+package leader
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// ElectorMock is a mocked implementation of a leader elector.
+type ElectorMock struct {
+	mock.Mock
+}
+
+// NewElectorMock returns a mocked leader elector.
+func NewElectorMock() *ElectorMock {
+	return &ElectorMock{}
+}
+
+// IsLeader mock.
+func (e *ElectorMock) IsLeader() bool {
+	args := e.Called()
+	return args.Bool(0)
+}
+
+// OnElected mock.
+func (e *ElectorMock) OnElected(fn func(ctx context.Context)) {
+	_ = e.Called(fn)
+}
+
+// Start mock.
+func (e *ElectorMock) Start(ctx context.Context) error {
+	args := e.Called(ctx)
+	return args.Error(0)
+}
@@ -0,0 +1,73 @@
+package lightning
+
+import (
+	"context"
+	"net"
+
+	"github.com/aftermath2/BTRY/fault"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Classify returns the error classified by its cause: the node being unreachable, overloaded or too
+// slow to answer is transient, requests it rejects are permanent and the ones that clash with its
+// state, like an invoice that already exists, are conflicts. Errors that are already classified or
+// have other causes are returned as is.
+func Classify(err error) error {
+	if err == nil || fault.KindOf(err) != fault.Unknown {
+		return err
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fault.NewTransient(err)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fault.NewTransient(err)
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	switch s.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return fault.NewTransient(err)
+	case codes.InvalidArgument, codes.NotFound, codes.PermissionDenied, codes.Unauthenticated,
+		codes.OutOfRange, codes.Unimplemented:
+		return fault.NewPermanent(err)
+	case codes.AlreadyExists, codes.FailedPrecondition:
+		return fault.NewConflict(err)
+	}
+
+	return err
+}
+
+// PaymentRejected returns whether the error of a payment stream that didn't send any update means
+// the node refused to send the payment, rather than the stream breaking or another payment to the
+// same hash being in progress or completed.
+func PaymentRejected(err error) bool {
+	if errors.Is(err, context.Canceled) || status.Code(err) == codes.Canceled {
+		return false
+	}
+
+	kind := fault.KindOf(Classify(err))
+	return kind != fault.Transient && kind != fault.Conflict
+}
+
+// classifyUnary classifies the errors of the unary calls made to the node.
+func classifyUnary(
+	ctx context.Context,
+	method string,
+	req, reply any,
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	return Classify(invoker(ctx, method, req, reply, cc, opts...))
+}
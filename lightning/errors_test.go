@@ -0,0 +1,112 @@
+package lightning
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aftermath2/BTRY/fault"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		err      error
+		desc     string
+		expected fault.Kind
+	}{
+		{
+			desc:     "Unclassified",
+			err:      errors.New("test"),
+			expected: fault.Unknown,
+		},
+		{
+			desc:     "Context deadline",
+			err:      errors.Wrap(context.DeadlineExceeded, "getting info"),
+			expected: fault.Transient,
+		},
+		{
+			desc:     "Unavailable",
+			err:      status.Error(codes.Unavailable, "connection refused"),
+			expected: fault.Transient,
+		},
+		{
+			desc:     "Invalid argument",
+			err:      status.Error(codes.InvalidArgument, "invalid payment request"),
+			expected: fault.Permanent,
+		},
+		{
+			desc:     "Already exists",
+			err:      errors.Wrap(status.Error(codes.AlreadyExists, "invoice already exists"), "adding invoice"),
+			expected: fault.Conflict,
+		},
+		{
+			desc:     "Already classified",
+			err:      fault.NewPermanent(status.Error(codes.Unavailable, "connection refused")),
+			expected: fault.Permanent,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, fault.KindOf(Classify(tc.err)))
+		})
+	}
+
+	assert.NoError(t, Classify(nil))
+}
+
+func TestPaymentRejected(t *testing.T) {
+	cases := []struct {
+		err      error
+		desc     string
+		expected bool
+	}{
+		{
+			desc:     "Rejected",
+			err:      status.Error(codes.Unknown, "invoice expired"),
+			expected: true,
+		},
+		{
+			desc:     "Invalid argument",
+			err:      status.Error(codes.InvalidArgument, "amount must be specified"),
+			expected: true,
+		},
+		{
+			desc: "Unavailable",
+			err:  status.Error(codes.Unavailable, "connection refused"),
+		},
+		{
+			desc: "Already paid",
+			err:  status.Error(codes.AlreadyExists, "invoice is already paid"),
+		},
+		{
+			desc: "Cancelled",
+			err:  status.Error(codes.Canceled, "context canceled"),
+		},
+		{
+			desc: "Context cancelled",
+			err:  errors.Wrap(context.Canceled, "receiving payment"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, PaymentRejected(tc.err))
+		})
+	}
+}
+
+func TestClassifyUnary(t *testing.T) {
+	invoker := func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+		return status.Error(codes.DeadlineExceeded, "timeout")
+	}
+
+	err := classifyUnary(context.Background(), "/lnrpc.Lightning/GetInfo", nil, nil, nil, invoker)
+	assert.True(t, fault.IsTransient(err))
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
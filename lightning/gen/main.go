@@ -0,0 +1,268 @@
+// Command gen writes the testify mocks of the interfaces declared in a source file of the
+// lightning package.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const mockImport = "github.com/stretchr/testify/mock"
+
+func main() {
+	source := flag.String("source", "", "Go source file path")
+	out := flag.String("out", "", "Mocks output path")
+	flag.Parse()
+
+	src, err := os.ReadFile(*source)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mocks, err := generate(src)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile(*out, mocks, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// method is an interface method with its types printed as source code.
+type method struct {
+	name    string
+	params  []param
+	results []string
+}
+
+type param struct {
+	name string
+	typ  string
+}
+
+// generate returns the source code of the mocks of the non-generic interfaces declared in src.
+// Interfaces embedding others declared in the same file get all their methods mocked.
+func generate(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing source")
+	}
+
+	interfaces := make(map[string]*ast.InterfaceType)
+	var names []string
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			iface, ok := typeSpec.Type.(*ast.InterfaceType)
+			if !ok || typeSpec.TypeParams != nil {
+				continue
+			}
+			interfaces[typeSpec.Name.Name] = iface
+			names = append(names, typeSpec.Name.Name)
+		}
+	}
+
+	// Map the import names to their paths to include only the ones the signatures use
+	importPaths := make(map[string]string)
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+		name := path[strings.LastIndex(path, "/")+1:]
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		importPaths[name] = path
+	}
+	imports := map[string]bool{mockImport: true}
+
+	var b bytes.Buffer
+	for _, name := range names {
+		methods, err := collectMethods(fset, interfaces, name, importPaths, imports)
+		if err != nil {
+			return nil, errors.Wrapf(err, "interface %s", name)
+		}
+		writeMock(&b, name, methods)
+	}
+
+	var header bytes.Buffer
+	header.WriteString("// Code generated by go generate; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&header, "package %s\n\n", file.Name.Name)
+	writeImports(&header, imports)
+	header.Write(b.Bytes())
+
+	return format.Source(header.Bytes())
+}
+
+// collectMethods returns the methods of an interface sorted by name, including the embedded ones.
+func collectMethods(
+	fset *token.FileSet,
+	interfaces map[string]*ast.InterfaceType,
+	name string,
+	importPaths map[string]string,
+	imports map[string]bool,
+) ([]method, error) {
+	var methods []method
+	for _, field := range interfaces[name].Methods.List {
+		if len(field.Names) == 0 {
+			embedded, ok := field.Type.(*ast.Ident)
+			if !ok || interfaces[embedded.Name] == nil {
+				return nil, errors.Errorf("embedded interface %s must be declared in the file",
+					printExpr(fset, field.Type))
+			}
+			embeddedMethods, err := collectMethods(fset, interfaces, embedded.Name, importPaths, imports)
+			if err != nil {
+				return nil, err
+			}
+			methods = append(methods, embeddedMethods...)
+			continue
+		}
+
+		funcType := field.Type.(*ast.FuncType)
+		if err := addImports(funcType, importPaths, imports); err != nil {
+			return nil, err
+		}
+
+		m := method{name: field.Names[0].Name}
+		for _, p := range funcType.Params.List {
+			if _, ok := p.Type.(*ast.Ellipsis); ok {
+				return nil, errors.Errorf("method %s: variadic parameters are not supported", m.name)
+			}
+			typ := printExpr(fset, p.Type)
+			if len(p.Names) == 0 {
+				m.params = append(m.params, param{name: "p" + strconv.Itoa(len(m.params)), typ: typ})
+			}
+			for _, paramName := range p.Names {
+				m.params = append(m.params, param{name: paramName.Name, typ: typ})
+			}
+		}
+		if funcType.Results != nil {
+			for _, r := range funcType.Results.List {
+				count := max(len(r.Names), 1)
+				for range count {
+					m.results = append(m.results, printExpr(fset, r.Type))
+				}
+			}
+		}
+		methods = append(methods, m)
+	}
+
+	sort.Slice(methods, func(i, j int) bool { return methods[i].name < methods[j].name })
+	return methods, nil
+}
+
+// addImports marks the imports used by a method signature.
+func addImports(funcType *ast.FuncType, importPaths map[string]string, imports map[string]bool) error {
+	var err error
+	ast.Inspect(funcType, func(n ast.Node) bool {
+		selector, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		pkg, ok := selector.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		path, ok := importPaths[pkg.Name]
+		if !ok {
+			err = errors.Errorf("unknown package %s", pkg.Name)
+			return false
+		}
+		imports[path] = true
+		return false
+	})
+	return err
+}
+
+func writeImports(b *bytes.Buffer, imports map[string]bool) {
+	var std, external []string
+	for path := range imports {
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			external = append(external, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(external)
+
+	b.WriteString("import (\n")
+	for _, path := range std {
+		fmt.Fprintf(b, "%q\n", path)
+	}
+	if len(std) != 0 && len(external) != 0 {
+		b.WriteString("\n")
+	}
+	for _, path := range external {
+		fmt.Fprintf(b, "%q\n", path)
+	}
+	b.WriteString(")\n\n")
+}
+
+func writeMock(b *bytes.Buffer, name string, methods []method) {
+	mockName := name + "Mock"
+	fmt.Fprintf(b, "// %s is a mocked implementation of %s.\n", mockName, name)
+	fmt.Fprintf(b, "type %s struct {\nmock.Mock\n}\n\n", mockName)
+	fmt.Fprintf(b, "// New%s returns a mocked %s.\n", mockName, name)
+	fmt.Fprintf(b, "func New%s() *%s {\nreturn &%s{}\n}\n\n", mockName, mockName, mockName)
+
+	for _, m := range methods {
+		params := make([]string, 0, len(m.params))
+		args := make([]string, 0, len(m.params))
+		for _, p := range m.params {
+			params = append(params, p.name+" "+p.typ)
+			args = append(args, p.name)
+		}
+		results := strings.Join(m.results, ", ")
+		if len(m.results) > 1 {
+			results = "(" + results + ")"
+		}
+
+		fmt.Fprintf(b, "// %s mock.\n", m.name)
+		fmt.Fprintf(b, "func (m *%s) %s(%s) %s {\n", mockName, m.name, strings.Join(params, ", "), results)
+		if len(m.results) == 0 {
+			fmt.Fprintf(b, "m.Called(%s)\n}\n\n", strings.Join(args, ", "))
+			continue
+		}
+
+		fmt.Fprintf(b, "args := m.Called(%s)\n", strings.Join(args, ", "))
+		returns := make([]string, 0, len(m.results))
+		for i, typ := range m.results {
+			if typ == "error" {
+				returns = append(returns, fmt.Sprintf("args.Error(%d)", i))
+				continue
+			}
+			fmt.Fprintf(b, "var r%d %s\n", i, typ)
+			fmt.Fprintf(b, "if v := args.Get(%d); v != nil {\nr%d = v.(%s)\n}\n", i, i, typ)
+			returns = append(returns, fmt.Sprintf("r%d", i))
+		}
+		fmt.Fprintf(b, "return %s\n}\n\n", strings.Join(returns, ", "))
+	}
+}
+
+func printExpr(fset *token.FileSet, expr ast.Expr) string {
+	var b bytes.Buffer
+	// Printing a parsed expression can't fail
+	_ = printer.Fprint(&b, fset, expr)
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerated(t *testing.T) {
+	src, err := os.ReadFile("../lightning.go")
+	assert.NoError(t, err)
+
+	generated, err := generate(src)
+	assert.NoError(t, err)
+
+	committed, err := os.ReadFile("../mock.go")
+	assert.NoError(t, err)
+
+	assert.Equal(t, string(committed), string(generated), "Outdated, run go generate")
+}
+
+func TestGenerateEmbedded(t *testing.T) {
+	src := []byte(`package node
+
+import "context"
+
+type Reader interface {
+	Read(ctx context.Context, key string) ([]byte, error)
+}
+
+type Store interface {
+	Reader
+	Close()
+}`)
+
+	generated, err := generate(src)
+	assert.NoError(t, err)
+
+	code := string(generated)
+	assert.Contains(t, code, "func (m *StoreMock) Read(ctx context.Context, key string) ([]byte, error) {")
+	assert.Contains(t, code, "func (m *StoreMock) Close() {\n\tm.Called()\n}")
+	assert.Contains(t, code, "func NewReaderMock() *ReaderMock {")
+}
+
+func TestGenerateUnknownEmbedded(t *testing.T) {
+	src := []byte(`package node
+
+import "io"
+
+type Store interface {
+	io.Reader
+}`)
+
+	_, err := generate(src)
+	assert.Error(t, err)
+}
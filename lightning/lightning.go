@@ -1,20 +1,29 @@
 // Package lightning provides utilities for interacting with a Lightning Netowrk node.
 package lightning
 
+//go:generate go run ./gen -source lightning.go -out mock.go
+
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"net"
 	"net/http"
 	"os"
 	"time"
 
+	"github.com/aftermath2/BTRY/chaos"
 	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/fault"
 	"github.com/aftermath2/BTRY/logger"
 
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/invoicesrpc"
 	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
 	"github.com/lightningnetwork/lnd/macaroons"
+	"github.com/lightningnetwork/lnd/record"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/backoff"
@@ -36,29 +45,76 @@ type Stream[T any] interface {
 
 // Client represents a Lightning Network node client.
 type Client interface {
-	AddInvoice(ctx context.Context, amountSat uint64) (*lnrpc.AddInvoiceResponse, error)
+	ChainNotifier
+	InvoiceManager
+	NodeInfo
+	PaymentSender
+}
+
+// ChainNotifier notifies the blocks mined.
+type ChainNotifier interface {
+	SubscribeBlocks(ctx context.Context) (Stream[*chainrpc.BlockEpoch], error)
+}
+
+// InvoiceManager creates, resolves and follows the invoices of the node.
+type InvoiceManager interface {
+	AddHoldInvoice(ctx context.Context, hash []byte, amountSat uint64, memo string, descriptionHash []byte) (string, error)
+	AddInvoice(ctx context.Context, amountSat uint64, memo string, descriptionHash []byte) (*lnrpc.AddInvoiceResponse, error)
+	CancelInvoice(ctx context.Context, hash []byte) error
 	DecodeInvoice(ctx context.Context, invoice string) (*lnrpc.PayReq, error)
+	SettleInvoice(ctx context.Context, preimage []byte) error
+	SubscribeInvoices(ctx context.Context, settleIndex uint64) (Stream[*lnrpc.Invoice], error)
+	SubscribeSingleInvoice(ctx context.Context, hash []byte) (Stream[*lnrpc.Invoice], error)
+}
+
+// NodeInfo describes the node, its channels and signs messages with its key.
+type NodeInfo interface {
 	GetInfo(ctx context.Context) (*lnrpc.GetInfoResponse, error)
-	PayInvoice(ctx context.Context, invoice *lnrpc.PayReq, feeSat int64, inflightUpdates bool) (Stream[*lnrpc.Payment], error)
+	ListChannels(ctx context.Context) ([]*lnrpc.Channel, error)
+	Network() string
 	RemoteBalance(ctx context.Context) (int64, error)
-	SendToLightningAddress(ctx context.Context, address string, amountSat int64) (string, error)
-	SubscribeBlocks(ctx context.Context) (Stream[*chainrpc.BlockEpoch], error)
+	SignMessage(ctx context.Context, message []byte) (string, error)
 	SubscribeChannelEvents(ctx context.Context) (Stream[*lnrpc.ChannelEventUpdate], error)
-	SubscribeInvoices(ctx context.Context) (Stream[*lnrpc.Invoice], error)
+}
+
+// PaymentSender sends and follows the payments of the node.
+type PaymentSender interface {
+	EstimateLightningAddressFee(ctx context.Context, address string, amountSat int64) (int64, error)
+	PayInvoice(ctx context.Context, invoice *lnrpc.PayReq, feeSat int64, inflightUpdates bool) (Stream[*lnrpc.Payment], error)
+	ProbeLightningAddress(ctx context.Context, address string, amountSat int64) (RouteProbe, error)
+	Rebalance(ctx context.Context, outgoingChanID uint64, lastHop string, amountSat, feeSat int64) (int64, error)
+	SendKeysend(ctx context.Context, destination string, amountSat, feeSat int64, records map[uint64][]byte) error
+	SendToLightningAddress(ctx context.Context, address string, amountSat int64) (string, error)
 	SubscribePayments(ctx context.Context) (Stream[*lnrpc.Payment], error)
 }
 
+// RouteProbe is the result of looking for a route to a payment destination.
+type RouteProbe struct {
+	// Fee is the routing fee of the route found, in sats
+	Fee int64
+	// SuccessProbability of the route, from 0 to 1, based on the previous payments of the node
+	SuccessProbability float64
+}
+
 type client struct {
 	ln        lnrpc.LightningClient
 	chain     chainrpc.ChainNotifierClient
+	invoices  invoicesrpc.InvoicesClient
 	router    routerrpc.RouterClient
 	logger    *logger.Logger
 	torClient *http.Client
+	network   string
 	maxFeePPM int64
+	// ampMinAmount is the amount from which the invoices are AMP
+	ampMinAmount uint64
 }
 
+// Dialer opens connections to the node, it's used instead of the default one when the node is
+// reached through Tor.
+type Dialer func(ctx context.Context, address string) (net.Conn, error)
+
 // NewClient returns a new client that communicates with a Lightning node.
-func NewClient(config config.Lightning, torClient *http.Client) (Client, error) {
+func NewClient(config config.Lightning, torClient *http.Client, torDialer Dialer) (Client, error) {
 	logger, err := logger.New(config.Logger)
 	if err != nil {
 		return nil, err
@@ -68,6 +124,10 @@ func NewClient(config config.Lightning, torClient *http.Client) (Client, error)
 	if err != nil {
 		return nil, errors.Wrap(err, "loading gRPC options")
 	}
+	if config.Tor {
+		opts = append(opts, grpc.WithContextDialer(torDialer))
+	}
+	opts = append(opts, chaos.DialOptions()...)
 
 	logger.Infof("Opening gRPC connection to %s...", config.RPCAddress)
 
@@ -80,13 +140,22 @@ func NewClient(config config.Lightning, torClient *http.Client) (Client, error)
 		return nil, err
 	}
 
+	network := defaultNetwork(config.Network)
+	ln := lnrpc.NewLightningClient(conn)
+	if err := checkNetwork(ln, network); err != nil {
+		return nil, err
+	}
+
 	return &client{
-		ln:        lnrpc.NewLightningClient(conn),
-		chain:     chainrpc.NewChainNotifierClient(conn),
-		router:    routerrpc.NewRouterClient(conn),
-		logger:    logger,
-		torClient: torClient,
-		maxFeePPM: config.MaxFeePPM,
+		ln:           ln,
+		chain:        chainrpc.NewChainNotifierClient(conn),
+		invoices:     invoicesrpc.NewInvoicesClient(conn),
+		router:       routerrpc.NewRouterClient(conn),
+		logger:       logger,
+		torClient:    torClient,
+		network:      network,
+		maxFeePPM:    config.MaxFeePPM,
+		ampMinAmount: config.AMPMinAmount,
 	}, nil
 }
 
@@ -124,6 +193,7 @@ func loadGRPCOpts(config config.Lightning) ([]grpc.DialOption, error) {
 		grpc.WithTransportCredentials(tlsCred),
 		grpc.WithPerRPCCredentials(macCred),
 		grpc.WithConnectParams(connectionParams),
+		grpc.WithChainUnaryInterceptor(classifyUnary),
 	}, nil
 }
 
@@ -152,29 +222,171 @@ func waitForLND(conn *grpc.ClientConn, logger *logger.Logger) error {
 	}
 }
 
+// checkNetwork returns an error if the node runs on a network other than the one configured, so
+// deployments can't mix them by mistake.
+func checkNetwork(ln lnrpc.LightningClient, network string) error {
+	info, err := ln.GetInfo(context.Background(), &lnrpc.GetInfoRequest{})
+	if err != nil {
+		return errors.Wrap(err, "getting node information")
+	}
+
+	for _, chain := range info.Chains {
+		if chain.Network != network {
+			return errors.Errorf("the node runs on %s but the configured network is %s",
+				chain.Network, network)
+		}
+	}
+
+	return nil
+}
+
+// AddHoldInvoice creates a hold invoice for the hash provided and returns its payment request.
+// Payments to it are only completed once the invoice is settled with the preimage.
+func (c *client) AddHoldInvoice(
+	ctx context.Context,
+	hash []byte,
+	amountSat uint64,
+	memo string,
+	descriptionHash []byte,
+) (string, error) {
+	resp, err := c.invoices.AddHoldInvoice(ctx, &invoicesrpc.AddHoldInvoiceRequest{
+		Hash:            hash,
+		Memo:            memo,
+		DescriptionHash: descriptionHash,
+		Value:           int64(amountSat),
+		Expiry:          int64(DefaultInvoiceExpiry.Seconds()),
+		Private:         false,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return resp.PaymentRequest, nil
+}
+
 // AddInvoice attempts to add a new invoice to the invoice database.
-func (c *client) AddInvoice(ctx context.Context, amountSat uint64) (*lnrpc.AddInvoiceResponse, error) {
+//
+// Invoices of at least the AMP minimum amount are AMP, the payer can split them in multiple partial
+// payments and they are settled once the full amount arrives.
+//
+// If a description hash is passed, the invoice commits to it instead of the memo, as LNURL-pay
+// requires.
+func (c *client) AddInvoice(
+	ctx context.Context,
+	amountSat uint64,
+	memo string,
+	descriptionHash []byte,
+) (*lnrpc.AddInvoiceResponse, error) {
 	invoice := &lnrpc.Invoice{
-		Memo:    "BTRY",
-		Value:   int64(amountSat),
-		Expiry:  int64(DefaultInvoiceExpiry.Seconds()),
-		Private: false,
+		Memo:            memo,
+		DescriptionHash: descriptionHash,
+		Value:           int64(amountSat),
+		Expiry:          int64(DefaultInvoiceExpiry.Seconds()),
+		Private:         false,
+		IsAmp:           c.ampMinAmount != 0 && amountSat >= c.ampMinAmount,
 	}
 	return c.ln.AddInvoice(ctx, invoice)
 }
 
+// CancelInvoice cancels a hold invoice, failing back any accepted HTLC.
+func (c *client) CancelInvoice(ctx context.Context, hash []byte) error {
+	_, err := c.invoices.CancelInvoice(ctx, &invoicesrpc.CancelInvoiceMsg{PaymentHash: hash})
+	return err
+}
+
 // DecodeInvoice parses the provided encoded invoice and returns a decoded Invoice if it is valid by
 // BOLT-0011 and matches the provided active network.
 func (c *client) DecodeInvoice(ctx context.Context, invoice string) (*lnrpc.PayReq, error) {
+	if err := CheckInvoiceNetwork(c.network, invoice); err != nil {
+		return nil, err
+	}
+
 	return c.ln.DecodePayReq(ctx, &lnrpc.PayReqString{PayReq: invoice})
 }
 
+// EstimateLightningAddressFee requests an invoice of the amount specified to the lightning address
+// and returns the routing fee to its destination, in sats, estimated from the channel graph without
+// probing. The invoice is not paid.
+func (c *client) EstimateLightningAddressFee(ctx context.Context, address string, amountSat int64) (int64, error) {
+	payReq, err := c.resolveLightningAddress(ctx, address, amountSat)
+	if err != nil {
+		return 0, err
+	}
+
+	dest, err := hex.DecodeString(payReq.Destination)
+	if err != nil {
+		return 0, errors.Wrap(err, "decoding destination")
+	}
+
+	resp, err := c.router.EstimateRouteFee(ctx, &routerrpc.RouteFeeRequest{
+		Dest:   dest,
+		AmtSat: amountSat,
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "estimating route fee")
+	}
+
+	// Round up, the fee limits are set in sats
+	return (resp.RoutingFeeMsat + 999) / 1000, nil
+}
+
+// ProbeLightningAddress requests an invoice of the amount specified to the lightning address and
+// looks for a route to its destination in the channel graph gossiped by the network, returning its
+// fee and its likelihood of success. The invoice is not paid.
+func (c *client) ProbeLightningAddress(ctx context.Context, address string, amountSat int64) (RouteProbe, error) {
+	payReq, err := c.resolveLightningAddress(ctx, address, amountSat)
+	if err != nil {
+		return RouteProbe{}, err
+	}
+
+	resp, err := c.ln.QueryRoutes(ctx, &lnrpc.QueryRoutesRequest{
+		PubKey:            payReq.Destination,
+		Amt:               amountSat,
+		FinalCltvDelta:    int32(payReq.CltvExpiry),
+		RouteHints:        payReq.RouteHints,
+		UseMissionControl: true,
+		FeeLimit: &lnrpc.FeeLimit{
+			Limit: &lnrpc.FeeLimit_Fixed{Fixed: amountSat * c.maxFeePPM / 1_000_000},
+		},
+	})
+	if err != nil {
+		return RouteProbe{}, errors.Wrap(err, "querying routes")
+	}
+
+	if len(resp.Routes) == 0 {
+		return RouteProbe{}, errors.New("no route found")
+	}
+
+	return RouteProbe{
+		// Round up, the fee limits are set in sats
+		Fee:                (resp.Routes[0].TotalFeesMsat + 999) / 1000,
+		SuccessProbability: resp.SuccessProb,
+	}, nil
+}
+
 // GetInfo returns general information concerning the lightning node.
 func (c *client) GetInfo(ctx context.Context) (*lnrpc.GetInfoResponse, error) {
 	return c.ln.GetInfo(ctx, &lnrpc.GetInfoRequest{})
 }
 
-// PayInvoice attempts to route a payment to the final destination.
+// ListChannels returns the active channels of the node.
+func (c *client) ListChannels(ctx context.Context) ([]*lnrpc.Channel, error) {
+	resp, err := c.ln.ListChannels(ctx, &lnrpc.ListChannelsRequest{ActiveOnly: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing channels")
+	}
+
+	return resp.Channels, nil
+}
+
+// Network returns the bitcoin network the node runs on.
+func (c *client) Network() string {
+	return c.network
+}
+
+// PayInvoice attempts to route a payment to the final destination. The stream returned reports the
+// status of the payment until it succeeds or fails, and every attempt in flight if inflightUpdates is
+// true. Cancelling ctx closes the stream but doesn't stop the payment.
 func (c *client) PayInvoice(
 	ctx context.Context,
 	invoice *lnrpc.PayReq,
@@ -246,6 +458,74 @@ func (c *client) PayInvoiceSync(
 	return c.ln.SendPaymentSync(ctx, req)
 }
 
+// Rebalance moves liquidity between two channels by paying an invoice to the node itself. The
+// payment leaves through the outgoing channel and comes back through the channel with the last hop
+// peer. It returns the fee paid in satoshis.
+func (c *client) Rebalance(
+	ctx context.Context,
+	outgoingChanID uint64,
+	lastHop string,
+	amountSat, feeSat int64,
+) (int64, error) {
+	if feeSat < 0 {
+		return 0, errors.New("invalid fee")
+	}
+
+	lastHopPubKey, err := hex.DecodeString(lastHop)
+	if err != nil {
+		return 0, errors.Wrap(err, "decoding last hop public key")
+	}
+
+	info, err := c.ln.GetInfo(ctx, &lnrpc.GetInfoRequest{})
+	if err != nil {
+		return 0, errors.Wrap(err, "getting node information")
+	}
+
+	dest, err := hex.DecodeString(info.IdentityPubkey)
+	if err != nil {
+		return 0, errors.Wrap(err, "decoding node public key")
+	}
+
+	invoice, err := c.ln.AddInvoice(ctx, &lnrpc.Invoice{
+		Memo:   "BTRY rebalance",
+		Value:  amountSat,
+		Expiry: 600,
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "adding invoice")
+	}
+
+	stream, err := c.router.SendPaymentV2(ctx, &routerrpc.SendPaymentRequest{
+		Amt:               amountSat,
+		FeeLimitSat:       feeSat,
+		Dest:              dest,
+		PaymentHash:       invoice.RHash,
+		PaymentAddr:       invoice.PaymentAddr,
+		OutgoingChanIds:   []uint64{outgoingChanID},
+		LastHopPubkey:     lastHopPubKey,
+		AllowSelfPayment:  true,
+		NoInflightUpdates: true,
+		TimeoutSeconds:    120,
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "sending payment")
+	}
+
+	for {
+		payment, err := stream.Recv()
+		if err != nil {
+			return 0, errors.Wrap(err, "receiving payment update")
+		}
+
+		switch payment.Status {
+		case lnrpc.Payment_SUCCEEDED:
+			return payment.FeeSat, nil
+		case lnrpc.Payment_FAILED:
+			return 0, errors.Errorf("rebalance failed: %s", payment.FailureReason)
+		}
+	}
+}
+
 // RemoteBalance returns a report on the total remote funds across all open public channels.
 func (c *client) RemoteBalance(ctx context.Context) (int64, error) {
 	resp, err := c.ln.ListChannels(ctx, &lnrpc.ListChannelsRequest{
@@ -264,39 +544,129 @@ func (c *client) RemoteBalance(ctx context.Context) (int64, error) {
 	return remoteBalance, nil
 }
 
+// SendKeysend sends a spontaneous payment to the node carrying the custom records specified and
+// waits for its result.
+func (c *client) SendKeysend(
+	ctx context.Context,
+	destination string,
+	amountSat, feeSat int64,
+	records map[uint64][]byte,
+) error {
+	if feeSat < 0 {
+		return errors.New("invalid fee")
+	}
+
+	dest, err := hex.DecodeString(destination)
+	if err != nil {
+		return errors.Wrap(err, "decoding destination")
+	}
+
+	preimage := make([]byte, 32)
+	if _, err := rand.Read(preimage); err != nil {
+		return errors.Wrap(err, "generating preimage")
+	}
+	paymentHash := sha256.Sum256(preimage)
+
+	customRecords := make(map[uint64][]byte, len(records)+1)
+	for recordType, value := range records {
+		customRecords[recordType] = value
+	}
+	customRecords[record.KeySendType] = preimage
+
+	req := &routerrpc.SendPaymentRequest{
+		Amt:               amountSat,
+		FeeLimitSat:       feeSat,
+		Dest:              dest,
+		PaymentHash:       paymentHash[:],
+		DestCustomRecords: customRecords,
+		DestFeatures:      []lnrpc.FeatureBit{lnrpc.FeatureBit_TLV_ONION_OPT},
+		NoInflightUpdates: true,
+		TimeoutSeconds:    60,
+	}
+	stream, err := c.router.SendPaymentV2(ctx, req)
+	if err != nil {
+		return errors.Wrap(err, "sending keysend payment")
+	}
+
+	for {
+		payment, err := stream.Recv()
+		if err != nil {
+			return errors.Wrap(err, "receiving keysend payment updates")
+		}
+
+		switch payment.Status {
+		case lnrpc.Payment_SUCCEEDED:
+			return nil
+		case lnrpc.Payment_FAILED:
+			return errors.Errorf("keysend payment failed: %s", payment.FailureReason)
+		}
+	}
+}
+
 // SendToLightningAddress uses the LNURL protocol to request invoices based on the address provided
 // and it pays them. It returns the payment preimage or an error if it fails.
+//
+// Only the errors resolving the address may be transient, nothing was paid when they are returned.
 func (c *client) SendToLightningAddress(ctx context.Context, address string, amountSat int64) (string, error) {
+	payReq, err := c.resolveLightningAddress(ctx, address, amountSat)
+	if err != nil {
+		return "", Classify(err)
+	}
+
+	fee := amountSat * c.maxFeePPM / 1_000_000
+	resp, err := c.PayInvoiceSync(ctx, payReq, fee)
+	if err != nil {
+		// The payment may be in flight, sending it again would request another invoice and pay twice
+		return "", fault.NewPermanent(err)
+	}
+
+	if resp.PaymentError != "" {
+		return "", fault.NewPermanent(errors.New(resp.PaymentError))
+	}
+
+	return hex.EncodeToString(resp.PaymentPreimage), nil
+}
+
+// resolveLightningAddress uses the LNURL protocol to request an invoice of the amount specified to
+// the address.
+func (c *client) resolveLightningAddress(ctx context.Context, address string, amountSat int64) (*lnrpc.PayReq, error) {
 	callback, err := getPayCallback(c.torClient, address, amountSat)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	invoice, err := getInvoice(c.torClient, callback)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	payReq, err := c.DecodeInvoice(ctx, invoice)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if payReq.NumSatoshis != amountSat {
-		return "", errors.New("invalid invoice amount")
+		return nil, errors.New("invalid invoice amount")
 	}
 
-	fee := amountSat * c.maxFeePPM / 1_000_000
-	resp, err := c.PayInvoiceSync(ctx, payReq, fee)
+	return payReq, nil
+}
+
+// SignMessage signs the message with the node identity key. The zbase32 signature returned can be
+// verified with the node public key using lnd's verifymessage.
+func (c *client) SignMessage(ctx context.Context, message []byte) (string, error) {
+	resp, err := c.ln.SignMessage(ctx, &lnrpc.SignMessageRequest{Msg: message})
 	if err != nil {
-		return "", err
+		return "", errors.Wrap(err, "signing message")
 	}
 
-	if resp.PaymentError != "" {
-		return "", errors.New(resp.PaymentError)
-	}
+	return resp.Signature, nil
+}
 
-	return hex.EncodeToString(resp.PaymentPreimage), nil
+// SettleInvoice settles an accepted hold invoice with its preimage.
+func (c *client) SettleInvoice(ctx context.Context, preimage []byte) error {
+	_, err := c.invoices.SettleInvoice(ctx, &invoicesrpc.SettleInvoiceMsg{Preimage: preimage})
+	return err
 }
 
 // SubscribeBlocks creates a uni-directional stream from the server to the client in which
@@ -315,8 +685,15 @@ func (c *client) SubscribeChannelEvents(ctx context.Context) (Stream[*lnrpc.Chan
 
 // SubscribeInvoices returns a uni-directional stream (server -> client) for notifying the client of
 // newly added/settled invoices.
-func (c *client) SubscribeInvoices(ctx context.Context) (Stream[*lnrpc.Invoice], error) {
-	return c.ln.SubscribeInvoices(ctx, &lnrpc.InvoiceSubscription{})
+//
+// If the settle index is not zero, the invoices settled after it are sent first.
+func (c *client) SubscribeInvoices(ctx context.Context, settleIndex uint64) (Stream[*lnrpc.Invoice], error) {
+	return c.ln.SubscribeInvoices(ctx, &lnrpc.InvoiceSubscription{SettleIndex: settleIndex})
+}
+
+// SubscribeSingleInvoice returns an update stream for every state change of the invoice.
+func (c *client) SubscribeSingleInvoice(ctx context.Context, hash []byte) (Stream[*lnrpc.Invoice], error) {
+	return c.invoices.SubscribeSingleInvoice(ctx, &invoicesrpc.SubscribeSingleInvoiceRequest{RHash: hash})
 }
 
 // SubscribePayments returns an update stream for every payment that is not in a terminal state.
@@ -18,19 +18,9 @@ func getPayCallback(client *http.Client, address string, amountSat int64) (strin
 	}
 
 	uri := fmt.Sprintf("https://%s/.well-known/lnurlp/%s", host, name)
-	callback, err := url.Parse(uri)
+	resp, err := client.Get(uri)
 	if err != nil {
-		return "", errors.Wrap(err, "parsing LNURL callback")
-	}
-
-	amountMsat := amountSat * 1000
-	query := callback.Query()
-	query.Set("amount", strconv.FormatInt(amountMsat, 10))
-	callback.RawQuery = query.Encode()
-
-	resp, err := client.Get(callback.String())
-	if err != nil {
-		return "", errors.Wrapf(err, "calling %s", callback)
+		return "", errors.Wrapf(err, "calling %s", uri)
 	}
 
 	var params lnurl.LNURLPayParams
@@ -43,6 +33,7 @@ func getPayCallback(client *http.Client, address string, amountSat int64) (strin
 		return "", errors.New(params.Reason)
 	}
 
+	amountMsat := amountSat * 1000
 	if amountMsat < params.MinSendable {
 		return "", errors.Errorf("amount %d is lower than the minimum allowed %d",
 			amountSat,
@@ -57,7 +48,16 @@ func getPayCallback(client *http.Client, address string, amountSat int64) (strin
 		)
 	}
 
-	return params.Callback, nil
+	callback, err := url.Parse(params.Callback)
+	if err != nil {
+		return "", errors.Wrap(err, "parsing LNURL callback")
+	}
+
+	query := callback.Query()
+	query.Set("amount", strconv.FormatInt(amountMsat, 10))
+	callback.RawQuery = query.Encode()
+
+	return callback.String(), nil
 }
 
 func getInvoice(client *http.Client, callback string) (string, error) {
@@ -72,5 +72,13 @@ func getInvoice(client *http.Client, callback string) (string, error) {
 	}
 	defer resp.Body.Close()
 
+	if values.Status == "ERROR" {
+		return "", errors.New(values.Reason)
+	}
+
+	if values.PR == "" {
+		return "", errors.New("no invoice returned")
+	}
+
 	return values.PR, nil
 }
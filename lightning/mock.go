@@ -1,3 +1,5 @@
+// Code generated by go generate; DO NOT EDIT.
+
 package lightning
 
 import (
@@ -8,130 +10,448 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
-// BlockedStreamMock is a stream of events that blocks to let the test execution end without failures.
-type BlockedStreamMock[T any] struct{}
-
-// Recv blocks forever.
-func (s BlockedStreamMock[T]) Recv() (T, error) {
-	// Block execution to let tests run
-	block := make(chan struct{})
-	<-block
-	var v T
-	return v, nil
-}
-
-// ClientMock is a mocked implementation of a lightning client.
+// ClientMock is a mocked implementation of Client.
 type ClientMock struct {
 	mock.Mock
 }
 
-// NewClientMock returns a mocked lightning network node client.
+// NewClientMock returns a mocked Client.
 func NewClientMock() *ClientMock {
 	return &ClientMock{}
 }
 
+// AddHoldInvoice mock.
+func (m *ClientMock) AddHoldInvoice(ctx context.Context, hash []byte, amountSat uint64, memo string, descriptionHash []byte) (string, error) {
+	args := m.Called(ctx, hash, amountSat, memo, descriptionHash)
+	var r0 string
+	if v := args.Get(0); v != nil {
+		r0 = v.(string)
+	}
+	return r0, args.Error(1)
+}
+
 // AddInvoice mock.
-func (c *ClientMock) AddInvoice(ctx context.Context, amount uint64) (*lnrpc.AddInvoiceResponse, error) {
-	args := c.Called(ctx, amount)
+func (m *ClientMock) AddInvoice(ctx context.Context, amountSat uint64, memo string, descriptionHash []byte) (*lnrpc.AddInvoiceResponse, error) {
+	args := m.Called(ctx, amountSat, memo, descriptionHash)
 	var r0 *lnrpc.AddInvoiceResponse
-	v0 := args.Get(0)
-	if v0 != nil {
-		r0 = v0.(*lnrpc.AddInvoiceResponse)
+	if v := args.Get(0); v != nil {
+		r0 = v.(*lnrpc.AddInvoiceResponse)
 	}
 	return r0, args.Error(1)
 }
 
+// CancelInvoice mock.
+func (m *ClientMock) CancelInvoice(ctx context.Context, hash []byte) error {
+	args := m.Called(ctx, hash)
+	return args.Error(0)
+}
+
 // DecodeInvoice mock.
-func (c *ClientMock) DecodeInvoice(ctx context.Context, invoice string) (*lnrpc.PayReq, error) {
-	args := c.Called(ctx, invoice)
+func (m *ClientMock) DecodeInvoice(ctx context.Context, invoice string) (*lnrpc.PayReq, error) {
+	args := m.Called(ctx, invoice)
 	var r0 *lnrpc.PayReq
-	v0 := args.Get(0)
-	if v0 != nil {
-		r0 = v0.(*lnrpc.PayReq)
+	if v := args.Get(0); v != nil {
+		r0 = v.(*lnrpc.PayReq)
+	}
+	return r0, args.Error(1)
+}
+
+// EstimateLightningAddressFee mock.
+func (m *ClientMock) EstimateLightningAddressFee(ctx context.Context, address string, amountSat int64) (int64, error) {
+	args := m.Called(ctx, address, amountSat)
+	var r0 int64
+	if v := args.Get(0); v != nil {
+		r0 = v.(int64)
 	}
 	return r0, args.Error(1)
 }
 
 // GetInfo mock.
-func (c *ClientMock) GetInfo(ctx context.Context) (*lnrpc.GetInfoResponse, error) {
-	args := c.Called(ctx)
+func (m *ClientMock) GetInfo(ctx context.Context) (*lnrpc.GetInfoResponse, error) {
+	args := m.Called(ctx)
 	var r0 *lnrpc.GetInfoResponse
-	v0 := args.Get(0)
-	if v0 != nil {
-		r0 = v0.(*lnrpc.GetInfoResponse)
+	if v := args.Get(0); v != nil {
+		r0 = v.(*lnrpc.GetInfoResponse)
+	}
+	return r0, args.Error(1)
+}
+
+// ListChannels mock.
+func (m *ClientMock) ListChannels(ctx context.Context) ([]*lnrpc.Channel, error) {
+	args := m.Called(ctx)
+	var r0 []*lnrpc.Channel
+	if v := args.Get(0); v != nil {
+		r0 = v.([]*lnrpc.Channel)
 	}
 	return r0, args.Error(1)
 }
 
+// Network mock.
+func (m *ClientMock) Network() string {
+	args := m.Called()
+	var r0 string
+	if v := args.Get(0); v != nil {
+		r0 = v.(string)
+	}
+	return r0
+}
+
 // PayInvoice mock.
-func (c *ClientMock) PayInvoice(ctx context.Context, invoice *lnrpc.PayReq, feeSat int64, inflightUpdates bool) (Stream[*lnrpc.Payment], error) {
-	args := c.Called(ctx, invoice, feeSat, inflightUpdates)
+func (m *ClientMock) PayInvoice(ctx context.Context, invoice *lnrpc.PayReq, feeSat int64, inflightUpdates bool) (Stream[*lnrpc.Payment], error) {
+	args := m.Called(ctx, invoice, feeSat, inflightUpdates)
 	var r0 Stream[*lnrpc.Payment]
-	v0 := args.Get(0)
-	if v0 != nil {
-		r0 = v0.(Stream[*lnrpc.Payment])
+	if v := args.Get(0); v != nil {
+		r0 = v.(Stream[*lnrpc.Payment])
+	}
+	return r0, args.Error(1)
+}
+
+// ProbeLightningAddress mock.
+func (m *ClientMock) ProbeLightningAddress(ctx context.Context, address string, amountSat int64) (RouteProbe, error) {
+	args := m.Called(ctx, address, amountSat)
+	var r0 RouteProbe
+	if v := args.Get(0); v != nil {
+		r0 = v.(RouteProbe)
+	}
+	return r0, args.Error(1)
+}
+
+// Rebalance mock.
+func (m *ClientMock) Rebalance(ctx context.Context, outgoingChanID uint64, lastHop string, amountSat int64, feeSat int64) (int64, error) {
+	args := m.Called(ctx, outgoingChanID, lastHop, amountSat, feeSat)
+	var r0 int64
+	if v := args.Get(0); v != nil {
+		r0 = v.(int64)
 	}
 	return r0, args.Error(1)
 }
 
 // RemoteBalance mock.
-func (c *ClientMock) RemoteBalance(ctx context.Context) (int64, error) {
-	args := c.Called(ctx)
-	remoteBalance := args.Get(0).(int64)
-	return remoteBalance, args.Error(1)
+func (m *ClientMock) RemoteBalance(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	var r0 int64
+	if v := args.Get(0); v != nil {
+		r0 = v.(int64)
+	}
+	return r0, args.Error(1)
+}
+
+// SendKeysend mock.
+func (m *ClientMock) SendKeysend(ctx context.Context, destination string, amountSat int64, feeSat int64, records map[uint64][]byte) error {
+	args := m.Called(ctx, destination, amountSat, feeSat, records)
+	return args.Error(0)
 }
 
 // SendToLightningAddress mock.
-func (c *ClientMock) SendToLightningAddress(ctx context.Context, address string, amountSat int64) (string, error) {
-	args := c.Called(ctx, address, amountSat)
+func (m *ClientMock) SendToLightningAddress(ctx context.Context, address string, amountSat int64) (string, error) {
+	args := m.Called(ctx, address, amountSat)
+	var r0 string
+	if v := args.Get(0); v != nil {
+		r0 = v.(string)
+	}
+	return r0, args.Error(1)
+}
+
+// SettleInvoice mock.
+func (m *ClientMock) SettleInvoice(ctx context.Context, preimage []byte) error {
+	args := m.Called(ctx, preimage)
+	return args.Error(0)
+}
+
+// SignMessage mock.
+func (m *ClientMock) SignMessage(ctx context.Context, message []byte) (string, error) {
+	args := m.Called(ctx, message)
 	var r0 string
-	v0 := args.Get(0)
-	if v0 != nil {
-		r0 = v0.(string)
+	if v := args.Get(0); v != nil {
+		r0 = v.(string)
 	}
 	return r0, args.Error(1)
 }
 
 // SubscribeBlocks mock.
-func (c *ClientMock) SubscribeBlocks(ctx context.Context) (Stream[*chainrpc.BlockEpoch], error) {
-	args := c.Called(ctx)
+func (m *ClientMock) SubscribeBlocks(ctx context.Context) (Stream[*chainrpc.BlockEpoch], error) {
+	args := m.Called(ctx)
 	var r0 Stream[*chainrpc.BlockEpoch]
-	v0 := args.Get(0)
-	if v0 != nil {
-		r0 = v0.(Stream[*chainrpc.BlockEpoch])
+	if v := args.Get(0); v != nil {
+		r0 = v.(Stream[*chainrpc.BlockEpoch])
 	}
 	return r0, args.Error(1)
 }
 
 // SubscribeChannelEvents mock.
-func (c *ClientMock) SubscribeChannelEvents(ctx context.Context) (Stream[*lnrpc.ChannelEventUpdate], error) {
-	args := c.Called(ctx)
+func (m *ClientMock) SubscribeChannelEvents(ctx context.Context) (Stream[*lnrpc.ChannelEventUpdate], error) {
+	args := m.Called(ctx)
 	var r0 Stream[*lnrpc.ChannelEventUpdate]
-	v0 := args.Get(0)
-	if v0 != nil {
-		r0 = v0.(Stream[*lnrpc.ChannelEventUpdate])
+	if v := args.Get(0); v != nil {
+		r0 = v.(Stream[*lnrpc.ChannelEventUpdate])
 	}
 	return r0, args.Error(1)
 }
 
 // SubscribeInvoices mock.
-func (c *ClientMock) SubscribeInvoices(ctx context.Context) (Stream[*lnrpc.Invoice], error) {
-	args := c.Called(ctx)
+func (m *ClientMock) SubscribeInvoices(ctx context.Context, settleIndex uint64) (Stream[*lnrpc.Invoice], error) {
+	args := m.Called(ctx, settleIndex)
 	var r0 Stream[*lnrpc.Invoice]
-	v0 := args.Get(0)
-	if v0 != nil {
-		r0 = v0.(Stream[*lnrpc.Invoice])
+	if v := args.Get(0); v != nil {
+		r0 = v.(Stream[*lnrpc.Invoice])
+	}
+	return r0, args.Error(1)
+}
+
+// SubscribePayments mock.
+func (m *ClientMock) SubscribePayments(ctx context.Context) (Stream[*lnrpc.Payment], error) {
+	args := m.Called(ctx)
+	var r0 Stream[*lnrpc.Payment]
+	if v := args.Get(0); v != nil {
+		r0 = v.(Stream[*lnrpc.Payment])
+	}
+	return r0, args.Error(1)
+}
+
+// SubscribeSingleInvoice mock.
+func (m *ClientMock) SubscribeSingleInvoice(ctx context.Context, hash []byte) (Stream[*lnrpc.Invoice], error) {
+	args := m.Called(ctx, hash)
+	var r0 Stream[*lnrpc.Invoice]
+	if v := args.Get(0); v != nil {
+		r0 = v.(Stream[*lnrpc.Invoice])
+	}
+	return r0, args.Error(1)
+}
+
+// ChainNotifierMock is a mocked implementation of ChainNotifier.
+type ChainNotifierMock struct {
+	mock.Mock
+}
+
+// NewChainNotifierMock returns a mocked ChainNotifier.
+func NewChainNotifierMock() *ChainNotifierMock {
+	return &ChainNotifierMock{}
+}
+
+// SubscribeBlocks mock.
+func (m *ChainNotifierMock) SubscribeBlocks(ctx context.Context) (Stream[*chainrpc.BlockEpoch], error) {
+	args := m.Called(ctx)
+	var r0 Stream[*chainrpc.BlockEpoch]
+	if v := args.Get(0); v != nil {
+		r0 = v.(Stream[*chainrpc.BlockEpoch])
+	}
+	return r0, args.Error(1)
+}
+
+// InvoiceManagerMock is a mocked implementation of InvoiceManager.
+type InvoiceManagerMock struct {
+	mock.Mock
+}
+
+// NewInvoiceManagerMock returns a mocked InvoiceManager.
+func NewInvoiceManagerMock() *InvoiceManagerMock {
+	return &InvoiceManagerMock{}
+}
+
+// AddHoldInvoice mock.
+func (m *InvoiceManagerMock) AddHoldInvoice(ctx context.Context, hash []byte, amountSat uint64, memo string, descriptionHash []byte) (string, error) {
+	args := m.Called(ctx, hash, amountSat, memo, descriptionHash)
+	var r0 string
+	if v := args.Get(0); v != nil {
+		r0 = v.(string)
+	}
+	return r0, args.Error(1)
+}
+
+// AddInvoice mock.
+func (m *InvoiceManagerMock) AddInvoice(ctx context.Context, amountSat uint64, memo string, descriptionHash []byte) (*lnrpc.AddInvoiceResponse, error) {
+	args := m.Called(ctx, amountSat, memo, descriptionHash)
+	var r0 *lnrpc.AddInvoiceResponse
+	if v := args.Get(0); v != nil {
+		r0 = v.(*lnrpc.AddInvoiceResponse)
+	}
+	return r0, args.Error(1)
+}
+
+// CancelInvoice mock.
+func (m *InvoiceManagerMock) CancelInvoice(ctx context.Context, hash []byte) error {
+	args := m.Called(ctx, hash)
+	return args.Error(0)
+}
+
+// DecodeInvoice mock.
+func (m *InvoiceManagerMock) DecodeInvoice(ctx context.Context, invoice string) (*lnrpc.PayReq, error) {
+	args := m.Called(ctx, invoice)
+	var r0 *lnrpc.PayReq
+	if v := args.Get(0); v != nil {
+		r0 = v.(*lnrpc.PayReq)
+	}
+	return r0, args.Error(1)
+}
+
+// SettleInvoice mock.
+func (m *InvoiceManagerMock) SettleInvoice(ctx context.Context, preimage []byte) error {
+	args := m.Called(ctx, preimage)
+	return args.Error(0)
+}
+
+// SubscribeInvoices mock.
+func (m *InvoiceManagerMock) SubscribeInvoices(ctx context.Context, settleIndex uint64) (Stream[*lnrpc.Invoice], error) {
+	args := m.Called(ctx, settleIndex)
+	var r0 Stream[*lnrpc.Invoice]
+	if v := args.Get(0); v != nil {
+		r0 = v.(Stream[*lnrpc.Invoice])
+	}
+	return r0, args.Error(1)
+}
+
+// SubscribeSingleInvoice mock.
+func (m *InvoiceManagerMock) SubscribeSingleInvoice(ctx context.Context, hash []byte) (Stream[*lnrpc.Invoice], error) {
+	args := m.Called(ctx, hash)
+	var r0 Stream[*lnrpc.Invoice]
+	if v := args.Get(0); v != nil {
+		r0 = v.(Stream[*lnrpc.Invoice])
+	}
+	return r0, args.Error(1)
+}
+
+// NodeInfoMock is a mocked implementation of NodeInfo.
+type NodeInfoMock struct {
+	mock.Mock
+}
+
+// NewNodeInfoMock returns a mocked NodeInfo.
+func NewNodeInfoMock() *NodeInfoMock {
+	return &NodeInfoMock{}
+}
+
+// GetInfo mock.
+func (m *NodeInfoMock) GetInfo(ctx context.Context) (*lnrpc.GetInfoResponse, error) {
+	args := m.Called(ctx)
+	var r0 *lnrpc.GetInfoResponse
+	if v := args.Get(0); v != nil {
+		r0 = v.(*lnrpc.GetInfoResponse)
+	}
+	return r0, args.Error(1)
+}
+
+// ListChannels mock.
+func (m *NodeInfoMock) ListChannels(ctx context.Context) ([]*lnrpc.Channel, error) {
+	args := m.Called(ctx)
+	var r0 []*lnrpc.Channel
+	if v := args.Get(0); v != nil {
+		r0 = v.([]*lnrpc.Channel)
+	}
+	return r0, args.Error(1)
+}
+
+// Network mock.
+func (m *NodeInfoMock) Network() string {
+	args := m.Called()
+	var r0 string
+	if v := args.Get(0); v != nil {
+		r0 = v.(string)
+	}
+	return r0
+}
+
+// RemoteBalance mock.
+func (m *NodeInfoMock) RemoteBalance(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	var r0 int64
+	if v := args.Get(0); v != nil {
+		r0 = v.(int64)
+	}
+	return r0, args.Error(1)
+}
+
+// SignMessage mock.
+func (m *NodeInfoMock) SignMessage(ctx context.Context, message []byte) (string, error) {
+	args := m.Called(ctx, message)
+	var r0 string
+	if v := args.Get(0); v != nil {
+		r0 = v.(string)
+	}
+	return r0, args.Error(1)
+}
+
+// SubscribeChannelEvents mock.
+func (m *NodeInfoMock) SubscribeChannelEvents(ctx context.Context) (Stream[*lnrpc.ChannelEventUpdate], error) {
+	args := m.Called(ctx)
+	var r0 Stream[*lnrpc.ChannelEventUpdate]
+	if v := args.Get(0); v != nil {
+		r0 = v.(Stream[*lnrpc.ChannelEventUpdate])
+	}
+	return r0, args.Error(1)
+}
+
+// PaymentSenderMock is a mocked implementation of PaymentSender.
+type PaymentSenderMock struct {
+	mock.Mock
+}
+
+// NewPaymentSenderMock returns a mocked PaymentSender.
+func NewPaymentSenderMock() *PaymentSenderMock {
+	return &PaymentSenderMock{}
+}
+
+// EstimateLightningAddressFee mock.
+func (m *PaymentSenderMock) EstimateLightningAddressFee(ctx context.Context, address string, amountSat int64) (int64, error) {
+	args := m.Called(ctx, address, amountSat)
+	var r0 int64
+	if v := args.Get(0); v != nil {
+		r0 = v.(int64)
+	}
+	return r0, args.Error(1)
+}
+
+// PayInvoice mock.
+func (m *PaymentSenderMock) PayInvoice(ctx context.Context, invoice *lnrpc.PayReq, feeSat int64, inflightUpdates bool) (Stream[*lnrpc.Payment], error) {
+	args := m.Called(ctx, invoice, feeSat, inflightUpdates)
+	var r0 Stream[*lnrpc.Payment]
+	if v := args.Get(0); v != nil {
+		r0 = v.(Stream[*lnrpc.Payment])
+	}
+	return r0, args.Error(1)
+}
+
+// ProbeLightningAddress mock.
+func (m *PaymentSenderMock) ProbeLightningAddress(ctx context.Context, address string, amountSat int64) (RouteProbe, error) {
+	args := m.Called(ctx, address, amountSat)
+	var r0 RouteProbe
+	if v := args.Get(0); v != nil {
+		r0 = v.(RouteProbe)
+	}
+	return r0, args.Error(1)
+}
+
+// Rebalance mock.
+func (m *PaymentSenderMock) Rebalance(ctx context.Context, outgoingChanID uint64, lastHop string, amountSat int64, feeSat int64) (int64, error) {
+	args := m.Called(ctx, outgoingChanID, lastHop, amountSat, feeSat)
+	var r0 int64
+	if v := args.Get(0); v != nil {
+		r0 = v.(int64)
+	}
+	return r0, args.Error(1)
+}
+
+// SendKeysend mock.
+func (m *PaymentSenderMock) SendKeysend(ctx context.Context, destination string, amountSat int64, feeSat int64, records map[uint64][]byte) error {
+	args := m.Called(ctx, destination, amountSat, feeSat, records)
+	return args.Error(0)
+}
+
+// SendToLightningAddress mock.
+func (m *PaymentSenderMock) SendToLightningAddress(ctx context.Context, address string, amountSat int64) (string, error) {
+	args := m.Called(ctx, address, amountSat)
+	var r0 string
+	if v := args.Get(0); v != nil {
+		r0 = v.(string)
 	}
 	return r0, args.Error(1)
 }
 
 // SubscribePayments mock.
-func (c *ClientMock) SubscribePayments(ctx context.Context) (Stream[*lnrpc.Payment], error) {
-	args := c.Called(ctx)
+func (m *PaymentSenderMock) SubscribePayments(ctx context.Context) (Stream[*lnrpc.Payment], error) {
+	args := m.Called(ctx)
 	var r0 Stream[*lnrpc.Payment]
-	v0 := args.Get(0)
-	if v0 != nil {
-		r0 = v0.(Stream[*lnrpc.Payment])
+	if v := args.Get(0); v != nil {
+		r0 = v.(Stream[*lnrpc.Payment])
 	}
 	return r0, args.Error(1)
 }
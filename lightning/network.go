@@ -0,0 +1,52 @@
+package lightning
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/pkg/errors"
+)
+
+// invoicePrefixes are the human-readable prefixes of the BOLT 11 invoices of each network.
+var invoicePrefixes = map[string]string{
+	config.NetworkMainnet: "lnbc",
+	config.NetworkTestnet: "lntb",
+	config.NetworkSignet:  "lntbs",
+	config.NetworkRegtest: "lnbcrt",
+}
+
+// defaultNetwork returns mainnet if the network is empty.
+func defaultNetwork(network string) string {
+	if network == "" {
+		return config.NetworkMainnet
+	}
+	return network
+}
+
+// ErrWrongNetwork is returned when an invoice belongs to a network other than the node's.
+var ErrWrongNetwork = errors.New("invoice is for another network")
+
+// InvoicePrefix returns the prefix of the invoices of the network. Mainnet is used if it's empty.
+func InvoicePrefix(network string) string {
+	return invoicePrefixes[defaultNetwork(network)]
+}
+
+// CheckInvoiceNetwork returns ErrWrongNetwork if the invoice is not for the network specified.
+//
+// Prefixes can't be compared directly as some contain others (lnbc and lnbcrt), the letters
+// preceding the amount or the bech32 separator are taken instead.
+func CheckInvoiceNetwork(network, invoice string) error {
+	invoice = strings.TrimPrefix(strings.ToLower(invoice), "lightning:")
+	end := strings.IndexFunc(invoice, unicode.IsDigit)
+	if end == -1 {
+		return errors.New("invalid invoice")
+	}
+
+	if prefix := InvoicePrefix(network); invoice[:end] != prefix {
+		return errors.Wrapf(ErrWrongNetwork, "%s invoices start with %s", defaultNetwork(network), prefix)
+	}
+
+	return nil
+}
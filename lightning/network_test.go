@@ -0,0 +1,41 @@
+package lightning
+
+import (
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckInvoiceNetwork(t *testing.T) {
+	cases := []struct {
+		desc    string
+		network string
+		invoice string
+		fail    bool
+	}{
+		{desc: "Mainnet", network: config.NetworkMainnet, invoice: "lnbc2500u1pvjluez"},
+		{desc: "Default network", invoice: "lnbc1pvjluez"},
+		{desc: "Uppercase", network: config.NetworkMainnet, invoice: "LNBC2500U1PVJLUEZ"},
+		{desc: "URI", network: config.NetworkTestnet, invoice: "lightning:lntb20m1pvjluez"},
+		{desc: "Signet", network: config.NetworkSignet, invoice: "lntbs10u1pvjluez"},
+		{desc: "Regtest", network: config.NetworkRegtest, invoice: "lnbcrt500n1pvjluez"},
+		{desc: "Regtest on mainnet", network: config.NetworkMainnet, invoice: "lnbcrt500n1pvjluez", fail: true},
+		{desc: "Mainnet on regtest", network: config.NetworkRegtest, invoice: "lnbc500n1pvjluez", fail: true},
+		{desc: "Signet on testnet", network: config.NetworkTestnet, invoice: "lntbs10u1pvjluez", fail: true},
+		{desc: "Testnet on signet", network: config.NetworkSignet, invoice: "lntb10u1pvjluez", fail: true},
+		{desc: "Invalid", network: config.NetworkMainnet, invoice: "invoice", fail: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := CheckInvoiceNetwork(tc.network, tc.invoice)
+			if tc.fail {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
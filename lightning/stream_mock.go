@@ -0,0 +1,13 @@
+package lightning
+
+// BlockedStreamMock is a stream of events that blocks to let the test execution end without failures.
+type BlockedStreamMock[T any] struct{}
+
+// Recv blocks forever.
+func (s BlockedStreamMock[T]) Recv() (T, error) {
+	// Block execution to let tests run
+	block := make(chan struct{})
+	<-block
+	var v T
+	return v, nil
+}
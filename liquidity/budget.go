@@ -0,0 +1,79 @@
+package liquidity
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+)
+
+// budget keeps track of the fees paid and the amount moved by the liquidity actions in the current
+// period.
+type budget struct {
+	now         func() time.Time
+	periodStart time.Time
+	period      time.Duration
+	maxFees     uint64
+	maxAmount   uint64
+	fees        uint64
+	amount      uint64
+	mu          sync.Mutex
+}
+
+func newBudget(config config.LiquidityBudget, now func() time.Time) *budget {
+	return &budget{
+		now:         now,
+		periodStart: now(),
+		period:      config.Period,
+		maxFees:     config.MaxFees,
+		maxAmount:   config.MaxAmount,
+	}
+}
+
+// limit returns the amount that can be moved and the maximum fee that can be paid for it, taking
+// into account what's left of the budget.
+func (b *budget) limit(amount, maxFeePPM uint64) (uint64, uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.reset()
+
+	if b.maxAmount != 0 {
+		amount = min(amount, remaining(b.maxAmount, b.amount))
+	}
+
+	maxFee := remaining(b.maxFees, b.fees)
+	if maxFeePPM != 0 {
+		maxFee = min(maxFee, amount*maxFeePPM/1_000_000)
+	}
+
+	return amount, maxFee
+}
+
+// spend charges the budget with the amount moved and the fees paid.
+func (b *budget) spend(amount, fees uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.reset()
+	b.amount += amount
+	b.fees += fees
+}
+
+// reset starts a new period if the current one is over. The mutex must be held by the caller.
+func (b *budget) reset() {
+	if b.now().Sub(b.periodStart) < b.period {
+		return
+	}
+
+	b.periodStart = b.now()
+	b.fees = 0
+	b.amount = 0
+}
+
+func remaining(limit, spent uint64) uint64 {
+	if spent >= limit {
+		return 0
+	}
+	return limit - spent
+}
@@ -0,0 +1,193 @@
+package liquidity
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/pkg/errors"
+)
+
+// defaultForecastRounds is the number of rounds whose prize pools are used to forecast the inbound
+// liquidity needed when it's not configured.
+const defaultForecastRounds = 6
+
+// InboundAnalysis classifies the bet invoices that expired without being paid by the inbound
+// liquidity the node had to receive them.
+//
+// Invoices larger than the inbound liquidity of every channel could only be paid with multi-part
+// payments, which many wallets don't support or fail to route, and the ones larger than the total
+// inbound liquidity couldn't be paid at all. The rest are considered abandoned by the bettors.
+type InboundAnalysis struct {
+	Inbound uint64 `json:"inbound"`
+	// LargestChannel is the highest inbound liquidity of a single channel
+	LargestChannel uint64 `json:"largest_channel"`
+	// Forecast is the biggest prize pool of the last rounds
+	Forecast uint64 `json:"forecast"`
+	// LargestFailure is the amount of the largest invoice that failed for lack of inbound liquidity
+	LargestFailure     uint64 `json:"largest_failure"`
+	NoRoute            uint64 `json:"no_route"`
+	NoRouteAmount      uint64 `json:"no_route_amount"`
+	Insufficient       uint64 `json:"insufficient"`
+	InsufficientAmount uint64 `json:"insufficient_amount"`
+	AbandonedAmount    uint64 `json:"abandoned_amount"`
+}
+
+// Suggestion returns the inbound liquidity the node should acquire in a new channel, zero if it
+// has enough.
+//
+// The node should be able to receive the bets it missed on top of the current inbound liquidity,
+// and at least the biggest recent prize pool. The channel must also be large enough to receive the
+// largest bet that failed through a single path.
+func (a InboundAnalysis) Suggestion() uint64 {
+	target := max(a.Forecast, a.Inbound+a.InsufficientAmount)
+
+	var deficit uint64
+	if target > a.Inbound {
+		deficit = target - a.Inbound
+	}
+
+	if a.LargestFailure > a.LargestChannel {
+		return max(deficit, a.LargestFailure)
+	}
+	return deficit
+}
+
+func (a InboundAnalysis) String() string {
+	return fmt.Sprintf("%d bets of %d sats were larger than the inbound liquidity of every channel, "+
+		"%d bets of %d sats were larger than the total inbound liquidity (%d sats) and the biggest "+
+		"recent prize pool was %d sats", a.NoRoute, a.NoRouteAmount, a.Insufficient,
+		a.InsufficientAmount, a.Inbound, a.Forecast)
+}
+
+func analyzeInbound(channels []*lnrpc.Channel, expired []db.Invoice, forecast uint64) InboundAnalysis {
+	analysis := InboundAnalysis{Forecast: forecast}
+	for _, channel := range channels {
+		if !channel.Active {
+			continue
+		}
+
+		inbound := uint64(channel.RemoteBalance)
+		analysis.Inbound += inbound
+		analysis.LargestChannel = max(analysis.LargestChannel, inbound)
+	}
+
+	for _, invoice := range expired {
+		switch {
+		case invoice.Amount > analysis.Inbound:
+			analysis.Insufficient++
+			analysis.InsufficientAmount += invoice.Amount
+		case invoice.Amount > analysis.LargestChannel:
+			analysis.NoRoute++
+			analysis.NoRouteAmount += invoice.Amount
+		default:
+			analysis.AbandonedAmount += invoice.Amount
+			continue
+		}
+		analysis.LargestFailure = max(analysis.LargestFailure, invoice.Amount)
+	}
+
+	return analysis
+}
+
+// checkInbound analyzes the bets missed since the last check and suggests or orders the inbound
+// liquidity to receive them and the ones of the next rounds.
+func (m *Manager) checkInbound(ctx context.Context, channels []*lnrpc.Channel) error {
+	since := time.Now().Add(-m.inbound.Window)
+	// Failures before the last order are expected to be solved by it
+	if m.lastOrder.After(since) {
+		since = m.lastOrder
+	}
+
+	expired, err := m.db.Invoices.ListExpired(since.Unix())
+	if err != nil {
+		return err
+	}
+
+	rounds, err := m.db.Stats.ListRounds(0, m.inbound.Rounds, true)
+	if err != nil {
+		return err
+	}
+
+	var forecast uint64
+	for _, round := range rounds {
+		forecast = max(forecast, round.PrizePool)
+	}
+
+	analysis := analyzeInbound(channels, expired, forecast)
+	m.logger.Debugf("Inbound liquidity analysis: %+v", analysis)
+
+	amount := analysis.Suggestion()
+	if amount == 0 {
+		return nil
+	}
+
+	if !slices.Contains(m.actions, ActionLSPOrder) {
+		m.alert(fmt.Sprintf("%s. Consider opening a channel with at least %d sats of inbound liquidity",
+			analysis, amount))
+		return nil
+	}
+
+	return m.orderChannel(ctx, analysis, amount)
+}
+
+// orderChannel buys a channel with amount sats of inbound liquidity from the LSP.
+func (m *Manager) orderChannel(ctx context.Context, analysis InboundAnalysis, amount uint64) error {
+	if time.Since(m.lastOrder) < m.inbound.Cooldown {
+		m.logger.Infof("Skipping channel order of %d sats, the last one was placed at %s",
+			amount, m.lastOrder.Format(time.RFC3339))
+		return nil
+	}
+
+	limitedAmount, maxFee := m.budget.limit(amount, m.inbound.LSP.MaxFeePPM)
+	if limitedAmount == 0 || maxFee == 0 {
+		m.alert(fmt.Sprintf("%s. A channel of %d sats should be ordered but the liquidity budget is exhausted",
+			analysis, amount))
+		return nil
+	}
+
+	if m.dryRun {
+		m.alert(fmt.Sprintf("[dry run] %s. Channel order of %d sats with a maximum fee of %d sats",
+			analysis, limitedAmount, maxFee))
+		return nil
+	}
+
+	info, err := m.lnd.GetInfo(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting node information")
+	}
+
+	order, err := m.lsp.CreateOrder(ctx, info.IdentityPubkey, limitedAmount)
+	if err != nil {
+		return errors.Wrap(err, "creating channel order")
+	}
+	if order.Fee >= maxFee {
+		return errors.Wrapf(ErrOrderFeeTooHigh, "order %s fee is %d sats, maximum is %d",
+			order.ID, order.Fee, maxFee)
+	}
+
+	invoice, err := m.lnd.DecodeInvoice(ctx, order.Invoice)
+	if err != nil {
+		return errors.Wrapf(err, "decoding order %s invoice", order.ID)
+	}
+	// No balance is pushed to the node, the invoice only pays the fee
+	if uint64(invoice.NumSatoshis) != order.Fee {
+		return errors.Errorf("order %s invoice amount is %d sats, expected %d", order.ID,
+			invoice.NumSatoshis, order.Fee)
+	}
+
+	// Whatever is left of the maximum fee is used to route the payment to the LSP
+	if _, err := m.lnd.PayInvoice(ctx, invoice, int64(maxFee-order.Fee), false); err != nil {
+		return errors.Wrapf(err, "paying order %s", order.ID)
+	}
+	m.budget.spend(limitedAmount, maxFee)
+	m.lastOrder = time.Now()
+
+	m.alert(fmt.Sprintf("%s. Channel order %s of %d sats placed paying %d sats in fees",
+		analysis, order.ID, limitedAmount, order.Fee))
+	return nil
+}
@@ -0,0 +1,185 @@
+package liquidity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type lspMock struct {
+	mock.Mock
+}
+
+func (l *lspMock) CreateOrder(ctx context.Context, publicKey string, amountSat uint64) (Order, error) {
+	args := l.Called(ctx, publicKey, amountSat)
+	return args.Get(0).(Order), args.Error(1)
+}
+
+func TestAnalyzeInbound(t *testing.T) {
+	channels := []*lnrpc.Channel{
+		{Active: true, RemoteBalance: 300_000},
+		{Active: true, RemoteBalance: 200_000},
+		{Active: false, RemoteBalance: 1_000_000},
+	}
+	expired := []db.Invoice{
+		{Amount: 10_000},
+		{Amount: 400_000},
+		{Amount: 450_000},
+		{Amount: 600_000},
+	}
+
+	analysis := analyzeInbound(channels, expired, 800_000)
+	expected := InboundAnalysis{
+		Inbound:            500_000,
+		LargestChannel:     300_000,
+		Forecast:           800_000,
+		LargestFailure:     600_000,
+		NoRoute:            2,
+		NoRouteAmount:      850_000,
+		Insufficient:       1,
+		InsufficientAmount: 600_000,
+		AbandonedAmount:    10_000,
+	}
+	assert.Equal(t, expected, analysis)
+}
+
+func TestInboundSuggestion(t *testing.T) {
+	cases := []struct {
+		desc     string
+		analysis InboundAnalysis
+		expected uint64
+	}{
+		{
+			desc:     "Enough inbound",
+			analysis: InboundAnalysis{Inbound: 500_000, LargestChannel: 300_000, Forecast: 400_000},
+			expected: 0,
+		},
+		{
+			desc:     "Big round forecast",
+			analysis: InboundAnalysis{Inbound: 500_000, LargestChannel: 300_000, Forecast: 800_000},
+			expected: 300_000,
+		},
+		{
+			desc: "Insufficient inbound",
+			analysis: InboundAnalysis{
+				Inbound:            500_000,
+				LargestChannel:     300_000,
+				LargestFailure:     600_000,
+				InsufficientAmount: 1_000_000,
+			},
+			expected: 1_000_000,
+		},
+		{
+			desc: "No route",
+			analysis: InboundAnalysis{
+				Inbound:        500_000,
+				LargestChannel: 300_000,
+				LargestFailure: 400_000,
+				NoRouteAmount:  400_000,
+			},
+			expected: 400_000,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.analysis.Suggestion())
+		})
+	}
+}
+
+func TestCheckInboundAlert(t *testing.T) {
+	manager, lndMock, _, notifierMock := setupInboundManager(t, []string{ActionAlert})
+	lndMock.On("ListChannels", mock.Anything).Return(inboundChannels, nil)
+	notifierMock.On("Notify", int64(alertChatID), mock.MatchedBy(func(message string) bool {
+		return assert.Contains(t, message, "at least 300000 sats of inbound liquidity")
+	}))
+
+	err := manager.check(context.Background())
+	assert.NoError(t, err)
+
+	notifierMock.AssertNumberOfCalls(t, "Notify", 1)
+}
+
+func TestCheckInboundLSPOrder(t *testing.T) {
+	manager, lndMock, lsp, notifierMock := setupInboundManager(t, []string{ActionLSPOrder})
+	invoice := &lnrpc.PayReq{NumSatoshis: 3_000}
+	lndMock.On("ListChannels", mock.Anything).Return(inboundChannels, nil)
+	lndMock.On("GetInfo", mock.Anything).Return(&lnrpc.GetInfoResponse{IdentityPubkey: "node"}, nil)
+	lndMock.On("DecodeInvoice", mock.Anything, "lnbc").Return(invoice, nil)
+	// Maximum fee: 300,000 * 20,000 / 1,000,000 = 6,000
+	lndMock.On("PayInvoice", mock.Anything, invoice, int64(3_000), false).Return(nil, nil).Once()
+	lsp.On("CreateOrder", mock.Anything, "node", uint64(300_000)).
+		Return(Order{ID: "order", Invoice: "lnbc", Fee: 3_000}, nil).Once()
+
+	err := manager.check(context.Background())
+	assert.NoError(t, err)
+
+	lndMock.AssertExpectations(t)
+	notifierMock.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+	assert.Equal(t, uint64(6_000), manager.budget.fees)
+	assert.False(t, manager.lastOrder.IsZero())
+
+	// No more channels are ordered during the cooldown
+	err = manager.check(context.Background())
+	assert.NoError(t, err)
+
+	lsp.AssertNumberOfCalls(t, "CreateOrder", 1)
+}
+
+func TestCheckInboundLSPOrderFeeTooHigh(t *testing.T) {
+	manager, lndMock, lsp, _ := setupInboundManager(t, []string{ActionLSPOrder})
+	lndMock.On("ListChannels", mock.Anything).Return(inboundChannels, nil)
+	lndMock.On("GetInfo", mock.Anything).Return(&lnrpc.GetInfoResponse{IdentityPubkey: "node"}, nil)
+	lsp.On("CreateOrder", mock.Anything, "node", uint64(300_000)).
+		Return(Order{ID: "order", Invoice: "lnbc", Fee: 9_000}, nil)
+
+	err := manager.checkInbound(context.Background(), inboundChannels)
+	assert.ErrorIs(t, err, ErrOrderFeeTooHigh)
+
+	lndMock.AssertNotCalled(t, "PayInvoice", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.Zero(t, manager.budget.fees)
+	assert.True(t, manager.lastOrder.IsZero())
+}
+
+var inboundChannels = []*lnrpc.Channel{
+	{ChanId: 1, Active: true, Capacity: 1_000_000, LocalBalance: 900_000, RemoteBalance: 100_000},
+}
+
+func setupInboundManager(t *testing.T, actions []string) (
+	*Manager,
+	*lightning.ClientMock,
+	*lspMock,
+	*notification.NotifierMock,
+) {
+	manager, lndMock, prizesMock, notifierMock, _ := setupManager(t, actions)
+	prizesMock.On("GetTotal").Return(uint64(0), nil)
+
+	invoicesMock := db.NewInvoicesStoreMock()
+	invoicesMock.On("ListExpired", mock.Anything).Return([]db.Invoice{{Amount: 300_000}}, nil)
+	statsMock := db.NewStatsStoreMock()
+	statsMock.On("ListRounds", uint64(0), uint64(defaultForecastRounds), true).
+		Return([]db.RoundStats{{PrizePool: 200_000}}, nil)
+	manager.db.Invoices = invoicesMock
+	manager.db.Stats = statsMock
+
+	lsp := &lspMock{}
+	manager.lsp = lsp
+	manager.inbound = config.LiquidityInbound{
+		LSP:      config.LSP{MaxFeePPM: 20_000},
+		Window:   24 * time.Hour,
+		Cooldown: time.Hour,
+		Rounds:   defaultForecastRounds,
+	}
+
+	return manager, lndMock, lsp, notifierMock
+}
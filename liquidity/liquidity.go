@@ -0,0 +1,311 @@
+// Package liquidity keeps the node channels balance in line with the prize liabilities.
+package liquidity
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/logger"
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/pkg/errors"
+)
+
+// Liquidity actions
+const (
+	ActionAlert     = "alert"
+	ActionRebalance = "rebalance"
+	ActionLoopOut   = "loop_out"
+	ActionLSPOrder  = "lsp_order"
+)
+
+// Report compares the node liquidity against the prize liabilities.
+type Report struct {
+	Liabilities uint64 `json:"liabilities"`
+	Outbound    uint64 `json:"outbound"`
+	Inbound     uint64 `json:"inbound"`
+	// OutboundDeficit is the outbound liquidity missing to cover the liabilities
+	OutboundDeficit uint64 `json:"outbound_deficit"`
+	// InboundDeficit is the inbound liquidity missing to keep receiving bets
+	InboundDeficit uint64 `json:"inbound_deficit"`
+	// Surplus is the outbound liquidity exceeding the one required, it can be swapped out
+	Surplus uint64 `json:"surplus"`
+}
+
+// Node is the part of the lightning node whose channels are monitored and rebalanced.
+type Node interface {
+	lightning.InvoiceManager
+	lightning.NodeInfo
+	lightning.PaymentSender
+}
+
+// Manager monitors the node liquidity and triggers the actions configured when it's not enough.
+type Manager struct {
+	lnd           Node
+	notifier      notification.Notifier
+	swapper       Swapper
+	lsp           LSP
+	logger        *logger.Logger
+	db            *db.DB
+	budget        *budget
+	inbound       config.LiquidityInbound
+	lastOrder     time.Time
+	actions       []string
+	interval      time.Duration
+	outboundRatio float64
+	inboundRatio  float64
+	channelRatio  float64
+	maxFeePPM     uint64
+	alertChatID   int64
+	enabled       bool
+	dryRun        bool
+}
+
+// New returns a new liquidity manager.
+func New(
+	config config.Liquidity,
+	db *db.DB,
+	lnd Node,
+	notifier notification.Notifier,
+) (*Manager, error) {
+	logger, err := logger.New(config.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var swapper Swapper
+	if config.Enabled && slices.Contains(config.Actions, ActionLoopOut) {
+		swapper, err = newLoopClient(config.Loop)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating loop client")
+		}
+	}
+
+	inbound := config.Inbound
+	if inbound.Rounds == 0 {
+		inbound.Rounds = defaultForecastRounds
+	}
+
+	return &Manager{
+		lnd:           lnd,
+		notifier:      notifier,
+		swapper:       swapper,
+		lsp:           newLSPClient(inbound.LSP),
+		logger:        logger,
+		db:            db,
+		budget:        newBudget(config.Budget, time.Now),
+		inbound:       inbound,
+		actions:       config.Actions,
+		interval:      config.Interval,
+		outboundRatio: config.OutboundRatio,
+		inboundRatio:  config.InboundRatio,
+		channelRatio:  config.ChannelRatio,
+		maxFeePPM:     config.MaxFeePPM,
+		alertChatID:   config.AlertChatID,
+		enabled:       config.Enabled,
+		dryRun:        config.DryRun,
+	}, nil
+}
+
+// Start executes the loop that checks the node liquidity periodically.
+func (m *Manager) Start(ctx context.Context) {
+	if !m.enabled {
+		m.logger.Info("Liquidity manager disabled")
+		return
+	}
+
+	if m.dryRun {
+		m.logger.Info("Liquidity manager running in dry-run mode, no funds will be moved")
+	}
+
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := m.check(ctx); err != nil {
+				m.logger.Error(err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (m *Manager) check(ctx context.Context) error {
+	channels, err := m.lnd.ListChannels(ctx)
+	if err != nil {
+		return err
+	}
+
+	liabilities, err := m.db.Prizes.GetTotal()
+	if err != nil {
+		return err
+	}
+
+	report := newReport(channels, liabilities, m.outboundRatio, m.inboundRatio)
+	m.logger.Debugf("Liquidity report: %+v", report)
+
+	if report.OutboundDeficit > 0 {
+		m.alert(fmt.Sprintf("Outbound liquidity is %d sats short of covering the prize liabilities (%d sats)",
+			report.OutboundDeficit, report.Liabilities))
+	}
+
+	if report.InboundDeficit > 0 {
+		if err := m.loopOut(ctx, report); err != nil {
+			m.logger.Error(err)
+		}
+	}
+
+	if slices.Contains(m.actions, ActionRebalance) {
+		if err := m.rebalance(ctx, channels); err != nil {
+			m.logger.Error(err)
+		}
+	}
+
+	if m.inbound.Window > 0 {
+		if err := m.checkInbound(ctx, channels); err != nil {
+			m.logger.Error(err)
+		}
+	}
+
+	return nil
+}
+
+// loopOut swaps the outbound surplus out to increase the inbound liquidity.
+func (m *Manager) loopOut(ctx context.Context, report Report) error {
+	if !slices.Contains(m.actions, ActionLoopOut) {
+		m.alert(fmt.Sprintf("Inbound liquidity is %d sats short", report.InboundDeficit))
+		return nil
+	}
+
+	amount := min(report.InboundDeficit, report.Surplus)
+	if amount == 0 {
+		m.alert(fmt.Sprintf("Inbound liquidity is %d sats short and there is no outbound surplus to swap out",
+			report.InboundDeficit))
+		return nil
+	}
+
+	amount, maxFee := m.budget.limit(amount, m.maxFeePPM)
+	if amount == 0 || maxFee == 0 {
+		m.alert(fmt.Sprintf("Inbound liquidity is %d sats short and the liquidity budget is exhausted",
+			report.InboundDeficit))
+		return nil
+	}
+
+	if m.dryRun {
+		m.alert(fmt.Sprintf("[dry run] Loop out of %d sats with a maximum fee of %d sats", amount, maxFee))
+		return nil
+	}
+
+	swap, err := m.swapper.LoopOut(ctx, amount, maxFee)
+	if err != nil {
+		return errors.Wrap(err, "performing loop out")
+	}
+	m.budget.spend(amount, swap.MaxFee)
+
+	m.alert(fmt.Sprintf("Loop out %s of %d sats initiated", swap.ID, amount))
+	return nil
+}
+
+// rebalance moves liquidity from the channel with the highest local balance ratio to the one with
+// the lowest if they are outside the thresholds.
+func (m *Manager) rebalance(ctx context.Context, channels []*lnrpc.Channel) error {
+	source, target, amount := planRebalance(channels, m.channelRatio)
+	if amount == 0 {
+		return nil
+	}
+
+	limitedAmount, maxFee := m.budget.limit(uint64(amount), m.maxFeePPM)
+	if limitedAmount == 0 || maxFee == 0 {
+		m.logger.Warningf("Skipping rebalance of %d sats, the liquidity budget is exhausted", amount)
+		return nil
+	}
+
+	if m.dryRun {
+		m.alert(fmt.Sprintf("[dry run] Rebalance of %d sats from channel %d to channel %d with a maximum fee of %d sats",
+			limitedAmount, source.ChanId, target.ChanId, maxFee))
+		return nil
+	}
+
+	fee, err := m.lnd.Rebalance(ctx, source.ChanId, target.RemotePubkey, int64(limitedAmount), int64(maxFee))
+	if err != nil {
+		return errors.Wrapf(err, "rebalancing channel %d to channel %d", source.ChanId, target.ChanId)
+	}
+	m.budget.spend(limitedAmount, uint64(fee))
+
+	m.logger.Infof("Rebalanced %d sats from channel %d to channel %d paying %d sats in fees",
+		limitedAmount, source.ChanId, target.ChanId, fee)
+	return nil
+}
+
+func (m *Manager) alert(message string) {
+	m.logger.Warning(message)
+
+	if !slices.Contains(m.actions, ActionAlert) || m.alertChatID == 0 {
+		return
+	}
+	m.notifier.Notify(m.alertChatID, message)
+}
+
+func newReport(channels []*lnrpc.Channel, liabilities uint64, outboundRatio, inboundRatio float64) Report {
+	report := Report{Liabilities: liabilities}
+	for _, channel := range channels {
+		report.Outbound += uint64(channel.LocalBalance)
+		report.Inbound += uint64(channel.RemoteBalance)
+	}
+
+	requiredOutbound := uint64(float64(liabilities) * outboundRatio)
+	if report.Outbound < requiredOutbound {
+		report.OutboundDeficit = requiredOutbound - report.Outbound
+	} else {
+		report.Surplus = report.Outbound - requiredOutbound
+	}
+
+	requiredInbound := uint64(float64(liabilities) * inboundRatio)
+	if report.Inbound < requiredInbound {
+		report.InboundDeficit = requiredInbound - report.Inbound
+	}
+
+	return report
+}
+
+// planRebalance returns the channels that should be balanced and the amount to move to leave both
+// as close to half of their capacity as possible.
+func planRebalance(channels []*lnrpc.Channel, ratio float64) (*lnrpc.Channel, *lnrpc.Channel, int64) {
+	var source, target *lnrpc.Channel
+	for _, channel := range channels {
+		if channel.Capacity == 0 {
+			continue
+		}
+
+		if source == nil || localRatio(channel) > localRatio(source) {
+			source = channel
+		}
+		if target == nil || localRatio(channel) < localRatio(target) {
+			target = channel
+		}
+	}
+
+	if source == nil || source == target ||
+		localRatio(source) <= 1-ratio || localRatio(target) >= ratio {
+		return nil, nil, 0
+	}
+
+	amount := min(source.LocalBalance-source.Capacity/2, target.Capacity/2-target.LocalBalance)
+	return source, target, amount
+}
+
+func localRatio(channel *lnrpc.Channel) float64 {
+	return float64(channel.LocalBalance) / float64(channel.Capacity)
+}
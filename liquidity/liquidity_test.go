@@ -0,0 +1,255 @@
+package liquidity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/logger"
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const alertChatID = 2140
+
+type swapperMock struct {
+	mock.Mock
+}
+
+func (s *swapperMock) LoopOut(ctx context.Context, amountSat, maxFeeSat uint64) (Swap, error) {
+	args := s.Called(ctx, amountSat, maxFeeSat)
+	return args.Get(0).(Swap), args.Error(1)
+}
+
+func TestCheckOutboundDeficit(t *testing.T) {
+	channels := []*lnrpc.Channel{
+		{ChanId: 1, Capacity: 1_000_000, LocalBalance: 400_000, RemoteBalance: 600_000},
+	}
+	manager, lndMock, prizesMock, notifierMock, _ := setupManager(t, []string{ActionAlert})
+	lndMock.On("ListChannels", mock.Anything).Return(channels, nil)
+	prizesMock.On("GetTotal").Return(uint64(500_000), nil)
+	notifierMock.On("Notify", int64(alertChatID), mock.Anything)
+
+	err := manager.check(context.Background())
+	assert.NoError(t, err)
+
+	notifierMock.AssertNumberOfCalls(t, "Notify", 1)
+}
+
+func TestCheckLoopOut(t *testing.T) {
+	channels := []*lnrpc.Channel{
+		{ChanId: 1, Capacity: 1_000_000, LocalBalance: 900_000, RemoteBalance: 100_000},
+	}
+	manager, lndMock, prizesMock, notifierMock, swapper := setupManager(t, []string{ActionLoopOut})
+	lndMock.On("ListChannels", mock.Anything).Return(channels, nil)
+	prizesMock.On("GetTotal").Return(uint64(500_000), nil)
+	// Required inbound: 500,000. Deficit: 400,000. Surplus: 900,000 - 500,000 = 400,000
+	swapper.On("LoopOut", mock.Anything, uint64(400_000), uint64(2_000)).
+		Return(Swap{ID: "swap", MaxFee: 2_000}, nil)
+
+	err := manager.check(context.Background())
+	assert.NoError(t, err)
+
+	swapper.AssertExpectations(t)
+	notifierMock.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+	assert.Equal(t, uint64(2_000), manager.budget.fees)
+	assert.Equal(t, uint64(400_000), manager.budget.amount)
+}
+
+func TestCheckDryRun(t *testing.T) {
+	channels := []*lnrpc.Channel{
+		{ChanId: 1, Capacity: 1_000_000, LocalBalance: 950_000, RemoteBalance: 50_000},
+		{ChanId: 2, Capacity: 1_000_000, LocalBalance: 50_000, RemoteBalance: 950_000, RemotePubkey: "peer"},
+	}
+	actions := []string{ActionAlert, ActionLoopOut, ActionRebalance}
+	manager, lndMock, prizesMock, notifierMock, swapper := setupManager(t, actions)
+	manager.dryRun = true
+	manager.inboundRatio = 3
+	lndMock.On("ListChannels", mock.Anything).Return(channels, nil)
+	prizesMock.On("GetTotal").Return(uint64(500_000), nil)
+	notifierMock.On("Notify", int64(alertChatID), mock.Anything)
+
+	err := manager.check(context.Background())
+	assert.NoError(t, err)
+
+	swapper.AssertNotCalled(t, "LoopOut", mock.Anything, mock.Anything, mock.Anything)
+	lndMock.AssertNotCalled(t, "Rebalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	notifierMock.AssertNumberOfCalls(t, "Notify", 2)
+	assert.Zero(t, manager.budget.fees)
+}
+
+func TestCheckRebalance(t *testing.T) {
+	channels := []*lnrpc.Channel{
+		{ChanId: 1, Capacity: 1_000_000, LocalBalance: 950_000, RemoteBalance: 50_000},
+		{ChanId: 2, Capacity: 2_000_000, LocalBalance: 1_000_000, RemoteBalance: 1_000_000},
+		{ChanId: 3, Capacity: 500_000, LocalBalance: 50_000, RemoteBalance: 450_000, RemotePubkey: "peer"},
+	}
+	manager, lndMock, prizesMock, _, _ := setupManager(t, []string{ActionRebalance})
+	lndMock.On("ListChannels", mock.Anything).Return(channels, nil)
+	lndMock.On("Rebalance", mock.Anything, uint64(1), "peer", int64(200_000), int64(1_000)).
+		Return(int64(120), nil)
+	prizesMock.On("GetTotal").Return(uint64(0), nil)
+
+	err := manager.check(context.Background())
+	assert.NoError(t, err)
+
+	lndMock.AssertExpectations(t)
+	assert.Equal(t, uint64(120), manager.budget.fees)
+}
+
+func TestCheckBudgetExhausted(t *testing.T) {
+	channels := []*lnrpc.Channel{
+		{ChanId: 1, Capacity: 1_000_000, LocalBalance: 950_000, RemoteBalance: 50_000},
+		{ChanId: 2, Capacity: 1_000_000, LocalBalance: 50_000, RemoteBalance: 950_000, RemotePubkey: "peer"},
+	}
+	manager, lndMock, prizesMock, _, _ := setupManager(t, []string{ActionRebalance})
+	manager.budget.fees = manager.budget.maxFees
+	lndMock.On("ListChannels", mock.Anything).Return(channels, nil)
+	prizesMock.On("GetTotal").Return(uint64(0), nil)
+
+	err := manager.check(context.Background())
+	assert.NoError(t, err)
+
+	lndMock.AssertNotCalled(t, "Rebalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestNewReport(t *testing.T) {
+	channels := []*lnrpc.Channel{
+		{LocalBalance: 300_000, RemoteBalance: 700_000},
+		{LocalBalance: 200_000, RemoteBalance: 100_000},
+	}
+
+	report := newReport(channels, 1_000_000, 1, 0.5)
+	expected := Report{
+		Liabilities:     1_000_000,
+		Outbound:        500_000,
+		Inbound:         800_000,
+		OutboundDeficit: 500_000,
+	}
+	assert.Equal(t, expected, report)
+
+	report = newReport(channels, 200_000, 1.5, 5)
+	expected = Report{
+		Liabilities:    200_000,
+		Outbound:       500_000,
+		Inbound:        800_000,
+		InboundDeficit: 200_000,
+		Surplus:        200_000,
+	}
+	assert.Equal(t, expected, report)
+}
+
+func TestPlanRebalance(t *testing.T) {
+	cases := []struct {
+		desc           string
+		channels       []*lnrpc.Channel
+		expectedAmount int64
+	}{
+		{
+			desc: "Balanced",
+			channels: []*lnrpc.Channel{
+				{ChanId: 1, Capacity: 1_000_000, LocalBalance: 700_000},
+				{ChanId: 2, Capacity: 1_000_000, LocalBalance: 300_000},
+			},
+			expectedAmount: 0,
+		},
+		{
+			desc: "Single channel",
+			channels: []*lnrpc.Channel{
+				{ChanId: 1, Capacity: 1_000_000, LocalBalance: 1_000_000},
+			},
+			expectedAmount: 0,
+		},
+		{
+			desc: "Limited by the target",
+			channels: []*lnrpc.Channel{
+				{ChanId: 1, Capacity: 4_000_000, LocalBalance: 4_000_000},
+				{ChanId: 2, Capacity: 1_000_000, LocalBalance: 0},
+			},
+			expectedAmount: 500_000,
+		},
+		{
+			desc: "Limited by the source",
+			channels: []*lnrpc.Channel{
+				{ChanId: 1, Capacity: 1_000_000, LocalBalance: 900_000},
+				{ChanId: 2, Capacity: 4_000_000, LocalBalance: 0},
+			},
+			expectedAmount: 400_000,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, _, amount := planRebalance(tc.channels, 0.2)
+			assert.Equal(t, tc.expectedAmount, amount)
+		})
+	}
+}
+
+func TestBudget(t *testing.T) {
+	now := time.Now()
+	b := newBudget(config.LiquidityBudget{
+		Period:    time.Hour,
+		MaxFees:   1_000,
+		MaxAmount: 1_000_000,
+	}, func() time.Time { return now })
+
+	amount, maxFee := b.limit(2_000_000, 5_000)
+	assert.Equal(t, uint64(1_000_000), amount)
+	assert.Equal(t, uint64(1_000), maxFee)
+
+	b.spend(800_000, 900)
+	amount, maxFee = b.limit(500_000, 5_000)
+	assert.Equal(t, uint64(200_000), amount)
+	assert.Equal(t, uint64(100), maxFee)
+
+	b.spend(200_000, 100)
+	amount, maxFee = b.limit(500_000, 5_000)
+	assert.Zero(t, amount)
+	assert.Zero(t, maxFee)
+
+	now = now.Add(time.Hour)
+	amount, maxFee = b.limit(500_000, 1_000)
+	assert.Equal(t, uint64(500_000), amount)
+	assert.Equal(t, uint64(500), maxFee)
+}
+
+func setupManager(t *testing.T, actions []string) (
+	*Manager,
+	*lightning.ClientMock,
+	*db.PrizesStoreMock,
+	*notification.NotifierMock,
+	*swapperMock,
+) {
+	logger, err := logger.New(config.Logger{Level: uint8(logger.DISABLED)})
+	assert.NoError(t, err)
+
+	lndMock := lightning.NewClientMock()
+	prizesMock := db.NewPrizesStoreMock()
+	notifierMock := notification.NewNotifierMock()
+	swapper := &swapperMock{}
+
+	manager := &Manager{
+		lnd:           lndMock,
+		notifier:      notifierMock,
+		swapper:       swapper,
+		logger:        logger,
+		db:            &db.DB{Prizes: prizesMock},
+		budget:        newBudget(config.LiquidityBudget{Period: time.Hour, MaxFees: 10_000}, time.Now),
+		actions:       actions,
+		outboundRatio: 1,
+		inboundRatio:  1,
+		channelRatio:  0.2,
+		maxFeePPM:     5_000,
+		alertChatID:   alertChatID,
+		enabled:       true,
+	}
+
+	return manager, lndMock, prizesMock, notifierMock, swapper
+}
@@ -0,0 +1,174 @@
+package liquidity
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/pkg/errors"
+)
+
+// ErrSwapFeeTooHigh is returned when the fees quoted for a swap exceed the maximum specified.
+var ErrSwapFeeTooHigh = errors.New("swap fees are higher than the maximum allowed")
+
+// Swapper performs submarine swaps that move off-chain funds to an on-chain address.
+type Swapper interface {
+	LoopOut(ctx context.Context, amountSat, maxFeeSat uint64) (Swap, error)
+}
+
+// Swap is a submarine swap initiated.
+type Swap struct {
+	ID string
+	// MaxFee is the maximum amount of fees the swap may cost, including the routing and miner ones
+	MaxFee uint64
+}
+
+type loopOutQuote struct {
+	SwapFeeSat      uint64 `json:"swap_fee_sat,string"`
+	PrepayAmtSat    uint64 `json:"prepay_amt_sat,string"`
+	HTLCSweepFeeSat uint64 `json:"htlc_sweep_fee_sat,string"`
+}
+
+type loopOutRequest struct {
+	Label               string `json:"label"`
+	Initiator           string `json:"initiator"`
+	Amount              uint64 `json:"amt,string"`
+	MaxSwapFee          uint64 `json:"max_swap_fee,string"`
+	MaxPrepayAmount     uint64 `json:"max_prepay_amt,string"`
+	MaxMinerFee         uint64 `json:"max_miner_fee,string"`
+	MaxSwapRoutingFee   uint64 `json:"max_swap_routing_fee,string"`
+	MaxPrepayRoutingFee uint64 `json:"max_prepay_routing_fee,string"`
+}
+
+type loopOutResponse struct {
+	ID string `json:"id"`
+}
+
+// loopClient communicates with the Loop daemon REST API.
+type loopClient struct {
+	client   *http.Client
+	address  string
+	macaroon string
+}
+
+func newLoopClient(config config.Loop) (*loopClient, error) {
+	macBytes, err := os.ReadFile(config.MacaroonPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading macaroon file")
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.TLSCertPath != "" {
+		cert, err := os.ReadFile(config.TLSCertPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading TLS certificate")
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(cert) {
+			return nil, errors.New("invalid TLS certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	address := config.Address
+	if !strings.HasPrefix(address, "http") {
+		address = "https://" + address
+	}
+
+	return &loopClient{
+		client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+			Timeout:   time.Minute,
+		},
+		address:  strings.TrimSuffix(address, "/"),
+		macaroon: hex.EncodeToString(macBytes),
+	}, nil
+}
+
+// LoopOut requests a quote for the swap and initiates it if the fees are lower than the maximum.
+//
+// Whatever is left of the maximum fee after the quoted ones is used as the limit for routing fees.
+func (l *loopClient) LoopOut(ctx context.Context, amountSat, maxFeeSat uint64) (Swap, error) {
+	var quote loopOutQuote
+	path := fmt.Sprintf("/v1/loop/out/quote/%d", amountSat)
+	if err := l.do(ctx, http.MethodGet, path, nil, &quote); err != nil {
+		return Swap{}, errors.Wrap(err, "getting quote")
+	}
+
+	quotedFee := quote.SwapFeeSat + quote.HTLCSweepFeeSat
+	if quotedFee >= maxFeeSat {
+		return Swap{}, ErrSwapFeeTooHigh
+	}
+
+	routingFee := (maxFeeSat - quotedFee) / 2
+	req := loopOutRequest{
+		Label:               "BTRY liquidity",
+		Initiator:           "btry",
+		Amount:              amountSat,
+		MaxSwapFee:          quote.SwapFeeSat,
+		MaxPrepayAmount:     quote.PrepayAmtSat,
+		MaxMinerFee:         quote.HTLCSweepFeeSat,
+		MaxSwapRoutingFee:   routingFee,
+		MaxPrepayRoutingFee: routingFee,
+	}
+
+	var resp loopOutResponse
+	if err := l.do(ctx, http.MethodPost, "/v1/loop/out", req, &resp); err != nil {
+		return Swap{}, errors.Wrap(err, "initiating loop out")
+	}
+
+	return Swap{
+		ID:     resp.ID,
+		MaxFee: quotedFee + routingFee*2,
+	}, nil
+}
+
+func (l *loopClient) do(ctx context.Context, method, path string, body, dst any) error {
+	var reqBody io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "encoding request")
+		}
+		reqBody = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, l.address+path, reqBody)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Grpc-Metadata-macaroon", l.macaroon)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var loopErr struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&loopErr)
+		return errors.Errorf("loop daemon returned status %d: %s", resp.StatusCode, loopErr.Message)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(dst); err != nil {
+		return errors.Wrap(err, "decoding response")
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package liquidity
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const macaroon = "0201036c6e64"
+
+func TestLoopOut(t *testing.T) {
+	var request loopOutRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, macaroon, r.Header.Get("Grpc-Metadata-macaroon"))
+
+		switch r.URL.Path {
+		case "/v1/loop/out/quote/500000":
+			w.Write([]byte(`{"swap_fee_sat":"600","prepay_amt_sat":"1337","htlc_sweep_fee_sat":"200"}`))
+		case "/v1/loop/out":
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			w.Write([]byte(`{"id":"swap_id"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &loopClient{client: server.Client(), address: server.URL, macaroon: macaroon}
+
+	swap, err := client.LoopOut(context.Background(), 500_000, 2_000)
+	assert.NoError(t, err)
+
+	expectedRequest := loopOutRequest{
+		Label:               "BTRY liquidity",
+		Initiator:           "btry",
+		Amount:              500_000,
+		MaxSwapFee:          600,
+		MaxPrepayAmount:     1337,
+		MaxMinerFee:         200,
+		MaxSwapRoutingFee:   600,
+		MaxPrepayRoutingFee: 600,
+	}
+	assert.Equal(t, expectedRequest, request)
+	assert.Equal(t, Swap{ID: "swap_id", MaxFee: 2_000}, swap)
+}
+
+func TestLoopOutErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/loop/out/quote/500000":
+			w.Write([]byte(`{"swap_fee_sat":"1500","prepay_amt_sat":"1337","htlc_sweep_fee_sat":"600"}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"message":"swap amount too low"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := &loopClient{client: server.Client(), address: server.URL, macaroon: macaroon}
+
+	t.Run("Fee too high", func(t *testing.T) {
+		_, err := client.LoopOut(context.Background(), 500_000, 2_000)
+		assert.ErrorIs(t, err, ErrSwapFeeTooHigh)
+	})
+
+	t.Run("Loop daemon error", func(t *testing.T) {
+		_, err := client.LoopOut(context.Background(), 1_000, 2_000)
+		assert.ErrorContains(t, err, "swap amount too low")
+	})
+}
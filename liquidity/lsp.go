@@ -0,0 +1,122 @@
+package liquidity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/pkg/errors"
+)
+
+// defaultChannelExpiryBlocks is the number of blocks the LSP keeps the channels open for when it's
+// not configured, around three months.
+const defaultChannelExpiryBlocks = 13_140
+
+// ErrOrderFeeTooHigh is returned when the fee of a channel order exceeds the maximum specified.
+var ErrOrderFeeTooHigh = errors.New("channel order fee is higher than the maximum allowed")
+
+// LSP sells inbound channels to the node.
+type LSP interface {
+	CreateOrder(ctx context.Context, publicKey string, amountSat uint64) (Order, error)
+}
+
+// Order is a channel order placed with the LSP, the channel is opened once the invoice is paid.
+type Order struct {
+	ID      string
+	Invoice string
+	Fee     uint64
+}
+
+type lspOrderRequest struct {
+	PublicKey                    string `json:"public_key"`
+	Token                        string `json:"token"`
+	LSPBalanceSat                uint64 `json:"lsp_balance_sat,string"`
+	ClientBalanceSat             uint64 `json:"client_balance_sat,string"`
+	RequiredChannelConfirmations uint32 `json:"required_channel_confirmations"`
+	FundingConfirmsWithinBlocks  uint32 `json:"funding_confirms_within_blocks"`
+	ChannelExpiryBlocks          uint32 `json:"channel_expiry_blocks"`
+	AnnounceChannel              bool   `json:"announce_channel"`
+}
+
+type lspOrderResponse struct {
+	OrderID string `json:"order_id"`
+	Payment struct {
+		Bolt11 struct {
+			Invoice     string `json:"invoice"`
+			FeeTotalSat uint64 `json:"fee_total_sat,string"`
+		} `json:"bolt11"`
+	} `json:"payment"`
+}
+
+// lspClient communicates with an LSPS1 compatible HTTP API.
+type lspClient struct {
+	client              *http.Client
+	address             string
+	token               string
+	channelExpiryBlocks uint32
+}
+
+func newLSPClient(config config.LSP) *lspClient {
+	channelExpiryBlocks := config.ChannelExpiryBlocks
+	if channelExpiryBlocks == 0 {
+		channelExpiryBlocks = defaultChannelExpiryBlocks
+	}
+
+	return &lspClient{
+		client:              &http.Client{Timeout: time.Minute},
+		address:             strings.TrimSuffix(config.URL, "/"),
+		token:               config.Token,
+		channelExpiryBlocks: channelExpiryBlocks,
+	}
+}
+
+// CreateOrder orders a private channel with amountSat of inbound liquidity to the node.
+func (l *lspClient) CreateOrder(ctx context.Context, publicKey string, amountSat uint64) (Order, error) {
+	payload, err := json.Marshal(lspOrderRequest{
+		PublicKey:                   publicKey,
+		Token:                       l.token,
+		LSPBalanceSat:               amountSat,
+		FundingConfirmsWithinBlocks: 6,
+		ChannelExpiryBlocks:         l.channelExpiryBlocks,
+	})
+	if err != nil {
+		return Order{}, errors.Wrap(err, "encoding request")
+	}
+
+	url := l.address + "/api/v1/create_order"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return Order{}, errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return Order{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		var lspErr struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&lspErr)
+		return Order{}, errors.Errorf("LSP returned status %d: %s", resp.StatusCode, lspErr.Message)
+	}
+
+	var order lspOrderResponse
+	if err := json.NewDecoder(resp.Body).Decode(&order); err != nil {
+		return Order{}, errors.Wrap(err, "decoding response")
+	}
+
+	return Order{
+		ID:      order.OrderID,
+		Invoice: order.Payment.Bolt11.Invoice,
+		Fee:     order.Payment.Bolt11.FeeTotalSat,
+	}, nil
+}
@@ -0,0 +1,54 @@
+package liquidity
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateOrder(t *testing.T) {
+	var request lspOrderRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v1/create_order", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"order_id":"order","payment":{"bolt11":{"invoice":"lnbc","fee_total_sat":"3000","order_total_sat":"3000"}}}`))
+	}))
+	defer server.Close()
+
+	client := newLSPClient(config.LSP{URL: server.URL + "/", Token: "token"})
+
+	order, err := client.CreateOrder(context.Background(), "node", 500_000)
+	assert.NoError(t, err)
+
+	expectedRequest := lspOrderRequest{
+		PublicKey:                   "node",
+		Token:                       "token",
+		LSPBalanceSat:               500_000,
+		FundingConfirmsWithinBlocks: 6,
+		ChannelExpiryBlocks:         defaultChannelExpiryBlocks,
+	}
+	assert.Equal(t, expectedRequest, request)
+	assert.Equal(t, Order{ID: "order", Invoice: "lnbc", Fee: 3_000}, order)
+}
+
+func TestCreateOrderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message":"lsp_balance_sat below minimum"}`))
+	}))
+	defer server.Close()
+
+	client := newLSPClient(config.LSP{URL: server.URL})
+
+	_, err := client.CreateOrder(context.Background(), "node", 1_000)
+	assert.ErrorContains(t, err, "lsp_balance_sat below minimum")
+}
@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aftermath2/BTRY/config"
@@ -39,7 +41,38 @@ type Logger struct {
 	out   io.Writer
 	file  *os.File
 	label string
-	level Level
+	level atomic.Uint32
+}
+
+// registry contains the loggers created, by label, so their level can be changed at runtime.
+var registry = struct {
+	loggers map[string][]*Logger
+	mu      sync.Mutex
+}{
+	loggers: make(map[string][]*Logger),
+}
+
+// errorCounts contains the number of errors reported by the loggers of each label, whether they
+// were written or not, so they can be monitored.
+var errorCounts sync.Map
+
+// ErrorCount returns the number of errors reported by the loggers with the label specified, or by
+// all of them if it's empty.
+func ErrorCount(label string) uint64 {
+	if label != "" {
+		count, ok := errorCounts.Load(label)
+		if !ok {
+			return 0
+		}
+		return count.(*atomic.Uint64).Load()
+	}
+
+	total := uint64(0)
+	errorCounts.Range(func(_, count any) bool {
+		total += count.(*atomic.Uint64).Load()
+		return true
+	})
+	return total
 }
 
 // New creates a new logger.
@@ -62,21 +95,45 @@ func New(config config.Logger) (*Logger, error) {
 		file = f
 	}
 
-	return &Logger{
-		level: Level(config.Level),
+	logger := &Logger{
 		label: config.Label,
 		out:   io.MultiWriter(writers...),
 		file:  file,
-	}, nil
+	}
+	logger.level.Store(uint32(config.Level))
+
+	registry.mu.Lock()
+	registry.loggers[config.Label] = append(registry.loggers[config.Label], logger)
+	registry.mu.Unlock()
+
+	return logger, nil
+}
+
+// Reload changes the level of the loggers created with the labels of the configurations passed.
+func Reload(configs ...config.Logger) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	for _, config := range configs {
+		for _, logger := range registry.loggers[config.Label] {
+			logger.level.Store(uint32(config.Level))
+		}
+	}
 }
 
-func (l Logger) log(level Level, message string) {
-	if l.level == DISABLED || level < l.level {
+func (l *Logger) log(level Level, message string) {
+	if level >= ERROR {
+		count, _ := errorCounts.LoadOrStore(l.label, new(atomic.Uint64))
+		count.(*atomic.Uint64).Add(1)
+	}
+
+	currentLevel := Level(l.level.Load())
+	if currentLevel == DISABLED || level < currentLevel {
 		return
 	}
 
 	var source string
-	if l.level == DEBUG {
+	if currentLevel == DEBUG {
 		_, file, line, _ := runtime.Caller(2)
 		split := strings.Split(file, "/")
 		join := strings.Join(split[4:], "/")
@@ -88,7 +145,7 @@ func (l Logger) log(level Level, message string) {
 	log := fmt.Sprintf("%s [%s] %s%s: %s", timestamp, levelName(level), l.label, source, message)
 	fmt.Fprintln(l.out, log)
 
-	if l.level == FATAL {
+	if currentLevel == FATAL {
 		if l.file != nil {
 			l.file.Close()
 		}
@@ -97,52 +154,52 @@ func (l Logger) log(level Level, message string) {
 }
 
 // Debug provides useful information for debugging.
-func (l Logger) Debug(args ...interface{}) {
+func (l *Logger) Debug(args ...interface{}) {
 	l.log(DEBUG, fmt.Sprint(args...))
 }
 
 // Debugf is like Debug but takes a formatted message.
-func (l Logger) Debugf(format string, args ...interface{}) {
+func (l *Logger) Debugf(format string, args ...interface{}) {
 	l.log(DEBUG, fmt.Sprintf(format, args...))
 }
 
 // Error reports the application errors.
-func (l Logger) Error(args ...interface{}) {
+func (l *Logger) Error(args ...interface{}) {
 	l.log(ERROR, fmt.Sprint(args...))
 }
 
 // Errorf is like Error but takes a formatted message.
-func (l Logger) Errorf(format string, args ...interface{}) {
+func (l *Logger) Errorf(format string, args ...interface{}) {
 	l.log(ERROR, fmt.Sprintf(format, args...))
 }
 
 // Fatal reports the application errors and exits.
-func (l Logger) Fatal(args ...interface{}) {
+func (l *Logger) Fatal(args ...interface{}) {
 	l.log(FATAL, fmt.Sprint(args...))
 }
 
 // Fatalf is like Fatal but takes a formatted message.
-func (l Logger) Fatalf(format string, args ...interface{}) {
+func (l *Logger) Fatalf(format string, args ...interface{}) {
 	l.log(FATAL, fmt.Sprintf(format, args...))
 }
 
 // Info provides useful information about the server.
-func (l Logger) Info(args ...interface{}) {
+func (l *Logger) Info(args ...interface{}) {
 	l.log(INFO, fmt.Sprint(args...))
 }
 
 // Infof is like Info but takes a formatted message.
-func (l Logger) Infof(format string, args ...interface{}) {
+func (l *Logger) Infof(format string, args ...interface{}) {
 	l.log(INFO, fmt.Sprintf(format, args...))
 }
 
 // Warning reports the application alerts.
-func (l Logger) Warning(args ...interface{}) {
+func (l *Logger) Warning(args ...interface{}) {
 	l.log(WARNING, fmt.Sprint(args...))
 }
 
 // Warningf is like Warning but takes a formatted message.
-func (l Logger) Warningf(format string, args ...interface{}) {
+func (l *Logger) Warningf(format string, args ...interface{}) {
 	l.log(WARNING, fmt.Sprintf(format, args...))
 }
 
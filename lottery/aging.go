@@ -0,0 +1,74 @@
+package lottery
+
+import (
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+)
+
+// Aging buckets of the unclaimed prizes, by the number of days elapsed since they were won.
+// Expired prizes passed their claim deadline but haven't been swept by a draw yet.
+const (
+	AgingDay1    = "0-1d"
+	AgingDay2    = "1-2d"
+	AgingDay3    = "2-3d"
+	AgingOlder   = "3d+"
+	AgingExpired = "expired"
+)
+
+// agingBuckets is the order in which the buckets are reported.
+var agingBuckets = []string{AgingDay1, AgingDay2, AgingDay3, AgingOlder, AgingExpired}
+
+// AgingBucket is the number of unclaimed prizes in a bucket and their sum, in satoshis.
+type AgingBucket struct {
+	Bucket string `json:"bucket"`
+	Count  int    `json:"count"`
+	Amount uint64 `json:"amount"`
+}
+
+// Aging classifies the prizes owed to the winners by how long ago they were won.
+type Aging struct {
+	Buckets []AgingBucket `json:"buckets"`
+	// Liabilities is the sum of all the unclaimed prizes, in satoshis
+	Liabilities uint64 `json:"liabilities"`
+}
+
+// Bucket returns the aging bucket with the name specified and whether it exists.
+func (a Aging) Bucket(name string) (AgingBucket, bool) {
+	for _, bucket := range a.Buckets {
+		if bucket.Bucket == name {
+			return bucket, true
+		}
+	}
+	return AgingBucket{}, false
+}
+
+// PrizesAging returns the aging report of the unclaimed prizes at the block height specified.
+func PrizesAging(prizes []db.UnclaimedPrize, blockHeight uint32) Aging {
+	aging := Aging{Buckets: make([]AgingBucket, len(agingBuckets))}
+	for i, name := range agingBuckets {
+		aging.Buckets[i].Bucket = name
+	}
+
+	for _, prize := range prizes {
+		bucket := &aging.Buckets[agingBucket(prize, blockHeight)]
+		bucket.Count++
+		bucket.Amount += prize.Amount
+		aging.Liabilities += prize.Amount
+	}
+
+	return aging
+}
+
+// agingBucket returns the index of the bucket the prize belongs to.
+func agingBucket(prize db.UnclaimedPrize, blockHeight uint32) int {
+	if blockHeight >= prize.ClaimDeadline {
+		return len(agingBuckets) - 1
+	}
+
+	if blockHeight <= prize.LotteryHeight {
+		return 0
+	}
+
+	days := int((blockHeight - prize.LotteryHeight) / config.BlocksPerDay)
+	return min(days, len(agingBuckets)-2)
+}
@@ -0,0 +1,40 @@
+package lottery
+
+import (
+	"testing"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrizesAging(t *testing.T) {
+	prizes := []db.UnclaimedPrize{
+		{PublicKey: "1", Amount: 100, LotteryHeight: 1000, ClaimDeadline: 1720},
+		{PublicKey: "2", Amount: 50, LotteryHeight: 1400, ClaimDeadline: 2120},
+		{PublicKey: "3", Amount: 25, LotteryHeight: 1500, ClaimDeadline: 2220},
+		{PublicKey: "4", Amount: 10, LotteryHeight: 1700, ClaimDeadline: 2420},
+		{PublicKey: "5", Amount: 5, LotteryHeight: 1200, ClaimDeadline: 1920},
+	}
+
+	aging := PrizesAging(prizes, 1750)
+
+	expected := Aging{
+		Buckets: []AgingBucket{
+			{Bucket: AgingDay1, Count: 1, Amount: 10},
+			{Bucket: AgingDay2, Count: 1, Amount: 25},
+			{Bucket: AgingDay3, Count: 1, Amount: 50},
+			{Bucket: AgingOlder, Count: 1, Amount: 5},
+			{Bucket: AgingExpired, Count: 1, Amount: 100},
+		},
+		Liabilities: 190,
+	}
+	assert.Equal(t, expected, aging)
+
+	bucket, ok := aging.Bucket(AgingOlder)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(5), bucket.Amount)
+
+	_, ok = aging.Bucket("unknown")
+	assert.False(t, ok)
+}
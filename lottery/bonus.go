@@ -0,0 +1,75 @@
+package lottery
+
+import (
+	"math"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/lottery/engine"
+)
+
+// BetSettings provides the settings the bets are placed with. They can be reloaded, so they are
+// read on every bet.
+type BetSettings interface {
+	Bonus() config.Bonus
+	Pools() Pools
+}
+
+// BonusTickets returns the extra tickets granted to a bet of the amount specified, using the
+// bundle with the highest minimum amount the bet qualifies for.
+func BonusTickets(bundles []config.Bundle, amount uint64) uint64 {
+	var best *config.Bundle
+	for i, bundle := range bundles {
+		if amount < bundle.MinAmount {
+			continue
+		}
+		if best == nil || bundle.MinAmount > best.MinAmount {
+			best = &bundles[i]
+		}
+	}
+
+	if best == nil {
+		return 0
+	}
+
+	return uint64(math.Floor(float64(amount) * best.Percentage / 100))
+}
+
+// CoinAgeTickets returns the extra tickets granted to a bet of the amount specified placed when
+// blocksLeft blocks remain until the draw, using the schedule entry with the highest minimum of
+// blocks left the bet qualifies for.
+func CoinAgeTickets(schedule []config.CoinAge, amount uint64, blocksLeft uint32) uint64 {
+	var best *config.CoinAge
+	for i, coinAge := range schedule {
+		if blocksLeft < coinAge.MinBlocksLeft {
+			continue
+		}
+		if best == nil || coinAge.MinBlocksLeft > best.MinBlocksLeft {
+			best = &schedule[i]
+		}
+	}
+
+	if best == nil {
+		return 0
+	}
+
+	return uint64(math.Floor(float64(amount) * best.Percentage / 100))
+}
+
+// BonusFeeRate returns the percentage of the tickets paid in a pool that funds its bonus tickets,
+// the fee of its prize table minus the share the last ticket bonus takes from it.
+func BonusFeeRate(distribution engine.Distribution, lastTicket config.LastTicket) float64 {
+	fee := distribution.Fee()
+	if lastTicket.Tickets > 0 {
+		fee -= lastTicket.Percentage
+	}
+	return max(fee, 0)
+}
+
+// DrawHooks returns the hooks executed after the winners of each pool are drawn.
+func DrawHooks(lastTicket config.LastTicket) []engine.Hook {
+	if lastTicket.Tickets == 0 {
+		return nil
+	}
+
+	return []engine.Hook{engine.LastTicketBonus(lastTicket.Tickets, lastTicket.Percentage)}
+}
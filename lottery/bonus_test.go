@@ -0,0 +1,112 @@
+package lottery
+
+import (
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/lottery/engine"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBonusTickets(t *testing.T) {
+	bundles := []config.Bundle{
+		{MinAmount: 100_000, Percentage: 10},
+		{MinAmount: 10_000, Percentage: 2.5},
+	}
+
+	cases := []struct {
+		desc     string
+		bundles  []config.Bundle
+		amount   uint64
+		expected uint64
+	}{
+		{
+			desc:     "No bundles",
+			amount:   100_000,
+			expected: 0,
+		},
+		{
+			desc:     "Below minimum",
+			bundles:  bundles,
+			amount:   9_999,
+			expected: 0,
+		},
+		{
+			desc:     "Lower bundle",
+			bundles:  bundles,
+			amount:   10_050,
+			expected: 251,
+		},
+		{
+			desc:     "Higher bundle",
+			bundles:  bundles,
+			amount:   150_000,
+			expected: 15_000,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, BonusTickets(tc.bundles, tc.amount))
+		})
+	}
+}
+
+func TestCoinAgeTickets(t *testing.T) {
+	schedule := []config.CoinAge{
+		{MinBlocksLeft: 72, Percentage: 1},
+		{MinBlocksLeft: 120, Percentage: 2.5},
+	}
+
+	cases := []struct {
+		desc       string
+		schedule   []config.CoinAge
+		blocksLeft uint32
+		expected   uint64
+	}{
+		{
+			desc:       "No schedule",
+			blocksLeft: 144,
+			expected:   0,
+		},
+		{
+			desc:       "Late bet",
+			schedule:   schedule,
+			blocksLeft: 71,
+			expected:   0,
+		},
+		{
+			desc:       "Lower entry",
+			schedule:   schedule,
+			blocksLeft: 72,
+			expected:   100,
+		},
+		{
+			desc:       "Higher entry",
+			schedule:   schedule,
+			blocksLeft: 143,
+			expected:   250,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, CoinAgeTickets(tc.schedule, 10_000, tc.blocksLeft))
+		})
+	}
+}
+
+func TestDrawHooks(t *testing.T) {
+	assert.Nil(t, DrawHooks(config.LastTicket{Percentage: 1}))
+	assert.Len(t, DrawHooks(config.LastTicket{Tickets: 1000, Percentage: 1}), 1)
+}
+
+func TestBonusFeeRate(t *testing.T) {
+	distribution := engine.Distribution{60, 30}
+	assert.Equal(t, float64(10), BonusFeeRate(distribution, config.LastTicket{}))
+	// The last ticket bonus is disabled without tickets
+	assert.Equal(t, float64(10), BonusFeeRate(distribution, config.LastTicket{Percentage: 4}))
+	assert.Equal(t, float64(6), BonusFeeRate(distribution, config.LastTicket{Tickets: 100, Percentage: 4}))
+	assert.Zero(t, BonusFeeRate(distribution, config.LastTicket{Tickets: 100, Percentage: 15}))
+}
@@ -0,0 +1,122 @@
+package lottery
+
+import (
+	"context"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+)
+
+// DefaultCapacityDivisor is the divisor of the remote balance used when none is configured.
+const DefaultCapacityDivisor = 5
+
+// CapacityOracle calculates the maximum number of sats bet in a lottery, the pools get a share of
+// it.
+type CapacityOracle interface {
+	Capacity(ctx context.Context, lnd lightning.NodeInfo, db *db.DB) (int64, error)
+}
+
+// NewCapacityOracle returns the oracle of the configured mode.
+func NewCapacityOracle(config config.Capacity) CapacityOracle {
+	switch config.Mode {
+	case "liability":
+		return LiabilityCapacity{Divisor: config.Divisor}
+	case "fixed":
+		return FixedCapacity{Amount: config.Override, Divisor: config.Divisor}
+	case "remote_balance":
+		return RemoteBalanceCapacity{Divisor: config.Divisor}
+	}
+
+	if config.Override > 0 {
+		return FixedCapacity{Amount: config.Override, Divisor: config.Divisor}
+	}
+	return RemoteBalanceCapacity{Divisor: config.Divisor}
+}
+
+// RemoteBalanceCapacity is the remote balance of the node channels divided by Divisor.
+type RemoteBalanceCapacity struct {
+	Divisor int64
+}
+
+// Capacity implements CapacityOracle.
+func (c RemoteBalanceCapacity) Capacity(ctx context.Context, lnd lightning.NodeInfo, _ *db.DB) (int64, error) {
+	remoteBalance, err := lnd.RemoteBalance(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return c.Backed(remoteBalance), nil
+}
+
+// Backed returns the capacity the remote balance specified supports.
+func (c RemoteBalanceCapacity) Backed(remoteBalance int64) int64 {
+	if c.Divisor <= 0 {
+		return remoteBalance / DefaultCapacityDivisor
+	}
+	return remoteBalance / c.Divisor
+}
+
+// LiabilityCapacity is the capacity backed by the remote balance minus the prizes owed that the
+// local balance doesn't cover, as they would be paid with the bets received.
+type LiabilityCapacity struct {
+	Divisor int64
+}
+
+// Capacity implements CapacityOracle.
+func (c LiabilityCapacity) Capacity(ctx context.Context, lnd lightning.NodeInfo, db *db.DB) (int64, error) {
+	backed, err := RemoteBalanceCapacity(c).Capacity(ctx, lnd, db)
+	if err != nil {
+		return 0, err
+	}
+
+	channels, err := lnd.ListChannels(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var localBalance int64
+	for _, channel := range channels {
+		localBalance += channel.LocalBalance
+	}
+
+	liabilities, err := db.Prizes.GetTotal()
+	if err != nil {
+		return 0, err
+	}
+
+	shortfall := max(int64(liabilities)-localBalance, 0)
+	return max(backed-shortfall, 0), nil
+}
+
+// FixedCapacity is a constant capacity regardless of the node liquidity.
+type FixedCapacity struct {
+	Amount int64
+	// Divisor of the remote balance the draws compare the amount with
+	Divisor int64
+}
+
+// Capacity implements CapacityOracle.
+func (c FixedCapacity) Capacity(context.Context, lightning.NodeInfo, *db.DB) (int64, error) {
+	return c.Amount, nil
+}
+
+// checkCapacity warns when the fixed capacity exceeds the one the node liquidity backs at the time
+// of the draw, as the prizes may not be payable.
+func (l *Lottery) checkCapacity(ctx context.Context, lotteryHeight uint32, prizePool uint64) {
+	fixed, ok := l.capacity.(FixedCapacity)
+	if !ok {
+		return
+	}
+
+	remoteBalance, err := l.lnd.RemoteBalance(ctx)
+	if err != nil {
+		l.logger.Errorf("Checking lottery %d capacity: %v", lotteryHeight, err)
+		return
+	}
+
+	backed := RemoteBalanceCapacity{Divisor: fixed.Divisor}.Backed(remoteBalance)
+	if fixed.Amount > backed {
+		l.logger.Warningf("Lottery %d fixed capacity of %d sats exceeds the %d sats backed by "+
+			"the remote balance, prize pool: %d sats", lotteryHeight, fixed.Amount, backed, prizePool)
+	}
+}
@@ -0,0 +1,101 @@
+package lottery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapacityOracle(t *testing.T) {
+	cases := []struct {
+		desc        string
+		config      config.Capacity
+		liabilities uint64
+		expected    int64
+	}{
+		{
+			desc:     "Default divisor",
+			config:   config.Capacity{},
+			expected: 2_000_000,
+		},
+		{
+			desc:     "Custom divisor",
+			config:   config.Capacity{Divisor: 20},
+			expected: 500_000,
+		},
+		{
+			desc:     "Override",
+			config:   config.Capacity{Divisor: 20, Override: 50_000_000},
+			expected: 50_000_000,
+		},
+		{
+			desc:     "Fixed",
+			config:   config.Capacity{Mode: "fixed", Override: 50_000_000},
+			expected: 50_000_000,
+		},
+		{
+			desc:     "Remote balance",
+			config:   config.Capacity{Mode: "remote_balance", Divisor: 10},
+			expected: 1_000_000,
+		},
+		{
+			desc:        "Liabilities covered",
+			config:      config.Capacity{Mode: "liability"},
+			liabilities: 300_000,
+			expected:    2_000_000,
+		},
+		{
+			desc:        "Liabilities shortfall",
+			config:      config.Capacity{Mode: "liability"},
+			liabilities: 1_000_000,
+			expected:    1_500_000,
+		},
+		{
+			desc:        "Liabilities exceed capacity",
+			config:      config.Capacity{Mode: "liability"},
+			liabilities: 5_000_000,
+			expected:    0,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+			lndMock := lightning.NewClientMock()
+			lndMock.On("RemoteBalance", ctx).Return(int64(10_000_000), nil).Maybe()
+			channels := []*lnrpc.Channel{{LocalBalance: 200_000}, {LocalBalance: 300_000}}
+			lndMock.On("ListChannels", ctx).Return(channels, nil).Maybe()
+			prizesMock := db.NewPrizesStoreMock()
+			prizesMock.On("GetTotal").Return(tc.liabilities, nil).Maybe()
+
+			oracle := NewCapacityOracle(tc.config)
+			capacity, err := oracle.Capacity(ctx, lndMock, &db.DB{Prizes: prizesMock})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, capacity)
+		})
+	}
+}
+
+func TestCheckCapacity(t *testing.T) {
+	ctx := context.Background()
+	lndMock := lightning.NewClientMock()
+	lndMock.On("RemoteBalance", ctx).Return(int64(10_000_000), nil).Once()
+
+	config := config.Lottery{Capacity: config.Capacity{Override: 5_000_000}}
+	lottery, err := New(config, nil, lndMock, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	lottery.checkCapacity(ctx, 144, 1_000_000)
+	lndMock.AssertExpectations(t)
+
+	// Without a fixed capacity the remote balance is not checked
+	lottery.capacity = RemoteBalanceCapacity{}
+	lottery.checkCapacity(ctx, 144, 1_000_000)
+	lndMock.AssertNumberOfCalls(t, "RemoteBalance", 1)
+}
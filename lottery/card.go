@@ -0,0 +1,85 @@
+package lottery
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/aftermath2/BTRY/db"
+)
+
+// Card dimensions, in pixels, the size social networks expect for Open Graph images.
+const (
+	cardWidth  = 1200
+	cardHeight = 630
+)
+
+// DrawCard summarizes the result of a draw to share it.
+type DrawCard struct {
+	Height    uint32
+	PrizePool uint64
+	Players   uint64
+	Winners   uint64
+	// TopPrize is the sum of the prizes won by the luckiest player
+	TopPrize uint64
+}
+
+// NewDrawCard returns the card of the lottery with the statistics and winners specified.
+func NewDrawCard(round db.RoundStats, winners []db.Winner) DrawCard {
+	prizes := make(map[string]uint64, len(winners))
+	for _, winner := range winners {
+		prizes[winner.PublicKey] += winner.Prize
+	}
+
+	card := DrawCard{
+		Height:    round.Height,
+		PrizePool: round.PrizePool,
+		Players:   round.Players,
+		Winners:   round.Winners,
+	}
+	for _, prize := range prizes {
+		card.TopPrize = max(card.TopPrize, prize)
+	}
+
+	return card
+}
+
+// SVG returns the card rendered as an SVG image. It only uses generic font families so it looks the
+// same without embedding any.
+func (c DrawCard) SVG() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" `+
+		`viewBox="0 0 %d %d">`, cardWidth, cardHeight, cardWidth, cardHeight)
+	buf.WriteString(`<rect width="100%" height="100%" fill="#101418"/>`)
+	buf.WriteString(`<rect x="0" y="0" width="100%" height="12" fill="#f7931a"/>`)
+	buf.WriteString(`<g font-family="sans-serif" fill="#ffffff">`)
+	buf.WriteString(`<text x="80" y="130" font-size="56" font-weight="bold">BTRY</text>`)
+	fmt.Fprintf(&buf, `<text x="80" y="190" font-size="32" fill="#9aa5b1">Lottery #%d drawn</text>`,
+		c.Height)
+	buf.WriteString(`<text x="80" y="300" font-size="32" fill="#9aa5b1">Prize pool</text>`)
+	fmt.Fprintf(&buf, `<text x="80" y="380" font-size="72" font-weight="bold">%s sats</text>`,
+		groupDigits(c.PrizePool))
+	buf.WriteString(`<text x="80" y="470" font-size="32" fill="#9aa5b1">Top prize</text>`)
+	fmt.Fprintf(&buf, `<text x="80" y="530" font-size="48" fill="#f7931a">%s sats</text>`,
+		groupDigits(c.TopPrize))
+	fmt.Fprintf(&buf, `<text x="%d" y="530" font-size="32" text-anchor="end">`+
+		`%d players · %d winners</text>`, cardWidth-80, c.Players, c.Winners)
+	buf.WriteString(`</g></svg>`)
+
+	return buf.Bytes()
+}
+
+// groupDigits returns the number with its digits grouped by thousands.
+func groupDigits(n uint64) string {
+	digits := strconv.FormatUint(n, 10)
+
+	var buf bytes.Buffer
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteRune(digit)
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,55 @@
+package lottery
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDrawCard(t *testing.T) {
+	round := db.RoundStats{Height: 144, PrizePool: 1_250_000, Players: 12, Winners: 2}
+	winners := []db.Winner{
+		{PublicKey: "1", Prize: 500_000},
+		{PublicKey: "2", Prize: 600_000},
+		{PublicKey: "1", Prize: 150_000},
+	}
+
+	card := NewDrawCard(round, winners)
+
+	expected := DrawCard{Height: 144, PrizePool: 1_250_000, Players: 12, Winners: 2, TopPrize: 650_000}
+	assert.Equal(t, expected, card)
+}
+
+func TestDrawCardSVG(t *testing.T) {
+	card := DrawCard{Height: 144, PrizePool: 1_250_000, Players: 12, Winners: 2, TopPrize: 650_000}
+
+	svg := card.SVG()
+
+	var doc struct {
+		XMLName xml.Name
+		Texts   []string `xml:"g>text"`
+	}
+	assert.NoError(t, xml.Unmarshal(svg, &doc))
+	assert.Equal(t, "svg", doc.XMLName.Local)
+	assert.Contains(t, doc.Texts, "Lottery #144 drawn")
+	assert.Contains(t, doc.Texts, "1,250,000 sats")
+	assert.Contains(t, doc.Texts, "650,000 sats")
+	assert.Contains(t, doc.Texts, "12 players · 2 winners")
+}
+
+func TestGroupDigits(t *testing.T) {
+	cases := map[uint64]string{
+		0:         "0",
+		999:       "999",
+		1_000:     "1,000",
+		100_000:   "100,000",
+		1_234_567: "1,234,567",
+	}
+
+	for n, expected := range cases {
+		assert.Equal(t, expected, groupDigits(n))
+	}
+}
@@ -0,0 +1,56 @@
+package lottery
+
+import (
+	"time"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// Failed deliveries are retried on the first block found after the backoff, which doubles with
+// every attempt up to about 10 hours.
+const (
+	deliveryBackoff         = 10 * time.Minute
+	maxDeliveryBackoffShift = 6
+)
+
+// retryDeliveries sends again the notifications that a service failed to deliver and are still
+// retried. Services that were disabled in the meantime are skipped.
+func (l *Lottery) retryDeliveries() {
+	now := l.now()
+	deliveries, err := l.db.Notifications.ListRetries(now.Unix())
+	if err != nil {
+		l.logger.Error(errors.Wrap(err, "listing notification retries"))
+		return
+	}
+
+	for _, delivery := range deliveries {
+		shift := min(max(delivery.Attempts, 1)-1, maxDeliveryBackoffShift)
+		if now.Before(time.Unix(delivery.UpdatedAt, 0).Add(deliveryBackoff << shift)) {
+			continue
+		}
+
+		switch delivery.Channel {
+		case db.ChannelTelegram:
+			chatID, err := l.db.Notifications.GetChatID(delivery.PublicKey)
+			if err != nil {
+				if !errors.Is(err, db.ErrNoChatID) {
+					l.logger.Error(errors.Wrap(err, "getting telegram chat ID"))
+				}
+				continue
+			}
+			l.notifier.NotifyPlayer(delivery, chatID)
+
+		case db.ChannelNostr:
+			nostrKey, err := l.db.Notifications.GetNostrKey(delivery.PublicKey)
+			if err != nil {
+				if !errors.Is(err, db.ErrNoNostrKey) {
+					l.logger.Error(errors.Wrap(err, "getting nostr key"))
+				}
+				continue
+			}
+			l.notifier.NotifyNostr(delivery, nostrKey)
+		}
+	}
+}
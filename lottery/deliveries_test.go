@@ -0,0 +1,81 @@
+package lottery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRetryDeliveries(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	telegram := db.NotificationDelivery{
+		ID:         1,
+		PublicKey:  "a",
+		Channel:    db.ChannelTelegram,
+		Status:     db.DeliveryFailed,
+		Message:    "Congratulations!",
+		Attempts:   3,
+		RetryUntil: now.Add(time.Hour).Unix(),
+		UpdatedAt:  now.Add(-40 * time.Minute).Unix(),
+	}
+	nostr := db.NotificationDelivery{
+		ID:         2,
+		PublicKey:  "a",
+		Channel:    db.ChannelNostr,
+		Status:     db.DeliveryFailed,
+		Message:    "Congratulations!",
+		Attempts:   1,
+		RetryUntil: now.Add(time.Hour).Unix(),
+		UpdatedAt:  now.Add(-10 * time.Minute).Unix(),
+	}
+	// The backoff doubled to 40 minutes after the fourth attempt
+	backingOff := db.NotificationDelivery{
+		ID:         3,
+		PublicKey:  "b",
+		Channel:    db.ChannelTelegram,
+		Status:     db.DeliveryFailed,
+		Attempts:   4,
+		RetryUntil: now.Add(time.Hour).Unix(),
+		UpdatedAt:  now.Add(-30 * time.Minute).Unix(),
+	}
+	// The player disabled the service after the delivery failed
+	disabled := db.NotificationDelivery{
+		ID:         4,
+		PublicKey:  "c",
+		Channel:    db.ChannelNostr,
+		Status:     db.DeliveryFailed,
+		Attempts:   1,
+		RetryUntil: now.Add(time.Hour).Unix(),
+		UpdatedAt:  now.Add(-time.Hour).Unix(),
+	}
+
+	notificationsMock := db.NewNotificationsStoreMock()
+	notificationsMock.On("ListRetries", now.Unix()).
+		Return([]db.NotificationDelivery{telegram, nostr, backingOff, disabled}, nil)
+	notificationsMock.On("GetChatID", "a").Return(int64(1), nil)
+	notificationsMock.On("GetNostrKey", "a").Return("nostr_key", nil)
+	notificationsMock.On("GetNostrKey", "c").Return("", db.ErrNoNostrKey)
+
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("NotifyPlayer", telegram, int64(1))
+	notifierMock.On("NotifyNostr", nostr, "nostr_key")
+
+	lottery, err := New(config.Lottery{}, &db.DB{Notifications: notificationsMock}, nil, notifierMock,
+		templates, nil, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	lottery.now = func() time.Time { return now }
+
+	lottery.retryDeliveries()
+
+	notifierMock.AssertExpectations(t)
+	notifierMock.AssertNumberOfCalls(t, "NotifyPlayer", 1)
+	notifierMock.AssertNumberOfCalls(t, "NotifyNostr", 1)
+	notificationsMock.AssertNotCalled(t, "GetChatID", "b")
+	notifierMock.AssertNotCalled(t, "NotifyNostr", disabled, mock.Anything)
+}
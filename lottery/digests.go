@@ -0,0 +1,104 @@
+package lottery
+
+import (
+	"slices"
+	"time"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/pkg/errors"
+)
+
+// Defaults of the digests batching, Telegram bots can send about 30 messages per second.
+const (
+	defaultDigestBatchSize = 20
+	defaultDigestInterval  = time.Second
+)
+
+// enqueueDigest enqueues the digest of the draw, which is sent to the players that opted in to it.
+func (l *Lottery) enqueueDigest(height uint32, bets []db.Bet, winners []db.Winner) {
+	if !l.digest.Enabled {
+		return
+	}
+
+	players := make([]string, 0, len(bets))
+	seen := make(map[string]struct{}, len(bets))
+	for _, bet := range bets {
+		if _, ok := seen[bet.PublicKey]; ok {
+			continue
+		}
+		seen[bet.PublicKey] = struct{}{}
+		players = append(players, bet.PublicKey)
+	}
+
+	l.enqueue(jobDrawDigest, drawDigestJob{Height: height, Players: players, Winners: winners})
+}
+
+// scheduleDigests splits the players of the draw that opted in to the digests in batches, which
+// are scheduled an interval apart to respect the rate limits of the notification services.
+func (l *Lottery) scheduleDigests(job drawDigestJob) error {
+	subscribers, err := l.db.Notifications.ListDigests()
+	if err != nil {
+		return errors.Wrap(err, "listing digests")
+	}
+
+	subscribed := make(map[string]struct{}, len(subscribers))
+	for _, publicKey := range subscribers {
+		subscribed[publicKey] = struct{}{}
+	}
+
+	recipients := make([]string, 0, len(subscribers))
+	for _, publicKey := range job.Players {
+		if _, ok := subscribed[publicKey]; ok {
+			recipients = append(recipients, publicKey)
+		}
+	}
+
+	tickets := make([]uint64, 0, len(job.Winners))
+	for _, winner := range job.Winners {
+		tickets = append(tickets, winner.Ticket)
+	}
+	slices.Sort(tickets)
+	tickets = slices.Compact(tickets)
+	prizes := aggregateWinners(job.Winners)
+
+	runAt := l.now()
+	for start := 0; start < len(recipients); start += l.digest.BatchSize {
+		batch := recipients[start:min(start+l.digest.BatchSize, len(recipients))]
+		batchPrizes := make(map[string]uint64)
+		for _, publicKey := range batch {
+			if prize, ok := prizes[publicKey]; ok {
+				batchPrizes[publicKey] = prize
+			}
+		}
+
+		payload := digestBatchJob{
+			Height:     job.Height,
+			PublicKeys: batch,
+			Tickets:    tickets,
+			Prizes:     batchPrizes,
+		}
+		// Errors are only logged, retrying the job would send the batches scheduled again
+		if err := l.queue.Schedule(jobDigestBatch, payload, runAt); err != nil {
+			l.logger.Error(err)
+		}
+		runAt = runAt.Add(l.digest.Interval)
+	}
+
+	return nil
+}
+
+// sendDigests notifies each player of the batch of their result in the draw.
+func (l *Lottery) sendDigests(job digestBatchJob) {
+	for _, publicKey := range job.PublicKeys {
+		message, ok := l.render(notification.EventDigest, notification.Data{
+			Height:  job.Height,
+			Prize:   job.Prizes[publicKey],
+			Tickets: job.Tickets,
+		})
+		if ok {
+			l.notify(publicKey, message)
+		}
+	}
+}
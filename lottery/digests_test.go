@@ -0,0 +1,99 @@
+package lottery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/jobs"
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnqueueDigest(t *testing.T) {
+	bets := []db.Bet{{PublicKey: "a"}, {PublicKey: "b"}, {PublicKey: "a"}}
+	winners := []db.Winner{{PublicKey: "a", Prize: 10, Ticket: 5}}
+	queueMock := jobs.NewQueueMock()
+	job := drawDigestJob{Height: 144, Players: []string{"a", "b"}, Winners: winners}
+	queueMock.On("Enqueue", jobDrawDigest, job).Return(nil)
+
+	lottery, err := New(config.Lottery{Digest: config.Digest{Enabled: true}}, nil, nil, nil, nil, nil,
+		nil, nil, queueMock, nil, nil, nil)
+	assert.NoError(t, err)
+
+	lottery.enqueueDigest(144, bets, winners)
+	queueMock.AssertExpectations(t)
+
+	// Nothing is enqueued if the digests are disabled
+	lottery.digest.Enabled = false
+	lottery.enqueueDigest(144, bets, winners)
+	queueMock.AssertNumberOfCalls(t, "Enqueue", 1)
+}
+
+func TestScheduleDigests(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	notificationsMock := db.NewNotificationsStoreMock()
+	notificationsMock.On("ListDigests").Return([]string{"x", "c", "b", "a"}, nil)
+	queueMock := jobs.NewQueueMock()
+	queueMock.On("Schedule", jobDigestBatch, digestBatchJob{
+		Height:     144,
+		PublicKeys: []string{"a", "b"},
+		Tickets:    []uint64{3, 5},
+		Prizes:     map[string]uint64{"a": 15},
+	}, now).Return(nil)
+	queueMock.On("Schedule", jobDigestBatch, digestBatchJob{
+		Height:     144,
+		PublicKeys: []string{"c"},
+		Tickets:    []uint64{3, 5},
+		Prizes:     map[string]uint64{},
+	}, now.Add(2*time.Second)).Return(nil)
+
+	digest := config.Digest{Enabled: true, BatchSize: 2, Interval: 2 * time.Second}
+	lottery, err := New(config.Lottery{Digest: digest}, &db.DB{Notifications: notificationsMock}, nil,
+		nil, nil, nil, nil, nil, queueMock, nil, nil, nil)
+	assert.NoError(t, err)
+	lottery.now = func() time.Time { return now }
+
+	err = lottery.scheduleDigests(drawDigestJob{
+		Height:  144,
+		Players: []string{"a", "b", "c", "d"},
+		Winners: []db.Winner{
+			{PublicKey: "a", Prize: 10, Ticket: 5},
+			{PublicKey: "d", Prize: 20, Ticket: 3},
+			{PublicKey: "a", Prize: 5, Ticket: 5},
+		},
+	})
+	assert.NoError(t, err)
+	queueMock.AssertExpectations(t)
+}
+
+func TestSendDigests(t *testing.T) {
+	notificationsMock := db.NewNotificationsStoreMock()
+	notificationsMock.On("GetChatID", "a").Return(int64(1), nil)
+	notificationsMock.On("GetChatID", "b").Return(int64(2), nil)
+	notificationsMock.On("GetNostrKey", "a").Return("", db.ErrNoNostrKey)
+	notificationsMock.On("GetNostrKey", "b").Return("", db.ErrNoNostrKey)
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("NotifyPlayer", db.NotificationDelivery{
+		PublicKey: "a",
+		Message:   "Lottery 144 was drawn. You won 15 sats!\nWinning tickets: #3, #5",
+	}, int64(1))
+	notifierMock.On("NotifyPlayer", db.NotificationDelivery{
+		PublicKey: "b",
+		Message:   "Lottery 144 was drawn. Your tickets didn't win this time.\nWinning tickets: #3, #5",
+	}, int64(2))
+
+	lottery, err := New(config.Lottery{}, &db.DB{Notifications: notificationsMock}, nil, notifierMock,
+		notification.DefaultTemplates(), nil, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	lottery.sendDigests(digestBatchJob{
+		Height:     144,
+		PublicKeys: []string{"a", "b"},
+		Tickets:    []uint64{3, 5},
+		Prizes:     map[string]uint64{"a": 15},
+	})
+	notifierMock.AssertExpectations(t)
+}
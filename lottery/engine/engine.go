@@ -0,0 +1,503 @@
+// Package engine contains the deterministic logic used to select the lottery winners.
+//
+// It has no database nor lightning dependencies so the results can be reproduced by anyone that
+// has the seed and the tickets.
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"math/big"
+	"slices"
+
+	"github.com/pkg/errors"
+)
+
+// Prize pool percentages
+const (
+	first   float64 = 50
+	second          = first / 2
+	third           = second / 2
+	fourth          = third / 2
+	fifth           = fourth / 2
+	sixth           = fifth / 2
+	seventh         = sixth / 2
+	eighth          = seventh / 2
+)
+
+// lastTicketTag is hashed with the draw seed to select the last ticket bonus winner.
+const lastTicketTag = "last_ticket"
+
+// DefaultDistribution is the prize pool percentage assigned to each winner, the remaining is BTRY's
+// fee.
+var DefaultDistribution = Distribution{first, second, third, fourth, fifth, sixth, seventh, eighth}
+
+// Distribution contains the prize pool percentages assigned to each winner, in order.
+type Distribution []float64
+
+// Fee returns the percentage of the prize pool that is not distributed to the winners.
+func (d Distribution) Fee() float64 {
+	total := float64(100)
+	for _, percentage := range d {
+		total -= percentage
+	}
+	return total
+}
+
+// Validate returns an error if the distribution can't be used to draw a lottery.
+func (d Distribution) Validate() error {
+	// Each winner takes two bytes of the 32 bytes seed
+	if len(d) > 16 {
+		return errors.New("it can have up to 16 winners")
+	}
+
+	total := float64(0)
+	for _, percentage := range d {
+		if percentage <= 0 {
+			return errors.New("percentages must be higher than zero")
+		}
+		total += percentage
+	}
+	if total > 100 {
+		return errors.New("percentages add up to more than 100")
+	}
+
+	return nil
+}
+
+// Adapt returns the distribution with its first tiers only, scaled so the prizes keep their
+// proportion and the fee stays the same. Distributions with tiers or fewer entries are returned as
+// they are.
+func (d Distribution) Adapt(tiers int) Distribution {
+	if tiers <= 0 || tiers >= len(d) {
+		return d
+	}
+
+	distributed := 100 - d.Fee()
+	kept := float64(0)
+	for _, percentage := range d[:tiers] {
+		kept += percentage
+	}
+
+	adapted := make(Distribution, 0, tiers)
+	for _, percentage := range d[:tiers] {
+		adapted = append(adapted, percentage*distributed/kept)
+	}
+
+	return adapted
+}
+
+// TierStep sets the number of prize tiers of the lotteries with Participants unique public keys or
+// more.
+type TierStep struct {
+	Participants uint64
+	Tiers        int
+}
+
+// TierSchedule contains the steps that scale the prize tiers with the participants of a lottery,
+// sorted by participants.
+type TierSchedule []TierStep
+
+// Validate returns an error if the schedule can't be used to draw a lottery.
+func (s TierSchedule) Validate() error {
+	for i, step := range s {
+		if step.Tiers <= 0 || step.Tiers > 16 {
+			return errors.New("tiers must be between 1 and 16")
+		}
+		if i > 0 && step.Participants <= s[i-1].Participants {
+			return errors.New("steps must be sorted by participants, without duplicates")
+		}
+	}
+
+	return nil
+}
+
+// Tiers returns the number of prize tiers of a lottery with the participants specified, the ones
+// of the last step reached. Lotteries below the first step use its tiers, an empty schedule
+// returns 0.
+func (s TierSchedule) Tiers(participants uint64) int {
+	tiers := 0
+	for i, step := range s {
+		if i > 0 && participants < step.Participants {
+			break
+		}
+		tiers = step.Tiers
+	}
+
+	return tiers
+}
+
+// Participants returns the number of unique public keys holding tickets.
+func Participants(tickets []Tickets) uint64 {
+	publicKeys := make(map[string]struct{}, len(tickets))
+	for _, t := range tickets {
+		publicKeys[t.PublicKey] = struct{}{}
+	}
+
+	return uint64(len(publicKeys))
+}
+
+// Rounding policies, they decide what happens with the fractions of sat of the prizes.
+const (
+	// RoundingNearest rounds each prize to the nearest sat, the default. If the prizes add up to
+	// more than the prize pool, the excess is taken from the last ones
+	RoundingNearest Rounding = "nearest"
+	// RoundingFirstPrize rounds the prizes down and adds the sats left to the first one
+	RoundingFirstPrize Rounding = "first_prize"
+	// RoundingFee rounds the prizes down and keeps the sats left as part of the fee
+	RoundingFee Rounding = "fee"
+)
+
+// Rounding is the policy used to turn the prize percentages into whole sats. The empty value is
+// RoundingNearest.
+type Rounding string
+
+// Validate returns an error if the rounding policy is unknown.
+func (r Rounding) Validate() error {
+	switch r {
+	case "", RoundingNearest, RoundingFirstPrize, RoundingFee:
+		return nil
+	default:
+		return errors.Errorf("unknown rounding policy %q", r)
+	}
+}
+
+// Prizes returns the prize of each winner in the distribution, rounded with the policy specified.
+// It returns an error if they add up to more than the prize pool.
+func Prizes(prizePool uint64, distribution Distribution, rounding Rounding) ([]uint64, error) {
+	if err := rounding.Validate(); err != nil {
+		return nil, err
+	}
+
+	prizes := make([]uint64, 0, len(distribution))
+	total := uint64(0)
+	exact := float64(0)
+	for _, percentage := range distribution {
+		prize := (percentage / 100) * float64(prizePool)
+		exact += prize
+		if rounding == RoundingFirstPrize || rounding == RoundingFee {
+			prize = math.Floor(prize)
+		} else {
+			prize = math.Round(prize)
+		}
+
+		prizes = append(prizes, uint64(prize))
+		total += uint64(prize)
+	}
+
+	switch rounding {
+	case RoundingFirstPrize:
+		// Tolerate floating point errors so whole amounts aren't rounded down
+		distributed := min(uint64(math.Floor(exact+1e-6)), prizePool)
+		if len(prizes) > 0 && distributed > total {
+			prizes[0] += distributed - total
+			total = distributed
+		}
+
+	case "", RoundingNearest:
+		// Prizes rounded up can only exceed the pool when the fee is lower than a sat
+		for i := len(prizes) - 1; i >= 0 && total > prizePool; i-- {
+			excess := min(total-prizePool, prizes[i])
+			prizes[i] -= excess
+			total -= excess
+		}
+	}
+
+	if total > prizePool {
+		return nil, errors.Errorf("prizes add up to %d sats, more than the prize pool of %d sats",
+			total, prizePool)
+	}
+
+	return prizes, nil
+}
+
+// Collision policies, they decide what happens when a prize tier lands on a ticket owned by a
+// public key that already won a higher one in the same draw.
+const (
+	// CollisionStack lets a public key win several prizes, the default
+	CollisionStack Collision = "stack"
+	// CollisionReroll draws the tier again with the hash of the seed until the ticket belongs to a
+	// public key that didn't win yet
+	CollisionReroll Collision = "reroll"
+	// CollisionCascade moves the prize to the first ticket of the next holder that didn't win yet
+	CollisionCascade Collision = "cascade"
+)
+
+// collisionTag is hashed with the draw seed, the tier and the attempt to re-roll collisions.
+const collisionTag = "collision"
+
+// maxRerolls is the number of times a tier is drawn again before its collision is accepted.
+const maxRerolls = 16
+
+// Collision is the policy applied when several prize tiers land on the same public key. The empty
+// value is CollisionStack.
+//
+// Public keys keep their prizes stacked when every holder already won, whatever the policy.
+type Collision string
+
+// Validate returns an error if the collision policy is unknown.
+func (c Collision) Validate() error {
+	switch c {
+	case "", CollisionStack, CollisionReroll, CollisionCascade:
+		return nil
+	default:
+		return errors.Errorf("unknown collision policy %q", c)
+	}
+}
+
+// Tickets is a range of tickets owned by a public key. It goes from the index of the previous
+// range plus one to Index, inclusive.
+type Tickets struct {
+	PublicKey string `json:"public_key"`
+	Index     uint64 `json:"index"`
+}
+
+// Winner is a ticket selected in a draw and the prize assigned to it.
+type Winner struct {
+	PublicKey string `json:"public_key"`
+	Ticket    uint64 `json:"ticket"`
+	Prize     uint64 `json:"prize"`
+}
+
+// Commitment returns the hash of the server seed, which is published before the lottery starts so
+// the server can't change it afterwards.
+func Commitment(serverSeed []byte) []byte {
+	hash := sha256.Sum256(serverSeed)
+	return hash[:]
+}
+
+// DrawSeed returns the seed used to draw the winners of a lottery, the hash of the server seed
+// revealed and the block hash. Neither the server nor the miners can choose the result on their
+// own. Lotteries without a server seed use the block hash as is.
+func DrawSeed(serverSeed, blockHash []byte) []byte {
+	if len(serverSeed) == 0 {
+		return blockHash
+	}
+
+	hash := sha256.Sum256(append(slices.Clip(serverSeed), blockHash...))
+	return hash[:]
+}
+
+// PoolSeed returns the seed used to draw the winners of a lottery pool. The unnamed pool uses the
+// seed as is, named ones hash it together with their name so their draws are independent.
+func PoolSeed(seed []byte, pool string) []byte {
+	if pool == "" {
+		return seed
+	}
+
+	hash := sha256.Sum256(append(slices.Clip(seed), pool...))
+	return hash[:]
+}
+
+// Draw selects one winner per distribution entry taking two bytes of the seed each, starting from
+// the end. The prizes are rounded with the policy specified and the tiers landing on a public key
+// that already won are resolved with the collision policy. The hooks are executed in order
+// afterwards, the winners they return are appended.
+//
+// The tickets must be sorted by index, the highest one is the prize pool.
+func Draw(
+	seed []byte,
+	tickets []Tickets,
+	distribution Distribution,
+	rounding Rounding,
+	collision Collision,
+	hooks ...Hook,
+) ([]Winner, error) {
+	if err := collision.Validate(); err != nil {
+		return nil, err
+	}
+
+	if len(tickets) == 0 {
+		return nil, nil
+	}
+
+	if len(seed) < len(distribution)*2 {
+		return nil, errors.Errorf("seed too short, at least %d bytes are required",
+			len(distribution)*2)
+	}
+
+	prizePool := tickets[len(tickets)-1].Index
+	if prizePool == 0 {
+		return nil, errors.New("prize pool is empty")
+	}
+
+	prizes, err := Prizes(prizePool, distribution, rounding)
+	if err != nil {
+		return nil, err
+	}
+
+	winners := make([]Winner, 0, len(distribution))
+	won := make(map[string]struct{}, len(distribution))
+	i := len(seed) - 1
+
+	for tier, prize := range prizes {
+		ticket := winningTicket(seed, i, prizePool)
+		idx := ownerIndex(tickets, ticket)
+
+		if _, ok := won[tickets[idx].PublicKey]; ok {
+			switch collision {
+			case CollisionReroll:
+				ticket, idx = reroll(seed, tier, tickets, won, ticket, idx)
+			case CollisionCascade:
+				ticket, idx = cascade(tickets, won, ticket, idx)
+			}
+		}
+
+		winner := Winner{
+			PublicKey: tickets[idx].PublicKey,
+			Ticket:    ticket,
+			Prize:     prize,
+		}
+
+		winners = append(winners, winner)
+		won[winner.PublicKey] = struct{}{}
+		i -= 2
+	}
+
+	for _, hook := range hooks {
+		extra, err := hook(seed, tickets, winners)
+		if err != nil {
+			return nil, err
+		}
+		winners = append(winners, extra...)
+	}
+
+	return winners, nil
+}
+
+// Hook is executed after the winners of a draw are selected, with the same seed and tickets. It
+// returns additional winners, whose prizes must be funded by the fee.
+type Hook func(seed []byte, tickets []Tickets, winners []Winner) ([]Winner, error)
+
+// LastTicketBonus returns a hook that draws a bonus prize among the last tickets sold, funded by
+// the fee, to encourage filling the pool before the draw. The prize is the percentage of the prize
+// pool specified, limited to the fee left by the other winners.
+//
+// The ticket is selected with the hash of the seed, so the bytes used by the main draw are not
+// reused.
+func LastTicketBonus(lastTickets uint64, percentage float64) Hook {
+	return func(seed []byte, tickets []Tickets, winners []Winner) ([]Winner, error) {
+		if lastTickets == 0 || len(tickets) == 0 {
+			return nil, nil
+		}
+
+		prizePool := tickets[len(tickets)-1].Index
+		distributed := uint64(0)
+		for _, winner := range winners {
+			distributed += winner.Prize
+		}
+		if distributed >= prizePool {
+			return nil, nil
+		}
+
+		prize := min(uint64(math.Floor(percentage/100*float64(prizePool))), prizePool-distributed)
+		if prize == 0 {
+			return nil, nil
+		}
+
+		hash := sha256.Sum256(append(slices.Clip(seed), lastTicketTag...))
+		eligible := min(lastTickets, prizePool)
+		ticket := prizePool - eligible + 1 + binary.BigEndian.Uint64(hash[:8])%eligible
+
+		winner := Winner{
+			PublicKey: owner(tickets, ticket),
+			Ticket:    ticket,
+			Prize:     prize,
+		}
+		return []Winner{winner}, nil
+	}
+}
+
+// winningTicket takes two bytes from the seed to get the winning number.
+func winningTicket(seed []byte, i int, prizePool uint64) uint64 {
+	num1 := int64(seed[i])
+	num2 := int64(seed[i-1])
+
+	// (num1 ^ num2) % prizePool
+	result := new(big.Int).Exp(
+		big.NewInt(num1),
+		big.NewInt(num2),
+		new(big.Int).SetUint64(prizePool),
+	)
+
+	// Add one so the index zero is not taken into account and the last one is
+	return result.Uint64() + 1
+}
+
+// reroll draws the tier again with the hash of the seed, the tier and the attempt number until
+// the ticket belongs to a public key that didn't win yet. The original ticket is kept if none of
+// the attempts succeeds.
+func reroll(
+	seed []byte,
+	tier int,
+	tickets []Tickets,
+	won map[string]struct{},
+	ticket uint64,
+	idx int,
+) (uint64, int) {
+	prizePool := tickets[len(tickets)-1].Index
+	for attempt := 0; attempt < maxRerolls; attempt++ {
+		data := append(slices.Clip(seed), collisionTag...)
+		hash := sha256.Sum256(append(data, byte(tier), byte(attempt)))
+
+		rerolled := binary.BigEndian.Uint64(hash[:8])%prizePool + 1
+		rerolledIdx := ownerIndex(tickets, rerolled)
+		if _, ok := won[tickets[rerolledIdx].PublicKey]; !ok {
+			return rerolled, rerolledIdx
+		}
+	}
+
+	return ticket, idx
+}
+
+// cascade moves the prize to the first ticket of the next range, wrapping around, whose public key
+// didn't win yet. The original ticket is kept if every holder already won.
+func cascade(tickets []Tickets, won map[string]struct{}, ticket uint64, idx int) (uint64, int) {
+	for offset := 1; offset < len(tickets); offset++ {
+		next := (idx + offset) % len(tickets)
+		if _, ok := won[tickets[next].PublicKey]; ok {
+			continue
+		}
+
+		first := uint64(1)
+		if next > 0 {
+			first = tickets[next-1].Index + 1
+		}
+		return first, next
+	}
+
+	return ticket, idx
+}
+
+// owner looks for the range containing the ticket and returns its public key.
+//
+// The tickets must be sorted and the ticket must not be higher than the last index.
+func owner(tickets []Tickets, ticket uint64) string {
+	return tickets[ownerIndex(tickets, ticket)].PublicKey
+}
+
+// ownerIndex looks for the range containing the ticket using the binary search algorithm and
+// returns its position.
+//
+// The tickets must be sorted and the ticket must not be higher than the last index.
+func ownerIndex(tickets []Tickets, ticket uint64) int {
+	left, mid, right := 0, 0, len(tickets)-1
+	for left <= right {
+		mid = (left + right) / 2
+
+		i := tickets[mid].Index
+		if i == ticket {
+			return mid
+		}
+		if i < ticket {
+			left = mid + 1
+			continue
+		}
+
+		right = mid - 1
+	}
+
+	// The left ends up being the higher value of the two, hence that user has the winning ticket
+	return left
+}
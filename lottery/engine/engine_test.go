@@ -0,0 +1,618 @@
+package engine
+
+import (
+	"encoding/hex"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var tickets = []Tickets{
+	{
+		Index:     427_224,
+		PublicKey: "1",
+	},
+	{
+		Index:     1_427_224,
+		PublicKey: "2",
+	},
+	{
+		Index:     1_527_224,
+		PublicKey: "3",
+	},
+}
+
+func TestDraw(t *testing.T) {
+	prizePool := tickets[len(tickets)-1].Index
+	seed, err := hex.DecodeString("000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6")
+	assert.NoError(t, err)
+
+	winners, err := Draw(seed, tickets, DefaultDistribution, RoundingNearest, CollisionStack)
+	assert.NoError(t, err)
+
+	assert.Len(t, winners, len(DefaultDistribution))
+
+	for i, winner := range winners {
+		validateOwner(t, winner.Ticket, winner.PublicKey)
+
+		prize := (DefaultDistribution[i] / 100) * float64(prizePool)
+		assert.Equal(t, uint64(math.Round(prize)), winner.Prize)
+	}
+}
+
+func TestDrawWithoutTickets(t *testing.T) {
+	seed, err := hex.DecodeString("000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6")
+	assert.NoError(t, err)
+	winners, err := Draw(seed, []Tickets{}, DefaultDistribution, RoundingNearest, CollisionStack)
+	assert.NoError(t, err)
+
+	assert.Nil(t, winners)
+}
+
+func TestDrawShortSeed(t *testing.T) {
+	_, err := Draw([]byte{1, 2, 3}, tickets, DefaultDistribution, RoundingNearest, CollisionStack)
+	assert.Error(t, err)
+}
+
+func TestDrawProperties(t *testing.T) {
+	property := func(seed [32]byte, amounts []uint16) bool {
+		tickets := make([]Tickets, 0, len(amounts))
+		owners := make(map[string]struct{}, len(amounts))
+		index := uint64(0)
+		for i, amount := range amounts {
+			index += uint64(amount) + 1
+			publicKey := string(rune('a' + i%26))
+			tickets = append(tickets, Tickets{PublicKey: publicKey, Index: index})
+			owners[publicKey] = struct{}{}
+		}
+
+		winners, err := Draw(seed[:], tickets, DefaultDistribution, RoundingNearest, CollisionStack)
+		if err != nil {
+			return false
+		}
+
+		if len(tickets) == 0 {
+			return winners == nil
+		}
+
+		// Same inputs must always produce the same outputs
+		again, err := Draw(seed[:], tickets, DefaultDistribution, RoundingNearest, CollisionStack)
+		if err != nil || !reflect.DeepEqual(winners, again) {
+			return false
+		}
+
+		prizes := uint64(0)
+		for _, winner := range winners {
+			if winner.Ticket == 0 || winner.Ticket > index {
+				return false
+			}
+			if _, ok := owners[winner.PublicKey]; !ok {
+				return false
+			}
+			prizes += winner.Prize
+		}
+
+		// Rounding can't make the prizes exceed the prize pool
+		return prizes <= index
+	}
+
+	config := &quick.Config{
+		MaxCount: 2000,
+		Rand:     rand.New(rand.NewSource(1)),
+	}
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDrawLastTicketBonus(t *testing.T) {
+	prizePool := tickets[len(tickets)-1].Index
+	seed, err := hex.DecodeString("000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6")
+	assert.NoError(t, err)
+
+	winners, err := Draw(seed, tickets, DefaultDistribution, RoundingNearest, CollisionStack,
+		LastTicketBonus(100_000, 0.1))
+	assert.NoError(t, err)
+
+	assert.Len(t, winners, len(DefaultDistribution)+1)
+
+	bonus := winners[len(winners)-1]
+	assert.Equal(t, "3", bonus.PublicKey)
+	assert.Greater(t, bonus.Ticket, prizePool-100_000)
+	assert.LessOrEqual(t, bonus.Ticket, prizePool)
+	assert.Equal(t, uint64(1527), bonus.Prize)
+
+	// The main draw is not affected
+	main, err := Draw(seed, tickets, DefaultDistribution, RoundingNearest, CollisionStack)
+	assert.NoError(t, err)
+	assert.Equal(t, main, winners[:len(DefaultDistribution)])
+}
+
+func TestDrawCollision(t *testing.T) {
+	seed, err := hex.DecodeString("000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6")
+	assert.NoError(t, err)
+
+	stacked, err := Draw(seed, tickets, DefaultDistribution, RoundingNearest, CollisionStack)
+	assert.NoError(t, err)
+
+	cases := []Collision{CollisionReroll, CollisionCascade}
+	for _, collision := range cases {
+		t.Run(string(collision), func(t *testing.T) {
+			winners, err := Draw(seed, tickets, DefaultDistribution, RoundingNearest, collision)
+			assert.NoError(t, err)
+			assert.Len(t, winners, len(DefaultDistribution))
+
+			// The first tier can't collide
+			assert.Equal(t, stacked[0], winners[0])
+
+			publicKeys := make(map[string]struct{}, len(tickets))
+			for i, winner := range winners {
+				validateOwner(t, winner.Ticket, winner.PublicKey)
+				assert.Equal(t, stacked[i].Prize, winner.Prize)
+
+				// Prizes stack only once every holder won
+				if i < len(tickets) {
+					assert.NotContains(t, publicKeys, winner.PublicKey)
+				}
+				publicKeys[winner.PublicKey] = struct{}{}
+			}
+
+			again, err := Draw(seed, tickets, DefaultDistribution, RoundingNearest, collision)
+			assert.NoError(t, err)
+			assert.Equal(t, winners, again)
+		})
+	}
+}
+
+func TestDrawCollisionCascade(t *testing.T) {
+	tickets := []Tickets{
+		{PublicKey: "1", Index: 10},
+		{PublicKey: "2", Index: 20},
+		{PublicKey: "1", Index: 30},
+	}
+	// Both tiers land on the ticket 28, owned by "1"
+	seed := []byte{3, 3, 3, 3}
+
+	winners, err := Draw(seed, tickets, Distribution{50, 25}, RoundingNearest, CollisionCascade)
+	assert.NoError(t, err)
+
+	expected := []Winner{
+		{PublicKey: "1", Ticket: 28, Prize: 15},
+		{PublicKey: "2", Ticket: 11, Prize: 8},
+	}
+	assert.Equal(t, expected, winners)
+}
+
+func TestDrawInvalidCollision(t *testing.T) {
+	_, err := Draw(make([]byte, 32), tickets, DefaultDistribution, RoundingNearest, Collision("skip"))
+	assert.Error(t, err)
+}
+
+func TestLastTicketBonus(t *testing.T) {
+	seed := make([]byte, 32)
+
+	cases := []struct {
+		desc          string
+		winners       []Winner
+		lastTickets   uint64
+		percentage    float64
+		expectedPrize uint64
+	}{
+		{
+			desc:          "Prize",
+			winners:       []Winner{{Prize: 500}},
+			lastTickets:   10,
+			percentage:    10,
+			expectedPrize: 100,
+		},
+		{
+			desc:          "Limited to the fee",
+			winners:       []Winner{{Prize: 950}},
+			lastTickets:   10,
+			percentage:    10,
+			expectedPrize: 50,
+		},
+		{
+			desc:        "No fee left",
+			winners:     []Winner{{Prize: 1000}},
+			lastTickets: 10,
+			percentage:  10,
+		},
+		{
+			desc:        "Prize lower than a sat",
+			lastTickets: 10,
+			percentage:  0.01,
+		},
+		{
+			desc:       "Disabled",
+			percentage: 10,
+		},
+		{
+			desc:          "More tickets than the prize pool",
+			lastTickets:   5000,
+			percentage:    1,
+			expectedPrize: 10,
+		},
+	}
+
+	tickets := []Tickets{{PublicKey: "1", Index: 990}, {PublicKey: "2", Index: 1000}}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			hook := LastTicketBonus(tc.lastTickets, tc.percentage)
+			winners, err := hook(seed, tickets, tc.winners)
+			assert.NoError(t, err)
+
+			if tc.expectedPrize == 0 {
+				assert.Empty(t, winners)
+				return
+			}
+
+			assert.Len(t, winners, 1)
+			assert.Equal(t, tc.expectedPrize, winners[0].Prize)
+			assert.Greater(t, winners[0].Ticket, 1000-min(tc.lastTickets, 1000))
+			assert.LessOrEqual(t, winners[0].Ticket, uint64(1000))
+		})
+	}
+}
+
+func TestWinningTickets(t *testing.T) {
+	prizePool := uint64(1000)
+	results := []uint64{417, 777, 865, 833, 977, 402, 322, 337}
+	seed, err := hex.DecodeString("000000000000000000001badcbb5d10b486a18a97ac9d6e08d526a62aa9a360e")
+	assert.NoError(t, err)
+	i := len(seed) - 1
+
+	for _, expected := range results {
+		target := winningTicket(seed, i, prizePool)
+		assert.Equal(t, expected, target)
+		i -= 2
+	}
+}
+
+func TestOwner(t *testing.T) {
+	cases := []struct {
+		desc              string
+		expectedPublicKey string
+		tickets           []Tickets
+		winningTicket     uint64
+	}{
+		{
+			desc:              "First",
+			tickets:           tickets,
+			winningTicket:     15,
+			expectedPublicKey: tickets[0].PublicKey,
+		},
+		{
+			desc:              "Second",
+			tickets:           tickets,
+			winningTicket:     1_000_000,
+			expectedPublicKey: tickets[1].PublicKey,
+		},
+		{
+			desc:              "Third",
+			tickets:           tickets,
+			winningTicket:     1_527_224,
+			expectedPublicKey: tickets[2].PublicKey,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			gotPublicKey := owner(tc.tickets, tc.winningTicket)
+
+			assert.Equal(t, tc.expectedPublicKey, gotPublicKey)
+		})
+	}
+}
+
+func TestDistributionFee(t *testing.T) {
+	// Just in case :)
+	assert.Equal(t, eighth, DefaultDistribution.Fee())
+
+	total := DefaultDistribution.Fee()
+	for _, percentage := range DefaultDistribution {
+		total += percentage
+	}
+	assert.Equal(t, 100, int(total))
+}
+
+func TestDistributionValidate(t *testing.T) {
+	cases := []struct {
+		desc         string
+		distribution Distribution
+		fail         bool
+	}{
+		{desc: "Default", distribution: DefaultDistribution},
+		{desc: "Empty", distribution: Distribution{}},
+		{desc: "Too many winners", distribution: make(Distribution, 17), fail: true},
+		{desc: "Zero percentage", distribution: Distribution{50, 0}, fail: true},
+		{desc: "Over 100", distribution: Distribution{80, 30}, fail: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := tc.distribution.Validate()
+			if tc.fail {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestDistributionAdapt(t *testing.T) {
+	adapted := DefaultDistribution.Adapt(3)
+	assert.Len(t, adapted, 3)
+	assert.InDelta(t, DefaultDistribution.Fee(), adapted.Fee(), 1e-9)
+	assert.InDelta(t, 2, adapted[0]/adapted[1], 1e-9)
+	assert.InDelta(t, 2, adapted[1]/adapted[2], 1e-9)
+	assert.NoError(t, adapted.Validate())
+
+	assert.Equal(t, DefaultDistribution, DefaultDistribution.Adapt(0))
+	assert.Equal(t, DefaultDistribution, DefaultDistribution.Adapt(8))
+	assert.Equal(t, DefaultDistribution, DefaultDistribution.Adapt(12))
+}
+
+func TestTierSchedule(t *testing.T) {
+	schedule := TierSchedule{
+		{Participants: 0, Tiers: 3},
+		{Participants: 10, Tiers: 5},
+		{Participants: 100, Tiers: 8},
+	}
+	assert.NoError(t, schedule.Validate())
+
+	cases := map[uint64]int{0: 3, 1: 3, 9: 3, 10: 5, 99: 5, 100: 8, 1_000: 8}
+	for participants, expected := range cases {
+		assert.Equal(t, expected, schedule.Tiers(participants), participants)
+	}
+
+	assert.Equal(t, 0, TierSchedule{}.Tiers(50))
+	// Lotteries below the first step use its tiers
+	assert.Equal(t, 2, TierSchedule{{Participants: 5, Tiers: 2}}.Tiers(1))
+}
+
+func TestTierScheduleValidate(t *testing.T) {
+	cases := []struct {
+		desc     string
+		schedule TierSchedule
+		fail     bool
+	}{
+		{desc: "Empty", schedule: TierSchedule{}},
+		{desc: "Zero tiers", schedule: TierSchedule{{Participants: 0, Tiers: 0}}, fail: true},
+		{desc: "Too many tiers", schedule: TierSchedule{{Participants: 0, Tiers: 17}}, fail: true},
+		{
+			desc:     "Unsorted",
+			schedule: TierSchedule{{Participants: 10, Tiers: 5}, {Participants: 5, Tiers: 3}},
+			fail:     true,
+		},
+		{
+			desc:     "Duplicated",
+			schedule: TierSchedule{{Participants: 10, Tiers: 5}, {Participants: 10, Tiers: 3}},
+			fail:     true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := tc.schedule.Validate()
+			if tc.fail {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestParticipants(t *testing.T) {
+	tickets := []Tickets{
+		{PublicKey: "a", Index: 10},
+		{PublicKey: "b", Index: 20},
+		{PublicKey: "a", Index: 30},
+	}
+	assert.Equal(t, uint64(2), Participants(tickets))
+	assert.Equal(t, uint64(0), Participants(nil))
+}
+
+func TestPrizes(t *testing.T) {
+	cases := []struct {
+		desc         string
+		rounding     Rounding
+		distribution Distribution
+		expected     []uint64
+		prizePool    uint64
+	}{
+		{
+			desc:         "Nearest",
+			rounding:     RoundingNearest,
+			prizePool:    1_000_003,
+			distribution: DefaultDistribution,
+			expected:     []uint64{500_002, 250_001, 125_000, 62_500, 31_250, 15_625, 7_813, 3_906},
+		},
+		{
+			desc:         "Empty policy",
+			prizePool:    1_000_003,
+			distribution: DefaultDistribution,
+			expected:     []uint64{500_002, 250_001, 125_000, 62_500, 31_250, 15_625, 7_813, 3_906},
+		},
+		{
+			desc:         "First prize",
+			rounding:     RoundingFirstPrize,
+			prizePool:    1_000_003,
+			distribution: DefaultDistribution,
+			expected:     []uint64{500_003, 250_000, 125_000, 62_500, 31_250, 15_625, 7_812, 3_906},
+		},
+		{
+			desc:         "Fee",
+			rounding:     RoundingFee,
+			prizePool:    1_000_003,
+			distribution: DefaultDistribution,
+			expected:     []uint64{500_001, 250_000, 125_000, 62_500, 31_250, 15_625, 7_812, 3_906},
+		},
+		{
+			desc:         "Nearest without fee",
+			rounding:     RoundingNearest,
+			prizePool:    1,
+			distribution: Distribution{50, 50},
+			expected:     []uint64{1, 0},
+		},
+		{
+			desc:         "Nearest three sats without fee",
+			rounding:     RoundingNearest,
+			prizePool:    3,
+			distribution: Distribution{50, 25, 25},
+			expected:     []uint64{2, 1, 0},
+		},
+		{
+			desc:         "First prize one sat",
+			rounding:     RoundingFirstPrize,
+			prizePool:    1,
+			distribution: DefaultDistribution,
+			expected:     []uint64{0, 0, 0, 0, 0, 0, 0, 0},
+		},
+		{
+			desc:         "First prize without fee",
+			rounding:     RoundingFirstPrize,
+			prizePool:    7,
+			distribution: Distribution{50, 25, 25},
+			expected:     []uint64{5, 1, 1},
+		},
+		{
+			desc:         "Fee without fee",
+			rounding:     RoundingFee,
+			prizePool:    7,
+			distribution: Distribution{50, 25, 25},
+			expected:     []uint64{3, 1, 1},
+		},
+		{
+			desc:         "Empty distribution",
+			rounding:     RoundingFirstPrize,
+			prizePool:    7,
+			distribution: Distribution{},
+			expected:     []uint64{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			prizes, err := Prizes(tc.prizePool, tc.distribution, tc.rounding)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expected, prizes)
+		})
+	}
+}
+
+func TestPrizesInvalidRounding(t *testing.T) {
+	_, err := Prizes(100, DefaultDistribution, "ceil")
+	assert.Error(t, err)
+}
+
+func TestPrizesProperties(t *testing.T) {
+	property := func(prizePool uint32, percentages [4]uint8, policy uint8) bool {
+		distribution := make(Distribution, 0, len(percentages))
+		for _, percentage := range percentages {
+			// Up to 25% each, so they never add up to more than 100
+			distribution = append(distribution, float64(percentage%25)+0.5)
+		}
+		roundings := []Rounding{RoundingNearest, RoundingFirstPrize, RoundingFee}
+		rounding := roundings[int(policy)%len(roundings)]
+
+		prizes, err := Prizes(uint64(prizePool), distribution, rounding)
+		if err != nil {
+			return false
+		}
+
+		total := uint64(0)
+		for _, prize := range prizes {
+			total += prize
+		}
+		distributed := (100 - distribution.Fee()) / 100 * float64(prizePool)
+
+		switch rounding {
+		case RoundingFirstPrize:
+			// Only the fractions of the fee are not distributed
+			return total <= uint64(prizePool) && float64(total) > distributed-1
+		case RoundingFee:
+			return float64(total) <= distributed+1e-6
+		default:
+			return total <= uint64(prizePool)
+		}
+	}
+
+	config := &quick.Config{
+		MaxCount: 2000,
+		Rand:     rand.New(rand.NewSource(1)),
+	}
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRoundingValidate(t *testing.T) {
+	assert.NoError(t, Rounding("").Validate())
+	assert.NoError(t, RoundingNearest.Validate())
+	assert.NoError(t, RoundingFirstPrize.Validate())
+	assert.NoError(t, RoundingFee.Validate())
+	assert.Error(t, Rounding("ceil").Validate())
+}
+
+func TestCollisionValidate(t *testing.T) {
+	assert.NoError(t, Collision("").Validate())
+	assert.NoError(t, CollisionStack.Validate())
+	assert.NoError(t, CollisionReroll.Validate())
+	assert.NoError(t, CollisionCascade.Validate())
+	assert.Error(t, Collision("skip").Validate())
+}
+
+func validateOwner(t *testing.T, target uint64, expectedPubKey string) {
+	t.Helper()
+
+	for _, tickets := range tickets {
+		if target <= tickets.Index {
+			assert.Equal(t, expectedPubKey, tickets.PublicKey)
+			return
+		}
+	}
+
+	assert.Failf(t, "Ticket out of range", "ticket: %d", target)
+}
+
+func TestCommitment(t *testing.T) {
+	serverSeed := []byte("seed")
+	commitment := Commitment(serverSeed)
+
+	assert.Equal(t, "19b25856e1c150ca834cffc8b59b23adbd0ec0389e58eb22b3b64768098d002b",
+		hex.EncodeToString(commitment))
+}
+
+func TestDrawSeed(t *testing.T) {
+	blockHash, err := hex.DecodeString("000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6")
+	assert.NoError(t, err)
+
+	assert.Equal(t, blockHash, DrawSeed(nil, blockHash))
+
+	seed := DrawSeed([]byte("seed"), blockHash)
+	assert.Len(t, seed, 32)
+	assert.NotEqual(t, blockHash, seed)
+	assert.NotEqual(t, seed, DrawSeed([]byte("other"), blockHash))
+	assert.Equal(t, seed, DrawSeed([]byte("seed"), blockHash))
+}
+
+func TestPoolSeed(t *testing.T) {
+	seed, err := hex.DecodeString("000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6")
+	assert.NoError(t, err)
+
+	assert.Equal(t, seed, PoolSeed(seed, ""))
+
+	whale := PoolSeed(seed, "whale")
+	assert.Len(t, whale, 32)
+	assert.NotEqual(t, seed, whale)
+	assert.NotEqual(t, whale, PoolSeed(seed, "micro"))
+	assert.Equal(t, whale, PoolSeed(seed, "whale"))
+}
@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Pool is the set of tickets of a lottery pool and the prize table it's drawn with.
+type Pool struct {
+	Name         string       `json:"name"`
+	Distribution Distribution `json:"distribution"`
+	Tickets      []Tickets    `json:"tickets"`
+}
+
+// Round contains every input of the draw of a lottery, so it can be reproduced outside of the
+// server. The randomness comes from the server seed and the block hash unless Seed is set, which
+// lets tests inject the draw seed directly.
+type Round struct {
+	Seed       []byte
+	ServerSeed []byte
+	BlockHash  []byte
+	Rounding   Rounding
+	Collision  Collision
+	Pools      []Pool
+	Hooks      []Hook
+}
+
+// Draw selects the winners of each pool the way the server does, returning them by pool name.
+// Pools without tickets have no winners.
+func (r Round) Draw() (map[string][]Winner, error) {
+	seed := r.Seed
+	if len(seed) == 0 {
+		seed = DrawSeed(r.ServerSeed, r.BlockHash)
+	}
+	if len(seed) == 0 {
+		return nil, errors.New("either the seed or the block hash is required")
+	}
+
+	winners := make(map[string][]Winner, len(r.Pools))
+	for _, pool := range r.Pools {
+		if err := pool.Distribution.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid distribution of pool %q", pool.Name)
+		}
+
+		poolWinners, err := Draw(PoolSeed(seed, pool.Name), pool.Tickets, pool.Distribution,
+			r.Rounding, r.Collision, r.Hooks...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "drawing pool %q", pool.Name)
+		}
+		winners[pool.Name] = poolWinners
+	}
+
+	return winners, nil
+}
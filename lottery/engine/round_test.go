@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "overwrite the winners of the golden draws with the current ones")
+
+// goldenDraw is a draw whose winners are known to be correct. The winners are only overwritten
+// with -update, after confirming a change in the results is intended.
+type goldenDraw struct {
+	Description string    `json:"description"`
+	ServerSeed  string    `json:"server_seed,omitempty"`
+	BlockHash   string    `json:"block_hash"`
+	Rounding    Rounding  `json:"rounding,omitempty"`
+	Collision   Collision `json:"collision,omitempty"`
+	LastTicket  *struct {
+		Tickets    uint64  `json:"tickets"`
+		Percentage float64 `json:"percentage"`
+	} `json:"last_ticket,omitempty"`
+	Pools   []Pool              `json:"pools"`
+	Winners map[string][]Winner `json:"winners"`
+}
+
+func (g goldenDraw) round(t *testing.T) Round {
+	serverSeed, err := hex.DecodeString(g.ServerSeed)
+	require.NoError(t, err)
+	blockHash, err := hex.DecodeString(g.BlockHash)
+	require.NoError(t, err)
+
+	round := Round{
+		ServerSeed: serverSeed,
+		BlockHash:  blockHash,
+		Rounding:   g.Rounding,
+		Collision:  g.Collision,
+		Pools:      g.Pools,
+	}
+	if g.LastTicket != nil {
+		round.Hooks = append(round.Hooks, LastTicketBonus(g.LastTicket.Tickets, g.LastTicket.Percentage))
+	}
+
+	return round
+}
+
+func TestGoldenDraws(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "draws", "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+
+			var golden goldenDraw
+			require.NoError(t, json.Unmarshal(data, &golden))
+
+			winners, err := golden.round(t).Draw()
+			require.NoError(t, err)
+
+			if *update {
+				golden.Winners = winners
+				data, err := json.MarshalIndent(golden, "", "  ")
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(path, append(data, '\n'), 0o644))
+				return
+			}
+
+			assert.Equal(t, golden.Winners, winners)
+		})
+	}
+}
+
+func TestRoundDraw(t *testing.T) {
+	seed, err := hex.DecodeString("000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6")
+	require.NoError(t, err)
+
+	round := Round{
+		Seed: seed,
+		Pools: []Pool{
+			{Distribution: DefaultDistribution, Tickets: tickets},
+			{Name: "whale", Distribution: DefaultDistribution, Tickets: tickets},
+			{Name: "empty", Distribution: DefaultDistribution},
+		},
+	}
+	winners, err := round.Draw()
+	require.NoError(t, err)
+
+	// The injected seed is used as the draw seed and each pool is drawn like the server does
+	expected, err := Draw(seed, tickets, DefaultDistribution, RoundingNearest, CollisionStack)
+	require.NoError(t, err)
+	assert.Equal(t, expected, winners[""])
+
+	expected, err = Draw(PoolSeed(seed, "whale"), tickets, DefaultDistribution, RoundingNearest,
+		CollisionStack)
+	require.NoError(t, err)
+	assert.Equal(t, expected, winners["whale"])
+	assert.Nil(t, winners["empty"])
+
+	// Without an injected seed it's derived from the server seed and the block hash
+	serverSeed := []byte("server seed")
+	round = Round{ServerSeed: serverSeed, BlockHash: seed, Pools: round.Pools[:1]}
+	winners, err = round.Draw()
+	require.NoError(t, err)
+
+	expected, err = Draw(DrawSeed(serverSeed, seed), tickets, DefaultDistribution, RoundingNearest,
+		CollisionStack)
+	require.NoError(t, err)
+	assert.Equal(t, expected, winners[""])
+}
+
+func TestRoundDrawErrors(t *testing.T) {
+	_, err := Round{Pools: []Pool{{Distribution: DefaultDistribution, Tickets: tickets}}}.Draw()
+	assert.Error(t, err)
+
+	_, err = Round{Seed: []byte{1, 2, 3}, Pools: []Pool{{Distribution: Distribution{101}}}}.Draw()
+	assert.Error(t, err)
+}
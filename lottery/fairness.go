@@ -0,0 +1,68 @@
+package lottery
+
+import (
+	"slices"
+
+	"github.com/aftermath2/BTRY/db"
+)
+
+// newFairnessReport returns the number of players of the lottery, how concentrated their tickets
+// were and the position of each winning ticket in its pool.
+func newFairnessReport(lotteryHeight uint32, bets []db.Bet, winners []db.Winner) db.FairnessReport {
+	report := db.FairnessReport{
+		LotteryHeight:    lotteryHeight,
+		WinningPositions: make([]db.WinningPosition, 0, len(winners)),
+	}
+
+	playerTickets := make(map[string]uint64)
+	prizePools := make(map[string]uint64)
+	for _, bet := range bets {
+		playerTickets[bet.PublicKey] += bet.Tickets
+		prizePools[bet.Pool] = max(prizePools[bet.Pool], bet.Index)
+		report.Tickets += bet.Tickets
+	}
+
+	tickets := make([]uint64, 0, len(playerTickets))
+	for _, t := range playerTickets {
+		tickets = append(tickets, t)
+	}
+	report.Players = uint64(len(tickets))
+	report.Gini = gini(tickets)
+
+	for _, winner := range winners {
+		prizePool := prizePools[winner.Pool]
+		if prizePool == 0 {
+			continue
+		}
+
+		report.WinningPositions = append(report.WinningPositions, db.WinningPosition{
+			Pool:      winner.Pool,
+			Ticket:    winner.Ticket,
+			PrizePool: prizePool,
+			Position:  float64(winner.Ticket) / float64(prizePool),
+		})
+	}
+
+	return report
+}
+
+// gini returns the Gini coefficient of the values, zero if there are none.
+func gini(values []uint64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	slices.Sort(values)
+
+	var sum, weightedSum float64
+	for i, v := range values {
+		sum += float64(v)
+		weightedSum += float64(i+1) * float64(v)
+	}
+	if sum == 0 {
+		return 0
+	}
+
+	n := float64(len(values))
+	return 2*weightedSum/(n*sum) - (n+1)/n
+}
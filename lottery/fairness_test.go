@@ -0,0 +1,55 @@
+package lottery
+
+import (
+	"testing"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFairnessReport(t *testing.T) {
+	bets := []db.Bet{
+		{PublicKey: "a", FirstTicket: 1, Index: 100, Tickets: 100},
+		{PublicKey: "b", FirstTicket: 101, Index: 400, Tickets: 300},
+		{PublicKey: "a", FirstTicket: 401, Index: 500, Tickets: 100},
+		{PublicKey: "c", Pool: "whale", FirstTicket: 1, Index: 1_000, Tickets: 1_000},
+	}
+	winners := []db.Winner{
+		{PublicKey: "b", Ticket: 250, Prize: 200},
+		{PublicKey: "c", Ticket: 900, Prize: 500, Pool: "whale"},
+	}
+
+	report := newFairnessReport(144, bets, winners)
+
+	assert.Equal(t, uint32(144), report.LotteryHeight)
+	assert.Equal(t, uint64(3), report.Players)
+	assert.Equal(t, uint64(1_500), report.Tickets)
+	// Players hold 200, 300 and 1000 tickets
+	assert.InDelta(t, 0.3555, report.Gini, 0.0001)
+	expected := []db.WinningPosition{
+		{Ticket: 250, PrizePool: 500, Position: 0.5},
+		{Pool: "whale", Ticket: 900, PrizePool: 1_000, Position: 0.9},
+	}
+	assert.Equal(t, expected, report.WinningPositions)
+}
+
+func TestGini(t *testing.T) {
+	cases := []struct {
+		desc     string
+		values   []uint64
+		expected float64
+	}{
+		{desc: "No players", values: nil, expected: 0},
+		{desc: "Single player", values: []uint64{500}, expected: 0},
+		{desc: "Equal", values: []uint64{10, 10, 10, 10}, expected: 0},
+		{desc: "Concentrated", values: []uint64{0, 0, 0, 100}, expected: 0.75},
+		{desc: "Unsorted", values: []uint64{3, 1, 2}, expected: 2.0 / 9},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.InDelta(t, tc.expected, gini(tc.values), 1e-9)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package lottery
+
+import (
+	"context"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// SplitFee returns the share of the lottery fee each destination receives. Shares are rounded
+// down, the sats left stay in the node along with the share no destination takes.
+func SplitFee(lotteryHeight uint32, fee uint64, destinations []config.FeeDestination) []db.FeeDistribution {
+	var distributions []db.FeeDistribution
+	for _, destination := range destinations {
+		amount := uint64(float64(fee) * destination.Percentage / 100)
+		if amount == 0 {
+			continue
+		}
+
+		distributions = append(distributions, db.FeeDistribution{
+			LotteryHeight: lotteryHeight,
+			Destination:   destination.Name,
+			Address:       destination.Address,
+			Amount:        amount,
+		})
+	}
+
+	return distributions
+}
+
+// lotteryFee returns the sats paid for the tickets of the lottery that were not assigned to the
+// winners. Bonus tickets are left out, as their share of the prizes is funded by the fee of the
+// bundles or by the fee balance in the case of airdrops.
+func lotteryFee(bets []db.Bet, winners []db.Winner) uint64 {
+	paid := uint64(0)
+	for _, bet := range bets {
+		paid += bet.Tickets - bet.Bonus
+	}
+
+	prizes := uint64(0)
+	for _, winner := range winners {
+		prizes += winner.Prize
+	}
+
+	if prizes >= paid {
+		return 0
+	}
+	return paid - prizes
+}
+
+// distributeFee records the shares of the lottery fee and forwards the ones not paid yet. Shares
+// are recorded once per lottery, so the job can be retried until every payment succeeds.
+func (l *Lottery) distributeFee(ctx context.Context, job feeDistributionJob) error {
+	if err := l.db.Fees.Add(SplitFee(job.Height, job.Fee, l.feeDestinations)); err != nil {
+		return err
+	}
+
+	unpaid, err := l.db.Fees.ListUnpaid(job.Height)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, distribution := range unpaid {
+		preimage, err := l.lnd.SendToLightningAddress(ctx, distribution.Address, int64(distribution.Amount))
+		if err != nil {
+			l.logger.Error(errors.Wrapf(err, "forwarding fee share to %s", distribution.Destination))
+			failed++
+			continue
+		}
+
+		if err := l.db.Fees.SetPaid(distribution.ID, preimage, l.now().Unix()); err != nil {
+			return err
+		}
+
+		l.auditor.Record(audit.FeeForwarded, map[string]any{
+			"lottery_height": distribution.LotteryHeight,
+			"destination":    distribution.Destination,
+			"address":        distribution.Address,
+			"amount":         distribution.Amount,
+			"preimage":       preimage,
+		})
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d fee shares of lottery %d could not be forwarded", failed, job.Height)
+	}
+
+	return nil
+}
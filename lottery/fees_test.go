@@ -0,0 +1,82 @@
+package lottery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var feeDestinations = []config.FeeDestination{
+	{Name: "charity", Address: "charity@example.com", Percentage: 30},
+	{Name: "dev", Address: "dev@example.com", Percentage: 20},
+}
+
+func TestSplitFee(t *testing.T) {
+	distributions := SplitFee(144, 1_001, feeDestinations)
+
+	expected := []db.FeeDistribution{
+		{LotteryHeight: 144, Destination: "charity", Address: "charity@example.com", Amount: 300},
+		{LotteryHeight: 144, Destination: "dev", Address: "dev@example.com", Amount: 200},
+	}
+	assert.Equal(t, expected, distributions)
+
+	// Shares rounded down to zero are skipped
+	assert.Empty(t, SplitFee(144, 3, feeDestinations))
+}
+
+func TestLotteryFee(t *testing.T) {
+	winners := []db.Winner{{Prize: 500}, {Prize: 250}}
+	bets := []db.Bet{{Tickets: 600}, {Tickets: 400}}
+
+	assert.Equal(t, uint64(250), lotteryFee(bets, winners))
+	assert.Equal(t, uint64(0), lotteryFee(bets[:1], winners))
+
+	// Bonus and airdropped tickets weren't paid
+	bets = append(bets, db.Bet{Tickets: 100, Bonus: 20}, db.Bet{Tickets: 50, Bonus: 50, Promo: "launch"})
+	assert.Equal(t, uint64(330), lotteryFee(bets, winners))
+}
+
+func TestDistributeFee(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	ctx := context.Background()
+	job := feeDistributionJob{Height: 144, Fee: 1_000}
+
+	feesMock := db.NewFeeDistributionsStoreMock()
+	feesMock.On("Add", SplitFee(144, 1_000, feeDestinations)).Return(nil)
+	feesMock.On("ListUnpaid", uint32(144)).Return([]db.FeeDistribution{
+		{ID: 1, LotteryHeight: 144, Destination: "charity", Address: "charity@example.com", Amount: 300},
+		{ID: 2, LotteryHeight: 144, Destination: "dev", Address: "dev@example.com", Amount: 200},
+	}, nil)
+	feesMock.On("SetPaid", uint64(1), "preimage", now.Unix()).Return(nil).Once()
+
+	lnd := lightning.NewClientMock()
+	lnd.On("SendToLightningAddress", ctx, "charity@example.com", int64(300)).Return("preimage", nil)
+	lnd.On("SendToLightningAddress", ctx, "dev@example.com", int64(200)).Return("", errors.New("test"))
+
+	auditorMock := audit.NewAuditorMock()
+	auditorMock.On("Record", audit.FeeForwarded, mock.MatchedBy(func(data map[string]any) bool {
+		return data["destination"] == "charity" && data["amount"] == uint64(300)
+	})).Once()
+
+	config := config.Lottery{FeeDestinations: feeDestinations}
+	lottery, err := New(config, &db.DB{Fees: feesMock}, lnd, nil, templates, auditorMock, nil, nil,
+		nil, nil, nil, nil)
+	assert.NoError(t, err)
+	lottery.now = func() time.Time { return now }
+
+	// The failed share makes the job be retried
+	err = lottery.distributeFee(ctx, job)
+	assert.Error(t, err)
+
+	feesMock.AssertExpectations(t)
+	auditorMock.AssertExpectations(t)
+}
@@ -0,0 +1,115 @@
+package lottery
+
+import (
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/pkg/errors"
+)
+
+// Growth is the projection of the prize pool of a lottery at its draw. The pool is assumed to keep
+// growing at the rate measured over the blocks sampled since the lottery opened.
+type Growth struct {
+	PrizePool          uint64  `json:"prize_pool"`
+	ProjectedPrizePool uint64  `json:"projected_prize_pool"`
+	Bets               uint64  `json:"bets"`
+	ProjectedBets      uint64  `json:"projected_bets"`
+	SatsPerBlock       float64 `json:"sats_per_block"`
+	BetsPerBlock       float64 `json:"bets_per_block"`
+	Height             uint32  `json:"height"`
+	// BlockHeight is the height of the last block sampled
+	BlockHeight uint32 `json:"block_height"`
+	// SampledBlocks is the number of blocks the rates were measured over
+	SampledBlocks uint32 `json:"sampled_blocks"`
+	BlocksLeft    uint32 `json:"blocks_left"`
+}
+
+// ProjectGrowth returns the projection of the lottery at the height specified from its samples,
+// sorted by block height.
+func ProjectGrowth(height uint32, samples []db.GrowthSample) Growth {
+	growth := Growth{Height: height}
+	if len(samples) == 0 {
+		return growth
+	}
+
+	first, last := samples[0], samples[len(samples)-1]
+	growth.PrizePool = last.PrizePool
+	growth.Bets = last.Bets
+	growth.ProjectedPrizePool = last.PrizePool
+	growth.ProjectedBets = last.Bets
+	growth.BlockHeight = last.BlockHeight
+	growth.SampledBlocks = last.BlockHeight - first.BlockHeight
+	if height > last.BlockHeight {
+		growth.BlocksLeft = height - last.BlockHeight
+	}
+
+	if growth.SampledBlocks == 0 {
+		return growth
+	}
+
+	// Cancelled bets may shrink the pool, it's never projected below its current size
+	blocks := float64(growth.SampledBlocks)
+	growth.SatsPerBlock = max(float64(last.PrizePool)-float64(first.PrizePool), 0) / blocks
+	growth.BetsPerBlock = max(float64(last.Bets)-float64(first.Bets), 0) / blocks
+	growth.ProjectedPrizePool += uint64(growth.SatsPerBlock * float64(growth.BlocksLeft))
+	growth.ProjectedBets += uint64(growth.BetsPerBlock * float64(growth.BlocksLeft))
+
+	return growth
+}
+
+// trackGrowth samples the prize pool of the lottery at the block height and announces the highest
+// milestone it crossed, the lower ones are only recorded. Errors are only logged as they must not
+// interrupt the draws.
+func (l *Lottery) trackGrowth(lotteryHeight, blockHeight uint32) {
+	if blockHeight >= lotteryHeight {
+		return
+	}
+
+	sample, err := l.db.Growth.Sample(lotteryHeight, blockHeight, l.now().Unix())
+	if err != nil {
+		l.logger.Error(errors.Wrap(err, "sampling prize pool"))
+		return
+	}
+
+	var reached uint64
+	for _, amount := range l.milestones.Amounts {
+		if sample.PrizePool < amount {
+			break
+		}
+
+		added, err := l.db.Growth.AddMilestone(lotteryHeight, amount, sample.CreatedAt)
+		if err != nil {
+			l.logger.Error(errors.Wrap(err, "adding milestone"))
+			return
+		}
+		if added {
+			reached = amount
+		}
+	}
+
+	if reached == 0 {
+		return
+	}
+
+	blocksLeft := lotteryHeight - blockHeight
+	l.logger.Infof("Lottery %d prize pool crossed %d sats", lotteryHeight, reached)
+
+	if l.milestones.Nostr {
+		l.enqueue(jobPublishMilestone, publishMilestoneJob{
+			Amount:     reached,
+			Height:     lotteryHeight,
+			BlocksLeft: blocksLeft,
+		})
+	}
+
+	if l.milestones.ChatID != 0 {
+		message, ok := l.render(notification.EventMilestone, notification.Data{
+			Prize:      reached,
+			Height:     lotteryHeight,
+			BlocksLeft: blocksLeft,
+		})
+		if ok {
+			l.notifier.Notify(l.milestones.ChatID, message)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package lottery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/jobs"
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjectGrowth(t *testing.T) {
+	cases := []struct {
+		desc     string
+		samples  []db.GrowthSample
+		expected Growth
+	}{
+		{
+			desc:     "No samples",
+			expected: Growth{Height: 144},
+		},
+		{
+			desc:    "Single sample",
+			samples: []db.GrowthSample{{BlockHeight: 100, PrizePool: 5_000, Bets: 2}},
+			expected: Growth{
+				PrizePool:          5_000,
+				ProjectedPrizePool: 5_000,
+				Bets:               2,
+				ProjectedBets:      2,
+				Height:             144,
+				BlockHeight:        100,
+				BlocksLeft:         44,
+			},
+		},
+		{
+			desc: "Growing",
+			samples: []db.GrowthSample{
+				{BlockHeight: 100, PrizePool: 5_000, Bets: 2},
+				{BlockHeight: 110, PrizePool: 10_000, Bets: 5},
+				{BlockHeight: 120, PrizePool: 25_000, Bets: 12},
+			},
+			expected: Growth{
+				PrizePool:          25_000,
+				ProjectedPrizePool: 49_000,
+				Bets:               12,
+				ProjectedBets:      24,
+				SatsPerBlock:       1_000,
+				BetsPerBlock:       0.5,
+				Height:             144,
+				BlockHeight:        120,
+				SampledBlocks:      20,
+				BlocksLeft:         24,
+			},
+		},
+		{
+			desc: "Shrinking",
+			samples: []db.GrowthSample{
+				{BlockHeight: 100, PrizePool: 5_000, Bets: 2},
+				{BlockHeight: 104, PrizePool: 4_000, Bets: 1},
+			},
+			expected: Growth{
+				PrizePool:          4_000,
+				ProjectedPrizePool: 4_000,
+				Bets:               1,
+				ProjectedBets:      1,
+				Height:             144,
+				BlockHeight:        104,
+				SampledBlocks:      4,
+				BlocksLeft:         40,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ProjectGrowth(144, tc.samples))
+		})
+	}
+}
+
+func TestTrackGrowth(t *testing.T) {
+	now := time.Unix(1231006505, 0)
+	lotteryHeight := uint32(144)
+	blockHeight := uint32(120)
+	chatID := int64(-100123)
+
+	growthMock := db.NewGrowthStoreMock()
+	growthMock.On("Sample", lotteryHeight, blockHeight, now.Unix()).
+		Return(db.GrowthSample{PrizePool: 120_000, CreatedAt: now.Unix()}, nil)
+	growthMock.On("AddMilestone", lotteryHeight, uint64(50_000), now.Unix()).Return(false, nil)
+	growthMock.On("AddMilestone", lotteryHeight, uint64(100_000), now.Unix()).Return(true, nil)
+	queueMock := jobs.NewQueueMock()
+	queueMock.On("Enqueue", jobPublishMilestone, publishMilestoneJob{
+		Amount:     100_000,
+		Height:     lotteryHeight,
+		BlocksLeft: 24,
+	}).Return(nil)
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("Notify", chatID, "The prize pool of the lottery 144 crossed 100000 sats! The "+
+		"draw takes place in 24 blocks, get your tickets before it.")
+
+	config := config.Lottery{
+		Milestones: config.Milestones{
+			Amounts: []uint64{50_000, 100_000, 500_000},
+			ChatID:  chatID,
+			Nostr:   true,
+		},
+	}
+	lottery, err := New(config, &db.DB{Growth: growthMock}, nil, notifierMock, templates, nil, nil, nil,
+		queueMock, nil, nil, nil)
+	assert.NoError(t, err)
+	lottery.now = func() time.Time { return now }
+
+	lottery.trackGrowth(lotteryHeight, blockHeight)
+
+	growthMock.AssertExpectations(t)
+	queueMock.AssertExpectations(t)
+	notifierMock.AssertExpectations(t)
+}
+
+func TestTrackGrowthNoMilestone(t *testing.T) {
+	growthMock := db.NewGrowthStoreMock()
+	growthMock.On("Sample", uint32(144), uint32(120), int64(1231006505)).
+		Return(db.GrowthSample{PrizePool: 120_000}, nil)
+	growthMock.On("AddMilestone", uint32(144), uint64(100_000), int64(0)).Return(false, nil)
+
+	config := config.Lottery{Milestones: config.Milestones{Amounts: []uint64{100_000}, Nostr: true}}
+	lottery, err := New(config, &db.DB{Growth: growthMock}, nil, nil, templates, nil, nil, nil,
+		nil, nil, nil, nil)
+	assert.NoError(t, err)
+	lottery.now = func() time.Time { return time.Unix(1231006505, 0) }
+
+	// Milestones already announced are not announced again, nothing is enqueued
+	lottery.trackGrowth(144, 120)
+	// Blocks at or past the target height are not sampled
+	lottery.trackGrowth(144, 144)
+
+	growthMock.AssertExpectations(t)
+	growthMock.AssertNumberOfCalls(t, "Sample", 1)
+}
@@ -0,0 +1,127 @@
+package lottery
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+)
+
+// defaultHubBufferSize is the number of batches buffered per subscriber if not configured.
+const defaultHubBufferSize = 8
+
+// WinnersHub broadcasts the winners of every lottery to multiple subscribers.
+//
+// Publishing never blocks the raffle: each subscriber has a bounded buffer and, when a slow one
+// falls behind, batches are dropped following the overflow policy. The last batches are kept to
+// replay them to late subscribers.
+type WinnersHub struct {
+	subscribers map[*Subscription]struct{}
+	history     [][]db.Winner
+	overflow    string
+	bufferSize  int
+	replay      int
+	mu          sync.Mutex
+}
+
+// NewWinnersHub returns a new winners hub.
+func NewWinnersHub(config config.WinnersHub) *WinnersHub {
+	bufferSize := config.BufferSize
+	if bufferSize == 0 {
+		bufferSize = defaultHubBufferSize
+	}
+
+	return &WinnersHub{
+		subscribers: make(map[*Subscription]struct{}),
+		overflow:    config.Overflow,
+		bufferSize:  bufferSize,
+		replay:      config.Replay,
+	}
+}
+
+// Publish sends the winners to every subscriber.
+func (h *WinnersHub) Publish(winners []db.Winner) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.replay > 0 {
+		if len(h.history) == h.replay {
+			h.history = append(h.history[:0], h.history[1:]...)
+		}
+		h.history = append(h.history, winners)
+	}
+
+	for subscription := range h.subscribers {
+		subscription.send(winners, h.overflow)
+	}
+}
+
+// Subscribe returns a subscription that receives the batches kept for replay followed by the ones
+// published from now on.
+func (h *WinnersHub) Subscribe() *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subscription := &Subscription{
+		hub: h,
+		ch:  make(chan []db.Winner, h.bufferSize),
+	}
+	for _, winners := range h.history {
+		subscription.send(winners, h.overflow)
+	}
+	h.subscribers[subscription] = struct{}{}
+
+	return subscription
+}
+
+// Subscription receives the winners published to a hub.
+type Subscription struct {
+	hub     *WinnersHub
+	ch      chan []db.Winner
+	dropped atomic.Uint64
+}
+
+// C returns the channel the winners are delivered through, it's closed when the subscription is.
+func (s *Subscription) C() <-chan []db.Winner {
+	return s.ch
+}
+
+// Close stops the delivery of winners to the subscription.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	if _, ok := s.hub.subscribers[s]; !ok {
+		return
+	}
+	delete(s.hub.subscribers, s)
+	close(s.ch)
+}
+
+// Dropped returns the number of batches the subscriber missed because it was falling behind.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// send delivers the winners without blocking. It must be called holding the hub lock, which
+// guarantees there's room after discarding the oldest batch.
+func (s *Subscription) send(winners []db.Winner, overflow string) {
+	select {
+	case s.ch <- winners:
+		return
+	default:
+	}
+
+	s.dropped.Add(1)
+	if overflow == config.OverflowDropNewest {
+		return
+	}
+
+	// The subscriber may have taken the oldest batch in the meantime
+	select {
+	case <-s.ch:
+	default:
+	}
+	s.ch <- winners
+}
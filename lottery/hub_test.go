@@ -0,0 +1,91 @@
+package lottery
+
+import (
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWinnersHubPublish(t *testing.T) {
+	hub := NewWinnersHub(config.WinnersHub{})
+	first := hub.Subscribe()
+	second := hub.Subscribe()
+
+	winners := []db.Winner{{PublicKey: "a", Prize: 100}}
+	hub.Publish(winners)
+
+	assert.Equal(t, winners, <-first.C())
+	assert.Equal(t, winners, <-second.C())
+
+	second.Close()
+	_, ok := <-second.C()
+	assert.False(t, ok)
+
+	// Closing twice is a no-op and publishing doesn't reach it anymore
+	second.Close()
+	hub.Publish(winners)
+	assert.Equal(t, winners, <-first.C())
+}
+
+func TestWinnersHubOverflow(t *testing.T) {
+	batches := [][]db.Winner{
+		{{PublicKey: "a"}},
+		{{PublicKey: "b"}},
+		{{PublicKey: "c"}},
+	}
+
+	cases := []struct {
+		desc     string
+		overflow string
+		expected [][]db.Winner
+	}{
+		{
+			desc:     "Drop oldest",
+			overflow: config.OverflowDropOldest,
+			expected: batches[1:],
+		},
+		{
+			desc:     "Drop newest",
+			overflow: config.OverflowDropNewest,
+			expected: batches[:2],
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			hub := NewWinnersHub(config.WinnersHub{Overflow: tc.overflow, BufferSize: 2})
+			subscription := hub.Subscribe()
+
+			// The publisher is never blocked by the subscriber
+			for _, batch := range batches {
+				hub.Publish(batch)
+			}
+
+			assert.Equal(t, uint64(1), subscription.Dropped())
+			for _, expected := range tc.expected {
+				assert.Equal(t, expected, <-subscription.C())
+			}
+		})
+	}
+}
+
+func TestWinnersHubReplay(t *testing.T) {
+	hub := NewWinnersHub(config.WinnersHub{Replay: 2})
+
+	batches := [][]db.Winner{
+		{{PublicKey: "a"}},
+		{{PublicKey: "b"}},
+		{{PublicKey: "c"}},
+	}
+	for _, batch := range batches {
+		hub.Publish(batch)
+	}
+
+	subscription := hub.Subscribe()
+	assert.Equal(t, batches[1], <-subscription.C())
+	assert.Equal(t, batches[2], <-subscription.C())
+	assert.Len(t, subscription.C(), 0)
+}
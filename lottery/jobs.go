@@ -0,0 +1,181 @@
+package lottery
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/jobs"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/pkg/errors"
+)
+
+// Kinds of the jobs executed after the draws. They are stored in the database, do not rename them.
+const (
+	jobAutoWithdrawals    = "auto_withdrawals"
+	jobDigestBatch        = "digest_batch"
+	jobDrawDigest         = "draw_digest"
+	jobExpirePrizes       = "expire_prizes"
+	jobFairnessReport     = "fairness_report"
+	jobFeeDistribution    = "fee_distribution"
+	jobNotify             = "notify"
+	jobPublishMilestone   = "publish_milestone"
+	jobPublishResultsHash = "publish_results_hash"
+	jobPublishReveal      = "publish_reveal"
+	jobPublishWinners     = "publish_winners"
+	jobRetryDeliveries    = "retry_deliveries"
+	jobRoundStats         = "round_stats"
+	jobScheduledPayouts   = "scheduled_payouts"
+)
+
+type autoWithdrawalsJob struct {
+	Winners map[string]uint64 `json:"winners"`
+	// Deadline is the block height at which the prizes expire, jobs enqueued before it was added
+	// don't have one and their payouts are never deferred
+	Deadline uint32 `json:"deadline,omitempty"`
+}
+
+type digestBatchJob struct {
+	// Prizes contains the prizes of the players of the batch that won
+	Prizes     map[string]uint64 `json:"prizes,omitempty"`
+	PublicKeys []string          `json:"public_keys"`
+	Tickets    []uint64          `json:"tickets"`
+	Height     uint32            `json:"height"`
+}
+
+type drawDigestJob struct {
+	Players []string    `json:"players"`
+	Winners []db.Winner `json:"winners"`
+	Height  uint32      `json:"height"`
+}
+
+type expirePrizesJob struct {
+	Height uint32 `json:"height"`
+}
+
+type feeDistributionJob struct {
+	Fee    uint64 `json:"fee"`
+	Height uint32 `json:"height"`
+}
+
+type notifyJob struct {
+	PublicKey string `json:"public_key"`
+	Message   string `json:"message"`
+	// RetryUntil is the time until which the services that fail to deliver the message are retried,
+	// zero if they are not
+	RetryUntil int64 `json:"retry_until,omitempty"`
+}
+
+type publishMilestoneJob struct {
+	Amount     uint64 `json:"amount"`
+	Height     uint32 `json:"height"`
+	BlocksLeft uint32 `json:"blocks_left"`
+}
+
+type publishResultsHashJob struct {
+	ResultsHash string `json:"results_hash"`
+	Height      uint32 `json:"height"`
+	Blocks      uint32 `json:"blocks"`
+}
+
+type publishWinnersJob struct {
+	// Salt of the results hash, set in the reveal of the first place only
+	Salt    string      `json:"salt,omitempty"`
+	Winners []db.Winner `json:"winners"`
+	Height  uint32      `json:"height"`
+}
+
+type scheduledPayoutsJob struct {
+	Height uint32 `json:"height"`
+}
+
+type roundStatsJob struct {
+	Winners        []db.Winner   `json:"winners"`
+	Round          db.RoundStats `json:"round"`
+	PreviousHeight uint32        `json:"previous_height"`
+}
+
+// jobHandlers returns the handlers of the jobs enqueued by the lottery.
+func (l *Lottery) jobHandlers() map[string]jobs.Handler {
+	return map[string]jobs.Handler{
+		jobAutoWithdrawals: handle(func(ctx context.Context, job autoWithdrawalsJob) error {
+			l.tryAutoWithdrawals(ctx, job.Winners, job.Deadline)
+			return nil
+		}),
+		jobDigestBatch: handle(func(_ context.Context, job digestBatchJob) error {
+			l.sendDigests(job)
+			return nil
+		}),
+		jobDrawDigest: handle(func(_ context.Context, job drawDigestJob) error {
+			return l.scheduleDigests(job)
+		}),
+		jobExpirePrizes: handle(func(_ context.Context, job expirePrizesJob) error {
+			return l.expirePrizes(job.Height)
+		}),
+		jobFairnessReport: handle(func(_ context.Context, report db.FairnessReport) error {
+			err := l.db.Fairness.Add(report)
+			return errors.Wrap(err, "storing fairness report")
+		}),
+		jobFeeDistribution: handle(func(ctx context.Context, job feeDistributionJob) error {
+			return l.distributeFee(ctx, job)
+		}),
+		jobNotify: handle(func(_ context.Context, job notifyJob) error {
+			l.deliver(db.NotificationDelivery{
+				PublicKey:  job.PublicKey,
+				Message:    job.Message,
+				RetryUntil: job.RetryUntil,
+			})
+			return nil
+		}),
+		jobPublishMilestone: handle(func(_ context.Context, job publishMilestoneJob) error {
+			return l.notifier.PublishMilestone(job.Height, job.Amount, job.BlocksLeft)
+		}),
+		jobPublishResultsHash: handle(func(_ context.Context, job publishResultsHashJob) error {
+			return l.notifier.PublishRevealCommitment(job.Height, job.ResultsHash, job.Blocks)
+		}),
+		jobPublishReveal: handle(func(_ context.Context, job publishWinnersJob) error {
+			winners, err := policy.AnonymizeWinners(l.db.Privacy, job.Winners)
+			if err != nil {
+				return errors.Wrap(err, "anonymizing winners")
+			}
+			return l.notifier.PublishReveal(job.Height, winners, job.Salt)
+		}),
+		jobPublishWinners: handle(func(_ context.Context, job publishWinnersJob) error {
+			winners, err := policy.AnonymizeWinners(l.db.Privacy, job.Winners)
+			if err != nil {
+				return errors.Wrap(err, "anonymizing winners")
+			}
+			return l.notifier.PublishWinners(job.Height, winners)
+		}),
+		jobRetryDeliveries: handle(func(_ context.Context, _ struct{}) error {
+			l.retryDeliveries()
+			return nil
+		}),
+		jobRoundStats: handle(func(_ context.Context, job roundStatsJob) error {
+			err := l.db.Stats.AddRound(job.Round, job.Winners, job.PreviousHeight)
+			return errors.Wrap(err, "updating lottery stats")
+		}),
+		jobScheduledPayouts: handle(func(ctx context.Context, job scheduledPayoutsJob) error {
+			return l.sendScheduledPayouts(ctx, job.Height)
+		}),
+	}
+}
+
+// enqueue adds a job to the queue. Errors are only logged as they must not interrupt the draw.
+func (l *Lottery) enqueue(kind string, payload any) {
+	if err := l.queue.Enqueue(kind, payload); err != nil {
+		l.logger.Error(err)
+	}
+}
+
+// handle returns a jobs handler that decodes the payload before calling fn.
+func handle[T any](fn func(ctx context.Context, job T) error) jobs.Handler {
+	return func(ctx context.Context, payload []byte) error {
+		var job T
+		if err := json.Unmarshal(payload, &job); err != nil {
+			return errors.Wrap(err, "decoding job payload")
+		}
+		return fn(ctx, job)
+	}
+}
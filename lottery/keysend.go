@@ -0,0 +1,116 @@
+package lottery
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/pkg/errors"
+)
+
+const (
+	// KeysendBetRecord is the TLV record type of the keysend payments that place bets. Its value is
+	// the public key the bet is registered under, optionally followed by the round as a big-endian
+	// 32 bits integer.
+	KeysendBetRecord uint64 = 5_128_029
+	// KeysendReplyRecord is the TLV record type of the bet payments asking for a confirmation. Its
+	// value is the compressed public key of the node the confirmation is sent to.
+	KeysendReplyRecord uint64 = 5_128_031
+	// KeysendConfirmationRecord is the TLV record type of the confirmations sent back to the payers.
+	// Its value is the round as a big-endian 32 bits integer followed by the first ticket, the
+	// last ticket and the bonus tickets as big-endian 64 bits integers.
+	KeysendConfirmationRecord uint64 = 5_128_033
+	// keysendMessageRecord is the TLV record type wallets display as the message of a keysend
+	// payment
+	keysendMessageRecord uint64 = 34_349_334
+	// keysendPreimageRecord is the TLV record type carrying the preimage of keysend payments
+	keysendPreimageRecord uint64 = 5_482_373_484
+)
+
+// ErrNoBetRecord is returned when a keysend payment doesn't carry a bet record.
+var ErrNoBetRecord = errors.New("keysend payment without a bet record")
+
+// KeysendBet is a bet placed with a keysend payment.
+type KeysendBet struct {
+	PublicKey string
+	Preimage  []byte
+	Amount    uint64
+	// ReplyTo is the node the confirmation of the bet is sent to, empty if it wasn't requested
+	ReplyTo string
+	// Round is the lottery the payer wants to bet in, 0 if it wasn't specified
+	Round uint32
+}
+
+// ParseKeysendBet returns the bet carried in the custom records of the HTLCs of a keysend payment.
+func ParseKeysendBet(htlcs []*lnrpc.InvoiceHTLC) (KeysendBet, error) {
+	var (
+		bet   KeysendBet
+		found bool
+	)
+	for _, htlc := range htlcs {
+		if htlc.State == lnrpc.InvoiceHTLCState_CANCELED {
+			continue
+		}
+		bet.Amount += htlc.AmtMsat / 1000
+
+		if preimage, ok := htlc.CustomRecords[keysendPreimageRecord]; ok {
+			bet.Preimage = preimage
+		}
+
+		if replyTo, ok := htlc.CustomRecords[KeysendReplyRecord]; ok {
+			// Node public keys are compressed secp256k1 points
+			if len(replyTo) != 33 || (replyTo[0] != 0x02 && replyTo[0] != 0x03) {
+				return KeysendBet{}, errors.New("invalid reply record, must be a node public key")
+			}
+			bet.ReplyTo = hex.EncodeToString(replyTo)
+		}
+
+		record, ok := htlc.CustomRecords[KeysendBetRecord]
+		if !ok || found {
+			continue
+		}
+		found = true
+
+		switch len(record) {
+		case ed25519.PublicKeySize:
+		case ed25519.PublicKeySize + 4:
+			bet.Round = binary.BigEndian.Uint32(record[ed25519.PublicKeySize:])
+		default:
+			return KeysendBet{}, errors.Errorf("invalid bet record length %d", len(record))
+		}
+		bet.PublicKey = hex.EncodeToString(record[:ed25519.PublicKeySize])
+	}
+
+	if !found {
+		return KeysendBet{}, ErrNoBetRecord
+	}
+	if len(bet.Preimage) == 0 {
+		return KeysendBet{}, errors.New("keysend payment without a preimage")
+	}
+	return bet, nil
+}
+
+// KeysendConfirmation returns the custom records of the keysend payment confirming the bet to
+// the payer, with the tickets it holds and a message wallets can display.
+func KeysendConfirmation(bet db.Bet) map[uint64][]byte {
+	confirmation := make([]byte, 0, 28)
+	confirmation = binary.BigEndian.AppendUint32(confirmation, bet.LotteryHeight)
+	confirmation = binary.BigEndian.AppendUint64(confirmation, bet.FirstTicket)
+	confirmation = binary.BigEndian.AppendUint64(confirmation, bet.Index)
+	confirmation = binary.BigEndian.AppendUint64(confirmation, bet.Bonus)
+
+	message := fmt.Sprintf("BTRY: tickets %d to %d in round %d", bet.FirstTicket, bet.Index,
+		bet.LotteryHeight)
+	if bet.Bonus > 0 {
+		message += fmt.Sprintf(", %d bonus tickets", bet.Bonus)
+	}
+
+	return map[uint64][]byte{
+		KeysendConfirmationRecord: confirmation,
+		keysendMessageRecord:      []byte(message),
+	}
+}
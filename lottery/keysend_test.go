@@ -0,0 +1,126 @@
+package lottery
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKeysendBet(t *testing.T) {
+	publicKey := "e68b99fc5f60c971926fdc3a3af38ccf67e6f4306ab1c388735533e7c5dcc749"
+	rawPublicKey, err := hex.DecodeString(publicKey)
+	assert.NoError(t, err)
+	preimage := []byte("preimage")
+	round := []byte{0x00, 0x0c, 0xd1, 0x40}
+	node := "03864ef025fde8fb587d989186ce6a4a186895ee44a926bfc370e2c366597a3f8f"
+	rawNode, err := hex.DecodeString(node)
+	assert.NoError(t, err)
+
+	htlc := func(amtMsat uint64, records map[uint64][]byte) *lnrpc.InvoiceHTLC {
+		return &lnrpc.InvoiceHTLC{
+			AmtMsat:       amtMsat,
+			State:         lnrpc.InvoiceHTLCState_ACCEPTED,
+			CustomRecords: records,
+		}
+	}
+
+	cases := []struct {
+		desc     string
+		htlcs    []*lnrpc.InvoiceHTLC
+		expected KeysendBet
+		fail     bool
+	}{
+		{
+			desc: "Public key",
+			htlcs: []*lnrpc.InvoiceHTLC{htlc(2_000_000, map[uint64][]byte{
+				keysendPreimageRecord: preimage,
+				KeysendBetRecord:      rawPublicKey,
+			})},
+			expected: KeysendBet{PublicKey: publicKey, Preimage: preimage, Amount: 2_000},
+		},
+		{
+			desc: "Public key and round",
+			htlcs: []*lnrpc.InvoiceHTLC{
+				htlc(1_000_000, map[uint64][]byte{
+					keysendPreimageRecord: preimage,
+					KeysendBetRecord:      append(rawPublicKey, round...),
+				}),
+				{AmtMsat: 5_000_000, State: lnrpc.InvoiceHTLCState_CANCELED},
+				htlc(500_000, nil),
+			},
+			expected: KeysendBet{PublicKey: publicKey, Preimage: preimage, Amount: 1_500, Round: 840_000},
+		},
+		{
+			desc: "Reply to node",
+			htlcs: []*lnrpc.InvoiceHTLC{htlc(1_000_000, map[uint64][]byte{
+				keysendPreimageRecord: preimage,
+				KeysendBetRecord:      rawPublicKey,
+				KeysendReplyRecord:    rawNode,
+			})},
+			expected: KeysendBet{PublicKey: publicKey, Preimage: preimage, Amount: 1_000, ReplyTo: node},
+		},
+		{
+			desc: "Invalid reply record",
+			htlcs: []*lnrpc.InvoiceHTLC{htlc(1_000_000, map[uint64][]byte{
+				keysendPreimageRecord: preimage,
+				KeysendBetRecord:      rawPublicKey,
+				KeysendReplyRecord:    rawPublicKey,
+			})},
+			fail: true,
+		},
+		{
+			desc:  "No bet record",
+			htlcs: []*lnrpc.InvoiceHTLC{htlc(1_000_000, map[uint64][]byte{keysendPreimageRecord: preimage})},
+			fail:  true,
+		},
+		{
+			desc: "Invalid length",
+			htlcs: []*lnrpc.InvoiceHTLC{htlc(1_000_000, map[uint64][]byte{
+				keysendPreimageRecord: preimage,
+				KeysendBetRecord:      rawPublicKey[:31],
+			})},
+			fail: true,
+		},
+		{
+			desc:  "No preimage",
+			htlcs: []*lnrpc.InvoiceHTLC{htlc(1_000_000, map[uint64][]byte{KeysendBetRecord: rawPublicKey})},
+			fail:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			bet, err := ParseKeysendBet(tc.htlcs)
+			if tc.fail {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, bet)
+		})
+	}
+
+	_, err = ParseKeysendBet(nil)
+	assert.ErrorIs(t, err, ErrNoBetRecord)
+}
+
+func TestKeysendConfirmation(t *testing.T) {
+	bet := db.Bet{LotteryHeight: 840_000, FirstTicket: 1, Index: 2_000, Bonus: 100}
+
+	records := KeysendConfirmation(bet)
+
+	expected := []byte{
+		0x00, 0x0c, 0xd1, 0x40,
+		0, 0, 0, 0, 0, 0, 0, 1,
+		0, 0, 0, 0, 0, 0, 0x07, 0xd0,
+		0, 0, 0, 0, 0, 0, 0, 0x64,
+	}
+	assert.Equal(t, expected, records[KeysendConfirmationRecord])
+	assert.Equal(t, "BTRY: tickets 1 to 2000 in round 840000, 100 bonus tickets",
+		string(records[keysendMessageRecord]))
+}
@@ -3,64 +3,138 @@ package lottery
 
 import (
 	"context"
-	"fmt"
-	"math"
-	"math/big"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
 	"slices"
+	"sync"
+	"time"
 
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/chaos"
 	"github.com/aftermath2/BTRY/config"
 	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/fault"
+	"github.com/aftermath2/BTRY/jobs"
+	"github.com/aftermath2/BTRY/leader"
 	"github.com/aftermath2/BTRY/lightning"
 	"github.com/aftermath2/BTRY/logger"
+	"github.com/aftermath2/BTRY/lottery/engine"
 	"github.com/aftermath2/BTRY/notification"
+	"github.com/aftermath2/BTRY/policy"
+	"github.com/aftermath2/BTRY/watchdog"
+	"github.com/aftermath2/BTRY/webhooks"
 
 	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
 	"github.com/pkg/errors"
 )
 
-// Prize pool percentages
-const (
-	first   float64 = 50
-	second          = first / 2
-	third           = second / 2
-	fourth          = third / 2
-	fifth           = fourth / 2
-	sixth           = fifth / 2
-	seventh         = sixth / 2
-	eighth          = seventh / 2
-	btryFee         = eighth
-
-	// Lottery capacity divisor
-	CapacityDivisor = 5
-)
-
-var prizes = [8]float64{first, second, third, fourth, fifth, sixth, seventh, eighth}
+// Average time between blocks
+const blockTime = 10 * time.Minute
 
 // Info contains details about the lottery.
+//
+// PrizePool and Capacity are the totals of all the pools.
 type Info struct {
-	PrizePool  int64  `json:"prize_pool"`
-	Capacity   int64  `json:"capacity"`
-	NextHeight uint32 `json:"next_height"`
+	// Postponement is set while a draw waits for the node to be able to pay its prizes, bets are
+	// not accepted meanwhile
+	Postponement *db.Postponement `json:"postponement,omitempty"`
+	Pools        []PoolInfo       `json:"pools"`
+	PrizePool    int64            `json:"prize_pool"`
+	Capacity     int64            `json:"capacity"`
+	NextHeight   uint32           `json:"next_height"`
+}
+
+// PoolInfo contains details about a lottery pool.
+type PoolInfo struct {
+	Name      string `json:"name,omitempty"`
+	PrizePool int64  `json:"prize_pool"`
+	Capacity  int64  `json:"capacity"`
+	MinAmount uint64 `json:"min_amount,omitempty"`
+	MaxAmount uint64 `json:"max_amount,omitempty"`
+}
+
+// Pool returns the information of the pool with the name specified.
+func (i Info) Pool(name string) (PoolInfo, bool) {
+	for _, pool := range i.Pools {
+		if pool.Name == name {
+			return pool, true
+		}
+	}
+	return PoolInfo{}, false
+}
+
+// Node is the part of the lightning node the lottery uses to follow the chain height and pay the
+// prizes automatically.
+type Node interface {
+	lightning.NodeInfo
+	lightning.PaymentSender
 }
 
 // Lottery is in charge of handling the lottery's logic.
 type Lottery struct {
-	lnd            lightning.Client
+	lnd            Node
 	notifier       notification.Notifier
+	templates      *notification.Templates
+	auditor        audit.Auditor
+	watchdog       watchdog.Watchdog
+	leader         leader.Elector
+	queue          jobs.Queue
+	webhooks       webhooks.Publisher
 	logger         *logger.Logger
 	db             *db.DB
-	winnersCh      chan<- []db.Winner
+	winnersHub     *WinnersHub
 	blocksCh       <-chan *chainrpc.BlockEpoch
+	now            func() time.Time
+	random         io.Reader
+	pools          Pools
+	nextPools      Pools
+	bonus          config.Bonus
+	deadManSwitch  config.DeadManSwitch
+	postponement   config.Postponement
+	drawSLO        config.DrawSLO
+	digest         config.Digest
+	reveal         config.Reveal
+	milestones     config.Milestones
+	payoutSchedule PayoutSchedule
+	retry          fault.Policy
+	capacity       CapacityOracle
+	rounding       engine.Rounding
+	collision      engine.Collision
+	hooks          []engine.Hook
+	// tiers scales the prizes of the pools with their participants, empty keeps the distributions
+	tiers          engine.TierSchedule
 	blocksDuration uint32
+	claimWindow    uint32
+	// archiveRetention is the number of lotteries whose bet archives are kept
+	archiveRetention uint32
+	// approvalThreshold is the amount above which payouts need the operators approval
+	approvalThreshold uint64
+	// overlap is the number of blocks before the target height of a lottery the next one opens
+	overlap uint32
+	// feeDestinations receive a share of the fee of every lottery
+	feeDestinations []config.FeeDestination
+	// roundPools are the prize tables of the lotteries open, each one is drawn with the tables in
+	// place when it opened
+	roundPools map[uint32]Pools
+	// mu protects the pools, the bonus and approvalThreshold, which are updated when the
+	// configuration is reloaded
+	mu sync.Mutex
 }
 
 // New returns a new Lottery object.
 func New(
 	config config.Lottery,
 	db *db.DB,
-	lnd lightning.Client,
+	lnd Node,
 	notifier notification.Notifier,
-	winnersCh chan<- []db.Winner,
+	templates *notification.Templates,
+	auditor audit.Auditor,
+	watchdog watchdog.Watchdog,
+	leader leader.Elector,
+	queue jobs.Queue,
+	webhooks webhooks.Publisher,
+	winnersHub *WinnersHub,
 	blocksCh <-chan *chainrpc.BlockEpoch,
 ) (*Lottery, error) {
 	logger, err := logger.New(config.Logger)
@@ -68,150 +142,861 @@ func New(
 		return nil, err
 	}
 
+	digest := config.Digest
+	if digest.BatchSize == 0 {
+		digest.BatchSize = defaultDigestBatchSize
+	}
+	if digest.Interval == 0 {
+		digest.Interval = defaultDigestInterval
+	}
+
 	return &Lottery{
-		blocksDuration: config.Duration,
-		logger:         logger,
-		db:             db,
-		lnd:            lnd,
-		notifier:       notifier,
-		winnersCh:      winnersCh,
-		blocksCh:       blocksCh,
+		approvalThreshold: config.Approvals.Threshold,
+		archiveRetention:  config.BetArchive.Retention,
+		blocksDuration:    config.DurationBlocks(),
+		overlap:           config.Overlap,
+		claimWindow:       config.ClaimWindowBlocks(),
+		deadManSwitch:     config.DeadManSwitch,
+		postponement:      config.Postponement,
+		drawSLO:           DrawSLO(config.DrawSLO),
+		digest:            digest,
+		reveal:            config.Reveal,
+		milestones:        config.Milestones,
+		payoutSchedule:    PayoutSchedule(config.Payouts),
+		retry:             fault.DefaultPolicy,
+		capacity:          NewCapacityOracle(config.Capacity),
+		pools:             NewPools(config.Pools),
+		bonus:             config.Bonus,
+		roundPools:        make(map[uint32]Pools),
+		rounding:          engine.Rounding(config.Rounding),
+		collision:         engine.Collision(config.Collision),
+		hooks:             DrawHooks(config.LastTicket),
+		tiers:             config.TierSchedule(),
+		feeDestinations:   config.FeeDestinations,
+		now:               time.Now,
+		random:            rand.Reader,
+		logger:            logger,
+		db:                db,
+		lnd:               lnd,
+		notifier:          notifier,
+		templates:         templates,
+		auditor:           auditor,
+		watchdog:          watchdog,
+		leader:            leader,
+		queue:             queue,
+		webhooks:          webhooks,
+		winnersHub:        winnersHub,
+		blocksCh:          blocksCh,
 	}, nil
 }
 
 // Start executes the loop in charge of doing the periodic lottery.
+//
+// The handlers of the jobs the draws enqueue are registered, the jobs queue must be started
+// afterwards.
 func (l *Lottery) Start() error {
 	ctx := context.Background()
 
-	info, err := l.lnd.GetInfo(ctx)
-	if err != nil {
-		return err
+	for kind, handler := range l.jobHandlers() {
+		l.queue.Register(kind, handler)
 	}
 
-	nextHeight, err := l.db.Lotteries.GetNextHeight()
+	info, err := l.lnd.GetInfo(ctx)
 	if err != nil {
 		return err
 	}
 
-	if nextHeight == 0 || info.BlockHeight > nextHeight {
-		if nextHeight != 0 && info.BlockHeight > nextHeight {
-			// Remove next height to avoid showing one where no lottery has taken place.
-			// Means the server was down when the block was mined.
-			if err := l.db.Lotteries.DeleteHeight(nextHeight); err != nil {
-				return err
-			}
-		}
-
-		nextHeight = info.BlockHeight + l.blocksDuration
-		if err := l.db.Lotteries.AddHeight(nextHeight); err != nil {
+	// postponed is the target block whose draw was paused, by the watchdog or because the node
+	// can't pay its prizes, it's retried on every block
+	var (
+		pending   []uint32
+		postponed *chainrpc.BlockEpoch
+	)
+	if l.leader.IsLeader() {
+		pending, postponed, err = l.resume(info.BlockHeight)
+		if err != nil {
 			return err
 		}
 	}
 
-	l.logger.Infof("Next block height target: %d", nextHeight)
-
 	go func() {
 		for {
 			block := <-l.blocksCh
-			if block.Height != nextHeight {
+			if chaos.DropBlock() {
+				l.logger.Warningf("Chaos: dropped block %d event", block.Height)
 				continue
 			}
+			tip := block.Height
+			l.watchdog.Observe(block.Height)
 
-			// Block hash bytes are reversed, correct it
-			slices.Reverse(block.Hash)
+			// Followers only keep up with the blocks, the targets are loaded again once elected
+			if !l.leader.IsLeader() {
+				pending, postponed = nil, nil
+				continue
+			}
+			if pending == nil {
+				var err error
+				pending, postponed, err = l.resume(block.Height)
+				if err != nil {
+					l.logger.Error(err)
+					continue
+				}
+			}
 
-			if err := l.raffle(block); err != nil {
-				l.logger.Error(err)
+			l.remindWinners(block.Height)
+			l.enqueue(jobRetryDeliveries, struct{}{})
+			l.revealWinners(block.Height)
+			// Bets are placed in the latest lottery opened
+			l.trackGrowth(pending[len(pending)-1], block.Height)
+			if l.payoutSchedule.Enabled() {
+				l.enqueue(jobScheduledPayouts, scheduledPayoutsJob{Height: block.Height})
 			}
 
-			// Add next lottery height
-			nextHeight += l.blocksDuration
-			if err := l.db.Lotteries.AddHeight(nextHeight); err != nil {
+			if postponed != nil {
+				block = postponed
+			} else {
+				if block.Height > pending[0] {
+					var err error
+					pending, err = l.skipMissedLotteries(pending, block.Height)
+					if err != nil {
+						l.logger.Error(err)
+						continue
+					}
+
+					l.logger.Infof("Next block height targets: %v", pending)
+					continue
+				}
+
+				if block.Height != pending[0] {
+					pending = l.openNext(pending, block.Height)
+					continue
+				}
+
+				// Block hash bytes are reversed, correct it
+				slices.Reverse(block.Hash)
+			}
+
+			if err := l.watchdog.Verify(ctx, block.Height, hex.EncodeToString(block.Hash)); err != nil {
+				l.logger.Warningf("Postponing lottery %d draw: %v", block.Height, err)
+				postponed = block
+				continue
+			}
+			postponed = nil
+
+			if l.postponement.Enabled {
+				wait, err := l.deferDraw(ctx, block, tip)
+				if err != nil {
+					l.logger.Warningf("Retrying lottery %d draw on the next block: %v", block.Height, err)
+					postponed = block
+					continue
+				}
+				if wait {
+					postponed = block
+					continue
+				}
+			}
+
+			if err := l.raffle(block); err != nil {
+				if fault.IsTransient(err) {
+					l.logger.Warningf("Retrying lottery %d draw on the next block: %v", block.Height, err)
+					postponed = block
+					continue
+				}
 				l.logger.Error(err)
 			}
+			l.compactBets(block.Height)
+			l.pruneClaimCodes()
+			l.pruneBetArchives(block.Height)
 
-			l.logger.Infof("Next block height target: %d", nextHeight)
+			delete(l.roundPools, block.Height)
+			pending = l.openNext(pending[1:], block.Height)
 		}
 	}()
 
 	return nil
 }
 
-func (l *Lottery) raffle(block *chainrpc.BlockEpoch) error {
-	// Expire prizes assigned blocksDuration*5 or more blocks ago
-	expiredPrizes, err := l.db.Prizes.Expire(block.Height - (l.blocksDuration * 5))
+// Reload schedules the prize tables of the pools to be used from the next lottery on, the current
+// one is drawn with the prize tables that were in place when its bets were placed. The bonus
+// settings apply to the bets placed from now on.
+func (l *Lottery) Reload(config config.Lottery) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.nextPools = NewPools(config.Pools)
+	l.bonus = config.Bonus
+	l.approvalThreshold = config.Approvals.Threshold
+}
+
+// Bonus returns the settings of the bonus tickets granted to the bets.
+func (l *Lottery) Bonus() config.Bonus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.bonus
+}
+
+// Pools returns the prize tables of the latest lottery opened, which the bets are placed in.
+func (l *Lottery) Pools() Pools {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.pools
+}
+
+// resume returns the target heights of the lotteries pending, skipping the ones missed while the
+// instance was down or a follower. If the draw of the first one was postponed, its target block is
+// returned instead and nothing is skipped until it's drawn.
+func (l *Lottery) resume(blockHeight uint32) ([]uint32, *chainrpc.BlockEpoch, error) {
+	pending, err := l.listPending(blockHeight)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	postponed, err := l.restorePostponement(pending)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if postponed == nil && blockHeight > pending[0] {
+		pending, err = l.skipMissedLotteries(pending, blockHeight)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	l.logger.Infof("Next block height targets: %v", pending)
+	return pending, postponed, nil
+}
+
+// listPending returns the target heights of the lotteries open that weren't drawn yet, in
+// ascending order, opening the first one if there are none.
+//
+// Without overlap only the latest lottery may be pending, otherwise the previous one may still be
+// awaiting its draw.
+func (l *Lottery) listPending(blockHeight uint32) ([]uint32, error) {
+	latest, err := l.db.Lotteries.GetNextHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	if latest == 0 {
+		latest = blockHeight + l.blocksDuration
+		if err := l.openRound(latest); err != nil {
+			return nil, err
+		}
+		return []uint32{latest}, nil
+	}
+
+	if l.overlap == 0 {
+		return []uint32{latest}, nil
+	}
+
+	pending, err := l.db.Lotteries.ListUndrawn(latest - min(latest, l.blocksDuration))
 	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(pending, latest) {
+		pending = append(pending, latest)
+	}
+
+	return pending, nil
+}
+
+// openNext opens the lottery that follows the latest one once it stops accepting bets, overlap
+// blocks before its target height, and returns the target heights pending.
+func (l *Lottery) openNext(pending []uint32, blockHeight uint32) []uint32 {
+	latest := blockHeight
+	if len(pending) > 0 {
+		latest = pending[len(pending)-1]
+	}
+
+	if latest > blockHeight+l.overlap {
+		return pending
+	}
+
+	nextHeight := latest + l.blocksDuration
+	if err := l.openRound(nextHeight); err != nil {
+		l.logger.Error(err)
+	}
+
+	pending = append(pending, nextHeight)
+	l.logger.Infof("Next block height targets: %v", pending)
+	return pending
+}
+
+// openRound opens the lottery at height with the prize tables reloaded, if any. The lotteries
+// already open are drawn with the tables in place when they opened.
+func (l *Lottery) openRound(height uint32) error {
+	l.rotatePools()
+	l.roundPools[height] = l.pools
+
+	return l.open(height)
+}
+
+// open starts the lottery at the height specified, committing to a random server seed that is
+// combined with the block hash to draw it. The commitment is published so players can verify that
+// the seed revealed after the draw was chosen in advance.
+//
+// The seed is read from l.random, tests replace it to open lotteries with known seeds.
+func (l *Lottery) open(height uint32) error {
+	seed := make([]byte, 32)
+	if _, err := io.ReadFull(l.random, seed); err != nil {
+		return errors.Wrap(err, "generating server seed")
+	}
+	commitment := hex.EncodeToString(engine.Commitment(seed))
+
+	if err := l.db.Lotteries.AddHeight(height, hex.EncodeToString(seed), commitment); err != nil {
 		return err
 	}
-	l.logger.Infof("Expired prizes: %d", expiredPrizes)
 
-	bets, err := l.db.Bets.List(block.Height, 0, 0, false)
+	if err := l.notifier.PublishCommitment(height, commitment); err != nil {
+		l.logger.Error(errors.Wrapf(err, "publishing lottery %d commitment", height))
+	}
+
+	return nil
+}
+
+// rotatePools switches to the prize tables reloaded, if any. It's called when a new lottery
+// starts.
+func (l *Lottery) rotatePools() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.nextPools == nil {
+		return
+	}
+
+	l.pools = l.nextPools
+	l.nextPools = nil
+	l.logger.Info("Using the prize tables reloaded")
+}
+
+// skipMissedLotteries skips the pending lotteries whose target height was mined without drawing
+// them and returns the target heights still pending. A new lottery is started if the latest one
+// was missed too.
+func (l *Lottery) skipMissedLotteries(pending []uint32, blockHeight uint32) ([]uint32, error) {
+	nextHeight := pending[len(pending)-1]
+	if nextHeight < blockHeight {
+		nextHeight = blockHeight + l.blocksDuration
+		if err := l.openRound(nextHeight); err != nil {
+			return pending, err
+		}
+		pending = append(pending, nextHeight)
+	}
+
+	for pending[0] < blockHeight {
+		if err := l.skipMissedLottery(pending[0], blockHeight, nextHeight); err != nil {
+			return pending, err
+		}
+		delete(l.roundPools, pending[0])
+		pending = pending[1:]
+	}
+
+	return pending, nil
+}
+
+// skipMissedLottery removes a lottery whose target height was mined without drawing it.
+//
+// If the dead man switch is enabled, the bets of the missed lottery are moved to the one at
+// nextHeight when only a few target heights were missed, and refunded otherwise.
+func (l *Lottery) skipMissedLottery(missedHeight, blockHeight, nextHeight uint32) error {
+	missedHeights := (blockHeight-missedHeight)/l.blocksDuration + 1
+	l.logger.Warningf("Lottery %d was not drawn, %d target heights were missed", missedHeight, missedHeights)
+
+	refund := l.deadManSwitch.Enabled && missedHeights > l.deadManSwitch.MaxMissedHeights
+	if l.deadManSwitch.Enabled && !refund {
+		if err := l.db.Bets.Move(missedHeight, nextHeight, l.auditor.SignReceipt); err != nil {
+			return err
+		}
+	}
+
+	// Remove the missed height to avoid showing one where no lottery has taken place
+	if err := l.db.Lotteries.DeleteHeight(missedHeight); err != nil {
+		return err
+	}
+
+	if refund {
+		l.logger.Warningf("Dead man switch triggered, refunding the bets of lottery %d", missedHeight)
+		if err := l.refund(missedHeight, nextHeight); err != nil {
+			return errors.Wrap(err, "refunding bets")
+		}
+	}
+
+	return nil
+}
+
+// refund gives the sats bet in a lottery that couldn't be drawn back to the bettors as prizes,
+// which expire with the ones of the lottery at height.
+func (l *Lottery) refund(missedHeight, height uint32) error {
+	bets, err := l.listBets(missedHeight)
 	if err != nil {
-		return errors.Wrap(err, "listing bets")
+		return err
 	}
 
 	if len(bets) == 0 {
 		return nil
 	}
 
-	prizePool, err := l.db.Bets.GetPrizePool(block.Height)
+	// Bonus tickets were not paid for
+	refundsMap := make(map[string]uint64)
+	total := uint64(0)
+	for _, bet := range bets {
+		amount := bet.Tickets - bet.Bonus
+		refundsMap[bet.PublicKey] += amount
+		total += amount
+	}
+
+	refunds := make([]db.Winner, 0, len(refundsMap))
+	for publicKey, amount := range refundsMap {
+		refunds = append(refunds, db.Winner{PublicKey: publicKey, Prize: amount})
+	}
+
+	if err := l.db.Prizes.Set(height, refunds); err != nil {
+		return errors.Wrap(err, "saving refunds")
+	}
+
+	l.auditor.Record(audit.BetsRefunded, map[string]any{
+		"lottery_height": missedHeight,
+		"amount":         total,
+		"bets":           len(bets),
+	})
+
+	expirationBlock := height + l.claimWindow
+	blocksLeft := l.blocksDuration + l.claimWindow
+	expirationTime := l.now().Add(time.Duration(blocksLeft) * blockTime).UTC()
+	deadline := expirationTime.Format(notification.DeadlineLayout)
+
+	for publicKey, amount := range refundsMap {
+		message, ok := l.render(notification.EventRefund, notification.Data{
+			Prize:          amount,
+			Height:         missedHeight,
+			DeadlineHeight: expirationBlock,
+			Deadline:       deadline,
+		})
+		if ok {
+			l.enqueue(jobNotify, notifyJob{PublicKey: publicKey, Message: message})
+		}
+	}
+
+	l.enqueue(jobAutoWithdrawals, autoWithdrawalsJob{Winners: refundsMap, Deadline: expirationBlock})
+	return nil
+}
+
+// raffle draws the lottery of the block and stores its winners. The rest of the side effects are
+// executed asynchronously by the jobs queue to keep the draw fast and unaffected by external
+// services outages.
+//
+// Errors are classified, the draws that fail transiently before storing the results are unlocked
+// so they can be retried.
+func (l *Lottery) raffle(block *chainrpc.BlockEpoch) (err error) {
+	// The block feed may deliver the target height again after reconnecting. The draw is locked
+	// along with its results, a draw interrupted before storing them is executed again
+	drawn, err := l.db.Lotteries.IsDrawn(block.Height)
 	if err != nil {
-		return err
+		return db.Classify(errors.Wrap(err, "checking draw"))
+	}
+	if drawn {
+		l.logger.Warningf("Lottery %d was already drawn, skipping it", block.Height)
+		return nil
+	}
+
+	defer func() {
+		err = db.Classify(err)
+	}()
+
+	// Expire prizes whose claim window ended
+	if block.Height > l.claimWindow {
+		l.enqueue(jobExpirePrizes, expirePrizesJob{Height: block.Height})
+	}
+
+	// Lotteries opened before a restart are drawn with the prize tables currently in place
+	tables, ok := l.roundPools[block.Height]
+	if !ok {
+		tables = l.pools
+	}
+
+	timer := newDrawTimer(l.now)
+	pools, err := l.db.Bets.ListPools(block.Height)
+	if err != nil {
+		return errors.Wrap(err, "listing pools")
+	}
+
+	if len(pools) == 0 {
+		return nil
 	}
 
-	winners, err := getWinners(block.Hash, prizePool, bets)
+	commitment, err := l.db.Lotteries.GetCommitment(block.Height)
 	if err != nil {
-		return errors.Wrap(err, "getting winners")
+		return errors.Wrap(err, "getting commitment")
+	}
+
+	serverSeed, err := hex.DecodeString(commitment.Seed)
+	if err != nil {
+		return errors.Wrap(err, "decoding server seed")
+	}
+	drawSeed := engine.DrawSeed(serverSeed, block.Hash)
+	timer.lap(stageBetsListing)
+
+	// The collision policy is stored so the draw can be verified after it changes
+	err = l.db.Lotteries.SetDraw(block.Height, hex.EncodeToString(block.Hash), string(l.collision))
+	if err != nil {
+		return errors.Wrap(err, "saving draw")
+	}
+	timer.lap(stageDBWrites)
+
+	var (
+		allBets   []db.Bet
+		winners   []db.Winner
+		tiers     []db.Tiers
+		draws     []map[string]any
+		prizePool uint64
+	)
+	claimDeadline := block.Height + l.claimWindow
+	for _, pool := range pools {
+		bets, err := l.db.Bets.List(block.Height, pool, 0, 0, false)
+		if err != nil {
+			return errors.Wrap(err, "listing bets")
+		}
+		timer.lap(stageBetsListing)
+
+		distribution := tables.Distribution(pool)
+		var participants uint64
+		if len(l.tiers) > 0 {
+			participants = engine.Participants(betTickets(bets))
+			distribution = distribution.Adapt(l.tiers.Tiers(participants))
+			tiers = append(tiers, db.Tiers{
+				Pool:         pool,
+				Participants: participants,
+				Tiers:        uint32(len(distribution)),
+			})
+		}
+
+		seed := engine.PoolSeed(drawSeed, pool)
+		poolWinners, err := getWinners(seed, bets, distribution, l.rounding, l.collision, l.hooks...)
+		if err != nil {
+			return errors.Wrapf(err, "getting winners of pool %q", pool)
+		}
+
+		for i := range poolWinners {
+			poolWinners[i].ClaimDeadline = claimDeadline
+			poolWinners[i].Pool = pool
+			poolWinners[i].Tier = uint32(i)
+		}
+
+		draw := map[string]any{
+			"lottery_height": block.Height,
+			"pool":           pool,
+			"block_hash":     hex.EncodeToString(block.Hash),
+			"server_seed":    commitment.Seed,
+			"commitment":     commitment.Commitment,
+			"collision":      l.collision,
+			"prize_pool":     bets[len(bets)-1].Index,
+			"bets":           len(bets),
+		}
+		if len(l.tiers) > 0 {
+			draw["participants"] = participants
+			draw["tiers"] = len(distribution)
+		}
+		draws = append(draws, draw)
+		allBets = append(allBets, bets...)
+		winners = append(winners, poolWinners...)
+		prizePool += bets[len(bets)-1].Index
+		timer.lap(stageWinners)
 	}
 
-	if err := l.db.Winners.Add(block.Height, winners); err != nil {
-		return errors.Wrap(err, "saving winners")
+	if err := l.db.BetArchives.Add(block.Height, allBets); err != nil {
+		return errors.Wrap(err, "archiving bets")
 	}
 
-	if err := l.db.Prizes.Set(block.Height, winners); err != nil {
-		return errors.Wrap(err, "saving prizes")
+	// The tiers are stored so the draw can be verified after the schedule changes
+	if err := l.db.Lotteries.SetTiers(block.Height, tiers); err != nil {
+		return errors.Wrap(err, "saving tiers")
 	}
 
-	l.winnersCh <- winners
+	// The reveal is stored along with the winners so no winner is listed without it
+	var reveal *db.Reveal
+	suspense := l.reveal.Blocks > 0 && len(winners) > 0
+	if suspense {
+		r, err := l.newReveal(block.Height, prizePool, winners)
+		if err != nil {
+			return err
+		}
+		reveal = &r
+	}
+
+	// Winners, prizes and the lock are stored in the same transaction, the draw can be retried
+	// until it's committed
+	if err := l.db.Winners.Store(block.Height, winners, reveal); err != nil {
+		if errors.Is(err, db.ErrLotteryDrawn) {
+			l.logger.Warningf("Lottery %d was already drawn, skipping it", block.Height)
+			return nil
+		}
+		return errors.Wrap(err, "saving results")
+	}
+
+	if suspense {
+		l.commitResults(*reveal)
+	}
+
+	l.checkCapacity(context.Background(), block.Height, prizePool)
+
+	for _, draw := range draws {
+		l.auditor.Record(audit.DrawExecuted, draw)
+	}
+	for _, winner := range winners {
+		l.auditor.Record(audit.PrizeAssigned, map[string]any{
+			"lottery_height": block.Height,
+			"pool":           winner.Pool,
+			"public_key":     winner.PublicKey,
+			"ticket":         winner.Ticket,
+			"prize":          winner.Prize,
+		})
+	}
+
+	timer.lap(stageDBWrites)
+
+	// In suspense mode the winners are announced as their tiers are revealed
+	if !suspense {
+		l.winnersHub.Publish(winners)
+		l.enqueue(jobPublishWinners, publishWinnersJob{Height: block.Height, Winners: winners})
+		l.announceDraw(block.Height, prizePool, allBets, winners)
+	}
 
 	winnersMap := aggregateWinners(winners)
 	l.notifyWinners(block.Height, winnersMap)
-	l.tryAutoWithdrawals(block.Height, winnersMap)
+	l.enqueue(jobAutoWithdrawals, autoWithdrawalsJob{
+		Winners:  winnersMap,
+		Deadline: block.Height + l.claimWindow,
+	})
+	if fee := lotteryFee(allBets, winners); fee > 0 && len(l.feeDestinations) > 0 {
+		l.enqueue(jobFeeDistribution, feeDistributionJob{Height: block.Height, Fee: fee})
+	}
+	timer.lap(stageNotifications)
+
+	l.recordTiming(timer.timing(block.Height, len(allBets)))
+
+	return nil
+}
 
-	if err := l.notifier.PublishWinners(block.Height, winners); err != nil {
+// announceDraw executes the side effects that disclose the results of the lottery to everyone.
+func (l *Lottery) announceDraw(lotteryHeight uint32, prizePool uint64, bets []db.Bet, winners []db.Winner) {
+	l.enqueueStats(lotteryHeight, prizePool, bets, winners)
+	l.enqueue(jobFairnessReport, newFairnessReport(lotteryHeight, bets, winners))
+	l.publishWebhooks(lotteryHeight, prizePool, winners)
+	l.enqueueDigest(lotteryHeight, bets, winners)
+}
+
+// publishWebhooks publishes the draw and its winners to the webhooks subscribed. Winners are
+// displayed according to their privacy preferences.
+func (l *Lottery) publishWebhooks(lotteryHeight uint32, prizePool uint64, winners []db.Winner) {
+	anonymized, err := policy.AnonymizeWinners(l.db.Privacy, winners)
+	if err != nil {
+		l.logger.Error(errors.Wrap(err, "anonymizing winners"))
+		return
+	}
+
+	l.webhooks.Publish(webhooks.DrawCompleted, map[string]any{
+		"lottery_height": lotteryHeight,
+		"prize_pool":     prizePool,
+		"winners":        anonymized,
+	})
+	for _, winner := range anonymized {
+		l.webhooks.Publish(webhooks.WinnerAnnounced, map[string]any{
+			"lottery_height": lotteryHeight,
+			"winner":         winner,
+		})
+	}
+}
+
+// compactBets merges the consecutive bets of each player in the lottery drawn to reduce the number
+// of rows stored. Errors are only logged as the bets are still valid if they are not compacted.
+func (l *Lottery) compactBets(lotteryHeight uint32) {
+	removed, err := l.db.Bets.Compact(lotteryHeight)
+	if err != nil {
+		l.logger.Error(errors.Wrapf(err, "compacting lottery %d bets", lotteryHeight))
+		return
+	}
+
+	if removed > 0 {
+		l.logger.Infof("Compacted lottery %d bets, %d rows removed", lotteryHeight, removed)
+	}
+}
+
+// pruneClaimCodes removes the anonymous bets claim codes that expired, their prizes can no longer
+// be claimed. Errors are only logged as expired codes are rejected anyway.
+func (l *Lottery) pruneClaimCodes() {
+	pruned, err := l.db.ClaimCodes.DeleteExpired(l.now().Unix())
+	if err != nil {
+		l.logger.Error(errors.Wrap(err, "pruning expired claim codes"))
+		return
+	}
+
+	if pruned > 0 {
+		l.logger.Infof("Pruned %d expired claim codes", pruned)
+	}
+}
+
+// pruneBetArchives removes the bet archives of the lotteries that fall outside the retention.
+// Errors are only logged as they are retried on the next draw.
+func (l *Lottery) pruneBetArchives(blockHeight uint32) {
+	if l.archiveRetention == 0 {
+		return
+	}
+
+	retained := l.archiveRetention * l.blocksDuration
+	if blockHeight <= retained {
+		return
+	}
+
+	pruned, err := l.db.BetArchives.Delete(blockHeight - retained + 1)
+	if err != nil {
+		l.logger.Error(errors.Wrap(err, "pruning bet archives"))
+		return
+	}
+
+	if pruned > 0 {
+		l.logger.Infof("Pruned %d bet archives", pruned)
+	}
+}
+
+// enqueueStats schedules the update of the aggregate statistics with the lottery results.
+func (l *Lottery) enqueueStats(blockHeight uint32, prizePool uint64, bets []db.Bet, winners []db.Winner) {
+	players := make(map[string]struct{}, len(bets))
+	for _, bet := range bets {
+		players[bet.PublicKey] = struct{}{}
+	}
+
+	round := db.RoundStats{
+		Height:    blockHeight,
+		PrizePool: prizePool,
+		Players:   uint64(len(players)),
+	}
+	l.enqueue(jobRoundStats, roundStatsJob{
+		Round:          round,
+		Winners:        winners,
+		PreviousHeight: blockHeight - l.blocksDuration,
+	})
+}
+
+// listBets returns the bets placed in all the pools of a lottery.
+func (l *Lottery) listBets(lotteryHeight uint32) ([]db.Bet, error) {
+	pools, err := l.db.Bets.ListPools(lotteryHeight)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing pools")
+	}
+
+	var bets []db.Bet
+	for _, pool := range pools {
+		poolBets, err := l.db.Bets.List(lotteryHeight, pool, 0, 0, false)
+		if err != nil {
+			return nil, errors.Wrap(err, "listing bets")
+		}
+		bets = append(bets, poolBets...)
+	}
+
+	return bets, nil
+}
+
+// expirePrizes sets the prizes assigned claimWindow or more blocks ago as expired.
+func (l *Lottery) expirePrizes(blockHeight uint32) error {
+	expiredPrizes, err := l.db.Prizes.Expire(blockHeight - l.claimWindow)
+	if err != nil {
 		return err
 	}
+	l.logger.Infof("Expired prizes: %d", expiredPrizes)
+
+	if expiredPrizes > 0 {
+		l.auditor.Record(audit.PrizeExpired, map[string]any{
+			"lottery_height": blockHeight,
+			"amount":         expiredPrizes,
+		})
+	}
 
 	return nil
 }
 
+// notify sends the message through every service the public key enabled notifications on.
 func (l *Lottery) notify(publicKey, message string) {
-	chatID, err := l.db.Notifications.GetChatID(publicKey)
+	l.deliver(db.NotificationDelivery{PublicKey: publicKey, Message: message})
+}
+
+// deliver sends the message of the delivery through every service its public key enabled
+// notifications on. The delivery through each of them is tracked separately, so the ones that fail
+// can be retried until RetryUntil while the others are not sent twice.
+func (l *Lottery) deliver(delivery db.NotificationDelivery) {
+	chatID, err := l.db.Notifications.GetChatID(delivery.PublicKey)
+	switch {
+	case err == nil:
+		l.notifier.NotifyPlayer(delivery, chatID)
+	case !errors.Is(err, db.ErrNoChatID):
+		l.logger.Error(errors.Wrap(err, "getting telegram chat ID"))
+	}
+
+	nostrKey, err := l.db.Notifications.GetNostrKey(delivery.PublicKey)
+	switch {
+	case err == nil:
+		l.notifier.NotifyNostr(delivery, nostrKey)
+	case !errors.Is(err, db.ErrNoNostrKey):
+		l.logger.Error(errors.Wrap(err, "getting nostr key"))
+	}
+}
+
+// render returns the message of the event. Errors are only logged, the templates were validated
+// at startup.
+func (l *Lottery) render(event notification.Event, data notification.Data) (string, bool) {
+	message, err := l.templates.Render(event, data)
 	if err != nil {
-		if !errors.Is(err, db.ErrNoChatID) {
-			l.logger.Error(errors.Wrap(err, "getting telegram chat ID"))
-		}
-		return
+		l.logger.Error(err)
+		return "", false
 	}
 
-	l.notifier.Notify(chatID, message)
+	return message, true
 }
 
-// notifyWinners sends a notification with a congratulations message to the winners if they have
-// enabled the notifications.
+// notifyWinners enqueues a notification with a congratulations message to the winners, which is
+// sent if they have enabled the notifications. The services that fail to deliver it are retried
+// until the prizes expire.
 func (l *Lottery) notifyWinners(blockHeight uint32, winnersMap map[string]uint64) {
-	expirationBlock := blockHeight + l.blocksDuration*5
+	expirationBlock := blockHeight + l.claimWindow
+	expirationTime := l.now().Add(time.Duration(l.claimWindow) * blockTime).UTC()
+	deadline := expirationTime.Format(notification.DeadlineLayout)
+
 	for publicKey, prizes := range winnersMap {
-		message := fmt.Sprintf(notification.Congratulations, prizes, expirationBlock)
-		l.notify(publicKey, message)
+		message, ok := l.render(notification.EventWin, notification.Data{
+			Prize:          prizes,
+			Height:         blockHeight,
+			DeadlineHeight: expirationBlock,
+			Deadline:       deadline,
+		})
+		if ok {
+			l.enqueue(jobNotify, notifyJob{
+				PublicKey:  publicKey,
+				Message:    message,
+				RetryUntil: expirationTime.Unix(),
+			})
+		}
 	}
 }
 
-// tryAutoWithdrawals attempts to send winners their prizes via lightning addresses.
-func (l *Lottery) tryAutoWithdrawals(lotteryHeight uint32, winnersMap map[string]uint64) {
-	ctx := context.Background()
+// tryAutoWithdrawals attempts to send winners their prizes via lightning addresses. If the address
+// can't be resolved or the payment fails, the prizes are returned so they can be claimed manually.
+//
+// Prizes above the approval threshold are left to be claimed manually, so the operators can review
+// the withdrawal. Payouts whose routing fee is too high are deferred if the prizes, which expire at
+// deadline, are not about to expire. Large payouts without a reliable route are left to be claimed
+// manually.
+func (l *Lottery) tryAutoWithdrawals(ctx context.Context, winnersMap map[string]uint64, deadline uint32) {
+	l.mu.Lock()
+	approvalThreshold := l.approvalThreshold
+	l.mu.Unlock()
 
 	for publicKey, prizes := range winnersMap {
+		if approvalThreshold != 0 && prizes > approvalThreshold {
+			continue
+		}
+
 		address, err := l.db.Lightning.GetAddress(publicKey)
 		if err != nil {
 			if !errors.Is(err, db.ErrNoAddress) {
@@ -220,33 +1005,90 @@ func (l *Lottery) tryAutoWithdrawals(lotteryHeight uint32, winnersMap map[string
 			continue
 		}
 
-		if err := l.db.Prizes.Withdraw(publicKey, prizes); err != nil {
-			l.logger.Error(err)
+		if deadline != 0 && l.deferPayout(ctx, publicKey, address, prizes, deadline) {
 			continue
 		}
 
-		preimage, err := l.lnd.SendToLightningAddress(ctx, address, int64(prizes))
-		if err != nil {
-			l.logger.Error(errors.Wrap(err, "sending to lightning address"))
-
-			winner := db.Winner{
-				PublicKey: publicKey,
-				Prize:     prizes,
-			}
-			if err := l.db.Prizes.Set(lotteryHeight, []db.Winner{winner}); err != nil {
-				l.logger.Error(err)
-			}
+		if l.payoutSchedule.Probed(prizes) && !l.probePayout(ctx, publicKey, address, prizes) {
 			continue
 		}
 
-		message := fmt.Sprintf(notification.AutomaticWithdrawal, prizes, address, preimage)
+		l.autoWithdraw(ctx, publicKey, address, prizes)
+	}
+}
+
+// autoWithdraw sends the winner prizes to the lightning address, returning them if the payment
+// fails. Transient failures are retried before giving up. Public keys the access lists don't allow
+// to withdraw are skipped.
+func (l *Lottery) autoWithdraw(ctx context.Context, publicKey, address string, prizes uint64) {
+	if err := policy.CheckAccess(l.db.AccessLists, l.auditor, publicKey, db.AccessWithdrawals); err != nil {
+		if !errors.Is(err, policy.ErrAccessDenied) {
+			l.logger.Error(err)
+		}
+		return
+	}
+
+	claims, err := l.db.Prizes.Withdraw(publicKey, prizes)
+	if err != nil {
+		l.logger.Error(err)
+		return
+	}
+
+	// Transient failures happen before the payment is sent, so it can be attempted again
+	var preimage string
+	err = fault.Retry(ctx, l.retry, func() error {
+		preimage, err = l.lnd.SendToLightningAddress(ctx, address, int64(prizes))
+		return err
+	})
+	if err != nil {
+		l.logger.Error(errors.Wrap(err, "sending to lightning address"))
+
+		// Fall back to the manual claim flow
+		if err := l.db.Prizes.Restore(claims); err != nil {
+			l.logger.Error(err)
+			return
+		}
+
+		message, ok := l.render(notification.EventWithdrawalFailed, notification.Data{
+			Prize:   prizes,
+			Address: address,
+		})
+		if ok {
+			l.notify(publicKey, message)
+		}
+		return
+	}
+
+	l.auditor.Record(audit.PayoutSent, map[string]any{
+		"public_key": publicKey,
+		"amount":     prizes,
+		"address":    address,
+		"preimage":   preimage,
+	})
+	l.webhooks.Publish(webhooks.PayoutSent, map[string]any{"amount": prizes})
+	if err := l.db.Stats.AddPayout(prizes); err != nil {
+		l.logger.Error(errors.Wrap(err, "updating payout stats"))
+	}
+
+	message, ok := l.render(notification.EventWithdrawal, notification.Data{
+		Prize:    prizes,
+		Address:  address,
+		Preimage: preimage,
+	})
+	if ok {
 		l.notify(publicKey, message)
 	}
 }
 
 // GetInfo returns information about the lottery.
-func GetInfo(ctx context.Context, lnd lightning.Client, db *db.DB) (Info, error) {
-	remoteBalance, err := lnd.RemoteBalance(ctx)
+func GetInfo(
+	ctx context.Context,
+	lnd lightning.NodeInfo,
+	db *db.DB,
+	pools Pools,
+	capacity CapacityOracle,
+) (Info, error) {
+	totalCapacity, err := capacity.Capacity(ctx, lnd, db)
 	if err != nil {
 		return Info{}, err
 	}
@@ -256,16 +1098,34 @@ func GetInfo(ctx context.Context, lnd lightning.Client, db *db.DB) (Info, error)
 		return Info{}, err
 	}
 
-	prizePool, err := db.Bets.GetPrizePool(nextHeight)
+	postponement, err := ActivePostponement(db.Postponements)
 	if err != nil {
 		return Info{}, err
 	}
 
-	return Info{
-		PrizePool:  int64(prizePool),
-		Capacity:   remoteBalance / CapacityDivisor,
-		NextHeight: nextHeight,
-	}, nil
+	info := Info{
+		Postponement: postponement,
+		Pools:        make([]PoolInfo, 0, len(pools)),
+		Capacity:     totalCapacity,
+		NextHeight:   nextHeight,
+	}
+	for _, pool := range pools {
+		prizePool, err := db.Bets.GetPrizePool(nextHeight, pool.Name)
+		if err != nil {
+			return Info{}, err
+		}
+
+		info.Pools = append(info.Pools, PoolInfo{
+			Name:      pool.Name,
+			PrizePool: int64(prizePool),
+			Capacity:  int64(float64(totalCapacity) * pool.Capacity / 100),
+			MinAmount: pool.MinAmount,
+			MaxAmount: pool.MaxAmount,
+		})
+		info.PrizePool += int64(prizePool)
+	}
+
+	return info, nil
 }
 
 // aggregateWinners returns a map with the winners and their prizes aggregated.
@@ -284,67 +1144,47 @@ func aggregateWinners(winners []db.Winner) map[string]uint64 {
 	return winnersMap
 }
 
-// getWinners looks for the target or the closest higher number using the binary search algorithm.
-//
-// The bets slice must be sorted.
-func getWinners(blockHash []byte, prizePool uint64, bets []db.Bet) ([]db.Winner, error) {
-	if len(bets) <= 0 {
-		return nil, nil
-	}
-
-	winners := make([]db.Winner, 0, len(prizes))
-	i := len(blockHash) - 1
-
-	for _, prize := range prizes {
-		winningTicket := getWinningTicket(blockHash, i, prizePool)
-		p := (prize / 100) * float64(prizePool)
-
-		winner := db.Winner{
-			PublicKey: getPublicKey(bets, winningTicket),
-			Ticket:    winningTicket,
-			Prize:     uint64(math.Round(p)),
-		}
-
-		winners = append(winners, winner)
-		i -= 2
+// betTickets returns the ticket ranges of the bets.
+func betTickets(bets []db.Bet) []engine.Tickets {
+	tickets := make([]engine.Tickets, 0, len(bets))
+	for _, bet := range bets {
+		tickets = append(tickets, engine.Tickets{
+			PublicKey: bet.PublicKey,
+			Index:     bet.Index,
+		})
 	}
 
-	return winners, nil
+	return tickets
 }
 
-// getWinningTicket takes two bytes from the latest block hash to get the winning number.
-func getWinningTicket(hash []byte, i int, prizePool uint64) uint64 {
-	num1 := int64(hash[i])
-	num2 := int64(hash[i-1])
-
-	// (num1 ^ num2) % prizePool
-	result := new(big.Int).Exp(
-		big.NewInt(num1),
-		big.NewInt(num2),
-		big.NewInt(int64(prizePool)),
-	)
-
-	// Add one so the index zero is not taken into account and the last one is
-	return result.Uint64() + 1
-}
-
-func getPublicKey(bets []db.Bet, winningTicket uint64) string {
-	left, mid, right := 0, 0, len(bets)-1
-	for left <= right {
-		mid = (left + right) / 2
+// getWinners draws the winners of a lottery pool.
+//
+// The bets slice must be sorted.
+func getWinners(
+	seed []byte,
+	bets []db.Bet,
+	distribution engine.Distribution,
+	rounding engine.Rounding,
+	collision engine.Collision,
+	hooks ...engine.Hook,
+) ([]db.Winner, error) {
+	draw, err := engine.Draw(seed, betTickets(bets), distribution, rounding, collision, hooks...)
+	if err != nil {
+		return nil, err
+	}
 
-		i := bets[mid].Index
-		if i == winningTicket {
-			return bets[mid].PublicKey
-		}
-		if i < winningTicket {
-			left = mid + 1
-			continue
-		}
+	if draw == nil {
+		return nil, nil
+	}
 
-		right = mid - 1
+	winners := make([]db.Winner, 0, len(draw))
+	for _, winner := range draw {
+		winners = append(winners, db.Winner{
+			PublicKey: winner.PublicKey,
+			Ticket:    winner.Ticket,
+			Prize:     winner.Prize,
+		})
 	}
 
-	// The left ends up being the higher value of the two, hence that user has the winning ticket
-	return bets[left].PublicKey
+	return winners, nil
 }
@@ -1,6 +1,7 @@
 package lottery
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/hex"
@@ -8,19 +9,31 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/aftermath2/BTRY/audit"
 	"github.com/aftermath2/BTRY/config"
 	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/fault"
+	"github.com/aftermath2/BTRY/jobs"
+	"github.com/aftermath2/BTRY/leader"
 	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/lottery/engine"
 	"github.com/aftermath2/BTRY/notification"
+	"github.com/aftermath2/BTRY/watchdog"
+	"github.com/aftermath2/BTRY/webhooks"
 
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	_ "modernc.org/sqlite"
 )
 
+var templates = notification.DefaultTemplates()
+
 var bets = []db.Bet{
 	{
 		Index:     427_224,
@@ -47,20 +60,30 @@ func TestStart(t *testing.T) {
 		Duration: blocksDuration,
 	}
 
-	prizesMock := db.NewPrizesStoreMock()
-	prizesMock.On("Expire", nextHeight-(config.Duration*5)).Return(uint64(0), nil)
-
 	betsMock := db.NewBetsStoreMock()
-	betsMock.On("List", nextHeight, uint64(0), uint64(0), false).Return([]db.Bet{}, nil)
+	betsMock.On("ListPools", nextHeight).Return([]string{}, nil)
+	betsMock.On("Compact", nextHeight).Return(uint64(0), nil).Maybe()
 
 	lotteryMock := db.NewLotteriesStoreMock()
 	lotteryMock.On("GetNextHeight").Return(nextHeight, nil)
-	lotteryMock.On("AddHeight", nextHeight+blocksDuration).Return(nil)
+	lotteryMock.On("AddHeight", nextHeight+blocksDuration, mock.Anything, mock.Anything).Return(nil)
+	lotteryMock.On("IsDrawn", nextHeight).Return(false, nil).Maybe()
+
+	claimCodesMock := db.NewClaimCodesStoreMock()
+	claimCodesMock.On("DeleteExpired", mock.Anything).Return(uint64(0), nil).Maybe()
+
+	winnersMock := db.NewWinnersStoreMock()
+	winnersMock.On("ListUnclaimed").Return(nil, nil).Maybe()
+
+	revealsMock := db.NewRevealsStoreMock()
+	revealsMock.On("ListPending").Return(nil, nil).Maybe()
 
 	db := &db.DB{
-		Bets:      betsMock,
-		Lotteries: lotteryMock,
-		Prizes:    prizesMock,
+		Bets:       betsMock,
+		ClaimCodes: claimCodesMock,
+		Lotteries:  lotteryMock,
+		Reveals:    revealsMock,
+		Winners:    winnersMock,
 	}
 
 	lnd := lightning.NewClientMock()
@@ -68,8 +91,17 @@ func TestStart(t *testing.T) {
 	lnd.On("GetInfo", context.Background()).Return(info, nil)
 
 	blocksCh := make(chan *chainrpc.BlockEpoch)
+	watchdogMock := watchdog.NewWatchdogMock()
+	watchdogMock.On("Observe", nextHeight).Maybe()
+	watchdogMock.On("Verify", mock.Anything, nextHeight, mock.Anything).Return(nil).Maybe()
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("PublishCommitment", nextHeight+blocksDuration, mock.Anything).Return(nil).Maybe()
+	queueMock := newQueueMock()
+	queueMock.On("Enqueue", jobExpirePrizes, expirePrizesJob{Height: nextHeight}).Return(nil).Maybe()
+	queueMock.On("Enqueue", jobRetryDeliveries, struct{}{}).Return(nil).Maybe()
 
-	lottery, err := New(config, db, lnd, nil, nil, blocksCh)
+	lottery, err := New(config, db, lnd, notifierMock, templates, nil, watchdogMock, newLeaderMock(), queueMock,
+		nil, nil, blocksCh)
 	assert.NoError(t, err)
 
 	go func() {
@@ -85,6 +117,147 @@ func TestStart(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestStartWatchdogPostponesDraw(t *testing.T) {
+	nextHeight := uint32(900_000)
+	config := config.Lottery{Duration: 144}
+	drawn := make(chan struct{})
+
+	betsMock := db.NewBetsStoreMock()
+	betsMock.On("ListPools", nextHeight).Return([]string{}, nil).Once()
+	betsMock.On("Compact", nextHeight).Return(uint64(0), nil).
+		Run(func(mock.Arguments) { close(drawn) })
+	lotteryMock := db.NewLotteriesStoreMock()
+	lotteryMock.On("GetNextHeight").Return(nextHeight, nil)
+	lotteryMock.On("AddHeight", nextHeight+config.Duration, mock.Anything, mock.Anything).Return(nil)
+	lotteryMock.On("IsDrawn", nextHeight).Return(false, nil).Once()
+	claimCodesMock := db.NewClaimCodesStoreMock()
+	claimCodesMock.On("DeleteExpired", mock.Anything).Return(uint64(0), nil).Maybe()
+	winnersMock := db.NewWinnersStoreMock()
+	winnersMock.On("ListUnclaimed").Return(nil, nil)
+	revealsMock := db.NewRevealsStoreMock()
+	revealsMock.On("ListPending").Return(nil, nil)
+	db := &db.DB{
+		Bets:       betsMock,
+		ClaimCodes: claimCodesMock,
+		Lotteries:  lotteryMock,
+		Reveals:    revealsMock,
+		Winners:    winnersMock,
+	}
+
+	lnd := lightning.NewClientMock()
+	lnd.On("GetInfo", context.Background()).Return(&lnrpc.GetInfoResponse{BlockHeight: nextHeight - 1}, nil)
+
+	hash := "000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6"
+	watchdogMock := watchdog.NewWatchdogMock()
+	watchdogMock.On("Observe", mock.Anything)
+	watchdogMock.On("Verify", mock.Anything, nextHeight, hash).Return(watchdog.ErrStalled).Once()
+	watchdogMock.On("Verify", mock.Anything, nextHeight, hash).Return(nil).Once()
+
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("PublishCommitment", nextHeight+config.Duration, mock.Anything).Return(nil)
+	queueMock := newQueueMock()
+	queueMock.On("Enqueue", jobExpirePrizes, expirePrizesJob{Height: nextHeight}).Return(nil).Once()
+	queueMock.On("Enqueue", jobRetryDeliveries, struct{}{}).Return(nil).Maybe()
+
+	blocksCh := make(chan *chainrpc.BlockEpoch)
+	lottery, err := New(config, db, lnd, notifierMock, templates, nil, watchdogMock, newLeaderMock(), queueMock,
+		nil, nil, blocksCh)
+	assert.NoError(t, err)
+
+	err = lottery.Start()
+	assert.NoError(t, err)
+
+	// Block hash bytes are reversed in the epochs
+	blockHash, err := hex.DecodeString(hash)
+	assert.NoError(t, err)
+	slices.Reverse(blockHash)
+
+	blocksCh <- &chainrpc.BlockEpoch{Hash: blockHash, Height: nextHeight}
+	betsMock.AssertNotCalled(t, "ListPools", nextHeight)
+
+	// The postponed draw is retried with the target block when the next one arrives
+	blocksCh <- &chainrpc.BlockEpoch{Hash: []byte{1}, Height: nextHeight + 1}
+
+	select {
+	case <-drawn:
+	case <-time.After(time.Second):
+		t.Fatal("lottery was not drawn")
+	}
+	watchdogMock.AssertExpectations(t)
+	betsMock.AssertExpectations(t)
+	queueMock.AssertExpectations(t)
+}
+
+func TestStartRetriesTransientDraw(t *testing.T) {
+	nextHeight := uint32(900_000)
+	config := config.Lottery{Duration: 144}
+	drawn := make(chan struct{})
+
+	betsMock := db.NewBetsStoreMock()
+	betsMock.On("ListPools", nextHeight).Return([]string(nil), fault.NewTransient(errors.New("database is locked"))).Once()
+	betsMock.On("ListPools", nextHeight).Return([]string{}, nil).Once()
+	betsMock.On("Compact", nextHeight).Return(uint64(0), nil).
+		Run(func(mock.Arguments) { close(drawn) })
+	lotteryMock := db.NewLotteriesStoreMock()
+	lotteryMock.On("GetNextHeight").Return(nextHeight, nil)
+	lotteryMock.On("AddHeight", nextHeight+config.Duration, mock.Anything, mock.Anything).Return(nil)
+	lotteryMock.On("IsDrawn", nextHeight).Return(false, nil).Twice()
+	claimCodesMock := db.NewClaimCodesStoreMock()
+	claimCodesMock.On("DeleteExpired", mock.Anything).Return(uint64(0), nil).Maybe()
+	winnersMock := db.NewWinnersStoreMock()
+	winnersMock.On("ListUnclaimed").Return(nil, nil)
+	revealsMock := db.NewRevealsStoreMock()
+	revealsMock.On("ListPending").Return(nil, nil)
+	db := &db.DB{
+		Bets:       betsMock,
+		ClaimCodes: claimCodesMock,
+		Lotteries:  lotteryMock,
+		Reveals:    revealsMock,
+		Winners:    winnersMock,
+	}
+
+	lnd := lightning.NewClientMock()
+	lnd.On("GetInfo", context.Background()).Return(&lnrpc.GetInfoResponse{BlockHeight: nextHeight - 1}, nil)
+
+	hash := "000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6"
+	watchdogMock := watchdog.NewWatchdogMock()
+	watchdogMock.On("Observe", mock.Anything)
+	watchdogMock.On("Verify", mock.Anything, nextHeight, hash).Return(nil).Twice()
+
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("PublishCommitment", nextHeight+config.Duration, mock.Anything).Return(nil)
+	queueMock := newQueueMock()
+	queueMock.On("Enqueue", jobExpirePrizes, expirePrizesJob{Height: nextHeight}).Return(nil)
+	queueMock.On("Enqueue", jobRetryDeliveries, struct{}{}).Return(nil).Maybe()
+
+	blocksCh := make(chan *chainrpc.BlockEpoch)
+	lottery, err := New(config, db, lnd, notifierMock, templates, nil, watchdogMock, newLeaderMock(), queueMock,
+		nil, nil, blocksCh)
+	assert.NoError(t, err)
+
+	err = lottery.Start()
+	assert.NoError(t, err)
+
+	// Block hash bytes are reversed in the epochs
+	blockHash, err := hex.DecodeString(hash)
+	assert.NoError(t, err)
+	slices.Reverse(blockHash)
+
+	blocksCh <- &chainrpc.BlockEpoch{Hash: blockHash, Height: nextHeight}
+
+	// The draw that failed transiently is retried with the target block when the next one arrives
+	blocksCh <- &chainrpc.BlockEpoch{Hash: []byte{1}, Height: nextHeight + 1}
+
+	select {
+	case <-drawn:
+	case <-time.After(time.Second):
+		t.Fatal("lottery was not drawn")
+	}
+	lotteryMock.AssertExpectations(t)
+	betsMock.AssertExpectations(t)
+	watchdogMock.AssertExpectations(t)
+}
+
 func TestStartNoNextHeight(t *testing.T) {
 	nextHeight := uint32(0)
 	blockHeight := uint32(843_204)
@@ -94,9 +267,13 @@ func TestStartNoNextHeight(t *testing.T) {
 		Duration: blocksDuration,
 	}
 
+	var seed, commitment string
 	lotteryMock := db.NewLotteriesStoreMock()
 	lotteryMock.On("GetNextHeight").Return(nextHeight, nil)
-	lotteryMock.On("AddHeight", blockHeight+blocksDuration).Return(nil)
+	lotteryMock.On("AddHeight", blockHeight+blocksDuration, mock.Anything, mock.Anything).Return(nil).
+		Run(func(args mock.Arguments) {
+			seed, commitment = args.String(1), args.String(2)
+		})
 	db := &db.DB{
 		Lotteries: lotteryMock,
 	}
@@ -105,11 +282,21 @@ func TestStartNoNextHeight(t *testing.T) {
 	info := &lnrpc.GetInfoResponse{BlockHeight: blockHeight}
 	lnd.On("GetInfo", context.Background()).Return(info, nil)
 
-	lottery, err := New(config, db, lnd, nil, nil, nil)
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("PublishCommitment", blockHeight+blocksDuration, mock.Anything).Return(nil)
+
+	lottery, err := New(config, db, lnd, notifierMock, templates, nil, nil, newLeaderMock(), newQueueMock(), nil, nil, nil)
 	assert.NoError(t, err)
 
 	err = lottery.Start()
 	assert.NoError(t, err)
+
+	// The commitment published is the hash of the server seed stored
+	serverSeed, err := hex.DecodeString(seed)
+	assert.NoError(t, err)
+	assert.Len(t, serverSeed, 32)
+	assert.Equal(t, hex.EncodeToString(engine.Commitment(serverSeed)), commitment)
+	notifierMock.AssertCalled(t, "PublishCommitment", blockHeight+blocksDuration, commitment)
 }
 
 func TestStartPastHeight(t *testing.T) {
@@ -123,9 +310,44 @@ func TestStartPastHeight(t *testing.T) {
 
 	lotteryMock := db.NewLotteriesStoreMock()
 	lotteryMock.On("GetNextHeight").Return(nextHeight, nil)
-	lotteryMock.On("AddHeight", blockHeight+blocksDuration).Return(nil)
+	lotteryMock.On("AddHeight", blockHeight+blocksDuration, mock.Anything, mock.Anything).Return(nil)
+	lotteryMock.On("DeleteHeight", nextHeight).Return(nil)
+	db := &db.DB{
+		Lotteries: lotteryMock,
+	}
+
+	lnd := lightning.NewClientMock()
+	info := &lnrpc.GetInfoResponse{BlockHeight: blockHeight}
+	lnd.On("GetInfo", context.Background()).Return(info, nil)
+
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("PublishCommitment", blockHeight+blocksDuration, mock.Anything).Return(nil)
+
+	lottery, err := New(config, db, lnd, notifierMock, templates, nil, nil, newLeaderMock(), newQueueMock(), nil, nil, nil)
+	assert.NoError(t, err)
+
+	err = lottery.Start()
+	assert.NoError(t, err)
+}
+
+func TestStartPastHeightMoveBets(t *testing.T) {
+	nextHeight := uint32(843_199)
+	blockHeight := uint32(843_204)
+	blocksDuration := uint32(144)
+
+	config := config.Lottery{
+		Duration:      blocksDuration,
+		DeadManSwitch: config.DeadManSwitch{Enabled: true, MaxMissedHeights: 1},
+	}
+
+	lotteryMock := db.NewLotteriesStoreMock()
+	lotteryMock.On("GetNextHeight").Return(nextHeight, nil)
+	lotteryMock.On("AddHeight", blockHeight+blocksDuration, mock.Anything, mock.Anything).Return(nil)
 	lotteryMock.On("DeleteHeight", nextHeight).Return(nil)
+	betsMock := db.NewBetsStoreMock()
+	betsMock.On("Move", nextHeight, blockHeight+blocksDuration, mock.Anything).Return(nil)
 	db := &db.DB{
+		Bets:      betsMock,
 		Lotteries: lotteryMock,
 	}
 
@@ -133,40 +355,285 @@ func TestStartPastHeight(t *testing.T) {
 	info := &lnrpc.GetInfoResponse{BlockHeight: blockHeight}
 	lnd.On("GetInfo", context.Background()).Return(info, nil)
 
-	lottery, err := New(config, db, lnd, nil, nil, nil)
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("PublishCommitment", blockHeight+blocksDuration, mock.Anything).Return(nil)
+
+	lottery, err := New(config, db, lnd, notifierMock, templates, audit.NewAuditorMock(), nil, newLeaderMock(),
+		newQueueMock(), nil, nil, nil)
+	assert.NoError(t, err)
+
+	err = lottery.Start()
+	assert.NoError(t, err)
+
+	betsMock.AssertExpectations(t)
+	lotteryMock.AssertExpectations(t)
+}
+
+func TestStartDeadManSwitch(t *testing.T) {
+	missedHeight := uint32(843_199)
+	blockHeight := missedHeight + 144*3
+	nextHeight := blockHeight + 144
+
+	db := setupDB(t, func(db *sql.DB) {
+		_, err := db.Exec("INSERT INTO lotteries (height) VALUES (?)", missedHeight)
+		assert.NoError(t, err)
+
+		query := `INSERT INTO bets (idx, tickets, bonus, public_key, lottery_height) VALUES
+		(?,?,0,?,?), (?,?,0,?,?), (?,?,?,?,?)`
+		_, err = db.Exec(query,
+			bets[0].Index, bets[0].Tickets, bets[0].PublicKey, missedHeight,
+			bets[1].Index, bets[1].Tickets, bets[1].PublicKey, missedHeight,
+			// Bet of the first player with 10,000 bonus tickets
+			bets[1].Index+110_000, 110_000, 10_000, bets[0].PublicKey, missedHeight,
+		)
+		assert.NoError(t, err)
+	})
+
+	lnd := lightning.NewClientMock()
+	info := &lnrpc.GetInfoResponse{BlockHeight: blockHeight}
+	lnd.On("GetInfo", context.Background()).Return(info, nil)
+
+	auditorMock := audit.NewAuditorMock()
+	auditorMock.On("Record", audit.BetsRefunded, map[string]any{
+		"lottery_height": missedHeight,
+		"amount":         bets[0].Tickets + bets[1].Tickets + 100_000,
+		"bets":           3,
+	})
+
+	config := config.Lottery{
+		Duration:      144,
+		DeadManSwitch: config.DeadManSwitch{Enabled: true, MaxMissedHeights: 2},
+	}
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("PublishCommitment", nextHeight, mock.Anything).Return(nil)
+
+	lottery, err := New(config, db, lnd, notifierMock, templates, auditorMock, nil, newLeaderMock(), newQueue(t, db), nil,
+		nil, nil)
+	assert.NoError(t, err)
+
+	err = lottery.Start()
+	assert.NoError(t, err)
+
+	auditorMock.AssertExpectations(t)
+	// A refund notification per player and the automatic withdrawals
+	assert.Equal(t, 3, runJobs(t, lottery, db))
+
+	height, err := db.Lotteries.GetNextHeight()
+	assert.NoError(t, err)
+	assert.Equal(t, nextHeight, height)
+
+	prizes, err := db.Prizes.Get(bets[0].PublicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, bets[0].Tickets+100_000, prizes)
+
+	prizes, err = db.Prizes.Get(bets[1].PublicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, bets[1].Tickets, prizes)
+
+	// Refunds expire with the prizes of the new lottery
+	expired, err := db.Prizes.Expire(nextHeight - 1)
+	assert.NoError(t, err)
+	assert.Zero(t, expired)
+}
+
+func TestStartOverlap(t *testing.T) {
+	drawHeight := uint32(900_000)
+	config := config.Lottery{Frequency: time.Hour, Overlap: 2}
+	// The next lottery was opened while the one at drawHeight awaits its draw
+	nextHeight := drawHeight + 6
+	opened := make(chan struct{})
+	drawn := make(chan struct{})
+
+	betsMock := db.NewBetsStoreMock()
+	betsMock.On("ListPools", drawHeight).Return([]string{}, nil).Once()
+	betsMock.On("ListPools", nextHeight).Return([]string{}, nil).Once()
+	betsMock.On("Compact", drawHeight).Return(uint64(0), nil).Once()
+	betsMock.On("Compact", nextHeight).Return(uint64(0), nil).Once().
+		Run(func(mock.Arguments) { close(drawn) })
+	lotteryMock := db.NewLotteriesStoreMock()
+	lotteryMock.On("GetNextHeight").Return(nextHeight, nil)
+	lotteryMock.On("ListUndrawn", drawHeight).Return([]uint32{drawHeight, nextHeight}, nil)
+	lotteryMock.On("AddHeight", nextHeight+6, mock.Anything, mock.Anything).Return(nil).Once().
+		Run(func(mock.Arguments) { close(opened) })
+	lotteryMock.On("IsDrawn", mock.Anything).Return(false, nil)
+	claimCodesMock := db.NewClaimCodesStoreMock()
+	claimCodesMock.On("DeleteExpired", mock.Anything).Return(uint64(0), nil)
+	winnersMock := db.NewWinnersStoreMock()
+	winnersMock.On("ListUnclaimed").Return(nil, nil)
+	revealsMock := db.NewRevealsStoreMock()
+	revealsMock.On("ListPending").Return(nil, nil)
+	growthMock := db.NewGrowthStoreMock()
+	growthMock.On("Sample", mock.Anything, mock.Anything, mock.Anything).Return(db.GrowthSample{}, nil)
+	db := &db.DB{
+		Bets:       betsMock,
+		ClaimCodes: claimCodesMock,
+		Growth:     growthMock,
+		Lotteries:  lotteryMock,
+		Reveals:    revealsMock,
+		Winners:    winnersMock,
+	}
+
+	lnd := lightning.NewClientMock()
+	lnd.On("GetInfo", context.Background()).Return(&lnrpc.GetInfoResponse{BlockHeight: drawHeight - 1}, nil)
+
+	watchdogMock := watchdog.NewWatchdogMock()
+	watchdogMock.On("Observe", mock.Anything)
+	watchdogMock.On("Verify", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("PublishCommitment", nextHeight+6, mock.Anything).Return(nil)
+	queueMock := newQueueMock()
+	queueMock.On("Enqueue", jobExpirePrizes, mock.Anything).Return(nil)
+	queueMock.On("Enqueue", jobRetryDeliveries, struct{}{}).Return(nil).Maybe()
+
+	blocksCh := make(chan *chainrpc.BlockEpoch)
+	lottery, err := New(config, db, lnd, notifierMock, templates, nil, watchdogMock, newLeaderMock(), queueMock,
+		nil, nil, blocksCh)
 	assert.NoError(t, err)
 
 	err = lottery.Start()
 	assert.NoError(t, err)
+
+	blocksCh <- &chainrpc.BlockEpoch{Hash: []byte{1}, Height: drawHeight}
+	// The lottery after the next one opens overlap blocks before the next one is drawn
+	blocksCh <- &chainrpc.BlockEpoch{Hash: []byte{2}, Height: nextHeight - 3}
+	lotteryMock.AssertNotCalled(t, "AddHeight", mock.Anything, mock.Anything, mock.Anything)
+	blocksCh <- &chainrpc.BlockEpoch{Hash: []byte{3}, Height: nextHeight - 2}
+
+	select {
+	case <-opened:
+	case <-time.After(time.Second):
+		t.Fatal("lottery was not opened")
+	}
+
+	blocksCh <- &chainrpc.BlockEpoch{Hash: []byte{4}, Height: nextHeight}
+
+	select {
+	case <-drawn:
+	case <-time.After(time.Second):
+		t.Fatal("lottery was not drawn")
+	}
+	betsMock.AssertExpectations(t)
+	lotteryMock.AssertExpectations(t)
+	// The prize pool sampled is the one of the latest lottery opened, where bets are placed
+	growthMock.AssertCalled(t, "Sample", nextHeight, drawHeight, mock.Anything)
+	growthMock.AssertCalled(t, "Sample", nextHeight+6, nextHeight, mock.Anything)
+}
+
+func TestSkipMissedLotteriesOverlap(t *testing.T) {
+	missedHeight := uint32(900_000)
+	nextHeight := missedHeight + 6
+
+	config := config.Lottery{
+		Duration:      6,
+		Overlap:       2,
+		DeadManSwitch: config.DeadManSwitch{Enabled: true, MaxMissedHeights: 1},
+	}
+
+	lotteryMock := db.NewLotteriesStoreMock()
+	lotteryMock.On("DeleteHeight", missedHeight).Return(nil)
+	betsMock := db.NewBetsStoreMock()
+	betsMock.On("Move", missedHeight, nextHeight, mock.Anything).Return(nil)
+	db := &db.DB{Bets: betsMock, Lotteries: lotteryMock}
+
+	lottery, err := New(config, db, nil, nil, templates, audit.NewAuditorMock(), nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	// The bets of the missed lottery are moved to the one already open
+	pending, err := lottery.skipMissedLotteries([]uint32{missedHeight, nextHeight}, missedHeight+1)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{nextHeight}, pending)
+
+	lotteryMock.AssertNotCalled(t, "AddHeight", mock.Anything, mock.Anything, mock.Anything)
+	betsMock.AssertExpectations(t)
+	lotteryMock.AssertExpectations(t)
+}
+
+func TestOpenRound(t *testing.T) {
+	lotteryMock := db.NewLotteriesStoreMock()
+	lotteryMock.On("AddHeight", uint32(150), mock.Anything, mock.Anything).Return(nil)
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("PublishCommitment", uint32(150), mock.Anything).Return(nil)
+	db := &db.DB{Lotteries: lotteryMock}
+
+	lottery, err := New(config.Lottery{}, db, nil, notifierMock, templates, nil, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	lottery.roundPools[144] = lottery.pools
+
+	distribution := []float64{60, 30}
+	lottery.Reload(config.Lottery{
+		Pools: []config.Pool{{Name: "micro", Capacity: 100, Distribution: distribution}},
+	})
+
+	err = lottery.openRound(150)
+	assert.NoError(t, err)
+
+	// The lottery awaiting its draw keeps the prize table it opened with
+	assert.Equal(t, engine.DefaultDistribution, lottery.roundPools[144].Distribution("micro"))
+	assert.Equal(t, engine.Distribution(distribution), lottery.roundPools[150].Distribution("micro"))
+}
+
+func TestOpenSeed(t *testing.T) {
+	seed := bytes.Repeat([]byte{7}, 32)
+	commitment := hex.EncodeToString(engine.Commitment(seed))
+	lotteryMock := db.NewLotteriesStoreMock()
+	lotteryMock.On("AddHeight", uint32(150), hex.EncodeToString(seed), commitment).Return(nil)
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("PublishCommitment", uint32(150), commitment).Return(nil)
+
+	lottery, err := New(config.Lottery{}, &db.DB{Lotteries: lotteryMock}, nil, notifierMock, templates, nil,
+		nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	lottery.random = bytes.NewReader(seed)
+
+	assert.NoError(t, lottery.open(150))
+	lotteryMock.AssertExpectations(t)
+	notifierMock.AssertExpectations(t)
+
+	// The source ran out of randomness
+	assert.Error(t, lottery.open(151))
 }
 
 func TestRaffle(t *testing.T) {
 	blockHeight := uint32(833348)
-	winnersCh := make(chan []db.Winner)
+	serverSeed := "6b1d6b1f9ac7a0a5a6b8d2e0c3f4e5d6c7b8a9f0e1d2c3b4a5968778695a4b3c"
+	commitment := "commitment"
+	winnersHub := NewWinnersHub(config.WinnersHub{})
 	blocksCh := make(<-chan *chainrpc.BlockEpoch)
 	db := setupDB(t, func(db *sql.DB) {
-		query := "INSERT INTO bets (idx, tickets, public_key, lottery_height) VALUES (?,?,?,?), (?,?,?,?)"
-		_, err := db.Exec(query,
+		query := "INSERT INTO lotteries (height, seed, commitment) VALUES (?,?,?)"
+		_, err := db.Exec(query, blockHeight, serverSeed, commitment)
+		assert.NoError(t, err)
+
+		query = "INSERT INTO bets (idx, tickets, public_key, lottery_height) VALUES (?,?,?,?), (?,?,?,?)"
+		_, err = db.Exec(query,
 			bets[0].Index, bets[0].Tickets, bets[0].PublicKey, blockHeight,
 			bets[1].Index, bets[1].Tickets, bets[1].PublicKey, blockHeight,
 		)
 		assert.NoError(t, err)
 	})
 	notifierMock := notification.NewNotifierMock()
-
-	lottery, err := New(config.Lottery{}, db, nil, notifierMock, winnersCh, blocksCh)
+	auditorMock := audit.NewAuditorMock()
+	auditorMock.On("Record", audit.DrawExecuted, mock.MatchedBy(func(draw map[string]any) bool {
+		return draw["server_seed"] == serverSeed && draw["commitment"] == commitment &&
+			draw["collision"] == engine.CollisionStack
+	})).Once()
+	auditorMock.On("Record", audit.PrizeAssigned, mock.Anything).Times(len(engine.DefaultDistribution))
+	webhooksMock := webhooks.NewPublisherMock()
+	webhooksMock.On("Publish", webhooks.DrawCompleted, mock.MatchedBy(func(data map[string]any) bool {
+		return data["lottery_height"] == blockHeight && data["prize_pool"] == bets[1].Index
+	})).Once()
+	webhooksMock.On("Publish", webhooks.WinnerAnnounced, mock.Anything).Times(len(engine.DefaultDistribution))
+
+	config := config.Lottery{Duration: 144, Collision: string(engine.CollisionStack)}
+	lottery, err := New(config, db, nil, notifierMock, templates, auditorMock, nil, nil, newQueue(t, db), webhooksMock, winnersHub, blocksCh)
 	assert.NoError(t, err)
 
-	prizePool, err := db.Bets.GetPrizePool(blockHeight)
+	subscription := winnersHub.Subscribe()
+
+	prizePool, err := db.Bets.GetPrizePool(blockHeight, "")
 	assert.NoError(t, err)
 
-	t.Run("Winners were sent through the channel", func(t *testing.T) {
-		go func() {
-			winners := <-winnersCh
-			notifierMock.On("PublishWinners", blockHeight, winners).Return(nil)
-			assert.Len(t, winners, len(prizes))
-		}()
-	})
+	notifierMock.On("PublishWinners", blockHeight, mock.Anything).Return(nil)
 
 	blockHash, err := hex.DecodeString("000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6")
 	assert.NoError(t, err)
@@ -179,8 +646,47 @@ func TestRaffle(t *testing.T) {
 	err = lottery.raffle(block)
 	assert.NoError(t, err)
 
+	auditorMock.AssertExpectations(t)
+	webhooksMock.AssertExpectations(t)
+	notifierMock.AssertNotCalled(t, "PublishWinners", blockHeight, mock.Anything)
+
+	timings, err := db.DrawTimings.List(0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, timings, 1)
+	assert.Equal(t, blockHeight, timings[0].LotteryHeight)
+	assert.Equal(t, uint64(len(bets[:2])), timings[0].Bets)
+	assert.GreaterOrEqual(t, timings[0].Total,
+		timings[0].BetsListing+timings[0].Winners+timings[0].DBWrites+timings[0].Notifications)
+
+	t.Run("Side effects were enqueued", func(t *testing.T) {
+		// Prizes expiry, congratulations to the two winners, statistics, fairness report,
+		// automatic withdrawals and publication
+		assert.Equal(t, 7, runJobs(t, lottery, db))
+		notifierMock.AssertCalled(t, "PublishWinners", blockHeight, mock.Anything)
+	})
+
+	t.Run("Winners were published", func(t *testing.T) {
+		winners := <-subscription.C()
+		assert.Len(t, winners, len(engine.DefaultDistribution))
+	})
+
+	t.Run("Bets were archived", func(t *testing.T) {
+		archived, err := db.BetArchives.Get(blockHeight)
+		assert.NoError(t, err)
+		assert.Len(t, archived, 2)
+		assert.Equal(t, bets[0].PublicKey, archived[0].PublicKey)
+		assert.Equal(t, bets[1].Index, archived[1].Index)
+	})
+
+	t.Run("Block hash and collision policy were stored", func(t *testing.T) {
+		commitment, err := db.Lotteries.GetCommitment(blockHeight)
+		assert.NoError(t, err)
+		assert.Equal(t, hex.EncodeToString(blockHash), commitment.BlockHash)
+		assert.Equal(t, string(engine.CollisionStack), commitment.Collision)
+	})
+
 	t.Run("Bets weren't reset", func(t *testing.T) {
-		bets, err := db.Bets.List(blockHeight, 0, 0, false)
+		bets, err := db.Bets.List(blockHeight, "", 0, 0, false)
 		assert.NoError(t, err)
 
 		assert.Len(t, bets, 2)
@@ -190,24 +696,212 @@ func TestRaffle(t *testing.T) {
 		winners, err := db.Winners.List(block.Height)
 		assert.NoError(t, err)
 
-		assert.Equal(t, len(winners), len(prizes))
+		assert.Equal(t, len(winners), len(engine.DefaultDistribution))
 
 		givenPrizes := uint64(0)
 		for _, winner := range winners {
+			assert.Equal(t, block.Height+config.ClaimWindowBlocks(), winner.ClaimDeadline)
 			givenPrizes += winner.Prize
 			if winner.PublicKey != bets[0].PublicKey && winner.PublicKey != bets[1].PublicKey {
 				assert.Failf(t, "A winner that did not have bets was assigned: %s", winner.PublicKey)
 			}
 		}
 
-		fee := float64(prizePool) * (btryFee / 100)
+		fee := float64(prizePool) * (engine.DefaultDistribution.Fee() / 100)
 		assert.Equal(t, math.Round(float64(prizePool)-fee), float64(givenPrizes))
 	})
+
+	t.Run("Winners match the engine draw", func(t *testing.T) {
+		seed, err := hex.DecodeString(serverSeed)
+		assert.NoError(t, err)
+
+		round := engine.Round{
+			ServerSeed: seed,
+			BlockHash:  blockHash,
+			Collision:  engine.CollisionStack,
+			Pools: []engine.Pool{
+				{Distribution: engine.DefaultDistribution, Tickets: betTickets(bets[:2])},
+			},
+		}
+		expected, err := round.Draw()
+		assert.NoError(t, err)
+
+		winners, err := db.Winners.List(block.Height)
+		assert.NoError(t, err)
+		for i, winner := range winners {
+			assert.Equal(t, expected[""][i].PublicKey, winner.PublicKey)
+			assert.Equal(t, expected[""][i].Ticket, winner.Ticket)
+			assert.Equal(t, expected[""][i].Prize, winner.Prize)
+		}
+	})
+
+	t.Run("Stats were updated", func(t *testing.T) {
+		rounds, err := db.Stats.ListRounds(0, 0, false)
+		assert.NoError(t, err)
+
+		assert.Len(t, rounds, 1)
+		assert.Equal(t, blockHeight, rounds[0].Height)
+		assert.Equal(t, prizePool, rounds[0].PrizePool)
+		assert.Equal(t, uint64(2), rounds[0].Players)
+	})
+
+	t.Run("Fairness report was stored", func(t *testing.T) {
+		reports, err := db.Fairness.List(0, 0, false)
+		assert.NoError(t, err)
+
+		assert.Len(t, reports, 1)
+		assert.Equal(t, blockHeight, reports[0].LotteryHeight)
+		assert.Equal(t, uint64(2), reports[0].Players)
+		assert.Len(t, reports[0].WinningPositions, len(engine.DefaultDistribution))
+		for _, position := range reports[0].WinningPositions {
+			assert.Equal(t, prizePool, position.PrizePool)
+			assert.LessOrEqual(t, position.Position, float64(1))
+		}
+	})
+
+	t.Run("Block delivered twice is not drawn again", func(t *testing.T) {
+		err := lottery.raffle(block)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 0, runJobs(t, lottery, db))
+		winners, err := db.Winners.List(block.Height)
+		assert.NoError(t, err)
+		assert.Len(t, winners, len(engine.DefaultDistribution))
+		auditorMock.AssertExpectations(t)
+	})
+}
+
+func TestRaffleAdaptiveTiers(t *testing.T) {
+	blockHeight := uint32(833348)
+	db := setupDB(t, func(db *sql.DB) {
+		query := "INSERT INTO lotteries (height, seed, commitment) VALUES (?,?,?)"
+		_, err := db.Exec(query, blockHeight, "6b1d6b1f", "commitment")
+		assert.NoError(t, err)
+
+		query = "INSERT INTO bets (idx, tickets, public_key, lottery_height) VALUES (?,?,?,?), (?,?,?,?)"
+		_, err = db.Exec(query,
+			bets[0].Index, bets[0].Tickets, bets[0].PublicKey, blockHeight,
+			bets[1].Index, bets[1].Tickets, bets[1].PublicKey, blockHeight,
+		)
+		assert.NoError(t, err)
+	})
+	auditorMock := audit.NewAuditorMock()
+	auditorMock.On("Record", audit.DrawExecuted, mock.MatchedBy(func(draw map[string]any) bool {
+		return draw["participants"] == uint64(2) && draw["tiers"] == 3
+	})).Once()
+	auditorMock.On("Record", audit.PrizeAssigned, mock.Anything).Times(3)
+	webhooksMock := webhooks.NewPublisherMock()
+	webhooksMock.On("Publish", mock.Anything, mock.Anything)
+
+	config := config.Lottery{
+		Duration: 144,
+		AdaptiveTiers: []config.AdaptiveTier{
+			{Participants: 0, Tiers: 3},
+			{Participants: 100, Tiers: 8},
+		},
+	}
+	lottery, err := New(config, db, nil, nil, templates, auditorMock, nil, nil, newQueue(t, db), webhooksMock,
+		NewWinnersHub(config.WinnersHub), nil)
+	assert.NoError(t, err)
+
+	blockHash, err := hex.DecodeString("000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6")
+	assert.NoError(t, err)
+
+	err = lottery.raffle(&chainrpc.BlockEpoch{Hash: blockHash, Height: blockHeight})
+	assert.NoError(t, err)
+	auditorMock.AssertExpectations(t)
+
+	winners, err := db.Winners.List(blockHeight)
+	assert.NoError(t, err)
+	assert.Len(t, winners, 3)
+
+	// The fee is the same as with the whole distribution
+	givenPrizes := uint64(0)
+	for _, winner := range winners {
+		givenPrizes += winner.Prize
+	}
+	fee := float64(bets[1].Index) * (engine.DefaultDistribution.Fee() / 100)
+	assert.Equal(t, math.Round(float64(bets[1].Index)-fee), float64(givenPrizes))
+
+	tiers, err := db.Lotteries.ListTiers(blockHeight)
+	assert.NoError(t, err)
+	assert.Len(t, tiers, 1)
+	assert.Equal(t, uint64(2), tiers[0].Participants)
+	assert.Equal(t, uint32(3), tiers[0].Tiers)
+}
+func TestRafflePools(t *testing.T) {
+	blockHeight := uint32(833348)
+	winnersHub := NewWinnersHub(config.WinnersHub{})
+	subscription := winnersHub.Subscribe()
+	db := setupDB(t, func(db *sql.DB) {
+		// Lottery started before the commitments were introduced
+		_, err := db.Exec("INSERT INTO lotteries (height) VALUES (?)", blockHeight)
+		assert.NoError(t, err)
+
+		query := `INSERT INTO bets (idx, tickets, public_key, lottery_height, pool) VALUES
+		(?,?,?,?,'micro'), (?,?,?,?,'micro'), (?,?,?,?,'whale')`
+		_, err = db.Exec(query,
+			100, 100, bets[0].PublicKey, blockHeight,
+			300, 200, bets[1].PublicKey, blockHeight,
+			bets[1].Tickets, bets[1].Tickets, bets[2].PublicKey, blockHeight,
+		)
+		assert.NoError(t, err)
+	})
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("PublishWinners", blockHeight, mock.Anything).Return(nil)
+	auditorMock := audit.NewAuditorMock()
+	auditorMock.On("Record", audit.DrawExecuted, mock.Anything).Twice()
+	auditorMock.On("Record", audit.PrizeAssigned, mock.Anything).Times(3)
+	webhooksMock := webhooks.NewPublisherMock()
+	webhooksMock.On("Publish", mock.Anything, mock.Anything).Maybe()
+
+	config := config.Lottery{
+		Duration: 144,
+		Pools: []config.Pool{
+			{Name: "micro", MaxAmount: 9_999, Capacity: 50, Distribution: []float64{60, 30}},
+			{Name: "whale", MinAmount: 10_000, Capacity: 50, Distribution: []float64{90}},
+		},
+	}
+	lottery, err := New(config, db, nil, notifierMock, templates, auditorMock, nil, nil, newQueue(t, db), webhooksMock, winnersHub, nil)
+	assert.NoError(t, err)
+
+	blockHash, err := hex.DecodeString("000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6")
+	assert.NoError(t, err)
+
+	err = lottery.raffle(&chainrpc.BlockEpoch{Hash: blockHash, Height: blockHeight})
+	assert.NoError(t, err)
+
+	auditorMock.AssertExpectations(t)
+	assert.Len(t, <-subscription.C(), 3)
+
+	winners, err := db.Winners.List(blockHeight)
+	assert.NoError(t, err)
+
+	prizes := make(map[string]uint64)
+	for _, winner := range winners {
+		switch winner.Pool {
+		case "micro":
+			assert.Contains(t, []string{bets[0].PublicKey, bets[1].PublicKey}, winner.PublicKey)
+		case "whale":
+			assert.Equal(t, bets[2].PublicKey, winner.PublicKey)
+		default:
+			assert.Failf(t, "Winner of an unknown pool", "pool %q", winner.Pool)
+		}
+		prizes[winner.Pool] += winner.Prize
+	}
+
+	assert.Equal(t, map[string]uint64{"micro": 270, "whale": 900_000}, prizes)
+
+	runJobs(t, lottery, db)
+	rounds, err := db.Stats.ListRounds(0, 0, false)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1_000_300), rounds[0].PrizePool)
+	assert.Equal(t, uint64(3), rounds[0].Players)
 }
 
 func TestRaffleWithoutBets(t *testing.T) {
 	db := setupDB(t, nil)
-	lottery, err := New(config.Lottery{}, db, nil, nil, nil, nil)
+	lottery, err := New(config.Lottery{}, db, nil, nil, templates, nil, nil, nil, nil, nil, nil, nil)
 	assert.NoError(t, err)
 
 	block := &chainrpc.BlockEpoch{
@@ -223,6 +917,135 @@ func TestRaffleWithoutBets(t *testing.T) {
 	assert.Empty(t, winners)
 }
 
+func TestRaffleInterrupted(t *testing.T) {
+	blockHeight := uint32(833348)
+	store := setupDB(t, func(db *sql.DB) {
+		query := "INSERT INTO lotteries (height, seed, commitment) VALUES (?,?,?)"
+		_, err := db.Exec(query, blockHeight, hex.EncodeToString(make([]byte, 32)), "commitment")
+		assert.NoError(t, err)
+
+		query = "INSERT INTO bets (idx, tickets, public_key, lottery_height) VALUES (?,?,?,?), (?,?,?,?)"
+		_, err = db.Exec(query,
+			bets[0].Index, bets[0].Tickets, bets[0].PublicKey, blockHeight,
+			bets[1].Index, bets[1].Tickets, bets[1].PublicKey, blockHeight,
+		)
+		assert.NoError(t, err)
+	})
+	auditorMock := audit.NewAuditorMock()
+	auditorMock.On("Record", mock.Anything, mock.Anything)
+	webhooksMock := webhooks.NewPublisherMock()
+	webhooksMock.On("Publish", mock.Anything, mock.Anything)
+
+	lottery, err := New(config.Lottery{Duration: 144}, store, nil, nil, templates, auditorMock, nil, nil,
+		newQueue(t, store), webhooksMock, NewWinnersHub(config.WinnersHub{}), nil)
+	assert.NoError(t, err)
+
+	block := &chainrpc.BlockEpoch{Hash: []byte{1}, Height: blockHeight}
+
+	// The process stops after the draw is checked and before its results are stored
+	winnersStore := store.Winners
+	winnersMock := db.NewWinnersStoreMock()
+	winnersMock.On("Store", blockHeight, mock.Anything, mock.Anything).Return(errors.New("disk I/O error"))
+	store.Winners = winnersMock
+
+	err = lottery.raffle(block)
+	assert.Error(t, err)
+
+	drawn, err := store.Lotteries.IsDrawn(blockHeight)
+	assert.NoError(t, err)
+	assert.False(t, drawn)
+
+	// The lottery is drawn when the process is back
+	store.Winners = winnersStore
+	err = lottery.raffle(block)
+	assert.NoError(t, err)
+
+	winners, err := store.Winners.List(blockHeight)
+	assert.NoError(t, err)
+	assert.Len(t, winners, len(engine.DefaultDistribution))
+
+	// And only once
+	err = lottery.raffle(block)
+	assert.NoError(t, err)
+
+	drawnWinners, err := store.Winners.List(blockHeight)
+	assert.NoError(t, err)
+	assert.Equal(t, winners, drawnWinners)
+}
+
+func TestCompactBets(t *testing.T) {
+	blockHeight := uint32(833348)
+	db := setupDB(t, func(db *sql.DB) {
+		query := `INSERT INTO bets (first_idx, idx, tickets, public_key, lottery_height) VALUES
+		(?,?,?,?,?), (?,?,?,?,?), (?,?,?,?,?)`
+		_, err := db.Exec(query,
+			1, 100, 100, bets[0].PublicKey, blockHeight,
+			101, 300, 200, bets[0].PublicKey, blockHeight,
+			301, 400, 100, bets[1].PublicKey, blockHeight,
+		)
+		assert.NoError(t, err)
+	})
+	lottery, err := New(config.Lottery{}, db, nil, nil, templates, nil, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	lottery.compactBets(blockHeight)
+
+	stored, err := db.Bets.List(blockHeight, "", 0, 0, false)
+	assert.NoError(t, err)
+	assert.Len(t, stored, 2)
+	assert.Equal(t, uint64(1), stored[0].FirstTicket)
+	assert.Equal(t, uint64(300), stored[0].Index)
+	assert.Equal(t, uint64(300), stored[0].Tickets)
+}
+
+func TestPruneBetArchives(t *testing.T) {
+	db := setupDB(t, func(db *sql.DB) {
+		_, err := db.Exec("INSERT INTO lotteries (height) VALUES (144), (288), (432)")
+		assert.NoError(t, err)
+	})
+	for _, height := range []uint32{144, 288, 432} {
+		assert.NoError(t, db.BetArchives.Add(height, bets))
+	}
+
+	config := config.Lottery{Duration: 144, BetArchive: config.BetArchive{Retention: 2}}
+	lottery, err := New(config, db, nil, nil, templates, nil, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	lottery.pruneBetArchives(432)
+
+	_, err = db.BetArchives.Get(144)
+	assert.Error(t, err)
+
+	for _, height := range []uint32{288, 432} {
+		_, err = db.BetArchives.Get(height)
+		assert.NoError(t, err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	lottery, err := New(config.Lottery{}, nil, nil, nil, templates, nil, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	distribution := []float64{60, 30}
+	bonus := config.Bonus{Bundles: []config.Bundle{{MinAmount: 100, Percentage: 10}}, RoundCap: 1000}
+	lottery.Reload(config.Lottery{
+		Pools: []config.Pool{{Name: "micro", Capacity: 100, Distribution: distribution}},
+		Bonus: bonus,
+	})
+
+	// The bonus applies to the next bets, but the current lottery keeps its prize table
+	assert.Equal(t, bonus, lottery.Bonus())
+	assert.Equal(t, engine.DefaultDistribution, lottery.Pools().Distribution("micro"))
+
+	lottery.rotatePools()
+	assert.Equal(t, engine.Distribution(distribution), lottery.Pools().Distribution("micro"))
+	assert.Nil(t, lottery.nextPools)
+
+	// Nothing changes if there's nothing to rotate
+	lottery.rotatePools()
+	assert.Equal(t, engine.Distribution(distribution), lottery.pools.Distribution("micro"))
+}
+
 func TestNotify(t *testing.T) {
 	publicKey := "pubKey"
 	chatID := int64(1)
@@ -230,33 +1053,63 @@ func TestNotify(t *testing.T) {
 
 	notificationsMock := db.NewNotificationsStoreMock()
 	notificationsMock.On("GetChatID", publicKey).Return(chatID, nil)
+	notificationsMock.On("GetNostrKey", publicKey).Return("", db.ErrNoNostrKey)
+	delivery := db.NotificationDelivery{PublicKey: publicKey, Message: message}
+
 	db := &db.DB{
 		Notifications: notificationsMock,
 	}
 
 	notifierMock := notification.NewNotifierMock()
-	notifierMock.On("Notify", chatID, message)
+	notifierMock.On("NotifyPlayer", delivery, chatID)
 
-	lottery, err := New(config.Lottery{}, db, nil, notifierMock, nil, nil)
+	lottery, err := New(config.Lottery{}, db, nil, notifierMock, templates, nil, nil, nil, nil, nil, nil, nil)
 	assert.NoError(t, err)
 
 	lottery.notify(publicKey, message)
 }
 
+func TestNotifyNostr(t *testing.T) {
+	publicKey := "pubKey"
+	nostrKey := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	message := "Hello world"
+
+	notificationsMock := db.NewNotificationsStoreMock()
+	notificationsMock.On("GetChatID", publicKey).Return(int64(0), db.ErrNoChatID)
+	notificationsMock.On("GetNostrKey", publicKey).Return(nostrKey, nil)
+	delivery := db.NotificationDelivery{PublicKey: publicKey, Message: message}
+
+	db := &db.DB{
+		Notifications: notificationsMock,
+	}
+
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("NotifyNostr", delivery, nostrKey)
+
+	lottery, err := New(config.Lottery{}, db, nil, notifierMock, templates, nil, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	lottery.notify(publicKey, message)
+
+	notifierMock.AssertExpectations(t)
+	notifierMock.AssertNotCalled(t, "NotifyPlayer", mock.Anything, mock.Anything)
+}
+
 func TestNotifyNoChatIDError(t *testing.T) {
 	publicKey := "pubKey"
 	message := "Hello world"
 
 	notificationsMock := db.NewNotificationsStoreMock()
 	notificationsMock.On("GetChatID", publicKey).Return(int64(0), db.ErrNoChatID)
+	notificationsMock.On("GetNostrKey", publicKey).Return("", db.ErrNoNostrKey)
 	db := &db.DB{
 		Notifications: notificationsMock,
 	}
 
 	notifierMock := notification.NewNotifierMock()
-	notificationsMock.AssertNotCalled(t, "Notify")
+	notifierMock.AssertNotCalled(t, "NotifyPlayer")
 
-	lottery, err := New(config.Lottery{}, db, nil, notifierMock, nil, nil)
+	lottery, err := New(config.Lottery{}, db, nil, notifierMock, templates, nil, nil, nil, nil, nil, nil, nil)
 	assert.NoError(t, err)
 
 	lottery.notify(publicKey, message)
@@ -268,14 +1121,15 @@ func TestNotifyError(t *testing.T) {
 
 	notificationsMock := db.NewNotificationsStoreMock()
 	notificationsMock.On("GetChatID", publicKey).Return(int64(0), errors.New("err"))
+	notificationsMock.On("GetNostrKey", publicKey).Return("", db.ErrNoNostrKey)
 	db := &db.DB{
 		Notifications: notificationsMock,
 	}
 
 	notifierMock := notification.NewNotifierMock()
-	notificationsMock.AssertNotCalled(t, "Notify")
+	notifierMock.AssertNotCalled(t, "NotifyPlayer")
 
-	lottery, err := New(config.Lottery{}, db, nil, notifierMock, nil, nil)
+	lottery, err := New(config.Lottery{}, db, nil, notifierMock, templates, nil, nil, nil, nil, nil, nil, nil)
 	assert.NoError(t, err)
 
 	lottery.notify(publicKey, message)
@@ -284,25 +1138,30 @@ func TestNotifyError(t *testing.T) {
 func TestNotifyWinners(t *testing.T) {
 	blockHeight := uint32(1)
 	publicKey := "public_key"
-	chatID := int64(1)
 	prizes := uint64(100)
 	blocksDuration := uint32(144)
-	message := fmt.Sprintf(notification.Congratulations, prizes, blockHeight+blocksDuration*5)
-
-	notificationsMock := db.NewNotificationsStoreMock()
-	notificationsMock.On("GetChatID", publicKey).Return(chatID, nil)
-	db := &db.DB{
-		Notifications: notificationsMock,
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	// Five lotteries of 144 blocks each, ~5 days
+	deadline := "May 15, 2024 12:00 UTC"
+	message := fmt.Sprintf("Congratulations! You have won %d sats, your prizes expire at block %d "+
+		"(approximately %s).", prizes, blockHeight+blocksDuration*5, deadline)
+
+	queueMock := jobs.NewQueueMock()
+	job := notifyJob{
+		PublicKey:  publicKey,
+		Message:    message,
+		RetryUntil: time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC).Unix(),
 	}
-
-	notifierMock := notification.NewNotifierMock()
-	notifierMock.On("Notify", chatID, message)
+	queueMock.On("Enqueue", jobNotify, job).Return(nil)
 
 	config := config.Lottery{Duration: blocksDuration}
-	lottery, err := New(config, db, nil, notifierMock, nil, nil)
+	lottery, err := New(config, nil, nil, nil, templates, nil, nil, nil, queueMock, nil, nil, nil)
 	assert.NoError(t, err)
+	lottery.now = func() time.Time { return now }
 
 	lottery.notifyWinners(blockHeight, map[string]uint64{publicKey: prizes})
+
+	queueMock.AssertExpectations(t)
 }
 
 func TestTryAutoWithdrawals(t *testing.T) {
@@ -311,21 +1170,29 @@ func TestTryAutoWithdrawals(t *testing.T) {
 	prizes := uint64(100)
 	preimage := "abc"
 	chatID := int64(1)
-	message := fmt.Sprintf(notification.AutomaticWithdrawal, prizes, address, preimage)
+	message := fmt.Sprintf("%d sats were withdrawn to %s. Preimage: %s", prizes, address, preimage)
 
 	lightningMock := db.NewLightningStoreMock()
 	lightningMock.On("GetAddress", publicKey).Return(address, nil)
 
 	prizesMock := db.NewPrizesStoreMock()
-	prizesMock.On("Withdraw", publicKey, prizes).Return(nil)
+	prizesMock.On("Withdraw", publicKey, prizes).Return([]db.PrizesRow{{RowID: 1, Amount: prizes}}, nil)
 
 	notificationsMock := db.NewNotificationsStoreMock()
 	notificationsMock.On("GetChatID", publicKey).Return(chatID, nil)
+	notificationsMock.On("GetNostrKey", publicKey).Return("", db.ErrNoNostrKey)
+
+	statsMock := db.NewStatsStoreMock()
+	statsMock.On("AddPayout", prizes).Return(nil)
+
+	delivery := db.NotificationDelivery{PublicKey: publicKey, Message: message}
 
 	db := &db.DB{
+		AccessLists:   allowedAccess(),
 		Lightning:     lightningMock,
 		Prizes:        prizesMock,
 		Notifications: notificationsMock,
+		Stats:         statsMock,
 	}
 
 	lnd := lightning.NewClientMock()
@@ -333,12 +1200,27 @@ func TestTryAutoWithdrawals(t *testing.T) {
 		Return(preimage, nil)
 
 	notifierMock := notification.NewNotifierMock()
-	notifierMock.On("Notify", chatID, message)
+	notifierMock.On("NotifyPlayer", delivery, chatID)
+
+	auditorMock := audit.NewAuditorMock()
+	auditorMock.On("Record", audit.PayoutSent, map[string]any{
+		"public_key": publicKey,
+		"amount":     prizes,
+		"address":    address,
+		"preimage":   preimage,
+	})
 
-	lottery, err := New(config.Lottery{}, db, lnd, notifierMock, nil, nil)
+	webhooksMock := webhooks.NewPublisherMock()
+	webhooksMock.On("Publish", webhooks.PayoutSent, map[string]any{"amount": prizes})
+
+	lottery, err := New(config.Lottery{}, db, lnd, notifierMock, templates, auditorMock, nil, nil, nil, webhooksMock, nil, nil)
 	assert.NoError(t, err)
 
-	lottery.tryAutoWithdrawals(1, map[string]uint64{publicKey: prizes})
+	lottery.tryAutoWithdrawals(context.Background(), map[string]uint64{publicKey: prizes}, 0)
+
+	auditorMock.AssertExpectations(t)
+	webhooksMock.AssertExpectations(t)
+	statsMock.AssertExpectations(t)
 }
 
 func TestTryAutoWithdrawalsNoAddress(t *testing.T) {
@@ -350,10 +1232,25 @@ func TestTryAutoWithdrawalsNoAddress(t *testing.T) {
 		Lightning: lightningMock,
 	}
 
-	lottery, err := New(config.Lottery{}, db, nil, nil, nil, nil)
+	lottery, err := New(config.Lottery{}, db, nil, nil, templates, nil, nil, nil, nil, nil, nil, nil)
 	assert.NoError(t, err)
 
-	lottery.tryAutoWithdrawals(1, map[string]uint64{publicKey: 0})
+	lottery.tryAutoWithdrawals(context.Background(), map[string]uint64{publicKey: 0}, 0)
+}
+
+func TestTryAutoWithdrawalsApprovalRequired(t *testing.T) {
+	lightningMock := db.NewLightningStoreMock()
+	db := &db.DB{
+		Lightning: lightningMock,
+	}
+
+	config := config.Lottery{Approvals: config.Approvals{Threshold: 1_000_000}}
+	lottery, err := New(config, db, nil, nil, templates, nil, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	lottery.tryAutoWithdrawals(context.Background(), map[string]uint64{"public_key": 1_000_001}, 0)
+
+	lightningMock.AssertNotCalled(t, "GetAddress", mock.Anything)
 }
 
 func TestTryAutoWithdrawalsGetAddressError(t *testing.T) {
@@ -365,10 +1262,10 @@ func TestTryAutoWithdrawalsGetAddressError(t *testing.T) {
 		Lightning: lightningMock,
 	}
 
-	lottery, err := New(config.Lottery{}, db, nil, nil, nil, nil)
+	lottery, err := New(config.Lottery{}, db, nil, nil, templates, nil, nil, nil, nil, nil, nil, nil)
 	assert.NoError(t, err)
 
-	lottery.tryAutoWithdrawals(1, map[string]uint64{publicKey: 0})
+	lottery.tryAutoWithdrawals(context.Background(), map[string]uint64{publicKey: 0}, 0)
 }
 
 func TestTryAutoWithdrawalsWithdrawError(t *testing.T) {
@@ -380,58 +1277,182 @@ func TestTryAutoWithdrawalsWithdrawError(t *testing.T) {
 	lightningMock.On("GetAddress", publicKey).Return(address, nil)
 
 	prizesMock := db.NewPrizesStoreMock()
-	prizesMock.On("Withdraw", publicKey, prizes).Return(errors.New("test"))
+	prizesMock.On("Withdraw", publicKey, prizes).Return([]db.PrizesRow(nil), errors.New("test"))
 
 	db := &db.DB{
-		Lightning: lightningMock,
-		Prizes:    prizesMock,
+		AccessLists: allowedAccess(),
+		Lightning:   lightningMock,
+		Prizes:      prizesMock,
 	}
 
-	lottery, err := New(config.Lottery{}, db, nil, nil, nil, nil)
+	lottery, err := New(config.Lottery{}, db, nil, nil, templates, nil, nil, nil, nil, nil, nil, nil)
 	assert.NoError(t, err)
 
-	lottery.tryAutoWithdrawals(1, map[string]uint64{publicKey: prizes})
+	lottery.tryAutoWithdrawals(context.Background(), map[string]uint64{publicKey: prizes}, 0)
 }
 
 func TestTryAutoWithdrawalsSendError(t *testing.T) {
-	lotteryHeight := uint32(1)
 	publicKey := "public_key"
 	address := "test@btry.com"
 	prizes := uint64(100)
+	nostrKey := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	message := fmt.Sprintf("The automatic withdrawal of %d sats to %s failed, please claim your "+
+		"prizes manually before they expire.", prizes, address)
 
 	lightningMock := db.NewLightningStoreMock()
 	lightningMock.On("GetAddress", publicKey).Return(address, nil)
 
 	prizesMock := db.NewPrizesStoreMock()
-	prizesMock.On("Withdraw", publicKey, prizes).Return(nil)
-	winner := db.Winner{
-		PublicKey: publicKey,
-		Prize:     prizes,
+	claims := []db.PrizesRow{{RowID: 1, Amount: prizes}}
+	prizesMock.On("Withdraw", publicKey, prizes).Return(claims, nil)
+	prizesMock.On("Restore", claims).Return(nil)
+
+	notificationsMock := db.NewNotificationsStoreMock()
+	notificationsMock.On("GetChatID", publicKey).Return(int64(0), db.ErrNoChatID)
+	notificationsMock.On("GetNostrKey", publicKey).Return(nostrKey, nil)
+
+	delivery := db.NotificationDelivery{PublicKey: publicKey, Message: message}
+
+	db := &db.DB{
+		AccessLists:   allowedAccess(),
+		Lightning:     lightningMock,
+		Prizes:        prizesMock,
+		Notifications: notificationsMock,
 	}
-	prizesMock.On("Set", lotteryHeight, []db.Winner{winner}).Return(nil)
+
+	lnd := lightning.NewClientMock()
+	lnd.On("SendToLightningAddress", context.Background(), address, int64(prizes)).
+		Return("", errors.New("test"))
+
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("NotifyNostr", delivery, nostrKey)
+
+	lottery, err := New(config.Lottery{}, db, lnd, notifierMock, templates, nil, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	lottery.tryAutoWithdrawals(context.Background(), map[string]uint64{publicKey: prizes}, 0)
+
+	prizesMock.AssertExpectations(t)
+	notifierMock.AssertExpectations(t)
+}
+
+func TestTryAutoWithdrawalsSendTransientError(t *testing.T) {
+	publicKey := "public_key"
+	address := "test@btry.com"
+	prizes := uint64(100)
+
+	lightningMock := db.NewLightningStoreMock()
+	lightningMock.On("GetAddress", publicKey).Return(address, nil)
+	prizesMock := db.NewPrizesStoreMock()
+	prizesMock.On("Withdraw", publicKey, prizes).Return([]db.PrizesRow{{RowID: 1, Amount: prizes}}, nil)
+	statsMock := db.NewStatsStoreMock()
+	statsMock.On("AddPayout", prizes).Return(nil)
+	notificationsMock := db.NewNotificationsStoreMock()
+	notificationsMock.On("GetChatID", publicKey).Return(int64(0), db.ErrNoChatID)
+	notificationsMock.On("GetNostrKey", publicKey).Return("", db.ErrNoNostrKey)
 
 	db := &db.DB{
-		Lightning: lightningMock,
-		Prizes:    prizesMock,
+		AccessLists:   allowedAccess(),
+		Lightning:     lightningMock,
+		Prizes:        prizesMock,
+		Stats:         statsMock,
+		Notifications: notificationsMock,
+	}
+
+	lnd := lightning.NewClientMock()
+	lnd.On("SendToLightningAddress", context.Background(), address, int64(prizes)).
+		Return("", fault.NewTransient(errors.New("timeout"))).Once()
+	lnd.On("SendToLightningAddress", context.Background(), address, int64(prizes)).
+		Return("preimage", nil).Once()
+
+	auditorMock := audit.NewAuditorMock()
+	auditorMock.On("Record", audit.PayoutSent, mock.Anything).Once()
+	webhooksMock := webhooks.NewPublisherMock()
+	webhooksMock.On("Publish", webhooks.PayoutSent, mock.Anything).Once()
+
+	lottery, err := New(config.Lottery{}, db, lnd, nil, templates, auditorMock, nil, nil, nil, webhooksMock,
+		nil, nil)
+	assert.NoError(t, err)
+	lottery.retry.Delay = 0
+
+	lottery.tryAutoWithdrawals(context.Background(), map[string]uint64{publicKey: prizes}, 0)
+
+	lnd.AssertExpectations(t)
+	prizesMock.AssertNotCalled(t, "Restore", mock.Anything)
+	auditorMock.AssertExpectations(t)
+}
+
+func TestTryAutoWithdrawalsAccessDenied(t *testing.T) {
+	publicKey := "public_key"
+
+	lightningMock := db.NewLightningStoreMock()
+	lightningMock.On("GetAddress", publicKey).Return("test@btry.com", nil)
+
+	accessListsMock := db.NewAccessListsStoreMock()
+	accessListsMock.On("Allowed", publicKey, db.AccessWithdrawals).Return(false, nil)
+
+	prizesMock := db.NewPrizesStoreMock()
+
+	db := &db.DB{
+		AccessLists: accessListsMock,
+		Lightning:   lightningMock,
+		Prizes:      prizesMock,
+	}
+
+	auditorMock := audit.NewAuditorMock()
+	auditorMock.On("Record", audit.AccessDenied, mock.Anything)
+
+	lottery, err := New(config.Lottery{}, db, nil, nil, templates, auditorMock, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	lottery.tryAutoWithdrawals(context.Background(), map[string]uint64{publicKey: 100}, 0)
+
+	auditorMock.AssertExpectations(t)
+	prizesMock.AssertNotCalled(t, "Withdraw", mock.Anything, mock.Anything)
+}
+
+func TestTryAutoWithdrawalsRestoreError(t *testing.T) {
+	publicKey := "public_key"
+	address := "test@btry.com"
+	prizes := uint64(100)
+
+	lightningMock := db.NewLightningStoreMock()
+	lightningMock.On("GetAddress", publicKey).Return(address, nil)
+
+	prizesMock := db.NewPrizesStoreMock()
+	claims := []db.PrizesRow{{RowID: 1, Amount: prizes}}
+	prizesMock.On("Withdraw", publicKey, prizes).Return(claims, nil)
+	prizesMock.On("Restore", claims).Return(errors.New("test"))
+
+	db := &db.DB{
+		AccessLists: allowedAccess(),
+		Lightning:   lightningMock,
+		Prizes:      prizesMock,
 	}
 
 	lnd := lightning.NewClientMock()
 	lnd.On("SendToLightningAddress", context.Background(), address, int64(prizes)).
 		Return("", errors.New("test"))
 
-	lottery, err := New(config.Lottery{}, db, lnd, nil, nil, nil)
+	lottery, err := New(config.Lottery{}, db, lnd, nil, templates, nil, nil, nil, nil, nil, nil, nil)
 	assert.NoError(t, err)
 
-	lottery.tryAutoWithdrawals(lotteryHeight, map[string]uint64{publicKey: prizes})
+	lottery.tryAutoWithdrawals(context.Background(), map[string]uint64{publicKey: prizes}, 0)
+
+	prizesMock.AssertExpectations(t)
 }
 
 func TestGetInfo(t *testing.T) {
 	lndMock := lightning.NewClientMock()
 	betsMock := db.NewBetsStoreMock()
 	lotteriesMock := db.NewLotteriesStoreMock()
+	postponement := db.Postponement{LotteryHeight: 1, PostponedAt: 1, ResumeHeight: 7, Attempts: 1}
+	postponementsMock := db.NewPostponementsStoreMock()
+	postponementsMock.On("GetActive").Return(postponement, nil)
 	db := &db.DB{
-		Bets:      betsMock,
-		Lotteries: lotteriesMock,
+		Bets:          betsMock,
+		Lotteries:     lotteriesMock,
+		Postponements: postponementsMock,
 	}
 
 	remoteBalance := int64(15_000_000)
@@ -441,14 +1462,61 @@ func TestGetInfo(t *testing.T) {
 	ctx := context.Background()
 	lndMock.On("RemoteBalance", ctx).Return(remoteBalance, nil)
 	lotteriesMock.On("GetNextHeight").Return(nextHeight, nil)
-	betsMock.On("GetPrizePool", nextHeight).Return(prizePool, nil)
+	betsMock.On("GetPrizePool", nextHeight, "").Return(prizePool, nil)
 
-	info, err := GetInfo(ctx, lndMock, db)
+	info, err := GetInfo(ctx, lndMock, db, NewPools(nil), RemoteBalanceCapacity{})
 	assert.NoError(t, err)
 
 	assert.Equal(t, int64(prizePool), info.PrizePool)
-	assert.Equal(t, remoteBalance/CapacityDivisor, info.Capacity)
+	assert.Equal(t, remoteBalance/DefaultCapacityDivisor, info.Capacity)
 	assert.Equal(t, nextHeight, info.NextHeight)
+	expectedPools := []PoolInfo{{PrizePool: int64(prizePool), Capacity: info.Capacity}}
+	assert.Equal(t, expectedPools, info.Pools)
+	assert.Equal(t, &postponement, info.Postponement)
+}
+
+func TestGetInfoPools(t *testing.T) {
+	lndMock := lightning.NewClientMock()
+	betsMock := db.NewBetsStoreMock()
+	lotteriesMock := db.NewLotteriesStoreMock()
+	postponementsMock := db.NewPostponementsStoreMock()
+	postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+	db := &db.DB{
+		Bets:          betsMock,
+		Lotteries:     lotteriesMock,
+		Postponements: postponementsMock,
+	}
+
+	nextHeight := uint32(1)
+	ctx := context.Background()
+	lndMock.On("RemoteBalance", ctx).Return(int64(10_000_000), nil)
+	lotteriesMock.On("GetNextHeight").Return(nextHeight, nil)
+	betsMock.On("GetPrizePool", nextHeight, "micro").Return(uint64(5_000), nil)
+	betsMock.On("GetPrizePool", nextHeight, "whale").Return(uint64(1_000_000), nil)
+
+	pools := NewPools([]config.Pool{
+		{Name: "micro", MaxAmount: 9_999, Capacity: 20},
+		{Name: "whale", MinAmount: 10_000, Capacity: 80},
+	})
+	info, err := GetInfo(ctx, lndMock, db, pools, RemoteBalanceCapacity{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(1_005_000), info.PrizePool)
+	assert.Equal(t, int64(2_000_000), info.Capacity)
+
+	expectedPools := []PoolInfo{
+		{Name: "micro", PrizePool: 5_000, Capacity: 400_000, MaxAmount: 9_999},
+		{Name: "whale", PrizePool: 1_000_000, Capacity: 1_600_000, MinAmount: 10_000},
+	}
+	assert.Equal(t, expectedPools, info.Pools)
+
+	pool, ok := info.Pool("whale")
+	assert.True(t, ok)
+	assert.Equal(t, expectedPools[1], pool)
+
+	_, ok = info.Pool("")
+	assert.False(t, ok)
+	assert.Nil(t, info.Postponement)
 }
 
 func TestAggregateWinners(t *testing.T) {
@@ -466,19 +1534,20 @@ func TestAggregateWinners(t *testing.T) {
 }
 
 func TestGetWinners(t *testing.T) {
-	prizePool := uint64(1_427_224)
+	prizePool := bets[len(bets)-1].Index
 	blockHash, err := hex.DecodeString("000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6")
 	assert.NoError(t, err)
 
-	winners, err := getWinners(blockHash, prizePool, bets)
+	winners, err := getWinners(blockHash, bets, engine.DefaultDistribution, engine.RoundingNearest,
+		engine.CollisionStack)
 	assert.NoError(t, err)
 
-	assert.Len(t, winners, len(prizes))
+	assert.Len(t, winners, len(engine.DefaultDistribution))
 
 	for i, winner := range winners {
 		validateGetWinner(t, winner.Ticket, winner.PublicKey)
 
-		prize := (prizes[i] / 100) * float64(prizePool)
+		prize := (engine.DefaultDistribution[i] / 100) * float64(prizePool)
 		assert.Equal(t, uint64(math.Round(prize)), winner.Prize)
 	}
 }
@@ -486,69 +1555,56 @@ func TestGetWinners(t *testing.T) {
 func TestGetWinnersWithoutBets(t *testing.T) {
 	blockHash, err := hex.DecodeString("000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6")
 	assert.NoError(t, err)
-	winners, err := getWinners(blockHash, 0, []db.Bet{})
+	winners, err := getWinners(blockHash, []db.Bet{}, engine.DefaultDistribution, engine.RoundingNearest,
+		engine.CollisionStack)
 	assert.NoError(t, err)
 
 	assert.Nil(t, winners)
 }
 
-func TestGetWinningTickets(t *testing.T) {
-	prizePool := uint64(1000)
-	results := []uint64{417, 777, 865, 833, 977, 402, 322, 337}
-	blockHash, err := hex.DecodeString("000000000000000000001badcbb5d10b486a18a97ac9d6e08d526a62aa9a360e")
-	assert.NoError(t, err)
-	i := len(blockHash) - 1
+// allowedAccess returns an access lists mock that allows every public key.
+func allowedAccess() *db.AccessListsStoreMock {
+	accessListsMock := db.NewAccessListsStoreMock()
+	accessListsMock.On("Allowed", mock.Anything, mock.Anything).Return(true, nil)
+	return accessListsMock
+}
 
-	for _, expected := range results {
-		target := getWinningTicket(blockHash, i, prizePool)
-		assert.Equal(t, expected, target)
-		i -= 2
-	}
+func newQueueMock() *jobs.QueueMock {
+	queueMock := jobs.NewQueueMock()
+	queueMock.On("Register", mock.Anything, mock.Anything)
+	return queueMock
 }
 
-func TestGetPublicKey(t *testing.T) {
-	cases := []struct {
-		desc              string
-		expectedPublicKey string
-		bets              []db.Bet
-		winningTicket     uint64
-	}{
-		{
-			desc:              "First",
-			bets:              bets,
-			winningTicket:     15,
-			expectedPublicKey: bets[0].PublicKey,
-		},
-		{
-			desc:              "Second",
-			bets:              bets,
-			winningTicket:     1_000_000,
-			expectedPublicKey: bets[1].PublicKey,
-		},
-		{
-			desc:              "Third",
-			bets:              bets,
-			winningTicket:     1_527_224,
-			expectedPublicKey: bets[2].PublicKey,
-		},
-	}
+func newLeaderMock() *leader.ElectorMock {
+	leaderMock := leader.NewElectorMock()
+	leaderMock.On("IsLeader").Return(true)
+	return leaderMock
+}
 
-	for _, tc := range cases {
-		t.Run(tc.desc, func(t *testing.T) {
-			gotPublicKey := getPublicKey(tc.bets, tc.winningTicket)
+func newQueue(t *testing.T, db *db.DB) jobs.Queue {
+	t.Helper()
 
-			assert.Equal(t, tc.expectedPublicKey, gotPublicKey)
-		})
-	}
+	queue, err := jobs.New(config.Jobs{}, db)
+	assert.NoError(t, err)
+	return queue
 }
 
-func TestPercentages(t *testing.T) {
-	// Just in case :)
-	total := btryFee
-	for _, prize := range prizes {
-		total += prize
+// runJobs executes the jobs enqueued with the lottery handlers and returns how many there were.
+func runJobs(t *testing.T, lottery *Lottery, db *db.DB) int {
+	t.Helper()
+
+	now := time.Now().Unix()
+	enqueued, err := db.Jobs.Claim(now, now, 100)
+	assert.NoError(t, err)
+
+	handlers := lottery.jobHandlers()
+	for _, job := range enqueued {
+		err := handlers[job.Kind](context.Background(), job.Payload)
+		assert.NoError(t, err, job.Kind)
+		assert.NoError(t, db.Jobs.Delete(job.ID))
 	}
-	assert.Equal(t, 100, int(total))
+
+	return len(enqueued)
 }
 
 func setupDB(t *testing.T, setup func(db *sql.DB)) *db.DB {
@@ -584,11 +1640,27 @@ func setupDB(t *testing.T, setup func(db *sql.DB)) *db.DB {
 func validateGetWinner(t *testing.T, target uint64, expectedPubKey string) {
 	t.Helper()
 
-	for i, bet := range bets {
-		if target <= bet.Tickets {
-			if i == 0 || target > bets[i-1].Tickets {
-				assert.Equal(t, expectedPubKey, bet.PublicKey)
-			}
+	for _, bet := range bets {
+		if target <= bet.Index {
+			assert.Equal(t, expectedPubKey, bet.PublicKey)
+			return
 		}
 	}
+
+	assert.Failf(t, "Ticket out of range", "ticket: %d", target)
+}
+
+func TestPruneClaimCodes(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	claimCodesMock := db.NewClaimCodesStoreMock()
+	claimCodesMock.On("DeleteExpired", now.Unix()).Return(uint64(2), nil).Once()
+
+	lottery, err := New(config.Lottery{}, &db.DB{ClaimCodes: claimCodesMock}, nil, nil, templates, nil, nil,
+		nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	lottery.now = func() time.Time { return now }
+
+	lottery.pruneClaimCodes()
+
+	claimCodesMock.AssertExpectations(t)
 }
@@ -0,0 +1,15 @@
+package lottery
+
+import "fmt"
+
+// memoPrefix identifies BTRY invoices.
+const memoPrefix = "BTRY"
+
+// InvoiceMemo returns the description of the invoices used to bet in a lottery.
+//
+// The memo is a list of key-value pairs separated by semicolons so wallets can display it and
+// clients can parse it. Tickets are numbered when the invoice is settled, so the memo contains the
+// number of tickets and not their range, which is included in the bet receipt.
+func InvoiceMemo(height uint32, tickets uint64) string {
+	return fmt.Sprintf("%s;round=%d;tickets=%d", memoPrefix, height, tickets)
+}
@@ -0,0 +1,12 @@
+package lottery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvoiceMemo(t *testing.T) {
+	memo := InvoiceMemo(840_144, 2_000)
+	assert.Equal(t, "BTRY;round=840144;tickets=2000", memo)
+}
@@ -0,0 +1,72 @@
+package lottery
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lottery/engine"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidChecksum is returned when a migration bundle was modified or it was exported for
+// another token.
+var ErrInvalidChecksum = errors.New("invalid migration bundle checksum")
+
+// MigrationBundle is a lottery in progress exported to be imported by another instance.
+//
+// The token is issued by the destination instance, keying the checksum with it binds the bundle to
+// the destination and detects any change made in transit.
+type MigrationBundle struct {
+	// Checksum is the hex encoded HMAC-SHA256 of the round, keyed with the migration token
+	Checksum string         `json:"checksum"`
+	Round    db.RoundExport `json:"round"`
+}
+
+// NewMigrationBundle returns the round exported along with its checksum.
+func NewMigrationBundle(round db.RoundExport, token string) (MigrationBundle, error) {
+	checksum, err := migrationChecksum(round, token)
+	if err != nil {
+		return MigrationBundle{}, err
+	}
+
+	return MigrationBundle{Checksum: checksum, Round: round}, nil
+}
+
+// Verify checks the bundle checksum with the token and that the server seed matches the commitment
+// published.
+func (b MigrationBundle) Verify(token string) error {
+	expected, err := migrationChecksum(b.Round, token)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal([]byte(expected), []byte(b.Checksum)) {
+		return ErrInvalidChecksum
+	}
+
+	seed, err := hex.DecodeString(b.Round.Seed)
+	if err != nil {
+		return errors.Wrap(err, "decoding server seed")
+	}
+
+	if hex.EncodeToString(engine.Commitment(seed)) != b.Round.Commitment {
+		return errors.New("the server seed doesn't match the commitment")
+	}
+
+	return nil
+}
+
+func migrationChecksum(round db.RoundExport, token string) (string, error) {
+	payload, err := json.Marshal(round)
+	if err != nil {
+		return "", errors.Wrap(err, "encoding round")
+	}
+
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,44 @@
+package lottery
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lottery/engine"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationBundle(t *testing.T) {
+	seed := make([]byte, 32)
+	round := db.RoundExport{
+		Height:     144,
+		Seed:       hex.EncodeToString(seed),
+		Commitment: hex.EncodeToString(engine.Commitment(seed)),
+		Bets: []db.MigratedBet{
+			{PublicKey: "1", PaymentHash: "hash", FirstTicket: 1, Index: 100, Tickets: 100},
+		},
+	}
+
+	bundle, err := NewMigrationBundle(round, "token")
+	assert.NoError(t, err)
+	assert.NoError(t, bundle.Verify("token"))
+
+	assert.ErrorIs(t, bundle.Verify("other"), ErrInvalidChecksum)
+
+	bundle.Round.Bets[0].Tickets = 1_000
+	assert.ErrorIs(t, bundle.Verify("token"), ErrInvalidChecksum)
+}
+
+func TestMigrationBundleCommitment(t *testing.T) {
+	round := db.RoundExport{
+		Height:     144,
+		Seed:       hex.EncodeToString(make([]byte, 32)),
+		Commitment: "commitment",
+	}
+
+	bundle, err := NewMigrationBundle(round, "token")
+	assert.NoError(t, err)
+	assert.Error(t, bundle.Verify("token"))
+}
@@ -0,0 +1,45 @@
+package lottery
+
+import (
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// PayoutNotifierMock is a mocked implementation of a payout notifier.
+type PayoutNotifierMock struct {
+	mock.Mock
+}
+
+// NewPayoutNotifierMock returns a mocked payout notifier.
+func NewPayoutNotifierMock() *PayoutNotifierMock {
+	return &PayoutNotifierMock{}
+}
+
+// NotifyPayout mock.
+func (p *PayoutNotifierMock) NotifyPayout(payout db.Payout) {
+	_ = p.Called(payout)
+}
+
+// BetSettingsMock is a mocked implementation of the bet settings.
+type BetSettingsMock struct {
+	mock.Mock
+}
+
+// NewBetSettingsMock returns mocked bet settings.
+func NewBetSettingsMock() *BetSettingsMock {
+	return &BetSettingsMock{}
+}
+
+// Bonus mock.
+func (b *BetSettingsMock) Bonus() config.Bonus {
+	args := b.Called()
+	return args.Get(0).(config.Bonus)
+}
+
+// Pools mock.
+func (b *BetSettingsMock) Pools() Pools {
+	args := b.Called()
+	return args.Get(0).(Pools)
+}
@@ -0,0 +1,217 @@
+package lottery
+
+import (
+	"context"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultUrgentBlocks is the number of blocks before the prizes expire from which their payouts
+// are sent regardless of the routing fees, used when none is configured.
+const DefaultUrgentBlocks = 144
+
+// DefaultProbeAttempts is the number of route probes that must fail in a row before giving up an
+// automatic payout, used when none is configured.
+const DefaultProbeAttempts = 3
+
+// PayoutNotifier notifies the winners the result of the withdrawals paid to their invoices.
+type PayoutNotifier interface {
+	NotifyPayout(payout db.Payout)
+}
+
+// PayoutSchedule decides which automatic payouts are deferred until the routing fees go down.
+type PayoutSchedule config.Payouts
+
+// Enabled returns whether any fee ceiling is configured.
+func (p PayoutSchedule) Enabled() bool {
+	return p.FeeCeilingPPM > 0 || p.FeeCeilingSat > 0
+}
+
+// Urgent returns whether the prizes expiring at deadline must be paid at blockHeight regardless of
+// the routing fees.
+func (p PayoutSchedule) Urgent(deadline, blockHeight uint32) bool {
+	urgentBlocks := p.UrgentBlocks
+	if urgentBlocks == 0 {
+		urgentBlocks = DefaultUrgentBlocks
+	}
+	return deadline <= blockHeight+urgentBlocks
+}
+
+// Expensive returns whether the routing fee of a payout, in sats, is above any of the ceilings.
+func (p PayoutSchedule) Expensive(amount uint64, fee int64) bool {
+	if p.FeeCeilingSat > 0 && fee > p.FeeCeilingSat {
+		return true
+	}
+	return p.FeeCeilingPPM > 0 && fee*1_000_000 > int64(amount)*p.FeeCeilingPPM
+}
+
+// Probed returns whether the payouts of amount sats are preceded by a route probe.
+func (p PayoutSchedule) Probed(amount uint64) bool {
+	return p.ProbeThreshold > 0 && amount >= p.ProbeThreshold
+}
+
+// probePayout looks for a route to the winner node before sending a large payout, returning whether
+// it's likely to succeed. The fee and success probability of every probe are recorded in the audit
+// log. If none of the attempts find a reliable route, the winner is asked to claim the prizes with
+// an invoice instead.
+func (l *Lottery) probePayout(ctx context.Context, publicKey, address string, amount uint64) bool {
+	attempts := l.payoutSchedule.ProbeAttempts
+	if attempts == 0 {
+		attempts = DefaultProbeAttempts
+	}
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		probe, err := l.lnd.ProbeLightningAddress(ctx, address, int64(amount))
+		if err != nil {
+			l.logger.Warningf("Probing %s payout route (attempt %d/%d): %v", publicKey, attempt, attempts, err)
+			continue
+		}
+
+		l.auditor.Record(audit.PayoutProbed, map[string]any{
+			"public_key":          publicKey,
+			"amount":              amount,
+			"address":             address,
+			"fee":                 probe.Fee,
+			"success_probability": probe.SuccessProbability,
+		})
+
+		if probe.SuccessProbability >= l.payoutSchedule.ProbeMinSuccess {
+			return true
+		}
+		l.logger.Warningf("Route to %s has a %.2f success probability (attempt %d/%d)",
+			publicKey, probe.SuccessProbability, attempt, attempts)
+	}
+
+	message, ok := l.render(notification.EventPayoutUnroutable, notification.Data{
+		Prize:   amount,
+		Address: address,
+	})
+	if ok {
+		l.notify(publicKey, message)
+	}
+	return false
+}
+
+// deferPayout schedules the payout of the prizes expiring at deadline for a later block if its
+// estimated routing fee is too high, returning whether it was deferred. Payouts whose fee can't be
+// estimated are not deferred.
+func (l *Lottery) deferPayout(ctx context.Context, publicKey, address string, amount uint64, deadline uint32) bool {
+	// The prizes were assigned a claim window before the deadline at most
+	if !l.payoutSchedule.Enabled() || l.payoutSchedule.Urgent(deadline, deadline-min(deadline, l.claimWindow)) {
+		return false
+	}
+
+	fee, err := l.lnd.EstimateLightningAddressFee(ctx, address, int64(amount))
+	if err != nil {
+		l.logger.Warningf("Estimating %s payout routing fee: %v", publicKey, err)
+		return false
+	}
+
+	if !l.payoutSchedule.Expensive(amount, fee) {
+		return false
+	}
+
+	_, err = l.db.Scheduled.Add(db.ScheduledPayout{
+		PublicKey:    publicKey,
+		Amount:       amount,
+		Deadline:     deadline,
+		EstimatedFee: fee,
+		CreatedAt:    l.now().Unix(),
+	})
+	if err != nil {
+		l.logger.Error(err)
+		return false
+	}
+
+	l.logger.Infof("Deferring %d sats payout to %s, estimated routing fee: %d sats", amount, publicKey, fee)
+	return true
+}
+
+// sendScheduledPayouts sends the deferred payouts whose routing fee went below the ceilings or
+// whose prizes are about to expire, the rest are kept with their new fee estimation.
+//
+// Payouts of prizes that expired or whose winner unlinked the lightning address are discarded,
+// the prizes are claimed manually.
+func (l *Lottery) sendScheduledPayouts(ctx context.Context, blockHeight uint32) error {
+	payouts, err := l.db.Scheduled.List()
+	if err != nil {
+		return err
+	}
+
+	for _, payout := range payouts {
+		if blockHeight >= payout.Deadline {
+			l.discardScheduledPayout(payout.ID)
+			continue
+		}
+
+		address, err := l.db.Lightning.GetAddress(payout.PublicKey)
+		if err != nil {
+			if !errors.Is(err, db.ErrNoAddress) {
+				l.logger.Error(err)
+				continue
+			}
+			l.discardScheduledPayout(payout.ID)
+			continue
+		}
+
+		if !l.payoutSchedule.Urgent(payout.Deadline, blockHeight) {
+			fee, err := l.lnd.EstimateLightningAddressFee(ctx, address, int64(payout.Amount))
+			if err != nil {
+				l.logger.Warningf("Estimating %s payout routing fee: %v", payout.PublicKey, err)
+			} else if l.payoutSchedule.Expensive(payout.Amount, fee) {
+				if err := l.db.Scheduled.Update(payout.ID, fee, l.now().Unix()); err != nil {
+					l.logger.Error(err)
+				}
+				continue
+			}
+		}
+
+		if err := l.db.Scheduled.Delete(payout.ID); err != nil {
+			l.logger.Error(err)
+			continue
+		}
+
+		if l.payoutSchedule.Probed(payout.Amount) &&
+			!l.probePayout(ctx, payout.PublicKey, address, payout.Amount) {
+			continue
+		}
+		l.autoWithdraw(ctx, payout.PublicKey, address, payout.Amount)
+	}
+
+	return nil
+}
+
+// discardScheduledPayout removes a deferred payout, logging errors.
+func (l *Lottery) discardScheduledPayout(id uint64) {
+	if err := l.db.Scheduled.Delete(id); err != nil {
+		l.logger.Error(err)
+	}
+}
+
+// NotifyPayout enqueues a notification with the result of a withdrawal paid to an invoice, which is
+// sent if the winner has enabled the notifications. Payouts in progress are not notified.
+func (l *Lottery) NotifyPayout(payout db.Payout) {
+	var event notification.Event
+	switch payout.Status {
+	case db.PayoutSucceeded:
+		event = notification.EventPayoutSucceeded
+	case db.PayoutFailed:
+		event = notification.EventPayoutFailed
+	default:
+		return
+	}
+
+	message, ok := l.render(event, notification.Data{
+		Prize:    payout.Amount,
+		Preimage: payout.Preimage,
+		Reason:   payout.FailureReason,
+	})
+	if ok {
+		l.enqueue(jobNotify, notifyJob{PublicKey: payout.PublicKey, Message: message})
+	}
+}
@@ -0,0 +1,330 @@
+package lottery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/jobs"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPayoutScheduleExpensive(t *testing.T) {
+	cases := []struct {
+		desc     string
+		schedule PayoutSchedule
+		fee      int64
+		expected bool
+	}{
+		{
+			desc:     "Disabled",
+			schedule: PayoutSchedule{},
+			fee:      1_000,
+		},
+		{
+			desc:     "Below the ceilings",
+			schedule: PayoutSchedule{FeeCeilingPPM: 5_000, FeeCeilingSat: 100},
+			fee:      50,
+		},
+		{
+			desc:     "Above the sats ceiling",
+			schedule: PayoutSchedule{FeeCeilingSat: 100},
+			fee:      101,
+			expected: true,
+		},
+		{
+			desc:     "Above the ppm ceiling",
+			schedule: PayoutSchedule{FeeCeilingPPM: 5_000, FeeCeilingSat: 100},
+			fee:      51,
+			expected: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.schedule.Expensive(10_000, tc.fee))
+		})
+	}
+}
+
+func TestPayoutScheduleUrgent(t *testing.T) {
+	assert.False(t, PayoutSchedule{}.Urgent(1_000, 855))
+	assert.True(t, PayoutSchedule{}.Urgent(1_000, 856))
+	assert.False(t, PayoutSchedule{UrgentBlocks: 10}.Urgent(1_000, 989))
+	assert.True(t, PayoutSchedule{UrgentBlocks: 10}.Urgent(1_000, 990))
+}
+
+func TestTryAutoWithdrawalsDeferred(t *testing.T) {
+	publicKey := "public_key"
+	address := "test@btry.com"
+	prizes := uint64(10_000)
+	now := time.Unix(1_700_000_000, 0)
+
+	lightningMock := db.NewLightningStoreMock()
+	lightningMock.On("GetAddress", publicKey).Return(address, nil)
+
+	scheduledMock := db.NewScheduledPayoutsStoreMock()
+	scheduledMock.On("Add", db.ScheduledPayout{
+		PublicKey:    publicKey,
+		Amount:       prizes,
+		Deadline:     2_000,
+		EstimatedFee: 120,
+		CreatedAt:    now.Unix(),
+	}).Return(uint64(1), nil)
+
+	prizesMock := db.NewPrizesStoreMock()
+
+	lnd := lightning.NewClientMock()
+	lnd.On("EstimateLightningAddressFee", context.Background(), address, int64(prizes)).Return(int64(120), nil)
+
+	db := &db.DB{
+		Lightning: lightningMock,
+		Prizes:    prizesMock,
+		Scheduled: scheduledMock,
+	}
+
+	config := config.Lottery{
+		ClaimWindow: config.ClaimWindow{Blocks: 720},
+		Payouts:     config.Payouts{FeeCeilingSat: 100},
+	}
+	lottery, err := New(config, db, lnd, nil, templates, nil, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	lottery.now = func() time.Time { return now }
+
+	lottery.tryAutoWithdrawals(context.Background(), map[string]uint64{publicKey: prizes}, 2_000)
+
+	scheduledMock.AssertExpectations(t)
+	prizesMock.AssertNotCalled(t, "Withdraw", mock.Anything, mock.Anything)
+}
+
+func TestTryAutoWithdrawalsUrgent(t *testing.T) {
+	publicKey := "public_key"
+	address := "test@btry.com"
+	prizes := uint64(10_000)
+
+	lightningMock := db.NewLightningStoreMock()
+	lightningMock.On("GetAddress", publicKey).Return(address, nil)
+
+	prizesMock := db.NewPrizesStoreMock()
+	prizesMock.On("Withdraw", publicKey, prizes).Return([]db.PrizesRow(nil), errors.New("test"))
+
+	lnd := lightning.NewClientMock()
+
+	db := &db.DB{
+		AccessLists: allowedAccess(),
+		Lightning:   lightningMock,
+		Prizes:      prizesMock,
+	}
+
+	// The claim window is shorter than the urgent blocks
+	config := config.Lottery{
+		ClaimWindow: config.ClaimWindow{Blocks: 100},
+		Payouts:     config.Payouts{FeeCeilingSat: 100},
+	}
+	lottery, err := New(config, db, lnd, nil, templates, nil, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	lottery.tryAutoWithdrawals(context.Background(), map[string]uint64{publicKey: prizes}, 2_000)
+
+	prizesMock.AssertExpectations(t)
+	lnd.AssertNotCalled(t, "EstimateLightningAddressFee", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTryAutoWithdrawalsProbed(t *testing.T) {
+	publicKey := "public_key"
+	address := "test@btry.com"
+	prizes := uint64(1_000_000)
+
+	lightningMock := db.NewLightningStoreMock()
+	lightningMock.On("GetAddress", publicKey).Return(address, nil)
+
+	prizesMock := db.NewPrizesStoreMock()
+	prizesMock.On("Withdraw", publicKey, prizes).Return([]db.PrizesRow(nil), errors.New("test"))
+
+	probe := lightning.RouteProbe{Fee: 120, SuccessProbability: 0.8}
+	lnd := lightning.NewClientMock()
+	lnd.On("ProbeLightningAddress", context.Background(), address, int64(prizes)).Return(probe, nil)
+
+	auditorMock := audit.NewAuditorMock()
+	auditorMock.On("Record", audit.PayoutProbed, map[string]any{
+		"public_key":          publicKey,
+		"amount":              prizes,
+		"address":             address,
+		"fee":                 probe.Fee,
+		"success_probability": probe.SuccessProbability,
+	})
+
+	db := &db.DB{
+		AccessLists: allowedAccess(),
+		Lightning:   lightningMock,
+		Prizes:      prizesMock,
+	}
+
+	config := config.Lottery{
+		Payouts: config.Payouts{ProbeThreshold: 500_000, ProbeMinSuccess: 0.5},
+	}
+	lottery, err := New(config, db, lnd, nil, templates, auditorMock, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	lottery.tryAutoWithdrawals(context.Background(), map[string]uint64{publicKey: prizes}, 0)
+
+	auditorMock.AssertExpectations(t)
+	prizesMock.AssertExpectations(t)
+}
+
+func TestTryAutoWithdrawalsUnroutable(t *testing.T) {
+	publicKey := "public_key"
+	address := "test@btry.com"
+	nostrKey := "nostr_key"
+	prizes := uint64(1_000_000)
+
+	lightningMock := db.NewLightningStoreMock()
+	lightningMock.On("GetAddress", publicKey).Return(address, nil)
+
+	notificationsMock := db.NewNotificationsStoreMock()
+	notificationsMock.On("GetChatID", publicKey).Return(int64(0), db.ErrNoChatID)
+	notificationsMock.On("GetNostrKey", publicKey).Return(nostrKey, nil)
+
+	prizesMock := db.NewPrizesStoreMock()
+
+	lnd := lightning.NewClientMock()
+	lnd.On("ProbeLightningAddress", context.Background(), address, int64(prizes)).
+		Return(lightning.RouteProbe{}, errors.New("no route found"))
+
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("NotifyNostr", mock.Anything, nostrKey)
+
+	db := &db.DB{
+		Lightning:     lightningMock,
+		Notifications: notificationsMock,
+		Prizes:        prizesMock,
+	}
+
+	config := config.Lottery{
+		Payouts: config.Payouts{ProbeThreshold: 500_000, ProbeAttempts: 2},
+	}
+	lottery, err := New(config, db, lnd, notifierMock, templates, nil, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	lottery.tryAutoWithdrawals(context.Background(), map[string]uint64{publicKey: prizes}, 0)
+
+	lnd.AssertNumberOfCalls(t, "ProbeLightningAddress", 2)
+	notifierMock.AssertExpectations(t)
+	prizesMock.AssertNotCalled(t, "Withdraw", mock.Anything, mock.Anything)
+}
+
+func TestSendScheduledPayouts(t *testing.T) {
+	address := "test@btry.com"
+	now := time.Unix(1_700_000_000, 0)
+	payouts := []db.ScheduledPayout{
+		{ID: 1, PublicKey: "expired", Amount: 1_000, Deadline: 1_000},
+		{ID: 2, PublicKey: "unlinked", Amount: 1_000, Deadline: 1_100},
+		{ID: 3, PublicKey: "urgent", Amount: 1_000, Deadline: 1_100},
+		{ID: 4, PublicKey: "expensive", Amount: 1_000, Deadline: 2_000},
+		{ID: 5, PublicKey: "cheap", Amount: 2_000, Deadline: 2_000},
+	}
+
+	scheduledMock := db.NewScheduledPayoutsStoreMock()
+	scheduledMock.On("List").Return(payouts, nil)
+	scheduledMock.On("Delete", uint64(1)).Return(nil).Once()
+	scheduledMock.On("Delete", uint64(2)).Return(nil).Once()
+	scheduledMock.On("Delete", uint64(3)).Return(nil).Once()
+	scheduledMock.On("Update", uint64(4), int64(150), now.Unix()).Return(nil).Once()
+	scheduledMock.On("Delete", uint64(5)).Return(nil).Once()
+
+	lightningMock := db.NewLightningStoreMock()
+	lightningMock.On("GetAddress", "unlinked").Return("", db.ErrNoAddress)
+	lightningMock.On("GetAddress", mock.Anything).Return(address, nil)
+
+	// Withdrawals fail to stop the payouts after the prizes are withdrawn
+	prizesMock := db.NewPrizesStoreMock()
+	prizesMock.On("Withdraw", "urgent", uint64(1_000)).Return([]db.PrizesRow(nil), errors.New("test")).Once()
+	prizesMock.On("Withdraw", "cheap", uint64(2_000)).Return([]db.PrizesRow(nil), errors.New("test")).Once()
+
+	lnd := lightning.NewClientMock()
+	lnd.On("EstimateLightningAddressFee", context.Background(), address, int64(1_000)).Return(int64(150), nil)
+	lnd.On("EstimateLightningAddressFee", context.Background(), address, int64(2_000)).Return(int64(50), nil)
+
+	db := &db.DB{
+		AccessLists: allowedAccess(),
+		Lightning:   lightningMock,
+		Prizes:      prizesMock,
+		Scheduled:   scheduledMock,
+	}
+
+	config := config.Lottery{Payouts: config.Payouts{FeeCeilingSat: 100}}
+	lottery, err := New(config, db, lnd, nil, templates, nil, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	lottery.now = func() time.Time { return now }
+
+	err = lottery.sendScheduledPayouts(context.Background(), 1_000)
+	assert.NoError(t, err)
+
+	scheduledMock.AssertExpectations(t)
+	prizesMock.AssertExpectations(t)
+}
+
+func TestNotifyPayout(t *testing.T) {
+	publicKey := "public_key"
+
+	cases := []struct {
+		desc     string
+		payout   db.Payout
+		expected string
+	}{
+		{
+			desc: "Succeeded",
+			payout: db.Payout{
+				PublicKey: publicKey,
+				Status:    db.PayoutSucceeded,
+				Amount:    1_010,
+				Preimage:  "preimage",
+			},
+			expected: "Your withdrawal of 1010 sats was paid. Preimage: preimage",
+		},
+		{
+			desc: "Failed",
+			payout: db.Payout{
+				PublicKey:     publicKey,
+				Status:        db.PayoutFailed,
+				Amount:        1_010,
+				FailureReason: "FAILURE_REASON_NO_ROUTE",
+			},
+			expected: "Your withdrawal of 1010 sats failed (FAILURE_REASON_NO_ROUTE), the prizes were " +
+				"returned and can be claimed again with another invoice before they expire.",
+		},
+		{
+			desc:   "In flight",
+			payout: db.Payout{PublicKey: publicKey, Status: db.PayoutInFlight, Amount: 1_010},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			queueMock := jobs.NewQueueMock()
+			if tc.expected != "" {
+				queueMock.On("Enqueue", jobNotify, notifyJob{PublicKey: publicKey, Message: tc.expected}).
+					Return(nil)
+			}
+
+			lottery, err := New(config.Lottery{}, nil, nil, nil, templates, nil, nil, nil, queueMock, nil,
+				nil, nil)
+			assert.NoError(t, err)
+
+			lottery.NotifyPayout(tc.payout)
+
+			queueMock.AssertExpectations(t)
+			if tc.expected == "" {
+				queueMock.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package lottery
+
+import (
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/lottery/engine"
+)
+
+// defaultPool takes all the bets when no pools are configured.
+var defaultPool = config.Pool{Capacity: 100}
+
+// Pools contains the segments of the lottery, bets are routed to them depending on their amount.
+type Pools []config.Pool
+
+// NewPools returns the lottery pools. If none is configured, all bets go to a single unnamed one.
+func NewPools(pools []config.Pool) Pools {
+	if len(pools) == 0 {
+		return Pools{defaultPool}
+	}
+	return pools
+}
+
+// Route returns the pool that accepts bets of the amount specified.
+func (p Pools) Route(amount uint64) (config.Pool, bool) {
+	for _, pool := range p {
+		if amount < pool.MinAmount {
+			continue
+		}
+		if pool.MaxAmount != 0 && amount > pool.MaxAmount {
+			continue
+		}
+		return pool, true
+	}
+
+	return config.Pool{}, false
+}
+
+// Distribution returns the prize table of a pool. Pools without one, or that are no longer
+// configured, use the default.
+func (p Pools) Distribution(name string) engine.Distribution {
+	for _, pool := range p {
+		if pool.Name == name && len(pool.Distribution) > 0 {
+			return pool.Distribution
+		}
+	}
+
+	return engine.DefaultDistribution
+}
@@ -0,0 +1,56 @@
+package lottery
+
+import (
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/lottery/engine"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoute(t *testing.T) {
+	pools := NewPools([]config.Pool{
+		{Name: "micro", MinAmount: 100, MaxAmount: 9_999, Capacity: 20},
+		{Name: "standard", MinAmount: 10_000, MaxAmount: 999_999, Capacity: 50},
+		{Name: "whale", MinAmount: 1_000_000, Capacity: 30},
+	})
+
+	cases := []struct {
+		desc     string
+		expected string
+		amount   uint64
+		ok       bool
+	}{
+		{desc: "Below all pools", amount: 99},
+		{desc: "Micro lower bound", amount: 100, expected: "micro", ok: true},
+		{desc: "Micro upper bound", amount: 9_999, expected: "micro", ok: true},
+		{desc: "Standard", amount: 10_000, expected: "standard", ok: true},
+		{desc: "Whale without upper bound", amount: 50_000_000, expected: "whale", ok: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			pool, ok := pools.Route(tc.amount)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, pool.Name)
+		})
+	}
+}
+
+func TestRouteDefaultPool(t *testing.T) {
+	pool, ok := NewPools(nil).Route(1)
+	assert.True(t, ok)
+	assert.Equal(t, defaultPool, pool)
+}
+
+func TestDistribution(t *testing.T) {
+	pools := NewPools([]config.Pool{
+		{Name: "micro", Capacity: 50, Distribution: []float64{60, 30}},
+		{Name: "whale", Capacity: 50},
+	})
+
+	assert.Equal(t, engine.Distribution{60, 30}, pools.Distribution("micro"))
+	assert.Equal(t, engine.DefaultDistribution, pools.Distribution("whale"))
+	assert.Equal(t, engine.DefaultDistribution, pools.Distribution("removed"))
+}
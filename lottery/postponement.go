@@ -0,0 +1,202 @@
+package lottery
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
+	"github.com/pkg/errors"
+)
+
+// defaultPostponementBlocks is the number of blocks a draw is postponed when none is configured.
+const defaultPostponementBlocks = 6
+
+// ActivePostponement returns the postponement of the lottery whose draw is waiting for liquidity,
+// or nil if there's none. Bets are not accepted until it's drawn.
+func ActivePostponement(postponements db.PostponementsStore) (*db.Postponement, error) {
+	postponement, err := postponements.GetActive()
+	if err != nil {
+		if errors.Is(err, db.ErrPostponementNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &postponement, nil
+}
+
+// deferDraw returns whether the draw of the lottery of the target block must wait for the node to
+// be able to pay its prizes, postponing it for the configured number of blocks if it can't. The
+// draw of a postponed lottery is only attempted again once the chain reaches its resume height.
+//
+// The lottery is drawn with the target block hash when it's resumed, so postponing it doesn't
+// change the winners.
+func (l *Lottery) deferDraw(ctx context.Context, block *chainrpc.BlockEpoch, tip uint32) (bool, error) {
+	postponement, err := ActivePostponement(l.db.Postponements)
+	if err != nil {
+		return false, err
+	}
+	if postponement != nil && postponement.LotteryHeight == block.Height && tip < postponement.ResumeHeight {
+		return true, nil
+	}
+
+	pools, err := l.db.Bets.ListPools(block.Height)
+	if err != nil {
+		return false, errors.Wrap(err, "listing pools")
+	}
+
+	// Lotteries without bets have no prizes to pay
+	if len(pools) == 0 {
+		return false, nil
+	}
+
+	liabilities, localBalance, err := l.liabilities(ctx, block.Height, pools)
+	if err != nil {
+		return false, err
+	}
+
+	if localBalance >= liabilities {
+		if postponement != nil && postponement.LotteryHeight == block.Height {
+			if err := l.resumeDraw(block.Height, tip, liabilities, localBalance); err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	}
+
+	blocks := l.postponement.Blocks
+	if blocks == 0 {
+		blocks = defaultPostponementBlocks
+	}
+
+	next := db.Postponement{
+		LotteryHeight: block.Height,
+		BlockHash:     hex.EncodeToString(block.Hash),
+		Liabilities:   liabilities,
+		LocalBalance:  localBalance,
+		PostponedAt:   tip,
+		ResumeHeight:  tip + blocks,
+	}
+	if err := l.db.Postponements.Postpone(next); err != nil {
+		return false, err
+	}
+
+	l.logger.Warningf("Postponing lottery %d draw until block %d, the local balance of %d sats "+
+		"doesn't cover the %d sats owed", block.Height, next.ResumeHeight, localBalance, liabilities)
+	l.auditor.Record(audit.DrawPostponed, map[string]any{
+		"lottery_height": block.Height,
+		"resume_height":  next.ResumeHeight,
+		"liabilities":    liabilities,
+		"local_balance":  localBalance,
+	})
+
+	// Players are only told the first time, the draw may be postponed several times in a row
+	if postponement == nil {
+		l.notifyPostponement(block.Height, blocks)
+	}
+
+	return true, nil
+}
+
+// resumeDraw records that the postponed draw of the lottery takes place at height.
+func (l *Lottery) resumeDraw(lotteryHeight, height uint32, liabilities, localBalance uint64) error {
+	if err := l.db.Postponements.Resume(lotteryHeight, height); err != nil {
+		return err
+	}
+
+	l.logger.Infof("Resuming lottery %d draw, the local balance of %d sats covers the %d sats owed",
+		lotteryHeight, localBalance, liabilities)
+	l.auditor.Record(audit.DrawResumed, map[string]any{
+		"lottery_height": lotteryHeight,
+		"height":         height,
+		"liabilities":    liabilities,
+		"local_balance":  localBalance,
+	})
+	return nil
+}
+
+// liabilities returns the sats the node owes once the lottery is drawn, the prizes that weren't
+// withdrawn plus the prize pools of the lottery, and the local balance of its channels to pay them.
+//
+// Force closed channels are not listed, their balances are on-chain and can't pay the winners.
+func (l *Lottery) liabilities(ctx context.Context, lotteryHeight uint32, pools []string) (uint64, uint64, error) {
+	owed, err := l.db.Prizes.GetTotal()
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "getting prizes owed")
+	}
+
+	for _, pool := range pools {
+		prizePool, err := l.db.Bets.GetPrizePool(lotteryHeight, pool)
+		if err != nil {
+			return 0, 0, err
+		}
+		owed += prizePool
+	}
+
+	channels, err := l.lnd.ListChannels(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var localBalance int64
+	for _, channel := range channels {
+		localBalance += channel.LocalBalance
+	}
+
+	return owed, uint64(max(localBalance, 0)), nil
+}
+
+// notifyPostponement tells the players of the lottery that its draw was postponed.
+func (l *Lottery) notifyPostponement(lotteryHeight, blocks uint32) {
+	bets, err := l.listBets(lotteryHeight)
+	if err != nil {
+		l.logger.Error(errors.Wrap(err, "listing postponed lottery bets"))
+		return
+	}
+
+	message, ok := l.render(notification.EventPostponed, notification.Data{
+		Height:     lotteryHeight,
+		BlocksLeft: blocks,
+	})
+	if !ok {
+		return
+	}
+
+	notified := make(map[string]struct{}, len(bets))
+	for _, bet := range bets {
+		if _, ok := notified[bet.PublicKey]; ok {
+			continue
+		}
+		notified[bet.PublicKey] = struct{}{}
+		l.enqueue(jobNotify, notifyJob{PublicKey: bet.PublicKey, Message: message})
+	}
+}
+
+// restorePostponement returns the target block of the first lottery pending if its draw was
+// postponed, so it's retried after a restart instead of being skipped as missed.
+func (l *Lottery) restorePostponement(pending []uint32) (*chainrpc.BlockEpoch, error) {
+	if !l.postponement.Enabled {
+		return nil, nil
+	}
+
+	postponement, err := ActivePostponement(l.db.Postponements)
+	if err != nil {
+		return nil, err
+	}
+	if postponement == nil || postponement.LotteryHeight != pending[0] {
+		return nil, nil
+	}
+
+	hash, err := hex.DecodeString(postponement.BlockHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding postponed block hash")
+	}
+
+	l.logger.Infof("Lottery %d draw is postponed until block %d", postponement.LotteryHeight,
+		postponement.ResumeHeight)
+	return &chainrpc.BlockEpoch{Hash: hash, Height: postponement.LotteryHeight}, nil
+}
@@ -0,0 +1,295 @@
+package lottery
+
+import (
+	"context"
+	"encoding/hex"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/jobs"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/notification"
+	"github.com/aftermath2/BTRY/watchdog"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDeferDraw(t *testing.T) {
+	lotteryHeight := uint32(900_000)
+	block := &chainrpc.BlockEpoch{Hash: []byte{1, 2}, Height: lotteryHeight}
+	message := "The draw of the lottery 900000 was postponed because the node can't pay its prizes " +
+		"right now, it will be attempted again in 3 blocks. Your tickets are kept, new bets are " +
+		"paused until the draw takes place."
+
+	cases := []struct {
+		active       *db.Postponement
+		postponed    *db.Postponement
+		desc         string
+		localBalance int64
+		tip          uint32
+		notified     bool
+		resumed      bool
+		wait         bool
+	}{
+		{
+			desc:         "Covered",
+			tip:          lotteryHeight,
+			localBalance: 1_600_000,
+		},
+		{
+			desc:         "Postponed",
+			tip:          lotteryHeight,
+			localBalance: 1_000_000,
+			postponed: &db.Postponement{
+				LotteryHeight: lotteryHeight,
+				BlockHash:     "0102",
+				Liabilities:   1_527_224 + 50_000,
+				LocalBalance:  1_000_000,
+				PostponedAt:   lotteryHeight,
+				ResumeHeight:  lotteryHeight + 3,
+			},
+			notified: true,
+			wait:     true,
+		},
+		{
+			desc:   "Waiting",
+			tip:    lotteryHeight + 2,
+			active: &db.Postponement{LotteryHeight: lotteryHeight, ResumeHeight: lotteryHeight + 3},
+			wait:   true,
+		},
+		{
+			desc:         "Postponed again",
+			tip:          lotteryHeight + 3,
+			localBalance: 1_500_000,
+			active:       &db.Postponement{LotteryHeight: lotteryHeight, ResumeHeight: lotteryHeight + 3},
+			postponed: &db.Postponement{
+				LotteryHeight: lotteryHeight,
+				BlockHash:     "0102",
+				Liabilities:   1_527_224 + 50_000,
+				LocalBalance:  1_500_000,
+				PostponedAt:   lotteryHeight + 3,
+				ResumeHeight:  lotteryHeight + 6,
+			},
+			wait: true,
+		},
+		{
+			desc:         "Resumed",
+			tip:          lotteryHeight + 3,
+			localBalance: 2_000_000,
+			active:       &db.Postponement{LotteryHeight: lotteryHeight, ResumeHeight: lotteryHeight + 3},
+			resumed:      true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			postponementsMock := db.NewPostponementsStoreMock()
+			if tc.active != nil {
+				postponementsMock.On("GetActive").Return(*tc.active, nil)
+			} else {
+				postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+			}
+			if tc.postponed != nil {
+				postponementsMock.On("Postpone", *tc.postponed).Return(nil).Once()
+			}
+			if tc.resumed {
+				postponementsMock.On("Resume", lotteryHeight, tc.tip).Return(nil).Once()
+			}
+			betsMock := db.NewBetsStoreMock()
+			betsMock.On("ListPools", lotteryHeight).Return([]string{""}, nil)
+			betsMock.On("GetPrizePool", lotteryHeight, "").Return(bets[len(bets)-1].Index, nil)
+			betsMock.On("List", lotteryHeight, "", uint64(0), uint64(0), false).
+				Return(append(bets, bets[0]), nil)
+			prizesMock := db.NewPrizesStoreMock()
+			prizesMock.On("GetTotal").Return(uint64(50_000), nil)
+			lndMock := lightning.NewClientMock()
+			lndMock.On("ListChannels", mock.Anything).Return([]*lnrpc.Channel{
+				{LocalBalance: tc.localBalance / 2},
+				{LocalBalance: tc.localBalance / 2},
+			}, nil)
+			auditorMock := audit.NewAuditorMock()
+			auditorMock.On("Record", audit.DrawPostponed, mock.Anything)
+			auditorMock.On("Record", audit.DrawResumed, mock.Anything)
+			queueMock := jobs.NewQueueMock()
+			queueMock.On("Enqueue", jobNotify, mock.Anything).Return(nil)
+
+			database := &db.DB{Bets: betsMock, Postponements: postponementsMock, Prizes: prizesMock}
+			config := config.Lottery{Postponement: config.Postponement{Enabled: true, Blocks: 3}}
+			lottery, err := New(config, database, lndMock, nil, templates, auditorMock, nil, nil, queueMock,
+				nil, nil, nil)
+			assert.NoError(t, err)
+
+			wait, err := lottery.deferDraw(context.Background(), block, tc.tip)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wait, wait)
+
+			postponementsMock.AssertExpectations(t)
+			if tc.notified {
+				// Players with several bets are notified once
+				for _, publicKey := range []string{"1", "2", "3"} {
+					queueMock.AssertCalled(t, "Enqueue", jobNotify,
+						notifyJob{PublicKey: publicKey, Message: message})
+				}
+				queueMock.AssertNumberOfCalls(t, "Enqueue", 3)
+			} else {
+				queueMock.AssertNotCalled(t, "Enqueue", jobNotify, mock.Anything)
+			}
+			if tc.postponed == nil {
+				auditorMock.AssertNotCalled(t, "Record", audit.DrawPostponed, mock.Anything)
+			}
+			if !tc.resumed {
+				auditorMock.AssertNotCalled(t, "Record", audit.DrawResumed, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestDeferDrawWithoutBets(t *testing.T) {
+	postponementsMock := db.NewPostponementsStoreMock()
+	postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound)
+	betsMock := db.NewBetsStoreMock()
+	betsMock.On("ListPools", uint32(144)).Return([]string{}, nil)
+
+	database := &db.DB{Bets: betsMock, Postponements: postponementsMock}
+	lottery, err := New(config.Lottery{}, database, nil, nil, templates, nil, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	wait, err := lottery.deferDraw(context.Background(), &chainrpc.BlockEpoch{Height: 144}, 144)
+	assert.NoError(t, err)
+	assert.False(t, wait)
+}
+
+func TestRestorePostponement(t *testing.T) {
+	postponementsMock := db.NewPostponementsStoreMock()
+	postponementsMock.On("GetActive").
+		Return(db.Postponement{LotteryHeight: 144, BlockHash: "0102", ResumeHeight: 150}, nil)
+
+	config := config.Lottery{Postponement: config.Postponement{Enabled: true}}
+	lottery, err := New(config, &db.DB{Postponements: postponementsMock}, nil, nil, templates, nil, nil,
+		nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	block, err := lottery.restorePostponement([]uint32{144, 288})
+	assert.NoError(t, err)
+	assert.Equal(t, &chainrpc.BlockEpoch{Hash: []byte{1, 2}, Height: 144}, block)
+
+	// Only the first lottery pending may be postponed
+	block, err = lottery.restorePostponement([]uint32{288})
+	assert.NoError(t, err)
+	assert.Nil(t, block)
+
+	lottery.postponement.Enabled = false
+	block, err = lottery.restorePostponement([]uint32{144, 288})
+	assert.NoError(t, err)
+	assert.Nil(t, block)
+	postponementsMock.AssertNumberOfCalls(t, "GetActive", 2)
+}
+
+func TestStartPostponesDraw(t *testing.T) {
+	nextHeight := uint32(900_000)
+	config := config.Lottery{
+		Duration:     144,
+		Postponement: config.Postponement{Enabled: true, Blocks: 1},
+	}
+	drawn := make(chan struct{})
+
+	betsMock := db.NewBetsStoreMock()
+	// The liquidity is checked on both blocks and the players notified, the draw finds no bets to
+	// keep the test short
+	betsMock.On("ListPools", nextHeight).Return([]string{""}, nil).Times(3)
+	betsMock.On("ListPools", nextHeight).Return([]string{}, nil).Once()
+	betsMock.On("GetPrizePool", nextHeight, "").Return(uint64(100_000), nil)
+	betsMock.On("List", nextHeight, "", uint64(0), uint64(0), false).Return(bets, nil)
+	betsMock.On("Compact", nextHeight).Return(uint64(0), nil).
+		Run(func(mock.Arguments) { close(drawn) })
+	lotteryMock := db.NewLotteriesStoreMock()
+	lotteryMock.On("GetNextHeight").Return(nextHeight, nil)
+	lotteryMock.On("AddHeight", nextHeight+config.Duration, mock.Anything, mock.Anything).Return(nil)
+	lotteryMock.On("IsDrawn", nextHeight).Return(false, nil).Once()
+	hash := "000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6"
+	postponement := db.Postponement{
+		LotteryHeight: nextHeight,
+		BlockHash:     hash,
+		Liabilities:   100_000,
+		LocalBalance:  10_000,
+		PostponedAt:   nextHeight,
+		ResumeHeight:  nextHeight + 1,
+	}
+	postponementsMock := db.NewPostponementsStoreMock()
+	// Nothing is postponed at startup nor at the target block
+	postponementsMock.On("GetActive").Return(db.Postponement{}, db.ErrPostponementNotFound).Twice()
+	postponementsMock.On("Postpone", postponement).Return(nil).Once()
+	postponementsMock.On("GetActive").Return(postponement, nil).Once()
+	postponementsMock.On("Resume", nextHeight, nextHeight+1).Return(nil).Once()
+	prizesMock := db.NewPrizesStoreMock()
+	prizesMock.On("GetTotal").Return(uint64(0), nil)
+	claimCodesMock := db.NewClaimCodesStoreMock()
+	claimCodesMock.On("DeleteExpired", mock.Anything).Return(uint64(0), nil).Maybe()
+	winnersMock := db.NewWinnersStoreMock()
+	winnersMock.On("ListUnclaimed").Return(nil, nil)
+	revealsMock := db.NewRevealsStoreMock()
+	revealsMock.On("ListPending").Return(nil, nil)
+	db := &db.DB{
+		Bets:          betsMock,
+		ClaimCodes:    claimCodesMock,
+		Lotteries:     lotteryMock,
+		Postponements: postponementsMock,
+		Prizes:        prizesMock,
+		Reveals:       revealsMock,
+		Winners:       winnersMock,
+	}
+
+	lnd := lightning.NewClientMock()
+	lnd.On("GetInfo", context.Background()).Return(&lnrpc.GetInfoResponse{BlockHeight: nextHeight - 1}, nil)
+	lnd.On("ListChannels", mock.Anything).Return([]*lnrpc.Channel{{LocalBalance: 10_000}}, nil).Once()
+	lnd.On("ListChannels", mock.Anything).Return([]*lnrpc.Channel{{LocalBalance: 200_000}}, nil).Once()
+
+	watchdogMock := watchdog.NewWatchdogMock()
+	watchdogMock.On("Observe", mock.Anything)
+	watchdogMock.On("Verify", mock.Anything, nextHeight, hash).Return(nil).Twice()
+	auditorMock := audit.NewAuditorMock()
+	auditorMock.On("Record", audit.DrawPostponed, mock.Anything).Once()
+	auditorMock.On("Record", audit.DrawResumed, mock.Anything).Once()
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("PublishCommitment", nextHeight+config.Duration, mock.Anything).Return(nil)
+	queueMock := newQueueMock()
+	queueMock.On("Enqueue", jobNotify, mock.Anything).Return(nil).Times(3)
+	queueMock.On("Enqueue", jobExpirePrizes, expirePrizesJob{Height: nextHeight}).Return(nil).Once()
+	queueMock.On("Enqueue", jobRetryDeliveries, struct{}{}).Return(nil).Maybe()
+
+	blocksCh := make(chan *chainrpc.BlockEpoch)
+	lottery, err := New(config, db, lnd, notifierMock, templates, auditorMock, watchdogMock, newLeaderMock(),
+		queueMock, nil, nil, blocksCh)
+	assert.NoError(t, err)
+
+	err = lottery.Start()
+	assert.NoError(t, err)
+
+	// Block hash bytes are reversed in the epochs
+	blockHash, err := hex.DecodeString(hash)
+	assert.NoError(t, err)
+	slices.Reverse(blockHash)
+
+	blocksCh <- &chainrpc.BlockEpoch{Hash: blockHash, Height: nextHeight}
+	lotteryMock.AssertNotCalled(t, "IsDrawn", nextHeight)
+
+	// The postponed draw is resumed with the target block once the node can pay it
+	blocksCh <- &chainrpc.BlockEpoch{Hash: []byte{1}, Height: nextHeight + 1}
+
+	select {
+	case <-drawn:
+	case <-time.After(time.Second):
+		t.Fatal("lottery was not drawn")
+	}
+	postponementsMock.AssertExpectations(t)
+	auditorMock.AssertExpectations(t)
+	queueMock.AssertExpectations(t)
+	watchdogMock.AssertExpectations(t)
+}
@@ -0,0 +1,69 @@
+package lottery
+
+import (
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+)
+
+// StatsPrivacy hides and coarsens the public statistics so they don't reveal how much individual
+// players bet, which is trivial to infer when few of them participate in a lottery.
+//
+// The same policy is applied to every place the statistics are published, otherwise the values
+// hidden in one could be recovered from another.
+type StatsPrivacy config.StatsPrivacy
+
+// Hidden returns whether the statistics of a lottery with the number of players specified are
+// withheld.
+func (p StatsPrivacy) Hidden(players uint64) bool {
+	return players < p.MinPlayers
+}
+
+// Amount returns the amount rounded down to the bucket size.
+func (p StatsPrivacy) Amount(amount uint64) uint64 {
+	if p.Bucket == 0 {
+		return amount
+	}
+	return amount - amount%p.Bucket
+}
+
+// Stats returns the all-time statistics with their amounts bucketed.
+func (p StatsPrivacy) Stats(stats db.Stats) db.Stats {
+	stats.TotalPool = p.Amount(stats.TotalPool)
+	stats.AveragePool = p.Amount(stats.AveragePool)
+	stats.TotalPaidOut = p.Amount(stats.TotalPaidOut)
+	return stats
+}
+
+// Round returns the statistics of the lottery with its prize pool bucketed, or only its height if
+// it had too few players.
+func (p StatsPrivacy) Round(round db.RoundStats) db.RoundStats {
+	if p.Hidden(round.Players) {
+		return db.RoundStats{Height: round.Height, Hidden: true}
+	}
+
+	round.PrizePool = p.Amount(round.PrizePool)
+	return round
+}
+
+// Rounds applies the policy to the statistics of each lottery.
+func (p StatsPrivacy) Rounds(rounds []db.RoundStats) []db.RoundStats {
+	for i, round := range rounds {
+		rounds[i] = p.Round(round)
+	}
+	return rounds
+}
+
+// Wins returns the leaderboard of wins without the ones from lotteries with too few players and
+// with the prizes bucketed.
+func (p StatsPrivacy) Wins(wins []db.Win) []db.Win {
+	visible := wins[:0]
+	for _, win := range wins {
+		if p.Hidden(win.Players) {
+			continue
+		}
+
+		win.Prize = p.Amount(win.Prize)
+		visible = append(visible, win)
+	}
+	return visible
+}
@@ -0,0 +1,52 @@
+package lottery
+
+import (
+	"testing"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsPrivacyAmount(t *testing.T) {
+	assert.Equal(t, uint64(12_345), StatsPrivacy{}.Amount(12_345))
+	assert.Equal(t, uint64(12_000), StatsPrivacy{Bucket: 1_000}.Amount(12_345))
+	assert.Equal(t, uint64(0), StatsPrivacy{Bucket: 1_000}.Amount(999))
+}
+
+func TestStatsPrivacyStats(t *testing.T) {
+	privacy := StatsPrivacy{Bucket: 1_000}
+	stats := db.Stats{Rounds: 3, TotalPool: 7_500, AveragePool: 2_500, TotalPaidOut: 4_200, Payouts: 2}
+
+	expected := db.Stats{Rounds: 3, TotalPool: 7_000, AveragePool: 2_000, TotalPaidOut: 4_000, Payouts: 2}
+	assert.Equal(t, expected, privacy.Stats(stats))
+}
+
+func TestStatsPrivacyRounds(t *testing.T) {
+	privacy := StatsPrivacy{MinPlayers: 3, Bucket: 1_000}
+	rounds := []db.RoundStats{
+		{Height: 144, PrizePool: 5_500, Players: 2, Winners: 1},
+		{Height: 288, PrizePool: 12_345, Players: 4, Winners: 2},
+	}
+
+	expected := []db.RoundStats{
+		{Height: 144, Hidden: true},
+		{Height: 288, PrizePool: 12_000, Players: 4, Winners: 2},
+	}
+	assert.Equal(t, expected, privacy.Rounds(rounds))
+}
+
+func TestStatsPrivacyWins(t *testing.T) {
+	privacy := StatsPrivacy{MinPlayers: 3, Bucket: 100}
+	wins := []db.Win{
+		{Height: 144, Prize: 5_050, Players: 2},
+		{Height: 288, Prize: 1_234, Players: 5},
+		{Height: 432, Prize: 999, Players: 3},
+	}
+
+	expected := []db.Win{
+		{Height: 288, Prize: 1_200, Players: 5},
+		{Height: 432, Prize: 900, Players: 3},
+	}
+	assert.Equal(t, expected, privacy.Wins(wins))
+}
@@ -0,0 +1,107 @@
+package lottery
+
+import (
+	"time"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/pkg/errors"
+)
+
+// Fractions of the claim window after which the winners are reminded to claim their prizes.
+const (
+	firstReminder = 0.5
+	finalReminder = 0.9
+)
+
+// AtRisk summarizes the prizes that are close to expiring.
+type AtRisk struct {
+	// Count is the number of prizes past the first reminder
+	Count int `json:"count"`
+	// Final is the number of prizes past the final reminder
+	Final int `json:"final"`
+	// Amount is the sum of the prizes at risk, in satoshis
+	Amount uint64 `json:"amount"`
+}
+
+// PrizesAtRisk returns the summary of the unclaimed prizes whose first reminder was due at the
+// block height specified.
+func PrizesAtRisk(prizes []db.UnclaimedPrize, blockHeight uint32) AtRisk {
+	var atRisk AtRisk
+	for _, prize := range prizes {
+		if blockHeight >= prize.ClaimDeadline || blockHeight < reminderHeight(prize, firstReminder) {
+			continue
+		}
+
+		atRisk.Count++
+		atRisk.Amount += prize.Amount
+		if blockHeight >= reminderHeight(prize, finalReminder) {
+			atRisk.Final++
+		}
+	}
+
+	return atRisk
+}
+
+// remindWinners enqueues a notification to the winners whose prizes reached a reminder threshold
+// since they were last reminded. Errors are only logged as they must not interrupt the draws.
+func (l *Lottery) remindWinners(blockHeight uint32) {
+	prizes, err := l.db.Winners.ListUnclaimed()
+	if err != nil {
+		l.logger.Error(errors.Wrap(err, "listing unclaimed prizes"))
+		return
+	}
+
+	for _, prize := range prizes {
+		message, ok := l.reminder(prize, blockHeight)
+		if !ok {
+			continue
+		}
+
+		l.enqueue(jobNotify, notifyJob{PublicKey: prize.PublicKey, Message: message})
+
+		err := l.db.Winners.SetReminded(prize.PublicKey, prize.LotteryHeight, blockHeight)
+		if err != nil {
+			l.logger.Error(err)
+		}
+	}
+}
+
+// reminder returns the message to send to the winner of the prize at the block height specified,
+// if any. Only the final reminder is sent if both thresholds were reached since the last one.
+func (l *Lottery) reminder(prize db.UnclaimedPrize, blockHeight uint32) (string, bool) {
+	if blockHeight >= prize.ClaimDeadline {
+		return "", false
+	}
+
+	blocksLeft := prize.ClaimDeadline - blockHeight
+	expirationTime := l.now().Add(time.Duration(blocksLeft) * blockTime).UTC()
+	deadline := expirationTime.Format(notification.DeadlineLayout)
+
+	data := notification.Data{
+		Prize:          prize.Amount,
+		Height:         prize.LotteryHeight,
+		DeadlineHeight: prize.ClaimDeadline,
+		Deadline:       deadline,
+		BlocksLeft:     blocksLeft,
+	}
+
+	final := reminderHeight(prize, finalReminder)
+	if blockHeight >= final && prize.LastRemindedAt < final {
+		return l.render(notification.EventFinalReminder, data)
+	}
+
+	first := reminderHeight(prize, firstReminder)
+	if blockHeight >= first && prize.LastRemindedAt < first {
+		return l.render(notification.EventReminder, data)
+	}
+
+	return "", false
+}
+
+// reminderHeight returns the block height at which the fraction of the prize claim window elapses.
+func reminderHeight(prize db.UnclaimedPrize, fraction float64) uint32 {
+	window := prize.ClaimDeadline - prize.LotteryHeight
+	return prize.LotteryHeight + uint32(float64(window)*fraction)
+}
@@ -0,0 +1,108 @@
+package lottery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/jobs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRemindWinners(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	prize := db.UnclaimedPrize{
+		PublicKey:     "public_key",
+		Amount:        100,
+		LotteryHeight: 1000,
+		ClaimDeadline: 1720,
+	}
+
+	cases := []struct {
+		desc           string
+		message        string
+		lastRemindedAt uint32
+		blockHeight    uint32
+	}{
+		{
+			desc:        "First reminder",
+			blockHeight: 1360,
+			message: "Reminder: you have 100 sats of unclaimed prizes from the lottery 1000, they " +
+				"expire at block 1720 (approximately May 13, 2024 00:00 UTC).",
+		},
+		{
+			desc:           "Already reminded",
+			blockHeight:    1400,
+			lastRemindedAt: 1360,
+		},
+		{
+			desc:           "Final reminder",
+			blockHeight:    1648,
+			lastRemindedAt: 1360,
+			message: "Last reminder: your 100 sats of unclaimed prizes from the lottery 1000 expire " +
+				"in 72 blocks, at block 1720 (approximately May 11, 2024 00:00 UTC). Claim them " +
+				"before they are lost.",
+		},
+		{
+			desc:        "Only the final reminder is sent",
+			blockHeight: 1700,
+			message: "Last reminder: your 100 sats of unclaimed prizes from the lottery 1000 expire " +
+				"in 20 blocks, at block 1720 (approximately May 10, 2024 15:20 UTC). Claim them " +
+				"before they are lost.",
+		},
+		{
+			desc:        "Before the first reminder",
+			blockHeight: 1359,
+		},
+		{
+			desc:        "Expired",
+			blockHeight: 1720,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			unclaimed := prize
+			unclaimed.LastRemindedAt = tc.lastRemindedAt
+
+			winnersMock := db.NewWinnersStoreMock()
+			winnersMock.On("ListUnclaimed").Return([]db.UnclaimedPrize{unclaimed}, nil)
+			winnersMock.On("SetReminded", prize.PublicKey, prize.LotteryHeight, tc.blockHeight).Return(nil)
+			queueMock := jobs.NewQueueMock()
+			queueMock.On("Enqueue", jobNotify, mock.Anything).Return(nil)
+
+			lottery, err := New(config.Lottery{}, &db.DB{Winners: winnersMock}, nil, nil, templates, nil, nil, nil,
+				queueMock, nil, nil, nil)
+			assert.NoError(t, err)
+			lottery.now = func() time.Time { return now }
+
+			lottery.remindWinners(tc.blockHeight)
+
+			if tc.message == "" {
+				queueMock.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+				winnersMock.AssertNotCalled(t, "SetReminded", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			job := notifyJob{PublicKey: prize.PublicKey, Message: tc.message}
+			queueMock.AssertCalled(t, "Enqueue", jobNotify, job)
+			winnersMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPrizesAtRisk(t *testing.T) {
+	prizes := []db.UnclaimedPrize{
+		{PublicKey: "1", Amount: 100, LotteryHeight: 1000, ClaimDeadline: 1720},
+		{PublicKey: "2", Amount: 50, LotteryHeight: 1144, ClaimDeadline: 1864},
+		{PublicKey: "3", Amount: 25, LotteryHeight: 1432, ClaimDeadline: 2152},
+	}
+
+	atRisk := PrizesAtRisk(prizes, 1700)
+
+	expected := AtRisk{Count: 2, Final: 1, Amount: 150}
+	assert.Equal(t, expected, atRisk)
+}
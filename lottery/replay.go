@@ -0,0 +1,80 @@
+package lottery
+
+import (
+	"encoding/hex"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lottery/engine"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBlockHashNotStored is returned when replaying a lottery drawn before the block hashes were
+// stored.
+var ErrBlockHashNotStored = errors.New("the hash of the block that drew the lottery is not stored")
+
+// Replay draws a past lottery pool again with another distribution. The seed and the bets are the
+// same, so the winning tickets of the winners in both draws match and only their prizes change.
+// The hooks must be the ones the lottery was drawn with for the bonus winners to match, the
+// collision policy is the one stored with the draw.
+//
+// The bets slice must be sorted.
+func Replay(
+	commitment db.Commitment,
+	pool string,
+	bets []db.Bet,
+	distribution engine.Distribution,
+	rounding engine.Rounding,
+	hooks ...engine.Hook,
+) ([]db.Winner, error) {
+	if commitment.BlockHash == "" {
+		return nil, ErrBlockHashNotStored
+	}
+
+	if err := distribution.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid distribution")
+	}
+
+	serverSeed, err := hex.DecodeString(commitment.Seed)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding server seed")
+	}
+
+	blockHash, err := hex.DecodeString(commitment.BlockHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding block hash")
+	}
+
+	seed := engine.PoolSeed(engine.DrawSeed(serverSeed, blockHash), pool)
+	winners, err := getWinners(seed, bets, distribution, rounding,
+		engine.Collision(commitment.Collision), hooks...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range winners {
+		winners[i].Pool = pool
+	}
+
+	return winners, nil
+}
+
+// ScaleDistribution returns the distribution with its percentages scaled so the fee is the one
+// specified, the proportion between the prizes stays the same.
+func ScaleDistribution(distribution engine.Distribution, fee float64) (engine.Distribution, error) {
+	if fee < 0 || fee >= 100 {
+		return nil, errors.New("fee must be between 0 and 100")
+	}
+
+	distributed := 100 - distribution.Fee()
+	if distributed == 0 {
+		return nil, errors.New("the distribution has no prizes")
+	}
+
+	scaled := make(engine.Distribution, 0, len(distribution))
+	for _, percentage := range distribution {
+		scaled = append(scaled, percentage*(100-fee)/distributed)
+	}
+
+	return scaled, nil
+}
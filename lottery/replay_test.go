@@ -0,0 +1,112 @@
+package lottery
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lottery/engine"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplay(t *testing.T) {
+	commitment := db.Commitment{
+		Height:    833348,
+		Seed:      "6b1d6b1f9ac7a0a5a6b8d2e0c3f4e5d6c7b8a9f0e1d2c3b4a5968778695a4b3c",
+		BlockHash: "000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6",
+	}
+	serverSeed, err := hex.DecodeString(commitment.Seed)
+	assert.NoError(t, err)
+	blockHash, err := hex.DecodeString(commitment.BlockHash)
+	assert.NoError(t, err)
+
+	seed := engine.PoolSeed(engine.DrawSeed(serverSeed, blockHash), "micro")
+	drawn, err := getWinners(seed, bets, engine.DefaultDistribution, engine.RoundingNearest,
+		engine.CollisionStack)
+	assert.NoError(t, err)
+
+	t.Run("Same distribution", func(t *testing.T) {
+		winners, err := Replay(commitment, "micro", bets, engine.DefaultDistribution, engine.RoundingNearest)
+		assert.NoError(t, err)
+
+		assert.Len(t, winners, len(drawn))
+		for i, winner := range winners {
+			assert.Equal(t, "micro", winner.Pool)
+			assert.Equal(t, drawn[i].PublicKey, winner.PublicKey)
+			assert.Equal(t, drawn[i].Ticket, winner.Ticket)
+			assert.Equal(t, drawn[i].Prize, winner.Prize)
+		}
+	})
+
+	t.Run("Alternate distribution", func(t *testing.T) {
+		winners, err := Replay(commitment, "micro", bets, engine.Distribution{70, 20}, engine.RoundingNearest)
+		assert.NoError(t, err)
+
+		prizePool := bets[len(bets)-1].Index
+		assert.Len(t, winners, 2)
+		for i, winner := range winners {
+			assert.Equal(t, drawn[i].Ticket, winner.Ticket)
+		}
+		assert.Equal(t, uint64(math.Round(0.7*float64(prizePool))), winners[0].Prize)
+		assert.Equal(t, uint64(math.Round(0.2*float64(prizePool))), winners[1].Prize)
+	})
+
+	t.Run("Last ticket bonus", func(t *testing.T) {
+		hooks := DrawHooks(config.LastTicket{Tickets: 100, Percentage: 0.1})
+		drawn, err := getWinners(seed, bets, engine.DefaultDistribution, engine.RoundingNearest,
+			engine.CollisionStack, hooks...)
+		assert.NoError(t, err)
+
+		winners, err := Replay(commitment, "micro", bets, engine.Distribution{70, 20}, engine.RoundingNearest, hooks...)
+		assert.NoError(t, err)
+
+		assert.Len(t, winners, 3)
+		bonus, drawnBonus := winners[2], drawn[len(drawn)-1]
+		assert.Equal(t, drawnBonus.Ticket, bonus.Ticket)
+		assert.Equal(t, drawnBonus.PublicKey, bonus.PublicKey)
+		assert.Equal(t, drawnBonus.Prize, bonus.Prize)
+	})
+
+	t.Run("Stored collision policy", func(t *testing.T) {
+		drawn, err := getWinners(seed, bets, engine.DefaultDistribution, engine.RoundingNearest,
+			engine.CollisionCascade)
+		assert.NoError(t, err)
+
+		commitment := commitment
+		commitment.Collision = string(engine.CollisionCascade)
+		winners, err := Replay(commitment, "micro", bets, engine.DefaultDistribution, engine.RoundingNearest)
+		assert.NoError(t, err)
+
+		assert.Len(t, winners, len(drawn))
+		for i, winner := range winners {
+			assert.Equal(t, drawn[i].PublicKey, winner.PublicKey)
+			assert.Equal(t, drawn[i].Ticket, winner.Ticket)
+		}
+	})
+
+	t.Run("Invalid distribution", func(t *testing.T) {
+		_, err := Replay(commitment, "micro", bets, engine.Distribution{80, 30}, engine.RoundingNearest)
+		assert.Error(t, err)
+	})
+
+	t.Run("Block hash not stored", func(t *testing.T) {
+		_, err := Replay(db.Commitment{Height: 1}, "", bets, engine.DefaultDistribution, engine.RoundingNearest)
+		assert.ErrorIs(t, err, ErrBlockHashNotStored)
+	})
+}
+
+func TestScaleDistribution(t *testing.T) {
+	scaled, err := ScaleDistribution(engine.Distribution{60, 20}, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, engine.Distribution{67.5, 22.5}, scaled)
+	assert.Equal(t, float64(10), scaled.Fee())
+
+	_, err = ScaleDistribution(engine.Distribution{60, 20}, 100)
+	assert.Error(t, err)
+
+	_, err = ScaleDistribution(engine.Distribution{}, 10)
+	assert.Error(t, err)
+}
@@ -0,0 +1,185 @@
+package lottery
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// ResultsHash returns the hash the results of a draw are committed to before they are revealed: the
+// SHA-256 of the hex encoded salt followed by a zero byte, and the pool and public key (each followed
+// by a zero byte) and the big-endian encoded ticket and prize (8 bytes each) of every winner, in the
+// order they were drawn. The salt is secret until the first place is revealed, otherwise the
+// winners could be guessed by hashing the likely results.
+func ResultsHash(salt string, winners []db.Winner) string {
+	var buf bytes.Buffer
+	buf.WriteString(salt)
+	buf.WriteByte(0)
+	for _, winner := range winners {
+		buf.WriteString(winner.Pool)
+		buf.WriteByte(0)
+		buf.WriteString(winner.PublicKey)
+		buf.WriteByte(0)
+		buf.Write(binary.BigEndian.AppendUint64(nil, winner.Ticket))
+		buf.Write(binary.BigEndian.AppendUint64(nil, winner.Prize))
+	}
+
+	hash := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(hash[:])
+}
+
+// Revealed returns the winners of the lottery that were already announced, which are all of them
+// unless the lottery is being revealed one tier at a time.
+func Revealed(reveals db.RevealsStore, lotteryHeight uint32, winners []db.Winner) ([]db.Winner, error) {
+	reveal, err := reveals.Get(lotteryHeight)
+	if err != nil {
+		if errors.Is(err, db.ErrRevealNotFound) {
+			return winners, nil
+		}
+		return nil, err
+	}
+
+	return reveal.Revealed(winners), nil
+}
+
+// RevealPending returns whether the winners of the lottery are still being revealed, the server
+// seed and the bets must not be disclosed until then as they would give the results away.
+func RevealPending(reveals db.RevealsStore, lotteryHeight uint32) (bool, error) {
+	reveal, err := reveals.Get(lotteryHeight)
+	if err != nil {
+		if errors.Is(err, db.ErrRevealNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return reveal.Hidden > 0, nil
+}
+
+// hiddenTiers returns the number of top tiers that are still hidden once the blocks elapsed since
+// the draw. The tiers are revealed evenly over the reveal blocks, the first one in the last block.
+func hiddenTiers(tiers, blocks, elapsed uint32) uint32 {
+	if elapsed >= blocks {
+		return 0
+	}
+	return tiers - uint32(uint64(elapsed)*uint64(tiers)/uint64(blocks))
+}
+
+// newReveal returns the reveal of the lottery, which holds the hash of the winners that are
+// revealed from the last tier on the next blocks instead of being announced at the draw. The salt
+// is read from l.random.
+func (l *Lottery) newReveal(lotteryHeight uint32, prizePool uint64, winners []db.Winner) (db.Reveal, error) {
+	var tiers uint32
+	for _, winner := range winners {
+		tiers = max(tiers, winner.Tier+1)
+	}
+
+	salt := make([]byte, 32)
+	if _, err := io.ReadFull(l.random, salt); err != nil {
+		return db.Reveal{}, errors.Wrap(err, "generating results salt")
+	}
+	saltHex := hex.EncodeToString(salt)
+
+	return db.Reveal{
+		LotteryHeight: lotteryHeight,
+		ResultsHash:   ResultsHash(saltHex, winners),
+		Salt:          saltHex,
+		PrizePool:     prizePool,
+		Tiers:         tiers,
+		Hidden:        tiers,
+		Blocks:        l.reveal.Blocks,
+	}, nil
+}
+
+// commitResults records the hash of the results of a reveal stored and publishes it.
+func (l *Lottery) commitResults(reveal db.Reveal) {
+	l.auditor.Record(audit.ResultsCommitted, map[string]any{
+		"lottery_height": reveal.LotteryHeight,
+		"results_hash":   reveal.ResultsHash,
+		"blocks":         reveal.Blocks,
+	})
+	l.enqueue(jobPublishResultsHash, publishResultsHashJob{
+		Height:      reveal.LotteryHeight,
+		ResultsHash: reveal.ResultsHash,
+		Blocks:      reveal.Blocks,
+	})
+}
+
+// revealWinners announces the tiers of the lotteries whose reveal is due at the block height.
+// Errors are only logged as the tiers are announced on the next block.
+func (l *Lottery) revealWinners(blockHeight uint32) {
+	reveals, err := l.db.Reveals.ListPending()
+	if err != nil {
+		l.logger.Error(errors.Wrap(err, "listing reveals"))
+		return
+	}
+
+	for _, reveal := range reveals {
+		if err := l.revealTiers(reveal, blockHeight); err != nil {
+			l.logger.Error(errors.Wrapf(err, "revealing lottery %d winners", reveal.LotteryHeight))
+		}
+	}
+}
+
+// revealTiers announces the tiers of the lottery that are due at the block height, one at a time,
+// and completes the draw side effects once the first place is revealed.
+func (l *Lottery) revealTiers(reveal db.Reveal, blockHeight uint32) error {
+	if blockHeight <= reveal.LotteryHeight {
+		return nil
+	}
+
+	hidden := hiddenTiers(reveal.Tiers, reveal.Blocks, blockHeight-reveal.LotteryHeight)
+	if hidden >= reveal.Hidden {
+		return nil
+	}
+
+	winners, err := l.db.Winners.List(reveal.LotteryHeight)
+	if err != nil {
+		return errors.Wrap(err, "listing winners")
+	}
+
+	// The progress is saved first so the tiers are never announced twice
+	if err := l.db.Reveals.SetHidden(reveal.LotteryHeight, hidden); err != nil {
+		return err
+	}
+
+	for tier := reveal.Hidden; tier > hidden; tier-- {
+		var batch []db.Winner
+		for _, winner := range winners {
+			if winner.Tier == tier-1 {
+				batch = append(batch, winner)
+			}
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		job := publishWinnersJob{Height: reveal.LotteryHeight, Winners: batch}
+		// The salt is disclosed with the first place so the results hash can be verified
+		if tier == 1 {
+			job.Salt = reveal.Salt
+		}
+
+		l.winnersHub.Publish(batch)
+		l.enqueue(jobPublishReveal, job)
+	}
+
+	if hidden > 0 {
+		return nil
+	}
+
+	bets, err := l.listBets(reveal.LotteryHeight)
+	if err != nil {
+		return err
+	}
+	l.announceDraw(reveal.LotteryHeight, reveal.PrizePool, bets, winners)
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package lottery
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"testing"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lottery/engine"
+	"github.com/aftermath2/BTRY/notification"
+	"github.com/aftermath2/BTRY/webhooks"
+
+	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHiddenTiers(t *testing.T) {
+	cases := []struct {
+		desc     string
+		tiers    uint32
+		blocks   uint32
+		elapsed  uint32
+		expected uint32
+	}{
+		{desc: "Draw", tiers: 8, blocks: 8, elapsed: 0, expected: 8},
+		{desc: "One per block", tiers: 8, blocks: 8, elapsed: 3, expected: 5},
+		{desc: "Last block", tiers: 8, blocks: 8, elapsed: 8, expected: 0},
+		{desc: "After the last block", tiers: 8, blocks: 8, elapsed: 20, expected: 0},
+		{desc: "More blocks than tiers", tiers: 8, blocks: 16, elapsed: 3, expected: 7},
+		{desc: "Less blocks than tiers", tiers: 8, blocks: 2, elapsed: 1, expected: 4},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, hiddenTiers(tc.tiers, tc.blocks, tc.elapsed))
+		})
+	}
+}
+
+func TestResultsHash(t *testing.T) {
+	winners := []db.Winner{
+		{PublicKey: "1", Ticket: 10, Prize: 500},
+		{PublicKey: "2", Ticket: 20, Prize: 250},
+	}
+
+	salt := "5a17"
+
+	hash := ResultsHash(salt, winners)
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, ResultsHash(salt, winners))
+	assert.NotEqual(t, hash, ResultsHash("", winners))
+	assert.NotEqual(t, hash, ResultsHash(salt, []db.Winner{winners[1], winners[0]}))
+	assert.NotEqual(t, hash, ResultsHash(salt, []db.Winner{winners[0], {PublicKey: "2", Ticket: 20, Prize: 251}}))
+}
+
+func TestRaffleReveal(t *testing.T) {
+	blockHeight := uint32(833348)
+	tiers := len(engine.DefaultDistribution)
+	winnersHub := NewWinnersHub(config.WinnersHub{})
+	subscription := winnersHub.Subscribe()
+	db := setupDB(t, func(db *sql.DB) {
+		query := "INSERT INTO lotteries (height, seed, commitment) VALUES (?,?,?)"
+		_, err := db.Exec(query, blockHeight, hex.EncodeToString([]byte("seed")), "commitment")
+		assert.NoError(t, err)
+
+		query = "INSERT INTO bets (idx, tickets, public_key, lottery_height) VALUES (?,?,?,?), (?,?,?,?)"
+		_, err = db.Exec(query,
+			bets[0].Index, bets[0].Tickets, bets[0].PublicKey, blockHeight,
+			bets[1].Index, bets[1].Tickets, bets[1].PublicKey, blockHeight,
+		)
+		assert.NoError(t, err)
+	})
+	notifierMock := notification.NewNotifierMock()
+	notifierMock.On("PublishRevealCommitment", blockHeight, mock.Anything, uint32(tiers)).Return(nil).Once()
+	notifierMock.On("PublishReveal", blockHeight, mock.Anything, "").Return(nil).Times(tiers - 1)
+	auditorMock := audit.NewAuditorMock()
+	auditorMock.On("Record", audit.DrawExecuted, mock.Anything).Once()
+	auditorMock.On("Record", audit.PrizeAssigned, mock.Anything).Times(tiers)
+	auditorMock.On("Record", audit.ResultsCommitted, mock.Anything).Once()
+	webhooksMock := webhooks.NewPublisherMock()
+
+	config := config.Lottery{
+		Duration:  144,
+		Collision: string(engine.CollisionStack),
+		Reveal:    config.Reveal{Blocks: uint32(tiers)},
+	}
+	lottery, err := New(config, db, nil, notifierMock, templates, auditorMock, nil, nil, newQueue(t, db),
+		webhooksMock, winnersHub, nil)
+	assert.NoError(t, err)
+
+	blockHash, err := hex.DecodeString("000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6")
+	assert.NoError(t, err)
+
+	err = lottery.raffle(&chainrpc.BlockEpoch{Hash: blockHash, Height: blockHeight})
+	assert.NoError(t, err)
+
+	winners, err := db.Winners.List(blockHeight)
+	assert.NoError(t, err)
+	reveal, err := db.Reveals.Get(blockHeight)
+	assert.NoError(t, err)
+	assert.Len(t, reveal.Salt, 64)
+	assert.Equal(t, ResultsHash(reveal.Salt, winners), reveal.ResultsHash)
+	assert.Equal(t, uint32(tiers), reveal.Hidden)
+	notifierMock.On("PublishReveal", blockHeight, mock.Anything, reveal.Salt).Return(nil).Once()
+
+	t.Run("Results were committed", func(t *testing.T) {
+		// Prizes expiry, congratulations to the two winners, automatic withdrawals and commitment
+		assert.Equal(t, 5, runJobs(t, lottery, db))
+		notifierMock.AssertCalled(t, "PublishRevealCommitment", blockHeight, reveal.ResultsHash, uint32(tiers))
+		webhooksMock.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+		assert.Empty(t, subscription.C())
+
+		revealed, err := Revealed(db.Reveals, blockHeight, winners)
+		assert.NoError(t, err)
+		assert.Empty(t, revealed)
+	})
+
+	t.Run("Last place revealed", func(t *testing.T) {
+		lottery.revealWinners(blockHeight + 1)
+
+		batch := <-subscription.C()
+		assert.Len(t, batch, 1)
+		assert.Equal(t, uint32(tiers-1), batch[0].Tier)
+		assert.Equal(t, 1, runJobs(t, lottery, db))
+
+		revealed, err := Revealed(db.Reveals, blockHeight, winners)
+		assert.NoError(t, err)
+		assert.Equal(t, winners[tiers-1:], revealed)
+	})
+
+	t.Run("Every tier revealed", func(t *testing.T) {
+		webhooksMock.On("Publish", webhooks.DrawCompleted, mock.Anything).Once()
+		webhooksMock.On("Publish", webhooks.WinnerAnnounced, mock.Anything).Times(tiers)
+
+		lottery.revealWinners(blockHeight + uint32(tiers))
+
+		for tier := tiers - 2; tier >= 0; tier-- {
+			batch := <-subscription.C()
+			assert.Equal(t, uint32(tier), batch[0].Tier)
+		}
+		// Reveals of the rest of the tiers, statistics and fairness report
+		assert.Equal(t, tiers-1+2, runJobs(t, lottery, db))
+
+		pending, err := RevealPending(db.Reveals, blockHeight)
+		assert.NoError(t, err)
+		assert.False(t, pending)
+
+		rounds, err := db.Stats.ListRounds(0, 0, false)
+		assert.NoError(t, err)
+		assert.Len(t, rounds, 1)
+	})
+
+	t.Run("Nothing left to reveal", func(t *testing.T) {
+		lottery.revealWinners(blockHeight + uint32(tiers) + 1)
+		assert.Equal(t, 0, runJobs(t, lottery, db))
+	})
+
+	notifierMock.AssertExpectations(t)
+	auditorMock.AssertExpectations(t)
+	webhooksMock.AssertExpectations(t)
+}
@@ -0,0 +1,209 @@
+package lottery
+
+import (
+	"slices"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultSLOObjective = time.Second
+	defaultSLOTarget    = 99
+	defaultSLOWindow    = 30
+)
+
+// drawStage is a part of the draw whose duration is measured.
+type drawStage int
+
+// Draw stages
+const (
+	// stageBetsListing lists the pools and their bets
+	stageBetsListing drawStage = iota
+	// stageWinners computes the winners of each pool
+	stageWinners
+	// stageDBWrites stores the draw, the winners and their prizes, the bets archive and the audit
+	// log entries
+	stageDBWrites
+	// stageNotifications publishes the winners and enqueues the side effects of the draw
+	stageNotifications
+	stagesCount
+)
+
+// histogramBounds are the upper bounds of the draws latency histogram buckets, in microseconds.
+var histogramBounds = []int64{
+	10_000, 50_000, 100_000, 250_000, 500_000,
+	1_000_000, 2_500_000, 5_000_000, 10_000_000, 30_000_000,
+}
+
+// SLOReport summarizes the latency of the last draws against the service level objective.
+type SLOReport struct {
+	Stages    []StageLatency    `json:"stages"`
+	Histogram []HistogramBucket `json:"histogram"`
+	// Objective is the maximum duration of a draw, in microseconds
+	Objective int64 `json:"objective_us"`
+	// Target is the percentage of draws that must complete within the objective
+	Target float64 `json:"target"`
+	// Compliance is the percentage of draws that completed within the objective
+	Compliance float64 `json:"compliance"`
+	Draws      int     `json:"draws"`
+	Breaches   int     `json:"breaches"`
+	Met        bool    `json:"met"`
+}
+
+// StageLatency contains the percentiles of the duration of a draw stage, in microseconds.
+type StageLatency struct {
+	Stage string `json:"stage"`
+	P50   int64  `json:"p50_us"`
+	P95   int64  `json:"p95_us"`
+	P99   int64  `json:"p99_us"`
+	Max   int64  `json:"max_us"`
+}
+
+// HistogramBucket is the number of draws that took up to LE microseconds, excluding the ones
+// counted in the previous buckets. The last bucket has no upper bound.
+type HistogramBucket struct {
+	LE    int64 `json:"le_us,omitempty"`
+	Count int   `json:"count"`
+}
+
+// DrawSLO returns the draws latency SLO with the defaults applied.
+func DrawSLO(slo config.DrawSLO) config.DrawSLO {
+	if slo.Objective == 0 {
+		slo.Objective = defaultSLOObjective
+	}
+	if slo.Target == 0 {
+		slo.Target = defaultSLOTarget
+	}
+	if slo.Window == 0 {
+		slo.Window = defaultSLOWindow
+	}
+	return slo
+}
+
+// NewSLOReport returns the report of the draw timings against the SLO. The SLO is met if there are
+// no draws yet.
+func NewSLOReport(slo config.DrawSLO, timings []db.DrawTiming) SLOReport {
+	slo = DrawSLO(slo)
+	objective := slo.Objective.Microseconds()
+
+	report := SLOReport{
+		Objective:  objective,
+		Target:     slo.Target,
+		Compliance: 100,
+		Draws:      len(timings),
+		Histogram:  make([]HistogramBucket, len(histogramBounds)+1),
+	}
+	for i, bound := range histogramBounds {
+		report.Histogram[i].LE = bound
+	}
+
+	for _, timing := range timings {
+		if timing.Total > objective {
+			report.Breaches++
+		}
+
+		i, _ := slices.BinarySearch(histogramBounds, timing.Total)
+		report.Histogram[i].Count++
+	}
+
+	if len(timings) > 0 {
+		report.Compliance = float64(len(timings)-report.Breaches) * 100 / float64(len(timings))
+	}
+	report.Met = report.Compliance >= slo.Target
+
+	stages := []struct {
+		name  string
+		value func(db.DrawTiming) int64
+	}{
+		{name: "bets_listing", value: func(t db.DrawTiming) int64 { return t.BetsListing }},
+		{name: "winners", value: func(t db.DrawTiming) int64 { return t.Winners }},
+		{name: "db_writes", value: func(t db.DrawTiming) int64 { return t.DBWrites }},
+		{name: "notifications", value: func(t db.DrawTiming) int64 { return t.Notifications }},
+		{name: "total", value: func(t db.DrawTiming) int64 { return t.Total }},
+	}
+	for _, stage := range stages {
+		durations := make([]int64, len(timings))
+		for i, timing := range timings {
+			durations[i] = stage.value(timing)
+		}
+		slices.Sort(durations)
+
+		report.Stages = append(report.Stages, StageLatency{
+			Stage: stage.name,
+			P50:   percentile(durations, 50),
+			P95:   percentile(durations, 95),
+			P99:   percentile(durations, 99),
+			Max:   percentile(durations, 100),
+		})
+	}
+
+	return report
+}
+
+// percentile returns the nearest-rank percentile of the sorted values.
+func percentile(sorted []int64, p int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// drawTimer measures how long each stage of a draw takes.
+type drawTimer struct {
+	now    func() time.Time
+	start  time.Time
+	last   time.Time
+	stages [stagesCount]time.Duration
+}
+
+func newDrawTimer(now func() time.Time) *drawTimer {
+	start := now()
+	return &drawTimer{
+		now:   now,
+		start: start,
+		last:  start,
+	}
+}
+
+// lap adds the time elapsed since the previous lap to the stage.
+func (t *drawTimer) lap(stage drawStage) {
+	now := t.now()
+	t.stages[stage] += now.Sub(t.last)
+	t.last = now
+}
+
+// timing returns the durations measured.
+func (t *drawTimer) timing(lotteryHeight uint32, bets int) db.DrawTiming {
+	return db.DrawTiming{
+		LotteryHeight: lotteryHeight,
+		Bets:          uint64(bets),
+		BetsListing:   t.stages[stageBetsListing].Microseconds(),
+		Winners:       t.stages[stageWinners].Microseconds(),
+		DBWrites:      t.stages[stageDBWrites].Microseconds(),
+		Notifications: t.stages[stageNotifications].Microseconds(),
+		Total:         t.last.Sub(t.start).Microseconds(),
+		CreatedAt:     t.last.Unix(),
+	}
+}
+
+// recordTiming stores the timing of the draw and warns the operators if it exceeded the SLO
+// objective. Errors are only logged as they must not interrupt the draw.
+func (l *Lottery) recordTiming(timing db.DrawTiming) {
+	if err := l.db.DrawTimings.Add(timing); err != nil {
+		l.logger.Error(errors.Wrap(err, "storing draw timing"))
+	}
+
+	if objective := l.drawSLO.Objective; timing.Total > objective.Microseconds() {
+		l.logger.Warningf("Lottery %d draw took %s, above the %s objective",
+			timing.LotteryHeight, time.Duration(timing.Total)*time.Microsecond, objective)
+	}
+}
@@ -0,0 +1,81 @@
+package lottery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSLOReport(t *testing.T) {
+	timings := []db.DrawTiming{
+		{Total: 8_000, BetsListing: 1_000, Winners: 2_000, DBWrites: 4_000, Notifications: 1_000},
+		{Total: 200_000, BetsListing: 10_000, Winners: 40_000, DBWrites: 140_000, Notifications: 10_000},
+		{Total: 900_000, BetsListing: 50_000, Winners: 300_000, DBWrites: 500_000, Notifications: 50_000},
+		{Total: 3_000_000, BetsListing: 100_000, Winners: 900_000, DBWrites: 1_900_000, Notifications: 100_000},
+		{Total: 60_000_000},
+	}
+	slo := config.DrawSLO{Objective: time.Second, Target: 50}
+
+	report := NewSLOReport(slo, timings)
+	assert.Equal(t, int64(1_000_000), report.Objective)
+	assert.Equal(t, 5, report.Draws)
+	assert.Equal(t, 2, report.Breaches)
+	assert.Equal(t, float64(60), report.Compliance)
+	assert.True(t, report.Met)
+
+	counts := make(map[int64]int)
+	for _, bucket := range report.Histogram {
+		counts[bucket.LE] = bucket.Count
+	}
+	assert.Equal(t, map[int64]int{
+		10_000: 1, 50_000: 0, 100_000: 0, 250_000: 1, 500_000: 0, 1_000_000: 1, 2_500_000: 0,
+		5_000_000: 1, 10_000_000: 0, 30_000_000: 0, 0: 1,
+	}, counts)
+
+	total := report.Stages[len(report.Stages)-1]
+	assert.Equal(t, StageLatency{Stage: "total", P50: 900_000, P95: 60_000_000, P99: 60_000_000, Max: 60_000_000}, total)
+	dbWrites := report.Stages[2]
+	assert.Equal(t, "db_writes", dbWrites.Stage)
+	assert.Equal(t, int64(140_000), dbWrites.P50)
+
+	slo.Target = 99
+	assert.False(t, NewSLOReport(slo, timings).Met)
+}
+
+func TestNewSLOReportEmpty(t *testing.T) {
+	report := NewSLOReport(config.DrawSLO{}, nil)
+	assert.Equal(t, defaultSLOObjective.Microseconds(), report.Objective)
+	assert.Equal(t, float64(defaultSLOTarget), report.Target)
+	assert.Equal(t, float64(100), report.Compliance)
+	assert.True(t, report.Met)
+	assert.Zero(t, report.Stages[0].Max)
+}
+
+func TestDrawTimer(t *testing.T) {
+	now := time.Unix(1231006505, 0)
+	timer := newDrawTimer(func() time.Time { return now })
+
+	now = now.Add(10 * time.Millisecond)
+	timer.lap(stageBetsListing)
+	now = now.Add(20 * time.Millisecond)
+	timer.lap(stageWinners)
+	now = now.Add(5 * time.Millisecond)
+	timer.lap(stageBetsListing)
+	now = now.Add(time.Millisecond)
+	timer.lap(stageNotifications)
+
+	expected := db.DrawTiming{
+		LotteryHeight: 144,
+		Bets:          3,
+		BetsListing:   15_000,
+		Winners:       20_000,
+		Notifications: 1_000,
+		Total:         36_000,
+		CreatedAt:     now.Unix(),
+	}
+	assert.Equal(t, expected, timer.timing(144, 3))
+}
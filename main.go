@@ -4,26 +4,53 @@ package main
 import (
 	"context"
 	"log"
+	"os"
 
+	"github.com/aftermath2/BTRY/alert"
+	"github.com/aftermath2/BTRY/archive"
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/chaos"
 	"github.com/aftermath2/BTRY/config"
 	"github.com/aftermath2/BTRY/db"
 	"github.com/aftermath2/BTRY/http/api"
 	"github.com/aftermath2/BTRY/http/server"
+	"github.com/aftermath2/BTRY/jobs"
+	"github.com/aftermath2/BTRY/leader"
 	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/liquidity"
 	"github.com/aftermath2/BTRY/lottery"
+	"github.com/aftermath2/BTRY/lottery/engine"
 	"github.com/aftermath2/BTRY/notification"
+	"github.com/aftermath2/BTRY/policy"
+	"github.com/aftermath2/BTRY/rates"
+	"github.com/aftermath2/BTRY/reload"
+	"github.com/aftermath2/BTRY/reserves"
 	"github.com/aftermath2/BTRY/tor"
+	"github.com/aftermath2/BTRY/watchdog"
+	"github.com/aftermath2/BTRY/webhooks"
 
 	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
 	_ "modernc.org/sqlite"
 )
 
 func main() {
-	config, err := config.New()
+	configPath, err := config.Path()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	config, err := config.Load(configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == verifyDBCommand {
+		if err := verifyDB(config.DB, os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	ctx := context.Background()
 
 	torClient, err := tor.NewClient(config.Tor)
@@ -31,12 +58,12 @@ func main() {
 		log.Fatal(err)
 	}
 
-	lnd, err := lightning.NewClient(config.Lightning, torClient)
+	lnd, err := lightning.NewClient(config.Lightning, torClient, tor.Dialer(config.Tor))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	winnersCh := make(chan []db.Winner)
+	winnersHub := lottery.NewWinnersHub(config.Lottery.WinnersHub)
 	blocksCh := make(chan *chainrpc.BlockEpoch)
 
 	db, err := db.Open(config.DB)
@@ -44,23 +71,140 @@ func main() {
 		log.Fatal(err)
 	}
 	defer db.Close()
+	db.StartSnapshots(ctx)
+	db.StartPruning(ctx)
+
+	violations, err := db.Verify(false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, v := range violations {
+		log.Printf("Database integrity violation, run %q for details: %s", verifyDBCommand, v)
+	}
 
-	notifier, err := notification.NewNotifier(config.Notifier, db, torClient)
+	auditor, err := audit.New(config.Audit, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	templates, err := notification.NewTemplates(config.Notifier.Templates)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	notifier, err := notification.NewNotifier(config.Notifier, db, templates, torClient)
 	if err != nil {
 		log.Fatal(err)
 	}
 	go notifier.GetUpdates()
 
-	lottery, err := lottery.New(config.Lottery, db, lnd, notifier, winnersCh, blocksCh)
+	watchdog, err := watchdog.New(config.Watchdog, lnd, notifier)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := watchdog.Start(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	alerts, err := alert.New(config.Alerts, db, lnd, notifier)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := alerts.Start(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	elector, err := leader.New(config.Leader, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := elector.Start(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	pools := lottery.NewPools(config.Lottery.Pools)
+	capacity := lottery.NewCapacityOracle(config.Lottery.Capacity)
+	statsPrivacy := lottery.StatsPrivacy(config.Lottery.StatsPrivacy)
+
+	queue, err := jobs.New(config.Jobs, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	webhooks, err := webhooks.New(config.Webhooks, db, queue, torClient)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	lottery, err := lottery.New(config.Lottery, db, lnd, notifier, templates, auditor, watchdog,
+		elector, queue, webhooks, winnersHub, blocksCh)
+	if err != nil {
+		log.Fatal(err)
+	}
+	approvals := policy.NewApprovals(config.Lottery.Approvals, db, queue)
+	peerCap := policy.NewPeerCap(config.Lottery.PeerCap, db, lnd)
+	invoices := policy.NewInvoices(db, lnd, queue, peerCap)
+
 	if err := lottery.Start(); err != nil {
 		log.Fatal(err)
 	}
+	// Failures are injected once the services started, so they can be recovered from
+	configureChaos(config.Chaos)
+	// Only the leader runs the jobs, the followers serve the requests that don't need them
+	elector.OnElected(queue.Start)
+
+	liquidityManager, err := liquidity.New(config.Liquidity, db, lnd, notifier)
+	if err != nil {
+		log.Fatal(err)
+	}
+	liquidityManager.Start(ctx)
+
+	rates, err := rates.New(config.Rates)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rates.Start(ctx)
+
+	reserves, err := reserves.New(config.Reserves, db, lnd, auditor)
+	if err != nil {
+		log.Fatal(err)
+	}
+	reserves.Start(ctx)
+
+	archiver, err := archive.New(config.Archive, db, lnd, auditor, torClient)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Only the leader exports the draws, so they are not published twice
+	elector.OnElected(archiver.Start)
+
+	limits := policy.NewLimits(config.Lottery.Limits, db)
+	housePlay := policy.NewHousePlay(config.Lottery.HousePlay)
+	cancellation := policy.NewCancellation(config.Lottery.Cancellation, db, auditor)
+	claimCodes := policy.NewClaimCodes(config.Lottery.ClaimCodes, db)
+	maintenance := policy.NewMaintenance(config.API.Maintenance)
+	jurisdiction, err := policy.NewJurisdiction(config.API.Jurisdiction)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	router, err := api.NewRouter(config.API, db, lnd, winnersCh, blocksCh)
+	reloader, err := reload.New(configPath, config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	reloader.Register(reloadHooks(notifier, lottery, limits, cancellation, peerCap, maintenance,
+		approvals)...)
+	reloader.Listen(ctx)
+
+	rounding := engine.Rounding(config.Lottery.Rounding)
+	router, err := api.NewRouter(config.API, lottery, pools, capacity, statsPrivacy, rounding,
+		config.Lottery.LastTicket, config.Lottery.LNURLPay, config.Lottery.Keysend,
+		config.Lottery.DrawSLO, db, lnd, auditor, webhooks, peerCap, limits, housePlay, cancellation,
+		claimCodes, jurisdiction, maintenance, approvals, invoices, elector, lottery, rates, reserves,
+		reloader, winnersHub, blocksCh)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -70,7 +214,63 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if config.Tor.HiddenService.Enabled {
+		hiddenService, err := tor.NewHiddenService(config.Tor, config.Server.Address)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer hiddenService.Close()
+		log.Printf("API available at http://%s", hiddenService.Address())
+	}
+
 	if err := server.Run(ctx); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// reloadHooks returns the hooks that apply the reloaded configuration to the services.
+func reloadHooks(
+	notifier notification.Notifier,
+	lottery *lottery.Lottery,
+	limits *policy.Limits,
+	cancellation *policy.Cancellation,
+	peerCap *policy.PeerCap,
+	maintenance *policy.Maintenance,
+	approvals *policy.Approvals,
+) []reload.Hook {
+	return []reload.Hook{
+		func(next config.Config) (func(), error) {
+			return notifier.Reload(next.Notifier)
+		},
+		func(next config.Config) (func(), error) {
+			return func() {
+				lottery.Reload(next.Lottery)
+				limits.Reload(next.Lottery.Limits)
+				cancellation.Reload(next.Lottery.Cancellation)
+				peerCap.Reload(next.Lottery.PeerCap)
+				maintenance.Reload(next.API.Maintenance)
+				approvals.Reload(next.Lottery.Approvals)
+				configureChaos(next.Chaos)
+			}, nil
+		},
+	}
+}
+
+// configureChaos sets up the failures injected, they are ignored unless the binary was built with
+// the chaos tag.
+func configureChaos(config config.Chaos) {
+	if !config.Enabled {
+		chaos.Configure(config)
+		return
+	}
+
+	if !chaos.Enabled {
+		log.Print("Chaos settings ignored, the binary was not built with the chaos tag")
+		return
+	}
+
+	log.Printf("Injecting failures: %.2f delays up to %s, %.2f dropped blocks, %.2f failed writes, "+
+		"%.2f lightning errors", config.Delay, config.MaxDelay, config.DropBlock, config.FailWrite,
+		config.LightningError)
+	chaos.Configure(config)
+}
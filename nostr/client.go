@@ -3,6 +3,7 @@ package nostr
 import (
 	"context"
 	"net/http"
+	"strconv"
 
 	"github.com/aftermath2/BTRY/config"
 	"github.com/aftermath2/BTRY/logger"
@@ -37,6 +38,36 @@ func (c *Client) Publish(message string) error {
 		return errors.Wrap(err, "creating event")
 	}
 
+	return c.publish(event)
+}
+
+// PublishArticle publishes a long-form event (NIP-23) to the configured relays and returns its ID.
+// Articles with the same identifier replace each other.
+func (c *Client) PublishArticle(identifier, title, content string) (string, error) {
+	event, err := c.createArticle(identifier, title, content)
+	if err != nil {
+		return "", errors.Wrap(err, "creating article")
+	}
+
+	if err := c.publish(event); err != nil {
+		return "", err
+	}
+
+	return event.ID, nil
+}
+
+// SendDirectMessage sends an end-to-end encrypted private message to the public key specified
+// (hex encoded), following NIP-17.
+func (c *Client) SendDirectMessage(recipient, message string) error {
+	giftWrap, err := c.createGiftWrap(recipient, message)
+	if err != nil {
+		return errors.Wrap(err, "creating gift wrap")
+	}
+
+	return c.publish(giftWrap)
+}
+
+func (c *Client) publish(event nostr.Event) error {
 	eventEnvelope := nostr.EventEnvelope{Event: event}
 	body, err := eventEnvelope.MarshalJSON()
 	if err != nil {
@@ -73,6 +104,26 @@ func (c *Client) createEvent(message string) (nostr.Event, error) {
 	return event, nil
 }
 
+func (c *Client) createArticle(identifier, title, content string) (nostr.Event, error) {
+	now := nostr.Now()
+	event := nostr.Event{
+		CreatedAt: now,
+		Kind:      nostr.KindArticle,
+		Tags: nostr.Tags{
+			{"d", identifier},
+			{"title", title},
+			{"published_at", strconv.FormatInt(int64(now), 10)},
+		},
+		Content: content,
+	}
+
+	if err := event.Sign(c.privateKey); err != nil {
+		return nostr.Event{}, errors.Wrap(err, "signing event")
+	}
+
+	return event, nil
+}
+
 // send opens a websocket connection with the relay and sends the event.
 func send(relay string, dialOpts *websocket.DialOptions, body []byte) error {
 	ctx := context.Background()
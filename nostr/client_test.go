@@ -43,3 +43,21 @@ func TestCreateEvent(t *testing.T) {
 	event.CreatedAt = 0
 	assert.Equal(t, expectedEvent, event)
 }
+
+func TestCreateArticle(t *testing.T) {
+	privateKey := nostrlib.GeneratePrivateKey()
+	client := NewClient(config.Nostr{PrivateKey: privateKey}, nil, nil)
+
+	event, err := client.createArticle("btry-archive-144-288", "Draws 144-288", "content")
+	assert.NoError(t, err)
+
+	assert.Equal(t, nostrlib.KindArticle, event.Kind)
+	assert.Equal(t, "content", event.Content)
+	assert.Equal(t, "btry-archive-144-288", event.Tags.GetD())
+	assert.Equal(t, "Draws 144-288", event.Tags.GetFirst([]string{"title"}).Value())
+	assert.NotNil(t, event.Tags.GetFirst([]string{"published_at"}))
+
+	ok, err := event.CheckSignature()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
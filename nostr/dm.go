@@ -0,0 +1,97 @@
+package nostr
+
+import (
+	"crypto/rand"
+	"math/big"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/pkg/errors"
+)
+
+// NIP-17 private direct messages kinds.
+const (
+	KindChatMessage = 14
+	KindSeal        = 13
+	KindGiftWrap    = 1059
+)
+
+// maxTimestampTweak is the maximum number of seconds the seal and gift wrap timestamps are moved
+// to the past, so they can't be correlated with the moment the message was sent.
+const maxTimestampTweak = 2 * 24 * 60 * 60
+
+// createGiftWrap returns the message wrapped following NIP-17: the unsigned chat message (rumor)
+// is encrypted into a seal signed by BTRY, which is encrypted into a gift wrap signed by a random
+// key. Relays and observers only learn the recipient.
+func (c *Client) createGiftWrap(recipient, message string) (nostr.Event, error) {
+	publicKey, err := nostr.GetPublicKey(c.privateKey)
+	if err != nil {
+		return nostr.Event{}, errors.Wrap(err, "getting public key")
+	}
+
+	rumor := nostr.Event{
+		PubKey:    publicKey,
+		CreatedAt: nostr.Now(),
+		Kind:      KindChatMessage,
+		Tags:      nostr.Tags{{"p", recipient}},
+		Content:   message,
+	}
+	rumor.ID = rumor.GetID()
+
+	seal, err := encryptEvent(c.privateKey, recipient, KindSeal, rumor, nil)
+	if err != nil {
+		return nostr.Event{}, errors.Wrap(err, "creating seal")
+	}
+
+	// The gift wrap is signed with a key used only once
+	giftWrap, err := encryptEvent(nostr.GeneratePrivateKey(), recipient, KindGiftWrap, seal,
+		nostr.Tags{{"p", recipient}})
+	if err != nil {
+		return nostr.Event{}, errors.Wrap(err, "creating gift wrap")
+	}
+
+	return giftWrap, nil
+}
+
+// encryptEvent returns an event signed with the private key whose content is the event passed
+// encrypted to the recipient.
+func encryptEvent(
+	privateKey, recipient string,
+	kind int,
+	event nostr.Event,
+	tags nostr.Tags,
+) (nostr.Event, error) {
+	conversationKey, err := ConversationKey(privateKey, recipient)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+
+	content, err := event.MarshalJSON()
+	if err != nil {
+		return nostr.Event{}, errors.Wrap(err, "encoding event")
+	}
+
+	payload, err := Encrypt(conversationKey, string(content))
+	if err != nil {
+		return nostr.Event{}, errors.Wrap(err, "encrypting event")
+	}
+
+	tweak, err := rand.Int(rand.Reader, big.NewInt(maxTimestampTweak))
+	if err != nil {
+		return nostr.Event{}, errors.Wrap(err, "generating timestamp")
+	}
+
+	if tags == nil {
+		tags = nostr.Tags{}
+	}
+	encrypted := nostr.Event{
+		CreatedAt: nostr.Now() - nostr.Timestamp(tweak.Int64()),
+		Kind:      kind,
+		Tags:      tags,
+		Content:   payload,
+	}
+	if err := encrypted.Sign(privateKey); err != nil {
+		return nostr.Event{}, errors.Wrap(err, "signing event")
+	}
+
+	return encrypted, nil
+}
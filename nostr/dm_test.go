@@ -0,0 +1,78 @@
+package nostr
+
+import (
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+
+	nostrlib "github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateGiftWrap(t *testing.T) {
+	privateKey := nostrlib.GeneratePrivateKey()
+	publicKey, err := nostrlib.GetPublicKey(privateKey)
+	assert.NoError(t, err)
+
+	recipientKey := nostrlib.GeneratePrivateKey()
+	recipient, err := nostrlib.GetPublicKey(recipientKey)
+	assert.NoError(t, err)
+
+	client := NewClient(config.Nostr{PrivateKey: privateKey}, nil, nil)
+	message := "You have won 100 sats"
+
+	giftWrap, err := client.createGiftWrap(recipient, message)
+	assert.NoError(t, err)
+
+	assert.Equal(t, KindGiftWrap, giftWrap.Kind)
+	assert.NotEqual(t, publicKey, giftWrap.PubKey)
+	assert.Equal(t, nostrlib.Tags{{"p", recipient}}, giftWrap.Tags)
+	assert.LessOrEqual(t, giftWrap.CreatedAt, nostrlib.Now())
+	ok, err := giftWrap.CheckSignature()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Only the recipient can open the gift wrap and the seal
+	seal := decryptEvent(t, recipientKey, giftWrap)
+	assert.Equal(t, KindSeal, seal.Kind)
+	assert.Equal(t, publicKey, seal.PubKey)
+	assert.Empty(t, seal.Tags)
+	ok, err = seal.CheckSignature()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	rumor := decryptEvent(t, recipientKey, seal)
+	assert.Equal(t, KindChatMessage, rumor.Kind)
+	assert.Equal(t, publicKey, rumor.PubKey)
+	assert.Equal(t, message, rumor.Content)
+	assert.Equal(t, nostrlib.Tags{{"p", recipient}}, rumor.Tags)
+	assert.Equal(t, rumor.GetID(), rumor.ID)
+	assert.Empty(t, rumor.Sig)
+}
+
+func TestSendDirectMessage(t *testing.T) {
+	recipient, err := nostrlib.GetPublicKey(nostrlib.GeneratePrivateKey())
+	assert.NoError(t, err)
+
+	client := NewClient(config.Nostr{PrivateKey: nostrlib.GeneratePrivateKey()}, nil, nil)
+	err = client.SendDirectMessage(recipient, "test")
+	assert.NoError(t, err)
+
+	err = client.SendDirectMessage("invalid", "test")
+	assert.Error(t, err)
+}
+
+func decryptEvent(t *testing.T, privateKey string, event nostrlib.Event) nostrlib.Event {
+	t.Helper()
+
+	conversationKey, err := ConversationKey(privateKey, event.PubKey)
+	assert.NoError(t, err)
+
+	content, err := Decrypt(conversationKey, event.Content)
+	assert.NoError(t, err)
+
+	var decrypted nostrlib.Event
+	assert.NoError(t, decrypted.UnmarshalJSON([]byte(content)))
+
+	return decrypted
+}
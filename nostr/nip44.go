@@ -0,0 +1,174 @@
+package nostr
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"math/bits"
+
+	"github.com/nbd-wtf/go-nostr/nip04"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/hkdf"
+)
+
+// NIP-44 version 2 parameters.
+const (
+	nip44Version    = 2
+	nip44Salt       = "nip44-v2"
+	nonceSize       = 32
+	macSize         = 32
+	minPlaintextLen = 1
+	maxPlaintextLen = 65535
+	minPayloadLen   = 132
+	maxPayloadLen   = 87472
+)
+
+// ErrInvalidPayload is returned when a NIP-44 payload can't be decrypted.
+var ErrInvalidPayload = errors.New("invalid NIP-44 payload")
+
+// ConversationKey returns the NIP-44 key shared by the owners of the keys specified, both hex
+// encoded. It's the same from both sides of the conversation.
+func ConversationKey(privateKey, publicKey string) ([]byte, error) {
+	sharedX, err := nip04.ComputeSharedSecret(publicKey, privateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "computing shared secret")
+	}
+
+	return hkdf.Extract(sha256.New, sharedX, []byte(nip44Salt)), nil
+}
+
+// Encrypt encrypts the plaintext following NIP-44 version 2 and returns the base64 payload.
+func Encrypt(conversationKey []byte, plaintext string) (string, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", errors.Wrap(err, "generating nonce")
+	}
+
+	return encrypt(conversationKey, nonce, plaintext)
+}
+
+func encrypt(conversationKey, nonce []byte, plaintext string) (string, error) {
+	chachaKey, chachaNonce, hmacKey, err := messageKeys(conversationKey, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	padded, err := pad(plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	cipher, err := chacha20.NewUnauthenticatedCipher(chachaKey, chachaNonce)
+	if err != nil {
+		return "", errors.Wrap(err, "creating cipher")
+	}
+	ciphertext := make([]byte, len(padded))
+	cipher.XORKeyStream(ciphertext, padded)
+
+	payload := make([]byte, 0, 1+nonceSize+len(ciphertext)+macSize)
+	payload = append(payload, nip44Version)
+	payload = append(payload, nonce...)
+	payload = append(payload, ciphertext...)
+	payload = append(payload, mac(hmacKey, nonce, ciphertext)...)
+
+	return base64.StdEncoding.EncodeToString(payload), nil
+}
+
+// Decrypt verifies and decrypts a NIP-44 version 2 payload.
+func Decrypt(conversationKey []byte, payload string) (string, error) {
+	if len(payload) < minPayloadLen || len(payload) > maxPayloadLen {
+		return "", errors.Wrap(ErrInvalidPayload, "invalid length")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", errors.Wrap(ErrInvalidPayload, "invalid encoding")
+	}
+
+	if data[0] != nip44Version {
+		return "", errors.Wrapf(ErrInvalidPayload, "unsupported version %d", data[0])
+	}
+
+	nonce := data[1 : 1+nonceSize]
+	ciphertext := data[1+nonceSize : len(data)-macSize]
+	chachaKey, chachaNonce, hmacKey, err := messageKeys(conversationKey, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	if !hmac.Equal(mac(hmacKey, nonce, ciphertext), data[len(data)-macSize:]) {
+		return "", errors.Wrap(ErrInvalidPayload, "invalid MAC")
+	}
+
+	cipher, err := chacha20.NewUnauthenticatedCipher(chachaKey, chachaNonce)
+	if err != nil {
+		return "", errors.Wrap(err, "creating cipher")
+	}
+	padded := make([]byte, len(ciphertext))
+	cipher.XORKeyStream(padded, ciphertext)
+
+	return unpad(padded)
+}
+
+// messageKeys derives the keys used to encrypt a single message from the conversation key.
+func messageKeys(conversationKey, nonce []byte) ([]byte, []byte, []byte, error) {
+	if len(conversationKey) != 32 {
+		return nil, nil, nil, errors.New("invalid conversation key length")
+	}
+
+	keys := make([]byte, 76)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, conversationKey, nonce), keys); err != nil {
+		return nil, nil, nil, errors.Wrap(err, "deriving message keys")
+	}
+
+	return keys[:32], keys[32:44], keys[44:], nil
+}
+
+func mac(hmacKey, nonce, ciphertext []byte) []byte {
+	h := hmac.New(sha256.New, hmacKey)
+	h.Write(nonce)
+	h.Write(ciphertext)
+	return h.Sum(nil)
+}
+
+// pad prefixes the plaintext with its length and pads it with zeros, so the ciphertext doesn't
+// reveal its exact size.
+func pad(plaintext string) ([]byte, error) {
+	if len(plaintext) < minPlaintextLen || len(plaintext) > maxPlaintextLen {
+		return nil, errors.Errorf("invalid plaintext length %d", len(plaintext))
+	}
+
+	padded := make([]byte, 2+paddedLen(len(plaintext)))
+	binary.BigEndian.PutUint16(padded, uint16(len(plaintext)))
+	copy(padded[2:], plaintext)
+
+	return padded, nil
+}
+
+func unpad(padded []byte) (string, error) {
+	length := int(binary.BigEndian.Uint16(padded))
+	if length < minPlaintextLen || len(padded) != 2+paddedLen(length) {
+		return "", errors.Wrap(ErrInvalidPayload, "invalid padding")
+	}
+
+	return string(padded[2 : 2+length]), nil
+}
+
+// paddedLen returns the length the plaintext is padded to.
+func paddedLen(length int) int {
+	if length <= 32 {
+		return 32
+	}
+
+	nextPower := 1 << bits.Len(uint(length-1))
+	chunk := 32
+	if nextPower > 256 {
+		chunk = nextPower / 8
+	}
+
+	return chunk * ((length-1)/chunk + 1)
+}
@@ -0,0 +1,94 @@
+package nostr
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	nostrlib "github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNIP44(t *testing.T) {
+	// Test vector from the NIP-44 specification
+	privateKey := "0000000000000000000000000000000000000000000000000000000000000001"
+	publicKey := "c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+	nonce, err := hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000001")
+	assert.NoError(t, err)
+
+	conversationKey, err := ConversationKey(privateKey, publicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "c41c775356fd92eadc63ff5a0dc1da211b268cbea22316767095b2871ea1412d",
+		hex.EncodeToString(conversationKey))
+
+	payload, err := encrypt(conversationKey, nonce, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "AgAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAABee0G5VSK0/9YypIObAtDKfYEAjD35uVk"+
+		"HyB0F4DwrcNaCXlCWZKaArsGrY6M9wnuTMxWfp1RTN9Xga8no+kF5Vsb", payload)
+
+	plaintext, err := Decrypt(conversationKey, payload)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", plaintext)
+}
+
+func TestNIP44RoundTrip(t *testing.T) {
+	senderKey := nostrlib.GeneratePrivateKey()
+	recipientKey := nostrlib.GeneratePrivateKey()
+	senderPublicKey, err := nostrlib.GetPublicKey(senderKey)
+	assert.NoError(t, err)
+	recipientPublicKey, err := nostrlib.GetPublicKey(recipientKey)
+	assert.NoError(t, err)
+
+	// Both sides derive the same key
+	conversationKey, err := ConversationKey(senderKey, recipientPublicKey)
+	assert.NoError(t, err)
+	recipientConversationKey, err := ConversationKey(recipientKey, senderPublicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, conversationKey, recipientConversationKey)
+
+	for _, message := range []string{"a", strings.Repeat("b", 33), strings.Repeat("c", 1000)} {
+		payload, err := Encrypt(conversationKey, message)
+		assert.NoError(t, err)
+
+		plaintext, err := Decrypt(recipientConversationKey, payload)
+		assert.NoError(t, err)
+		assert.Equal(t, message, plaintext)
+	}
+
+	_, err = Encrypt(conversationKey, "")
+	assert.Error(t, err)
+}
+
+func TestNIP44DecryptInvalid(t *testing.T) {
+	conversationKey, err := ConversationKey(nostrlib.GeneratePrivateKey(),
+		"c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5")
+	assert.NoError(t, err)
+
+	payload, err := Encrypt(conversationKey, "message")
+	assert.NoError(t, err)
+
+	// Tamper with the ciphertext
+	tampered := []byte(payload)
+	tampered[50] ^= 1
+
+	_, err = Decrypt(conversationKey, string(tampered))
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+
+	_, err = Decrypt(conversationKey, "#invalid")
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+}
+
+func TestPaddedLen(t *testing.T) {
+	cases := map[int]int{
+		1:     32,
+		32:    32,
+		33:    64,
+		257:   320,
+		1025:  1280,
+		65535: 65536,
+	}
+
+	for length, expected := range cases {
+		assert.Equal(t, expected, paddedLen(length), length)
+	}
+}
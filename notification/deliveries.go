@@ -0,0 +1,47 @@
+package notification
+
+import (
+	"time"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// deliveries records the status of the messages sent through each channel.
+type deliveries struct {
+	db     *db.DB
+	logger *logger.Logger
+}
+
+// start records a new delivery as pending and returns it with its ID, which is zero if it couldn't
+// be stored. Deliveries that are retried are marked as pending again, so they aren't retried twice
+// while they are being sent.
+func (d deliveries) start(delivery db.NotificationDelivery) db.NotificationDelivery {
+	delivery.Status = db.DeliveryPending
+	if delivery.ID != 0 {
+		d.record(delivery)
+		return delivery
+	}
+
+	delivery.CreatedAt = time.Now().Unix()
+	id, err := d.db.Notifications.AddDelivery(delivery)
+	if err != nil {
+		d.logger.Error(errors.Wrap(err, "adding notification delivery"))
+	}
+	delivery.ID = id
+	return delivery
+}
+
+// record stores the status, attempts and error of the delivery.
+func (d deliveries) record(delivery db.NotificationDelivery) {
+	if delivery.ID == 0 {
+		return
+	}
+
+	delivery.UpdatedAt = time.Now().Unix()
+	if err := d.db.Notifications.UpdateDelivery(delivery); err != nil {
+		d.logger.Error(errors.Wrapf(err, "updating notification delivery %d", delivery.ID))
+	}
+}
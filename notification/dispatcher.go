@@ -0,0 +1,194 @@
+package notification
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/logger"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+// Telegram limits bots to about 30 messages per second across all chats and one per second to
+// the same chat, the defaults stay below them.
+const (
+	defaultWorkers      = 4
+	defaultGlobalRate   = 25
+	defaultChatInterval = time.Second
+	defaultMaxAttempts  = 3
+	queueSize           = 1024
+)
+
+// message is a telegram message waiting to be sent.
+type message struct {
+	delivery db.NotificationDelivery
+	chatID   int64
+}
+
+// dispatcher sends the telegram messages from a pool of workers, spacing them so the bot stays
+// within the global and per chat rate limits. Messages rejected by the flood control are retried
+// after the time advised by Telegram.
+//
+// The delivery status of each message is stored. Messages still queued when the server stops are
+// lost and remain pending.
+type dispatcher struct {
+	logger     *logger.Logger
+	deliveries deliveries
+	send       func(chatID int64, text string) error
+	sleep      func(time.Duration)
+	queue      chan message
+	// nextChat holds the time of the next message allowed to each chat, entries are removed
+	// once that time has passed
+	nextChat       map[int64]time.Time
+	nextGlobal     time.Time
+	globalInterval time.Duration
+	chatInterval   time.Duration
+	maxAttempts    uint32
+	mu             sync.Mutex
+}
+
+func newDispatcher(
+	config config.Dispatch,
+	db *db.DB,
+	logger *logger.Logger,
+	send func(chatID int64, text string) error,
+) *dispatcher {
+	if config.Workers == 0 {
+		config.Workers = defaultWorkers
+	}
+	if config.GlobalRate == 0 {
+		config.GlobalRate = defaultGlobalRate
+	}
+	if config.ChatInterval == 0 {
+		config.ChatInterval = defaultChatInterval
+	}
+	if config.MaxAttempts == 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+
+	d := &dispatcher{
+		logger:         logger,
+		deliveries:     deliveries{db: db, logger: logger},
+		send:           send,
+		sleep:          time.Sleep,
+		queue:          make(chan message, queueSize),
+		nextChat:       make(map[int64]time.Time),
+		globalInterval: time.Second / time.Duration(config.GlobalRate),
+		chatInterval:   config.ChatInterval,
+		maxAttempts:    uint32(config.MaxAttempts),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		go d.work()
+	}
+
+	return d
+}
+
+// dispatch records the delivery as pending and enqueues its message. It blocks if the queue is
+// full.
+func (d *dispatcher) dispatch(delivery db.NotificationDelivery, chatID int64) {
+	delivery.Channel = db.ChannelTelegram
+	d.queue <- message{
+		delivery: d.deliveries.start(delivery),
+		chatID:   chatID,
+	}
+}
+
+func (d *dispatcher) work() {
+	for msg := range d.queue {
+		d.deliver(msg)
+	}
+}
+
+// deliver sends the message, retrying it while Telegram rejects it for exceeding the rate limits.
+func (d *dispatcher) deliver(msg message) {
+	delivery := msg.delivery
+	// Deliveries retried later keep counting their attempts, the limit applies to each dispatch
+	for attempts := uint32(1); ; attempts++ {
+		d.sleep(d.reserve(msg.chatID))
+
+		delivery.Attempts++
+		err := d.send(msg.chatID, delivery.Message)
+		if err == nil {
+			delivery.Status = db.DeliverySent
+			delivery.Error = ""
+			d.deliveries.record(delivery)
+			return
+		}
+
+		delivery.Error = err.Error()
+		retryAfter, limited := rateLimited(err)
+		if !limited || attempts >= d.maxAttempts {
+			delivery.Status = db.DeliveryFailed
+			d.deliveries.record(delivery)
+			d.logger.Error(errors.Wrapf(err, "sending message to chat %d", msg.chatID))
+			return
+		}
+
+		delivery.Status = db.DeliveryPending
+		d.deliveries.record(delivery)
+		d.backoff(msg.chatID, retryAfter)
+	}
+}
+
+// reserve returns how long to wait before sending a message to the chat and books that moment.
+func (d *dispatcher) reserve(chatID int64) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for id, next := range d.nextChat {
+		if !next.After(now) {
+			delete(d.nextChat, id)
+		}
+	}
+
+	// A busy chat delays only its own messages, the global slot is booked independently
+	slot := now
+	if d.nextGlobal.After(slot) {
+		slot = d.nextGlobal
+	}
+	d.nextGlobal = slot.Add(d.globalInterval)
+
+	at := slot
+	if next, ok := d.nextChat[chatID]; ok && next.After(at) {
+		at = next
+	}
+	d.nextChat[chatID] = at.Add(d.chatInterval)
+
+	return at.Sub(now)
+}
+
+// backoff holds the messages to the chat and to every other chat until the time advised by
+// Telegram has passed.
+func (d *dispatcher) backoff(chatID int64, retryAfter time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	until := time.Now().Add(retryAfter)
+	if until.After(d.nextGlobal) {
+		d.nextGlobal = until
+	}
+	if until.After(d.nextChat[chatID]) {
+		d.nextChat[chatID] = until
+	}
+}
+
+// rateLimited returns whether the error is a flood control rejection and the time to wait before
+// retrying.
+func rateLimited(err error) (time.Duration, bool) {
+	var tgErr *tg.Error
+	if !errors.As(err, &tgErr) || tgErr.Code != 429 {
+		return 0, false
+	}
+
+	retryAfter := time.Duration(tgErr.RetryAfter) * time.Second
+	if retryAfter <= 0 {
+		retryAfter = time.Second
+	}
+	return retryAfter, true
+}
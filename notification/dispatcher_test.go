@@ -0,0 +1,173 @@
+package notification
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/logger"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDispatch(t *testing.T) {
+	publicKey := "345fe256754b1b472e58aede6c2f138ce67d05d431c776bcb4e384edbbdca9cd"
+	chatID := int64(123123)
+	message := "You won"
+
+	botAPI := NewTelegramBotAPIMock()
+	telegram := &telegram{botAPI: botAPI, botName: "BTRY"}
+	botAPI.On("Send", createTelegramMessage(chatID, message, telegram.botName)).Return(tg.Message{}, nil)
+
+	recorded := make(chan db.NotificationDelivery)
+	notificationsMock := db.NewNotificationsStoreMock()
+	notificationsMock.On("AddDelivery", mock.MatchedBy(func(d db.NotificationDelivery) bool {
+		return d.PublicKey == publicKey && d.Channel == db.ChannelTelegram && d.Message == message
+	})).Return(uint64(7), nil)
+	notificationsMock.On("UpdateDelivery", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		recorded <- args.Get(0).(db.NotificationDelivery)
+	})
+
+	dispatcher := newDispatcher(config.Dispatch{Workers: 1}, &db.DB{Notifications: notificationsMock},
+		&logger.Logger{}, telegram.send)
+	dispatcher.dispatch(db.NotificationDelivery{PublicKey: publicKey, Message: message}, chatID)
+
+	select {
+	case delivery := <-recorded:
+		assert.Equal(t, uint64(7), delivery.ID)
+		assert.Equal(t, db.DeliverySent, delivery.Status)
+		assert.Equal(t, uint32(1), delivery.Attempts)
+	case <-time.After(time.Second):
+		t.Fatal("message not sent")
+	}
+}
+
+func TestDeliverRateLimited(t *testing.T) {
+	chatID := int64(123123)
+	text := "Alert"
+
+	botAPI := NewTelegramBotAPIMock()
+	telegram := &telegram{botAPI: botAPI, botName: "BTRY"}
+	tgMessage := createTelegramMessage(chatID, text, telegram.botName)
+	tooManyRequests := &tg.Error{
+		Code:               429,
+		Message:            "Too Many Requests: retry after 5",
+		ResponseParameters: tg.ResponseParameters{RetryAfter: 5},
+	}
+
+	cases := []struct {
+		desc        string
+		sendErrors  []error
+		wantStatus  string
+		wantRetries int
+	}{
+		{
+			desc:        "Retried",
+			sendErrors:  []error{tooManyRequests, nil},
+			wantStatus:  db.DeliverySent,
+			wantRetries: 1,
+		},
+		{
+			desc:        "Attempts exhausted",
+			sendErrors:  []error{tooManyRequests, tooManyRequests, tooManyRequests},
+			wantStatus:  db.DeliveryFailed,
+			wantRetries: 2,
+		},
+		{
+			desc:       "Not retried",
+			sendErrors: []error{errors.New("Forbidden: bot was blocked by the user")},
+			wantStatus: db.DeliveryFailed,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			for _, err := range tc.sendErrors {
+				botAPI.On("Send", tgMessage).Return(tg.Message{}, err).Once()
+			}
+
+			var deliveries []db.NotificationDelivery
+			notificationsMock := db.NewNotificationsStoreMock()
+			notificationsMock.On("UpdateDelivery", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				deliveries = append(deliveries, args.Get(0).(db.NotificationDelivery))
+			})
+
+			dispatcher := newDispatcher(config.Dispatch{
+				Workers:      1,
+				GlobalRate:   1000,
+				ChatInterval: time.Millisecond,
+				MaxAttempts:  3,
+			},
+				&db.DB{Notifications: notificationsMock}, &logger.Logger{}, telegram.send)
+			var waits []time.Duration
+			dispatcher.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+			delivery := db.NotificationDelivery{ID: 1, Message: text}
+			dispatcher.deliver(message{chatID: chatID, delivery: delivery})
+
+			assert.Len(t, deliveries, len(tc.sendErrors))
+			last := deliveries[len(deliveries)-1]
+			assert.Equal(t, tc.wantStatus, last.Status)
+			assert.Equal(t, uint32(len(tc.sendErrors)), last.Attempts)
+
+			// Retries wait for the time advised by Telegram
+			var retries int
+			for _, wait := range waits[1:] {
+				assert.InDelta(t, 5*time.Second, wait, float64(100*time.Millisecond))
+				retries++
+			}
+			assert.Equal(t, tc.wantRetries, retries)
+		})
+	}
+}
+
+func TestDispatchRetry(t *testing.T) {
+	chatID := int64(123123)
+	message := "You won"
+
+	botAPI := NewTelegramBotAPIMock()
+	telegram := &telegram{botAPI: botAPI, botName: "BTRY"}
+	botAPI.On("Send", createTelegramMessage(chatID, message, telegram.botName)).Return(tg.Message{}, nil)
+
+	recorded := make(chan db.NotificationDelivery, 2)
+	notificationsMock := db.NewNotificationsStoreMock()
+	notificationsMock.On("UpdateDelivery", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		recorded <- args.Get(0).(db.NotificationDelivery)
+	})
+
+	dispatcher := newDispatcher(config.Dispatch{Workers: 1}, &db.DB{Notifications: notificationsMock},
+		&logger.Logger{}, telegram.send)
+	retry := db.NotificationDelivery{ID: 3, Status: db.DeliveryFailed, Message: message, Attempts: 3}
+	dispatcher.dispatch(retry, chatID)
+
+	// The retry is pending again until it's sent
+	for _, want := range []string{db.DeliveryPending, db.DeliverySent} {
+		select {
+		case delivery := <-recorded:
+			assert.Equal(t, uint64(3), delivery.ID)
+			assert.Equal(t, want, delivery.Status)
+		case <-time.After(time.Second):
+			t.Fatal("message not sent")
+		}
+	}
+	notificationsMock.AssertNotCalled(t, "AddDelivery", mock.Anything)
+}
+
+func TestReserve(t *testing.T) {
+	dispatcher := &dispatcher{
+		nextChat:       make(map[int64]time.Time),
+		globalInterval: 100 * time.Millisecond,
+		chatInterval:   time.Second,
+	}
+
+	assert.Zero(t, dispatcher.reserve(1))
+	assert.InDelta(t, 100*time.Millisecond, dispatcher.reserve(2), float64(10*time.Millisecond))
+	// The chat waits for its own interval
+	assert.InDelta(t, time.Second, dispatcher.reserve(1), float64(10*time.Millisecond))
+	// Other chats keep the global pace
+	assert.InDelta(t, 300*time.Millisecond, dispatcher.reserve(3), float64(10*time.Millisecond))
+}
@@ -2,8 +2,6 @@ package notification
 
 import (
 	"net/http"
-	"strconv"
-	"strings"
 
 	"github.com/aftermath2/BTRY/config"
 	"github.com/aftermath2/BTRY/db"
@@ -14,18 +12,41 @@ import (
 )
 
 type nostrc struct {
-	client *nostr.Client
+	client    *nostr.Client
+	templates *Templates
 }
 
 // newNostrNotifier returns a notifier that sends nostr events.
-func newNostrNotifier(config config.Nostr, logger *logger.Logger, torClient *http.Client) *nostrc {
+func newNostrNotifier(
+	config config.Nostr,
+	templates *Templates,
+	logger *logger.Logger,
+	torClient *http.Client,
+) *nostrc {
 	return &nostrc{
-		client: nostr.NewClient(config, logger, torClient),
+		client:    nostr.NewClient(config, logger, torClient),
+		templates: templates,
 	}
 }
 
+func (n *nostrc) PublishCommitment(blockHeight uint32, commitment string) error {
+	message, err := n.templates.Render(EventCommitment, Data{Height: blockHeight, Commitment: commitment})
+	if err != nil {
+		return err
+	}
+
+	if err := n.client.Publish(message); err != nil {
+		return errors.Wrap(err, "publishing event")
+	}
+
+	return nil
+}
+
 func (n *nostrc) PublishWinners(blockHeight uint32, winners []db.Winner) error {
-	message := buildMessage(blockHeight, winners)
+	message, err := buildMessage(n.templates, blockHeight, winners)
+	if err != nil {
+		return err
+	}
 
 	if err := n.client.Publish(message); err != nil {
 		return errors.Wrap(err, "publishing event")
@@ -34,26 +55,70 @@ func (n *nostrc) PublishWinners(blockHeight uint32, winners []db.Winner) error {
 	return nil
 }
 
-func buildMessage(blockHeight uint32, winners []db.Winner) string {
-	var msg strings.Builder
-	msg.WriteString("Lottery winners. Block: ")
-	msg.WriteString(strconv.FormatUint(uint64(blockHeight), 10))
-	msg.WriteString("\n------------------------------\n")
+func (n *nostrc) PublishReveal(blockHeight uint32, winners []db.Winner, salt string) error {
+	data := Data{Height: blockHeight, Winners: revealWinners(winners), Salt: salt}
+	message, err := n.templates.Render(EventReveal, data)
+	if err != nil {
+		return err
+	}
 
-	for i, winner := range winners {
-		if i != 0 {
-			msg.WriteByte('\n')
-		}
+	if err := n.client.Publish(message); err != nil {
+		return errors.Wrap(err, "publishing event")
+	}
+
+	return nil
+}
+
+func (n *nostrc) PublishMilestone(blockHeight uint32, amount uint64, blocksLeft uint32) error {
+	data := Data{Height: blockHeight, Prize: amount, BlocksLeft: blocksLeft}
+	message, err := n.templates.Render(EventMilestone, data)
+	if err != nil {
+		return err
+	}
 
-		msg.WriteString(strconv.FormatInt(int64(i+1), 10))
-		msg.WriteString(". Ticket #")
-		msg.WriteString(strconv.FormatUint(winner.Ticket, 10))
-		msg.WriteString(" from ")
-		msg.WriteString(winner.PublicKey)
-		msg.WriteString(" won ")
-		msg.WriteString(strconv.FormatUint(winner.Prize, 10))
-		msg.WriteString(" sats")
+	if err := n.client.Publish(message); err != nil {
+		return errors.Wrap(err, "publishing event")
 	}
 
-	return msg.String()
+	return nil
+}
+
+func (n *nostrc) PublishRevealCommitment(blockHeight uint32, resultsHash string, blocks uint32) error {
+	data := Data{Height: blockHeight, Commitment: resultsHash, BlocksLeft: blocks}
+	message, err := n.templates.Render(EventRevealCommitment, data)
+	if err != nil {
+		return err
+	}
+
+	if err := n.client.Publish(message); err != nil {
+		return errors.Wrap(err, "publishing event")
+	}
+
+	return nil
+}
+
+func (n *nostrc) SendDirectMessage(publicKey, message string) error {
+	if err := n.client.SendDirectMessage(publicKey, message); err != nil {
+		return errors.Wrap(err, "sending direct message")
+	}
+
+	return nil
+}
+
+// buildMessage returns the announcement of the winners of the lottery at the block height.
+func buildMessage(templates *Templates, blockHeight uint32, winners []db.Winner) (string, error) {
+	return templates.Render(EventDraw, Data{Height: blockHeight, Winners: drawWinners(winners)})
+}
+
+// displayName returns how the winner is shown in the announcements, the public key or alias may
+// be empty if the winner chose to hide them.
+func displayName(winner db.Winner) string {
+	switch {
+	case winner.Alias != "":
+		return winner.Alias
+	case winner.PublicKey != "":
+		return winner.PublicKey
+	default:
+		return "anonymous"
+	}
 }
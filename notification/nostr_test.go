@@ -38,7 +38,46 @@ func TestBuildMessage(t *testing.T) {
 		winners[1].Ticket, winners[1].PublicKey, winners[1].Prize,
 		winners[2].Ticket, winners[2].PublicKey, winners[2].Prize,
 	)
-	message := buildMessage(blockHeight, winners)
+	message, err := buildMessage(DefaultTemplates(), blockHeight, winners)
+	assert.NoError(t, err)
+
+	assert.Equal(t, expectedMessage, message)
+}
+
+func TestBuildMessagePools(t *testing.T) {
+	winners := []db.Winner{
+		{PublicKey: "One", Prize: 60, Ticket: 10, Pool: "micro"},
+		{PublicKey: "Two", Prize: 30, Ticket: 20, Pool: "micro"},
+		{PublicKey: "Three", Prize: 900, Ticket: 30, Pool: "whale"},
+	}
+	expectedMessage := `Lottery winners. Block: 300000
+------------------------------
+Pool micro:
+1. Ticket #10 from One won 60 sats
+2. Ticket #20 from Two won 30 sats
+Pool whale:
+1. Ticket #30 from Three won 900 sats`
+
+	message, err := buildMessage(DefaultTemplates(), 300000, winners)
+	assert.NoError(t, err)
+
+	assert.Equal(t, expectedMessage, message)
+}
+
+func TestBuildMessageAnonymized(t *testing.T) {
+	winners := []db.Winner{
+		{PublicKey: "02abcdef...12345678", Prize: 60, Ticket: 10},
+		{Alias: "lucky", Prize: 30, Ticket: 20},
+		{Prize: 10, Ticket: 30},
+	}
+	expectedMessage := `Lottery winners. Block: 300000
+------------------------------
+1. Ticket #10 from 02abcdef...12345678 won 60 sats
+2. Ticket #20 from lucky won 30 sats
+3. Ticket #30 from anonymous won 10 sats`
+
+	message, err := buildMessage(DefaultTemplates(), 300000, winners)
+	assert.NoError(t, err)
 
 	assert.Equal(t, expectedMessage, message)
 }
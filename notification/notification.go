@@ -4,39 +4,64 @@ package notification
 
 import (
 	"net/http"
+	"reflect"
+	"sync"
 
 	"github.com/aftermath2/BTRY/config"
 	"github.com/aftermath2/BTRY/db"
 	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
 )
 
-// Notification message formats
+// Telegram bot replies, the messages sent on lottery events are rendered from the templates
 const (
-	AutomaticWithdrawal = "%d sats were withdrawn to %s. Preimage: %s"
-	Congratulations     = "Congratulations! You have won %d sats, your prizes expire at block %d."
-	welcome             = "Hello @%s! I will send you a notification if you win."
-	errInvalidMessage   = "Message not recognized. Enable notifications using `/start " +
+	welcome           = "Hello @%s! I will send you a notification if you win."
+	errInvalidMessage = "Message not recognized. Enable notifications using `/start " +
 		"<public_key>` or scanning the QR code on BTRY's web client."
 	errInvalidPublicKey = "The public key %q is invalid."
 	errInternalError    = "Something went wrong. Please try again later or contact an admin."
 	errAlreadyEnabled   = "The public key already has notifications enabled"
 )
 
+// DeadlineLayout is the format used to display the prizes expiration time.
+const DeadlineLayout = "Jan 2, 2006 15:04 UTC"
+
 // Notifier represents a service that is used to send messages to winners.
 type Notifier interface {
 	GetUpdates()
 	Notify(chatID int64, message string)
+	NotifyNostr(delivery db.NotificationDelivery, nostrKey string)
+	NotifyPlayer(delivery db.NotificationDelivery, chatID int64)
+	PublishCommitment(blockHeight uint32, commitment string) error
+	PublishMilestone(blockHeight uint32, amount uint64, blocksLeft uint32) error
+	PublishReveal(blockHeight uint32, winners []db.Winner, salt string) error
+	PublishRevealCommitment(blockHeight uint32, resultsHash string, blocks uint32) error
 	PublishWinners(blockHeight uint32, winners []db.Winner) error
+	Reload(config config.Notifier) (func(), error)
 }
 
 type notifier struct {
-	telegram *telegram
-	nostr    *nostrc
-	enabled  bool
+	telegram   *telegram
+	dispatcher *dispatcher
+	deliveries deliveries
+	nostr      *nostrc
+	logger     *logger.Logger
+	torClient  *http.Client
+	templates  *Templates
+	config     config.Notifier
+	enabled    bool
+	// mu protects the nostr client and the configuration, which are replaced on reloads
+	mu sync.RWMutex
 }
 
 // NewNotifier returns a new notification sender.
-func NewNotifier(config config.Notifier, db *db.DB, torClient *http.Client) (Notifier, error) {
+func NewNotifier(
+	config config.Notifier,
+	db *db.DB,
+	templates *Templates,
+	torClient *http.Client,
+) (Notifier, error) {
 	logger, err := logger.New(config.Logger)
 	if err != nil {
 		return nil, err
@@ -53,9 +78,15 @@ func NewNotifier(config config.Notifier, db *db.DB, torClient *http.Client) (Not
 	}
 
 	return &notifier{
-		enabled:  config.Enabled,
-		telegram: telegram,
-		nostr:    newNostrNotifier(config.Nostr, logger, torClient),
+		enabled:    config.Enabled,
+		telegram:   telegram,
+		dispatcher: newDispatcher(config.Dispatch, db, logger, telegram.send),
+		deliveries: deliveries{db: db, logger: logger},
+		nostr:      newNostrNotifier(config.Nostr, templates, logger, torClient),
+		logger:     logger,
+		torClient:  torClient,
+		templates:  templates,
+		config:     config,
 	}, nil
 }
 
@@ -66,16 +97,151 @@ func (n *notifier) GetUpdates() {
 	n.telegram.GetUpdates()
 }
 
+// Notify enqueues a telegram message to the chat, it's used for the operators alerts.
 func (n *notifier) Notify(chatID int64, message string) {
 	if !n.enabled {
 		return
 	}
-	n.telegram.Notify(chatID, message)
+	n.dispatcher.dispatch(db.NotificationDelivery{Message: message}, chatID)
+}
+
+// NotifyPlayer enqueues the message of the delivery to the telegram chat linked to its public key.
+// Deliveries with an ID are retries, their attempts are recorded in the same delivery.
+func (n *notifier) NotifyPlayer(delivery db.NotificationDelivery, chatID int64) {
+	if !n.enabled {
+		return
+	}
+	n.dispatcher.dispatch(delivery, chatID)
+}
+
+// NotifyNostr sends the message of the delivery as an end-to-end encrypted direct message to the
+// nostr public key and records whether the relays accepted it. Deliveries with an ID are retries.
+func (n *notifier) NotifyNostr(delivery db.NotificationDelivery, nostrKey string) {
+	if !n.enabled {
+		return
+	}
+
+	n.mu.RLock()
+	nostr := n.nostr
+	n.mu.RUnlock()
+
+	delivery.Channel = db.ChannelNostr
+	delivery = n.deliveries.start(delivery)
+	delivery.Attempts++
+
+	if err := nostr.SendDirectMessage(nostrKey, delivery.Message); err != nil {
+		n.logger.Error(errors.Wrapf(err, "sending direct message to %s", nostrKey))
+		delivery.Status = db.DeliveryFailed
+		delivery.Error = err.Error()
+	} else {
+		delivery.Status = db.DeliverySent
+		delivery.Error = ""
+	}
+	n.deliveries.record(delivery)
+}
+
+// PublishCommitment announces the commitment to the server seed of the lottery at the block height
+// specified.
+func (n *notifier) PublishCommitment(blockHeight uint32, commitment string) error {
+	if !n.enabled {
+		return nil
+	}
+
+	n.mu.RLock()
+	nostr := n.nostr
+	n.mu.RUnlock()
+
+	return nostr.PublishCommitment(blockHeight, commitment)
+}
+
+// PublishMilestone announces that the prize pool of the lottery at the block height crossed the
+// amount.
+func (n *notifier) PublishMilestone(blockHeight uint32, amount uint64, blocksLeft uint32) error {
+	if !n.enabled {
+		return nil
+	}
+
+	n.mu.RLock()
+	nostr := n.nostr
+	n.mu.RUnlock()
+
+	return nostr.PublishMilestone(blockHeight, amount, blocksLeft)
+}
+
+// PublishReveal announces the winners of a tier of the lottery at the block height. The salt of the
+// results hash is only set along with the first place.
+func (n *notifier) PublishReveal(blockHeight uint32, winners []db.Winner, salt string) error {
+	if !n.enabled {
+		return nil
+	}
+
+	n.mu.RLock()
+	nostr := n.nostr
+	n.mu.RUnlock()
+
+	return nostr.PublishReveal(blockHeight, winners, salt)
+}
+
+// PublishRevealCommitment announces the hash of the results of the lottery at the block height,
+// whose winners are revealed over the following blocks.
+func (n *notifier) PublishRevealCommitment(blockHeight uint32, resultsHash string, blocks uint32) error {
+	if !n.enabled {
+		return nil
+	}
+
+	n.mu.RLock()
+	nostr := n.nostr
+	n.mu.RUnlock()
+
+	return nostr.PublishRevealCommitment(blockHeight, resultsHash, blocks)
 }
 
 func (n *notifier) PublishWinners(blockHeight uint32, winners []db.Winner) error {
 	if !n.enabled {
 		return nil
 	}
-	return n.nostr.PublishWinners(blockHeight, winners)
+
+	n.mu.RLock()
+	nostr := n.nostr
+	n.mu.RUnlock()
+
+	return nostr.PublishWinners(blockHeight, winners)
+}
+
+// Reload prepares the clients with the credentials of the configuration passed and returns the
+// function that starts using them. The current clients are kept if it fails.
+func (n *notifier) Reload(config config.Notifier) (func(), error) {
+	if !n.enabled {
+		return func() {}, nil
+	}
+
+	n.mu.RLock()
+	current := n.config
+	n.mu.RUnlock()
+
+	// Avoid restarting the telegram updates polling if the credentials didn't change
+	var botAPI botAPI
+	if config.Telegram != current.Telegram {
+		var err error
+		botAPI, err = newBotAPI(config.Telegram, n.torClient)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	apply := func() {
+		if botAPI != nil {
+			n.telegram.swap(botAPI, config.Telegram.BotName)
+		}
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		if !reflect.DeepEqual(config.Nostr, current.Nostr) {
+			n.nostr = newNostrNotifier(config.Nostr, n.templates, n.logger, n.torClient)
+		}
+		n.config = config
+	}
+
+	return apply, nil
 }
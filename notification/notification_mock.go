@@ -1,6 +1,7 @@
 package notification
 
 import (
+	"github.com/aftermath2/BTRY/config"
 	"github.com/aftermath2/BTRY/db"
 
 	"github.com/stretchr/testify/mock"
@@ -24,8 +25,48 @@ func (n *NotifierMock) Notify(chatID int64, message string) {
 	_ = n.Called(chatID, message)
 }
 
+// NotifyNostr mock.
+func (n *NotifierMock) NotifyNostr(delivery db.NotificationDelivery, nostrKey string) {
+	_ = n.Called(delivery, nostrKey)
+}
+
+// NotifyPlayer mock.
+func (n *NotifierMock) NotifyPlayer(delivery db.NotificationDelivery, chatID int64) {
+	_ = n.Called(delivery, chatID)
+}
+
+// PublishCommitment mock.
+func (n *NotifierMock) PublishCommitment(blockHeight uint32, commitment string) error {
+	args := n.Called(blockHeight, commitment)
+	return args.Error(0)
+}
+
+// PublishReveal mock.
+func (n *NotifierMock) PublishReveal(blockHeight uint32, winners []db.Winner, salt string) error {
+	args := n.Called(blockHeight, winners, salt)
+	return args.Error(0)
+}
+
+// PublishMilestone mock.
+func (n *NotifierMock) PublishMilestone(blockHeight uint32, amount uint64, blocksLeft uint32) error {
+	args := n.Called(blockHeight, amount, blocksLeft)
+	return args.Error(0)
+}
+
+// PublishRevealCommitment mock.
+func (n *NotifierMock) PublishRevealCommitment(blockHeight uint32, resultsHash string, blocks uint32) error {
+	args := n.Called(blockHeight, resultsHash, blocks)
+	return args.Error(0)
+}
+
 // PublishWinners mock.
 func (n *NotifierMock) PublishWinners(blockHeight uint32, winners []db.Winner) error {
 	_ = n.Called(blockHeight, winners)
 	return nil
 }
+
+// Reload mock.
+func (n *NotifierMock) Reload(config config.Notifier) (func(), error) {
+	args := n.Called(config)
+	return args.Get(0).(func()), args.Error(1)
+}
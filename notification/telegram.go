@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/aftermath2/BTRY/config"
 	"github.com/aftermath2/BTRY/crypto"
@@ -27,6 +28,7 @@ var escapedCharacters = map[rune]struct{}{
 type botAPI interface {
 	GetUpdatesChan(config tg.UpdateConfig) tg.UpdatesChannel
 	Send(c tg.Chattable) (tg.Message, error)
+	StopReceivingUpdates()
 }
 
 type telegram struct {
@@ -34,6 +36,8 @@ type telegram struct {
 	botAPI  botAPI
 	db      *db.DB
 	botName string
+	// mu protects the bot API and name, which are replaced when the configuration is reloaded
+	mu sync.RWMutex
 }
 
 func newTelegramNotifier(
@@ -42,9 +46,9 @@ func newTelegramNotifier(
 	logger *logger.Logger,
 	torClient *http.Client,
 ) (*telegram, error) {
-	botAPI, err := tg.NewBotAPIWithClient(config.BotAPIToken, tg.APIEndpoint, torClient)
+	botAPI, err := newBotAPI(config, torClient)
 	if err != nil {
-		return nil, errors.Wrap(err, "creating telegram bot API")
+		return nil, err
 	}
 
 	return &telegram{
@@ -55,17 +59,39 @@ func newTelegramNotifier(
 	}, nil
 }
 
-func (t *telegram) GetUpdates() {
-	updatesChannel := t.botAPI.GetUpdatesChan(tg.UpdateConfig{Timeout: 10})
+func newBotAPI(config config.Telegram, torClient *http.Client) (botAPI, error) {
+	botAPI, err := tg.NewBotAPIWithClient(config.BotAPIToken, tg.APIEndpoint, torClient)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating telegram bot API")
+	}
+
+	return botAPI, nil
+}
 
+func (t *telegram) GetUpdates() {
 	for {
-		select {
-		case update := <-updatesChannel:
+		t.mu.RLock()
+		botAPI := t.botAPI
+		t.mu.RUnlock()
+
+		// The channel is closed when the bot API is replaced, continue with the new one
+		for update := range botAPI.GetUpdatesChan(tg.UpdateConfig{Timeout: 10}) {
 			t.processUpdate(update)
 		}
 	}
 }
 
+// swap replaces the bot API and stops receiving updates from the previous one.
+func (t *telegram) swap(botAPI botAPI, botName string) {
+	t.mu.Lock()
+	previous := t.botAPI
+	t.botAPI = botAPI
+	t.botName = botName
+	t.mu.Unlock()
+
+	previous.StopReceivingUpdates()
+}
+
 func (t *telegram) processUpdate(update tg.Update) {
 	chatID := update.Message.From.ID
 	// Message should have the format `/start <public_key>`
@@ -95,14 +121,24 @@ func (t *telegram) processUpdate(update tg.Update) {
 	t.Notify(chatID, fmt.Sprintf(welcome, update.Message.From.UserName))
 }
 
+// Notify sends the message right away, it's used for the bot replies.
 func (t *telegram) Notify(chatID int64, message string) {
+	if err := t.send(chatID, message); err != nil {
+		t.logger.Error(errors.Wrapf(err, "sending message to chat %d", chatID))
+	}
+}
+
+func (t *telegram) send(chatID int64, message string) error {
+	t.mu.RLock()
+	botAPI, botName := t.botAPI, t.botName
+	t.mu.RUnlock()
+
 	msg := tg.NewMessage(chatID, formatMessage(message))
 	msg.ParseMode = tg.ModeMarkdownV2
-	msg.ChannelUsername = t.botName
+	msg.ChannelUsername = botName
 
-	if _, err := t.botAPI.Send(msg); err != nil {
-		t.logger.Error(errors.Wrapf(err, "sending message to chat %d", chatID))
-	}
+	_, err := botAPI.Send(msg)
+	return err
 }
 
 func formatMessage(message string) string {
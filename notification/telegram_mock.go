@@ -26,3 +26,8 @@ func (t *TelegramBotAPIMock) Send(c tg.Chattable) (tg.Message, error) {
 	args := t.Called(c)
 	return args.Get(0).(tg.Message), args.Error(1)
 }
+
+// StopReceivingUpdates mock.
+func (t *TelegramBotAPIMock) StopReceivingUpdates() {
+	_ = t.Called()
+}
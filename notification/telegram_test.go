@@ -157,3 +157,26 @@ func createTelegramMessage(chatID int64, message, botName string) tg.MessageConf
 	msg.ChannelUsername = botName
 	return msg
 }
+
+func TestSwap(t *testing.T) {
+	previous := NewTelegramBotAPIMock()
+	previous.On("StopReceivingUpdates").Return()
+	telegram := &telegram{
+		botAPI:  previous,
+		botName: "BTRY",
+	}
+
+	botAPI := NewTelegramBotAPIMock()
+	telegram.swap(botAPI, "BTRY2")
+
+	chatID := int64(123123)
+	message := "Hello, world"
+
+	tgMessage := createTelegramMessage(chatID, message, "BTRY2")
+	botAPI.On("Send", tgMessage).Return(tg.Message{}, nil)
+
+	telegram.Notify(chatID, message)
+
+	previous.AssertExpectations(t)
+	botAPI.AssertExpectations(t)
+}
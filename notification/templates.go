@@ -0,0 +1,271 @@
+package notification
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// Event identifies the message sent when something happens.
+type Event string
+
+// Events with a message template.
+const (
+	EventCommitment       Event = "commitment"
+	EventDigest           Event = "digest"
+	EventDraw             Event = "draw"
+	EventFinalReminder    Event = "final_reminder"
+	EventMilestone        Event = "milestone"
+	EventPayoutFailed     Event = "payout_failed"
+	EventPayoutSucceeded  Event = "payout_succeeded"
+	EventPayoutUnroutable Event = "payout_unroutable"
+	EventPostponed        Event = "postponed"
+	EventRefund           Event = "refund"
+	EventReminder         Event = "reminder"
+	EventReveal           Event = "reveal"
+	EventRevealCommitment Event = "reveal_commitment"
+	EventWin              Event = "win"
+	EventWithdrawal       Event = "withdrawal"
+	EventWithdrawalFailed Event = "withdrawal_failed"
+)
+
+// templateExtension is the extension of the template files in the templates directory.
+const templateExtension = ".tmpl"
+
+// defaultTemplates contains the messages sent when the operator doesn't override them.
+var defaultTemplates = map[Event]string{
+	EventCommitment: "Lottery commitment. Block: {{.Height}}\nSHA256 of the server seed: {{.Commitment}}\n" +
+		"The seed will be revealed after the draw, the winning tickets are generated with the hash " +
+		"of the seed and the block hash.",
+	EventDigest: "Lottery {{.Height}} was drawn. {{if .Prize}}You won {{.Prize}} sats!" +
+		"{{else}}Your tickets didn't win this time.{{end}}\nWinning tickets: " +
+		"{{range $i, $t := .Tickets}}{{if $i}}, {{end}}#{{$t}}{{end}}" +
+		"{{if .VerifyURL}}\nVerify the draw: {{.VerifyURL}}?height={{.Height}}{{end}}",
+	EventDraw: "Lottery winners. Block: {{.Height}}\n------------------------------\n" +
+		"{{range $i, $w := .Winners}}{{if $i}}\n{{end}}" +
+		"{{if and $w.FirstInPool $w.Pool}}Pool {{$w.Pool}}:\n{{end}}" +
+		"{{$w.Place}}. Ticket #{{$w.Ticket}} from {{$w.Name}} won {{$w.Prize}} sats{{end}}",
+	EventFinalReminder: "Last reminder: your {{.Prize}} sats of unclaimed prizes from the lottery " +
+		"{{.Height}} expire in {{.BlocksLeft}} blocks, at block {{.DeadlineHeight}} (approximately " +
+		"{{.Deadline}}). Claim them before they are lost.{{if .ClaimURL}} {{.ClaimURL}}{{end}}",
+	EventMilestone: "The prize pool of the lottery {{.Height}} crossed {{.Prize}} sats! The draw " +
+		"takes place in {{.BlocksLeft}} blocks, get your tickets before it.",
+	EventPayoutFailed: "Your withdrawal of {{.Prize}} sats failed ({{.Reason}}), the prizes were " +
+		"returned and can be claimed again with another invoice before they expire." +
+		"{{if .ClaimURL}} {{.ClaimURL}}{{end}}",
+	EventPayoutSucceeded: "Your withdrawal of {{.Prize}} sats was paid. Preimage: {{.Preimage}}",
+	EventPayoutUnroutable: "No reliable route to {{.Address}} was found to send your {{.Prize}} sats " +
+		"automatically, please claim your prizes with an invoice from a wallet with good connectivity, " +
+		"or a wrapped one, before they expire.{{if .ClaimURL}} {{.ClaimURL}}{{end}}",
+	EventPostponed: "The draw of the lottery {{.Height}} was postponed because the node can't pay " +
+		"its prizes right now, it will be attempted again in {{.BlocksLeft}} blocks. Your tickets " +
+		"are kept, new bets are paused until the draw takes place.",
+	EventRefund: "The lottery {{.Height}} could not be drawn because the server was offline for too " +
+		"long. Your {{.Prize}} sats bet was refunded and it can be withdrawn until block " +
+		"{{.DeadlineHeight}} (approximately {{.Deadline}}).{{if .ClaimURL}} {{.ClaimURL}}{{end}}",
+	EventReminder: "Reminder: you have {{.Prize}} sats of unclaimed prizes from the lottery " +
+		"{{.Height}}, they expire at block {{.DeadlineHeight}} (approximately {{.Deadline}})." +
+		"{{if .ClaimURL}} {{.ClaimURL}}{{end}}",
+	EventReveal: "Lottery winners reveal. Block: {{.Height}}\n------------------------------\n" +
+		"{{range $i, $w := .Winners}}{{if $i}}\n{{end}}{{if $w.Pool}}Pool {{$w.Pool}}, {{end}}" +
+		"{{$w.Place}}. Ticket #{{$w.Ticket}} from {{$w.Name}} won {{$w.Prize}} sats{{end}}" +
+		"{{if .Salt}}\nSalt of the results: {{.Salt}}{{end}}",
+	EventRevealCommitment: "Lottery {{.Height}} was drawn. The winners will be revealed over the next " +
+		"{{.BlocksLeft}} blocks, from the last place to the first one.\nSHA256 of the results: " +
+		"{{.Commitment}}",
+	EventWin: "Congratulations! You have won {{.Prize}} sats, your prizes expire at block " +
+		"{{.DeadlineHeight}} (approximately {{.Deadline}}).{{if .ClaimURL}} {{.ClaimURL}}{{end}}",
+	EventWithdrawal: "{{.Prize}} sats were withdrawn to {{.Address}}. Preimage: {{.Preimage}}",
+	EventWithdrawalFailed: "The automatic withdrawal of {{.Prize}} sats to {{.Address}} failed, " +
+		"please claim your prizes manually before they expire.{{if .ClaimURL}} {{.ClaimURL}}{{end}}",
+}
+
+// Data contains the variables available to the templates. Each event sets the ones related to it.
+type Data struct {
+	// Winners of the lottery, in the draw announcements
+	Winners []DrawWinner
+	// Tickets are the winning tickets of the lottery, in the digests
+	Tickets []uint64
+	// Deadline is the approximate time at which the prizes expire
+	Deadline string
+	// ClaimURL is the page where prizes are claimed, taken from the configuration
+	ClaimURL string
+	// VerifyURL is the page where the draws are verified, taken from the configuration
+	VerifyURL string
+	// Reason is the cause of a failed payout
+	Reason     string
+	Address    string
+	Preimage   string
+	Commitment string
+	// Salt is the secret the results hash was salted with, disclosed with the first place
+	Salt  string
+	Prize uint64
+	// Height is the height of the lottery the message refers to
+	Height uint32
+	// DeadlineHeight is the block height at which the prizes expire
+	DeadlineHeight uint32
+	BlocksLeft     uint32
+}
+
+// DrawWinner is a winner as displayed in the draw announcements.
+type DrawWinner struct {
+	Pool string
+	// Name is the alias or public key of the winner, depending on their privacy settings
+	Name   string
+	Prize  uint64
+	Ticket uint64
+	// Place starts from one in each pool
+	Place int
+	// FirstInPool is true for the first winner of each pool
+	FirstInPool bool
+}
+
+// Templates renders the notification messages.
+type Templates struct {
+	templates map[Event]*template.Template
+	claimURL  string
+	verifyURL string
+}
+
+// NewTemplates parses the default templates and the ones the operator overrides, which are read
+// from the directory configured and then from the configuration itself. Every template is executed
+// with sample data to detect errors at startup.
+func NewTemplates(config config.Templates) (*Templates, error) {
+	sources := make(map[Event]string, len(defaultTemplates))
+	for event, text := range defaultTemplates {
+		sources[event] = text
+	}
+
+	if config.Dir != "" {
+		for event := range defaultTemplates {
+			path := filepath.Join(config.Dir, string(event)+templateExtension)
+			content, err := os.ReadFile(path)
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				return nil, errors.Wrapf(err, "reading %s template", event)
+			}
+			sources[event] = strings.TrimSuffix(string(content), "\n")
+		}
+	}
+
+	for name, text := range config.Messages {
+		event := Event(name)
+		if _, ok := defaultTemplates[event]; !ok {
+			return nil, errors.Errorf("unknown notification template %q", name)
+		}
+		sources[event] = text
+	}
+
+	templates := &Templates{
+		templates: make(map[Event]*template.Template, len(sources)),
+		claimURL:  config.ClaimURL,
+		verifyURL: config.VerifyURL,
+	}
+	for event, text := range sources {
+		tmpl, err := template.New(string(event)).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %s template", event)
+		}
+
+		if err := tmpl.Execute(io.Discard, sampleData); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s template", event)
+		}
+		templates.templates[event] = tmpl
+	}
+
+	return templates, nil
+}
+
+// DefaultTemplates returns the templates used when the operator doesn't override them.
+func DefaultTemplates() *Templates {
+	templates, err := NewTemplates(config.Templates{})
+	if err != nil {
+		panic(err)
+	}
+	return templates
+}
+
+// Render returns the message of the event.
+func (t *Templates) Render(event Event, data Data) (string, error) {
+	tmpl, ok := t.templates[event]
+	if !ok {
+		return "", errors.Errorf("unknown notification template %q", event)
+	}
+
+	if data.ClaimURL == "" {
+		data.ClaimURL = t.claimURL
+	}
+	if data.VerifyURL == "" {
+		data.VerifyURL = t.verifyURL
+	}
+
+	var message strings.Builder
+	if err := tmpl.Execute(&message, data); err != nil {
+		return "", errors.Wrapf(err, "rendering %s template", event)
+	}
+
+	return message.String(), nil
+}
+
+// drawWinners returns the winners as displayed in the draw announcements. Winners are sorted by
+// pool and the places start from one in each of them.
+func drawWinners(winners []db.Winner) []DrawWinner {
+	result := make([]DrawWinner, 0, len(winners))
+	place := 0
+	for i, winner := range winners {
+		firstInPool := i == 0 || winner.Pool != winners[i-1].Pool
+		if firstInPool {
+			place = 0
+		}
+		place++
+
+		result = append(result, DrawWinner{
+			Pool:        winner.Pool,
+			Name:        displayName(winner),
+			Prize:       winner.Prize,
+			Ticket:      winner.Ticket,
+			Place:       place,
+			FirstInPool: firstInPool,
+		})
+	}
+
+	return result
+}
+
+// revealWinners returns the winners of a tier as displayed in its reveal announcement, their place
+// is taken from the tier as the rest of the pool is not included.
+func revealWinners(winners []db.Winner) []DrawWinner {
+	result := drawWinners(winners)
+	for i, winner := range winners {
+		result[i].Place = int(winner.Tier) + 1
+	}
+	return result
+}
+
+// sampleData is used to validate the templates, it sets every variable so all the branches can be
+// executed.
+var sampleData = Data{
+	Winners:        []DrawWinner{{Pool: "pool", Name: "name", Prize: 1, Ticket: 1, Place: 1, FirstInPool: true}},
+	Tickets:        []uint64{1},
+	Deadline:       DeadlineLayout,
+	ClaimURL:       "https://example.com",
+	VerifyURL:      "https://example.com",
+	Reason:         "reason",
+	Address:        "address",
+	Preimage:       "preimage",
+	Commitment:     "commitment",
+	Prize:          1,
+	Height:         1,
+	DeadlineHeight: 1,
+	BlocksLeft:     1,
+}
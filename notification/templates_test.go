@@ -0,0 +1,131 @@
+package notification
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTemplates(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "win.tmpl"), []byte("You won {{.Prize}} sats\n"), 0o600)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "refund.tmpl"), []byte("Refunded {{.Prize}} sats"), 0o600)
+	assert.NoError(t, err)
+
+	templates, err := NewTemplates(config.Templates{
+		Dir: dir,
+		Messages: map[string]string{
+			"refund": "Lottery {{.Height}} refunded, claim it at {{.ClaimURL}}",
+		},
+		ClaimURL: "https://btry.example/withdraw",
+	})
+	assert.NoError(t, err)
+
+	data := Data{Prize: 100, Height: 144}
+
+	cases := []struct {
+		event    Event
+		expected string
+	}{
+		{
+			event:    EventWin,
+			expected: "You won 100 sats",
+		},
+		{
+			event:    EventRefund,
+			expected: "Lottery 144 refunded, claim it at https://btry.example/withdraw",
+		},
+		{
+			event:    EventWithdrawal,
+			expected: "100 sats were withdrawn to . Preimage: ",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.event), func(t *testing.T) {
+			message, err := templates.Render(tc.event, data)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, message)
+		})
+	}
+}
+
+func TestNewTemplatesErrors(t *testing.T) {
+	cases := []struct {
+		desc     string
+		messages map[string]string
+	}{
+		{
+			desc:     "Unknown event",
+			messages: map[string]string{"jackpot": "{{.Prize}}"},
+		},
+		{
+			desc:     "Invalid syntax",
+			messages: map[string]string{"win": "{{.Prize"},
+		},
+		{
+			desc:     "Unknown variable",
+			messages: map[string]string{"win": "{{.Amount}}"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := NewTemplates(config.Templates{Messages: tc.messages})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRenderClaimURL(t *testing.T) {
+	templates, err := NewTemplates(config.Templates{ClaimURL: "https://btry.example/withdraw"})
+	assert.NoError(t, err)
+
+	message, err := templates.Render(EventWin, Data{Prize: 100, DeadlineHeight: 864, Deadline: "soon"})
+	assert.NoError(t, err)
+
+	expected := "Congratulations! You have won 100 sats, your prizes expire at block 864 " +
+		"(approximately soon). https://btry.example/withdraw"
+	assert.Equal(t, expected, message)
+}
+
+func TestRenderDigest(t *testing.T) {
+	templates, err := NewTemplates(config.Templates{VerifyURL: "https://btry.example/api/lottery/archive"})
+	assert.NoError(t, err)
+
+	message, err := templates.Render(EventDigest, Data{Height: 144, Tickets: []uint64{7, 21}})
+	assert.NoError(t, err)
+
+	expected := "Lottery 144 was drawn. Your tickets didn't win this time.\nWinning tickets: #7, #21\n" +
+		"Verify the draw: https://btry.example/api/lottery/archive?height=144"
+	assert.Equal(t, expected, message)
+
+	message, err = templates.Render(EventDigest, Data{Height: 144, Prize: 50, Tickets: []uint64{7}})
+	assert.NoError(t, err)
+	assert.Contains(t, message, "You won 50 sats!")
+}
+
+func TestRenderUnknownEvent(t *testing.T) {
+	_, err := DefaultTemplates().Render("jackpot", Data{})
+	assert.Error(t, err)
+}
+
+func TestRenderReveal(t *testing.T) {
+	winners := []db.Winner{{PublicKey: "pubkey", Prize: 10, Ticket: 7, Tier: 7}}
+	message, err := DefaultTemplates().Render(EventReveal, Data{Height: 144, Winners: revealWinners(winners)})
+	assert.NoError(t, err)
+
+	expected := "Lottery winners reveal. Block: 144\n------------------------------\n" +
+		"8. Ticket #7 from pubkey won 10 sats"
+	assert.Equal(t, expected, message)
+
+	message, err = DefaultTemplates().Render(EventReveal, Data{Height: 144, Winners: revealWinners(winners), Salt: "5a17"})
+	assert.NoError(t, err)
+	assert.Equal(t, expected+"\nSalt of the results: 5a17", message)
+}
@@ -0,0 +1,30 @@
+package policy
+
+import (
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// ErrAccessDenied is returned when the access lists don't let a public key bet or withdraw.
+var ErrAccessDenied = errors.New("public key not allowed")
+
+// CheckAccess returns ErrAccessDenied if the access lists of the action don't let the public key
+// perform it, recording the rejection in the audit log. Anonymous bets, without a public key, are
+// only rejected when the action has an allow list.
+func CheckAccess(accessLists db.AccessListsStore, auditor audit.Auditor, publicKey, action string) error {
+	allowed, err := accessLists.Allowed(publicKey, action)
+	if err != nil {
+		return err
+	}
+	if allowed {
+		return nil
+	}
+
+	auditor.Record(audit.AccessDenied, map[string]any{
+		"public_key": publicKey,
+		"action":     action,
+	})
+	return errors.Wrapf(ErrAccessDenied, "%s are not available", action)
+}
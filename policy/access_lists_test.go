@@ -0,0 +1,36 @@
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckAccess(t *testing.T) {
+	accessListsMock := db.NewAccessListsStoreMock()
+	accessListsMock.On("Allowed", "allowed", db.AccessBets).Return(true, nil)
+	accessListsMock.On("Allowed", "denied", db.AccessWithdrawals).Return(false, nil)
+	accessListsMock.On("Allowed", "error", db.AccessBets).Return(false, errors.New("test"))
+
+	auditorMock := audit.NewAuditorMock()
+	auditorMock.On("Record", audit.AccessDenied, map[string]any{
+		"public_key": "denied",
+		"action":     db.AccessWithdrawals,
+	}).Once()
+
+	assert.NoError(t, policy.CheckAccess(accessListsMock, auditorMock, "allowed", db.AccessBets))
+
+	err := policy.CheckAccess(accessListsMock, auditorMock, "denied", db.AccessWithdrawals)
+	assert.ErrorIs(t, err, policy.ErrAccessDenied)
+
+	err = policy.CheckAccess(accessListsMock, auditorMock, "error", db.AccessBets)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, policy.ErrAccessDenied)
+
+	auditorMock.AssertExpectations(t)
+}
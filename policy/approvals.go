@@ -0,0 +1,151 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/jobs"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// requiredApprovals is the number of different operators that must approve a payout.
+	requiredApprovals = 2
+	// defaultApprovalsExpiry is the time operators have to approve a payout if not configured.
+	defaultApprovalsExpiry = 24 * time.Hour
+	// jobExpireApproval is the kind of the job that expires a pending approval. It is stored in the
+	// database, do not rename it.
+	jobExpireApproval = "expire_approval"
+)
+
+// PayoutRequest is a payout that may need the operators approval.
+type PayoutRequest struct {
+	PublicKey      string
+	PaymentRequest string
+	PaymentHash    string
+	Claims         []db.PrizesRow
+	Amount         uint64
+	Fee            uint64
+	// InvoiceExpiry is when the invoice expires, the approval can't outlive it
+	InvoiceExpiry time.Time
+}
+
+// Approvals holds large payouts until two different operators approve them. The prizes stay
+// deducted in the meantime and are returned if the approval expires or it's rejected.
+type Approvals struct {
+	db        *db.DB
+	queue     jobs.Queue
+	now       func() time.Time
+	threshold uint64
+	expiry    time.Duration
+	mu        sync.RWMutex
+}
+
+// NewApprovals returns a new payout approvals policy. It registers the job that expires the
+// approvals in the queue, so it must be created before starting it.
+func NewApprovals(config config.Approvals, db *db.DB, queue jobs.Queue) *Approvals {
+	approvals := &Approvals{
+		db:    db,
+		queue: queue,
+		now:   time.Now,
+	}
+	approvals.Reload(config)
+	queue.Register(jobExpireApproval, approvals.expire)
+
+	return approvals
+}
+
+// Reload updates the threshold and expiry, the approvals pending keep their expiration.
+func (a *Approvals) Reload(config config.Approvals) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.threshold = config.Threshold
+	a.expiry = config.Expiry
+	if a.expiry == 0 {
+		a.expiry = defaultApprovalsExpiry
+	}
+}
+
+// Required returns whether a payout of amount sats must be approved.
+func (a *Approvals) Required(amount uint64) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.threshold != 0 && amount > a.threshold
+}
+
+// Request stores a payout pending approval and schedules its expiration.
+func (a *Approvals) Request(req PayoutRequest) (db.Approval, error) {
+	a.mu.RLock()
+	expiry := a.expiry
+	a.mu.RUnlock()
+
+	now := a.now()
+	expiresAt := now.Add(expiry)
+	if !req.InvoiceExpiry.IsZero() && req.InvoiceExpiry.Before(expiresAt) {
+		expiresAt = req.InvoiceExpiry
+	}
+
+	approval := db.Approval{
+		PublicKey:      req.PublicKey,
+		PaymentRequest: req.PaymentRequest,
+		PaymentHash:    req.PaymentHash,
+		Claims:         req.Claims,
+		Amount:         req.Amount,
+		Fee:            req.Fee,
+		CreatedAt:      now.Unix(),
+		ExpiresAt:      expiresAt.Unix(),
+	}
+
+	id, err := a.db.Approvals.Add(approval)
+	if err != nil {
+		return db.Approval{}, err
+	}
+	approval.ID = id
+
+	if err := a.queue.Schedule(jobExpireApproval, id, expiresAt); err != nil {
+		return db.Approval{}, err
+	}
+
+	return approval, nil
+}
+
+// Approve records the operator's approval. It returns true once the payout has the approvals
+// required, in which case the caller must pay it.
+func (a *Approvals) Approve(id, operatorID uint64) (db.Approval, bool, error) {
+	return a.db.Approvals.Approve(id, operatorID, a.now().Unix(), requiredApprovals)
+}
+
+// Reject removes a pending payout and returns its prizes.
+func (a *Approvals) Reject(id uint64) (db.Approval, error) {
+	approval, err := a.db.Approvals.Delete(id)
+	if err != nil {
+		return db.Approval{}, err
+	}
+
+	if err := a.db.Prizes.Restore(approval.Claims); err != nil {
+		return db.Approval{}, errors.Wrap(err, "restoring prizes")
+	}
+
+	return approval, nil
+}
+
+// expire rejects the approval in the payload, unless it was already released or rejected.
+func (a *Approvals) expire(_ context.Context, payload []byte) error {
+	var id uint64
+	if err := json.Unmarshal(payload, &id); err != nil {
+		return errors.Wrap(err, "decoding job payload")
+	}
+
+	if _, err := a.Reject(id); err != nil && !errors.Is(err, db.ErrApprovalNotFound) {
+		return err
+	}
+
+	return nil
+}
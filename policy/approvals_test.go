@@ -0,0 +1,118 @@
+package policy_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/jobs"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupApprovals(t *testing.T) (*policy.Approvals, *db.PrizesStoreMock, *jobs.QueueMock, *jobs.Handler) {
+	t.Helper()
+
+	_, database := setupLimits(t)
+	prizesMock := db.NewPrizesStoreMock()
+	database.Prizes = prizesMock
+
+	var expire jobs.Handler
+	queueMock := jobs.NewQueueMock()
+	queueMock.On("Register", "expire_approval", mock.Anything).Run(func(args mock.Arguments) {
+		expire = args.Get(1).(jobs.Handler)
+	})
+
+	approvals := policy.NewApprovals(config.Approvals{Threshold: 100_000, Expiry: time.Hour}, database, queueMock)
+	return approvals, prizesMock, queueMock, &expire
+}
+
+func TestApprovalsRequired(t *testing.T) {
+	approvals, _, _, _ := setupApprovals(t)
+
+	assert.False(t, approvals.Required(100_000))
+	assert.True(t, approvals.Required(100_001))
+
+	approvals.Reload(config.Approvals{})
+	assert.False(t, approvals.Required(100_001))
+}
+
+func TestApprovalsApprove(t *testing.T) {
+	approvals, _, queueMock, _ := setupApprovals(t)
+	queueMock.On("Schedule", "expire_approval", uint64(1), mock.Anything).Return(nil)
+
+	claims := []db.PrizesRow{{RowID: 1, Amount: 200_000}}
+	invoiceExpiry := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+	approval, err := approvals.Request(policy.PayoutRequest{
+		PublicKey:      publicKey,
+		PaymentRequest: "lnbc1",
+		PaymentHash:    "hash",
+		Claims:         claims,
+		Amount:         199_990,
+		Fee:            10,
+		InvoiceExpiry:  invoiceExpiry,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), approval.ID)
+	// The invoice expires before the configured expiry
+	assert.Equal(t, invoiceExpiry.Unix(), approval.ExpiresAt)
+	queueMock.AssertCalled(t, "Schedule", "expire_approval", uint64(1), invoiceExpiry)
+
+	_, released, err := approvals.Approve(approval.ID, 1)
+	assert.NoError(t, err)
+	assert.False(t, released)
+
+	_, _, err = approvals.Approve(approval.ID, 1)
+	assert.ErrorIs(t, err, db.ErrAlreadyApproved)
+
+	approved, released, err := approvals.Approve(approval.ID, 2)
+	assert.NoError(t, err)
+	assert.True(t, released)
+	assert.Equal(t, claims, approved.Claims)
+	assert.Equal(t, []uint64{1, 2}, approved.Approvers)
+}
+
+func TestApprovalsExpire(t *testing.T) {
+	approvals, prizesMock, queueMock, expire := setupApprovals(t)
+	queueMock.On("Schedule", "expire_approval", uint64(1), mock.Anything).Return(nil)
+
+	claims := []db.PrizesRow{{RowID: 1, Amount: 200_000}}
+	prizesMock.On("Restore", claims).Return(nil).Once()
+
+	approval, err := approvals.Request(policy.PayoutRequest{PublicKey: publicKey, Claims: claims})
+	assert.NoError(t, err)
+
+	err = (*expire)(context.Background(), []byte("1"))
+	assert.NoError(t, err)
+
+	_, _, err = approvals.Approve(approval.ID, 1)
+	assert.ErrorIs(t, err, db.ErrApprovalNotFound)
+
+	// Approvals released or rejected before expiring are ignored
+	err = (*expire)(context.Background(), []byte("1"))
+	assert.NoError(t, err)
+	prizesMock.AssertExpectations(t)
+}
+
+func TestApprovalsReject(t *testing.T) {
+	approvals, prizesMock, queueMock, _ := setupApprovals(t)
+	queueMock.On("Schedule", "expire_approval", uint64(1), mock.Anything).Return(nil)
+
+	claims := []db.PrizesRow{{RowID: 3, Amount: 150_000}}
+	prizesMock.On("Restore", claims).Return(nil)
+
+	approval, err := approvals.Request(policy.PayoutRequest{PublicKey: publicKey, Claims: claims})
+	assert.NoError(t, err)
+
+	rejected, err := approvals.Reject(approval.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, publicKey, rejected.PublicKey)
+	prizesMock.AssertExpectations(t)
+
+	_, err = approvals.Reject(approval.ID)
+	assert.ErrorIs(t, err, db.ErrApprovalNotFound)
+}
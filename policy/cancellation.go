@@ -0,0 +1,64 @@
+package policy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCancellationDisabled is returned when a player tries to cancel a bet but the cancellation
+// window is not configured.
+var ErrCancellationDisabled = errors.New("bet cancellations are disabled")
+
+// Cancellation lets players cancel their bets for a while after placing them, refunding the sats
+// paid minus a fee.
+type Cancellation struct {
+	db      *db.DB
+	auditor audit.Auditor
+	now     func() time.Time
+	window  time.Duration
+	fee     float64
+	mu      sync.RWMutex
+}
+
+// NewCancellation returns a new bet cancellation policy. The auditor signs again the receipts of
+// the bets whose tickets are moved by a cancellation.
+func NewCancellation(config config.Cancellation, db *db.DB, auditor audit.Auditor) *Cancellation {
+	cancellation := &Cancellation{
+		db:      db,
+		auditor: auditor,
+		now:     time.Now,
+	}
+	cancellation.Reload(config)
+
+	return cancellation
+}
+
+// Reload updates the cancellation window and fee, bets placed before are affected as well.
+func (c *Cancellation) Reload(config config.Cancellation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.window = config.Window
+	c.fee = config.Fee
+}
+
+// Cancel removes the bet paid with the payment hash and returns it along with the sats refunded
+// as a prize.
+func (c *Cancellation) Cancel(publicKey, paymentHash string) (db.Bet, uint64, error) {
+	c.mu.RLock()
+	window, fee := c.window, c.fee
+	c.mu.RUnlock()
+
+	if window == 0 {
+		return db.Bet{}, 0, ErrCancellationDisabled
+	}
+
+	placedAfter := c.now().Add(-window).Unix()
+	return c.db.Bets.Cancel(publicKey, paymentHash, placedAfter, fee, c.auditor.SignReceipt)
+}
@@ -0,0 +1,67 @@
+package policy_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCancellationCancel(t *testing.T) {
+	_, database := setupLimits(t)
+	auditorMock := audit.NewAuditorMock()
+	cancellation := policy.NewCancellation(config.Cancellation{Window: 10 * time.Minute, Fee: 2},
+		database, auditorMock)
+
+	bet, err := database.Bets.Add(db.Bet{
+		PublicKey:   publicKey,
+		Tickets:     1000,
+		PaymentHash: "recent",
+		CreatedAt:   time.Now().Unix(),
+	}, 0, 0)
+	assert.NoError(t, err)
+
+	_, err = database.Bets.Add(db.Bet{
+		PublicKey:   publicKey,
+		Tickets:     1000,
+		PaymentHash: "old",
+		CreatedAt:   time.Now().Add(-time.Hour).Unix(),
+	}, 0, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, database.Receipts.Add(db.Receipt{PublicKey: publicKey, PaymentHash: "old"}))
+
+	// The receipt of the bet moved down is signed again
+	auditorMock.On("SignReceipt", mock.MatchedBy(func(bet db.Bet) bool {
+		return bet.FirstTicket == 1 && bet.Index == 1000
+	}), "old").Return(db.Receipt{PublicKey: publicKey, PaymentHash: "old", Signature: "signature"}, nil)
+
+	cancelled, refund, err := cancellation.Cancel(publicKey, bet.PaymentHash)
+	assert.NoError(t, err)
+	assert.Equal(t, bet.Index, cancelled.Index)
+	assert.Equal(t, uint64(980), refund)
+	auditorMock.AssertExpectations(t)
+
+	receipt, err := database.Receipts.Get(publicKey, "old")
+	assert.NoError(t, err)
+	assert.Equal(t, "signature", receipt.Signature)
+
+	_, _, err = cancellation.Cancel(publicKey, "old")
+	assert.ErrorIs(t, err, db.ErrCancellationExpired)
+}
+
+func TestCancellationDisabled(t *testing.T) {
+	_, database := setupLimits(t)
+	cancellation := policy.NewCancellation(config.Cancellation{Window: 10 * time.Minute}, database,
+		audit.NewAuditorMock())
+
+	cancellation.Reload(config.Cancellation{})
+
+	_, _, err := cancellation.Cancel(publicKey, "hash")
+	assert.ErrorIs(t, err, policy.ErrCancellationDisabled)
+}
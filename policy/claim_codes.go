@@ -0,0 +1,105 @@
+package policy
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// claimCodeSize is the number of random bytes of a claim code.
+const claimCodeSize = 32
+
+var (
+	// ErrAnonymousBetsDisabled is returned when a player tries to bet anonymously but the claim
+	// codes expiry is not configured.
+	ErrAnonymousBetsDisabled = errors.New("anonymous bets are disabled")
+	// ErrInvalidClaimCode is returned when a claim code was never issued or it expired.
+	ErrInvalidClaimCode = errors.New("invalid or expired claim code")
+)
+
+// ClaimCodes lets players bet without a persistent public key. Each anonymous bet is registered
+// under a new public key with no private key, and the player receives a one-time claim code to
+// withdraw the prizes won until the code expires.
+type ClaimCodes struct {
+	db     *db.DB
+	now    func() time.Time
+	expiry time.Duration
+}
+
+// NewClaimCodes returns a new claim codes policy.
+func NewClaimCodes(config config.ClaimCodes, db *db.DB) *ClaimCodes {
+	return &ClaimCodes{
+		db:     db,
+		now:    time.Now,
+		expiry: config.Expiry,
+	}
+}
+
+// Enabled returns whether anonymous bets are accepted.
+func (c *ClaimCodes) Enabled() bool {
+	return c.expiry > 0
+}
+
+// Issue returns the public key an anonymous bet is registered under and the claim code that
+// redeems its prizes. Only the hash of the code is stored, it can't be recovered if lost.
+func (c *ClaimCodes) Issue() (publicKey, code string, err error) {
+	if !c.Enabled() {
+		return "", "", ErrAnonymousBetsDisabled
+	}
+
+	publicKeyBytes := make([]byte, 32)
+	if _, err := rand.Read(publicKeyBytes); err != nil {
+		return "", "", errors.Wrap(err, "generating public key")
+	}
+
+	codeBytes := make([]byte, claimCodeSize)
+	if _, err := rand.Read(codeBytes); err != nil {
+		return "", "", errors.Wrap(err, "generating claim code")
+	}
+
+	publicKey = hex.EncodeToString(publicKeyBytes)
+	code = hex.EncodeToString(codeBytes)
+	expiresAt := c.now().Add(c.expiry).Unix()
+	if err := c.db.ClaimCodes.Add(hashClaimCode(code), publicKey, expiresAt); err != nil {
+		return "", "", err
+	}
+
+	return publicKey, code, nil
+}
+
+// Redeem returns the public key whose prizes the claim code gives access to.
+func (c *ClaimCodes) Redeem(code string) (string, error) {
+	if !c.Enabled() {
+		return "", ErrAnonymousBetsDisabled
+	}
+
+	claimCode, err := c.db.ClaimCodes.Get(hashClaimCode(code))
+	if err != nil {
+		if errors.Is(err, db.ErrClaimCodeNotFound) {
+			return "", ErrInvalidClaimCode
+		}
+		return "", err
+	}
+
+	if c.now().Unix() >= claimCode.ExpiresAt {
+		return "", ErrInvalidClaimCode
+	}
+
+	return claimCode.PublicKey, nil
+}
+
+// Prune removes the claim codes that expired.
+func (c *ClaimCodes) Prune() (uint64, error) {
+	return c.db.ClaimCodes.DeleteExpired(c.now().Unix())
+}
+
+func hashClaimCode(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,63 @@
+package policy_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/crypto"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimCodes(t *testing.T) {
+	_, database := setupLimits(t)
+	claimCodes := policy.NewClaimCodes(config.ClaimCodes{Expiry: time.Hour}, database)
+
+	publicKey, code, err := claimCodes.Issue()
+	assert.NoError(t, err)
+	assert.NoError(t, crypto.ValidatePublicKey(publicKey))
+	assert.Len(t, code, 64)
+
+	redeemed, err := claimCodes.Redeem(code)
+	assert.NoError(t, err)
+	assert.Equal(t, publicKey, redeemed)
+
+	_, err = claimCodes.Redeem(publicKey)
+	assert.ErrorIs(t, err, policy.ErrInvalidClaimCode)
+
+	otherPublicKey, otherCode, err := claimCodes.Issue()
+	assert.NoError(t, err)
+	assert.NotEqual(t, publicKey, otherPublicKey)
+	assert.NotEqual(t, code, otherCode)
+}
+
+func TestClaimCodesExpired(t *testing.T) {
+	_, database := setupLimits(t)
+	claimCodes := policy.NewClaimCodes(config.ClaimCodes{Expiry: time.Nanosecond}, database)
+
+	_, code, err := claimCodes.Issue()
+	assert.NoError(t, err)
+
+	_, err = claimCodes.Redeem(code)
+	assert.ErrorIs(t, err, policy.ErrInvalidClaimCode)
+
+	// Expired codes are removed once the second they expired at passed
+	time.Sleep(time.Second)
+	pruned, err := claimCodes.Prune()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), pruned)
+}
+
+func TestClaimCodesDisabled(t *testing.T) {
+	_, database := setupLimits(t)
+	claimCodes := policy.NewClaimCodes(config.ClaimCodes{}, database)
+	assert.False(t, claimCodes.Enabled())
+
+	_, _, err := claimCodes.Issue()
+	assert.ErrorIs(t, err, policy.ErrAnonymousBetsDisabled)
+
+	_, err = claimCodes.Redeem("code")
+	assert.ErrorIs(t, err, policy.ErrAnonymousBetsDisabled)
+}
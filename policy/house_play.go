@@ -0,0 +1,67 @@
+package policy
+
+import (
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/pkg/errors"
+)
+
+// ErrHousePlay is returned when a house public key tries to bet and the house is excluded from the
+// draws.
+var ErrHousePlay = errors.New("house public keys are not allowed to bet")
+
+// House play modes published to the players.
+const (
+	// HousePlayUndeclared is used when the operators didn't designate their public keys
+	HousePlayUndeclared = "undeclared"
+	// HousePlayExcluded is used when the house public keys can't bet
+	HousePlayExcluded = "excluded"
+	// HousePlayFlagged is used when the house public keys can bet and their bets are flagged
+	HousePlayFlagged = "flagged"
+)
+
+// HousePlay keeps the operators public keys from betting, or flags their bets if they are allowed
+// to. Anonymous bets are not tied to any public key and can't be told apart.
+type HousePlay struct {
+	publicKeys map[string]struct{}
+	allowed    bool
+}
+
+// NewHousePlay returns a new house play policy.
+func NewHousePlay(config config.HousePlay) *HousePlay {
+	publicKeys := make(map[string]struct{}, len(config.PublicKeys))
+	for _, publicKey := range config.PublicKeys {
+		publicKeys[publicKey] = struct{}{}
+	}
+
+	return &HousePlay{
+		publicKeys: publicKeys,
+		allowed:    config.Allowed,
+	}
+}
+
+// Check returns ErrHousePlay if the public key belongs to the house and it can't bet.
+func (h *HousePlay) Check(publicKey string) error {
+	if h.IsHouse(publicKey) && !h.allowed {
+		return ErrHousePlay
+	}
+	return nil
+}
+
+// IsHouse returns whether the public key belongs to the house.
+func (h *HousePlay) IsHouse(publicKey string) bool {
+	_, ok := h.publicKeys[publicKey]
+	return ok
+}
+
+// Mode returns how the house takes part in the draws.
+func (h *HousePlay) Mode() string {
+	switch {
+	case len(h.publicKeys) == 0:
+		return HousePlayUndeclared
+	case h.allowed:
+		return HousePlayFlagged
+	default:
+		return HousePlayExcluded
+	}
+}
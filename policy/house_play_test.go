@@ -0,0 +1,53 @@
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHousePlay(t *testing.T) {
+	player := "345fe256754b1b472e58aede6c2f138ce67d05d431c776bcb4e384edbbdca9cd"
+
+	cases := []struct {
+		desc     string
+		config   config.HousePlay
+		mode     string
+		checkErr error
+		isHouse  bool
+	}{
+		{
+			desc: "Undeclared",
+			mode: policy.HousePlayUndeclared,
+		},
+		{
+			desc:     "Excluded",
+			config:   config.HousePlay{PublicKeys: []string{publicKey}},
+			mode:     policy.HousePlayExcluded,
+			checkErr: policy.ErrHousePlay,
+			isHouse:  true,
+		},
+		{
+			desc:    "Flagged",
+			config:  config.HousePlay{PublicKeys: []string{publicKey}, Allowed: true},
+			mode:    policy.HousePlayFlagged,
+			isHouse: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			housePlay := policy.NewHousePlay(tc.config)
+
+			assert.Equal(t, tc.mode, housePlay.Mode())
+			assert.ErrorIs(t, housePlay.Check(publicKey), tc.checkErr)
+			assert.Equal(t, tc.isHouse, housePlay.IsHouse(publicKey))
+
+			assert.NoError(t, housePlay.Check(player))
+			assert.False(t, housePlay.IsHouse(player))
+		})
+	}
+}
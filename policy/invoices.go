@@ -0,0 +1,115 @@
+package policy
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/jobs"
+	"github.com/aftermath2/BTRY/lightning"
+
+	"github.com/pkg/errors"
+)
+
+// jobExpireInvoice is the kind of the job that expires an unpaid invoice. It is stored in the
+// database, do not rename it.
+const jobExpireInvoice = "expire_invoice"
+
+// Invoices tracks the bet invoices generated until they are paid. The ones abandoned are cancelled
+// in the lightning node once they expire and their reservations released, so they neither count as
+// demand nor hold any capacity.
+type Invoices struct {
+	db      *db.DB
+	lnd     lightning.InvoiceManager
+	queue   jobs.Queue
+	peerCap *PeerCap
+	now     func() time.Time
+}
+
+// NewInvoices returns a new invoices policy. It registers the job that expires the invoices in
+// the queue, so it must be created before starting it.
+func NewInvoices(db *db.DB, lnd lightning.InvoiceManager, queue jobs.Queue, peerCap *PeerCap) *Invoices {
+	invoices := &Invoices{
+		db:      db,
+		lnd:     lnd,
+		queue:   queue,
+		peerCap: peerCap,
+		now:     time.Now,
+	}
+	queue.Register(jobExpireInvoice, invoices.expire)
+
+	return invoices
+}
+
+// Track stores an invoice generated and schedules its expiration.
+func (i *Invoices) Track(paymentHash, publicKey string, amount uint64) error {
+	return i.track(paymentHash, publicKey, amount, "")
+}
+
+// TrackHold stores a hold invoice along with its preimage, so it can still be settled if the
+// process restarts before its HTLCs are accepted, and schedules its expiration.
+func (i *Invoices) TrackHold(paymentHash, publicKey string, amount uint64, preimage []byte) error {
+	return i.track(paymentHash, publicKey, amount, hex.EncodeToString(preimage))
+}
+
+func (i *Invoices) track(paymentHash, publicKey string, amount uint64, preimage string) error {
+	now := i.now()
+	expiresAt := now.Add(lightning.DefaultInvoiceExpiry)
+
+	invoice := db.Invoice{
+		PaymentHash: paymentHash,
+		PublicKey:   publicKey,
+		Amount:      amount,
+		Preimage:    preimage,
+		CreatedAt:   now.Unix(),
+		ExpiresAt:   expiresAt.Unix(),
+	}
+	if err := i.db.Invoices.Add(invoice); err != nil {
+		return err
+	}
+
+	return i.queue.Schedule(jobExpireInvoice, paymentHash, expiresAt)
+}
+
+// Stats returns the conversion of the invoices generated since the time specified.
+func (i *Invoices) Stats(since time.Time) (db.InvoiceStats, error) {
+	return i.db.ReadReplica().Invoices.Stats(since.Unix())
+}
+
+// expire cancels the invoice in the payload unless it was paid, and releases its reservations.
+func (i *Invoices) expire(ctx context.Context, payload []byte) error {
+	var paymentHash string
+	if err := json.Unmarshal(payload, &paymentHash); err != nil {
+		return errors.Wrap(err, "decoding job payload")
+	}
+
+	invoice, err := i.db.Invoices.Get(paymentHash)
+	if err != nil {
+		if errors.Is(err, db.ErrInvoiceNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if invoice.Status != db.InvoicePending {
+		return nil
+	}
+
+	hash, err := hex.DecodeString(paymentHash)
+	if err != nil {
+		return errors.Wrap(err, "decoding payment hash")
+	}
+
+	// Paid invoices can't be cancelled, the job is retried until the payment is registered
+	if err := i.lnd.CancelInvoice(ctx, hash); err != nil {
+		return errors.Wrapf(err, "cancelling invoice %s", paymentHash)
+	}
+
+	if _, err := i.db.Invoices.Expire(paymentHash, i.now().Unix()); err != nil {
+		return err
+	}
+
+	return i.peerCap.Release(paymentHash)
+}
@@ -0,0 +1,127 @@
+package policy_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/jobs"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const invoiceHash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+func setupInvoices(t *testing.T) (*policy.Invoices, *db.DB, *lightning.ClientMock, *jobs.Handler) {
+	t.Helper()
+
+	_, database := setupLimits(t)
+	lndMock := lightning.NewClientMock()
+
+	var expire jobs.Handler
+	queueMock := jobs.NewQueueMock()
+	queueMock.On("Register", "expire_invoice", mock.Anything).Run(func(args mock.Arguments) {
+		expire = args.Get(1).(jobs.Handler)
+	})
+	queueMock.On("Schedule", "expire_invoice", invoiceHash, mock.Anything).Return(nil)
+
+	peerCap := policy.NewPeerCap(config.PeerCap{}, database, lndMock)
+	invoices := policy.NewInvoices(database, lndMock, queueMock, peerCap)
+	return invoices, database, lndMock, &expire
+}
+
+func TestInvoicesTrack(t *testing.T) {
+	invoices, database, _, _ := setupInvoices(t)
+	start := time.Now()
+
+	err := invoices.Track(invoiceHash, publicKey, 1_000)
+	assert.NoError(t, err)
+
+	invoice, err := database.Invoices.Get(invoiceHash)
+	assert.NoError(t, err)
+	assert.Equal(t, db.InvoicePending, invoice.Status)
+	assert.Equal(t, uint64(1_000), invoice.Amount)
+	assert.Equal(t, invoice.CreatedAt+int64(lightning.DefaultInvoiceExpiry.Seconds()), invoice.ExpiresAt)
+
+	stats, err := invoices.Stats(start.Add(-time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, db.InvoiceStats{Created: 1, Pending: 1, CreatedAmount: 1_000, PendingAmount: 1_000}, stats)
+}
+
+func TestInvoicesTrackHold(t *testing.T) {
+	invoices, database, _, _ := setupInvoices(t)
+
+	preimage := []byte("preimage")
+	err := invoices.TrackHold(invoiceHash, publicKey, 1_000, preimage)
+	assert.NoError(t, err)
+
+	held, err := database.Invoices.ListHeld(time.Now().Unix())
+	assert.NoError(t, err)
+	assert.Len(t, held, 1)
+	assert.Equal(t, invoiceHash, held[0].PaymentHash)
+	assert.Equal(t, hex.EncodeToString(preimage), held[0].Preimage)
+}
+
+func TestInvoicesExpire(t *testing.T) {
+	invoices, database, lndMock, expire := setupInvoices(t)
+	hash, err := hex.DecodeString(invoiceHash)
+	assert.NoError(t, err)
+	lndMock.On("CancelInvoice", mock.Anything, hash).Return(nil).Once()
+
+	assert.NoError(t, invoices.Track(invoiceHash, publicKey, 1_000))
+	exposure := map[string]uint64{"peer": 1_000}
+	assert.NoError(t, database.Exposure.Add(height, invoiceHash, exposure))
+
+	err = (*expire)(context.Background(), []byte(`"`+invoiceHash+`"`))
+	assert.NoError(t, err)
+
+	invoice, err := database.Invoices.Get(invoiceHash)
+	assert.NoError(t, err)
+	assert.Equal(t, db.InvoiceExpired, invoice.Status)
+
+	exposed, err := database.Exposure.List(height)
+	assert.NoError(t, err)
+	assert.Empty(t, exposed)
+
+	// Invoices already expired are ignored
+	err = (*expire)(context.Background(), []byte(`"`+invoiceHash+`"`))
+	assert.NoError(t, err)
+	lndMock.AssertExpectations(t)
+}
+
+func TestInvoicesExpirePaid(t *testing.T) {
+	invoices, database, lndMock, expire := setupInvoices(t)
+
+	assert.NoError(t, invoices.Track(invoiceHash, publicKey, 1_000))
+	assert.NoError(t, database.Invoices.Settle(invoiceHash, 1, time.Now().Unix()))
+
+	err := (*expire)(context.Background(), []byte(`"`+invoiceHash+`"`))
+	assert.NoError(t, err)
+	lndMock.AssertNotCalled(t, "CancelInvoice", mock.Anything, mock.Anything)
+
+	// Untracked invoices are ignored
+	err = (*expire)(context.Background(), []byte(`"untracked"`))
+	assert.NoError(t, err)
+}
+
+func TestInvoicesExpireCancelError(t *testing.T) {
+	invoices, database, lndMock, expire := setupInvoices(t)
+	lndMock.On("CancelInvoice", mock.Anything, mock.Anything).Return(errors.New("invoice already settled"))
+
+	assert.NoError(t, invoices.Track(invoiceHash, publicKey, 1_000))
+
+	err := (*expire)(context.Background(), []byte(`"`+invoiceHash+`"`))
+	assert.Error(t, err)
+
+	// The invoice stays pending so the job is retried
+	invoice, err := database.Invoices.Get(invoiceHash)
+	assert.NoError(t, err)
+	assert.Equal(t, db.InvoicePending, invoice.Status)
+}
@@ -0,0 +1,213 @@
+package policy
+
+import (
+	"encoding/csv"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/pkg/errors"
+)
+
+// ErrJurisdictionBlocked is returned when the client address is not allowed to place bets.
+var ErrJurisdictionBlocked = errors.New("bets are not available in your jurisdiction")
+
+// Labels of the blocked attempts that are not counted by country
+const (
+	// listedNetwork is used for the addresses in the networks configured explicitly
+	listedNetwork = "network"
+	// unknownCountry is used for the addresses whose country couldn't be determined
+	unknownCountry = "unknown"
+)
+
+// JurisdictionStats contains the number of blocked attempts since the server started, by country
+// code or "network" and "unknown" if it's not known.
+type JurisdictionStats struct {
+	ByCountry map[string]uint64 `json:"by_country"`
+	Blocked   uint64            `json:"blocked"`
+}
+
+// Jurisdiction restricts the clients that can place bets by their IP address, for operators with
+// legal constraints.
+type Jurisdiction struct {
+	// networks are the ones configured explicitly, they may overlap
+	networks []netip.Prefix
+	// countries are the networks of the countries configured, sorted and not overlapping
+	countries []countryNetwork
+	blocked   map[string]uint64
+	allow     bool
+	enabled   bool
+	mu        sync.Mutex
+}
+
+type countryNetwork struct {
+	prefix  netip.Prefix
+	country string
+}
+
+// NewJurisdiction returns a new jurisdiction policy, loading the networks of the countries
+// configured.
+func NewJurisdiction(config config.Jurisdiction) (*Jurisdiction, error) {
+	jurisdiction := &Jurisdiction{
+		blocked: make(map[string]uint64),
+		allow:   config.Mode == "allow",
+		enabled: config.Mode != "",
+	}
+	if !jurisdiction.enabled {
+		return jurisdiction, nil
+	}
+
+	for _, cidr := range config.CIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing jurisdiction cidr")
+		}
+		jurisdiction.networks = append(jurisdiction.networks, prefix.Masked())
+	}
+
+	if len(config.Countries) > 0 {
+		countries, err := loadCountries(config.CountriesFile, config.Countries)
+		if err != nil {
+			return nil, err
+		}
+		jurisdiction.countries = countries
+	}
+
+	return jurisdiction, nil
+}
+
+// Enabled returns whether the gating is enabled.
+func (j *Jurisdiction) Enabled() bool {
+	return j.enabled
+}
+
+// Check returns ErrJurisdictionBlocked if the address is not allowed to place bets and counts the
+// attempt. Invalid addresses are only allowed in block mode.
+func (j *Jurisdiction) Check(address string) error {
+	if !j.enabled {
+		return nil
+	}
+
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		if j.allow {
+			j.count(unknownCountry)
+			return ErrJurisdictionBlocked
+		}
+		return nil
+	}
+	addr = addr.Unmap()
+
+	country, listed := j.lookup(addr)
+	if listed == j.allow {
+		return nil
+	}
+
+	if country == "" {
+		country = unknownCountry
+	}
+	j.count(country)
+	return ErrJurisdictionBlocked
+}
+
+// Stats returns the number of attempts blocked, in total and by country.
+func (j *Jurisdiction) Stats() JurisdictionStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	stats := JurisdictionStats{
+		ByCountry: make(map[string]uint64, len(j.blocked)),
+	}
+	for country, blocked := range j.blocked {
+		stats.ByCountry[country] = blocked
+		stats.Blocked += blocked
+	}
+
+	return stats
+}
+
+func (j *Jurisdiction) count(country string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.blocked[country]++
+}
+
+// lookup returns the country of the address and whether it's listed, either explicitly or by its
+// country.
+func (j *Jurisdiction) lookup(addr netip.Addr) (string, bool) {
+	i, _ := slices.BinarySearchFunc(j.countries, addr, func(network countryNetwork, addr netip.Addr) int {
+		return network.prefix.Addr().Compare(addr)
+	})
+	// The network containing the address is the last one starting before it
+	for _, k := range []int{i, i - 1} {
+		if k >= 0 && k < len(j.countries) && j.countries[k].prefix.Contains(addr) {
+			return j.countries[k].country, true
+		}
+	}
+
+	for _, network := range j.networks {
+		if network.Contains(addr) {
+			return listedNetwork, true
+		}
+	}
+
+	return "", false
+}
+
+// loadCountries reads the networks of the countries specified from a CSV file. Lines that don't
+// start with a valid network, like headers, are skipped.
+func loadCountries(path string, countries []string) ([]countryNetwork, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening countries file")
+	}
+	defer file.Close()
+
+	codes := make(map[string]struct{}, len(countries))
+	for _, country := range countries {
+		codes[strings.ToUpper(country)] = struct{}{}
+	}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	var networks []countryNetwork
+	for {
+		record, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, errors.Wrap(err, "reading countries file")
+		}
+
+		if len(record) < 2 {
+			continue
+		}
+
+		country := strings.ToUpper(strings.TrimSpace(record[1]))
+		if _, ok := codes[country]; !ok {
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(record[0]))
+		if err != nil {
+			continue
+		}
+
+		networks = append(networks, countryNetwork{prefix: prefix.Masked(), country: country})
+	}
+
+	slices.SortFunc(networks, func(a, b countryNetwork) int {
+		return a.prefix.Addr().Compare(b.prefix.Addr())
+	})
+
+	return networks, nil
+}
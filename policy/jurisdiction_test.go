@@ -0,0 +1,110 @@
+package policy_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const countriesCSV = `network,country_iso_code
+2.16.0.0/13,FR
+3.0.0.0/15,US
+5.0.0.0/16,ES
+2001:db8::/32,US
+`
+
+func TestJurisdictionBlock(t *testing.T) {
+	jurisdiction, err := policy.NewJurisdiction(config.Jurisdiction{
+		Mode:          "block",
+		CountriesFile: writeCountries(t),
+		Countries:     []string{"us", "FR"},
+		CIDRs:         []string{"10.0.0.0/8"},
+	})
+	assert.NoError(t, err)
+
+	cases := []struct {
+		address string
+		blocked bool
+	}{
+		{address: "3.0.1.1", blocked: true},
+		{address: "2.16.0.0", blocked: true},
+		{address: "2.23.255.255", blocked: true},
+		{address: "::ffff:3.0.1.1", blocked: true},
+		{address: "2001:db8::1", blocked: true},
+		{address: "10.1.2.3", blocked: true},
+		{address: "5.0.0.1", blocked: false},
+		{address: "2.24.0.0", blocked: false},
+		{address: "1.1.1.1", blocked: false},
+		{address: "invalid", blocked: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.address, func(t *testing.T) {
+			err := jurisdiction.Check(tc.address)
+			if tc.blocked {
+				assert.ErrorIs(t, err, policy.ErrJurisdictionBlocked)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	expected := policy.JurisdictionStats{
+		ByCountry: map[string]uint64{"US": 3, "FR": 2, "network": 1},
+		Blocked:   6,
+	}
+	assert.Equal(t, expected, jurisdiction.Stats())
+}
+
+func TestJurisdictionAllow(t *testing.T) {
+	jurisdiction, err := policy.NewJurisdiction(config.Jurisdiction{
+		Mode:          "allow",
+		CountriesFile: writeCountries(t),
+		Countries:     []string{"ES"},
+		CIDRs:         []string{"127.0.0.1/32"},
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, jurisdiction.Check("5.0.10.10"))
+	assert.NoError(t, jurisdiction.Check("127.0.0.1"))
+	assert.ErrorIs(t, jurisdiction.Check("3.0.1.1"), policy.ErrJurisdictionBlocked)
+	assert.ErrorIs(t, jurisdiction.Check("invalid"), policy.ErrJurisdictionBlocked)
+
+	expected := policy.JurisdictionStats{
+		ByCountry: map[string]uint64{"unknown": 2},
+		Blocked:   2,
+	}
+	assert.Equal(t, expected, jurisdiction.Stats())
+}
+
+func TestJurisdictionDisabled(t *testing.T) {
+	jurisdiction, err := policy.NewJurisdiction(config.Jurisdiction{})
+	assert.NoError(t, err)
+
+	assert.False(t, jurisdiction.Enabled())
+	assert.NoError(t, jurisdiction.Check("invalid"))
+}
+
+func TestJurisdictionMissingFile(t *testing.T) {
+	_, err := policy.NewJurisdiction(config.Jurisdiction{
+		Mode:          "block",
+		CountriesFile: filepath.Join(t.TempDir(), "missing.csv"),
+		Countries:     []string{"US"},
+	})
+	assert.Error(t, err)
+}
+
+func writeCountries(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "countries.csv")
+	err := os.WriteFile(path, []byte(countriesCSV), 0o600)
+	assert.NoError(t, err)
+
+	return path
+}
@@ -0,0 +1,168 @@
+package policy
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultCooldown is the time it takes for a looser limit to be effective if not configured
+	defaultCooldown = 7 * 24 * time.Hour
+
+	// limitsWindow is the number of blocks the weekly limits take into account
+	limitsWindow = 7 * config.BlocksPerDay
+)
+
+var (
+	// ErrSelfExcluded is returned when a public key that excluded itself tries to bet.
+	ErrSelfExcluded = errors.New("public key is self-excluded")
+	// ErrLimitExceeded is returned when a bet would take a player above one of its weekly limits.
+	ErrLimitExceeded = errors.New("weekly limit exceeded")
+)
+
+// Limits enforces the deposit and loss limits and the self-exclusions players set themselves.
+//
+// Tighter limits take effect immediately while looser ones, including removing a limit, only do
+// after a cooldown so they can't be changed in the heat of the moment.
+type Limits struct {
+	db       *db.DB
+	now      func() time.Time
+	cooldown atomic.Int64
+}
+
+// NewLimits returns a new responsible gambling limits policy.
+func NewLimits(config config.Limits, db *db.DB) *Limits {
+	limits := &Limits{
+		db:  db,
+		now: time.Now,
+	}
+	limits.Reload(config)
+
+	return limits
+}
+
+// Reload updates the cooldown of the limits loosened from now on.
+func (l *Limits) Reload(config config.Limits) {
+	cooldown := config.Cooldown
+	if cooldown == 0 {
+		cooldown = defaultCooldown
+	}
+
+	l.cooldown.Store(int64(cooldown))
+}
+
+// Check returns an error if the public key can't bet the amount specified.
+func (l *Limits) Check(publicKey string, amount uint64) error {
+	now := l.now()
+
+	until, err := l.db.Limits.GetExclusion(publicKey)
+	if err != nil {
+		return err
+	}
+	if until > now.Unix() {
+		return errors.Wrapf(ErrSelfExcluded, "bets are not allowed until %s",
+			time.Unix(until, 0).UTC().Format(time.RFC3339))
+	}
+
+	limits, err := l.effective(publicKey, now)
+	if err != nil {
+		return err
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+
+	nextHeight, err := l.db.Lotteries.GetNextHeight()
+	if err != nil {
+		return err
+	}
+
+	activity, err := l.db.Limits.GetActivity(publicKey, nextHeight-min(nextHeight, limitsWindow))
+	if err != nil {
+		return err
+	}
+
+	deposits := activity.Deposits + amount
+	if limit, ok := limits[db.DepositLimit]; ok && deposits > limit {
+		return errors.Wrapf(ErrLimitExceeded, "deposit limit is %d sats, %d sats were already bet",
+			limit, activity.Deposits)
+	}
+
+	if limit, ok := limits[db.LossLimit]; ok && deposits > activity.Winnings+limit {
+		return errors.Wrapf(ErrLimitExceeded, "loss limit is %d sats, %d sats could be lost",
+			limit, deposits-min(deposits, activity.Winnings))
+	}
+
+	return nil
+}
+
+// Exclude prevents the public key from betting for the duration specified. An exclusion can be
+// extended but never shortened.
+func (l *Limits) Exclude(publicKey string, duration time.Duration) (time.Time, error) {
+	until := l.now().Add(duration)
+	if err := l.db.Limits.Exclude(publicKey, until.Unix()); err != nil {
+		return time.Time{}, err
+	}
+
+	excludedUntil, err := l.db.Limits.GetExclusion(publicKey)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(excludedUntil, 0), nil
+}
+
+// List returns the limits in effect followed by the pending ones.
+func (l *Limits) List(publicKey string) ([]db.Limit, error) {
+	return l.db.Limits.List(publicKey, l.now().Unix())
+}
+
+// Set changes a limit of the public key and returns it with the time it takes effect. An amount of
+// 0 removes the limit.
+func (l *Limits) Set(publicKey string, kind db.LimitKind, amount uint64) (db.Limit, error) {
+	now := l.now()
+
+	limits, err := l.effective(publicKey, now)
+	if err != nil {
+		return db.Limit{}, err
+	}
+
+	limit := db.Limit{
+		Kind:        kind,
+		Amount:      amount,
+		EffectiveAt: now.Unix(),
+	}
+	if current, ok := limits[kind]; ok && (amount == 0 || amount > current) {
+		limit.EffectiveAt = now.Add(time.Duration(l.cooldown.Load())).Unix()
+	}
+
+	if err := l.db.Limits.Set(publicKey, limit, now.Unix()); err != nil {
+		return db.Limit{}, err
+	}
+
+	return limit, nil
+}
+
+// effective returns the limits of the public key in effect, limits with an amount of 0 are
+// omitted.
+func (l *Limits) effective(publicKey string, now time.Time) (map[db.LimitKind]uint64, error) {
+	limits, err := l.db.Limits.List(publicKey, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	effective := make(map[db.LimitKind]uint64, len(limits))
+	for _, limit := range limits {
+		if limit.EffectiveAt > now.Unix() || limit.Amount == 0 {
+			continue
+		}
+		effective[limit.Kind] = limit.Amount
+	}
+
+	return effective, nil
+}
@@ -0,0 +1,127 @@
+package policy_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const publicKey = "7d959d6d552c7d38b3ecafb72805fa03a6dee6b7f0c5f63f57a371736cb004b1"
+
+func setupLimits(t *testing.T) (*policy.Limits, *db.DB) {
+	t.Helper()
+
+	file, err := os.CreateTemp("", "*")
+	assert.NoError(t, err)
+
+	database, err := db.Open(config.DB{Path: file.Name()})
+	assert.NoError(t, err)
+
+	t.Cleanup(func() {
+		database.Close()
+		file.Close()
+		os.Remove(file.Name())
+	})
+
+	assert.NoError(t, database.Lotteries.AddHeight(height, "", ""))
+
+	return policy.NewLimits(config.Limits{Cooldown: time.Hour}, database), database
+}
+
+func TestLimitsSet(t *testing.T) {
+	limits, _ := setupLimits(t)
+	start := time.Now().Unix()
+
+	limit, err := limits.Set(publicKey, db.DepositLimit, 10_000)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, limit.EffectiveAt, start)
+	assert.LessOrEqual(t, limit.EffectiveAt, time.Now().Unix())
+
+	// Loosening a limit takes effect after the cooldown
+	looser, err := limits.Set(publicKey, db.DepositLimit, 20_000)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, looser.EffectiveAt, start+int64(time.Hour.Seconds()))
+
+	list, err := limits.List(publicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, []db.Limit{limit, looser}, list)
+
+	// Tightening it takes effect immediately and discards the pending change
+	tighter, err := limits.Set(publicKey, db.DepositLimit, 5_000)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, tighter.EffectiveAt, time.Now().Unix())
+
+	list, err = limits.List(publicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, []db.Limit{tighter}, list)
+
+	removed, err := limits.Set(publicKey, db.DepositLimit, 0)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, removed.EffectiveAt, start+int64(time.Hour.Seconds()))
+}
+
+func TestLimitsReload(t *testing.T) {
+	limits, _ := setupLimits(t)
+	limits.Reload(config.Limits{Cooldown: 24 * time.Hour})
+	start := time.Now().Unix()
+
+	_, err := limits.Set(publicKey, db.LossLimit, 10_000)
+	assert.NoError(t, err)
+
+	looser, err := limits.Set(publicKey, db.LossLimit, 20_000)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, looser.EffectiveAt, start+int64((24*time.Hour).Seconds()))
+}
+
+func TestLimitsCheck(t *testing.T) {
+	limits, database := setupLimits(t)
+
+	_, err := database.Bets.Add(db.Bet{PublicKey: publicKey, Tickets: 3_000}, 0, 0)
+	assert.NoError(t, err)
+
+	assert.NoError(t, limits.Check(publicKey, 1_000_000))
+
+	_, err = limits.Set(publicKey, db.DepositLimit, 5_000)
+	assert.NoError(t, err)
+
+	assert.NoError(t, limits.Check(publicKey, 2_000))
+	assert.ErrorIs(t, limits.Check(publicKey, 2_001), policy.ErrLimitExceeded)
+
+	_, err = limits.Set(publicKey, db.DepositLimit, 0)
+	assert.NoError(t, err)
+	_, err = limits.Set(publicKey, db.LossLimit, 3_000)
+	assert.NoError(t, err)
+
+	// The deposit limit removal is still pending
+	assert.ErrorIs(t, limits.Check(publicKey, 2_001), policy.ErrLimitExceeded)
+
+	winner := db.Winner{PublicKey: publicKey, Prize: 1_000, Ticket: 10}
+	assert.NoError(t, database.Winners.Add(height, []db.Winner{winner}))
+
+	// 3,000 bet and 1,000 won, 1,000 more can be lost
+	assert.NoError(t, limits.Check(publicKey, 1_000))
+	assert.ErrorIs(t, limits.Check(publicKey, 1_001), policy.ErrLimitExceeded)
+}
+
+func TestLimitsExclude(t *testing.T) {
+	limits, _ := setupLimits(t)
+
+	until, err := limits.Exclude(publicKey, 24*time.Hour)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), until, 2*time.Second)
+
+	assert.ErrorIs(t, limits.Check(publicKey, 1), policy.ErrSelfExcluded)
+
+	// Exclusions can't be shortened
+	shorter, err := limits.Exclude(publicKey, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, until, shorter)
+
+	assert.NoError(t, limits.Check("other", 1))
+}
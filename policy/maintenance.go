@@ -0,0 +1,99 @@
+package policy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidMaintenanceWindow is returned when a maintenance window ends before it starts.
+var ErrInvalidMaintenanceWindow = errors.New("maintenance window must end after it starts")
+
+// MaintenanceStatus describes the maintenance window scheduled, if any.
+type MaintenanceStatus struct {
+	// From and Until are unix timestamps, zero if there's no window scheduled
+	From   int64 `json:"from,omitempty"`
+	Until  int64 `json:"until,omitempty"`
+	Active bool  `json:"active"`
+}
+
+// Maintenance rejects new bets and withdrawals during the scheduled windows, while the block
+// watcher and the draws keep running, so the database and the node can be upgraded without missing
+// a lottery.
+//
+// The window is taken from the configuration and can be overridden by the operators until the
+// configuration is reloaded.
+type Maintenance struct {
+	now   func() time.Time
+	from  time.Time
+	until time.Time
+	mu    sync.RWMutex
+}
+
+// NewMaintenance returns a new maintenance policy.
+func NewMaintenance(config config.Maintenance) *Maintenance {
+	maintenance := &Maintenance{
+		now: time.Now,
+	}
+	maintenance.Reload(config)
+
+	return maintenance
+}
+
+// Reload replaces the maintenance window with the one configured.
+func (m *Maintenance) Reload(config config.Maintenance) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.from = config.From
+	m.until = config.Until
+}
+
+// Schedule sets a maintenance window, a zero from starts it immediately.
+func (m *Maintenance) Schedule(from, until time.Time) error {
+	if !until.After(from) || !until.After(m.now()) {
+		return ErrInvalidMaintenanceWindow
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.from = from
+	m.until = until
+	return nil
+}
+
+// End finishes the current maintenance window or cancels the scheduled one.
+func (m *Maintenance) End() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.from = time.Time{}
+	m.until = time.Time{}
+}
+
+// Status returns the maintenance window and whether it's in progress. Windows that already ended
+// are not reported.
+func (m *Maintenance) Status() MaintenanceStatus {
+	m.mu.RLock()
+	from, until := m.from, m.until
+	m.mu.RUnlock()
+
+	now := m.now()
+	if until.IsZero() || !now.Before(until) {
+		return MaintenanceStatus{}
+	}
+
+	status := MaintenanceStatus{
+		Until:  until.Unix(),
+		Active: !now.Before(from),
+	}
+	if !from.IsZero() {
+		status.From = from.Unix()
+	}
+
+	return status
+}
@@ -0,0 +1,80 @@
+package policy_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceStatus(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		desc     string
+		config   config.Maintenance
+		expected policy.MaintenanceStatus
+	}{
+		{
+			desc:     "Disabled",
+			config:   config.Maintenance{},
+			expected: policy.MaintenanceStatus{},
+		},
+		{
+			desc:   "In progress",
+			config: config.Maintenance{Until: now.Add(time.Hour)},
+			expected: policy.MaintenanceStatus{
+				Until:  now.Add(time.Hour).Unix(),
+				Active: true,
+			},
+		},
+		{
+			desc:   "Scheduled",
+			config: config.Maintenance{From: now.Add(time.Hour), Until: now.Add(2 * time.Hour)},
+			expected: policy.MaintenanceStatus{
+				From:   now.Add(time.Hour).Unix(),
+				Until:  now.Add(2 * time.Hour).Unix(),
+				Active: false,
+			},
+		},
+		{
+			desc:     "Finished",
+			config:   config.Maintenance{From: now.Add(-2 * time.Hour), Until: now.Add(-time.Hour)},
+			expected: policy.MaintenanceStatus{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			maintenance := policy.NewMaintenance(tc.config)
+			assert.Equal(t, tc.expected, maintenance.Status())
+		})
+	}
+}
+
+func TestMaintenanceSchedule(t *testing.T) {
+	maintenance := policy.NewMaintenance(config.Maintenance{})
+	now := time.Now()
+
+	err := maintenance.Schedule(now.Add(time.Hour), now)
+	assert.ErrorIs(t, err, policy.ErrInvalidMaintenanceWindow)
+
+	err = maintenance.Schedule(time.Time{}, now.Add(-time.Minute))
+	assert.ErrorIs(t, err, policy.ErrInvalidMaintenanceWindow)
+
+	err = maintenance.Schedule(time.Time{}, now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, maintenance.Status().Active)
+
+	maintenance.End()
+	assert.Equal(t, policy.MaintenanceStatus{}, maintenance.Status())
+
+	err = maintenance.Schedule(time.Time{}, now.Add(time.Hour))
+	assert.NoError(t, err)
+
+	maintenance.Reload(config.Maintenance{})
+	assert.False(t, maintenance.Status().Active)
+}
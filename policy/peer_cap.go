@@ -0,0 +1,126 @@
+// Package policy contains the rules that decide whether a bet payment is accepted.
+package policy
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/pkg/errors"
+)
+
+// ErrPeerCapExceeded is returned when a bet would take the sats received through a channel peer
+// above its cap.
+var ErrPeerCapExceeded = errors.New("channel peer bet cap exceeded")
+
+// PeerCap limits the sats bet in a lottery through each channel peer.
+type PeerCap struct {
+	lnd       lightning.NodeInfo
+	db        *db.DB
+	peers     map[string]uint64
+	maxAmount uint64
+	enabled   bool
+	// mu serializes reservations so concurrent bets can't exceed a cap together
+	mu sync.Mutex
+	// limitsMu protects the limits, which are updated when the configuration is reloaded
+	limitsMu sync.RWMutex
+}
+
+// NewPeerCap returns a new per-peer bet cap policy.
+func NewPeerCap(config config.PeerCap, db *db.DB, lnd lightning.NodeInfo) *PeerCap {
+	return &PeerCap{
+		lnd:       lnd,
+		db:        db,
+		peers:     config.Peers,
+		maxAmount: config.MaxAmount,
+		enabled:   config.Enabled,
+	}
+}
+
+// Reload updates the limits of the peers, the sats already bet through them are kept.
+func (p *PeerCap) Reload(config config.PeerCap) {
+	p.limitsMu.Lock()
+	defer p.limitsMu.Unlock()
+
+	p.peers = config.Peers
+	p.maxAmount = config.MaxAmount
+}
+
+// Enabled returns whether bets must be checked against the policy before being accepted.
+func (p *PeerCap) Enabled() bool {
+	return p.enabled
+}
+
+// Limit returns the maximum amount of sats that can be bet in a lottery through the peer.
+func (p *PeerCap) Limit(peer string) uint64 {
+	p.limitsMu.RLock()
+	defer p.limitsMu.RUnlock()
+
+	if limit, ok := p.peers[peer]; ok {
+		return limit
+	}
+	return p.maxAmount
+}
+
+// Reserve records the amount of the bet received through each channel peer, or returns
+// ErrPeerCapExceeded if any of them would go above its limit.
+func (p *PeerCap) Reserve(ctx context.Context, height uint32, paymentHash string, htlcs []*lnrpc.InvoiceHTLC) error {
+	amounts, err := p.peerAmounts(ctx, htlcs)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	exposure, err := p.db.Exposure.List(height)
+	if err != nil {
+		return err
+	}
+
+	for peer, amount := range amounts {
+		if exposure[peer]+amount > p.Limit(peer) {
+			return errors.Wrapf(ErrPeerCapExceeded, "peer %s has %d sats bet, limit is %d",
+				peer, exposure[peer], p.Limit(peer))
+		}
+	}
+
+	return p.db.Exposure.Add(height, paymentHash, amounts)
+}
+
+// Release removes the reservation of a bet that wasn't completed.
+func (p *PeerCap) Release(paymentHash string) error {
+	return p.db.Exposure.Delete(paymentHash)
+}
+
+// peerAmounts returns the sats received through each channel peer.
+func (p *PeerCap) peerAmounts(ctx context.Context, htlcs []*lnrpc.InvoiceHTLC) (map[string]uint64, error) {
+	channels, err := p.lnd.ListChannels(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing channels")
+	}
+
+	peers := make(map[uint64]string, len(channels))
+	for _, channel := range channels {
+		peers[channel.ChanId] = channel.RemotePubkey
+	}
+
+	amounts := make(map[string]uint64, len(htlcs))
+	for _, htlc := range htlcs {
+		if htlc.State == lnrpc.InvoiceHTLCState_CANCELED {
+			continue
+		}
+
+		peer, ok := peers[htlc.ChanId]
+		if !ok {
+			return nil, errors.Errorf("channel %d not found", htlc.ChanId)
+		}
+		amounts[peer] += htlc.AmtMsat / 1000
+	}
+
+	return amounts, nil
+}
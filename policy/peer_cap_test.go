@@ -0,0 +1,109 @@
+package policy_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	height      = uint32(144)
+	paymentHash = "hash"
+	firstPeer   = "peer1"
+	secondPeer  = "peer2"
+)
+
+var channels = []*lnrpc.Channel{
+	{ChanId: 1, RemotePubkey: firstPeer},
+	{ChanId: 2, RemotePubkey: secondPeer},
+	{ChanId: 3, RemotePubkey: secondPeer},
+}
+
+func setupPeerCap(t *testing.T) (*policy.PeerCap, *db.ExposureStoreMock) {
+	t.Helper()
+
+	lndMock := lightning.NewNodeInfoMock()
+	lndMock.On("ListChannels", mock.Anything).Return(channels, nil)
+
+	exposureMock := db.NewExposureStoreMock()
+	config := config.PeerCap{
+		Enabled:   true,
+		MaxAmount: 10_000,
+		Peers:     map[string]uint64{secondPeer: 50_000},
+	}
+	return policy.NewPeerCap(config, &db.DB{Exposure: exposureMock}, lndMock), exposureMock
+}
+
+func TestLimit(t *testing.T) {
+	peerCap, _ := setupPeerCap(t)
+
+	assert.True(t, peerCap.Enabled())
+	assert.Equal(t, uint64(10_000), peerCap.Limit(firstPeer))
+	assert.Equal(t, uint64(50_000), peerCap.Limit(secondPeer))
+}
+
+func TestPeerCapReload(t *testing.T) {
+	peerCap, _ := setupPeerCap(t)
+
+	peerCap.Reload(config.PeerCap{
+		Enabled:   true,
+		MaxAmount: 20_000,
+		Peers:     map[string]uint64{firstPeer: 5_000},
+	})
+
+	assert.Equal(t, uint64(5_000), peerCap.Limit(firstPeer))
+	assert.Equal(t, uint64(20_000), peerCap.Limit(secondPeer))
+}
+
+func TestReserve(t *testing.T) {
+	peerCap, exposureMock := setupPeerCap(t)
+	htlcs := []*lnrpc.InvoiceHTLC{
+		{ChanId: 1, AmtMsat: 4_000_000, State: lnrpc.InvoiceHTLCState_ACCEPTED},
+		{ChanId: 2, AmtMsat: 30_000_000, State: lnrpc.InvoiceHTLCState_ACCEPTED},
+		{ChanId: 3, AmtMsat: 10_000_000, State: lnrpc.InvoiceHTLCState_ACCEPTED},
+		{ChanId: 1, AmtMsat: 99_000_000, State: lnrpc.InvoiceHTLCState_CANCELED},
+	}
+	expectedAmounts := map[string]uint64{firstPeer: 4_000, secondPeer: 40_000}
+
+	exposureMock.On("List", height).Return(map[string]uint64{firstPeer: 6_000}, nil)
+	exposureMock.On("Add", height, paymentHash, expectedAmounts).Return(nil)
+
+	err := peerCap.Reserve(context.Background(), height, paymentHash, htlcs)
+	assert.NoError(t, err)
+
+	exposureMock.AssertExpectations(t)
+}
+
+func TestReserveExceeded(t *testing.T) {
+	peerCap, exposureMock := setupPeerCap(t)
+	htlcs := []*lnrpc.InvoiceHTLC{
+		{ChanId: 1, AmtMsat: 4_001_000, State: lnrpc.InvoiceHTLCState_ACCEPTED},
+	}
+
+	exposureMock.On("List", height).Return(map[string]uint64{firstPeer: 6_000}, nil)
+
+	err := peerCap.Reserve(context.Background(), height, paymentHash, htlcs)
+	assert.ErrorIs(t, err, policy.ErrPeerCapExceeded)
+
+	exposureMock.AssertNotCalled(t, "Add", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReserveUnknownChannel(t *testing.T) {
+	peerCap, exposureMock := setupPeerCap(t)
+	htlcs := []*lnrpc.InvoiceHTLC{
+		{ChanId: 4, AmtMsat: 1_000_000, State: lnrpc.InvoiceHTLCState_ACCEPTED},
+	}
+
+	err := peerCap.Reserve(context.Background(), height, paymentHash, htlcs)
+	assert.Error(t, err)
+
+	exposureMock.AssertNotCalled(t, "Add", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,100 @@
+package policy
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	maxAliasLength = 32
+	// truncatedLength is the number of characters kept at each end of the truncated public keys
+	truncatedLength = 8
+)
+
+var (
+	// ErrInvalidDisplay is returned when the display mode is not known.
+	ErrInvalidDisplay = errors.New("invalid display mode, must be full, truncated, alias or hidden")
+	// ErrInvalidAlias is returned when the alias is empty, too long or has unsupported characters.
+	ErrInvalidAlias = errors.New("invalid alias, it must have up to 32 letters, digits, spaces, " +
+		"dashes or underscores")
+)
+
+// ValidatePrivacy returns an error if the privacy preference is not valid. The alias is only
+// allowed and required in the alias display mode.
+func ValidatePrivacy(privacy db.Privacy) error {
+	switch privacy.Display {
+	case db.DisplayAlias:
+		return validateAlias(privacy.Alias)
+	case db.DisplayFull, db.DisplayTruncated, db.DisplayHidden:
+		if privacy.Alias != "" {
+			return errors.New("alias is only allowed in the alias display mode")
+		}
+		return nil
+	default:
+		return ErrInvalidDisplay
+	}
+}
+
+// AnonymizeWinners returns a copy of the winners displayed according to their privacy
+// preferences, to be used in the public lists. The winners passed are not modified.
+func AnonymizeWinners(store db.PrivacyStore, winners []db.Winner) ([]db.Winner, error) {
+	if len(winners) == 0 {
+		return winners, nil
+	}
+
+	publicKeys := make([]string, 0, len(winners))
+	seen := make(map[string]struct{}, len(winners))
+	for _, winner := range winners {
+		if _, ok := seen[winner.PublicKey]; ok {
+			continue
+		}
+		seen[winner.PublicKey] = struct{}{}
+		publicKeys = append(publicKeys, winner.PublicKey)
+	}
+
+	preferences, err := store.List(publicKeys)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing privacy preferences")
+	}
+
+	anonymized := make([]db.Winner, len(winners))
+	for i, winner := range winners {
+		switch privacy := preferences[winner.PublicKey]; privacy.Display {
+		case db.DisplayTruncated:
+			winner.PublicKey = truncatePublicKey(winner.PublicKey)
+		case db.DisplayAlias:
+			winner.PublicKey = ""
+			winner.Alias = privacy.Alias
+		case db.DisplayHidden:
+			winner.PublicKey = ""
+		}
+		anonymized[i] = winner
+	}
+
+	return anonymized, nil
+}
+
+func truncatePublicKey(publicKey string) string {
+	if len(publicKey) <= truncatedLength*2 {
+		return publicKey
+	}
+	return publicKey[:truncatedLength] + "..." + publicKey[len(publicKey)-truncatedLength:]
+}
+
+func validateAlias(alias string) error {
+	if alias == "" || len(alias) > maxAliasLength || strings.TrimSpace(alias) != alias {
+		return ErrInvalidAlias
+	}
+
+	for _, r := range alias {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ' ' && r != '-' && r != '_' {
+			return ErrInvalidAlias
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestValidatePrivacy(t *testing.T) {
+	cases := []struct {
+		desc    string
+		privacy db.Privacy
+		fail    bool
+	}{
+		{desc: "Full", privacy: db.Privacy{Display: db.DisplayFull}},
+		{desc: "Truncated", privacy: db.Privacy{Display: db.DisplayTruncated}},
+		{desc: "Hidden", privacy: db.Privacy{Display: db.DisplayHidden}},
+		{desc: "Alias", privacy: db.Privacy{Display: db.DisplayAlias, Alias: "Lucky_Player-7"}},
+		{desc: "Unicode alias", privacy: db.Privacy{Display: db.DisplayAlias, Alias: "Satoshi Nakamotó"}},
+		{desc: "Unknown display", privacy: db.Privacy{Display: "invisible"}, fail: true},
+		{desc: "Missing alias", privacy: db.Privacy{Display: db.DisplayAlias}, fail: true},
+		{desc: "Alias too long", privacy: db.Privacy{Display: db.DisplayAlias, Alias: "abcdefghijklmnopqrstuvwxyz0123456"}, fail: true},
+		{desc: "Alias with symbols", privacy: db.Privacy{Display: db.DisplayAlias, Alias: "<script>"}, fail: true},
+		{desc: "Alias with padding", privacy: db.Privacy{Display: db.DisplayAlias, Alias: " bob"}, fail: true},
+		{desc: "Alias in other mode", privacy: db.Privacy{Display: db.DisplayHidden, Alias: "bob"}, fail: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := policy.ValidatePrivacy(tc.privacy)
+			if tc.fail {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAnonymizeWinners(t *testing.T) {
+	full := "a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90"
+	truncated := "0f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c4b5a69788796a5b4c3d2e1f0"
+	alias := "5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f"
+	hidden := "7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a7a"
+	winners := []db.Winner{
+		{PublicKey: full, Prize: 1, Ticket: 1},
+		{PublicKey: truncated, Prize: 2, Ticket: 2},
+		{PublicKey: alias, Prize: 3, Ticket: 3},
+		{PublicKey: hidden, Prize: 4, Ticket: 4},
+		{PublicKey: alias, Prize: 5, Ticket: 5},
+	}
+
+	privacyMock := db.NewPrivacyStoreMock()
+	privacyMock.On("List", []string{full, truncated, alias, hidden}).Return(map[string]db.Privacy{
+		truncated: {Display: db.DisplayTruncated},
+		alias:     {Display: db.DisplayAlias, Alias: "lucky"},
+		hidden:    {Display: db.DisplayHidden},
+	}, nil)
+
+	anonymized, err := policy.AnonymizeWinners(privacyMock, winners)
+	assert.NoError(t, err)
+
+	expected := []db.Winner{
+		{PublicKey: full, Prize: 1, Ticket: 1},
+		{PublicKey: "0f1e2d3c...c3d2e1f0", Prize: 2, Ticket: 2},
+		{Alias: "lucky", Prize: 3, Ticket: 3},
+		{Prize: 4, Ticket: 4},
+		{Alias: "lucky", Prize: 5, Ticket: 5},
+	}
+	assert.Equal(t, expected, anonymized)
+	// The original winners are kept intact
+	assert.Equal(t, hidden, winners[3].PublicKey)
+}
+
+func TestAnonymizeWinnersError(t *testing.T) {
+	privacyMock := db.NewPrivacyStoreMock()
+	privacyMock.On("List", mock.Anything).Return(nil, errors.New("test"))
+
+	_, err := policy.AnonymizeWinners(privacyMock, []db.Winner{{PublicKey: "a"}})
+	assert.Error(t, err)
+
+	winners, err := policy.AnonymizeWinners(privacyMock, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, winners)
+}
@@ -0,0 +1,157 @@
+package policy
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// SwapClaimDomain separates the signatures of the swap claim receipts from the ones of other
+// messages signed with the audit log key or the players keys.
+const SwapClaimDomain = "btry-swap-claim-v1"
+
+// swapPreimageSize is the number of random bytes of a swap claim preimage.
+const swapPreimageSize = 32
+
+var (
+	// ErrSwapClaimNotPaid is returned when a receipt is countersigned before its invoice is paid.
+	ErrSwapClaimNotPaid = errors.New("swap claim invoice not paid yet")
+	// ErrInvalidCountersignature is returned when the receipt wasn't signed by the winner.
+	ErrInvalidCountersignature = errors.New("invalid countersignature")
+)
+
+// SwapReceipt is the statement of a prize paid to a hold invoice that the server and the winner
+// sign. Both signatures are made over the receipt encoded in JSON.
+type SwapReceipt struct {
+	PublicKey   string `json:"public_key"`
+	PaymentHash string `json:"payment_hash"`
+	Amount      uint64 `json:"amount"`
+	Timestamp   int64  `json:"timestamp"`
+}
+
+// SwapClaims lets winners withdraw their prizes to a hold invoice locked to a hash the server
+// chooses. The server reveals the preimage, which the winner needs to settle the invoice, only
+// after the winner countersigns the receipt of the payment, so both sides end up with a proof.
+type SwapClaims struct {
+	db      *db.DB
+	auditor audit.Auditor
+	now     func() time.Time
+}
+
+// NewSwapClaims returns a new swap claims policy.
+func NewSwapClaims(db *db.DB, auditor audit.Auditor) *SwapClaims {
+	return &SwapClaims{
+		db:      db,
+		auditor: auditor,
+		now:     time.Now,
+	}
+}
+
+// Start returns the payment hash the winner's hold invoice must be locked to.
+func (s *SwapClaims) Start(publicKey string) (string, error) {
+	// Signing the receipts requires the audit log key, fail before the winner creates the invoice
+	if s.auditor.PublicKey() == "" {
+		return "", audit.ErrSigningDisabled
+	}
+
+	preimage := make([]byte, swapPreimageSize)
+	if _, err := rand.Read(preimage); err != nil {
+		return "", errors.Wrap(err, "generating preimage")
+	}
+	hash := sha256.Sum256(preimage)
+	paymentHash := hex.EncodeToString(hash[:])
+
+	err := s.db.SwapClaims.Add(db.SwapClaim{
+		PublicKey:   publicKey,
+		PaymentHash: paymentHash,
+		Preimage:    hex.EncodeToString(preimage),
+		CreatedAt:   s.now().Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return paymentHash, nil
+}
+
+// Sign returns the receipt of the invoice of amount sats paid with a swap claim and the server
+// signature. Each swap claim pays a single invoice.
+func (s *SwapClaims) Sign(publicKey, paymentHash string, amount uint64) (string, string, error) {
+	claim, err := s.get(publicKey, paymentHash)
+	if err != nil {
+		return "", "", err
+	}
+	if claim.Message != "" {
+		return "", "", db.ErrSwapClaimUsed
+	}
+
+	message, err := json.Marshal(SwapReceipt{
+		PublicKey:   publicKey,
+		PaymentHash: paymentHash,
+		Amount:      amount,
+		Timestamp:   s.now().Unix(),
+	})
+	if err != nil {
+		return "", "", errors.Wrap(err, "encoding receipt")
+	}
+
+	signature, err := s.auditor.Sign(SwapClaimDomain, message)
+	if err != nil {
+		return "", "", errors.Wrap(err, "signing receipt")
+	}
+
+	if err := s.db.SwapClaims.SetReceipt(paymentHash, string(message), signature); err != nil {
+		return "", "", err
+	}
+
+	return string(message), signature, nil
+}
+
+// Countersign verifies the winner's signature of the receipt, stores it and returns the preimage
+// that settles the hold invoice.
+func (s *SwapClaims) Countersign(publicKey, paymentHash, countersignature string) (string, error) {
+	claim, err := s.get(publicKey, paymentHash)
+	if err != nil {
+		return "", err
+	}
+	if claim.Message == "" {
+		return "", ErrSwapClaimNotPaid
+	}
+
+	err = audit.VerifySignature(publicKey, SwapClaimDomain, []byte(claim.Message), countersignature)
+	if err != nil {
+		return "", ErrInvalidCountersignature
+	}
+
+	// The preimage was already revealed, don't overwrite the first countersignature
+	if claim.Countersignature != "" {
+		return claim.Preimage, nil
+	}
+
+	err = s.db.SwapClaims.Countersign(paymentHash, countersignature, s.now().Unix())
+	if err != nil {
+		return "", err
+	}
+
+	return claim.Preimage, nil
+}
+
+// get returns the swap claim of the public key, claims of other keys are reported as not found.
+func (s *SwapClaims) get(publicKey, paymentHash string) (db.SwapClaim, error) {
+	claim, err := s.db.SwapClaims.Get(paymentHash)
+	if err != nil {
+		return db.SwapClaim{}, err
+	}
+	if claim.PublicKey != publicKey {
+		return db.SwapClaim{}, db.ErrSwapClaimNotFound
+	}
+
+	return claim, nil
+}
@@ -0,0 +1,96 @@
+package policy_test
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/policy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const auditPrivateKey = "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb"
+
+func setupSwapClaims(t *testing.T) (*policy.SwapClaims, audit.Auditor) {
+	t.Helper()
+
+	_, database := setupLimits(t)
+	auditor, err := audit.New(config.Audit{Enabled: true, PrivateKey: auditPrivateKey}, database)
+	assert.NoError(t, err)
+
+	return policy.NewSwapClaims(database, auditor), auditor
+}
+
+func TestSwapClaims(t *testing.T) {
+	swapClaims, auditor := setupSwapClaims(t)
+	winnerPublicKey, winnerPrivateKey, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	publicKey := hex.EncodeToString(winnerPublicKey)
+
+	paymentHash, err := swapClaims.Start(publicKey)
+	assert.NoError(t, err)
+
+	// The preimage can't be revealed before the invoice is paid
+	_, err = swapClaims.Countersign(publicKey, paymentHash, "")
+	assert.ErrorIs(t, err, policy.ErrSwapClaimNotPaid)
+
+	message, signature, err := swapClaims.Sign(publicKey, paymentHash, 100_000)
+	assert.NoError(t, err)
+	assert.NoError(t, audit.VerifySignature(auditor.PublicKey(), policy.SwapClaimDomain, []byte(message), signature))
+
+	var receipt policy.SwapReceipt
+	assert.NoError(t, json.Unmarshal([]byte(message), &receipt))
+	assert.Equal(t, publicKey, receipt.PublicKey)
+	assert.Equal(t, paymentHash, receipt.PaymentHash)
+	assert.Equal(t, uint64(100_000), receipt.Amount)
+
+	_, _, err = swapClaims.Sign(publicKey, paymentHash, 100_000)
+	assert.ErrorIs(t, err, db.ErrSwapClaimUsed)
+
+	// Receipts signed by the server don't count as the winner's
+	_, err = swapClaims.Countersign(publicKey, paymentHash, signature)
+	assert.ErrorIs(t, err, policy.ErrInvalidCountersignature)
+
+	countersignature := sign(winnerPrivateKey, message)
+	preimage, err := swapClaims.Countersign(publicKey, paymentHash, countersignature)
+	assert.NoError(t, err)
+
+	decoded, err := hex.DecodeString(preimage)
+	assert.NoError(t, err)
+	hash := sha256.Sum256(decoded)
+	assert.Equal(t, paymentHash, hex.EncodeToString(hash[:]))
+}
+
+func TestSwapClaimsOtherPublicKey(t *testing.T) {
+	swapClaims, _ := setupSwapClaims(t)
+
+	paymentHash, err := swapClaims.Start("pubkey")
+	assert.NoError(t, err)
+
+	_, _, err = swapClaims.Sign("pubkey2", paymentHash, 100_000)
+	assert.ErrorIs(t, err, db.ErrSwapClaimNotFound)
+}
+
+func TestSwapClaimsSigningDisabled(t *testing.T) {
+	_, database := setupLimits(t)
+	auditor, err := audit.New(config.Audit{}, database)
+	assert.NoError(t, err)
+
+	_, err = policy.NewSwapClaims(database, auditor).Start("pubkey")
+	assert.ErrorIs(t, err, audit.ErrSigningDisabled)
+}
+
+// sign returns the signature of a receipt made with a winner key.
+func sign(privateKey ed25519.PrivateKey, message string) string {
+	hash := sha256.New()
+	hash.Write([]byte(policy.SwapClaimDomain))
+	hash.Write([]byte{0})
+	hash.Write([]byte(message))
+	return hex.EncodeToString(ed25519.Sign(privateKey, hash.Sum(nil)))
+}
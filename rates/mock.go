@@ -0,0 +1,32 @@
+package rates
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// RatesMock is a mocked implementation of the exchange rates service.
+type RatesMock struct {
+	mock.Mock
+}
+
+// NewRatesMock returns a mocked exchange rates service.
+func NewRatesMock() *RatesMock {
+	return &RatesMock{}
+}
+
+// Convert mock.
+func (r *RatesMock) Convert(sats uint64) Values {
+	args := r.Called(sats)
+	var values Values
+	if v := args.Get(0); v != nil {
+		values = v.(Values)
+	}
+	return values
+}
+
+// Start mock.
+func (r *RatesMock) Start(ctx context.Context) {
+	_ = r.Called(ctx)
+}
@@ -0,0 +1,147 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/pkg/errors"
+)
+
+// Exchange rates providers
+const (
+	ProviderCoinGecko = "coingecko"
+	ProviderMempool   = "mempool"
+)
+
+// maxResponseSize is the maximum size of the providers responses.
+const maxResponseSize = 1 << 16
+
+// Provider fetches the price of bitcoin in fiat currencies.
+type Provider interface {
+	Name() string
+	Prices(ctx context.Context, currencies []string) (Values, error)
+}
+
+// newProvider returns the exchange rates provider configured.
+func newProvider(config config.RateProvider) (Provider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	url := strings.TrimSuffix(config.URL, "/")
+
+	switch config.Name {
+	case ProviderCoinGecko:
+		if url == "" {
+			url = "https://api.coingecko.com"
+		}
+		return &coinGecko{client: client, url: url}, nil
+	case ProviderMempool:
+		if url == "" {
+			url = "https://mempool.space"
+		}
+		return &mempool{client: client, url: url}, nil
+	default:
+		return nil, errors.Errorf("invalid rates provider %q", config.Name)
+	}
+}
+
+// coinGecko communicates with the CoinGecko simple price API.
+type coinGecko struct {
+	client *http.Client
+	url    string
+}
+
+// Name returns the name of the provider.
+func (c *coinGecko) Name() string {
+	return ProviderCoinGecko
+}
+
+// Prices returns the price of one bitcoin in the currencies specified.
+func (c *coinGecko) Prices(ctx context.Context, currencies []string) (Values, error) {
+	path := "/api/v3/simple/price?ids=bitcoin&vs_currencies=" +
+		strings.ToLower(strings.Join(currencies, ","))
+
+	var resp struct {
+		Bitcoin map[string]float64 `json:"bitcoin"`
+	}
+	if err := get(ctx, c.client, c.url+path, &resp); err != nil {
+		return nil, err
+	}
+
+	prices := make(Values, len(resp.Bitcoin))
+	for currency, price := range resp.Bitcoin {
+		prices[strings.ToUpper(currency)] = price
+	}
+
+	return pick(prices, currencies)
+}
+
+// mempool communicates with the prices API of a mempool.space instance.
+type mempool struct {
+	client *http.Client
+	url    string
+}
+
+// Name returns the name of the provider.
+func (m *mempool) Name() string {
+	return ProviderMempool
+}
+
+// Prices returns the price of one bitcoin in the currencies specified.
+func (m *mempool) Prices(ctx context.Context, currencies []string) (Values, error) {
+	// The response includes the time of the prices along with them
+	var prices Values
+	if err := get(ctx, m.client, m.url+"/api/v1/prices", &prices); err != nil {
+		return nil, err
+	}
+
+	return pick(prices, currencies)
+}
+
+func get(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "sending request")
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return errors.Wrap(err, "reading response")
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status code %d: %s", res.StatusCode, body)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return errors.Wrap(err, "decoding response")
+	}
+
+	return nil
+}
+
+// pick returns the prices of the currencies specified, failing if any is missing so the next
+// provider is tried.
+func pick(prices Values, currencies []string) (Values, error) {
+	picked := make(Values, len(currencies))
+	for _, currency := range currencies {
+		price, ok := prices[currency]
+		if !ok || price <= 0 {
+			return nil, errors.Errorf("no price for %s", currency)
+		}
+		picked[currency] = price
+	}
+
+	return picked, nil
+}
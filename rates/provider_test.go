@@ -0,0 +1,65 @@
+package rates
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoinGecko(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v3/simple/price", r.URL.Path)
+		assert.Equal(t, "bitcoin", r.URL.Query().Get("ids"))
+		assert.Equal(t, "usd,eur", r.URL.Query().Get("vs_currencies"))
+		w.Write([]byte(`{"bitcoin":{"usd":60000,"eur":55000.5}}`))
+	}))
+	defer server.Close()
+
+	provider, err := newProvider(config.RateProvider{Name: ProviderCoinGecko, URL: server.URL + "/"})
+	assert.NoError(t, err)
+
+	prices, err := provider.Prices(context.Background(), []string{"USD", "EUR"})
+	assert.NoError(t, err)
+	assert.Equal(t, Values{"USD": 60_000, "EUR": 55_000.5}, prices)
+}
+
+func TestMempool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/prices", r.URL.Path)
+		w.Write([]byte(`{"time":1703252411,"USD":60000,"EUR":55000,"GBP":48000}`))
+	}))
+	defer server.Close()
+
+	provider, err := newProvider(config.RateProvider{Name: ProviderMempool, URL: server.URL})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	prices, err := provider.Prices(ctx, []string{"EUR"})
+	assert.NoError(t, err)
+	assert.Equal(t, Values{"EUR": 55_000}, prices)
+
+	_, err = provider.Prices(ctx, []string{"ARS"})
+	assert.ErrorContains(t, err, "no price for ARS")
+}
+
+func TestProviderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte("rate limited"))
+	}))
+	defer server.Close()
+
+	provider, err := newProvider(config.RateProvider{Name: ProviderMempool, URL: server.URL})
+	assert.NoError(t, err)
+
+	_, err = provider.Prices(context.Background(), []string{"USD"})
+	assert.ErrorContains(t, err, "rate limited")
+
+	_, err = newProvider(config.RateProvider{Name: "kraken"})
+	assert.Error(t, err)
+}
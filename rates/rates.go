@@ -0,0 +1,150 @@
+// Package rates fetches the price of bitcoin in fiat currencies, so the API can show the
+// approximate value of the amounts handled by the lottery.
+package rates
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultInterval = 5 * time.Minute
+	satsPerBitcoin  = 100_000_000
+)
+
+// ErrNoProvider is returned when none of the providers responded with the prices.
+var ErrNoProvider = errors.New("no exchange rates provider available")
+
+// Values maps currency codes to amounts in that currency.
+type Values map[string]float64
+
+// Rates converts amounts to fiat currencies.
+type Rates interface {
+	Convert(sats uint64) Values
+	Start(ctx context.Context)
+}
+
+type rates struct {
+	logger     *logger.Logger
+	now        func() time.Time
+	prices     Values
+	updatedAt  time.Time
+	providers  []Provider
+	currencies []string
+	interval   time.Duration
+	maxAge     time.Duration
+	enabled    bool
+	mu         sync.RWMutex
+}
+
+// New returns a new exchange rates service.
+func New(config config.Rates) (Rates, error) {
+	logger, err := logger.New(config.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &rates{
+		logger:   logger,
+		now:      time.Now,
+		interval: config.Interval,
+		maxAge:   config.MaxAge,
+		enabled:  config.Enabled,
+	}
+	if r.interval == 0 {
+		r.interval = defaultInterval
+	}
+	if r.maxAge == 0 {
+		r.maxAge = 3 * r.interval
+	}
+
+	if !config.Enabled {
+		return r, nil
+	}
+
+	for _, providerConfig := range config.Providers {
+		provider, err := newProvider(providerConfig)
+		if err != nil {
+			return nil, err
+		}
+		r.providers = append(r.providers, provider)
+	}
+
+	for _, currency := range config.Currencies {
+		r.currencies = append(r.currencies, strings.ToUpper(currency))
+	}
+
+	return r, nil
+}
+
+// Convert returns the value of the sats in each currency, rounded to two decimals. It returns nil
+// if the rates are disabled or the prices are outdated.
+func (r *rates) Convert(sats uint64) Values {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.prices == nil || r.now().Sub(r.updatedAt) > r.maxAge {
+		return nil
+	}
+
+	values := make(Values, len(r.prices))
+	for currency, price := range r.prices {
+		value := float64(sats) / satsPerBitcoin * price
+		values[currency] = math.Round(value*100) / 100
+	}
+
+	return values
+}
+
+// Start executes the loop that updates the prices periodically.
+func (r *rates) Start(ctx context.Context) {
+	if !r.enabled {
+		r.logger.Info("Exchange rates disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := r.update(ctx); err != nil {
+				r.logger.Error(err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// update fetches the prices from the first provider that responds, in the order configured. The
+// previous prices are kept if none does.
+func (r *rates) update(ctx context.Context) error {
+	for _, provider := range r.providers {
+		prices, err := provider.Prices(ctx, r.currencies)
+		if err != nil {
+			r.logger.Warningf("Fetching prices from %s: %v", provider.Name(), err)
+			continue
+		}
+
+		r.mu.Lock()
+		r.prices = prices
+		r.updatedAt = r.now()
+		r.mu.Unlock()
+		return nil
+	}
+
+	return ErrNoProvider
+}
@@ -0,0 +1,81 @@
+package rates
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRates(t *testing.T, urls ...string) *rates {
+	t.Helper()
+
+	providers := make([]config.RateProvider, 0, len(urls))
+	for _, url := range urls {
+		providers = append(providers, config.RateProvider{Name: ProviderMempool, URL: url})
+	}
+
+	r, err := New(config.Rates{
+		Enabled:    true,
+		Providers:  providers,
+		Currencies: []string{"usd"},
+		Interval:   time.Minute,
+	})
+	assert.NoError(t, err)
+
+	return r.(*rates)
+}
+
+func TestUpdateFailover(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"USD":60000}`))
+	}))
+	defer up.Close()
+
+	r := newTestRates(t, down.URL, up.URL)
+
+	assert.Nil(t, r.Convert(1_000))
+
+	err := r.update(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, Values{"USD": 0.6}, r.Convert(1_000))
+	assert.Equal(t, Values{"USD": 0.01}, r.Convert(12))
+}
+
+func TestUpdateNoProvider(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	r := newTestRates(t, down.URL)
+	r.prices = Values{"USD": 50_000}
+	r.updatedAt = time.Now()
+
+	err := r.update(context.Background())
+	assert.ErrorIs(t, err, ErrNoProvider)
+
+	// The previous prices are kept until they are too old
+	assert.Equal(t, Values{"USD": 500}, r.Convert(1_000_000))
+
+	r.now = func() time.Time { return time.Now().Add(3*time.Minute + time.Second) }
+	assert.Nil(t, r.Convert(1_000_000))
+}
+
+func TestDisabled(t *testing.T) {
+	r, err := New(config.Rates{})
+	assert.NoError(t, err)
+
+	r.Start(context.Background())
+	assert.Nil(t, r.Convert(1_000))
+}
@@ -0,0 +1,33 @@
+package reload
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// ReloaderMock is a mocked implementation of a configuration reloader.
+type ReloaderMock struct {
+	mock.Mock
+}
+
+// NewReloaderMock returns a mocked configuration reloader.
+func NewReloaderMock() *ReloaderMock {
+	return &ReloaderMock{}
+}
+
+// Listen mock.
+func (r *ReloaderMock) Listen(ctx context.Context) {
+	_ = r.Called(ctx)
+}
+
+// Register mock.
+func (r *ReloaderMock) Register(hooks ...Hook) {
+	_ = r.Called(hooks)
+}
+
+// Reload mock.
+func (r *ReloaderMock) Reload() error {
+	args := r.Called()
+	return args.Error(0)
+}
@@ -0,0 +1,119 @@
+// Package reload applies the configuration changes that don't require restarting the server, so
+// the block subscriptions aren't interrupted.
+package reload
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidConfig is returned when the configuration file is not valid or it changes settings
+// that can't be reloaded.
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// Hook prepares a service to use the configuration passed, it returns the function that applies
+// it or an error if it can't be used.
+//
+// The functions returned must not fail, they are called only if every hook succeeded.
+type Hook func(next config.Config) (apply func(), err error)
+
+// Reloader reloads the configuration file.
+type Reloader interface {
+	Listen(ctx context.Context)
+	Register(hooks ...Hook)
+	Reload() error
+}
+
+type reloader struct {
+	logger  *logger.Logger
+	path    string
+	current config.Config
+	hooks   []Hook
+	// mu serializes reloads, the configuration is swapped atomically
+	mu sync.Mutex
+}
+
+// New returns a new configuration reloader.
+func New(path string, current config.Config) (Reloader, error) {
+	logger, err := logger.New(current.Reload.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reloader{
+		logger:  logger,
+		path:    path,
+		current: current,
+	}, nil
+}
+
+// Listen reloads the configuration every time the process receives a SIGHUP signal.
+func (r *reloader) Listen(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(signals)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				if err := r.Reload(); err != nil {
+					r.logger.Error(err)
+				}
+			}
+		}
+	}()
+}
+
+// Register adds hooks that are executed on every reload, in order.
+func (r *reloader) Register(hooks ...Hook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hooks = append(r.hooks, hooks...)
+}
+
+// Reload loads and validates the configuration file and applies it to all the services. Nothing
+// changes if the file is not valid or any of the hooks fails.
+func (r *reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := config.Load(r.path)
+	if err != nil {
+		return errors.Wrap(ErrInvalidConfig, err.Error())
+	}
+
+	if err := r.current.Reloadable(next); err != nil {
+		return errors.Wrap(ErrInvalidConfig, err.Error())
+	}
+
+	applies := make([]func(), 0, len(r.hooks))
+	for _, hook := range r.hooks {
+		apply, err := hook(next)
+		if err != nil {
+			return errors.Wrap(err, "preparing reload")
+		}
+		applies = append(applies, apply)
+	}
+
+	for _, apply := range applies {
+		apply()
+	}
+	logger.Reload(next.Loggers()...)
+	r.current = next
+
+	r.logger.Info("Configuration reloaded")
+	return nil
+}
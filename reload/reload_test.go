@@ -0,0 +1,121 @@
+package reload
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const configTemplate = `
+lightning:
+  rpc_address: 127.0.0.1:10001
+  tls_cert_path: ../config/testdata/tls.cert
+  macaroon_path: ../config/testdata/readonly.macaroon
+lottery:
+  duration: 144
+  logger:
+    label: Lottery
+    level: %d
+  limits:
+    cooldown: 24h
+server:
+  address: %s
+tor:
+  address: 127.0.0.1:9050
+`
+
+func writeConfig(t *testing.T, path string, level int, serverAddress string) {
+	t.Helper()
+
+	content := fmt.Sprintf(configTemplate, level, serverAddress)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func setupReloader(t *testing.T) (*reloader, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "btry.yml")
+	writeConfig(t, path, 0, "127.0.0.1:9000")
+
+	current, err := config.Load(path)
+	assert.NoError(t, err)
+
+	r, err := New(path, current)
+	assert.NoError(t, err)
+
+	return r.(*reloader), path
+}
+
+func TestReload(t *testing.T) {
+	r, path := setupReloader(t)
+
+	var applied config.Config
+	r.Register(func(next config.Config) (func(), error) {
+		return func() { applied = next }, nil
+	})
+
+	writeConfig(t, path, 1, "127.0.0.1:9000")
+
+	err := r.Reload()
+	assert.NoError(t, err)
+
+	assert.Equal(t, uint8(1), applied.Lottery.Logger.Level)
+	assert.Equal(t, applied, r.current)
+}
+
+func TestReloadStructuralChange(t *testing.T) {
+	r, path := setupReloader(t)
+	current := r.current
+
+	r.Register(func(next config.Config) (func(), error) {
+		t.Fatal("hook executed")
+		return nil, nil
+	})
+
+	writeConfig(t, path, 1, "127.0.0.1:9001")
+
+	err := r.Reload()
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	assert.ErrorContains(t, err, "server settings changed")
+	assert.Equal(t, current, r.current)
+}
+
+func TestReloadInvalidFile(t *testing.T) {
+	r, path := setupReloader(t)
+
+	assert.NoError(t, os.WriteFile(path, []byte("lottery: ["), 0o600))
+
+	err := r.Reload()
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestReloadHookError(t *testing.T) {
+	r, path := setupReloader(t)
+	current := r.current
+
+	applied := false
+	r.Register(
+		func(next config.Config) (func(), error) {
+			return func() { applied = true }, nil
+		},
+		func(next config.Config) (func(), error) {
+			return nil, errors.New("invalid credentials")
+		},
+	)
+
+	writeConfig(t, path, 1, "127.0.0.1:9000")
+
+	err := r.Reload()
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidConfig)
+
+	// Nothing is applied if any of the hooks fails
+	assert.False(t, applied)
+	assert.Equal(t, current, r.current)
+}
@@ -0,0 +1,28 @@
+package reserves
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// ProverMock is a mocked implementation of the proof of reserves generator.
+type ProverMock struct {
+	mock.Mock
+}
+
+// NewProverMock returns a mocked proof of reserves generator.
+func NewProverMock() *ProverMock {
+	return &ProverMock{}
+}
+
+// Proof mock.
+func (p *ProverMock) Proof() (Proof, error) {
+	args := p.Called()
+	return args.Get(0).(Proof), args.Error(1)
+}
+
+// Start mock.
+func (p *ProverMock) Start(ctx context.Context) {
+	_ = p.Called(ctx)
+}
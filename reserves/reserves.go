@@ -0,0 +1,274 @@
+// Package reserves publishes a proof that the node can cover the prizes owed to the players: a
+// statement of the liabilities and the channel balances, signed with the audit log key and the node
+// key, refreshed on every block.
+package reserves
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// Domain separates the signatures of the statements from the ones of other messages signed with
+// the audit log key.
+const Domain = "btry-reserves-v1"
+
+var (
+	// ErrDisabled is returned when the proof of reserves is disabled.
+	ErrDisabled = errors.New("proof of reserves disabled")
+	// ErrNotReady is returned until the first proof is generated.
+	ErrNotReady = errors.New("proof of reserves not generated yet")
+)
+
+// Channel is the balance of a channel, its channel point can be looked up on chain.
+type Channel struct {
+	ChannelPoint string `json:"channel_point"`
+	Capacity     int64  `json:"capacity"`
+	LocalBalance int64  `json:"local_balance"`
+}
+
+// Statement contains the liabilities of the lottery and the balances covering them at a block
+// height. Liabilities are the prizes not claimed yet plus the prize pool of the lottery in
+// progress.
+type Statement struct {
+	Channels        []Channel `json:"channels"`
+	Timestamp       int64     `json:"timestamp"`
+	UnclaimedPrizes uint64    `json:"unclaimed_prizes"`
+	PrizePool       uint64    `json:"prize_pool"`
+	Liabilities     uint64    `json:"liabilities"`
+	LocalBalance    uint64    `json:"local_balance"`
+	Height          uint32    `json:"height"`
+}
+
+// Proof is a statement signed with the audit log key and the node key. Both signatures are made
+// over the message, the statement encoded in JSON.
+type Proof struct {
+	Message       string `json:"message"`
+	Signature     string `json:"signature"`
+	NodePublicKey string `json:"node_public_key"`
+	NodeSignature string `json:"node_signature"`
+	Statement
+}
+
+// Prover generates the proofs of reserves.
+type Prover interface {
+	Proof() (Proof, error)
+	Start(ctx context.Context)
+}
+
+// Node is the part of the lightning node the proofs are generated from, they are signed with its
+// key on every block.
+type Node interface {
+	lightning.ChainNotifier
+	lightning.NodeInfo
+}
+
+type prover struct {
+	db      *db.DB
+	lnd     Node
+	auditor audit.Auditor
+	logger  *logger.Logger
+	now     func() time.Time
+	proof   *Proof
+	mu      sync.RWMutex
+	enabled bool
+}
+
+// New returns a new proof of reserves generator.
+func New(config config.Reserves, db *db.DB, lnd Node, auditor audit.Auditor) (Prover, error) {
+	logger, err := logger.New(config.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &prover{
+		db:      db,
+		lnd:     lnd,
+		auditor: auditor,
+		logger:  logger,
+		now:     time.Now,
+		enabled: config.Enabled,
+	}, nil
+}
+
+// Proof returns the last proof generated.
+func (p *prover) Proof() (Proof, error) {
+	if !p.enabled {
+		return Proof{}, ErrDisabled
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.proof == nil {
+		return Proof{}, ErrNotReady
+	}
+
+	return *p.proof, nil
+}
+
+// Start generates a proof and a new one on every block.
+func (p *prover) Start(ctx context.Context) {
+	if !p.enabled {
+		p.logger.Info("Proof of reserves disabled")
+		return
+	}
+
+	go func() {
+		info, err := p.lnd.GetInfo(ctx)
+		if err != nil {
+			p.logger.Error(errors.Wrap(err, "getting node information"))
+			return
+		}
+
+		if err := p.update(ctx, info.BlockHeight); err != nil {
+			p.logger.Error(err)
+		}
+
+		stream, err := p.lnd.SubscribeBlocks(ctx)
+		if err != nil {
+			p.logger.Error(errors.Wrap(err, "subscribing to blocks stream"))
+			return
+		}
+
+		for {
+			block, err := stream.Recv()
+			if err != nil {
+				p.logger.Error(errors.Wrap(err, "receiving events from blocks stream"))
+				return
+			}
+
+			if err := p.update(ctx, block.Height); err != nil {
+				p.logger.Error(err)
+			}
+		}
+	}()
+}
+
+// update generates the proof of the block height specified.
+func (p *prover) update(ctx context.Context, height uint32) error {
+	statement, err := p.statement(ctx, height)
+	if err != nil {
+		return err
+	}
+
+	message, err := json.Marshal(statement)
+	if err != nil {
+		return errors.Wrap(err, "encoding statement")
+	}
+
+	signature, err := p.auditor.Sign(Domain, message)
+	if err != nil {
+		return errors.Wrap(err, "signing statement")
+	}
+
+	nodeSignature, err := p.lnd.SignMessage(ctx, message)
+	if err != nil {
+		return err
+	}
+
+	info, err := p.lnd.GetInfo(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting node information")
+	}
+
+	proof := &Proof{
+		Statement:     statement,
+		Message:       string(message),
+		Signature:     signature,
+		NodePublicKey: info.IdentityPubkey,
+		NodeSignature: nodeSignature,
+	}
+
+	p.mu.Lock()
+	p.proof = proof
+	p.mu.Unlock()
+
+	if statement.LocalBalance < statement.Liabilities {
+		p.logger.Warningf("Liabilities (%d sats) exceed the local balance (%d sats)",
+			statement.Liabilities, statement.LocalBalance)
+	}
+
+	return nil
+}
+
+func (p *prover) statement(ctx context.Context, height uint32) (Statement, error) {
+	unclaimed, err := p.db.Prizes.GetTotal()
+	if err != nil {
+		return Statement{}, errors.Wrap(err, "getting unclaimed prizes")
+	}
+
+	// The prize pools of every lottery awaiting its draw are owed, not only the one open for bets
+	heights, err := p.db.Lotteries.ListUndrawn(height)
+	if err != nil {
+		return Statement{}, errors.Wrap(err, "listing undrawn lotteries")
+	}
+
+	prizePool := uint64(0)
+	for _, lotteryHeight := range heights {
+		pools, err := p.db.Bets.ListPools(lotteryHeight)
+		if err != nil {
+			return Statement{}, errors.Wrap(err, "listing pools")
+		}
+
+		for _, pool := range pools {
+			amount, err := p.db.Bets.GetPrizePool(lotteryHeight, pool)
+			if err != nil {
+				return Statement{}, errors.Wrap(err, "getting prize pool")
+			}
+			prizePool += amount
+		}
+	}
+
+	channels, err := p.lnd.ListChannels(ctx)
+	if err != nil {
+		return Statement{}, err
+	}
+
+	statement := Statement{
+		Height:          height,
+		Timestamp:       p.now().Unix(),
+		UnclaimedPrizes: unclaimed,
+		PrizePool:       prizePool,
+		Liabilities:     unclaimed + prizePool,
+		Channels:        make([]Channel, 0, len(channels)),
+	}
+	for _, channel := range channels {
+		statement.Channels = append(statement.Channels, Channel{
+			ChannelPoint: channel.ChannelPoint,
+			Capacity:     channel.Capacity,
+			LocalBalance: channel.LocalBalance,
+		})
+		statement.LocalBalance += uint64(channel.LocalBalance)
+	}
+
+	return statement, nil
+}
+
+// Verify checks that the proof was signed with the audit log key and that its message matches the
+// statement. The node signature can be verified with lnd's verifymessage.
+func Verify(auditPublicKey string, proof Proof) error {
+	if err := audit.VerifySignature(auditPublicKey, Domain, []byte(proof.Message), proof.Signature); err != nil {
+		return err
+	}
+
+	message, err := json.Marshal(proof.Statement)
+	if err != nil {
+		return errors.Wrap(err, "encoding statement")
+	}
+
+	if string(message) != proof.Message {
+		return errors.New("the statement does not match the message signed")
+	}
+
+	return nil
+}
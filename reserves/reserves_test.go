@@ -0,0 +1,142 @@
+package reserves
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/audit"
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	privateKey    = "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb"
+	nodePublicKey = "03864ef025fde8fb587d989186ce6a4a186895ee44a926bfc370e2c366597a3f8f"
+)
+
+type mocks struct {
+	bets      *db.BetsStoreMock
+	lotteries *db.LotteriesStoreMock
+	prizes    *db.PrizesStoreMock
+	lnd       *lightning.ClientMock
+}
+
+func newTestProver(t *testing.T, enabled bool) (*prover, audit.Auditor, mocks) {
+	t.Helper()
+
+	auditMock := db.NewAuditStoreMock()
+	auditMock.On("Last").Return(db.AuditEntry{}, nil)
+	auditor, err := audit.New(config.Audit{Enabled: true, PrivateKey: privateKey}, &db.DB{Audit: auditMock})
+	assert.NoError(t, err)
+
+	m := mocks{
+		bets:      db.NewBetsStoreMock(),
+		lotteries: db.NewLotteriesStoreMock(),
+		prizes:    db.NewPrizesStoreMock(),
+		lnd:       lightning.NewClientMock(),
+	}
+	database := &db.DB{Bets: m.bets, Lotteries: m.lotteries, Prizes: m.prizes}
+
+	p, err := New(config.Reserves{
+		Enabled: enabled,
+		Logger:  config.Logger{Level: uint8(logger.DISABLED)},
+	}, database, m.lnd, auditor)
+	assert.NoError(t, err)
+
+	prover := p.(*prover)
+	prover.now = func() time.Time { return time.Unix(1_700_000_000, 0) }
+
+	return prover, auditor, m
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	p, auditor, m := newTestProver(t, true)
+
+	_, err := p.Proof()
+	assert.ErrorIs(t, err, ErrNotReady)
+
+	m.prizes.On("GetTotal").Return(uint64(40_000), nil)
+	// The lottery at 102 awaits its draw while the one at 108 accepts bets
+	m.lotteries.On("ListUndrawn", uint32(100)).Return([]uint32{102, 108}, nil)
+	m.bets.On("ListPools", uint32(102)).Return([]string{"", "whale"}, nil)
+	m.bets.On("GetPrizePool", uint32(102), "").Return(uint64(5_000), nil)
+	m.bets.On("GetPrizePool", uint32(102), "whale").Return(uint64(15_000), nil)
+	m.bets.On("ListPools", uint32(108)).Return([]string{""}, nil)
+	m.bets.On("GetPrizePool", uint32(108), "").Return(uint64(2_000), nil)
+	m.lnd.On("ListChannels", ctx).Return([]*lnrpc.Channel{
+		{ChannelPoint: "txid:0", Capacity: 100_000, LocalBalance: 50_000},
+		{ChannelPoint: "txid:1", Capacity: 20_000, LocalBalance: 5_000},
+	}, nil)
+	m.lnd.On("SignMessage", ctx, mock.Anything).Return("node_signature", nil)
+	m.lnd.On("GetInfo", ctx).Return(&lnrpc.GetInfoResponse{IdentityPubkey: nodePublicKey}, nil)
+
+	err = p.update(ctx, 100)
+	assert.NoError(t, err)
+
+	proof, err := p.Proof()
+	assert.NoError(t, err)
+
+	expected := Statement{
+		Channels: []Channel{
+			{ChannelPoint: "txid:0", Capacity: 100_000, LocalBalance: 50_000},
+			{ChannelPoint: "txid:1", Capacity: 20_000, LocalBalance: 5_000},
+		},
+		Timestamp:       1_700_000_000,
+		UnclaimedPrizes: 40_000,
+		PrizePool:       22_000,
+		Liabilities:     62_000,
+		LocalBalance:    55_000,
+		Height:          100,
+	}
+	assert.Equal(t, expected, proof.Statement)
+	assert.Equal(t, nodePublicKey, proof.NodePublicKey)
+	assert.Equal(t, "node_signature", proof.NodeSignature)
+	m.lnd.AssertCalled(t, "SignMessage", ctx, []byte(proof.Message))
+
+	var decoded Statement
+	assert.NoError(t, json.Unmarshal([]byte(proof.Message), &decoded))
+	assert.Equal(t, expected, decoded)
+
+	assert.NoError(t, Verify(auditor.PublicKey(), proof))
+
+	t.Run("Tampered statement", func(t *testing.T) {
+		tampered := proof
+		tampered.Liabilities = 1
+		assert.Error(t, Verify(auditor.PublicKey(), tampered))
+	})
+
+	t.Run("Tampered message", func(t *testing.T) {
+		tampered := proof
+		tampered.Message = `{"liabilities":1}`
+		assert.Error(t, Verify(auditor.PublicKey(), tampered))
+	})
+}
+
+func TestUpdateError(t *testing.T) {
+	p, _, m := newTestProver(t, true)
+
+	m.prizes.On("GetTotal").Return(uint64(0), errors.New("test error"))
+
+	err := p.update(context.Background(), 100)
+	assert.Error(t, err)
+
+	_, err = p.Proof()
+	assert.ErrorIs(t, err, ErrNotReady)
+}
+
+func TestProofDisabled(t *testing.T) {
+	p, _, _ := newTestProver(t, false)
+
+	_, err := p.Proof()
+	assert.ErrorIs(t, err, ErrDisabled)
+}
@@ -0,0 +1,29 @@
+package swaps
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// ProviderMock is a mocked implementation of a swap provider.
+type ProviderMock struct {
+	mock.Mock
+}
+
+// NewProviderMock returns a mocked swap provider.
+func NewProviderMock() *ProviderMock {
+	return &ProviderMock{}
+}
+
+// Create mock.
+func (p *ProviderMock) Create(ctx context.Context, invoice, refundPublicKey string) (Swap, error) {
+	args := p.Called(ctx, invoice, refundPublicKey)
+	return args.Get(0).(Swap), args.Error(1)
+}
+
+// Status mock.
+func (p *ProviderMock) Status(ctx context.Context, id string) (string, error) {
+	args := p.Called(ctx, id)
+	return args.String(0), args.Error(1)
+}
@@ -0,0 +1,193 @@
+// Package swaps lets players holding on-chain funds only pay the bet invoices through submarine
+// swaps: they send the coins to an address of the swap provider, which pays the invoice in turn.
+package swaps
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/pkg/errors"
+)
+
+// Swap providers
+const (
+	ProviderBoltz = "boltz"
+)
+
+// Swap statuses reported by the provider, only the relevant ones are listed.
+const (
+	StatusCreated      = "swap.created"
+	StatusMempool      = "transaction.mempool"
+	StatusConfirmed    = "transaction.confirmed"
+	StatusInvoicePaid  = "invoice.paid"
+	StatusClaimed      = "transaction.claimed"
+	StatusFailedToPay  = "invoice.failedToPay"
+	StatusLockupFailed = "transaction.lockupFailed"
+	StatusExpired      = "swap.expired"
+	StatusRefunded     = "transaction.refunded"
+)
+
+const (
+	defaultBoltzURL = "https://api.boltz.exchange"
+	// maxResponseSize is the maximum size of the provider responses.
+	maxResponseSize = 1 << 16
+	timeout         = 30 * time.Second
+)
+
+// ErrDisabled is returned when no swap provider is configured.
+var ErrDisabled = errors.New("submarine swaps are disabled")
+
+// Provider creates submarine swaps that pay an invoice once the on-chain funds are locked.
+type Provider interface {
+	Create(ctx context.Context, invoice, refundPublicKey string) (Swap, error)
+	Status(ctx context.Context, id string) (string, error)
+}
+
+// Swap is a submarine swap created to pay an invoice. The player sends ExpectedAmount sats to the
+// address, they can refund them with the key passed on creation after TimeoutBlockHeight if the
+// provider doesn't pay the invoice.
+type Swap struct {
+	ID                 string `json:"id"`
+	Address            string `json:"address"`
+	BIP21              string `json:"bip21"`
+	ExpectedAmount     uint64 `json:"expected_amount"`
+	TimeoutBlockHeight uint32 `json:"timeout_block_height"`
+}
+
+// Final returns whether the status won't change anymore.
+func Final(status string) bool {
+	switch status {
+	case StatusClaimed, StatusFailedToPay, StatusLockupFailed, StatusExpired, StatusRefunded:
+		return true
+	default:
+		return false
+	}
+}
+
+// New returns the swap provider configured, or one that fails with ErrDisabled if there's none.
+func New(config config.Swaps) (Provider, error) {
+	address := strings.TrimSuffix(config.URL, "/")
+
+	switch config.Provider {
+	case "":
+		return disabled{}, nil
+	case ProviderBoltz:
+		if address == "" {
+			address = defaultBoltzURL
+		}
+		return &boltz{client: &http.Client{Timeout: timeout}, url: address}, nil
+	default:
+		return nil, errors.Errorf("invalid swap provider %q", config.Provider)
+	}
+}
+
+type disabled struct{}
+
+func (disabled) Create(context.Context, string, string) (Swap, error) {
+	return Swap{}, ErrDisabled
+}
+
+func (disabled) Status(context.Context, string) (string, error) {
+	return "", ErrDisabled
+}
+
+type boltzSwapRequest struct {
+	From            string `json:"from"`
+	To              string `json:"to"`
+	Invoice         string `json:"invoice"`
+	RefundPublicKey string `json:"refundPublicKey"`
+}
+
+type boltzSwapResponse struct {
+	ID                 string `json:"id"`
+	Address            string `json:"address"`
+	BIP21              string `json:"bip21"`
+	ExpectedAmount     uint64 `json:"expectedAmount"`
+	TimeoutBlockHeight uint32 `json:"timeoutBlockHeight"`
+}
+
+// boltz communicates with the Boltz v2 REST API.
+type boltz struct {
+	client *http.Client
+	url    string
+}
+
+// Create creates a submarine swap from bitcoin on-chain to lightning paying the invoice.
+func (b *boltz) Create(ctx context.Context, invoice, refundPublicKey string) (Swap, error) {
+	req := boltzSwapRequest{
+		From:            "BTC",
+		To:              "BTC",
+		Invoice:         invoice,
+		RefundPublicKey: refundPublicKey,
+	}
+
+	var resp boltzSwapResponse
+	if err := b.do(ctx, http.MethodPost, "/v2/swap/submarine", req, &resp); err != nil {
+		return Swap{}, errors.Wrap(err, "creating swap")
+	}
+
+	return Swap{
+		ID:                 resp.ID,
+		Address:            resp.Address,
+		BIP21:              resp.BIP21,
+		ExpectedAmount:     resp.ExpectedAmount,
+		TimeoutBlockHeight: resp.TimeoutBlockHeight,
+	}, nil
+}
+
+// Status returns the status of the swap.
+func (b *boltz) Status(ctx context.Context, id string) (string, error) {
+	var resp struct {
+		Status string `json:"status"`
+	}
+	if err := b.do(ctx, http.MethodGet, "/v2/swap/"+url.PathEscape(id), nil, &resp); err != nil {
+		return "", errors.Wrap(err, "getting swap status")
+	}
+
+	return resp.Status, nil
+}
+
+func (b *boltz) do(ctx context.Context, method, path string, body, dst any) error {
+	var reqBody io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "encoding request")
+		}
+		reqBody = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.url+path, reqBody)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		var boltzErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&boltzErr)
+		return errors.Errorf("boltz returned status %d: %s", resp.StatusCode, boltzErr.Error)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(dst); err != nil {
+		return errors.Wrap(err, "decoding response")
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package swaps
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoltz(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/swap/submarine":
+			assert.Equal(t, http.MethodPost, r.Method)
+			var req boltzSwapRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, boltzSwapRequest{From: "BTC", To: "BTC", Invoice: "lnbc1", RefundPublicKey: "02ab"}, req)
+
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"jkP3hq","address":"bc1pswap","bip21":"bitcoin:bc1pswap?amount=0.0000242",
+			"expectedAmount":2420,"timeoutBlockHeight":840288,"acceptZeroConf":false}`))
+		case "/v2/swap/jkP3hq":
+			w.Write([]byte(`{"status":"invoice.paid"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"could not find swap with id: unknown"}`))
+		}
+	}))
+	defer server.Close()
+
+	provider, err := New(config.Swaps{Provider: ProviderBoltz, URL: server.URL + "/"})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	swap, err := provider.Create(ctx, "lnbc1", "02ab")
+	assert.NoError(t, err)
+	assert.Equal(t, Swap{
+		ID:                 "jkP3hq",
+		Address:            "bc1pswap",
+		BIP21:              "bitcoin:bc1pswap?amount=0.0000242",
+		ExpectedAmount:     2420,
+		TimeoutBlockHeight: 840_288,
+	}, swap)
+
+	status, err := provider.Status(ctx, swap.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusInvoicePaid, status)
+	assert.False(t, Final(status))
+
+	_, err = provider.Status(ctx, "unknown")
+	assert.ErrorContains(t, err, "boltz returned status 404: could not find swap with id: unknown")
+}
+
+func TestNew(t *testing.T) {
+	provider, err := New(config.Swaps{})
+	assert.NoError(t, err)
+	_, err = provider.Create(context.Background(), "lnbc1", "02ab")
+	assert.ErrorIs(t, err, ErrDisabled)
+
+	_, err = New(config.Swaps{Provider: "loop"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package harness
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	rpcUser     = "btry"
+	rpcPassword = "btry"
+	zmqBlock    = 28332
+	zmqTx       = 28333
+	walletName  = "harness"
+	// maturity is the number of blocks mined to spend the first coinbase output
+	maturity = 101
+)
+
+// Bitcoind is a regtest bitcoind node with a wallet used to mine blocks and fund the nodes.
+type Bitcoind struct {
+	container string
+	address   string
+}
+
+// startBitcoind runs a bitcoind container and mines the blocks needed to spend its coins.
+func startBitcoind(network, name string) (*Bitcoind, error) {
+	_, err := docker("run", "-d", "--name", name, "--network", network, bitcoindImage, "bitcoind",
+		"-regtest",
+		"-server",
+		"-txindex",
+		"-fallbackfee=0.0002",
+		"-rpcuser="+rpcUser,
+		"-rpcpassword="+rpcPassword,
+		"-rpcbind=0.0.0.0",
+		"-rpcallowip=0.0.0.0/0",
+		"-zmqpubrawblock=tcp://0.0.0.0:"+strconv.Itoa(zmqBlock),
+		"-zmqpubrawtx=tcp://0.0.0.0:"+strconv.Itoa(zmqTx),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "starting bitcoind")
+	}
+
+	b := &Bitcoind{container: name}
+
+	err = waitFor(defaultTimeout, func() (bool, error) {
+		_, err := b.cli("getblockchaininfo")
+		return err == nil, err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "waiting for bitcoind")
+	}
+
+	if _, err := b.cli("createwallet", walletName); err != nil {
+		return nil, errors.Wrap(err, "creating wallet")
+	}
+
+	address, err := b.cli("getnewaddress")
+	if err != nil {
+		return nil, errors.Wrap(err, "getting mining address")
+	}
+	b.address = address
+
+	if err := b.Mine(maturity); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Mine generates n blocks.
+func (b *Bitcoind) Mine(n int) error {
+	if _, err := b.cli("generatetoaddress", strconv.Itoa(n), b.address); err != nil {
+		return errors.Wrap(err, "mining blocks")
+	}
+	return nil
+}
+
+// Height returns the number of blocks in the chain.
+func (b *Bitcoind) Height() (uint32, error) {
+	out, err := b.cli("getblockcount")
+	if err != nil {
+		return 0, errors.Wrap(err, "getting block count")
+	}
+
+	height, err := strconv.ParseUint(out, 10, 32)
+	if err != nil {
+		return 0, errors.Wrap(err, "parsing block count")
+	}
+
+	return uint32(height), nil
+}
+
+// Fund sends the amount of bitcoin specified to the address.
+func (b *Bitcoind) Fund(address string, btc float64) error {
+	amount := strconv.FormatFloat(btc, 'f', 8, 64)
+	if _, err := b.cli("sendtoaddress", address, amount); err != nil {
+		return errors.Wrap(err, "funding address")
+	}
+	return nil
+}
+
+func (b *Bitcoind) cli(args ...string) (string, error) {
+	cmd := append([]string{"exec", b.container, "bitcoin-cli", "-regtest",
+		"-rpcuser=" + rpcUser, "-rpcpassword=" + rpcPassword}, args...)
+	out, err := docker(cmd...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
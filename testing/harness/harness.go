@@ -0,0 +1,282 @@
+// Package harness runs BTRY against a bitcoind node and two LND nodes in regtest, so features can be
+// tested end to end with real Lightning payments.
+//
+// The nodes run in docker containers attached to a network of their own, BTRY is built from the
+// module and runs as a separate process connected to Alice, the lottery node. Bob is the node of
+// the players, it has a channel open with Alice.
+//
+// The harness is only started when the BTRY_HARNESS environment variable is set, the tests using it
+// are skipped otherwise:
+//
+//	BTRY_HARNESS=1 go test ./testing/harness/ -run TestCycle -v
+package harness
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/pkg/errors"
+)
+
+// EnvVar is the environment variable that enables the harness.
+const EnvVar = "BTRY_HARNESS"
+
+const (
+	bitcoindImage = "polarlightning/bitcoind:26.0"
+	lndImage      = "lightninglabs/lnd:v0.17.4-beta"
+
+	// channelCapacity is the size of the channel Bob opens with Alice, half of it is pushed to Alice
+	// so she can pay the prizes
+	channelCapacity = 1_000_000
+	// pollInterval is how often the harness checks whether a condition was met
+	pollInterval = 500 * time.Millisecond
+	// defaultTimeout is how long the harness waits for a condition before failing the test
+	defaultTimeout = time.Minute
+)
+
+// Harness is a regtest environment running BTRY.
+type Harness struct {
+	t        testing.TB
+	Bitcoind *Bitcoind
+	// Alice is the node of the lottery
+	Alice *Node
+	// Bob is the node of the players
+	Bob     *Node
+	Server  *Server
+	network string
+	dir     string
+	// containers are removed when the test finishes
+	containers []string
+}
+
+// Option modifies the configuration BTRY is started with.
+type Option func(*config.Config)
+
+// WithDuration sets the lottery duration in blocks.
+func WithDuration(blocks uint32) Option {
+	return func(c *config.Config) {
+		c.Lottery.Duration = blocks
+		c.Lottery.Frequency = 0
+	}
+}
+
+// WithConfig lets the test modify any setting of the configuration.
+func WithConfig(f func(*config.Config)) Option {
+	return Option(f)
+}
+
+// New starts the nodes, funds the channel between Bob and Alice and runs BTRY. Everything is torn
+// down when the test finishes.
+//
+// The test is skipped if the harness is not enabled or docker is not available.
+func New(t testing.TB, opts ...Option) *Harness {
+	t.Helper()
+
+	if os.Getenv(EnvVar) == "" {
+		t.Skipf("regtest harness disabled, set %s to run it", EnvVar)
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("regtest harness requires docker")
+	}
+
+	suffix, err := randomSuffix()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Harness{
+		t:       t,
+		network: "btry-harness-" + suffix,
+		dir:     t.TempDir(),
+	}
+
+	if _, err := docker("network", "create", h.network); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(h.close)
+
+	h.containers = append(h.containers, "bitcoind-"+suffix)
+	h.Bitcoind, err = startBitcoind(h.network, "bitcoind-"+suffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.containers = append(h.containers, "alice-"+suffix)
+	h.Alice, err = startNode(h.network, "alice-"+suffix, h.dir, h.Bitcoind)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.containers = append(h.containers, "bob-"+suffix)
+	h.Bob, err = startNode(h.network, "bob-"+suffix, h.dir, h.Bitcoind)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.fundChannel(); err != nil {
+		t.Fatal(err)
+	}
+
+	h.Server, err = startServer(h.dir, h.Alice, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h
+}
+
+// MineBlocks mines n blocks and waits for both nodes to process them, BTRY may take a bit longer.
+func (h *Harness) MineBlocks(n int) {
+	h.t.Helper()
+
+	if err := h.Bitcoind.Mine(n); err != nil {
+		h.t.Fatal(err)
+	}
+
+	height, err := h.Bitcoind.Height()
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	for _, node := range []*Node{h.Alice, h.Bob} {
+		if err := node.WaitSynced(height); err != nil {
+			h.t.Fatal(err)
+		}
+	}
+}
+
+// MineUntil mines blocks until the chain reaches the height specified.
+func (h *Harness) MineUntil(height uint32) {
+	h.t.Helper()
+
+	current, err := h.Bitcoind.Height()
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	if current < height {
+		h.MineBlocks(int(height - current))
+	}
+}
+
+// Eventually fails the test if the condition doesn't return true within a minute.
+func (h *Harness) Eventually(condition func() (bool, error), msg string) {
+	h.t.Helper()
+
+	if err := waitFor(defaultTimeout, condition); err != nil {
+		h.t.Fatalf("%s: %v", msg, err)
+	}
+}
+
+// fundChannel sends coins to Bob and opens a channel with Alice, pushing half of its capacity.
+func (h *Harness) fundChannel() error {
+	address, err := h.Bob.NewAddress()
+	if err != nil {
+		return err
+	}
+
+	if err := h.Bitcoind.Fund(address, 1); err != nil {
+		return err
+	}
+	if err := h.Bitcoind.Mine(6); err != nil {
+		return err
+	}
+
+	err = waitFor(defaultTimeout, func() (bool, error) {
+		balance, err := h.Bob.ConfirmedBalance()
+		return balance > 0, err
+	})
+	if err != nil {
+		return errors.Wrap(err, "waiting for Bob's funds")
+	}
+
+	if err := h.Bob.Connect(h.Alice); err != nil {
+		return err
+	}
+
+	if err := h.Bob.OpenChannel(h.Alice, channelCapacity, channelCapacity/2); err != nil {
+		return err
+	}
+	if err := h.Bitcoind.Mine(6); err != nil {
+		return err
+	}
+
+	err = waitFor(defaultTimeout, func() (bool, error) {
+		aliceActive, err := h.Alice.ActiveChannels()
+		if err != nil {
+			return false, err
+		}
+		bobActive, err := h.Bob.ActiveChannels()
+		return aliceActive > 0 && bobActive > 0, err
+	})
+	return errors.Wrap(err, "waiting for the channel to be active")
+}
+
+// close stops BTRY and removes the containers and the network.
+func (h *Harness) close() {
+	if h.Server != nil {
+		if err := h.Server.stop(); err != nil {
+			h.t.Log(err)
+		}
+	}
+
+	for _, container := range h.containers {
+		if _, err := docker("rm", "-f", "-v", container); err != nil {
+			h.t.Log(err)
+		}
+	}
+
+	if _, err := docker("network", "rm", h.network); err != nil {
+		h.t.Log(err)
+	}
+}
+
+// docker runs a docker command and returns its standard output.
+func docker(args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("docker", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Errorf("docker %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// waitFor polls the condition until it returns true or the timeout expires.
+func waitFor(timeout time.Duration, condition func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		ok, err := condition()
+		if ok && err == nil {
+			return nil
+		}
+		lastErr = err
+
+		time.Sleep(pollInterval)
+	}
+
+	if lastErr != nil {
+		return errors.Wrap(lastErr, "timed out")
+	}
+	return errors.New("timed out")
+}
+
+func randomSuffix() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "generating random suffix")
+	}
+	return hex.EncodeToString(b), nil
+}
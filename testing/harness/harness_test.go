@@ -0,0 +1,73 @@
+package harness
+
+import (
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/crypto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfg := serverConfig(dir, "127.0.0.1:9000", "127.0.0.1:10009", "../../config/testdata/tls.cert",
+		"../../config/testdata/readonly.macaroon")
+	WithDuration(10)(&cfg)
+
+	path, err := writeConfig(dir, cfg)
+	assert.NoError(t, err)
+
+	loaded, err := config.Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, config.NetworkRegtest, loaded.Lightning.Network)
+	assert.Equal(t, uint32(10), loaded.Lottery.Duration)
+	assert.Equal(t, cfg.Server.Timeout, loaded.Server.Timeout)
+}
+
+func TestPlayerSignature(t *testing.T) {
+	player, err := NewPlayer()
+	assert.NoError(t, err)
+
+	assert.NoError(t, crypto.ValidatePublicKey(player.PublicKey))
+	assert.NoError(t, crypto.VerifySignature(player.PublicKey, player.Signature()))
+}
+
+// TestCycle places a bet, mines the blocks until the lottery is drawn and withdraws the prize.
+func TestCycle(t *testing.T) {
+	h := New(t)
+
+	player, err := NewPlayer()
+	assert.NoError(t, err)
+
+	lottery, err := h.Server.Lottery()
+	assert.NoError(t, err)
+
+	invoice, err := h.Server.Invoice(player, 10_000)
+	assert.NoError(t, err)
+	assert.NoError(t, h.Bob.PayInvoice(invoice))
+
+	h.MineUntil(lottery.NextHeight)
+
+	// The only player wins the prize pool
+	var prizes uint64
+	h.Eventually(func() (bool, error) {
+		prizes, err = h.Server.Prizes(player)
+		return prizes > 0, err
+	}, "waiting for the draw")
+
+	before, err := h.Bob.ChannelBalance()
+	assert.NoError(t, err)
+
+	fee := uint64(10)
+	withdrawal, err := h.Bob.AddInvoice(prizes - fee)
+	assert.NoError(t, err)
+
+	_, err = h.Server.Withdraw(player, withdrawal, fee)
+	assert.NoError(t, err)
+
+	h.Eventually(func() (bool, error) {
+		after, err := h.Bob.ChannelBalance()
+		return after == before+int64(prizes-fee), err
+	}, "waiting for the prize")
+}
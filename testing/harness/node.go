@@ -0,0 +1,220 @@
+package harness
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	lndRPCPort  = 10009
+	lndP2PPort  = 9735
+	lndDir      = "/root/.lnd"
+	macaroonDir = lndDir + "/data/chain/bitcoin/regtest"
+)
+
+// Node is a regtest LND node.
+type Node struct {
+	container string
+	// PublicKey is the identity key of the node
+	PublicKey    string
+	rpcAddress   string
+	tlsCertPath  string
+	macaroonPath string
+}
+
+type nodeInfo struct {
+	IdentityPubkey    string `json:"identity_pubkey"`
+	BlockHeight       uint32 `json:"block_height"`
+	NumActiveChannels uint32 `json:"num_active_channels"`
+	SyncedToChain     bool   `json:"synced_to_chain"`
+}
+
+// startNode runs an LND container connected to bitcoind and copies its credentials to dir.
+func startNode(network, name, dir string, bitcoind *Bitcoind) (*Node, error) {
+	_, err := docker("run", "-d", "--name", name, "--network", network,
+		"-p", "127.0.0.1::"+strconv.Itoa(lndRPCPort), lndImage,
+		"--noseedbackup",
+		"--alias="+name,
+		"--accept-keysend",
+		"--accept-amp",
+		"--bitcoin.active",
+		"--bitcoin.regtest",
+		"--bitcoin.node=bitcoind",
+		"--bitcoind.rpchost="+bitcoind.container,
+		"--bitcoind.rpcuser="+rpcUser,
+		"--bitcoind.rpcpass="+rpcPassword,
+		"--bitcoind.zmqpubrawblock=tcp://"+bitcoind.container+":"+strconv.Itoa(zmqBlock),
+		"--bitcoind.zmqpubrawtx=tcp://"+bitcoind.container+":"+strconv.Itoa(zmqTx),
+		"--rpclisten=0.0.0.0:"+strconv.Itoa(lndRPCPort),
+		"--listen=0.0.0.0:"+strconv.Itoa(lndP2PPort),
+		"--tlsextradomain="+name,
+		"--trickledelay=50",
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "starting %s", name)
+	}
+
+	n := &Node{container: name}
+
+	var info nodeInfo
+	err = waitFor(defaultTimeout, func() (bool, error) {
+		err := n.cli(&info, "getinfo")
+		return err == nil && info.SyncedToChain, err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "waiting for %s", name)
+	}
+	n.PublicKey = info.IdentityPubkey
+
+	out, err := docker("port", name, strconv.Itoa(lndRPCPort))
+	if err != nil {
+		return nil, errors.Wrap(err, "getting rpc port")
+	}
+	// Only the first line, docker lists IPv6 bindings as well
+	n.rpcAddress = strings.TrimSpace(strings.Split(string(out), "\n")[0])
+
+	n.tlsCertPath = filepath.Join(dir, name+".cert")
+	if _, err := docker("cp", name+":"+lndDir+"/tls.cert", n.tlsCertPath); err != nil {
+		return nil, errors.Wrap(err, "copying tls certificate")
+	}
+
+	n.macaroonPath = filepath.Join(dir, name+".macaroon")
+	if _, err := docker("cp", name+":"+macaroonDir+"/admin.macaroon", n.macaroonPath); err != nil {
+		return nil, errors.Wrap(err, "copying macaroon")
+	}
+
+	return n, nil
+}
+
+// WaitSynced waits until the node processed the block at the height specified.
+func (n *Node) WaitSynced(height uint32) error {
+	err := waitFor(defaultTimeout, func() (bool, error) {
+		var info nodeInfo
+		err := n.cli(&info, "getinfo")
+		return info.SyncedToChain && info.BlockHeight >= height, err
+	})
+	return errors.Wrapf(err, "waiting for %s to sync", n.container)
+}
+
+// NewAddress returns a new on-chain address of the node wallet.
+func (n *Node) NewAddress() (string, error) {
+	var resp struct {
+		Address string `json:"address"`
+	}
+	if err := n.cli(&resp, "newaddress", "p2wkh"); err != nil {
+		return "", errors.Wrap(err, "getting new address")
+	}
+	return resp.Address, nil
+}
+
+// ConfirmedBalance returns the confirmed on-chain balance of the node in satoshis.
+func (n *Node) ConfirmedBalance() (int64, error) {
+	var resp struct {
+		ConfirmedBalance int64 `json:"confirmed_balance,string"`
+	}
+	if err := n.cli(&resp, "walletbalance"); err != nil {
+		return 0, errors.Wrap(err, "getting wallet balance")
+	}
+	return resp.ConfirmedBalance, nil
+}
+
+// ChannelBalance returns the amount the node can send through its channels in satoshis.
+func (n *Node) ChannelBalance() (int64, error) {
+	var resp struct {
+		LocalBalance struct {
+			Sat int64 `json:"sat,string"`
+		} `json:"local_balance"`
+	}
+	if err := n.cli(&resp, "channelbalance"); err != nil {
+		return 0, errors.Wrap(err, "getting channel balance")
+	}
+	return resp.LocalBalance.Sat, nil
+}
+
+// ActiveChannels returns the number of channels that can be used to route payments.
+func (n *Node) ActiveChannels() (uint32, error) {
+	var info nodeInfo
+	if err := n.cli(&info, "getinfo"); err != nil {
+		return 0, errors.Wrap(err, "getting node information")
+	}
+	return info.NumActiveChannels, nil
+}
+
+// Connect connects the node to the peer.
+func (n *Node) Connect(peer *Node) error {
+	address := peer.PublicKey + "@" + peer.container + ":" + strconv.Itoa(lndP2PPort)
+	if err := n.cli(nil, "connect", address); err != nil {
+		return errors.Wrapf(err, "connecting to %s", peer.container)
+	}
+	return nil
+}
+
+// OpenChannel opens a channel with the peer, pushing the amount specified to it.
+func (n *Node) OpenChannel(peer *Node, capacity, push int64) error {
+	err := n.cli(nil, "openchannel",
+		"--node_key="+peer.PublicKey,
+		"--local_amt="+strconv.FormatInt(capacity, 10),
+		"--push_amt="+strconv.FormatInt(push, 10),
+	)
+	return errors.Wrapf(err, "opening channel with %s", peer.container)
+}
+
+// AddInvoice returns a new invoice of the amount specified.
+func (n *Node) AddInvoice(amountSat uint64) (string, error) {
+	var resp struct {
+		PaymentRequest string `json:"payment_request"`
+	}
+	if err := n.cli(&resp, "addinvoice", "--amt="+strconv.FormatUint(amountSat, 10)); err != nil {
+		return "", errors.Wrap(err, "adding invoice")
+	}
+	return resp.PaymentRequest, nil
+}
+
+// PayInvoice pays the invoice and waits for the payment to complete.
+func (n *Node) PayInvoice(invoice string) error {
+	out, err := docker("exec", n.container, "lncli", "--network=regtest", "payinvoice", "--force",
+		"--json", invoice)
+	if err != nil {
+		return errors.Wrap(err, "paying invoice")
+	}
+
+	// The payment updates are printed one after the other, the last one has the final status
+	var resp struct {
+		Status        string `json:"status"`
+		FailureReason string `json:"failure_reason"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for decoder.More() {
+		if err := decoder.Decode(&resp); err != nil {
+			return errors.Wrap(err, "decoding payment update")
+		}
+	}
+
+	if resp.Status != "SUCCEEDED" {
+		return errors.Errorf("payment %s: %s", strings.ToLower(resp.Status), resp.FailureReason)
+	}
+	return nil
+}
+
+// cli runs an lncli command in the container and decodes its JSON output into dst, if not nil.
+func (n *Node) cli(dst any, args ...string) error {
+	cmd := append([]string{"exec", n.container, "lncli", "--network=regtest"}, args...)
+	out, err := docker(cmd...)
+	if err != nil {
+		return err
+	}
+
+	if dst == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(out, dst); err != nil {
+		return errors.Wrap(err, "decoding lncli output")
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+package harness
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/http/api/handler"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultDuration is the number of blocks a lottery lasts unless the test configures it.
+const defaultDuration = 5
+
+// Server is a BTRY process connected to the lottery node.
+type Server struct {
+	cmd    *exec.Cmd
+	client *http.Client
+	logs   *os.File
+	url    string
+	// LogPath is the file the server output is written to
+	LogPath string
+}
+
+// Player is a public key placing bets and claiming prizes.
+type Player struct {
+	PublicKey  string
+	privateKey ed25519.PrivateKey
+}
+
+// NewPlayer returns a player with a random key.
+func NewPlayer() (Player, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return Player{}, errors.Wrap(err, "generating player key")
+	}
+
+	return Player{PublicKey: hex.EncodeToString(publicKey), privateKey: privateKey}, nil
+}
+
+// Signature returns the signature that proves the ownership of the public key.
+func (p Player) Signature() string {
+	publicKey, _ := hex.DecodeString(p.PublicKey)
+	return hex.EncodeToString(ed25519.Sign(p.privateKey, publicKey))
+}
+
+// startServer builds BTRY, writes its configuration to dir and runs it until the API responds.
+func startServer(dir string, node *Node, opts ...Option) (*Server, error) {
+	binary := filepath.Join(dir, "btry")
+	if err := build(binary); err != nil {
+		return nil, err
+	}
+
+	address, err := freeAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := serverConfig(dir, address, node.rpcAddress, node.tlsCertPath, node.macaroonPath)
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	configPath, err := writeConfig(dir, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		client:  &http.Client{Timeout: 30 * time.Second},
+		url:     "http://" + address,
+		LogPath: filepath.Join(dir, "btry.log"),
+	}
+
+	s.logs, err = os.Create(s.LogPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating log file")
+	}
+
+	s.cmd = exec.Command(binary)
+	s.cmd.Dir = dir
+	s.cmd.Env = append(os.Environ(), "BTRY_CONFIG="+configPath)
+	s.cmd.Stdout = s.logs
+	s.cmd.Stderr = s.logs
+	if err := s.cmd.Start(); err != nil {
+		s.logs.Close()
+		return nil, errors.Wrap(err, "starting server")
+	}
+
+	err = waitFor(defaultTimeout, func() (bool, error) {
+		_, err := s.Lottery()
+		return err == nil, err
+	})
+	if err != nil {
+		s.stop()
+		return nil, errors.Wrapf(err, "waiting for the server, see %s", s.LogPath)
+	}
+
+	return s, nil
+}
+
+// URL returns the base URL of the server.
+func (s *Server) URL() string {
+	return s.url
+}
+
+// Lottery returns the information of the lottery in progress.
+func (s *Server) Lottery() (handler.LotteryResponse, error) {
+	var resp handler.LotteryResponse
+	err := s.do(http.MethodGet, "/api/lottery", nil, "", &resp)
+	return resp, err
+}
+
+// Invoice returns an invoice to place a bet of the amount specified.
+func (s *Server) Invoice(player Player, amountSat uint64) (string, error) {
+	query := url.Values{"amount": {strconv.FormatUint(amountSat, 10)}}
+
+	var resp handler.InvoiceResponse
+	if err := s.do(http.MethodGet, "/api/invoice", query, player.PublicKey, &resp); err != nil {
+		return "", err
+	}
+	return resp.Invoice, nil
+}
+
+// Prizes returns the prizes the player can withdraw.
+func (s *Server) Prizes(player Player) (uint64, error) {
+	var resp handler.GetPrizesResponse
+	if err := s.do(http.MethodGet, "/api/prizes", nil, player.PublicKey, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Prizes, nil
+}
+
+// Withdraw pays the invoice with the player's prizes, the fee is the maximum routing fee.
+func (s *Server) Withdraw(player Player, invoice string, feeSat uint64) (handler.WithdrawResponse, error) {
+	query := url.Values{
+		"k1":     {player.Signature()},
+		"pubkey": {player.PublicKey},
+		"pr":     {invoice},
+		"fee":    {strconv.FormatUint(feeSat, 10)},
+	}
+
+	var resp handler.WithdrawResponse
+	err := s.do(http.MethodPost, "/api/withdraw", query, "", &resp)
+	return resp, err
+}
+
+// do sends a request to the API and decodes the response into dst, the public key is sent as the
+// bearer token if not empty.
+func (s *Server) do(method, path string, query url.Values, publicKey string, dst any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	endpoint := s.url + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	if publicKey != "" {
+		req.Header.Set("Authorization", "Bearer "+publicKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "%s %s", method, path)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return errors.Errorf("%s %s: status %d: %s", method, path, resp.StatusCode,
+			strings.TrimSpace(string(body)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(dst); err != nil {
+		return errors.Wrapf(err, "decoding %s response", path)
+	}
+
+	return nil
+}
+
+// stop interrupts the server and waits for it to exit.
+func (s *Server) stop() error {
+	defer s.logs.Close()
+
+	if err := s.cmd.Process.Signal(os.Interrupt); err != nil {
+		return errors.Wrap(err, "stopping server")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.cmd.Wait() }()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(10 * time.Second):
+		return s.cmd.Process.Kill()
+	}
+}
+
+// serverConfig returns the configuration of a server listening on address and connected to the
+// lottery node. Logs are written to standard error only.
+func serverConfig(dir, address, rpcAddress, tlsCertPath, macaroonPath string) config.Config {
+	var cfg config.Config
+	for i, logger := range []*config.Logger{
+		&cfg.API.Logger,
+		&cfg.DB.Logger,
+		&cfg.Lightning.Logger,
+		&cfg.Lottery.Logger,
+		&cfg.Server.Logger,
+	} {
+		logger.Label = []string{"API", "DB", "LND", "LTRY", "SRVR"}[i]
+		logger.Level = 2
+	}
+
+	cfg.API.RateLimiter.Tokens = 1_000
+	cfg.API.RateLimiter.Interval = time.Minute
+	cfg.DB.Path = filepath.Join(dir, "btry.db")
+	cfg.Lightning.Network = config.NetworkRegtest
+	cfg.Lightning.RPCAddress = rpcAddress
+	cfg.Lightning.TLSCertPath = tlsCertPath
+	cfg.Lightning.MacaroonPath = macaroonPath
+	cfg.Lightning.MaxFeePPM = 1_000
+	cfg.Lottery.Duration = defaultDuration
+	cfg.Server.Address = address
+	cfg.Server.Timeout.Read = 10 * time.Second
+	cfg.Server.Timeout.Write = time.Minute
+	cfg.Server.Timeout.Shutdown = 5 * time.Second
+	// Not used, the nodes are reached directly
+	cfg.Tor.Address = "127.0.0.1:9050"
+
+	return cfg
+}
+
+// writeConfig stores the configuration in dir and validates it the way the server will.
+func writeConfig(dir string, cfg config.Config) (string, error) {
+	path := filepath.Join(dir, "btry.yml")
+	content, err := yaml.Marshal(cfg)
+	if err != nil {
+		return "", errors.Wrap(err, "encoding configuration")
+	}
+
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		return "", errors.Wrap(err, "writing configuration file")
+	}
+
+	if _, err := config.Load(path); err != nil {
+		return "", errors.Wrap(err, "invalid configuration")
+	}
+
+	return path, nil
+}
+
+// build compiles the server from the module the harness belongs to.
+func build(output string) error {
+	out, err := exec.Command("go", "env", "GOMOD").Output()
+	if err != nil {
+		return errors.Wrap(err, "locating module")
+	}
+	moduleDir := filepath.Dir(strings.TrimSpace(string(out)))
+
+	cmd := exec.Command("go", "build", "-o", output, ".")
+	cmd.Dir = moduleDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Errorf("building server: %v: %s", err, out)
+	}
+
+	return nil
+}
+
+// freeAddress returns a local address that is not in use.
+func freeAddress() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", errors.Wrap(err, "finding free port")
+	}
+	defer listener.Close()
+
+	return fmt.Sprintf("127.0.0.1:%d", listener.Addr().(*net.TCPAddr).Port), nil
+}
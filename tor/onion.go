@@ -0,0 +1,97 @@
+package tor
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+
+	lndtor "github.com/lightningnetwork/lnd/tor"
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultHiddenServicePort is the port the onion service is reachable at if not configured.
+	defaultHiddenServicePort = 80
+	// keyFilePerm are the permissions of the file the onion service private key is stored in.
+	keyFilePerm = 0o600
+)
+
+// HiddenService exposes the API as an onion service, it lives as long as the connection to the Tor
+// control port.
+type HiddenService struct {
+	controller *lndtor.Controller
+	address    string
+}
+
+// NewHiddenService creates an onion service that forwards the connections to the server address.
+//
+// The private key of the service is persisted so it keeps the same address across restarts.
+func NewHiddenService(config config.Tor, serverAddress string) (*HiddenService, error) {
+	_, port, err := net.SplitHostPort(serverAddress)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing server address")
+	}
+
+	targetPort, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing server port")
+	}
+
+	virtualPort := config.HiddenService.Port
+	if virtualPort == 0 {
+		virtualPort = defaultHiddenServicePort
+	}
+
+	controller := lndtor.NewController(config.ControlAddress, config.HiddenService.TargetAddress,
+		config.ControlPassword)
+	if err := controller.Start(); err != nil {
+		return nil, errors.Wrap(err, "connecting to the tor control port")
+	}
+
+	onionAddr, err := controller.AddOnion(lndtor.AddOnionConfig{
+		Type:        lndtor.V3,
+		VirtualPort: virtualPort,
+		TargetPorts: []int{targetPort},
+		Store:       lndtor.NewOnionFile(config.HiddenService.KeyPath, keyFilePerm, false, nil),
+	})
+	if err != nil {
+		controller.Stop()
+		return nil, errors.Wrap(err, "creating onion service")
+	}
+
+	return &HiddenService{
+		controller: controller,
+		address:    onionAddr.String(),
+	}, nil
+}
+
+// Address returns the onion address of the service.
+func (h *HiddenService) Address() string {
+	return h.address
+}
+
+// Close removes the onion service and disconnects from the control port.
+func (h *HiddenService) Close() error {
+	return h.controller.Stop()
+}
+
+// Dialer returns a function that opens connections through the Tor proxy, onion addresses
+// included.
+func Dialer(config config.Tor) func(ctx context.Context, address string) (net.Conn, error) {
+	return func(ctx context.Context, address string) (net.Conn, error) {
+		timeout := config.Timeout
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+		}
+
+		conn, err := lndtor.Dial(address, config.Address, false, false, timeout)
+		if err != nil {
+			return nil, errors.Wrap(err, "dialing through tor")
+		}
+
+		return conn, nil
+	}
+}
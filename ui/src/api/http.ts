@@ -125,7 +125,7 @@ const parseErr = async (res: Response): Promise<string> => {
 	}
 	const err: ErrResponse | LNURLErrorResponse = await res.json()
 	if ("error" in err) {
-		return err.error
+		return err.error.message
 	} else {
 		return err.reason
 	}
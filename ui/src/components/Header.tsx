@@ -1,4 +1,4 @@
-import { Component, Show, createSignal } from 'solid-js';
+import { Component, Show, createResource, createSignal } from 'solid-js';
 import { useI18n } from "@solid-primitives/i18n";
 import Dismiss from "solid-dismiss";
 
@@ -6,6 +6,7 @@ import styles from "./Header.module.css";
 import logo from "../assets/icons/logo.svg"
 import angleDownIcon from "../assets/icons/angle_down.svg"
 import { useAuthContext } from "../context/AuthContext";
+import { useAPIContext } from "../context/APIContext";
 import Menu from "./Menu";
 import { A, RouteSectionProps } from "@solidjs/router";
 
@@ -15,12 +16,22 @@ const Header: Component<RouteSectionProps<unknown>> = (props) => {
 	let boxRef: HTMLButtonElement
 
 	const [auth] = useAuthContext()
+	const api = useAPIContext()
 	const [t] = useI18n()
 
 	const [showMenu, setShowMenu] = createSignal(false)
 
+	const getNetwork = async (): Promise<string> => {
+		const info = await api.GetLottery()
+		return info.network || "mainnet"
+	}
+	const [network] = createResource<string>(getNetwork)
+
 	return (
 		<>
+			<Show when={network() && network() !== "mainnet"}>
+				<div class={styles.network}>{t("test_network", { network: network()! })}</div>
+			</Show>
 			<nav class={styles.header}>
 				<div class={styles.container}>
 					<div class={styles.brand}>
@@ -43,6 +43,8 @@ export const dict = {
 		required: "Required",
 		target_block_height: "Target block height",
 		set: "Set",
+		test_network: "Running on {{ network }}, its coins have no value",
+		last_ticket_bonus: "The last {{ tickets }} tickets sold enter a bonus draw for {{ percentage }}% of the pool",
 	},
 	de: {
 		bet: "Wetten",
@@ -86,6 +88,8 @@ export const dict = {
 		required: "Erforderlich",
 		target_block_height: "Zielblockhöhe",
 		set: "Satz",
+		test_network: "Läuft auf {{ network }}, die Coins haben keinen Wert",
+		last_ticket_bonus: "Die letzten {{ tickets }} verkauften Tickets nehmen an einer Bonusziehung über {{ percentage }}% des Pools teil",
 	},
 	es: {
 		bet: "Apostar",
@@ -129,6 +133,8 @@ export const dict = {
 		required: "Requerida",
 		target_block_height: "Altura del bloque objetivo",
 		set: "Colocar",
+		test_network: "Funcionando en {{ network }}, sus monedas no tienen valor",
+		last_ticket_bonus: "Los últimos {{ tickets }} tickets vendidos participan en un sorteo extra del {{ percentage }}% del pozo",
 	},
 	fr: {
 		bet: "Pari",
@@ -172,6 +178,8 @@ export const dict = {
 		required: "Requis",
 		target_block_height: "Hauteur du bloc cible",
 		set: "Ensemble",
+		test_network: "Fonctionne sur {{ network }}, ses pièces n'ont aucune valeur",
+		last_ticket_bonus: "Les {{ tickets }} derniers tickets vendus participent à un tirage bonus de {{ percentage }}% de la cagnotte",
 	},
 	it: {
 		bet: "Scommettere",
@@ -215,6 +223,8 @@ export const dict = {
 		required: "Necessaria",
 		target_block_height: "Altezza del blocco target",
 		set: "Imposta",
+		test_network: "In esecuzione su {{ network }}, le sue monete non hanno valore",
+		last_ticket_bonus: "Gli ultimi {{ tickets }} biglietti venduti partecipano a un'estrazione bonus del {{ percentage }}% del montepremi",
 	},
 	pr: {
 		bet: "Aposta",
@@ -258,6 +268,8 @@ export const dict = {
 		required: "Obrigatória",
 		target_block_height: "Altura do bloco alvo",
 		set: "Definir",
+		test_network: "Executando em {{ network }}, suas moedas não têm valor",
+		last_ticket_bonus: "Os últimos {{ tickets }} bilhetes vendidos participam de um sorteio bônus de {{ percentage }}% do prêmio",
 	},
 	jp: {
 		bet: "賭ける",
@@ -301,6 +313,8 @@ export const dict = {
 		required: "必須",
 		target_block_height: "ターゲットブロックの高さ",
 		set: "セット",
+		test_network: "{{ network }} で稼働中です。このコインに価値はありません",
+		last_ticket_bonus: "最後に販売された {{ tickets }} 枚のチケットは、プールの {{ percentage }}% のボーナス抽選に参加します",
 	},
 	ch: { /* Traditional */
 		bet: "打賭",
@@ -344,6 +358,8 @@ export const dict = {
 		required: "必需的",
 		target_block_height: "目標塊高度",
 		set: "放",
+		test_network: "运行在 {{ network }} 上，其币没有价值",
+		last_ticket_bonus: "最后售出的 {{ tickets }} 张彩票将参与奖池 {{ percentage }}% 的额外抽奖",
 	},
 	ru: {
 		bet: "Ставка",
@@ -387,6 +403,8 @@ export const dict = {
 		required: "Необходимый",
 		target_block_height: "Целевой блок высота",
 		set: "Установить",
+		test_network: "Работает в сети {{ network }}, её монеты не имеют ценности",
+		last_ticket_bonus: "Последние {{ tickets }} проданных билетов участвуют в бонусном розыгрыше {{ percentage }}% пула",
 	}
 };
 
@@ -71,6 +71,9 @@ const Bet: Component = () => {
 				if (payload.status === Status.Success) {
 					toast.success(t("bet_sent"), { duration: 3000 })
 					setShowInvoice(false)
+				} else if (payload.error) {
+					toast.error(payload.error, { duration: 5000 })
+					setShowInvoice(false)
 				}
 				// Remove payment ID from the array
 				setPaymentIDs(paymentIDs.filter(id => id !== payload.payment_id))
@@ -35,6 +35,7 @@ const Home: Component = () => {
 
 			if (payload.capacity !== undefined && payload.prize_pool !== undefined) {
 				const updatedInfo: LotteryInfo = {
+					...info(),
 					prize_pool: payload.prize_pool,
 					capacity: payload.capacity,
 					next_height: nextHeight()
@@ -52,9 +53,9 @@ const Home: Component = () => {
 			const inf = info()
 			if (inf) {
 				infoOptions.mutate({
+					...inf,
 					prize_pool: inf.prize_pool + payload.amount,
 					capacity: inf.capacity - payload.amount,
-					next_height: inf.next_height
 				})
 			}
 		})
@@ -72,6 +73,16 @@ const Home: Component = () => {
 				<div class={styles.info} >
 					<p class={`${styles.text} ${styles.prize_pool}`}>{t("prize_pool")}</p>
 					<Sats num={info()?.prize_pool} fontSize="2.813rem" fontWeight="700" />
+					<Show when={info()?.last_ticket}>
+						{(lastTicket) => (
+							<p class={`${styles.text} ${styles.last_ticket}`}>
+								{t("last_ticket_bonus", {
+									tickets: BeautifyNumber(lastTicket().tickets),
+									percentage: lastTicket().percentage.toString()
+								})}
+							</p>
+						)}
+					</Show>
 				</div>
 				<div class={styles.secondary}>
 					<div class={styles.info}>
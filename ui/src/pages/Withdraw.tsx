@@ -43,6 +43,12 @@ const Withdraw: Component = () => {
 	}
 	const [prizes, prizesOptions] = createResource<number>(getPrizes)
 
+	const getInvoicePrefix = async (): Promise<string> => {
+		const info = await api.GetLottery()
+		return info.invoice_prefix || "lnbc"
+	}
+	const [invoicePrefix] = createResource<string>(getInvoicePrefix)
+
 	const getLNURLWithdraw = async (): Promise<string> => {
 		const signature = await Sign(auth().privateKey, auth().publicKey)
 		const url = getLNURLWithdrawURL(auth().publicKey, signature)
@@ -72,7 +78,7 @@ const Withdraw: Component = () => {
 
 		let inv: Invoice
 		try {
-			inv = ValidateInvoice(invoice(), undefined, availablePrizes - fee())
+			inv = ValidateInvoice(invoice(), invoicePrefix() || "lnbc", undefined, availablePrizes - fee())
 
 			if (payments.some(payment => payment.hash === inv.paymentHash)) {
 				throw Error("already used")
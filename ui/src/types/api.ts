@@ -3,7 +3,12 @@ import { LotteryInfo } from "./lottery"
 import { Winner } from "./winners"
 
 export type ErrResponse = {
-	readonly error: string
+	readonly error: {
+		readonly code: string
+		readonly message: string
+		readonly retryable: boolean
+		readonly details?: { [key: string]: unknown }
+	}
 }
 
 export type LNURLErrorResponse = {
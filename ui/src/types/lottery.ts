@@ -2,4 +2,12 @@ export type LotteryInfo = {
 	readonly prize_pool: number
 	readonly capacity: number
 	readonly next_height: number
+	readonly network?: string
+	readonly invoice_prefix?: string
+	readonly last_ticket?: LastTicketBonus
+}
+
+export type LastTicketBonus = {
+	readonly tickets: number
+	readonly percentage: number
 }
@@ -0,0 +1,760 @@
+// Code generated by go generate; DO NOT EDIT.
+
+export type Archive = {
+	readonly destination: string
+	readonly reference: string
+	readonly hash: string
+	readonly id: number
+	readonly created_at: number
+	readonly first_height: number
+	readonly last_height: number
+}
+
+export type ArchivesResponse = {
+	readonly archives?: Archive[]
+}
+
+export type Bet = {
+	readonly public_key?: string
+	readonly pool?: string
+	readonly first_ticket?: number
+	readonly index?: number
+	readonly tickets?: number
+	readonly bonus?: number
+	readonly promo?: string
+	readonly house?: boolean
+}
+
+export type BetArchiveResponse = {
+	readonly commitment: Commitment
+	readonly bets: Bet[]
+	readonly tiers?: Tiers[]
+}
+
+export type BetResponse = {
+	readonly fiat?: { [key: string]: number }
+	readonly public_key?: string
+	readonly pool?: string
+	readonly first_ticket?: number
+	readonly index?: number
+	readonly tickets?: number
+	readonly bonus?: number
+	readonly promo?: string
+	readonly house?: boolean
+}
+
+export type BetsResponse = {
+	readonly bets?: BetResponse[]
+}
+
+export type Bundle = {
+	readonly kind: string
+	readonly public_key: string
+	readonly server_public_key: string
+	readonly receipt?: Receipt
+	readonly prizes?: Winner[]
+	readonly block_hash?: string
+	readonly server_seed?: string
+	readonly instructions: string[]
+	readonly tickets: number
+	readonly amount: number
+	readonly issued_at: number
+	readonly round: number
+}
+
+export type CancelBetResponse = {
+	readonly refund: number
+}
+
+export type Channel = {
+	readonly channel_point: string
+	readonly capacity: number
+	readonly local_balance: number
+}
+
+export type Commitment = {
+	readonly commitment: string
+	readonly seed?: string
+	readonly block_hash?: string
+	readonly collision?: string
+	readonly height: number
+}
+
+export type DigestNotificationsResponse = {
+	readonly success?: boolean
+}
+
+export type Error = {
+	readonly code: string
+	readonly message: string
+	readonly retryable: boolean
+	readonly details?: { [key: string]: unknown }
+}
+
+export type ErrorResponse = {
+	readonly error?: Error
+	readonly status?: string
+	readonly reason?: string
+}
+
+export type ExclusionResponse = {
+	readonly excluded_until: number
+}
+
+export type FairnessReport = {
+	readonly winning_positions: WinningPosition[]
+	readonly gini: number
+	readonly players: number
+	readonly tickets: number
+	readonly lottery_height: number
+}
+
+export type FairnessResponse = {
+	readonly reports?: FairnessReport[]
+}
+
+export type GetLightningAddressResponse = {
+	readonly address?: string
+	readonly has_address?: boolean
+}
+
+export type GetPrizesResponse = {
+	readonly fiat?: { [key: string]: number }
+	readonly claimable: Prize[]
+	readonly prizes: number
+}
+
+export type HeightsResponse = {
+	readonly heights: number[]
+}
+
+export type IdentityResponse = {
+	readonly public_key: string
+}
+
+export type InvoiceResponse = {
+	readonly invoice?: string
+	readonly payment_id?: number
+	readonly claim_code?: string
+	readonly swap?: Swap
+}
+
+export type InvoiceSwapResponse = {
+	readonly id: string
+	readonly payment_hash: string
+	readonly address: string
+	readonly status: string
+	readonly expected_amount: number
+	readonly amount: number
+	readonly timeout_block_height: number
+	readonly updated_at: number
+}
+
+export type LNURLPayParams = {
+	readonly status?: string
+	readonly reason?: string
+	readonly callback: string
+	readonly tag: string
+	readonly maxSendable: number
+	readonly minSendable: number
+	readonly metadata: string
+	readonly commentAllowed: number
+	readonly payerData?: PayerDataSpec
+}
+
+export type LNURLPayValues = {
+	readonly status?: string
+	readonly reason?: string
+	readonly successAction?: SuccessAction
+	readonly routes: unknown
+	readonly pr: string
+	readonly disposable?: boolean
+}
+
+export type LNURLWithdrawResponse = {
+	readonly status?: string
+	readonly reason?: string
+	readonly tag: string
+	readonly k1: string
+	readonly callback: string
+	readonly maxWithdrawable: number
+	readonly minWithdrawable: number
+	readonly defaultDescription: string
+	readonly balanceCheck?: string
+	readonly payLink?: string
+}
+
+export type LastTicketBonus = {
+	readonly tickets: number
+	readonly percentage: number
+}
+
+export type Limit = {
+	readonly kind: string
+	readonly amount: number
+	readonly effective_at: number
+}
+
+export type LimitsResponse = {
+	readonly limits: Limit[]
+	readonly excluded_until?: number
+}
+
+export type LotteryResponse = {
+	readonly fiat?: { [key: string]: number }
+	readonly network: string
+	readonly invoice_prefix: string
+	readonly last_ticket?: LastTicketBonus
+	readonly house_play: string
+	readonly postponement?: Postponement
+	readonly pools: PoolInfo[]
+	readonly prize_pool: number
+	readonly capacity: number
+	readonly next_height: number
+}
+
+export type MaintenanceStatus = {
+	readonly from?: number
+	readonly until?: number
+	readonly active: boolean
+}
+
+export type NostrNotificationsResponse = {
+	readonly success?: boolean
+}
+
+export type NotificationDelivery = {
+	readonly public_key?: string
+	readonly channel: string
+	readonly status: string
+	readonly error?: string
+	readonly message?: string
+	readonly id: number
+	readonly attempts: number
+	readonly retry_until?: number
+	readonly created_at: number
+	readonly updated_at: number
+}
+
+export type NotificationsResponse = {
+	readonly nostr?: string
+	readonly deliveries?: NotificationDelivery[]
+	readonly telegram?: boolean
+	readonly digest?: boolean
+}
+
+export type PayerDataItemSpec = {
+	readonly mandatory: boolean
+}
+
+export type PayerDataKeyAuthSpec = {
+	readonly mandatory: boolean
+	readonly k1: string
+}
+
+export type PayerDataSpec = {
+	readonly name?: PayerDataItemSpec
+	readonly pubkey?: PayerDataItemSpec
+	readonly identifier?: PayerDataItemSpec
+	readonly email?: PayerDataItemSpec
+	readonly auth?: PayerDataKeyAuthSpec
+}
+
+export type Payout = {
+	readonly public_key: string
+	readonly payment_hash: string
+	readonly status: string
+	readonly failure_reason?: string
+	readonly preimage?: string
+	readonly id: number
+	readonly amount: number
+	readonly fee: number
+	readonly created_at: number
+	readonly updated_at: number
+}
+
+export type PayoutsResponse = {
+	readonly payouts: Payout[]
+}
+
+export type PoolInfo = {
+	readonly name?: string
+	readonly prize_pool: number
+	readonly capacity: number
+	readonly min_amount?: number
+	readonly max_amount?: number
+}
+
+export type Postponement = {
+	readonly block_hash: string
+	readonly lottery_height: number
+	readonly postponed_at: number
+	readonly resume_height: number
+	readonly attempts: number
+	readonly resumed_at?: number
+}
+
+export type Privacy = {
+	readonly display: string
+	readonly alias?: string
+}
+
+export type Prize = {
+	readonly lottery_height: number
+	readonly amount: number
+}
+
+export type Receipt = {
+	readonly public_key: string
+	readonly payment_hash: string
+	readonly signature: string
+	readonly first_ticket: number
+	readonly last_ticket: number
+	readonly timestamp: number
+	readonly bonus_tickets?: number
+	readonly round: number
+}
+
+export type ReceiptBundleResponse = {
+	readonly message: string
+	readonly signature: string
+	readonly pdf?: string
+	readonly bundle: Bundle
+}
+
+export type ReservesResponse = {
+	readonly public_key: string
+	readonly message: string
+	readonly signature: string
+	readonly node_public_key: string
+	readonly node_signature: string
+	readonly channels: Channel[]
+	readonly timestamp: number
+	readonly unclaimed_prizes: number
+	readonly prize_pool: number
+	readonly liabilities: number
+	readonly local_balance: number
+	readonly height: number
+}
+
+export type Reveal = {
+	readonly results_hash: string
+	readonly salt?: string
+	readonly lottery_height: number
+	readonly tiers: number
+	readonly hidden: number
+	readonly blocks: number
+}
+
+export type RoundStats = {
+	readonly height: number
+	readonly prize_pool: number
+	readonly players: number
+	readonly winners: number
+	readonly hidden?: boolean
+}
+
+export type RoundsResponse = {
+	readonly rounds?: RoundStats[]
+}
+
+export type SetLightningAddressResponse = {
+	readonly success?: boolean
+}
+
+export type Stats = {
+	readonly rounds: number
+	readonly total_pool: number
+	readonly average_pool: number
+	readonly total_paid_out: number
+	readonly payouts: number
+}
+
+export type StatsResponse = {
+	readonly stats: Stats
+}
+
+export type Streak = {
+	readonly length: number
+	readonly end_height: number
+}
+
+export type StreaksResponse = {
+	readonly streaks?: Streak[]
+}
+
+export type SuccessAction = {
+	readonly tag: string
+	readonly description?: string
+	readonly url?: string
+	readonly message?: string
+	readonly ciphertext?: string
+	readonly iv?: string
+}
+
+export type Swap = {
+	readonly id: string
+	readonly address: string
+	readonly bip21: string
+	readonly expected_amount: number
+	readonly timeout_block_height: number
+}
+
+export type SwapClaimResponse = {
+	readonly payment_hash: string
+}
+
+export type SwapPaymentResponse = {
+	readonly message: string
+	readonly signature: string
+	readonly status?: string
+	readonly payment_id?: number
+	readonly payment_ids?: number[]
+	readonly approval_ids?: number[]
+}
+
+export type SwapPreimageResponse = {
+	readonly preimage: string
+}
+
+export type TicketResponse = {
+	readonly public_key: string
+}
+
+export type Tiers = {
+	readonly pool: string
+	readonly participants: number
+	readonly tiers: number
+}
+
+export type Token = {
+	readonly id: string
+	readonly token: string
+	readonly expires_at: number
+}
+
+export type VerifyReceiptResponse = {
+	readonly public_key: string
+	readonly error?: string
+	readonly valid: boolean
+}
+
+export type Win = {
+	readonly height: number
+	readonly prize: number
+}
+
+export type Winner = {
+	readonly public_key?: string
+	readonly prize?: number
+	readonly ticket?: number
+	readonly id?: number
+	readonly lottery_height?: number
+	readonly status?: string
+	readonly pool?: string
+	readonly claim_deadline?: number
+	readonly alias?: string
+}
+
+export type WinnersResponse = {
+	readonly reveal?: Reveal
+	readonly winners?: Winner[]
+}
+
+export type WinnersSearchResponse = {
+	readonly winners?: Winner[]
+}
+
+export type WinningPosition = {
+	readonly pool?: string
+	readonly ticket: number
+	readonly prize_pool: number
+	readonly position: number
+}
+
+export type WinsResponse = {
+	readonly wins?: Win[]
+}
+
+export type WithdrawResponse = {
+	readonly status?: string
+	readonly payment_id?: number
+	readonly payment_ids?: number[]
+	readonly approval_ids?: number[]
+}
+
+export type GetArchivesParams = {
+	readonly offset?: number
+	readonly limit?: number
+	readonly reverse?: boolean
+}
+
+export type GetArchivesResponse = ArchivesResponse
+
+export type GetBetsParams = {
+	readonly height: number
+	readonly offset?: number
+	readonly limit?: number
+	readonly reverse?: boolean
+	readonly pool?: string
+}
+
+export type GetBetsResponse = BetsResponse
+
+export type CancelBetParams = {
+	readonly payment_hash: string
+	readonly signature: string
+}
+
+export type GetClaimParams = {
+	readonly code: string
+}
+
+export type GetClaimResponse = GetPrizesResponse
+
+export type ClaimParams = {
+	readonly code: string
+	readonly pr: string[]
+	readonly fee?: number[]
+}
+
+export type ClaimResponse = WithdrawResponse
+
+export type StartSwapClaimParams = {
+	readonly signature: string
+}
+
+export type StartSwapClaimResponse = SwapClaimResponse
+
+export type CountersignSwapClaimParams = {
+	readonly payment_hash: string
+	readonly countersignature: string
+	readonly signature: string
+}
+
+export type CountersignSwapClaimResponse = SwapPreimageResponse
+
+export type IssueClaimTokenParams = {
+	readonly origin: string
+	readonly signature: string
+}
+
+export type IssueClaimTokenResponse = Token
+
+export type GetWidgetClaimParams = {
+	readonly token: string
+}
+
+export type GetWidgetClaimResponse = GetPrizesResponse
+
+export type WidgetClaimParams = {
+	readonly token: string
+	readonly pr: string[]
+	readonly fee?: number[]
+	readonly confirmation: string
+}
+
+export type WidgetClaimResponse = WithdrawResponse
+
+export type PaySwapClaimParams = {
+	readonly pr: string
+	readonly fee?: number
+	readonly signature: string
+}
+
+export type PaySwapClaimResponse = SwapPaymentResponse
+
+export type GetHeightsParams = {
+	readonly offset?: number
+	readonly limit?: number
+	readonly reverse?: boolean
+}
+
+export type GetHeightsResponse = HeightsResponse
+
+export type GetIdentityResponse = IdentityResponse
+
+export type GetInvoiceParams = {
+	readonly amount: number
+	readonly anonymous?: boolean
+	readonly swap?: boolean
+	readonly refund_public_key?: string
+}
+
+export type GetInvoiceResponse = InvoiceResponse
+
+export type GetInvoiceSwapParams = {
+	readonly id: string
+}
+
+export type GetInvoiceSwapResponse = InvoiceSwapResponse
+
+export type GetLotteryResponse = LotteryResponse
+
+export type GetBetArchiveParams = {
+	readonly height: number
+}
+
+export type GetBetArchiveResponse = BetArchiveResponse
+
+export type GetCommitmentParams = {
+	readonly height?: number
+}
+
+export type GetCommitmentResponse = Commitment
+
+export type GetFairnessParams = {
+	readonly offset?: number
+	readonly limit?: number
+	readonly reverse?: boolean
+}
+
+export type GetFairnessResponse = FairnessResponse
+
+export type SetLightningAddressParams = {
+	readonly address: string
+}
+
+export type LNURLWithdrawParams = {
+	readonly pubkey: string
+	readonly signature: string
+}
+
+export type LNURLPayResponse = LNURLPayParams
+
+export type LNURLPayCallbackParams = {
+	readonly amount: number
+	readonly comment?: string
+}
+
+export type LNURLPayCallbackResponse = LNURLPayValues
+
+export type GetLimitsResponse = LimitsResponse
+
+export type SetLimitParams = {
+	readonly kind: string
+	readonly amount: number
+	readonly signature: string
+}
+
+export type SetLimitResponse = Limit
+
+export type ExcludeParams = {
+	readonly days: number
+	readonly signature: string
+}
+
+export type ExcludeResponse = ExclusionResponse
+
+export type GetMaintenanceResponse = MaintenanceStatus
+
+export type GetNotificationsResponse = NotificationsResponse
+
+export type SetDigestNotificationsParams = {
+	readonly signature: string
+}
+
+export type SetDigestNotificationsResponse = DigestNotificationsResponse
+
+export type DeleteDigestNotificationsParams = {
+	readonly signature: string
+}
+
+export type DeleteDigestNotificationsResponse = DigestNotificationsResponse
+
+export type SetNostrNotificationsParams = {
+	readonly npub: string
+	readonly signature: string
+}
+
+export type SetNostrNotificationsResponse = NostrNotificationsResponse
+
+export type DeleteNostrNotificationsParams = {
+	readonly signature: string
+}
+
+export type DeleteNostrNotificationsResponse = NostrNotificationsResponse
+
+export type GetPayoutsParams = {
+	readonly offset?: number
+	readonly limit?: number
+	readonly reverse?: boolean
+}
+
+export type GetPayoutsResponse = PayoutsResponse
+
+export type GetPrivacyResponse = Privacy
+
+export type SetPrivacyParams = {
+	readonly display: string
+	readonly alias?: string
+	readonly signature: string
+}
+
+export type SetPrivacyResponse = Privacy
+
+export type GetReceiptBundleParams = {
+	readonly payment_hash?: string
+	readonly height?: number
+	readonly pdf?: boolean
+	readonly signature: string
+}
+
+export type GetReceiptBundleResponse = ReceiptBundleResponse
+
+export type GetReservesResponse = ReservesResponse
+
+export type GetStatsResponse = StatsResponse
+
+export type GetRoundStatsParams = {
+	readonly offset?: number
+	readonly limit?: number
+	readonly reverse?: boolean
+}
+
+export type GetRoundStatsResponse = RoundsResponse
+
+export type GetStreaksParams = {
+	readonly limit?: number
+}
+
+export type GetStreaksResponse = StreaksResponse
+
+export type GetBiggestWinsParams = {
+	readonly limit?: number
+}
+
+export type GetBiggestWinsResponse = WinsResponse
+
+export type GetTicketParams = {
+	readonly height: number
+	readonly ticket: number
+	readonly pool?: string
+}
+
+export type GetTicketResponse = TicketResponse
+
+export type GetWinnersParams = {
+	readonly height: number
+}
+
+export type GetWinnersResponse = WinnersResponse
+
+export type SearchWinnersParams = {
+	readonly pubkey?: string
+	readonly from?: number
+	readonly to?: number
+	readonly status?: string
+	readonly offset?: number
+	readonly limit?: number
+	readonly reverse?: boolean
+}
+
+export type SearchWinnersResponse = WinnersSearchResponse
+
+export type WithdrawParams = {
+	readonly pubkey: string
+	readonly k1: string
+	readonly pr: string[]
+	readonly fee?: number[]
+}
@@ -2,7 +2,6 @@ import { bech32 } from "@scure/base";
 // @ts-ignore
 import { decode } from "light-bolt11-decoder";
 
-const errInvalidNetwork = Error(`network must be ${import.meta.env.VITE_NETWORK}`)
 const errExpiredInvoice = Error("already expired")
 const errInvalidAmount = Error("invalid amount")
 const errAmountTooHigh = Error("amount is higher than available prizes")
@@ -47,16 +46,29 @@ export const ValidateLightningAddress = (address: string) => {
 	}
 }
 
+/**
+ * InvoicePrefix returns the letters preceding the amount of an invoice, which identify its network.
+ * 
+ * @param payReq payment request
+ * @returns invoice prefix
+ */
+export const InvoicePrefix = (payReq: string): string => {
+	const match = payReq.toLowerCase().match(/^(?:lightning:)?([a-z]+)/)
+	return match ? match[1] : ""
+}
+
 /**
  * ValidateInvoice throws an error if the provided invoice is invalid.
  * 
  * @param payReq payment request
+ * @param prefix prefix of the invoices of the lottery network
  * @param targetAmount exact invoice amount in sats expected
  * @param maxAmount maximum invoice amount in sats expected
  */
-export const ValidateInvoice = (payReq: string, targetAmount?: number, maxAmount?: number): Invoice => {
-	if (!payReq.startsWith(import.meta.env.VITE_BOLT11_PREFIX)) {
-		throw errInvalidNetwork
+export const ValidateInvoice = (payReq: string, prefix: string, targetAmount?: number, maxAmount?: number): Invoice => {
+	// Prefixes can't be compared directly as some contain others (lnbc and lnbcrt)
+	if (InvoicePrefix(payReq) !== prefix) {
+		throw Error(`network must be the lottery's, invoices start with ${prefix}`)
 	}
 
 	try {
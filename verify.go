@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+
+	"github.com/pkg/errors"
+)
+
+// verifyDBCommand is the argument that runs the database integrity checks instead of the server.
+const verifyDBCommand = "verify-db"
+
+// verifyDB checks every invariant of the database and prints the violations found. With -repair,
+// the fixes available are offered one by one and applied once confirmed, -yes confirms all of them.
+//
+// An error is returned if any violation is left.
+func verifyDB(config config.DB, args []string, in io.Reader, out io.Writer) error {
+	flags := flag.NewFlagSet(verifyDBCommand, flag.ContinueOnError)
+	flags.SetOutput(out)
+	repair := flags.Bool("repair", false, "offer the repairs available")
+	yes := flags.Bool("yes", false, "apply the repairs without asking")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	database, err := db.Open(config)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	violations, err := database.Verify(true)
+	if err != nil {
+		return err
+	}
+
+	if len(violations) == 0 {
+		fmt.Fprintln(out, "No violations found")
+		return nil
+	}
+
+	answers := bufio.NewScanner(in)
+	left := 0
+	for _, v := range violations {
+		fmt.Fprintln(out, v)
+
+		if v.Repair == "" {
+			fmt.Fprintln(out, "  must be fixed manually")
+			left++
+			continue
+		}
+
+		if !*repair {
+			fmt.Fprintf(out, "  repair available: %s\n", v.Repair)
+			left++
+			continue
+		}
+
+		if !*yes {
+			fmt.Fprintf(out, "  %s? [y/N] ", v.Repair)
+			if !answers.Scan() || !strings.EqualFold(strings.TrimSpace(answers.Text()), "y") {
+				left++
+				continue
+			}
+		}
+
+		if err := database.Repair(v); err != nil {
+			return errors.Wrapf(err, "repairing %s", v.Kind)
+		}
+		fmt.Fprintln(out, "  repaired")
+	}
+
+	if left > 0 {
+		return errors.Errorf("%d of %d violations left", left, len(violations))
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package watchdog
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// WatchdogMock is a mocked implementation of a watchdog.
+type WatchdogMock struct {
+	mock.Mock
+}
+
+// NewWatchdogMock returns a mocked watchdog.
+func NewWatchdogMock() *WatchdogMock {
+	return &WatchdogMock{}
+}
+
+// Observe mock.
+func (w *WatchdogMock) Observe(height uint32) {
+	_ = w.Called(height)
+}
+
+// Start mock.
+func (w *WatchdogMock) Start(ctx context.Context) error {
+	args := w.Called(ctx)
+	return args.Error(0)
+}
+
+// Verify mock.
+func (w *WatchdogMock) Verify(ctx context.Context, height uint32, hash string) error {
+	args := w.Called(ctx, height, hash)
+	return args.Error(0)
+}
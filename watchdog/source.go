@@ -0,0 +1,185 @@
+package watchdog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/pkg/errors"
+)
+
+// Chain sources
+const (
+	SourceBitcoind = "bitcoind"
+	SourceEsplora  = "esplora"
+)
+
+// Source is a secondary source of the blockchain state.
+type Source interface {
+	BlockHash(ctx context.Context, height uint32) (string, error)
+	BlockHeight(ctx context.Context) (uint32, error)
+}
+
+// newSource returns the chain source configured.
+func newSource(config config.Watchdog) (Source, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	url := strings.TrimSuffix(config.URL, "/")
+
+	switch config.Source {
+	case SourceBitcoind:
+		return &bitcoind{
+			client:   client,
+			url:      url,
+			user:     config.RPCUser,
+			password: config.RPCPassword,
+		}, nil
+	case SourceEsplora:
+		return &esplora{
+			client: client,
+			url:    url,
+		}, nil
+	default:
+		return nil, errors.Errorf("invalid chain source %q", config.Source)
+	}
+}
+
+// esplora communicates with an Esplora REST API, like the one of mempool.space or blockstream.info.
+type esplora struct {
+	client *http.Client
+	url    string
+}
+
+// BlockHash returns the hash of the block at the height specified.
+func (e *esplora) BlockHash(ctx context.Context, height uint32) (string, error) {
+	return e.get(ctx, fmt.Sprintf("/block-height/%d", height))
+}
+
+// BlockHeight returns the height of the chain tip.
+func (e *esplora) BlockHeight(ctx context.Context) (uint32, error) {
+	body, err := e.get(ctx, "/blocks/tip/height")
+	if err != nil {
+		return 0, err
+	}
+
+	height, err := strconv.ParseUint(body, 10, 32)
+	if err != nil {
+		return 0, errors.Wrap(err, "parsing block height")
+	}
+
+	return uint32(height), nil
+}
+
+func (e *esplora) get(ctx context.Context, path string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.url+path, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "creating request")
+	}
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "sending request")
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<10))
+	if err != nil {
+		return "", errors.Wrap(err, "reading response")
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", errors.Errorf("esplora error: %s", body)
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      string `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+type rpcResponse struct {
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Result json.RawMessage `json:"result"`
+}
+
+// bitcoind communicates with a Bitcoin Core JSON-RPC server.
+type bitcoind struct {
+	client   *http.Client
+	url      string
+	user     string
+	password string
+}
+
+// BlockHash returns the hash of the block at the height specified.
+func (b *bitcoind) BlockHash(ctx context.Context, height uint32) (string, error) {
+	var hash string
+	if err := b.call(ctx, "getblockhash", []any{height}, &hash); err != nil {
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// BlockHeight returns the height of the chain tip.
+func (b *bitcoind) BlockHeight(ctx context.Context) (uint32, error) {
+	var height uint32
+	if err := b.call(ctx, "getblockcount", []any{}, &height); err != nil {
+		return 0, err
+	}
+
+	return height, nil
+}
+
+func (b *bitcoind) call(ctx context.Context, method string, params []any, result any) error {
+	body, err := json.Marshal(rpcRequest{
+		JSONRPC: "1.0",
+		ID:      "btry",
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return errors.Wrap(err, "encoding request")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(b.user, b.password)
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "sending request")
+	}
+	defer res.Body.Close()
+
+	// bitcoind responds with an error status code along with the error in the body
+	var resp rpcResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return errors.Wrapf(err, "decoding %s response, status %d", method, res.StatusCode)
+	}
+
+	if resp.Error != nil {
+		return errors.Errorf("bitcoind error: %s", resp.Error.Message)
+	}
+
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return errors.Wrapf(err, "decoding %s result", method)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package watchdog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const blockHash = "000000000000000000003bc0544004a6e74beb66b21b1e564eb81dbd478d67c6"
+
+func TestEsplora(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/blocks/tip/height":
+			w.Write([]byte("900001"))
+		case "/api/block-height/900000":
+			w.Write([]byte(blockHash))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("Block not found"))
+		}
+	}))
+	defer server.Close()
+
+	source, err := newSource(config.Watchdog{Source: SourceEsplora, URL: server.URL + "/api/"})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	height, err := source.BlockHeight(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(900_001), height)
+
+	hash, err := source.BlockHash(ctx, 900_000)
+	assert.NoError(t, err)
+	assert.Equal(t, blockHash, hash)
+
+	_, err = source.BlockHash(ctx, 900_002)
+	assert.ErrorContains(t, err, "Block not found")
+}
+
+func TestBitcoind(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "user", user)
+		assert.Equal(t, "pass", password)
+
+		var req rpcRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		switch req.Method {
+		case "getblockcount":
+			w.Write([]byte(`{"result":900001,"error":null,"id":"btry"}`))
+		case "getblockhash":
+			if req.Params[0] == float64(900_000) {
+				w.Write([]byte(`{"result":"` + blockHash + `","error":null,"id":"btry"}`))
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"result":null,"error":{"code":-8,"message":"Block height out of range"},"id":"btry"}`))
+		}
+	}))
+	defer server.Close()
+
+	source, err := newSource(config.Watchdog{
+		Source:      SourceBitcoind,
+		URL:         server.URL,
+		RPCUser:     "user",
+		RPCPassword: "pass",
+	})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	height, err := source.BlockHeight(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(900_001), height)
+
+	hash, err := source.BlockHash(ctx, 900_000)
+	assert.NoError(t, err)
+	assert.Equal(t, blockHash, hash)
+
+	_, err = source.BlockHash(ctx, 900_002)
+	assert.ErrorContains(t, err, "Block height out of range")
+}
@@ -0,0 +1,215 @@
+// Package watchdog cross-checks the blocks received from the lightning node against a secondary
+// chain source, so lotteries are not drawn with a stale or forked block feed.
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/lightning"
+	"github.com/aftermath2/BTRY/logger"
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrStalled is returned when the node stopped receiving blocks the secondary source has.
+	ErrStalled = errors.New("block feed stalled")
+	// ErrDiverged is returned when the node and the secondary source heights are too far apart.
+	ErrDiverged = errors.New("block heights diverged")
+	// ErrHashMismatch is returned when the node and the secondary source disagree on a block hash.
+	ErrHashMismatch = errors.New("block hash mismatch")
+)
+
+// Watchdog monitors the node block feed.
+type Watchdog interface {
+	Observe(height uint32)
+	Start(ctx context.Context) error
+	Verify(ctx context.Context, height uint32, hash string) error
+}
+
+type watchdog struct {
+	source        Source
+	lnd           lightning.NodeInfo
+	notifier      notification.Notifier
+	logger        *logger.Logger
+	now           func() time.Time
+	lastBlockAt   time.Time
+	issue         error
+	interval      time.Duration
+	stallTimeout  time.Duration
+	alertChatID   int64
+	height        uint32
+	sourceHeight  uint32
+	maxDivergence uint32
+	pauseDraws    bool
+	enabled       bool
+	mu            sync.Mutex
+}
+
+// New returns a new block feed watchdog.
+func New(config config.Watchdog, lnd lightning.NodeInfo, notifier notification.Notifier) (Watchdog, error) {
+	logger, err := logger.New(config.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var source Source
+	if config.Enabled {
+		source, err = newSource(config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &watchdog{
+		source:        source,
+		lnd:           lnd,
+		notifier:      notifier,
+		logger:        logger,
+		now:           time.Now,
+		interval:      config.Interval,
+		stallTimeout:  config.StallTimeout,
+		alertChatID:   config.AlertChatID,
+		maxDivergence: config.MaxDivergence,
+		pauseDraws:    config.PauseDraws,
+		enabled:       config.Enabled,
+	}, nil
+}
+
+// Observe records a block received from the node.
+func (w *watchdog) Observe(height uint32) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.height = height
+	w.lastBlockAt = w.now()
+}
+
+// Start executes the loop that compares the node and the secondary source periodically.
+func (w *watchdog) Start(ctx context.Context) error {
+	if !w.enabled {
+		w.logger.Info("Watchdog disabled")
+		return nil
+	}
+
+	info, err := w.lnd.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+	w.Observe(info.BlockHeight)
+
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := w.check(ctx); err != nil {
+				w.logger.Error(err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Verify returns an error if the block feed is unhealthy or the hash of the block at the height
+// specified differs from the one of the secondary source, only if draws should be paused.
+//
+// An unreachable secondary source doesn't pause the draws, it's not meant to be a point of failure.
+func (w *watchdog) Verify(ctx context.Context, height uint32, hash string) error {
+	if !w.enabled {
+		return nil
+	}
+
+	w.mu.Lock()
+	issue := w.status()
+	w.mu.Unlock()
+
+	if issue == nil {
+		sourceHash, err := w.source.BlockHash(ctx, height)
+		if err != nil {
+			w.logger.Error(errors.Wrap(err, "getting block hash from secondary source"))
+			return nil
+		}
+
+		if sourceHash != hash {
+			issue = errors.Wrapf(ErrHashMismatch, "block %d hash is %s but the secondary source has %s",
+				height, hash, sourceHash)
+			w.alert(issue.Error())
+		}
+	}
+
+	if !w.pauseDraws {
+		return nil
+	}
+
+	return issue
+}
+
+// check updates the secondary source height and alerts when the feed health changes.
+func (w *watchdog) check(ctx context.Context) error {
+	sourceHeight, err := w.source.BlockHeight(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting block height from secondary source")
+	}
+
+	w.mu.Lock()
+	w.sourceHeight = sourceHeight
+	height := w.height
+	previous := w.issue
+	w.issue = w.status()
+	issue := w.issue
+	w.mu.Unlock()
+
+	w.logger.Debugf("Node height: %d, secondary source height: %d", height, sourceHeight)
+
+	// Alert only when the kind of issue changes, the heights in the message do on every check
+	switch {
+	case issue != nil && errors.Cause(issue) != errors.Cause(previous):
+		w.alert(issue.Error())
+	case issue == nil && previous != nil:
+		w.alert("Block feed recovered")
+	}
+
+	return nil
+}
+
+// status returns the issue the block feed has, if any. It must be called holding the lock.
+func (w *watchdog) status() error {
+	if w.sourceHeight == 0 {
+		// The secondary source wasn't reached yet
+		return nil
+	}
+
+	if w.sourceHeight > w.height+w.maxDivergence || w.height > w.sourceHeight+w.maxDivergence {
+		return errors.Wrapf(ErrDiverged, "node height is %d and secondary source height is %d",
+			w.height, w.sourceHeight)
+	}
+
+	elapsed := w.now().Sub(w.lastBlockAt)
+	if w.sourceHeight > w.height && elapsed > w.stallTimeout {
+		return errors.Wrapf(ErrStalled,
+			"no block received for %s, node height is %d and secondary source height is %d",
+			elapsed.Truncate(time.Second), w.height, w.sourceHeight)
+	}
+
+	return nil
+}
+
+func (w *watchdog) alert(message string) {
+	w.logger.Warning(message)
+	if w.alertChatID != 0 {
+		w.notifier.Notify(w.alertChatID, fmt.Sprintf("Watchdog: %s", message))
+	}
+}
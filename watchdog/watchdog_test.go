@@ -0,0 +1,128 @@
+package watchdog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/logger"
+	"github.com/aftermath2/BTRY/notification"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const alertChatID = 1
+
+type sourceMock struct {
+	mock.Mock
+}
+
+func (s *sourceMock) BlockHash(ctx context.Context, height uint32) (string, error) {
+	args := s.Called(ctx, height)
+	return args.String(0), args.Error(1)
+}
+
+func (s *sourceMock) BlockHeight(ctx context.Context) (uint32, error) {
+	args := s.Called(ctx)
+	return args.Get(0).(uint32), args.Error(1)
+}
+
+func setupWatchdog(t *testing.T, now *time.Time) (*watchdog, *sourceMock, *notification.NotifierMock) {
+	t.Helper()
+
+	logger, err := logger.New(config.Logger{Level: uint8(logger.DISABLED)})
+	assert.NoError(t, err)
+
+	source := &sourceMock{}
+	notifierMock := notification.NewNotifierMock()
+
+	watchdog := &watchdog{
+		source:        source,
+		notifier:      notifierMock,
+		logger:        logger,
+		now:           func() time.Time { return *now },
+		stallTimeout:  time.Hour,
+		alertChatID:   alertChatID,
+		maxDivergence: 2,
+		pauseDraws:    true,
+		enabled:       true,
+	}
+
+	return watchdog, source, notifierMock
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	watchdog, source, notifierMock := setupWatchdog(t, &now)
+	notifierMock.On("Notify", int64(alertChatID), mock.Anything)
+
+	watchdog.Observe(900_000)
+
+	source.On("BlockHeight", ctx).Return(uint32(900_001), nil).Once()
+	assert.NoError(t, watchdog.check(ctx))
+	assert.NoError(t, watchdog.issue)
+
+	// A block is late but the feed did not stall yet
+	now = now.Add(59 * time.Minute)
+	source.On("BlockHeight", ctx).Return(uint32(900_001), nil).Once()
+	assert.NoError(t, watchdog.check(ctx))
+	assert.NoError(t, watchdog.issue)
+	notifierMock.AssertNotCalled(t, "Notify", int64(alertChatID), mock.Anything)
+
+	now = now.Add(2 * time.Minute)
+	source.On("BlockHeight", ctx).Return(uint32(900_002), nil).Once()
+	assert.NoError(t, watchdog.check(ctx))
+	assert.ErrorIs(t, watchdog.issue, ErrStalled)
+
+	source.On("BlockHeight", ctx).Return(uint32(900_003), nil).Once()
+	assert.NoError(t, watchdog.check(ctx))
+	assert.ErrorIs(t, watchdog.issue, ErrDiverged)
+
+	// Same issue, no new alert
+	source.On("BlockHeight", ctx).Return(uint32(900_004), nil).Once()
+	assert.NoError(t, watchdog.check(ctx))
+	notifierMock.AssertNumberOfCalls(t, "Notify", 2)
+
+	watchdog.Observe(900_004)
+	source.On("BlockHeight", ctx).Return(uint32(900_004), nil).Once()
+	assert.NoError(t, watchdog.check(ctx))
+	assert.NoError(t, watchdog.issue)
+	notifierMock.AssertCalled(t, "Notify", int64(alertChatID), "Watchdog: Block feed recovered")
+	notifierMock.AssertNumberOfCalls(t, "Notify", 3)
+
+	source.On("BlockHeight", ctx).Return(uint32(0), errors.New("timeout")).Once()
+	assert.Error(t, watchdog.check(ctx))
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	watchdog, source, notifierMock := setupWatchdog(t, &now)
+	notifierMock.On("Notify", int64(alertChatID), mock.Anything)
+	watchdog.Observe(900_000)
+
+	source.On("BlockHash", ctx, uint32(900_000)).Return(blockHash, nil).Once()
+	assert.NoError(t, watchdog.Verify(ctx, 900_000, blockHash))
+
+	source.On("BlockHash", ctx, uint32(900_000)).Return("00", nil).Once()
+	assert.ErrorIs(t, watchdog.Verify(ctx, 900_000, blockHash), ErrHashMismatch)
+
+	// The secondary source is not a point of failure
+	source.On("BlockHash", ctx, uint32(900_000)).Return("", errors.New("timeout")).Once()
+	assert.NoError(t, watchdog.Verify(ctx, 900_000, blockHash))
+
+	watchdog.sourceHeight = 900_010
+	assert.ErrorIs(t, watchdog.Verify(ctx, 900_000, blockHash), ErrDiverged)
+
+	// Only alerts if draws shouldn't be paused
+	watchdog.pauseDraws = false
+	assert.NoError(t, watchdog.Verify(ctx, 900_000, blockHash))
+
+	watchdog.enabled = false
+	assert.NoError(t, watchdog.Verify(ctx, 900_000, blockHash))
+	source.AssertExpectations(t)
+}
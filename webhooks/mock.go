@@ -0,0 +1,28 @@
+package webhooks
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// PublisherMock is a mocked implementation of a webhooks publisher.
+type PublisherMock struct {
+	mock.Mock
+}
+
+// NewPublisherMock returns a mocked webhooks publisher.
+func NewPublisherMock() *PublisherMock {
+	return &PublisherMock{}
+}
+
+// Publish mock.
+func (p *PublisherMock) Publish(event Event, data any) {
+	_ = p.Called(event, data)
+}
+
+// ValidateURL mock.
+func (p *PublisherMock) ValidateURL(ctx context.Context, rawURL string) error {
+	args := p.Called(ctx, rawURL)
+	return args.Error(0)
+}
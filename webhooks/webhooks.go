@@ -0,0 +1,367 @@
+// Package webhooks delivers the lottery events to the URLs integrators subscribed to them.
+//
+// Payloads are signed with the secret of each webhook so receivers can verify them, deliveries
+// are executed by the jobs queue, which retries the failed ones with exponential backoff, and
+// every attempt is logged in the database.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/jobs"
+	"github.com/aftermath2/BTRY/logger"
+
+	"github.com/pkg/errors"
+)
+
+// jobDeliver is the kind of the job that delivers an event to a webhook. It is stored in the
+// database, do not rename it.
+const jobDeliver = "webhook_delivery"
+
+const defaultTimeout = 10 * time.Second
+
+// ErrNonPublicAddress is returned when a webhook URL points to an address that isn't reachable
+// from the internet, so integrators can't make the server send requests to its own network.
+var ErrNonPublicAddress = errors.New("webhook url must resolve to a public address")
+
+// reservedPrefixes are the ranges not routable on the internet that the netip package doesn't
+// classify: "this network" (RFC 1122) and the carrier-grade NAT shared space (RFC 6598).
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// Headers sent along with the deliveries.
+const (
+	EventHeader     = "X-BTRY-Event"
+	DeliveryHeader  = "X-BTRY-Delivery"
+	TimestampHeader = "X-BTRY-Timestamp"
+	SignatureHeader = "X-BTRY-Signature"
+)
+
+// Event is an action integrators can subscribe to.
+type Event string
+
+// Events
+const (
+	DrawCompleted   Event = "draw_completed"
+	WinnerAnnounced Event = "winner_announced"
+	PayoutSent      Event = "payout_sent"
+	CapacityChanged Event = "capacity_changed"
+)
+
+var events = []Event{DrawCompleted, WinnerAnnounced, PayoutSent, CapacityChanged}
+
+// ParseEvents parses a comma-separated list of events.
+func ParseEvents(s string) ([]string, error) {
+	var parsed []string
+	for _, value := range strings.Split(s, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		valid := false
+		for _, event := range events {
+			if Event(value) == event {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, errors.Errorf("invalid event %q", value)
+		}
+
+		parsed = append(parsed, value)
+	}
+
+	if len(parsed) == 0 {
+		return nil, errors.New("no events specified")
+	}
+
+	return parsed, nil
+}
+
+// Publisher publishes events to the webhooks subscribed to them.
+type Publisher interface {
+	Publish(event Event, data any)
+	ValidateURL(ctx context.Context, rawURL string) error
+}
+
+// Payload is the body of the requests sent to the webhooks.
+type Payload struct {
+	Data      json.RawMessage `json:"data"`
+	ID        string          `json:"id"`
+	Event     Event           `json:"event"`
+	Timestamp int64           `json:"timestamp"`
+}
+
+// delivery is the payload of the delivery jobs.
+type delivery struct {
+	Payload   Payload `json:"payload"`
+	WebhookID uint64  `json:"webhook_id"`
+}
+
+type dispatcher struct {
+	db       *db.DB
+	logger   *logger.Logger
+	client   *http.Client
+	queue    jobs.Queue
+	resolver *net.Resolver
+	now      func() time.Time
+	// tor is set when the requests are routed through the Tor proxy, which resolves the hosts
+	tor          bool
+	allowPrivate bool
+}
+
+// New returns a new webhooks dispatcher. It registers the job that delivers the events in the
+// queue, so it must be created before starting it.
+func New(config config.Webhooks, db *db.DB, queue jobs.Queue, torClient *http.Client) (Publisher, error) {
+	logger, err := logger.New(config.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	client := &http.Client{Timeout: timeout}
+	switch {
+	case config.Tor:
+		client = &http.Client{Transport: torClient.Transport, Timeout: timeout}
+	case !config.AllowPrivate:
+		// The address is checked once resolved, when connecting, so a host can't resolve to a
+		// public address when subscribed and to a private one when the events are delivered
+		dialer := &net.Dialer{Timeout: timeout, Control: checkDialAddress}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = dialer.DialContext
+		client = &http.Client{Transport: transport, Timeout: timeout}
+	}
+
+	d := &dispatcher{
+		db:           db,
+		logger:       logger,
+		client:       client,
+		queue:        queue,
+		resolver:     net.DefaultResolver,
+		now:          time.Now,
+		tor:          config.Tor,
+		allowPrivate: config.AllowPrivate,
+	}
+	queue.Register(jobDeliver, d.deliver)
+
+	return d, nil
+}
+
+// Publish enqueues the delivery of the event to every webhook subscribed to it. Errors are logged
+// and not returned so they don't interrupt the operation that triggered the event.
+func (d *dispatcher) Publish(event Event, data any) {
+	webhooks, err := d.db.Webhooks.ListByEvent(string(event))
+	if err != nil {
+		d.logger.Error(errors.Wrapf(err, "listing %s webhooks", event))
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		d.logger.Error(errors.Wrapf(err, "encoding %s event data", event))
+		return
+	}
+
+	timestamp := d.now().Unix()
+	for _, webhook := range webhooks {
+		id, err := deliveryID()
+		if err != nil {
+			d.logger.Error(err)
+			return
+		}
+
+		job := delivery{
+			WebhookID: webhook.ID,
+			Payload: Payload{
+				ID:        id,
+				Event:     event,
+				Timestamp: timestamp,
+				Data:      encoded,
+			},
+		}
+		if err := d.queue.Enqueue(jobDeliver, job); err != nil {
+			d.logger.Error(errors.Wrapf(err, "enqueuing webhook %d delivery", webhook.ID))
+		}
+	}
+}
+
+// ValidateURL checks that the URL is an http or https address whose host resolves to public
+// addresses only. Hosts are resolved by the Tor proxy when it's used, only literal addresses are
+// checked then.
+func (d *dispatcher) ValidateURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("invalid url, it must be an http or https address")
+	}
+
+	if d.allowPrivate {
+		return nil
+	}
+
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !publicAddress(addr) {
+			return ErrNonPublicAddress
+		}
+		return nil
+	}
+
+	if d.tor {
+		return nil
+	}
+
+	addrs, err := d.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return errors.Wrapf(err, "resolving %s", host)
+	}
+
+	for _, addr := range addrs {
+		if !publicAddress(addr) {
+			return ErrNonPublicAddress
+		}
+	}
+
+	return nil
+}
+
+// deliver sends the payload to the webhook and logs the attempt. Deliveries to webhooks deleted
+// in the meantime are dropped.
+func (d *dispatcher) deliver(ctx context.Context, payload []byte) error {
+	var job delivery
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return errors.Wrap(err, "decoding job payload")
+	}
+
+	webhook, err := d.db.Webhooks.Get(job.WebhookID)
+	if err != nil {
+		if errors.Is(err, db.ErrNoWebhook) {
+			return nil
+		}
+		return err
+	}
+
+	statusCode, err := d.send(ctx, webhook, job.Payload)
+
+	attempt := db.WebhookDelivery{
+		WebhookID:  webhook.ID,
+		DeliveryID: job.Payload.ID,
+		Event:      string(job.Payload.Event),
+		StatusCode: statusCode,
+		CreatedAt:  d.now().Unix(),
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	if err := d.db.Webhooks.AddDelivery(attempt); err != nil {
+		d.logger.Error(err)
+	}
+
+	return err
+}
+
+func (d *dispatcher) send(ctx context.Context, webhook db.Webhook, payload Payload) (int, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, errors.Wrap(err, "encoding payload")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, errors.Wrap(err, "creating request")
+	}
+
+	timestamp := strconv.FormatInt(payload.Timestamp, 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(payload.Event))
+	req.Header.Set(DeliveryHeader, payload.ID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "sending request")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return res.StatusCode, errors.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 of the timestamp and the body joined by a dot, using
+// the webhook secret as the key. Including the timestamp lets receivers reject replayed requests.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkDialAddress rejects the connections to non-public addresses.
+func checkDialAddress(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return errors.Wrapf(err, "parsing address %s", address)
+	}
+
+	if !publicAddress(addrPort.Addr()) {
+		return ErrNonPublicAddress
+	}
+
+	return nil
+}
+
+// publicAddress returns whether the address is routable on the internet.
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func deliveryID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.Wrap(err, "generating delivery id")
+	}
+	return hex.EncodeToString(buf), nil
+}
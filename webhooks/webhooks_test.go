@@ -0,0 +1,201 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/aftermath2/BTRY/config"
+	"github.com/aftermath2/BTRY/db"
+	"github.com/aftermath2/BTRY/jobs"
+	"github.com/aftermath2/BTRY/webhooks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const secret = "secret"
+
+func setupDispatcher(
+	t *testing.T,
+	webhooksConfig config.Webhooks,
+) (webhooks.Publisher, *db.DB, *jobs.QueueMock, *jobs.Handler) {
+	t.Helper()
+
+	file, err := os.CreateTemp("", "*")
+	assert.NoError(t, err)
+
+	database, err := db.Open(config.DB{Path: file.Name()})
+	assert.NoError(t, err)
+
+	t.Cleanup(func() {
+		database.Close()
+		file.Close()
+		os.Remove(file.Name())
+	})
+
+	var deliver jobs.Handler
+	queueMock := jobs.NewQueueMock()
+	queueMock.On("Register", "webhook_delivery", mock.Anything).Run(func(args mock.Arguments) {
+		deliver = args.Get(1).(jobs.Handler)
+	})
+
+	publisher, err := webhooks.New(webhooksConfig, database, queueMock, nil)
+	assert.NoError(t, err)
+
+	return publisher, database, queueMock, &deliver
+}
+
+func addWebhook(t *testing.T, database *db.DB, url string, events ...string) db.Webhook {
+	t.Helper()
+
+	apiKey, err := database.APIKeys.Add("integrator", "hash", []db.Scope{db.ScopeWebhooks}, 0)
+	assert.NoError(t, err)
+
+	webhook, err := database.Webhooks.Add(apiKey.ID, url, secret, events)
+	assert.NoError(t, err)
+
+	return webhook
+}
+
+func TestParseEvents(t *testing.T) {
+	events, err := webhooks.ParseEvents("draw_completed, payout_sent")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"draw_completed", "payout_sent"}, events)
+
+	_, err = webhooks.ParseEvents("draw_completed,unknown")
+	assert.Error(t, err)
+
+	_, err = webhooks.ParseEvents("")
+	assert.Error(t, err)
+}
+
+func TestPublishAndDeliver(t *testing.T) {
+	var (
+		body    []byte
+		headers http.Header
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		headers = r.Header
+	}))
+	defer server.Close()
+
+	publisher, database, queueMock, deliver := setupDispatcher(t, config.Webhooks{AllowPrivate: true})
+	webhook := addWebhook(t, database, server.URL, "draw_completed")
+
+	var payload []byte
+	queueMock.On("Enqueue", "webhook_delivery", mock.Anything).Run(func(args mock.Arguments) {
+		var err error
+		payload, err = json.Marshal(args.Get(1))
+		assert.NoError(t, err)
+	}).Return(nil).Once()
+
+	publisher.Publish(webhooks.DrawCompleted, map[string]uint32{"lottery_height": 100})
+	// Nobody is subscribed to this event
+	publisher.Publish(webhooks.PayoutSent, nil)
+	queueMock.AssertNumberOfCalls(t, "Enqueue", 1)
+
+	err := (*deliver)(context.Background(), payload)
+	assert.NoError(t, err)
+
+	var received webhooks.Payload
+	assert.NoError(t, json.Unmarshal(body, &received))
+	assert.Equal(t, webhooks.DrawCompleted, received.Event)
+	assert.JSONEq(t, `{"lottery_height":100}`, string(received.Data))
+	assert.Equal(t, received.ID, headers.Get(webhooks.DeliveryHeader))
+	assert.Equal(t, "draw_completed", headers.Get(webhooks.EventHeader))
+
+	timestamp := headers.Get(webhooks.TimestampHeader)
+	assert.Equal(t, webhooks.Sign(secret, timestamp, body), headers.Get(webhooks.SignatureHeader))
+
+	deliveries, err := database.Webhooks.ListDeliveries(webhook.ID, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+	assert.Equal(t, received.ID, deliveries[0].DeliveryID)
+	assert.Empty(t, deliveries[0].Error)
+}
+
+func TestDeliverFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, database, _, deliver := setupDispatcher(t, config.Webhooks{AllowPrivate: true})
+	webhook := addWebhook(t, database, server.URL, "payout_sent")
+
+	payload := []byte(`{"webhook_id":1,"payload":{"id":"abc","event":"payout_sent","timestamp":1,"data":{}}}`)
+	err := (*deliver)(context.Background(), payload)
+	assert.Error(t, err)
+
+	deliveries, err := database.Webhooks.ListDeliveries(webhook.ID, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, http.StatusServiceUnavailable, deliveries[0].StatusCode)
+	assert.NotEmpty(t, deliveries[0].Error)
+
+	// Deliveries to deleted webhooks are dropped
+	assert.NoError(t, database.Webhooks.Delete(webhook.ID, webhook.APIKeyID))
+	err = (*deliver)(context.Background(), payload)
+	assert.NoError(t, err)
+}
+
+func TestValidateURL(t *testing.T) {
+	publisher, _, _, _ := setupDispatcher(t, config.Webhooks{})
+	ctx := context.Background()
+
+	assert.NoError(t, publisher.ValidateURL(ctx, "https://203.0.113.10/hook"))
+	assert.NoError(t, publisher.ValidateURL(ctx, "http://[2001:db8::1]:8080"))
+
+	invalid := []string{"ftp://203.0.113.10", "https://", "203.0.113.10"}
+	for _, url := range invalid {
+		assert.Error(t, publisher.ValidateURL(ctx, url), url)
+	}
+
+	nonPublic := []string{
+		"http://127.0.0.1:8080",
+		"http://localhost",
+		"https://10.0.0.1",
+		"https://192.168.1.1",
+		"https://100.64.0.1",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0",
+		"http://[::1]",
+		"http://[fd00::1]",
+		"http://[::ffff:127.0.0.1]",
+	}
+	for _, url := range nonPublic {
+		assert.ErrorIs(t, publisher.ValidateURL(ctx, url), webhooks.ErrNonPublicAddress, url)
+	}
+
+	t.Run("Allow private", func(t *testing.T) {
+		publisher, _, _, _ := setupDispatcher(t, config.Webhooks{AllowPrivate: true})
+		assert.NoError(t, publisher.ValidateURL(ctx, "http://127.0.0.1:8080"))
+	})
+}
+
+func TestDeliverNonPublicAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request received")
+	}))
+	defer server.Close()
+
+	// Hosts may resolve to a different address after subscribing, the one dialed is checked
+	_, database, _, deliver := setupDispatcher(t, config.Webhooks{})
+	webhook := addWebhook(t, database, server.URL, "payout_sent")
+
+	payload := []byte(`{"webhook_id":1,"payload":{"id":"abc","event":"payout_sent","timestamp":1,"data":{}}}`)
+	err := (*deliver)(context.Background(), payload)
+	assert.ErrorIs(t, err, webhooks.ErrNonPublicAddress)
+
+	deliveries, err := database.Webhooks.ListDeliveries(webhook.ID, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.Contains(t, deliveries[0].Error, webhooks.ErrNonPublicAddress.Error())
+}